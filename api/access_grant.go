@@ -0,0 +1,37 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+)
+
+// AccessGrantMaxDuration is the longest time for which an access grant can be created.
+const AccessGrantMaxDuration = 30 * 24 * time.Hour
+
+type CreateAccessGrantRequest struct {
+	UserAccountID         uuid.UUID  `json:"userAccountId"`
+	DeploymentTargetID    *uuid.UUID `json:"deploymentTargetId"`
+	CustomerUserAccountID *uuid.UUID `json:"customerUserAccountId"`
+	Reason                string     `json:"reason"`
+	ExpiresAt             time.Time  `json:"expiresAt"`
+}
+
+// Validate checks the request at time now.
+func (r *CreateAccessGrantRequest) Validate(now time.Time) error {
+	if r.UserAccountID == uuid.Nil {
+		return validation.NewValidationFailedError("userAccountId is empty")
+	} else if (r.DeploymentTargetID == nil) == (r.CustomerUserAccountID == nil) {
+		return validation.NewValidationFailedError(
+			"exactly one of deploymentTargetId and customerUserAccountId must be set")
+	} else if strings.TrimSpace(r.Reason) == "" {
+		return validation.NewValidationFailedError("reason is empty")
+	} else if !r.ExpiresAt.After(now) {
+		return validation.NewValidationFailedError("expiresAt must be in the future")
+	} else if r.ExpiresAt.Sub(now) > AccessGrantMaxDuration {
+		return validation.NewValidationFailedError("expiresAt must not be more than 30 days in the future")
+	}
+	return nil
+}
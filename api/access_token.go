@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/glasskube/distr/internal/authkey"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
 	"github.com/google/uuid"
 )
 
@@ -13,6 +15,9 @@ type AccessToken struct {
 	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
 	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
 	Label      *string    `json:"label,omitempty"`
+	KeyPrefix  string     `json:"keyPrefix"`
+	// RegistryScopes limit the token to these repositories of the artifact registry, if set.
+	RegistryScopes types.RegistryScopes `json:"registryScopes,omitempty"`
 }
 
 func (obj AccessToken) WithKey(key authkey.Key) AccessTokenWithKey {
@@ -27,4 +32,46 @@ type AccessTokenWithKey struct {
 type CreateAccessTokenRequest struct {
 	ExpiresAt *time.Time `json:"expiresAt"`
 	Label     *string    `json:"label"`
+	// RegistryScopes create a token that can only be used for these actions on these repositories of the artifact
+	// registry and not for the API.
+	RegistryScopes types.RegistryScopes `json:"registryScopes,omitempty"`
+}
+
+func (r *CreateAccessTokenRequest) Validate() error {
+	if r.RegistryScopes != nil && len(r.RegistryScopes) == 0 {
+		return validation.NewValidationFailedError("registryScopes must not be empty")
+	}
+	for _, scope := range r.RegistryScopes {
+		if err := scope.Validate(); err != nil {
+			return validation.NewValidationFailedError(err.Error())
+		}
+	}
+	return nil
+}
+
+// OrganizationAccessToken is an access token of any user or service account of the organization. The key itself is
+// never included.
+type OrganizationAccessToken struct {
+	AccessToken
+	UserAccountID *uuid.UUID `json:"userAccountId,omitempty"`
+	UserEmail     string     `json:"userEmail,omitempty"`
+	UserName      string     `json:"userName,omitempty"`
+	// UserRole is empty if the user is not a member of the organization anymore.
+	UserRole types.UserRole `json:"userRole,omitempty"`
+	// Customer is set for tokens of customers, which are often used to automate pulls from the registry.
+	Customer           bool       `json:"customer"`
+	ServiceAccountID   *uuid.UUID `json:"serviceAccountId,omitempty"`
+	ServiceAccountName string     `json:"serviceAccountName,omitempty"`
+}
+
+// RevokeAccessTokensRequest revokes all access tokens of a user in the current organization.
+type RevokeAccessTokensRequest struct {
+	UserAccountID uuid.UUID `json:"userAccountId"`
+}
+
+func (r *RevokeAccessTokensRequest) Validate() error {
+	if r.UserAccountID == uuid.Nil {
+		return validation.NewValidationFailedError("userAccountId is empty")
+	}
+	return nil
 }
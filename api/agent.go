@@ -1,17 +1,97 @@
 package api
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
 	"github.com/google/uuid"
 )
 
+// These codes are sent in an ErrorResponse to agents with status 410 Gone. Agents must stop polling and discard their
+// credentials when they receive one of them, because no request will ever succeed again.
+const (
+	AgentGoneCodeArchived = "deployment_target_archived"
+	AgentGoneCodeDeleted  = "deployment_target_deleted"
+)
+
 type AgentResource struct {
 	Version        types.AgentVersion `json:"version"`
 	Namespace      string             `json:"namespace,omitempty"`
 	MetricsEnabled bool               `json:"metricsEnabled"`
+	// InventoryEnabled is true if the agent should periodically report the workloads that exist on the host.
+	InventoryEnabled bool `json:"inventoryEnabled"`
 	// Deprecated: This property will be removed in v2. Please consider using Deployments instead.
 	Deployment  *AgentDeployment  `json:"deployment,omitempty"`
 	Deployments []AgentDeployment `json:"deployments,omitempty"`
+	// ConnectivityCheck is set if the agent should test its network connectivity and report the results.
+	ConnectivityCheck *AgentConnectivityCheck `json:"connectivityCheck,omitempty"`
+	// FileRetrievals are the pending requests for files on the host that the agent should upload.
+	FileRetrievals []AgentFileRetrieval `json:"fileRetrievals,omitempty"`
+	// Migration is set if the deployment target has been imported in another Distr instance
+	// and the agent should connect to that instance instead.
+	Migration *AgentMigration `json:"migration,omitempty"`
+	// Hold is set while Distr is in maintenance mode.
+	Hold *AgentHold `json:"hold,omitempty"`
+	// DataCollection is the effective data collection policy of the deployment target.
+	DataCollection types.DataCollection `json:"dataCollection"`
+	// Announcements are the active announcements of the vendor that are addressed to this deployment target, so
+	// that tooling on the host can display them.
+	Announcements []AgentAnnouncement `json:"announcements,omitempty"`
+	// ResourceLimits are the effective resource limits of the deployment target. Agents report the values they
+	// applied with an AgentResourceLimitsReport.
+	ResourceLimits types.AgentResourceLimits `json:"resourceLimits"`
+}
+
+// ApplyDataCollection disables everything in r that would collect data not allowed by r.DataCollection.
+// It is applied by the server before sending the resource and again by the agent after receiving it.
+func (r *AgentResource) ApplyDataCollection() {
+	if r.DataCollection.MetricsDisabled {
+		r.MetricsEnabled = false
+	}
+	if r.DataCollection.DiagnosticsDisabled {
+		r.ConnectivityCheck = nil
+		r.FileRetrievals = nil
+	}
+	if r.DataCollection.InventoryDisabled {
+		r.InventoryEnabled = false
+	}
+	for i := range r.Deployments {
+		r.Deployments[i].applyDataCollection(r.DataCollection)
+	}
+	//nolint:staticcheck
+	if r.Deployment != nil {
+		r.Deployment.applyDataCollection(r.DataCollection)
+	}
+}
+
+type AgentAnnouncement struct {
+	ID       uuid.UUID                  `json:"id"`
+	Title    string                     `json:"title"`
+	Body     string                     `json:"body"`
+	Severity types.AnnouncementSeverity `json:"severity"`
+	StartsAt time.Time                  `json:"startsAt"`
+	EndsAt   time.Time                  `json:"endsAt"`
+}
+
+func AsAgentAnnouncements(announcements []types.Announcement) []AgentAnnouncement {
+	result := make([]AgentAnnouncement, len(announcements))
+	for i, a := range announcements {
+		result[i] = AgentAnnouncement{
+			ID:       a.ID,
+			Title:    a.Title,
+			Body:     a.Body,
+			Severity: a.Severity,
+			StartsAt: a.StartsAt,
+			EndsAt:   a.EndsAt,
+		}
+	}
+	return result
+}
+
+type AgentMigration struct {
+	ConnectURL string `json:"connectUrl"`
 }
 
 type AgentRegistryAuth struct {
@@ -22,8 +102,18 @@ type AgentRegistryAuth struct {
 type AgentDeployment struct {
 	ID           uuid.UUID                    `json:"id"`
 	RevisionID   uuid.UUID                    `json:"revisionId"`
+	OperationID  uuid.UUID                    `json:"operationId"`
 	RegistryAuth map[string]AgentRegistryAuth `json:"registryAuth"`
 	LogsEnabled  bool                         `json:"logsEnabled"`
+	// MetricsEndpoint is the metrics endpoint of the application that the agent should scrape, if any.
+	MetricsEndpoint *types.ApplicationMetricsEndpoint `json:"metricsEndpoint,omitempty"`
+	// Uninstall is set if the agent should uninstall the deployment instead of applying it. The agent reports a
+	// status of type uninstalled when it is done.
+	Uninstall *AgentDeploymentUninstall `json:"uninstall,omitempty"`
+	// Paused is true if the deployment or its deployment target is paused, or if the deployment is archived. The agent
+	// must neither apply nor uninstall the deployment, but keeps reporting the status of the revision that it has
+	// applied before.
+	Paused bool `json:"paused,omitempty"`
 
 	// Docker specific data
 
@@ -40,10 +130,34 @@ type AgentDeployment struct {
 	Values       map[string]any `json:"values"`
 }
 
+type AgentDeploymentUninstall struct {
+	// DeleteData is true if the volumes of the deployment should be deleted as well.
+	DeleteData bool `json:"deleteData"`
+}
+
+func (d *AgentDeployment) applyDataCollection(dc types.DataCollection) {
+	if dc.LogsDisabled {
+		d.LogsEnabled = false
+	}
+	if dc.MetricsDisabled {
+		d.MetricsEndpoint = nil
+	}
+}
+
 type AgentDeploymentStatus struct {
-	RevisionID uuid.UUID                  `json:"revisionId"`
-	Type       types.DeploymentStatusType `json:"type"`
-	Message    string                     `json:"message"`
+	RevisionID   uuid.UUID                  `json:"revisionId"`
+	OperationID  *uuid.UUID                 `json:"operationId,omitempty"`
+	Type         types.DeploymentStatusType `json:"type"`
+	Message      string                     `json:"message"`
+	PullProgress []AgentImagePullProgress   `json:"pullProgress,omitempty"`
+	// ErrorReason is the machine-readable cause of an error status. Older agents never send it.
+	ErrorReason *types.DeploymentStatusErrorReason `json:"errorReason,omitempty"`
+}
+
+type AgentImagePullProgress struct {
+	Image      string `json:"image"`
+	BytesDone  int64  `json:"bytesDone"`
+	BytesTotal int64  `json:"bytesTotal"`
 }
 
 type AgentDeploymentTargetMetrics struct {
@@ -51,4 +165,78 @@ type AgentDeploymentTargetMetrics struct {
 	CPUUsage       float64 `json:"cpuUsage" db:"cpu_usage"`
 	MemoryBytes    int64   `json:"memoryBytes" db:"memory_bytes"`
 	MemoryUsage    float64 `json:"memoryUsage" db:"memory_usage"`
+	// DiskBytes is the capacity of the filesystem that deployments are installed on.
+	// It is nil if the agent does not report it.
+	DiskBytes *int64 `json:"diskBytes,omitempty" db:"disk_bytes"`
+}
+
+type AgentConnectivityCheck struct {
+	ID        uuid.UUID                   `json:"id"`
+	Endpoints []AgentConnectivityEndpoint `json:"endpoints"`
+}
+
+type AgentConnectivityEndpoint struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type AgentConnectivityReport struct {
+	CheckID uuid.UUID                       `json:"checkId"`
+	Results []types.ConnectivityCheckResult `json:"results"`
+}
+
+// AgentFileRetrieval asks the agent to upload a single file. The agent must deny the retrieval if the path does not
+// match one of the AllowedPaths.
+type AgentFileRetrieval struct {
+	ID           uuid.UUID `json:"id"`
+	Path         string    `json:"path"`
+	AllowedPaths []string  `json:"allowedPaths"`
+}
+
+// AgentFileRetrievalReport is the result of an AgentFileRetrieval. Secrets in the content have already been redacted
+// by the agent and RedactedSecrets is the number of secrets that have been found.
+type AgentFileRetrievalReport struct {
+	RetrievalID     uuid.UUID                 `json:"retrievalId"`
+	Status          types.FileRetrievalStatus `json:"status"`
+	Content         []byte                    `json:"content,omitempty"`
+	Size            int64                     `json:"size,omitempty"`
+	RedactedSecrets int                       `json:"redactedSecrets,omitempty"`
+	Error           string                    `json:"error,omitempty"`
+}
+
+func (r *AgentFileRetrievalReport) Validate() error {
+	switch r.Status {
+	case types.FileRetrievalStatusCompleted:
+		if len(r.Content) > types.MaxFileRetrievalSize {
+			return validation.NewValidationFailedError(
+				fmt.Sprintf("content must not be larger than %v bytes", types.MaxFileRetrievalSize))
+		}
+		return nil
+	case types.FileRetrievalStatusDenied, types.FileRetrievalStatusFailed:
+		if len(r.Content) > 0 {
+			return validation.NewValidationFailedError("content must be empty if the retrieval did not complete")
+		}
+		return nil
+	default:
+		return validation.NewValidationFailedError(fmt.Sprintf("invalid status: %v", r.Status))
+	}
+}
+
+// AgentInventoryReport is a snapshot of the workloads on the host of an agent. Truncated is set if the agent found
+// more than types.MaxInventoryItems workloads and only reports the first of them.
+type AgentInventoryReport struct {
+	Items     []types.InventoryItem `json:"items"`
+	Truncated bool                  `json:"truncated"`
+}
+
+// AgentResourceLimitsReport contains the resource limits that an agent has applied. Unsupported lists the names of
+// the requested limits that could not be applied on the host.
+type AgentResourceLimitsReport struct {
+	Applied     types.AgentResourceLimits `json:"applied"`
+	Unsupported []string                  `json:"unsupported,omitempty"`
+}
+
+type AgentAppMetricsReport struct {
+	DeploymentID uuid.UUID               `json:"deploymentId"`
+	Series       []types.AppMetricSeries `json:"series"`
 }
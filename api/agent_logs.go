@@ -7,10 +7,11 @@ import (
 )
 
 type DeploymentLogRecord struct {
-	DeploymentID         uuid.UUID `json:"deploymentId"`
-	DeploymentRevisionID uuid.UUID `json:"deploymentRevisionId"`
-	Resource             string    `json:"resource"`
-	Timestamp            time.Time `json:"timestamp"`
-	Severity             string    `json:"severity"`
-	Body                 string    `json:"body"`
+	DeploymentID         uuid.UUID  `json:"deploymentId"`
+	DeploymentRevisionID uuid.UUID  `json:"deploymentRevisionId"`
+	OperationID          *uuid.UUID `json:"operationId,omitempty"`
+	Resource             string     `json:"resource"`
+	Timestamp            time.Time  `json:"timestamp"`
+	Severity             string     `json:"severity"`
+	Body                 string     `json:"body"`
 }
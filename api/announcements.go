@@ -0,0 +1,20 @@
+package api
+
+import (
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+)
+
+type AnnouncementRequest struct {
+	Title               string                     `json:"title"`
+	Body                string                     `json:"body"`
+	Severity            types.AnnouncementSeverity `json:"severity"`
+	Audience            types.AnnouncementAudience `json:"audience"`
+	CustomerIDs         []uuid.UUID                `json:"customerIds"`
+	DeploymentTargetIDs []uuid.UUID                `json:"deploymentTargetIds"`
+	// StartsAt defaults to the current time.
+	StartsAt *time.Time `json:"startsAt"`
+	EndsAt   time.Time  `json:"endsAt"`
+}
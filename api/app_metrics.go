@@ -0,0 +1,10 @@
+package api
+
+import "github.com/glasskube/distr/internal/types"
+
+type ApplicationMetricAlertRuleRequest struct {
+	Metric     string                       `json:"metric"`
+	Operator   types.AppMetricAlertOperator `json:"operator"`
+	Value      float64                      `json:"value"`
+	ForSeconds int                          `json:"forSeconds"`
+}
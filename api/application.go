@@ -9,7 +9,8 @@ import (
 
 type ApplicationResponse struct {
 	types.Application
-	ImageUrl string `json:"imageUrl"`
+	ImageUrl     string                        `json:"imageUrl"`
+	Dependencies []types.ApplicationDependency `json:"dependencies,omitempty"`
 }
 
 func AsApplication(a types.Application) ApplicationResponse {
@@ -21,7 +22,8 @@ func AsApplication(a types.Application) ApplicationResponse {
 
 type ApplicationsResponse struct {
 	types.Application
-	ImageUrl string `json:"imageUrl"`
+	ImageUrl     string                        `json:"imageUrl"`
+	Dependencies []types.ApplicationDependency `json:"dependencies,omitempty"`
 }
 
 func AsApplications(a types.Application) ApplicationsResponse {
@@ -31,10 +33,14 @@ func AsApplications(a types.Application) ApplicationsResponse {
 	}
 }
 
-func MapApplicationsToResponse(applications []types.Application) []ApplicationsResponse {
+func MapApplicationsToResponse(
+	applications []types.Application,
+	dependencies map[uuid.UUID][]types.ApplicationDependency,
+) []ApplicationsResponse {
 	result := make([]ApplicationsResponse, len(applications))
 	for i, a := range applications {
 		result[i] = AsApplications(a)
+		result[i].Dependencies = dependencies[a.ID]
 	}
 	return result
 }
@@ -48,3 +54,23 @@ type PatchApplicationVersionRequest struct {
 	ID         uuid.UUID  `json:"id"`
 	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
 }
+
+type ApplicationDependenciesRequest struct {
+	Dependencies []ApplicationDependencyRequest `json:"dependencies"`
+}
+
+type ApplicationDependencyRequest struct {
+	ApplicationID uuid.UUID `json:"applicationId"`
+	MinVersion    *string   `json:"minVersion,omitempty"`
+}
+
+// ErrorCodeVersionNotIncreasing is returned if an application version of an application with a strict version policy
+// is not higher than the highest existing version. The request can be repeated with the query parameter force=true.
+const ErrorCodeVersionNotIncreasing = "VERSION_NOT_INCREASING"
+
+type NextApplicationVersionResponse struct {
+	// Version is the suggested name of the next version.
+	Version string `json:"version"`
+	// BasedOn is the highest existing version that was bumped, or nil if no version is a semantic version.
+	BasedOn *string `json:"basedOn"`
+}
@@ -0,0 +1,24 @@
+package api
+
+import "time"
+
+// ApplicationBadgeResponse is returned to vendors. Token, SvgUrl and JsonUrl are only set right after the token has
+// been created or rotated, because only the hash of the token is stored.
+type ApplicationBadgeResponse struct {
+	TokenPrefix       string     `json:"tokenPrefix"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	LatestVersion     *string    `json:"latestVersion"`
+	InstallationCount *int       `json:"installationCount"`
+	RefreshedAt       *time.Time `json:"refreshedAt"`
+	Token             string     `json:"token,omitempty"`
+	SvgUrl            string     `json:"svgUrl,omitempty"`
+	JsonUrl           string     `json:"jsonUrl,omitempty"`
+}
+
+// ApplicationBadgeStatus is the public JSON representation of a badge.
+type ApplicationBadgeStatus struct {
+	Application       string     `json:"application"`
+	LatestVersion     *string    `json:"latestVersion"`
+	InstallationCount *int       `json:"installationCount,omitempty"`
+	UpdatedAt         *time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/glasskube/distr/internal/types"
+)
+
+var channelNameRegex = regexp.MustCompile("^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$")
+
+func ValidateChannelName(channel string) error {
+	if !channelNameRegex.MatchString(channel) {
+		return errors.New("channel must consist of at most 63 lowercase alphanumeric characters or '-'")
+	}
+	return nil
+}
+
+type ApplicationPromotionRuleRequest struct {
+	Checks []types.PromotionCheck `json:"checks"`
+}
+
+type ApplicationVersionScanRequest struct {
+	Scanner  *string `json:"scanner"`
+	Critical int     `json:"critical"`
+	High     int     `json:"high"`
+	Medium   int     `json:"medium"`
+	Low      int     `json:"low"`
+}
+
+func (r ApplicationVersionScanRequest) Validate() error {
+	if r.Critical < 0 || r.High < 0 || r.Medium < 0 || r.Low < 0 {
+		return errors.New("finding counts must not be negative")
+	}
+	return nil
+}
+
+type PromoteApplicationVersionRequest struct {
+	Channel string `json:"channel"`
+}
+
+type ApplicationVersionPromotionsResponse struct {
+	// Channels are the channels that currently point to the version
+	Channels   []string                            `json:"channels"`
+	Promotions []types.ApplicationVersionPromotion `json:"promotions"`
+}
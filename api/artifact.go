@@ -1,6 +1,14 @@
 package api
 
-import "github.com/glasskube/distr/internal/types"
+import (
+	"fmt"
+	"path"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/uuid"
+)
 
 type ArtifactResponse struct {
 	types.ArtifactWithTaggedVersion
@@ -33,3 +41,124 @@ func MapArtifactsToResponse(artifacts []types.ArtifactWithDownloads) []Artifacts
 	}
 	return result
 }
+
+// ArtifactNameViolation describes an existing artifact whose name does not conform to the repository name grammar
+// that is enforced for pushes.
+type ArtifactNameViolation struct {
+	ArtifactID    uuid.UUID `json:"artifactId"`
+	Name          string    `json:"name"`
+	Reason        string    `json:"reason"`
+	SuggestedName string    `json:"suggestedName"`
+}
+
+// ArtifactRecommendedVersionRequest sets the recommended version of an artifact to a tag or digest.
+type ArtifactRecommendedVersionRequest struct {
+	Reference string `json:"reference"`
+}
+
+func (r *ArtifactRecommendedVersionRequest) Validate() error {
+	if r.Reference == "" {
+		return validation.NewValidationFailedError("reference is empty")
+	} else if r.Reference == types.ArtifactRecommendedTag {
+		return validation.NewValidationFailedError("reference must not be the recommended tag itself")
+	}
+	return nil
+}
+
+// ArtifactPublicRequest sets whether an artifact can be pulled from the registry without authentication.
+type ArtifactPublicRequest struct {
+	Public bool `json:"public"`
+}
+
+// ArtifactTagImmutabilityRequest sets whether the tags of an artifact can be moved to a different manifest.
+// MutableTagPatterns are path.Match patterns of tags that can still be moved if ImmutableTags is true.
+type ArtifactTagImmutabilityRequest struct {
+	ImmutableTags      bool     `json:"immutableTags"`
+	MutableTagPatterns []string `json:"mutableTagPatterns"`
+}
+
+func (r *ArtifactTagImmutabilityRequest) Validate() error {
+	for _, pattern := range r.MutableTagPatterns {
+		if pattern == "" {
+			return validation.NewValidationFailedError("mutable tag pattern is empty")
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			return validation.NewValidationFailedError(fmt.Sprintf("invalid mutable tag pattern %q", pattern))
+		}
+	}
+	return nil
+}
+
+// ArtifactUpstreamRequest makes an artifact a pull-through cache of a repository in another registry. Repository
+// includes the registry host, e.g. ghcr.io/glasskube/distr. The credentials are optional.
+type ArtifactUpstreamRequest struct {
+	Repository string  `json:"repository"`
+	Username   *string `json:"username,omitempty"`
+	Password   *string `json:"password,omitempty"`
+}
+
+func (r *ArtifactUpstreamRequest) Validate() error {
+	if r.Repository == "" {
+		return validation.NewValidationFailedError("repository is empty")
+	} else if _, err := name.NewRepository(r.Repository); err != nil {
+		return validation.NewValidationFailedError(fmt.Sprintf("invalid repository: %v", err))
+	} else if (r.Username == nil) != (r.Password == nil) {
+		return validation.NewValidationFailedError("username and password must be set together")
+	}
+	return nil
+}
+
+// ArtifactTagRetentionRuleRequest creates or replaces a tag retention rule of an artifact, see
+// types.ArtifactTagRetentionRule.
+type ArtifactTagRetentionRuleRequest struct {
+	TagPattern   string `json:"tagPattern"`
+	KeepLast     *int   `json:"keepLast"`
+	UnpulledDays *int   `json:"unpulledDays"`
+	DryRun       bool   `json:"dryRun"`
+}
+
+func (r *ArtifactTagRetentionRuleRequest) Validate() error {
+	if r.TagPattern == "" {
+		return validation.NewValidationFailedError("tag pattern is empty")
+	} else if _, err := path.Match(r.TagPattern, ""); err != nil {
+		return validation.NewValidationFailedError(fmt.Sprintf("invalid tag pattern %q", r.TagPattern))
+	} else if r.KeepLast == nil && r.UnpulledDays == nil {
+		return validation.NewValidationFailedError("at least one of keepLast and unpulledDays must be set")
+	} else if r.KeepLast != nil && *r.KeepLast < 0 {
+		return validation.NewValidationFailedError("keepLast must not be negative")
+	} else if r.UnpulledDays != nil && *r.UnpulledDays < 1 {
+		return validation.NewValidationFailedError("unpulledDays must be positive")
+	}
+	return nil
+}
+
+// CreateProxyArtifactRequest creates an artifact that is a pull-through cache of a repository in another registry.
+type CreateProxyArtifactRequest struct {
+	Name string `json:"name"`
+	ArtifactUpstreamRequest
+}
+
+type RenameArtifactRequest struct {
+	Name string `json:"name"`
+}
+
+// ArtifactPullInstructions contains ready-to-copy commands to pull a version of an artifact from the registry.
+type ArtifactPullInstructions struct {
+	RegistryHost string        `json:"registryHost"`
+	Repository   string        `json:"repository"`
+	Reference    string        `json:"reference"`
+	Digest       string        `json:"digest"`
+	Commands     []PullCommand `json:"commands"`
+	// Recommended is true if the version is the recommended version of the artifact, which can also be pulled with
+	// the tag types.ArtifactRecommendedTag.
+	Recommended bool `json:"recommended"`
+	// AccessTokens are the access tokens of the current customer user that can be used to pull from the registry.
+	// They are not set for vendor users. The token keys are never included.
+	AccessTokens    []AccessToken `json:"accessTokens,omitempty"`
+	AccessTokenNote string        `json:"accessTokenNote,omitempty"`
+}
+
+type PullCommand struct {
+	Tool          string `json:"tool"`
+	Command       string `json:"command"`
+	DigestCommand string `json:"digestCommand"`
+}
@@ -0,0 +1,19 @@
+package api
+
+import "github.com/glasskube/distr/internal/types"
+
+type CustomFieldDefinitionRequest struct {
+	Target            types.CustomFieldTarget `json:"target"`
+	Key               string                  `json:"key"`
+	Type              types.CustomFieldType   `json:"type"`
+	Required          bool                    `json:"required"`
+	EnumValues        []string                `json:"enumValues"`
+	VisibleToCustomer bool                    `json:"visibleToCustomer"`
+}
+
+// CustomFieldDefinitionResponse contains the saved definition together with all existing values
+// of the same target that do not conform to the current definitions.
+type CustomFieldDefinitionResponse struct {
+	Definition types.CustomFieldDefinition  `json:"definition"`
+	Violations []types.CustomFieldViolation `json:"violations"`
+}
@@ -1,5 +1,13 @@
 package api
 
+import (
+	"fmt"
+	"slices"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+)
+
 type DashboardArtifact struct {
 	Artifact            ArtifactResponse `json:"artifact"`
 	LatestPulledVersion string           `json:"latestPulledVersion"`
@@ -9,3 +17,17 @@ type ArtifactsByCustomer struct {
 	Customer  UserAccountResponse `json:"customer"`
 	Artifacts []DashboardArtifact `json:"artifacts,omitempty"`
 }
+
+type RefreshOrganizationAggregatesRequest struct {
+	// Names are the aggregates that should be refreshed. All aggregates are refreshed if it is empty.
+	Names []types.AggregateName `json:"names"`
+}
+
+func (r *RefreshOrganizationAggregatesRequest) Validate() error {
+	for _, name := range r.Names {
+		if !slices.Contains(types.AggregateNames, name) {
+			return validation.NewValidationFailedError(fmt.Sprintf("unknown aggregate: %v", name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/glasskube/distr/internal/types"
+)
+
+// maxRetentionDays is about ten years. Data that must be kept longer has to be protected with a legal hold.
+const maxRetentionDays = 3660
+
+type DataRetentionRequest struct {
+	Policies []DataRetentionPolicyRequest `json:"policies"`
+}
+
+type DataRetentionPolicyRequest struct {
+	Category      types.DataRetentionCategory `json:"category"`
+	RetentionDays int                         `json:"retentionDays"`
+}
+
+func (r DataRetentionRequest) Validate() error {
+	var categories []types.DataRetentionCategory
+	for _, p := range r.Policies {
+		if !slices.Contains(types.DataRetentionCategories, p.Category) {
+			return fmt.Errorf("invalid category: %v", p.Category)
+		} else if slices.Contains(categories, p.Category) {
+			return fmt.Errorf("category %v is listed more than once", p.Category)
+		} else if p.RetentionDays < 1 || p.RetentionDays > maxRetentionDays {
+			return errors.New("retentionDays must be between 1 and 3660")
+		}
+		categories = append(categories, p.Category)
+	}
+	return nil
+}
+
+type DataRetentionResponse struct {
+	Policies []types.DataRetentionPolicy `json:"policies"`
+	// LegalHold is true if a legal hold suspends the deletion of any data of the organization.
+	LegalHold bool `json:"legalHold"`
+}
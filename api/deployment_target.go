@@ -2,8 +2,13 @@ package api
 
 import (
 	"fmt"
+	"net/mail"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
 	"github.com/google/uuid"
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +19,60 @@ type DeploymentTargetAccessTokenResponse struct {
 	TargetSecret string    `json:"targetSecret"`
 }
 
+// DeploymentTargetPreRegistrationRequest creates a deployment target on behalf of a customer and mails the connect
+// instructions to ContactEmail.
+type DeploymentTargetPreRegistrationRequest struct {
+	Name                  string                       `json:"name"`
+	Type                  types.DeploymentType         `json:"type"`
+	Namespace             *string                      `json:"namespace,omitempty"`
+	Scope                 *types.DeploymentTargetScope `json:"scope,omitempty"`
+	CustomerUserAccountID uuid.UUID                    `json:"customerUserAccountId"`
+	// ContactEmail defaults to the email of the customer.
+	ContactEmail string `json:"contactEmail,omitempty"`
+	// ApplicationVersionID is the version whose resource requirements are sent as prerequisites.
+	ApplicationVersionID *uuid.UUID `json:"applicationVersionId,omitempty"`
+}
+
+func (r *DeploymentTargetPreRegistrationRequest) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return validation.NewValidationFailedError("name is empty")
+	} else if r.Type != types.DeploymentTypeDocker && r.Type != types.DepolymentTypeKubernetes {
+		return validation.NewValidationFailedError(fmt.Sprintf("invalid type: %v", r.Type))
+	} else if r.CustomerUserAccountID == uuid.Nil {
+		return validation.NewValidationFailedError("customerUserAccountId is empty")
+	} else if r.ContactEmail != "" {
+		if _, err := mail.ParseAddress(r.ContactEmail); err != nil {
+			return validation.NewValidationFailedError("contactEmail is not a valid email address")
+		}
+	}
+	return nil
+}
+
+type DeploymentTargetPreRegistrationResponse struct {
+	DeploymentTarget types.DeploymentTargetWithCreatedBy   `json:"deploymentTarget"`
+	PreRegistration  types.DeploymentTargetPreRegistration `json:"preRegistration"`
+}
+
+const (
+	// ErrorCodeHeader contains a machine-readable code for some errors, in addition to the human-readable message in
+	// the response body.
+	ErrorCodeHeader = "X-Distr-Error-Code"
+	// ErrorCodeDeploymentReasonRequired is returned if a deployment is created or updated without a reason, but the
+	// organization requires one for the deployment target.
+	ErrorCodeDeploymentReasonRequired = "DEPLOYMENT_REASON_REQUIRED"
+	// ErrorCodeResourceRequirementsNotMet is returned if a deployment is created on a deployment target that does not
+	// meet the resource requirements of the application and the requirements are enforced.
+	ErrorCodeResourceRequirementsNotMet = "RESOURCE_REQUIREMENTS_NOT_MET"
+	// ErrorCodeSecretsFound is returned if an uploaded file contains secrets and the organization blocks such
+	// uploads. The message lists the file, line and rule of every finding.
+	ErrorCodeSecretsFound = "SECRETS_FOUND"
+	// WarningHeader contains a human-readable warning for a request that succeeded anyway, e.g. because a deployment
+	// target does not meet requirements that are not enforced. It can occur multiple times.
+	WarningHeader = "X-Distr-Warning"
+	// DeploymentReasonMaxLength is the maximum length of a deployment reason.
+	DeploymentReasonMaxLength = 1000
+)
+
 type DeploymentRequest struct {
 	DeploymentID         *uuid.UUID        `json:"deploymentId"`
 	DeploymentTargetID   uuid.UUID         `json:"deploymentTargetId"`
@@ -23,6 +82,24 @@ type DeploymentRequest struct {
 	ValuesYaml           []byte            `json:"valuesYaml"`
 	DockerType           *types.DockerType `json:"dockerType"`
 	EnvFileData          []byte            `json:"envFileData"`
+	Reason               *string           `json:"reason,omitempty"`
+}
+
+// ErrDeploymentReasonRequired is returned by DeploymentRequest.ValidateReason if a required reason is missing.
+var ErrDeploymentReasonRequired = fmt.Errorf("%w: a reason is required to deploy to this deployment target",
+	validation.ErrValidationFailed)
+
+// ValidateReason checks the reason of the request. A reason that only consists of whitespace counts as missing.
+func (d DeploymentRequest) ValidateReason(required bool) error {
+	if d.Reason == nil || strings.TrimSpace(*d.Reason) == "" {
+		if required {
+			return ErrDeploymentReasonRequired
+		}
+	} else if len(strings.TrimSpace(*d.Reason)) > DeploymentReasonMaxLength {
+		return validation.NewValidationFailedError(
+			fmt.Sprintf("reason must not be longer than %v characters", DeploymentReasonMaxLength))
+	}
+	return nil
 }
 
 func (d DeploymentRequest) ParsedValuesFile() (result map[string]any, err error) {
@@ -35,6 +112,145 @@ func (d DeploymentRequest) ParsedValuesFile() (result map[string]any, err error)
 	return
 }
 
+type DeploymentUninstallRequest struct {
+	// DeleteData deletes the volumes of the deployment. It requires ConfirmApplicationName.
+	DeleteData bool `json:"deleteData"`
+	// ConfirmApplicationName must be the name of the application of the deployment if DeleteData is true.
+	ConfirmApplicationName string `json:"confirmApplicationName,omitempty"`
+}
+
+// PauseRequest pauses a deployment or all deployments of a deployment target.
+type PauseRequest struct {
+	// Reason is shown to everyone who can see the deployment, e.g. the incident that is worked on.
+	Reason string `json:"reason"`
+	// ResumeAt ends the pause automatically if it is set. It must be in the future.
+	ResumeAt *time.Time `json:"resumeAt,omitempty"`
+}
+
+func (r *PauseRequest) Validate() error {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return validation.NewValidationFailedError("reason is required")
+	} else if len(r.Reason) > DeploymentReasonMaxLength {
+		return validation.NewValidationFailedError(
+			fmt.Sprintf("reason must not be longer than %v characters", DeploymentReasonMaxLength))
+	}
+	return nil
+}
+
+type DeploymentDependenciesRequest struct {
+	// DependsOn are the deployments on the same deployment target that the deployment depends on.
+	DependsOn []uuid.UUID `json:"dependsOn"`
+}
+
+type DeploymentDependenciesResponse struct {
+	DependsOn []uuid.UUID `json:"dependsOn"`
+	// DependedOnBy are the deployments that depend on the deployment. It can not be uninstalled while any of them
+	// is installed.
+	DependedOnBy []uuid.UUID `json:"dependedOnBy"`
+}
+
 type PatchDeploymentRequest struct {
 	LogsEnabled *bool `json:"logsEnabled,omitempty"`
+	// AutoRollback replaces the automatic rollback policy of the deployment if it is not nil.
+	AutoRollback *DeploymentAutoRollbackPolicy `json:"autoRollback,omitempty"`
+}
+
+// DeploymentAutoRollbackPolicy overrides the automatic rollback policy of the organization for one deployment. Fields
+// that are nil inherit the setting of the organization.
+type DeploymentAutoRollbackPolicy struct {
+	Enabled       *bool `json:"enabled"`
+	WindowSeconds *int  `json:"windowSeconds"`
+}
+
+// DeploymentPullProgress coalesces the image pull progress of all images of a deployment revision.
+type DeploymentPullProgress struct {
+	DeploymentRevisionID uuid.UUID                              `json:"deploymentRevisionId"`
+	BytesDone            int64                                  `json:"bytesDone"`
+	BytesTotal           int64                                  `json:"bytesTotal"`
+	Percent              int                                    `json:"percent"`
+	Images               []types.DeploymentRevisionPullProgress `json:"images"`
+}
+
+type DeploymentRevisionTimeline struct {
+	types.DeploymentRevisionWithVersion
+	Events []types.DeploymentTimelineEvent `json:"events"`
+	// Approximate is true if any event has been correlated by time instead of by operation ID.
+	Approximate bool `json:"approximate"`
+}
+
+// DeploymentTargetDataPurgeRequest selects the categories of collected data that should be deleted.
+type DeploymentTargetDataPurgeRequest struct {
+	Logs        bool `json:"logs"`
+	Metrics     bool `json:"metrics"`
+	Diagnostics bool `json:"diagnostics"`
+	Inventory   bool `json:"inventory"`
+}
+
+func (r *DeploymentTargetDataPurgeRequest) Validate() error {
+	if !r.Logs && !r.Metrics && !r.Diagnostics && !r.Inventory {
+		return validation.NewValidationFailedError(
+			"at least one of logs, metrics, diagnostics and inventory must be selected")
+	}
+	return nil
+}
+
+// AcknowledgeDeploymentRevisionRequest acknowledges a deployment revision that requires acknowledgment. Vendors can not
+// acknowledge on behalf of a customer, but they can override the acknowledgment in an emergency if they give a reason.
+type AcknowledgeDeploymentRevisionRequest struct {
+	Override bool   `json:"override"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (r *AcknowledgeDeploymentRevisionRequest) Validate(role types.UserRole) error {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if role == types.UserRoleVendor && !r.Override {
+		return validation.NewValidationFailedError("vendors can only override the acknowledgment")
+	} else if role != types.UserRoleVendor && r.Override {
+		return validation.NewValidationFailedError("only vendors can override the acknowledgment")
+	} else if r.Override && r.Reason == "" {
+		return validation.NewValidationFailedError("a reason is required to override the acknowledgment")
+	}
+	return nil
+}
+
+// MaxFileRetrievalAllowedPaths is the maximum number of patterns in the file retrieval allow-list of a deployment
+// target.
+const MaxFileRetrievalAllowedPaths = 50
+
+// DeploymentTargetFileRetrievalAllowListRequest replaces the file retrieval allow-list of a deployment target.
+type DeploymentTargetFileRetrievalAllowListRequest struct {
+	AllowedPaths []string `json:"allowedPaths"`
+}
+
+func (r *DeploymentTargetFileRetrievalAllowListRequest) Validate() error {
+	if len(r.AllowedPaths) > MaxFileRetrievalAllowedPaths {
+		return validation.NewValidationFailedError(
+			fmt.Sprintf("at most %v allowed paths can be configured", MaxFileRetrievalAllowedPaths))
+	}
+	for i, pattern := range r.AllowedPaths {
+		pattern = strings.TrimSpace(pattern)
+		if !path.IsAbs(pattern) {
+			return validation.NewValidationFailedError(fmt.Sprintf("allowed path %q must be absolute", pattern))
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			return validation.NewValidationFailedError(fmt.Sprintf("allowed path %q is not a valid pattern", pattern))
+		}
+		r.AllowedPaths[i] = pattern
+	}
+	return nil
+}
+
+// DeploymentTargetFileRetrievalRequest requests a single file from the host of a deployment target. The path is
+// checked against the allow-list by the agent, so that paths outside of it are denied and recorded as such.
+type DeploymentTargetFileRetrievalRequest struct {
+	Path string `json:"path"`
+}
+
+func (r *DeploymentTargetFileRetrievalRequest) Validate() error {
+	if !path.IsAbs(r.Path) || path.Clean(r.Path) != r.Path {
+		return validation.NewValidationFailedError("path must be absolute and must not contain . or .. elements")
+	} else if len(r.Path) > 4096 {
+		return validation.NewValidationFailedError("path must not be longer than 4096 characters")
+	}
+	return nil
 }
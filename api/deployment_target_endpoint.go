@@ -0,0 +1,18 @@
+package api
+
+import "github.com/glasskube/distr/internal/types"
+
+// DeploymentTargetEndpointMaxCount is the maximum number of endpoints that can be monitored per deployment target.
+const DeploymentTargetEndpointMaxCount = 10
+
+type CreateDeploymentTargetEndpointRequest struct {
+	URL          string `json:"url"`
+	SelfSignedOK bool   `json:"selfSignedOk"`
+}
+
+type DeploymentTargetEndpoint struct {
+	types.DeploymentTargetEndpoint
+	Certificate   *types.DeploymentTargetEndpointCertificate `json:"certificate,omitempty"`
+	ExpiresInDays *int                                       `json:"expiresInDays,omitempty"`
+	Warning       string                                     `json:"warning,omitempty"`
+}
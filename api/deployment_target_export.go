@@ -0,0 +1,65 @@
+package api
+
+import (
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+)
+
+type DeploymentTargetExportRequest struct {
+	TransportKey string `json:"transportKey"`
+}
+
+// DeploymentTargetExport is a portable definition of a deployment target that can be imported in another Distr
+// instance. Values and environment files are encrypted with the transport key given when exporting.
+type DeploymentTargetExport struct {
+	FormatVersion  int                           `json:"formatVersion"`
+	ExportedAt     time.Time                     `json:"exportedAt"`
+	ID             uuid.UUID                     `json:"id"`
+	Name           string                        `json:"name"`
+	Type           types.DeploymentType          `json:"type"`
+	Namespace      *string                       `json:"namespace,omitempty"`
+	Scope          *types.DeploymentTargetScope  `json:"scope,omitempty"`
+	MetricsEnabled bool                          `json:"metricsEnabled"`
+	CustomFields   types.CustomFields            `json:"customFields,omitempty"`
+	CreatedByEmail string                        `json:"createdByEmail"`
+	Deployments    []DeploymentTargetExportEntry `json:"deployments"`
+}
+
+type DeploymentTargetExportEntry struct {
+	ID                     uuid.UUID         `json:"id"`
+	ApplicationName        string            `json:"applicationName"`
+	ApplicationVersionName string            `json:"applicationVersionName"`
+	ApplicationLicenseName *string           `json:"applicationLicenseName,omitempty"`
+	ReleaseName            *string           `json:"releaseName,omitempty"`
+	DockerType             *types.DockerType `json:"dockerType,omitempty"`
+	LogsEnabled            bool              `json:"logsEnabled"`
+	EncryptedValuesYaml    string            `json:"encryptedValuesYaml,omitempty"`
+	EncryptedEnvFileData   string            `json:"encryptedEnvFileData,omitempty"`
+	// Images contains the digest pinned images of the application version that are hosted on the Distr registry.
+	Images []string `json:"images,omitempty"`
+}
+
+type DeploymentTargetImportRequest struct {
+	TransportKey string                 `json:"transportKey"`
+	Export       DeploymentTargetExport `json:"export"`
+}
+
+type DeploymentTargetImportResponse struct {
+	DeploymentTarget types.DeploymentTargetWithCreatedBy `json:"deploymentTarget"`
+	// Access can be used to connect a new agent or to migrate the existing agent to this instance.
+	Access DeploymentTargetAccessTokenResponse `json:"access"`
+	Gaps   []DeploymentTargetImportGap         `json:"gaps"`
+}
+
+// DeploymentTargetImportGap describes something that could not be imported exactly as it was exported.
+type DeploymentTargetImportGap struct {
+	DeploymentID *uuid.UUID `json:"deploymentId,omitempty"`
+	Image        string     `json:"image,omitempty"`
+	Message      string     `json:"message"`
+}
+
+type DeploymentTargetMigrationRequest struct {
+	ConnectURL string `json:"connectUrl"`
+}
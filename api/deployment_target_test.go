@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/glasskube/distr/internal/validation"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentRequestValidateReason(t *testing.T) {
+	g := NewWithT(t)
+	missing := api.DeploymentRequest{}
+	blank := api.DeploymentRequest{Reason: util.PtrTo(" \t\n")}
+	valid := api.DeploymentRequest{Reason: util.PtrTo("  rotate certificates  ")}
+	tooLong := api.DeploymentRequest{Reason: util.PtrTo(strings.Repeat("x", api.DeploymentReasonMaxLength+1))}
+
+	g.Expect(missing.ValidateReason(true)).To(MatchError(api.ErrDeploymentReasonRequired))
+	g.Expect(blank.ValidateReason(true)).To(MatchError(api.ErrDeploymentReasonRequired))
+	g.Expect(valid.ValidateReason(true)).To(Succeed())
+	g.Expect(missing.ValidateReason(false)).To(Succeed())
+	g.Expect(blank.ValidateReason(false)).To(Succeed())
+	g.Expect(tooLong.ValidateReason(false)).To(MatchError(validation.ErrValidationFailed))
+	g.Expect(tooLong.ValidateReason(true)).NotTo(MatchError(api.ErrDeploymentReasonRequired))
+}
+
+func TestDeploymentRequestValidateReasonPolicy(t *testing.T) {
+	production := types.DeploymentTarget{Production: true}
+	staging := types.DeploymentTarget{}
+	for _, tc := range []struct {
+		policy     types.DeploymentReasonPolicy
+		target     types.DeploymentTarget
+		isRejected bool
+	}{
+		{types.DeploymentReasonPolicyOptional, production, false},
+		{types.DeploymentReasonPolicyProduction, staging, false},
+		{types.DeploymentReasonPolicyProduction, production, true},
+		{types.DeploymentReasonPolicyRequired, staging, true},
+	} {
+		t.Run(fmt.Sprintf("%v/production=%v", tc.policy, tc.target.Production), func(t *testing.T) {
+			g := NewWithT(t)
+			org := types.Organization{DeploymentReasonPolicy: tc.policy}
+			request := api.DeploymentRequest{Reason: util.PtrTo(" ")}
+			err := request.ValidateReason(org.RequiresDeploymentReason(&tc.target))
+			if tc.isRejected {
+				g.Expect(err).To(MatchError(api.ErrDeploymentReasonRequired))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
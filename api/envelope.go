@@ -0,0 +1,32 @@
+package api
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrorResponse is the body of all error responses starting with API v2.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+type Error struct {
+	// Code is derived from the HTTP status, for example "not_found".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ListResponse is the body of all list responses starting with API v2. NextCursor is omitted on the last page.
+type ListResponse struct {
+	Items      any     `json:"items"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+}
+
+// DeploymentRevisionResponse is returned by API v2 when a deployment is created or updated.
+type DeploymentRevisionResponse struct {
+	DeploymentID           uuid.UUID `json:"deploymentId"`
+	RevisionID             uuid.UUID `json:"revisionId"`
+	CreatedAt              time.Time `json:"createdAt"`
+	AcknowledgmentRequired bool      `json:"acknowledgmentRequired"`
+}
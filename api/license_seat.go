@@ -0,0 +1,5 @@
+package api
+
+type LicenseSeatRequest struct {
+	Email string `json:"email"`
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"slices"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+)
+
+// ResendableMailTypes are the mail types that can be re-sent. Mails containing a token are re-sent with a new token.
+// Both invite types re-send the invitation that matches the role of the user in the organization.
+var ResendableMailTypes = []types.MailType{
+	types.MailTypeInviteUser,
+	types.MailTypeInviteCustomer,
+	types.MailTypeVerifyEmail,
+	types.MailTypePasswordReset,
+}
+
+type ResendMailRequest struct {
+	Type          types.MailType `json:"type"`
+	UserAccountID uuid.UUID      `json:"userAccountId"`
+}
+
+func (r *ResendMailRequest) Validate() error {
+	if !slices.Contains(ResendableMailTypes, r.Type) {
+		return validation.NewValidationFailedError("mail type can not be re-sent")
+	} else if r.UserAccountID == uuid.Nil {
+		return validation.NewValidationFailedError("userAccountId is empty")
+	}
+	return nil
+}
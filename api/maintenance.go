@@ -0,0 +1,54 @@
+package api
+
+import (
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+)
+
+type MaintenanceModeRequest struct {
+	Reason            string `json:"reason"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds"`
+}
+
+const (
+	MaintenanceScopeServer       = "server"
+	MaintenanceScopeOrganization = "organization"
+)
+
+// MaintenanceStatus describes the maintenance mode that applies to the current user, so that it can be displayed
+// as a banner.
+type MaintenanceStatus struct {
+	Active            bool       `json:"active"`
+	Scope             string     `json:"scope,omitempty"`
+	Reason            string     `json:"reason,omitempty"`
+	Since             *time.Time `json:"since,omitempty"`
+	RetryAfterSeconds int        `json:"retryAfterSeconds,omitempty"`
+}
+
+func AsMaintenanceStatus(mode *types.MaintenanceMode) MaintenanceStatus {
+	if mode == nil {
+		return MaintenanceStatus{}
+	}
+	status := MaintenanceStatus{
+		Active:            true,
+		Scope:             MaintenanceScopeServer,
+		Reason:            mode.Reason,
+		Since:             &mode.CreatedAt,
+		RetryAfterSeconds: mode.RetryAfterSeconds,
+	}
+	if mode.OrganizationID != nil {
+		status.Scope = MaintenanceScopeOrganization
+	}
+	return status
+}
+
+// AgentHold is set if the agent should not apply any changes or report status until RetryAfterSeconds have passed.
+type AgentHold struct {
+	Reason            string `json:"reason"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds"`
+}
+
+func (h AgentHold) Duration() time.Duration {
+	return time.Duration(h.RetryAfterSeconds) * time.Second
+}
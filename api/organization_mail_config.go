@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"github.com/glasskube/distr/internal/types"
+)
+
+type OrganizationMailConfigRequest struct {
+	Type         types.MailConfigType `json:"type"`
+	FromAddress  string               `json:"fromAddress"`
+	SmtpHost     *string              `json:"smtpHost"`
+	SmtpPort     *int                 `json:"smtpPort"`
+	SmtpUsername *string              `json:"smtpUsername"`
+	SmtpPassword *string              `json:"smtpPassword"`
+	DkimSelector *string              `json:"dkimSelector"`
+}
+
+func (r OrganizationMailConfigRequest) Validate() error {
+	if _, err := mail.ParseAddress(r.FromAddress); err != nil {
+		return fmt.Errorf("invalid fromAddress: %w", err)
+	}
+	switch r.Type {
+	case types.MailConfigTypeSMTP:
+		if r.SmtpHost == nil || *r.SmtpHost == "" {
+			return errors.New("smtpHost is required")
+		}
+		if r.SmtpPort != nil && (*r.SmtpPort <= 0 || *r.SmtpPort > 65535) {
+			return errors.New("smtpPort is invalid")
+		}
+		if (r.SmtpUsername == nil) != (r.SmtpPassword == nil) {
+			return errors.New("smtpUsername and smtpPassword must be specified together")
+		}
+	case types.MailConfigTypeDomain:
+		if r.SmtpHost != nil || r.SmtpUsername != nil || r.SmtpPassword != nil {
+			return errors.New("SMTP settings are not allowed for sender domain configs")
+		}
+	default:
+		return fmt.Errorf("invalid type: %v", r.Type)
+	}
+	return nil
+}
+
+type MailDnsRecord struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+type OrganizationMailConfigResponse struct {
+	types.OrganizationMailConfig
+	DnsRecords []MailDnsRecord `json:"dnsRecords,omitempty"`
+}
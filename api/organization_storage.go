@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"strings"
+)
+
+type OrganizationStorageRequest struct {
+	Bucket               string  `json:"bucket"`
+	Region               string  `json:"region"`
+	Endpoint             *string `json:"endpoint"`
+	UsePathStyle         bool    `json:"usePathStyle"`
+	AllowRedirect        bool    `json:"allowRedirect"`
+	AccessKeyID          string  `json:"accessKeyId"`
+	SecretAccessKey      string  `json:"secretAccessKey"`
+	MigrateExistingBlobs bool    `json:"migrateExistingBlobs"`
+	QuotaEnabled         bool    `json:"quotaEnabled"`
+}
+
+func (r OrganizationStorageRequest) Validate() error {
+	if strings.TrimSpace(r.Bucket) == "" {
+		return errors.New("bucket is required")
+	}
+	if strings.TrimSpace(r.Region) == "" {
+		return errors.New("region is required")
+	}
+	if r.Endpoint != nil && !strings.HasPrefix(*r.Endpoint, "https://") && !strings.HasPrefix(*r.Endpoint, "http://") {
+		return errors.New("endpoint must be an http or https URL")
+	}
+	if r.AccessKeyID == "" || r.SecretAccessKey == "" {
+		return errors.New("accessKeyId and secretAccessKey are required")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/glasskube/distr/internal/webauthn"
+	"github.com/google/uuid"
+)
+
+// PasskeyNicknameMaxLength is the maximum length of the nickname of a passkey.
+const PasskeyNicknameMaxLength = 100
+
+type Passkey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	Nickname   string     `json:"nickname"`
+	Transports []string   `json:"transports"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// PasskeyRegistrationOptions must be passed to navigator.credentials.create() as publicKey. The response is sent back
+// in a CreatePasskeyRequest with the same ChallengeID.
+type PasskeyRegistrationOptions struct {
+	ChallengeID uuid.UUID                `json:"challengeId"`
+	PublicKey   webauthn.CreationOptions `json:"publicKey"`
+}
+
+type CreatePasskeyRequest struct {
+	ChallengeID uuid.UUID                    `json:"challengeId"`
+	Nickname    string                       `json:"nickname"`
+	Credential  webauthn.AttestationResponse `json:"credential"`
+}
+
+func (r *CreatePasskeyRequest) Validate() error {
+	if r.ChallengeID == uuid.Nil {
+		return validation.NewValidationFailedError("challengeId is empty")
+	}
+	return validatePasskeyNickname(r.Nickname)
+}
+
+type UpdatePasskeyRequest struct {
+	Nickname string `json:"nickname"`
+}
+
+func (r *UpdatePasskeyRequest) Validate() error {
+	return validatePasskeyNickname(r.Nickname)
+}
+
+func validatePasskeyNickname(nickname string) error {
+	if strings.TrimSpace(nickname) == "" {
+		return validation.NewValidationFailedError("nickname is empty")
+	} else if len(nickname) > PasskeyNicknameMaxLength {
+		return validation.NewValidationFailedError("nickname is too long")
+	}
+	return nil
+}
+
+// PasskeyLoginOptions must be passed to navigator.credentials.get() as publicKey. The response is sent back in a
+// PasskeyLoginRequest with the same ChallengeID.
+type PasskeyLoginOptions struct {
+	ChallengeID uuid.UUID               `json:"challengeId"`
+	PublicKey   webauthn.RequestOptions `json:"publicKey"`
+}
+
+type PasskeyLoginRequest struct {
+	ChallengeID uuid.UUID                  `json:"challengeId"`
+	Credential  webauthn.AssertionResponse `json:"credential"`
+}
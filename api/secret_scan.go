@@ -0,0 +1,14 @@
+package api
+
+type SecretScanRuleRequest struct {
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description"`
+	// Pattern is a regular expression in RE2 syntax. If it has a capture group, the first group is the secret.
+	Pattern string `json:"pattern"`
+}
+
+type SecretScanDismissalRequest struct {
+	// Fingerprint is the fingerprint of the finding that is a false positive.
+	Fingerprint string `json:"fingerprint"`
+	Reason      string `json:"reason"`
+}
@@ -0,0 +1,10 @@
+package api
+
+import "github.com/glasskube/distr/internal/types"
+
+type SecurityEventPreferences struct {
+	// MailsDisabled are the event types for which no mails are sent to the user.
+	MailsDisabled []types.SecurityEventType `json:"mailsDisabled"`
+	// Notifiable are all event types for which mails can be sent. It is ignored in requests.
+	Notifiable []types.SecurityEventType `json:"notifiable,omitempty"`
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/glasskube/distr/internal/validation"
+)
+
+// ServiceAccountNameMaxLength is the maximum length of the name of a service account.
+const ServiceAccountNameMaxLength = 100
+
+type CreateServiceAccountRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+func (r *CreateServiceAccountRequest) Validate() error {
+	return validateServiceAccountName(r.Name)
+}
+
+type UpdateServiceAccountRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+func (r *UpdateServiceAccountRequest) Validate() error {
+	return validateServiceAccountName(r.Name)
+}
+
+func validateServiceAccountName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return validation.NewValidationFailedError("name is empty")
+	} else if len(name) > ServiceAccountNameMaxLength {
+		return validation.NewValidationFailedError("name is too long")
+	}
+	return nil
+}
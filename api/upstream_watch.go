@@ -0,0 +1,5 @@
+package api
+
+type CreateUpstreamWatchRequest struct {
+	Reference string `json:"reference"`
+}
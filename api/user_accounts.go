@@ -20,7 +20,8 @@ type CreateUserAccountResponse struct {
 
 type UserAccountResponse struct {
 	types.UserAccountWithUserRole
-	ImageUrl string `json:"imageUrl"`
+	ImageUrl     string             `json:"imageUrl"`
+	CustomFields types.CustomFields `json:"customFields,omitempty"`
 }
 
 func AsUserAccount(u types.UserAccountWithUserRole) UserAccountResponse {
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+)
+
+// VersionEOLSelection selects the application versions of a report or campaign, either by their IDs or by a semantic
+// version constraint, e.g. "< 2.0.0".
+type VersionEOLSelection struct {
+	ApplicationVersionIDs []uuid.UUID `json:"applicationVersionIds"`
+	VersionConstraint     string      `json:"versionConstraint"`
+}
+
+func (s *VersionEOLSelection) Validate() error {
+	if len(s.ApplicationVersionIDs) == 0 && strings.TrimSpace(s.VersionConstraint) == "" {
+		return validation.NewValidationFailedError("either applicationVersionIds or versionConstraint must be set")
+	} else if len(s.ApplicationVersionIDs) > 0 && s.VersionConstraint != "" {
+		return validation.NewValidationFailedError("applicationVersionIds and versionConstraint are mutually exclusive")
+	}
+	return nil
+}
+
+type CreateVersionEOLCampaignRequest struct {
+	VersionEOLSelection
+	EndOfLifeAt *time.Time `json:"endOfLifeAt"`
+	// Message is included in the notification of every affected customer.
+	Message string `json:"message"`
+}
+
+func (r *CreateVersionEOLCampaignRequest) Validate() error {
+	if strings.TrimSpace(r.Message) == "" {
+		return validation.NewValidationFailedError("message must not be empty")
+	}
+	return r.VersionEOLSelection.Validate()
+}
+
+type VersionEOLReport struct {
+	ApplicationVersions []types.ApplicationVersion    `json:"applicationVersions"`
+	Entries             []types.VersionEOLReportEntry `json:"entries"`
+}
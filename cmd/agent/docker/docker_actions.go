@@ -19,19 +19,41 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-func DockerEngineApply(ctx context.Context, deployment api.AgentDeployment) (*AgentDeployment, string, error) {
+func DockerEngineApply(
+	ctx context.Context,
+	deployment api.AgentDeployment,
+	progress *PullProgress,
+) (*AgentDeployment, string, error) {
+	// secret references are resolved right before applying, so that the secrets are never stored by the agent
+	if envFile, err := secretResolver.ResolveEnvFile(ctx, deployment.EnvFile); err != nil {
+		return nil, "", err
+	} else {
+		deployment.EnvFile = envFile
+	}
 	if *deployment.DockerType == types.DockerTypeSwarm {
 		return ApplyComposeFileSwarm(ctx, deployment)
 	}
-	return ApplyComposeFile(ctx, deployment)
+	return ApplyComposeFile(ctx, deployment, progress)
 }
 
-func ApplyComposeFile(ctx context.Context, deployment api.AgentDeployment) (*AgentDeployment, string, error) {
+func ApplyComposeFile(
+	ctx context.Context,
+	deployment api.AgentDeployment,
+	progress *PullProgress,
+) (*AgentDeployment, string, error) {
 	agentDeploymet, err := NewAgentDeployment(deployment)
 	if err != nil {
 		return nil, "", err
 	}
 
+	if err := PullImages(ctx, deployment, progress); err != nil {
+		return nil, "", err
+	} else if snapshot := progress.Snapshot(); len(snapshot) > 0 {
+		if err := client.PullProgress(ctx, deployment.RevisionID, "images pulled", snapshot); err != nil {
+			logger.Warn("error updating pull progress", zap.Error(err))
+		}
+	}
+
 	var envFile *os.File
 	if deployment.EnvFile != nil {
 		if envFile, err = os.CreateTemp("", "distr-env"); err != nil {
@@ -138,15 +160,42 @@ func ApplyComposeFileSwarm(ctx context.Context, deployment api.AgentDeployment)
 	return agentDeployment, statusStr, nil
 }
 
-func DockerEngineUninstall(ctx context.Context, deployment AgentDeployment) error {
+// DockerEngineStatus returns the state of the containers of a deployment without changing them. It returns an error if
+// a container has exited with a non-zero exit code.
+func DockerEngineStatus(ctx context.Context, deployment AgentDeployment) (string, error) {
+	if deployment.DockerType == types.DockerTypeSwarm {
+		return "status checks are not yet supported in swarm mode", nil
+	}
+	cmd := exec.CommandContext(ctx, "docker", "compose", "--project-name", deployment.ProjectName,
+		"ps", "--all", "--format", "{{.Name}}\t{{.State}}\t{{.ExitCode}}")
+	out, err := cmd.CombinedOutput()
+	statusStr := string(out)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", err, statusStr)
+	}
+	for line := range strings.Lines(statusStr) {
+		if fields := strings.Split(strings.TrimSpace(line), "\t"); len(fields) == 3 &&
+			fields[1] == "exited" && fields[2] != "0" {
+			return "", fmt.Errorf("container %v exited with code %v", fields[0], fields[2])
+		}
+	}
+	return statusStr, nil
+}
+
+// DockerEngineUninstall removes a deployment. Its volumes are only removed if deleteData is true.
+func DockerEngineUninstall(ctx context.Context, deployment AgentDeployment, deleteData bool) error {
 	if deployment.DockerType == types.DockerTypeSwarm {
-		return UninstallDockerSwarm(ctx, deployment)
+		return UninstallDockerSwarm(ctx, deployment, deleteData)
 	}
-	return UninstallDockerCompose(ctx, deployment)
+	return UninstallDockerCompose(ctx, deployment, deleteData)
 }
 
-func UninstallDockerCompose(ctx context.Context, deployment AgentDeployment) error {
-	cmd := exec.CommandContext(ctx, "docker", "compose", "--project-name", deployment.ProjectName, "down", "--volumes")
+func UninstallDockerCompose(ctx context.Context, deployment AgentDeployment, deleteData bool) error {
+	args := []string{"compose", "--project-name", deployment.ProjectName, "down"}
+	if deleteData {
+		args = append(args, "--volumes")
+	}
+	cmd := exec.CommandContext(ctx, "docker", args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%w: %v", err, string(out))
@@ -154,7 +203,7 @@ func UninstallDockerCompose(ctx context.Context, deployment AgentDeployment) err
 	return nil
 }
 
-func UninstallDockerSwarm(ctx context.Context, deployment AgentDeployment) error {
+func UninstallDockerSwarm(ctx context.Context, deployment AgentDeployment, deleteData bool) error {
 	cmd := exec.CommandContext(ctx, "docker", "stack", "rm", deployment.ProjectName)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -168,6 +217,15 @@ func UninstallDockerSwarm(ctx context.Context, deployment AgentDeployment) error
 		logger.Warn("Failed to prune networks", zap.String("output", string(pruneOut)), zap.Error(pruneErr))
 	}
 
+	if deleteData {
+		// docker stack rm keeps volumes, they are found by the label that docker stack deploy sets
+		pruneCmd := exec.CommandContext(ctx, "docker", "volume", "prune", "--force", "--all",
+			"--filter", "label=com.docker.stack.namespace="+deployment.ProjectName)
+		if out, err := pruneCmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove Docker Swarm stack volumes: %w: %v", err, string(out))
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	dockercommand "github.com/docker/cli/cli/command"
+	"github.com/docker/cli/cli/flags"
+	"github.com/docker/docker/api/types/container"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// listInventory returns all containers on the host. A container is managed if it belongs to the compose project or
+// swarm stack of a deployment of this agent.
+func listInventory(ctx context.Context) ([]types.InventoryItem, error) {
+	deployments, err := GetExistingDeployments()
+	if err != nil {
+		return nil, err
+	}
+	projects := make(map[string]struct{}, len(deployments))
+	for _, d := range deployments {
+		projects[d.ProjectName] = struct{}{}
+	}
+
+	cli, err := dockercommand.NewDockerCli()
+	if err != nil {
+		return nil, err
+	} else if err := cli.Initialize(flags.NewClientOptions()); err != nil {
+		return nil, err
+	}
+	defer cli.Client().Close()
+	containers, err := cli.Client().ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]types.InventoryItem, len(containers))
+	for i, c := range containers {
+		item := types.InventoryItem{Kind: "container", Name: c.ID, Images: []string{c.Image}, State: c.State}
+		if len(c.Names) > 0 {
+			item.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		for _, label := range []string{"com.docker.compose.project", "com.docker.stack.namespace"} {
+			if project, ok := c.Labels[label]; ok {
+				if _, ok := projects[project]; ok {
+					item.Managed = true
+				}
+			}
+		}
+		items[i] = item
+	}
+	return items, nil
+}
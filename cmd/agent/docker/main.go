@@ -2,16 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os/signal"
 	"slices"
 	"syscall"
 	"time"
 
 	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentappmetrics"
 	"github.com/glasskube/distr/internal/agentauth"
 	"github.com/glasskube/distr/internal/agentclient"
+	"github.com/glasskube/distr/internal/agentconnectivity"
 	"github.com/glasskube/distr/internal/agentenv"
+	"github.com/glasskube/distr/internal/agentfileretrieval"
+	"github.com/glasskube/distr/internal/agentinventory"
+	"github.com/glasskube/distr/internal/agentlimits"
 	"github.com/glasskube/distr/internal/buildconfig"
+	"github.com/glasskube/distr/internal/secretref"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	"go.uber.org/multierr"
@@ -19,8 +26,17 @@ import (
 )
 
 var (
-	logger = util.Require(zap.NewDevelopment())
-	client = util.Require(agentclient.NewFromEnv(logger))
+	loggerConfig = zap.NewDevelopmentConfig()
+	logger       = util.Require(loggerConfig.Build())
+	client       = util.Require(agentclient.NewFromEnv(logger))
+	connectivity = agentconnectivity.NewChecker(client, logger)
+	files        = agentfileretrieval.NewRetriever(client, logger)
+	appMetrics   = agentappmetrics.NewRelayer(client, logger)
+	inventory    = agentinventory.NewReporter(client, logger)
+	// image pulls are done by the docker daemon, so their bandwidth can not be limited by the agent
+	limits = agentlimits.NewApplier(client, logger, loggerConfig.Level,
+		types.AgentResourceLimitMaxDownloadBytesPerSecond)
+	secretResolver = secretref.NewResolverFromEnv()
 )
 
 func init() {
@@ -40,6 +56,7 @@ func main() {
 	go util.Require(NewLogsWatcher()).Watch(ctx, 30*time.Second)
 
 	tick := time.Tick(agentenv.Interval)
+	var goneBackoff agentclient.GoneBackoff
 
 loop:
 	for ctx.Err() == nil {
@@ -49,9 +66,35 @@ loop:
 			break loop
 		}
 
-		if resource, err := client.Resource(ctx); err != nil {
+		if resource, err := client.Resource(ctx); agentclient.IsDeploymentTargetGone(err) {
+			// exiting would only cause the container to be restarted, so the agent stays idle and polls less often in
+			// case the deployment target is unarchived
+			logger.Warn("deployment target has been archived or deleted, the agent will poll less often", zap.Error(err))
+			goneBackoff.Wait(ctx)
+		} else if err != nil {
 			logger.Error("failed to get resource", zap.Error(err))
 		} else {
+			goneBackoff.Reset()
+			if resource.Hold != nil {
+				logger.Info("Distr is in maintenance mode, pausing",
+					zap.String("reason", resource.Hold.Reason), zap.Duration("duration", resource.Hold.Duration()))
+				select {
+				case <-time.After(resource.Hold.Duration()):
+				case <-ctx.Done():
+				}
+				continue
+			}
+
+			if resource.Migration != nil {
+				logger.Info("deployment target has been moved to another Distr instance. starting migration")
+				if err := RunAgentMigration(ctx, resource.Migration.ConnectURL); err != nil {
+					logger.Error("migration failed", zap.Error(err))
+				} else {
+					logger.Info("migration has been applied")
+					continue
+				}
+			}
+
 			if agentenv.AgentVersionID != "" {
 				if agentenv.AgentVersionID != resource.Version.ID.String() {
 					logger.Info("agent version has changed. starting self-update")
@@ -72,6 +115,11 @@ loop:
 				}
 			}
 
+			limits.Apply(ctx, resource.ResourceLimits)
+			connectivity.HandleAsync(ctx, resource.ConnectivityCheck)
+			files.HandleAsync(ctx, resource.FileRetrievals)
+			inventory.ReportAsync(ctx, resource.InventoryEnabled, listInventory)
+
 			if resource.MetricsEnabled {
 				startMetrics(ctx)
 			} else {
@@ -89,7 +137,7 @@ loop:
 					)
 					if !resourceHasExistingDeployment {
 						logger.Info("uninstalling old deployment", zap.String("id", deployment.ID.String()))
-						if err := DockerEngineUninstall(ctx, deployment); err != nil {
+						if err := DockerEngineUninstall(ctx, deployment, true); err != nil {
 							logger.Error("could not uninstall deployment", zap.Error(err))
 						} else if err := DeleteDeployment(deployment); err != nil {
 							logger.Error("could not delete deployment", zap.Error(err))
@@ -104,6 +152,23 @@ loop:
 			}
 
 			for _, deployment := range resource.Deployments {
+				if deployment.Paused {
+					var existing *AgentDeployment
+					if d, ok := deployments[deployment.ID]; ok {
+						existing = &d
+					}
+					runPaused(ctx, existing)
+					continue
+				}
+				if deployment.Uninstall != nil {
+					var existing *AgentDeployment
+					if d, ok := deployments[deployment.ID]; ok {
+						existing = &d
+					}
+					runUninstall(ctx, deployment, existing)
+					continue
+				}
+
 				var agentDeployment *AgentDeployment
 				var status string
 				_, err = agentauth.EnsureAuth(ctx, client.RawToken(), deployment)
@@ -125,6 +190,7 @@ loop:
 						logger.Info("skip apply in swarm mode")
 						status = "status checks are not yet supported in swarm mode"
 					} else {
+						progress := NewPullProgress()
 						progressCtx, progressCancel := context.WithCancel(ctx)
 						go func(ctx context.Context) {
 							tick := time.Tick(agentenv.Interval)
@@ -135,11 +201,11 @@ loop:
 									return
 								case <-tick:
 									logger.Info("sending progress update")
-									err := client.Status(
+									err := client.PullProgress(
 										ctx,
 										deployment.RevisionID,
-										types.DeploymentStatusTypeProgressing,
 										"applying docker compose…",
+										progress.Snapshot(),
 									)
 									if err != nil {
 										logger.Warn("error updating status", zap.Error(err))
@@ -148,7 +214,7 @@ loop:
 							}
 						}(progressCtx)
 
-						if agentDeployment, status, err = DockerEngineApply(ctx, deployment); err == nil {
+						if agentDeployment, status, err = DockerEngineApply(ctx, deployment, progress); err == nil {
 							multierr.AppendInto(&err, SaveDeployment(*agentDeployment))
 						}
 
@@ -160,7 +226,61 @@ loop:
 					logger.Error("failed to send status", zap.Error(statusErr))
 				}
 			}
+
+			// the agent runs in the host network, so the endpoint must be published on the host
+			appMetrics.RelayAsync(ctx, resource.Deployments, func(endpoint types.ApplicationMetricsEndpoint) string {
+				return fmt.Sprintf("http://localhost:%v%v", endpoint.Port, endpoint.Path)
+			})
 		}
 	}
 	logger.Info("shutting down")
 }
+
+// runPaused reports the status of a paused deployment for the revision that has been applied before, without applying
+// the current revision.
+func runPaused(ctx context.Context, existing *AgentDeployment) {
+	if existing == nil {
+		logger.Info("skip apply of paused deployment that has never been applied")
+		return
+	}
+	logger.Info("skip apply of paused deployment", zap.String("id", existing.ID.String()))
+	status, err := DockerEngineStatus(ctx, *existing)
+	if statusErr := client.StatusWithError(ctx, existing.RevisionID, status, err); statusErr != nil {
+		logger.Error("failed to send status", zap.Error(statusErr))
+	}
+}
+
+// runUninstall uninstalls a deployment on request of a user and reports completion with an uninstalled status.
+func runUninstall(ctx context.Context, deployment api.AgentDeployment, existing *AgentDeployment) {
+	logger.Info("uninstalling deployment", zap.String("id", deployment.ID.String()),
+		zap.Bool("deleteData", deployment.Uninstall.DeleteData))
+	agentDeployment := existing
+	if agentDeployment == nil {
+		// the deployment was never applied by this agent, but a previous agent may have applied it
+		var err error
+		if agentDeployment, err = NewAgentDeployment(deployment); err != nil {
+			logger.Error("could not uninstall deployment", zap.Error(err))
+			if err := client.StatusWithError(ctx, deployment.RevisionID, "", err); err != nil {
+				logger.Error("failed to send status", zap.Error(err))
+			}
+			return
+		}
+	}
+	if err := DockerEngineUninstall(ctx, *agentDeployment, deployment.Uninstall.DeleteData); err != nil {
+		logger.Error("could not uninstall deployment", zap.Error(err))
+		if err := client.StatusWithError(ctx, deployment.RevisionID, "", err); err != nil {
+			logger.Error("failed to send status", zap.Error(err))
+		}
+		return
+	}
+	if existing != nil {
+		if err := DeleteDeployment(*existing); err != nil {
+			logger.Warn("could not delete deployment", zap.Error(err))
+		}
+	}
+	if err := client.Status(
+		ctx, deployment.RevisionID, types.DeploymentStatusTypeUninstalled, "deployment has been uninstalled",
+	); err != nil {
+		logger.Error("failed to send status", zap.Error(err))
+	}
+}
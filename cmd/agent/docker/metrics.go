@@ -33,6 +33,13 @@ scrapers:
         enabled: true
       system.memory.limit:
         enabled: true
+  filesystem:
+    # the root filesystem of the agent container is backed by the docker data directory of the host, so its size is
+    # the disk space that is available to deployments
+    include_virtual_filesystems: true
+    include_mount_points:
+      mount_points: ["/"]
+      match_type: strict
 `
 
 type defaultHost struct{}
@@ -66,6 +73,7 @@ func startMetrics(ctx context.Context) {
 		var cpuUsed float64
 		var memoryTotal int64
 		var memoryUsed float64
+		var diskTotal int64
 		for _, resourceMetrics := range md.ResourceMetrics().All() {
 			for _, scopeMetrics := range resourceMetrics.ScopeMetrics().All() {
 				for _, metric := range scopeMetrics.Metrics().All() {
@@ -100,6 +108,12 @@ func startMetrics(ctx context.Context) {
 					case "system.memory.limit":
 						dataPoint := metric.Sum().DataPoints().At(metric.Sum().DataPoints().Len() - 1)
 						memoryTotal = dataPoint.IntValue()
+
+					case "system.filesystem.usage":
+						// each datapoint describes one state (used, free or reserved), so their sum is the capacity
+						for _, dataPoint := range metric.Sum().DataPoints().All() {
+							diskTotal += dataPoint.IntValue()
+						}
 					}
 				}
 			}
@@ -111,12 +125,16 @@ func startMetrics(ctx context.Context) {
 		logger.Debug("cpu usage", zap.Any("usage", usage), zap.Any("cores", cores))
 		logger.Debug("memory usage", zap.Any("usage", memoryUsed), zap.Any("total", memoryTotal))
 
-		if err := client.ReportMetrics(ctx, api.AgentDeploymentTargetMetrics{
+		report := api.AgentDeploymentTargetMetrics{
 			CPUCoresMillis: cores * 1000,
 			CPUUsage:       usage,
 			MemoryBytes:    memoryTotal,
 			MemoryUsage:    memoryUsed,
-		}); err != nil {
+		}
+		if diskTotal > 0 {
+			report.DiskBytes = &diskTotal
+		}
+		if err := client.ReportMetrics(ctx, report); err != nil {
 			logger.Error("failed to report metrics", zap.Error(err))
 			return err
 		}
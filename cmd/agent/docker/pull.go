@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/cli/cli/command"
+	dockerconfig "github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/docker/api/types/image"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentauth"
+	"github.com/glasskube/distr/internal/agentpull"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	pullMaxAttempts    = 5
+	pullInitialBackoff = 2 * time.Second
+)
+
+// PullProgress tracks the download progress of all images of a deployment. It is safe for concurrent use.
+type PullProgress struct {
+	mutex  sync.Mutex
+	images map[string]map[string]*layerProgress
+}
+
+type layerProgress struct {
+	current int64
+	total   int64
+}
+
+func NewPullProgress() *PullProgress {
+	return &PullProgress{images: make(map[string]map[string]*layerProgress)}
+}
+
+// Snapshot returns the current progress of every image, sorted by image name.
+func (p *PullProgress) Snapshot() []api.AgentImagePullProgress {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	result := make([]api.AgentImagePullProgress, 0, len(p.images))
+	for name, layers := range p.images {
+		item := api.AgentImagePullProgress{Image: name}
+		for _, layer := range layers {
+			item.BytesDone += layer.current
+			item.BytesTotal += layer.total
+		}
+		result = append(result, item)
+	}
+	slices.SortFunc(result, func(a, b api.AgentImagePullProgress) int { return strings.Compare(a.Image, b.Image) })
+	return result
+}
+
+func (p *PullProgress) update(name string, msg jsonmessage.JSONMessage) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	layers, ok := p.images[name]
+	if !ok {
+		layers = make(map[string]*layerProgress)
+		p.images[name] = layers
+	}
+	if msg.ID == "" {
+		return
+	}
+	layer, ok := layers[msg.ID]
+	if !ok {
+		layer = &layerProgress{}
+		layers[msg.ID] = layer
+	}
+	switch msg.Status {
+	case "Downloading":
+		if msg.Progress != nil {
+			layer.current = msg.Progress.Current
+			if msg.Progress.Total > 0 {
+				layer.total = msg.Progress.Total
+			}
+		}
+	case "Verifying Checksum", "Download complete", "Pull complete":
+		layer.current = layer.total
+	}
+}
+
+// PullImages pulls all images referenced by the compose file of the deployment before it is applied.
+//
+// The docker daemon verifies the digest of every layer against the image manifest while pulling and resumes
+// interrupted layer downloads with range requests. Afterwards, the digest of every image is verified against the
+// digest of its reference or, for tags, against the digest that the registry returned for the tag before the pull.
+// Failed pulls are retried with an exponential backoff; layers that were downloaded completely in a previous attempt
+// are not downloaded again. At most limits.MaxConcurrentPulls images are pulled at the same time.
+func PullImages(ctx context.Context, deployment api.AgentDeployment, progress *PullProgress) error {
+	images, err := getComposeImages(deployment)
+	if err != nil || len(images) == 0 {
+		// if the images can not be determined, pulling is left to docker compose
+		return nil
+	}
+
+	dockerClient, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv, dockerclient.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dockerClient.Close() }()
+
+	configDir := dockerconfig.Dir()
+	if len(DockerConfigEnv(deployment)) > 0 {
+		configDir = agentauth.DockerConfigDir(deployment)
+	}
+	config, err := dockerconfig.Load(configDir)
+	if err != nil {
+		return fmt.Errorf("failed to load docker config: %w", err)
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(limits.MaxConcurrentPulls())
+	for _, name := range images {
+		group.Go(func() error {
+			if err := pullImageWithRetry(ctx, dockerClient, config, name, progress); err != nil {
+				return fmt.Errorf("failed to pull image %v: %w", name, err)
+			}
+			return nil
+		})
+	}
+	return group.Wait()
+}
+
+func pullImageWithRetry(
+	ctx context.Context,
+	dockerClient *dockerclient.Client,
+	config *configfile.ConfigFile,
+	name string,
+	progress *PullProgress,
+) error {
+	auth, err := command.RetrieveAuthTokenFromImage(config, name)
+	if err != nil {
+		return err
+	}
+	backoff := pullInitialBackoff
+	for attempt := 1; ; attempt++ {
+		digest, err := agentpull.ResolveDigest(ctx, dockerClient, name, auth)
+		if err == nil {
+			err = pullImage(ctx, dockerClient, name, auth, progress)
+		}
+		if err == nil {
+			return agentpull.VerifyDigest(ctx, dockerClient, name, digest)
+		} else if attempt >= pullMaxAttempts || !isTransientPullError(err) {
+			return err
+		}
+		logger.Warn("image pull failed, retrying",
+			zap.String("image", name), zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+func pullImage(
+	ctx context.Context,
+	dockerClient *dockerclient.Client,
+	name string,
+	auth string,
+	progress *PullProgress,
+) error {
+	stream, err := dockerClient.ImagePull(ctx, name, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stream.Close() }()
+	decoder := json.NewDecoder(stream)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		} else if msg.Error != nil {
+			return msg.Error
+		}
+		progress.update(name, msg)
+	}
+}
+
+func isTransientPullError(err error) bool {
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!cerrdefs.IsNotFound(err) &&
+		!cerrdefs.IsUnauthorized(err) &&
+		!cerrdefs.IsPermissionDenied(err) &&
+		!cerrdefs.IsInvalidArgument(err)
+}
+
+// getComposeImages returns the distinct images of all services in the compose file.
+// Images that contain variables are skipped, because they can only be resolved by docker compose.
+func getComposeImages(deployment api.AgentDeployment) ([]string, error) {
+	var compose struct {
+		Services map[string]struct {
+			Image string
+		}
+	}
+	if err := yaml.Unmarshal(deployment.ComposeFile, &compose); err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, svc := range compose.Services {
+		if svc.Image != "" && !strings.Contains(svc.Image, "$") && !slices.Contains(images, svc.Image) {
+			images = append(images, svc.Image)
+		}
+	}
+	slices.Sort(images)
+	return images, nil
+}
@@ -26,6 +26,20 @@ func RunAgentSelfUpdate(ctx context.Context) error {
 	}
 }
 
+// RunAgentMigration replaces this agent with an agent that is connected to the Distr instance of connectURL.
+// In contrast to a regular self-update, the manifest already contains the new target secret.
+func RunAgentMigration(ctx context.Context, connectURL string) error {
+	if manifest, err := client.ConnectManifest(ctx, connectURL); err != nil {
+		return fmt.Errorf("error fetching agent manifest: %w", err)
+	} else if parsedManifest, err := DecodeComposeFile(manifest); err != nil {
+		return fmt.Errorf("error parsing agent manifest: %w", err)
+	} else if err := ApplyAgentComposeFile(ctx, parsedManifest); err != nil {
+		return fmt.Errorf("error applying agent manifest: %w", err)
+	} else {
+		return nil
+	}
+}
+
 func PatchAgentManifest(manifest map[string]any) error {
 	if svcs, ok := manifest["services"].(map[string]any); ok {
 		if svc, ok := svcs["agent"].(map[string]any); ok {
@@ -89,3 +89,18 @@ func DeleteDeployment(ctx context.Context, namespace string, deployment AgentDep
 	}
 	return nil
 }
+
+// DeletePersistentVolumeClaims deletes the persistent volume claims that belong to a helm release but are not part of
+// it, like those created from the volume claim templates of stateful sets. They are found by the standard instance
+// label, so claims of charts that do not set it are kept.
+func DeletePersistentVolumeClaims(ctx context.Context, namespace, releaseName string) error {
+	err := k8sClient.CoreV1().PersistentVolumeClaims(namespace).DeleteCollection(
+		ctx,
+		metav1.DeleteOptions{},
+		metav1.ListOptions{LabelSelector: "app.kubernetes.io/instance=" + releaseName},
+	)
+	if err != nil {
+		return fmt.Errorf("could not delete PersistentVolumeClaims: %w", err)
+	}
+	return nil
+}
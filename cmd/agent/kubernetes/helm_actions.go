@@ -114,7 +114,9 @@ func RunHelmInstall(
 	installAction.PlainHTTP = agentenv.DistrRegistryPlainHTTP
 	if chart, err := RunHelmPreflight(&installAction.ChartPathOptions, deployment); err != nil {
 		return nil, fmt.Errorf("helm preflight failed: %w", err)
-	} else if release, err := installAction.RunWithContext(ctx, chart, deployment.Values); err != nil {
+	} else if values, err := secretResolver.ResolveValues(ctx, deployment.Values); err != nil {
+		return nil, err
+	} else if release, err := installAction.RunWithContext(ctx, chart, values); err != nil {
 		return nil, fmt.Errorf("helm install failed: %w", err)
 	} else {
 		return util.PtrTo(NewAgentDeployment(deployment, release)), nil
@@ -141,8 +143,10 @@ func RunHelmUpgrade(
 	upgradeAction.PlainHTTP = agentenv.DistrRegistryPlainHTTP
 	if chart, err := RunHelmPreflight(&upgradeAction.ChartPathOptions, deployment); err != nil {
 		return nil, fmt.Errorf("helm preflight failed: %w", err)
+	} else if values, err := secretResolver.ResolveValues(ctx, deployment.Values); err != nil {
+		return nil, err
 	} else if release, err := upgradeAction.RunWithContext(
-		ctx, deployment.ReleaseName, chart, deployment.Values); err != nil {
+		ctx, deployment.ReleaseName, chart, values); err != nil {
 		return nil, fmt.Errorf("helm upgrade failed: %w", err)
 	} else {
 		return util.PtrTo(NewAgentDeployment(deployment, release)), nil
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// helmReleaseNameAnnotation is set by helm on all resources of a release.
+const helmReleaseNameAnnotation = "meta.helm.sh/release-name"
+
+// listInventory returns the deployments, stateful sets and daemon sets in namespace. A workload is managed if it
+// belongs to the helm release of a deployment of this agent.
+func listInventory(ctx context.Context, namespace string) ([]types.InventoryItem, error) {
+	existingDeployments, err := GetExistingDeployments(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	releases := make(map[string]struct{}, len(existingDeployments))
+	for _, d := range existingDeployments {
+		releases[d.ReleaseName] = struct{}{}
+	}
+	newItem := func(kind string, meta metav1.ObjectMeta, spec corev1.PodSpec, state string) types.InventoryItem {
+		item := types.InventoryItem{Kind: kind, Name: meta.Name, Images: make([]string, len(spec.Containers)), State: state}
+		for i, c := range spec.Containers {
+			item.Images[i] = c.Image
+		}
+		if release, ok := meta.Annotations[helmReleaseNameAnnotation]; ok {
+			_, item.Managed = releases[release]
+		}
+		return item
+	}
+
+	var items []types.InventoryItem
+	apps := k8sClient.AppsV1()
+	if list, err := apps.Deployments(namespace).List(ctx, metav1.ListOptions{}); err != nil {
+		return nil, fmt.Errorf("could not list Deployments: %w", err)
+	} else {
+		for _, d := range list.Items {
+			items = append(items, newItem("Deployment", d.ObjectMeta, d.Spec.Template.Spec,
+				fmt.Sprintf("%v/%v ready", d.Status.ReadyReplicas, d.Status.Replicas)))
+		}
+	}
+	if list, err := apps.StatefulSets(namespace).List(ctx, metav1.ListOptions{}); err != nil {
+		return nil, fmt.Errorf("could not list StatefulSets: %w", err)
+	} else {
+		for _, s := range list.Items {
+			items = append(items, newItem("StatefulSet", s.ObjectMeta, s.Spec.Template.Spec,
+				fmt.Sprintf("%v/%v ready", s.Status.ReadyReplicas, s.Status.Replicas)))
+		}
+	}
+	if list, err := apps.DaemonSets(namespace).List(ctx, metav1.ListOptions{}); err != nil {
+		return nil, fmt.Errorf("could not list DaemonSets: %w", err)
+	} else {
+		for _, d := range list.Items {
+			items = append(items, newItem("DaemonSet", d.ObjectMeta, d.Spec.Template.Spec,
+				fmt.Sprintf("%v/%v ready", d.Status.NumberReady, d.Status.DesiredNumberScheduled)))
+		}
+	}
+	return items, nil
+}
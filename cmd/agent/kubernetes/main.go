@@ -14,10 +14,16 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentappmetrics"
 	"github.com/glasskube/distr/internal/agentauth"
 	"github.com/glasskube/distr/internal/agentclient"
+	"github.com/glasskube/distr/internal/agentconnectivity"
 	"github.com/glasskube/distr/internal/agentenv"
+	"github.com/glasskube/distr/internal/agentfileretrieval"
+	"github.com/glasskube/distr/internal/agentinventory"
+	"github.com/glasskube/distr/internal/agentlimits"
 	"github.com/glasskube/distr/internal/buildconfig"
+	"github.com/glasskube/distr/internal/secretref"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/google/uuid"
@@ -32,14 +38,23 @@ import (
 )
 
 var (
-	logger           = util.Require(zap.NewDevelopment())
+	loggerConfig     = zap.NewDevelopmentConfig()
+	logger           = util.Require(loggerConfig.Build())
 	agentClient      = util.Require(agentclient.NewFromEnv(logger))
+	connectivity     = agentconnectivity.NewChecker(agentClient, logger)
+	files            = agentfileretrieval.NewRetriever(agentClient, logger)
+	appMetrics       = agentappmetrics.NewRelayer(agentClient, logger)
+	inventory        = agentinventory.NewReporter(agentClient, logger)
 	k8sConfigFlags   = genericclioptions.NewConfigFlags(true)
 	k8sClient        = util.Require(kubernetes.NewForConfig(util.Require(k8sConfigFlags.ToRESTConfig())))
 	metricsClientSet = util.Require(metricsv.NewForConfig(util.Require(k8sConfigFlags.ToRESTConfig())))
 	k8sDynamicClient = util.Require(dynamic.NewForConfig(util.Require(k8sConfigFlags.ToRESTConfig())))
 	k8sRestMapper    = util.Require(k8sConfigFlags.ToRESTMapper())
 	agentConfigDirs  []string
+	// images are pulled by the kubelet, so image pulls can not be limited by the agent
+	limits = agentlimits.NewApplier(agentClient, logger, loggerConfig.Level,
+		types.AgentResourceLimitMaxConcurrentPulls, types.AgentResourceLimitMaxDownloadBytesPerSecond)
+	secretResolver = secretref.NewResolverFromEnv()
 )
 
 func init() {
@@ -75,6 +90,7 @@ func main() {
 	var logsWatcher *logsWatcher
 	var logsCancelFunc context.CancelFunc
 	tick := time.Tick(agentenv.Interval)
+	var goneBackoff agentclient.GoneBackoff
 	for ctx.Err() == nil {
 		select {
 		case <-tick:
@@ -91,15 +107,43 @@ func main() {
 		}
 
 		res, err := agentClient.Resource(ctx)
-		if err != nil {
+		if agentclient.IsDeploymentTargetGone(err) {
+			// exiting would only cause the pod to be restarted, so the agent stays idle and polls less often in case
+			// the deployment target is unarchived
+			logger.Warn("deployment target has been archived or deleted, the agent will poll less often", zap.Error(err))
+			goneBackoff.Wait(ctx)
+			continue
+		} else if err != nil {
 			logger.Error("could not get resource", zap.Error(err))
 			continue
 		}
+		goneBackoff.Reset()
+
+		if res.Hold != nil {
+			logger.Info("Distr is in maintenance mode, pausing",
+				zap.String("reason", res.Hold.Reason), zap.Duration("duration", res.Hold.Duration()))
+			select {
+			case <-time.After(res.Hold.Duration()):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		if runMigrationIfNeeded(ctx, res.Namespace, res.Migration) {
+			continue
+		}
 
 		if runSelfUpdateIfNeeded(ctx, res.Namespace, res.Version) {
 			continue
 		}
 
+		limits.Apply(ctx, res.ResourceLimits)
+		connectivity.HandleAsync(ctx, res.ConnectivityCheck)
+		files.HandleAsync(ctx, res.FileRetrievals)
+		inventory.ReportAsync(ctx, res.InventoryEnabled, func(ctx context.Context) ([]types.InventoryItem, error) {
+			return listInventory(ctx, res.Namespace)
+		})
+
 		if res.MetricsEnabled && metricsCancelFunc == nil {
 			var metricsCtx context.Context
 			metricsCtx, metricsCancelFunc = context.WithCancel(ctx)
@@ -148,6 +192,13 @@ func main() {
 			continue
 		}
 
+		appMetrics.RelayAsync(ctx, res.Deployments, func(endpoint types.ApplicationMetricsEndpoint) string {
+			if endpoint.Service == "" {
+				return ""
+			}
+			return fmt.Sprintf("http://%v.%v.svc:%v%v", endpoint.Service, res.Namespace, endpoint.Port, endpoint.Path)
+		})
+
 		for _, deployment := range res.Deployments {
 			var currentDeployment *AgentDeployment
 			for _, existing := range existingDeployments {
@@ -156,6 +207,21 @@ func main() {
 					break
 				}
 			}
+			if deployment.Paused {
+				if currentDeployment == nil {
+					logger.Info("skip install of paused deployment that has never been installed")
+				} else {
+					// the status is reported for the revision that is still installed
+					logger.Info("skip apply of paused deployment. running status check")
+					deployment.RevisionID = currentDeployment.RevisionID
+					runStatusCheck(ctx, res.Namespace, deployment, currentDeployment)
+				}
+				continue
+			}
+			if deployment.Uninstall != nil {
+				runUninstall(ctx, res.Namespace, deployment, currentDeployment)
+				continue
+			}
 			if err := verifyLatestHelmRelease(ctx, res.Namespace, deployment, currentDeployment); err != nil {
 				if errors.Is(err, driver.ErrReleaseNotFound) {
 					logger.Info("current helm release does not exist")
@@ -173,6 +239,26 @@ func main() {
 	logger.Info("shutting down")
 }
 
+// runMigrationIfNeeded applies the agent manifest of another Distr instance if the deployment target has been moved
+// there. The new manifest contains the new credentials, which are picked up by the config watch.
+func runMigrationIfNeeded(ctx context.Context, namespace string, migration *api.AgentMigration) bool {
+	if migration == nil {
+		return false
+	}
+	logger.Info("deployment target has been moved to another Distr instance. starting migration")
+	if manifest, err := agentClient.ConnectManifest(ctx, migration.ConnectURL); err != nil {
+		logger.Error("error fetching agent manifest", zap.Error(err))
+	} else if parsedManifest, err := DecodeResourceYaml(manifest); err != nil {
+		logger.Error("error parsing agent manifest", zap.Error(err))
+	} else if err := ApplyResources(ctx, namespace, parsedManifest); err != nil {
+		logger.Error("error applying agent manifest", zap.Error(err))
+	} else {
+		logger.Info("migration has been applied")
+		return true
+	}
+	return false
+}
+
 func runSelfUpdateIfNeeded(ctx context.Context, namespace string, targetVersion types.AgentVersion) bool {
 	if agentenv.AgentVersionID != "" {
 		if agentenv.AgentVersionID != targetVersion.ID.String() {
@@ -262,32 +348,41 @@ func runInstallOrUpgrade(
 		}
 	} else {
 		logger.Info("no action required. running status check")
-		if currentDeployment.LogsEnabled != deployment.LogsEnabled {
-			currentDeployment.LogsEnabled = deployment.LogsEnabled
-			if err := SaveDeployment(ctx, namespace, *currentDeployment); err != nil {
-				logger.Error("could not save latest deployment", zap.Error(err))
-				pushErrorStatus(ctx, deployment, fmt.Errorf("could not save latest deployment: %w", err))
-			}
-		} else if resources, err := GetHelmManifest(ctx, namespace, deployment.ReleaseName); err != nil {
-			logger.Warn("could not get helm manifest", zap.Error(err))
-			pushErrorStatus(ctx, deployment, fmt.Errorf("could not get helm manifest: %w", err))
-		} else {
-			var err error
-			for _, resource := range resources {
-				logger.Sugar().Debugf("check status for %v %v", resource.GetKind(), resource.GetName())
-				if err = CheckStatus(ctx, namespace, resource); err != nil {
-					break
-				}
-			}
+		runStatusCheck(ctx, namespace, deployment, currentDeployment)
+	}
+}
 
-			if err != nil {
-				logger.Warn("resource status error", zap.Error(err))
-				pushErrorStatus(ctx, deployment, fmt.Errorf("resource status error: %w", err))
-			} else {
-				logger.Info("status check passed")
-				pushStatus(ctx, deployment, fmt.Sprintf("status check passed. %v resources", len(resources)))
+func runStatusCheck(
+	ctx context.Context,
+	namespace string,
+	deployment api.AgentDeployment,
+	currentDeployment *AgentDeployment,
+) {
+	if currentDeployment.LogsEnabled != deployment.LogsEnabled {
+		currentDeployment.LogsEnabled = deployment.LogsEnabled
+		if err := SaveDeployment(ctx, namespace, *currentDeployment); err != nil {
+			logger.Error("could not save latest deployment", zap.Error(err))
+			pushErrorStatus(ctx, deployment, fmt.Errorf("could not save latest deployment: %w", err))
+		}
+	} else if resources, err := GetHelmManifest(ctx, namespace, deployment.ReleaseName); err != nil {
+		logger.Warn("could not get helm manifest", zap.Error(err))
+		pushErrorStatus(ctx, deployment, fmt.Errorf("could not get helm manifest: %w", err))
+	} else {
+		var err error
+		for _, resource := range resources {
+			logger.Sugar().Debugf("check status for %v %v", resource.GetKind(), resource.GetName())
+			if err = CheckStatus(ctx, namespace, resource); err != nil {
+				break
 			}
 		}
+
+		if err != nil {
+			logger.Warn("resource status error", zap.Error(err))
+			pushErrorStatus(ctx, deployment, fmt.Errorf("resource status error: %w", err))
+		} else {
+			logger.Info("status check passed")
+			pushStatus(ctx, deployment, fmt.Sprintf("status check passed. %v resources", len(resources)))
+		}
 	}
 }
 
@@ -320,6 +415,40 @@ func (psr *progressStatusRunner) Run(ctx context.Context, f func() error) error
 	return f()
 }
 
+// runUninstall uninstalls a deployment on request of a user and reports completion with an uninstalled status.
+// Persistent volume claims that are not removed by helm are only deleted if the data should be deleted.
+func runUninstall(
+	ctx context.Context,
+	namespace string,
+	deployment api.AgentDeployment,
+	currentDeployment *AgentDeployment,
+) {
+	logger.Info("uninstalling deployment", zap.String("id", deployment.ID.String()),
+		zap.Bool("deleteData", deployment.Uninstall.DeleteData))
+	if err := RunHelmUninstall(ctx, namespace, deployment.ReleaseName); err != nil {
+		logger.Error("uninstall error", zap.Error(err))
+		pushErrorStatus(ctx, deployment, fmt.Errorf("uninstall error: %w", err))
+		return
+	}
+	if deployment.Uninstall.DeleteData {
+		if err := DeletePersistentVolumeClaims(ctx, namespace, deployment.ReleaseName); err != nil {
+			logger.Error("uninstall error", zap.Error(err))
+			pushErrorStatus(ctx, deployment, fmt.Errorf("uninstall error: %w", err))
+			return
+		}
+	}
+	if currentDeployment != nil {
+		if err := DeleteDeployment(ctx, namespace, *currentDeployment); err != nil {
+			logger.Warn("could not delete AgentDeployment resource", zap.Error(err))
+		}
+	}
+	if err := agentClient.Status(
+		ctx, deployment.RevisionID, types.DeploymentStatusTypeUninstalled, "helm uninstall succeeded",
+	); err != nil {
+		logger.Warn("status push failed", zap.Error(err))
+	}
+}
+
 func pushStatus(ctx context.Context, deployment api.AgentDeployment, status string) {
 	if err := agentClient.Status(ctx, deployment.RevisionID, types.DeploymentStatusTypeOK, status); err != nil {
 		logger.Warn("status push failed", zap.Error(err))
@@ -338,7 +467,7 @@ func pushProgressingStatus(ctx context.Context, deployment api.AgentDeployment)
 }
 
 func pushErrorStatus(ctx context.Context, deployment api.AgentDeployment, err error) {
-	if err := agentClient.Status(ctx, deployment.RevisionID, types.DeploymentStatusTypeError, err.Error()); err != nil {
+	if err := agentClient.StatusWithError(ctx, deployment.RevisionID, "", err); err != nil {
 		logger.Warn("status push failed", zap.Error(err))
 	}
 }
@@ -28,6 +28,7 @@ func doReportMetrics(ctx context.Context) {
 	var cpuUsageM int64
 	var memoryCapacityBytes int64
 	var memoryUsageBytes int64
+	var diskCapacityBytes int64
 	if nodes, err := k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{}); err != nil {
 		logger.Error("getting nodes failed", zap.Error(err))
 		return
@@ -36,6 +37,7 @@ func doReportMetrics(ctx context.Context) {
 			logger.Info("node", zap.String("name", node.Name))
 			cpuCapacityM += node.Status.Capacity.Cpu().MilliValue()
 			memoryCapacityBytes += node.Status.Capacity.Memory().Value()
+			diskCapacityBytes += node.Status.Capacity.StorageEphemeral().Value()
 
 			if nodeMetrics, err := metricsClientSet.MetricsV1beta1().NodeMetricses().
 				Get(ctx, node.Name, metav1.GetOptions{}); err != nil {
@@ -56,12 +58,16 @@ func doReportMetrics(ctx context.Context) {
 		zap.Any("memUsageSum", memoryUsageBytes))
 
 	if cpuCapacityM > 0 && memoryCapacityBytes > 0 {
-		if err := agentClient.ReportMetrics(ctx, api.AgentDeploymentTargetMetrics{
+		metrics := api.AgentDeploymentTargetMetrics{
 			CPUCoresMillis: cpuCapacityM,
 			CPUUsage:       float64(cpuUsageM) / float64(cpuCapacityM),
 			MemoryBytes:    memoryCapacityBytes,
 			MemoryUsage:    float64(memoryUsageBytes) / float64(memoryCapacityBytes),
-		}); err != nil {
+		}
+		if diskCapacityBytes > 0 {
+			metrics.DiskBytes = &diskCapacityBytes
+		}
+		if err := agentClient.ReportMetrics(ctx, metrics); err != nil {
 			logger.Error("failed to report metrics", zap.Error(err))
 		}
 	}
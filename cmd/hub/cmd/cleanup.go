@@ -19,6 +19,8 @@ const (
 	deploymentTargetMetrics  = "DeploymentTargetMetrics"
 	deploymentRevisionStatus = "DeploymentRevisionStatus"
 	deploymentLogRecord      = "DeploymentLogRecord"
+	orphanedFile             = "OrphanedFile"
+	dataRetention            = "DataRetention"
 )
 
 type CleanupOptions struct{ Type string }
@@ -26,11 +28,13 @@ type CleanupOptions struct{ Type string }
 var CleanupCommand = &cobra.Command{
 	Use: "cleanup <type>",
 	Long: fmt.Sprintf(
-		"type must be one of: %v, %v, %v, %v",
+		"type must be one of: %v, %v, %v, %v, %v, %v",
 		deploymentTargetStatus,
 		deploymentRevisionStatus,
 		deploymentTargetMetrics,
 		deploymentLogRecord,
+		orphanedFile,
+		dataRetention,
 	),
 	Short: "delete old data",
 	Args:  cobra.ExactArgs(1),
@@ -39,6 +43,8 @@ var CleanupCommand = &cobra.Command{
 		deploymentRevisionStatus,
 		deploymentTargetMetrics,
 		deploymentLogRecord,
+		orphanedFile,
+		dataRetention,
 	},
 	PreRun: func(cmd *cobra.Command, args []string) { env.Initialize() },
 	Run: func(cmd *cobra.Command, args []string) {
@@ -65,6 +71,10 @@ func runCleanup(ctx context.Context, opts CleanupOptions) {
 		cleanupFunc = cleanup.RunDeploymentTargetMetricsCleanup
 	case deploymentLogRecord:
 		cleanupFunc = cleanup.RunDeploymentLogRecordCleanup
+	case orphanedFile:
+		cleanupFunc = cleanup.RunOrphanedFileCleanup
+	case dataRetention:
+		cleanupFunc = cleanup.RunDataRetentionCleanup
 	default:
 		log.Sugar().Errorf("invalid cleanup type: %v", opts.Type)
 		os.Exit(1)
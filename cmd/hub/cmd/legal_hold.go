@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/legalhold"
+	"github.com/glasskube/distr/internal/svc"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type LegalHoldOptions struct {
+	OrganizationID string
+	UserAccountID  string
+	Reason         string
+}
+
+var legalHoldOpts = LegalHoldOptions{}
+
+var LegalHoldCommand = &cobra.Command{
+	Use:   "legal-hold",
+	Short: "control legal holds",
+	Long: "While a legal hold is active, cleanup jobs do not delete any data of the organization.\n" +
+		"With --user-account, the hold only covers the data of one customer account of the organization.",
+}
+
+var LegalHoldPlaceCommand = &cobra.Command{
+	Use:    "place",
+	Short:  "place a legal hold",
+	Args:   cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) { env.Initialize() },
+	Run: func(cmd *cobra.Command, args []string) {
+		runLegalHold(cmd.Context(), func(ctx context.Context, log *zap.Logger, orgID uuid.UUID, userID *uuid.UUID) error {
+			hold := types.LegalHold{OrganizationID: orgID, UserAccountID: userID, Reason: legalHoldOpts.Reason}
+			if err := legalhold.Place(ctx, &hold, "cli"); errors.Is(err, apierrors.ErrConflict) {
+				log.Info("legal hold is already active")
+			} else if err != nil {
+				return err
+			} else {
+				log.Info("legal hold has been placed", zap.Stringer("id", hold.ID))
+			}
+			return nil
+		})
+	},
+}
+
+var LegalHoldReleaseCommand = &cobra.Command{
+	Use:    "release",
+	Short:  "release a legal hold",
+	Args:   cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) { env.Initialize() },
+	Run: func(cmd *cobra.Command, args []string) {
+		runLegalHold(cmd.Context(), func(ctx context.Context, log *zap.Logger, orgID uuid.UUID, userID *uuid.UUID) error {
+			if err := legalhold.Release(ctx, orgID, userID, "cli"); errors.Is(err, apierrors.ErrNotFound) {
+				log.Info("legal hold is not active")
+			} else if err != nil {
+				return err
+			} else {
+				log.Info("legal hold has been released")
+			}
+			return nil
+		})
+	},
+}
+
+var LegalHoldStatusCommand = &cobra.Command{
+	Use:    "status",
+	Short:  "show all active legal holds",
+	Args:   cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) { env.Initialize() },
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, log, shutdown := legalHoldContext(cmd.Context())
+		defer shutdown()
+		holds, err := db.GetLegalHolds(ctx)
+		if err != nil {
+			log.Error("legal hold command failed", zap.Error(err))
+			os.Exit(1)
+		}
+		if len(holds) == 0 {
+			log.Info("no legal hold is active")
+		}
+		for _, hold := range holds {
+			log.Info("legal hold is active",
+				zap.Stringer("organizationId", hold.OrganizationID),
+				zap.Any("userAccountId", hold.UserAccountID),
+				zap.String("reason", hold.Reason),
+				zap.Time("since", hold.CreatedAt))
+		}
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{LegalHoldPlaceCommand, LegalHoldReleaseCommand} {
+		cmd.Flags().StringVar(&legalHoldOpts.OrganizationID, "organization", "", "ID of the organization")
+		cmd.Flags().StringVar(&legalHoldOpts.UserAccountID, "user-account", "",
+			"ID of a customer account. If not set, the hold applies to the whole organization")
+		util.Must(cmd.MarkFlagRequired("organization"))
+	}
+	LegalHoldPlaceCommand.Flags().StringVar(&legalHoldOpts.Reason, "reason", "",
+		"reason for the hold, for example a case reference")
+	util.Must(LegalHoldPlaceCommand.MarkFlagRequired("reason"))
+
+	LegalHoldCommand.AddCommand(LegalHoldPlaceCommand, LegalHoldReleaseCommand, LegalHoldStatusCommand)
+	RootCommand.AddCommand(LegalHoldCommand)
+}
+
+func legalHoldContext(ctx context.Context) (context.Context, *zap.Logger, func()) {
+	registry := util.Require(svc.NewDefault(ctx))
+	log := registry.GetLogger()
+	ctx = internalctx.WithDb(ctx, registry.GetDbPool())
+	ctx = internalctx.WithLogger(ctx, log)
+	return ctx, log, func() { util.Must(registry.Shutdown(ctx)) }
+}
+
+func runLegalHold(
+	ctx context.Context,
+	fn func(ctx context.Context, log *zap.Logger, orgID uuid.UUID, userID *uuid.UUID) error,
+) {
+	ctx, log, shutdown := legalHoldContext(ctx)
+	defer shutdown()
+
+	orgID, err := uuid.Parse(legalHoldOpts.OrganizationID)
+	if err != nil {
+		log.Error("invalid organization ID", zap.Error(err))
+		os.Exit(1)
+	}
+	var userID *uuid.UUID
+	if legalHoldOpts.UserAccountID != "" {
+		if id, err := uuid.Parse(legalHoldOpts.UserAccountID); err != nil {
+			log.Error("invalid user account ID", zap.Error(err))
+			os.Exit(1)
+		} else {
+			userID = &id
+		}
+	}
+
+	if err := fn(ctx, log, orgID, userID); err != nil {
+		log.Error("legal hold command failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
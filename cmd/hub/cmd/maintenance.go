@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/maintenance"
+	"github.com/glasskube/distr/internal/svc"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type MaintenanceOptions struct {
+	OrganizationID string
+	Reason         string
+	RetryAfter     time.Duration
+}
+
+var maintenanceOpts = MaintenanceOptions{RetryAfter: maintenance.DefaultRetryAfter * time.Second}
+
+var MaintenanceCommand = &cobra.Command{
+	Use:   "maintenance",
+	Short: "control read-only maintenance mode",
+	Long: "While maintenance mode is active, mutating API requests and registry pushes are rejected.\n" +
+		"Without --organization, maintenance mode applies to the whole server.",
+}
+
+var MaintenanceEnterCommand = &cobra.Command{
+	Use:    "enter",
+	Short:  "enter maintenance mode or update its reason",
+	Args:   cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) { env.Initialize() },
+	Run: func(cmd *cobra.Command, args []string) {
+		runMaintenance(cmd.Context(), func(ctx context.Context, log *zap.Logger, orgID *uuid.UUID) error {
+			mode := types.MaintenanceMode{
+				OrganizationID:    orgID,
+				Reason:            maintenanceOpts.Reason,
+				RetryAfterSeconds: int(maintenanceOpts.RetryAfter.Seconds()),
+			}
+			if err := maintenance.Enter(ctx, &mode, "cli"); err != nil {
+				return err
+			}
+			log.Info("maintenance mode is active", zap.String("reason", mode.Reason))
+			return nil
+		})
+	},
+}
+
+var MaintenanceLeaveCommand = &cobra.Command{
+	Use:    "leave",
+	Short:  "leave maintenance mode",
+	Args:   cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) { env.Initialize() },
+	Run: func(cmd *cobra.Command, args []string) {
+		runMaintenance(cmd.Context(), func(ctx context.Context, log *zap.Logger, orgID *uuid.UUID) error {
+			if err := maintenance.Leave(ctx, orgID, nil, "cli"); errors.Is(err, apierrors.ErrNotFound) {
+				log.Info("maintenance mode is not active")
+			} else if err != nil {
+				return err
+			} else {
+				log.Info("maintenance mode has been left")
+			}
+			return nil
+		})
+	},
+}
+
+var MaintenanceStatusCommand = &cobra.Command{
+	Use:    "status",
+	Short:  "show all active maintenance modes",
+	Args:   cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) { env.Initialize() },
+	Run: func(cmd *cobra.Command, args []string) {
+		runMaintenance(cmd.Context(), func(ctx context.Context, log *zap.Logger, _ *uuid.UUID) error {
+			modes, err := db.GetMaintenanceModes(ctx)
+			if err != nil {
+				return err
+			}
+			if len(modes) == 0 {
+				log.Info("maintenance mode is not active")
+			}
+			for _, mode := range modes {
+				log.Info("maintenance mode is active",
+					zap.Any("organizationId", mode.OrganizationID),
+					zap.String("reason", mode.Reason),
+					zap.Time("since", mode.CreatedAt),
+					zap.Int("retryAfterSeconds", mode.RetryAfterSeconds))
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	MaintenanceCommand.PersistentFlags().StringVar(&maintenanceOpts.OrganizationID, "organization", "",
+		"ID of the organization. If not set, maintenance mode applies to the whole server")
+	MaintenanceEnterCommand.Flags().StringVar(&maintenanceOpts.Reason, "reason", maintenanceOpts.Reason,
+		"human-readable reason that is shown to users")
+	MaintenanceEnterCommand.Flags().DurationVar(&maintenanceOpts.RetryAfter, "retry-after", maintenanceOpts.RetryAfter,
+		"time after which clients should retry rejected requests")
+
+	MaintenanceCommand.AddCommand(MaintenanceEnterCommand, MaintenanceLeaveCommand, MaintenanceStatusCommand)
+	RootCommand.AddCommand(MaintenanceCommand)
+}
+
+func runMaintenance(ctx context.Context, fn func(ctx context.Context, log *zap.Logger, orgID *uuid.UUID) error) {
+	registry := util.Require(svc.NewDefault(ctx))
+	defer func() { util.Must(registry.Shutdown(ctx)) }()
+	log := registry.GetLogger()
+
+	var orgID *uuid.UUID
+	if maintenanceOpts.OrganizationID != "" {
+		if id, err := uuid.Parse(maintenanceOpts.OrganizationID); err != nil {
+			log.Error("invalid organization ID", zap.Error(err))
+			os.Exit(1)
+		} else {
+			orgID = &id
+		}
+	}
+
+	ctx = internalctx.WithDb(ctx, registry.GetDbPool())
+	ctx = internalctx.WithLogger(ctx, log)
+
+	if err := fn(ctx, log, orgID); err != nil {
+		log.Error("maintenance command failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/scrub"
 	"github.com/glasskube/distr/internal/svc"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/spf13/cobra"
@@ -38,12 +39,16 @@ func init() {
 }
 
 func runServe(ctx context.Context, opts ServeOptions) {
+	scrubber := scrub.FromEnv()
 	util.Must(sentry.Init(sentry.ClientOptions{
-		Dsn:              env.SentryDSN(),
-		Debug:            env.SentryDebug(),
-		EnableTracing:    env.OtelExporterSentryEnabled(),
-		TracesSampleRate: 1.0,
-		Release:          buildconfig.Version(),
+		Dsn:                   env.SentryDSN(),
+		Debug:                 env.SentryDebug(),
+		EnableTracing:         env.OtelExporterSentryEnabled(),
+		TracesSampleRate:      1.0,
+		Release:               buildconfig.Version(),
+		BeforeSend:            scrubber.SentryEvent,
+		BeforeSendTransaction: scrubber.SentryEvent,
+		BeforeBreadcrumb:      scrubber.SentryBreadcrumb,
 	}))
 	defer sentry.Flush(5 * time.Second)
 	defer func() {
@@ -58,20 +63,28 @@ func runServe(ctx context.Context, opts ServeOptions) {
 
 	util.Must(db.CreateAgentVersion(internalctx.WithDb(ctx, registry.GetDbPool())))
 
+	registry.GetMaintenanceWatcher().Start(ctx)
+	registry.GetManifestCacheSync().Start(ctx)
+	registry.GetSelfCheck().Start(ctx)
+
 	server := registry.GetServer()
 	artifactsServer := registry.GetArtifactsServer()
+	metricsServer := registry.GetMetricsServer()
 
 	sigCtx, _ := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
 	context.AfterFunc(sigCtx, func() {
 		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		server.Shutdown(ctx)
 		artifactsServer.Shutdown(ctx)
+		metricsServer.Shutdown(ctx)
 		cancel()
 	})
 
 	go func() { util.Must(server.Start(":8080")) }()
 	go func() { util.Must(artifactsServer.Start(":8585")) }()
+	go func() { util.Must(metricsServer.Start(env.RegistryMetricsAddr())) }()
 	registry.GetJobsScheduler().Start()
 	server.WaitForShutdown()
 	artifactsServer.WaitForShutdown()
+	metricsServer.WaitForShutdown()
 }
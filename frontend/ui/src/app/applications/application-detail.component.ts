@@ -1,5 +1,6 @@
 import {OverlayModule} from '@angular/cdk/overlay';
 import {AsyncPipe, DatePipe, NgOptimizedImage} from '@angular/common';
+import {HttpErrorResponse} from '@angular/common/http';
 import {Component, ElementRef, inject, OnDestroy, OnInit, signal, ViewChild} from '@angular/core';
 import {FormControl, FormGroup, ReactiveFormsModule, Validators} from '@angular/forms';
 import {ActivatedRoute, Router, RouterLink} from '@angular/router';
@@ -15,7 +16,15 @@ import {
   faTrash,
   faXmark,
 } from '@fortawesome/free-solid-svg-icons';
-import {Application, ApplicationVersion, HelmChartType} from '@glasskube/distr-sdk';
+import {
+  Application,
+  ApplicationDeletionCascade,
+  ApplicationVersion,
+  ApplicationVersionPolicy,
+  HelmChartType,
+  ResourceRequirements,
+  ResourceRequirementsEnforcement,
+} from '@glasskube/distr-sdk';
 import {
   catchError,
   combineLatest,
@@ -47,6 +56,8 @@ import {ApplicationsService} from '../services/applications.service';
 import {OverlayService} from '../services/overlay.service';
 import {ToastService} from '../services/toast.service';
 
+const GiB = 1024 * 1024 * 1024;
+
 @Component({
   selector: 'app-application-detail',
   imports: [
@@ -90,6 +101,8 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
       if (app) {
         this.editForm.patchValue({name: app.name});
         this.enableTypeSpecificGroups(app);
+        this.patchRequirementsForm(app.resourceRequirements);
+        this.versionPolicyForm.reset({versionPolicy: app.versionPolicy ?? 'lenient'});
       }
     })
   );
@@ -140,6 +153,18 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
     name: new FormControl('', Validators.required),
   });
   editFormLoading = signal(false);
+  requirementsForm = new FormGroup({
+    minCpuCores: new FormControl<number | null>(null, Validators.min(0.001)),
+    minMemoryGiB: new FormControl<number | null>(null, Validators.min(0.001)),
+    minDiskGiB: new FormControl<number | null>(null, Validators.min(0.001)),
+    architectures: new FormControl(''),
+    enforcement: new FormControl<ResourceRequirementsEnforcement>('warn', {nonNullable: true}),
+  });
+  requirementsFormLoading = signal(false);
+  versionPolicyForm = new FormGroup({
+    versionPolicy: new FormControl<ApplicationVersionPolicy>('lenient', {nonNullable: true}),
+  });
+  versionPolicyFormLoading = signal(false);
 
   protected readonly faBoxesStacked = faBoxesStacked;
   protected readonly faChevronDown = faChevronDown;
@@ -215,7 +240,71 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
     }
   }
 
-  async createVersion(application: Application) {
+  private patchRequirementsForm(requirements?: ResourceRequirements) {
+    this.requirementsForm.reset({
+      minCpuCores: requirements?.minCpuCoresMillis ? requirements.minCpuCoresMillis / 1000 : null,
+      minMemoryGiB: requirements?.minMemoryBytes ? requirements.minMemoryBytes / GiB : null,
+      minDiskGiB: requirements?.minDiskBytes ? requirements.minDiskBytes / GiB : null,
+      architectures: requirements?.architectures?.join(', ') ?? '',
+      enforcement: requirements?.enforcement ?? 'warn',
+    });
+  }
+
+  async saveResourceRequirements(application: Application) {
+    this.requirementsForm.markAllAsTouched();
+    if (!this.requirementsForm.valid) {
+      return;
+    }
+    const value = this.requirementsForm.value;
+    const requirements: ResourceRequirements = {
+      minCpuCoresMillis: value.minCpuCores ? Math.round(value.minCpuCores * 1000) : undefined,
+      minMemoryBytes: value.minMemoryGiB ? Math.round(value.minMemoryGiB * GiB) : undefined,
+      minDiskBytes: value.minDiskGiB ? Math.round(value.minDiskGiB * GiB) : undefined,
+      architectures: (value.architectures ?? '')
+        .split(',')
+        .map((it) => it.trim())
+        .filter((it) => it),
+      enforcement: value.enforcement,
+    };
+    const isEmpty =
+      !requirements.minCpuCoresMillis &&
+      !requirements.minMemoryBytes &&
+      !requirements.minDiskBytes &&
+      !requirements.architectures?.length;
+    this.requirementsFormLoading.set(true);
+    try {
+      await lastValueFrom(
+        this.applicationService.update({...application, resourceRequirements: isEmpty ? undefined : requirements})
+      );
+      this.toast.success('System requirements saved successfully');
+    } catch (e) {
+      const msg = getFormDisplayedError(e);
+      if (msg) {
+        this.toast.error(msg);
+      }
+    } finally {
+      this.requirementsFormLoading.set(false);
+    }
+  }
+
+  async saveVersionPolicy(application: Application) {
+    this.versionPolicyFormLoading.set(true);
+    try {
+      await lastValueFrom(
+        this.applicationService.update({...application, versionPolicy: this.versionPolicyForm.value.versionPolicy})
+      );
+      this.toast.success('Version policy saved successfully');
+    } catch (e) {
+      const msg = getFormDisplayedError(e);
+      if (msg) {
+        this.toast.error(msg);
+      }
+    } finally {
+      this.versionPolicyFormLoading.set(false);
+    }
+  }
+
+  async createVersion(application: Application, force = false) {
     this.newVersionForm.markAllAsTouched();
     if (this.newVersionForm.valid && application) {
       this.newVersionFormLoading.set(true);
@@ -225,7 +314,8 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
           application,
           {name: this.newVersionForm.controls.versionName.value!},
           this.newVersionForm.controls.docker.controls.compose.value!,
-          this.newVersionForm.controls.docker.controls.template.value
+          this.newVersionForm.controls.docker.controls.template.value,
+          force
         );
       } else {
         const versionFormVal = this.newVersionForm.controls.kubernetes.value;
@@ -240,7 +330,8 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
           application,
           version,
           versionFormVal.baseValues,
-          versionFormVal.template
+          versionFormVal.template,
+          force
         );
       }
 
@@ -250,6 +341,18 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
         this.newVersionForm.reset();
         this.enableTypeSpecificGroups(application);
       } catch (e) {
+        if (
+          !force &&
+          e instanceof HttpErrorResponse &&
+          e.headers.get('X-Distr-Error-Code') === 'VERSION_NOT_INCREASING'
+        ) {
+          this.newVersionFormLoading.set(false);
+          const confirmed = await firstValueFrom(this.overlay.confirm(`${e.error} Create it anyway?`));
+          if (confirmed) {
+            await this.createVersion(application, true);
+          }
+          return;
+        }
         const msg = getFormDisplayedError(e);
         if (msg) {
           this.toast.error(msg);
@@ -260,6 +363,18 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
     }
   }
 
+  async suggestVersionName(application: Application) {
+    try {
+      const next = await firstValueFrom(this.applicationService.getNextVersion(application));
+      this.newVersionForm.patchValue({versionName: next.version});
+    } catch (e) {
+      const msg = getFormDisplayedError(e);
+      if (msg) {
+        this.toast.error(msg);
+      }
+    }
+  }
+
   async fillVersionFormWith(application: Application, version: ApplicationVersion) {
     this.isVersionFormExpanded.set(true);
     if (application.type === 'kubernetes') {
@@ -302,12 +417,27 @@ export class ApplicationDetailComponent implements OnInit, OnDestroy {
   }
 
   deleteApplication(application: Application) {
-    this.overlay
-      .confirm(`Really delete ${application.name}?`)
+    this.applicationService
+      .getDeletionImpact(application)
       .pipe(
-        filter((result) => result === true),
-        switchMap(async () => {
-          await lastValueFrom(this.applicationService.delete(application));
+        switchMap((impact) => {
+          const deployedTo = impact.deployments
+            .map((d) => (d.releaseName ? `${d.deploymentTargetName} (${d.releaseName})` : d.deploymentTargetName))
+            .join(', ');
+          const cascade: ApplicationDeletionCascade | undefined = deployedTo ? 'archive' : undefined;
+          const impactMessage = deployedTo
+            ? `${application.name} is still deployed to ${deployedTo}. These deployments will be archived. `
+            : '';
+          const message =
+            impactMessage +
+            `Really delete ${application.name}? It can be restored from the applications list for a few days.`;
+          return this.overlay.confirm(message).pipe(
+            filter((result) => result === true),
+            map(() => cascade)
+          );
+        }),
+        switchMap(async (cascade) => {
+          await lastValueFrom(this.applicationService.delete(application, cascade));
           return this.router.navigate(['/applications']);
         }),
         catchError((e) => {
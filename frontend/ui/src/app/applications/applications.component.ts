@@ -9,10 +9,11 @@ import {
   faMagnifyingGlass,
   faPen,
   faPlus,
+  faRotateLeft,
   faTrash,
   faXmark,
 } from '@fortawesome/free-solid-svg-icons';
-import {lastValueFrom, Observable, Subject, takeUntil} from 'rxjs';
+import {BehaviorSubject, lastValueFrom, Observable, Subject, switchMap, takeUntil} from 'rxjs';
 import {drawerFlyInOut} from '../animations/drawer';
 import {dropdownAnimation} from '../animations/dropdown';
 import {modalFlyInOut} from '../animations/modal';
@@ -55,6 +56,7 @@ export class ApplicationsComponent implements OnDestroy {
   protected readonly faXmark = faXmark;
   protected readonly faBoxArchive = faBoxArchive;
   protected readonly faTrash = faTrash;
+  protected readonly faRotateLeft = faRotateLeft;
   showDropdown = false;
 
   private readonly destroyed$ = new Subject<void>();
@@ -67,6 +69,11 @@ export class ApplicationsComponent implements OnDestroy {
     this.filterForm.controls.search,
     (it: Application, search: string) => !search || (it.name || '').toLowerCase().includes(search.toLowerCase())
   ).pipe(takeUntil(this.destroyed$));
+  private readonly deletedApplicationsRefresh$ = new BehaviorSubject<void>(undefined);
+  deletedApplications$: Observable<Application[]> = this.deletedApplicationsRefresh$.pipe(
+    switchMap(() => this.applications.listDeleted()),
+    takeUntil(this.destroyed$)
+  );
   editForm = new FormGroup({
     id: new FormControl(''),
     name: new FormControl('', Validators.required),
@@ -127,5 +134,18 @@ export class ApplicationsComponent implements OnDestroy {
     }
   }
 
+  async restoreApplication(application: Application) {
+    try {
+      await lastValueFrom(this.applications.restore(application));
+      this.toast.success(`${application.name} restored successfully`);
+      this.deletedApplicationsRefresh$.next();
+    } catch (e) {
+      const msg = getFormDisplayedError(e);
+      if (msg) {
+        this.toast.error(msg);
+      }
+    }
+  }
+
   protected readonly faBox = faBox;
 }
@@ -40,6 +40,7 @@ import {modalFlyInOut} from '../../animations/modal';
 import {EditArtifactLicenseComponent} from './edit-artifact-license.component';
 import {UsersService} from '../../services/users.service';
 import {ArtifactsService} from '../../services/artifacts.service';
+import {describeLicenseImpact} from '../../types/license-impact';
 
 @Component({
   selector: 'app-artifact-licenses',
@@ -127,11 +128,20 @@ export class ArtifactLicensesComponent implements OnDestroy {
   }
 
   deleteLicense(license: ArtifactLicense) {
-    this.overlay
-      .confirm(`Really delete ${license.name}?`)
+    this.artifactLicensesService
+      .impact(license)
       .pipe(
+        switchMap((impact) => {
+          const description = describeLicenseImpact(impact);
+          return this.overlay.confirm({
+            message: {
+              message: `Really delete ${license.name}?`,
+              warning: description ? {message: description} : undefined,
+            },
+          });
+        }),
         filter((result) => result === true),
-        switchMap(() => this.artifactLicensesService.delete(license)),
+        switchMap(() => this.artifactLicensesService.delete(license, true)),
         catchError((e) => {
           const msg = getFormDisplayedError(e);
           if (msg) {
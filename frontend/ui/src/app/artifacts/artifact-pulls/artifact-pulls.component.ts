@@ -1,6 +1,6 @@
 import {AsyncPipe, DatePipe} from '@angular/common';
 import {Component, inject} from '@angular/core';
-import {scan, shareReplay, startWith, Subject, switchMap, tap} from 'rxjs';
+import {map, scan, shareReplay, startWith, Subject, switchMap, tap} from 'rxjs';
 import {ArtifactPullsService} from '../../services/artifact-pulls.service';
 
 @Component({
@@ -9,22 +9,19 @@ import {ArtifactPullsService} from '../../services/artifact-pulls.service';
 })
 export class ArtifactPullsComponent {
   protected hasMore = true;
-  private currentOldestPull?: Date;
+  private nextCursor?: string;
   private readonly fetchCount = 50;
   private readonly showMore$ = new Subject<void>();
   private readonly pulls = inject(ArtifactPullsService);
 
   protected readonly pulls$ = this.showMore$.pipe(
     startWith(undefined),
-    switchMap(() => this.pulls.get({before: this.currentOldestPull, count: this.fetchCount})),
-    tap((it) => {
-      if (it.length > 0) {
-        this.currentOldestPull = new Date(it[it.length - 1].createdAt);
-      }
-      if (it.length < this.fetchCount) {
-        this.hasMore = false;
-      }
+    switchMap(() => this.pulls.get({cursor: this.nextCursor, limit: this.fetchCount})),
+    tap((page) => {
+      this.nextCursor = page.nextCursor;
+      this.hasMore = page.nextCursor !== undefined;
     }),
+    map((page) => page.pulls),
     scan((all, next) => [...all, ...next]),
     shareReplay(1)
   );
@@ -0,0 +1,67 @@
+import {AsyncPipe} from '@angular/common';
+import {Component, inject} from '@angular/core';
+import {FaIconComponent} from '@fortawesome/angular-fontawesome';
+import {faCircleInfo, faTriangleExclamation, faXmark} from '@fortawesome/free-solid-svg-icons';
+import {MarkdownPipe} from 'ngx-markdown';
+import {firstValueFrom, tap} from 'rxjs';
+import {AnnouncementSeverity, AnnouncementsService, UserAnnouncement} from '../services/announcements.service';
+import {AuthService} from '../services/auth.service';
+
+@Component({
+  selector: 'app-announcement-banner',
+  template: `
+    @if (auth.hasRole('customer')) {
+      @for (announcement of announcements$ | async; track announcement.id) {
+        <div
+          class="flex items-start gap-2 px-4 py-2 text-sm"
+          [class]="severityClasses[announcement.severity]"
+          role="alert">
+          <fa-icon
+            class="mt-0.5"
+            [icon]="announcement.severity === 'info' ? faCircleInfo : faTriangleExclamation"></fa-icon>
+          <div class="grow">
+            <span class="font-medium">{{ announcement.title }}</span>
+            <div class="markdown" [innerHTML]="announcement.body | markdown | async"></div>
+          </div>
+          <button type="button" class="ms-auto" aria-label="Dismiss" (click)="dismiss(announcement)">
+            <fa-icon [icon]="faXmark"></fa-icon>
+          </button>
+        </div>
+      }
+    }
+  `,
+  imports: [AsyncPipe, FaIconComponent, MarkdownPipe],
+})
+export class AnnouncementBannerComponent {
+  protected readonly auth = inject(AuthService);
+  private readonly announcements = inject(AnnouncementsService);
+  private readonly markedAsRead = new Set<string>();
+
+  protected readonly announcements$ = this.announcements.active$.pipe(
+    tap((announcements) => this.markRead(announcements))
+  );
+
+  protected readonly severityClasses: Record<AnnouncementSeverity, string> = {
+    info: 'text-blue-800 bg-blue-50 dark:bg-gray-800 dark:text-blue-400',
+    warning: 'text-yellow-800 bg-yellow-50 dark:bg-gray-800 dark:text-yellow-300',
+    critical: 'text-red-800 bg-red-50 dark:bg-gray-800 dark:text-red-400',
+  };
+
+  protected readonly faCircleInfo = faCircleInfo;
+  protected readonly faTriangleExclamation = faTriangleExclamation;
+  protected readonly faXmark = faXmark;
+
+  protected async dismiss(announcement: UserAnnouncement) {
+    await firstValueFrom(this.announcements.dismiss(announcement));
+    this.announcements.reload();
+  }
+
+  private markRead(announcements: UserAnnouncement[]) {
+    for (const announcement of announcements) {
+      if (!announcement.readAt && !this.markedAsRead.has(announcement.id)) {
+        this.markedAsRead.add(announcement.id);
+        this.announcements.markRead(announcement).subscribe();
+      }
+    }
+  }
+}
@@ -28,7 +28,7 @@ export class ConnectInstructionsComponent {
       (response) => {
         this.modalConnectCommand =
           this.deploymentTarget.type === 'docker'
-            ? `curl "${response.connectUrl}" | docker compose -f - up -d`
+            ? `curl "${response.connectUrl}&platform=$(docker version -f '{{.Server.Os}}/{{.Server.Arch}}')" | docker compose -f - up -d`
             : `kubectl apply -n ${this.deploymentTarget.namespace} -f "${response.connectUrl}"`;
         this.modalTargetId = response.targetId;
         this.modalTargetSecret = response.targetSecret;
@@ -1,3 +1,4 @@
+import {AsyncPipe} from '@angular/common';
 import {CdkStep, CdkStepper} from '@angular/cdk/stepper';
 import {Component, EventEmitter, inject, OnDestroy, OnInit, Output, signal, ViewChild} from '@angular/core';
 import {toObservable} from '@angular/core/rxjs-interop';
@@ -26,6 +27,7 @@ import {DeploymentTargetsService} from '../../services/deployment-targets.servic
 import {FeatureFlagService} from '../../services/feature-flag.service';
 import {ToastService} from '../../services/toast.service';
 import {ConnectInstructionsComponent} from '../connect-instructions/connect-instructions.component';
+import {ResourceRequirementsComponent} from '../resource-requirements.component';
 import {InstallationWizardStepperComponent} from './installation-wizard-stepper.component';
 import {KUBERNETES_RESOURCE_MAX_LENGTH, KUBERNETES_RESOURCE_NAME_REGEX} from '../../../util/validation';
 
@@ -40,6 +42,8 @@ import {KUBERNETES_RESOURCE_MAX_LENGTH, KUBERNETES_RESOURCE_NAME_REGEX} from '..
     ConnectInstructionsComponent,
     AutotrimDirective,
     DeploymentFormComponent,
+    ResourceRequirementsComponent,
+    AsyncPipe,
   ],
   animations: [modalFlyInOut],
 })
@@ -179,8 +183,9 @@ export class InstallationWizardComponent implements OnInit, OnDestroy {
     try {
       this.loading = true;
       const deployment = mapToDeploymentRequest(this.deployForm.value!);
-      await firstValueFrom(this.deploymentTargets.deploy(deployment));
+      const warnings = await firstValueFrom(this.deploymentTargets.deploy(deployment));
       this.toast.success('Deployment saved successfully');
+      warnings.forEach((warning) => this.toast.warning(warning));
       this.close();
     } catch (e) {
       const msg = getFormDisplayedError(e);
@@ -0,0 +1,36 @@
+import {AsyncPipe, DatePipe} from '@angular/common';
+import {Component, inject} from '@angular/core';
+import {FaIconComponent} from '@fortawesome/angular-fontawesome';
+import {faTriangleExclamation} from '@fortawesome/free-solid-svg-icons';
+import {MaintenanceService} from '../services/maintenance.service';
+
+@Component({
+  selector: 'app-maintenance-banner',
+  template: `
+    @if (maintenance.status$ | async; as status) {
+      @if (status.active) {
+        <div
+          class="flex items-center justify-center gap-2 px-4 py-2 text-sm text-yellow-800 bg-yellow-50 dark:bg-gray-800 dark:text-yellow-300"
+          role="alert">
+          <fa-icon [icon]="faTriangleExclamation"></fa-icon>
+          <span class="font-medium">
+            @if (status.scope === 'organization') {
+              Your organization is in read-only maintenance mode.
+            } @else {
+              Distr is in read-only maintenance mode.
+            }
+          </span>
+          <span>{{ status.reason }}</span>
+          @if (status.since) {
+            <span class="text-yellow-700 dark:text-yellow-400">(since {{ status.since | date: 'short' }})</span>
+          }
+        </div>
+      }
+    }
+  `,
+  imports: [AsyncPipe, DatePipe, FaIconComponent],
+})
+export class MaintenanceBannerComponent {
+  protected readonly maintenance = inject(MaintenanceService);
+  protected readonly faTriangleExclamation = faTriangleExclamation;
+}
@@ -34,6 +34,8 @@ import {AutotrimDirective} from '../../directives/autotrim.directive';
 import {FormControl, FormGroup, ReactiveFormsModule, Validators} from '@angular/forms';
 import {DialogRef, OverlayService} from '../../services/overlay.service';
 import {modalFlyInOut} from '../../animations/modal';
+import {MaintenanceBannerComponent} from '../maintenance-banner.component';
+import {AnnouncementBannerComponent} from '../announcement-banner.component';
 
 type SwitchOptions = {
   currentOrg: Organization;
@@ -55,6 +57,8 @@ type SwitchOptions = {
     TitleCasePipe,
     AutotrimDirective,
     ReactiveFormsModule,
+    MaintenanceBannerComponent,
+    AnnouncementBannerComponent,
   ],
   animations: [dropdownAnimation, modalFlyInOut],
 })
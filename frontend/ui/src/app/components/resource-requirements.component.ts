@@ -0,0 +1,38 @@
+import {Component, input} from '@angular/core';
+import {ResourceRequirements} from '@glasskube/distr-sdk';
+import {BytesPipe} from '../../util/units';
+
+@Component({
+  selector: 'app-resource-requirements',
+  template: `
+    @if (requirements(); as req) {
+      <div class="text-sm text-gray-500 dark:text-gray-400">
+        <h4 class="font-medium text-gray-900 dark:text-white">
+          {{ title() }}
+        </h4>
+        <ul class="list-disc list-inside">
+          @if (req.minCpuCoresMillis) {
+            <li>At least {{ req.minCpuCoresMillis / 1000 }} CPU cores</li>
+          }
+          @if (req.minMemoryBytes) {
+            <li>At least {{ req.minMemoryBytes | bytes }} of memory</li>
+          }
+          @if (req.minDiskBytes) {
+            <li>At least {{ req.minDiskBytes | bytes }} of disk space</li>
+          }
+          @if (req.architectures?.length) {
+            <li>Architecture: {{ req.architectures!.join(', ') }}</li>
+          }
+        </ul>
+        @if (req.enforcement === 'block') {
+          <p class="mt-1">Deployments to targets that do not meet these requirements are rejected.</p>
+        }
+      </div>
+    }
+  `,
+  imports: [BytesPipe],
+})
+export class ResourceRequirementsComponent {
+  public readonly requirements = input<ResourceRequirements | undefined>();
+  public readonly title = input('System requirements');
+}
@@ -36,6 +36,9 @@ export class DeploymentStatusDot {
 
   private readonly bgClass = computed(() => {
     const d = this.deployment();
+    if (d.paused) {
+      return 'bg-slate-400';
+    }
     if (d.latestStatus !== undefined) {
       if (d.latestStatus.type === 'error') {
         return 'bg-red-400';
@@ -1,7 +1,7 @@
 import {animate, keyframes, state, style, transition, trigger} from '@angular/animations';
 import {Component} from '@angular/core';
 import {Toast} from 'ngx-toastr';
-import {faCheck, faCircleExclamation} from '@fortawesome/free-solid-svg-icons';
+import {faCheck, faCircleExclamation, faTriangleExclamation} from '@fortawesome/free-solid-svg-icons';
 import {FaIconComponent} from '@fortawesome/angular-fontawesome';
 
 @Component({
@@ -21,6 +21,8 @@ import {FaIconComponent} from '@fortawesome/angular-fontawesome';
       [class.dark:border-red-800]="options.payload === 'error'"
       [class.border-green-300]="options.payload === 'success'"
       [class.dark:border-green-800]="options.payload === 'success'"
+      [class.border-yellow-300]="options.payload === 'warning'"
+      [class.dark:border-yellow-800]="options.payload === 'warning'"
       class="flex items-center w-full max-w-xs p-4 mb-4 text-gray-500 bg-white rounded-lg shadow-sm dark:text-gray-400 dark:bg-gray-800 border border-gray-200 dark:border-gray-600"
       role="alert">
       @switch (options.payload) {
@@ -31,6 +33,13 @@ import {FaIconComponent} from '@fortawesome/angular-fontawesome';
             class="inline-flex items-center justify-center flex-shrink-0 w-8 h-8 rounded-lg text-red-500 dark:bg-red-800 bg-red-100 dark:text-red-200">
           </fa-icon>
         }
+        @case ('warning') {
+          <fa-icon
+            [icon]="faTriangleExclamation"
+            size="lg"
+            class="inline-flex items-center justify-center flex-shrink-0 w-8 h-8 rounded-lg text-yellow-500 dark:bg-yellow-800 bg-yellow-100 dark:text-yellow-200">
+          </fa-icon>
+        }
         @case ('success') {
           <fa-icon
             [icon]="faCheck"
@@ -118,4 +127,5 @@ import {FaIconComponent} from '@fortawesome/angular-fontawesome';
 export class ToastComponent extends Toast {
   protected readonly faCheck = faCheck;
   protected readonly faCircleExclamation = faCircleExclamation;
+  protected readonly faTriangleExclamation = faTriangleExclamation;
 }
@@ -29,6 +29,7 @@ import {
 import {isArchived} from '../../util/dates';
 import {HELM_RELEASE_NAME_MAX_LENGTH, HELM_RELEASE_NAME_REGEX} from '../../util/validation';
 import {EditorComponent} from '../components/editor.component';
+import {ResourceRequirementsComponent} from '../components/resource-requirements.component';
 import {AutotrimDirective} from '../directives/autotrim.directive';
 import {ApplicationsService} from '../services/applications.service';
 import {DeploymentTargetsService} from '../services/deployment-targets.service';
@@ -45,6 +46,7 @@ export type DeploymentFormValue = Partial<{
   releaseName: string;
   envFileData: string;
   swarmMode: boolean;
+  reason: string;
 }>;
 
 export function mapToDeploymentRequest(value: DeploymentFormValue): DeploymentRequest {
@@ -57,6 +59,7 @@ export function mapToDeploymentRequest(value: DeploymentFormValue): DeploymentRe
     valuesYaml: value.valuesYaml ? btoa(value.valuesYaml) : undefined,
     dockerType: value.swarmMode ? 'swarm' : 'compose',
     envFileData: value.envFileData ? btoa(value.envFileData) : undefined,
+    reason: value.reason?.trim() || undefined,
   };
 }
 
@@ -64,7 +67,7 @@ type DeploymentFormValueCallback = (v: DeploymentFormValue | undefined) => void;
 
 @Component({
   selector: 'app-deployment-form',
-  imports: [ReactiveFormsModule, AsyncPipe, EditorComponent, AutotrimDirective],
+  imports: [ReactiveFormsModule, AsyncPipe, EditorComponent, AutotrimDirective, ResourceRequirementsComponent],
   providers: [
     {
       provide: NG_VALUE_ACCESSOR,
@@ -96,6 +99,7 @@ export class DeploymentFormComponent implements OnInit, AfterViewInit, OnDestroy
     valuesYaml: this.fb.nonNullable.control(''),
     envFileData: this.fb.nonNullable.control(''),
     swarmMode: this.fb.nonNullable.control<boolean>(false),
+    reason: this.fb.nonNullable.control('', Validators.maxLength(1000)),
   });
   protected readonly composeFile = this.fb.nonNullable.control({disabled: true, value: ''});
 
@@ -218,6 +222,25 @@ export class DeploymentFormComponent implements OnInit, AfterViewInit, OnDestroy
     )
   );
 
+  /**
+   * The requirements of the selected version, falling back to those of the application.
+   */
+  protected readonly resourceRequirements$ = this.selectedApplication$.pipe(
+    combineLatestWith(this.applicationVersionId$),
+    map(
+      ([application, versionId]) =>
+        application?.versions?.find((av) => av.id === versionId)?.resourceRequirements ??
+        application?.resourceRequirements
+    )
+  );
+
+  /**
+   * The applications that must already be deployed to the deployment target before the selected application.
+   */
+  protected readonly dependencies$ = this.selectedApplication$.pipe(
+    map((application) => application?.dependencies ?? [])
+  );
+
   private readonly destroyed$ = new Subject<void>();
 
   private onChange?: DeploymentFormValueCallback;
@@ -117,8 +117,9 @@ export class DeploymentModalComponent {
       this.loading.set(true);
       const deployment = mapToDeploymentRequest(this.deployForm.value!);
       try {
-        await firstValueFrom(this.deploymentTargets.deploy(deployment));
+        const warnings = await firstValueFrom(this.deploymentTargets.deploy(deployment));
         this.toast.success('Deployment saved successfully');
+        warnings.forEach((warning) => this.toast.warning(warning));
         this.closed.emit();
       } catch (e) {
         const msg = getFormDisplayedError(e);
@@ -137,6 +137,16 @@ export class DeploymentTargetCardComponent {
       this.deploymentTarget().agentVersion?.id !== this.deploymentTarget().reportedAgentVersionId
   );
 
+  protected readonly clockSkewWarning = computed(() => {
+    const skew = this.deploymentTarget().clockSkewMs;
+    // must match clockskew.Threshold of the hub
+    if (skew === undefined || Math.abs(skew) <= 60_000) {
+      return undefined;
+    }
+    const minutes = Math.round(Math.abs(skew) / 60_000);
+    return `Agent clock is ${minutes} minute${minutes === 1 ? '' : 's'} ${skew > 0 ? 'ahead' : 'behind'}`;
+  });
+
   protected readonly editForm = new FormGroup({
     id: new FormControl<string | undefined>(undefined),
     name: new FormControl('', Validators.required),
@@ -144,6 +154,7 @@ export class DeploymentTargetCardComponent {
     namespace: new FormControl<string | undefined>({value: undefined, disabled: true}),
     scope: new FormControl<DeploymentTargetScope>({value: 'namespace', disabled: true}),
     metricsEnabled: new FormControl<boolean>(true),
+    production: new FormControl<boolean>(false),
   });
   protected editFormLoading = false;
 
@@ -167,6 +178,7 @@ export class DeploymentTargetCardComponent {
         type: val.type!,
         deployments: [],
         metricsEnabled: val.metricsEnabled ?? false,
+        production: val.production ?? false,
       };
 
       try {
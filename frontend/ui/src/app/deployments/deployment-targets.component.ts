@@ -17,8 +17,6 @@ import {
   switchMap,
   takeUntil,
 } from 'rxjs';
-import {SemVer} from 'semver';
-import {maxBy} from '../../util/arrays';
 import {isArchived} from '../../util/dates';
 import {filteredByFormControl} from '../../util/filter';
 import {drawerFlyInOut} from '../animations/drawer';
@@ -195,15 +193,7 @@ export class DeploymentTargetsComponent implements AfterViewInit, OnDestroy {
   }
 
   private findMaxVersion(versions: ApplicationVersion[]): ApplicationVersion | undefined {
-    try {
-      return maxBy(
-        versions,
-        (version) => new SemVer(version.name),
-        (a, b) => a.compare(b) > 0
-      );
-    } catch (e) {
-      console.warn('semver compare failed, falling back to creation date', e);
-      return maxBy(versions, (version) => new Date(version.createdAt!));
-    }
+    // the server orders versions by their semantic version, from the lowest to the highest
+    return versions[versions.length - 1];
   }
 }
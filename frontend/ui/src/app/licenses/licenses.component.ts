@@ -19,6 +19,7 @@ import {modalFlyInOut} from '../animations/modal';
 import {ApplicationsService} from '../services/applications.service';
 import {EditLicenseComponent} from './edit-license.component';
 import {isExpired} from '../../util/dates';
+import {describeLicenseImpact} from '../types/license-impact';
 
 @Component({
   selector: 'app-licenses',
@@ -108,11 +109,20 @@ export class LicensesComponent implements OnDestroy {
   }
 
   deleteLicense(license: ApplicationLicense) {
-    this.overlay
-      .confirm(`Really delete ${license.name}?`)
+    this.licensesService
+      .impact(license)
       .pipe(
+        switchMap((impact) => {
+          const description = describeLicenseImpact(impact);
+          return this.overlay.confirm({
+            message: {
+              message: `Really delete ${license.name}?`,
+              warning: description ? {message: description} : undefined,
+            },
+          });
+        }),
         filter((result) => result === true),
-        switchMap(() => this.licensesService.delete(license)),
+        switchMap(() => this.licensesService.delete(license, true)),
         catchError((e) => {
           const msg = getFormDisplayedError(e);
           if (msg) {
@@ -1,13 +1,19 @@
 import {Component, inject, OnInit, signal} from '@angular/core';
 import {FaIconComponent} from '@fortawesome/angular-fontawesome';
 import {faFloppyDisk, faLightbulb} from '@fortawesome/free-solid-svg-icons';
-import {FormControl, FormGroup, ReactiveFormsModule, Validators} from '@angular/forms';
+import {FormArray, FormControl, FormGroup, ReactiveFormsModule, Validators} from '@angular/forms';
 import {firstValueFrom, lastValueFrom} from 'rxjs';
 import {getFormDisplayedError} from '../../util/errors';
 import {ToastService} from '../services/toast.service';
 import {AutotrimDirective} from '../directives/autotrim.directive';
 import {OrganizationService} from '../services/organization.service';
-import {Organization} from '../types/organization';
+import {
+  BusinessHours,
+  DeploymentReasonPolicy,
+  DeploymentUninstallPolicy,
+  Organization,
+  SecretScanPolicy,
+} from '../types/organization';
 import {slugMaxLength, slugPattern} from '../../util/slug';
 
 @Component({
@@ -18,6 +24,8 @@ import {slugMaxLength, slugPattern} from '../../util/slug';
 export class OrganizationSettingsComponent implements OnInit {
   protected readonly faFloppyDisk = faFloppyDisk;
   protected readonly faLightbulb = faLightbulb;
+  protected readonly timezones = ['UTC', ...Intl.supportedValuesOf('timeZone').filter((tz) => tz !== 'UTC')];
+  protected readonly weekdays = ['Sunday', 'Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday'];
 
   private readonly organizationService = inject(OrganizationService);
   private organization?: Organization;
@@ -26,9 +34,19 @@ export class OrganizationSettingsComponent implements OnInit {
   protected readonly form = new FormGroup({
     name: new FormControl('', [Validators.required]),
     slug: new FormControl('', [Validators.pattern(slugPattern), Validators.maxLength(slugMaxLength)]),
+    registryIndexChildCheckDisabled: new FormControl(false, {nonNullable: true}),
     appDomain: new FormControl<string | undefined>({value: undefined, disabled: true}),
     registryDomain: new FormControl<string | undefined>({value: undefined, disabled: true}),
     emailFromAddress: new FormControl<string | undefined>({value: undefined, disabled: true}),
+    deploymentReasonPolicy: new FormControl<DeploymentReasonPolicy>('optional', {nonNullable: true}),
+    deploymentUninstallPolicy: new FormControl<DeploymentUninstallPolicy>('vendor_and_customer', {nonNullable: true}),
+    deploymentAutoRollbackWindowMinutes: new FormControl<number | null>(null, [Validators.min(0)]),
+    secretScanPolicy: new FormControl<SecretScanPolicy>('block', {nonNullable: true}),
+    timezone: new FormControl('UTC', {nonNullable: true}),
+    businessHoursEnabled: new FormControl(false, {nonNullable: true}),
+    businessHoursStart: new FormControl('09:00', {nonNullable: true}),
+    businessHoursEnd: new FormControl('17:00', {nonNullable: true}),
+    businessHoursWeekdays: new FormArray(this.weekdays.map((_, day) => new FormControl(day >= 1 && day <= 5))),
   });
   formLoading = signal(false);
 
@@ -39,6 +57,19 @@ export class OrganizationSettingsComponent implements OnInit {
         this.form.controls.slug.addValidators([Validators.required]);
       }
       this.form.patchValue(this.organization);
+      const autoRollbackWindow = this.organization.deploymentAutoRollbackWindowSeconds;
+      this.form.patchValue({
+        deploymentAutoRollbackWindowMinutes: typeof autoRollbackWindow === 'number' ? autoRollbackWindow / 60 : null,
+      });
+      const businessHours = this.organization.businessHours;
+      if (businessHours) {
+        this.form.patchValue({
+          businessHoursEnabled: true,
+          businessHoursStart: businessHours.start,
+          businessHoursEnd: businessHours.end,
+          businessHoursWeekdays: this.weekdays.map((_, day) => businessHours.weekdays.includes(day)),
+        });
+      }
     } catch (e) {
       const msg = getFormDisplayedError(e);
       if (msg) {
@@ -57,6 +88,13 @@ export class OrganizationSettingsComponent implements OnInit {
             ...this.organization!,
             name: this.form.value.name?.trim(),
             slug: this.form.value.slug?.trim(),
+            registryIndexChildCheckDisabled: this.form.value.registryIndexChildCheckDisabled,
+            deploymentReasonPolicy: this.form.value.deploymentReasonPolicy,
+            deploymentUninstallPolicy: this.form.value.deploymentUninstallPolicy,
+            deploymentAutoRollbackWindowSeconds: this.getAutoRollbackWindowSeconds(),
+            secretScanPolicy: this.form.value.secretScanPolicy,
+            timezone: this.form.value.timezone,
+            businessHours: this.getBusinessHours(),
           })
         );
         this.toast.success('Settings saved successfully');
@@ -70,4 +108,21 @@ export class OrganizationSettingsComponent implements OnInit {
       }
     }
   }
+
+  private getBusinessHours(): BusinessHours | null {
+    const value = this.form.getRawValue();
+    if (!value.businessHoursEnabled) {
+      return null;
+    }
+    return {
+      weekdays: value.businessHoursWeekdays.flatMap((checked, day) => (checked ? [day] : [])),
+      start: value.businessHoursStart,
+      end: value.businessHoursEnd,
+    };
+  }
+
+  private getAutoRollbackWindowSeconds(): number | null {
+    const minutes = this.form.value.deploymentAutoRollbackWindowMinutes;
+    return typeof minutes === 'number' ? Math.round(minutes * 60) : null;
+  }
 }
@@ -0,0 +1,47 @@
+import {HttpClient} from '@angular/common/http';
+import {inject, Injectable} from '@angular/core';
+import {merge, Observable, shareReplay, startWith, Subject, switchMap, timer} from 'rxjs';
+
+export type AnnouncementSeverity = 'info' | 'warning' | 'critical';
+
+export interface UserAnnouncement {
+  id: string;
+  createdAt: string;
+  title: string;
+  body: string;
+  severity: AnnouncementSeverity;
+  audience: 'all_customers' | 'customers' | 'deployment_targets';
+  startsAt: string;
+  endsAt: string;
+  readAt?: string;
+  dismissedAt?: string;
+}
+
+@Injectable({providedIn: 'root'})
+export class AnnouncementsService {
+  private readonly httpClient = inject(HttpClient);
+  private readonly baseUrl = '/api/v1/announcements';
+  private readonly refresh$ = new Subject<void>();
+
+  public readonly active$: Observable<UserAnnouncement[]> = merge(timer(0, 60_000), this.refresh$).pipe(
+    switchMap(() => this.getActive()),
+    startWith([]),
+    shareReplay({bufferSize: 1, refCount: true})
+  );
+
+  public getActive(): Observable<UserAnnouncement[]> {
+    return this.httpClient.get<UserAnnouncement[]>(`${this.baseUrl}/active`);
+  }
+
+  public markRead(announcement: UserAnnouncement): Observable<void> {
+    return this.httpClient.post<void>(`${this.baseUrl}/${announcement.id}/read`, null);
+  }
+
+  public dismiss(announcement: UserAnnouncement): Observable<void> {
+    return this.httpClient.post<void>(`${this.baseUrl}/${announcement.id}/dismiss`, null);
+  }
+
+  public reload() {
+    this.refresh$.next();
+  }
+}
@@ -3,7 +3,18 @@ import {Injectable} from '@angular/core';
 import {catchError, Observable, of, startWith, Subject, switchMap, tap, throwError} from 'rxjs';
 import {DefaultReactiveList, ReactiveList} from './cache';
 import {CrudService} from './interfaces';
-import {Application, ApplicationVersion, DeploymentTarget, PatchApplicationRequest} from '@glasskube/distr-sdk';
+import {
+  Application,
+  ApplicationDeletionCascade,
+  ApplicationDeletionImpact,
+  ApplicationDependency,
+  ApplicationDependencyRequest,
+  ApplicationVersion,
+  ApplicationVersionBump,
+  DeploymentTarget,
+  NextApplicationVersion,
+  PatchApplicationRequest,
+} from '@glasskube/distr-sdk';
 import {ArtifactWithTags} from './artifacts.service';
 
 @Injectable({
@@ -48,12 +59,31 @@ export class ApplicationsService implements CrudService<Application> {
       .pipe(tap((it) => this.cache.save(it)));
   }
 
-  delete(application: Application): Observable<void> {
+  /**
+   * Deletes the application, which can be restored until it is purged. Active deployments of the application must be
+   * archived or uninstalled with cascade.
+   */
+  delete(application: Application, cascade?: ApplicationDeletionCascade): Observable<void> {
+    const params: Record<string, string> = cascade ? {cascade} : {};
     return this.httpClient
-      .delete<void>(`${this.applicationsUrl}/${application.id}`)
+      .delete<void>(`${this.applicationsUrl}/${application.id}`, {params})
       .pipe(tap(() => this.cache.remove(application)));
   }
 
+  getDeletionImpact(application: Application): Observable<ApplicationDeletionImpact> {
+    return this.httpClient.get<ApplicationDeletionImpact>(`${this.applicationsUrl}/${application.id}/deletion-impact`);
+  }
+
+  listDeleted(): Observable<Application[]> {
+    return this.httpClient.get<Application[]>(this.applicationsUrl, {params: {deleted: true}});
+  }
+
+  restore(application: Application): Observable<Application> {
+    return this.httpClient
+      .post<Application>(`${this.applicationsUrl}/${application.id}/restore`, null)
+      .pipe(tap((it) => this.cache.save(it)));
+  }
+
   getTemplateFile(applicationId: string, versionId: string): Observable<string | null> {
     return this.getFile(applicationId, versionId, 'template-file');
   }
@@ -84,7 +114,8 @@ export class ApplicationsService implements CrudService<Application> {
     application: Application,
     applicationVersion: ApplicationVersion,
     compose: string,
-    template?: string | null
+    template?: string | null,
+    force = false
   ): Observable<ApplicationVersion> {
     const formData = new FormData();
     formData.append('composefile', new Blob([compose], {type: 'application/yaml'}));
@@ -92,14 +123,15 @@ export class ApplicationsService implements CrudService<Application> {
       formData.append('templatefile', new Blob([template], {type: 'application/yaml'}));
     }
 
-    return this.doCreateVersion(application, applicationVersion, formData);
+    return this.doCreateVersion(application, applicationVersion, formData, force);
   }
 
   createApplicationVersionForKubernetes(
     application: Application,
     applicationVersion: ApplicationVersion,
     baseValues?: string | null,
-    template?: string | null
+    template?: string | null,
+    force = false
   ): Observable<ApplicationVersion> {
     const formData = new FormData();
     if (baseValues) {
@@ -108,13 +140,20 @@ export class ApplicationsService implements CrudService<Application> {
     if (template) {
       formData.append('templatefile', new Blob([template], {type: 'application/yaml'}));
     }
-    return this.doCreateVersion(application, applicationVersion, formData);
+    return this.doCreateVersion(application, applicationVersion, formData, force);
   }
 
-  private doCreateVersion(application: Application, applicationVersion: ApplicationVersion, formData: FormData) {
+  private doCreateVersion(
+    application: Application,
+    applicationVersion: ApplicationVersion,
+    formData: FormData,
+    force: boolean
+  ) {
     formData.append('applicationversion', JSON.stringify(applicationVersion));
     return this.httpClient
-      .post<ApplicationVersion>(`${this.applicationsUrl}/${application.id}/versions`, formData)
+      .post<ApplicationVersion>(`${this.applicationsUrl}/${application.id}/versions`, formData, {
+        params: force ? {force} : {},
+      })
       .pipe(
         tap((it) => {
           application.versions = [...(application.versions || []), it];
@@ -123,6 +162,12 @@ export class ApplicationsService implements CrudService<Application> {
       );
   }
 
+  getNextVersion(application: Application, bump: ApplicationVersionBump = 'patch'): Observable<NextApplicationVersion> {
+    return this.httpClient.get<NextApplicationVersion>(`${this.applicationsUrl}/${application.id}/versions/next`, {
+      params: {bump},
+    });
+  }
+
   createSample(): Observable<Application> {
     return this.httpClient
       .post<Application>(`${this.applicationsUrl}/sample`, null)
@@ -151,4 +196,10 @@ export class ApplicationsService implements CrudService<Application> {
       .patch<Application>(`${this.applicationsUrl}/${artifactsId}/image`, {imageId})
       .pipe(tap((it) => this.cache.save(it)));
   }
+
+  putDependencies(app: Application, dependencies: ApplicationDependencyRequest[]): Observable<ApplicationDependency[]> {
+    return this.httpClient
+      .put<ApplicationDependency[]>(`${this.applicationsUrl}/${app.id}/dependencies`, {dependencies})
+      .pipe(tap((it) => this.cache.save({...app, dependencies: it})));
+  }
 }
@@ -6,6 +6,7 @@ import {CrudService} from './interfaces';
 import {DefaultReactiveList, ReactiveList} from './cache';
 import {UsersService} from './users.service';
 import {HttpClient} from '@angular/common/http';
+import {LicenseImpact} from '../types/license-impact';
 
 export interface ArtifactLicenseSelection {
   artifactId: string;
@@ -35,9 +36,14 @@ export class ArtifactLicensesService implements CrudService<ArtifactLicense> {
     return this.http.post<ArtifactLicense>(this.artifactLicensesUrl, request).pipe(tap((l) => this.cache.save(l)));
   }
 
-  delete(request: ArtifactLicense): Observable<void> {
+  impact(request: ArtifactLicense): Observable<LicenseImpact> {
+    return this.http.get<LicenseImpact>(`${this.artifactLicensesUrl}/${request.id}/impact`);
+  }
+
+  delete(request: ArtifactLicense, confirm = false): Observable<void> {
+    const params = confirm ? {confirm} : undefined;
     return this.http
-      .delete<void>(`${this.artifactLicensesUrl}/${request.id}`)
+      .delete<void>(`${this.artifactLicensesUrl}/${request.id}`, {params})
       .pipe(tap(() => this.cache.remove(request)));
   }
 
@@ -1,21 +1,31 @@
 import {HttpClient, HttpParams} from '@angular/common/http';
 import {inject, Injectable} from '@angular/core';
-import {Observable} from 'rxjs';
+import {map, Observable} from 'rxjs';
 import {ArtifactVersionPull} from '../types/artifact-version-pull';
 
+export interface ArtifactVersionPullPage {
+  pulls: ArtifactVersionPull[];
+  nextCursor?: string;
+}
+
 @Injectable({providedIn: 'root'})
 export class ArtifactPullsService {
   private readonly baseUrl = '/api/v1/artifact-pulls';
   private readonly httpClient = inject(HttpClient);
 
-  public get({before, count}: {before?: Date; count?: number} = {}): Observable<ArtifactVersionPull[]> {
+  public get({cursor, limit}: {cursor?: string; limit?: number} = {}): Observable<ArtifactVersionPullPage> {
     let params = new HttpParams();
-    if (before !== undefined) {
-      params = params.set('before', before.toISOString());
+    if (cursor !== undefined) {
+      params = params.set('cursor', cursor);
     }
-    if (count !== undefined) {
-      params = params.set('count', count);
+    if (limit !== undefined) {
+      params = params.set('limit', limit);
     }
-    return this.httpClient.get<ArtifactVersionPull[]>(this.baseUrl, {params});
+    return this.httpClient.get<ArtifactVersionPull[]>(this.baseUrl, {params, observe: 'response'}).pipe(
+      map((response) => ({
+        pulls: response.body ?? [],
+        nextCursor: response.headers.get('X-Next-Cursor') ?? undefined,
+      }))
+    );
   }
 }
@@ -2,6 +2,7 @@ import {Injectable} from '@angular/core';
 import {map, Observable, of, switchMap, tap} from 'rxjs';
 import {HttpClient} from '@angular/common/http';
 import {DefaultReactiveList, ReactiveList} from './cache';
+import {AccessToken} from '@glasskube/distr-sdk';
 
 export interface HasDownloads {
   downloadsTotal?: number;
@@ -40,7 +41,53 @@ export interface BaseArtifactVersion {
   name: string;
 }
 
-export interface Artifact extends BaseArtifact, HasDownloads {}
+export interface Artifact extends BaseArtifact, HasDownloads {
+  deletionRequestedAt?: string;
+  deletionScheduledAt?: string;
+  recommendedVersionId?: string;
+  recommendedReference?: string;
+  public?: boolean;
+  immutableTags?: boolean;
+  mutableTagPatterns?: string[];
+  upstreamRepository?: string;
+}
+
+export interface ArtifactUpstream {
+  artifactId: string;
+  createdAt: string;
+  updatedAt: string;
+  repository: string;
+  username?: string;
+}
+
+export interface ArtifactUpstreamRequest {
+  repository: string;
+  username?: string;
+  password?: string;
+}
+
+export interface ArtifactTagRetentionRuleRequest {
+  tagPattern: string;
+  keepLast?: number;
+  unpulledDays?: number;
+  dryRun: boolean;
+}
+
+export interface ArtifactTagRetentionRule extends ArtifactTagRetentionRuleRequest {
+  id: string;
+  createdAt: string;
+  artifactId: string;
+  lastEvaluatedAt?: string;
+  lastReport?: ArtifactTagRetentionReport;
+}
+
+export interface ArtifactTagRetentionReport {
+  evaluatedAt: string;
+  dryRun: boolean;
+  removed: string[];
+  protected: {tag: string; reason: 'license' | 'recommended' | 'deployment'}[];
+  failed?: string[];
+}
 
 export interface TaggedArtifactVersion extends HasDownloads {
   id: string;
@@ -58,6 +105,23 @@ export interface ArtifactWithTags extends Artifact {
   versions?: TaggedArtifactVersion[];
 }
 
+export interface PullCommand {
+  tool: 'docker' | 'helm' | 'oras';
+  command: string;
+  digestCommand: string;
+}
+
+export interface ArtifactPullInstructions {
+  registryHost: string;
+  repository: string;
+  reference: string;
+  digest: string;
+  commands: PullCommand[];
+  recommended: boolean;
+  accessTokens?: AccessToken[];
+  accessTokenNote?: string;
+}
+
 @Injectable({providedIn: 'root'})
 export class ArtifactsService {
   private readonly cache: ReactiveList<ArtifactWithTags>;
@@ -91,4 +155,105 @@ export class ArtifactsService {
       .patch<ArtifactWithTags>(`${this.artifactsUrl}/${artifactsId}/image`, {imageId})
       .pipe(tap((it) => this.cache.save(it)));
   }
+
+  public requestDeletion(artifactId: string, opts: {force?: boolean; confirm?: boolean} = {}) {
+    const params: Record<string, boolean> = {};
+    if (opts.force) {
+      params['force'] = true;
+    }
+    if (opts.confirm) {
+      params['confirm'] = true;
+    }
+    return this.http.delete<ArtifactWithTags | null>(`${this.artifactsUrl}/${artifactId}`, {params}).pipe(
+      tap((it) => {
+        if (it) {
+          this.cache.save(it);
+        } else {
+          this.cache.remove({id: artifactId} as ArtifactWithTags);
+        }
+      })
+    );
+  }
+
+  public cancelDeletion(artifactId: string) {
+    return this.http
+      .post<ArtifactWithTags>(`${this.artifactsUrl}/${artifactId}/cancel-deletion`, {})
+      .pipe(tap((it) => this.cache.save(it)));
+  }
+
+  public setRecommendedVersion(artifactId: string, reference: string) {
+    return this.http
+      .put<ArtifactWithTags>(`${this.artifactsUrl}/${artifactId}/recommended-version`, {reference})
+      .pipe(tap((it) => this.cache.save(it)));
+  }
+
+  public clearRecommendedVersion(artifactId: string) {
+    return this.http
+      .delete<ArtifactWithTags>(`${this.artifactsUrl}/${artifactId}/recommended-version`)
+      .pipe(tap((it) => this.cache.save(it)));
+  }
+
+  public setTagImmutability(artifactId: string, immutableTags: boolean, mutableTagPatterns: string[]) {
+    return this.http
+      .put<ArtifactWithTags>(`${this.artifactsUrl}/${artifactId}/tag-immutability`, {immutableTags, mutableTagPatterns})
+      .pipe(tap((it) => this.cache.save(it)));
+  }
+
+  public createProxy(name: string, upstream: ArtifactUpstreamRequest): Observable<Artifact> {
+    return this.http.post<Artifact>(`${this.artifactsUrl}/proxies`, {name, ...upstream});
+  }
+
+  public getUpstream(artifactId: string): Observable<ArtifactUpstream> {
+    return this.http.get<ArtifactUpstream>(`${this.artifactsUrl}/${artifactId}/upstream`);
+  }
+
+  public setUpstream(artifactId: string, upstream: ArtifactUpstreamRequest): Observable<ArtifactUpstream> {
+    return this.http.put<ArtifactUpstream>(`${this.artifactsUrl}/${artifactId}/upstream`, upstream);
+  }
+
+  public deleteUpstream(artifactId: string): Observable<void> {
+    return this.http.delete<void>(`${this.artifactsUrl}/${artifactId}/upstream`);
+  }
+
+  public getTagRetentionRules(artifactId: string): Observable<ArtifactTagRetentionRule[]> {
+    return this.http.get<ArtifactTagRetentionRule[]>(`${this.artifactsUrl}/${artifactId}/retention-rules`);
+  }
+
+  public createTagRetentionRule(
+    artifactId: string,
+    rule: ArtifactTagRetentionRuleRequest
+  ): Observable<ArtifactTagRetentionRule> {
+    return this.http.post<ArtifactTagRetentionRule>(`${this.artifactsUrl}/${artifactId}/retention-rules`, rule);
+  }
+
+  public updateTagRetentionRule(
+    artifactId: string,
+    ruleId: string,
+    rule: ArtifactTagRetentionRuleRequest
+  ): Observable<ArtifactTagRetentionRule> {
+    return this.http.put<ArtifactTagRetentionRule>(
+      `${this.artifactsUrl}/${artifactId}/retention-rules/${ruleId}`,
+      rule
+    );
+  }
+
+  public deleteTagRetentionRule(artifactId: string, ruleId: string): Observable<void> {
+    return this.http.delete<void>(`${this.artifactsUrl}/${artifactId}/retention-rules/${ruleId}`);
+  }
+
+  public dryRunTagRetentionRule(
+    artifactId: string,
+    rule: ArtifactTagRetentionRuleRequest
+  ): Observable<ArtifactTagRetentionReport> {
+    return this.http.post<ArtifactTagRetentionReport>(
+      `${this.artifactsUrl}/${artifactId}/retention-rules/dry-run`,
+      rule
+    );
+  }
+
+  public getPullInstructions(artifactId: string, reference: string): Observable<ArtifactPullInstructions> {
+    return this.http.get<ArtifactPullInstructions>(
+      `${this.artifactsUrl}/${artifactId}/versions/${encodeURIComponent(reference)}/pull-instructions`
+    );
+  }
 }
@@ -14,6 +14,63 @@ export interface ArtifactsByCustomer {
   artifacts?: DashboardArtifact[];
 }
 
+export type AggregateName =
+  | 'storage_usage'
+  | 'target_health'
+  | 'target_uptime'
+  | 'license_utilization'
+  | 'version_distribution';
+
+export interface OrganizationAggregate<T = unknown> {
+  name: AggregateName;
+  data: T | null;
+  computedAt: string | null;
+  refreshRequestedAt?: string;
+}
+
+export interface StorageUsage {
+  artifactCount: number;
+  versionCount: number;
+  blobCount: number;
+  totalBytes: number;
+}
+
+export interface TargetHealth {
+  total: number;
+  online: number;
+  stale: number;
+  neverConnected: number;
+  paused: number;
+}
+
+export interface TargetUptime {
+  deploymentTargetId: string;
+  hoursOnline: number;
+  hoursTotal: number;
+  uptime: number;
+}
+
+export interface LicenseCounts {
+  total: number;
+  assigned: number;
+  expired: number;
+  inUse?: number;
+}
+
+export interface LicenseUtilization {
+  applicationLicenses: LicenseCounts;
+  artifactLicenses: LicenseCounts;
+}
+
+export interface VersionDistribution {
+  applicationId: string;
+  applicationName: string;
+  applicationVersionId: string;
+  applicationVersionName: string;
+  deploymentCount: number;
+  deploymentTargetCount: number;
+}
+
 @Injectable({providedIn: 'root'})
 export class DashboardService {
   private readonly httpClient = inject(HttpClient);
@@ -22,4 +79,16 @@ export class DashboardService {
   public getArtifactsByCustomer(): Observable<ArtifactsByCustomer[]> {
     return this.httpClient.get<ArtifactsByCustomer[]>(`${this.baseUrl}/artifacts-by-customer`);
   }
+
+  public getAggregates(): Observable<OrganizationAggregate[]> {
+    return this.httpClient.get<OrganizationAggregate[]>(`${this.baseUrl}/aggregates`);
+  }
+
+  public getAggregate<T>(name: AggregateName): Observable<OrganizationAggregate<T>> {
+    return this.httpClient.get<OrganizationAggregate<T>>(`${this.baseUrl}/aggregates/${name}`);
+  }
+
+  public refreshAggregates(names: AggregateName[] = []): Observable<OrganizationAggregate[]> {
+    return this.httpClient.post<OrganizationAggregate[]>(`${this.baseUrl}/aggregates/refresh`, {names});
+  }
 }
@@ -8,6 +8,7 @@ interface AgentDeploymentTargetMetrics {
   cpuUsage: number;
   memoryBytes: number;
   memoryUsage: number;
+  diskBytes?: number;
 }
 
 export interface DeploymentTargetLatestMetrics extends AgentDeploymentTargetMetrics {
@@ -16,13 +16,54 @@ import {
 import {ReactiveList} from './cache';
 import {CrudService} from './interfaces';
 import {
+  AcknowledgeDeploymentRevisionRequest,
+  DataCollection,
   Deployment,
+  DeploymentDependencies,
   DeploymentRequest,
   DeploymentTarget,
   DeploymentTargetAccessResponse,
+  DeploymentRevision,
+  DeploymentTargetDataPurge,
+  DeploymentTargetFileRetrieval,
+  DeploymentTargetFileRetrievalAllowList,
+  DeploymentTargetFileRetrievalWithContent,
+  DeploymentUninstallRequest,
+  PauseRequest,
   PatchDeploymentRequest,
+  PendingDeploymentAcknowledgment,
 } from '@glasskube/distr-sdk';
 
+export interface DeploymentTargetFilter {
+  health?: 'online' | 'stale' | 'never_connected';
+  /** Deployment targets that are paused or have a paused deployment. */
+  paused?: boolean;
+  production?: boolean;
+  agentVersionId?: string;
+  lastSeenBefore?: string;
+  lastSeenAfter?: string;
+  applicationId?: string;
+  applicationVersionId?: string;
+  versionOlderThan?: string;
+  includeArchived?: boolean;
+  /** Custom field filters by key. */
+  customFields?: Record<string, string>;
+}
+
+function filterParams(filter: DeploymentTargetFilter): Record<string, string> {
+  const {customFields, ...rest} = filter;
+  const params: Record<string, string> = {};
+  for (const [key, value] of Object.entries(rest)) {
+    if (value !== undefined) {
+      params[key] = String(value);
+    }
+  }
+  for (const [key, value] of Object.entries(customFields ?? {})) {
+    params[`customField.${key}`] = value;
+  }
+  return params;
+}
+
 class DeploymentTargetsReactiveList extends ReactiveList<DeploymentTarget> {
   protected override identify = (dt: DeploymentTarget) => dt.id;
   protected override sortAttr = (dt: DeploymentTarget) => dt.createdBy?.name ?? dt.createdBy?.email ?? dt.name;
@@ -59,6 +100,18 @@ export class DeploymentTargetsService implements CrudService<DeploymentTarget> {
     return this.cache.get();
   }
 
+  /** Returns the deployment targets that match all conditions of filter. The result is not cached. */
+  search(filter: DeploymentTargetFilter): Observable<DeploymentTarget[]> {
+    return this.httpClient.get<DeploymentTarget[]>(this.deploymentTargetsBaseUrl, {params: filterParams(filter)});
+  }
+
+  exportCsv(filter: DeploymentTargetFilter): Observable<Blob> {
+    return this.httpClient.get(this.deploymentTargetsBaseUrl, {
+      params: {...filterParams(filter), format: 'csv'},
+      responseType: 'blob',
+    });
+  }
+
   poll(): Observable<DeploymentTarget[]> {
     return this.sharedPolling$;
   }
@@ -97,8 +150,81 @@ export class DeploymentTargetsService implements CrudService<DeploymentTarget> {
     );
   }
 
-  deploy(request: DeploymentRequest): Observable<void> {
-    return this.httpClient.put<void>(this.deploymentsBaseUrl, request).pipe(tap(() => this.pollRefresh$.next()));
+  /**
+   * Updates the data collection settings of the current user's side (vendor or customer). The response contains the
+   * resulting effective policy.
+   */
+  updateDataCollection(deploymentTargetId: string, request: DataCollection): Observable<DeploymentTarget> {
+    return this.httpClient
+      .put<DeploymentTarget>(`${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/data-collection`, request)
+      .pipe(
+        tap((it) => {
+          this.cache.save(it);
+          this.pollRefresh$.next();
+        })
+      );
+  }
+
+  getDataPurges(deploymentTargetId: string): Observable<DeploymentTargetDataPurge[]> {
+    return this.httpClient.get<DeploymentTargetDataPurge[]>(
+      `${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/data-purges`
+    );
+  }
+
+  requestDataPurge(
+    deploymentTargetId: string,
+    request: Pick<DeploymentTargetDataPurge, 'logs' | 'metrics' | 'diagnostics' | 'inventory'>
+  ): Observable<DeploymentTargetDataPurge> {
+    return this.httpClient.post<DeploymentTargetDataPurge>(
+      `${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/data-purges`,
+      request
+    );
+  }
+
+  getFileRetrievalAllowList(deploymentTargetId: string): Observable<DeploymentTargetFileRetrievalAllowList> {
+    return this.httpClient.get<DeploymentTargetFileRetrievalAllowList>(
+      `${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/file-retrieval-allow-list`
+    );
+  }
+
+  updateFileRetrievalAllowList(
+    deploymentTargetId: string,
+    allowedPaths: string[]
+  ): Observable<DeploymentTargetFileRetrievalAllowList> {
+    return this.httpClient.put<DeploymentTargetFileRetrievalAllowList>(
+      `${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/file-retrieval-allow-list`,
+      {allowedPaths}
+    );
+  }
+
+  getFileRetrievals(deploymentTargetId: string): Observable<DeploymentTargetFileRetrieval[]> {
+    return this.httpClient.get<DeploymentTargetFileRetrieval[]>(
+      `${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/file-retrievals`
+    );
+  }
+
+  getFileRetrieval(deploymentTargetId: string, id: string): Observable<DeploymentTargetFileRetrievalWithContent> {
+    return this.httpClient.get<DeploymentTargetFileRetrievalWithContent>(
+      `${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/file-retrievals/${id}`
+    );
+  }
+
+  requestFileRetrieval(deploymentTargetId: string, path: string): Observable<DeploymentTargetFileRetrieval> {
+    return this.httpClient.post<DeploymentTargetFileRetrieval>(
+      `${this.deploymentTargetsBaseUrl}/${deploymentTargetId}/file-retrievals`,
+      {path}
+    );
+  }
+
+  /**
+   * Creates or updates a deployment and returns the warnings reported by the server, e.g. for resource requirements
+   * that the deployment target does not meet.
+   */
+  deploy(request: DeploymentRequest): Observable<string[]> {
+    return this.httpClient.put<void>(this.deploymentsBaseUrl, request, {observe: 'response'}).pipe(
+      tap(() => this.pollRefresh$.next()),
+      map((response) => response.headers.getAll('X-Distr-Warning') ?? [])
+    );
   }
 
   patchDeployment(id: string, request: PatchDeploymentRequest): Observable<Deployment> {
@@ -110,4 +236,59 @@ export class DeploymentTargetsService implements CrudService<DeploymentTarget> {
   undeploy(id: string): Observable<void> {
     return this.httpClient.delete<void>(`${this.deploymentsBaseUrl}/${id}`).pipe(tap(() => this.pollRefresh$.next()));
   }
+
+  getPendingAcknowledgments(): Observable<PendingDeploymentAcknowledgment[]> {
+    return this.httpClient.get<PendingDeploymentAcknowledgment[]>('/api/v1/deployment-acknowledgments');
+  }
+
+  acknowledgeDeploymentRevision(
+    deploymentId: string,
+    revisionId: string,
+    request: AcknowledgeDeploymentRevisionRequest = {}
+  ): Observable<DeploymentRevision> {
+    return this.httpClient
+      .post<DeploymentRevision>(
+        `${this.deploymentsBaseUrl}/${deploymentId}/revisions/${revisionId}/acknowledgment`,
+        request
+      )
+      .pipe(tap(() => this.pollRefresh$.next()));
+  }
+
+  uninstall(id: string, request: DeploymentUninstallRequest): Observable<Deployment> {
+    return this.httpClient
+      .post<Deployment>(`${this.deploymentsBaseUrl}/${id}/uninstall`, request)
+      .pipe(tap(() => this.pollRefresh$.next()));
+  }
+
+  pause(id: string, request: PauseRequest): Observable<Deployment> {
+    return this.httpClient
+      .post<Deployment>(`${this.deploymentsBaseUrl}/${id}/pause`, request)
+      .pipe(tap(() => this.pollRefresh$.next()));
+  }
+
+  resume(id: string): Observable<Deployment> {
+    return this.httpClient
+      .delete<Deployment>(`${this.deploymentsBaseUrl}/${id}/pause`)
+      .pipe(tap(() => this.pollRefresh$.next()));
+  }
+
+  pauseDeploymentTarget(id: string, request: PauseRequest): Observable<DeploymentTarget> {
+    return this.httpClient
+      .post<DeploymentTarget>(`${this.deploymentTargetsBaseUrl}/${id}/pause`, request)
+      .pipe(tap(() => this.pollRefresh$.next()));
+  }
+
+  resumeDeploymentTarget(id: string): Observable<DeploymentTarget> {
+    return this.httpClient
+      .delete<DeploymentTarget>(`${this.deploymentTargetsBaseUrl}/${id}/pause`)
+      .pipe(tap(() => this.pollRefresh$.next()));
+  }
+
+  getDependencies(id: string): Observable<DeploymentDependencies> {
+    return this.httpClient.get<DeploymentDependencies>(`${this.deploymentsBaseUrl}/${id}/dependencies`);
+  }
+
+  putDependencies(id: string, dependsOn: string[]): Observable<DeploymentDependencies> {
+    return this.httpClient.put<DeploymentDependencies>(`${this.deploymentsBaseUrl}/${id}/dependencies`, {dependsOn});
+  }
 }
@@ -5,6 +5,7 @@ import {DefaultReactiveList, ReactiveList} from './cache';
 import {CrudService} from './interfaces';
 import {Application, ApplicationVersion} from '@glasskube/distr-sdk';
 import {ApplicationLicense} from '../types/application-license';
+import {LicenseImpact} from '../types/license-impact';
 
 @Injectable({
   providedIn: 'root',
@@ -35,9 +36,14 @@ export class LicensesService implements CrudService<ApplicationLicense> {
       .pipe(tap((it) => this.cache.save(it)));
   }
 
-  delete(license: ApplicationLicense): Observable<void> {
+  impact(license: ApplicationLicense): Observable<LicenseImpact> {
+    return this.httpClient.get<LicenseImpact>(`${this.licensesUrl}/${license.id}/impact`);
+  }
+
+  delete(license: ApplicationLicense, confirm = false): Observable<void> {
+    const params = confirm ? {confirm} : undefined;
     return this.httpClient
-      .delete<void>(`${this.licensesUrl}/${license.id}`)
+      .delete<void>(`${this.licensesUrl}/${license.id}`, {params})
       .pipe(tap(() => this.cache.remove(license)));
   }
 }
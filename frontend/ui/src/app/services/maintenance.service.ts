@@ -0,0 +1,34 @@
+import {HttpClient} from '@angular/common/http';
+import {inject, Injectable} from '@angular/core';
+import {Observable, shareReplay, switchMap, timer} from 'rxjs';
+
+export interface MaintenanceStatus {
+  active: boolean;
+  scope?: 'server' | 'organization';
+  reason?: string;
+  since?: string;
+  retryAfterSeconds?: number;
+}
+
+@Injectable({providedIn: 'root'})
+export class MaintenanceService {
+  private readonly httpClient = inject(HttpClient);
+  private readonly baseUrl = '/api/v1/maintenance';
+
+  public readonly status$: Observable<MaintenanceStatus> = timer(0, 60_000).pipe(
+    switchMap(() => this.get()),
+    shareReplay({bufferSize: 1, refCount: true})
+  );
+
+  public get(): Observable<MaintenanceStatus> {
+    return this.httpClient.get<MaintenanceStatus>(this.baseUrl);
+  }
+
+  public enterOrganizationMaintenance(request: {reason: string; retryAfterSeconds?: number}) {
+    return this.httpClient.put<MaintenanceStatus>(this.baseUrl, request);
+  }
+
+  public leaveOrganizationMaintenance() {
+    return this.httpClient.delete<void>(this.baseUrl);
+  }
+}
@@ -0,0 +1,23 @@
+import {HttpClient} from '@angular/common/http';
+import {inject, Injectable} from '@angular/core';
+import {Observable} from 'rxjs';
+import {OrganizationAccessToken} from '../types/organization-access-token';
+
+const baseUrl = '/api/v1/organization/access-tokens';
+
+@Injectable({providedIn: 'root'})
+export class OrganizationAccessTokensService {
+  private readonly httpClient = inject(HttpClient);
+
+  public list(): Observable<OrganizationAccessToken[]> {
+    return this.httpClient.get<OrganizationAccessToken[]>(baseUrl);
+  }
+
+  public revoke(id: string): Observable<void> {
+    return this.httpClient.delete<void>(`${baseUrl}/${id}`);
+  }
+
+  public revokeAllOfUser(userAccountId: string): Observable<OrganizationAccessToken[]> {
+    return this.httpClient.post<OrganizationAccessToken[]>(`${baseUrl}/revoke`, {userAccountId});
+  }
+}
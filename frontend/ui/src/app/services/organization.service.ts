@@ -1,7 +1,7 @@
 import {HttpClient} from '@angular/common/http';
 import {inject, Injectable} from '@angular/core';
 import {combineLatestWith, map, merge, Observable, shareReplay, Subject, tap} from 'rxjs';
-import {Organization, OrganizationWithUserRole} from '../types/organization';
+import {Organization, OrganizationStorageUsage, OrganizationWithUserRole} from '../types/organization';
 import {ContextService} from './context.service';
 
 @Injectable({
@@ -27,6 +27,10 @@ export class OrganizationService {
     return this.contextService.getAvailableOrganizations();
   }
 
+  getStorageUsage(): Observable<OrganizationStorageUsage> {
+    return this.httpClient.get<OrganizationStorageUsage>(`${this.baseUrl}/usage`);
+  }
+
   create(organization: Organization): Observable<Organization> {
     return this.httpClient.post<Organization>(this.baseUrl, organization);
   }
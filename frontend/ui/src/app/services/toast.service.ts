@@ -12,7 +12,7 @@ const toastBaseConfig: Partial<IndividualConfig> = {
   positionClass: 'toast-bottom-right',
 };
 
-export type ToastType = 'success' | 'error';
+export type ToastType = 'success' | 'error' | 'warning';
 
 @Injectable({providedIn: 'root'})
 export class ToastService {
@@ -32,4 +32,11 @@ export class ToastService {
       payload: 'error',
     });
   }
+
+  public warning(message: string) {
+    this.toastr.show<ToastType>('', message, {
+      ...toastBaseConfig,
+      payload: 'warning',
+    });
+  }
 }
@@ -1,10 +1,15 @@
 import {UserAccount} from '@glasskube/distr-sdk';
+import {ServiceAccount} from './service-account';
 import {BaseArtifact, BaseArtifactVersion} from '../services/artifacts.service';
 
 export interface ArtifactVersionPull {
+  id: string;
   createdAt: string;
   remoteAddress?: string;
+  method: 'GET' | 'HEAD';
+  tokenSubject?: string;
   userAccount?: UserAccount;
+  serviceAccount?: ServiceAccount;
   artifact: BaseArtifact;
   artifactVersion: BaseArtifactVersion;
 }
@@ -0,0 +1,32 @@
+export interface LicenseImpact {
+  recentPulls: {
+    artifactId: string;
+    artifactName: string;
+    userAccountId: string;
+    userAccountEmail: string;
+    remoteAddress?: string;
+    pullCount: number;
+    lastPulledAt: string;
+  }[];
+  deploymentTargets: {id: string; name: string}[];
+  deployments: {id: string; deploymentTargetId: string; deploymentTargetName: string; archivedAt?: string}[];
+  seats: unknown[];
+}
+
+export function describeLicenseImpact(impact: LicenseImpact): string | undefined {
+  const parts: string[] = [];
+  if (impact.recentPulls.length > 0) {
+    const artifacts = new Set(impact.recentPulls.map((it) => it.artifactName));
+    parts.push(`${artifacts.size} artifact(s) were pulled with this license in the last 30 days`);
+  }
+  if (impact.deploymentTargets.length > 0) {
+    parts.push(`${impact.deploymentTargets.length} deployment target(s) might not be able to pull anymore`);
+  }
+  if (impact.deployments.length > 0) {
+    parts.push(`${impact.deployments.length} deployment(s) use this license`);
+  }
+  if (impact.seats.length > 0) {
+    parts.push(`${impact.seats.length} seat(s) are assigned`);
+  }
+  return parts.length > 0 ? parts.join(', ') + '.' : undefined;
+}
@@ -0,0 +1,11 @@
+import {AccessToken, UserRole} from '@glasskube/distr-sdk';
+
+export interface OrganizationAccessToken extends AccessToken {
+  userAccountId?: string;
+  userEmail?: string;
+  userName?: string;
+  userRole?: UserRole;
+  customer: boolean;
+  serviceAccountId?: string;
+  serviceAccountName?: string;
+}
@@ -1,6 +1,12 @@
-import {BaseModel, Named, UserRole} from '@glasskube/distr-sdk';
+import {AgentResourceLimits, BaseModel, Named, UserRole} from '@glasskube/distr-sdk';
 
-export type Feature = 'licensing';
+export type Feature = 'licensing' | 'access_grants';
+
+export type DeploymentReasonPolicy = 'optional' | 'production' | 'required';
+
+export type DeploymentUninstallPolicy = 'vendor' | 'vendor_and_customer';
+
+export type SecretScanPolicy = 'block' | 'warn';
 
 export interface Organization extends BaseModel, Named {
   slug?: string;
@@ -8,9 +14,32 @@ export interface Organization extends BaseModel, Named {
   appDomain?: string;
   registryDomain?: string;
   emailFromAddress?: string;
+  deploymentReasonPolicy?: DeploymentReasonPolicy;
+  deploymentUninstallPolicy?: DeploymentUninstallPolicy;
+  deploymentAutoRollbackWindowSeconds?: number | null;
+  secretScanPolicy?: SecretScanPolicy;
+  timezone?: string;
+  businessHours?: BusinessHours | null;
+  agentResourceLimits?: AgentResourceLimits;
+  registryIndexChildCheckDisabled?: boolean;
+}
+
+export interface BusinessHours {
+  /** 0 is Sunday */
+  weekdays: number[];
+  /** HH:MM in the timezone of the organization */
+  start: string;
+  end: string;
 }
 
 export interface OrganizationWithUserRole extends Organization {
   userRole: UserRole;
   joinedOrgAt: string;
 }
+
+export interface OrganizationStorageUsage {
+  blobCount: number;
+  blobBytes: number;
+  /** null if the organization is not limited */
+  quotaBytes: number | null;
+}
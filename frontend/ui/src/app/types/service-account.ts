@@ -0,0 +1,8 @@
+export interface ServiceAccount {
+  id: string;
+  createdAt: string;
+  name: string;
+  description?: string;
+  createdByUserAccountId?: string;
+  revokedAt?: string;
+}
@@ -0,0 +1,70 @@
+// Package agentappmetrics relays the metrics of deployed applications from the agent to the server.
+package agentappmetrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentclient"
+	"github.com/glasskube/distr/internal/appmetrics"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// URLFunc returns the URL at which the metrics endpoint of a deployment can be reached from the agent.
+// It returns an empty string if the endpoint can not be scraped on this platform.
+type URLFunc func(endpoint types.ApplicationMetricsEndpoint) string
+
+// Relayer scrapes the metrics endpoints of deployed applications and relays the allowed series to the server.
+type Relayer struct {
+	client     *agentclient.Client
+	logger     *zap.Logger
+	httpClient *http.Client
+	mutex      sync.Mutex
+}
+
+func NewRelayer(client *agentclient.Client, logger *zap.Logger) *Relayer {
+	return &Relayer{client: client, logger: logger, httpClient: &http.Client{}}
+}
+
+// RelayAsync scrapes all deployments that declare a metrics endpoint in the background. It does nothing if the
+// previous run has not finished yet, so that slow endpoints can not pile up scrapes.
+func (r *Relayer) RelayAsync(ctx context.Context, deployments []api.AgentDeployment, url URLFunc) {
+	if !r.mutex.TryLock() {
+		r.logger.Debug("previous app metrics relay is still running")
+		return
+	}
+	go func() {
+		defer r.mutex.Unlock()
+		for _, deployment := range deployments {
+			if deployment.MetricsEndpoint != nil {
+				r.relay(ctx, deployment.ID, url(*deployment.MetricsEndpoint), *deployment.MetricsEndpoint)
+			}
+		}
+	}()
+}
+
+func (r *Relayer) relay(
+	ctx context.Context,
+	deploymentID uuid.UUID,
+	url string,
+	endpoint types.ApplicationMetricsEndpoint,
+) {
+	log := r.logger.With(zap.Stringer("deploymentId", deploymentID))
+	if url == "" {
+		log.Debug("app metrics endpoint can not be scraped on this platform")
+		return
+	}
+	series, err := appmetrics.Scrape(ctx, r.httpClient, url, endpoint, appmetrics.MaxSeriesPerScrape)
+	if err != nil {
+		log.Warn("failed to scrape app metrics", zap.String("url", url), zap.Error(err))
+		return
+	}
+	report := api.AgentAppMetricsReport{DeploymentID: deploymentID, Series: series}
+	if err := r.client.ReportAppMetrics(ctx, report); err != nil {
+		log.Warn("failed to report app metrics", zap.Error(err))
+	}
+}
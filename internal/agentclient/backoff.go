@@ -0,0 +1,38 @@
+package agentclient
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	goneBackoffMin = time.Minute
+	goneBackoffMax = time.Hour
+)
+
+// GoneBackoff is used by agents to poll less often while their deployment target is gone. An archived deployment
+// target can be unarchived at any time, so agents keep polling, but the interval starts at one minute and doubles
+// with every poll up to one hour.
+type GoneBackoff struct {
+	next time.Duration
+}
+
+// Next returns the time to wait before the next poll.
+func (b *GoneBackoff) Next() time.Duration {
+	current := max(b.next, goneBackoffMin)
+	b.next = min(2*current, goneBackoffMax)
+	return current
+}
+
+// Wait blocks until it is time for the next poll or ctx is done.
+func (b *GoneBackoff) Wait(ctx context.Context) {
+	select {
+	case <-time.After(b.Next()):
+	case <-ctx.Done():
+	}
+}
+
+// Reset is called once the deployment target is available again.
+func (b *GoneBackoff) Reset() {
+	b.next = 0
+}
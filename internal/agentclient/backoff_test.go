@@ -0,0 +1,24 @@
+package agentclient
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGoneBackoff(t *testing.T) {
+	g := NewWithT(t)
+	var b GoneBackoff
+	var delays []time.Duration
+	for range 9 {
+		delays = append(delays, b.Next())
+	}
+	g.Expect(delays).To(Equal([]time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute,
+		time.Hour, time.Hour, time.Hour,
+	}))
+
+	b.Reset()
+	g.Expect(b.Next()).To(Equal(time.Minute))
+}
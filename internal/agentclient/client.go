@@ -4,17 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/glasskube/distr/api"
 	"github.com/glasskube/distr/internal/agentclient/useragent"
 	"github.com/glasskube/distr/internal/buildconfig"
+	"github.com/glasskube/distr/internal/secretref"
 	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"go.uber.org/multierr"
@@ -30,6 +35,16 @@ type clientData struct {
 	statusEndpoint   string
 	metricsEndpoint  string
 	logsEndpoint     string
+	// connectivityEndpoint is optional, because older agent manifests do not contain it
+	connectivityEndpoint string
+	// appMetricsEndpoint is optional, because older agent manifests do not contain it
+	appMetricsEndpoint string
+	// inventoryEndpoint is optional, because older agent manifests do not contain it
+	inventoryEndpoint string
+	// resourceLimitsEndpoint is optional, because older agent manifests do not contain it
+	resourceLimitsEndpoint string
+	// fileRetrievalsEndpoint is optional, because older agent manifests do not contain it
+	fileRetrievalsEndpoint string
 }
 
 type Client struct {
@@ -39,6 +54,23 @@ type Client struct {
 	token      jwt.Token
 	rawToken   string
 	mutex      sync.Mutex
+	// operationIDs maps the revision IDs of the latest resource to their operation IDs, so that status and log reports
+	// can be correlated with the operation on the server.
+	operationIDs   map[uuid.UUID]uuid.UUID
+	operationMutex sync.RWMutex
+	// dataCollection is the data collection policy of the latest resource. Reports of disabled categories are
+	// discarded instead of being sent.
+	dataCollection      types.DataCollection
+	dataCollectionMutex sync.RWMutex
+	// resourceCache is the latest resource that the server sent with an ETag. It is used again if the server responds
+	// that the resource has not been modified.
+	resourceCache *resourceCache
+}
+
+type resourceCache struct {
+	endpoint string
+	etag     string
+	body     []byte
 }
 
 func (c *Client) Resource(ctx context.Context) (*api.AgentResource, error) {
@@ -47,16 +79,79 @@ func (c *Client) Resource(ctx context.Context) (*api.AgentResource, error) {
 		return nil, err
 	} else {
 		req.Header.Set("Content-Type", "application/json")
+		cache := c.resourceCache
+		if cache != nil && cache.endpoint == c.resourceEndpoint {
+			req.Header.Set("If-None-Match", cache.etag)
+		} else {
+			cache = nil
+		}
 		if resp, err := c.doAuthenticated(ctx, req); err != nil {
 			return nil, err
-		} else if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		} else if body, err := readResource(resp, cache); err != nil {
+			return nil, err
+		} else if err := json.Unmarshal(body, &result); err != nil {
 			return nil, err
 		} else {
+			if etag := resp.Header.Get("ETag"); etag != "" {
+				c.resourceCache = &resourceCache{endpoint: c.resourceEndpoint, etag: etag, body: body}
+			} else {
+				c.resourceCache = nil
+			}
+			result.ApplyDataCollection()
+			c.setOperationIDs(result.Deployments)
+			c.setDataCollection(result.DataCollection)
 			return &result, nil
 		}
 	}
 }
 
+// readResource returns the body of resp or the body of cache if the resource has not been modified.
+func readResource(resp *http.Response, cache *resourceCache) ([]byte, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		return io.ReadAll(resp.Body)
+	} else if cache == nil {
+		return nil, fmt.Errorf("%w: %v without cached resource", ErrHttpStatus, resp.Status)
+	} else {
+		return cache.body, nil
+	}
+}
+
+func (c *Client) setOperationIDs(deployments []api.AgentDeployment) {
+	operationIDs := make(map[uuid.UUID]uuid.UUID, len(deployments))
+	for _, deployment := range deployments {
+		if deployment.OperationID != uuid.Nil {
+			operationIDs[deployment.RevisionID] = deployment.OperationID
+		}
+	}
+	c.operationMutex.Lock()
+	defer c.operationMutex.Unlock()
+	c.operationIDs = operationIDs
+}
+
+// operationID returns the operation ID of a revision or nil if the revision is not part of the latest resource or
+// the server did not send an operation ID.
+func (c *Client) operationID(revisionID uuid.UUID) *uuid.UUID {
+	c.operationMutex.RLock()
+	defer c.operationMutex.RUnlock()
+	if id, ok := c.operationIDs[revisionID]; ok {
+		return &id
+	}
+	return nil
+}
+
+func (c *Client) setDataCollection(dc types.DataCollection) {
+	c.dataCollectionMutex.Lock()
+	defer c.dataCollectionMutex.Unlock()
+	c.dataCollection = dc
+}
+
+func (c *Client) getDataCollection() types.DataCollection {
+	c.dataCollectionMutex.RLock()
+	defer c.dataCollectionMutex.RUnlock()
+	return c.dataCollection
+}
+
 func (c *Client) Manifest(ctx context.Context) ([]byte, error) {
 	if req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.manifestEndpoint, nil); err != nil {
 		return nil, err
@@ -69,13 +164,35 @@ func (c *Client) Manifest(ctx context.Context) ([]byte, error) {
 	}
 }
 
+// ConnectManifest fetches the agent manifest from the connect URL of a (possibly different) Distr instance.
+// The request is not authenticated, because the connect URL already contains the credentials.
+func (c *Client) ConnectManifest(ctx context.Context, connectURL string) ([]byte, error) {
+	if req, err := http.NewRequestWithContext(ctx, http.MethodGet, connectURL, nil); err != nil {
+		return nil, err
+	} else if resp, err := c.do(req); err != nil {
+		return nil, err
+	} else {
+		defer resp.Body.Close()
+		return io.ReadAll(resp.Body)
+	}
+}
+
+// StatusWithError sends an error status with the message of err or an ok status with message if err is nil. If err is
+// a [secretref.ResolutionError], the status contains the corresponding error reason.
 func (c *Client) StatusWithError(ctx context.Context, revisionID uuid.UUID, message string, err error) error {
-	statusType := types.DeploymentStatusTypeOK
-	if err != nil {
-		statusType = types.DeploymentStatusTypeError
-		message = err.Error()
+	if err == nil {
+		return c.Status(ctx, revisionID, types.DeploymentStatusTypeOK, message)
+	}
+	status := api.AgentDeploymentStatus{
+		RevisionID: revisionID,
+		Message:    err.Error(),
+		Type:       types.DeploymentStatusTypeError,
 	}
-	return c.Status(ctx, revisionID, statusType, message)
+	var resolutionErr *secretref.ResolutionError
+	if errors.As(err, &resolutionErr) {
+		status.ErrorReason = util.PtrTo(resolutionErr.DeploymentStatusErrorReason())
+	}
+	return c.postStatus(ctx, status)
 }
 
 func (c *Client) Status(
@@ -84,11 +201,30 @@ func (c *Client) Status(
 	statusType types.DeploymentStatusType,
 	message string,
 ) error {
-	deploymentStatus := api.AgentDeploymentStatus{
+	return c.postStatus(ctx, api.AgentDeploymentStatus{
 		RevisionID: revisionID,
 		Message:    message,
 		Type:       statusType,
-	}
+	})
+}
+
+// PullProgress sends a progressing status that includes the pull progress of the deployment images.
+func (c *Client) PullProgress(
+	ctx context.Context,
+	revisionID uuid.UUID,
+	message string,
+	progress []api.AgentImagePullProgress,
+) error {
+	return c.postStatus(ctx, api.AgentDeploymentStatus{
+		RevisionID:   revisionID,
+		Message:      message,
+		Type:         types.DeploymentStatusTypeProgressing,
+		PullProgress: progress,
+	})
+}
+
+func (c *Client) postStatus(ctx context.Context, deploymentStatus api.AgentDeploymentStatus) error {
+	deploymentStatus.OperationID = c.operationID(deploymentStatus.RevisionID)
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(deploymentStatus); err != nil {
 		return err
@@ -105,6 +241,15 @@ func (c *Client) Status(
 }
 
 func (c *Client) Logs(ctx context.Context, logs []api.DeploymentLogRecord) error {
+	if c.getDataCollection().LogsDisabled {
+		c.logger.Debug("logs collection is disabled, discarding log records", zap.Int("count", len(logs)))
+		return nil
+	}
+	for i := range logs {
+		if logs[i].OperationID == nil {
+			logs[i].OperationID = c.operationID(logs[i].DeploymentRevisionID)
+		}
+	}
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(logs); err != nil {
 		return err
@@ -176,6 +321,10 @@ func (c *Client) RawToken() string {
 }
 
 func (c *Client) ReportMetrics(ctx context.Context, metrics api.AgentDeploymentTargetMetrics) error {
+	if c.getDataCollection().MetricsDisabled {
+		c.logger.Debug("metrics collection is disabled, discarding metrics")
+		return nil
+	}
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(metrics); err != nil {
 		return err
@@ -191,8 +340,93 @@ func (c *Client) ReportMetrics(ctx context.Context, metrics api.AgentDeploymentT
 	}
 }
 
+func (c *Client) ReportConnectivity(ctx context.Context, report api.AgentConnectivityReport) error {
+	if c.getDataCollection().DiagnosticsDisabled {
+		c.logger.Debug("diagnostics collection is disabled, discarding connectivity report")
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(report); err != nil {
+		return err
+	} else if req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.connectivityEndpoint, &buf); err != nil {
+		return err
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		_, err := c.doAuthenticated(ctx, req)
+		return err
+	}
+}
+
+func (c *Client) ReportFileRetrieval(ctx context.Context, report api.AgentFileRetrievalReport) error {
+	if c.getDataCollection().DiagnosticsDisabled {
+		c.logger.Debug("diagnostics collection is disabled, discarding file retrieval report")
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(report); err != nil {
+		return err
+	} else if req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.fileRetrievalsEndpoint, &buf); err != nil {
+		return err
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		_, err := c.doAuthenticated(ctx, req)
+		return err
+	}
+}
+
+func (c *Client) ReportAppMetrics(ctx context.Context, report api.AgentAppMetricsReport) error {
+	if c.getDataCollection().MetricsDisabled {
+		c.logger.Debug("metrics collection is disabled, discarding app metrics")
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(report); err != nil {
+		return err
+	} else if req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.appMetricsEndpoint, &buf); err != nil {
+		return err
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		_, err := c.doAuthenticated(ctx, req)
+		return err
+	}
+}
+
+func (c *Client) ReportInventory(ctx context.Context, report api.AgentInventoryReport) error {
+	if c.getDataCollection().InventoryDisabled {
+		c.logger.Debug("inventory collection is disabled, discarding inventory report")
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(report); err != nil {
+		return err
+	} else if req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.inventoryEndpoint, &buf); err != nil {
+		return err
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		_, err := c.doAuthenticated(ctx, req)
+		return err
+	}
+}
+
+func (c *Client) ReportResourceLimits(ctx context.Context, report api.AgentResourceLimitsReport) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(report); err != nil {
+		return err
+	} else if req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.resourceLimitsEndpoint, &buf); err != nil {
+		return err
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		_, err := c.doAuthenticated(ctx, req)
+		return err
+	}
+}
+
 func (c *Client) doAuthenticated(ctx context.Context, r *http.Request) (*http.Response, error) {
-	if resp, err := c.doAuthenticatedNoRetry(ctx, r); resp == nil || resp.StatusCode != 401 {
+	if resp, err := c.doAuthenticatedNoRetry(ctx, r); IsDeploymentTargetGone(err) {
+		// the token can never be used again
+		c.ClearToken()
+		return resp, err
+	} else if resp == nil || resp.StatusCode != 401 {
 		return resp, err
 	} else {
 		c.logger.Warn("got 401 response, try to regenerate token")
@@ -217,6 +451,8 @@ func (c *Client) doAuthenticatedNoRetry(ctx context.Context, r *http.Request) (*
 
 func (c *Client) do(r *http.Request) (*http.Response, error) {
 	r.Header.Set("User-Agent", fmt.Sprintf("%v/%v", useragent.DistrAgentUserAgent, buildconfig.Version()))
+	r.Header.Set(useragent.DistrAgentPlatformHeader, runtime.GOOS+"/"+runtime.GOARCH)
+	r.Header.Set(useragent.DistrAgentTimeHeader, time.Now().UTC().Format(time.RFC3339Nano))
 	return checkStatus(c.httpClient.Do(r))
 }
 
@@ -239,6 +475,31 @@ func (c *Client) ReloadFromEnv() (changed bool, err error) {
 	} else if d.logsEndpoint, err = readEnvVar("DISTR_LOGS_ENDPOINT"); err != nil {
 		return
 	} else {
+		if value, ok := os.LookupEnv("DISTR_CONNECTIVITY_ENDPOINT"); ok {
+			d.connectivityEndpoint = value
+		} else {
+			d.connectivityEndpoint = strings.TrimSuffix(d.resourceEndpoint, "resources") + "connectivity"
+		}
+		if value, ok := os.LookupEnv("DISTR_APP_METRICS_ENDPOINT"); ok {
+			d.appMetricsEndpoint = value
+		} else {
+			d.appMetricsEndpoint = strings.TrimSuffix(d.resourceEndpoint, "resources") + "app-metrics"
+		}
+		if value, ok := os.LookupEnv("DISTR_INVENTORY_ENDPOINT"); ok {
+			d.inventoryEndpoint = value
+		} else {
+			d.inventoryEndpoint = strings.TrimSuffix(d.resourceEndpoint, "resources") + "inventory"
+		}
+		if value, ok := os.LookupEnv("DISTR_RESOURCE_LIMITS_ENDPOINT"); ok {
+			d.resourceLimitsEndpoint = value
+		} else {
+			d.resourceLimitsEndpoint = strings.TrimSuffix(d.resourceEndpoint, "resources") + "resource-limits"
+		}
+		if value, ok := os.LookupEnv("DISTR_FILE_RETRIEVALS_ENDPOINT"); ok {
+			d.fileRetrievalsEndpoint = value
+		} else {
+			d.fileRetrievalsEndpoint = strings.TrimSuffix(d.resourceEndpoint, "resources") + "file-retrievals"
+		}
 		changed = c.clientData != d
 		if changed {
 			c.clientData = d
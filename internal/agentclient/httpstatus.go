@@ -1,18 +1,45 @@
 package agentclient
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/glasskube/distr/api"
 )
 
-var ErrHttpStatus = errors.New("non-ok http status")
+var (
+	ErrHttpStatus = errors.New("non-ok http status")
+	// ErrDeploymentTargetArchived is returned for every request once the deployment target has been archived.
+	// Agents should keep polling with a GoneBackoff when they receive this error, so that they resume once the
+	// deployment target is unarchived.
+	ErrDeploymentTargetArchived = errors.New("deployment target is archived")
+	// ErrDeploymentTargetDeleted is returned for every request once the deployment target has been deleted.
+	// The deployment target will never come back, but exiting would only cause the agent to be restarted, so agents
+	// keep polling with a GoneBackoff as well.
+	ErrDeploymentTargetDeleted = errors.New("deployment target has been deleted")
+)
+
+// IsDeploymentTargetGone returns true if err means that the agent must stay idle and poll with a GoneBackoff, because
+// its deployment target has been archived or deleted.
+func IsDeploymentTargetGone(err error) bool {
+	return errors.Is(err, ErrDeploymentTargetArchived) || errors.Is(err, ErrDeploymentTargetDeleted)
+}
 
 func checkStatus(r *http.Response, err error) (*http.Response, error) {
 	if err != nil || statusOK(r) {
 		return r, err
+	} else if r.StatusCode == http.StatusGone {
+		// older servers respond with a plain text body and only archived deployment targets were gone
+		var response api.ErrorResponse
+		if err := json.NewDecoder(r.Body).Decode(&response); err == nil &&
+			response.Error.Code == api.AgentGoneCodeDeleted {
+			return r, fmt.Errorf("%w: %v", ErrDeploymentTargetDeleted, r.Status)
+		}
+		return r, fmt.Errorf("%w: %v", ErrDeploymentTargetArchived, r.Status)
 	} else {
 		if errorBody, err := io.ReadAll(r.Body); err == nil {
 			return r, fmt.Errorf("%w: %v (%v)", ErrHttpStatus, r.Status, strings.TrimSpace(string(errorBody)))
@@ -21,6 +48,8 @@ func checkStatus(r *http.Response, err error) (*http.Response, error) {
 	}
 }
 
+// statusOK returns true for successful responses. 304 is also successful, because it is only sent for conditional
+// requests and means that the cached response can be used.
 func statusOK(r *http.Response) bool {
-	return 200 <= r.StatusCode && r.StatusCode < 300
+	return 200 <= r.StatusCode && r.StatusCode < 300 || r.StatusCode == http.StatusNotModified
 }
@@ -1,3 +1,10 @@
 package useragent
 
 const DistrAgentUserAgent = "DistrAgentClient"
+
+// DistrAgentPlatformHeader is sent by agents to report the platform they are running on, e.g. "linux/arm64".
+const DistrAgentPlatformHeader = "X-Distr-Agent-Platform"
+
+// DistrAgentTimeHeader is sent by agents with the current time of the agent clock in RFC 3339 format, so that the
+// server can detect clock skew.
+const DistrAgentTimeHeader = "X-Distr-Agent-Time"
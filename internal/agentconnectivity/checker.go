@@ -0,0 +1,140 @@
+package agentconnectivity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentclient"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	endpointTimeout = 10 * time.Second
+	// maxEndpoints limits how many endpoints are checked for a single request, regardless of what the server sends
+	maxEndpoints = 20
+)
+
+// rootCAs are used to validate server certificates. If it is nil, the system roots are used.
+var rootCAs *x509.CertPool
+
+// Checker runs connectivity checks requested by the server and reports their results.
+// Every check is only run once, even though the server keeps sending it until the results have been reported.
+type Checker struct {
+	client      *agentclient.Client
+	logger      *zap.Logger
+	mutex       sync.Mutex
+	lastCheckID uuid.UUID
+}
+
+func NewChecker(client *agentclient.Client, logger *zap.Logger) *Checker {
+	return &Checker{client: client, logger: logger}
+}
+
+// HandleAsync starts the given check in the background. It does nothing if check is nil or has already been handled.
+func (c *Checker) HandleAsync(ctx context.Context, check *api.AgentConnectivityCheck) {
+	if check == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.lastCheckID == check.ID {
+		return
+	}
+	c.lastCheckID = check.ID
+	go func() {
+		c.logger.Info("running connectivity check", zap.Stringer("checkId", check.ID))
+		report := api.AgentConnectivityReport{CheckID: check.ID, Results: Run(ctx, check.Endpoints)}
+		if err := c.client.ReportConnectivity(ctx, report); err != nil {
+			c.logger.Warn("failed to report connectivity check results", zap.Error(err))
+		}
+	}()
+}
+
+// Run tests the reachability of all endpoints.
+// For every endpoint, the host is resolved and a TCP connection is established.
+// If the endpoint uses https, a TLS handshake is performed as well and the server certificate is validated.
+func Run(ctx context.Context, endpoints []api.AgentConnectivityEndpoint) []types.ConnectivityCheckResult {
+	if len(endpoints) > maxEndpoints {
+		endpoints = endpoints[:maxEndpoints]
+	}
+	results := make([]types.ConnectivityCheckResult, len(endpoints))
+	for i, endpoint := range endpoints {
+		results[i] = check(ctx, endpoint)
+	}
+	return results
+}
+
+func check(ctx context.Context, endpoint api.AgentConnectivityEndpoint) types.ConnectivityCheckResult {
+	result := types.ConnectivityCheckResult{Name: endpoint.Name, URL: endpoint.URL}
+	ctx, cancel := context.WithTimeout(ctx, endpointTimeout)
+	defer cancel()
+
+	u, err := url.Parse(endpoint.URL)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid url: %v", err)
+		return result
+	}
+	port := u.Port()
+	if port == "" {
+		if u.Scheme == "http" {
+			port = "80"
+		} else {
+			port = "443"
+		}
+	}
+
+	if addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+		result.Error = fmt.Sprintf("dns lookup failed: %v", err)
+		return result
+	} else {
+		result.ResolvedIPs = addrs
+	}
+
+	start := time.Now()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		result.Error = fmt.Sprintf("connection failed: %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	if u.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), RootCAs: rootCAs})
+		err := tlsConn.HandshakeContext(ctx)
+		var verificationErr *tls.CertificateVerificationError
+		if errors.As(err, &verificationErr) {
+			result.Reachable = true
+			result.TLSValid = util.PtrTo(false)
+			if len(verificationErr.UnverifiedCertificates) > 0 {
+				cert := verificationErr.UnverifiedCertificates[0]
+				result.TLSExpiry = &cert.NotAfter
+				result.TLSIssuer = cert.Issuer.String()
+			}
+			result.Error = fmt.Sprintf("tls verification failed: %v", verificationErr.Err)
+			return result
+		} else if err != nil {
+			result.Error = fmt.Sprintf("tls handshake failed: %v", err)
+			return result
+		}
+		result.TLSValid = util.PtrTo(true)
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			result.TLSExpiry = &certs[0].NotAfter
+			result.TLSIssuer = certs[0].Issuer.String()
+		}
+	}
+
+	result.Reachable = true
+	result.LatencyMs = util.PtrTo(time.Since(start).Milliseconds())
+	return result
+}
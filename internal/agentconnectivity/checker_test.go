@@ -0,0 +1,113 @@
+package agentconnectivity
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glasskube/distr/api"
+	. "github.com/onsi/gomega"
+)
+
+func stub(t *testing.T, server *httptest.Server) api.AgentConnectivityEndpoint {
+	t.Cleanup(server.Close)
+	return api.AgentConnectivityEndpoint{Name: "stub", URL: server.URL}
+}
+
+func TestCheckHTTP(t *testing.T) {
+	g := NewWithT(t)
+	endpoint := stub(t, httptest.NewServer(http.NotFoundHandler()))
+
+	result := check(context.Background(), endpoint)
+	g.Expect(result.Name).To(Equal("stub"))
+	g.Expect(result.URL).To(Equal(endpoint.URL))
+	g.Expect(result.Reachable).To(BeTrue())
+	g.Expect(result.Error).To(BeEmpty())
+	g.Expect(result.ResolvedIPs).To(ContainElement("127.0.0.1"))
+	g.Expect(result.LatencyMs).NotTo(BeNil())
+	g.Expect(result.TLSValid).To(BeNil())
+}
+
+func TestCheckTLSValid(t *testing.T) {
+	g := NewWithT(t)
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	endpoint := stub(t, server)
+	rootCAs = x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	t.Cleanup(func() { rootCAs = nil })
+
+	result := check(context.Background(), endpoint)
+	g.Expect(result.Reachable).To(BeTrue())
+	g.Expect(result.Error).To(BeEmpty())
+	g.Expect(result.TLSValid).To(HaveValue(BeTrue()))
+	g.Expect(result.TLSExpiry).To(HaveValue(Equal(server.Certificate().NotAfter)))
+	g.Expect(result.TLSIssuer).NotTo(BeEmpty())
+	g.Expect(result.LatencyMs).NotTo(BeNil())
+}
+
+func TestCheckTLSUntrusted(t *testing.T) {
+	g := NewWithT(t)
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	endpoint := stub(t, server)
+
+	// the server is reachable, but its certificate is not signed by a trusted authority
+	result := check(context.Background(), endpoint)
+	g.Expect(result.Reachable).To(BeTrue())
+	g.Expect(result.TLSValid).To(HaveValue(BeFalse()))
+	g.Expect(result.TLSExpiry).To(HaveValue(Equal(server.Certificate().NotAfter)))
+	g.Expect(result.Error).To(HavePrefix("tls verification failed"))
+	g.Expect(result.LatencyMs).To(BeNil())
+}
+
+func TestCheckTLSHandshakeFails(t *testing.T) {
+	g := NewWithT(t)
+	// a plain http server does not respond to the TLS handshake with a certificate
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := stub(t, server)
+	endpoint.URL = "https://" + server.Listener.Addr().String()
+
+	result := check(context.Background(), endpoint)
+	g.Expect(result.Reachable).To(BeFalse())
+	g.Expect(result.TLSValid).To(BeNil())
+	g.Expect(result.Error).To(HavePrefix("tls handshake failed"))
+}
+
+func TestCheckConnectionRefused(t *testing.T) {
+	g := NewWithT(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).NotTo(HaveOccurred())
+	url := "http://" + listener.Addr().String()
+	g.Expect(listener.Close()).To(Succeed())
+
+	result := check(context.Background(), api.AgentConnectivityEndpoint{Name: "closed", URL: url})
+	g.Expect(result.Reachable).To(BeFalse())
+	g.Expect(result.ResolvedIPs).To(ContainElement("127.0.0.1"))
+	g.Expect(result.Error).To(HavePrefix("connection failed"))
+}
+
+func TestCheckInvalidEndpoint(t *testing.T) {
+	g := NewWithT(t)
+	result := check(context.Background(), api.AgentConnectivityEndpoint{URL: "http://[::1"})
+	g.Expect(result.Reachable).To(BeFalse())
+	g.Expect(result.Error).To(HavePrefix("invalid url"))
+
+	result = check(context.Background(), api.AgentConnectivityEndpoint{URL: "https://distr.invalid"})
+	g.Expect(result.Reachable).To(BeFalse())
+	g.Expect(result.Error).To(HavePrefix("dns lookup failed"))
+}
+
+func TestRunLimitsEndpoints(t *testing.T) {
+	g := NewWithT(t)
+	endpoint := stub(t, httptest.NewServer(http.NotFoundHandler()))
+	endpoints := make([]api.AgentConnectivityEndpoint, maxEndpoints+5)
+	for i := range endpoints {
+		endpoints[i] = endpoint
+	}
+
+	results := Run(context.Background(), endpoints)
+	g.Expect(results).To(HaveLen(maxEndpoints))
+	g.Expect(results).To(HaveEach(HaveField("Reachable", true)))
+}
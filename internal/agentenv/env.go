@@ -14,3 +14,13 @@ var (
 	DistrRegistryHost      = envutil.GetEnv("DISTR_REGISTRY_HOST")
 	DistrRegistryPlainHTTP = envutil.GetEnvParsedOrDefault("DISTR_REGISTRY_PLAIN_HTTP", strconv.ParseBool, false)
 )
+
+// Secret stores that the agent uses to resolve secret references in deployment values. They are all optional.
+var (
+	VaultAddress              = envutil.GetEnv("DISTR_VAULT_ADDR")
+	VaultToken                = envutil.GetEnv("DISTR_VAULT_TOKEN")
+	VaultTokenFile            = envutil.GetEnv("DISTR_VAULT_TOKEN_FILE")
+	VaultNamespace            = envutil.GetEnv("DISTR_VAULT_NAMESPACE")
+	AWSSecretsManagerRegion   = envutil.GetEnv("DISTR_AWS_SECRETS_MANAGER_REGION")
+	AWSSecretsManagerEndpoint = envutil.GetEnv("DISTR_AWS_SECRETS_MANAGER_ENDPOINT")
+)
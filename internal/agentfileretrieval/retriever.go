@@ -0,0 +1,123 @@
+// Package agentfileretrieval uploads single files from the host of the agent that have been requested by the server.
+// Only files that match the allow-list of the deployment target are uploaded, and secrets are redacted beforehand.
+package agentfileretrieval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentclient"
+	"github.com/glasskube/distr/internal/secretscan"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var scanner = secretscan.New()
+
+// Retriever handles the file retrievals requested by the server and reports their results.
+// Every retrieval is only handled once, even though the server keeps sending it until the result has been reported.
+type Retriever struct {
+	client  *agentclient.Client
+	logger  *zap.Logger
+	mutex   sync.Mutex
+	handled map[uuid.UUID]struct{}
+}
+
+func NewRetriever(client *agentclient.Client, logger *zap.Logger) *Retriever {
+	return &Retriever{client: client, logger: logger}
+}
+
+// HandleAsync starts the retrievals that have not been handled yet in the background.
+func (r *Retriever) HandleAsync(ctx context.Context, retrievals []api.AgentFileRetrieval) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// only the IDs of the current retrievals are remembered, because the server stops sending a retrieval once its
+	// result has been reported
+	handled := make(map[uuid.UUID]struct{}, len(retrievals))
+	for _, retrieval := range retrievals {
+		handled[retrieval.ID] = struct{}{}
+		if _, ok := r.handled[retrieval.ID]; ok {
+			continue
+		}
+		go func() {
+			r.logger.Info("retrieving file",
+				zap.Stringer("retrievalId", retrieval.ID), zap.String("path", retrieval.Path))
+			report := Retrieve(retrieval)
+			if report.Status == types.FileRetrievalStatusDenied {
+				r.logger.Warn("file retrieval denied", zap.String("path", retrieval.Path), zap.String("reason", report.Error))
+			}
+			if err := r.client.ReportFileRetrieval(ctx, report); err != nil {
+				r.logger.Warn("failed to report file retrieval", zap.Error(err))
+			}
+		}()
+	}
+	r.handled = handled
+}
+
+// Retrieve reads the requested file and redacts the secrets in it. The retrieval is denied if the path, or the path
+// that it resolves to if it contains symlinks, does not match one of the allowed paths. Files that are larger than
+// types.MaxFileRetrievalSize are not read.
+func Retrieve(retrieval api.AgentFileRetrieval) api.AgentFileRetrievalReport {
+	report := api.AgentFileRetrievalReport{RetrievalID: retrieval.ID}
+	fail := func(status types.FileRetrievalStatus, format string, args ...any) api.AgentFileRetrievalReport {
+		report.Status = status
+		report.Error = fmt.Sprintf(format, args...)
+		return report
+	}
+
+	if !Allowed(retrieval.Path, retrieval.AllowedPaths) {
+		return fail(types.FileRetrievalStatusDenied, "path is not in the allow-list")
+	}
+	resolved, err := filepath.EvalSymlinks(retrieval.Path)
+	if err != nil {
+		return fail(types.FileRetrievalStatusFailed, "could not resolve path: %v", err)
+	} else if !Allowed(resolved, retrieval.AllowedPaths) {
+		return fail(types.FileRetrievalStatusDenied, "path resolves to %v, which is not in the allow-list", resolved)
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return fail(types.FileRetrievalStatusFailed, "could not open file: %v", err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil {
+		return fail(types.FileRetrievalStatusFailed, "could not stat file: %v", err)
+	} else if !info.Mode().IsRegular() {
+		return fail(types.FileRetrievalStatusFailed, "not a regular file")
+	} else if info.Size() > types.MaxFileRetrievalSize {
+		return fail(types.FileRetrievalStatusFailed, "file is larger than %v bytes", types.MaxFileRetrievalSize)
+	}
+	// the file could have grown since it has been checked
+	data, err := io.ReadAll(io.LimitReader(file, types.MaxFileRetrievalSize+1))
+	if err != nil {
+		return fail(types.FileRetrievalStatusFailed, "could not read file: %v", err)
+	} else if len(data) > types.MaxFileRetrievalSize {
+		return fail(types.FileRetrievalStatusFailed, "file is larger than %v bytes", types.MaxFileRetrievalSize)
+	}
+
+	report.Status = types.FileRetrievalStatusCompleted
+	report.Size = int64(len(data))
+	report.RedactedSecrets = len(scanner.Scan(retrieval.Path, data))
+	report.Content = scanner.Redact(data)
+	return report
+}
+
+// Allowed reports whether p is a clean absolute path that matches one of the patterns.
+func Allowed(p string, patterns []string) bool {
+	if !path.IsAbs(p) || path.Clean(p) != p {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+// Package agentimage resolves platform specific digests of the multi-platform agent images.
+package agentimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	DockerAgentImage     = "ghcr.io/glasskube/distr/docker-agent"
+	KubernetesAgentImage = "ghcr.io/glasskube/distr/kubernetes-agent"
+)
+
+var (
+	ErrInvalidPlatform     = errors.New("platform must have the format os/arch[/variant]")
+	ErrPlatformUnsupported = errors.New("image has no manifest for platform")
+
+	platformRegex = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+)
+
+// IndexFetcher returns the image index for an image reference.
+// If the reference points to a single manifest instead of an index, an index containing only this manifest without
+// platform should be returned.
+type IndexFetcher interface {
+	Index(ctx context.Context, ref string) (*v1.IndexManifest, error)
+}
+
+// Image returns the agent image repository for a deployment type.
+func Image(deploymentType types.DeploymentType) string {
+	if deploymentType == types.DeploymentTypeDocker {
+		return DockerAgentImage
+	}
+	return KubernetesAgentImage
+}
+
+// ParsePlatform validates a platform string like "linux/arm64" and returns its canonical form.
+func ParsePlatform(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if !platformRegex.MatchString(s) {
+		return "", ErrInvalidPlatform
+	}
+	return s, nil
+}
+
+// Arch returns the architecture component of a platform.
+func Arch(platform string) string {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// PlatformDigest returns the digest of the manifest for the given platform.
+// A variant is only compared if the platform has one.
+func PlatformDigest(index *v1.IndexManifest, platform string) (string, error) {
+	for _, m := range index.Manifests {
+		if m.Platform != nil && platformMatches(*m.Platform, platform) {
+			return m.Digest.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%w %v", ErrPlatformUnsupported, platform)
+}
+
+// MissingPlatforms returns all platforms that have no manifest in index.
+func MissingPlatforms(index *v1.IndexManifest, platforms []string) []string {
+	var result []string
+	for _, platform := range platforms {
+		if _, err := PlatformDigest(index, platform); err != nil {
+			result = append(result, platform)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+func platformMatches(p v1.Platform, platform string) bool {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || p.OS != parts[0] || p.Architecture != parts[1] {
+		return false
+	}
+	return len(parts) < 3 || p.Variant == parts[2]
+}
+
+// Resolver caches image indexes returned by an IndexFetcher.
+type Resolver struct {
+	fetcher IndexFetcher
+	ttl     time.Duration
+	mut     sync.Mutex
+	cache   map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	index     *v1.IndexManifest
+	err       error
+	expiresAt time.Time
+}
+
+func NewResolver(fetcher IndexFetcher, ttl time.Duration) *Resolver {
+	return &Resolver{fetcher: fetcher, ttl: ttl, cache: make(map[string]cacheEntry)}
+}
+
+// Index returns the index of image:tag. Errors are cached as well, so that an unreachable registry does not slow
+// down every request.
+func (r *Resolver) Index(ctx context.Context, image, tag string) (*v1.IndexManifest, error) {
+	ref := image + ":" + tag
+	r.mut.Lock()
+	entry, ok := r.cache[ref]
+	r.mut.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.index, entry.err
+	}
+	index, err := r.fetcher.Index(ctx, ref)
+	entry = cacheEntry{index: index, err: err, expiresAt: time.Now().Add(r.ttl)}
+	if err != nil {
+		// retry failed lookups sooner
+		entry.expiresAt = time.Now().Add(r.ttl / 10)
+	}
+	r.mut.Lock()
+	r.cache[ref] = entry
+	r.mut.Unlock()
+	return index, err
+}
+
+// Digest returns the digest of image:tag for platform.
+func (r *Resolver) Digest(ctx context.Context, image, tag, platform string) (string, error) {
+	if index, err := r.Index(ctx, image, tag); err != nil {
+		return "", err
+	} else {
+		return PlatformDigest(index, platform)
+	}
+}
+
+// MissingPlatforms returns the platforms for which image:tag has no manifest.
+func (r *Resolver) MissingPlatforms(ctx context.Context, image, tag string, platforms []string) ([]string, error) {
+	if index, err := r.Index(ctx, image, tag); err != nil {
+		return nil, err
+	} else {
+		return MissingPlatforms(index, platforms), nil
+	}
+}
@@ -0,0 +1,116 @@
+package agentimage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/agentimage"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	amd64Digest = v1.Hash{Algorithm: "sha256", Hex: "1111111111111111111111111111111111111111111111111111111111111111"}
+	arm64Digest = v1.Hash{Algorithm: "sha256", Hex: "2222222222222222222222222222222222222222222222222222222222222222"}
+	armv7Digest = v1.Hash{Algorithm: "sha256", Hex: "3333333333333333333333333333333333333333333333333333333333333333"}
+
+	amd64OnlyIndex = &v1.IndexManifest{
+		MediaType: types.OCIImageIndex,
+		Manifests: []v1.Descriptor{
+			{Digest: amd64Digest, Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+		},
+	}
+	multiArchIndex = &v1.IndexManifest{
+		MediaType: types.OCIImageIndex,
+		Manifests: []v1.Descriptor{
+			{Digest: amd64Digest, Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+			{Digest: arm64Digest, Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}},
+			{Digest: armv7Digest, Platform: &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+			{Digest: v1.Hash{Algorithm: "sha256", Hex: "4444444444444444444444444444444444444444444444444444444444444444"},
+				Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}},
+		},
+	}
+)
+
+type fakeFetcher struct {
+	indexes map[string]*v1.IndexManifest
+	calls   int
+}
+
+func (f *fakeFetcher) Index(ctx context.Context, ref string) (*v1.IndexManifest, error) {
+	f.calls++
+	if index, ok := f.indexes[ref]; ok {
+		return index, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestParsePlatform(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(agentimage.ParsePlatform("linux/arm64")).To(Equal("linux/arm64"))
+	g.Expect(agentimage.ParsePlatform(" Linux/AMD64 ")).To(Equal("linux/amd64"))
+	g.Expect(agentimage.ParsePlatform("linux/arm/v7")).To(Equal("linux/arm/v7"))
+	for _, invalid := range []string{"", "linux", "linux/", "linux/arm/v7/x", "linux/$(id)"} {
+		_, err := agentimage.ParsePlatform(invalid)
+		g.Expect(err).To(MatchError(agentimage.ErrInvalidPlatform), invalid)
+	}
+	g.Expect(agentimage.Arch("linux/arm/v7")).To(Equal("arm"))
+	g.Expect(agentimage.Arch("linux")).To(BeEmpty())
+}
+
+func TestPlatformDigest(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(agentimage.PlatformDigest(multiArchIndex, "linux/amd64")).To(Equal(amd64Digest.String()))
+	g.Expect(agentimage.PlatformDigest(multiArchIndex, "linux/arm64")).To(Equal(arm64Digest.String()))
+	g.Expect(agentimage.PlatformDigest(multiArchIndex, "linux/arm/v7")).To(Equal(armv7Digest.String()))
+	g.Expect(agentimage.PlatformDigest(multiArchIndex, "linux/arm")).To(Equal(armv7Digest.String()))
+	_, err := agentimage.PlatformDigest(multiArchIndex, "linux/arm/v6")
+	g.Expect(err).To(MatchError(agentimage.ErrPlatformUnsupported))
+
+	g.Expect(agentimage.PlatformDigest(amd64OnlyIndex, "linux/amd64")).To(Equal(amd64Digest.String()))
+	_, err = agentimage.PlatformDigest(amd64OnlyIndex, "linux/arm64")
+	g.Expect(err).To(MatchError(agentimage.ErrPlatformUnsupported))
+
+	singleManifest := &v1.IndexManifest{Manifests: []v1.Descriptor{{Digest: amd64Digest}}}
+	_, err = agentimage.PlatformDigest(singleManifest, "linux/amd64")
+	g.Expect(err).To(MatchError(agentimage.ErrPlatformUnsupported), "manifest without platform must not match")
+}
+
+func TestMissingPlatforms(t *testing.T) {
+	g := NewWithT(t)
+	fleet := []string{"linux/arm64", "linux/amd64", "linux/arm64"}
+	g.Expect(agentimage.MissingPlatforms(multiArchIndex, fleet)).To(BeEmpty())
+	g.Expect(agentimage.MissingPlatforms(amd64OnlyIndex, fleet)).To(Equal([]string{"linux/arm64"}))
+	g.Expect(agentimage.MissingPlatforms(amd64OnlyIndex, nil)).To(BeEmpty())
+}
+
+func TestResolver(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	fetcher := &fakeFetcher{indexes: map[string]*v1.IndexManifest{
+		agentimage.DockerAgentImage + ":1.0.0":     amd64OnlyIndex,
+		agentimage.KubernetesAgentImage + ":1.0.0": multiArchIndex,
+	}}
+	resolver := agentimage.NewResolver(fetcher, time.Hour)
+
+	g.Expect(resolver.Digest(ctx, agentimage.KubernetesAgentImage, "1.0.0", "linux/arm64")).
+		To(Equal(arm64Digest.String()))
+	g.Expect(resolver.Digest(ctx, agentimage.KubernetesAgentImage, "1.0.0", "linux/amd64")).
+		To(Equal(amd64Digest.String()))
+	g.Expect(fetcher.calls).To(Equal(1), "index should be cached")
+
+	_, err := resolver.Digest(ctx, agentimage.DockerAgentImage, "1.0.0", "linux/arm64")
+	g.Expect(err).To(MatchError(agentimage.ErrPlatformUnsupported))
+	g.Expect(resolver.MissingPlatforms(ctx, agentimage.DockerAgentImage, "1.0.0", []string{"linux/arm64"})).
+		To(Equal([]string{"linux/arm64"}))
+	g.Expect(fetcher.calls).To(Equal(2))
+
+	_, err = resolver.Index(ctx, agentimage.DockerAgentImage, "2.0.0")
+	g.Expect(err).To(HaveOccurred())
+	_, err = resolver.Index(ctx, agentimage.DockerAgentImage, "2.0.0")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(fetcher.calls).To(Equal(3), "errors should be cached")
+}
@@ -0,0 +1,74 @@
+package agentimage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/registryclient"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	remoteTimeout = 5 * time.Second
+	defaultTTL    = time.Hour
+)
+
+var acceptedManifestTypes = []string{
+	string(types.OCIImageIndex),
+	string(types.DockerManifestList),
+	string(types.OCIManifestSchema1),
+	string(types.DockerManifestSchema2),
+}
+
+// remoteFetcher implements IndexFetcher by querying the registry of a reference anonymously
+// via the OCI distribution API.
+type remoteFetcher struct {
+	client *registryclient.Client
+}
+
+func (f remoteFetcher) Index(ctx context.Context, ref string) (*v1.IndexManifest, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+	parsed, err := name.NewTag(ref)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Manifest(ctx, http.MethodGet, parsed, acceptedManifestTypes, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status getting manifest %v: %v", ref, resp.Status)
+	}
+
+	mediaType := types.MediaType(resp.Header.Get("Content-Type"))
+	if mediaType.IsIndex() {
+		return v1.ParseIndexManifest(resp.Body)
+	}
+	// A single platform image does not declare its platform in the manifest. Callers must treat it as
+	// supporting no platform in particular.
+	digest, err := v1.NewHash(resp.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		return nil, fmt.Errorf("manifest of %v has no digest: %w", ref, err)
+	}
+	return &v1.IndexManifest{Manifests: []v1.Descriptor{{MediaType: mediaType, Digest: digest}}}, nil
+}
+
+var (
+	defaultResolver     *Resolver
+	defaultResolverOnce sync.Once
+)
+
+// DefaultResolver returns a Resolver that queries the agent image registry directly.
+func DefaultResolver() *Resolver {
+	defaultResolverOnce.Do(func() {
+		defaultResolver = NewResolver(remoteFetcher{client: registryclient.New(http.DefaultClient)}, defaultTTL)
+	})
+	return defaultResolver
+}
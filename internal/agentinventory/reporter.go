@@ -0,0 +1,78 @@
+// Package agentinventory reports the workloads that exist on the host of an agent to the server.
+package agentinventory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentclient"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// Interval is the minimum time between two inventory reports. The inventory changes rarely, so it is reported much
+// less often than the status of deployments.
+const Interval = 15 * time.Minute
+
+// ListFunc returns all workloads on the host and whether they are managed by the agent.
+type ListFunc func(ctx context.Context) ([]types.InventoryItem, error)
+
+// Reporter lists the workloads on the host and reports them, at most once per Interval.
+type Reporter struct {
+	client       *agentclient.Client
+	logger       *zap.Logger
+	mutex        sync.Mutex
+	lastReported time.Time
+}
+
+func NewReporter(client *agentclient.Client, logger *zap.Logger) *Reporter {
+	return &Reporter{client: client, logger: logger}
+}
+
+// ReportAsync reports the inventory returned by list in the background if enabled is true and the last report is older
+// than Interval. It does nothing if the previous report has not finished yet. If enabled is false, the next report
+// after it has been enabled again is sent immediately.
+func (r *Reporter) ReportAsync(ctx context.Context, enabled bool, list ListFunc) {
+	if !r.mutex.TryLock() {
+		r.logger.Debug("previous inventory report is still running")
+		return
+	}
+	if !enabled {
+		r.lastReported = time.Time{}
+		r.mutex.Unlock()
+		return
+	} else if time.Since(r.lastReported) < Interval {
+		r.mutex.Unlock()
+		return
+	}
+	go func() {
+		defer r.mutex.Unlock()
+		if err := r.report(ctx, list); err != nil {
+			r.logger.Warn("failed to report inventory", zap.Error(err))
+		} else {
+			r.lastReported = time.Now()
+		}
+	}()
+}
+
+func (r *Reporter) report(ctx context.Context, list ListFunc) error {
+	items, err := list(ctx)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(items, func(a, b types.InventoryItem) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Name, b.Name))
+	})
+	report := api.AgentInventoryReport{Items: items}
+	if len(items) > types.MaxInventoryItems {
+		r.logger.Warn("inventory exceeds the maximum number of items and has been truncated",
+			zap.Int("items", len(items)))
+		report.Items = items[:types.MaxInventoryItems]
+		report.Truncated = true
+	}
+	return r.client.ReportInventory(ctx, report)
+}
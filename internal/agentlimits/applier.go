@@ -0,0 +1,159 @@
+// Package agentlimits applies the resource limits that an agent receives from the server to the agent process and
+// reports the values that are in effect.
+package agentlimits
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentclient"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultMaxConcurrentPulls is used if the number of concurrent image pulls is not limited.
+const DefaultMaxConcurrentPulls = 1
+
+// Applier applies resource limits. Limits are only applied and reported again after they have changed.
+type Applier struct {
+	client *agentclient.Client
+	logger *zap.Logger
+	level  zap.AtomicLevel
+	// unsupported contains the names of the limits that the agent can not apply.
+	unsupported        []string
+	defaultLevel       zapcore.Level
+	defaultMaxProcs    int
+	niceLevel          int
+	maxConcurrentPulls atomic.Int32
+	mutex              sync.Mutex
+	last               *types.AgentResourceLimits
+}
+
+// NewApplier creates an Applier that changes the log level of level. The names of the limits that the agent does not
+// support are reported as unsupported if they are requested.
+func NewApplier(
+	client *agentclient.Client,
+	logger *zap.Logger,
+	level zap.AtomicLevel,
+	unsupported ...string,
+) *Applier {
+	a := &Applier{
+		client:          client,
+		logger:          logger,
+		level:           level,
+		unsupported:     unsupported,
+		defaultLevel:    level.Level(),
+		defaultMaxProcs: runtime.GOMAXPROCS(0),
+	}
+	if !niceSupported {
+		a.unsupported = append(a.unsupported, types.AgentResourceLimitNiceLevel)
+	}
+	a.maxConcurrentPulls.Store(DefaultMaxConcurrentPulls)
+	return a
+}
+
+// MaxConcurrentPulls returns the number of images that may be pulled at the same time.
+func (a *Applier) MaxConcurrentPulls() int {
+	return int(a.maxConcurrentPulls.Load())
+}
+
+// Apply applies limits and reports the applied values if limits differ from the limits of the previous call.
+// Limits that are not set are reset to the default of the agent.
+func (a *Applier) Apply(ctx context.Context, limits types.AgentResourceLimits) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.last != nil && reflect.DeepEqual(*a.last, limits) {
+		return
+	}
+	a.last = &limits
+
+	report := api.AgentResourceLimitsReport{Applied: a.apply(limits)}
+	for _, name := range a.unsupported {
+		if isSet(limits, name) {
+			report.Unsupported = append(report.Unsupported, name)
+		}
+	}
+	if len(report.Unsupported) > 0 {
+		a.logger.Warn("some resource limits are not supported by this agent and are ignored",
+			zap.Strings("unsupported", report.Unsupported))
+	}
+	// the report is not retried, because servers that do not support it would otherwise receive it on every poll
+	if err := a.client.ReportResourceLimits(ctx, report); err != nil {
+		a.logger.Warn("failed to report applied resource limits", zap.Error(err))
+	}
+}
+
+func (a *Applier) apply(limits types.AgentResourceLimits) types.AgentResourceLimits {
+	var applied types.AgentResourceLimits
+
+	if !slices.Contains(a.unsupported, types.AgentResourceLimitMaxConcurrentPulls) {
+		pulls := DefaultMaxConcurrentPulls
+		if limits.MaxConcurrentPulls != nil {
+			pulls = *limits.MaxConcurrentPulls
+		}
+		a.maxConcurrentPulls.Store(int32(pulls))
+		applied.MaxConcurrentPulls = &pulls
+	}
+
+	// MaxProcs is only a hint, the agent never uses more CPUs than are available to it
+	maxProcs := a.defaultMaxProcs
+	if limits.MaxProcs != nil {
+		maxProcs = min(*limits.MaxProcs, a.defaultMaxProcs)
+	}
+	runtime.GOMAXPROCS(maxProcs)
+	applied.MaxProcs = &maxProcs
+
+	if niceSupported {
+		niceLevel := 0
+		if limits.NiceLevel != nil {
+			niceLevel = *limits.NiceLevel
+		}
+		if niceLevel != a.niceLevel {
+			if err := setNiceLevel(niceLevel); err != nil {
+				// decreasing the nice level requires privileges that the agent usually does not have
+				a.logger.Warn("failed to set nice level", zap.Int("niceLevel", niceLevel), zap.Error(err))
+			} else {
+				a.niceLevel = niceLevel
+			}
+		}
+		applied.NiceLevel = util.PtrTo(a.niceLevel)
+	}
+
+	level := a.defaultLevel
+	if limits.LogLevel != nil {
+		if parsed, err := zapcore.ParseLevel(*limits.LogLevel); err != nil {
+			a.logger.Warn("invalid log level", zap.String("logLevel", *limits.LogLevel), zap.Error(err))
+		} else {
+			level = parsed
+		}
+	}
+	a.level.SetLevel(level)
+	applied.LogLevel = util.PtrTo(level.String())
+
+	a.logger.Info("applied resource limits", zap.Any("limits", applied))
+	return applied
+}
+
+func isSet(limits types.AgentResourceLimits, name string) bool {
+	switch name {
+	case types.AgentResourceLimitMaxConcurrentPulls:
+		return limits.MaxConcurrentPulls != nil
+	case types.AgentResourceLimitMaxDownloadBytesPerSecond:
+		return limits.MaxDownloadBytesPerSecond != nil
+	case types.AgentResourceLimitMaxProcs:
+		return limits.MaxProcs != nil
+	case types.AgentResourceLimitNiceLevel:
+		return limits.NiceLevel != nil
+	case types.AgentResourceLimitLogLevel:
+		return limits.LogLevel != nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package agentlimits
+
+import "errors"
+
+const niceSupported = false
+
+func setNiceLevel(int) error {
+	return errors.New("nice level is not supported on this platform")
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package agentlimits
+
+import "syscall"
+
+const niceSupported = true
+
+// setNiceLevel sets the nice level of all threads of the agent. On Linux, the nice level of a process only applies to
+// its main thread, so the process group is used instead.
+func setNiceLevel(niceLevel int) error {
+	return syscall.Setpriority(syscall.PRIO_PGRP, 0, niceLevel)
+}
@@ -9,11 +9,14 @@ import (
 	"path"
 	"text/template"
 
+	"github.com/glasskube/distr/internal/agentimage"
 	"github.com/glasskube/distr/internal/buildconfig"
+	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/customdomains"
 	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/resources"
 	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
 )
 
 func Get(
@@ -21,12 +24,16 @@ func Get(
 	deploymentTarget types.DeploymentTargetWithCreatedBy,
 	org types.Organization,
 	secret *string,
+	platform *string,
 ) (io.Reader, error) {
 	if tmpl, err := getTemplate(deploymentTarget); err != nil {
 		return nil, err
 	} else if data, err := getTemplateData(deploymentTarget, org, secret); err != nil {
 		return nil, err
 	} else {
+		if platform != nil {
+			addPlatformData(ctx, data, deploymentTarget, *platform)
+		}
 		var buf bytes.Buffer
 		return &buf, tmpl.Execute(&buf, data)
 	}
@@ -38,12 +45,17 @@ func getTemplateData(
 	secret *string,
 ) (map[string]any, error) {
 	var (
-		loginEndpoint     string
-		manifestEndpoint  string
-		resourcesEndpoint string
-		statusEndpoint    string
-		metricsEndpoint   string
-		logsEndpoint      string
+		loginEndpoint          string
+		manifestEndpoint       string
+		resourcesEndpoint      string
+		statusEndpoint         string
+		metricsEndpoint        string
+		logsEndpoint           string
+		connectivityEndpoint   string
+		appMetricsEndpoint     string
+		inventoryEndpoint      string
+		resourceLimitsEndpoint string
+		fileRetrievalsEndpoint string
 	)
 
 	if u, err := url.Parse(customdomains.AppDomainOrDefault(org)); err != nil {
@@ -56,24 +68,34 @@ func getTemplateData(
 		statusEndpoint = u.JoinPath("status").String()
 		metricsEndpoint = u.JoinPath("metrics").String()
 		logsEndpoint = u.JoinPath("logs").String()
+		connectivityEndpoint = u.JoinPath("connectivity").String()
+		appMetricsEndpoint = u.JoinPath("app-metrics").String()
+		inventoryEndpoint = u.JoinPath("inventory").String()
+		resourceLimitsEndpoint = u.JoinPath("resource-limits").String()
+		fileRetrievalsEndpoint = u.JoinPath("file-retrievals").String()
 	}
 
 	result := map[string]any{
-		"agentDockerConfig": base64.StdEncoding.EncodeToString(env.AgentDockerConfig()),
-		"agentInterval":     env.AgentInterval(),
-		"agentVersion":      deploymentTarget.AgentVersion.Name,
-		"agentVersionId":    deploymentTarget.AgentVersion.ID,
-		"loginEndpoint":     loginEndpoint,
-		"manifestEndpoint":  manifestEndpoint,
-		"metricsEndpoint":   metricsEndpoint,
-		"registryEnabled":   env.RegistryEnabled(),
-		"registryHost":      customdomains.RegistryDomainOrDefault(org),
-		"registryPlainHttp": buildconfig.IsDevelopment(),
-		"resourcesEndpoint": resourcesEndpoint,
-		"statusEndpoint":    statusEndpoint,
-		"targetId":          deploymentTarget.ID,
-		"targetSecret":      secret,
-		"logsEndpoint":      logsEndpoint,
+		"agentDockerConfig":      base64.StdEncoding.EncodeToString(env.AgentDockerConfig()),
+		"agentInterval":          env.AgentInterval(),
+		"agentVersion":           deploymentTarget.AgentVersion.Name,
+		"agentVersionId":         deploymentTarget.AgentVersion.ID,
+		"loginEndpoint":          loginEndpoint,
+		"manifestEndpoint":       manifestEndpoint,
+		"metricsEndpoint":        metricsEndpoint,
+		"registryEnabled":        env.RegistryEnabled(),
+		"registryHost":           customdomains.RegistryDomainOrDefault(org),
+		"registryPlainHttp":      buildconfig.IsDevelopment(),
+		"resourcesEndpoint":      resourcesEndpoint,
+		"statusEndpoint":         statusEndpoint,
+		"targetId":               deploymentTarget.ID,
+		"targetSecret":           secret,
+		"logsEndpoint":           logsEndpoint,
+		"connectivityEndpoint":   connectivityEndpoint,
+		"appMetricsEndpoint":     appMetricsEndpoint,
+		"inventoryEndpoint":      inventoryEndpoint,
+		"resourceLimitsEndpoint": resourceLimitsEndpoint,
+		"fileRetrievalsEndpoint": fileRetrievalsEndpoint,
 	}
 	if deploymentTarget.Namespace != nil {
 		result["targetNamespace"] = *deploymentTarget.Namespace
@@ -84,6 +106,26 @@ func getTemplateData(
 	return result, nil
 }
 
+// addPlatformData pins the agent image to the digest for platform.
+// If the digest can not be resolved, the manifest falls back to the multi-platform tag.
+func addPlatformData(
+	ctx context.Context,
+	data map[string]any,
+	deploymentTarget types.DeploymentTargetWithCreatedBy,
+	platform string,
+) {
+	data["agentPlatform"] = platform
+	data["agentArch"] = agentimage.Arch(platform)
+	if digest, err := agentimage.DefaultResolver().Digest(
+		ctx, agentimage.Image(deploymentTarget.Type), deploymentTarget.AgentVersion.Name, platform,
+	); err != nil {
+		internalctx.GetLogger(ctx).Warn("could not resolve agent image digest",
+			zap.String("platform", platform), zap.Error(err))
+	} else {
+		data["agentImageDigest"] = digest
+	}
+}
+
 func getTemplate(deploymentTarget types.DeploymentTargetWithCreatedBy) (*template.Template, error) {
 	if deploymentTarget.Type == types.DeploymentTypeDocker {
 		return resources.GetTemplate(path.Join(
@@ -0,0 +1,38 @@
+package agentmanifest
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/glasskube/distr/internal/customdomains"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+)
+
+// PairingURL returns the URL at which the agent manifest of a pre-registered deployment target can be fetched once
+// with pairingToken.
+func PairingURL(org types.Organization, targetID uuid.UUID, pairingToken string) (string, error) {
+	if u, err := url.Parse(customdomains.AppDomainOrDefault(org)); err != nil {
+		return "", err
+	} else {
+		query := url.Values{}
+		query.Set("targetId", targetID.String())
+		query.Set("pairingToken", pairingToken)
+		u = u.JoinPath("/api/v1/connect/pairing")
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+}
+
+// ConnectCommand returns the shell command that installs the agent of target from the manifest at connectURL. It
+// matches the command that is shown in the UI.
+func ConnectCommand(target types.DeploymentTarget, connectURL string) string {
+	if target.Type == types.DeploymentTypeDocker {
+		return fmt.Sprintf(`curl "%v&platform=$(docker version -f '{{.Server.Os}}/{{.Server.Arch}}')" | `+
+			`docker compose -f - up -d`, connectURL)
+	} else if target.Namespace != nil {
+		return fmt.Sprintf(`kubectl apply -n %v -f "%v"`, *target.Namespace, connectURL)
+	} else {
+		return fmt.Sprintf(`kubectl apply -f "%v"`, connectURL)
+	}
+}
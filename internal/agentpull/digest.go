@@ -0,0 +1,57 @@
+// Package agentpull verifies the digests of the images that the docker agent pulls.
+package agentpull
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	dockerclient "github.com/docker/docker/client"
+)
+
+// DigestClient is the part of the docker client that is needed to verify the digests of pulled images.
+type DigestClient interface {
+	DistributionInspect(ctx context.Context, image, encodedRegistryAuth string) (registry.DistributionInspect, error)
+	ImageInspect(
+		ctx context.Context,
+		image string,
+		opts ...dockerclient.ImageInspectOption,
+	) (image.InspectResponse, error)
+}
+
+// ResolveDigest returns the digest that the image must have after pulling. For an image that is referenced by digest,
+// this is the digest of the reference. A tag is resolved to the digest that the registry currently returns for it, so
+// that images referenced by tag are verified as well.
+func ResolveDigest(ctx context.Context, client DigestClient, name, auth string) (string, error) {
+	if digest, ok := pinnedDigest(name); ok {
+		return digest, nil
+	} else if inspect, err := client.DistributionInspect(ctx, name, auth); err != nil {
+		return "", fmt.Errorf("failed to resolve digest: %w", err)
+	} else if digest := inspect.Descriptor.Digest.String(); digest == "" {
+		return "", errors.New("failed to resolve digest: registry returned no digest")
+	} else {
+		return digest, nil
+	}
+}
+
+// VerifyDigest ensures that a pulled image actually has the given digest.
+func VerifyDigest(ctx context.Context, client DigestClient, name, digest string) error {
+	if inspect, err := client.ImageInspect(ctx, name); err != nil {
+		return err
+	} else if !slices.ContainsFunc(inspect.RepoDigests, func(d string) bool { return strings.HasSuffix(d, "@"+digest) }) {
+		return fmt.Errorf("digest of pulled image does not match %v", digest)
+	}
+	return nil
+}
+
+// pinnedDigest returns the digest of an image reference of the form "name@digest".
+func pinnedDigest(name string) (string, bool) {
+	if idx := strings.LastIndex(name, "@"); idx >= 0 {
+		return name[idx+1:], true
+	}
+	return "", false
+}
@@ -0,0 +1,87 @@
+package agentpull_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/glasskube/distr/internal/agentpull"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	tagDigest   = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	otherDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+type fakeClient struct {
+	distribution    registry.DistributionInspect
+	distributionErr error
+	distributionFor []string
+	repoDigests     []string
+	inspectErr      error
+}
+
+func (c *fakeClient) DistributionInspect(
+	ctx context.Context,
+	image, encodedRegistryAuth string,
+) (registry.DistributionInspect, error) {
+	c.distributionFor = append(c.distributionFor, image)
+	return c.distribution, c.distributionErr
+}
+
+func (c *fakeClient) ImageInspect(
+	ctx context.Context,
+	name string,
+	opts ...dockerclient.ImageInspectOption,
+) (image.InspectResponse, error) {
+	return image.InspectResponse{RepoDigests: c.repoDigests}, c.inspectErr
+}
+
+func distribution(d string) registry.DistributionInspect {
+	return registry.DistributionInspect{Descriptor: ocispec.Descriptor{Digest: digest.Digest(d)}}
+}
+
+func TestResolveDigestOfPinnedImage(t *testing.T) {
+	g := NewWithT(t)
+	client := &fakeClient{distributionErr: errors.New("unexpected")}
+	d, err := agentpull.ResolveDigest(context.Background(), client, "registry.example.com/app@"+tagDigest, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(d).To(Equal(tagDigest))
+	g.Expect(client.distributionFor).To(BeEmpty(), "pinned images are not resolved with the registry")
+}
+
+func TestResolveDigestOfTag(t *testing.T) {
+	g := NewWithT(t)
+	client := &fakeClient{distribution: distribution(tagDigest)}
+	d, err := agentpull.ResolveDigest(context.Background(), client, "registry.example.com/app:1.0.0", "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(d).To(Equal(tagDigest))
+	g.Expect(client.distributionFor).To(Equal([]string{"registry.example.com/app:1.0.0"}))
+}
+
+func TestResolveDigestOfTagFails(t *testing.T) {
+	g := NewWithT(t)
+	_, err := agentpull.ResolveDigest(context.Background(),
+		&fakeClient{distributionErr: errors.New("unauthorized")}, "registry.example.com/app:1.0.0", "")
+	g.Expect(err).To(MatchError(ContainSubstring("unauthorized")))
+	_, err = agentpull.ResolveDigest(context.Background(), &fakeClient{}, "registry.example.com/app:1.0.0", "")
+	g.Expect(err).To(MatchError(ContainSubstring("no digest")))
+}
+
+func TestVerifyDigest(t *testing.T) {
+	g := NewWithT(t)
+	client := &fakeClient{repoDigests: []string{"registry.example.com/app@" + tagDigest}}
+	g.Expect(agentpull.VerifyDigest(context.Background(), client, "registry.example.com/app:1.0.0", tagDigest)).
+		To(Succeed())
+	g.Expect(agentpull.VerifyDigest(context.Background(), client, "registry.example.com/app:1.0.0", otherDigest)).
+		To(MatchError(ContainSubstring("does not match")))
+	client.inspectErr = errors.New("no such image")
+	g.Expect(agentpull.VerifyDigest(context.Background(), client, "registry.example.com/app:1.0.0", tagDigest)).
+		To(MatchError(ContainSubstring("no such image")))
+}
@@ -0,0 +1,76 @@
+// Package aggregates recomputes the precomputed organization aggregates that are served by the API.
+package aggregates
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// Interval is the age after which an aggregate is recomputed even if no refresh was requested.
+	Interval time.Duration
+	// BatchSize is the number of organizations that are recomputed in one transaction.
+	BatchSize int
+}
+
+type Refresher struct {
+	opts Options
+}
+
+func NewRefresher(opts Options) *Refresher {
+	return &Refresher{opts: opts}
+}
+
+// Run recomputes all aggregates that are due, one aggregate after the other.
+//
+// Every batch is recomputed in its own short transaction that holds a lock for the aggregate, so that a run on another
+// instance skips the aggregate while a batch is in progress and continues with the remaining organizations otherwise.
+func (r *Refresher) Run(ctx context.Context) error {
+	var errs []error
+	for _, name := range types.AggregateNames {
+		if err := r.refresh(ctx, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Refresher) refresh(ctx context.Context, name types.AggregateName) error {
+	log := internalctx.GetLogger(ctx).With(zap.String("aggregate", string(name)))
+	processed := []uuid.UUID{}
+	for {
+		var locked bool
+		var orgIDs []uuid.UUID
+		err := db.RunTx(ctx, func(ctx context.Context) (err error) {
+			if locked, err = db.TryLockOrganizationAggregate(ctx, name); err != nil || !locked {
+				return err
+			}
+			orgIDs, err = db.GetDueOrganizationAggregateOrgIDs(ctx, name, r.opts.Interval, processed, r.opts.BatchSize)
+			if err != nil || len(orgIDs) == 0 {
+				return err
+			}
+			return db.RecomputeOrganizationAggregate(ctx, name, orgIDs)
+		})
+		if err != nil {
+			log.Warn("organization aggregate refresh failed", zap.Int("processed", len(processed)), zap.Error(err))
+			return err
+		} else if !locked {
+			log.Info("organization aggregate is being refreshed by another instance",
+				zap.Int("processed", len(processed)))
+			return nil
+		}
+		// organizations are excluded explicitly in case they were updated while the batch was running
+		processed = append(processed, orgIDs...)
+		if len(orgIDs) < r.opts.BatchSize {
+			log.Info("organization aggregate refresh finished", zap.Int("processed", len(processed)))
+			return nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package apiversion implements the versioning of the public API.
+//
+// Every version is a route group below /api/<version>. Handlers are shared between versions and only the shape of
+// responses differs: handlers call the helpers of this package or check FromContext to map their result to the DTOs
+// of the version that was requested. Routes of an older version that have a successor are marked with the Deprecation
+// and Sunset headers, and every authenticated request is counted per version and organization, so that it is known
+// when an older version can be removed.
+package apiversion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/glasskube/distr/internal/auth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type Version string
+
+const (
+	V1 Version = "v1"
+	V2 Version = "v2"
+)
+
+// V2ReleasedAt is the date at which V1 routes that have a successor in V2 became deprecated.
+var V2ReleasedAt = time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+
+// Path returns the absolute path of route in version v.
+func (v Version) Path(route string) string {
+	return "/api/" + string(v) + route
+}
+
+type contextKey struct{}
+
+// FromContext returns the version of the current request. Requests that were not routed through Middleware, for
+// example those of the agent, are treated as V1.
+func FromContext(ctx context.Context) Version {
+	if v, ok := ctx.Value(contextKey{}).(Version); ok {
+		return v
+	}
+	return V1
+}
+
+func WithVersion(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// Middleware adds v to the request context. Starting with V2, plain text error responses are replaced with the JSON
+// error envelope.
+func Middleware(v Version) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(WithVersion(r.Context(), v))
+			if v != V1 {
+				ew := &errorEnvelopeWriter{ResponseWriter: w}
+				defer ew.flush()
+				w = ew
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Deprecation describes a route that has a successor in a newer version.
+type Deprecation struct {
+	// DeprecatedAt is the date the successor was released.
+	DeprecatedAt time.Time
+	// Sunset is the date after which the route may be removed. It is not announced if it is nil.
+	Sunset *time.Time
+	// Successor is the absolute path of the route that replaces the deprecated one.
+	Successor string
+}
+
+// Deprecated sets the Deprecation (RFC 9745) and Sunset (RFC 8594) headers and links the successor of a route.
+func Deprecated(d Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", d.DeprecatedAt.Unix()))
+			if d.Sunset != nil {
+				w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			w.Header().Add("Link", fmt.Sprintf(`<%v>; rel="successor-version"`, d.Successor))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Usage counts requests per version and organization. It must be used after authentication, requests without an
+// authenticated user are not counted.
+func Usage(next http.Handler) http.Handler {
+	requests, _ := otel.Meter("github.com/glasskube/distr/internal/apiversion").Int64Counter(
+		"api.requests",
+		metric.WithDescription("Authenticated API requests by API version and organization"),
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if authInfo, err := auth.Authentication.Get(ctx); err == nil {
+			organizationID := ""
+			if orgID := authInfo.CurrentOrgID(); orgID != nil {
+				organizationID = orgID.String()
+			}
+			requests.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("api.version", string(FromContext(ctx))),
+				attribute.String("organization.id", organizationID),
+			))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,99 @@
+package apiversion_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apiversion"
+	. "github.com/onsi/gomega"
+)
+
+func serve(handler http.Handler, middlewares ...func(http.Handler) http.Handler) *httptest.ResponseRecorder {
+	for _, m := range middlewares {
+		handler = m(handler)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	g := NewWithT(t)
+	var version apiversion.Version
+	recordVersion := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = apiversion.FromContext(r.Context())
+	})
+
+	serve(recordVersion)
+	g.Expect(version).To(Equal(apiversion.V1))
+	serve(recordVersion, apiversion.Middleware(apiversion.V2))
+	g.Expect(version).To(Equal(apiversion.V2))
+
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+	})
+	w := serve(notFound, apiversion.Middleware(apiversion.V1))
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+	g.Expect(w.Body.String()).To(Equal("artifact not found\n"))
+
+	w = serve(notFound, apiversion.Middleware(apiversion.V2))
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+	g.Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+	var response api.ErrorResponse
+	g.Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+	g.Expect(response.Error).To(Equal(api.Error{Code: "not_found", Message: "artifact not found"}))
+
+	w = serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}), apiversion.Middleware(apiversion.V2))
+	g.Expect(w.Code).To(Equal(http.StatusInternalServerError))
+	g.Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+	g.Expect(response.Error).To(Equal(api.Error{Code: "internal_server_error", Message: "Internal Server Error"}))
+
+	w = serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"custom":true}`))
+	}), apiversion.Middleware(apiversion.V2))
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(w.Body.String()).To(Equal(`{"custom":true}`))
+}
+
+func TestDeprecated(t *testing.T) {
+	g := NewWithT(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	deprecation := apiversion.Deprecation{
+		DeprecatedAt: time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC),
+		Successor:    apiversion.V2.Path("/user-accounts"),
+	}
+
+	w := serve(ok, apiversion.Deprecated(deprecation))
+	g.Expect(w.Header().Get("Deprecation")).To(Equal("@1792108800"))
+	g.Expect(w.Header().Get("Sunset")).To(BeEmpty())
+	g.Expect(w.Header().Get("Link")).To(Equal(`</api/v2/user-accounts>; rel="successor-version"`))
+
+	sunset := time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+	deprecation.Sunset = &sunset
+	w = serve(ok, apiversion.Deprecated(deprecation))
+	g.Expect(w.Header().Get("Sunset")).To(Equal("Thu, 01 Apr 2027 00:00:00 GMT"))
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	g := NewWithT(t)
+	doc, err := apiversion.OpenAPIDocument(apiversion.V2)
+	g.Expect(err).NotTo(HaveOccurred())
+	var parsed struct {
+		Info  struct{ Version string }
+		Paths map[string]any
+	}
+	g.Expect(json.Unmarshal(doc, &parsed)).To(Succeed())
+	g.Expect(parsed.Info.Version).To(Equal(string(apiversion.V2)))
+	g.Expect(parsed.Paths).To(HaveKey("/deployment-targets"))
+
+	_, err = apiversion.OpenAPIDocument(apiversion.V1)
+	g.Expect(err).To(HaveOccurred())
+}
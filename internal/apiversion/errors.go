@@ -0,0 +1,86 @@
+package apiversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/glasskube/distr/api"
+)
+
+// errorEnvelopeWriter buffers plain text error responses, as written by http.Error, and replaces them with an
+// api.ErrorResponse when flush is called. All other responses are passed through unchanged.
+type errorEnvelopeWriter struct {
+	http.ResponseWriter
+	status int
+	buf    *bytes.Buffer
+}
+
+func (w *errorEnvelopeWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status >= http.StatusBadRequest && isPlainText(w.Header().Get("Content-Type")) {
+		w.buf = &bytes.Buffer{}
+	} else {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *errorEnvelopeWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buf != nil {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *errorEnvelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *errorEnvelopeWriter) flush() {
+	if w.buf == nil {
+		return
+	}
+	message := strings.TrimSpace(w.buf.String())
+	if message == "" {
+		message = http.StatusText(w.status)
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Del("X-Content-Type-Options")
+	w.Header().Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.status)
+	_ = json.NewEncoder(w.ResponseWriter).Encode(api.ErrorResponse{
+		Error: api.Error{Code: ErrorCode(w.status), Message: message},
+	})
+}
+
+// ErrorCode returns a machine readable code for status, for example "not_found" for 404.
+func ErrorCode(status int) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		case r == ' ' || r == '-':
+			return '_'
+		default:
+			return -1
+		}
+	}, http.StatusText(status))
+}
+
+func isPlainText(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/plain"
+}
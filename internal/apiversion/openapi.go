@@ -0,0 +1,26 @@
+package apiversion
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed openapi/*.json
+var openAPIDocuments embed.FS
+
+// OpenAPIDocument returns the OpenAPI document of version v. V1 has no document.
+func OpenAPIDocument(v Version) ([]byte, error) {
+	return openAPIDocuments.ReadFile("openapi/" + string(v) + ".json")
+}
+
+// OpenAPIHandler serves the OpenAPI document of version v.
+func OpenAPIHandler(v Version) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if doc, err := OpenAPIDocument(v); err != nil {
+			http.NotFound(w, r)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(doc)
+		}
+	}
+}
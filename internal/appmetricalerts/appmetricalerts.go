@@ -0,0 +1,105 @@
+// Package appmetricalerts evaluates the metric alert rules of applications whenever an agent relays new metrics and
+// notifies vendors and the owner of the deployment target when a rule starts firing.
+package appmetricalerts
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/appmetrics"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Process evaluates all alert rules of the application of deployment with the latest series and saves the new alert
+// states. Notification errors are only logged.
+func Process(
+	ctx context.Context,
+	target types.DeploymentTargetWithCreatedBy,
+	deployment types.DeploymentWithLatestRevision,
+	series []types.AppMetricSeries,
+) error {
+	rules, err := db.GetApplicationMetricAlertRules(ctx, deployment.ApplicationID)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	alerts, err := db.GetDeploymentAppMetricAlerts(ctx, deployment.ID)
+	if err != nil {
+		return err
+	}
+	previous := make(map[uuid.UUID]types.DeploymentAppMetricAlert, len(alerts))
+	for _, alert := range alerts {
+		previous[alert.ApplicationMetricAlertRuleID] = alert
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		alert, ok := previous[rule.ID]
+		if !ok {
+			alert = types.DeploymentAppMetricAlert{ApplicationMetricAlertRuleID: rule.ID, DeploymentID: deployment.ID}
+		}
+		next, fired := appmetrics.Evaluate(rule, alert, series, now)
+		if changed(alert, next) {
+			if err := db.SaveDeploymentAppMetricAlert(ctx, next); err != nil {
+				return err
+			}
+		}
+		if fired {
+			if err := notify(ctx, target, deployment, rule); err != nil {
+				internalctx.GetLogger(ctx).Warn("could not send app metric alert notification",
+					zap.Stringer("ruleId", rule.ID), zap.Error(err))
+			}
+		}
+	}
+	return nil
+}
+
+func changed(a, b types.DeploymentAppMetricAlert) bool {
+	return (a.PendingSince == nil) != (b.PendingSince == nil) || (a.FiringSince == nil) != (b.FiringSince == nil)
+}
+
+// notify informs all vendor users of the organization and the customer that owns the deployment target about a
+// firing rule.
+func notify(
+	ctx context.Context,
+	target types.DeploymentTargetWithCreatedBy,
+	deployment types.DeploymentWithLatestRevision,
+	rule types.ApplicationMetricAlertRule,
+) error {
+	org, err := db.GetOrganizationWithBranding(ctx, target.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, target.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, 0, len(users)+1)
+	for _, user := range users {
+		recipients = append(recipients, user.Email)
+	}
+	if target.CreatedBy != nil && target.CreatedBy.UserRole == types.UserRoleCustomer {
+		recipients = append(recipients, target.CreatedBy.Email)
+	}
+	mailer := internalctx.GetMailer(ctx)
+	var errs []error
+	for _, email := range recipients {
+		errs = append(errs, mailer.Send(ctx, mail.New(
+			mail.To(email),
+			mail.Subject("Alert for "+deployment.ApplicationName+" on "+target.Name+" is firing"),
+			mail.Type(types.MailTypeAppMetricAlertFiring),
+			mail.HtmlBodyTemplate(
+				mailtemplates.AppMetricAlertFiring(*org, target.Name, deployment.ApplicationName, rule),
+			),
+			mail.Organization(target.OrganizationID),
+		)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,196 @@
+// Package appmetrics scrapes Prometheus metrics endpoints of deployed applications and evaluates the alert rules that
+// vendors define on the relayed series.
+//
+// Only the Prometheus text exposition format is supported. Series are filtered by the allow-list of the application
+// version and capped, so that applications exposing many series can not overload the agent or the server.
+package appmetrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+)
+
+const (
+	scrapeTimeout = 10 * time.Second
+	// maxResponseSize limits how much of a metrics response is read, regardless of how many series are allowed
+	maxResponseSize = 4 * 1024 * 1024
+	// MaxSeriesPerScrape is the maximum number of series an agent relays to the server for a single deployment.
+	MaxSeriesPerScrape = 500
+)
+
+var errInvalidLine = errors.New("invalid metrics line")
+
+// Scrape fetches url and returns at most limit series that are allowed by endpoint.
+func Scrape(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	endpoint types.ApplicationMetricsEndpoint,
+	limit int,
+) ([]types.AppMetricSeries, error) {
+	ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+	return Parse(io.LimitReader(resp.Body, maxResponseSize), endpoint, limit)
+}
+
+// Parse reads metrics in the Prometheus text exposition format and returns at most limit series that are allowed by
+// endpoint. Comments, timestamps and samples with non-finite values are ignored.
+func Parse(r io.Reader, endpoint types.ApplicationMetricsEndpoint, limit int) ([]types.AppMetricSeries, error) {
+	var result []types.AppMetricSeries
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() && len(result) < limit {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest := splitName(line)
+		if !endpoint.Allows(name) {
+			continue
+		}
+		series, err := parseSample(name, rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", err, line)
+		} else if !math.IsNaN(series.Value) && !math.IsInf(series.Value, 0) {
+			result = append(result, series)
+		}
+	}
+	return result, scanner.Err()
+}
+
+func splitName(line string) (string, string) {
+	if i := strings.IndexAny(line, "{ \t"); i >= 0 {
+		return line[:i], line[i:]
+	}
+	return line, ""
+}
+
+func parseSample(name, rest string) (types.AppMetricSeries, error) {
+	series := types.AppMetricSeries{Name: name}
+	if strings.HasPrefix(rest, "{") {
+		labels, next, err := parseLabels(rest[1:])
+		if err != nil {
+			return series, err
+		}
+		series.Labels = labels
+		rest = next
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return series, errInvalidLine
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return series, errInvalidLine
+	}
+	series.Value = value
+	return series, nil
+}
+
+// parseLabels parses the labels of a sample up to the closing brace and returns the remainder of the line.
+func parseLabels(s string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if strings.HasPrefix(s, "}") {
+			return labels, s[1:], nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || len(s) < eq+2 || s[eq+1] != '"' {
+			return nil, "", errInvalidLine
+		}
+		key := strings.TrimSpace(s[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+			} else {
+				value.WriteByte(s[i])
+			}
+		}
+		if i >= len(s) {
+			return nil, "", errInvalidLine
+		}
+		labels[key] = value.String()
+		s = s[i+1:]
+	}
+}
+
+// Filter returns at most limit series of series that are allowed by endpoint. Series reported by agents are filtered
+// again on the server, because the allow-list of an application version might have changed since the agent scraped
+// the endpoint.
+func Filter(
+	series []types.AppMetricSeries,
+	endpoint types.ApplicationMetricsEndpoint,
+	limit int,
+) []types.AppMetricSeries {
+	result := make([]types.AppMetricSeries, 0, min(len(series), limit))
+	for _, s := range series {
+		if len(result) >= limit {
+			break
+		} else if endpoint.Allows(s.Name) && !math.IsNaN(s.Value) && !math.IsInf(s.Value, 0) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Evaluate updates the state of rule for a deployment with the latest series and returns the new state.
+// The condition holds if any series of the rule metric satisfies it. The returned bool is true if the rule started
+// firing with this evaluation, i.e. the condition has held for at least the duration of the rule.
+func Evaluate(
+	rule types.ApplicationMetricAlertRule,
+	alert types.DeploymentAppMetricAlert,
+	series []types.AppMetricSeries,
+	now time.Time,
+) (types.DeploymentAppMetricAlert, bool) {
+	holds := false
+	for _, s := range series {
+		if s.Name == rule.Metric && rule.Matches(s.Value) {
+			holds = true
+			break
+		}
+	}
+	if !holds {
+		alert.PendingSince = nil
+		alert.FiringSince = nil
+		return alert, false
+	}
+	if alert.PendingSince == nil {
+		alert.PendingSince = &now
+	}
+	if alert.FiringSince == nil && !now.Before(alert.PendingSince.Add(rule.For())) {
+		alert.FiringSince = &now
+		return alert, true
+	}
+	return alert, false
+}
@@ -0,0 +1,105 @@
+package appmetrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/appmetrics"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+const exposition = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+queue_length 12
+queue_length_max NaN
+process_start_time_seconds 1.7e+09
+escaped{path="C:\\dir\\",msg="say \"hi\"\n"} 1
+`
+
+func TestParse(t *testing.T) {
+	g := NewWithT(t)
+	endpoint := types.ApplicationMetricsEndpoint{Series: []string{"http_requests_total", "queue_length", "escaped"}}
+	series, err := appmetrics.Parse(strings.NewReader(exposition), endpoint, 10)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(series).To(Equal([]types.AppMetricSeries{
+		{Name: "http_requests_total", Labels: map[string]string{"method": "post", "code": "200"}, Value: 1027},
+		{Name: "http_requests_total", Labels: map[string]string{"method": "post", "code": "400"}, Value: 3},
+		{Name: "queue_length", Value: 12},
+		{Name: "escaped", Labels: map[string]string{"path": `C:\dir\`, "msg": "say \"hi\"\n"}, Value: 1},
+	}))
+}
+
+func TestParseLimit(t *testing.T) {
+	g := NewWithT(t)
+	endpoint := types.ApplicationMetricsEndpoint{Series: []string{"http_requests_total", "queue_length"}}
+	series, err := appmetrics.Parse(strings.NewReader(exposition), endpoint, 2)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(series).To(HaveLen(2))
+	g.Expect(series).To(HaveEach(HaveField("Name", "http_requests_total")))
+}
+
+func TestParseInvalid(t *testing.T) {
+	g := NewWithT(t)
+	endpoint := types.ApplicationMetricsEndpoint{Series: []string{"queue_length"}}
+	_, err := appmetrics.Parse(strings.NewReader(`queue_length{a="b} 1`), endpoint, 10)
+	g.Expect(err).To(HaveOccurred())
+	_, err = appmetrics.Parse(strings.NewReader(`queue_length abc`), endpoint, 10)
+	g.Expect(err).To(HaveOccurred())
+	_, err = appmetrics.Parse(strings.NewReader(`other_metric abc`), endpoint, 10)
+	g.Expect(err).NotTo(HaveOccurred(), "lines of metrics that are not allowed are not parsed")
+}
+
+func TestFilter(t *testing.T) {
+	g := NewWithT(t)
+	endpoint := types.ApplicationMetricsEndpoint{Series: []string{"a"}}
+	series := []types.AppMetricSeries{{Name: "a", Value: 1}, {Name: "b", Value: 2}, {Name: "a", Value: 3}}
+	g.Expect(appmetrics.Filter(series, endpoint, 10)).To(Equal([]types.AppMetricSeries{
+		{Name: "a", Value: 1}, {Name: "a", Value: 3},
+	}))
+	g.Expect(appmetrics.Filter(series, endpoint, 1)).To(HaveLen(1))
+}
+
+func TestEvaluate(t *testing.T) {
+	g := NewWithT(t)
+	rule := types.ApplicationMetricAlertRule{
+		Metric:     "queue_length",
+		Operator:   types.AppMetricAlertOperatorGreaterThan,
+		Value:      100,
+		ForSeconds: 60,
+	}
+	high := []types.AppMetricSeries{{Name: "queue_length", Value: 150}}
+	low := []types.AppMetricSeries{{Name: "queue_length", Value: 50}}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	alert, fired := appmetrics.Evaluate(rule, types.DeploymentAppMetricAlert{}, high, start)
+	g.Expect(fired).To(BeFalse())
+	g.Expect(alert.PendingSince).To(HaveValue(Equal(start)))
+	g.Expect(alert.FiringSince).To(BeNil())
+
+	alert, fired = appmetrics.Evaluate(rule, alert, high, start.Add(time.Minute))
+	g.Expect(fired).To(BeTrue())
+	g.Expect(alert.FiringSince).To(HaveValue(Equal(start.Add(time.Minute))))
+
+	alert, fired = appmetrics.Evaluate(rule, alert, high, start.Add(2*time.Minute))
+	g.Expect(fired).To(BeFalse(), "a firing rule only fires once")
+
+	alert, fired = appmetrics.Evaluate(rule, alert, low, start.Add(3*time.Minute))
+	g.Expect(fired).To(BeFalse())
+	g.Expect(alert.PendingSince).To(BeNil())
+	g.Expect(alert.FiringSince).To(BeNil())
+
+	_, fired = appmetrics.Evaluate(rule, types.DeploymentAppMetricAlert{}, nil, start)
+	g.Expect(fired).To(BeFalse(), "missing metrics do not satisfy the condition")
+}
+
+func TestEvaluateWithoutDuration(t *testing.T) {
+	g := NewWithT(t)
+	rule := types.ApplicationMetricAlertRule{Metric: "up", Operator: types.AppMetricAlertOperatorEqual, Value: 0}
+	_, fired := appmetrics.Evaluate(rule, types.DeploymentAppMetricAlert{},
+		[]types.AppMetricSeries{{Name: "up", Value: 0}}, time.Now())
+	g.Expect(fired).To(BeTrue())
+}
@@ -0,0 +1,141 @@
+// Package appversion interprets the names of application versions as semantic versions.
+//
+// Versions are ordered by their semantic version. Versions whose names are not semantic versions can not be compared
+// with each other, so they are ordered by their creation time and sort before all semantic versions. Versions with
+// the same precedence (e.g. "1.0.0" and "v1.0.0") are ordered by their creation time as well. The database uses the
+// same order with the semver_sort_key function.
+package appversion
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/glasskube/distr/internal/types"
+)
+
+type Bump string
+
+const (
+	BumpPatch Bump = "patch"
+	BumpMinor Bump = "minor"
+	BumpMajor Bump = "major"
+)
+
+var (
+	ErrNotSemver     = errors.New("version name is not a semantic version")
+	ErrNotIncreasing = errors.New("version is not higher than the highest existing version")
+)
+
+// Parse returns the semantic version of name or nil if name is not a semantic version.
+func Parse(name string) *semver.Version {
+	if v, err := semver.NewVersion(name); err == nil {
+		return v
+	}
+	return nil
+}
+
+// Compare orders application versions as described in the package documentation.
+func Compare(a, b types.ApplicationVersion) int {
+	va, vb := Parse(a.Name), Parse(b.Name)
+	switch {
+	case va == nil && vb != nil:
+		return -1
+	case va != nil && vb == nil:
+		return 1
+	case va != nil:
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+	}
+	return a.CreatedAt.Compare(b.CreatedAt)
+}
+
+// Sort sorts versions in place from the lowest to the highest version.
+func Sort(versions []types.ApplicationVersion) {
+	slices.SortStableFunc(versions, Compare)
+}
+
+// Highest returns the highest version name of versions that is a semantic version, including archived versions.
+// It returns nil if there is no such version.
+func Highest(versions []types.ApplicationVersion) *string {
+	var highest *semver.Version
+	var highestName string
+	for _, version := range versions {
+		if v := Parse(version.Name); v != nil && (highest == nil || v.GreaterThan(highest)) {
+			highest = v
+			highestName = version.Name
+		}
+	}
+	if highest == nil {
+		return nil
+	}
+	return &highestName
+}
+
+// Check returns ErrNotSemver if name is not a semantic version and ErrNotIncreasing if it is not higher than highest.
+func Check(name string, highest *string) error {
+	if v := Parse(name); v == nil {
+		return ErrNotSemver
+	} else if highest != nil && !v.GreaterThan(Parse(*highest)) {
+		return fmt.Errorf("%w: %v is not higher than %v", ErrNotIncreasing, name, *highest)
+	}
+	return nil
+}
+
+// Next returns the version that follows highest when it is bumped. If highest is nil, the bump is applied to 0.0.0.
+// Pre-release versions are bumped to their release, e.g. a patch bump of 1.3.0-rc.1 returns 1.3.0. A "v" prefix of
+// highest is kept.
+func Next(highest *string, bump Bump) (string, error) {
+	current := semver.New(0, 0, 0, "", "")
+	prefix := ""
+	if highest != nil {
+		if current = Parse(*highest); current == nil {
+			return "", ErrNotSemver
+		}
+		if strings.HasPrefix(*highest, "v") {
+			prefix = "v"
+		}
+	}
+	var next semver.Version
+	switch bump {
+	case BumpPatch:
+		next = current.IncPatch()
+	case BumpMinor:
+		next = current.IncMinor()
+	case BumpMajor:
+		next = current.IncMajor()
+	default:
+		return "", fmt.Errorf("invalid bump: %v", bump)
+	}
+	return prefix + next.String(), nil
+}
+
+// ParseBump returns the Bump named by value. An empty value defaults to BumpPatch.
+func ParseBump(value string) (Bump, error) {
+	switch bump := cmp.Or(Bump(value), BumpPatch); bump {
+	case BumpPatch, BumpMinor, BumpMajor:
+		return bump, nil
+	default:
+		return "", fmt.Errorf("invalid bump: %v", value)
+	}
+}
+
+// Matching returns the versions whose names are semantic versions that satisfy constraint, e.g. "< 2.0.0" or
+// "1.x". Versions whose names are not semantic versions never match.
+func Matching(versions []types.ApplicationVersion, constraint string) ([]types.ApplicationVersion, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint: %w", err)
+	}
+	var result []types.ApplicationVersion
+	for _, version := range versions {
+		if v := Parse(version.Name); v != nil && c.Check(v) {
+			result = append(result, version)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package appversion_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/appversion"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestSort(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	version := func(name string, age time.Duration) types.ApplicationVersion {
+		return types.ApplicationVersion{Name: name, CreatedAt: now.Add(-age)}
+	}
+	versions := []types.ApplicationVersion{
+		version("1.2.10", 1*time.Hour),
+		version("latest", 2*time.Hour),
+		version("1.2.9", 3*time.Hour),
+		version("v1.2.9", 4*time.Hour),
+		version("1.3.0-rc.1", 5*time.Hour),
+		version("nightly", 6*time.Hour),
+	}
+	appversion.Sort(versions)
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.Name
+	}
+	g.Expect(names).To(Equal([]string{"nightly", "latest", "v1.2.9", "1.2.9", "1.2.10", "1.3.0-rc.1"}))
+	g.Expect(appversion.Highest(versions)).To(Equal(util.PtrTo("1.3.0-rc.1")))
+	g.Expect(appversion.Highest(versions[:2])).To(BeNil())
+}
+
+func TestCheck(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(appversion.Check("1.2.10", util.PtrTo("1.2.9"))).To(Succeed())
+	g.Expect(appversion.Check("1.0.0", nil)).To(Succeed())
+	g.Expect(appversion.Check("latest", nil)).To(MatchError(appversion.ErrNotSemver))
+	g.Expect(appversion.Check("1.2.1", util.PtrTo("1.2.9"))).To(MatchError(appversion.ErrNotIncreasing))
+	g.Expect(appversion.Check("v1.2.9", util.PtrTo("1.2.9"))).To(MatchError(appversion.ErrNotIncreasing))
+}
+
+func TestNext(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(appversion.Next(util.PtrTo("1.2.9"), appversion.BumpPatch)).To(Equal("1.2.10"))
+	g.Expect(appversion.Next(util.PtrTo("v1.2.9"), appversion.BumpMinor)).To(Equal("v1.3.0"))
+	g.Expect(appversion.Next(util.PtrTo("1.2.9"), appversion.BumpMajor)).To(Equal("2.0.0"))
+	g.Expect(appversion.Next(util.PtrTo("1.3.0-rc.1"), appversion.BumpPatch)).To(Equal("1.3.0"))
+	g.Expect(appversion.Next(nil, appversion.BumpMinor)).To(Equal("0.1.0"))
+	_, err := appversion.Next(nil, "huge")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestParseBump(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(appversion.ParseBump("")).To(Equal(appversion.BumpPatch))
+	g.Expect(appversion.ParseBump("major")).To(Equal(appversion.BumpMajor))
+	_, err := appversion.ParseBump("huge")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestMatching(t *testing.T) {
+	g := NewWithT(t)
+	versions := []types.ApplicationVersion{{Name: "1.2.0"}, {Name: "v1.9.3"}, {Name: "2.0.0"}, {Name: "latest"}}
+	matching, err := appversion.Matching(versions, "< 2.0.0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matching).To(Equal(versions[:2]))
+	g.Expect(appversion.Matching(versions, "3.x")).To(BeEmpty())
+	_, err = appversion.Matching(versions, "not a constraint")
+	g.Expect(err).To(HaveOccurred())
+}
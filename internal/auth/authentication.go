@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/internal/authjwt"
@@ -11,11 +13,13 @@ import (
 	"github.com/glasskube/distr/internal/authn/jwt"
 	"github.com/glasskube/distr/internal/authn/token"
 	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/env"
 	"go.uber.org/zap"
 )
 
 // Authentication supports Bearer (classic JWT) and AccessToken (PAT) headers and uses authinfo.DbAuthenticator
 // to verify the token against the database, thereby ensuring the user exists in the database.
+// Access tokens that are limited to registry scopes are only accepted by ArtifactsAuthentication.
 var Authentication = authn.New(
 	authn.Chain4(
 		token.NewExtractor(token.WithExtractorFuncs(token.FromHeader("Bearer"))),
@@ -26,7 +30,7 @@ var Authentication = authn.New(
 	authn.Chain4(
 		token.NewExtractor(token.WithExtractorFuncs(token.FromHeader("AccessToken"))),
 		authkey.Authenticator(),
-		authinfo.AuthKeyAuthenticator(),
+		authinfo.UnscopedAuthKeyAuthenticator(),
 		authinfo.DbAuthenticator(),
 	),
 )
@@ -43,11 +47,13 @@ var AgentAuthentication = authn.New(
 
 // ArtifactsAuthentication supports Basic auth login for OCI clients, where the password should be a PAT.
 // The given PAT is verified against the database, to make sure that the user still exists.
+// Clients that follow the challenge get a Bearer token from the token endpoint of the registry instead, which is either
+// the credential they have presented there or an anonymous token for pulling public artifacts.
 var ArtifactsAuthentication = authn.New(
 	authn.Chain(
 		token.NewExtractor(
-			token.WithExtractorFuncs(token.FromBasicAuth()),
-			token.WithErrorHeaders(http.Header{"WWW-Authenticate": []string{"Basic realm=\"Distr\""}}),
+			token.WithExtractorFuncs(token.FromBasicAuth(), token.FromHeader("Bearer")),
+			token.WithErrorHeadersFunc(registryChallenge),
 		),
 		authn.Alternative(
 			// Authenticate UserAccount with PAT
@@ -56,6 +62,12 @@ var ArtifactsAuthentication = authn.New(
 				authinfo.AuthKeyAuthenticator(),
 				authinfo.DbAuthenticator(),
 			),
+			// Authenticate anonymous client with a pull token for public artifacts
+			authn.Chain3(
+				jwt.Authenticator(authjwt.JWTAuth),
+				authinfo.AnonymousJWTAuthenticator(),
+				authinfo.AnonymousDbAuthenticator(),
+			),
 			// Authenticate with Agent JWT
 			authn.Chain3(
 				jwt.Authenticator(authjwt.JWTAuth),
@@ -66,6 +78,21 @@ var ArtifactsAuthentication = authn.New(
 	),
 )
 
+// RegistryTokenPath is the path of the token endpoint of the registry.
+const RegistryTokenPath = "/v2/token"
+
+// registryChallenge points OCI clients at the token endpoint of the registry host that they have sent r to.
+func registryChallenge(r *http.Request) http.Header {
+	scheme := "https"
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	} else if r.TLS == nil && strings.HasPrefix(env.Host(), "http://") {
+		scheme = "http"
+	}
+	challenge := fmt.Sprintf(`Bearer realm="%v://%v%v",service="%v"`, scheme, r.Host, RegistryTokenPath, r.Host)
+	return http.Header{"WWW-Authenticate": []string{challenge}}
+}
+
 func handleUnknownError(w http.ResponseWriter, r *http.Request, err error) {
 	internalctx.GetLogger(r.Context()).Error("error authenticating request", zap.Error(err))
 	sentry.GetHubFromContext(r.Context()).CaptureException(err)
@@ -2,10 +2,12 @@ package authjwt
 
 import (
 	"maps"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/clock"
 	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/go-chi/jwtauth/v5"
@@ -25,9 +27,11 @@ const (
 	UserImageURLKey      = "image_url"
 	OrgIdKey             = "org"
 	PasswordResetKey     = "password_reset"
+	PullScopeKey         = "pull_scope"
 
-	audienceUserValue  = "user"
-	audienceAgentValue = "agent"
+	audienceUserValue      = "user"
+	audienceAgentValue     = "agent"
+	audienceAnonymousValue = "anonymous"
 )
 
 // JWTAuth is for generating/validating JWTs.
@@ -36,7 +40,7 @@ const (
 //
 // TODO: Maybe migrate to asymmetric encryption at some point.
 var JWTAuth = sync.OnceValue(func() *jwtauth.JWTAuth {
-	return jwtauth.New("HS256", env.JWTSecret(), nil)
+	return jwtauth.New("HS256", env.JWTSecret(), nil, jwt.WithClock(jwt.ClockFunc(clock.Now)))
 })
 
 func GenerateDefaultToken(user types.UserAccount, org types.OrganizationWithUserRole) (jwt.Token, string, error) {
@@ -60,7 +64,7 @@ func generateUserToken(
 	validFor time.Duration,
 	extraClaims map[string]any,
 ) (jwt.Token, string, error) {
-	now := time.Now()
+	now := clock.Now()
 	claims := map[string]any{
 		jwt.IssuedAtKey:      now,
 		jwt.NotBeforeKey:     now,
@@ -83,7 +87,7 @@ func generateUserToken(
 }
 
 func GenerateAgentTokenValidFor(targetID, orgID uuid.UUID, validFor time.Duration) (jwt.Token, string, error) {
-	now := time.Now()
+	now := clock.Now()
 	claims := map[string]any{
 		jwt.IssuedAtKey:   now,
 		jwt.NotBeforeKey:  now,
@@ -94,3 +98,28 @@ func GenerateAgentTokenValidFor(targetID, orgID uuid.UUID, validFor time.Duratio
 	}
 	return JWTAuth().Encode(claims)
 }
+
+// GenerateAnonymousTokenValidFor creates a token for registry clients without credentials that may only pull the
+// given public repositories of the organization. Its nil subject does not match any user account or deployment target.
+func GenerateAnonymousTokenValidFor(
+	orgID uuid.UUID,
+	repositories []string,
+	validFor time.Duration,
+) (jwt.Token, string, error) {
+	now := clock.Now()
+	claims := map[string]any{
+		jwt.IssuedAtKey:   now,
+		jwt.NotBeforeKey:  now,
+		jwt.ExpirationKey: now.Add(validFor),
+		jwt.SubjectKey:    uuid.Nil.String(),
+		jwt.AudienceKey:   audienceAnonymousValue,
+		OrgIdKey:          orgID.String(),
+		PullScopeKey:      repositories,
+	}
+	return JWTAuth().Encode(claims)
+}
+
+// IsAnonymousToken returns true if token has been created by GenerateAnonymousTokenValidFor.
+func IsAnonymousToken(token jwt.Token) bool {
+	return slices.Contains(token.Audience(), audienceAnonymousValue)
+}
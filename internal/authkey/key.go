@@ -2,6 +2,7 @@ package authkey
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -32,15 +33,14 @@ func NewKey() (key Key, err error) {
 
 func (key Key) Serialize() string { return keyPrefix + hex.EncodeToString(key[:]) }
 
-func (key Key) MarshalJSON() ([]byte, error) { return json.Marshal(key.Serialize()) }
-
-func (key *Key) Scan(src any) error {
-	switch v := src.(type) {
-	case []byte:
-		if len(v) == 16 {
-			*key = Key(v[:])
-			return nil
-		}
-	}
-	return errors.New("cannot scan into Key")
+// Hash returns the SHA-256 hash of the key. Only the hash is persisted, so it is used to look up a presented key.
+func (key Key) Hash() []byte {
+	hash := sha256.Sum256(key[:])
+	return hash[:]
 }
+
+// DisplayPrefix returns the beginning of the serialized key. It is not sufficient to use the key, but can be shown
+// to users to help them identify a key.
+func (key Key) DisplayPrefix() string { return key.Serialize()[:len(keyPrefix)+4] }
+
+func (key Key) MarshalJSON() ([]byte, error) { return json.Marshal(key.Serialize()) }
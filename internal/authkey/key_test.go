@@ -0,0 +1,32 @@
+package authkey_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/glasskube/distr/internal/authkey"
+	. "github.com/onsi/gomega"
+)
+
+// Tokens issued before the migration to hashed storage were hashed in place with the postgres function
+// sha256(key). These tests ensure that keys presented by clients still produce exactly the same hashes.
+func TestHashMatchesMigratedTokens(t *testing.T) {
+	g := NewWithT(t)
+	// key serialized as it was handed out before the migration
+	key, err := authkey.Parse("distr-000102030405060708090a0b0c0d0e0f")
+	g.Expect(err).NotTo(HaveOccurred())
+	// result of SELECT sha256('\x000102030405060708090a0b0c0d0e0f'::bytea)
+	expected, _ := hex.DecodeString("be45cb2605bf36bebde684841a28f0fd43c69850a3dce5fedba69928ee3a8991")
+	g.Expect(key.Hash()).To(Equal(expected))
+}
+
+func TestHashIsSha256OfRawKey(t *testing.T) {
+	g := NewWithT(t)
+	key, err := authkey.NewKey()
+	g.Expect(err).NotTo(HaveOccurred())
+	expected := sha256.Sum256(key[:])
+	g.Expect(key.Hash()).To(Equal(expected[:]))
+	g.Expect(key.Serialize()).To(HavePrefix(key.DisplayPrefix()))
+	g.Expect(key.DisplayPrefix()).To(HaveLen(len("distr-") + 4))
+}
@@ -11,6 +11,11 @@ type AuthInfo interface {
 	CurrentUserRole() *types.UserRole
 	CurrentOrgID() *uuid.UUID
 	CurrentUserEmailVerified() bool
+	// RegistryScopes returns the scopes that the credential is limited to in the registry or nil if it is not limited.
+	RegistryScopes() types.RegistryScopes
+	// CurrentServiceAccountID returns the ID of the service account if the client is not a user but a service account.
+	// Service accounts act as vendors of their organization, but have no user account.
+	CurrentServiceAccountID() *uuid.UUID
 	Token() any
 }
 
@@ -19,3 +24,11 @@ type AgentAuthInfo interface {
 	CurrentOrgID() uuid.UUID
 	Token() any
 }
+
+// AnonymousAuthInfo is the authentication of a registry client without credentials, which may only pull the public
+// repositories in its pull scope.
+type AnonymousAuthInfo interface {
+	CurrentOrgID() uuid.UUID
+	PullScope() []string
+	Token() any
+}
@@ -15,6 +15,8 @@ type DbAuthInfo struct {
 	AuthInfo
 	user *types.UserAccount
 	org  *types.Organization
+	// pullScope is only set for anonymous registry clients
+	pullScope []string
 }
 
 func (a DbAuthInfo) CurrentUser() *types.UserAccount {
@@ -25,11 +27,34 @@ func (a DbAuthInfo) CurrentOrg() *types.Organization {
 	return a.org
 }
 
+// IsServiceAccount returns true for service accounts. They have the vendor role but no user account.
+func (a DbAuthInfo) IsServiceAccount() bool {
+	return a.CurrentServiceAccountID() != nil
+}
+
+// IsAnonymous returns true for registry clients without credentials. They have the customer role but no user account.
+func (a DbAuthInfo) IsAnonymous() bool {
+	return a.pullScope != nil
+}
+
+// AnonymousPullScope returns the names of the repositories that an anonymous registry client may pull.
+func (a DbAuthInfo) AnonymousPullScope() []string {
+	return a.pullScope
+}
+
 func DbAuthenticator() authn.Authenticator[AuthInfo, *DbAuthInfo] {
 	return authn.AuthenticatorFunc[AuthInfo, *DbAuthInfo](func(ctx context.Context, a AuthInfo) (*DbAuthInfo, error) {
 		var user *types.UserAccount
 		var org *types.Organization
-		if a.CurrentOrgID() != nil && a.CurrentUserRole() != nil {
+		if a.CurrentServiceAccountID() != nil {
+			if o, err := db.GetOrganizationByID(ctx, *a.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+				return nil, authn.ErrBadAuthentication
+			} else if err != nil {
+				return nil, err
+			} else {
+				org = o
+			}
+		} else if a.CurrentOrgID() != nil && a.CurrentUserRole() != nil {
 			if u, o, err := db.GetUserAccountAndOrg(
 				ctx, a.CurrentUserID(), *a.CurrentOrgID(), a.CurrentUserRole()); errors.Is(err, apierrors.ErrNotFound) {
 				return nil, authn.ErrBadAuthentication
@@ -80,3 +105,28 @@ func AgentDbAuthenticator() authn.Authenticator[AgentAuthInfo, *DbAuthInfo] {
 	}
 	return authn.AuthenticatorFunc[AgentAuthInfo, *DbAuthInfo](fn)
 }
+
+func AnonymousDbAuthenticator() authn.Authenticator[AnonymousAuthInfo, *DbAuthInfo] {
+	fn := func(ctx context.Context, a AnonymousAuthInfo) (*DbAuthInfo, error) {
+		org, err := db.GetOrganizationByID(ctx, a.CurrentOrgID())
+		if errors.Is(err, apierrors.ErrNotFound) {
+			return nil, authn.ErrBadAuthentication
+		} else if err != nil {
+			return nil, err
+		}
+		pullScope := a.PullScope()
+		if pullScope == nil {
+			pullScope = []string{}
+		}
+		return &DbAuthInfo{
+			AuthInfo: &SimpleAuthInfo{
+				organizationID: &org.ID,
+				userRole:       util.PtrTo(types.UserRoleCustomer),
+				rawToken:       a.Token(),
+			},
+			org:       org,
+			pullScope: pullScope,
+		}, nil
+	}
+	return authn.AuthenticatorFunc[AnonymousAuthInfo, *DbAuthInfo](fn)
+}
@@ -72,3 +72,34 @@ func AgentJWTAuthenticator() authn.Authenticator[jwt.Token, AgentAuthInfo] {
 		},
 	)
 }
+
+func FromAnonymousJWT(token jwt.Token) (*SimpleAnonymousAuthInfo, error) {
+	if !authjwt.IsAnonymousToken(token) {
+		return nil, fmt.Errorf("%w: not an anonymous JWT", authn.ErrBadAuthentication)
+	}
+	var result SimpleAnonymousAuthInfo
+	if orgID, ok := token.Get(authjwt.OrgIdKey); !ok {
+		return nil, fmt.Errorf("%w: JWT orgId is missing", authn.ErrBadAuthentication)
+	} else if parsedOrgID, err := uuid.Parse(fmt.Sprint(orgID)); err != nil {
+		return nil, fmt.Errorf("JWT orgId is invalid: %w", err)
+	} else {
+		result.organizationID = parsedOrgID
+	}
+	if scope, ok := token.Get(authjwt.PullScopeKey); ok {
+		if repositories, ok := scope.([]any); ok {
+			for _, repository := range repositories {
+				result.pullScope = append(result.pullScope, fmt.Sprint(repository))
+			}
+		}
+	}
+	result.rawToken = token
+	return &result, nil
+}
+
+func AnonymousJWTAuthenticator() authn.Authenticator[jwt.Token, AnonymousAuthInfo] {
+	return authn.AuthenticatorFunc[jwt.Token, AnonymousAuthInfo](
+		func(ctx context.Context, token jwt.Token) (AnonymousAuthInfo, error) {
+			return FromAnonymousJWT(token)
+		},
+	)
+}
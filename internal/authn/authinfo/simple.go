@@ -6,12 +6,14 @@ import (
 )
 
 type SimpleAuthInfo struct {
-	userID         uuid.UUID
-	userEmail      string
-	organizationID *uuid.UUID
-	emailVerified  bool
-	userRole       *types.UserRole
-	rawToken       any
+	userID           uuid.UUID
+	userEmail        string
+	organizationID   *uuid.UUID
+	emailVerified    bool
+	userRole         *types.UserRole
+	registryScopes   types.RegistryScopes
+	serviceAccountID *uuid.UUID
+	rawToken         any
 }
 
 // CurrentOrgID implements AuthInfo.
@@ -29,6 +31,12 @@ func (i *SimpleAuthInfo) CurrentUserEmail() string { return i.userEmail }
 // CurrentUserRole implements AuthInfo.
 func (i *SimpleAuthInfo) CurrentUserRole() *types.UserRole { return i.userRole }
 
+// RegistryScopes implements AuthInfo.
+func (i *SimpleAuthInfo) RegistryScopes() types.RegistryScopes { return i.registryScopes }
+
+// CurrentServiceAccountID implements AuthInfo.
+func (i *SimpleAuthInfo) CurrentServiceAccountID() *uuid.UUID { return i.serviceAccountID }
+
 // Token implements AuthInfo.
 func (i *SimpleAuthInfo) Token() any { return i.rawToken }
 
@@ -52,3 +60,24 @@ func (i *SimpleAgentAuthInfo) CurrentOrgID() uuid.UUID {
 func (i *SimpleAgentAuthInfo) Token() any {
 	return i.rawToken
 }
+
+type SimpleAnonymousAuthInfo struct {
+	organizationID uuid.UUID
+	pullScope      []string
+	rawToken       any
+}
+
+// CurrentOrgID implements AnonymousAuthInfo.
+func (i *SimpleAnonymousAuthInfo) CurrentOrgID() uuid.UUID {
+	return i.organizationID
+}
+
+// PullScope implements AnonymousAuthInfo.
+func (i *SimpleAnonymousAuthInfo) PullScope() []string {
+	return i.pullScope
+}
+
+// Token implements AnonymousAuthInfo.
+func (i *SimpleAnonymousAuthInfo) Token() any {
+	return i.rawToken
+}
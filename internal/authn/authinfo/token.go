@@ -9,13 +9,15 @@ import (
 	"github.com/glasskube/distr/internal/authkey"
 	"github.com/glasskube/distr/internal/authn"
 	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
 )
 
+// FromAuthKey authenticates a personal access token of a user or a token of a service account.
 func FromAuthKey(ctx context.Context, token authkey.Key) (AuthInfo, error) {
-	if at, err := db.GetAccessTokenByKeyUpdatingLastUsed(ctx, token); err != nil {
-		if errors.Is(err, apierrors.ErrNotFound) {
-			err = fmt.Errorf("%w: %w", authn.ErrBadAuthentication, err)
-		}
+	if at, err := db.GetAccessTokenByKeyUpdatingLastUsed(ctx, token); errors.Is(err, apierrors.ErrNotFound) {
+		return fromServiceAccountKey(ctx, token)
+	} else if err != nil {
 		return nil, err
 	} else {
 		return &SimpleAuthInfo{
@@ -24,11 +26,30 @@ func FromAuthKey(ctx context.Context, token authkey.Key) (AuthInfo, error) {
 			emailVerified:  at.UserAccount.EmailVerifiedAt != nil,
 			organizationID: &at.OrganizationID,
 			userRole:       &at.UserRole,
+			registryScopes: at.RegistryScopes,
 			rawToken:       token,
 		}, nil
 	}
 }
 
+// fromServiceAccountKey authenticates a service account, which has the role of a vendor in its organization.
+func fromServiceAccountKey(ctx context.Context, token authkey.Key) (AuthInfo, error) {
+	if sat, err := db.GetServiceAccountTokenByKeyUpdatingLastUsed(ctx, token); err != nil {
+		if errors.Is(err, apierrors.ErrNotFound) {
+			err = fmt.Errorf("%w: %w", authn.ErrBadAuthentication, err)
+		}
+		return nil, err
+	} else {
+		return &SimpleAuthInfo{
+			organizationID:   &sat.ServiceAccount.OrganizationID,
+			userRole:         util.PtrTo(types.UserRoleVendor),
+			registryScopes:   sat.RegistryScopes,
+			serviceAccountID: &sat.ServiceAccount.ID,
+			rawToken:         token,
+		}, nil
+	}
+}
+
 func AuthKeyAuthenticator() authn.Authenticator[authkey.Key, AuthInfo] {
 	return authn.AuthenticatorFunc[authkey.Key, AuthInfo](
 		func(ctx context.Context, key authkey.Key) (AuthInfo, error) {
@@ -36,3 +57,21 @@ func AuthKeyAuthenticator() authn.Authenticator[authkey.Key, AuthInfo] {
 		},
 	)
 }
+
+// UnscopedAuthKeyAuthenticator is like AuthKeyAuthenticator, but rejects access tokens that are limited to registry
+// scopes and tokens of service accounts, because they must not be usable for anything else.
+func UnscopedAuthKeyAuthenticator() authn.Authenticator[authkey.Key, AuthInfo] {
+	return authn.AuthenticatorFunc[authkey.Key, AuthInfo](
+		func(ctx context.Context, key authkey.Key) (AuthInfo, error) {
+			if info, err := FromAuthKey(ctx, key); err != nil {
+				return nil, err
+			} else if info.RegistryScopes() != nil {
+				return nil, fmt.Errorf("%w: access token is limited to registry scopes", authn.ErrBadAuthentication)
+			} else if info.CurrentServiceAccountID() != nil {
+				return nil, fmt.Errorf("%w: service accounts can only access the registry", authn.ErrBadAuthentication)
+			} else {
+				return info, nil
+			}
+		},
+	)
+}
@@ -12,7 +12,7 @@ type TokenExtractorFunc func(r *http.Request) string
 
 type TokenExtractor struct {
 	fns     []TokenExtractorFunc
-	headers http.Header
+	headers func(r *http.Request) http.Header
 }
 
 // Authenticate implements Provider.
@@ -22,7 +22,11 @@ func (extractor *TokenExtractor) Authenticate(ctx context.Context, r *http.Reque
 			return token, nil
 		}
 	}
-	return "", authn.NewHttpHeaderError(authn.ErrNoAuthentication, extractor.headers)
+	var headers http.Header
+	if extractor.headers != nil {
+		headers = extractor.headers(r)
+	}
+	return "", authn.NewHttpHeaderError(authn.ErrNoAuthentication, headers)
 }
 
 var _ authn.RequestAuthenticator[string] = &TokenExtractor{}
@@ -36,8 +40,13 @@ func WithExtractorFuncs(fns ...TokenExtractorFunc) ExtractorOption {
 }
 
 func WithErrorHeaders(headers http.Header) ExtractorOption {
+	return WithErrorHeadersFunc(func(r *http.Request) http.Header { return headers })
+}
+
+// WithErrorHeadersFunc is like WithErrorHeaders for headers that depend on the request, e.g. on its host.
+func WithErrorHeadersFunc(fn func(r *http.Request) http.Header) ExtractorOption {
 	return func(te *TokenExtractor) {
-		te.headers = headers
+		te.headers = fn
 	}
 }
 
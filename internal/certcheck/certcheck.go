@@ -0,0 +1,67 @@
+// Package certcheck monitors the TLS certificates of deployment target endpoints and notifies vendors and customers
+// before they expire.
+package certcheck
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+)
+
+// NotificationThresholds are the numbers of days before expiry at which notifications are sent, in ascending order.
+var NotificationThresholds = []int{3, 14, 30}
+
+// WarningDays is the number of days before expiry from which on a warning is shown.
+const WarningDays = 30
+
+// DaysUntil returns the number of whole days until notAfter. It is negative if notAfter has passed.
+func DaysUntil(notAfter, now time.Time) int {
+	return int(math.Floor(notAfter.Sub(now).Hours() / 24))
+}
+
+// NotificationThreshold returns the threshold for which a notification should be sent, if any.
+// notified is the lowest threshold that has already been notified for the current certificate.
+func NotificationThreshold(daysLeft int, notified *int) (int, bool) {
+	for _, threshold := range NotificationThresholds {
+		if daysLeft <= threshold {
+			return threshold, notified == nil || threshold < *notified
+		}
+	}
+	return 0, false
+}
+
+// ShouldNotify is like NotificationThreshold, but never notifies about certificates with an invalid chain on
+// endpoints that accept self-signed certificates. Those are only recorded.
+func ShouldNotify(
+	endpoint types.DeploymentTargetEndpoint,
+	cert types.DeploymentTargetEndpointCertificate,
+	now time.Time,
+) (int, bool) {
+	if !cert.ChainValid && endpoint.SelfSignedOK {
+		return 0, false
+	}
+	return NotificationThreshold(DaysUntil(cert.NotAfter, now), endpoint.NotifiedDays)
+}
+
+// Warning returns a human-readable warning about the certificate of an endpoint or an empty string if there is
+// nothing to warn about.
+func Warning(
+	endpoint types.DeploymentTargetEndpoint,
+	cert *types.DeploymentTargetEndpointCertificate,
+	now time.Time,
+) string {
+	if cert == nil {
+		return ""
+	}
+	if days := DaysUntil(cert.NotAfter, now); days < 0 {
+		return fmt.Sprintf("certificate expired %v days ago", -days)
+	} else if days <= WarningDays {
+		return fmt.Sprintf("certificate expires in %v days", days)
+	}
+	if !cert.ChainValid && !endpoint.SelfSignedOK {
+		return "certificate chain is not valid"
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package certcheck
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"go.uber.org/zap"
+)
+
+// ProbeFunc returns the certificate that is currently served at an endpoint URL.
+type ProbeFunc func(ctx context.Context, url string) (*types.DeploymentTargetEndpointCertificate, error)
+
+type Options struct {
+	// Interval is the minimum time between two checks of the same endpoint.
+	Interval time.Duration
+	// BatchSize is the maximum number of endpoints that are considered in one run.
+	BatchSize int
+}
+
+// Result is the outcome of checking a single endpoint. Certificate is nil if the check failed.
+type Result struct {
+	Endpoint    types.DeploymentTargetEndpoint
+	Certificate *types.DeploymentTargetEndpointCertificate
+}
+
+type Checker struct {
+	probe  ProbeFunc
+	mailer mail.Mailer
+	opts   Options
+	now    func() time.Time
+}
+
+func NewChecker(probe ProbeFunc, mailer mail.Mailer, opts Options) *Checker {
+	return &Checker{probe: probe, mailer: mailer, opts: opts, now: clock.Now}
+}
+
+// Run checks a batch of due endpoints, records their certificates and sends expiry notifications.
+func (c *Checker) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	due, err := db.GetDueDeploymentTargetEndpoints(ctx, c.now().Add(-c.opts.Interval), c.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	var rotated, notified int
+	for _, result := range c.Check(ctx, due) {
+		if ok, err := db.SaveDeploymentTargetEndpointCheck(ctx, &result.Endpoint, result.Certificate); err != nil {
+			return err
+		} else if ok {
+			rotated++
+		}
+		if result.Certificate == nil {
+			continue
+		}
+		if threshold, ok := ShouldNotify(result.Endpoint, *result.Certificate, c.now()); ok {
+			if err := c.notify(ctx, result.Endpoint, *result.Certificate); err != nil {
+				log.Warn("could not send certificate expiry notification", zap.Error(err))
+				continue
+			}
+			if err := db.UpdateDeploymentTargetEndpointNotifiedDays(ctx, result.Endpoint.ID, threshold); err != nil {
+				return err
+			}
+			notified++
+		}
+	}
+	log.Info("DeploymentTargetEndpoint certificate check finished",
+		zap.Int("checked", len(due)), zap.Int("rotated", rotated), zap.Int("notified", notified))
+	return nil
+}
+
+// Check probes all endpoints. To avoid hammering a single host, endpoints that share a host are checked with a single
+// probe whose result is used for all of them.
+func (c *Checker) Check(ctx context.Context, endpoints []types.DeploymentTargetEndpoint) []Result {
+	type probeResult struct {
+		cert *types.DeploymentTargetEndpointCertificate
+		err  error
+	}
+	probes := make(map[string]probeResult)
+	results := make([]Result, len(endpoints))
+	for i, endpoint := range endpoints {
+		host := HostKey(endpoint.URL)
+		pr, ok := probes[host]
+		if !ok {
+			pr.cert, pr.err = c.probe(ctx, endpoint.URL)
+			probes[host] = pr
+		}
+		endpoint.LastCheckedAt = util.PtrTo(c.now())
+		if pr.err != nil {
+			endpoint.LastError = util.PtrTo(pr.err.Error())
+			results[i] = Result{Endpoint: endpoint}
+		} else {
+			endpoint.LastError = nil
+			results[i] = Result{Endpoint: endpoint, Certificate: util.PtrCopy(pr.cert)}
+		}
+	}
+	return results
+}
+
+// notify informs all vendor users of the organization and the customer who created the deployment target about a
+// certificate that is about to expire.
+func (c *Checker) notify(
+	ctx context.Context,
+	endpoint types.DeploymentTargetEndpoint,
+	cert types.DeploymentTargetEndpointCertificate,
+) error {
+	target, err := db.GetDeploymentTarget(ctx, endpoint.DeploymentTargetID, nil)
+	if err != nil {
+		return err
+	}
+	org, err := db.GetOrganizationWithBranding(ctx, target.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, target.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, 0, len(users)+1)
+	for _, user := range users {
+		recipients = append(recipients, user.Email)
+	}
+	if target.CreatedBy != nil && target.CreatedBy.UserRole == types.UserRoleCustomer {
+		recipients = append(recipients, target.CreatedBy.Email)
+	}
+	daysLeft := DaysUntil(cert.NotAfter, c.now())
+	var errs []error
+	for _, email := range recipients {
+		errs = append(errs, c.mailer.Send(ctx, mail.New(
+			mail.To(email),
+			mail.Subject("TLS certificate of "+endpoint.URL+" expires soon"),
+			mail.Type(types.MailTypeCertificateExpiring),
+			mail.HtmlBodyTemplate(mailtemplates.CertificateExpiring(*org, target.Name, endpoint, cert, daysLeft)),
+			mail.Organization(target.OrganizationID),
+		)))
+	}
+	return errors.Join(errs...)
+}
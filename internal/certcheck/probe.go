@@ -0,0 +1,114 @@
+package certcheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+)
+
+const probeTimeout = 10 * time.Second
+
+var (
+	ErrInvalidURL       = errors.New("url must be an absolute https URL without credentials")
+	ErrNonPublicAddress = errors.New("endpoint does not resolve to a public address")
+)
+
+// ParseURL validates an endpoint URL and returns its normalized form.
+// Endpoints are checked from the Distr server, so only https URLs of public hosts are accepted.
+func ParseURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil || u.Opaque != "" {
+		return "", ErrInvalidURL
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !isPublic(addr) {
+		return "", ErrNonPublicAddress
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// HostKey returns host:port of an endpoint URL. All endpoints with the same host key share a single check.
+func HostKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// Probe performs a TLS handshake with the host of rawURL and returns its leaf certificate.
+// A certificate that fails verification is still returned, with ChainValid set to false.
+func Probe(ctx context.Context, rawURL string) (*types.DeploymentTargetEndpointCertificate, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	dialer := tls.Dialer{
+		NetDialer: &net.Dialer{Control: rejectNonPublicAddress},
+		Config:    &tls.Config{ServerName: u.Hostname()},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", HostKey(rawURL))
+	var verificationErr *tls.CertificateVerificationError
+	if errors.As(err, &verificationErr) {
+		if len(verificationErr.UnverifiedCertificates) == 0 {
+			return nil, err
+		}
+		cert := certificate(verificationErr.UnverifiedCertificates[0])
+		cert.ChainError = util.PtrTo(verificationErr.Err.Error())
+		return cert, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("server did not present a certificate")
+	}
+	cert := certificate(certs[0])
+	cert.ChainValid = true
+	return cert, nil
+}
+
+func certificate(leaf *x509.Certificate) *types.DeploymentTargetEndpointCertificate {
+	sum := sha256.Sum256(leaf.Raw)
+	return &types.DeploymentTargetEndpointCertificate{
+		Fingerprint: hex.EncodeToString(sum[:]),
+		Subject:     leaf.Subject.String(),
+		Issuer:      leaf.Issuer.String(),
+		NotAfter:    leaf.NotAfter,
+	}
+}
+
+// rejectNonPublicAddress prevents endpoint checks from being used to reach the internal network of the server.
+// It is called after DNS resolution, so it also covers host names that resolve to internal addresses.
+func rejectNonPublicAddress(network, address string, _ syscall.RawConn) error {
+	if addrPort, err := netip.ParseAddrPort(address); err != nil {
+		return fmt.Errorf("%w: %w", ErrNonPublicAddress, err)
+	} else if !isPublic(addrPort.Addr()) {
+		return ErrNonPublicAddress
+	}
+	return nil
+}
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
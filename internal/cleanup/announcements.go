@@ -0,0 +1,21 @@
+package cleanup
+
+import (
+	"context"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/metrics"
+	"go.uber.org/zap"
+)
+
+func RunAnnouncementCleanup(ctx context.Context) error {
+	if count, err := db.CleanupExpiredAnnouncements(ctx, time.Now()); err != nil {
+		return err
+	} else {
+		internalctx.GetLogger(ctx).Info("Announcement cleanup finished", zap.Int64("rowsDeleted", count))
+		metrics.ObserveCleanup("Announcement", count, 0)
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// RunApplicationDeletion purges all deleted applications whose restore window has passed. Applications that still
+// have active deployments, e.g. because their uninstall has not completed yet, are kept until the next run.
+// Applications of organizations under legal hold are kept until the hold is released.
+func RunApplicationDeletion(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	applications, err := db.GetApplicationsDueForDeletion(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	holds, err := db.GetLegalHolds(ctx)
+	if err != nil {
+		return err
+	}
+	var count, held, blocked int
+	var errs []error
+	for _, application := range applications {
+		// purging an application also deletes the history of its deployments
+		if holds.CoversAnyOf(application.OrganizationID) {
+			log.Info("skipping deletion of application under legal hold", zap.Stringer("applicationId", application.ID))
+			held++
+			continue
+		}
+		err := db.RunTx(ctx, func(ctx context.Context) error {
+			if err := db.PurgeApplication(ctx, application.ID); err != nil {
+				return err
+			}
+			return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+				OrganizationID: &application.OrganizationID,
+				Action:         "delete",
+				ResourceType:   "Application",
+				ResourceID:     application.ID,
+				Data:           map[string]any{"application": application},
+			})
+		})
+		if errors.Is(err, apierrors.ErrNotFound) {
+			continue
+		} else if errors.Is(err, apierrors.ErrConflict) {
+			log.Info("skipping deletion of application with active deployments",
+				zap.Stringer("applicationId", application.ID))
+			blocked++
+		} else if err != nil {
+			log.Warn("could not delete application", zap.Stringer("applicationId", application.ID), zap.Error(err))
+			errs = append(errs, err)
+		} else {
+			count++
+		}
+	}
+	log.Info("application deletion finished", zap.Int("applicationsDeleted", count),
+		zap.Int("applicationsHeld", held), zap.Int("applicationsBlocked", blocked))
+	return errors.Join(errs...)
+}
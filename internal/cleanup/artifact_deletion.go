@@ -0,0 +1,60 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// RunArtifactDeletion deletes all artifacts whose deletion was requested and whose cool-off period has passed.
+// Each deletion is recorded in the audit log without a user. Artifacts of organizations under legal hold are kept until
+// the hold is released.
+func RunArtifactDeletion(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	artifacts, err := db.GetArtifactsDueForDeletion(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	holds, err := db.GetLegalHolds(ctx)
+	if err != nil {
+		return err
+	}
+	var count, held int
+	var errs []error
+	for _, artifact := range artifacts {
+		// deleting an artifact also deletes its pull audit log, which may contain data of any customer account
+		if holds.CoversAnyOf(artifact.OrganizationID) {
+			log.Info("skipping deletion of artifact under legal hold", zap.Stringer("artifactId", artifact.ID))
+			held++
+			continue
+		}
+		err := db.RunTx(ctx, func(ctx context.Context) error {
+			if err := db.DeleteArtifact(ctx, artifact.ID); err != nil {
+				return err
+			}
+			return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+				OrganizationID: &artifact.OrganizationID,
+				Action:         "delete",
+				ResourceType:   "Artifact",
+				ResourceID:     artifact.ID,
+				Data:           map[string]any{"artifact": artifact},
+			})
+		})
+		if errors.Is(err, apierrors.ErrNotFound) {
+			continue
+		} else if err != nil {
+			log.Warn("could not delete artifact", zap.Stringer("artifactId", artifact.ID), zap.Error(err))
+			errs = append(errs, err)
+		} else {
+			count++
+		}
+	}
+	log.Info("artifact deletion finished", zap.Int("artifactsDeleted", count), zap.Int("artifactsHeld", held))
+	return errors.Join(errs...)
+}
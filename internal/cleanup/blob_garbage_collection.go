@@ -0,0 +1,85 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/registry/metrics"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"go.uber.org/zap"
+)
+
+type BlobGarbageCollectionOptions struct {
+	// GracePeriod is the time after an upload during which a blob is kept even if it is not referenced, so that the
+	// manifest that references it can still be pushed.
+	GracePeriod time.Duration
+	// BatchSize is the maximum number of blobs that are deleted per run.
+	BatchSize int
+}
+
+// BlobGarbageCollector deletes the blobs of the platform bucket that are not referenced by any artifact version,
+// e.g. because the push of an image was aborted or the artifact was deleted. It also removes the blobs that an
+// organization does not reference anymore from its storage usage.
+type BlobGarbageCollector struct {
+	handler blob.BlobDeleteHandler
+	opts    BlobGarbageCollectionOptions
+	now     func() time.Time
+}
+
+func NewBlobGarbageCollector(handler blob.BlobDeleteHandler, opts BlobGarbageCollectionOptions) *BlobGarbageCollector {
+	return &BlobGarbageCollector{handler: handler, opts: opts, now: clock.Now}
+}
+
+// Run deletes unreferenced blobs whose grace period has passed.
+//
+// Each blob is deleted in its own transaction that holds a lock on its metadata. A manifest push that references the
+// blob waits for the deletion to finish or, if it is first, keeps the blob from being deleted.
+func (c *BlobGarbageCollector) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	before := c.now().Add(-c.opts.GracePeriod)
+	blobs, err := db.GetUnreferencedPlatformBlobs(ctx, before, c.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	var count int
+	var size int64
+	var errs []error
+	for _, metadata := range blobs {
+		var deleted bool
+		err := db.RunTx(ctx, func(ctx context.Context) error {
+			if ok, err := db.LockUnreferencedPlatformBlob(ctx, metadata.Digest, before); err != nil || !ok {
+				return err
+			} else if err := c.handler.Delete(ctx, "", v1.Hash(metadata.Digest)); errors.Is(err, blob.ErrNotFound) {
+				// the object is already gone, only the metadata is left
+				deleted = true
+				return db.DeleteBlobMetadata(ctx, metadata.Digest)
+			} else if err != nil {
+				return err
+			}
+			deleted = true
+			return nil
+		})
+		if err != nil {
+			log.Warn("could not delete blob", zap.Stringer("digest", v1.Hash(metadata.Digest)), zap.Error(err))
+			errs = append(errs, err)
+		} else if deleted {
+			count++
+			size += metadata.Size
+		}
+	}
+	// blobs that are shared with other organizations are kept, but do not count towards the usage of this one anymore
+	usageRemoved, err := db.DeleteUnreferencedOrganizationBlobUsage(ctx, before)
+	if err != nil {
+		log.Warn("could not update organization storage usage", zap.Error(err))
+		errs = append(errs, err)
+	}
+	log.Info("blob garbage collection finished", zap.Int("blobsDeleted", count), zap.Int64("bytesReclaimed", size),
+		zap.Int64("usageEntriesRemoved", usageRemoved))
+	metrics.ObserveCleanup("Blob", int64(count), size)
+	return errors.Join(errs...)
+}
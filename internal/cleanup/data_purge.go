@@ -0,0 +1,56 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/metrics"
+	"go.uber.org/zap"
+)
+
+// RunDataPurge deletes the collected data of deployment targets for all pending purge requests.
+// Each purge runs in its own transaction, so that a failing purge does not affect the others. Purges of deployment
+// targets under legal hold stay pending until the hold is released.
+func RunDataPurge(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	purges, err := db.GetPendingDeploymentTargetDataPurges(ctx)
+	if err != nil {
+		return err
+	}
+	var total int64
+	var held int
+	var errs []error
+	for _, purge := range purges {
+		if isHeld, err := db.IsDeploymentTargetUnderLegalHold(ctx, purge.DeploymentTargetID); err != nil {
+			errs = append(errs, err)
+			continue
+		} else if isHeld {
+			log.Info("skipping purge of deployment target data under legal hold",
+				zap.Stringer("purgeId", purge.ID), zap.Stringer("deploymentTargetId", purge.DeploymentTargetID))
+			held++
+			continue
+		}
+		var count int64
+		err := db.RunTx(ctx, func(ctx context.Context) (err error) {
+			count, err = db.ExecuteDeploymentTargetDataPurge(ctx, &purge)
+			return err
+		})
+		if errors.Is(err, apierrors.ErrNotFound) {
+			continue
+		} else if err != nil {
+			log.Warn("could not purge deployment target data",
+				zap.Stringer("purgeId", purge.ID), zap.Stringer("deploymentTargetId", purge.DeploymentTargetID),
+				zap.Error(err))
+			errs = append(errs, err)
+		} else {
+			total += count
+		}
+	}
+	log.Info("data purge finished",
+		zap.Int("purges", len(purges)), zap.Int("purgesHeld", held), zap.Int64("rowsDeleted", total))
+	metrics.ObserveCleanup("DataPurge", total, 0)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,44 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/metrics"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// RunDataRetentionCleanup deletes the data of every category that is older than the retention policy of its
+// organization. Data under legal hold is kept and reported as held.
+func RunDataRetentionCleanup(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	var errs []error
+	for _, category := range types.DataRetentionCategories {
+		if deleted, held, err := db.CleanupExpiredData(ctx, category); err != nil {
+			log.Warn("data retention cleanup failed", zap.String("category", string(category)), zap.Error(err))
+			errs = append(errs, err)
+		} else {
+			log.Info("data retention cleanup finished", zap.String("category", string(category)),
+				zap.Int64("rowsDeleted", deleted), zap.Int64("rowsHeld", held))
+			metrics.ObserveCleanup("DataRetention", deleted, 0)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reportLegalHolds logs every active legal hold, because cleanup jobs skip the data they cover.
+func reportLegalHolds(ctx context.Context) error {
+	holds, err := db.GetLegalHolds(ctx)
+	if err != nil {
+		return err
+	}
+	log := internalctx.GetLogger(ctx)
+	for _, hold := range holds {
+		log.Info("skipping data under legal hold",
+			zap.Stringer("organizationId", hold.OrganizationID), zap.Any("userAccountId", hold.UserAccountID))
+	}
+	return nil
+}
@@ -5,15 +5,19 @@ import (
 
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/metrics"
 	"go.uber.org/zap"
 )
 
 func RunDeploymentTargetMetricsCleanup(ctx context.Context) error {
 	log := internalctx.GetLogger(ctx)
-	if count, err := db.CleanupDeploymentTargetMetrics(ctx); err != nil {
+	if err := reportLegalHolds(ctx); err != nil {
+		return err
+	} else if count, err := db.CleanupDeploymentTargetMetrics(ctx); err != nil {
 		return err
 	} else {
 		log.Info("DeploymentTargetMetrics cleanup finished", zap.Int64("rowsDeleted", count))
+		metrics.ObserveCleanup("DeploymentTargetMetrics", count, 0)
 		return nil
 	}
 }
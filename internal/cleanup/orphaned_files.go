@@ -0,0 +1,22 @@
+package cleanup
+
+import (
+	"context"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/registry/metrics"
+	"go.uber.org/zap"
+)
+
+func RunOrphanedFileCleanup(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	if count, size, err := db.CleanupOrphanedFiles(ctx, env.OrphanedFilesGracePeriod()); err != nil {
+		return err
+	} else {
+		log.Info("OrphanedFile cleanup finished", zap.Int64("rowsDeleted", count), zap.Int64("bytesReclaimed", size))
+		metrics.ObserveCleanup("OrphanedFile", count, size)
+		return nil
+	}
+}
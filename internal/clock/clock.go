@@ -0,0 +1,67 @@
+// Package clock provides the current time to code that checks expiry dates or runs on a schedule.
+//
+// Production code calls Now instead of time.Now, so that tests can replace the clock with a Fake and move it forward
+// deterministically, e.g. with testutil.AdvanceTime. The clock is global, so tests that replace it must not run in
+// parallel.
+package clock
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+var current atomic.Pointer[Clock]
+
+func init() {
+	Reset()
+}
+
+// Now returns the current time of the active clock.
+func Now() time.Time {
+	return (*current.Load()).Now()
+}
+
+// Set replaces the active clock with c until Reset is called.
+func Set(c Clock) {
+	current.Store(&c)
+}
+
+// Reset restores the real clock.
+func Reset() {
+	Set(realClock{})
+}
+
+// Fake is a Clock that only moves when it is told to.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ Clock = &Fake{}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (f *Fake) Advance(d time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	return f.now
+}
@@ -0,0 +1,53 @@
+// Package clockskew estimates how far the clock of an agent deviates from the server clock and converts timestamps
+// reported by agents to server time.
+package clockskew
+
+import (
+	"time"
+)
+
+const (
+	// Threshold is the skew above which timestamps reported by an agent are corrected with the estimated skew.
+	Threshold = time.Minute
+	// smoothing is the weight of a new sample in the moving average.
+	smoothing = 0.2
+	// maxEstimateAge is the age after which a previous estimate is discarded instead of being averaged.
+	maxEstimateAge = time.Hour
+)
+
+// Sample returns the skew indicated by a single request: the time reported by the agent minus the time the server
+// received the request. A positive skew means that the agent clock is ahead.
+// The sample also includes the network latency, which is negligible compared to Threshold.
+func Sample(agentTime, receivedAt time.Time) time.Duration {
+	return agentTime.Sub(receivedAt)
+}
+
+// Estimate updates the previous estimate with a new sample using an exponential moving average, so that a single
+// delayed request does not cause a warning. If there is no previous estimate or it is older than an hour, the sample
+// is used as is.
+func Estimate(previous *time.Duration, previousAt *time.Time, sample time.Duration, now time.Time) time.Duration {
+	if previous == nil || previousAt == nil || now.Sub(*previousAt) > maxEstimateAge {
+		return sample
+	}
+	return *previous + time.Duration(smoothing*float64(sample-*previous))
+}
+
+// Exceeded reports whether the absolute value of skew is above Threshold.
+func Exceeded(skew time.Duration) bool {
+	return skew > Threshold || skew < -Threshold
+}
+
+// ServerTime converts a timestamp reported by an agent to server time. If skew exceeds Threshold, it is subtracted
+// from t. The result is never later than receivedAt, because an agent can not report events from the future.
+func ServerTime(t time.Time, skew time.Duration, receivedAt time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	if Exceeded(skew) {
+		t = t.Add(-skew)
+	}
+	if t.After(receivedAt) {
+		return receivedAt
+	}
+	return t
+}
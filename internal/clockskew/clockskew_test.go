@@ -0,0 +1,56 @@
+package clockskew_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/clockskew"
+	. "github.com/onsi/gomega"
+)
+
+func TestEstimate(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	skew := clockskew.Estimate(nil, nil, -14*time.Minute, now)
+	g.Expect(skew).To(Equal(-14 * time.Minute))
+
+	// a single outlier only moves the estimate by a fraction of the difference
+	skew = clockskew.Estimate(&skew, &now, 16*time.Minute, now.Add(5*time.Second))
+	g.Expect(skew).To(Equal(-8 * time.Minute))
+
+	// an outdated estimate is replaced
+	skew = clockskew.Estimate(&skew, &now, 2*time.Second, now.Add(2*time.Hour))
+	g.Expect(skew).To(Equal(2 * time.Second))
+}
+
+func TestSample(t *testing.T) {
+	g := NewWithT(t)
+	receivedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	g.Expect(clockskew.Sample(receivedAt.Add(-14*time.Minute), receivedAt)).To(Equal(-14 * time.Minute))
+	g.Expect(clockskew.Sample(receivedAt.Add(time.Hour), receivedAt)).To(Equal(time.Hour))
+}
+
+func TestExceeded(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(clockskew.Exceeded(30 * time.Second)).To(BeFalse())
+	g.Expect(clockskew.Exceeded(-clockskew.Threshold)).To(BeFalse())
+	g.Expect(clockskew.Exceeded(2 * time.Minute)).To(BeTrue())
+	g.Expect(clockskew.Exceeded(-2 * time.Minute)).To(BeTrue())
+}
+
+func TestServerTime(t *testing.T) {
+	g := NewWithT(t)
+	receivedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	logged := receivedAt.Add(-10 * time.Second)
+
+	g.Expect(clockskew.ServerTime(logged, 0, receivedAt)).To(Equal(logged))
+	g.Expect(clockskew.ServerTime(logged.Add(20*time.Second), 0, receivedAt)).To(Equal(receivedAt),
+		"timestamps in the future are clamped")
+	g.Expect(clockskew.ServerTime(logged.Add(time.Hour), time.Hour, receivedAt)).To(Equal(logged),
+		"the skew is subtracted if it exceeds the threshold")
+	g.Expect(clockskew.ServerTime(logged.Add(-14*time.Minute), -14*time.Minute, receivedAt)).To(Equal(logged))
+	g.Expect(clockskew.ServerTime(logged.Add(-30*time.Second), -30*time.Second, receivedAt)).
+		To(Equal(logged.Add(-30*time.Second)), "skew below the threshold is not corrected")
+	g.Expect(clockskew.ServerTime(time.Time{}, time.Hour, receivedAt)).To(BeZero())
+}
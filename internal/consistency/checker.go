@@ -0,0 +1,62 @@
+// Package consistency implements a check for rows that reference user accounts that were deleted or removed from the
+// organization, like organization memberships of deleted user accounts or licenses owned by former customers.
+// Such rows can be left behind by constraints that were dropped manually or by bugs that predate the current checks.
+package consistency
+
+import (
+	"context"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+type Checker struct {
+	repair bool
+}
+
+// NewChecker returns a Checker that only reports issues, or also repairs them if repair is true.
+// See types.ConsistencyIssueType.Repairable for the issues that can be repaired.
+func NewChecker(repair bool) *Checker {
+	return &Checker{repair: repair}
+}
+
+// Run finds all consistency issues, repairs them if enabled and saves the report, replacing the previous one.
+func (c *Checker) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	issues, err := db.GetConsistencyIssues(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.repair && len(issues) > 0 {
+		if count, err := db.RepairConsistencyIssues(ctx); err != nil {
+			return err
+		} else {
+			log.Info("consistency issues repaired", zap.Int64("rowsChanged", count))
+		}
+		for i := range issues {
+			issues[i].Repaired = issues[i].Type.Repairable()
+		}
+	}
+
+	for _, issue := range issues {
+		log.Warn("consistency issue found",
+			zap.String("type", string(issue.Type)),
+			zap.Stringer("organizationId", issue.OrganizationID),
+			zap.Any("entityId", issue.EntityID),
+			zap.Stringer("userAccountId", issue.UserAccountID),
+			zap.Bool("repaired", issue.Repaired))
+	}
+
+	report := types.ConsistencyReport{Repair: c.repair, Issues: issues}
+	if report.Issues == nil {
+		report.Issues = []types.ConsistencyIssue{}
+	}
+	if err := db.CreateConsistencyReport(ctx, &report); err != nil {
+		return err
+	}
+	log.Info("consistency check finished", zap.Int("issues", len(issues)), zap.Bool("repair", c.repair))
+	return nil
+}
@@ -14,6 +14,7 @@ const (
 	MediaTypeTextPlain        = "text/plain"
 	MediaTypeTextXYaml        = "text/x-yaml"
 	MediaTypeApplicationXYaml = "application/x-yaml"
+	MediaTypeMultipartForm    = "multipart/form-data"
 )
 
 func IsYaml(header textproto.MIMEHeader) error {
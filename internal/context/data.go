@@ -113,6 +113,17 @@ func WithArtifactLicense(ctx context.Context, license *types.ArtifactLicense) co
 	return ctx
 }
 
+func GetServiceAccount(ctx context.Context) *types.ServiceAccount {
+	if sa, ok := ctx.Value(ctxKeyServiceAccount).(*types.ServiceAccount); ok && sa != nil {
+		return sa
+	}
+	panic("service account not contained in context")
+}
+
+func WithServiceAccount(ctx context.Context, sa *types.ServiceAccount) context.Context {
+	return context.WithValue(ctx, ctxKeyServiceAccount, sa)
+}
+
 func GetRequestIPAddress(ctx context.Context) string {
 	if val, ok := ctx.Value(ctxKeyIPAddress).(string); ok {
 		return val
@@ -123,3 +134,28 @@ func GetRequestIPAddress(ctx context.Context) string {
 func WithRequestIPAddress(ctx context.Context, address string) context.Context {
 	return context.WithValue(ctx, ctxKeyIPAddress, address)
 }
+
+// GetMaintenanceState returns the maintenance state snapshot of the current request.
+// If no snapshot was added to the context, an empty state is returned.
+func GetMaintenanceState(ctx context.Context) types.MaintenanceState {
+	if state, ok := ctx.Value(ctxKeyMaintenanceState).(types.MaintenanceState); ok {
+		return state
+	}
+	return types.MaintenanceState{}
+}
+
+func WithMaintenanceState(ctx context.Context, state types.MaintenanceState) context.Context {
+	return context.WithValue(ctx, ctxKeyMaintenanceState, state)
+}
+
+// GetAccessGrant returns the access grant under which the current request is performed or nil if there is none.
+func GetAccessGrant(ctx context.Context) *types.AccessGrant {
+	if grant, ok := ctx.Value(ctxKeyAccessGrant).(*types.AccessGrant); ok {
+		return grant
+	}
+	return nil
+}
+
+func WithAccessGrant(ctx context.Context, grant *types.AccessGrant) context.Context {
+	return context.WithValue(ctx, ctxKeyAccessGrant, grant)
+}
@@ -12,6 +12,7 @@ type contextKey int
 
 const (
 	ctxKeyDb contextKey = iota
+	ctxKeyRootDb
 	ctxKeyLogger
 	ctxKeyMailer
 	ctxKeyOrgId
@@ -24,6 +25,9 @@ const (
 	ctxKeyApplicationLicense
 	ctxKeyArtifactLicense
 	ctxKeyIPAddress
+	ctxKeyMaintenanceState
+	ctxKeyAccessGrant
+	ctxKeyServiceAccount
 )
 
 func GetDb(ctx context.Context) queryable.Queryable {
@@ -37,10 +41,24 @@ func GetDb(ctx context.Context) queryable.Queryable {
 }
 
 func WithDb(ctx context.Context, db queryable.Queryable) context.Context {
+	if _, ok := ctx.Value(ctxKeyRootDb).(queryable.Queryable); !ok {
+		// the first db that is put into a context is remembered, so that it is possible to
+		// escape from a transaction that was started later on
+		ctx = context.WithValue(ctx, ctxKeyRootDb, db)
+	}
 	ctx = context.WithValue(ctx, ctxKeyDb, db)
 	return ctx
 }
 
+// GetRootDb returns the db that was first added to the context. In contrast to GetDb, this is never a
+// transaction that was started by db.RunTx.
+func GetRootDb(ctx context.Context) queryable.Queryable {
+	if db, ok := ctx.Value(ctxKeyRootDb).(queryable.Queryable); ok && db != nil {
+		return db
+	}
+	return GetDb(ctx)
+}
+
 func GetLogger(ctx context.Context) *zap.Logger {
 	val := ctx.Value(ctxKeyLogger)
 	if logger, ok := val.(*zap.Logger); ok {
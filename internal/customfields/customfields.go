@@ -0,0 +1,214 @@
+package customfields
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+)
+
+// FilterQueryPrefix is the prefix of query parameters that filter list endpoints by custom field values,
+// e.g. "?customField.region=eu".
+const FilterQueryPrefix = "customField."
+
+var keyPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,63}$`)
+
+// ValidateDefinition returns an error if the definition itself is not valid.
+func ValidateDefinition(def types.CustomFieldDefinition) error {
+	if !keyPattern.MatchString(def.Key) {
+		return validation.NewValidationFailedError(
+			"key must start with a letter and only contain letters, digits, \"_\" and \"-\" (max 64 characters)",
+		)
+	}
+	switch def.Target {
+	case types.CustomFieldTargetDeploymentTarget, types.CustomFieldTargetCustomer:
+	default:
+		return validation.NewValidationFailedError(fmt.Sprintf("invalid target: %v", def.Target))
+	}
+	switch def.Type {
+	case types.CustomFieldTypeString, types.CustomFieldTypeNumber, types.CustomFieldTypeBool:
+		if len(def.EnumValues) > 0 {
+			return validation.NewValidationFailedError("enumValues are only allowed for type enum")
+		}
+	case types.CustomFieldTypeEnum:
+		if len(def.EnumValues) == 0 {
+			return validation.NewValidationFailedError("enumValues must not be empty for type enum")
+		}
+	default:
+		return validation.NewValidationFailedError(fmt.Sprintf("invalid type: %v", def.Type))
+	}
+	return nil
+}
+
+// ValidateValues checks the given values against the definitions.
+// Every key must have a definition, every value must match its type and all required fields must be present.
+func ValidateValues(defs []types.CustomFieldDefinition, values types.CustomFields) error {
+	for key, value := range values {
+		if def := find(defs, key); def == nil {
+			return validation.NewValidationFailedError(fmt.Sprintf("unknown custom field: %v", key))
+		} else if reason := checkValue(*def, value); reason != "" {
+			return validation.NewValidationFailedError(fmt.Sprintf("custom field %v: %v", key, reason))
+		}
+	}
+	for _, def := range defs {
+		if _, ok := values[def.Key]; def.Required && !ok {
+			return validation.NewValidationFailedError(fmt.Sprintf("custom field %v is required", def.Key))
+		}
+	}
+	return nil
+}
+
+// Merge validates the incoming values and combines them with the existing values of an entity.
+//
+// Existing values that the writer can not manage are carried over unchanged, so that no data is lost:
+// This applies to values without a definition (e.g. because the definition was deleted) and,
+// if forCustomer is true, to values of fields that are not visible to customers.
+// Likewise, such fields are not subject to the required check.
+func Merge(
+	defs []types.CustomFieldDefinition,
+	existing, incoming types.CustomFields,
+	forCustomer bool,
+) (types.CustomFields, error) {
+	writable := defs
+	if forCustomer {
+		writable = visibleDefinitions(defs)
+	}
+	if err := ValidateValues(writable, incoming); err != nil {
+		return nil, err
+	}
+	result := make(types.CustomFields, len(existing)+len(incoming))
+	for key, value := range existing {
+		if find(writable, key) == nil {
+			result[key] = value
+		}
+	}
+	for key, value := range incoming {
+		result[key] = value
+	}
+	return result, nil
+}
+
+// FilterVisible returns only the values of fields that are visible to customers.
+func FilterVisible(defs []types.CustomFieldDefinition, values types.CustomFields) types.CustomFields {
+	result := make(types.CustomFields)
+	for key, value := range values {
+		if def := find(defs, key); def != nil && def.VisibleToCustomer {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// Report returns all violations of the definitions in the existing values of the given entities.
+func Report(
+	defs []types.CustomFieldDefinition,
+	values map[uuid.UUID]types.CustomFields,
+) []types.CustomFieldViolation {
+	result := []types.CustomFieldViolation{}
+	for id, fields := range values {
+		for key, value := range fields {
+			if def := find(defs, key); def == nil {
+				result = append(result, types.CustomFieldViolation{EntityID: id, Key: key, Reason: "no definition"})
+			} else if reason := checkValue(*def, value); reason != "" {
+				result = append(result, types.CustomFieldViolation{EntityID: id, Key: key, Reason: reason})
+			}
+		}
+		for _, def := range defs {
+			if _, ok := fields[def.Key]; def.Required && !ok {
+				result = append(result, types.CustomFieldViolation{EntityID: id, Key: def.Key, Reason: "missing"})
+			}
+		}
+	}
+	slices.SortFunc(result, func(a, b types.CustomFieldViolation) int {
+		if c := strings.Compare(a.EntityID.String(), b.EntityID.String()); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return result
+}
+
+// ParseFilter extracts custom field filters from the query parameters and converts them
+// to the types of the respective definitions.
+// The result can be used for a jsonb containment query.
+// If forCustomer is true, only fields that are visible to customers can be used for filtering.
+func ParseFilter(
+	defs []types.CustomFieldDefinition,
+	query url.Values,
+	forCustomer bool,
+) (types.CustomFields, error) {
+	if forCustomer {
+		defs = visibleDefinitions(defs)
+	}
+	result := make(types.CustomFields)
+	for param, values := range query {
+		key, ok := strings.CutPrefix(param, FilterQueryPrefix)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		def := find(defs, key)
+		if def == nil {
+			return nil, validation.NewValidationFailedError(fmt.Sprintf("unknown custom field: %v", key))
+		}
+		var value any = values[0]
+		switch def.Type {
+		case types.CustomFieldTypeNumber:
+			if n, err := strconv.ParseFloat(values[0], 64); err != nil {
+				return nil, validation.NewValidationFailedError(fmt.Sprintf("custom field %v: must be a number", key))
+			} else {
+				value = n
+			}
+		case types.CustomFieldTypeBool:
+			if b, err := strconv.ParseBool(values[0]); err != nil {
+				return nil, validation.NewValidationFailedError(fmt.Sprintf("custom field %v: must be a bool", key))
+			} else {
+				value = b
+			}
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func checkValue(def types.CustomFieldDefinition, value any) string {
+	switch def.Type {
+	case types.CustomFieldTypeString:
+		if _, ok := value.(string); !ok {
+			return "must be a string"
+		}
+	case types.CustomFieldTypeNumber:
+		if _, ok := value.(float64); !ok {
+			return "must be a number"
+		}
+	case types.CustomFieldTypeBool:
+		if _, ok := value.(bool); !ok {
+			return "must be a bool"
+		}
+	case types.CustomFieldTypeEnum:
+		if s, ok := value.(string); !ok || !slices.Contains(def.EnumValues, s) {
+			return fmt.Sprintf("must be one of %v", strings.Join(def.EnumValues, ", "))
+		}
+	}
+	return ""
+}
+
+func find(defs []types.CustomFieldDefinition, key string) *types.CustomFieldDefinition {
+	for i := range defs {
+		if defs[i].Key == key {
+			return &defs[i]
+		}
+	}
+	return nil
+}
+
+func visibleDefinitions(defs []types.CustomFieldDefinition) []types.CustomFieldDefinition {
+	return slices.DeleteFunc(slices.Clone(defs), func(def types.CustomFieldDefinition) bool {
+		return !def.VisibleToCustomer
+	})
+}
@@ -0,0 +1,64 @@
+package customfields_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/glasskube/distr/internal/customfields"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+var defs = []types.CustomFieldDefinition{
+	{Key: "tier", Type: types.CustomFieldTypeEnum, EnumValues: []string{"gold", "silver"}, Required: true},
+	{Key: "seats", Type: types.CustomFieldTypeNumber, VisibleToCustomer: true},
+	{Key: "region", Type: types.CustomFieldTypeString, VisibleToCustomer: true},
+}
+
+func TestValidateValues(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(customfields.ValidateValues(defs, types.CustomFields{"tier": "gold", "seats": 5.0})).To(Succeed())
+	g.Expect(customfields.ValidateValues(defs, types.CustomFields{"seats": 5.0})).NotTo(Succeed())
+	g.Expect(customfields.ValidateValues(defs, types.CustomFields{"tier": "bronze"})).NotTo(Succeed())
+	g.Expect(customfields.ValidateValues(defs, types.CustomFields{"tier": "gold", "seats": "5"})).NotTo(Succeed())
+	g.Expect(customfields.ValidateValues(defs, types.CustomFields{"tier": "gold", "crm": "x"})).NotTo(Succeed())
+}
+
+func TestMergeKeepsValuesTheWriterCannotManage(t *testing.T) {
+	g := NewWithT(t)
+	existing := types.CustomFields{"tier": "gold", "region": "eu", "legacy": "x"}
+
+	merged, err := customfields.Merge(defs, existing, types.CustomFields{"region": "us"}, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(merged).To(Equal(types.CustomFields{"tier": "gold", "region": "us", "legacy": "x"}))
+
+	_, err = customfields.Merge(defs, existing, types.CustomFields{"tier": "silver"}, true)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestFilterVisible(t *testing.T) {
+	g := NewWithT(t)
+	values := types.CustomFields{"tier": "gold", "region": "eu", "legacy": "x"}
+	g.Expect(customfields.FilterVisible(defs, values)).To(Equal(types.CustomFields{"region": "eu"}))
+}
+
+func TestReport(t *testing.T) {
+	g := NewWithT(t)
+	id := uuid.New()
+	violations := customfields.Report(defs, map[uuid.UUID]types.CustomFields{id: {"seats": "many", "legacy": "x"}})
+	g.Expect(violations).To(HaveLen(3))
+	g.Expect(violations[0].Key).To(Equal("legacy"))
+	g.Expect(violations[1].Key).To(Equal("seats"))
+	g.Expect(violations[2].Key).To(Equal("tier"))
+}
+
+func TestParseFilter(t *testing.T) {
+	g := NewWithT(t)
+	filter, err := customfields.ParseFilter(defs, url.Values{"customField.seats": {"5"}, "other": {"x"}}, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(filter).To(Equal(types.CustomFields{"seats": 5.0}))
+
+	_, err = customfields.ParseFilter(defs, url.Values{"customField.tier": {"gold"}}, true)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,175 @@
+// Package dataexport assembles archives of all data that is stored about a customer, so that customers can learn what
+// is held about them.
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/secretscan"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+)
+
+// LinkValidity is the time during which the archive of a completed export can be downloaded.
+const LinkValidity = 7 * 24 * time.Hour
+
+// RequestInterval is the minimum time between two exports of the same customer.
+const RequestInterval = 24 * time.Hour
+
+const keyLen = 32
+
+var ErrDecryptionFailed = errors.New("decryption failed, the key is probably wrong")
+
+// redactor replaces secrets in deployment values. In addition to the rules of the secret scan, every value that is
+// assigned to a key that looks like a credential is redacted, regardless of its entropy.
+var redactor = secretscan.New(secretscan.Rule{
+	ID:          "credential",
+	Description: "Value assigned to a key that looks like a credential",
+	Pattern: regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|` +
+		`private[_-]?key|client[_-]?secret)[A-Za-z0-9_-]*["']?\s*[:=]\s*["']?([^\s"',;#]+)`),
+})
+
+// Build returns a zip archive with the data of the customer of export: the user account, deployment targets,
+// deployments with redacted values, licenses, audit log entries where the customer is the actor and a summary of the
+// collected logs and metrics.
+func Build(ctx context.Context, export types.CustomerDataExport) ([]byte, error) {
+	user, err := db.GetUserAccountWithRole(ctx, export.CustomerUserAccountID, export.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	targets, _, err := db.GetDeploymentTargets(
+		ctx,
+		export.OrganizationID,
+		export.CustomerUserAccountID,
+		types.UserRoleCustomer,
+		types.DeploymentTargetFilter{IncludeArchived: true},
+		pagination.Page{},
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	targetIDs := make([]uuid.UUID, len(targets))
+	for i := range targets {
+		targetIDs[i] = targets[i].ID
+		targets[i].Deployment = nil //nolint:staticcheck
+		for j := range targets[i].Deployments {
+			deployment := &targets[i].Deployments[j]
+			deployment.ValuesYaml = redact(deployment.ValuesYaml)
+			deployment.EnvFileData = redact(deployment.EnvFileData)
+		}
+	}
+	licenses, err := db.GetApplicationLicensesWithOwnerID(
+		ctx, export.CustomerUserAccountID, export.OrganizationID, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for i := range licenses {
+		licenses[i].RegistryPassword = nil
+	}
+	auditLog, err := db.GetAuditLogEntriesByUserAccount(ctx, export.OrganizationID, export.CustomerUserAccountID)
+	if err != nil {
+		return nil, err
+	}
+	collectedData, err := db.GetDeploymentTargetDataSummaries(ctx, targetIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"user-account.json", user},
+		{"deployment-targets.json", targets},
+		{"licenses.json", licenses},
+		{"audit-log.json", auditLog},
+		{"collected-data.json", collectedData},
+	} {
+		if w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.CreatedAt,
+		}); err != nil {
+			return nil, err
+		} else {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(file.data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func redact(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	return redactor.Redact(data)
+}
+
+// Encrypt encrypts data with a new random key using AES-GCM. The result contains the nonce. The key is returned
+// encoded, so that it can be used in a URL.
+func Encrypt(data []byte) (encrypted []byte, key string, err error) {
+	rawKey := make([]byte, keyLen)
+	if _, err := rand.Read(rawKey); err != nil {
+		return nil, "", err
+	}
+	gcm, err := newGCM(rawKey)
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return slices.Concat(nonce, gcm.Seal(nil, nonce, data, nil)), base64.RawURLEncoding.EncodeToString(rawKey), nil
+}
+
+// Decrypt reverses Encrypt.
+func Decrypt(key string, encrypted []byte) ([]byte, error) {
+	rawKey, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(rawKey) != keyLen {
+		return nil, ErrDecryptionFailed
+	}
+	gcm, err := newGCM(rawKey)
+	if err != nil {
+		return nil, err
+	} else if len(encrypted) < gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	if result, err := gcm.Open(nil, encrypted[:gcm.NonceSize()], encrypted[gcm.NonceSize():], nil); err != nil {
+		return nil, ErrDecryptionFailed
+	} else {
+		return result, nil
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,36 @@
+package dataexport
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	g := NewWithT(t)
+	encrypted, key, err := Encrypt([]byte("archive"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(encrypted)).NotTo(ContainSubstring("archive"))
+
+	decrypted, err := Decrypt(key, encrypted)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(decrypted)).To(Equal("archive"))
+
+	_, otherKey, err := Encrypt(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = Decrypt(otherKey, encrypted)
+	g.Expect(err).To(MatchError(ErrDecryptionFailed))
+	_, err = Decrypt("", encrypted)
+	g.Expect(err).To(MatchError(ErrDecryptionFailed))
+	_, err = Decrypt(key, encrypted[:4])
+	g.Expect(err).To(MatchError(ErrDecryptionFailed))
+}
+
+func TestRedact(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(redact(nil)).To(BeNil())
+	redacted := string(redact([]byte("replicas: 2\ndb:\n  password: hunter2\nAPI_KEY=abc\n")))
+	g.Expect(redacted).To(ContainSubstring("replicas: 2"))
+	g.Expect(redacted).NotTo(ContainSubstring("hunter2"))
+	g.Expect(redacted).NotTo(ContainSubstring("abc"))
+}
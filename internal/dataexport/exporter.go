@@ -0,0 +1,75 @@
+package dataexport
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// Exporter assembles the archives of pending data exports and deletes the archives of expired ones.
+type Exporter struct {
+	mailer mail.Mailer
+	now    func() time.Time
+}
+
+func NewExporter(mailer mail.Mailer) *Exporter {
+	return &Exporter{mailer: mailer, now: clock.Now}
+}
+
+func (e *Exporter) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	ctx = internalctx.WithMailer(ctx, e.mailer)
+	now := e.now()
+
+	expired, err := db.DeleteExpiredCustomerDataExportData(ctx, now)
+	if err != nil {
+		return err
+	}
+	exports, err := db.GetPendingCustomerDataExports(ctx)
+	if err != nil {
+		return err
+	}
+	var count int
+	var errs []error
+	for _, export := range exports {
+		if err := e.export(ctx, export, now); errors.Is(err, apierrors.ErrNotFound) {
+			// another replica was faster
+			continue
+		} else if err != nil {
+			log.Warn("could not export customer data", zap.Stringer("exportId", export.ID), zap.Error(err))
+			errs = append(errs, err)
+		} else {
+			count++
+		}
+	}
+	log.Info("customer data export finished", zap.Int("exported", count), zap.Int64("expired", expired))
+	return errors.Join(errs...)
+}
+
+// export assembles and stores the encrypted archive of export and sends the download link to the requester. The key
+// is only part of the link, so the export stays pending if the mail can not be sent.
+func (e *Exporter) export(ctx context.Context, export types.CustomerDataExport, now time.Time) error {
+	archive, err := Build(ctx, export)
+	if err != nil {
+		return err
+	}
+	encrypted, key, err := Encrypt(archive)
+	if err != nil {
+		return err
+	}
+	return db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.CompleteCustomerDataExport(ctx, &export, encrypted, now.Add(LinkValidity)); err != nil {
+			return err
+		}
+		return mailsending.SendCustomerDataExportReadyMail(ctx, export, key)
+	})
+}
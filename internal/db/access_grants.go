@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const accessGrantOutputExpr = `
+	g.id,
+	g.created_at,
+	g.organization_id,
+	g.user_account_id,
+	g.deployment_target_id,
+	g.customer_user_account_id,
+	g.granted_by_user_account_id,
+	g.reason,
+	g.expires_at,
+	g.revoked_at,
+	g.revoked_by_user_account_id
+`
+
+func CreateAccessGrant(ctx context.Context, grant *types.AccessGrant) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO AccessGrant AS g (
+			organization_id, user_account_id, deployment_target_id, customer_user_account_id,
+			granted_by_user_account_id, reason, expires_at
+		) VALUES (
+			@organizationId, @userAccountId, @deploymentTargetId, @customerUserAccountId,
+			@grantedBy, @reason, @expiresAt
+		) RETURNING`+accessGrantOutputExpr,
+		pgx.NamedArgs{
+			"organizationId":        grant.OrganizationID,
+			"userAccountId":         grant.UserAccountID,
+			"deploymentTargetId":    grant.DeploymentTargetID,
+			"customerUserAccountId": grant.CustomerUserAccountID,
+			"grantedBy":             grant.GrantedByUserAccountID,
+			"reason":                grant.Reason,
+			"expiresAt":             grant.ExpiresAt,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert AccessGrant: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.AccessGrant]); err != nil {
+		return fmt.Errorf("could not insert AccessGrant: %w", err)
+	} else {
+		*grant = result
+		return nil
+	}
+}
+
+func GetAccessGrant(ctx context.Context, id, orgID uuid.UUID) (*types.AccessGrant, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+accessGrantOutputExpr+`FROM AccessGrant g WHERE g.id = @id AND g.organization_id = @orgId`,
+		pgx.NamedArgs{"id": id, "orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query AccessGrant: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.AccessGrant]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get AccessGrant: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetAccessGrants returns the access grants of an organization, the newest first. If deploymentTargetID is not nil,
+// only grants that cover this deployment target are returned, including grants for the customer account that owns it.
+// If activeOnly is true, grants that have expired or have been revoked are omitted.
+func GetAccessGrants(
+	ctx context.Context,
+	orgID uuid.UUID,
+	deploymentTargetID *uuid.UUID,
+	activeOnly bool,
+) ([]types.AccessGrant, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+accessGrantOutputExpr+`
+		FROM AccessGrant g
+		WHERE g.organization_id = @orgId
+			AND (
+				@deploymentTargetId::UUID IS NULL
+				OR g.deployment_target_id = @deploymentTargetId
+				OR g.customer_user_account_id = (
+					SELECT dt.created_by_user_account_id FROM DeploymentTarget dt WHERE dt.id = @deploymentTargetId
+				)
+			)
+			AND (NOT @activeOnly OR (g.revoked_at IS NULL AND g.expires_at > now()))
+		ORDER BY g.created_at DESC`,
+		pgx.NamedArgs{"orgId": orgID, "deploymentTargetId": deploymentTargetID, "activeOnly": activeOnly},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query AccessGrant: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.AccessGrant]); err != nil {
+		return nil, fmt.Errorf("could not collect AccessGrant: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetActiveAccessGrant returns the grant that gives userID access to dt at time t. If multiple grants are active, the
+// one that expires last is returned. If there is none, apierrors.ErrNotFound is returned.
+func GetActiveAccessGrant(
+	ctx context.Context,
+	userID uuid.UUID,
+	dt *types.DeploymentTarget,
+	t time.Time,
+) (*types.AccessGrant, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+accessGrantOutputExpr+`
+		FROM AccessGrant g
+		WHERE g.organization_id = @orgId
+			AND g.user_account_id = @userId
+			AND (g.deployment_target_id = @deploymentTargetId OR g.customer_user_account_id = @customerId)
+			AND g.revoked_at IS NULL
+			AND g.expires_at > @t
+		ORDER BY g.expires_at DESC
+		LIMIT 1`,
+		pgx.NamedArgs{
+			"orgId":              dt.OrganizationID,
+			"userId":             userID,
+			"deploymentTargetId": dt.ID,
+			"customerId":         dt.CreatedByUserAccountID,
+			"t":                  t,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query AccessGrant: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.AccessGrant]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get AccessGrant: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// RevokeAccessGrant ends grant immediately. It returns apierrors.ErrConflict if grant is no longer active.
+func RevokeAccessGrant(ctx context.Context, grant *types.AccessGrant, userID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE AccessGrant AS g
+		SET revoked_at = now(), revoked_by_user_account_id = @userId
+		WHERE g.id = @id AND g.revoked_at IS NULL AND g.expires_at > now()
+		RETURNING`+accessGrantOutputExpr,
+		pgx.NamedArgs{"id": grant.ID, "userId": userID},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update AccessGrant: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.AccessGrant]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrConflict
+		}
+		return fmt.Errorf("could not update AccessGrant: %w", err)
+	} else {
+		*grant = result
+		return nil
+	}
+}
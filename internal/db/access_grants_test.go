@@ -0,0 +1,97 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetActiveAccessGrant(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 2, 1)
+	vendor, other, customer := org.Vendors[0], org.Vendors[1], org.Customers[0]
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	now := time.Now()
+
+	_, err := db.GetActiveAccessGrant(ctx, vendor.ID, &dt.DeploymentTarget, now)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	targetGrant := types.AccessGrant{
+		OrganizationID:         org.ID,
+		UserAccountID:          vendor.ID,
+		DeploymentTargetID:     &dt.ID,
+		GrantedByUserAccountID: &other.ID,
+		Reason:                 "ticket 1",
+		ExpiresAt:              now.Add(time.Hour),
+	}
+	g.Expect(db.CreateAccessGrant(ctx, &targetGrant)).To(Succeed())
+	customerGrant := types.AccessGrant{
+		OrganizationID:        org.ID,
+		UserAccountID:         vendor.ID,
+		CustomerUserAccountID: &customer.ID,
+		Reason:                "ticket 2",
+		ExpiresAt:             now.Add(2 * time.Hour),
+	}
+	g.Expect(db.CreateAccessGrant(ctx, &customerGrant)).To(Succeed())
+
+	active, err := db.GetActiveAccessGrant(ctx, vendor.ID, &dt.DeploymentTarget, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(active.ID).To(Equal(customerGrant.ID))
+
+	// expiry is enforced without any job
+	active, err = db.GetActiveAccessGrant(ctx, vendor.ID, &dt.DeploymentTarget, now.Add(90*time.Minute))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(active.ID).To(Equal(customerGrant.ID))
+	_, err = db.GetActiveAccessGrant(ctx, vendor.ID, &dt.DeploymentTarget, now.Add(3*time.Hour))
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	_, err = db.GetActiveAccessGrant(ctx, other.ID, &dt.DeploymentTarget, now)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.RevokeAccessGrant(ctx, &customerGrant, other.ID)).To(Succeed())
+	g.Expect(customerGrant.RevokedAt).NotTo(BeNil())
+	g.Expect(db.RevokeAccessGrant(ctx, &customerGrant, other.ID)).To(MatchError(apierrors.ErrConflict))
+	active, err = db.GetActiveAccessGrant(ctx, vendor.ID, &dt.DeploymentTarget, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(active.ID).To(Equal(targetGrant.ID))
+
+	all, err := db.GetAccessGrants(ctx, org.ID, &dt.ID, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(HaveLen(2))
+	activeOnly, err := db.GetAccessGrants(ctx, org.ID, &dt.ID, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(activeOnly).To(ConsistOf(HaveField("ID", targetGrant.ID)))
+}
+
+func TestCreateAuditLogEntryWithAccessGrant(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Customers[0].ID)
+	grant := types.AccessGrant{
+		OrganizationID:     org.ID,
+		UserAccountID:      org.Vendors[0].ID,
+		DeploymentTargetID: &dt.ID,
+		Reason:             "ticket",
+		ExpiresAt:          time.Now().Add(time.Hour),
+	}
+	g.Expect(db.CreateAccessGrant(ctx, &grant)).To(Succeed())
+
+	entry := types.AuditLogEntry{
+		OrganizationID: &org.ID,
+		UserAccountID:  util.PtrTo(org.Vendors[0].ID),
+		Action:         "access",
+		ResourceType:   "DeploymentTarget",
+		ResourceID:     dt.ID,
+	}
+	g.Expect(db.CreateAuditLogEntry(internalctx.WithAccessGrant(ctx, &grant), &entry)).To(Succeed())
+	g.Expect(entry.AccessGrantID).To(HaveValue(Equal(grant.ID)))
+}
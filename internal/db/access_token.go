@@ -7,6 +7,7 @@ import (
 
 	"github.com/glasskube/distr/internal/apierrors"
 	"github.com/glasskube/distr/internal/authkey"
+	"github.com/glasskube/distr/internal/clock"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/google/uuid"
@@ -15,10 +16,14 @@ import (
 
 const (
 	accessTokenOutputExpr = `
-	tok.id, tok.created_at, tok.expires_at, tok.last_used_at, tok.label, tok.key, tok.user_account_id, tok.organization_id
+	tok.id, tok.created_at, tok.expires_at, tok.last_used_at, tok.label, tok.key_hash, tok.key_prefix,
+	tok.user_account_id, tok.organization_id, tok.registry_scopes
 `
 	accessTokenWithUserAccountOutputExpr = accessTokenOutputExpr + `,
 	(` + userAccountOutputExpr + `) AS user_account, oua.user_role
+`
+	organizationAccessTokenOutputExpr = `
+	tok.id, tok.created_at, tok.expires_at, tok.last_used_at, tok.label, tok.key_prefix, tok.registry_scopes
 `
 )
 
@@ -27,16 +32,21 @@ func CreateAccessToken(ctx context.Context, token *types.AccessToken) error {
 	rows, err := db.Query(
 		ctx,
 		fmt.Sprintf(
-			`INSERT INTO AccessToken AS tok (label, expires_at, key, user_account_id, organization_id)
-			VALUES (@label, @expiresAt, @key, @userAccountId, @orgId)
+			`INSERT INTO AccessToken AS tok (
+				label, expires_at, key_hash, key_prefix, user_account_id, organization_id, registry_scopes
+			)
+			VALUES (@label, @expiresAt, @keyHash, @keyPrefix, @userAccountId, @orgId,
+				NULLIF(@registryScopes::jsonb, 'null'::jsonb))
 			RETURNING %v`,
 			accessTokenOutputExpr),
 		pgx.NamedArgs{
-			"label":         token.Label,
-			"expiresAt":     token.ExpiresAt,
-			"key":           token.Key[:],
-			"userAccountId": token.UserAccountID,
-			"orgId":         token.OrganizationID,
+			"label":          token.Label,
+			"expiresAt":      token.ExpiresAt,
+			"keyHash":        token.KeyHash,
+			"keyPrefix":      token.KeyPrefix,
+			"userAccountId":  token.UserAccountID,
+			"orgId":          token.OrganizationID,
+			"registryScopes": token.RegistryScopes,
 		},
 	)
 	if err != nil {
@@ -93,7 +103,7 @@ func GetAccessTokenByKeyUpdatingLastUsed(
 			`WITH updated AS (
 				UPDATE AccessToken
 				SET last_used_at = now()
-				WHERE key = @key AND (expires_at IS NULL OR expires_at > now())
+				WHERE key_hash = @keyHash AND (expires_at IS NULL OR expires_at > @now)
 				RETURNING *
 			)
 			SELECT %v FROM updated tok
@@ -103,7 +113,7 @@ func GetAccessTokenByKeyUpdatingLastUsed(
 			`,
 			accessTokenWithUserAccountOutputExpr,
 		),
-		pgx.NamedArgs{"key": key[:]},
+		pgx.NamedArgs{"keyHash": key.Hash(), "now": clock.Now()},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error querying access token: %w", err)
@@ -113,6 +123,8 @@ func GetAccessTokenByKeyUpdatingLastUsed(
 			err = apierrors.ErrNotFound
 		}
 		return nil, fmt.Errorf("could not get token: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	} else {
 		return &result, nil
 	}
@@ -129,3 +141,84 @@ func DeleteAccessTokensOfUserInOrg(ctx context.Context, userID, orgID uuid.UUID)
 	}
 	return nil
 }
+
+// organizationAccessTokensSelectExpr selects the tokens of users from userTokens and the tokens of service accounts
+// from serviceAccountTokens, which must both be aliased as tok.
+func organizationAccessTokensSelectExpr(userTokens, serviceAccountTokens string) string {
+	return fmt.Sprintf(`
+		SELECT %[1]v, (%[3]v) AS user_account, oua.user_role, NULL AS service_account
+		FROM %[4]v
+		INNER JOIN UserAccount u ON tok.user_account_id = u.id
+		LEFT JOIN Organization_UserAccount oua
+			ON oua.user_account_id = tok.user_account_id AND oua.organization_id = tok.organization_id
+		UNION ALL
+		SELECT %[1]v, NULL, NULL, (%[2]v)
+		FROM %[5]v
+		INNER JOIN ServiceAccount sa ON tok.service_account_id = sa.id
+		ORDER BY created_at DESC`,
+		organizationAccessTokenOutputExpr, serviceAccountOutputExpr, userAccountOutputExpr,
+		userTokens, serviceAccountTokens,
+	)
+}
+
+// GetAccessTokensOfOrganization returns the access tokens of all users and service accounts of the organization,
+// newest first. Tokens of users that are no longer members of the organization are included, too.
+func GetAccessTokensOfOrganization(ctx context.Context, orgID uuid.UUID) ([]types.OrganizationAccessToken, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		organizationAccessTokensSelectExpr(
+			"(SELECT * FROM AccessToken WHERE organization_id = @orgId) tok",
+			`(SELECT sat.* FROM ServiceAccountToken sat
+				INNER JOIN ServiceAccount sa ON sat.service_account_id = sa.id
+				WHERE sa.organization_id = @orgId) tok`,
+		),
+		pgx.NamedArgs{"orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying access tokens: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.OrganizationAccessToken]); err != nil {
+		return nil, fmt.Errorf("could not get tokens: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// RevokeAccessTokens deletes the access tokens of users and service accounts of the organization with the given IDs
+// or, if userID is not nil, all tokens of that user in the organization. Tokens are verified with every request, so a
+// revoked token can not be used anymore as soon as the transaction is committed. The revoked tokens are returned.
+func RevokeAccessTokens(
+	ctx context.Context,
+	orgID uuid.UUID,
+	ids []uuid.UUID,
+	userID *uuid.UUID,
+) ([]types.OrganizationAccessToken, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`WITH deleted AS (
+			DELETE FROM AccessToken
+			WHERE organization_id = @orgId AND (id = any(@ids) OR user_account_id = @userId)
+			RETURNING *
+		), deleted_service_account_tokens AS (
+			DELETE FROM ServiceAccountToken sat
+			USING ServiceAccount sa
+			WHERE sat.service_account_id = sa.id AND sa.organization_id = @orgId AND sat.id = any(@ids)
+			RETURNING sat.*
+		)`+organizationAccessTokensSelectExpr("deleted tok", "deleted_service_account_tokens tok"),
+		pgx.NamedArgs{"orgId": orgID, "ids": ids, "userId": userID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not revoke tokens: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.OrganizationAccessToken]); err != nil {
+		return nil, fmt.Errorf("could not revoke tokens: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
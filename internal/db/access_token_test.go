@@ -0,0 +1,122 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/authkey"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestAccessTokenRegistryScopes(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+
+	newToken := func(scopes types.RegistryScopes) (authkey.Key, types.AccessToken) {
+		key, err := authkey.NewKey()
+		g.Expect(err).NotTo(HaveOccurred())
+		token := types.AccessToken{
+			UserAccountID:  org.Vendors[0].ID,
+			OrganizationID: org.ID,
+			KeyHash:        key.Hash(),
+			KeyPrefix:      key.DisplayPrefix(),
+			RegistryScopes: scopes,
+		}
+		g.Expect(db.CreateAccessToken(ctx, &token)).To(Succeed())
+		return key, token
+	}
+
+	unscopedKey, unscoped := newToken(nil)
+	g.Expect(unscoped.RegistryScopes).To(BeNil())
+	loaded, err := db.GetAccessTokenByKeyUpdatingLastUsed(ctx, unscopedKey)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.RegistryScopes).To(BeNil())
+
+	scopes := types.RegistryScopes{
+		{Repository: *org.Slug + "/app", Actions: []types.RegistryAction{types.RegistryActionPull}},
+		{Repository: *org.Slug + "/ci-*", Actions: []types.RegistryAction{types.RegistryActionPush}},
+	}
+	scopedKey, scoped := newToken(scopes)
+	g.Expect(scoped.RegistryScopes).To(Equal(scopes))
+	loaded, err = db.GetAccessTokenByKeyUpdatingLastUsed(ctx, scopedKey)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.RegistryScopes).To(Equal(scopes))
+	g.Expect(loaded.RegistryScopes.Allow(*org.Slug+"/app", types.RegistryActionPull)).To(BeTrue())
+	g.Expect(loaded.RegistryScopes.Allow(*org.Slug+"/app", types.RegistryActionPush)).To(BeFalse())
+	g.Expect(loaded.RegistryScopes.Allow(*org.Slug+"/ci-runner", types.RegistryActionPush)).To(BeTrue())
+	g.Expect(loaded.RegistryScopes.Allow(*org.Slug+"/other", types.RegistryActionPull)).To(BeFalse())
+}
+
+func TestRevokeAccessTokens(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 2, 1)
+	otherOrg := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendorToken, _ := testutil.NewAccessToken(ctx, t, org.Vendors[0].ID, org.ID)
+	customerToken, _ := testutil.NewAccessToken(ctx, t, org.Customers[0].ID, org.ID)
+	secondCustomerToken, _ := testutil.NewAccessToken(ctx, t, org.Customers[0].ID, org.ID)
+	formerMemberToken, _ := testutil.NewAccessToken(ctx, t, org.Vendors[1].ID, org.ID)
+	otherToken, _ := testutil.NewAccessToken(ctx, t, otherOrg.Vendors[0].ID, otherOrg.ID)
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"DELETE FROM Organization_UserAccount WHERE user_account_id = $1", org.Vendors[1].ID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	sa := types.ServiceAccount{OrganizationID: org.ID, Name: "ci"}
+	g.Expect(db.CreateServiceAccount(ctx, &sa)).To(Succeed())
+	key, err := authkey.NewKey()
+	g.Expect(err).NotTo(HaveOccurred())
+	serviceAccountToken := types.ServiceAccountToken{
+		ServiceAccountID: sa.ID,
+		KeyHash:          key.Hash(),
+		KeyPrefix:        key.DisplayPrefix(),
+	}
+	g.Expect(db.CreateServiceAccountToken(ctx, &serviceAccountToken)).To(Succeed())
+
+	tokens, err := db.GetAccessTokensOfOrganization(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tokens).To(HaveLen(5))
+	for _, token := range tokens {
+		g.Expect(token.ID).NotTo(Equal(otherToken.ID))
+		switch token.ID {
+		case vendorToken.ID:
+			g.Expect(token.UserRole).To(HaveValue(Equal(types.UserRoleVendor)))
+			g.Expect(token.UserAccount.Email).To(Equal(org.Vendors[0].Email))
+		case formerMemberToken.ID:
+			g.Expect(token.UserRole).To(BeNil())
+			g.Expect(token.UserAccount.ID).To(Equal(org.Vendors[1].ID))
+		case serviceAccountToken.ID:
+			g.Expect(token.UserAccount).To(BeNil())
+			g.Expect(token.ServiceAccount.Name).To(Equal(sa.Name))
+		default:
+			g.Expect(token.UserRole).To(HaveValue(Equal(types.UserRoleCustomer)))
+			g.Expect(token.ServiceAccount).To(BeNil())
+		}
+	}
+
+	// tokens of other organizations can not be revoked
+	revoked, err := db.RevokeAccessTokens(ctx, org.ID, []uuid.UUID{otherToken.ID}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(revoked).To(BeEmpty())
+
+	revoked, err = db.RevokeAccessTokens(ctx, org.ID, []uuid.UUID{vendorToken.ID, serviceAccountToken.ID}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(revoked).To(ConsistOf(HaveField("ID", vendorToken.ID), HaveField("ID", serviceAccountToken.ID)))
+	_, err = db.GetServiceAccountTokenByKeyUpdatingLastUsed(ctx, key)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	revoked, err = db.RevokeAccessTokens(ctx, org.ID, nil, &org.Customers[0].ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(revoked).To(ConsistOf(HaveField("ID", customerToken.ID), HaveField("ID", secondCustomerToken.ID)))
+
+	revoked, err = db.RevokeAccessTokens(ctx, org.ID, nil, &org.Vendors[1].ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(revoked).To(ConsistOf(HaveField("ID", formerMemberToken.ID)))
+	g.Expect(db.GetAccessTokensOfOrganization(ctx, org.ID)).To(BeEmpty())
+	g.Expect(db.GetAccessTokensOfOrganization(ctx, otherOrg.ID)).To(HaveLen(1))
+}
@@ -103,3 +103,24 @@ func GetAgentVersionWithName(ctx context.Context, name string) (*types.AgentVers
 		return &result, nil
 	}
 }
+
+func GetAgentVersionByID(ctx context.Context, id uuid.UUID) (*types.AgentVersion, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT av.id, av.created_at, av.name, av.manifest_file_revision, av.compose_file_revision
+		FROM AgentVersion av
+		WHERE av.id = @id`,
+		pgx.NamedArgs{"id": id},
+	)
+	if err != nil {
+		return nil, err
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.AgentVersion]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		} else {
+			return nil, err
+		}
+	} else {
+		return &result, nil
+	}
+}
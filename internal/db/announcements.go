@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	announcementOutputExpr = `
+		a.id, a.created_at, a.organization_id, a.created_by_useraccount_id, a.title, a.body, a.severity, a.audience,
+		coalesce((
+			SELECT array_agg(ac.useraccount_id) FROM Announcement_Customer ac WHERE ac.announcement_id = a.id
+		), array[]::uuid[]) AS customer_ids,
+		coalesce((
+			SELECT array_agg(adt.deployment_target_id)
+			FROM Announcement_DeploymentTarget adt
+			WHERE adt.announcement_id = a.id
+		), array[]::uuid[]) AS deployment_target_ids,
+		a.starts_at, a.ends_at
+	`
+	userAnnouncementOutputExpr = announcementOutputExpr + `, r.read_at, r.dismissed_at`
+)
+
+func GetAnnouncements(ctx context.Context, orgID uuid.UUID) ([]types.Announcement, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+announcementOutputExpr+
+			"FROM Announcement a "+
+			"WHERE a.organization_id = @orgId "+
+			"ORDER BY a.starts_at DESC, a.created_at DESC",
+		pgx.NamedArgs{"orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Announcements: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.Announcement])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Announcements: %w", err)
+	}
+	return result, nil
+}
+
+func GetAnnouncement(ctx context.Context, id, orgID uuid.UUID) (*types.Announcement, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+announcementOutputExpr+
+			"FROM Announcement a "+
+			"WHERE a.id = @id AND a.organization_id = @orgId",
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Announcement: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Announcement])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get Announcement: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// CreateAnnouncement creates an announcement with its audience. It returns a validation error if the audience
+// contains customers or deployment targets that do not belong to the organization.
+func CreateAnnouncement(ctx context.Context, announcement *types.Announcement) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		rows, err := db.Query(ctx,
+			`INSERT INTO Announcement AS a
+				(organization_id, created_by_useraccount_id, title, body, severity, audience, starts_at, ends_at)
+				VALUES (@orgId, @createdBy, @title, @body, @severity, @audience, @startsAt, @endsAt)
+				RETURNING id`,
+			pgx.NamedArgs{
+				"orgId":     announcement.OrganizationID,
+				"createdBy": announcement.CreatedByUserAccountID,
+				"title":     announcement.Title,
+				"body":      announcement.Body,
+				"severity":  announcement.Severity,
+				"audience":  announcement.Audience,
+				"startsAt":  announcement.StartsAt,
+				"endsAt":    announcement.EndsAt,
+			})
+		if err != nil {
+			return fmt.Errorf("failed to insert Announcement: %w", err)
+		}
+		id, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[uuid.UUID])
+		if err != nil {
+			return fmt.Errorf("failed to insert Announcement: %w", err)
+		}
+		return setAnnouncementAudience(ctx, id, announcement)
+	})
+}
+
+// UpdateAnnouncement updates an announcement and replaces its audience. Receipts of users are kept.
+func UpdateAnnouncement(ctx context.Context, announcement *types.Announcement) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		cmd, err := db.Exec(ctx,
+			`UPDATE Announcement
+			SET title = @title, body = @body, severity = @severity, audience = @audience, starts_at = @startsAt,
+				ends_at = @endsAt
+			WHERE id = @id AND organization_id = @orgId`,
+			pgx.NamedArgs{
+				"id":       announcement.ID,
+				"orgId":    announcement.OrganizationID,
+				"title":    announcement.Title,
+				"body":     announcement.Body,
+				"severity": announcement.Severity,
+				"audience": announcement.Audience,
+				"startsAt": announcement.StartsAt,
+				"endsAt":   announcement.EndsAt,
+			})
+		if err != nil {
+			return fmt.Errorf("failed to update Announcement: %w", err)
+		} else if cmd.RowsAffected() == 0 {
+			return apierrors.ErrNotFound
+		}
+		return setAnnouncementAudience(ctx, announcement.ID, announcement)
+	})
+}
+
+// setAnnouncementAudience replaces the customers and deployment targets of an announcement and reloads it into
+// announcement.
+func setAnnouncementAudience(ctx context.Context, id uuid.UUID, announcement *types.Announcement) error {
+	db := internalctx.GetDb(ctx)
+	customerIDs := uniqueUUIDs(announcement.CustomerIDs)
+	targetIDs := uniqueUUIDs(announcement.DeploymentTargetIDs)
+	args := pgx.NamedArgs{
+		"id":          id,
+		"orgId":       announcement.OrganizationID,
+		"customerIds": customerIDs,
+		"targetIds":   targetIDs,
+	}
+	if _, err := db.Exec(ctx, `DELETE FROM Announcement_Customer WHERE announcement_id = @id`, args); err != nil {
+		return fmt.Errorf("could not delete Announcement_Customer: %w", err)
+	} else if _, err := db.Exec(ctx,
+		`DELETE FROM Announcement_DeploymentTarget WHERE announcement_id = @id`, args,
+	); err != nil {
+		return fmt.Errorf("could not delete Announcement_DeploymentTarget: %w", err)
+	}
+	if cmd, err := db.Exec(ctx,
+		`INSERT INTO Announcement_Customer (announcement_id, useraccount_id)
+		SELECT @id, oua.user_account_id
+		FROM Organization_UserAccount oua
+		WHERE oua.organization_id = @orgId AND oua.user_role = 'customer' AND oua.user_account_id = ANY(@customerIds)`,
+		args,
+	); err != nil {
+		return fmt.Errorf("could not insert Announcement_Customer: %w", err)
+	} else if cmd.RowsAffected() != int64(len(customerIDs)) {
+		return validation.NewValidationFailedError("customerIds must be customers of the organization")
+	}
+	if cmd, err := db.Exec(ctx,
+		`INSERT INTO Announcement_DeploymentTarget (announcement_id, deployment_target_id)
+		SELECT @id, dt.id
+		FROM DeploymentTarget dt
+		WHERE dt.organization_id = @orgId AND dt.id = ANY(@targetIds)`,
+		args,
+	); err != nil {
+		return fmt.Errorf("could not insert Announcement_DeploymentTarget: %w", err)
+	} else if cmd.RowsAffected() != int64(len(targetIDs)) {
+		return validation.NewValidationFailedError("deploymentTargetIds must be deployment targets of the organization")
+	}
+	if result, err := GetAnnouncement(ctx, id, announcement.OrganizationID); err != nil {
+		return err
+	} else {
+		*announcement = *result
+		return nil
+	}
+}
+
+func DeleteAnnouncement(ctx context.Context, id, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`DELETE FROM Announcement WHERE id = @id AND organization_id = @orgId`,
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return fmt.Errorf("failed to delete Announcement: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+// GetActiveUserAnnouncements returns the announcements that are active at now and addressed to the user, together
+// with the receipt of the user. Vendors get all active announcements of the organization. Dismissed announcements
+// are only included if includeDismissed is true.
+func GetActiveUserAnnouncements(
+	ctx context.Context,
+	orgID, userID uuid.UUID,
+	userRole types.UserRole,
+	now time.Time,
+	includeDismissed bool,
+) ([]types.UserAnnouncement, error) {
+	return getActiveUserAnnouncements(ctx, nil, orgID, userID, userRole, now, includeDismissed)
+}
+
+// GetActiveUserAnnouncement returns a single announcement like GetActiveUserAnnouncements. It returns
+// apierrors.ErrNotFound if the announcement is not active or not addressed to the user.
+func GetActiveUserAnnouncement(
+	ctx context.Context,
+	id, orgID, userID uuid.UUID,
+	userRole types.UserRole,
+	now time.Time,
+) (*types.UserAnnouncement, error) {
+	if result, err := getActiveUserAnnouncements(ctx, &id, orgID, userID, userRole, now, true); err != nil {
+		return nil, err
+	} else if len(result) == 0 {
+		return nil, apierrors.ErrNotFound
+	} else {
+		return &result[0], nil
+	}
+}
+
+func getActiveUserAnnouncements(
+	ctx context.Context,
+	id *uuid.UUID,
+	orgID, userID uuid.UUID,
+	userRole types.UserRole,
+	now time.Time,
+	includeDismissed bool,
+) ([]types.UserAnnouncement, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+userAnnouncementOutputExpr+
+			`FROM Announcement a
+			LEFT JOIN AnnouncementReceipt r ON r.announcement_id = a.id AND r.useraccount_id = @userId
+			WHERE a.organization_id = @orgId
+				AND (@id::UUID IS NULL OR a.id = @id)
+				AND a.starts_at <= @now AND a.ends_at > @now
+				AND (@includeDismissed OR r.dismissed_at IS NULL)
+				AND (
+					@userRole = 'vendor'
+					OR a.audience = 'all_customers'
+					OR (a.audience = 'customers' AND EXISTS (
+						SELECT 1 FROM Announcement_Customer ac
+						WHERE ac.announcement_id = a.id AND ac.useraccount_id = @userId
+					))
+					OR (a.audience = 'deployment_targets' AND EXISTS (
+						SELECT 1 FROM Announcement_DeploymentTarget adt
+							JOIN DeploymentTarget dt ON adt.deployment_target_id = dt.id
+						WHERE adt.announcement_id = a.id AND dt.created_by_user_account_id = @userId
+					))
+				)
+			ORDER BY a.severity DESC, a.starts_at DESC`,
+		pgx.NamedArgs{
+			"id":               id,
+			"orgId":            orgID,
+			"userId":           userID,
+			"userRole":         userRole,
+			"now":              now,
+			"includeDismissed": includeDismissed,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Announcements: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.UserAnnouncement])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Announcements: %w", err)
+	}
+	return result, nil
+}
+
+// GetActiveDeploymentTargetAnnouncements returns the announcements that are active at now and addressed to the
+// deployment target explicitly.
+func GetActiveDeploymentTargetAnnouncements(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	now time.Time,
+) ([]types.Announcement, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+announcementOutputExpr+
+			`FROM Announcement a
+			JOIN Announcement_DeploymentTarget t ON t.announcement_id = a.id
+			WHERE t.deployment_target_id = @deploymentTargetId AND a.starts_at <= @now AND a.ends_at > @now
+			ORDER BY a.severity DESC, a.starts_at DESC`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID, "now": now})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Announcements: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.Announcement])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Announcements: %w", err)
+	}
+	return result, nil
+}
+
+// MarkAnnouncementRead records that the user has read the announcement. Dismissing an announcement also marks it as
+// read. Existing timestamps are never overwritten.
+func MarkAnnouncementRead(ctx context.Context, announcementID, userID uuid.UUID, dismiss bool) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`INSERT INTO AnnouncementReceipt AS r (announcement_id, useraccount_id, read_at, dismissed_at)
+		VALUES (@announcementId, @userId, now(), CASE WHEN @dismiss THEN now() END)
+		ON CONFLICT (announcement_id, useraccount_id) DO UPDATE SET
+			read_at = coalesce(r.read_at, EXCLUDED.read_at),
+			dismissed_at = coalesce(r.dismissed_at, EXCLUDED.dismissed_at)`,
+		pgx.NamedArgs{"announcementId": announcementID, "userId": userID, "dismiss": dismiss},
+	); err != nil {
+		return fmt.Errorf("failed to upsert AnnouncementReceipt: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredAnnouncements deletes all announcements that have ended before now, together with their receipts.
+func CleanupExpiredAnnouncements(ctx context.Context, now time.Time) (int64, error) {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx, `DELETE FROM Announcement WHERE ends_at <= @now`, pgx.NamedArgs{"now": now})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete Announcements: %w", err)
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	result := slices.Clone(ids)
+	slices.SortFunc(result, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	return slices.Compact(result)
+}
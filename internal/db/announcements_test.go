@@ -0,0 +1,102 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestAnnouncements(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 3)
+	other := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Customers[2].ID)
+	now := time.Now()
+
+	newAnnouncement := func(audience types.AnnouncementAudience) types.Announcement {
+		return types.Announcement{
+			OrganizationID: org.ID,
+			Title:          string(audience),
+			Severity:       types.AnnouncementSeverityInfo,
+			Audience:       audience,
+			StartsAt:       now.Add(-time.Hour),
+			EndsAt:         now.Add(time.Hour),
+		}
+	}
+	all := newAnnouncement(types.AnnouncementAudienceAllCustomers)
+	g.Expect(db.CreateAnnouncement(ctx, &all)).To(Succeed())
+	customers := newAnnouncement(types.AnnouncementAudienceCustomers)
+	customers.CustomerIDs = []uuid.UUID{org.Customers[1].ID, org.Customers[1].ID}
+	g.Expect(db.CreateAnnouncement(ctx, &customers)).To(Succeed())
+	g.Expect(customers.CustomerIDs).To(ConsistOf(org.Customers[1].ID))
+	targets := newAnnouncement(types.AnnouncementAudienceDeploymentTargets)
+	targets.DeploymentTargetIDs = []uuid.UUID{target.ID}
+	targets.Severity = types.AnnouncementSeverityCritical
+	g.Expect(db.CreateAnnouncement(ctx, &targets)).To(Succeed())
+	future := newAnnouncement(types.AnnouncementAudienceAllCustomers)
+	future.StartsAt = now.Add(time.Hour)
+	future.EndsAt = now.Add(2 * time.Hour)
+	g.Expect(db.CreateAnnouncement(ctx, &future)).To(Succeed())
+
+	// the audience must belong to the organization
+	for _, customerID := range []uuid.UUID{other.Customers[0].ID, org.Vendors[0].ID} {
+		foreign := newAnnouncement(types.AnnouncementAudienceCustomers)
+		foreign.CustomerIDs = []uuid.UUID{customerID}
+		g.Expect(db.RunTx(ctx, func(ctx context.Context) error {
+			return db.CreateAnnouncement(ctx, &foreign)
+		})).To(MatchError(validation.ErrValidationFailed))
+	}
+
+	active := func(userID uuid.UUID, role types.UserRole, includeDismissed bool) []uuid.UUID {
+		result, err := db.GetActiveUserAnnouncements(ctx, org.ID, userID, role, now, includeDismissed)
+		g.Expect(err).NotTo(HaveOccurred())
+		ids := make([]uuid.UUID, len(result))
+		for i, a := range result {
+			ids[i] = a.ID
+		}
+		return ids
+	}
+	g.Expect(active(org.Vendors[0].ID, types.UserRoleVendor, false)).To(ConsistOf(all.ID, customers.ID, targets.ID))
+	g.Expect(active(org.Customers[0].ID, types.UserRoleCustomer, false)).To(ConsistOf(all.ID))
+	g.Expect(active(org.Customers[1].ID, types.UserRoleCustomer, false)).To(ConsistOf(all.ID, customers.ID))
+	g.Expect(active(org.Customers[2].ID, types.UserRoleCustomer, false)).To(Equal([]uuid.UUID{targets.ID, all.ID}))
+
+	agentAnnouncements, err := db.GetActiveDeploymentTargetAnnouncements(ctx, target.ID, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(agentAnnouncements).To(ConsistOf(HaveField("ID", targets.ID)))
+
+	// receipts
+	_, err = db.GetActiveUserAnnouncement(ctx, customers.ID, org.ID, org.Customers[0].ID, types.UserRoleCustomer, now)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(db.MarkAnnouncementRead(ctx, all.ID, org.Customers[0].ID, false)).To(Succeed())
+	read, err := db.GetActiveUserAnnouncement(ctx, all.ID, org.ID, org.Customers[0].ID, types.UserRoleCustomer, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(read.ReadAt).NotTo(BeNil())
+	g.Expect(read.DismissedAt).To(BeNil())
+	g.Expect(db.MarkAnnouncementRead(ctx, all.ID, org.Customers[0].ID, true)).To(Succeed())
+	g.Expect(active(org.Customers[0].ID, types.UserRoleCustomer, false)).To(BeEmpty())
+	g.Expect(active(org.Customers[0].ID, types.UserRoleCustomer, true)).To(ConsistOf(all.ID))
+	g.Expect(active(org.Customers[1].ID, types.UserRoleCustomer, false)).To(ContainElement(all.ID))
+
+	// updating replaces the audience
+	customers.Audience = types.AnnouncementAudienceAllCustomers
+	customers.CustomerIDs = nil
+	g.Expect(db.UpdateAnnouncement(ctx, &customers)).To(Succeed())
+	g.Expect(customers.CustomerIDs).To(BeEmpty())
+	g.Expect(active(org.Customers[0].ID, types.UserRoleCustomer, false)).To(ConsistOf(customers.ID))
+
+	count, err := db.CleanupExpiredAnnouncements(ctx, now.Add(90*time.Minute))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(count).To(BeEquivalentTo(3))
+	announcements, err := db.GetAnnouncements(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(announcements).To(ConsistOf(HaveField("ID", future.ID)))
+}
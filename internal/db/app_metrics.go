@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	applicationMetricAlertRuleOutputExpr = `r.id, r.created_at, r.application_id, r.metric, r.operator, r.value,
+		r.for_seconds`
+	deploymentAppMetricAlertOutputExpr = `a.application_metric_alert_rule_id, a.deployment_id, a.pending_since,
+		a.firing_since`
+)
+
+// ReplaceDeploymentAppMetrics replaces all stored application metric series of a deployment with series.
+func ReplaceDeploymentAppMetrics(ctx context.Context, deploymentID uuid.UUID, series []types.AppMetricSeries) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		if _, err := db.Exec(ctx,
+			"DELETE FROM DeploymentAppMetric WHERE deployment_id = @deploymentId",
+			pgx.NamedArgs{"deploymentId": deploymentID}); err != nil {
+			return fmt.Errorf("could not delete DeploymentAppMetric: %w", err)
+		}
+		_, err := db.CopyFrom(
+			ctx,
+			pgx.Identifier{"deploymentappmetric"},
+			[]string{"deployment_id", "name", "labels", "value"},
+			pgx.CopyFromSlice(len(series), func(i int) ([]any, error) {
+				labels := series[i].Labels
+				if labels == nil {
+					labels = map[string]string{}
+				}
+				return []any{deploymentID, series[i].Name, labels, series[i].Value}, nil
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("could not insert DeploymentAppMetric: %w", err)
+		}
+		return nil
+	})
+}
+
+func GetDeploymentAppMetrics(ctx context.Context, deploymentID uuid.UUID) ([]types.DeploymentAppMetric, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT m.name, m.labels, m.value, m.updated_at FROM DeploymentAppMetric m
+			WHERE m.deployment_id = @deploymentId ORDER BY m.name, m.labels::text`,
+		pgx.NamedArgs{"deploymentId": deploymentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentAppMetric: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentAppMetric])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect DeploymentAppMetric: %w", err)
+	}
+	return result, nil
+}
+
+func GetApplicationMetricAlertRules(
+	ctx context.Context,
+	applicationID uuid.UUID,
+) ([]types.ApplicationMetricAlertRule, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationMetricAlertRuleOutputExpr+
+			" FROM ApplicationMetricAlertRule r WHERE r.application_id = @applicationId ORDER BY r.created_at",
+		pgx.NamedArgs{"applicationId": applicationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationMetricAlertRule: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationMetricAlertRule])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ApplicationMetricAlertRule: %w", err)
+	}
+	return result, nil
+}
+
+func CreateApplicationMetricAlertRule(ctx context.Context, rule *types.ApplicationMetricAlertRule) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO ApplicationMetricAlertRule AS r (application_id, metric, operator, value, for_seconds)
+			VALUES (@applicationId, @metric, @operator, @value, @forSeconds)
+			RETURNING `+applicationMetricAlertRuleOutputExpr,
+		pgx.NamedArgs{
+			"applicationId": rule.ApplicationID,
+			"metric":        rule.Metric,
+			"operator":      rule.Operator,
+			"value":         rule.Value,
+			"forSeconds":    rule.ForSeconds,
+		})
+	if err != nil {
+		return fmt.Errorf("could not insert ApplicationMetricAlertRule: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationMetricAlertRule])
+	if err != nil {
+		return fmt.Errorf("could not collect ApplicationMetricAlertRule: %w", err)
+	}
+	*rule = result
+	return nil
+}
+
+func DeleteApplicationMetricAlertRule(ctx context.Context, id, applicationID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM ApplicationMetricAlertRule WHERE id = @id AND application_id = @applicationId",
+		pgx.NamedArgs{"id": id, "applicationId": applicationID})
+	if err == nil && cmd.RowsAffected() == 0 {
+		err = apierrors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete ApplicationMetricAlertRule: %w", err)
+	}
+	return nil
+}
+
+func GetDeploymentAppMetricAlerts(
+	ctx context.Context,
+	deploymentID uuid.UUID,
+) ([]types.DeploymentAppMetricAlert, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+deploymentAppMetricAlertOutputExpr+
+			" FROM DeploymentAppMetricAlert a WHERE a.deployment_id = @deploymentId",
+		pgx.NamedArgs{"deploymentId": deploymentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentAppMetricAlert: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentAppMetricAlert])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect DeploymentAppMetricAlert: %w", err)
+	}
+	return result, nil
+}
+
+func SaveDeploymentAppMetricAlert(ctx context.Context, alert types.DeploymentAppMetricAlert) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(ctx,
+		`INSERT INTO DeploymentAppMetricAlert
+			(application_metric_alert_rule_id, deployment_id, pending_since, firing_since)
+			VALUES (@ruleId, @deploymentId, @pendingSince, @firingSince)
+			ON CONFLICT (application_metric_alert_rule_id, deployment_id)
+			DO UPDATE SET pending_since = EXCLUDED.pending_since, firing_since = EXCLUDED.firing_since`,
+		pgx.NamedArgs{
+			"ruleId":       alert.ApplicationMetricAlertRuleID,
+			"deploymentId": alert.DeploymentID,
+			"pendingSince": alert.PendingSince,
+			"firingSince":  alert.FiringSince,
+		})
+	if err != nil {
+		return fmt.Errorf("could not save DeploymentAppMetricAlert: %w", err)
+	}
+	return nil
+}
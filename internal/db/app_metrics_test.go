@@ -0,0 +1,80 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestReplaceDeploymentAppMetrics(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+
+	err := db.ReplaceDeploymentAppMetrics(ctx, revision.DeploymentID, []types.AppMetricSeries{
+		{Name: "queue_length", Value: 3},
+		{Name: "http_requests_total", Labels: map[string]string{"code": "200"}, Value: 10},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	err = db.ReplaceDeploymentAppMetrics(ctx, revision.DeploymentID, []types.AppMetricSeries{
+		{Name: "queue_length", Value: 5},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	metrics, err := db.GetDeploymentAppMetrics(ctx, revision.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(metrics).To(HaveLen(1), "previous series are replaced")
+	g.Expect(metrics[0].AppMetricSeries).To(Equal(types.AppMetricSeries{
+		Name: "queue_length", Labels: map[string]string{}, Value: 5,
+	}))
+}
+
+func TestDeploymentAppMetricAlert(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, target.ID, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(HaveLen(1))
+	deployment := deployments[0]
+
+	rule := types.ApplicationMetricAlertRule{
+		ApplicationID: deployment.ApplicationID,
+		Metric:        "queue_length",
+		Operator:      types.AppMetricAlertOperatorGreaterThan,
+		Value:         100,
+		ForSeconds:    60,
+	}
+	g.Expect(db.CreateApplicationMetricAlertRule(ctx, &rule)).To(Succeed())
+	rules, err := db.GetApplicationMetricAlertRules(ctx, deployment.ApplicationID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rules).To(Equal([]types.ApplicationMetricAlertRule{rule}))
+
+	pendingSince := time.Now().UTC().Truncate(time.Microsecond)
+	alert := types.DeploymentAppMetricAlert{
+		ApplicationMetricAlertRuleID: rule.ID,
+		DeploymentID:                 revision.DeploymentID,
+		PendingSince:                 &pendingSince,
+	}
+	g.Expect(db.SaveDeploymentAppMetricAlert(ctx, alert)).To(Succeed())
+	alert.FiringSince = util.PtrTo(pendingSince.Add(time.Minute))
+	g.Expect(db.SaveDeploymentAppMetricAlert(ctx, alert)).To(Succeed())
+	alerts, err := db.GetDeploymentAppMetricAlerts(ctx, revision.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(alerts).To(HaveLen(1))
+	g.Expect(alerts[0].FiringSince).To(HaveValue(BeTemporally("==", *alert.FiringSince)))
+
+	g.Expect(db.DeleteApplicationMetricAlertRule(ctx, rule.ID, deployment.ApplicationID)).To(Succeed())
+	alerts, err = db.GetDeploymentAppMetricAlerts(ctx, revision.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(alerts).To(BeEmpty(), "alert states are deleted together with their rule")
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	applicationBadgeOutputExpr = `
+		b.application_id, b.created_at, b.token_hash, b.token_prefix, b.latest_version, b.installation_count,
+		b.refreshed_at
+	`
+)
+
+func GetApplicationBadge(ctx context.Context, applicationID uuid.UUID) (*types.ApplicationBadge, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationBadgeOutputExpr+" FROM ApplicationBadge b WHERE b.application_id = @applicationId",
+		pgx.NamedArgs{"applicationId": applicationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationBadge: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationBadge])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get ApplicationBadge: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// GetApplicationBadgeByTokenHash returns the badge for a presented capability token.
+func GetApplicationBadgeByTokenHash(
+	ctx context.Context,
+	tokenHash []byte,
+) (*types.ApplicationBadgeWithApplication, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationBadgeOutputExpr+", a.name AS application_name, o.status_badges_disabled "+
+			"FROM ApplicationBadge b "+
+			"JOIN Application a ON a.id = b.application_id "+
+			"JOIN Organization o ON o.id = a.organization_id "+
+			"WHERE b.token_hash = @tokenHash",
+		pgx.NamedArgs{"tokenHash": tokenHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationBadge: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationBadgeWithApplication])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get ApplicationBadge: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// SaveApplicationBadgeToken creates the badge of an application or replaces the token of an existing one.
+// Replacing the token invalidates all previously published badge URLs, but keeps the aggregated data.
+func SaveApplicationBadgeToken(ctx context.Context, badge *types.ApplicationBadge) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO ApplicationBadge AS b (application_id, token_hash, token_prefix)
+			VALUES (@applicationId, @tokenHash, @tokenPrefix)
+			ON CONFLICT (application_id) DO UPDATE SET
+				created_at = current_timestamp,
+				token_hash = EXCLUDED.token_hash,
+				token_prefix = EXCLUDED.token_prefix
+			RETURNING`+applicationBadgeOutputExpr,
+		pgx.NamedArgs{
+			"applicationId": badge.ApplicationID,
+			"tokenHash":     badge.TokenHash,
+			"tokenPrefix":   badge.TokenPrefix,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to save ApplicationBadge: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationBadge]); err != nil {
+		return fmt.Errorf("failed to get saved ApplicationBadge: %w", err)
+	} else {
+		*badge = result
+		return nil
+	}
+}
+
+func DeleteApplicationBadge(ctx context.Context, applicationID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM ApplicationBadge WHERE application_id = @applicationId",
+		pgx.NamedArgs{"applicationId": applicationID})
+	if err != nil {
+		return fmt.Errorf("failed to delete ApplicationBadge: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+// GetApplicationBadgeApplicationIDs returns the IDs of all applications that have a badge.
+func GetApplicationBadgeApplicationIDs(ctx context.Context) ([]uuid.UUID, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, "SELECT application_id FROM ApplicationBadge ORDER BY refreshed_at NULLS FIRST")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationBadge: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ApplicationBadge: %w", err)
+	}
+	return result, nil
+}
+
+// GetApplicationInstallationStats returns the names of all non-archived versions of an application, the number of
+// active deployments of the application and the number of distinct users that created the targets of these
+// deployments.
+// A deployment is active if neither the deployment nor its target are archived.
+func GetApplicationInstallationStats(
+	ctx context.Context,
+	applicationID uuid.UUID,
+) (versionNames []string, installations int, owners int, err error) {
+	db := internalctx.GetDb(ctx)
+	if err = db.QueryRow(ctx,
+		`SELECT
+			coalesce((
+				SELECT array_agg(av.name ORDER BY `+applicationVersionOrderExpr+`)
+				FROM ApplicationVersion av
+				WHERE av.application_id = @applicationId AND av.archived_at IS NULL
+			), array[]::text[]),
+			count(DISTINCT d.id),
+			count(DISTINCT dt.created_by_user_account_id)
+		FROM Deployment d
+		JOIN DeploymentTarget dt ON dt.id = d.deployment_target_id
+		JOIN LATERAL (
+			SELECT dr.application_version_id
+			FROM DeploymentRevision dr
+			WHERE dr.deployment_id = d.id
+			ORDER BY dr.created_at DESC
+			LIMIT 1
+		) dr ON true
+		JOIN ApplicationVersion av ON av.id = dr.application_version_id
+		WHERE av.application_id = @applicationId AND d.archived_at IS NULL AND dt.archived_at IS NULL`,
+		pgx.NamedArgs{"applicationId": applicationID},
+	).Scan(&versionNames, &installations, &owners); err != nil {
+		err = fmt.Errorf("failed to query installation stats: %w", err)
+	}
+	return
+}
+
+func UpdateApplicationBadgeData(
+	ctx context.Context,
+	applicationID uuid.UUID,
+	latestVersion *string,
+	installationCount *int,
+) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE ApplicationBadge SET
+			latest_version = @latestVersion,
+			installation_count = @installationCount,
+			refreshed_at = current_timestamp
+		WHERE application_id = @applicationId`,
+		pgx.NamedArgs{
+			"applicationId":     applicationID,
+			"latestVersion":     latestVersion,
+			"installationCount": installationCount,
+		},
+	); err != nil {
+		return fmt.Errorf("failed to update ApplicationBadge: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// RequestApplicationDeletion marks application as deleted. It can be restored until scheduledAt.
+// It returns apierrors.ErrConflict if the application is already deleted.
+func RequestApplicationDeletion(
+	ctx context.Context,
+	application *types.Application,
+	userID uuid.UUID,
+	scheduledAt time.Time,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE Application AS a
+		SET deleted_at = now(),
+			deleted_by_useraccount_id = @userId,
+			deletion_scheduled_at = @scheduledAt
+		WHERE a.id = @id AND a.deleted_at IS NULL
+		RETURNING `+applicationOutputExpr,
+		pgx.NamedArgs{"id": application.ID, "userId": userID, "scheduledAt": scheduledAt},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Application: %w", err)
+	}
+	return collectApplicationDeletionUpdate(rows, application)
+}
+
+// RestoreApplication resets the deletion of application.
+// It returns apierrors.ErrConflict if the application is not deleted.
+func RestoreApplication(ctx context.Context, application *types.Application) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE Application AS a
+		SET deleted_at = NULL,
+			deleted_by_useraccount_id = NULL,
+			deletion_scheduled_at = NULL
+		WHERE a.id = @id AND a.deleted_at IS NOT NULL
+		RETURNING `+applicationOutputExpr,
+		pgx.NamedArgs{"id": application.ID},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Application: %w", err)
+	}
+	return collectApplicationDeletionUpdate(rows, application)
+}
+
+func collectApplicationDeletionUpdate(rows pgx.Rows, application *types.Application) error {
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByNameLax[types.Application]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrConflict
+		}
+		return fmt.Errorf("could not update Application: %w", err)
+	} else {
+		// the versions are not changed by the update
+		result.Versions = application.Versions
+		*application = result
+		return nil
+	}
+}
+
+// GetApplicationsDueForDeletion returns all deleted applications whose deletion is scheduled at or before now.
+func GetApplicationsDueForDeletion(ctx context.Context, now time.Time) ([]types.Application, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT `+applicationOutputExpr+`
+		FROM Application a
+		WHERE a.deletion_scheduled_at <= @now
+		ORDER BY a.deletion_scheduled_at`,
+		pgx.NamedArgs{"now": now},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query Application: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[types.Application])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect Application: %w", err)
+	}
+	return result, nil
+}
+
+// GetApplicationDeletionImpact returns the active deployments of the application. Deployments are active unless
+// they are archived, uninstalled or on an archived deployment target. Deployments with a pending uninstall are still
+// active.
+func GetApplicationDeletionImpact(
+	ctx context.Context,
+	applicationID uuid.UUID,
+) (*types.ApplicationDeletionImpact, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT d.id AS deployment_id, dt.id AS deployment_target_id, dt.name AS deployment_target_name,
+			d.release_name, d.uninstall_requested_at
+		FROM Deployment d
+			JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+		WHERE d.archived_at IS NULL AND d.uninstalled_at IS NULL AND dt.archived_at IS NULL
+			AND EXISTS (
+				SELECT 1 FROM DeploymentRevision dr
+					JOIN ApplicationVersion av ON dr.application_version_id = av.id
+				WHERE dr.deployment_id = d.id AND av.application_id = @applicationId
+			)
+		ORDER BY dt.name, d.created_at`,
+		pgx.NamedArgs{"applicationId": applicationID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query Deployment: %w", err)
+	}
+	deployments, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationDeletionDeployment])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect Deployment: %w", err)
+	}
+	return &types.ApplicationDeletionImpact{Deployments: deployments}, nil
+}
+
+// PurgeApplication permanently deletes an application together with its versions, licenses and the history of its
+// deployments. It returns apierrors.ErrConflict if the application still has active deployments.
+func PurgeApplication(ctx context.Context, id uuid.UUID) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		if impact, err := GetApplicationDeletionImpact(ctx, id); err != nil {
+			return err
+		} else if !impact.IsEmpty() {
+			return fmt.Errorf("%w: application still has active deployments", apierrors.ErrConflict)
+		}
+		db := internalctx.GetDb(ctx)
+		// deployments would lose all of their revisions by cascade, so they are deleted explicitly
+		if _, err := db.Exec(ctx,
+			`DELETE FROM Deployment d
+			WHERE EXISTS (
+				SELECT 1 FROM DeploymentRevision dr
+					JOIN ApplicationVersion av ON dr.application_version_id = av.id
+				WHERE dr.deployment_id = d.id AND av.application_id = @id
+			)`,
+			pgx.NamedArgs{"id": id},
+		); err != nil {
+			return fmt.Errorf("could not delete Deployment: %w", err)
+		}
+		if _, err := db.Exec(ctx,
+			`DELETE FROM ApplicationLicense WHERE application_id = @id`,
+			pgx.NamedArgs{"id": id},
+		); err != nil {
+			return fmt.Errorf("could not delete ApplicationLicense: %w", err)
+		}
+		return DeleteApplicationWithID(ctx, id)
+	})
+}
@@ -0,0 +1,95 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestApplicationDeletion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	app, err := db.GetApplicationForApplicationVersionID(ctx, revision.ApplicationVersionID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.CreateApplicationLicense(ctx, &types.ApplicationLicenseBase{
+		Name:               "license",
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &org.Customers[0].ID,
+	})).To(Succeed())
+	now := time.Now()
+
+	impact, err := db.GetApplicationDeletionImpact(ctx, app.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(impact.Deployments).To(ConsistOf(HaveField("DeploymentID", revision.DeploymentID)))
+
+	g.Expect(db.RequestApplicationDeletion(ctx, app, org.Vendors[0].ID, now.Add(time.Hour))).To(Succeed())
+	g.Expect(app.IsDeleted()).To(BeTrue())
+	g.Expect(app.Versions).To(HaveLen(1))
+	g.Expect(db.RequestApplicationDeletion(ctx, app, org.Vendors[0].ID, now)).To(MatchError(apierrors.ErrConflict))
+
+	// deleted applications are still resolvable, but not listed or licensed
+	_, err = db.GetApplication(ctx, app.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	apps, err := db.GetApplicationsByOrgID(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).NotTo(ContainElement(HaveField("ID", app.ID)))
+	apps, err = db.GetDeletedApplicationsByOrgID(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(ConsistOf(HaveField("ID", app.ID)))
+	apps, err = db.GetApplicationsWithLicenseOwnerID(ctx, org.Customers[0].ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(BeEmpty())
+
+	due, err := db.GetApplicationsDueForDeletion(ctx, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).NotTo(ContainElement(HaveField("ID", app.ID)))
+	due, err = db.GetApplicationsDueForDeletion(ctx, now.Add(2*time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).To(ContainElement(HaveField("ID", app.ID)))
+
+	g.Expect(db.RestoreApplication(ctx, app)).To(Succeed())
+	g.Expect(app.IsDeleted()).To(BeFalse())
+	g.Expect(app.DeletionScheduledAt).To(BeNil())
+	g.Expect(db.RestoreApplication(ctx, app)).To(MatchError(apierrors.ErrConflict))
+}
+
+func TestPurgeApplication(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	app, err := db.GetApplicationForApplicationVersionID(ctx, revision.ApplicationVersionID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.CreateApplicationLicense(ctx, &types.ApplicationLicenseBase{
+		Name:               "license",
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &org.Customers[0].ID,
+	})).To(Succeed())
+
+	g.Expect(db.PurgeApplication(ctx, app.ID)).To(MatchError(apierrors.ErrConflict))
+
+	deployment := types.Deployment{Base: types.Base{ID: revision.DeploymentID}}
+	g.Expect(db.SetDeploymentArchived(ctx, &deployment, true)).To(Succeed())
+	impact, err := db.GetApplicationDeletionImpact(ctx, app.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(impact.IsEmpty()).To(BeTrue())
+
+	g.Expect(db.PurgeApplication(ctx, app.ID)).To(Succeed())
+	_, err = db.GetApplication(ctx, app.ID, org.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.DeleteDeploymentWithID(ctx, deployment.ID)).To(MatchError(apierrors.ErrNotFound))
+	licenses, err := db.GetApplicationLicensesWithOrganizationID(ctx, org.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(licenses).To(BeEmpty())
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetApplicationDependencies returns the dependencies of all applications of an organization, keyed by the ID of the
+// dependent application.
+func GetApplicationDependencies(
+	ctx context.Context,
+	orgID uuid.UUID,
+) (map[uuid.UUID][]types.ApplicationDependency, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT ad.application_id, ad.depends_on_application_id, da.name AS depends_on_application_name,
+			ad.min_version, ad.created_at
+		FROM ApplicationDependency ad
+		JOIN Application a ON ad.application_id = a.id
+		JOIN Application da ON ad.depends_on_application_id = da.id
+		WHERE a.organization_id = @orgId
+		ORDER BY ad.created_at`,
+		pgx.NamedArgs{"orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationDependencies: %w", err)
+	}
+	dependencies, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationDependency])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ApplicationDependencies: %w", err)
+	}
+	result := map[uuid.UUID][]types.ApplicationDependency{}
+	for _, d := range dependencies {
+		result[d.ApplicationID] = append(result[d.ApplicationID], d)
+	}
+	return result, nil
+}
+
+// PutApplicationDependencies replaces the dependencies of an application. Callers must make sure that all
+// applications belong to the same organization.
+func PutApplicationDependencies(
+	ctx context.Context,
+	applicationID uuid.UUID,
+	dependencies []types.ApplicationDependency,
+) error {
+	db := internalctx.GetDb(ctx)
+	dependsOn := make([]uuid.UUID, len(dependencies))
+	minVersions := make([]*string, len(dependencies))
+	for i, d := range dependencies {
+		dependsOn[i] = d.DependsOnApplicationID
+		minVersions[i] = d.MinVersion
+	}
+	if _, err := db.Exec(ctx, `
+		DELETE FROM ApplicationDependency
+		WHERE application_id = @applicationId AND NOT depends_on_application_id = ANY(@dependsOn)`,
+		pgx.NamedArgs{"applicationId": applicationID, "dependsOn": dependsOn},
+	); err != nil {
+		return fmt.Errorf("could not delete ApplicationDependencies: %w", err)
+	}
+	if _, err := db.Exec(ctx, `
+		INSERT INTO ApplicationDependency (application_id, depends_on_application_id, min_version)
+		SELECT @applicationId, d.depends_on, d.min_version
+		FROM unnest(@dependsOn::UUID[], @minVersions::TEXT[]) AS d(depends_on, min_version)
+		ON CONFLICT (application_id, depends_on_application_id) DO UPDATE SET min_version = EXCLUDED.min_version`,
+		pgx.NamedArgs{"applicationId": applicationID, "dependsOn": dependsOn, "minVersions": minVersions},
+	); err != nil {
+		return fmt.Errorf("could not insert ApplicationDependencies: %w", err)
+	}
+	return nil
+}
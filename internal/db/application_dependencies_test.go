@@ -0,0 +1,57 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestPutApplicationDependencies(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	other := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	var platform, addon types.Application
+	for _, app := range []*types.Application{&platform, &addon} {
+		app.Name = "test-app"
+		app.Type = types.DeploymentTypeDocker
+		g.Expect(db.CreateApplication(ctx, app, org.ID)).To(Succeed())
+	}
+
+	g.Expect(db.PutApplicationDependencies(ctx, addon.ID, []types.ApplicationDependency{
+		{DependsOnApplicationID: platform.ID, MinVersion: util.PtrTo("1.2.0")},
+	})).To(Succeed())
+	dependencies, err := db.GetApplicationDependencies(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dependencies).To(HaveLen(1))
+	g.Expect(dependencies[addon.ID]).To(ConsistOf(And(
+		HaveField("DependsOnApplicationID", platform.ID),
+		HaveField("DependsOnApplicationName", platform.Name),
+		HaveField("MinVersion", HaveValue(Equal("1.2.0"))),
+	)))
+	g.Expect(dependencies[addon.ID][0].IsSatisfiedBy("1.10.0")).To(BeTrue())
+	g.Expect(dependencies[addon.ID][0].IsSatisfiedBy("1.1.9")).To(BeFalse())
+	g.Expect(dependencies[addon.ID][0].IsSatisfiedBy("latest")).To(BeFalse())
+
+	// the constraint of an existing dependency is updated in place
+	g.Expect(db.PutApplicationDependencies(ctx, addon.ID, []types.ApplicationDependency{
+		{DependsOnApplicationID: platform.ID},
+	})).To(Succeed())
+	dependencies, err = db.GetApplicationDependencies(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dependencies[addon.ID]).To(ConsistOf(HaveField("MinVersion", BeNil())))
+	g.Expect(dependencies[addon.ID][0].IsSatisfiedBy("latest")).To(BeTrue())
+
+	dependencies, err = db.GetApplicationDependencies(ctx, other.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dependencies).To(BeEmpty())
+
+	g.Expect(db.PutApplicationDependencies(ctx, addon.ID, nil)).To(Succeed())
+	dependencies, err = db.GetApplicationDependencies(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dependencies).To(BeEmpty())
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/clock"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/google/uuid"
@@ -17,14 +18,14 @@ import (
 const (
 	applicationLicenseOutputExpr = `
 		al.id, al.created_at, al.name, al.expires_at, al.application_id, al.organization_id,
-		al.owner_useraccount_id, al.registry_url, al.registry_username, al.registry_password
+		al.owner_useraccount_id, al.registry_url, al.registry_username, al.registry_password, al.seat_count
 	`
 	applicationLicenseWithVersionsOutputExpr = applicationLicenseOutputExpr + `,
 		coalesce((
 		   	SELECT array_agg(
 				row(av.id, av.created_at, av.archived_at, av.name, av.application_id,
 					av.chart_type, av.chart_name, av.chart_url, av.chart_version)
-				ORDER BY av.created_at ASC
+				ORDER BY ` + applicationVersionOrderExpr + `
 			)
 		   	FROM ApplicationLicense_ApplicationVersion alav
 				LEFT JOIN applicationversion av ON alav.application_version_id = av.id
@@ -34,7 +35,11 @@ const (
 	`
 	applicationLicenseCompleteOutputExpr = applicationLicenseWithVersionsOutputExpr + `,
 		(a.id, a.created_at, a.organization_id, a.name, a.type) as application,
-		CASE WHEN al.owner_useraccount_id IS NOT NULL THEN (` + userAccountOutputExpr + `) END as owner
+		CASE WHEN al.owner_useraccount_id IS NOT NULL THEN (` + userAccountOutputExpr + `) END as owner,
+		coalesce((
+			SELECT array_agg(als.useraccount_id) FROM ApplicationLicenseSeat als WHERE als.application_license_id = al.id
+		), array[]::uuid[]) as seat_holder_ids,
+		(SELECT count(*) FROM ApplicationLicenseSeat als WHERE als.application_license_id = al.id) as seats_used
 	`
 )
 
@@ -44,10 +49,10 @@ func CreateApplicationLicense(ctx context.Context, license *types.ApplicationLic
 		ctx,
 		`INSERT INTO ApplicationLicense AS al (
 			name, expires_at, application_id, organization_id, owner_useraccount_id, registry_url, registry_username,
-			registry_password
+			registry_password, seat_count
 		) VALUES (
 			@name, @expiresAt, @applicationId, @organizationId, @ownerUserAccountId, @registryUrl, @registryUsername,
-			@registryPassword
+			@registryPassword, @seatCount
 		) RETURNING`+applicationLicenseOutputExpr,
 		pgx.NamedArgs{
 			"name":               license.Name,
@@ -58,6 +63,7 @@ func CreateApplicationLicense(ctx context.Context, license *types.ApplicationLic
 			"registryUrl":        license.RegistryURL,
 			"registryUsername":   license.RegistryUsername,
 			"registryPassword":   license.RegistryPassword,
+			"seatCount":          license.SeatCount,
 		},
 	)
 	if err != nil {
@@ -85,7 +91,8 @@ func UpdateApplicationLicense(ctx context.Context, license *types.ApplicationLic
             owner_useraccount_id = @ownerUserAccountId,
             registry_url = @registryUrl,
             registry_username = @registryUsername,
-            registry_password = @registryPassword
+            registry_password = @registryPassword,
+            seat_count = @seatCount
 		 WHERE al.id = @id RETURNING`+applicationLicenseOutputExpr,
 		pgx.NamedArgs{
 			"id":                 license.ID,
@@ -95,6 +102,7 @@ func UpdateApplicationLicense(ctx context.Context, license *types.ApplicationLic
 			"registryUrl":        license.RegistryURL,
 			"registryUsername":   license.RegistryUsername,
 			"registryPassword":   license.RegistryPassword,
+			"seatCount":          license.SeatCount,
 		},
 	)
 	if err != nil {
@@ -114,7 +122,11 @@ func UpdateApplicationLicense(ctx context.Context, license *types.ApplicationLic
 
 func RevokeApplicationLicenseWithID(ctx context.Context, id uuid.UUID) error {
 	db := internalctx.GetDb(ctx)
-	cmd, err := db.Exec(ctx, "UPDATE ApplicationLicense SET expires_at = now() WHERE id = @id", pgx.NamedArgs{"id": id})
+	cmd, err := db.Exec(
+		ctx,
+		"UPDATE ApplicationLicense SET expires_at = @now WHERE id = @id",
+		pgx.NamedArgs{"id": id, "now": clock.Now()},
+	)
 	if err == nil && cmd.RowsAffected() < 1 {
 		err = apierrors.ErrNotFound
 	}
@@ -125,17 +137,23 @@ func RevokeApplicationLicenseWithID(ctx context.Context, id uuid.UUID) error {
 	}
 }
 
+// AddVersionToApplicationLicense adds the version with the given id to license. If the version does not belong to the
+// application of the license, apierrors.ErrNotFound is returned.
 func AddVersionToApplicationLicense(
 	ctx context.Context,
 	license *types.ApplicationLicenseBase,
 	id uuid.UUID,
 ) error {
 	db := internalctx.GetDb(ctx)
-	_, err := db.Exec(
+	cmd, err := db.Exec(
 		ctx,
 		`INSERT INTO ApplicationLicense_ApplicationVersion (application_version_id, application_license_id)
-		VALUES (@applicationVersionId, @applicationLicenseId)
-		ON CONFLICT (application_version_id, application_license_id) DO NOTHING`,
+		SELECT av.id, al.id
+		FROM ApplicationLicense al
+			JOIN ApplicationVersion av ON av.application_id = al.application_id
+		WHERE al.id = @applicationLicenseId AND av.id = @applicationVersionId
+		ON CONFLICT (application_version_id, application_license_id)
+			DO UPDATE SET application_version_id = EXCLUDED.application_version_id`,
 		pgx.NamedArgs{
 			"applicationVersionId": id,
 			"applicationLicenseId": license.ID,
@@ -143,6 +161,8 @@ func AddVersionToApplicationLicense(
 	)
 	if err != nil {
 		return fmt.Errorf("could not insert relation: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
 	}
 	return nil
 }
@@ -195,6 +215,8 @@ func GetApplicationLicensesWithOrganizationID(
 
 	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationLicense]); err != nil {
 		return nil, fmt.Errorf("could not collect ApplicationLicense: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	} else {
 		return result, nil
 	}
@@ -212,7 +234,8 @@ func GetApplicationLicensesWithOwnerID(
 			"FROM ApplicationLicense al "+
 			"LEFT JOIN Application a ON al.application_id = a.id "+
 			"LEFT JOIN UserAccount u ON al.owner_useraccount_id = u.id "+
-			"WHERE al.owner_useraccount_id = @ownerId AND al.organization_id = @organizationId "+
+			"WHERE "+applicationLicenseHeldByExpr("ownerId")+" AND al.organization_id = @organizationId "+
+			"AND a.deleted_at IS NULL "+
 			andApplicationIdMatchesOrEmpty(applicationID),
 		pgx.NamedArgs{
 			"ownerId":        ownerID,
@@ -226,11 +249,22 @@ func GetApplicationLicensesWithOwnerID(
 
 	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationLicense]); err != nil {
 		return nil, fmt.Errorf("could not collect ApplicationLicense: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	} else {
 		return result, nil
 	}
 }
 
+// applicationLicenseHeldByExpr returns a condition that matches licenses (aliased as al) that are owned by the user
+// given by the named parameter or where this user holds a seat.
+func applicationLicenseHeldByExpr(param string) string {
+	return fmt.Sprintf(`(al.owner_useraccount_id = @%[1]v OR EXISTS (
+		SELECT 1 FROM ApplicationLicenseSeat als
+		WHERE als.application_license_id = al.id AND als.useraccount_id = @%[1]v
+	))`, param)
+}
+
 func andApplicationIdMatchesOrEmpty(applicationID *uuid.UUID) string {
 	if applicationID != nil {
 		return " AND al.application_id = @applicationId "
@@ -258,6 +292,8 @@ func GetApplicationLicenseByID(ctx context.Context, id uuid.UUID) (*types.Applic
 			return nil, apierrors.ErrNotFound
 		}
 		return nil, fmt.Errorf("could not collect ApplicationLicense: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	} else {
 		return &result, nil
 	}
@@ -282,3 +318,104 @@ func DeleteApplicationLicenseWithID(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+func GetApplicationLicenseSeats(ctx context.Context, licenseID uuid.UUID) ([]types.LicenseSeat, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`SELECT als.created_at, (`+userAccountOutputExpr+`) as user_account
+		FROM ApplicationLicenseSeat als
+			JOIN UserAccount u ON als.useraccount_id = u.id
+		WHERE als.application_license_id = @licenseId
+		ORDER BY als.created_at`,
+		pgx.NamedArgs{"licenseId": licenseID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ApplicationLicenseSeat: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.LicenseSeat]); err != nil {
+		return nil, fmt.Errorf("could not collect ApplicationLicenseSeat: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// CreateApplicationLicenseSeat assigns a seat of the given license to a user.
+// Assigning a seat to a user that already holds one is a no-op.
+// If all seats are already assigned, apierrors.ErrConflict is returned.
+func CreateApplicationLicenseSeat(ctx context.Context, licenseID, userID uuid.UUID) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		// The license row is locked so that concurrent assignments can not exceed the seat count.
+		rows, err := db.Query(
+			ctx,
+			`SELECT al.seat_count,
+				(SELECT count(*) FROM ApplicationLicenseSeat als WHERE als.application_license_id = al.id),
+				EXISTS (
+					SELECT 1 FROM ApplicationLicenseSeat als
+					WHERE als.application_license_id = al.id AND als.useraccount_id = @userId
+				)
+			FROM ApplicationLicense al
+			WHERE al.id = @licenseId
+			FOR UPDATE`,
+			pgx.NamedArgs{"licenseId": licenseID, "userId": userID},
+		)
+		if err != nil {
+			return fmt.Errorf("could not query ApplicationLicense: %w", err)
+		}
+		seats, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[struct {
+			SeatCount *int
+			SeatsUsed int
+			Assigned  bool
+		}])
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierrors.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("could not collect ApplicationLicense: %w", err)
+		} else if seats.Assigned {
+			return nil
+		} else if seats.SeatCount == nil || seats.SeatsUsed >= *seats.SeatCount {
+			return fmt.Errorf("%w: no seats available", apierrors.ErrConflict)
+		}
+		if _, err := db.Exec(
+			ctx,
+			`INSERT INTO ApplicationLicenseSeat (application_license_id, useraccount_id) VALUES (@licenseId, @userId)`,
+			pgx.NamedArgs{"licenseId": licenseID, "userId": userID},
+		); err != nil {
+			return fmt.Errorf("could not insert ApplicationLicenseSeat: %w", err)
+		}
+		return nil
+	})
+}
+
+func DeleteApplicationLicenseSeat(ctx context.Context, licenseID, userID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(
+		ctx,
+		`DELETE FROM ApplicationLicenseSeat WHERE application_license_id = @licenseId AND useraccount_id = @userId`,
+		pgx.NamedArgs{"licenseId": licenseID, "userId": userID},
+	)
+	if err == nil && cmd.RowsAffected() == 0 {
+		err = apierrors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete ApplicationLicenseSeat: %w", err)
+	}
+	return nil
+}
+
+func DeleteApplicationLicenseSeatsOfUserInOrg(ctx context.Context, userID, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(
+		ctx,
+		`DELETE FROM ApplicationLicenseSeat als
+		USING ApplicationLicense al
+		WHERE als.application_license_id = al.id AND als.useraccount_id = @userId AND al.organization_id = @orgId`,
+		pgx.NamedArgs{"userId": userID, "orgId": orgID},
+	); err != nil {
+		return fmt.Errorf("could not delete ApplicationLicenseSeat: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	applicationPromotionRuleOutputExpr = `r.id, r.created_at, r.application_id, r.channel, r.checks`
+	applicationVersionScanOutputExpr   = `
+		s.id, s.created_at, s.application_version_id, s.scanner, s.critical, s.high, s.medium, s.low
+	`
+	applicationVersionApprovalOutputExpr  = `a.id, a.created_at, a.application_version_id, a.user_account_id, a.user_role`
+	applicationVersionPromotionOutputExpr = `
+		p.id, p.created_at, p.application_version_id, p.user_account_id, p.channel, p.promoted, p.results
+	`
+	applicationChannelOutputExpr = `c.application_id, c.channel, c.application_version_id, c.promotion_id, c.promoted_at`
+)
+
+func GetApplicationPromotionRules(
+	ctx context.Context,
+	applicationID uuid.UUID,
+) ([]types.ApplicationPromotionRule, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationPromotionRuleOutputExpr+
+			" FROM ApplicationPromotionRule r WHERE r.application_id = @applicationId ORDER BY r.channel",
+		pgx.NamedArgs{"applicationId": applicationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationPromotionRule: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationPromotionRule])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ApplicationPromotionRule: %w", err)
+	}
+	return result, nil
+}
+
+func GetApplicationPromotionRule(
+	ctx context.Context,
+	applicationID uuid.UUID,
+	channel string,
+) (*types.ApplicationPromotionRule, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationPromotionRuleOutputExpr+
+			" FROM ApplicationPromotionRule r WHERE r.application_id = @applicationId AND r.channel = @channel",
+		pgx.NamedArgs{"applicationId": applicationID, "channel": channel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationPromotionRule: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationPromotionRule])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get ApplicationPromotionRule: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+func UpsertApplicationPromotionRule(ctx context.Context, rule *types.ApplicationPromotionRule) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO ApplicationPromotionRule AS r (application_id, channel, checks)
+			VALUES (@applicationId, @channel, @checks)
+			ON CONFLICT (application_id, channel) DO UPDATE SET checks = EXCLUDED.checks
+			RETURNING `+applicationPromotionRuleOutputExpr,
+		pgx.NamedArgs{"applicationId": rule.ApplicationID, "channel": rule.Channel, "checks": rule.Checks})
+	if err != nil {
+		return fmt.Errorf("failed to save ApplicationPromotionRule: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationPromotionRule])
+	if err != nil {
+		return fmt.Errorf("could not save ApplicationPromotionRule: %w", err)
+	} else {
+		*rule = result
+		return nil
+	}
+}
+
+func DeleteApplicationPromotionRule(ctx context.Context, applicationID uuid.UUID, channel string) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM ApplicationPromotionRule WHERE application_id = @applicationId AND channel = @channel",
+		pgx.NamedArgs{"applicationId": applicationID, "channel": channel})
+	if err == nil && cmd.RowsAffected() == 0 {
+		err = apierrors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete ApplicationPromotionRule: %w", err)
+	}
+	return nil
+}
+
+func CreateApplicationVersionScan(ctx context.Context, scan *types.ApplicationVersionScan) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO ApplicationVersionScan AS s (application_version_id, scanner, critical, high, medium, low)
+			VALUES (@applicationVersionId, @scanner, @critical, @high, @medium, @low)
+			RETURNING `+applicationVersionScanOutputExpr,
+		pgx.NamedArgs{
+			"applicationVersionId": scan.ApplicationVersionID,
+			"scanner":              scan.Scanner,
+			"critical":             scan.Critical,
+			"high":                 scan.High,
+			"medium":               scan.Medium,
+			"low":                  scan.Low,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert ApplicationVersionScan: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationVersionScan])
+	if err != nil {
+		return fmt.Errorf("could not insert ApplicationVersionScan: %w", err)
+	} else {
+		*scan = result
+		return nil
+	}
+}
+
+func GetLatestApplicationVersionScan(
+	ctx context.Context,
+	applicationVersionID uuid.UUID,
+) (*types.ApplicationVersionScan, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationVersionScanOutputExpr+
+			" FROM ApplicationVersionScan s WHERE s.application_version_id = @applicationVersionId"+
+			" ORDER BY s.created_at DESC LIMIT 1",
+		pgx.NamedArgs{"applicationVersionId": applicationVersionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationVersionScan: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationVersionScan])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get ApplicationVersionScan: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// CreateApplicationVersionApproval approves the given version on behalf of a user.
+// Approving the same version twice is a no-op that returns the existing approval.
+func CreateApplicationVersionApproval(ctx context.Context, approval *types.ApplicationVersionApproval) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO ApplicationVersionApproval AS a (application_version_id, user_account_id, user_role)
+			VALUES (@applicationVersionId, @userAccountId, @userRole)
+			ON CONFLICT (application_version_id, user_account_id) DO UPDATE SET user_role = EXCLUDED.user_role
+			RETURNING `+applicationVersionApprovalOutputExpr,
+		pgx.NamedArgs{
+			"applicationVersionId": approval.ApplicationVersionID,
+			"userAccountId":        approval.UserAccountID,
+			"userRole":             approval.UserRole,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert ApplicationVersionApproval: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationVersionApproval])
+	if err != nil {
+		return fmt.Errorf("could not insert ApplicationVersionApproval: %w", err)
+	} else {
+		*approval = result
+		return nil
+	}
+}
+
+// CountApplicationVersionApprovals counts the approvals of a version. If role is not nil, only approvals given by
+// users with that role are counted.
+func CountApplicationVersionApprovals(
+	ctx context.Context,
+	applicationVersionID uuid.UUID,
+	role *types.UserRole,
+) (int, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT count(*) FROM ApplicationVersionApproval
+			WHERE application_version_id = @applicationVersionId AND (@role::USER_ROLE IS NULL OR user_role = @role)`,
+		pgx.NamedArgs{"applicationVersionId": applicationVersionID, "role": role})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query ApplicationVersionApproval: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, fmt.Errorf("failed to count ApplicationVersionApproval: %w", err)
+	}
+	return result, nil
+}
+
+// CountSuccessfulDeploymentTargetsForApplicationVersion counts how many of the given deployment targets currently run
+// the given application version successfully: the current revision of an active deployment on the target must be of
+// the version, and the latest status reported for that revision must be "ok". Targets that have been updated to
+// another version or whose deployment has failed since do not count anymore.
+func CountSuccessfulDeploymentTargetsForApplicationVersion(
+	ctx context.Context,
+	applicationVersionID uuid.UUID,
+	deploymentTargetIDs []uuid.UUID,
+) (int, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT count(DISTINCT d.deployment_target_id)
+			FROM Deployment d
+				-- revisions that are pending acknowledgment have not been applied yet
+				JOIN LATERAL (
+					SELECT dr.id, dr.application_version_id
+					FROM DeploymentRevision dr
+					WHERE dr.deployment_id = d.id AND `+deploymentRevisionReleasedExpr+`
+					ORDER BY dr.created_at DESC
+					LIMIT 1
+				) dr ON true
+				JOIN LATERAL (
+					SELECT drs.type
+					FROM DeploymentRevisionStatus drs
+					WHERE drs.deployment_revision_id = dr.id
+					ORDER BY drs.created_at DESC
+					LIMIT 1
+				) drs ON true
+			WHERE d.deployment_target_id = any(@deploymentTargetIds)
+				AND d.archived_at IS NULL
+				AND d.uninstalled_at IS NULL
+				AND dr.application_version_id = @applicationVersionId
+				AND drs.type = 'ok'`,
+		pgx.NamedArgs{"applicationVersionId": applicationVersionID, "deploymentTargetIds": deploymentTargetIDs})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query DeploymentRevision: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, fmt.Errorf("failed to count successful deployments: %w", err)
+	}
+	return result, nil
+}
+
+func CreateApplicationVersionPromotion(ctx context.Context, promotion *types.ApplicationVersionPromotion) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO ApplicationVersionPromotion AS p
+			(application_version_id, user_account_id, channel, promoted, results)
+			VALUES (@applicationVersionId, @userAccountId, @channel, @promoted, @results)
+			RETURNING `+applicationVersionPromotionOutputExpr,
+		pgx.NamedArgs{
+			"applicationVersionId": promotion.ApplicationVersionID,
+			"userAccountId":        promotion.UserAccountID,
+			"channel":              promotion.Channel,
+			"promoted":             promotion.Promoted,
+			"results":              promotion.Results,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert ApplicationVersionPromotion: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ApplicationVersionPromotion])
+	if err != nil {
+		return fmt.Errorf("could not insert ApplicationVersionPromotion: %w", err)
+	} else {
+		*promotion = result
+		return nil
+	}
+}
+
+// GetApplicationVersionPromotions returns all promotion evaluations of a version, newest first.
+func GetApplicationVersionPromotions(
+	ctx context.Context,
+	applicationVersionID uuid.UUID,
+) ([]types.ApplicationVersionPromotion, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationVersionPromotionOutputExpr+
+			" FROM ApplicationVersionPromotion p WHERE p.application_version_id = @applicationVersionId"+
+			" ORDER BY p.created_at DESC",
+		pgx.NamedArgs{"applicationVersionId": applicationVersionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationVersionPromotion: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationVersionPromotion])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ApplicationVersionPromotion: %w", err)
+	}
+	return result, nil
+}
+
+// SetApplicationChannel points the channel of the application to the version of the successful promotion.
+func SetApplicationChannel(ctx context.Context, promotion *types.ApplicationVersionPromotion) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`INSERT INTO ApplicationChannel (application_id, channel, application_version_id, promotion_id)
+			SELECT av.application_id, @channel, av.id, @promotionId
+			FROM ApplicationVersion av
+			WHERE av.id = @applicationVersionId
+			ON CONFLICT (application_id, channel) DO UPDATE SET
+				application_version_id = EXCLUDED.application_version_id,
+				promotion_id = EXCLUDED.promotion_id,
+				promoted_at = current_timestamp`,
+		pgx.NamedArgs{
+			"channel":              promotion.Channel,
+			"promotionId":          promotion.ID,
+			"applicationVersionId": promotion.ApplicationVersionID,
+		},
+	); err != nil {
+		return fmt.Errorf("could not save ApplicationChannel: %w", err)
+	}
+	return nil
+}
+
+// GetApplicationChannels returns the channels of the application with the versions they point to.
+func GetApplicationChannels(ctx context.Context, applicationID uuid.UUID) ([]types.ApplicationChannel, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+applicationChannelOutputExpr+
+			" FROM ApplicationChannel c WHERE c.application_id = @applicationId ORDER BY c.channel",
+		pgx.NamedArgs{"applicationId": applicationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ApplicationChannel: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationChannel])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ApplicationChannel: %w", err)
+	}
+	return result, nil
+}
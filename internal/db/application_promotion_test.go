@@ -0,0 +1,53 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestSetApplicationChannel(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, dt)
+	version, err := db.GetApplicationVersion(ctx, revision.ApplicationVersionID)
+	g.Expect(err).NotTo(HaveOccurred())
+	next := types.ApplicationVersion{
+		Name:            "2.0.0",
+		ApplicationID:   version.ApplicationID,
+		ComposeFileData: []byte("services:\n  app:\n    image: nginx\n"),
+	}
+	g.Expect(db.CreateApplicationVersion(ctx, &next)).To(Succeed())
+
+	promote := func(versionID uuid.UUID) *types.ApplicationVersionPromotion {
+		promotion := types.ApplicationVersionPromotion{
+			ApplicationVersionID: versionID,
+			Channel:              "stable",
+			Promoted:             true,
+		}
+		g.Expect(db.CreateApplicationVersionPromotion(ctx, &promotion)).To(Succeed())
+		g.Expect(db.SetApplicationChannel(ctx, &promotion)).To(Succeed())
+		return &promotion
+	}
+
+	g.Expect(db.GetApplicationChannels(ctx, version.ApplicationID)).To(BeEmpty())
+	first := promote(version.ID)
+	g.Expect(db.GetApplicationChannels(ctx, version.ApplicationID)).To(ConsistOf(And(
+		HaveField("Channel", "stable"),
+		HaveField("ApplicationVersionID", version.ID),
+		HaveField("PromotionID", HaveValue(Equal(first.ID))),
+	)))
+
+	// promoting another version moves the channel
+	second := promote(next.ID)
+	g.Expect(db.GetApplicationChannels(ctx, version.ApplicationID)).To(ConsistOf(And(
+		HaveField("ApplicationVersionID", next.ID),
+		HaveField("PromotionID", HaveValue(Equal(second.ID))),
+	)))
+}
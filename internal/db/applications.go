@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/appversion"
+	"github.com/glasskube/distr/internal/clock"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
@@ -16,11 +18,14 @@ import (
 )
 
 const (
-	applicationOutputExpr             = `a.id, a.created_at, a.organization_id, a.name, a.type, a.image_id`
+	applicationOutputExpr = `a.id, a.created_at, a.organization_id, a.name, a.type, a.image_id,
+		a.resource_requirements, a.version_policy, a.deleted_at, a.deleted_by_useraccount_id, a.deletion_scheduled_at`
 	applicationWithVersionsOutputExpr = applicationOutputExpr + `,
 		coalesce((
 			SELECT array_agg(row(av.id, av.created_at, av.archived_at, av.name, av.application_id,
-				av.chart_type, av.chart_name, av.chart_url, av.chart_version) ORDER BY av.created_at ASC)
+				av.chart_type, av.chart_name, av.chart_url, av.chart_version, av.resource_requirements,
+				av.metrics_endpoint, av.acknowledgment_message)
+				ORDER BY ` + applicationVersionOrderExpr + `)
 			FROM ApplicationVersion av
 			WHERE av.application_id = a.id
 		), array[]::record[]) AS versions `
@@ -28,7 +33,9 @@ const (
 	applicationWithLicensedVersionsOutputExpr = applicationOutputExpr + `,
 		coalesce((
 			SELECT array_agg(row(av.id, av.created_at, av.archived_at, av.name, av.application_id,
-				av.chart_type, av.chart_name, av.chart_url, av.chart_version) ORDER BY av.created_at ASC)
+				av.chart_type, av.chart_name, av.chart_url, av.chart_version, av.resource_requirements,
+				av.metrics_endpoint, av.acknowledgment_message)
+				ORDER BY ` + applicationVersionOrderExpr + `)
 			FROM ApplicationVersion av
 			WHERE av.application_id = a.id and
 				((av.id IN
@@ -37,12 +44,25 @@ const (
 		)), array[]::record[]) AS versions `
 )
 
+// applicationVersionOrderExpr orders application versions like appversion.Compare.
+const applicationVersionOrderExpr = `av.version_sort_key NULLS FIRST, av.created_at`
+
 func CreateApplication(ctx context.Context, application *types.Application, orgID uuid.UUID) error {
 	application.OrganizationID = orgID
+	if application.VersionPolicy == "" {
+		application.VersionPolicy = types.ApplicationVersionPolicyLenient
+	}
 	db := internalctx.GetDb(ctx)
 	row := db.QueryRow(ctx,
-		"INSERT INTO Application (name, type, organization_id) VALUES (@name, @type, @orgId) RETURNING id, created_at",
-		pgx.NamedArgs{"name": application.Name, "type": application.Type, "orgId": application.OrganizationID})
+		"INSERT INTO Application (name, type, organization_id, resource_requirements, version_policy) "+
+			"VALUES (@name, @type, @orgId, @resourceRequirements, @versionPolicy) RETURNING id, created_at",
+		pgx.NamedArgs{
+			"name":                 application.Name,
+			"type":                 application.Type,
+			"orgId":                application.OrganizationID,
+			"resourceRequirements": application.ResourceRequirements,
+			"versionPolicy":        application.VersionPolicy,
+		})
 	if err := row.Scan(&application.ID, &application.CreatedAt); err != nil {
 		return fmt.Errorf("could not save application: %w", err)
 	}
@@ -53,8 +73,16 @@ func UpdateApplication(ctx context.Context, application *types.Application, orgI
 	application.OrganizationID = orgID
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
-		"UPDATE Application SET name = @name WHERE id = @id AND organization_id = @orgId RETURNING *",
-		pgx.NamedArgs{"id": application.ID, "name": application.Name, "orgId": application.OrganizationID})
+		"UPDATE Application SET name = @name, resource_requirements = @resourceRequirements, "+
+			"version_policy = @versionPolicy "+
+			"WHERE id = @id AND organization_id = @orgId RETURNING *",
+		pgx.NamedArgs{
+			"id":                   application.ID,
+			"name":                 application.Name,
+			"orgId":                application.OrganizationID,
+			"resourceRequirements": application.ResourceRequirements,
+			"versionPolicy":        application.VersionPolicy,
+		})
 	if err != nil {
 		return fmt.Errorf("could not update application: %w", err)
 	} else if updated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByNameLax[types.Application]); err != nil {
@@ -83,14 +111,25 @@ func DeleteApplicationWithID(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// GetApplicationsByOrgID returns all applications of the organization that are not deleted.
 func GetApplicationsByOrgID(ctx context.Context, orgID uuid.UUID) ([]types.Application, error) {
+	return getApplicationsByOrgID(ctx, orgID, false)
+}
+
+// GetDeletedApplicationsByOrgID returns all applications of the organization that are deleted but can still be
+// restored.
+func GetDeletedApplicationsByOrgID(ctx context.Context, orgID uuid.UUID) ([]types.Application, error) {
+	return getApplicationsByOrgID(ctx, orgID, true)
+}
+
+func getApplicationsByOrgID(ctx context.Context, orgID uuid.UUID, deleted bool) ([]types.Application, error) {
 	db := internalctx.GetDb(ctx)
 	if rows, err := db.Query(ctx, `
 			SELECT `+applicationWithVersionsOutputExpr+`
 			FROM Application a
-			WHERE a.organization_id = @orgId
+			WHERE a.organization_id = @orgId AND (a.deleted_at IS NOT NULL) = @deleted
 			ORDER BY a.name
-			`, pgx.NamedArgs{"orgId": orgID}); err != nil {
+			`, pgx.NamedArgs{"orgId": orgID, "deleted": deleted}); err != nil {
 		return nil, fmt.Errorf("failed to query applications: %w", err)
 	} else if applications, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.Application]); err != nil {
 		return nil, fmt.Errorf("failed to get applications: %w", err)
@@ -112,6 +151,7 @@ func appendMissingVersions(application types.Application, versions []types.Appli
 			application.Versions = append(application.Versions, version)
 		}
 	}
+	appversion.Sort(application.Versions)
 	return application
 }
 
@@ -127,15 +167,16 @@ func mergeApplications(applications []types.Application) []types.Application {
 	return util.GetValues(applicationMap)
 }
 
-func GetApplicationsWithLicenseOwnerID(ctx context.Context, id uuid.UUID) ([]types.Application, error) {
+func GetApplicationsWithLicenseOwnerID(ctx context.Context, id, orgID uuid.UUID) ([]types.Application, error) {
 	db := internalctx.GetDb(ctx)
 	if rows, err := db.Query(ctx, `
 			SELECT DISTINCT `+applicationWithLicensedVersionsOutputExpr+`
 			FROM ApplicationLicense al
 				LEFT JOIN Application a ON al.application_id = a.id
-			WHERE al.owner_useraccount_id = @id AND (al.expires_at IS NULL OR al.expires_at > now())
+			WHERE `+applicationLicenseHeldByExpr("id")+` AND al.organization_id = @orgId
+				AND (al.expires_at IS NULL OR al.expires_at > @now) AND a.deleted_at IS NULL
 			ORDER BY a.name
-			`, pgx.NamedArgs{"id": id}); err != nil {
+			`, pgx.NamedArgs{"id": id, "orgId": orgID, "now": clock.Now()}); err != nil {
 		return nil, fmt.Errorf("failed to query applications: %w", err)
 	} else if applications, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.Application]); err != nil {
 		return nil, fmt.Errorf("failed to get applications: %w", err)
@@ -162,21 +203,25 @@ func GetApplication(ctx context.Context, id, orgID uuid.UUID) (*types.Applicatio
 	}
 }
 
-func GetApplicationWithLicenseOwnerID(ctx context.Context, oID uuid.UUID, id uuid.UUID) (*types.Application, error) {
+func GetApplicationWithLicenseOwnerID(
+	ctx context.Context,
+	oID, orgID uuid.UUID,
+	id uuid.UUID,
+) (*types.Application, error) {
 	db := internalctx.GetDb(ctx)
 	if rows, err := db.Query(ctx, `
 			SELECT DISTINCT `+applicationWithLicensedVersionsOutputExpr+`
 			FROM ApplicationLicense al
 				LEFT JOIN Application a ON al.application_id = a.id
-			WHERE al.owner_useraccount_id = @ownerID AND a.id = @id AND (al.expires_at IS NULL OR al.expires_at > now())
+			WHERE `+applicationLicenseHeldByExpr("ownerID")+` AND al.organization_id = @orgId AND a.id = @id
+				AND (al.expires_at IS NULL OR al.expires_at > @now) AND a.deleted_at IS NULL
 			ORDER BY a.name
-			`, pgx.NamedArgs{"ownerID": oID, "id": id}); err != nil {
+			`, pgx.NamedArgs{"ownerID": oID, "orgId": orgID, "id": id, "now": clock.Now()}); err != nil {
 		return nil, fmt.Errorf("failed to query applications: %w", err)
 	} else if applications, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.Application]); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, apierrors.ErrNotFound
-		}
 		return nil, fmt.Errorf("failed to get application: %w", err)
+	} else if len(applications) == 0 {
+		return nil, apierrors.ErrNotFound
 	} else {
 		return &mergeApplications(applications)[0], nil
 	}
@@ -205,12 +250,15 @@ func CreateApplicationVersion(ctx context.Context, applicationVersion *types.App
 	db := internalctx.GetDb(ctx)
 
 	args := pgx.NamedArgs{
-		"name":          applicationVersion.Name,
-		"applicationId": applicationVersion.ApplicationID,
-		"chartType":     applicationVersion.ChartType,
-		"chartName":     applicationVersion.ChartName,
-		"chartUrl":      applicationVersion.ChartUrl,
-		"chartVersion":  applicationVersion.ChartVersion,
+		"name":                  applicationVersion.Name,
+		"applicationId":         applicationVersion.ApplicationID,
+		"chartType":             applicationVersion.ChartType,
+		"chartName":             applicationVersion.ChartName,
+		"chartUrl":              applicationVersion.ChartUrl,
+		"chartVersion":          applicationVersion.ChartVersion,
+		"resourceRequirements":  applicationVersion.ResourceRequirements,
+		"metricsEndpoint":       applicationVersion.MetricsEndpoint,
+		"acknowledgmentMessage": applicationVersion.AcknowledgmentMessage,
 	}
 	if applicationVersion.ComposeFileData != nil {
 		args["composeFileData"] = applicationVersion.ComposeFileData
@@ -224,11 +272,14 @@ func CreateApplicationVersion(ctx context.Context, applicationVersion *types.App
 
 	row, err := db.Query(ctx,
 		`INSERT INTO ApplicationVersion AS av (name, application_id, chart_type, chart_name, chart_url, chart_version,
-				compose_file_data, values_file_data, template_file_data)
+				compose_file_data, values_file_data, template_file_data, resource_requirements,
+				metrics_endpoint, acknowledgment_message)
 			VALUES (@name, @applicationId, @chartType, @chartName, @chartUrl, @chartVersion, @composeFileData::bytea,
-				@valuesFileData::bytea, @templateFileData::bytea)
+				@valuesFileData::bytea, @templateFileData::bytea, @resourceRequirements, @metricsEndpoint,
+				@acknowledgmentMessage)
 			RETURNING av.id, av.created_at, av.archived_at, av.name, av.chart_type, av.chart_name, av.chart_url,
-				av.chart_version, av.values_file_data, av.template_file_data, av.compose_file_data, av.application_id`,
+				av.chart_version, av.values_file_data, av.template_file_data, av.compose_file_data, av.application_id,
+				av.resource_requirements, av.metrics_endpoint, av.acknowledgment_message`,
 		args)
 	if err != nil {
 		return fmt.Errorf("can not create ApplicationVersion: %w", err)
@@ -248,14 +299,20 @@ func CreateApplicationVersion(ctx context.Context, applicationVersion *types.App
 func UpdateApplicationVersion(ctx context.Context, applicationVersion *types.ApplicationVersion) error {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
-		`UPDATE ApplicationVersion AS av SET name = @name, archived_at = @archivedAt
+		`UPDATE ApplicationVersion AS av
+		SET name = @name, archived_at = @archivedAt, resource_requirements = @resourceRequirements,
+			metrics_endpoint = @metricsEndpoint, acknowledgment_message = @acknowledgmentMessage
 		WHERE id = @id
 		RETURNING av.id, av.created_at, av.archived_at, av.name, av.chart_type, av.chart_name, av.chart_url, av.chart_version,
-			av.values_file_data, av.template_file_data, av.compose_file_data, av.application_id`,
+			av.values_file_data, av.template_file_data, av.compose_file_data, av.application_id, av.resource_requirements,
+			av.metrics_endpoint, av.acknowledgment_message`,
 		pgx.NamedArgs{
-			"id":         applicationVersion.ID,
-			"name":       applicationVersion.Name,
-			"archivedAt": applicationVersion.ArchivedAt,
+			"id":                    applicationVersion.ID,
+			"name":                  applicationVersion.Name,
+			"archivedAt":            applicationVersion.ArchivedAt,
+			"resourceRequirements":  applicationVersion.ResourceRequirements,
+			"metricsEndpoint":       applicationVersion.MetricsEndpoint,
+			"acknowledgmentMessage": applicationVersion.AcknowledgmentMessage,
 		})
 	if err != nil {
 		if pgerr := (*pgconn.PgError)(nil); errors.As(err, &pgerr) && pgerr.Code == pgerrcode.UniqueViolation {
@@ -278,7 +335,8 @@ func GetApplicationVersion(ctx context.Context, applicationVersionID uuid.UUID)
 	rows, err := db.Query(
 		ctx,
 		`SELECT av.id, av.created_at, av.archived_at, av.name, av.chart_type, av.chart_name, av.chart_url, av.chart_version,
-			av.values_file_data, av.template_file_data, av.compose_file_data, av.application_id
+			av.values_file_data, av.template_file_data, av.compose_file_data, av.application_id, av.resource_requirements,
+			av.metrics_endpoint, av.acknowledgment_message
 		FROM ApplicationVersion av
 		WHERE id = @id`,
 		pgx.NamedArgs{"id": applicationVersionID},
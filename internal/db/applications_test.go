@@ -0,0 +1,145 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestApplicationResourceRequirements(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+
+	app := types.Application{
+		Name: "app",
+		Type: types.DeploymentTypeDocker,
+		ResourceRequirements: &types.ResourceRequirements{
+			MinMemoryBytes: util.PtrTo(int64(1024)),
+			Enforcement:    types.ResourceRequirementsEnforcementBlock,
+		},
+	}
+	g.Expect(db.CreateApplication(ctx, &app, org.ID)).To(Succeed())
+	version := types.ApplicationVersion{
+		Name:                 "1.0.0",
+		ApplicationID:        app.ID,
+		ComposeFileData:      []byte("services: {}\n"),
+		ResourceRequirements: &types.ResourceRequirements{Architectures: []string{"amd64"}},
+	}
+	g.Expect(db.CreateApplicationVersion(ctx, &version)).To(Succeed())
+	g.Expect(db.CreateApplicationVersion(ctx, &types.ApplicationVersion{
+		Name:            "1.0.1",
+		ApplicationID:   app.ID,
+		ComposeFileData: []byte("services: {}\n"),
+	})).To(Succeed())
+
+	result, err := db.GetApplication(ctx, app.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.ResourceRequirements).To(Equal(app.ResourceRequirements))
+	g.Expect(result.Versions).To(HaveLen(2))
+	// versions created in the same transaction have the same creation date, so their order is not defined
+	for _, v := range result.Versions {
+		if v.ID == version.ID {
+			g.Expect(v.ResourceRequirements).To(Equal(version.ResourceRequirements))
+			g.Expect(types.EffectiveResourceRequirements(*result, v)).To(Equal(version.ResourceRequirements))
+		} else {
+			g.Expect(v.ResourceRequirements).To(BeNil())
+			g.Expect(types.EffectiveResourceRequirements(*result, v)).To(Equal(app.ResourceRequirements))
+		}
+	}
+}
+
+func TestGetApplicationsWithLicenseOwnerIDOnlyReturnsLicensesOfOrganization(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 0, 1)
+	other := testutil.NewOrganization(ctx, t)
+	customer := org.Customers[0]
+	g.Expect(db.CreateUserAccountOrganizationAssignment(
+		ctx, customer.ID, other.ID, types.UserRoleCustomer,
+	)).To(Succeed())
+	app := types.Application{Name: "app", Type: types.DeploymentTypeDocker}
+	g.Expect(db.CreateApplication(ctx, &app, other.ID)).To(Succeed())
+	g.Expect(db.CreateApplicationLicense(ctx, &types.ApplicationLicenseBase{
+		Name:               "license",
+		ApplicationID:      app.ID,
+		OrganizationID:     other.ID,
+		OwnerUserAccountID: &customer.ID,
+	})).To(Succeed())
+
+	apps, err := db.GetApplicationsWithLicenseOwnerID(ctx, customer.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(BeEmpty())
+	_, err = db.GetApplicationWithLicenseOwnerID(ctx, customer.ID, org.ID, app.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	apps, err = db.GetApplicationsWithLicenseOwnerID(ctx, customer.ID, other.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(ConsistOf(HaveField("ID", app.ID)))
+}
+
+func TestApplicationLicenseExpiry(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	now := testutil.FreezeTime(t, time.Now()).Now()
+	org := testutil.NewOrganizationWithUsers(ctx, t, 0, 1)
+	customer := org.Customers[0]
+	app := types.Application{Name: "app", Type: types.DeploymentTypeDocker}
+	g.Expect(db.CreateApplication(ctx, &app, org.ID)).To(Succeed())
+	license := types.ApplicationLicenseBase{
+		Name:               "license",
+		ExpiresAt:          util.PtrTo(now.Add(time.Hour)),
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &customer.ID,
+	}
+	g.Expect(db.CreateApplicationLicense(ctx, &license)).To(Succeed())
+
+	testutil.AdvanceTime(t, time.Hour-time.Second)
+	apps, err := db.GetApplicationsWithLicenseOwnerID(ctx, customer.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(ConsistOf(HaveField("ID", app.ID)))
+
+	testutil.AdvanceTime(t, time.Second)
+	apps, err = db.GetApplicationsWithLicenseOwnerID(ctx, customer.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(BeEmpty())
+	_, err = db.GetApplicationWithLicenseOwnerID(ctx, customer.ID, org.ID, app.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestApplicationVersionsAreOrderedBySemver(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+
+	app := types.Application{Name: "app", Type: types.DeploymentTypeDocker}
+	g.Expect(db.CreateApplication(ctx, &app, org.ID)).To(Succeed())
+	g.Expect(app.VersionPolicy).To(Equal(types.ApplicationVersionPolicyLenient))
+	for _, name := range []string{"1.2.10", "latest", "1.2.9", "v2.0.0-rc.1", "1.2.1"} {
+		g.Expect(db.CreateApplicationVersion(ctx, &types.ApplicationVersion{
+			Name:            name,
+			ApplicationID:   app.ID,
+			ComposeFileData: []byte("services: {}\n"),
+		})).To(Succeed())
+	}
+
+	app.VersionPolicy = types.ApplicationVersionPolicyStrict
+	g.Expect(db.UpdateApplication(ctx, &app, org.ID)).To(Succeed())
+	result, err := db.GetApplication(ctx, app.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.VersionPolicy).To(Equal(types.ApplicationVersionPolicyStrict))
+	g.Expect(result.Versions).To(HaveExactElements(
+		HaveField("Name", "latest"),
+		HaveField("Name", "1.2.1"),
+		HaveField("Name", "1.2.9"),
+		HaveField("Name", "1.2.10"),
+		HaveField("Name", "v2.0.0-rc.1"),
+	))
+}
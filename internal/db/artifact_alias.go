@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	artifactAliasOutputExpr = ` aa.id, aa.created_at, aa.organization_id, aa.artifact_id, aa.name, aa.expires_at `
+)
+
+func GetArtifactAliases(ctx context.Context, artifactID uuid.UUID) ([]types.ArtifactAlias, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+artifactAliasOutputExpr+"FROM ArtifactAlias aa "+
+			"WHERE aa.artifact_id = @artifactId AND aa.expires_at > now() "+
+			"ORDER BY aa.created_at",
+		pgx.NamedArgs{"artifactId": artifactID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ArtifactAlias: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactAlias])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ArtifactAlias: %w", err)
+	}
+	return result, nil
+}
+
+// RenameArtifact changes the name of an artifact and creates an alias for the previous name that expires after
+// aliasDuration, so that existing pull commands keep working in the meantime.
+//
+// Aliases that already have the new name are removed, because the artifact name takes precedence.
+func RenameArtifact(
+	ctx context.Context,
+	artifact *types.Artifact,
+	newName string,
+	aliasDuration time.Duration,
+) (*types.ArtifactAlias, error) {
+	var alias types.ArtifactAlias
+	err := RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		if _, err := db.Exec(ctx,
+			"DELETE FROM ArtifactAlias WHERE organization_id = @orgId AND lower(name) = lower(@name)",
+			pgx.NamedArgs{"orgId": artifact.OrganizationID, "name": newName},
+		); err != nil {
+			return fmt.Errorf("failed to delete ArtifactAlias: %w", err)
+		}
+
+		if cmd, err := db.Exec(ctx,
+			"UPDATE Artifact SET name = @name WHERE id = @id AND organization_id = @orgId",
+			pgx.NamedArgs{"id": artifact.ID, "orgId": artifact.OrganizationID, "name": newName},
+		); err != nil {
+			var pgError *pgconn.PgError
+			if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+				err = fmt.Errorf("%w: %w", apierrors.ErrConflict, err)
+			}
+			return fmt.Errorf("failed to rename Artifact: %w", err)
+		} else if cmd.RowsAffected() == 0 {
+			return apierrors.ErrNotFound
+		}
+
+		rows, err := db.Query(ctx,
+			`INSERT INTO ArtifactAlias AS aa (organization_id, artifact_id, name, expires_at)
+				VALUES (@orgId, @artifactId, @name, @expiresAt)
+				ON CONFLICT (organization_id, name) DO UPDATE SET
+					artifact_id = EXCLUDED.artifact_id,
+					expires_at = EXCLUDED.expires_at
+				RETURNING`+artifactAliasOutputExpr,
+			pgx.NamedArgs{
+				"orgId":      artifact.OrganizationID,
+				"artifactId": artifact.ID,
+				"name":       artifact.Name,
+				"expiresAt":  time.Now().Add(aliasDuration),
+			})
+		if err != nil {
+			return fmt.Errorf("failed to insert ArtifactAlias: %w", err)
+		}
+		if alias, err = pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ArtifactAlias]); err != nil {
+			return fmt.Errorf("failed to collect ArtifactAlias: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	artifact.Name = newName
+	return &alias, nil
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// RequestArtifactDeletion marks artifact as pending deletion until scheduledAt.
+// It returns apierrors.ErrConflict if the deletion of the artifact has already been requested.
+func RequestArtifactDeletion(
+	ctx context.Context,
+	artifact *types.Artifact,
+	userID uuid.UUID,
+	scheduledAt time.Time,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE Artifact AS a
+		SET deletion_requested_at = now(),
+			deletion_requested_by_useraccount_id = @userId,
+			deletion_scheduled_at = @scheduledAt
+		WHERE a.id = @id AND a.deletion_requested_at IS NULL
+		RETURNING`+artifactOutputExpr,
+		pgx.NamedArgs{"id": artifact.ID, "userId": userID, "scheduledAt": scheduledAt},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Artifact: %w", err)
+	}
+	return collectArtifactDeletionUpdate(rows, artifact)
+}
+
+// CancelArtifactDeletion resets the pending deletion of artifact.
+// It returns apierrors.ErrConflict if the artifact is not pending deletion.
+func CancelArtifactDeletion(ctx context.Context, artifact *types.Artifact) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE Artifact AS a
+		SET deletion_requested_at = NULL,
+			deletion_requested_by_useraccount_id = NULL,
+			deletion_scheduled_at = NULL
+		WHERE a.id = @id AND a.deletion_requested_at IS NOT NULL
+		RETURNING`+artifactOutputExpr,
+		pgx.NamedArgs{"id": artifact.ID},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Artifact: %w", err)
+	}
+	return collectArtifactDeletionUpdate(rows, artifact)
+}
+
+func collectArtifactDeletionUpdate(rows pgx.Rows, artifact *types.Artifact) error {
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Artifact]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrConflict
+		}
+		return fmt.Errorf("could not update Artifact: %w", err)
+	} else {
+		*artifact = result
+		return nil
+	}
+}
+
+// GetArtifactsDueForDeletion returns all artifacts whose deletion is scheduled at or before now.
+func GetArtifactsDueForDeletion(ctx context.Context, now time.Time) ([]types.Artifact, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+artifactOutputExpr+`
+		FROM Artifact a
+		WHERE a.deletion_scheduled_at <= @now
+		ORDER BY a.deletion_scheduled_at`,
+		pgx.NamedArgs{"now": now},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query Artifact: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.Artifact])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect Artifact: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteArtifact deletes an artifact together with all of its versions and tags. The pull log entries, license
+// assignments and aliases of the artifact are deleted by cascade.
+// Blobs are not deleted, because they are addressed by digest and might be shared with other artifacts.
+func DeleteArtifact(ctx context.Context, id uuid.UUID) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		if _, err := db.Exec(ctx,
+			`DELETE FROM ArtifactVersion WHERE artifact_id = @id`,
+			pgx.NamedArgs{"id": id},
+		); err != nil {
+			return fmt.Errorf("could not delete ArtifactVersion: %w", err)
+		}
+		if cmd, err := db.Exec(ctx, `DELETE FROM Artifact WHERE id = @id`, pgx.NamedArgs{"id": id}); err != nil {
+			return fmt.Errorf("could not delete Artifact: %w", err)
+		} else if cmd.RowsAffected() == 0 {
+			return apierrors.ErrNotFound
+		}
+		return nil
+	})
+}
+
+// GetArtifactLicensesForArtifact returns all licenses that grant access to the artifact or some of its versions.
+func GetArtifactLicensesForArtifact(ctx context.Context, artifactID uuid.UUID) ([]types.ArtifactLicenseBase, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT `+artifactLicenseOutExpr+`
+		FROM ArtifactLicense al
+		WHERE EXISTS (
+			SELECT 1 FROM ArtifactLicense_Artifact ala
+			WHERE ala.artifact_license_id = al.id AND ala.artifact_id = @artifactId
+		)
+		ORDER BY al.name`,
+		pgx.NamedArgs{"artifactId": artifactID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactLicense: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactLicenseBase])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect ArtifactLicense: %w", err)
+	}
+	return result, nil
+}
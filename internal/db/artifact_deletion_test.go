@@ -0,0 +1,69 @@
+package db_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestArtifactDeletion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	artifact, _ := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+	now := time.Now()
+
+	g.Expect(db.RequestArtifactDeletion(ctx, artifact, org.Vendors[0].ID, now.Add(time.Hour))).To(Succeed())
+	g.Expect(artifact.IsPendingDeletion()).To(BeTrue())
+	g.Expect(artifact.DeletionRequestedByUserAccountID).To(HaveValue(Equal(org.Vendors[0].ID)))
+	g.Expect(db.RequestArtifactDeletion(ctx, artifact, org.Vendors[0].ID, now)).
+		To(MatchError(apierrors.ErrConflict))
+
+	due, err := db.GetArtifactsDueForDeletion(ctx, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).NotTo(ContainElement(HaveField("ID", artifact.ID)))
+	due, err = db.GetArtifactsDueForDeletion(ctx, now.Add(2*time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).To(ContainElement(HaveField("ID", artifact.ID)))
+
+	g.Expect(db.CancelArtifactDeletion(ctx, artifact)).To(Succeed())
+	g.Expect(artifact.IsPendingDeletion()).To(BeFalse())
+	g.Expect(artifact.DeletionScheduledAt).To(BeNil())
+	g.Expect(db.CancelArtifactDeletion(ctx, artifact)).To(MatchError(apierrors.ErrConflict))
+}
+
+func TestDeleteArtifact(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0", "latest")
+	g.Expect(db.CreateArtifactPullLogEntry(
+		ctx, versions[1].ID, &org.Customers[0].ID, nil, "192.0.2.1", http.MethodGet, "",
+	)).To(Succeed())
+
+	license := types.ArtifactLicenseBase{
+		Name:               "test-license",
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &org.Customers[0].ID,
+	}
+	g.Expect(db.CreateArtifactLicense(ctx, &license)).To(Succeed())
+	g.Expect(db.AddArtifactToArtifactLicense(ctx, license.ID, artifact.ID, nil)).To(Succeed())
+
+	licenses, err := db.GetArtifactLicensesForArtifact(ctx, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(licenses).To(ConsistOf(HaveField("ID", license.ID)))
+
+	g.Expect(db.DeleteArtifact(ctx, artifact.ID)).To(Succeed())
+	g.Expect(db.DeleteArtifact(ctx, artifact.ID)).To(MatchError(apierrors.ErrNotFound))
+	_, err = db.GetArtifactByID(ctx, org.ID, artifact.ID, nil)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	licenses, err = db.GetArtifactLicensesForArtifact(ctx, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(licenses).To(BeEmpty())
+}
@@ -16,7 +16,7 @@ import (
 
 const (
 	artifactLicenseOutExpr = `al.id, al.created_at, al.name, al.expires_at, ` +
-		`al.owner_useraccount_id, al.organization_id `
+		`al.owner_useraccount_id, al.organization_id, al.seat_count `
 	artifactSelectionsOutExpor = `
 		(
 			SELECT array_agg(DISTINCT row(
@@ -29,7 +29,8 @@ const (
 				))
 			FROM ArtifactLicense_Artifact ala
 			WHERE ala.artifact_license_id = al.id
-		) as artifacts `
+		) as artifacts,
+		(SELECT count(*) FROM ArtifactLicenseSeat als WHERE als.artifact_license_id = al.id) as seats_used `
 )
 
 func GetArtifactLicenses(ctx context.Context, orgID uuid.UUID) ([]types.ArtifactLicense, error) {
@@ -56,9 +57,9 @@ func CreateArtifactLicense(ctx context.Context, license *types.ArtifactLicenseBa
 	rows, err := db.Query(ctx, `
 		WITH inserted AS (
 			INSERT INTO ArtifactLicense (
-				name, expires_at, organization_id, owner_useraccount_id
+				name, expires_at, organization_id, owner_useraccount_id, seat_count
 			) VALUES (
-				@name, @expiresAt, @organizationId, @ownerUserAccountId
+				@name, @expiresAt, @organizationId, @ownerUserAccountId, @seatCount
 			) RETURNING *
 		)
 		SELECT `+artifactLicenseOutExpr+`
@@ -68,6 +69,7 @@ func CreateArtifactLicense(ctx context.Context, license *types.ArtifactLicenseBa
 			"expiresAt":          license.ExpiresAt,
 			"organizationId":     license.OrganizationID,
 			"ownerUserAccountId": license.OwnerUserAccountID,
+			"seatCount":          license.SeatCount,
 		},
 	)
 	if err != nil {
@@ -92,7 +94,8 @@ func UpdateArtifactLicense(ctx context.Context, license *types.ArtifactLicenseBa
 			UPDATE ArtifactLicense SET
 			name = @name,
             expires_at = @expiresAt,
-            owner_useraccount_id = @ownerUserAccountId
+            owner_useraccount_id = @ownerUserAccountId,
+            seat_count = @seatCount
 		 	WHERE id = @id RETURNING *
 		)
 		SELECT `+artifactLicenseOutExpr+`
@@ -102,6 +105,7 @@ func UpdateArtifactLicense(ctx context.Context, license *types.ArtifactLicenseBa
 			"name":               license.Name,
 			"expiresAt":          license.ExpiresAt,
 			"ownerUserAccountId": license.OwnerUserAccountID,
+			"seatCount":          license.SeatCount,
 		},
 	)
 	if err != nil {
@@ -139,6 +143,9 @@ func RemoveAllArtifactsFromLicense(
 	}
 }
 
+// AddArtifactToArtifactLicense adds the artifact, or only one of its versions if artifactVersionId is not nil, to the
+// license. If the artifact does not belong to the organization of the license or the version does not belong to the
+// artifact, apierrors.ErrNotFound is returned.
 func AddArtifactToArtifactLicense(
 	ctx context.Context,
 	licenseID uuid.UUID,
@@ -146,11 +153,18 @@ func AddArtifactToArtifactLicense(
 	artifactVersionId *uuid.UUID,
 ) error {
 	db := internalctx.GetDb(ctx)
-	_, err := db.Exec(
+	cmd, err := db.Exec(
 		ctx,
 		`INSERT INTO ArtifactLicense_Artifact (artifact_license_id, artifact_id, artifact_version_id)
-		VALUES (@licenseId, @id, @versionId)
-		ON CONFLICT (artifact_license_id, artifact_id, artifact_version_id) DO NOTHING`,
+		SELECT al.id, a.id, @versionId
+		FROM ArtifactLicense al
+			JOIN Artifact a ON a.organization_id = al.organization_id
+		WHERE al.id = @licenseId AND a.id = @id
+			AND (@versionId::UUID IS NULL OR EXISTS (
+				SELECT 1 FROM ArtifactVersion av WHERE av.id = @versionId AND av.artifact_id = a.id
+			))
+		ON CONFLICT (artifact_license_id, artifact_id, artifact_version_id)
+			DO UPDATE SET artifact_id = EXCLUDED.artifact_id`,
 		pgx.NamedArgs{
 			"licenseId": licenseID,
 			"id":        artifactId,
@@ -159,17 +173,19 @@ func AddArtifactToArtifactLicense(
 	)
 	if err != nil {
 		return fmt.Errorf("could not insert relation: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
 	}
 	return nil
 }
 
-func GetArtifactLicenseByID(ctx context.Context, id uuid.UUID) (*types.ArtifactLicense, error) {
+func GetArtifactLicenseByID(ctx context.Context, id, orgID uuid.UUID) (*types.ArtifactLicense, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx, `
 			SELECT `+artifactLicenseOutExpr+`, `+artifactSelectionsOutExpor+`
 			FROM ArtifactLicense al
-			WHERE al.id = @id `,
-		pgx.NamedArgs{"id": id},
+			WHERE al.id = @id AND al.organization_id = @orgId`,
+		pgx.NamedArgs{"id": id, "orgId": orgID},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("could not query ArtifactLicense: %w", err)
@@ -204,3 +220,113 @@ func DeleteArtifactLicenseWithID(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+// artifactLicenseHeldByExpr returns a condition that matches licenses (aliased as al) that are owned by the user given
+// by the named parameter or where this user holds a seat.
+func artifactLicenseHeldByExpr(param string) string {
+	return fmt.Sprintf(`(al.owner_useraccount_id = @%[1]v OR EXISTS (
+		SELECT 1 FROM ArtifactLicenseSeat als
+		WHERE als.artifact_license_id = al.id AND als.useraccount_id = @%[1]v
+	))`, param)
+}
+
+func GetArtifactLicenseSeats(ctx context.Context, licenseID uuid.UUID) ([]types.LicenseSeat, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`SELECT als.created_at, (`+userAccountOutputExpr+`) as user_account
+		FROM ArtifactLicenseSeat als
+			JOIN UserAccount u ON als.useraccount_id = u.id
+		WHERE als.artifact_license_id = @licenseId
+		ORDER BY als.created_at`,
+		pgx.NamedArgs{"licenseId": licenseID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactLicenseSeat: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.LicenseSeat]); err != nil {
+		return nil, fmt.Errorf("could not collect ArtifactLicenseSeat: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// CreateArtifactLicenseSeat assigns a seat of the given license to a user.
+// Assigning a seat to a user that already holds one is a no-op.
+// If all seats are already assigned, apierrors.ErrConflict is returned.
+func CreateArtifactLicenseSeat(ctx context.Context, licenseID, userID uuid.UUID) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		// The license row is locked so that concurrent assignments can not exceed the seat count.
+		rows, err := db.Query(
+			ctx,
+			`SELECT al.seat_count,
+				(SELECT count(*) FROM ArtifactLicenseSeat als WHERE als.artifact_license_id = al.id),
+				EXISTS (
+					SELECT 1 FROM ArtifactLicenseSeat als
+					WHERE als.artifact_license_id = al.id AND als.useraccount_id = @userId
+				)
+			FROM ArtifactLicense al
+			WHERE al.id = @licenseId
+			FOR UPDATE`,
+			pgx.NamedArgs{"licenseId": licenseID, "userId": userID},
+		)
+		if err != nil {
+			return fmt.Errorf("could not query ArtifactLicense: %w", err)
+		}
+		seats, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[struct {
+			SeatCount *int
+			SeatsUsed int
+			Assigned  bool
+		}])
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apierrors.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("could not collect ArtifactLicense: %w", err)
+		} else if seats.Assigned {
+			return nil
+		} else if seats.SeatCount == nil || seats.SeatsUsed >= *seats.SeatCount {
+			return fmt.Errorf("%w: no seats available", apierrors.ErrConflict)
+		}
+		if _, err := db.Exec(
+			ctx,
+			`INSERT INTO ArtifactLicenseSeat (artifact_license_id, useraccount_id) VALUES (@licenseId, @userId)`,
+			pgx.NamedArgs{"licenseId": licenseID, "userId": userID},
+		); err != nil {
+			return fmt.Errorf("could not insert ArtifactLicenseSeat: %w", err)
+		}
+		return nil
+	})
+}
+
+func DeleteArtifactLicenseSeat(ctx context.Context, licenseID, userID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(
+		ctx,
+		`DELETE FROM ArtifactLicenseSeat WHERE artifact_license_id = @licenseId AND useraccount_id = @userId`,
+		pgx.NamedArgs{"licenseId": licenseID, "userId": userID},
+	)
+	if err == nil && cmd.RowsAffected() == 0 {
+		err = apierrors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete ArtifactLicenseSeat: %w", err)
+	}
+	return nil
+}
+
+func DeleteArtifactLicenseSeatsOfUserInOrg(ctx context.Context, userID, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(
+		ctx,
+		`DELETE FROM ArtifactLicenseSeat als
+		USING ArtifactLicense al
+		WHERE als.artifact_license_id = al.id AND als.useraccount_id = @userId AND al.organization_id = @orgId`,
+		pgx.NamedArgs{"userId": userID, "orgId": orgID},
+	); err != nil {
+		return fmt.Errorf("could not delete ArtifactLicenseSeat: %w", err)
+	}
+	return nil
+}
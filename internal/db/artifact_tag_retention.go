@@ -0,0 +1,294 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const artifactTagRetentionRuleOutputExpr = `
+	r.id, r.created_at, r.artifact_id, a.organization_id, r.created_by_user_account_id, r.tag_pattern, r.keep_last,
+	r.unpulled_days, r.dry_run, r.last_evaluated_at, r.last_report
+`
+
+func GetArtifactTagRetentionRules(ctx context.Context, artifactID uuid.UUID) ([]types.ArtifactTagRetentionRule, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+artifactTagRetentionRuleOutputExpr+`
+		FROM ArtifactTagRetentionRule r
+			JOIN Artifact a ON a.id = r.artifact_id
+		WHERE r.artifact_id = @artifactId
+		ORDER BY r.created_at`,
+		pgx.NamedArgs{"artifactId": artifactID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactTagRetentionRule: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactTagRetentionRule])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect ArtifactTagRetentionRule: %w", err)
+	}
+	return result, nil
+}
+
+func GetArtifactTagRetentionRule(
+	ctx context.Context,
+	artifactID, id uuid.UUID,
+) (*types.ArtifactTagRetentionRule, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+artifactTagRetentionRuleOutputExpr+`
+		FROM ArtifactTagRetentionRule r
+			JOIN Artifact a ON a.id = r.artifact_id
+		WHERE r.artifact_id = @artifactId AND r.id = @id`,
+		pgx.NamedArgs{"artifactId": artifactID, "id": id},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactTagRetentionRule: %w", err)
+	}
+	return collectArtifactTagRetentionRule(rows)
+}
+
+// GetArtifactTagRetentionRulesForEvaluation returns at most limit rules, starting with those that have not been
+// evaluated for the longest time. Rules of artifacts that are pending deletion are skipped.
+func GetArtifactTagRetentionRulesForEvaluation(
+	ctx context.Context,
+	limit int,
+) ([]types.ArtifactTagRetentionRule, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+artifactTagRetentionRuleOutputExpr+`
+		FROM ArtifactTagRetentionRule r
+			JOIN Artifact a ON a.id = r.artifact_id
+		WHERE a.deletion_requested_at IS NULL
+		ORDER BY r.last_evaluated_at NULLS FIRST, r.created_at
+		LIMIT @limit`,
+		pgx.NamedArgs{"limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactTagRetentionRule: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactTagRetentionRule])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect ArtifactTagRetentionRule: %w", err)
+	}
+	return result, nil
+}
+
+func CreateArtifactTagRetentionRule(ctx context.Context, rule *types.ArtifactTagRetentionRule) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`WITH r AS (
+			INSERT INTO ArtifactTagRetentionRule
+				(artifact_id, created_by_user_account_id, tag_pattern, keep_last, unpulled_days, dry_run)
+			VALUES (@artifactId, @createdBy, @tagPattern, @keepLast, @unpulledDays, @dryRun)
+			RETURNING *
+		)
+		SELECT`+artifactTagRetentionRuleOutputExpr+`FROM r JOIN Artifact a ON a.id = r.artifact_id`,
+		pgx.NamedArgs{
+			"artifactId":   rule.ArtifactID,
+			"createdBy":    rule.CreatedByUserAccountID,
+			"tagPattern":   rule.TagPattern,
+			"keepLast":     rule.KeepLast,
+			"unpulledDays": rule.UnpulledDays,
+			"dryRun":       rule.DryRun,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert ArtifactTagRetentionRule: %w", err)
+	}
+	if result, err := collectArtifactTagRetentionRule(rows); err != nil {
+		return err
+	} else {
+		*rule = *result
+		return nil
+	}
+}
+
+// UpdateArtifactTagRetentionRule replaces the settings of a rule. The report of its last evaluation is kept.
+func UpdateArtifactTagRetentionRule(ctx context.Context, rule *types.ArtifactTagRetentionRule) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`WITH r AS (
+			UPDATE ArtifactTagRetentionRule SET
+				tag_pattern = @tagPattern,
+				keep_last = @keepLast,
+				unpulled_days = @unpulledDays,
+				dry_run = @dryRun
+			WHERE id = @id AND artifact_id = @artifactId
+			RETURNING *
+		)
+		SELECT`+artifactTagRetentionRuleOutputExpr+`FROM r JOIN Artifact a ON a.id = r.artifact_id`,
+		pgx.NamedArgs{
+			"id":           rule.ID,
+			"artifactId":   rule.ArtifactID,
+			"tagPattern":   rule.TagPattern,
+			"keepLast":     rule.KeepLast,
+			"unpulledDays": rule.UnpulledDays,
+			"dryRun":       rule.DryRun,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update ArtifactTagRetentionRule: %w", err)
+	}
+	if result, err := collectArtifactTagRetentionRule(rows); err != nil {
+		return err
+	} else {
+		*rule = *result
+		return nil
+	}
+}
+
+func UpdateArtifactTagRetentionRuleReport(
+	ctx context.Context,
+	id uuid.UUID,
+	report types.ArtifactTagRetentionReport,
+) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE ArtifactTagRetentionRule SET last_evaluated_at = @evaluatedAt, last_report = @report WHERE id = @id`,
+		pgx.NamedArgs{"id": id, "evaluatedAt": report.EvaluatedAt, "report": report},
+	); err != nil {
+		return fmt.Errorf("could not update ArtifactTagRetentionRule: %w", err)
+	}
+	return nil
+}
+
+func DeleteArtifactTagRetentionRule(ctx context.Context, artifactID, id uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		`DELETE FROM ArtifactTagRetentionRule WHERE id = @id AND artifact_id = @artifactId`,
+		pgx.NamedArgs{"id": id, "artifactId": artifactID},
+	); err != nil {
+		return fmt.Errorf("could not delete ArtifactTagRetentionRule: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+// GetArtifactTagRetentionCandidates returns all tags of the artifact, most recently pushed first. Pulls of any
+// reference to the manifest of a tag count as pulls of the tag, but HEAD requests do not.
+func GetArtifactTagRetentionCandidates(
+	ctx context.Context,
+	artifactID uuid.UUID,
+) ([]types.ArtifactTagRetentionCandidate, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT
+			v.id,
+			v.name,
+			v.manifest_blob_digest,
+			coalesce(v.updated_at, v.created_at) AS pushed_at,
+			(
+				SELECT max(p.created_at)
+				FROM ArtifactVersion pv
+					JOIN ArtifactVersionPull p ON p.artifact_version_id = pv.id
+				WHERE pv.artifact_id = v.artifact_id
+					AND pv.manifest_blob_digest = v.manifest_blob_digest
+					AND p.method != 'HEAD'
+			) AS last_pulled_at,
+			EXISTS (SELECT FROM ArtifactLicense_Artifact ala WHERE ala.artifact_version_id = v.id) AS licensed,
+			coalesce(v.id = a.recommended_artifact_version_id, false) AS recommended
+		FROM ArtifactVersion v
+			JOIN Artifact a ON a.id = v.artifact_id
+		WHERE v.artifact_id = @artifactId AND v.name NOT LIKE '%:%'
+		ORDER BY pushed_at DESC, v.name`,
+		pgx.NamedArgs{"artifactId": artifactID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactVersion: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactTagRetentionCandidate])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect ArtifactVersion: %w", err)
+	}
+	return result, nil
+}
+
+// GetActiveDeploymentApplicationVersions returns the application versions of the latest revision and the latest
+// released revision of all active deployments of the organization.
+func GetActiveDeploymentApplicationVersions(
+	ctx context.Context,
+	orgID uuid.UUID,
+) ([]types.ApplicationVersion, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT av.id, av.created_at, av.archived_at, av.name, av.chart_type, av.chart_name, av.chart_url,
+			av.chart_version, av.values_file_data, av.template_file_data, av.compose_file_data, av.application_id,
+			av.resource_requirements, av.metrics_endpoint, av.acknowledgment_message
+		FROM ApplicationVersion av
+		WHERE av.id IN (
+			SELECT cur.application_version_id
+			FROM Deployment d
+				JOIN DeploymentTarget dt ON dt.id = d.deployment_target_id
+				CROSS JOIN LATERAL (
+					(
+						SELECT dr.application_version_id FROM DeploymentRevision dr
+						WHERE dr.deployment_id = d.id
+						ORDER BY dr.created_at DESC
+						LIMIT 1
+					) UNION (
+						SELECT dr.application_version_id FROM DeploymentRevision dr
+						WHERE dr.deployment_id = d.id AND `+deploymentRevisionReleasedExpr+`
+						ORDER BY dr.created_at DESC
+						LIMIT 1
+					)
+				) cur
+			WHERE dt.organization_id = @orgId
+				AND dt.archived_at IS NULL
+				AND d.archived_at IS NULL
+				AND d.uninstalled_at IS NULL
+		)`,
+		pgx.NamedArgs{"orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ApplicationVersion: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ApplicationVersion])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect ApplicationVersion: %w", err)
+	}
+	return result, nil
+}
+
+// GetDeploymentTargetInventoryImages returns the images of all workloads in the latest inventory snapshots of the
+// deployment targets of the organization.
+func GetDeploymentTargetInventoryImages(ctx context.Context, orgID uuid.UUID) ([]string, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT DISTINCT image
+		FROM DeploymentTargetInventory i
+			JOIN DeploymentTarget dt ON dt.id = i.deployment_target_id
+			CROSS JOIN LATERAL jsonb_array_elements(i.items) item
+			CROSS JOIN LATERAL jsonb_array_elements_text(
+				CASE WHEN jsonb_typeof(item -> 'images') = 'array' THEN item -> 'images' ELSE '[]' END
+			) image
+		WHERE dt.organization_id = @orgId AND dt.archived_at IS NULL`,
+		pgx.NamedArgs{"orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetInventory: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetInventory: %w", err)
+	}
+	return result, nil
+}
+
+func collectArtifactTagRetentionRule(rows pgx.Rows) (*types.ArtifactTagRetentionRule, error) {
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.ArtifactTagRetentionRule])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not collect ArtifactTagRetentionRule: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestArtifactTagRetentionRules(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, _ := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+
+	rule := types.ArtifactTagRetentionRule{ArtifactID: artifact.ID, TagPattern: "*", KeepLast: util.PtrTo(3)}
+	g.Expect(db.CreateArtifactTagRetentionRule(ctx, &rule)).To(Succeed())
+	g.Expect(rule.OrganizationID).To(Equal(org.ID))
+
+	rule.UnpulledDays = util.PtrTo(30)
+	rule.DryRun = true
+	g.Expect(db.UpdateArtifactTagRetentionRule(ctx, &rule)).To(Succeed())
+	report := types.ArtifactTagRetentionReport{EvaluatedAt: rule.CreatedAt, Removed: []string{"0.9.0"}}
+	g.Expect(db.UpdateArtifactTagRetentionRuleReport(ctx, rule.ID, report)).To(Succeed())
+
+	rules, err := db.GetArtifactTagRetentionRules(ctx, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rules).To(HaveLen(1))
+	g.Expect(rules[0].UnpulledDays).To(HaveValue(Equal(30)))
+	g.Expect(rules[0].DryRun).To(BeTrue())
+	g.Expect(rules[0].LastReport).To(HaveValue(HaveField("Removed", ConsistOf("0.9.0"))))
+
+	g.Expect(db.DeleteArtifactTagRetentionRule(ctx, artifact.ID, rule.ID)).To(Succeed())
+	g.Expect(db.DeleteArtifactTagRetentionRule(ctx, artifact.ID, rule.ID)).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestGetArtifactTagRetentionCandidates(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0", "1.1.0")
+	g.Expect(db.UpdateArtifactRecommendedVersion(ctx, artifact, &versions[2].ID)).To(Succeed())
+
+	candidates, err := db.GetArtifactTagRetentionCandidates(ctx, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(candidates).To(HaveLen(2), "digests are not candidates")
+	for _, candidate := range candidates {
+		g.Expect(candidate.LastPulledAt).To(BeNil())
+		g.Expect(candidate.Recommended).To(Equal(candidate.Name == "1.1.0"))
+	}
+
+	// a pull of the digest counts as a pull of all tags of the manifest, a HEAD request does not
+	g.Expect(db.CreateArtifactPullLogEntry(ctx, versions[0].ID, nil, nil, "", http.MethodHead, "")).To(Succeed())
+	candidates, err = db.GetArtifactTagRetentionCandidates(ctx, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(candidates).To(HaveEach(HaveField("LastPulledAt", BeNil())))
+	g.Expect(db.CreateArtifactPullLogEntry(ctx, versions[0].ID, nil, nil, "", http.MethodGet, "")).To(Succeed())
+	candidates, err = db.GetArtifactTagRetentionCandidates(ctx, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(candidates).To(HaveEach(HaveField("LastPulledAt", Not(BeNil()))))
+}
+
+func TestDeleteArtifactVersion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0", "1.1.0")
+
+	g.Expect(db.DeleteArtifactVersion(ctx, *org.Slug, artifact.Name, "1.0.0")).To(Succeed())
+	g.Expect(db.DeleteArtifactVersion(ctx, *org.Slug, artifact.Name, "1.0.0")).To(MatchError(apierrors.ErrNotFound))
+	remaining, err := db.GetArtifactVersions(ctx, *org.Slug, artifact.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(remaining).To(HaveLen(2))
+
+	// deleting the digest also deletes its tags
+	g.Expect(db.DeleteArtifactVersion(ctx, *org.Slug, artifact.Name, versions[0].Name)).To(Succeed())
+	_, err = db.GetArtifactVersion(ctx, *org.Slug, artifact.Name, "1.1.0")
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
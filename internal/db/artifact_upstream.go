@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	artifactUpstreamOutputExpr = `
+		au.artifact_id, au.created_at, au.updated_at, au.updated_by_user_account_id, au.repository, au.username,
+		au.password
+	`
+)
+
+func GetArtifactUpstream(ctx context.Context, artifactID uuid.UUID) (*types.ArtifactUpstream, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+artifactUpstreamOutputExpr+"FROM ArtifactUpstream au WHERE au.artifact_id = @artifactId",
+		pgx.NamedArgs{"artifactId": artifactID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactUpstream: %w", err)
+	}
+	return collectArtifactUpstream(rows)
+}
+
+// GetArtifactUpstreamByName returns the upstream of the artifact with the given name or alias. It returns
+// apierrors.ErrNotFound if there is no such artifact or if it has no upstream.
+func GetArtifactUpstreamByName(ctx context.Context, orgSlug, name string) (*types.ArtifactUpstream, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+artifactUpstreamOutputExpr+`
+		FROM ArtifactUpstream au
+			JOIN Artifact a ON a.id = au.artifact_id
+			JOIN Organization o ON o.id = a.organization_id
+		WHERE o.slug = lower(@orgSlug) AND`+artifactNameMatchExpr,
+		pgx.NamedArgs{"orgSlug": orgSlug, "name": name},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactUpstream: %w", err)
+	}
+	return collectArtifactUpstream(rows)
+}
+
+// UpsertArtifactUpstream makes the artifact a pull-through cache of the upstream repository or replaces the upstream
+// of an artifact that already is one.
+func UpsertArtifactUpstream(ctx context.Context, upstream *types.ArtifactUpstream) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO ArtifactUpstream AS au (artifact_id, updated_by_user_account_id, repository, username, password)
+			VALUES (@artifactId, @updatedBy, @repository, @username, @password)
+			ON CONFLICT (artifact_id) DO UPDATE SET
+				updated_at = current_timestamp,
+				updated_by_user_account_id = EXCLUDED.updated_by_user_account_id,
+				repository = EXCLUDED.repository,
+				username = EXCLUDED.username,
+				password = EXCLUDED.password
+			RETURNING`+artifactUpstreamOutputExpr,
+		pgx.NamedArgs{
+			"artifactId": upstream.ArtifactID,
+			"updatedBy":  upstream.UpdatedByUserAccountID,
+			"repository": upstream.Repository,
+			"username":   upstream.Username,
+			"password":   upstream.Password,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not save ArtifactUpstream: %w", err)
+	}
+	if result, err := collectArtifactUpstream(rows); err != nil {
+		return err
+	} else {
+		*upstream = *result
+		return nil
+	}
+}
+
+// DeleteArtifactUpstream turns the artifact back into a regular artifact. Manifests and blobs that have already been
+// fetched from the upstream are kept. It returns apierrors.ErrNotFound if the artifact has no upstream.
+func DeleteArtifactUpstream(ctx context.Context, artifactID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		"DELETE FROM ArtifactUpstream WHERE artifact_id = @artifactId",
+		pgx.NamedArgs{"artifactId": artifactID},
+	); err != nil {
+		return fmt.Errorf("could not delete ArtifactUpstream: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+func collectArtifactUpstream(rows pgx.Rows) (*types.ArtifactUpstream, error) {
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ArtifactUpstream]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not collect ArtifactUpstream: %w", err)
+	} else {
+		return &result, nil
+	}
+}
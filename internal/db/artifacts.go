@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/glasskube/distr/internal/apierrors"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/fieldset"
+	"github.com/glasskube/distr/internal/pagination"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
@@ -18,7 +21,11 @@ import (
 )
 
 const (
-	artifactOutputExpr         = ` a.id, a.created_at, a.organization_id, a.name, a.image_id `
+	artifactOutputExpr = ` a.id, a.created_at, a.organization_id, a.name, a.image_id, a.deletion_requested_at,
+		a.deletion_requested_by_useraccount_id, a.deletion_scheduled_at, a.recommended_artifact_version_id,
+		(SELECT rv.name FROM ArtifactVersion rv WHERE rv.id = a.recommended_artifact_version_id)
+			AS recommended_reference, a.public, a.immutable_tags, a.mutable_tag_patterns,
+		(SELECT au.repository FROM ArtifactUpstream au WHERE au.artifact_id = a.id) AS upstream_repository `
 	artifactOutputWithSlugExpr = artifactOutputExpr + ", o.slug AS organization_slug"
 	artifactVersionOutputExpr  = `
 		v.id,
@@ -32,6 +39,23 @@ const (
 		v.manifest_content_type,
 		v.artifact_id
 	`
+	// artifactVersionReferenceMatchExpr matches the version v of artifact a by its name. The virtual
+	// recommended tag matches the recommended version, which takes precedence over a pushed tag of the same name.
+	artifactVersionReferenceMatchExpr = `
+		(v.name = @reference OR (@reference = @recommendedTag AND v.id = a.recommended_artifact_version_id))
+	`
+	// artifactNameMatchExpr matches an artifact case-insensitively by its name or by an alias that has not expired yet
+	artifactNameMatchExpr = `
+		(a.name_normalized = lower(@name) OR a.id IN (
+			SELECT aa.artifact_id FROM ArtifactAlias aa
+			WHERE aa.organization_id = a.organization_id AND lower(aa.name) = lower(@name) AND aa.expires_at > now()
+		))
+	`
+	// artifactSearchExpr matches artifacts whose name contains @q or is similar to it. It matches all artifacts if
+	// @q is empty. The arguments are added by artifactSearchArgs.
+	artifactSearchExpr = `
+		(@q = '' OR a.name_normalized LIKE @qPattern OR a.name_normalized % @q)
+	`
 	artifactDownloadsOutExpr = `
 			count(DISTINCT avpl.id) as downloads_total,
 			count(DISTINCT avpl.useraccount_id) as downloaded_by_count,
@@ -40,20 +64,57 @@ const (
 	`
 )
 
-func GetArtifactsByOrgID(ctx context.Context, orgID uuid.UUID) ([]types.ArtifactWithDownloads, error) {
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// artifactDownloadsFields are the JSON fields of artifacts that need the expensive join with the pull log.
+var artifactDownloadsFields = []string{"downloadsTotal", "downloadedByCount", "downloadedByUsers"}
+
+// artifactListDownloadsExprs returns the output, join and group by expressions for the download metrics of an
+// artifact list. If fields does not contain any download metric, the pull log is not joined and all metrics are zero.
+func artifactListDownloadsExprs(fields fieldset.Set, pullJoinCondition string) (string, string, string) {
+	if !fields.Has(artifactDownloadsFields...) {
+		return `
+			0 AS downloads_total,
+			0 AS downloaded_by_count,
+			ARRAY[]::UUID[] AS downloaded_by_users
+		`, "", ""
+	}
+	return artifactDownloadsOutExpr, `
+			LEFT JOIN ArtifactVersion av ON a.id = av.artifact_id
+			LEFT JOIN ArtifactVersionPull avpl ON avpl.artifact_version_id = av.id` + pullJoinCondition + `
+		`, `
+			GROUP BY a.id, a.created_at, a.organization_id, a.name, o.slug
+		`
+}
+
+// artifactSearchArgs adds the arguments of artifactSearchExpr for the search query to args.
+func artifactSearchArgs(args pgx.NamedArgs, query string) pgx.NamedArgs {
+	query = strings.ToLower(strings.TrimSpace(query))
+	args["q"] = query
+	args["qPattern"] = "%" + likeEscaper.Replace(query) + "%"
+	return args
+}
+
+// GetArtifactsByOrgID returns all artifacts of an organization. If fields is not nil, download metrics are only
+// computed if they are requested. If query is not empty, only artifacts whose name contains it or is similar to it
+// are returned, the most similar first.
+func GetArtifactsByOrgID(ctx context.Context, orgID uuid.UUID, query string, fields fieldset.Set) (
+	[]types.ArtifactWithDownloads, error,
+) {
 	db := internalctx.GetDb(ctx)
+	downloadsExpr, joinExpr, groupByExpr := artifactListDownloadsExprs(fields, "")
 	if artifactRows, err := db.Query(ctx, `
-			SELECT `+artifactOutputWithSlugExpr+`,`+artifactDownloadsOutExpr+`
+			SELECT `+artifactOutputWithSlugExpr+`,`+downloadsExpr+`
 			FROM Artifact a
 			JOIN Organization o ON o.id = a.organization_id
-			LEFT JOIN ArtifactVersion av ON a.id = av.artifact_id
-			LEFT JOIN ArtifactVersionPull avpl ON avpl.artifact_version_id = av.id
-			WHERE a.organization_id = @orgId
-			GROUP BY a.id, a.created_at, a.organization_id, a.name, o.slug
-			ORDER BY a.name`,
-		pgx.NamedArgs{
+			`+joinExpr+`
+			WHERE a.organization_id = @orgId AND`+artifactSearchExpr+`
+			`+groupByExpr+`
+			ORDER BY similarity(a.name_normalized, @q) DESC, a.name`,
+		artifactSearchArgs(pgx.NamedArgs{
 			"orgId": orgID,
-		}); err != nil {
+		}, query)); err != nil {
 		return nil, fmt.Errorf("failed to query artifacts: %w", err)
 	} else if artifacts, err := pgx.CollectRows(
 		artifactRows, pgx.RowToStructByName[types.ArtifactWithDownloads],
@@ -64,30 +125,36 @@ func GetArtifactsByOrgID(ctx context.Context, orgID uuid.UUID) ([]types.Artifact
 	}
 }
 
-func GetArtifactsByLicenseOwnerID(ctx context.Context, orgID uuid.UUID, ownerID uuid.UUID) (
-	[]types.ArtifactWithDownloads, error,
-) {
+// GetArtifactsByLicenseOwnerID returns all artifacts that a license owner or seat holder has access to. Fields and
+// query are applied like in [GetArtifactsByOrgID].
+func GetArtifactsByLicenseOwnerID(
+	ctx context.Context,
+	orgID uuid.UUID,
+	ownerID uuid.UUID,
+	query string,
+	fields fieldset.Set,
+) ([]types.ArtifactWithDownloads, error) {
 	db := internalctx.GetDb(ctx)
+	downloadsExpr, joinExpr, groupByExpr := artifactListDownloadsExprs(fields, " AND avpl.useraccount_id = @ownerId")
 	if artifactRows, err := db.Query(ctx, `
-			SELECT `+artifactOutputWithSlugExpr+`,`+artifactDownloadsOutExpr+`
+			SELECT `+artifactOutputWithSlugExpr+`,`+downloadsExpr+`
 			FROM Artifact a
 			JOIN Organization o ON o.id = a.organization_id
-			LEFT JOIN ArtifactVersion av ON a.id = av.artifact_id
-			LEFT JOIN ArtifactVersionPull avpl ON avpl.artifact_version_id = av.id AND avpl.useraccount_id = @ownerId
-			WHERE a.organization_id = @orgId
+			`+joinExpr+`
+			WHERE a.organization_id = @orgId AND`+artifactSearchExpr+`
 			AND EXISTS(
 				SELECT ala.id
 				FROM ArtifactLicense_Artifact ala
 				INNER JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
-				WHERE al.owner_useraccount_id = @ownerId AND (al.expires_at IS NULL OR al.expires_at > now())
+				WHERE `+artifactLicenseHeldByExpr("ownerId")+` AND (al.expires_at IS NULL OR al.expires_at > now())
 				AND ala.artifact_id = a.id
 			)
-			GROUP BY a.id, a.created_at, a.organization_id, a.name, o.slug
-			ORDER BY a.name`,
-		pgx.NamedArgs{
+			`+groupByExpr+`
+			ORDER BY similarity(a.name_normalized, @q) DESC, a.name`,
+		artifactSearchArgs(pgx.NamedArgs{
 			"orgId":   orgID,
 			"ownerId": ownerID,
-		}); err != nil {
+		}, query)); err != nil {
 		return nil, fmt.Errorf("failed to query artifacts: %w", err)
 	} else if artifacts, err := pgx.CollectRows(
 		artifactRows, pgx.RowToStructByName[types.ArtifactWithDownloads],
@@ -148,7 +215,7 @@ func GetArtifactByName(ctx context.Context, orgSlug, name string) (*types.Artifa
 		`SELECT`+artifactOutputExpr+`
 			FROM Artifact a
 			JOIN Organization o on o.id = a.organization_id
-			WHERE o.slug = @orgSlug AND a.name = @name
+			WHERE o.slug = lower(@orgSlug) AND`+artifactNameMatchExpr+`
 			ORDER BY a.name`,
 		pgx.NamedArgs{
 			"orgSlug": orgSlug,
@@ -211,6 +278,7 @@ func GetVersionsForArtifact(ctx context.Context, artifactID uuid.UUID, ownerID *
 					SELECT aggregate.base_av_id, av1.id, avp.artifact_blob_digest, avp.artifact_blob_size
 					FROM aggregate
 					JOIN ArtifactVersion av1 ON av1.manifest_blob_digest = aggregate.artifact_blob_digest
+						AND av1.artifact_id = av.artifact_id
 					JOIN ArtifactVersionPart avp ON av1.id = avp.artifact_version_id
 				)
 				SELECT DISTINCT * FROM aggregate
@@ -219,39 +287,7 @@ func GetVersionsForArtifact(ctx context.Context, artifactID uuid.UUID, ownerID *
 				(NOT @checkLicense OR avpl.useraccount_id = @ownerId)
 			WHERE av.artifact_id = @artifactId
 			AND av.name LIKE '%:%'
-			AND (
-				NOT @checkLicense
-				-- license check
-				OR EXISTS (
-					-- license for all versions of the artifact
-					SELECT *
-					FROM ArtifactLicense_Artifact ala
-					INNER JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
-					WHERE ala.artifact_id = @artifactId AND ala.artifact_version_id IS NULL
-					AND al.owner_useraccount_id = @ownerId AND (al.expires_at IS NULL OR al.expires_at > now())
-				)
-				OR EXISTS (
-					-- or license only for specific versions or their parent versions
-					WITH RECURSIVE ArtifactVersionAggregate (id, manifest_blob_digest) AS (
-						SELECT avx.id, avx.manifest_blob_digest
-						FROM ArtifactVersion avx
-						WHERE avx.manifest_blob_digest = av.manifest_blob_digest AND avx.artifact_id = @artifactId
-
-						UNION ALL
-
-						SELECT DISTINCT avx.id, avx.manifest_blob_digest
-						FROM ArtifactVersion avx
-						JOIN ArtifactVersionPart avp ON avx.id = avp.artifact_version_id
-						JOIN ArtifactVersionAggregate agg ON avp.artifact_blob_digest = agg.manifest_blob_digest
-					)
-					SELECT *
-					FROM ArtifactVersionAggregate avagg
-					INNER JOIN ArtifactLicense_Artifact ala ON ala.artifact_version_id = avagg.id
-					INNER JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
-					WHERE al.owner_useraccount_id = @ownerId AND (al.expires_at IS NULL OR al.expires_at > now())
-					AND ala.artifact_id = @artifactId
-				)
-			)
+			AND `+artifactVersionLicenseExpr("av.manifest_blob_digest")+`
 			AND EXISTS (
 				-- only versions that have a tag
 				SELECT avt.id
@@ -276,13 +312,168 @@ func GetVersionsForArtifact(ctx context.Context, artifactID uuid.UUID, ownerID *
 	}
 }
 
+// artifactVersionLicenseExpr returns a condition that is true if the license holder @ownerId has access to the version
+// of the artifact @artifactId with the digest given by the SQL expression, or if @checkLicense is false. Access to a
+// version is granted by a license for the whole artifact, for the version itself or for a version that contains it.
+func artifactVersionLicenseExpr(digestExpr string) string {
+	return `(
+			NOT @checkLicense
+			-- license check
+			OR EXISTS (
+				-- license for all versions of the artifact
+				SELECT *
+				FROM ArtifactLicense_Artifact ala
+				INNER JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
+				WHERE ala.artifact_id = @artifactId AND ala.artifact_version_id IS NULL
+				AND ` + artifactLicenseHeldByExpr("ownerId") + ` AND (al.expires_at IS NULL OR al.expires_at > now())
+			)
+			OR EXISTS (
+				-- or license only for specific versions or their parent versions
+				WITH RECURSIVE ArtifactVersionAggregate (id, manifest_blob_digest) AS (
+					SELECT avx.id, avx.manifest_blob_digest
+					FROM ArtifactVersion avx
+					WHERE avx.manifest_blob_digest = ` + digestExpr + ` AND avx.artifact_id = @artifactId
+
+					UNION ALL
+
+					SELECT DISTINCT avx.id, avx.manifest_blob_digest
+					FROM ArtifactVersion avx
+					JOIN ArtifactVersionPart avp ON avx.id = avp.artifact_version_id
+					JOIN ArtifactVersionAggregate agg ON avp.artifact_blob_digest = agg.manifest_blob_digest
+				)
+				SELECT *
+				FROM ArtifactVersionAggregate avagg
+				INNER JOIN ArtifactLicense_Artifact ala ON ala.artifact_version_id = avagg.id
+				INNER JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
+				WHERE ` + artifactLicenseHeldByExpr("ownerId") + ` AND (al.expires_at IS NULL OR al.expires_at > now())
+				AND ala.artifact_id = @artifactId
+			)
+		)`
+}
+
+// GetArtifactTagNames returns the tags of the artifact that sort after last in byte order, like required by the OCI
+// distribution spec for paginated tag listings. At most limit tags are returned if limit is positive.
+// The virtual tag types.ArtifactRecommendedTag is included if the artifact has a recommended version. If ownerID is
+// not nil, only the tags of versions that the license holder has access to are returned.
+func GetArtifactTagNames(
+	ctx context.Context,
+	artifactID uuid.UUID,
+	ownerID *uuid.UUID,
+	last string,
+	limit int,
+) ([]string, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		WITH visible AS (
+			SELECT av.manifest_blob_digest
+			FROM ArtifactVersion av
+			WHERE av.artifact_id = @artifactId
+			AND av.name LIKE '%:%'
+			AND `+artifactVersionLicenseExpr("av.manifest_blob_digest")+`
+		),
+		tags AS (
+			SELECT avt.name
+			FROM ArtifactVersion avt
+			WHERE avt.artifact_id = @artifactId
+			AND avt.name NOT LIKE '%:%'
+			AND avt.manifest_blob_digest IN (SELECT manifest_blob_digest FROM visible)
+			UNION
+			SELECT @recommendedTag::TEXT
+			FROM Artifact a
+			JOIN ArtifactVersion rv ON rv.id = a.recommended_artifact_version_id
+			WHERE a.id = @artifactId
+			AND rv.manifest_blob_digest IN (SELECT manifest_blob_digest FROM visible)
+			AND EXISTS (
+				SELECT FROM ArtifactVersion avt
+				WHERE avt.artifact_id = @artifactId
+				AND avt.manifest_blob_digest = rv.manifest_blob_digest
+				AND avt.name NOT LIKE '%:%'
+			)
+		)
+		SELECT name FROM tags
+		WHERE name COLLATE "C" > @last
+		ORDER BY name COLLATE "C"
+		LIMIT @limit`,
+		pgx.NamedArgs{
+			"artifactId":     artifactID,
+			"ownerId":        ownerID,
+			"checkLicense":   ownerID != nil,
+			"recommendedTag": types.ArtifactRecommendedTag,
+			"last":           last,
+			"limit":          pageLimit(limit),
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not query tags: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+		return nil, fmt.Errorf("could not collect tags: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetArtifactRepositoryNames returns the repository names ("<organization slug>/<artifact name>") of the artifacts of
+// the organization that sort after last in byte order. At most limit names are returned if limit is positive. If
+// ownerID is not nil, only the artifacts that the license holder has a license for are returned.
+func GetArtifactRepositoryNames(
+	ctx context.Context,
+	orgID uuid.UUID,
+	ownerID *uuid.UUID,
+	last string,
+	limit int,
+) ([]string, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT r.name
+		FROM (
+			SELECT o.slug || '/' || a.name AS name
+			FROM Artifact a
+			JOIN Organization o ON o.id = a.organization_id
+			WHERE a.organization_id = @orgId
+			AND (
+				NOT @checkLicense
+				OR EXISTS (
+					SELECT ala.id
+					FROM ArtifactLicense_Artifact ala
+					INNER JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
+					WHERE `+artifactLicenseHeldByExpr("ownerId")+` AND (al.expires_at IS NULL OR al.expires_at > now())
+					AND ala.artifact_id = a.id
+				)
+			)
+		) r
+		WHERE r.name COLLATE "C" > @last
+		ORDER BY r.name COLLATE "C"
+		LIMIT @limit`,
+		pgx.NamedArgs{
+			"orgId":        orgID,
+			"ownerId":      ownerID,
+			"checkLicense": ownerID != nil,
+			"last":         last,
+			"limit":        pageLimit(limit),
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not query artifacts: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+		return nil, fmt.Errorf("could not collect artifacts: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// pageLimit returns the argument for a LIMIT clause, which is NULL and thus no limit if limit is not positive.
+func pageLimit(limit int) *int {
+	if limit > 0 {
+		return &limit
+	}
+	return nil
+}
+
 func GetOrCreateArtifact(ctx context.Context, orgID uuid.UUID, artifactName string) (*types.Artifact, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(
 		ctx,
 		`SELECT `+artifactOutputExpr+`
 			FROM Artifact a
-			WHERE a.name = @name AND a.organization_id = @orgId`,
+			WHERE a.organization_id = @orgId AND`+artifactNameMatchExpr,
 		pgx.NamedArgs{
 			"name":  artifactName,
 			"orgId": orgID,
@@ -336,8 +527,8 @@ func GetArtifactVersions(ctx context.Context, orgName, name string) ([]types.Art
 		FROM Artifact a
 		JOIN Organization o ON o.id = a.organization_id
 		LEFT JOIN ArtifactVersion v ON a.id = v.artifact_id
-		WHERE o.slug = @orgName
-			AND a.name = @name
+		WHERE o.slug = lower(@orgName)
+			AND`+artifactNameMatchExpr+`
 		ORDER BY v.name ASC`,
 		pgx.NamedArgs{"orgName": orgName, "name": name},
 	)
@@ -361,9 +552,13 @@ func CheckLicenseForArtifact(ctx context.Context, orgName, name, reference strin
 				JOIN ArtifactVersion av ON a.id = av.artifact_id
 				JOIN ArtifactVersion avx ON a.id = avx.artifact_id AND avx.manifest_blob_digest = av.manifest_blob_digest
 				JOIN Organization o ON o.id = a.organization_id
-				WHERE o.slug = @orgName
-				AND a.name = @name
-				AND (avx.name = @reference OR avx.manifest_blob_digest = @reference)
+				WHERE o.slug = lower(@orgName)
+				AND`+artifactNameMatchExpr+`
+				AND (
+					avx.name = @reference
+					OR avx.manifest_blob_digest = @reference
+					OR (@reference = @recommendedTag AND avx.id = a.recommended_artifact_version_id)
+				)
 			UNION ALL
 			SELECT DISTINCT av.id, av.artifact_id, av.manifest_blob_digest
 				FROM ArtifactVersion av
@@ -377,10 +572,16 @@ func CheckLicenseForArtifact(ctx context.Context, orgName, name, reference strin
 					ON av.artifact_id = ala.artifact_id
 						AND (ala.artifact_version_id IS NULL OR ala.artifact_version_id = av.id)
 				JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
-				WHERE al.owner_useraccount_id = @userId
+				WHERE `+artifactLicenseHeldByExpr("userId")+`
 					AND (al.expires_at IS NULL OR al.expires_at > now())
 		)`,
-		pgx.NamedArgs{"orgName": orgName, "name": name, "reference": reference, "userId": userID},
+		pgx.NamedArgs{
+			"orgName":        orgName,
+			"name":           name,
+			"reference":      reference,
+			"recommendedTag": types.ArtifactRecommendedTag,
+			"userId":         userID,
+		},
 	)
 	if err != nil {
 		return fmt.Errorf("could not query ArtifactVersion: %w", err)
@@ -420,6 +621,62 @@ func CheckOrganizationForArtifactBlob(ctx context.Context, digest string, orgID
 	return nil
 }
 
+// CheckArtifactForBlob returns apierrors.ErrNotFound if no version of the artifact with the given name contains the
+// blob with the given digest.
+func CheckArtifactForBlob(ctx context.Context, orgSlug, name string, digest types.Digest) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`SELECT exists(
+			SELECT *
+				FROM Artifact a
+				JOIN Organization o ON o.id = a.organization_id
+				JOIN ArtifactVersion av ON a.id = av.artifact_id
+				JOIN ArtifactVersionPart avp ON av.id = avp.artifact_version_id
+				WHERE avp.artifact_blob_digest = @digest
+					AND o.slug = lower(@orgSlug) AND`+artifactNameMatchExpr+`
+		)`,
+		pgx.NamedArgs{"digest": digest, "orgSlug": orgSlug, "name": name},
+	)
+	if err != nil {
+		return fmt.Errorf("could not query ArtifactVersion: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[struct{ Exists bool }])
+	if err != nil {
+		return fmt.Errorf("could not query ArtifactVersion: %w", err)
+	} else if !result.Exists {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+// CheckOrganizationForArtifactManifest returns apierrors.ErrNotFound if the organization does not have an artifact
+// version with the given manifest digest.
+func CheckOrganizationForArtifactManifest(ctx context.Context, digest string, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`SELECT exists(
+			SELECT *
+				FROM Artifact a
+				JOIN ArtifactVersion av ON a.id = av.artifact_id
+				WHERE av.manifest_blob_digest = @digest
+					AND a.organization_id = @orgId
+		)`,
+		pgx.NamedArgs{"digest": digest, "orgId": orgID},
+	)
+	if err != nil {
+		return fmt.Errorf("could not query ArtifactVersion: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[struct{ Exists bool }])
+	if err != nil {
+		return fmt.Errorf("could not query ArtifactVersion: %w", err)
+	} else if !result.Exists {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
 func CheckLicenseForArtifactBlob(ctx context.Context, digest string, userID uuid.UUID) error {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(
@@ -442,7 +699,7 @@ func CheckLicenseForArtifactBlob(ctx context.Context, digest string, userID uuid
 					ON av.artifact_id = ala.artifact_id
 						AND (ala.artifact_version_id IS NULL OR ala.artifact_version_id = av.id)
 				JOIN ArtifactLicense al ON ala.artifact_license_id = al.id
-				WHERE al.owner_useraccount_id = @userId
+				WHERE `+artifactLicenseHeldByExpr("userId")+`
 					AND (al.expires_at IS NULL OR al.expires_at > now())
 		)`,
 		pgx.NamedArgs{"digest": digest, "userId": userID},
@@ -459,6 +716,35 @@ func CheckLicenseForArtifactBlob(ctx context.Context, digest string, userID uuid
 	return nil
 }
 
+// CheckPublicArtifactBlob returns apierrors.ErrForbidden if no public artifact of the organization contains the blob
+// with the given digest.
+func CheckPublicArtifactBlob(ctx context.Context, digest string, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`SELECT exists(
+			SELECT *
+				FROM Artifact a
+				JOIN ArtifactVersion av ON a.id = av.artifact_id
+				JOIN ArtifactVersionPart avp ON av.id = avp.artifact_version_id
+				WHERE avp.artifact_blob_digest = @digest
+					AND a.organization_id = @orgId
+					AND a.public
+		)`,
+		pgx.NamedArgs{"digest": digest, "orgId": orgID},
+	)
+	if err != nil {
+		return fmt.Errorf("could not query ArtifactVersion: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[struct{ Exists bool }])
+	if err != nil {
+		return fmt.Errorf("could not query ArtifactVersion: %w", err)
+	} else if !result.Exists {
+		return apierrors.ErrForbidden
+	}
+	return nil
+}
+
 func GetArtifactVersion(ctx context.Context, orgName, name, reference string) (*types.ArtifactVersion, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(
@@ -467,10 +753,17 @@ func GetArtifactVersion(ctx context.Context, orgName, name, reference string) (*
 		FROM Artifact a
 		JOIN Organization o ON o.id = a.organization_id
 		LEFT JOIN ArtifactVersion v ON a.id = v.artifact_id
-		WHERE o.slug = @orgName
-			AND a.name = @name
-			AND v.name = @reference`,
-		pgx.NamedArgs{"orgName": orgName, "name": name, "reference": reference},
+		WHERE o.slug = lower(@orgName)
+			AND`+artifactNameMatchExpr+`
+			AND`+artifactVersionReferenceMatchExpr+`
+		ORDER BY v.id = a.recommended_artifact_version_id DESC NULLS LAST
+		LIMIT 1`,
+		pgx.NamedArgs{
+			"orgName":        orgName,
+			"name":           name,
+			"reference":      reference,
+			"recommendedTag": types.ArtifactRecommendedTag,
+		},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("could not query ArtifactVersion: %w", err)
@@ -485,6 +778,34 @@ func GetArtifactVersion(ctx context.Context, orgName, name, reference string) (*
 	return &result, nil
 }
 
+// GetArtifactVersionsByNames returns the versions of an artifact whose names are in references with a single query.
+// Names that do not exist are omitted and the recommended tag is not resolved.
+func GetArtifactVersionsByNames(
+	ctx context.Context,
+	orgName, name string,
+	references []string,
+) ([]types.ArtifactVersion, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`SELECT`+artifactVersionOutputExpr+`
+		FROM Artifact a
+		JOIN Organization o ON o.id = a.organization_id
+		JOIN ArtifactVersion v ON a.id = v.artifact_id
+		WHERE o.slug = lower(@orgName)
+			AND`+artifactNameMatchExpr+`
+			AND v.name = ANY (@references)`,
+		pgx.NamedArgs{"orgName": orgName, "name": name, "references": references},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ArtifactVersion: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactVersion]); err != nil {
+		return nil, fmt.Errorf("could not collect ArtifactVersion: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
 func CreateArtifactVersion(ctx context.Context, av *types.ArtifactVersion) error {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(
@@ -523,6 +844,68 @@ func CreateArtifactVersion(ctx context.Context, av *types.ArtifactVersion) error
 	}
 }
 
+// UpdateArtifactVersionManifest moves the version av to the manifest it has been given. The parts of the previous
+// manifest are removed and must be created again for the new manifest.
+func UpdateArtifactVersionManifest(ctx context.Context, av *types.ArtifactVersion) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`WITH deleted AS (
+			DELETE FROM ArtifactVersionPart WHERE artifact_version_id = @id
+		)
+		UPDATE ArtifactVersion AS v
+		SET manifest_blob_digest = @manifestBlobDigest,
+			manifest_blob_size = @manifestBlobSize,
+			manifest_content_type = @manifestContentType,
+			updated_at = current_timestamp,
+			updated_by_useraccount_id = @updatedById
+		WHERE v.id = @id
+		RETURNING`+artifactVersionOutputExpr,
+		pgx.NamedArgs{
+			"id":                  av.ID,
+			"manifestBlobDigest":  av.ManifestBlobDigest,
+			"manifestBlobSize":    av.ManifestBlobSize,
+			"manifestContentType": av.ManifestContentType,
+			"updatedById":         av.UpdatedByUserAccountID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update ArtifactVersion: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ArtifactVersion]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return fmt.Errorf("could not update ArtifactVersion: %w", err)
+	} else {
+		*av = result
+		return nil
+	}
+}
+
+// DeleteArtifactVersion removes a tag of an artifact. If reference is a digest, the digest and all tags that point at
+// it are removed. The license assignments and pull log entries of the removed versions are deleted by cascade.
+func DeleteArtifactVersion(ctx context.Context, orgName, name, reference string) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(
+		ctx,
+		`DELETE FROM ArtifactVersion v
+		USING Artifact a
+			JOIN Organization o ON o.id = a.organization_id
+		WHERE v.artifact_id = a.id
+			AND o.slug = lower(@orgName)
+			AND`+artifactNameMatchExpr+`
+			AND (v.name = @reference OR (@reference LIKE '%:%' AND v.manifest_blob_digest = @reference))`,
+		pgx.NamedArgs{"orgName": orgName, "name": name, "reference": reference},
+	)
+	if err != nil {
+		return fmt.Errorf("could not delete ArtifactVersion: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
 func CreateArtifactVersionPart(ctx context.Context, avp *types.ArtifactVersionPart) error {
 	db := internalctx.GetDb(ctx)
 	if rows, err := db.Query(
@@ -551,17 +934,29 @@ func CreateArtifactVersionPart(ctx context.Context, avp *types.ArtifactVersionPa
 	}
 }
 
-func CreateArtifactPullLogEntry(ctx context.Context, versionID, userID uuid.UUID, remoteAddress string) error {
+// CreateArtifactPullLogEntry records a request of the artifact version with the given HTTP method. The userID is nil
+// for anonymous pulls and pulls of service accounts, which are identified by serviceAccountID instead. The
+// tokenSubject identifies the token that was used, see types.TokenSubjectAccessToken.
+func CreateArtifactPullLogEntry(
+	ctx context.Context,
+	versionID uuid.UUID,
+	userID, serviceAccountID *uuid.UUID,
+	remoteAddress, method, tokenSubject string,
+) error {
 	db := internalctx.GetDb(ctx)
-	remoteAddressPtr := &remoteAddress
-	if remoteAddress == "" {
-		remoteAddressPtr = nil
-	}
 	_, err := db.Exec(
 		ctx,
-		`INSERT INTO ArtifactVersionPull (artifact_version_id, useraccount_id, remote_address)
-		VALUES (@versionId, @userId, @remoteAddress)`,
-		pgx.NamedArgs{"versionId": versionID, "userId": userID, "remoteAddress": remoteAddressPtr},
+		`INSERT INTO ArtifactVersionPull
+			(artifact_version_id, useraccount_id, service_account_id, remote_address, method, token_subject)
+		VALUES (@versionId, @userId, @serviceAccountId, NULLIF(@remoteAddress, ''), @method, NULLIF(@tokenSubject, ''))`,
+		pgx.NamedArgs{
+			"versionId":        versionID,
+			"userId":           userID,
+			"serviceAccountId": serviceAccountID,
+			"remoteAddress":    remoteAddress,
+			"method":           method,
+			"tokenSubject":     tokenSubject,
+		},
 	)
 	if err != nil {
 		return fmt.Errorf("could not create artifact pull log entry: %w", err)
@@ -613,22 +1008,27 @@ func GetArtifactVersionPullers(ctx context.Context, versionID uuid.UUID) ([]type
 	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.UserAccount])
 	if err != nil {
 		return nil, fmt.Errorf("could not get pullers: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	}
 	return result, nil
 }
 
+// EnsureArtifactTagLimitForInsert returns true if the organization may create another tag. Organizations that store
+// their blobs in their own bucket are not limited unless they have enabled the quota for their storage.
 func EnsureArtifactTagLimitForInsert(ctx context.Context, orgID uuid.UUID) (bool, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx, `
-		SELECT count(av.name) + 1 < coalesce(
+		SELECT (os.id IS NOT NULL AND NOT os.quota_enabled) OR count(av.name) + 1 < coalesce(
 			o.artifact_tag_limit,
 			CASE WHEN @defaultLimit > 0 THEN @defaultLimit ELSE @maxLimit END
 		)
 		FROM ArtifactVersion av
 		JOIN Artifact a on av.artifact_id = a.id
 		JOIN Organization o ON a.organization_id = o.id
+		LEFT JOIN OrganizationStorage os ON os.organization_id = o.id
 		WHERE o.id = @orgId AND av.name NOT LIKE '%:%'
-		GROUP BY o.id;`,
+		GROUP BY o.id, os.id;`,
 		pgx.NamedArgs{
 			"orgId":        orgID,
 			"defaultLimit": env.ArtifactTagsDefaultLimitPerOrg(),
@@ -649,43 +1049,133 @@ func EnsureArtifactTagLimitForInsert(ctx context.Context, orgID uuid.UUID) (bool
 	}
 }
 
+// ArtifactVersionPullsPageSpec orders artifact pulls from newest to oldest.
+var ArtifactVersionPullsPageSpec = pagination.Spec{
+	Name: "ArtifactVersionPull",
+	Columns: []pagination.Column{
+		{Expr: "p.created_at", Type: "TIMESTAMP", Desc: true},
+		{Expr: "p.id", Type: "UUID", Desc: true},
+	},
+}
+
 func GetArtifactVersionPulls(
 	ctx context.Context,
 	orgID uuid.UUID,
-	count int,
+	page pagination.Page,
 	before time.Time,
-) ([]types.ArtifactVersionPull, error) {
+) ([]types.ArtifactVersionPull, []any, error) {
 	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{
+		"orgId":  orgID,
+		"before": before,
+	}
 	rows, err := db.Query(
 		ctx,
 		`SELECT
+			p.id,
 			p.created_at,
 			p.remote_address,
+			p.method,
+			p.token_subject,
 			CASE WHEN u.id IS NOT NULL THEN (`+userAccountOutputExpr+`) ELSE NULL END,
+			CASE WHEN sa.id IS NOT NULL THEN (`+serviceAccountOutputExpr+`) ELSE NULL END,
 			(`+artifactOutputExpr+`),
 			(`+artifactVersionOutputExpr+`)
 		FROM ArtifactVersionPull p
 			LEFT JOIN UserAccount u ON u.id = p.useraccount_id
+			LEFT JOIN ServiceAccount sa ON sa.id = p.service_account_id
 			JOIN ArtifactVersion v ON v.id = p.artifact_version_id
 			JOIN Artifact A on a.id = v.artifact_id
 		WHERE a.organization_id = @orgId
 			AND p.created_at < @before
-		ORDER BY p.created_at DESC
-		LIMIT @count`,
-		pgx.NamedArgs{
-			"orgId":  orgID,
-			"count":  count,
-			"before": before,
-		},
+			AND `+ArtifactVersionPullsPageSpec.Where(page, args)+`
+		`+ArtifactVersionPullsPageSpec.OrderByLimit(page, args),
+		args,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("could not query ArtifactVersionPulls: %w", err)
+		return nil, nil, fmt.Errorf("could not query ArtifactVersionPulls: %w", err)
 	}
 	result, err := pgx.CollectRows(rows, pgx.RowToStructByPos[types.ArtifactVersionPull])
 	if err != nil {
-		return nil, fmt.Errorf("could not scan ArtifactVersionPulls: %w", err)
+		return nil, nil, fmt.Errorf("could not scan ArtifactVersionPulls: %w", err)
 	}
-	return result, nil
+	result, next := pagination.Trim(page, result, func(p types.ArtifactVersionPull) []any {
+		return []any{p.CreatedAt, p.ID}
+	})
+	if err := decryptUserAccounts(&result); err != nil {
+		return nil, nil, err
+	}
+	return result, next, nil
+}
+
+const artifactDownloadCountOutputExpr = `
+	count(*) FILTER (WHERE p.method != 'HEAD') AS pulls,
+	count(*) FILTER (WHERE p.method = 'HEAD') AS head_requests
+`
+
+// GetArtifactDownloads returns the pulls of all versions of the artifact in the range from (inclusive) to (exclusive),
+// grouped by the pulled tag, by day and by the user and token that pulled it.
+func GetArtifactDownloads(
+	ctx context.Context,
+	artifactID uuid.UUID,
+	from, to time.Time,
+) (*types.ArtifactDownloads, error) {
+	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{"artifactId": artifactID, "from": from, "to": to}
+	fromExpr := `
+		FROM ArtifactVersionPull p
+		JOIN ArtifactVersion v ON v.id = p.artifact_version_id
+		WHERE v.artifact_id = @artifactId AND p.created_at >= @from AND p.created_at < @to
+	`
+	var result types.ArtifactDownloads
+
+	if rows, err := db.Query(ctx,
+		`SELECT v.name AS tag, `+artifactDownloadCountOutputExpr+fromExpr+`
+		GROUP BY v.name
+		ORDER BY pulls DESC, tag`,
+		args,
+	); err != nil {
+		return nil, fmt.Errorf("could not query downloads by tag: %w", err)
+	} else if result.ByTag, err = pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactDownloadsByTag]); err != nil {
+		return nil, fmt.Errorf("could not collect downloads by tag: %w", err)
+	}
+
+	if rows, err := db.Query(ctx,
+		`SELECT date_trunc('day', p.created_at) AS day, `+artifactDownloadCountOutputExpr+fromExpr+`
+		GROUP BY day
+		ORDER BY day`,
+		args,
+	); err != nil {
+		return nil, fmt.Errorf("could not query downloads by day: %w", err)
+	} else if result.ByDay, err = pgx.CollectRows(rows, pgx.RowToStructByName[types.ArtifactDownloadsByDay]); err != nil {
+		return nil, fmt.Errorf("could not collect downloads by day: %w", err)
+	}
+
+	if rows, err := db.Query(ctx,
+		`SELECT
+			CASE WHEN u.id IS NOT NULL THEN (`+userAccountOutputExpr+`) END AS user_account,
+			CASE WHEN sa.id IS NOT NULL THEN (`+serviceAccountOutputExpr+`) END AS service_account,
+			p.token_subject,
+			`+artifactDownloadCountOutputExpr+`
+		FROM ArtifactVersionPull p
+		JOIN ArtifactVersion v ON v.id = p.artifact_version_id
+		LEFT JOIN UserAccount u ON u.id = p.useraccount_id
+		LEFT JOIN ServiceAccount sa ON sa.id = p.service_account_id
+		WHERE v.artifact_id = @artifactId AND p.created_at >= @from AND p.created_at < @to
+		GROUP BY u.id, sa.id, p.token_subject
+		ORDER BY pulls DESC, u.id, sa.id, p.token_subject`,
+		args,
+	); err != nil {
+		return nil, fmt.Errorf("could not query downloads by consumer: %w", err)
+	} else if result.ByConsumer, err = pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.ArtifactDownloadsByConsumer],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect downloads by consumer: %w", err)
+	} else if err := decryptUserAccounts(&result.ByConsumer); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
 }
 
 func UpdateArtifactImage(ctx context.Context, artifact *types.ArtifactWithTaggedVersion, imageID uuid.UUID) error {
@@ -699,3 +1189,81 @@ func UpdateArtifactImage(ctx context.Context, artifact *types.ArtifactWithTagged
 	}
 	return nil
 }
+
+// UpdateArtifactRecommendedVersion sets the recommended version of artifact. A versionID of nil clears it.
+func UpdateArtifactRecommendedVersion(ctx context.Context, artifact *types.Artifact, versionID *uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE Artifact AS a
+		SET recommended_artifact_version_id = @versionId
+		WHERE a.id = @id
+		RETURNING`+artifactOutputExpr,
+		pgx.NamedArgs{"id": artifact.ID, "versionId": versionID},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Artifact: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Artifact]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return fmt.Errorf("could not update Artifact: %w", err)
+	} else {
+		*artifact = result
+		return nil
+	}
+}
+
+// UpdateArtifactPublic sets whether artifact can be pulled from the registry without authentication.
+func UpdateArtifactPublic(ctx context.Context, artifact *types.Artifact, public bool) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE Artifact AS a
+		SET public = @public
+		WHERE a.id = @id
+		RETURNING`+artifactOutputExpr,
+		pgx.NamedArgs{"id": artifact.ID, "public": public},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Artifact: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Artifact]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return fmt.Errorf("could not update Artifact: %w", err)
+	} else {
+		*artifact = result
+		return nil
+	}
+}
+
+// UpdateArtifactTagImmutability sets whether the tags of artifact can be moved to a different manifest.
+func UpdateArtifactTagImmutability(
+	ctx context.Context,
+	artifact *types.Artifact,
+	immutableTags bool,
+	mutableTagPatterns []string,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE Artifact AS a
+		SET immutable_tags = @immutableTags, mutable_tag_patterns = @mutableTagPatterns
+		WHERE a.id = @id
+		RETURNING`+artifactOutputExpr,
+		pgx.NamedArgs{
+			"id":                 artifact.ID,
+			"immutableTags":      immutableTags,
+			"mutableTagPatterns": mutableTagPatterns,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Artifact: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Artifact]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return fmt.Errorf("could not update Artifact: %w", err)
+	} else {
+		*artifact = result
+		return nil
+	}
+}
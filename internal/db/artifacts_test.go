@@ -0,0 +1,349 @@
+package db_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/fieldset"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetArtifactsByOrgIDFields(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+	g.Expect(db.CreateArtifactPullLogEntry(
+		ctx, versions[1].ID, &org.Customers[0].ID, nil, "192.0.2.1", http.MethodGet, "",
+	)).To(Succeed())
+
+	all, err := db.GetArtifactsByOrgID(ctx, org.ID, "", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(HaveLen(1))
+	g.Expect(all[0].DownloadsTotal).To(Equal(1))
+	g.Expect(all[0].DownloadedByUsers).To(ConsistOf(org.Customers[0].ID))
+
+	names, err := db.GetArtifactsByOrgID(ctx, org.ID, "", fieldset.Set{"name": {}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names).To(HaveLen(1))
+	g.Expect(names[0].Name).To(Equal(artifact.Name))
+	g.Expect(names[0].DownloadsTotal).To(BeZero())
+	g.Expect(names[0].DownloadedByUsers).To(BeEmpty())
+}
+
+func TestArtifactNameCaseInsensitive(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact := types.Artifact{Name: "team/Backend-Service", OrganizationID: org.ID}
+	g.Expect(db.CreateArtifact(ctx, &artifact)).To(Succeed())
+	other := types.Artifact{Name: "team/frontend", OrganizationID: org.ID}
+	g.Expect(db.CreateArtifact(ctx, &other)).To(Succeed())
+
+	loaded, err := db.GetArtifactByName(ctx, strings.ToUpper(*org.Slug), "TEAM/backend-service")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.ID).To(Equal(artifact.ID))
+
+	duplicate := types.Artifact{Name: "team/backend-service", OrganizationID: org.ID}
+	g.Expect(db.CreateArtifact(ctx, &duplicate)).To(MatchError(apierrors.ErrConflict))
+	existing, err := db.GetOrCreateArtifact(ctx, org.ID, "team/backend-service")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(existing.ID).To(Equal(artifact.ID))
+
+	names := func(query string) []string {
+		artifacts, err := db.GetArtifactsByOrgID(ctx, org.ID, query, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		var result []string
+		for _, a := range artifacts {
+			result = append(result, a.Name)
+		}
+		return result
+	}
+	g.Expect(names("")).To(Equal([]string{"team/Backend-Service", "team/frontend"}))
+	g.Expect(names("BACKEND")).To(Equal([]string{"team/Backend-Service"}))
+	g.Expect(names("backend-servise")).To(Equal([]string{"team/Backend-Service"}))
+	g.Expect(names("_")).To(BeEmpty())
+	g.Expect(names("database")).To(BeEmpty())
+}
+
+func TestGetArtifactDownloads(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	customer := org.Customers[0]
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0", "latest")
+	subject := types.TokenSubjectAccessToken + "distr-1a2b"
+	for _, pull := range []struct {
+		version              types.ArtifactVersion
+		userID               *uuid.UUID
+		method, tokenSubject string
+	}{
+		{versions[1], &customer.ID, http.MethodHead, subject},
+		{versions[1], &customer.ID, http.MethodGet, subject},
+		{versions[2], &customer.ID, http.MethodGet, subject},
+		{versions[2], nil, http.MethodGet, types.TokenSubjectAnonymous},
+	} {
+		g.Expect(db.CreateArtifactPullLogEntry(
+			ctx, pull.version.ID, pull.userID, nil, "192.0.2.1", pull.method, pull.tokenSubject,
+		)).To(Succeed())
+	}
+	now := time.Now()
+
+	downloads, err := db.GetArtifactDownloads(ctx, artifact.ID, now.Add(-time.Hour), now.Add(time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(downloads.ByTag).To(Equal([]types.ArtifactDownloadsByTag{
+		{Tag: "latest", ArtifactDownloadCount: types.ArtifactDownloadCount{Pulls: 2}},
+		{Tag: "1.0.0", ArtifactDownloadCount: types.ArtifactDownloadCount{Pulls: 1, HeadRequests: 1}},
+	}))
+	g.Expect(downloads.ByDay).To(ConsistOf(
+		HaveField("ArtifactDownloadCount", types.ArtifactDownloadCount{Pulls: 3, HeadRequests: 1}),
+	))
+	g.Expect(downloads.ByConsumer).To(ConsistOf(
+		And(
+			HaveField("UserAccount.ID", customer.ID),
+			HaveField("TokenSubject", &subject),
+			HaveField("ArtifactDownloadCount", types.ArtifactDownloadCount{Pulls: 2, HeadRequests: 1}),
+		),
+		And(
+			HaveField("UserAccount", BeNil()),
+			HaveField("TokenSubject", HaveValue(Equal(types.TokenSubjectAnonymous))),
+			HaveField("ArtifactDownloadCount", types.ArtifactDownloadCount{Pulls: 1}),
+		),
+	))
+
+	downloads, err = db.GetArtifactDownloads(ctx, artifact.ID, now.Add(time.Hour), now.Add(2*time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(downloads.ByTag).To(BeEmpty())
+	g.Expect(downloads.ByDay).To(BeEmpty())
+	g.Expect(downloads.ByConsumer).To(BeEmpty())
+}
+
+func TestArtifactRecommendedVersion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+
+	_, err := db.GetArtifactVersion(ctx, *org.Slug, artifact.Name, types.ArtifactRecommendedTag)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.UpdateArtifactRecommendedVersion(ctx, artifact, &versions[1].ID)).To(Succeed())
+	g.Expect(artifact.RecommendedArtifactVersionID).To(HaveValue(Equal(versions[1].ID)))
+	g.Expect(artifact.RecommendedReference).To(HaveValue(Equal("1.0.0")))
+
+	version, err := db.GetArtifactVersion(ctx, *org.Slug, artifact.Name, types.ArtifactRecommendedTag)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(version.ID).To(Equal(versions[1].ID))
+
+	loaded, err := db.GetArtifactByName(ctx, *org.Slug, artifact.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.RecommendedReference).To(HaveValue(Equal("1.0.0")))
+
+	g.Expect(db.UpdateArtifactRecommendedVersion(ctx, artifact, nil)).To(Succeed())
+	g.Expect(artifact.RecommendedReference).To(BeNil())
+	_, err = db.GetArtifactVersion(ctx, *org.Slug, artifact.Name, types.ArtifactRecommendedTag)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestCheckArtifactForBlob(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+	other, _ := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+	digest := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0123456789abcdef", 4)})
+
+	g.Expect(db.CheckArtifactForBlob(ctx, *org.Slug, artifact.Name, digest)).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.CreateArtifactVersionPart(ctx, &types.ArtifactVersionPart{
+		ArtifactVersionID:  versions[0].ID,
+		ArtifactBlobDigest: digest,
+		ArtifactBlobSize:   42,
+	})).To(Succeed())
+	g.Expect(db.CheckArtifactForBlob(ctx, *org.Slug, artifact.Name, digest)).To(Succeed())
+	g.Expect(db.CheckArtifactForBlob(ctx, *org.Slug, other.Name, digest)).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestArtifactPublic(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	otherOrg := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+	g.Expect(artifact.Public).To(BeFalse())
+	digest := v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("fedcba9876543210", 4)}
+	g.Expect(db.CreateArtifactVersionPart(ctx, &types.ArtifactVersionPart{
+		ArtifactVersionID:  versions[0].ID,
+		ArtifactBlobDigest: types.Digest(digest),
+		ArtifactBlobSize:   42,
+	})).To(Succeed())
+	g.Expect(db.CheckPublicArtifactBlob(ctx, digest.String(), org.ID)).To(MatchError(apierrors.ErrForbidden))
+
+	g.Expect(db.UpdateArtifactPublic(ctx, artifact, true)).To(Succeed())
+	g.Expect(artifact.Public).To(BeTrue())
+	loaded, err := db.GetArtifactByName(ctx, *org.Slug, artifact.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.Public).To(BeTrue())
+	g.Expect(db.CheckPublicArtifactBlob(ctx, digest.String(), org.ID)).To(Succeed())
+	g.Expect(db.CheckPublicArtifactBlob(ctx, digest.String(), otherOrg.ID)).To(MatchError(apierrors.ErrForbidden))
+
+	g.Expect(db.UpdateArtifactPublic(ctx, artifact, false)).To(Succeed())
+	g.Expect(db.CheckPublicArtifactBlob(ctx, digest.String(), org.ID)).To(MatchError(apierrors.ErrForbidden))
+}
+
+func TestArtifactTagImmutability(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, _ := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+	g.Expect(artifact.ImmutableTags).To(BeFalse())
+	g.Expect(artifact.MutableTagPatterns).To(BeEmpty())
+
+	g.Expect(db.UpdateArtifactTagImmutability(ctx, artifact, true, []string{"latest", "*-snapshot"})).To(Succeed())
+	loaded, err := db.GetArtifactByName(ctx, *org.Slug, artifact.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.ImmutableTags).To(BeTrue())
+	g.Expect(loaded.MutableTagPatterns).To(Equal([]string{"latest", "*-snapshot"}))
+	g.Expect(loaded.IsTagImmutable("1.0.0")).To(BeTrue())
+	g.Expect(loaded.IsTagImmutable("latest")).To(BeFalse())
+	g.Expect(loaded.IsTagImmutable("1.1.0-snapshot")).To(BeFalse())
+
+	g.Expect(db.UpdateArtifactTagImmutability(ctx, artifact, false, []string{})).To(Succeed())
+	g.Expect(artifact.IsTagImmutable("1.0.0")).To(BeFalse())
+}
+
+func TestUpdateArtifactVersionManifest(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "latest")
+	blob := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0123456789abcdef", 4)})
+	g.Expect(db.CreateArtifactVersionPart(ctx, &types.ArtifactVersionPart{
+		ArtifactVersionID:  versions[1].ID,
+		ArtifactBlobDigest: blob,
+		ArtifactBlobSize:   42,
+	})).To(Succeed())
+
+	moved := versions[1]
+	moved.ManifestBlobDigest = types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("fedcba9876543210", 4)})
+	moved.ManifestBlobSize = 2048
+	moved.UpdatedByUserAccountID = &org.Vendors[0].ID
+	g.Expect(db.UpdateArtifactVersionManifest(ctx, &moved)).To(Succeed())
+	g.Expect(moved.ID).To(Equal(versions[1].ID))
+	g.Expect(moved.UpdatedAt).NotTo(BeNil())
+
+	loaded, err := db.GetArtifactVersion(ctx, *org.Slug, artifact.Name, "latest")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.ManifestBlobDigest).To(Equal(moved.ManifestBlobDigest))
+	g.Expect(loaded.ManifestBlobSize).To(Equal(int64(2048)))
+	g.Expect(db.CheckArtifactForBlob(ctx, *org.Slug, artifact.Name, blob)).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestArtifactUpstream(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact := types.Artifact{OrganizationID: org.ID, Name: "mirror/app"}
+	g.Expect(db.CreateArtifact(ctx, &artifact)).To(Succeed())
+	_, err := db.GetArtifactUpstreamByName(ctx, *org.Slug, artifact.Name)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	upstream := types.ArtifactUpstream{
+		ArtifactID:             artifact.ID,
+		UpdatedByUserAccountID: &org.Vendors[0].ID,
+		Repository:             "ghcr.io/glasskube/distr",
+		Username:               util.PtrTo("robot"),
+		Password:               util.PtrTo("secret"),
+	}
+	g.Expect(db.UpsertArtifactUpstream(ctx, &upstream)).To(Succeed())
+	loaded, err := db.GetArtifactUpstreamByName(ctx, *org.Slug, artifact.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.Repository).To(Equal("ghcr.io/glasskube/distr"))
+	g.Expect(loaded.Password).To(Equal(util.PtrTo("secret")))
+	g.Expect(db.GetArtifactByName(ctx, *org.Slug, artifact.Name)).
+		To(HaveField("UpstreamRepository", util.PtrTo("ghcr.io/glasskube/distr")))
+
+	upstream.Repository = "docker.io/library/nginx"
+	upstream.Username, upstream.Password = nil, nil
+	g.Expect(db.UpsertArtifactUpstream(ctx, &upstream)).To(Succeed())
+	loaded, err = db.GetArtifactUpstream(ctx, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.Repository).To(Equal("docker.io/library/nginx"))
+	g.Expect(loaded.Password).To(BeNil())
+
+	g.Expect(db.DeleteArtifactUpstream(ctx, artifact.ID)).To(Succeed())
+	g.Expect(db.DeleteArtifactUpstream(ctx, artifact.ID)).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.GetArtifactByName(ctx, *org.Slug, artifact.Name)).To(HaveField("UpstreamRepository", BeNil()))
+}
+
+func TestGetArtifactVersionsByNames(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0", "latest")
+	missing := "sha256:" + strings.Repeat("0", 64)
+
+	result, err := db.GetArtifactVersionsByNames(ctx, *org.Slug, artifact.Name,
+		[]string{versions[0].Name, "latest", missing})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(ConsistOf(HaveField("ID", versions[0].ID), HaveField("ID", versions[2].ID)))
+
+	result, err = db.GetArtifactVersionsByNames(ctx, *org.Slug, "unknown", []string{versions[0].Name})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(BeEmpty())
+}
+
+// BenchmarkGetArtifactsByOrgID compares loading artifacts with download metrics to loading only their names.
+func BenchmarkGetArtifactsByOrgID(b *testing.B) {
+	ctx := testutil.DBContext(b)
+	org := testutil.NewOrganizationWithUsers(ctx, b, 1, 5)
+	for range 20 {
+		_, versions := testutil.NewArtifactWithTags(ctx, b, org.ID, org.Vendors[0].ID, "1.0.0", "latest")
+		for _, customer := range org.Customers {
+			for range 10 {
+				err := db.CreateArtifactPullLogEntry(
+					ctx, versions[1].ID, &customer.ID, nil, "192.0.2.1", http.MethodGet, "")
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+
+	for _, bc := range []struct {
+		name   string
+		fields fieldset.Set
+	}{
+		{"all", nil},
+		{"id,name", fieldset.Set{"id": {}, "name": {}}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var size int
+			for b.Loop() {
+				artifacts, err := db.GetArtifactsByOrgID(ctx, org.ID, "", bc.fields)
+				if err != nil {
+					b.Fatal(err)
+				}
+				result, err := fieldset.Apply(bc.fields, artifacts)
+				if err != nil {
+					b.Fatal(err)
+				}
+				data, err := json.Marshal(result)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/response")
+		})
+	}
+}
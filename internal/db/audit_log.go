@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateAuditLogEntry stores entry. Data is serialized as JSON.
+// If entry has no AccessGrantID and the request is performed under an access grant, the entry is tagged with it.
+func CreateAuditLogEntry(ctx context.Context, entry *types.AuditLogEntry) error {
+	if entry.AccessGrantID == nil {
+		if grant := internalctx.GetAccessGrant(ctx); grant != nil {
+			entry.AccessGrantID = &grant.ID
+		}
+	}
+	db := internalctx.GetDb(ctx)
+	row := db.QueryRow(ctx,
+		`INSERT INTO AuditLogEntry
+			(organization_id, useraccount_id, action, resource_type, resource_id, data, access_grant_id)
+		VALUES (@organizationId, @userAccountId, @action, @resourceType, @resourceId, @data, @accessGrantId)
+		RETURNING id, created_at`,
+		pgx.NamedArgs{
+			"organizationId": entry.OrganizationID,
+			"userAccountId":  entry.UserAccountID,
+			"action":         entry.Action,
+			"resourceType":   entry.ResourceType,
+			"resourceId":     entry.ResourceID,
+			"data":           entry.Data,
+			"accessGrantId":  entry.AccessGrantID,
+		},
+	)
+	if err := row.Scan(&entry.ID, &entry.CreatedAt); err != nil {
+		return fmt.Errorf("could not insert AuditLogEntry: %w", err)
+	}
+	return nil
+}
+
+// GetAuditLogEntriesByUserAccount returns all audit log entries of the organization where the given user is the actor,
+// the oldest first.
+func GetAuditLogEntriesByUserAccount(
+	ctx context.Context,
+	organizationID, userAccountID uuid.UUID,
+) ([]types.AuditLogEntry, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT id, created_at, organization_id, useraccount_id, action, resource_type, resource_id, data,
+			access_grant_id
+		FROM AuditLogEntry
+		WHERE organization_id = @organizationId AND useraccount_id = @userAccountId
+		ORDER BY created_at`,
+		pgx.NamedArgs{"organizationId": organizationID, "userAccountId": userAccountID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query AuditLogEntry: %w", err)
+	} else if result, err := pgx.CollectRows(rows, rowToAuditLogEntry); err != nil {
+		return nil, fmt.Errorf("could not collect AuditLogEntry: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// rowToAuditLogEntry scans the data of the entry as json.RawMessage. Scanning it into any would convert all numbers to
+// float64, which changes integers that are larger than 2^53, like the sizes of large blobs.
+func rowToAuditLogEntry(row pgx.CollectableRow) (types.AuditLogEntry, error) {
+	var entry types.AuditLogEntry
+	var data json.RawMessage
+	err := row.Scan(&entry.ID, &entry.CreatedAt, &entry.OrganizationID, &entry.UserAccountID, &entry.Action,
+		&entry.ResourceType, &entry.ResourceID, &data, &entry.AccessGrantID)
+	if data != nil {
+		entry.Data = data
+	}
+	return entry, err
+}
@@ -0,0 +1,41 @@
+package db_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetAuditLogEntriesByUserAccount(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendor := org.Vendors[0]
+	for _, data := range []any{nil, map[string]any{"size": int64(1<<53 + 1)}} {
+		g.Expect(db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+			OrganizationID: &org.ID,
+			UserAccountID:  util.PtrTo(vendor.ID),
+			Action:         "delete",
+			ResourceType:   "Artifact",
+			ResourceID:     uuid.New(),
+			Data:           data,
+		})).To(Succeed())
+	}
+
+	entries, err := db.GetAuditLogEntriesByUserAccount(ctx, org.ID, vendor.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entries).To(HaveLen(2))
+	// both entries have the same created_at, so their order is not defined
+	g.Expect(entries).To(ContainElement(HaveField("Data", BeNil())))
+	// numbers are not converted to float64, which would round 2^53 + 1
+	g.Expect(entries).To(ContainElement(HaveField("Data", WithTransform(func(data any) (string, error) {
+		result, err := json.Marshal(data)
+		return string(result), err
+	}, Equal(`{"size":9007199254740993}`)))))
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+const blobMetadataOutputExpr = `b.digest, b.created_at, b.size, b.content_type`
+
+const blobReferencedExpr = `(
+	EXISTS (SELECT 1 FROM ArtifactVersion av WHERE av.manifest_blob_digest = b.digest)
+	OR EXISTS (SELECT 1 FROM ArtifactVersionPart avp WHERE avp.artifact_blob_digest = b.digest)
+)`
+
+// SaveBlobMetadata creates or replaces the metadata of a blob. The creation time of a blob that is uploaded again is
+// reset, so that it is not garbage collected before it can be referenced.
+func SaveBlobMetadata(ctx context.Context, metadata *types.BlobMetadata) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO BlobMetadata AS b (digest, size, content_type)
+			VALUES (@digest, @size, @contentType)
+			ON CONFLICT (digest) DO UPDATE
+				SET created_at = EXCLUDED.created_at, size = EXCLUDED.size, content_type = EXCLUDED.content_type
+			RETURNING `+blobMetadataOutputExpr,
+		pgx.NamedArgs{"digest": metadata.Digest, "size": metadata.Size, "contentType": metadata.ContentType},
+	)
+	if err != nil {
+		return fmt.Errorf("could not save BlobMetadata: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.BlobMetadata]); err != nil {
+		return fmt.Errorf("could not save BlobMetadata: %w", err)
+	} else {
+		*metadata = result
+		return nil
+	}
+}
+
+func GetBlobMetadata(ctx context.Context, digest types.Digest) (*types.BlobMetadata, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT `+blobMetadataOutputExpr+` FROM BlobMetadata b WHERE b.digest = @digest`,
+		pgx.NamedArgs{"digest": digest},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query BlobMetadata: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.BlobMetadata]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get BlobMetadata: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+func DeleteBlobMetadata(ctx context.Context, digest types.Digest) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx, `DELETE FROM BlobMetadata WHERE digest = @digest`,
+		pgx.NamedArgs{"digest": digest}); err != nil {
+		return fmt.Errorf("could not delete BlobMetadata: %w", err)
+	}
+	return nil
+}
+
+// LockBlobMetadata locks the metadata of the blobs with the given digests until the end of the current transaction, so
+// that they can not be garbage collected while they become referenced.
+func LockBlobMetadata(ctx context.Context, digests []types.Digest) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`SELECT 1 FROM BlobMetadata WHERE digest = ANY (@digests::TEXT[]) ORDER BY digest FOR KEY SHARE`,
+		pgx.NamedArgs{"digests": digests},
+	); err != nil {
+		return fmt.Errorf("could not lock BlobMetadata: %w", err)
+	}
+	return nil
+}
+
+// GetUnreferencedPlatformBlobs returns up to limit blobs of the platform bucket that were recorded before the given
+// time and are not referenced by any artifact version. Blobs that are stored in the bucket of an organization are not
+// considered, because they share their metadata with the copy in the platform bucket.
+func GetUnreferencedPlatformBlobs(ctx context.Context, before time.Time, limit int) ([]types.BlobMetadata, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT `+blobMetadataOutputExpr+`
+		FROM BlobMetadata b
+		WHERE b.created_at < @before
+			AND NOT EXISTS (SELECT 1 FROM OrganizationBlob ob WHERE ob.digest = b.digest)
+			AND NOT `+blobReferencedExpr+`
+		ORDER BY b.created_at
+		LIMIT @limit`,
+		pgx.NamedArgs{"before": before, "limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query BlobMetadata: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.BlobMetadata]); err != nil {
+		return nil, fmt.Errorf("could not collect BlobMetadata: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// LockUnreferencedPlatformBlob locks the metadata of the blob with the given digest until the end of the current
+// transaction and returns true if the blob can still be garbage collected, i.e. it was recorded before the given time
+// and is neither referenced nor stored in the bucket of an organization.
+//
+// The references are checked after the lock has been acquired, so manifests that were pushed while waiting for it are
+// taken into account.
+func LockUnreferencedPlatformBlob(ctx context.Context, digest types.Digest, before time.Time) (bool, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT 1 FROM BlobMetadata b WHERE b.digest = @digest AND b.created_at < @before FOR UPDATE`,
+		pgx.NamedArgs{"digest": digest, "before": before},
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not lock BlobMetadata: %w", err)
+	} else if locked, err := pgx.CollectRows(rows, pgx.RowTo[int]); err != nil {
+		return false, fmt.Errorf("could not lock BlobMetadata: %w", err)
+	} else if len(locked) == 0 {
+		return false, nil
+	}
+	rows, err = db.Query(ctx,
+		`SELECT NOT `+blobReferencedExpr+`
+			AND NOT EXISTS (SELECT 1 FROM OrganizationBlob ob WHERE ob.digest = b.digest)
+		FROM BlobMetadata b
+		WHERE b.digest = @digest`,
+		pgx.NamedArgs{"digest": digest},
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not query BlobMetadata: %w", err)
+	} else if unreferenced, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[bool]); err != nil {
+		return false, fmt.Errorf("could not query BlobMetadata: %w", err)
+	} else {
+		return unreferenced, nil
+	}
+}
@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetUnreferencedPlatformBlobs(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	_, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID)
+	referenced := versions[0].ManifestBlobDigest
+	unreferenced := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0123456789abcdef", 4)})
+	organization := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("fedcba9876543210", 4)})
+	for _, digest := range []types.Digest{referenced, unreferenced, organization} {
+		g.Expect(db.SaveBlobMetadata(ctx, &types.BlobMetadata{Digest: digest, Size: 1})).To(Succeed())
+	}
+	g.Expect(db.CreateOrganizationBlob(ctx, org.ID, organization)).To(Succeed())
+
+	// blobs within the grace period are kept
+	blobs, err := db.GetUnreferencedPlatformBlobs(ctx, time.Now().Add(-time.Hour), 1000)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(blobs).NotTo(ContainElement(HaveField("Digest", unreferenced)))
+	g.Expect(db.LockUnreferencedPlatformBlob(ctx, unreferenced, time.Now().Add(-time.Hour))).To(BeFalse())
+
+	before := time.Now().Add(time.Hour)
+	blobs, err = db.GetUnreferencedPlatformBlobs(ctx, before, 1000)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(blobs).To(ContainElement(HaveField("Digest", unreferenced)))
+	g.Expect(blobs).NotTo(ContainElement(HaveField("Digest", referenced)))
+	g.Expect(blobs).NotTo(ContainElement(HaveField("Digest", organization)))
+	g.Expect(db.LockUnreferencedPlatformBlob(ctx, unreferenced, before)).To(BeTrue())
+	g.Expect(db.LockUnreferencedPlatformBlob(ctx, referenced, before)).To(BeFalse())
+	g.Expect(db.LockUnreferencedPlatformBlob(ctx, organization, before)).To(BeFalse())
+
+	// a blob that becomes referenced can not be collected anymore
+	g.Expect(db.LockBlobMetadata(ctx, []types.Digest{unreferenced})).To(Succeed())
+	g.Expect(db.CreateArtifactVersionPart(ctx, &types.ArtifactVersionPart{
+		ArtifactVersionID:  versions[0].ID,
+		ArtifactBlobDigest: unreferenced,
+		ArtifactBlobSize:   1,
+	})).To(Succeed())
+	g.Expect(db.LockUnreferencedPlatformBlob(ctx, unreferenced, before)).To(BeFalse())
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+const isOrphanedOrganizationUserAccountExpr = `
+	NOT EXISTS (SELECT FROM UserAccount u WHERE u.id = j.user_account_id)
+	OR NOT EXISTS (SELECT FROM Organization o WHERE o.id = j.organization_id)
+`
+
+// isOrganizationMember returns an expression that is true if the user account userExpr exists and is a member of the
+// organization orgExpr.
+func isOrganizationMember(userExpr, orgExpr string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT FROM Organization_UserAccount j
+		JOIN UserAccount u ON u.id = j.user_account_id
+		WHERE j.user_account_id = %v AND j.organization_id = %v
+	)`, userExpr, orgExpr)
+}
+
+// GetConsistencyIssues returns all organization memberships of user accounts or organizations that do not exist and
+// all licenses and deployment targets that reference a user account that is not a member of their organization.
+func GetConsistencyIssues(ctx context.Context) ([]types.ConsistencyIssue, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT @orphanedOrganizationUserAccount::TEXT AS type, j.organization_id, NULL::UUID AS entity_id, j.user_account_id
+		FROM Organization_UserAccount j
+		WHERE (`+isOrphanedOrganizationUserAccountExpr+`)
+		UNION ALL
+		SELECT @danglingApplicationLicenseOwner::TEXT, al.organization_id, al.id, al.owner_useraccount_id
+		FROM ApplicationLicense al
+		WHERE al.owner_useraccount_id IS NOT NULL
+			AND NOT `+isOrganizationMember("al.owner_useraccount_id", "al.organization_id")+`
+		UNION ALL
+		SELECT @danglingArtifactLicenseOwner::TEXT, al.organization_id, al.id, al.owner_useraccount_id
+		FROM ArtifactLicense al
+		WHERE al.owner_useraccount_id IS NOT NULL
+			AND NOT `+isOrganizationMember("al.owner_useraccount_id", "al.organization_id")+`
+		UNION ALL
+		SELECT @danglingDeploymentTargetCreator::TEXT, dt.organization_id, dt.id, dt.created_by_user_account_id
+		FROM DeploymentTarget dt
+		WHERE NOT `+isOrganizationMember("dt.created_by_user_account_id", "dt.organization_id")+`
+		ORDER BY type, organization_id, entity_id, user_account_id`,
+		pgx.NamedArgs{
+			"orphanedOrganizationUserAccount": types.ConsistencyIssueTypeOrphanedOrganizationUserAccount,
+			"danglingApplicationLicenseOwner": types.ConsistencyIssueTypeDanglingApplicationLicenseOwner,
+			"danglingArtifactLicenseOwner":    types.ConsistencyIssueTypeDanglingArtifactLicenseOwner,
+			"danglingDeploymentTargetCreator": types.ConsistencyIssueTypeDanglingDeploymentTargetCreator,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consistency issues: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ConsistencyIssue])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect consistency issues: %w", err)
+	}
+	return result, nil
+}
+
+// RepairConsistencyIssues deletes orphaned organization memberships and removes owners of licenses that are not a
+// member of the organization of the license, so that the license can be assigned again.
+// Deployment targets are not changed, see types.ConsistencyIssueType.Repairable.
+// It returns the number of changed rows.
+func RepairConsistencyIssues(ctx context.Context) (int64, error) {
+	var count int64
+	err := RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		for _, query := range []string{
+			`DELETE FROM Organization_UserAccount j WHERE ` + isOrphanedOrganizationUserAccountExpr,
+			`UPDATE ApplicationLicense al SET owner_useraccount_id = NULL
+				WHERE al.owner_useraccount_id IS NOT NULL
+					AND NOT ` + isOrganizationMember("al.owner_useraccount_id", "al.organization_id"),
+			`UPDATE ArtifactLicense al SET owner_useraccount_id = NULL
+				WHERE al.owner_useraccount_id IS NOT NULL
+					AND NOT ` + isOrganizationMember("al.owner_useraccount_id", "al.organization_id"),
+		} {
+			if cmd, err := db.Exec(ctx, query); err != nil {
+				return err
+			} else {
+				count += cmd.RowsAffected()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to repair consistency issues: %w", err)
+	}
+	return count, nil
+}
+
+// CreateConsistencyReport saves the report and deletes all previous reports.
+func CreateConsistencyReport(ctx context.Context, report *types.ConsistencyReport) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		rows, err := db.Query(ctx, `
+			INSERT INTO ConsistencyReport AS r (repair, issues)
+			VALUES (@repair, @issues)
+			RETURNING r.id, r.created_at, r.repair, r.issues`,
+			pgx.NamedArgs{"repair": report.Repair, "issues": report.Issues},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert ConsistencyReport: %w", err)
+		}
+		result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ConsistencyReport])
+		if err != nil {
+			return fmt.Errorf("failed to insert ConsistencyReport: %w", err)
+		}
+		_, err = db.Exec(ctx, `DELETE FROM ConsistencyReport WHERE id != @id`, pgx.NamedArgs{"id": result.ID})
+		if err != nil {
+			return fmt.Errorf("failed to delete previous ConsistencyReports: %w", err)
+		}
+		*report = result
+		return nil
+	})
+}
+
+func GetLatestConsistencyReport(ctx context.Context) (*types.ConsistencyReport, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT r.id, r.created_at, r.repair, r.issues
+		FROM ConsistencyReport r
+		ORDER BY r.created_at DESC
+		LIMIT 1`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ConsistencyReport: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ConsistencyReport])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get ConsistencyReport: %w", err)
+	} else {
+		return &result, nil
+	}
+}
@@ -0,0 +1,94 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+// consistencyIssuesOf returns the consistency issues of the organization, ignoring those of other tests.
+func consistencyIssuesOf(ctx context.Context, t testing.TB, orgID uuid.UUID) []types.ConsistencyIssue {
+	t.Helper()
+	issues, err := db.GetConsistencyIssues(ctx)
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	var result []types.ConsistencyIssue
+	for _, issue := range issues {
+		if issue.OrganizationID == orgID {
+			result = append(result, issue)
+		}
+	}
+	return result
+}
+
+func TestConsistencyIssues(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	customer := org.Customers[0]
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	license := types.ArtifactLicenseBase{Name: "test", OrganizationID: org.ID, OwnerUserAccountID: &customer.ID}
+	g.Expect(db.CreateArtifactLicense(ctx, &license)).To(Succeed())
+	g.Expect(consistencyIssuesOf(ctx, t, org.ID)).To(BeEmpty())
+
+	// the customer is removed without the checks of db.DeleteUserAccountFromOrganization
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"DELETE FROM Organization_UserAccount WHERE user_account_id = $1 AND organization_id = $2", customer.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(consistencyIssuesOf(ctx, t, org.ID)).To(ConsistOf(
+		types.ConsistencyIssue{
+			Type:           types.ConsistencyIssueTypeDanglingArtifactLicenseOwner,
+			OrganizationID: org.ID,
+			EntityID:       &license.ID,
+			UserAccountID:  customer.ID,
+		},
+		types.ConsistencyIssue{
+			Type:           types.ConsistencyIssueTypeDanglingDeploymentTargetCreator,
+			OrganizationID: org.ID,
+			EntityID:       &dt.ID,
+			UserAccountID:  customer.ID,
+		},
+	))
+
+	_, err = db.RepairConsistencyIssues(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(consistencyIssuesOf(ctx, t, org.ID)).To(ConsistOf(
+		HaveField("Type", types.ConsistencyIssueTypeDanglingDeploymentTargetCreator),
+	), "deployment targets are not repaired")
+	repaired, err := db.GetArtifactLicenseByID(ctx, license.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(repaired.OwnerUserAccountID).To(BeNil())
+}
+
+func TestConsistencyReport(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	_, err := internalctx.GetDb(ctx).Exec(ctx, "DELETE FROM ConsistencyReport")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = db.GetLatestConsistencyReport(ctx)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	issue := types.ConsistencyIssue{
+		Type:           types.ConsistencyIssueTypeOrphanedOrganizationUserAccount,
+		OrganizationID: uuid.New(),
+		UserAccountID:  uuid.New(),
+		Repaired:       true,
+	}
+	first := types.ConsistencyReport{Issues: []types.ConsistencyIssue{}}
+	g.Expect(db.CreateConsistencyReport(ctx, &first)).To(Succeed())
+	second := types.ConsistencyReport{Repair: true, Issues: []types.ConsistencyIssue{issue}}
+	g.Expect(db.CreateConsistencyReport(ctx, &second)).To(Succeed())
+	g.Expect(second.ID).NotTo(Equal(uuid.Nil))
+
+	latest, err := db.GetLatestConsistencyReport(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(latest.ID).To(Equal(second.ID))
+	g.Expect(latest.Repair).To(BeTrue())
+	g.Expect(latest.Issues).To(Equal([]types.ConsistencyIssue{issue}))
+}
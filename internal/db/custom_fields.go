@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	customFieldDefinitionOutputExpr = `
+		d.id, d.created_at, d.organization_id, d.target, d.key, d.type, d.required, d.enum_values, d.visible_to_customer
+	`
+)
+
+func GetCustomFieldDefinitions(
+	ctx context.Context,
+	orgID uuid.UUID,
+	target types.CustomFieldTarget,
+) ([]types.CustomFieldDefinition, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+customFieldDefinitionOutputExpr+
+			"FROM CustomFieldDefinition d "+
+			"WHERE d.organization_id = @orgId AND d.target = @target "+
+			"ORDER BY d.key",
+		pgx.NamedArgs{"orgId": orgID, "target": target})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CustomFieldDefinitions: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.CustomFieldDefinition])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CustomFieldDefinitions: %w", err)
+	}
+	return result, nil
+}
+
+func GetCustomFieldDefinition(ctx context.Context, id, orgID uuid.UUID) (*types.CustomFieldDefinition, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+customFieldDefinitionOutputExpr+
+			"FROM CustomFieldDefinition d "+
+			"WHERE d.id = @id AND d.organization_id = @orgId",
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CustomFieldDefinition: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.CustomFieldDefinition])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get CustomFieldDefinition: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+func CreateCustomFieldDefinition(ctx context.Context, def *types.CustomFieldDefinition) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO CustomFieldDefinition AS d
+			(organization_id, target, key, type, required, enum_values, visible_to_customer)
+			VALUES (@orgId, @target, @key, @type, @required, @enumValues, @visibleToCustomer)
+			RETURNING`+customFieldDefinitionOutputExpr,
+		pgx.NamedArgs{
+			"orgId":             def.OrganizationID,
+			"target":            def.Target,
+			"key":               def.Key,
+			"type":              def.Type,
+			"required":          def.Required,
+			"enumValues":        nonNilStrings(def.EnumValues),
+			"visibleToCustomer": def.VisibleToCustomer,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert CustomFieldDefinition: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.CustomFieldDefinition]); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrAlreadyExists, err)
+		}
+		return err
+	} else {
+		*def = result
+		return nil
+	}
+}
+
+// UpdateCustomFieldDefinition updates everything except the target and key of a definition.
+// Existing values are never modified.
+func UpdateCustomFieldDefinition(ctx context.Context, def *types.CustomFieldDefinition) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE CustomFieldDefinition AS d SET
+			type = @type,
+			required = @required,
+			enum_values = @enumValues,
+			visible_to_customer = @visibleToCustomer
+			WHERE d.id = @id AND d.organization_id = @orgId
+			RETURNING`+customFieldDefinitionOutputExpr,
+		pgx.NamedArgs{
+			"id":                def.ID,
+			"orgId":             def.OrganizationID,
+			"type":              def.Type,
+			"required":          def.Required,
+			"enumValues":        nonNilStrings(def.EnumValues),
+			"visibleToCustomer": def.VisibleToCustomer,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to update CustomFieldDefinition: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.CustomFieldDefinition])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apierrors.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get updated CustomFieldDefinition: %w", err)
+	} else {
+		*def = result
+		return nil
+	}
+}
+
+// DeleteCustomFieldDefinition deletes a definition.
+// Existing values are kept and show up as violations until they are removed explicitly.
+func DeleteCustomFieldDefinition(ctx context.Context, id, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM CustomFieldDefinition WHERE id = @id AND organization_id = @orgId",
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return fmt.Errorf("failed to delete CustomFieldDefinition: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+// GetCustomFieldValues returns the custom field values of all entities of the given target in the organization,
+// keyed by the entity ID. For customers, this is the user account ID.
+// Only entities whose values contain filter are returned.
+func GetCustomFieldValues(
+	ctx context.Context,
+	orgID uuid.UUID,
+	target types.CustomFieldTarget,
+	filter types.CustomFields,
+) (map[uuid.UUID]types.CustomFields, error) {
+	var query string
+	switch target {
+	case types.CustomFieldTargetDeploymentTarget:
+		query = "SELECT id, custom_fields FROM DeploymentTarget " +
+			"WHERE organization_id = @orgId AND custom_fields @> @filter"
+	case types.CustomFieldTargetCustomer:
+		query = "SELECT user_account_id, custom_fields FROM Organization_UserAccount " +
+			"WHERE organization_id = @orgId AND user_role = 'customer' AND custom_fields @> @filter"
+	default:
+		return nil, fmt.Errorf("invalid custom field target: %v", target)
+	}
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, query, pgx.NamedArgs{"orgId": orgID, "filter": nonNilCustomFields(filter)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query custom field values: %w", err)
+	}
+	result := make(map[uuid.UUID]types.CustomFields)
+	var id uuid.UUID
+	var fields types.CustomFields
+	if _, err := pgx.ForEachRow(rows, []any{&id, &fields}, func() error {
+		result[id] = fields
+		fields = nil
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to get custom field values: %w", err)
+	}
+	return result, nil
+}
+
+func UpdateCustomerCustomFields(ctx context.Context, orgID, userID uuid.UUID, fields types.CustomFields) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"UPDATE Organization_UserAccount SET custom_fields = @customFields "+
+			"WHERE organization_id = @orgId AND user_account_id = @userId",
+		pgx.NamedArgs{"orgId": orgID, "userId": userID, "customFields": nonNilCustomFields(fields)})
+	if err != nil {
+		return fmt.Errorf("failed to update custom fields: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+func nonNilCustomFields(fields types.CustomFields) types.CustomFields {
+	if fields == nil {
+		return types.CustomFields{}
+	}
+	return fields
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const customerDataExportOutputExpr = `
+	e.id,
+	e.created_at,
+	e.organization_id,
+	e.customer_user_account_id,
+	e.requested_by_user_account_id,
+	e.completed_at,
+	e.expires_at,
+	e.size
+`
+
+// CreateCustomerDataExport requests an export of the data of a customer. apierrors.ErrQuotaExceeded is returned if an
+// export of the same customer has already been requested after since.
+func CreateCustomerDataExport(ctx context.Context, export *types.CustomerDataExport, since time.Time) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO CustomerDataExport AS e (organization_id, customer_user_account_id, requested_by_user_account_id)
+		SELECT @organizationId, @customerUserAccountId, @requestedBy
+		WHERE NOT EXISTS (
+			SELECT 1 FROM CustomerDataExport
+			WHERE organization_id = @organizationId
+				AND customer_user_account_id = @customerUserAccountId
+				AND created_at > @since
+		)
+		RETURNING`+customerDataExportOutputExpr,
+		pgx.NamedArgs{
+			"organizationId":        export.OrganizationID,
+			"customerUserAccountId": export.CustomerUserAccountID,
+			"requestedBy":           export.RequestedByUserAccountID,
+			"since":                 since,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert CustomerDataExport: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.CustomerDataExport],
+	); errors.Is(err, pgx.ErrNoRows) {
+		return apierrors.ErrQuotaExceeded
+	} else if err != nil {
+		return fmt.Errorf("could not insert CustomerDataExport: %w", err)
+	} else {
+		*export = result
+		return nil
+	}
+}
+
+// GetCustomerDataExports returns the data exports of a customer, the newest first.
+func GetCustomerDataExports(
+	ctx context.Context,
+	organizationID, customerUserAccountID uuid.UUID,
+) ([]types.CustomerDataExport, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+customerDataExportOutputExpr+`
+		FROM CustomerDataExport e
+		WHERE e.organization_id = @organizationId AND e.customer_user_account_id = @customerUserAccountId
+		ORDER BY e.created_at DESC`,
+		pgx.NamedArgs{"organizationId": organizationID, "customerUserAccountId": customerUserAccountID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query CustomerDataExport: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.CustomerDataExport]); err != nil {
+		return nil, fmt.Errorf("could not collect CustomerDataExport: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetPendingCustomerDataExports returns all data exports that have not been assembled yet, the oldest first.
+func GetPendingCustomerDataExports(ctx context.Context) ([]types.CustomerDataExport, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+customerDataExportOutputExpr+`
+		FROM CustomerDataExport e
+		WHERE e.completed_at IS NULL
+		ORDER BY e.created_at`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query CustomerDataExport: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.CustomerDataExport]); err != nil {
+		return nil, fmt.Errorf("could not collect CustomerDataExport: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// CompleteCustomerDataExport stores the encrypted archive of export and marks it as completed. It returns
+// apierrors.ErrNotFound if export has already been completed.
+func CompleteCustomerDataExport(
+	ctx context.Context,
+	export *types.CustomerDataExport,
+	data []byte,
+	expiresAt time.Time,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE CustomerDataExport AS e
+		SET completed_at = now(), expires_at = @expiresAt, size = octet_length(@data::BYTEA), data = @data
+		WHERE e.id = @id AND e.completed_at IS NULL
+		RETURNING`+customerDataExportOutputExpr,
+		pgx.NamedArgs{"id": export.ID, "data": data, "expiresAt": expiresAt},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update CustomerDataExport: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.CustomerDataExport],
+	); errors.Is(err, pgx.ErrNoRows) {
+		return apierrors.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not update CustomerDataExport: %w", err)
+	} else {
+		*export = result
+		return nil
+	}
+}
+
+// GetCustomerDataExportData returns the encrypted archive of the data export with the given ID. apierrors.ErrNotFound
+// is returned if the export does not exist, has not been completed yet or has expired at now.
+func GetCustomerDataExportData(ctx context.Context, id uuid.UUID, now time.Time) ([]byte, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT e.data FROM CustomerDataExport e WHERE e.id = @id AND e.data IS NOT NULL AND e.expires_at > @now`,
+		pgx.NamedArgs{"id": id, "now": now},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query CustomerDataExport: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[[]byte]); errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get CustomerDataExport: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// DeleteExpiredCustomerDataExportData deletes the archives of all data exports that have expired at now. The exports
+// themselves are kept as a record of the request. It returns the number of deleted archives.
+func DeleteExpiredCustomerDataExportData(ctx context.Context, now time.Time) (int64, error) {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		`UPDATE CustomerDataExport SET data = NULL WHERE data IS NOT NULL AND expires_at <= @now`,
+		pgx.NamedArgs{"now": now},
+	); err != nil {
+		return 0, fmt.Errorf("could not update CustomerDataExport: %w", err)
+	} else {
+		return cmd.RowsAffected(), nil
+	}
+}
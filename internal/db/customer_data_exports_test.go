@@ -0,0 +1,59 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestCustomerDataExport(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	export := types.CustomerDataExport{
+		OrganizationID:           org.ID,
+		CustomerUserAccountID:    org.Customers[0].ID,
+		RequestedByUserAccountID: &org.Vendors[0].ID,
+	}
+	g.Expect(db.CreateCustomerDataExport(ctx, &export, time.Now().Add(-24*time.Hour))).To(Succeed())
+	g.Expect(export.ID).NotTo(BeZero())
+	g.Expect(export.CompletedAt).To(BeNil())
+
+	// only one export can be requested per interval
+	second := export
+	g.Expect(db.CreateCustomerDataExport(ctx, &second, time.Now().Add(-24*time.Hour))).
+		To(MatchError(apierrors.ErrQuotaExceeded))
+
+	pending, err := db.GetPendingCustomerDataExports(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(ContainElement(HaveField("ID", export.ID)))
+	_, err = db.GetCustomerDataExportData(ctx, export.ID, time.Now())
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	expiresAt := time.Now().Add(time.Hour)
+	g.Expect(db.CompleteCustomerDataExport(ctx, &export, []byte("data"), expiresAt)).To(Succeed())
+	g.Expect(export.CompletedAt).NotTo(BeNil())
+	g.Expect(export.Size).To(HaveValue(BeEquivalentTo(4)))
+	g.Expect(db.CompleteCustomerDataExport(ctx, &export, []byte("data"), expiresAt)).
+		To(MatchError(apierrors.ErrNotFound))
+
+	exports, err := db.GetCustomerDataExports(ctx, org.ID, org.Customers[0].ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(exports).To(HaveExactElements(HaveField("ID", export.ID)))
+
+	data, err := db.GetCustomerDataExportData(ctx, export.ID, time.Now())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("data"))
+	_, err = db.GetCustomerDataExportData(ctx, export.ID, expiresAt)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.DeleteExpiredCustomerDataExportData(ctx, time.Now())).To(BeZero())
+	g.Expect(db.DeleteExpiredCustomerDataExportData(ctx, expiresAt)).To(BeEquivalentTo(1))
+	_, err = db.GetCustomerDataExportData(ctx, export.ID, time.Now())
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
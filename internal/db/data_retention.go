@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func GetDataRetentionPolicies(ctx context.Context, orgID uuid.UUID) ([]types.DataRetentionPolicy, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT organization_id, category, retention_days, updated_at
+		FROM DataRetentionPolicy
+		WHERE organization_id = @organizationId
+		ORDER BY category`,
+		pgx.NamedArgs{"organizationId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("could not query DataRetentionPolicy: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DataRetentionPolicy]); err != nil {
+		return nil, fmt.Errorf("could not collect DataRetentionPolicy: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// PutDataRetentionPolicies replaces the retention policies of an organization. Categories without a policy are kept
+// indefinitely.
+func PutDataRetentionPolicies(ctx context.Context, orgID uuid.UUID, policies []types.DataRetentionPolicy) error {
+	db := internalctx.GetDb(ctx)
+	categories := make([]types.DataRetentionCategory, len(policies))
+	days := make([]int, len(policies))
+	for i, p := range policies {
+		categories[i] = p.Category
+		days[i] = p.RetentionDays
+	}
+	if _, err := db.Exec(ctx,
+		`DELETE FROM DataRetentionPolicy
+		WHERE organization_id = @organizationId AND NOT category::TEXT = ANY(@categories)`,
+		pgx.NamedArgs{"organizationId": orgID, "categories": categories},
+	); err != nil {
+		return fmt.Errorf("could not delete DataRetentionPolicy: %w", err)
+	}
+	if _, err := db.Exec(ctx,
+		`INSERT INTO DataRetentionPolicy (organization_id, category, retention_days)
+		SELECT @organizationId, p.category::DATA_RETENTION_CATEGORY, p.retention_days
+		FROM unnest(@categories::TEXT[], @days::INT[]) AS p(category, retention_days)
+		ON CONFLICT (organization_id, category) DO UPDATE SET
+			retention_days = EXCLUDED.retention_days,
+			updated_at = CASE WHEN DataRetentionPolicy.retention_days = EXCLUDED.retention_days
+				THEN DataRetentionPolicy.updated_at ELSE current_timestamp END`,
+		pgx.NamedArgs{"organizationId": orgID, "categories": categories, "days": days},
+	); err != nil {
+		return fmt.Errorf("could not insert DataRetentionPolicy: %w", err)
+	}
+	return nil
+}
+
+// expiredDataQueries select the ID of every row of a category that is older than the retention policy of its
+// organization, and whether it is under legal hold.
+var expiredDataQueries = map[types.DataRetentionCategory]struct{ table, query string }{
+	types.DataRetentionCategoryAdminAudit: {
+		table: "AuditLogEntry",
+		query: `SELECT x.id, ` + legalHoldExpr("x.organization_id", "x.useraccount_id") + ` AS held
+			FROM AuditLogEntry x
+			JOIN DataRetentionPolicy p ON p.organization_id = x.organization_id AND p.category = 'admin_audit'
+			WHERE x.created_at < current_timestamp - p.retention_days * INTERVAL '1 day'`,
+	},
+	types.DataRetentionCategoryArtifactAudit: {
+		table: "ArtifactVersionPull",
+		query: `SELECT x.id, ` + legalHoldExpr("a.organization_id", "x.useraccount_id") + ` AS held
+			FROM ArtifactVersionPull x
+			JOIN ArtifactVersion av ON x.artifact_version_id = av.id
+			JOIN Artifact a ON av.artifact_id = a.id
+			JOIN DataRetentionPolicy p ON p.organization_id = a.organization_id AND p.category = 'artifact_audit'
+			WHERE x.created_at < current_timestamp - p.retention_days * INTERVAL '1 day'`,
+	},
+	types.DataRetentionCategoryDeploymentLogs: {
+		table: "DeploymentLogRecord",
+		query: `SELECT x.id, ` + legalHoldExpr("dt.organization_id", "dt.created_by_user_account_id") + ` AS held
+			FROM DeploymentLogRecord x
+			JOIN Deployment d ON x.deployment_id = d.id
+			JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+			JOIN DataRetentionPolicy p ON p.organization_id = dt.organization_id AND p.category = 'deployment_logs'
+			WHERE x.created_at < current_timestamp - p.retention_days * INTERVAL '1 day'`,
+	},
+	// Security events belong to a user, who can be a member of multiple organizations. They are only deleted when
+	// they have expired for every organization of the user.
+	types.DataRetentionCategorySecurityEvents: {
+		table: "SecurityEvent",
+		query: `SELECT x.id, EXISTS (
+				SELECT 1 FROM Organization_UserAccount oua
+				WHERE oua.user_account_id = x.useraccount_id
+					AND ` + legalHoldExpr("oua.organization_id", "x.useraccount_id") + `
+			) AS held
+			FROM SecurityEvent x
+			WHERE EXISTS (SELECT 1 FROM Organization_UserAccount oua WHERE oua.user_account_id = x.useraccount_id)
+				AND NOT EXISTS (
+					SELECT 1 FROM Organization_UserAccount oua
+					LEFT JOIN DataRetentionPolicy p
+						ON p.organization_id = oua.organization_id AND p.category = 'security_events'
+					WHERE oua.user_account_id = x.useraccount_id
+						AND (p.retention_days IS NULL
+							OR x.created_at >= current_timestamp - p.retention_days * INTERVAL '1 day')
+				)`,
+	},
+}
+
+// CleanupExpiredData deletes the data of a category that is older than the retention policy of its organization.
+// Data under legal hold is kept and counted as held instead.
+func CleanupExpiredData(ctx context.Context, category types.DataRetentionCategory) (deleted, held int64, err error) {
+	q, ok := expiredDataQueries[category]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown data retention category: %v", category)
+	}
+	db := internalctx.GetDb(ctx)
+	err = db.QueryRow(ctx,
+		`WITH expired AS (`+q.query+`),
+		deleted AS (
+			DELETE FROM `+q.table+` WHERE id IN (SELECT id FROM expired WHERE NOT held) RETURNING 1
+		)
+		SELECT (SELECT count(*) FROM deleted), (SELECT count(*) FROM expired WHERE held)`,
+	).Scan(&deleted, &held)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not clean up %v: %w", q.table, err)
+	}
+	return deleted, held, nil
+}
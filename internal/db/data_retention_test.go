@@ -0,0 +1,92 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	. "github.com/onsi/gomega"
+)
+
+func createAuditLogEntryDaysAgo(ctx context.Context, t *testing.T, orgID uuid.UUID, userID *uuid.UUID, days int) {
+	t.Helper()
+	entry := types.AuditLogEntry{
+		OrganizationID: &orgID,
+		UserAccountID:  userID,
+		Action:         "update",
+		ResourceType:   "Test",
+		ResourceID:     uuid.New(),
+	}
+	if err := db.CreateAuditLogEntry(ctx, &entry); err != nil {
+		t.Fatal(err)
+	} else if _, err := internalctx.GetDb(ctx).Exec(ctx,
+		`UPDATE AuditLogEntry SET created_at = created_at - @days * INTERVAL '1 day' WHERE id = @id`,
+		pgx.NamedArgs{"id": entry.ID, "days": days},
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCleanupExpiredData(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 2)
+	withoutPolicy := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	held, notHeld := org.Customers[0].ID, org.Customers[1].ID
+
+	g.Expect(db.PutDataRetentionPolicies(ctx, org.ID, []types.DataRetentionPolicy{
+		{Category: types.DataRetentionCategoryAdminAudit, RetentionDays: 30},
+	})).To(Succeed())
+	policies, err := db.GetDataRetentionPolicies(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(policies).To(ConsistOf(HaveField("RetentionDays", 30)))
+
+	createAuditLogEntryDaysAgo(ctx, t, org.ID, &held, 31)
+	createAuditLogEntryDaysAgo(ctx, t, org.ID, &notHeld, 31)
+	createAuditLogEntryDaysAgo(ctx, t, org.ID, &notHeld, 29)
+	createAuditLogEntryDaysAgo(ctx, t, withoutPolicy.ID, nil, 31)
+
+	hold := types.LegalHold{OrganizationID: org.ID, UserAccountID: &held, Reason: "case 42"}
+	g.Expect(db.CreateLegalHold(ctx, &hold)).To(Succeed())
+	g.Expect(db.CreateLegalHold(ctx, &types.LegalHold{OrganizationID: org.ID, UserAccountID: &held, Reason: "again"})).
+		To(MatchError(apierrors.ErrConflict))
+	holds, err := db.GetLegalHolds(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(holds.Covers(org.ID, &held)).To(BeTrue())
+	g.Expect(holds.Covers(org.ID, &notHeld)).To(BeFalse())
+	g.Expect(holds.CoversAnyOf(org.ID)).To(BeTrue())
+
+	deleted, heldCount, err := db.CleanupExpiredData(ctx, types.DataRetentionCategoryAdminAudit)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(Equal(int64(1)))
+	g.Expect(heldCount).To(Equal(int64(1)))
+
+	// an organization wide hold also covers entries of vendors
+	createAuditLogEntryDaysAgo(ctx, t, org.ID, util.PtrTo(org.Vendors[0].ID), 31)
+	g.Expect(db.CreateLegalHold(ctx, &types.LegalHold{OrganizationID: org.ID, Reason: "case 43"})).To(Succeed())
+	deleted, heldCount, err = db.CleanupExpiredData(ctx, types.DataRetentionCategoryAdminAudit)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeZero())
+	g.Expect(heldCount).To(Equal(int64(2)))
+
+	_, err = db.DeleteLegalHold(ctx, org.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = db.DeleteLegalHold(ctx, org.ID, nil)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	deleted, heldCount, err = db.CleanupExpiredData(ctx, types.DataRetentionCategoryAdminAudit)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(Equal(int64(1)))
+	g.Expect(heldCount).To(Equal(int64(1)))
+
+	for _, category := range types.DataRetentionCategories {
+		_, _, err := db.CleanupExpiredData(ctx, category)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+}
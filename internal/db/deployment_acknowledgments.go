@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	pendingDeploymentAcknowledgmentOutputExpr = `
+		dr.id AS deployment_revision_id,
+		dr.created_at,
+		dr.deployment_id,
+		dt.id AS deployment_target_id,
+		dt.name AS deployment_target_name,
+		dt.organization_id,
+		dt.created_by_user_account_id AS customer_user_account_id,
+		u.email AS customer_email,
+		a.name AS application_name,
+		av.name AS application_version_name,
+		av.acknowledgment_message,
+		dr.acknowledgment_reminded_at
+	`
+	// pendingDeploymentAcknowledgmentFromExpr selects revisions that are pending acknowledgment and have not been
+	// superseded by a later revision of the same deployment.
+	pendingDeploymentAcknowledgmentFromExpr = `
+		FROM DeploymentRevision dr
+			JOIN Deployment d ON dr.deployment_id = d.id
+			JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+			LEFT JOIN UserAccount u ON dt.created_by_user_account_id = u.id
+			JOIN ApplicationVersion av ON dr.application_version_id = av.id
+			JOIN Application a ON av.application_id = a.id
+		WHERE dr.acknowledgment_required
+			AND dr.acknowledged_at IS NULL
+			AND d.archived_at IS NULL
+			AND d.uninstall_requested_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM DeploymentRevision later
+				WHERE later.deployment_id = dr.deployment_id AND later.created_at > dr.created_at
+			)
+	`
+)
+
+// GetPendingDeploymentAcknowledgments returns all outstanding acknowledgments of an organization, oldest first. If
+// customerUserAccountID is not nil, only acknowledgments for deployment targets of this customer are returned.
+func GetPendingDeploymentAcknowledgments(
+	ctx context.Context,
+	orgID uuid.UUID,
+	customerUserAccountID *uuid.UUID,
+) ([]types.PendingDeploymentAcknowledgment, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+pendingDeploymentAcknowledgmentOutputExpr+pendingDeploymentAcknowledgmentFromExpr+`
+			AND dt.organization_id = @orgId
+			AND (@customerId::UUID IS NULL OR dt.created_by_user_account_id = @customerId)
+		ORDER BY dr.created_at`,
+		pgx.NamedArgs{"orgId": orgID, "customerId": customerUserAccountID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query pending DeploymentRevision acknowledgments: %w", err)
+	} else if result, err := pgx.CollectRows(rows,
+		pgx.RowToStructByName[types.PendingDeploymentAcknowledgment]); err != nil {
+		return nil, fmt.Errorf("could not collect pending DeploymentRevision acknowledgments: %w", err)
+	} else if err := decryptCustomerEmails(result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// GetDueDeploymentAcknowledgmentReminders returns at most limit outstanding acknowledgments of all organizations for
+// which neither the revision was created nor a reminder was sent after remindBefore.
+func GetDueDeploymentAcknowledgmentReminders(
+	ctx context.Context,
+	remindBefore time.Time,
+	limit int,
+) ([]types.PendingDeploymentAcknowledgment, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+pendingDeploymentAcknowledgmentOutputExpr+pendingDeploymentAcknowledgmentFromExpr+`
+			AND coalesce(dr.acknowledgment_reminded_at, dr.created_at) < @remindBefore
+		ORDER BY coalesce(dr.acknowledgment_reminded_at, dr.created_at)
+		LIMIT @limit`,
+		pgx.NamedArgs{"remindBefore": remindBefore, "limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query due DeploymentRevision acknowledgments: %w", err)
+	} else if result, err := pgx.CollectRows(rows,
+		pgx.RowToStructByName[types.PendingDeploymentAcknowledgment]); err != nil {
+		return nil, fmt.Errorf("could not collect due DeploymentRevision acknowledgments: %w", err)
+	} else if err := decryptCustomerEmails(result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func UpdateDeploymentRevisionAcknowledgmentRemindedAt(ctx context.Context, id uuid.UUID, remindedAt time.Time) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE DeploymentRevision SET acknowledgment_reminded_at = @remindedAt WHERE id = @id`,
+		pgx.NamedArgs{"id": id, "remindedAt": remindedAt},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentRevision: %w", err)
+	}
+	return nil
+}
+
+// AcknowledgeDeploymentRevision releases a revision that is pending acknowledgment, so that it is sent to the agent.
+// If overridden is true, the revision was released by a vendor user without the acknowledgment of the customer.
+// apierrors.ErrConflict is returned if the revision is not pending acknowledgment or has been superseded by a later
+// revision.
+func AcknowledgeDeploymentRevision(
+	ctx context.Context,
+	revision *types.DeploymentRevisionWithVersion,
+	userID uuid.UUID,
+	overridden bool,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE DeploymentRevision AS dr
+		SET acknowledged_at = now(), acknowledged_by_user_account_id = @userId, acknowledgment_overridden = @overridden
+		WHERE dr.id = @id
+			AND dr.acknowledgment_required
+			AND dr.acknowledged_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM DeploymentRevision later
+				WHERE later.deployment_id = dr.deployment_id AND later.created_at > dr.created_at
+			)
+		RETURNING`+deploymentRevisionOutputExpr,
+		pgx.NamedArgs{"id": revision.ID, "userId": userID, "overridden": overridden},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update DeploymentRevision: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentRevision]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrConflict
+		}
+		return fmt.Errorf("could not update DeploymentRevision: %w", err)
+	} else {
+		revision.DeploymentRevision = result
+		return nil
+	}
+}
+
+// GetPendingDeploymentAcknowledgment returns the outstanding acknowledgment of a revision or apierrors.ErrNotFound if
+// the revision is not pending acknowledgment.
+func GetPendingDeploymentAcknowledgment(
+	ctx context.Context,
+	revisionID uuid.UUID,
+) (*types.PendingDeploymentAcknowledgment, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+pendingDeploymentAcknowledgmentOutputExpr+pendingDeploymentAcknowledgmentFromExpr+`
+			AND dr.id = @id`,
+		pgx.NamedArgs{"id": revisionID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query pending DeploymentRevision acknowledgment: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows,
+		pgx.RowToAddrOfStructByName[types.PendingDeploymentAcknowledgment]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get pending DeploymentRevision acknowledgment: %w", err)
+	} else if err := decryptPII(result.CustomerEmail); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func decryptCustomerEmails(acknowledgments []types.PendingDeploymentAcknowledgment) error {
+	for _, ack := range acknowledgments {
+		if err := decryptPII(ack.CustomerEmail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	. "github.com/onsi/gomega"
+)
+
+// backdateDeploymentRevision moves a revision into the past, because all revisions that are created in the same test
+// transaction have the same creation time.
+func backdateDeploymentRevision(ctx context.Context, t *testing.T, id uuid.UUID) {
+	t.Helper()
+	if _, err := internalctx.GetDb(ctx).Exec(ctx,
+		`UPDATE DeploymentRevision SET created_at = created_at - INTERVAL '1 hour' WHERE id = @id`,
+		pgx.NamedArgs{"id": id},
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeploymentAcknowledgment(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	vendor, customer := org.Vendors[0], org.Customers[0]
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	released := testutil.NewDeploymentRevision(ctx, t, target)
+	g.Expect(released.AcknowledgmentRequired).To(BeFalse())
+	backdateDeploymentRevision(ctx, t, released.ID)
+
+	v1, err := db.GetApplicationVersion(ctx, released.ApplicationVersionID)
+	g.Expect(err).NotTo(HaveOccurred())
+	v2 := types.ApplicationVersion{
+		Name:                  "2.0.0",
+		ApplicationID:         v1.ApplicationID,
+		ComposeFileData:       v1.ComposeFileData,
+		AcknowledgmentMessage: util.PtrTo("requires downtime"),
+	}
+	g.Expect(db.CreateApplicationVersion(ctx, &v2)).To(Succeed())
+
+	pending, err := db.CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+		DeploymentID:         &released.DeploymentID,
+		DeploymentTargetID:   target.ID,
+		ApplicationVersionID: v2.ID,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending.IsAcknowledgmentPending()).To(BeTrue())
+
+	// the agent keeps the released revision
+	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, target.ID, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(ConsistOf(HaveField("DeploymentRevisionID", released.ID)))
+
+	all, err := db.GetPendingDeploymentAcknowledgments(ctx, org.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(ConsistOf(And(
+		HaveField("DeploymentRevisionID", pending.ID),
+		HaveField("CustomerEmail", HaveValue(Equal(customer.Email))),
+		HaveField("AcknowledgmentMessage", "requires downtime"),
+	)))
+	own, err := db.GetPendingDeploymentAcknowledgments(ctx, org.ID, &customer.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(own).To(HaveLen(1))
+	other, err := db.GetPendingDeploymentAcknowledgments(ctx, org.ID, &vendor.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(other).To(BeEmpty())
+
+	due, err := db.GetDueDeploymentAcknowledgmentReminders(ctx, time.Now().Add(time.Hour), 100)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).To(ContainElement(HaveField("DeploymentRevisionID", pending.ID)))
+	g.Expect(db.UpdateDeploymentRevisionAcknowledgmentRemindedAt(ctx, pending.ID, time.Now().Add(2*time.Hour))).
+		To(Succeed())
+	due, err = db.GetDueDeploymentAcknowledgmentReminders(ctx, time.Now().Add(time.Hour), 100)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).NotTo(ContainElement(HaveField("DeploymentRevisionID", pending.ID)))
+
+	withVersion, err := db.GetDeploymentRevision(ctx, pending.ID, pending.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.AcknowledgeDeploymentRevision(ctx, withVersion, customer.ID, false)).To(Succeed())
+	g.Expect(withVersion.AcknowledgedAt).NotTo(BeNil())
+	g.Expect(withVersion.AcknowledgedByUserAccountID).To(HaveValue(Equal(customer.ID)))
+	g.Expect(db.AcknowledgeDeploymentRevision(ctx, withVersion, vendor.ID, true)).
+		To(MatchError(apierrors.ErrConflict))
+
+	deployments, err = db.GetDeploymentsForDeploymentTarget(ctx, target.ID, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(ConsistOf(HaveField("DeploymentRevisionID", pending.ID)))
+	all, err = db.GetPendingDeploymentAcknowledgments(ctx, org.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(BeEmpty())
+
+	// redeploying the released version with other values does not require acknowledgment again
+	again, err := db.CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+		DeploymentID:         &released.DeploymentID,
+		DeploymentTargetID:   target.ID,
+		ApplicationVersionID: v2.ID,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again.AcknowledgmentRequired).To(BeFalse())
+}
+
+func TestDeploymentAcknowledgmentVendorTarget(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	v1, err := db.GetApplicationVersion(ctx, revision.ApplicationVersionID)
+	g.Expect(err).NotTo(HaveOccurred())
+	v2 := types.ApplicationVersion{
+		Name:                  "2.0.0",
+		ApplicationID:         v1.ApplicationID,
+		ComposeFileData:       v1.ComposeFileData,
+		AcknowledgmentMessage: util.PtrTo("requires downtime"),
+	}
+	g.Expect(db.CreateApplicationVersion(ctx, &v2)).To(Succeed())
+
+	// deployment targets of the vendor never require acknowledgment
+	next, err := db.CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+		DeploymentID:         &revision.DeploymentID,
+		DeploymentTargetID:   target.ID,
+		ApplicationVersionID: v2.ID,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next.AcknowledgmentRequired).To(BeFalse())
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// GetDueDeploymentAutoRollbacks returns at most limit deployments whose released revision must be rolled back
+// automatically, longest failing first. The rollback restores the latest earlier revision that has been healthy and
+// has not been rolled back itself.
+//
+// A revision is due if its latest status is an error and it has either never been healthy, which means that the
+// update itself failed, or it has reported errors for longer than the rollback window of the deployment. The window
+// falls back to the window of the organization and then to defaultWindow.
+//
+// To prevent rollback loops, revisions that have been created by an automatic rollback are never rolled back
+// automatically, and neither are revisions that have already been rolled back or that have been superseded by a
+// later revision, even if that one is still pending acknowledgment. Automatic rollbacks resume once a user creates a
+// new revision.
+func GetDueDeploymentAutoRollbacks(
+	ctx context.Context,
+	now time.Time,
+	defaultWindow time.Duration,
+	limit int,
+) ([]types.DeploymentAutoRollback, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT
+			dt.organization_id,
+			d.id AS deployment_id,
+			dt.id AS deployment_target_id,
+			dt.name AS deployment_target_name,
+			CASE WHEN j.user_role = 'customer' THEN u.email END AS customer_email,
+			a.name AS application_name,
+			failed.id AS failed_revision_id,
+			failed_av.name AS failed_application_version_name,
+			status.message AS failure_message,
+			status.failed_since,
+			healthy.id AS rollback_revision_id,
+			healthy.application_version_id AS rollback_application_version_id,
+			healthy_av.name AS rollback_application_version_name,
+			healthy.values_yaml AS rollback_values_yaml,
+			healthy.env_file_data AS rollback_env_file_data
+		FROM Deployment d
+			JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+			JOIN Organization o ON dt.organization_id = o.id
+			LEFT JOIN UserAccount u ON dt.created_by_user_account_id = u.id
+			LEFT JOIN Organization_UserAccount j
+				ON dt.created_by_user_account_id = j.user_account_id AND dt.organization_id = j.organization_id
+			CROSS JOIN LATERAL (
+				SELECT * FROM DeploymentRevision dr
+				WHERE dr.deployment_id = d.id AND `+deploymentRevisionReleasedExpr+`
+				ORDER BY dr.created_at DESC
+				LIMIT 1
+			) failed
+			JOIN ApplicationVersion failed_av ON failed.application_version_id = failed_av.id
+			JOIN Application a ON failed_av.application_id = a.id
+			CROSS JOIN LATERAL (
+				SELECT drs.type, drs.message, (
+					-- the start of the current run of errors
+					SELECT min(e.created_at) FROM DeploymentRevisionStatus e
+					WHERE e.deployment_revision_id = failed.id AND e.created_at > coalesce((
+						SELECT max(n.created_at) FROM DeploymentRevisionStatus n
+						WHERE n.deployment_revision_id = failed.id AND n.type <> 'error'
+					), '-infinity')
+				) AS failed_since
+				FROM DeploymentRevisionStatus drs
+				WHERE drs.deployment_revision_id = failed.id
+				ORDER BY drs.created_at DESC
+				LIMIT 1
+			) status
+			CROSS JOIN LATERAL (
+				SELECT * FROM DeploymentRevision dr
+				WHERE dr.deployment_id = d.id
+					AND dr.created_at < failed.created_at
+					AND dr.healthy_at IS NOT NULL
+					AND `+deploymentRevisionReleasedExpr+`
+					AND NOT EXISTS (SELECT 1 FROM DeploymentRevision r WHERE r.automatic_rollback_of_revision_id = dr.id)
+				ORDER BY dr.created_at DESC
+				LIMIT 1
+			) healthy
+			JOIN ApplicationVersion healthy_av ON healthy.application_version_id = healthy_av.id
+		WHERE d.archived_at IS NULL
+			AND d.uninstall_requested_at IS NULL
+			AND dt.archived_at IS NULL
+			AND d.paused_at IS NULL
+			AND dt.paused_at IS NULL
+			AND coalesce(d.auto_rollback_enabled, o.deployment_auto_rollback_window_seconds IS NOT NULL)
+			AND status.type = 'error'
+			AND (failed.healthy_at IS NULL OR status.failed_since <= @now::TIMESTAMP - make_interval(secs => coalesce(
+				d.auto_rollback_window_seconds, o.deployment_auto_rollback_window_seconds, @defaultWindowSeconds
+			)))
+			AND failed.automatic_rollback_of_revision_id IS NULL
+			AND NOT EXISTS (SELECT 1 FROM DeploymentRevision r WHERE r.automatic_rollback_of_revision_id = failed.id)
+			AND NOT EXISTS (
+				SELECT 1 FROM DeploymentRevision later
+				WHERE later.deployment_id = d.id AND later.created_at > failed.created_at
+			)
+		ORDER BY status.failed_since
+		LIMIT @limit`,
+		pgx.NamedArgs{"now": now, "defaultWindowSeconds": int(defaultWindow.Seconds()), "limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query due automatic rollbacks: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentAutoRollback])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect due automatic rollbacks: %w", err)
+	}
+	for i := range result {
+		if err := decryptPII(result[i].CustomerEmail); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// CreateDeploymentAutoRollbackRevision creates a revision that restores the application version, values and
+// environment of the healthy revision of rollback, like a user would do to roll back manually. It must be called in a
+// transaction. apierrors.ErrConflict is returned if the failed revision has already been rolled back.
+func CreateDeploymentAutoRollbackRevision(
+	ctx context.Context,
+	rollback types.DeploymentAutoRollback,
+) (*types.DeploymentRevision, error) {
+	reason := fmt.Sprintf("Automatic rollback of %v: %v",
+		rollback.FailedApplicationVersionName, rollback.FailureMessage)
+	if runes := []rune(reason); len(runes) > api.DeploymentReasonMaxLength {
+		reason = string(runes[:api.DeploymentReasonMaxLength-1]) + "…"
+	}
+	revision, err := CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+		DeploymentID:         &rollback.DeploymentID,
+		DeploymentTargetID:   rollback.DeploymentTargetID,
+		ApplicationVersionID: rollback.RollbackApplicationVersionID,
+		ValuesYaml:           rollback.RollbackValuesYaml,
+		EnvFileData:          rollback.RollbackEnvFileData,
+		Reason:               &reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE DeploymentRevision SET automatic_rollback_of_revision_id = @failedRevisionId WHERE id = @id`,
+		pgx.NamedArgs{"id": revision.ID, "failedRevisionId": rollback.FailedRevisionID},
+	); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = fmt.Errorf("%w: revision has already been rolled back", apierrors.ErrConflict)
+		}
+		return nil, fmt.Errorf("could not update DeploymentRevision: %w", err)
+	}
+	revision.AutomaticRollbackOfRevisionID = &rollback.FailedRevisionID
+	return revision, nil
+}
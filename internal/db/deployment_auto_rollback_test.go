@@ -0,0 +1,108 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+// All statements of a test run in one transaction, so they share the same current_timestamp. The helpers below move
+// revisions and statuses in time to give them a well-defined order.
+
+func setRevisionCreatedAt(ctx context.Context, t *testing.T, id uuid.UUID, createdAt time.Time) {
+	t.Helper()
+	if _, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE DeploymentRevision SET created_at = $2 WHERE id = $1", id, createdAt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createErrorStatus(ctx context.Context, t *testing.T, revisionID uuid.UUID, createdAt time.Time) {
+	t.Helper()
+	if _, err := internalctx.GetDb(ctx).Exec(ctx,
+		"INSERT INTO DeploymentRevisionStatus (deployment_revision_id, type, message, created_at) "+
+			"VALUES ($1, 'error', 'UPGRADE FAILED', $2)",
+		revisionID, createdAt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeploymentAutoRollback(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	window := 10 * time.Minute
+
+	healthy := testutil.NewDeploymentRevision(ctx, t, target)
+	base := healthy.CreatedAt
+	setRevisionCreatedAt(ctx, t, healthy.ID, base.Add(-2*time.Hour))
+	g.Expect(db.CreateDeploymentRevisionStatus(ctx, healthy.ID, nil, types.DeploymentStatusTypeOK, "ok", nil)).
+		To(Succeed())
+
+	version, err := db.GetApplicationVersion(ctx, healthy.ApplicationVersionID)
+	g.Expect(err).NotTo(HaveOccurred())
+	update := types.ApplicationVersion{
+		Name:            "2.0.0",
+		ApplicationID:   version.ApplicationID,
+		ComposeFileData: version.ComposeFileData,
+	}
+	g.Expect(db.CreateApplicationVersion(ctx, &update)).To(Succeed())
+	request := api.DeploymentRequest{
+		DeploymentID:         &healthy.DeploymentID,
+		DeploymentTargetID:   target.ID,
+		ApplicationVersionID: update.ID,
+	}
+	failed, err := db.CreateDeploymentRevision(ctx, &request)
+	g.Expect(err).NotTo(HaveOccurred())
+	setRevisionCreatedAt(ctx, t, failed.ID, base.Add(-time.Hour))
+	g.Expect(db.CreateDeploymentRevisionStatus(ctx, failed.ID, nil, types.DeploymentStatusTypeOK, "ok", nil)).
+		To(Succeed())
+	createErrorStatus(ctx, t, failed.ID, base.Add(time.Second))
+
+	// automatic rollback is disabled by default
+	g.Expect(db.GetDueDeploymentAutoRollbacks(ctx, base.Add(time.Hour), window, 10)).To(BeEmpty())
+
+	deployment := types.Deployment{Base: types.Base{ID: healthy.DeploymentID}, AutoRollbackEnabled: util.PtrTo(true)}
+	g.Expect(db.UpdateDeployment(ctx, &deployment)).To(Succeed())
+	// the revision has been healthy before, so the error must persist for the whole window
+	g.Expect(db.GetDueDeploymentAutoRollbacks(ctx, base.Add(time.Minute), window, 10)).To(BeEmpty())
+	due, err := db.GetDueDeploymentAutoRollbacks(ctx, base.Add(11*time.Minute), window, 10)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).To(ConsistOf(And(
+		HaveField("FailedRevisionID", failed.ID),
+		HaveField("FailureMessage", "UPGRADE FAILED"),
+		HaveField("RollbackRevisionID", healthy.ID),
+		HaveField("RollbackApplicationVersionName", "1.0.0"),
+	)))
+
+	rollback, err := db.CreateDeploymentAutoRollbackRevision(ctx, due[0])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rollback.ApplicationVersionID).To(Equal(healthy.ApplicationVersionID))
+	g.Expect(rollback.AutomaticRollbackOfRevisionID).To(HaveValue(Equal(failed.ID)))
+	g.Expect(rollback.Reason).To(HaveValue(ContainSubstring("UPGRADE FAILED")))
+	setRevisionCreatedAt(ctx, t, rollback.ID, base.Add(-30*time.Minute))
+
+	// a failing rollback is not rolled back again
+	createErrorStatus(ctx, t, rollback.ID, base.Add(2*time.Second))
+	g.Expect(db.GetDueDeploymentAutoRollbacks(ctx, base.Add(time.Hour), window, 10)).To(BeEmpty())
+
+	// a new revision that never became healthy is rolled back right away, but not to the revision that has been
+	// rolled back before
+	retry, err := db.CreateDeploymentRevision(ctx, &request)
+	g.Expect(err).NotTo(HaveOccurred())
+	createErrorStatus(ctx, t, retry.ID, base.Add(3*time.Second))
+	g.Expect(db.GetDueDeploymentAutoRollbacks(ctx, base.Add(3*time.Second), window, 10)).To(ConsistOf(And(
+		HaveField("FailedRevisionID", retry.ID),
+		HaveField("RollbackRevisionID", healthy.ID),
+	)))
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetDeploymentDependencies returns the declared dependencies of all deployments of a deployment target, keyed by the
+// ID of the dependent deployment.
+func GetDeploymentDependencies(ctx context.Context, deploymentTargetID uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT dd.deployment_id, dd.depends_on_deployment_id
+		FROM DeploymentDependency dd
+		JOIN Deployment d ON dd.deployment_id = d.id
+		WHERE d.deployment_target_id = @deploymentTargetId
+		ORDER BY dd.created_at`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentDependencies: %w", err)
+	}
+	result := map[uuid.UUID][]uuid.UUID{}
+	var deploymentID, dependsOnID uuid.UUID
+	if _, err := pgx.ForEachRow(rows, []any{&deploymentID, &dependsOnID}, func() error {
+		result[deploymentID] = append(result[deploymentID], dependsOnID)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to collect DeploymentDependencies: %w", err)
+	}
+	return result, nil
+}
+
+// PutDeploymentDependencies replaces the declared dependencies of a deployment. Callers must make sure that all
+// deployments belong to the same deployment target.
+func PutDeploymentDependencies(ctx context.Context, deploymentID uuid.UUID, dependsOn []uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if dependsOn == nil {
+		dependsOn = []uuid.UUID{}
+	}
+	if _, err := db.Exec(ctx, `
+		DELETE FROM DeploymentDependency
+		WHERE deployment_id = @deploymentId AND NOT depends_on_deployment_id = ANY(@dependsOn)`,
+		pgx.NamedArgs{"deploymentId": deploymentID, "dependsOn": dependsOn},
+	); err != nil {
+		return fmt.Errorf("could not delete DeploymentDependencies: %w", err)
+	}
+	if _, err := db.Exec(ctx, `
+		INSERT INTO DeploymentDependency (deployment_id, depends_on_deployment_id)
+		SELECT @deploymentId, unnest(@dependsOn::UUID[])
+		ON CONFLICT DO NOTHING`,
+		pgx.NamedArgs{"deploymentId": deploymentID, "dependsOn": dependsOn},
+	); err != nil {
+		return fmt.Errorf("could not insert DeploymentDependencies: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestPutDeploymentDependencies(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	app := testutil.NewDeploymentRevision(ctx, t, target)
+	database := testutil.NewDeploymentRevision(ctx, t, target)
+	cache := testutil.NewDeploymentRevision(ctx, t, target)
+
+	g.Expect(db.PutDeploymentDependencies(ctx, app.DeploymentID,
+		[]uuid.UUID{database.DeploymentID, cache.DeploymentID})).To(Succeed())
+	dependencies, err := db.GetDeploymentDependencies(ctx, target.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dependencies).To(HaveLen(1))
+	g.Expect(dependencies[app.DeploymentID]).To(ConsistOf(database.DeploymentID, cache.DeploymentID))
+
+	g.Expect(db.PutDeploymentDependencies(ctx, app.DeploymentID, []uuid.UUID{cache.DeploymentID})).To(Succeed())
+	dependencies, err = db.GetDeploymentDependencies(ctx, target.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dependencies[app.DeploymentID]).To(ConsistOf(cache.DeploymentID))
+
+	g.Expect(db.PutDeploymentDependencies(ctx, app.DeploymentID, nil)).To(Succeed())
+	dependencies, err = db.GetDeploymentDependencies(ctx, target.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dependencies).To(BeEmpty())
+}
+
+func TestRequestDeploymentUninstall(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendor := org.Vendors[0]
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	deployment, err := db.GetDeployment(ctx, revision.DeploymentID, vendor.ID, org.ID, types.UserRoleVendor)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(db.RequestDeploymentUninstall(ctx, deployment, vendor.ID, true)).To(Succeed())
+	g.Expect(deployment.UninstallRequestedAt).NotTo(BeNil())
+	g.Expect(deployment.UninstallRequestedByUserAccountID).To(HaveValue(Equal(vendor.ID)))
+	g.Expect(deployment.UninstallDeleteData).To(BeTrue())
+	g.Expect(deployment.IsUninstalling()).To(BeTrue())
+	g.Expect(db.RequestDeploymentUninstall(ctx, deployment, vendor.ID, false)).
+		To(MatchError(apierrors.ErrConflict))
+
+	// the deployment is still sent to the agent until it reports completion
+	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, target.ID, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(HaveLen(1))
+
+	g.Expect(db.MarkDeploymentUninstalled(ctx, revision.ID)).To(Succeed())
+	deployment, err = db.GetDeployment(ctx, revision.DeploymentID, vendor.ID, org.ID, types.UserRoleVendor)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployment.UninstalledAt).NotTo(BeNil())
+	g.Expect(deployment.IsUninstalling()).To(BeFalse())
+
+	deployments, err = db.GetDeploymentsForDeploymentTarget(ctx, target.ID, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(BeEmpty())
+	deployments, err = db.GetDeploymentsForDeploymentTarget(ctx, target.ID, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(HaveLen(1))
+}
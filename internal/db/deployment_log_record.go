@@ -25,10 +25,12 @@ func SaveDeploymentLogRecords(ctx context.Context, records []api.DeploymentLogRe
 	_, err := db.CopyFrom(
 		ctx,
 		pgx.Identifier{"deploymentlogrecord"},
-		[]string{"deployment_id", "deployment_revision_id", "resource", "timestamp", "severity", "body"},
+		[]string{"deployment_id", "deployment_revision_id", "operation_id", "resource", "timestamp", "severity", "body"},
 		pgx.CopyFromSlice(len(records), func(i int) ([]any, error) {
 			r := records[i]
-			return []any{r.DeploymentID, r.DeploymentRevisionID, r.Resource, r.Timestamp, r.Severity, r.Body}, nil
+			return []any{
+				r.DeploymentID, r.DeploymentRevisionID, r.OperationID, r.Resource, r.Timestamp, r.Severity, r.Body,
+			}, nil
 		}),
 	)
 	return err
@@ -118,6 +120,11 @@ func CleanupDeploymentLogRecords(ctx context.Context) (int64, error) {
 				ORDER BY lr.timestamp DESC
 				LIMIT @limit
 			) keep ON true
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM Deployment d
+			WHERE d.id = DeploymentLogRecord.deployment_id
+				AND `+legalHoldOfDeploymentTargetExpr("d.deployment_target_id")+`
 		)`,
 		pgx.NamedArgs{"limit": limit},
 	)
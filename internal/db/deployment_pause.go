@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const pauseOutputExpr = ` paused_at, paused_by_user_account_id, pause_reason, resume_at `
+
+// PauseDeployment pauses the deployment with the user, reason and resume time of pause. Pausing a paused deployment
+// again replaces them, but keeps the time at which it has been paused.
+func PauseDeployment(ctx context.Context, deployment *types.Deployment, pause types.Pause) error {
+	return updatePause(ctx, "Deployment", deployment.ID, &pause, &deployment.Pause)
+}
+
+// ResumeDeployment ends the pause of the deployment. It returns apierrors.ErrConflict if the deployment is not paused.
+func ResumeDeployment(ctx context.Context, deployment *types.Deployment) error {
+	return updatePause(ctx, "Deployment", deployment.ID, nil, &deployment.Pause)
+}
+
+// PauseDeploymentTarget pauses all deployments of the deployment target, like [PauseDeployment].
+func PauseDeploymentTarget(ctx context.Context, dt *types.DeploymentTarget, pause types.Pause) error {
+	return updatePause(ctx, "DeploymentTarget", dt.ID, &pause, &dt.Pause)
+}
+
+// ResumeDeploymentTarget ends the pause of the deployment target. Deployments that have been paused individually stay
+// paused. It returns apierrors.ErrConflict if the deployment target is not paused.
+func ResumeDeploymentTarget(ctx context.Context, dt *types.DeploymentTarget) error {
+	return updatePause(ctx, "DeploymentTarget", dt.ID, nil, &dt.Pause)
+}
+
+// updatePause pauses the row with the given ID in table, or resumes it if pause is nil, and stores the resulting pause
+// in result.
+func updatePause(ctx context.Context, table string, id uuid.UUID, pause *types.Pause, result *types.Pause) error {
+	db := internalctx.GetDb(ctx)
+	var rows pgx.Rows
+	var err error
+	if pause != nil {
+		rows, err = db.Query(ctx,
+			`UPDATE `+table+`
+			SET paused_at = coalesce(paused_at, current_timestamp),
+				paused_by_user_account_id = @userAccountId,
+				pause_reason = @reason,
+				resume_at = @resumeAt
+			WHERE id = @id
+			RETURNING`+pauseOutputExpr,
+			pgx.NamedArgs{
+				"id":            id,
+				"userAccountId": pause.PausedByUserAccountID,
+				"reason":        pause.PauseReason,
+				"resumeAt":      pause.ResumeAt,
+			},
+		)
+	} else {
+		rows, err = db.Query(ctx,
+			`UPDATE `+table+`
+			SET paused_at = NULL, paused_by_user_account_id = NULL, pause_reason = NULL, resume_at = NULL
+			WHERE id = @id AND paused_at IS NOT NULL
+			RETURNING`+pauseOutputExpr,
+			pgx.NamedArgs{"id": id},
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("could not update %v: %w", table, err)
+	}
+	if updated, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Pause]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if pause != nil {
+				err = apierrors.ErrNotFound
+			} else {
+				err = apierrors.ErrConflict
+			}
+		}
+		return fmt.Errorf("could not update %v: %w", table, err)
+	} else {
+		*result = updated
+		return nil
+	}
+}
+
+// ResumeDuePauses ends the pauses of all deployments and deployment targets whose resume time is at or before now and
+// returns them with the pause that has been ended. It must be called in a transaction.
+func ResumeDuePauses(ctx context.Context, now time.Time) (
+	deployments []types.ResumedPause,
+	targets []types.ResumedPause,
+	err error,
+) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		WITH due AS (
+			SELECT d.id, dt.organization_id, d.paused_at, d.paused_by_user_account_id, d.pause_reason, d.resume_at
+			FROM Deployment d
+			JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+			WHERE d.resume_at <= @now
+			FOR UPDATE OF d
+		)
+		UPDATE Deployment d
+		SET paused_at = NULL, paused_by_user_account_id = NULL, pause_reason = NULL, resume_at = NULL
+		FROM due
+		WHERE d.id = due.id
+		RETURNING due.id, due.organization_id, due.paused_at, due.paused_by_user_account_id, due.pause_reason,
+			due.resume_at`,
+		pgx.NamedArgs{"now": now},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not resume Deployments: %w", err)
+	}
+	if deployments, err = pgx.CollectRows(rows, pgx.RowToStructByName[types.ResumedPause]); err != nil {
+		return nil, nil, fmt.Errorf("could not resume Deployments: %w", err)
+	}
+
+	rows, err = db.Query(ctx, `
+		WITH due AS (
+			SELECT id, organization_id, paused_at, paused_by_user_account_id, pause_reason, resume_at
+			FROM DeploymentTarget
+			WHERE resume_at <= @now
+			FOR UPDATE
+		)
+		UPDATE DeploymentTarget dt
+		SET paused_at = NULL, paused_by_user_account_id = NULL, pause_reason = NULL, resume_at = NULL
+		FROM due
+		WHERE dt.id = due.id
+		RETURNING due.id, due.organization_id, due.paused_at, due.paused_by_user_account_id, due.pause_reason,
+			due.resume_at`,
+		pgx.NamedArgs{"now": now},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not resume DeploymentTargets: %w", err)
+	}
+	if targets, err = pgx.CollectRows(rows, pgx.RowToStructByName[types.ResumedPause]); err != nil {
+		return nil, nil, fmt.Errorf("could not resume DeploymentTargets: %w", err)
+	}
+	return deployments, targets, nil
+}
@@ -0,0 +1,107 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentPause(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	userID := org.Vendors[0].ID
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, userID)
+	revision := testutil.NewDeploymentRevision(ctx, t, dt)
+	deployment, err := db.GetDeployment(ctx, revision.DeploymentID, userID, org.ID, types.UserRoleVendor)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	paused := func() bool {
+		deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, dt.ID, false)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(deployments).To(HaveLen(1))
+		return deployments[0].Paused
+	}
+	g.Expect(paused()).To(BeFalse())
+	g.Expect(db.ResumeDeployment(ctx, deployment)).To(MatchError(apierrors.ErrConflict))
+
+	g.Expect(db.PauseDeployment(ctx, deployment, types.Pause{
+		PausedByUserAccountID: &userID,
+		PauseReason:           util.PtrTo("incident"),
+	})).To(Succeed())
+	g.Expect(deployment.PausedAt).NotTo(BeNil())
+	pausedAt := *deployment.PausedAt
+	g.Expect(paused()).To(BeTrue())
+
+	// pausing again replaces the reason but keeps the time
+	resumeAt := time.Now().Add(time.Hour)
+	g.Expect(db.PauseDeployment(ctx, deployment, types.Pause{
+		PausedByUserAccountID: &userID,
+		PauseReason:           util.PtrTo("maintenance"),
+		ResumeAt:              &resumeAt,
+	})).To(Succeed())
+	g.Expect(deployment.PauseReason).To(HaveValue(Equal("maintenance")))
+	g.Expect(*deployment.PausedAt).To(Equal(pausedAt))
+
+	g.Expect(db.ResumeDeployment(ctx, deployment)).To(Succeed())
+	g.Expect(deployment.IsPaused()).To(BeFalse())
+	g.Expect(paused()).To(BeFalse())
+
+	// a paused deployment target pauses its deployments
+	g.Expect(db.PauseDeploymentTarget(ctx, &dt.DeploymentTarget, types.Pause{
+		PausedByUserAccountID: &userID,
+		PauseReason:           util.PtrTo("host maintenance"),
+		ResumeAt:              &resumeAt,
+	})).To(Succeed())
+	g.Expect(paused()).To(BeTrue())
+
+	var resumedDeployments, resumedTargets []types.ResumedPause
+	g.Expect(db.RunTx(ctx, func(ctx context.Context) (err error) {
+		resumedDeployments, resumedTargets, err = db.ResumeDuePauses(ctx, time.Now())
+		return
+	})).To(Succeed())
+	g.Expect(resumedDeployments).To(BeEmpty())
+	g.Expect(resumedTargets).To(BeEmpty())
+
+	g.Expect(db.RunTx(ctx, func(ctx context.Context) (err error) {
+		resumedDeployments, resumedTargets, err = db.ResumeDuePauses(ctx, resumeAt)
+		return
+	})).To(Succeed())
+	g.Expect(resumedDeployments).To(BeEmpty())
+	g.Expect(resumedTargets).To(HaveLen(1))
+	g.Expect(resumedTargets[0].ID).To(Equal(dt.ID))
+	g.Expect(resumedTargets[0].OrganizationID).To(Equal(org.ID))
+	g.Expect(resumedTargets[0].PauseReason).To(HaveValue(Equal("host maintenance")))
+	g.Expect(paused()).To(BeFalse())
+}
+
+func TestArchivedDeploymentIsSentToAgent(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	userID := org.Vendors[0].ID
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, userID)
+	revision := testutil.NewDeploymentRevision(ctx, t, dt)
+	deployment, err := db.GetDeployment(ctx, revision.DeploymentID, userID, org.ID, types.UserRoleVendor)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.SetDeploymentArchived(ctx, deployment, true)).To(Succeed())
+
+	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, dt.ID, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(BeEmpty())
+
+	// the agent resource includes archived deployments, so that the agent does not uninstall them
+	deployments, err = db.GetDeploymentsForDeploymentTarget(ctx, dt.ID, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deployments).To(HaveLen(1))
+	g.Expect(deployments[0].ID).To(Equal(deployment.ID))
+	g.Expect(deployments[0].ArchivedAt).NotTo(BeNil())
+	g.Expect(deployments[0].DeploymentRevisionID).To(Equal(revision.ID))
+}
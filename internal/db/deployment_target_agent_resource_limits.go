@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+// UpdateDeploymentTargetAgentResourceLimits sets the agent resource limits that override the defaults of the
+// organization for dt and updates the limits properties of dt.
+func UpdateDeploymentTargetAgentResourceLimits(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	limits types.AgentResourceLimits,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE DeploymentTarget AS dt
+		SET agent_resource_limits = @limits
+		WHERE dt.id = @id
+		RETURNING dt.agent_resource_limits,
+			`+deploymentTargetEffectiveAgentResourceLimitsExpr+` AS effective_agent_resource_limits`,
+		pgx.NamedArgs{"id": dt.ID, "limits": limits},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update DeploymentTarget: %w", err)
+	} else if updated, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByNameLax[types.DeploymentTarget],
+	); err != nil {
+		return fmt.Errorf("could not get updated DeploymentTarget: %w", err)
+	} else {
+		dt.AgentResourceLimits = updated.AgentResourceLimits
+		dt.EffectiveAgentResourceLimits = updated.EffectiveAgentResourceLimits
+		return nil
+	}
+}
+
+// UpdateDeploymentTargetAppliedAgentResourceLimits saves the limits that the agent of dt reported as applied.
+func UpdateDeploymentTargetAppliedAgentResourceLimits(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	applied types.AgentResourceLimits,
+	unsupported []string,
+) error {
+	result := types.AppliedAgentResourceLimits{Limits: applied, Unsupported: unsupported, ReportedAt: time.Now()}
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE DeploymentTarget SET applied_agent_resource_limits = @applied WHERE id = @id`,
+		pgx.NamedArgs{"id": dt.ID, "applied": result},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTarget: %w", err)
+	}
+	dt.AppliedAgentResourceLimits = &result
+	return nil
+}
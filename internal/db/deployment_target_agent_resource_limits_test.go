@@ -0,0 +1,47 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentTargetAgentResourceLimits(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	g.Expect(dt.EffectiveAgentResourceLimits).To(Equal(types.AgentResourceLimits{}))
+	g.Expect(dt.AppliedAgentResourceLimits).To(BeNil())
+
+	org.AgentResourceLimits = types.AgentResourceLimits{
+		MaxConcurrentPulls: util.PtrTo(2),
+		LogLevel:           util.PtrTo("info"),
+	}
+	g.Expect(db.UpdateOrganization(ctx, &org.Organization)).To(Succeed())
+	g.Expect(org.AgentResourceLimits.MaxConcurrentPulls).To(HaveValue(Equal(2)))
+
+	override := types.AgentResourceLimits{LogLevel: util.PtrTo("debug"), NiceLevel: util.PtrTo(10)}
+	g.Expect(db.UpdateDeploymentTargetAgentResourceLimits(ctx, dt, override)).To(Succeed())
+	g.Expect(dt.AgentResourceLimits).To(Equal(override))
+	g.Expect(dt.EffectiveAgentResourceLimits).To(Equal(types.AgentResourceLimits{
+		MaxConcurrentPulls: util.PtrTo(2),
+		NiceLevel:          util.PtrTo(10),
+		LogLevel:           util.PtrTo("debug"),
+	}))
+
+	applied := types.AgentResourceLimits{MaxConcurrentPulls: util.PtrTo(2), LogLevel: util.PtrTo("debug")}
+	g.Expect(db.UpdateDeploymentTargetAppliedAgentResourceLimits(
+		ctx, dt, applied, []string{types.AgentResourceLimitNiceLevel})).To(Succeed())
+
+	loaded, err := db.GetDeploymentTarget(ctx, dt.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.EffectiveAgentResourceLimits).To(Equal(dt.EffectiveAgentResourceLimits))
+	g.Expect(loaded.AppliedAgentResourceLimits).NotTo(BeNil())
+	g.Expect(loaded.AppliedAgentResourceLimits.Limits).To(Equal(applied))
+	g.Expect(loaded.AppliedAgentResourceLimits.Unsupported).To(ConsistOf(types.AgentResourceLimitNiceLevel))
+}
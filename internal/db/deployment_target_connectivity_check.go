@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	deploymentTargetConnectivityCheckOutputExpr = `
+		c.id, c.created_at, c.deployment_target_id, c.requested_by_user_account_id, c.requested_at, c.reported_at,
+		coalesce(c.results, '[]'::jsonb) AS results
+	`
+)
+
+func GetDeploymentTargetConnectivityCheck(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+) (*types.DeploymentTargetConnectivityCheck, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetConnectivityCheckOutputExpr+
+			"FROM DeploymentTargetConnectivityCheck c "+
+			"WHERE c.deployment_target_id = @deploymentTargetId",
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetConnectivityCheck: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentTargetConnectivityCheck])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetConnectivityCheck: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// RequestDeploymentTargetConnectivityCheck replaces the previous connectivity check of the deployment target with a
+// new pending one. If the previous check was requested less than minInterval ago, apierrors.ErrConflict is returned.
+func RequestDeploymentTargetConnectivityCheck(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	userID uuid.UUID,
+	minInterval time.Duration,
+) (*types.DeploymentTargetConnectivityCheck, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO DeploymentTargetConnectivityCheck AS c (deployment_target_id, requested_by_user_account_id)
+			VALUES (@deploymentTargetId, @userId)
+			ON CONFLICT (deployment_target_id) DO UPDATE SET
+				id = gen_random_uuid(),
+				created_at = current_timestamp,
+				requested_by_user_account_id = EXCLUDED.requested_by_user_account_id,
+				requested_at = current_timestamp,
+				reported_at = NULL,
+				results = NULL
+			WHERE c.requested_at < current_timestamp - @minInterval::interval
+			RETURNING `+deploymentTargetConnectivityCheckOutputExpr,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID, "userId": userID, "minInterval": minInterval})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save DeploymentTargetConnectivityCheck: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentTargetConnectivityCheck])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrConflict
+	} else if err != nil {
+		return nil, fmt.Errorf("could not save DeploymentTargetConnectivityCheck: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// GetPendingDeploymentTargetConnectivityCheck returns the connectivity check of the deployment target that has been
+// requested but not reported yet. Checks that are older than maxAge are considered abandoned and not returned.
+func GetPendingDeploymentTargetConnectivityCheck(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	maxAge time.Duration,
+) (*types.DeploymentTargetConnectivityCheck, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetConnectivityCheckOutputExpr+
+			"FROM DeploymentTargetConnectivityCheck c "+
+			"WHERE c.deployment_target_id = @deploymentTargetId "+
+			"AND c.reported_at IS NULL "+
+			"AND c.requested_at >= current_timestamp - @maxAge::interval",
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID, "maxAge": maxAge})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetConnectivityCheck: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentTargetConnectivityCheck])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetConnectivityCheck: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// ReportDeploymentTargetConnectivityCheck stores the results of a pending connectivity check.
+// Reporting a check that does not exist or has already been reported results in apierrors.ErrNotFound.
+func ReportDeploymentTargetConnectivityCheck(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	checkID uuid.UUID,
+	results []types.ConnectivityCheckResult,
+) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`UPDATE DeploymentTargetConnectivityCheck
+			SET reported_at = current_timestamp, results = @results
+			WHERE id = @id AND deployment_target_id = @deploymentTargetId AND reported_at IS NULL`,
+		pgx.NamedArgs{"id": checkID, "deploymentTargetId": deploymentTargetID, "results": results})
+	if err == nil && cmd.RowsAffected() == 0 {
+		err = apierrors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not update DeploymentTargetConnectivityCheck: %w", err)
+	}
+	return nil
+}
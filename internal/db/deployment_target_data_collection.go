@@ -0,0 +1,245 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const deploymentTargetDataPurgeOutputExpr = `
+	p.id,
+	p.created_at,
+	p.deployment_target_id,
+	p.requested_by_user_account_id,
+	p.logs,
+	p.metrics,
+	p.diagnostics,
+	p.inventory,
+	p.completed_at
+`
+
+// UpdateDeploymentTargetDataCollection sets the data collection settings of either the vendor or the customer of dt,
+// depending on role, and updates the data collection properties of dt.
+func UpdateDeploymentTargetDataCollection(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	role types.UserRole,
+	dc types.DataCollection,
+) error {
+	var prefix string
+	switch role {
+	case types.UserRoleVendor:
+		prefix = "vendor"
+	case types.UserRoleCustomer:
+		prefix = "customer"
+	default:
+		return fmt.Errorf("invalid role: %v", role)
+	}
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		fmt.Sprintf(
+			`UPDATE DeploymentTarget AS dt
+			SET %[1]v_logs_disabled = @logsDisabled,
+				%[1]v_metrics_disabled = @metricsDisabled,
+				%[1]v_diagnostics_disabled = @diagnosticsDisabled,
+				%[1]v_inventory_disabled = @inventoryDisabled
+			WHERE dt.id = @id
+			RETURNING`+deploymentTargetDataCollectionOutputExpr,
+			prefix,
+		),
+		pgx.NamedArgs{
+			"id":                  dt.ID,
+			"logsDisabled":        dc.LogsDisabled,
+			"metricsDisabled":     dc.MetricsDisabled,
+			"diagnosticsDisabled": dc.DiagnosticsDisabled,
+			"inventoryDisabled":   dc.InventoryDisabled,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update DeploymentTarget: %w", err)
+	} else if updated, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByNameLax[types.DeploymentTarget],
+	); err != nil {
+		return fmt.Errorf("could not get updated DeploymentTarget: %w", err)
+	} else {
+		dt.VendorDataCollection = updated.VendorDataCollection
+		dt.CustomerDataCollection = updated.CustomerDataCollection
+		dt.DataCollection = updated.DataCollection
+		return nil
+	}
+}
+
+func CreateDeploymentTargetDataPurge(ctx context.Context, purge *types.DeploymentTargetDataPurge) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO DeploymentTargetDataPurge AS p
+			(deployment_target_id, requested_by_user_account_id, logs, metrics, diagnostics, inventory)
+		VALUES (@deploymentTargetId, @requestedBy, @logs, @metrics, @diagnostics, @inventory)
+		RETURNING`+deploymentTargetDataPurgeOutputExpr,
+		pgx.NamedArgs{
+			"deploymentTargetId": purge.DeploymentTargetID,
+			"requestedBy":        purge.RequestedByUserAccountID,
+			"logs":               purge.Logs,
+			"metrics":            purge.Metrics,
+			"diagnostics":        purge.Diagnostics,
+			"inventory":          purge.Inventory,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetDataPurge: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetDataPurge],
+	); err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetDataPurge: %w", err)
+	} else {
+		*purge = result
+		return nil
+	}
+}
+
+// GetDeploymentTargetDataPurges returns the purge requests of a deployment target, the newest first.
+func GetDeploymentTargetDataPurges(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+) ([]types.DeploymentTargetDataPurge, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+deploymentTargetDataPurgeOutputExpr+`
+		FROM DeploymentTargetDataPurge p
+		WHERE p.deployment_target_id = @deploymentTargetId
+		ORDER BY p.created_at DESC`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetDataPurge: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentTargetDataPurge]); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetDataPurge: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetPendingDeploymentTargetDataPurges returns all purge requests that have not been executed yet, the oldest first.
+func GetPendingDeploymentTargetDataPurges(ctx context.Context) ([]types.DeploymentTargetDataPurge, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+deploymentTargetDataPurgeOutputExpr+`
+		FROM DeploymentTargetDataPurge p
+		WHERE p.completed_at IS NULL
+		ORDER BY p.created_at`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetDataPurge: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentTargetDataPurge]); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetDataPurge: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// ExecuteDeploymentTargetDataPurge deletes the data of all categories requested by purge and marks it as completed.
+// Logs are the deployment log records, metrics are the deployment target and application metrics and diagnostics are
+// the reported connectivity checks. Inventory is the latest inventory snapshot. It returns the number of deleted rows
+// and apierrors.ErrNotFound if purge has already been completed.
+func ExecuteDeploymentTargetDataPurge(ctx context.Context, purge *types.DeploymentTargetDataPurge) (int64, error) {
+	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{"id": purge.ID, "deploymentTargetId": purge.DeploymentTargetID}
+	rows, err := db.Query(ctx,
+		`UPDATE DeploymentTargetDataPurge AS p
+		SET completed_at = now()
+		WHERE p.id = @id AND p.completed_at IS NULL
+		RETURNING`+deploymentTargetDataPurgeOutputExpr,
+		args,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not update DeploymentTargetDataPurge: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetDataPurge],
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return 0, fmt.Errorf("could not update DeploymentTargetDataPurge: %w", err)
+	} else {
+		*purge = result
+	}
+
+	var queries []string
+	if purge.Logs {
+		queries = append(queries,
+			`DELETE FROM DeploymentLogRecord
+			WHERE deployment_id IN (SELECT id FROM Deployment WHERE deployment_target_id = @deploymentTargetId)`,
+		)
+	}
+	if purge.Metrics {
+		queries = append(queries,
+			`DELETE FROM DeploymentTargetMetrics WHERE deployment_target_id = @deploymentTargetId`,
+			`DELETE FROM DeploymentAppMetric
+			WHERE deployment_id IN (SELECT id FROM Deployment WHERE deployment_target_id = @deploymentTargetId)`,
+		)
+	}
+	if purge.Diagnostics {
+		queries = append(queries,
+			`DELETE FROM DeploymentTargetConnectivityCheck
+			WHERE deployment_target_id = @deploymentTargetId AND reported_at IS NOT NULL`,
+		)
+	}
+	if purge.Inventory {
+		queries = append(queries,
+			`DELETE FROM DeploymentTargetInventory WHERE deployment_target_id = @deploymentTargetId`,
+		)
+	}
+	var count int64
+	for _, query := range queries {
+		if cmd, err := db.Exec(ctx, query, args); err != nil {
+			return count, fmt.Errorf("could not purge data: %w", err)
+		} else {
+			count += cmd.RowsAffected()
+		}
+	}
+	return count, nil
+}
+
+// GetDeploymentTargetDataSummaries summarizes the collected logs and metrics of the given deployment targets.
+func GetDeploymentTargetDataSummaries(
+	ctx context.Context,
+	deploymentTargetIDs []uuid.UUID,
+) ([]types.DeploymentTargetDataSummary, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT
+			dt.id AS deployment_target_id,
+			m.count AS metrics_count, m.min AS metrics_from, m.max AS metrics_to,
+			l.count AS log_record_count, l.min AS log_records_from, l.max AS log_records_to
+		FROM DeploymentTarget dt
+			CROSS JOIN LATERAL (
+				SELECT count(*), min(created_at), max(created_at)
+				FROM DeploymentTargetMetrics
+				WHERE deployment_target_id = dt.id
+			) m
+			CROSS JOIN LATERAL (
+				SELECT count(*), min(lr.created_at), max(lr.created_at)
+				FROM DeploymentLogRecord lr
+					JOIN Deployment d ON d.id = lr.deployment_id
+				WHERE d.deployment_target_id = dt.id
+			) l
+		WHERE dt.id = ANY (@deploymentTargetIds)
+		ORDER BY dt.id`,
+		pgx.NamedArgs{"deploymentTargetIds": deploymentTargetIDs},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTarget data: %w", err)
+	} else if result, err := pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.DeploymentTargetDataSummary],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTarget data: %w", err)
+	} else {
+		return result, nil
+	}
+}
@@ -0,0 +1,93 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestUpdateDeploymentTargetDataCollection(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	g.Expect(dt.DataCollection).To(BeZero())
+
+	g.Expect(db.UpdateDeploymentTargetDataCollection(
+		ctx, dt, types.UserRoleVendor, types.DataCollection{MetricsDisabled: true},
+	)).To(Succeed())
+	g.Expect(db.UpdateDeploymentTargetDataCollection(
+		ctx, dt, types.UserRoleCustomer, types.DataCollection{LogsDisabled: true},
+	)).To(Succeed())
+	g.Expect(dt.VendorDataCollection).To(Equal(types.DataCollection{MetricsDisabled: true}))
+	g.Expect(dt.CustomerDataCollection).To(Equal(types.DataCollection{LogsDisabled: true}))
+	g.Expect(dt.DataCollection).To(Equal(types.DataCollection{LogsDisabled: true, MetricsDisabled: true}))
+
+	loaded, err := db.GetDeploymentTarget(ctx, dt.ID, &org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.DataCollection).To(Equal(dt.DataCollection))
+
+	// the vendor can not override the setting of the customer
+	g.Expect(db.UpdateDeploymentTargetDataCollection(
+		ctx, dt, types.UserRoleVendor, types.DataCollection{},
+	)).To(Succeed())
+	g.Expect(dt.DataCollection).To(Equal(types.DataCollection{LogsDisabled: true}))
+
+	g.Expect(db.UpdateDeploymentTargetDataCollection(ctx, dt, "", types.DataCollection{})).NotTo(Succeed())
+}
+
+func TestExecuteDeploymentTargetDataPurge(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, dt)
+
+	g.Expect(db.SaveDeploymentLogRecords(ctx, []api.DeploymentLogRecord{{
+		DeploymentID:         revision.DeploymentID,
+		DeploymentRevisionID: revision.ID,
+		Resource:             "app",
+		Timestamp:            time.Now(),
+		Severity:             "info",
+		Body:                 "hello",
+	}})).To(Succeed())
+	check, err := db.RequestDeploymentTargetConnectivityCheck(ctx, dt.ID, org.Vendors[0].ID, 0)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.ReportDeploymentTargetConnectivityCheck(ctx, dt.ID, check.ID, nil)).To(Succeed())
+
+	purge := types.DeploymentTargetDataPurge{
+		DeploymentTargetID:       dt.ID,
+		RequestedByUserAccountID: util.PtrTo(org.Vendors[0].ID),
+		Diagnostics:              true,
+	}
+	g.Expect(db.CreateDeploymentTargetDataPurge(ctx, &purge)).To(Succeed())
+	pending, err := db.GetPendingDeploymentTargetDataPurges(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(ContainElement(HaveField("ID", purge.ID)))
+
+	count, err := db.ExecuteDeploymentTargetDataPurge(ctx, &purge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(count).To(Equal(int64(1)))
+	g.Expect(purge.CompletedAt).NotTo(BeNil())
+	_, err = db.GetDeploymentTargetConnectivityCheck(ctx, dt.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	// logs were not requested and must be kept
+	records, err := db.GetDeploymentLogRecordResources(ctx, revision.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(records).To(ConsistOf("app"))
+
+	_, err = db.ExecuteDeploymentTargetDataPurge(ctx, &purge)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	purges, err := db.GetDeploymentTargetDataPurges(ctx, dt.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(purges).To(HaveLen(1))
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	deploymentTargetEndpointOutputExpr = `
+		e.id, e.created_at, e.deployment_target_id, e.url, e.self_signed_ok, e.last_checked_at, e.last_error,
+		e.notified_days
+	`
+	deploymentTargetEndpointCertificateOutputExpr = `
+		c.id, c.created_at, c.deployment_target_endpoint_id, c.last_seen_at, c.fingerprint, c.subject, c.issuer,
+		c.not_after, c.chain_valid, c.chain_error
+	`
+)
+
+func GetDeploymentTargetEndpoints(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+) ([]types.DeploymentTargetEndpoint, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetEndpointOutputExpr+"FROM DeploymentTargetEndpoint e "+
+			"WHERE e.deployment_target_id = @deploymentTargetId ORDER BY e.url",
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetEndpoints: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentTargetEndpoint])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetEndpoints: %w", err)
+	}
+	return result, nil
+}
+
+func GetDeploymentTargetEndpoint(
+	ctx context.Context,
+	id, deploymentTargetID uuid.UUID,
+) (*types.DeploymentTargetEndpoint, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetEndpointOutputExpr+"FROM DeploymentTargetEndpoint e "+
+			"WHERE e.id = @id AND e.deployment_target_id = @deploymentTargetId",
+		pgx.NamedArgs{"id": id, "deploymentTargetId": deploymentTargetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetEndpoint: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentTargetEndpoint])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetEndpoint: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// GetDueDeploymentTargetEndpoints returns at most limit endpoints of non-archived deployment targets that have not
+// been checked since checkedBefore, least recently checked first.
+func GetDueDeploymentTargetEndpoints(
+	ctx context.Context,
+	checkedBefore time.Time,
+	limit int,
+) ([]types.DeploymentTargetEndpoint, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetEndpointOutputExpr+"FROM DeploymentTargetEndpoint e "+
+			"JOIN DeploymentTarget dt ON dt.id = e.deployment_target_id "+
+			"WHERE dt.archived_at IS NULL AND (e.last_checked_at IS NULL OR e.last_checked_at < @checkedBefore) "+
+			"ORDER BY e.last_checked_at NULLS FIRST, e.created_at LIMIT @limit",
+		pgx.NamedArgs{"checkedBefore": checkedBefore, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetEndpoints: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentTargetEndpoint])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetEndpoints: %w", err)
+	}
+	return result, nil
+}
+
+func CreateDeploymentTargetEndpoint(ctx context.Context, endpoint *types.DeploymentTargetEndpoint) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO DeploymentTargetEndpoint AS e (deployment_target_id, url, self_signed_ok)
+			VALUES (@deploymentTargetId, @url, @selfSignedOk)
+			RETURNING`+deploymentTargetEndpointOutputExpr,
+		pgx.NamedArgs{
+			"deploymentTargetId": endpoint.DeploymentTargetID,
+			"url":                endpoint.URL,
+			"selfSignedOk":       endpoint.SelfSignedOK,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert DeploymentTargetEndpoint: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentTargetEndpoint]); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrAlreadyExists, err)
+		}
+		return err
+	} else {
+		*endpoint = result
+		return nil
+	}
+}
+
+func DeleteDeploymentTargetEndpoint(ctx context.Context, id, deploymentTargetID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM DeploymentTargetEndpoint WHERE id = @id AND deployment_target_id = @deploymentTargetId",
+		pgx.NamedArgs{"id": id, "deploymentTargetId": deploymentTargetID})
+	if err != nil {
+		return fmt.Errorf("failed to delete DeploymentTargetEndpoint: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+// SaveDeploymentTargetEndpointCheck saves the check state of endpoint and, if cert is not nil, records the
+// certificate. If the certificate differs from the last recorded one, a new history entry is created and the
+// notification state of the endpoint is reset. The returned bool is true in this case.
+func SaveDeploymentTargetEndpointCheck(
+	ctx context.Context,
+	endpoint *types.DeploymentTargetEndpoint,
+	cert *types.DeploymentTargetEndpointCertificate,
+) (rotated bool, err error) {
+	err = RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		if cert != nil {
+			cmd, err := db.Exec(ctx,
+				`UPDATE DeploymentTargetEndpointCertificate
+					SET last_seen_at = current_timestamp, chain_valid = @chainValid, chain_error = @chainError
+					WHERE id = (
+						SELECT id FROM DeploymentTargetEndpointCertificate
+						WHERE deployment_target_endpoint_id = @endpointId
+						ORDER BY created_at DESC LIMIT 1
+					) AND fingerprint = @fingerprint`,
+				pgx.NamedArgs{
+					"endpointId":  endpoint.ID,
+					"fingerprint": cert.Fingerprint,
+					"chainValid":  cert.ChainValid,
+					"chainError":  cert.ChainError,
+				})
+			if err != nil {
+				return fmt.Errorf("failed to update DeploymentTargetEndpointCertificate: %w", err)
+			}
+			if cmd.RowsAffected() == 0 {
+				rotated = true
+				endpoint.NotifiedDays = nil
+				_, err := db.Exec(ctx,
+					`INSERT INTO DeploymentTargetEndpointCertificate
+						(deployment_target_endpoint_id, fingerprint, subject, issuer, not_after, chain_valid, chain_error)
+						VALUES (@endpointId, @fingerprint, @subject, @issuer, @notAfter, @chainValid, @chainError)`,
+					pgx.NamedArgs{
+						"endpointId":  endpoint.ID,
+						"fingerprint": cert.Fingerprint,
+						"subject":     cert.Subject,
+						"issuer":      cert.Issuer,
+						"notAfter":    cert.NotAfter,
+						"chainValid":  cert.ChainValid,
+						"chainError":  cert.ChainError,
+					})
+				if err != nil {
+					return fmt.Errorf("failed to insert DeploymentTargetEndpointCertificate: %w", err)
+				}
+			}
+		}
+		cmd, err := db.Exec(ctx,
+			`UPDATE DeploymentTargetEndpoint
+				SET last_checked_at = @lastCheckedAt, last_error = @lastError, notified_days = @notifiedDays
+				WHERE id = @id`,
+			pgx.NamedArgs{
+				"id":            endpoint.ID,
+				"lastCheckedAt": endpoint.LastCheckedAt,
+				"lastError":     endpoint.LastError,
+				"notifiedDays":  endpoint.NotifiedDays,
+			})
+		if err != nil {
+			return fmt.Errorf("failed to update DeploymentTargetEndpoint: %w", err)
+		} else if cmd.RowsAffected() == 0 {
+			return apierrors.ErrNotFound
+		}
+		return nil
+	})
+	return
+}
+
+func UpdateDeploymentTargetEndpointNotifiedDays(ctx context.Context, id uuid.UUID, days int) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(ctx,
+		"UPDATE DeploymentTargetEndpoint SET notified_days = @days WHERE id = @id",
+		pgx.NamedArgs{"id": id, "days": days})
+	if err != nil {
+		return fmt.Errorf("failed to update DeploymentTargetEndpoint: %w", err)
+	}
+	return nil
+}
+
+// GetLatestDeploymentTargetEndpointCertificates returns the most recently recorded certificate for each of the given
+// endpoints that has one, keyed by endpoint ID.
+func GetLatestDeploymentTargetEndpointCertificates(
+	ctx context.Context,
+	endpointIDs []uuid.UUID,
+) (map[uuid.UUID]types.DeploymentTargetEndpointCertificate, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT DISTINCT ON (c.deployment_target_endpoint_id)"+deploymentTargetEndpointCertificateOutputExpr+
+			"FROM DeploymentTargetEndpointCertificate c "+
+			"WHERE c.deployment_target_endpoint_id = ANY(@endpointIds) "+
+			"ORDER BY c.deployment_target_endpoint_id, c.created_at DESC",
+		pgx.NamedArgs{"endpointIds": endpointIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetEndpointCertificates: %w", err)
+	}
+	certs, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentTargetEndpointCertificate])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetEndpointCertificates: %w", err)
+	}
+	result := make(map[uuid.UUID]types.DeploymentTargetEndpointCertificate, len(certs))
+	for _, cert := range certs {
+		result[cert.DeploymentTargetEndpointID] = cert
+	}
+	return result, nil
+}
+
+// GetDeploymentTargetEndpointCertificates returns all certificates that have been seen on an endpoint, latest first.
+func GetDeploymentTargetEndpointCertificates(
+	ctx context.Context,
+	endpointID uuid.UUID,
+) ([]types.DeploymentTargetEndpointCertificate, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetEndpointCertificateOutputExpr+"FROM DeploymentTargetEndpointCertificate c "+
+			"WHERE c.deployment_target_endpoint_id = @endpointId ORDER BY c.created_at DESC",
+		pgx.NamedArgs{"endpointId": endpointID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetEndpointCertificates: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentTargetEndpointCertificate])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetEndpointCertificates: %w", err)
+	}
+	return result, nil
+}
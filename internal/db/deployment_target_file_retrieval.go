@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	deploymentTargetFileRetrievalAllowListOutputExpr = `
+		a.deployment_target_id, a.updated_at, a.updated_by_user_account_id, a.allowed_paths
+	`
+	// deploymentTargetFileRetrievalOutputExpr reports pending retrievals that are older than @maxAge as expired.
+	// requested_by may be encrypted and must be decrypted with decryptPII.
+	deploymentTargetFileRetrievalOutputExpr = `
+		r.id, r.created_at, r.deployment_target_id, r.requested_by_user_account_id, u.email AS requested_by, r.path,
+		CASE WHEN r.status = 'pending' AND r.created_at < current_timestamp - @maxAge::interval
+			THEN 'expired' ELSE r.status::TEXT END AS status,
+		r.reported_at, r.size, r.redacted_secrets, r.error
+	`
+	deploymentTargetFileRetrievalFromExpr = `
+		FROM DeploymentTargetFileRetrieval r
+		LEFT JOIN UserAccount u ON u.id = r.requested_by_user_account_id
+	`
+)
+
+// GetDeploymentTargetFileRetrievalAllowList returns the file retrieval allow-list of the deployment target. If none
+// has been saved yet, an empty allow-list is returned.
+func GetDeploymentTargetFileRetrievalAllowList(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+) (*types.DeploymentTargetFileRetrievalAllowList, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetFileRetrievalAllowListOutputExpr+
+			"FROM DeploymentTargetFileRetrievalAllowList a "+
+			"WHERE a.deployment_target_id = @deploymentTargetId",
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetFileRetrievalAllowList: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetFileRetrievalAllowList])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &types.DeploymentTargetFileRetrievalAllowList{
+			DeploymentTargetID: deploymentTargetID,
+			AllowedPaths:       []string{},
+		}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetFileRetrievalAllowList: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+func UpdateDeploymentTargetFileRetrievalAllowList(
+	ctx context.Context,
+	allowList *types.DeploymentTargetFileRetrievalAllowList,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO DeploymentTargetFileRetrievalAllowList AS a
+			(deployment_target_id, updated_by_user_account_id, allowed_paths)
+			VALUES (@deploymentTargetId, @updatedBy, @allowedPaths)
+			ON CONFLICT (deployment_target_id) DO UPDATE SET
+				updated_at = current_timestamp,
+				updated_by_user_account_id = EXCLUDED.updated_by_user_account_id,
+				allowed_paths = EXCLUDED.allowed_paths
+			RETURNING`+deploymentTargetFileRetrievalAllowListOutputExpr,
+		pgx.NamedArgs{
+			"deploymentTargetId": allowList.DeploymentTargetID,
+			"updatedBy":          allowList.UpdatedByUserAccountID,
+			"allowedPaths":       allowList.AllowedPaths,
+		})
+	if err != nil {
+		return fmt.Errorf("could not save DeploymentTargetFileRetrievalAllowList: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetFileRetrievalAllowList],
+	); err != nil {
+		return fmt.Errorf("could not save DeploymentTargetFileRetrievalAllowList: %w", err)
+	} else {
+		*allowList = result
+		return nil
+	}
+}
+
+func CreateDeploymentTargetFileRetrieval(
+	ctx context.Context,
+	retrieval *types.DeploymentTargetFileRetrieval,
+	maxAge time.Duration,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`WITH r AS (
+			INSERT INTO DeploymentTargetFileRetrieval (deployment_target_id, requested_by_user_account_id, path)
+			VALUES (@deploymentTargetId, @requestedBy, @path)
+			RETURNING *
+		)
+		SELECT`+deploymentTargetFileRetrievalOutputExpr+`
+		FROM r
+		LEFT JOIN UserAccount u ON u.id = r.requested_by_user_account_id`,
+		pgx.NamedArgs{
+			"deploymentTargetId": retrieval.DeploymentTargetID,
+			"requestedBy":        retrieval.RequestedByUserAccountID,
+			"path":               retrieval.Path,
+			"maxAge":             maxAge,
+		})
+	if err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetFileRetrieval: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetFileRetrieval],
+	); err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetFileRetrieval: %w", err)
+	} else if err := decryptPII(result.RequestedBy); err != nil {
+		return err
+	} else {
+		*retrieval = result
+		return nil
+	}
+}
+
+// GetDeploymentTargetFileRetrievals returns all file retrievals of the deployment target without their content, the
+// newest first.
+func GetDeploymentTargetFileRetrievals(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	maxAge time.Duration,
+) ([]types.DeploymentTargetFileRetrieval, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetFileRetrievalOutputExpr+deploymentTargetFileRetrievalFromExpr+
+			"WHERE r.deployment_target_id = @deploymentTargetId "+
+			"ORDER BY r.created_at DESC",
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID, "maxAge": maxAge})
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetFileRetrieval: %w", err)
+	} else if result, err := pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.DeploymentTargetFileRetrieval],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetFileRetrieval: %w", err)
+	} else {
+		for i := range result {
+			if err := decryptPII(result[i].RequestedBy); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+}
+
+func GetDeploymentTargetFileRetrieval(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	id uuid.UUID,
+	maxAge time.Duration,
+) (*types.DeploymentTargetFileRetrievalWithContent, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetFileRetrievalOutputExpr+", r.content"+deploymentTargetFileRetrievalFromExpr+
+			"WHERE r.id = @id AND r.deployment_target_id = @deploymentTargetId",
+		pgx.NamedArgs{"id": id, "deploymentTargetId": deploymentTargetID, "maxAge": maxAge})
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetFileRetrieval: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentTargetFileRetrievalWithContent])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get DeploymentTargetFileRetrieval: %w", err)
+	} else if err := decryptPII(result.RequestedBy); err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// GetPendingDeploymentTargetFileRetrievals returns the file retrievals of the deployment target that have been
+// requested but not reported yet, the oldest first. Retrievals that are older than maxAge are not returned.
+func GetPendingDeploymentTargetFileRetrievals(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	maxAge time.Duration,
+) ([]types.DeploymentTargetFileRetrieval, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+deploymentTargetFileRetrievalOutputExpr+deploymentTargetFileRetrievalFromExpr+
+			"WHERE r.deployment_target_id = @deploymentTargetId "+
+			"AND r.status = 'pending' "+
+			"AND r.created_at >= current_timestamp - @maxAge::interval "+
+			"ORDER BY r.created_at",
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID, "maxAge": maxAge})
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetFileRetrieval: %w", err)
+	} else if result, err := pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.DeploymentTargetFileRetrieval],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetFileRetrieval: %w", err)
+	} else {
+		for i := range result {
+			if err := decryptPII(result[i].RequestedBy); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+}
+
+// ReportDeploymentTargetFileRetrieval stores the result of a pending file retrieval. Reporting a retrieval that does
+// not exist, has already been reported or is older than maxAge results in apierrors.ErrNotFound.
+func ReportDeploymentTargetFileRetrieval(
+	ctx context.Context,
+	result *types.DeploymentTargetFileRetrievalWithContent,
+	maxAge time.Duration,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`WITH r AS (
+			UPDATE DeploymentTargetFileRetrieval
+			SET status = @status, reported_at = current_timestamp, content = @content, size = @size,
+				redacted_secrets = @redactedSecrets, error = @error
+			WHERE id = @id AND deployment_target_id = @deploymentTargetId AND status = 'pending'
+				AND created_at >= current_timestamp - @maxAge::interval
+			RETURNING *
+		)
+		SELECT`+deploymentTargetFileRetrievalOutputExpr+`, r.content
+		FROM r
+		LEFT JOIN UserAccount u ON u.id = r.requested_by_user_account_id`,
+		pgx.NamedArgs{
+			"id":                 result.ID,
+			"deploymentTargetId": result.DeploymentTargetID,
+			"status":             result.Status,
+			"content":            result.Content,
+			"size":               result.Size,
+			"redactedSecrets":    result.RedactedSecrets,
+			"error":              result.Error,
+			"maxAge":             maxAge,
+		})
+	if err != nil {
+		return fmt.Errorf("could not update DeploymentTargetFileRetrieval: %w", err)
+	} else if updated, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetFileRetrievalWithContent],
+	); errors.Is(err, pgx.ErrNoRows) {
+		return apierrors.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not update DeploymentTargetFileRetrieval: %w", err)
+	} else if err := decryptPII(updated.RequestedBy); err != nil {
+		return err
+	} else {
+		*result = updated
+		return nil
+	}
+}
@@ -0,0 +1,127 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentTargetFileRetrievalAllowList(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+
+	allowList, err := db.GetDeploymentTargetFileRetrievalAllowList(ctx, target.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowList.AllowedPaths).To(BeEmpty())
+	g.Expect(allowList.UpdatedAt).To(BeNil())
+
+	allowList.AllowedPaths = []string{"/etc/app/*.conf"}
+	allowList.UpdatedByUserAccountID = &org.Vendors[0].ID
+	g.Expect(db.UpdateDeploymentTargetFileRetrievalAllowList(ctx, allowList)).To(Succeed())
+	allowList.AllowedPaths = []string{"/etc/app/*.conf", "/var/log/app/*.log"}
+	g.Expect(db.UpdateDeploymentTargetFileRetrievalAllowList(ctx, allowList)).To(Succeed())
+
+	allowList, err = db.GetDeploymentTargetFileRetrievalAllowList(ctx, target.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allowList.AllowedPaths).To(Equal([]string{"/etc/app/*.conf", "/var/log/app/*.log"}))
+	g.Expect(allowList.UpdatedAt).NotTo(BeNil())
+}
+
+func TestDeploymentTargetFileRetrieval(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendor := org.Vendors[0]
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID)
+	maxAge := 10 * time.Minute
+
+	retrieval := types.DeploymentTargetFileRetrieval{
+		DeploymentTargetID:       target.ID,
+		RequestedByUserAccountID: &vendor.ID,
+		Path:                     "/etc/app/app.conf",
+	}
+	g.Expect(db.CreateDeploymentTargetFileRetrieval(ctx, &retrieval, maxAge)).To(Succeed())
+	g.Expect(retrieval.Status).To(Equal(types.FileRetrievalStatusPending))
+	g.Expect(retrieval.RequestedBy).To(HaveValue(Equal(vendor.Email)))
+
+	pending, err := db.GetPendingDeploymentTargetFileRetrievals(ctx, target.ID, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(ConsistOf(HaveField("ID", retrieval.ID)))
+	all, err := db.GetDeploymentTargetFileRetrievals(ctx, target.ID, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(ConsistOf(HaveField("Status", types.FileRetrievalStatusPending)))
+
+	result := types.DeploymentTargetFileRetrievalWithContent{
+		DeploymentTargetFileRetrieval: types.DeploymentTargetFileRetrieval{
+			ID:                 retrieval.ID,
+			DeploymentTargetID: target.ID,
+			Status:             types.FileRetrievalStatusCompleted,
+			Size:               util.PtrTo(int64(11)),
+			RedactedSecrets:    util.PtrTo(0),
+		},
+		Content: []byte("port = 8080"),
+	}
+	g.Expect(db.ReportDeploymentTargetFileRetrieval(ctx, &result, maxAge)).To(Succeed())
+	g.Expect(result.Path).To(Equal(retrieval.Path))
+	g.Expect(result.ReportedAt).NotTo(BeNil())
+	g.Expect(db.ReportDeploymentTargetFileRetrieval(ctx, &result, maxAge)).
+		To(MatchError(apierrors.ErrNotFound), "already reported")
+
+	pending, err = db.GetPendingDeploymentTargetFileRetrievals(ctx, target.ID, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeEmpty())
+	stored, err := db.GetDeploymentTargetFileRetrieval(ctx, target.ID, retrieval.ID, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored.Status).To(Equal(types.FileRetrievalStatusCompleted))
+	g.Expect(stored.Content).To(Equal([]byte("port = 8080")))
+}
+
+func TestDeploymentTargetFileRetrievalEncryptedRequester(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	withPIIKeys(t, piiKey1)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendor := org.Vendors[0]
+	email, _, _ := storedUserAccount(ctx, t, vendor.ID)
+	g.Expect(pii.IsEncrypted(email)).To(BeTrue())
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID)
+	maxAge := 10 * time.Minute
+
+	retrieval := types.DeploymentTargetFileRetrieval{
+		DeploymentTargetID:       target.ID,
+		RequestedByUserAccountID: &vendor.ID,
+		Path:                     "/etc/app/app.conf",
+	}
+	g.Expect(db.CreateDeploymentTargetFileRetrieval(ctx, &retrieval, maxAge)).To(Succeed())
+	g.Expect(retrieval.RequestedBy).To(HaveValue(Equal(vendor.Email)))
+
+	all, err := db.GetDeploymentTargetFileRetrievals(ctx, target.ID, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(ConsistOf(HaveField("RequestedBy", HaveValue(Equal(vendor.Email)))))
+	pending, err := db.GetPendingDeploymentTargetFileRetrievals(ctx, target.ID, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(ConsistOf(HaveField("RequestedBy", HaveValue(Equal(vendor.Email)))))
+
+	result := types.DeploymentTargetFileRetrievalWithContent{
+		DeploymentTargetFileRetrieval: types.DeploymentTargetFileRetrieval{
+			ID:                 retrieval.ID,
+			DeploymentTargetID: target.ID,
+			Status:             types.FileRetrievalStatusDenied,
+			Error:              util.PtrTo("path is not in the allow-list"),
+		},
+	}
+	g.Expect(db.ReportDeploymentTargetFileRetrieval(ctx, &result, maxAge)).To(Succeed())
+	g.Expect(result.RequestedBy).To(HaveValue(Equal(vendor.Email)))
+	stored, err := db.GetDeploymentTargetFileRetrieval(ctx, target.ID, retrieval.ID, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored.RequestedBy).To(HaveValue(Equal(vendor.Email)))
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const deploymentTargetInventoryOutputExpr = `
+	i.deployment_target_id,
+	i.reported_at,
+	i.previous_reported_at,
+	i.items,
+	i.truncated,
+	i.diff
+`
+
+// SaveDeploymentTargetInventory replaces the inventory snapshot of a deployment target. The difference to the
+// previous snapshot, if one exists, is computed and stored with the new snapshot.
+func SaveDeploymentTargetInventory(ctx context.Context, inventory *types.DeploymentTargetInventory) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		args := pgx.NamedArgs{
+			"deploymentTargetId": inventory.DeploymentTargetID,
+			"items":              inventory.Items,
+			"truncated":          inventory.Truncated,
+		}
+		rows, err := db.Query(ctx,
+			`SELECT`+deploymentTargetInventoryOutputExpr+`
+			FROM DeploymentTargetInventory i
+			WHERE i.deployment_target_id = @deploymentTargetId
+			FOR UPDATE`,
+			args,
+		)
+		if err != nil {
+			return fmt.Errorf("could not query DeploymentTargetInventory: %w", err)
+		}
+		previous, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentTargetInventory])
+		if err == nil {
+			diff := types.DiffInventory(previous.Items, inventory.Items)
+			args["diff"] = diff
+			args["previousReportedAt"] = previous.ReportedAt
+		} else if errors.Is(err, pgx.ErrNoRows) {
+			args["diff"] = nil
+			args["previousReportedAt"] = nil
+		} else {
+			return fmt.Errorf("could not collect DeploymentTargetInventory: %w", err)
+		}
+
+		rows, err = db.Query(ctx,
+			`INSERT INTO DeploymentTargetInventory AS i
+				(deployment_target_id, previous_reported_at, items, truncated, diff)
+			VALUES (@deploymentTargetId, @previousReportedAt, @items, @truncated, @diff)
+			ON CONFLICT (deployment_target_id) DO UPDATE SET
+				reported_at = current_timestamp,
+				previous_reported_at = excluded.previous_reported_at,
+				items = excluded.items,
+				truncated = excluded.truncated,
+				diff = excluded.diff
+			RETURNING`+deploymentTargetInventoryOutputExpr,
+			args,
+		)
+		if err != nil {
+			return fmt.Errorf("could not save DeploymentTargetInventory: %w", err)
+		} else if result, err := pgx.CollectExactlyOneRow(
+			rows, pgx.RowToStructByName[types.DeploymentTargetInventory],
+		); err != nil {
+			return fmt.Errorf("could not collect DeploymentTargetInventory: %w", err)
+		} else {
+			*inventory = result
+			return nil
+		}
+	})
+}
+
+// GetDeploymentTargetInventory returns the latest inventory snapshot of a deployment target or apierrors.ErrNotFound
+// if the agent has not reported one yet.
+func GetDeploymentTargetInventory(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+) (*types.DeploymentTargetInventory, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+deploymentTargetInventoryOutputExpr+`
+		FROM DeploymentTargetInventory i
+		WHERE i.deployment_target_id = @deploymentTargetId`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetInventory: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.DeploymentTargetInventory])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetInventory: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentTargetInventory(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+
+	_, err := db.GetDeploymentTargetInventory(ctx, dt.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	app := types.InventoryItem{Kind: "container", Name: "app", Images: []string{"app:1"}, State: "running", Managed: true}
+	db1 := types.InventoryItem{Kind: "container", Name: "db", Images: []string{"postgres:17"}, State: "running"}
+	first := types.DeploymentTargetInventory{DeploymentTargetID: dt.ID, Items: []types.InventoryItem{app, db1}}
+	g.Expect(db.SaveDeploymentTargetInventory(ctx, &first)).To(Succeed())
+	g.Expect(first.Diff).To(BeNil())
+	g.Expect(first.PreviousReportedAt).To(BeNil())
+
+	updatedApp := app
+	updatedApp.Images = []string{"app:2"}
+	cache := types.InventoryItem{Kind: "container", Name: "cache", Images: []string{"valkey:8"}, State: "exited"}
+	second := types.DeploymentTargetInventory{
+		DeploymentTargetID: dt.ID,
+		Items:              []types.InventoryItem{updatedApp, cache},
+		Truncated:          true,
+	}
+	g.Expect(db.SaveDeploymentTargetInventory(ctx, &second)).To(Succeed())
+	g.Expect(second.PreviousReportedAt).To(HaveValue(BeTemporally("~", first.ReportedAt)))
+	g.Expect(second.Diff).To(HaveValue(Equal(types.InventoryDiff{
+		Added:   []types.InventoryItem{cache},
+		Removed: []types.InventoryItem{db1},
+		Changed: []types.InventoryItem{updatedApp},
+	})))
+
+	loaded, err := db.GetDeploymentTargetInventory(ctx, dt.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.Items).To(Equal(second.Items))
+	g.Expect(loaded.Truncated).To(BeTrue())
+	loaded.FilterManaged(false)
+	g.Expect(loaded.Items).To(ConsistOf(cache))
+	g.Expect(loaded.Diff.Changed).To(BeEmpty())
+
+	purge := types.DeploymentTargetDataPurge{
+		DeploymentTargetID:       dt.ID,
+		RequestedByUserAccountID: util.PtrTo(org.Vendors[0].ID),
+		Inventory:                true,
+	}
+	g.Expect(db.CreateDeploymentTargetDataPurge(ctx, &purge)).To(Succeed())
+	g.Expect(db.ExecuteDeploymentTargetDataPurge(ctx, &purge)).To(Equal(int64(1)))
+	_, err = db.GetDeploymentTargetInventory(ctx, dt.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
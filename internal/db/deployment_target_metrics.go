@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/types"
@@ -22,12 +24,12 @@ func GetLatestDeploymentTargetMetrics(
 ) ([]DeploymentTargetLatestMetrics, error) {
 	db := internalctx.GetDb(ctx)
 	if rows, err := db.Query(ctx,
-		`SELECT dt.id, dtm.cpu_cores_millis, dtm.cpu_usage, dtm.memory_bytes, dtm.memory_usage FROM
+		`SELECT dt.id, dtm.cpu_cores_millis, dtm.cpu_usage, dtm.memory_bytes, dtm.memory_usage, dtm.disk_bytes FROM
 			DeploymentTarget dt
 			LEFT JOIN UserAccount u
 				ON dt.created_by_user_account_id = u.id
 			LEFT JOIN Organization_UserAccount j
-				ON u.id = j.user_account_id
+				ON u.id = j.user_account_id AND j.organization_id = dt.organization_id
 			LEFT JOIN (
 				-- copied from getting deployment target latest status:
 				-- find the creation date of the latest status entry for each deployment target
@@ -45,6 +47,7 @@ func GetLatestDeploymentTargetMetrics(
 			WHERE dt.organization_id = @orgId
 			AND (dt.created_by_user_account_id = @userId OR @userRole = 'vendor')
 			AND dt.metrics_enabled = true
+			AND dt.archived_at IS NULL
 			ORDER BY u.name, u.email, dt.name`,
 		pgx.NamedArgs{"orgId": orgID, "userId": userID, "userRole": userRole},
 	); err != nil {
@@ -59,6 +62,32 @@ func GetLatestDeploymentTargetMetrics(
 	}
 }
 
+// GetLatestDeploymentTargetMetricsByID returns the most recent metrics reported for a deployment target or
+// apierrors.ErrNotFound if none have been reported yet.
+func GetLatestDeploymentTargetMetricsByID(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+) (*api.AgentDeploymentTargetMetrics, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT dtm.cpu_cores_millis, dtm.cpu_usage, dtm.memory_bytes, dtm.memory_usage, dtm.disk_bytes
+			FROM DeploymentTargetMetrics dtm
+			WHERE dtm.deployment_target_id = @deploymentTargetId
+			ORDER BY dtm.created_at DESC
+			LIMIT 1`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTargetMetrics: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[api.AgentDeploymentTargetMetrics])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentTargetMetrics: %w", err)
+	}
+	return &result, nil
+}
+
 func CreateDeploymentTargetMetrics(
 	ctx context.Context,
 	dt *types.DeploymentTarget,
@@ -67,14 +96,15 @@ func CreateDeploymentTargetMetrics(
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
 		"INSERT INTO DeploymentTargetMetrics "+
-			"(deployment_target_id, cpu_cores_millis, cpu_usage, memory_bytes, memory_usage) "+
-			"VALUES (@deploymentTargetId, @cpuCoresMillis, @cpuUsage, @memoryBytes, @memoryUsage)",
+			"(deployment_target_id, cpu_cores_millis, cpu_usage, memory_bytes, memory_usage, disk_bytes) "+
+			"VALUES (@deploymentTargetId, @cpuCoresMillis, @cpuUsage, @memoryBytes, @memoryUsage, @diskBytes)",
 		pgx.NamedArgs{
 			"deploymentTargetId": dt.ID,
 			"cpuCoresMillis":     metrics.CPUCoresMillis,
 			"cpuUsage":           metrics.CPUUsage,
 			"memoryBytes":        metrics.MemoryBytes,
 			"memoryUsage":        metrics.MemoryUsage,
+			"diskBytes":          metrics.DiskBytes,
 		})
 	if err != nil {
 		return err
@@ -101,7 +131,8 @@ func CleanupDeploymentTargetMetrics(ctx context.Context) (int64, error) {
 		) max_created_at
 		WHERE dtm.deployment_target_id = max_created_at.deployment_target_id
 			AND dtm.created_at < max_created_at.max_created_at
-			AND current_timestamp - dtm.created_at > @metricsEntriesMaxAge`,
+			AND current_timestamp - dtm.created_at > @metricsEntriesMaxAge
+			AND NOT `+legalHoldOfDeploymentTargetExpr("dtm.deployment_target_id"),
 		pgx.NamedArgs{"metricsEntriesMaxAge": env.MetricsEntriesMaxAge()},
 	); err != nil {
 		return 0, err
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const deploymentTargetOutageOutputExpr = `
+	o.id, o.created_at, o.organization_id, o.customer_user_account_id, o.target_count,
+	o.affected_deployment_target_ids, o.resolved_at
+`
+
+// GetDeploymentTargetConnectivity aggregates the non-archived deployment targets that reported their status between
+// since and now in a single query, once per organization and once per customer that owns deployment targets. A
+// deployment target is stale if its latest status is older than one minute at now.
+//
+// The aggregates of organizations come before the aggregates of their customers.
+func GetDeploymentTargetConnectivity(
+	ctx context.Context,
+	now time.Time,
+	since time.Time,
+) ([]types.DeploymentTargetConnectivity, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		WITH seen AS (
+			SELECT
+				dt.id,
+				dt.organization_id,
+				CASE WHEN j.user_role = 'customer' THEN dt.created_by_user_account_id END AS customer_user_account_id,
+				`+deploymentTargetLastSeenExpr+` AS last_seen
+			FROM DeploymentTarget dt
+				LEFT JOIN Organization_UserAccount j
+					ON dt.created_by_user_account_id = j.user_account_id AND dt.organization_id = j.organization_id
+			WHERE dt.archived_at IS NULL
+		)
+		SELECT
+			organization_id,
+			customer_user_account_id,
+			count(*) AS target_count,
+			coalesce(
+				array_agg(id) FILTER (WHERE last_seen < @now::TIMESTAMP - INTERVAL '1 minute'), '{}'
+			) AS stale_deployment_target_ids
+		FROM seen
+		WHERE last_seen >= @since
+		GROUP BY GROUPING SETS ((organization_id), (organization_id, customer_user_account_id))
+		-- targets of vendors only count towards the organization
+		HAVING GROUPING(customer_user_account_id) = 1 OR customer_user_account_id IS NOT NULL
+		ORDER BY organization_id, customer_user_account_id NULLS FIRST`,
+		pgx.NamedArgs{"now": now, "since": since},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTarget connectivity: %w", err)
+	} else if result, err := pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.DeploymentTargetConnectivity],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTarget connectivity: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetOpenDeploymentTargetOutages returns all outages that have not been resolved, together with the number of their
+// affected deployment targets that are still stale at now.
+func GetOpenDeploymentTargetOutages(ctx context.Context, now time.Time) ([]types.OpenDeploymentTargetOutage, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT `+deploymentTargetOutageOutputExpr+`, (
+			SELECT count(*) FROM DeploymentTarget dt
+			WHERE dt.id = ANY (o.affected_deployment_target_ids)
+				AND dt.archived_at IS NULL
+				AND `+deploymentTargetLastSeenExpr+` < @now::TIMESTAMP - INTERVAL '1 minute'
+		) AS stale_count
+		FROM DeploymentTargetOutage o
+		WHERE o.resolved_at IS NULL
+		ORDER BY o.created_at`,
+		pgx.NamedArgs{"now": now},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetOutage: %w", err)
+	} else if result, err := pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.OpenDeploymentTargetOutage],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetOutage: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// CreateDeploymentTargetOutage records a suspected outage. apierrors.ErrConflict is returned if there already is an
+// open outage for the same scope.
+func CreateDeploymentTargetOutage(ctx context.Context, outage *types.DeploymentTargetOutage) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO DeploymentTargetOutage AS o
+			(organization_id, customer_user_account_id, target_count, affected_deployment_target_ids)
+		VALUES (@organizationId, @customerUserAccountId, @targetCount, @affectedDeploymentTargetIds)
+		RETURNING `+deploymentTargetOutageOutputExpr,
+		pgx.NamedArgs{
+			"organizationId":              outage.OrganizationID,
+			"customerUserAccountId":       outage.CustomerUserAccountID,
+			"targetCount":                 outage.TargetCount,
+			"affectedDeploymentTargetIds": outage.AffectedDeploymentTargetIDs,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetOutage: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetOutage],
+	); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrConflict, err)
+		}
+		return fmt.Errorf("could not insert DeploymentTargetOutage: %w", err)
+	} else {
+		*outage = result
+		return nil
+	}
+}
+
+// ResolveDeploymentTargetOutage marks the outage with the given ID as resolved at now. apierrors.ErrConflict is
+// returned if it has already been resolved.
+func ResolveDeploymentTargetOutage(ctx context.Context, id uuid.UUID, now time.Time) error {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		`UPDATE DeploymentTargetOutage SET resolved_at = @now WHERE id = @id AND resolved_at IS NULL`,
+		pgx.NamedArgs{"id": id, "now": now},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTargetOutage: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrConflict
+	}
+	return nil
+}
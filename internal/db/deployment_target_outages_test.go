@@ -0,0 +1,97 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentTargetOutages(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	customer := org.Customers[0].ID
+	vendorTarget := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	customerTargets := []*types.DeploymentTargetWithCreatedBy{
+		testutil.NewDeploymentTarget(ctx, t, org.ID, customer),
+		testutil.NewDeploymentTarget(ctx, t, org.ID, customer),
+	}
+	// never connected deployment targets are not considered
+	testutil.NewDeploymentTarget(ctx, t, org.ID, customer)
+	for _, dt := range append(customerTargets, vendorTarget) {
+		g.Expect(db.CreateDeploymentTargetStatus(ctx, &dt.DeploymentTarget, "ok")).To(Succeed())
+	}
+	// all statements of the test share the same current_timestamp, which is the creation time of the statuses
+	target, err := db.GetDeploymentTarget(ctx, vendorTarget.ID, &org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	base := target.CurrentStatus.CreatedAt
+	window := 15 * time.Minute
+
+	ofOrg := func(now time.Time) []types.DeploymentTargetConnectivity {
+		connectivity, err := db.GetDeploymentTargetConnectivity(ctx, now, now.Add(-window))
+		g.Expect(err).NotTo(HaveOccurred())
+		var result []types.DeploymentTargetConnectivity
+		for _, c := range connectivity {
+			if c.OrganizationID == org.ID {
+				result = append(result, c)
+			}
+		}
+		return result
+	}
+
+	g.Expect(ofOrg(base)).To(HaveExactElements(
+		And(HaveField("CustomerUserAccountID", BeNil()), HaveField("TargetCount", 3),
+			HaveField("StaleDeploymentTargetIDs", BeEmpty())),
+		And(HaveField("CustomerUserAccountID", HaveValue(Equal(customer))), HaveField("TargetCount", 2),
+			HaveField("StaleDeploymentTargetIDs", BeEmpty())),
+	))
+	g.Expect(ofOrg(base.Add(5 * time.Minute))).To(HaveExactElements(
+		And(HaveField("CustomerUserAccountID", BeNil()), HaveField("TargetCount", 3),
+			HaveField("StaleDeploymentTargetIDs", ConsistOf(vendorTarget.ID, customerTargets[0].ID,
+				customerTargets[1].ID))),
+		And(HaveField("CustomerUserAccountID", HaveValue(Equal(customer))), HaveField("TargetCount", 2),
+			HaveField("StaleDeploymentTargetIDs", ConsistOf(customerTargets[0].ID, customerTargets[1].ID))),
+	))
+	// deployment targets that have been stale for longer than the window are not considered
+	g.Expect(ofOrg(base.Add(time.Hour))).To(BeEmpty())
+
+	outage := types.DeploymentTargetOutage{
+		OrganizationID:              org.ID,
+		TargetCount:                 3,
+		AffectedDeploymentTargetIDs: []uuid.UUID{vendorTarget.ID, customerTargets[0].ID, customerTargets[1].ID},
+	}
+	g.Expect(db.CreateDeploymentTargetOutage(ctx, &outage)).To(Succeed())
+	g.Expect(outage.ID).NotTo(Equal(uuid.Nil))
+	duplicate := types.DeploymentTargetOutage{OrganizationID: org.ID, AffectedDeploymentTargetIDs: []uuid.UUID{}}
+	g.Expect(db.CreateDeploymentTargetOutage(ctx, &duplicate)).To(MatchError(apierrors.ErrConflict))
+	customerOutage := types.DeploymentTargetOutage{
+		OrganizationID:              org.ID,
+		CustomerUserAccountID:       &customer,
+		TargetCount:                 2,
+		AffectedDeploymentTargetIDs: []uuid.UUID{customerTargets[0].ID, customerTargets[1].ID},
+	}
+	g.Expect(db.CreateDeploymentTargetOutage(ctx, &customerOutage)).To(Succeed())
+
+	open, err := db.GetOpenDeploymentTargetOutages(ctx, base.Add(5*time.Minute))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(open).To(ContainElements(
+		And(HaveField("ID", outage.ID), HaveField("StaleCount", 3)),
+		And(HaveField("ID", customerOutage.ID), HaveField("StaleCount", 2)),
+	))
+	open, err = db.GetOpenDeploymentTargetOutages(ctx, base)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(open).To(ContainElement(And(HaveField("ID", outage.ID), HaveField("StaleCount", 0))))
+
+	g.Expect(db.ResolveDeploymentTargetOutage(ctx, outage.ID, base)).To(Succeed())
+	g.Expect(db.ResolveDeploymentTargetOutage(ctx, outage.ID, base)).To(MatchError(apierrors.ErrConflict))
+	open, err = db.GetOpenDeploymentTargetOutages(ctx, base)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(open).NotTo(ContainElement(HaveField("ID", outage.ID)))
+	g.Expect(open).To(ContainElement(HaveField("ID", customerOutage.ID)))
+}
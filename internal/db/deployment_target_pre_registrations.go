@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const deploymentTargetPreRegistrationOutputExpr = `
+	pr.deployment_target_id,
+	pr.created_at,
+	pr.created_by_user_account_id,
+	pr.contact_email,
+	pr.application_version_id,
+	pr.pairing_token_salt,
+	pr.pairing_token_hash,
+	pr.pairing_token_expires_at,
+	pr.paired_at,
+	pr.connected_at,
+	pr.reminded_at,
+	pr.reminder_count,
+	pr.expires_at
+`
+
+// CreateDeploymentTargetPreRegistration stores the pre-registration of a deployment target that has just been
+// created. The contact email is encrypted with the key of the organization if PII encryption is configured.
+func CreateDeploymentTargetPreRegistration(
+	ctx context.Context,
+	preRegistration *types.DeploymentTargetPreRegistration,
+	orgID uuid.UUID,
+) error {
+	contactEmail, err := pii.Default().Encrypt(orgID, preRegistration.ContactEmail)
+	if err != nil {
+		return fmt.Errorf("could not encrypt contact email: %w", err)
+	}
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO DeploymentTargetPreRegistration AS pr (
+			deployment_target_id, created_by_user_account_id, contact_email, application_version_id,
+			pairing_token_salt, pairing_token_hash, pairing_token_expires_at, expires_at
+		)
+		VALUES (
+			@deploymentTargetId, @createdBy, @contactEmail, @applicationVersionId,
+			@pairingTokenSalt, @pairingTokenHash, @pairingTokenExpiresAt, @expiresAt
+		)
+		RETURNING`+deploymentTargetPreRegistrationOutputExpr,
+		pgx.NamedArgs{
+			"deploymentTargetId":    preRegistration.DeploymentTargetID,
+			"createdBy":             preRegistration.CreatedByUserAccountID,
+			"contactEmail":          contactEmail,
+			"applicationVersionId":  preRegistration.ApplicationVersionID,
+			"pairingTokenSalt":      preRegistration.PairingTokenSalt,
+			"pairingTokenHash":      preRegistration.PairingTokenHash,
+			"pairingTokenExpiresAt": preRegistration.PairingTokenExpiresAt,
+			"expiresAt":             preRegistration.ExpiresAt,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetPreRegistration: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetPreRegistration],
+	); err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetPreRegistration: %w", err)
+	} else {
+		result.ContactEmail = preRegistration.ContactEmail
+		*preRegistration = result
+		return nil
+	}
+}
+
+// GetDeploymentTargetPreRegistration returns apierrors.ErrNotFound if the deployment target has not been
+// pre-registered.
+func GetDeploymentTargetPreRegistration(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+) (*types.DeploymentTargetPreRegistration, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+deploymentTargetPreRegistrationOutputExpr+`
+		FROM DeploymentTargetPreRegistration pr
+		WHERE pr.deployment_target_id = @deploymentTargetId`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetPreRegistration: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetPreRegistration],
+	); errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetPreRegistration: %w", err)
+	} else if err := decryptPII(&result.ContactEmail); err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// RedeemDeploymentTargetPairingToken removes the pairing token with the given hash so that it can not be used again.
+// It returns apierrors.ErrNotFound if the token has already been redeemed or replaced in the meantime.
+func RedeemDeploymentTargetPairingToken(ctx context.Context, deploymentTargetID uuid.UUID, hash []byte) error {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		`UPDATE DeploymentTargetPreRegistration
+		SET pairing_token_salt = NULL, pairing_token_hash = NULL, paired_at = now()
+		WHERE deployment_target_id = @deploymentTargetId AND pairing_token_hash = @hash`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID, "hash": hash},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTargetPreRegistration: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	} else {
+		return nil
+	}
+}
+
+// RenewDeploymentTargetPairingToken replaces the pairing token of a pre-registered deployment target, which
+// invalidates the previous token. If reminded is true, the renewal is counted as a reminder.
+func RenewDeploymentTargetPairingToken(
+	ctx context.Context,
+	preRegistration *types.DeploymentTargetPreRegistration,
+	reminded bool,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE DeploymentTargetPreRegistration AS pr
+		SET pairing_token_salt = @pairingTokenSalt,
+			pairing_token_hash = @pairingTokenHash,
+			pairing_token_expires_at = @pairingTokenExpiresAt,
+			reminded_at = CASE WHEN @reminded THEN now() ELSE reminded_at END,
+			reminder_count = reminder_count + CASE WHEN @reminded THEN 1 ELSE 0 END
+		WHERE pr.deployment_target_id = @deploymentTargetId
+		RETURNING`+deploymentTargetPreRegistrationOutputExpr,
+		pgx.NamedArgs{
+			"deploymentTargetId":    preRegistration.DeploymentTargetID,
+			"pairingTokenSalt":      preRegistration.PairingTokenSalt,
+			"pairingTokenHash":      preRegistration.PairingTokenHash,
+			"pairingTokenExpiresAt": preRegistration.PairingTokenExpiresAt,
+			"reminded":              reminded,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update DeploymentTargetPreRegistration: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.DeploymentTargetPreRegistration],
+	); errors.Is(err, pgx.ErrNoRows) {
+		return apierrors.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not update DeploymentTargetPreRegistration: %w", err)
+	} else {
+		result.ContactEmail = preRegistration.ContactEmail
+		*preRegistration = result
+		return nil
+	}
+}
+
+// MarkDeploymentTargetPreRegistrationConnected records the first check-in of the agent of a pre-registered deployment
+// target. Any pairing token that has not been redeemed yet is invalidated.
+func MarkDeploymentTargetPreRegistrationConnected(ctx context.Context, deploymentTargetID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE DeploymentTargetPreRegistration
+		SET connected_at = now(), pairing_token_salt = NULL, pairing_token_hash = NULL
+		WHERE deployment_target_id = @deploymentTargetId AND connected_at IS NULL`,
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTargetPreRegistration: %w", err)
+	}
+	return nil
+}
+
+// GetDueDeploymentTargetPreRegistrationReminders returns the pre-registrations of deployment targets that are neither
+// connected, archived nor expired, that have not been reminded since remindBefore and have been reminded less than
+// maxReminders times.
+func GetDueDeploymentTargetPreRegistrationReminders(
+	ctx context.Context,
+	remindBefore time.Time,
+	maxReminders int,
+) ([]types.DeploymentTargetPreRegistration, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+deploymentTargetPreRegistrationOutputExpr+`
+		FROM DeploymentTargetPreRegistration pr
+		JOIN DeploymentTarget dt ON dt.id = pr.deployment_target_id
+		WHERE pr.connected_at IS NULL
+			AND dt.archived_at IS NULL
+			AND pr.expires_at > now()
+			AND pr.reminder_count < @maxReminders
+			AND coalesce(pr.reminded_at, pr.created_at) < @remindBefore
+		ORDER BY coalesce(pr.reminded_at, pr.created_at)`,
+		pgx.NamedArgs{"remindBefore": remindBefore, "maxReminders": maxReminders},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTargetPreRegistration: %w", err)
+	} else if result, err := pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.DeploymentTargetPreRegistration],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTargetPreRegistration: %w", err)
+	} else {
+		for i := range result {
+			if err := decryptPII(&result[i].ContactEmail); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+}
+
+// DeleteExpiredPreRegisteredDeploymentTargets deletes all pre-registered deployment targets that have not connected
+// before their expiry. It returns the number of deleted deployment targets.
+func DeleteExpiredPreRegisteredDeploymentTargets(ctx context.Context) (int64, error) {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		`DELETE FROM DeploymentTarget dt
+		USING DeploymentTargetPreRegistration pr
+		WHERE pr.deployment_target_id = dt.id AND pr.connected_at IS NULL AND pr.expires_at <= now()`,
+	); err != nil {
+		return 0, fmt.Errorf("could not delete DeploymentTarget: %w", err)
+	} else {
+		return cmd.RowsAffected(), nil
+	}
+}
@@ -0,0 +1,105 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/preregistration"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentTargetPreRegistration(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	vendor := org.Vendors[0]
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Customers[0].ID)
+	g.Expect(target.AwaitingConnection).To(BeFalse())
+
+	preRegistration := types.DeploymentTargetPreRegistration{
+		DeploymentTargetID:     target.ID,
+		CreatedByUserAccountID: util.PtrTo(vendor.ID),
+		ContactEmail:           "ops@example.com",
+		ExpiresAt:              time.Now().Add(time.Hour),
+	}
+	_, err := preregistration.NewPairingToken(&preRegistration, time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.CreateDeploymentTargetPreRegistration(ctx, &preRegistration, org.ID)).To(Succeed())
+	g.Expect(preRegistration.ContactEmail).To(Equal("ops@example.com"))
+
+	dt, err := db.GetDeploymentTarget(ctx, target.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dt.AwaitingConnection).To(BeTrue())
+
+	hash := *preRegistration.PairingTokenHash
+	g.Expect(db.RedeemDeploymentTargetPairingToken(ctx, target.ID, hash)).To(Succeed())
+	g.Expect(db.RedeemDeploymentTargetPairingToken(ctx, target.ID, hash)).To(MatchError(apierrors.ErrNotFound),
+		"the pairing token is single-use")
+	stored, err := db.GetDeploymentTargetPreRegistration(ctx, target.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored.PairedAt).NotTo(BeNil())
+	g.Expect(stored.PairingTokenHash).To(BeNil())
+	g.Expect(stored.ContactEmail).To(Equal("ops@example.com"))
+
+	due, err := db.GetDueDeploymentTargetPreRegistrationReminders(ctx, time.Now().Add(time.Minute), 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).To(ContainElement(HaveField("DeploymentTargetID", target.ID)))
+	_, err = preregistration.NewPairingToken(&preRegistration, time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.RenewDeploymentTargetPairingToken(ctx, &preRegistration, true)).To(Succeed())
+	g.Expect(preRegistration.ReminderCount).To(Equal(1))
+	g.Expect(preRegistration.PairingTokenHash).NotTo(BeNil())
+	due, err = db.GetDueDeploymentTargetPreRegistrationReminders(ctx, time.Now().Add(time.Minute), 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).NotTo(ContainElement(HaveField("DeploymentTargetID", target.ID)), "maximum number of reminders")
+
+	g.Expect(db.MarkDeploymentTargetPreRegistrationConnected(ctx, target.ID)).To(Succeed())
+	dt, err = db.GetDeploymentTarget(ctx, target.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dt.AwaitingConnection).To(BeFalse())
+	stored, err = db.GetDeploymentTargetPreRegistration(ctx, target.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored.ConnectedAt).NotTo(BeNil())
+	g.Expect(stored.PairingTokenHash).To(BeNil(), "the renewed pairing token is invalidated by the connection")
+}
+
+func TestDeleteExpiredPreRegisteredDeploymentTargets(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 0, 1)
+	customer := org.Customers[0]
+	expired := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	connected := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	pending := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	for _, tc := range []struct {
+		target    *types.DeploymentTargetWithCreatedBy
+		expiresAt time.Time
+	}{
+		{expired, time.Now().Add(-time.Minute)},
+		{connected, time.Now().Add(-time.Minute)},
+		{pending, time.Now().Add(time.Hour)},
+	} {
+		g.Expect(db.CreateDeploymentTargetPreRegistration(ctx, &types.DeploymentTargetPreRegistration{
+			DeploymentTargetID:    tc.target.ID,
+			ContactEmail:          customer.Email,
+			PairingTokenExpiresAt: time.Now(),
+			ExpiresAt:             tc.expiresAt,
+		}, org.ID)).To(Succeed())
+	}
+	g.Expect(db.MarkDeploymentTargetPreRegistrationConnected(ctx, connected.ID)).To(Succeed())
+
+	deleted, err := db.DeleteExpiredPreRegisteredDeploymentTargets(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeNumerically(">=", 1))
+	_, err = db.GetDeploymentTarget(ctx, expired.ID, nil)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	for _, target := range []*types.DeploymentTargetWithCreatedBy{connected, pending} {
+		_, err = db.GetDeploymentTarget(ctx, target.ID, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+}
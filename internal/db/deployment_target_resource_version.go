@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// HasDeploymentTargetScheduledResourceChange reports whether the agent resource of the deployment target changed
+// between since and now without a change of its resource version. This is the case if an announcement started or ended
+// or a pending connectivity check or file retrieval expired after connectivityCheckMaxAge or fileRetrievalMaxAge.
+func HasDeploymentTargetScheduledResourceChange(
+	ctx context.Context,
+	deploymentTargetID uuid.UUID,
+	since, now time.Time,
+	connectivityCheckMaxAge, fileRetrievalMaxAge time.Duration,
+) (bool, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM Announcement a
+			JOIN Announcement_DeploymentTarget t ON t.announcement_id = a.id
+			WHERE t.deployment_target_id = @deploymentTargetId
+				AND (a.starts_at > @since AND a.starts_at <= @now OR a.ends_at > @since AND a.ends_at <= @now)
+		) OR EXISTS (
+			SELECT 1 FROM DeploymentTargetConnectivityCheck c
+			WHERE c.deployment_target_id = @deploymentTargetId
+				AND c.reported_at IS NULL
+				AND c.requested_at + @maxAge::interval > @since
+				AND c.requested_at + @maxAge::interval <= @now
+		) OR EXISTS (
+			SELECT 1 FROM DeploymentTargetFileRetrieval r
+			WHERE r.deployment_target_id = @deploymentTargetId
+				AND r.status = 'pending'
+				AND r.created_at + @fileRetrievalMaxAge::interval > @since
+				AND r.created_at + @fileRetrievalMaxAge::interval <= @now
+		)`,
+		pgx.NamedArgs{
+			"deploymentTargetId":  deploymentTargetID,
+			"since":               since,
+			"now":                 now,
+			"maxAge":              connectivityCheckMaxAge,
+			"fileRetrievalMaxAge": fileRetrievalMaxAge,
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not query scheduled resource changes: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[bool]); err != nil {
+		return false, fmt.Errorf("could not get scheduled resource changes: %w", err)
+	} else {
+		return result, nil
+	}
+}
@@ -0,0 +1,175 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeploymentTargetResourceVersion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendor := org.Vendors[0]
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID)
+
+	version := target.ResourceVersion
+	expectChange := func(description string, change func(ctx context.Context) error) {
+		t.Helper()
+		g.Expect(change(ctx)).To(Succeed(), description)
+		dt, err := db.GetDeploymentTarget(ctx, target.ID, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(dt.ResourceVersion).To(BeNumerically(">", version), description)
+		version = dt.ResourceVersion
+	}
+
+	var revision *types.DeploymentRevision
+	expectChange("create deployment", func(ctx context.Context) error {
+		revision = testutil.NewDeploymentRevision(ctx, t, target)
+		return nil
+	})
+	expectChange("create deployment revision", func(ctx context.Context) error {
+		_, err := db.CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+			DeploymentID:         &revision.DeploymentID,
+			DeploymentTargetID:   target.ID,
+			ApplicationVersionID: revision.ApplicationVersionID,
+		})
+		return err
+	})
+	expectChange("update deployment", func(ctx context.Context) error {
+		deployment, err := db.GetDeployment(ctx, revision.DeploymentID, vendor.ID, org.ID, types.UserRoleVendor)
+		if err != nil {
+			return err
+		}
+		deployment.LogsEnabled = !deployment.LogsEnabled
+		return db.UpdateDeployment(ctx, deployment)
+	})
+	expectChange("update application version", func(ctx context.Context) error {
+		av, err := db.GetApplicationVersion(ctx, revision.ApplicationVersionID)
+		if err != nil {
+			return err
+		}
+		av.AcknowledgmentMessage = util.PtrTo("requires downtime")
+		return db.UpdateApplicationVersion(ctx, av)
+	})
+	expectChange("update data collection", func(ctx context.Context) error {
+		return db.UpdateDeploymentTargetDataCollection(
+			ctx, target, types.UserRoleVendor, types.DataCollection{LogsDisabled: true},
+		)
+	})
+	expectChange("update agent resource limits", func(ctx context.Context) error {
+		return db.UpdateDeploymentTargetAgentResourceLimits(
+			ctx, target, types.AgentResourceLimits{MaxConcurrentPulls: util.PtrTo(1)},
+		)
+	})
+	expectChange("update organization agent resource limits", func(ctx context.Context) error {
+		o, err := db.GetOrganizationByID(ctx, org.ID)
+		if err != nil {
+			return err
+		}
+		o.AgentResourceLimits = types.AgentResourceLimits{MaxProcs: util.PtrTo(2)}
+		return db.UpdateOrganization(ctx, o)
+	})
+	announcement := types.Announcement{
+		OrganizationID:      org.ID,
+		Title:               "maintenance",
+		Severity:            types.AnnouncementSeverityInfo,
+		Audience:            types.AnnouncementAudienceDeploymentTargets,
+		DeploymentTargetIDs: []uuid.UUID{target.ID},
+		StartsAt:            time.Now().Add(time.Hour),
+		EndsAt:              time.Now().Add(2 * time.Hour),
+	}
+	expectChange("create announcement", func(ctx context.Context) error {
+		return db.CreateAnnouncement(ctx, &announcement)
+	})
+	expectChange("update announcement", func(ctx context.Context) error {
+		announcement.Title = "planned maintenance"
+		return db.UpdateAnnouncement(ctx, &announcement)
+	})
+	expectChange("request connectivity check", func(ctx context.Context) error {
+		_, err := db.RequestDeploymentTargetConnectivityCheck(ctx, target.ID, vendor.ID, 0)
+		return err
+	})
+	expectChange("update file retrieval allow-list", func(ctx context.Context) error {
+		return db.UpdateDeploymentTargetFileRetrievalAllowList(ctx, &types.DeploymentTargetFileRetrievalAllowList{
+			DeploymentTargetID: target.ID,
+			AllowedPaths:       []string{"/etc/app/*.conf"},
+		})
+	})
+	expectChange("request file retrieval", func(ctx context.Context) error {
+		return db.CreateDeploymentTargetFileRetrieval(ctx, &types.DeploymentTargetFileRetrieval{
+			DeploymentTargetID: target.ID,
+			Path:               "/etc/app/app.conf",
+		}, time.Hour)
+	})
+
+	// properties that the agent reports on every poll are not part of the resource
+	g.Expect(db.UpdateDeploymentTargetClockSkew(ctx, target, time.Second, time.Now())).To(Succeed())
+	dt, err := db.GetDeploymentTarget(ctx, target.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dt.ResourceVersion).To(Equal(version))
+}
+
+func TestHasDeploymentTargetScheduledResourceChange(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendor := org.Vendors[0]
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID)
+	now := time.Now()
+	maxAge := 10 * time.Minute
+
+	changed, err := db.HasDeploymentTargetScheduledResourceChange(
+		ctx, target.ID, now.Add(-time.Hour), now, maxAge, maxAge,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+
+	g.Expect(db.CreateAnnouncement(ctx, &types.Announcement{
+		OrganizationID:      org.ID,
+		Title:               "maintenance",
+		Severity:            types.AnnouncementSeverityInfo,
+		Audience:            types.AnnouncementAudienceDeploymentTargets,
+		DeploymentTargetIDs: []uuid.UUID{target.ID},
+		StartsAt:            now.Add(time.Hour),
+		EndsAt:              now.Add(2 * time.Hour),
+	})).To(Succeed())
+	changed, err = db.HasDeploymentTargetScheduledResourceChange(ctx, target.ID, now, now.Add(time.Minute), maxAge, maxAge)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+	changed, err = db.HasDeploymentTargetScheduledResourceChange(
+		ctx, target.ID, now, now.Add(90*time.Minute), maxAge, maxAge,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue(), "announcement started")
+	changed, err = db.HasDeploymentTargetScheduledResourceChange(
+		ctx, target.ID, now.Add(90*time.Minute), now.Add(3*time.Hour), maxAge, maxAge,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue(), "announcement ended")
+
+	check, err := db.RequestDeploymentTargetConnectivityCheck(ctx, target.ID, vendor.ID, 0)
+	g.Expect(err).NotTo(HaveOccurred())
+	changed, err = db.HasDeploymentTargetScheduledResourceChange(
+		ctx, target.ID, check.RequestedAt, check.RequestedAt.Add(maxAge+time.Second), maxAge, 2*maxAge,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue(), "connectivity check expired")
+
+	retrieval := types.DeploymentTargetFileRetrieval{DeploymentTargetID: target.ID, Path: "/etc/app/app.conf"}
+	g.Expect(db.CreateDeploymentTargetFileRetrieval(ctx, &retrieval, 2*maxAge)).To(Succeed())
+	changed, err = db.HasDeploymentTargetScheduledResourceChange(
+		ctx, target.ID, check.RequestedAt.Add(maxAge+time.Second), retrieval.CreatedAt.Add(2*maxAge+time.Second),
+		maxAge, 2*maxAge,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue(), "file retrieval expired")
+}
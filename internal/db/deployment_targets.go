@@ -4,16 +4,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/glasskube/distr/internal/apierrors"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/fieldset"
+	"github.com/glasskube/distr/internal/pagination"
 	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
 const (
+	deploymentTargetDataCollectionOutputExpr = `
+		(
+			dt.vendor_logs_disabled, dt.vendor_metrics_disabled, dt.vendor_diagnostics_disabled,
+			dt.vendor_inventory_disabled
+		) AS vendor_data_collection,
+		(
+			dt.customer_logs_disabled, dt.customer_metrics_disabled, dt.customer_diagnostics_disabled,
+			dt.customer_inventory_disabled
+		) AS customer_data_collection,
+		(
+			dt.vendor_logs_disabled OR dt.customer_logs_disabled,
+			dt.vendor_metrics_disabled OR dt.customer_metrics_disabled,
+			dt.vendor_diagnostics_disabled OR dt.customer_diagnostics_disabled,
+			dt.vendor_inventory_disabled OR dt.customer_inventory_disabled
+		) AS data_collection
+	`
+	deploymentTargetAwaitingConnectionExpr = `
+		EXISTS (
+			SELECT 1 FROM DeploymentTargetPreRegistration pr
+			WHERE pr.deployment_target_id = dt.id AND pr.connected_at IS NULL
+		)`
+	// deploymentTargetEffectiveAgentResourceLimitsExpr merges the limits of dt into the defaults of its organization.
+	// Limits that are not set are omitted from the JSON objects, so they do not override the defaults.
+	deploymentTargetEffectiveAgentResourceLimitsExpr = `
+		((SELECT o.agent_resource_limits FROM Organization o WHERE o.id = dt.organization_id) || dt.agent_resource_limits)`
 	deploymentTargetOutputExprBase = `
 		dt.id,
 		dt.created_at,
@@ -27,19 +57,41 @@ const (
 		dt.created_by_user_account_id,
 		dt.agent_version_id,
 		dt.reported_agent_version_id,
-		dt.metrics_enabled
+		dt.metrics_enabled,
+		dt.inventory_enabled,
+		dt.custom_fields,
+		dt.archived_at,
+		dt.migration_connect_url,
+		dt.reported_agent_platform,
+		dt.production,
+		dt.clock_skew_ms,
+		dt.clock_skew_measured_at,
+		dt.agent_resource_limits,
+		` + deploymentTargetEffectiveAgentResourceLimitsExpr + ` AS effective_agent_resource_limits,
+		dt.applied_agent_resource_limits,
+		dt.resource_version,
+		dt.paused_at,
+		dt.paused_by_user_account_id,
+		dt.pause_reason,
+		dt.resume_at,
+		` + deploymentTargetAwaitingConnectionExpr + ` AS awaiting_connection,
+		` + deploymentTargetDataCollectionOutputExpr + `
 	`
 	deploymentTargetOutputExpr = deploymentTargetOutputExprBase +
 		", (" + userAccountWithRoleOutputExpr + ") as created_by"
-	deploymentTargetWithStatusOutputExpr = deploymentTargetOutputExpr + `,
+	deploymentTargetStatusOutputExpr = `
 		CASE WHEN status.id IS NOT NULL
 			THEN (status.id, status.created_at, status.message) END
-			AS current_status,
+			AS current_status
+	`
+	deploymentTargetAgentVersionOutputExpr = `
 		CASE WHEN agv.id IS NOT NULL
 			THEN (agv.id, agv.created_at, agv.name, agv.manifest_file_revision, agv.compose_file_revision) END
 			AS agent_version
 	`
-	deploymentTargetJoinExpr = `
+	deploymentTargetWithStatusOutputExpr = deploymentTargetOutputExpr + "," +
+		deploymentTargetStatusOutputExpr + "," + deploymentTargetAgentVersionOutputExpr
+	deploymentTargetStatusJoinExpr = `
 		LEFT JOIN (
 			-- find the creation date of the latest status entry for each deployment target
 			-- IMPORTANT: The sub-query here might seem inefficient but it is MUCH FASTER than using a GROUP BY clause
@@ -53,45 +105,231 @@ const (
 		LEFT JOIN DeploymentTargetStatus status
 			ON dt.id = status.deployment_target_id
 			AND status.created_at = status_max.max_created_at
+	`
+	deploymentTargetAgentVersionJoinExpr = `
 		LEFT JOIN AgentVersion agv
 			ON dt.agent_version_id = agv.id
+	`
+	deploymentTargetUserJoinExpr = `
 		LEFT JOIN UserAccount u
 			ON dt.created_by_user_account_id = u.id
 		LEFT JOIN Organization_UserAccount j
-			ON u.id = j.user_account_id
+			ON u.id = j.user_account_id AND j.organization_id = dt.organization_id
 	`
+	deploymentTargetJoinExpr = deploymentTargetStatusJoinExpr + deploymentTargetAgentVersionJoinExpr +
+		deploymentTargetUserJoinExpr
 	deploymentTargetFromExpr = `
 		DeploymentTarget dt
 	` + deploymentTargetJoinExpr
+	// deploymentTargetLastSeenExpr is the time of the latest status of dt. It uses the same index as the status join.
+	deploymentTargetLastSeenExpr = `
+		(SELECT max(s.created_at) FROM DeploymentTargetStatus s WHERE s.deployment_target_id = dt.id)`
 )
 
+// DeploymentTargetsPageSpec orders deployment targets by the name and email of their creator and their own name.
+var DeploymentTargetsPageSpec = pagination.Spec{
+	Name: "DeploymentTarget",
+	Columns: []pagination.Column{
+		{Expr: "coalesce(u.name, '')", Type: "TEXT"},
+		{Expr: "coalesce(u.email, '')", Type: "TEXT"},
+		{Expr: "dt.name", Type: "TEXT"},
+		{Expr: "dt.id", Type: "UUID"},
+	},
+}
+
+// deploymentTargetListColumns are the columns that GetDeploymentTargets only selects if the JSON field of the same
+// deployment target property is requested. All other columns are needed for filtering, pagination or authorization.
+var deploymentTargetListColumns = []struct{ field, expr string }{
+	{"createdAt", "dt.created_at"},
+	{"type", "dt.type"},
+	{"namespace", "dt.namespace"},
+	{"scope", "dt.scope"},
+	{"reportedAgentVersionId", "dt.reported_agent_version_id"},
+	{"metricsEnabled", "dt.metrics_enabled"},
+	{"inventoryEnabled", "dt.inventory_enabled"},
+	{"customFields", "dt.custom_fields"},
+	{"archivedAt", "dt.archived_at"},
+	{"reportedAgentPlatform", "dt.reported_agent_platform"},
+	{"production", "dt.production"},
+	{"clockSkewMs", "dt.clock_skew_ms"},
+	{"clockSkewMeasuredAt", "dt.clock_skew_measured_at"},
+	{"agentResourceLimits", "dt.agent_resource_limits"},
+	{"effectiveAgentResourceLimits", deploymentTargetEffectiveAgentResourceLimitsExpr +
+		" AS effective_agent_resource_limits"},
+	{"appliedAgentResourceLimits", "dt.applied_agent_resource_limits"},
+	{"awaitingConnection", deploymentTargetAwaitingConnectionExpr + " AS awaiting_connection"},
+	{"pausedAt", "dt.paused_at"},
+	{"pausedByUserAccountId", "dt.paused_by_user_account_id"},
+	{"pauseReason", "dt.pause_reason"},
+	{"resumeAt", "dt.resume_at"},
+	{"vendorDataCollection", "(dt.vendor_logs_disabled, dt.vendor_metrics_disabled, dt.vendor_diagnostics_disabled, " +
+		"dt.vendor_inventory_disabled) AS vendor_data_collection"},
+	{"customerDataCollection", "(dt.customer_logs_disabled, dt.customer_metrics_disabled, " +
+		"dt.customer_diagnostics_disabled, dt.customer_inventory_disabled) AS customer_data_collection"},
+	{"dataCollection", "(dt.vendor_logs_disabled OR dt.customer_logs_disabled, " +
+		"dt.vendor_metrics_disabled OR dt.customer_metrics_disabled, " +
+		"dt.vendor_diagnostics_disabled OR dt.customer_diagnostics_disabled, " +
+		"dt.vendor_inventory_disabled OR dt.customer_inventory_disabled) AS data_collection"},
+}
+
+// deploymentTargetListExprs returns the output and from expressions for a deployment target query that loads the
+// requested fields. The status and agent version joins and the deployments are only loaded if they are requested.
+func deploymentTargetListExprs(fields fieldset.Set) (string, string) {
+	if fields == nil {
+		return deploymentTargetWithStatusOutputExpr, deploymentTargetFromExpr
+	}
+	outputExprs := []string{
+		"dt.id", "dt.name", "dt.organization_id", "dt.created_by_user_account_id",
+		"(" + userAccountWithRoleOutputExpr + ") as created_by",
+	}
+	for _, column := range deploymentTargetListColumns {
+		if fields.Has(column.field) {
+			outputExprs = append(outputExprs, column.expr)
+		}
+	}
+	fromExpr := " DeploymentTarget dt "
+	if fields.Has("currentStatus") {
+		outputExprs = append(outputExprs, deploymentTargetStatusOutputExpr)
+		fromExpr += deploymentTargetStatusJoinExpr
+	}
+	if fields.Has("agentVersion") {
+		outputExprs = append(outputExprs, deploymentTargetAgentVersionOutputExpr)
+		fromExpr += deploymentTargetAgentVersionJoinExpr
+	}
+	return " " + strings.Join(outputExprs, ", ") + " ", fromExpr + deploymentTargetUserJoinExpr
+}
+
+// GetDeploymentTargets returns one page of the deployment targets that the user can see. If fields is not nil, only
+// the data needed for the given JSON fields is loaded and all other properties are left empty.
 func GetDeploymentTargets(
 	ctx context.Context,
 	orgID, userID uuid.UUID,
 	userRole types.UserRole,
-) ([]types.DeploymentTargetWithCreatedBy, error) {
+	filter types.DeploymentTargetFilter,
+	page pagination.Page,
+	fields fieldset.Set,
+) ([]types.DeploymentTargetWithCreatedBy, []any, error) {
 	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{
+		"orgId":    orgID,
+		"userId":   userID,
+		"userRole": userRole,
+	}
+	outputExpr, fromExpr := deploymentTargetListExprs(fields)
 	if rows, err := db.Query(ctx,
-		"SELECT"+deploymentTargetWithStatusOutputExpr+"FROM"+deploymentTargetFromExpr+
+		"SELECT"+outputExpr+"FROM"+fromExpr+
 			"WHERE dt.organization_id = @orgId AND j.organization_id = dt.organization_id "+
 			"AND (dt.created_by_user_account_id = @userId OR @userRole = 'vendor') "+
-			"ORDER BY u.name, u.email, dt.name",
-		pgx.NamedArgs{"orgId": orgID, "userId": userID, "userRole": userRole},
+			"AND "+deploymentTargetFilterExpr(filter, args)+" "+
+			"AND "+DeploymentTargetsPageSpec.Where(page, args)+" "+
+			DeploymentTargetsPageSpec.OrderByLimit(page, args),
+		args,
 	); err != nil {
-		return nil, fmt.Errorf("failed to query DeploymentTargets: %w", err)
+		return nil, nil, fmt.Errorf("failed to query DeploymentTargets: %w", err)
 	} else if result, err := pgx.CollectRows(
 		rows,
-		pgx.RowToStructByName[types.DeploymentTargetWithCreatedBy],
+		pgx.RowToStructByNameLax[types.DeploymentTargetWithCreatedBy],
 	); err != nil {
-		return nil, fmt.Errorf("failed to get DeploymentTargets: %w", err)
+		return nil, nil, fmt.Errorf("failed to get DeploymentTargets: %w", err)
 	} else {
-		for i := range result {
-			if err := addDeploymentsToTarget(ctx, &result[i]); err != nil {
-				return nil, err
+		result, next := pagination.Trim(page, result, func(dt types.DeploymentTargetWithCreatedBy) []any {
+			var name, email string
+			if dt.CreatedBy != nil {
+				name, email = dt.CreatedBy.Name, dt.CreatedBy.Email
 			}
+			return []any{name, email, dt.Name, dt.ID}
+		})
+		// the cursor refers to the stored values, so they are only decrypted afterwards
+		if err := decryptUserAccounts(&result); err != nil {
+			return nil, nil, err
 		}
-		return result, nil
+		if fields.Has("deployments", "deployment") {
+			for i := range result {
+				if err := addDeploymentsToTarget(ctx, &result[i], filter.IncludeArchived); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		return result, next, nil
+	}
+}
+
+// deploymentTargetFilterExpr returns a condition for the deployment target dt that is met if dt matches filter and
+// adds the parameters of the condition to args. Only the conditions of filter that are set are included, so that the
+// query planner can skip the joins of unused conditions.
+func deploymentTargetFilterExpr(filter types.DeploymentTargetFilter, args pgx.NamedArgs) string {
+	conditions := []string{"dt.custom_fields @> @customFieldsFilter"}
+	args["customFieldsFilter"] = nonNilCustomFields(filter.CustomFields)
+	if !filter.IncludeArchived {
+		conditions = append(conditions, "dt.archived_at IS NULL")
 	}
+	if filter.Health != nil {
+		switch *filter.Health {
+		case types.DeploymentTargetHealthOnline:
+			conditions = append(conditions, deploymentTargetLastSeenExpr+" >= current_timestamp - INTERVAL '1 minute'")
+		case types.DeploymentTargetHealthStale:
+			conditions = append(conditions, deploymentTargetLastSeenExpr+" < current_timestamp - INTERVAL '1 minute'")
+		case types.DeploymentTargetHealthNeverConnected:
+			conditions = append(conditions, deploymentTargetLastSeenExpr+" IS NULL")
+		default:
+			conditions = append(conditions, "false")
+		}
+	}
+	if filter.Production != nil {
+		conditions = append(conditions, "dt.production = @production")
+		args["production"] = *filter.Production
+	}
+	if filter.Paused != nil {
+		conditions = append(conditions, `(dt.paused_at IS NOT NULL OR EXISTS (
+			SELECT 1 FROM Deployment d
+			WHERE d.deployment_target_id = dt.id AND d.paused_at IS NOT NULL AND d.archived_at IS NULL
+		)) = @paused`)
+		args["paused"] = *filter.Paused
+	}
+	if filter.ReportedAgentVersionID != nil {
+		conditions = append(conditions, "dt.reported_agent_version_id = @reportedAgentVersionId")
+		args["reportedAgentVersionId"] = *filter.ReportedAgentVersionID
+	}
+	if filter.LastSeenBefore != nil {
+		conditions = append(conditions, deploymentTargetLastSeenExpr+" < @lastSeenBefore")
+		args["lastSeenBefore"] = *filter.LastSeenBefore
+	}
+	if filter.LastSeenAfter != nil {
+		conditions = append(conditions, deploymentTargetLastSeenExpr+" > @lastSeenAfter")
+		args["lastSeenAfter"] = *filter.LastSeenAfter
+	}
+
+	var deploymentConditions []string
+	if filter.ApplicationID != nil {
+		deploymentConditions = append(deploymentConditions, "av.application_id = @applicationId")
+		args["applicationId"] = *filter.ApplicationID
+	}
+	if filter.ApplicationVersionID != nil {
+		deploymentConditions = append(deploymentConditions, "av.id = @applicationVersionId")
+		args["applicationVersionId"] = *filter.ApplicationVersionID
+	}
+	if filter.VersionOlderThan != nil {
+		deploymentConditions = append(deploymentConditions,
+			"av.version_sort_key < semver_sort_key(@versionOlderThan) COLLATE \"C\"")
+		args["versionOlderThan"] = *filter.VersionOlderThan
+	}
+	if len(deploymentConditions) > 0 {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1
+			FROM Deployment d
+			JOIN LATERAL (
+				SELECT dr.application_version_id
+				FROM DeploymentRevision dr
+				WHERE dr.deployment_id = d.id AND `+deploymentRevisionReleasedExpr+`
+				ORDER BY dr.created_at DESC
+				LIMIT 1
+			) dr ON true
+			JOIN ApplicationVersion av ON av.id = dr.application_version_id
+			WHERE d.deployment_target_id = dt.id AND d.archived_at IS NULL AND d.uninstalled_at IS NULL
+				AND `+strings.Join(deploymentConditions, " AND ")+`
+		)`)
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")"
 }
 
 func GetDeploymentTarget(
@@ -118,8 +356,10 @@ func GetDeploymentTarget(
 		return nil, apierrors.ErrNotFound
 	} else if err != nil {
 		return nil, fmt.Errorf("failed to get DeploymentTarget: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	} else {
-		return &result, addDeploymentsToTarget(ctx, &result)
+		return &result, addDeploymentsToTarget(ctx, &result, true)
 	}
 }
 
@@ -143,8 +383,10 @@ func GetDeploymentTargetForDeploymentID(
 		return nil, apierrors.ErrNotFound
 	} else if err != nil {
 		return nil, fmt.Errorf("failed to get DeploymentTarget: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	} else {
-		return &result, addDeploymentsToTarget(ctx, &result)
+		return &result, addDeploymentsToTarget(ctx, &result, true)
 	}
 }
 
@@ -152,6 +394,24 @@ func CreateDeploymentTarget(
 	ctx context.Context,
 	dt *types.DeploymentTargetWithCreatedBy,
 	orgID, createdByID uuid.UUID,
+) error {
+	return createDeploymentTarget(ctx, dt, orgID, createdByID, nil)
+}
+
+// CreateDeploymentTargetWithID is like CreateDeploymentTarget but uses the ID of dt instead of generating a new one.
+func CreateDeploymentTargetWithID(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	orgID, createdByID uuid.UUID,
+) error {
+	return createDeploymentTarget(ctx, dt, orgID, createdByID, &dt.ID)
+}
+
+func createDeploymentTarget(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	orgID, createdByID uuid.UUID,
+	id *uuid.UUID,
 ) error {
 	dt.OrganizationID = orgID
 	if dt.CreatedBy == nil {
@@ -160,21 +420,31 @@ func CreateDeploymentTarget(
 
 	db := internalctx.GetDb(ctx)
 	args := pgx.NamedArgs{
-		"name":           dt.Name,
-		"type":           dt.Type,
-		"orgId":          dt.OrganizationID,
-		"userId":         dt.CreatedBy.ID,
-		"namespace":      dt.Namespace,
-		"scope":          dt.Scope,
-		"agentVersionId": dt.AgentVersionID,
-		"metricsEnabled": dt.MetricsEnabled,
+		"id":               id,
+		"name":             dt.Name,
+		"type":             dt.Type,
+		"orgId":            dt.OrganizationID,
+		"userId":           dt.CreatedBy.ID,
+		"namespace":        dt.Namespace,
+		"scope":            dt.Scope,
+		"agentVersionId":   dt.AgentVersionID,
+		"metricsEnabled":   dt.MetricsEnabled,
+		"inventoryEnabled": dt.InventoryEnabled,
+		"customFields":     nonNilCustomFields(dt.CustomFields),
+		"production":       dt.Production,
 	}
 	rows, err := db.Query(
 		ctx,
 		`WITH inserted AS (
 			INSERT INTO DeploymentTarget
-			(name, type, organization_id, created_by_user_account_id, namespace, scope, agent_version_id, metrics_enabled)
-			VALUES (@name, @type, @orgId, @userId, @namespace, @scope, @agentVersionId, @metricsEnabled)
+			(
+				id, name, type, organization_id, created_by_user_account_id, namespace, scope, agent_version_id,
+				metrics_enabled, inventory_enabled, custom_fields, production
+			)
+			VALUES (
+				coalesce(@id, gen_random_uuid()), @name, @type, @orgId, @userId, @namespace, @scope, @agentVersionId,
+				@metricsEnabled, @inventoryEnabled, @customFields, @production
+			)
 			RETURNING *
 		)
 		SELECT `+deploymentTargetOutputExpr+` FROM inserted dt`+deploymentTargetJoinExpr+
@@ -187,9 +457,11 @@ func CreateDeploymentTarget(
 	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByNameLax[types.DeploymentTargetWithCreatedBy])
 	if err != nil {
 		return fmt.Errorf("could not save DeploymentTarget: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return err
 	} else {
 		*dt = result
-		return addDeploymentsToTarget(ctx, dt)
+		return addDeploymentsToTarget(ctx, dt, true)
 	}
 }
 
@@ -197,10 +469,13 @@ func UpdateDeploymentTarget(ctx context.Context, dt *types.DeploymentTargetWithC
 	agentUpdateStr := ""
 	db := internalctx.GetDb(ctx)
 	args := pgx.NamedArgs{
-		"id":             dt.ID,
-		"name":           dt.Name,
-		"orgId":          orgID,
-		"metricsEnabled": dt.MetricsEnabled,
+		"id":               dt.ID,
+		"name":             dt.Name,
+		"orgId":            orgID,
+		"metricsEnabled":   dt.MetricsEnabled,
+		"inventoryEnabled": dt.InventoryEnabled,
+		"customFields":     nonNilCustomFields(dt.CustomFields),
+		"production":       dt.Production,
 	}
 	if dt.AgentVersionID != nil {
 		args["agentVersionId"] = dt.AgentVersionID
@@ -209,7 +484,8 @@ func UpdateDeploymentTarget(ctx context.Context, dt *types.DeploymentTargetWithC
 	rows, err := db.Query(ctx,
 		`WITH updated AS (
 			UPDATE DeploymentTarget AS dt SET
-				name = @name, metrics_enabled = @metricsEnabled `+agentUpdateStr+`
+				name = @name, metrics_enabled = @metricsEnabled, inventory_enabled = @inventoryEnabled,
+				custom_fields = @customFields, production = @production `+agentUpdateStr+`
 			WHERE id = @id AND organization_id = @orgId RETURNING *
 		)
 		SELECT `+deploymentTargetWithStatusOutputExpr+` FROM updated dt`+deploymentTargetJoinExpr+
@@ -221,9 +497,11 @@ func UpdateDeploymentTarget(ctx context.Context, dt *types.DeploymentTargetWithC
 		rows, pgx.RowToStructByNameLax[types.DeploymentTargetWithCreatedBy],
 	); err != nil {
 		return fmt.Errorf("could not get updated DeploymentTarget: %w", err)
+	} else if err := decryptUserAccounts(&updated); err != nil {
+		return err
 	} else {
 		*dt = updated
-		return addDeploymentsToTarget(ctx, dt)
+		return addDeploymentsToTarget(ctx, dt, true)
 	}
 }
 
@@ -238,6 +516,24 @@ func DeleteDeploymentTargetWithID(ctx context.Context, id uuid.UUID) error {
 	}
 }
 
+// SetDeploymentTargetArchived archives or unarchives the deployment target.
+// The agent of an archived deployment target can not log in or check in anymore, but its history is kept.
+func SetDeploymentTargetArchived(ctx context.Context, id, orgID uuid.UUID, archived bool) error {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		`UPDATE DeploymentTarget
+		SET archived_at = CASE WHEN @archived THEN coalesce(archived_at, current_timestamp) END
+		WHERE id = @id AND organization_id = @orgId`,
+		pgx.NamedArgs{"id": id, "orgId": orgID, "archived": archived},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTarget: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	} else {
+		return nil
+	}
+}
+
 func UpdateDeploymentTargetAccess(ctx context.Context, dt *types.DeploymentTarget, orgID uuid.UUID) error {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
@@ -316,7 +612,8 @@ func CleanupDeploymentTargetStatus(ctx context.Context) (int64, error) {
 		) max_created_at
 		WHERE dts.deployment_target_id = max_created_at.deployment_target_id
 			AND dts.created_at < max_created_at.max_created_at
-			AND current_timestamp - dts.created_at > @statusEntriesMaxAge`,
+			AND current_timestamp - dts.created_at > @statusEntriesMaxAge
+			AND NOT `+legalHoldOfDeploymentTargetExpr("dts.deployment_target_id"),
 		pgx.NamedArgs{"statusEntriesMaxAge": env.StatusEntriesMaxAge()},
 	); err != nil {
 		return 0, err
@@ -325,8 +622,12 @@ func CleanupDeploymentTargetStatus(ctx context.Context) (int64, error) {
 	}
 }
 
-func addDeploymentsToTarget(ctx context.Context, dt *types.DeploymentTargetWithCreatedBy) error {
-	if d, err := GetDeploymentsForDeploymentTarget(ctx, dt.ID); errors.Is(err, apierrors.ErrNotFound) {
+func addDeploymentsToTarget(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	includeArchived bool,
+) error {
+	if d, err := GetDeploymentsForDeploymentTarget(ctx, dt.ID, includeArchived); errors.Is(err, apierrors.ErrNotFound) {
 		return nil
 	} else if err != nil {
 		return err
@@ -339,3 +640,80 @@ func addDeploymentsToTarget(ctx context.Context, dt *types.DeploymentTargetWithC
 		return nil
 	}
 }
+
+// SetDeploymentTargetMigration sets the connect URL of another Distr instance that the agent of this deployment target
+// should migrate to. Passing nil cancels a pending migration.
+func SetDeploymentTargetMigration(ctx context.Context, id, orgID uuid.UUID, connectURL *string) error {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(ctx,
+		`UPDATE DeploymentTarget SET migration_connect_url = @connectUrl WHERE id = @id AND organization_id = @orgId`,
+		pgx.NamedArgs{"id": id, "orgId": orgID, "connectUrl": connectURL},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTarget: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	} else {
+		return nil
+	}
+}
+
+func UpdateDeploymentTargetReportedAgentPlatform(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	platform string,
+) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE DeploymentTarget SET reported_agent_platform = @platform WHERE id = @id`,
+		pgx.NamedArgs{"id": dt.ID, "platform": platform},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTarget: %w", err)
+	}
+	dt.ReportedAgentPlatform = &platform
+	return nil
+}
+
+func UpdateDeploymentTargetClockSkew(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	skew time.Duration,
+	measuredAt time.Time,
+) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`UPDATE DeploymentTarget SET clock_skew_ms = @skewMs, clock_skew_measured_at = @measuredAt WHERE id = @id`,
+		pgx.NamedArgs{"id": dt.ID, "skewMs": skew.Milliseconds(), "measuredAt": measuredAt},
+	); err != nil {
+		return fmt.Errorf("could not update DeploymentTarget: %w", err)
+	}
+	dt.ClockSkewMs = util.PtrTo(skew.Milliseconds())
+	dt.ClockSkewMeasuredAt = &measuredAt
+	return nil
+}
+
+// GetDeploymentTargetPlatforms returns all agent platforms reported by non-archived deployment targets of the given
+// type in an organization.
+func GetDeploymentTargetPlatforms(
+	ctx context.Context,
+	orgID uuid.UUID,
+	deploymentType types.DeploymentType,
+) ([]string, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT DISTINCT reported_agent_platform
+		FROM DeploymentTarget
+		WHERE organization_id = @orgId
+			AND type = @type
+			AND archived_at IS NULL
+			AND reported_agent_platform IS NOT NULL
+		ORDER BY reported_agent_platform`,
+		pgx.NamedArgs{"orgId": orgID, "type": deploymentType},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DeploymentTarget platforms: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+		return nil, fmt.Errorf("could not collect DeploymentTarget platforms: %w", err)
+	} else {
+		return result, nil
+	}
+}
@@ -0,0 +1,185 @@
+package db_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/fieldset"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestUpdateDeploymentTargetClockSkew(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	g.Expect(dt.ClockSkew()).To(BeNil())
+
+	measuredAt := time.Now().UTC().Truncate(time.Microsecond)
+	g.Expect(db.UpdateDeploymentTargetClockSkew(ctx, dt, -14*time.Minute, measuredAt)).To(Succeed())
+	g.Expect(*dt.ClockSkew()).To(Equal(-14 * time.Minute))
+
+	loaded, err := db.GetDeploymentTarget(ctx, dt.ID, &org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.ClockSkewMs).To(HaveValue(Equal(int64(-14 * 60 * 1000))))
+	g.Expect(loaded.ClockSkewMeasuredAt).To(HaveValue(BeTemporally("~", measuredAt, time.Millisecond)))
+}
+
+func TestGetDeploymentTargetsFields(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	testutil.NewDeploymentRevision(ctx, t, dt)
+	g.Expect(db.CreateDeploymentTargetStatus(ctx, &dt.DeploymentTarget, "running")).To(Succeed())
+
+	all, _, err := db.GetDeploymentTargets(
+		ctx, org.ID, org.Vendors[0].ID, types.UserRoleVendor, types.DeploymentTargetFilter{}, pagination.Page{}, nil,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(HaveLen(1))
+	g.Expect(all[0].CurrentStatus).NotTo(BeNil())
+	g.Expect(all[0].Deployments).To(HaveLen(1))
+	g.Expect(all[0].AgentVersion.ID).To(Equal(*dt.AgentVersionID))
+
+	names, _, err := db.GetDeploymentTargets(
+		ctx, org.ID, org.Vendors[0].ID, types.UserRoleVendor, types.DeploymentTargetFilter{}, pagination.Page{},
+		fieldset.Set{"name": {}},
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names).To(HaveLen(1))
+	g.Expect(names[0].ID).To(Equal(dt.ID))
+	g.Expect(names[0].Name).To(Equal(dt.Name))
+	g.Expect(names[0].CurrentStatus).To(BeNil())
+	g.Expect(names[0].Deployments).To(BeEmpty())
+	g.Expect(names[0].CustomFields).To(BeNil())
+}
+
+// BenchmarkGetDeploymentTargets compares loading and serializing all fields of deployment targets to the minimal
+// field sets that a list of names with their health needs.
+func BenchmarkGetDeploymentTargets(b *testing.B) {
+	ctx := testutil.DBContext(b)
+	org := testutil.NewOrganizationWithUsers(ctx, b, 1, 0)
+	for range 50 {
+		dt := testutil.NewDeploymentTarget(ctx, b, org.ID, org.Vendors[0].ID)
+		testutil.NewDeploymentRevision(ctx, b, dt)
+		for range 10 {
+			if err := db.CreateDeploymentTargetStatus(ctx, &dt.DeploymentTarget, "running"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	for _, bc := range []struct {
+		name   string
+		fields fieldset.Set
+	}{
+		{"all", nil},
+		{"name,currentStatus", fieldset.Set{"name": {}, "currentStatus": {}}},
+		{"name", fieldset.Set{"name": {}}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var size int
+			for b.Loop() {
+				dts, _, err := db.GetDeploymentTargets(
+					ctx, org.ID, org.Vendors[0].ID, types.UserRoleVendor, types.DeploymentTargetFilter{}, pagination.Page{}, bc.fields,
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+				result, err := fieldset.Apply(bc.fields, dts)
+				if err != nil {
+					b.Fatal(err)
+				}
+				data, err := json.Marshal(result)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/response")
+		})
+	}
+}
+
+func TestGetDeploymentTargetCreatedByMemberOfMultipleOrganizations(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 0, 1)
+	other := testutil.NewOrganization(ctx, t)
+	customer := org.Customers[0]
+	g.Expect(db.CreateUserAccountOrganizationAssignment(
+		ctx, customer.ID, other.ID, types.UserRoleVendor,
+	)).To(Succeed())
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+
+	// the role of the creator must be the one in the organization of the deployment target
+	loaded, err := db.GetDeploymentTarget(ctx, dt.ID, &org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.CreatedBy.UserRole).To(Equal(types.UserRoleCustomer))
+	all, _, err := db.GetDeploymentTargets(
+		ctx, org.ID, customer.ID, types.UserRoleCustomer, types.DeploymentTargetFilter{}, pagination.Page{}, nil,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(HaveLen(1))
+}
+
+func TestGetDeploymentTargetsFilter(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendor := org.Vendors[0]
+	online := testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID, func(dt *types.DeploymentTargetWithCreatedBy) {
+		dt.Production = true
+	})
+	revision := testutil.NewDeploymentRevision(ctx, t, online)
+	g.Expect(db.CreateDeploymentTargetStatus(ctx, &online.DeploymentTarget, "running")).To(Succeed())
+	never := testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID)
+
+	list := func(filter types.DeploymentTargetFilter) []types.DeploymentTargetWithCreatedBy {
+		result, _, err := db.GetDeploymentTargets(
+			ctx, org.ID, vendor.ID, types.UserRoleVendor, filter, pagination.Page{}, fieldset.Set{"name": {}},
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+	ids := func(ids ...any) OmegaMatcher {
+		matchers := make([]any, len(ids))
+		for i, id := range ids {
+			matchers[i] = HaveField("ID", id)
+		}
+		return ConsistOf(matchers...)
+	}
+
+	g.Expect(list(types.DeploymentTargetFilter{})).To(ids(online.ID, never.ID))
+	g.Expect(list(types.DeploymentTargetFilter{
+		Health: util.PtrTo(types.DeploymentTargetHealthOnline),
+	})).To(ids(online.ID))
+	g.Expect(list(types.DeploymentTargetFilter{
+		Health: util.PtrTo(types.DeploymentTargetHealthNeverConnected),
+	})).To(ids(never.ID))
+	g.Expect(list(types.DeploymentTargetFilter{Health: util.PtrTo(types.DeploymentTargetHealthStale)})).To(BeEmpty())
+	g.Expect(list(types.DeploymentTargetFilter{Production: util.PtrTo(false)})).To(ids(never.ID))
+	g.Expect(list(types.DeploymentTargetFilter{
+		LastSeenAfter: util.PtrTo(time.Now().Add(-time.Hour)),
+	})).To(ids(online.ID))
+	g.Expect(list(types.DeploymentTargetFilter{LastSeenBefore: util.PtrTo(time.Now().Add(-time.Hour))})).To(BeEmpty())
+
+	// the deployed version of the test revision is 1.0.0
+	g.Expect(list(types.DeploymentTargetFilter{
+		ApplicationVersionID: &revision.ApplicationVersionID,
+		VersionOlderThan:     util.PtrTo("1.0.1"),
+	})).To(ids(online.ID))
+	g.Expect(list(types.DeploymentTargetFilter{VersionOlderThan: util.PtrTo("1.0.0")})).To(BeEmpty())
+	g.Expect(list(types.DeploymentTargetFilter{VersionOlderThan: util.PtrTo("v1.0.1-rc.1")})).To(ids(online.ID))
+	g.Expect(list(types.DeploymentTargetFilter{
+		VersionOlderThan: util.PtrTo("1.0.1"),
+		Production:       util.PtrTo(false),
+	})).To(BeEmpty())
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/glasskube/distr/internal/apierrors"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/pagination"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
@@ -20,8 +21,18 @@ import (
 const (
 	deploymentOutputExpr = `
 		d.id, d.created_at, d.deployment_target_id, d.release_name, d.application_license_id, d.docker_type,
-		d.logs_enabled
+		d.logs_enabled, d.archived_at, d.uninstall_requested_at, d.uninstall_requested_by_user_account_id,
+		d.uninstall_delete_data, d.uninstalled_at, d.auto_rollback_enabled, d.auto_rollback_window_seconds,
+		d.paused_at, d.paused_by_user_account_id, d.pause_reason, d.resume_at
 	`
+	deploymentRevisionOutputExpr = `
+		dr.id, dr.created_at, dr.deployment_id, dr.application_version_id, dr.reason, dr.operation_id,
+		dr.acknowledgment_required, dr.acknowledged_at, dr.acknowledged_by_user_account_id, dr.acknowledgment_overridden,
+		dr.acknowledgment_reminded_at, dr.healthy_at, dr.automatic_rollback_of_revision_id
+	`
+	// deploymentRevisionReleasedExpr is true for revisions that can be sent to the agent, i.e. revisions that do not
+	// require acknowledgment or have been acknowledged.
+	deploymentRevisionReleasedExpr = `NOT (dr.acknowledgment_required AND dr.acknowledged_at IS NULL)`
 )
 
 func GetDeployment(
@@ -57,9 +68,13 @@ func GetDeployment(
 	}
 }
 
+// GetDeploymentsForDeploymentTarget returns the deployments of a deployment target with their latest released
+// revision. Revisions that are pending acknowledgment are not considered, so deployments that only have such revisions
+// are omitted.
 func GetDeploymentsForDeploymentTarget(
 	ctx context.Context,
 	deploymentTargetID uuid.UUID,
+	includeArchived bool,
 ) ([]types.DeploymentWithLatestRevision, error) {
 	// TODO all these methods also need the orgId criteria
 	db := internalctx.GetDb(ctx)
@@ -71,6 +86,7 @@ func GetDeploymentsForDeploymentTarget(
 				dr.env_file_data as env_file_data,
 				dr.id as deployment_revision_id,
 				dr.created_at AS deployment_revision_created_at,
+				dr.operation_id AS deployment_revision_operation_id,
 				a.id AS application_id,
 				a.name AS application_name,
 				av.name AS application_version_name,
@@ -78,17 +94,22 @@ func GetDeploymentsForDeploymentTarget(
 					drs.id,
 					drs.created_at,
 					drs.deployment_revision_id,
-					drs.type, drs.message
-				) END AS latest_status
+					drs.type, drs.message, drs.error_reason
+				) END AS latest_status,
+				d.paused_at IS NOT NULL OR dt.paused_at IS NOT NULL AS paused
 			FROM Deployment d
+				JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+				-- Revisions that are pending acknowledgment are skipped, so that the agent keeps the released revision
 				LEFT JOIN (
 					SELECT deployment_id, max(created_at) AS max_created_at
-					FROM DeploymentRevision
+					FROM DeploymentRevision dr
+					WHERE `+deploymentRevisionReleasedExpr+`
 					GROUP BY deployment_id
 				) dr_max ON d.id = dr_max.deployment_id
 				JOIN DeploymentRevision dr
 					ON d.id = dr.deployment_id
 					AND dr.created_at = dr_max.max_created_at
+					AND `+deploymentRevisionReleasedExpr+`
 				JOIN ApplicationVersion av ON dr.application_version_id = av.id
 				JOIN Application a ON av.application_id = a.id
 				-- Join the DeploymentRevision table again because we ALSO need the latest deployment revision for
@@ -112,8 +133,9 @@ func GetDeploymentsForDeploymentTarget(
 					ON dr_status.id = drs.deployment_revision_id
 					AND drs.created_at = status_max.max_created_at
 			WHERE d.deployment_target_id = @deploymentTargetId
+				AND (@includeArchived OR (d.archived_at IS NULL AND d.uninstalled_at IS NULL))
 			ORDER BY d.created_at`,
-		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID})
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID, "includeArchived": includeArchived})
 	if err != nil {
 		return nil, fmt.Errorf("failed to query Deployments: %w", err)
 	}
@@ -130,10 +152,13 @@ func CreateDeployment(ctx context.Context, request *api.DeploymentRequest) error
 	rows, err := db.Query(
 		ctx,
 		`INSERT INTO Deployment AS d
-			(deployment_target_id, release_name, application_license_id, docker_type)
-			VALUES (@deploymentTargetId, @releaseName, @applicationLicenseId, @dockerType)
+			(id, deployment_target_id, release_name, application_license_id, docker_type)
+			VALUES (
+				coalesce(@id, gen_random_uuid()), @deploymentTargetId, @releaseName, @applicationLicenseId, @dockerType
+			)
 			RETURNING`+deploymentOutputExpr,
 		pgx.NamedArgs{
+			"id":                   request.DeploymentID,
 			"deploymentTargetId":   request.DeploymentTargetID,
 			"releaseName":          request.ReleaseName,
 			"applicationLicenseId": request.ApplicationLicenseID,
@@ -161,12 +186,16 @@ func UpdateDeployment(ctx context.Context, deployment *types.Deployment) error {
 	rows, err := db.Query(
 		ctx,
 		`UPDATE Deployment AS d
-		SET logs_enabled = @logsEnabled
+		SET logs_enabled = @logsEnabled,
+			auto_rollback_enabled = @autoRollbackEnabled,
+			auto_rollback_window_seconds = @autoRollbackWindowSeconds
 		WHERE id = @id
 		RETURNING`+deploymentOutputExpr,
 		pgx.NamedArgs{
-			"id":          deployment.ID,
-			"logsEnabled": deployment.LogsEnabled,
+			"id":                        deployment.ID,
+			"logsEnabled":               deployment.LogsEnabled,
+			"autoRollbackEnabled":       deployment.AutoRollbackEnabled,
+			"autoRollbackWindowSeconds": deployment.AutoRollbackWindowSeconds,
 		},
 	)
 	if err != nil {
@@ -183,6 +212,33 @@ func UpdateDeployment(ctx context.Context, deployment *types.Deployment) error {
 	}
 }
 
+// SetDeploymentArchived archives or unarchives the deployment.
+// Archived deployments are sent to the agent as paused, so that they are neither updated nor uninstalled, and their
+// history is kept.
+func SetDeploymentArchived(ctx context.Context, deployment *types.Deployment, archived bool) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`UPDATE Deployment AS d
+		SET archived_at = CASE WHEN @archived THEN coalesce(archived_at, current_timestamp) END
+		WHERE id = @id
+		RETURNING`+deploymentOutputExpr,
+		pgx.NamedArgs{"id": deployment.ID, "archived": archived},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Deployment: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Deployment]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return fmt.Errorf("could not update Deployment: %w", err)
+	} else {
+		*deployment = result
+		return nil
+	}
+}
+
 func DeleteDeploymentWithID(ctx context.Context, id uuid.UUID) error {
 	db := internalctx.GetDb(ctx)
 	res, err := db.Exec(ctx, "DELETE FROM Deployment WHERE id = @id", pgx.NamedArgs{"id": id})
@@ -195,19 +251,40 @@ func DeleteDeploymentWithID(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// CreateDeploymentRevision creates a new revision of a deployment.
+//
+// The revision requires acknowledgment if the application version has an acknowledgment message, the deployment
+// target belongs to a customer and the released revision of the deployment has a different version. Because this is
+// decided here, no caller can deploy such a revision without acknowledgment.
 func CreateDeploymentRevision(ctx context.Context, request *api.DeploymentRequest) (*types.DeploymentRevision, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(
 		ctx,
-		`INSERT INTO DeploymentRevision AS d
-			(deployment_id, application_version_id, values_yaml, env_file_data)
-			VALUES (@deploymentId, @applicationVersionId, @valuesYaml, @envFileData)
-			RETURNING d.id, d.created_at, d.deployment_id, d.application_version_id`,
+		`INSERT INTO DeploymentRevision AS dr
+			(deployment_id, application_version_id, values_yaml, env_file_data, reason, acknowledgment_required)
+			VALUES (@deploymentId, @applicationVersionId, @valuesYaml, @envFileData, @reason, coalesce((
+				SELECT av.acknowledgment_message IS NOT NULL
+					AND j.user_role = 'customer'
+					AND av.id IS DISTINCT FROM (
+						SELECT dr.application_version_id
+						FROM DeploymentRevision dr
+						WHERE dr.deployment_id = d.id AND `+deploymentRevisionReleasedExpr+`
+						ORDER BY dr.created_at DESC
+						LIMIT 1
+					)
+				FROM ApplicationVersion av, Deployment d
+					JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+					LEFT JOIN Organization_UserAccount j
+						ON dt.created_by_user_account_id = j.user_account_id AND dt.organization_id = j.organization_id
+				WHERE av.id = @applicationVersionId AND d.id = @deploymentId
+			), false))
+			RETURNING`+deploymentRevisionOutputExpr,
 		pgx.NamedArgs{
 			"deploymentId":         request.DeploymentID,
 			"applicationVersionId": request.ApplicationVersionID,
 			"valuesYaml":           request.ValuesYaml,
 			"envFileData":          request.EnvFileData,
+			"reason":               request.Reason,
 		},
 	)
 	if err != nil {
@@ -221,17 +298,150 @@ func CreateDeploymentRevision(ctx context.Context, request *api.DeploymentReques
 	}
 }
 
+// GetDeploymentRevisions returns all revisions of a deployment, latest first.
+func GetDeploymentRevisions(
+	ctx context.Context,
+	deploymentID uuid.UUID,
+) ([]types.DeploymentRevisionWithVersion, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT`+deploymentRevisionOutputExpr+`, av.name AS application_version_name
+		FROM DeploymentRevision dr
+		JOIN ApplicationVersion av ON dr.application_version_id = av.id
+		WHERE dr.deployment_id = @deploymentId
+		ORDER BY dr.created_at DESC`,
+		pgx.NamedArgs{"deploymentId": deploymentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentRevisions: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentRevisionWithVersion])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect DeploymentRevisions: %w", err)
+	}
+	return result, nil
+}
+
+// GetDeploymentRevision returns the revision with the given ID if it belongs to the given deployment.
+func GetDeploymentRevision(
+	ctx context.Context,
+	id, deploymentID uuid.UUID,
+) (*types.DeploymentRevisionWithVersion, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT`+deploymentRevisionOutputExpr+`, av.name AS application_version_name
+		FROM DeploymentRevision dr
+		JOIN ApplicationVersion av ON dr.application_version_id = av.id
+		WHERE dr.id = @id AND dr.deployment_id = @deploymentId`,
+		pgx.NamedArgs{"id": id, "deploymentId": deploymentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentRevision: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.DeploymentRevisionWithVersion])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get DeploymentRevision: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// GetDeploymentRevisionTimeline returns at most limit events that belong to the operation of a revision, oldest
+// first.
+//
+// Agent reports that carry the operation ID of the revision are matched exactly. Reports of agents that do not echo
+// operation IDs are matched by their revision and by time: they must have been created between the creation of this
+// revision and the creation of the next revision of the same deployment. These events are marked as approximate.
+func GetDeploymentRevisionTimeline(
+	ctx context.Context,
+	revision types.DeploymentRevisionWithVersion,
+	limit int,
+) ([]types.DeploymentTimelineEvent, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		WITH revision_window AS (
+			SELECT @createdAt::TIMESTAMP AS start_at, (
+				SELECT min(dr.created_at) FROM DeploymentRevision dr
+				WHERE dr.deployment_id = @deploymentId AND dr.created_at > @createdAt
+			) AS end_at
+		)
+		SELECT * FROM (
+			SELECT drs.created_at AS time, 'agent' AS source, 'status' AS type, drs.type::TEXT AS severity,
+				NULL::TEXT AS resource, drs.message, drs.operation_id IS NULL AS approximate
+			FROM DeploymentRevisionStatus drs, revision_window w
+			WHERE drs.operation_id = @operationId
+				OR (drs.operation_id IS NULL AND drs.deployment_revision_id = @revisionId
+					AND drs.created_at >= w.start_at AND (w.end_at IS NULL OR drs.created_at < w.end_at))
+			UNION ALL
+			SELECT coalesce(lr.timestamp, lr.created_at) AS time, 'agent' AS source, 'log' AS type,
+				coalesce(lr.severity, '') AS severity, lr.resource, coalesce(lr.body, '') AS message,
+				lr.operation_id IS NULL AS approximate
+			FROM DeploymentLogRecord lr, revision_window w
+			WHERE lr.operation_id = @operationId
+				OR (lr.operation_id IS NULL AND lr.deployment_revision_id = @revisionId
+					AND lr.created_at >= w.start_at AND (w.end_at IS NULL OR lr.created_at < w.end_at))
+		) events
+		ORDER BY time
+		LIMIT @limit`,
+		pgx.NamedArgs{
+			"revisionId":   revision.ID,
+			"deploymentId": revision.DeploymentID,
+			"operationId":  revision.OperationID,
+			"createdAt":    revision.CreatedAt,
+			"limit":        limit,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentTimelineEvents: %w", err)
+	}
+	agentEvents, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentTimelineEvent])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect DeploymentTimelineEvents: %w", err)
+	}
+	message := "revision created with application version " + revision.ApplicationVersionName
+	if revision.Reason != nil {
+		message += ": " + *revision.Reason
+	}
+	result := []types.DeploymentTimelineEvent{{
+		Time:    revision.CreatedAt,
+		Source:  types.DeploymentTimelineEventSourceServer,
+		Type:    types.DeploymentTimelineEventTypeRevisionCreated,
+		Message: message,
+	}}
+	return append(result, agentEvents...), nil
+}
+
+// CreateDeploymentRevisionStatus stores a status reported by the agent. The first "ok" status marks the revision as
+// healthy, which is kept even after its status entries have been cleaned up. The errorReason is only stored for error
+// statuses.
 func CreateDeploymentRevisionStatus(
 	ctx context.Context,
 	revisionID uuid.UUID,
+	operationID *uuid.UUID,
 	statusType types.DeploymentStatusType,
 	message string,
+	errorReason *types.DeploymentStatusErrorReason,
 ) error {
+	if statusType != types.DeploymentStatusTypeError {
+		errorReason = nil
+	}
 	db := internalctx.GetDb(ctx)
 	_, err := db.Exec(ctx, `
-		INSERT INTO DeploymentRevisionStatus (deployment_revision_id, message, type)
-		VALUES (@deploymentRevisionId, @message, @type)`,
-		pgx.NamedArgs{"deploymentRevisionId": revisionID, "message": message, "type": statusType})
+		WITH status AS (
+			INSERT INTO DeploymentRevisionStatus (deployment_revision_id, operation_id, message, type, error_reason)
+			VALUES (@deploymentRevisionId, @operationId, @message, @type, @errorReason)
+			RETURNING deployment_revision_id, type, created_at
+		)
+		UPDATE DeploymentRevision dr
+		SET healthy_at = status.created_at
+		FROM status
+		WHERE dr.id = status.deployment_revision_id AND status.type = 'ok' AND dr.healthy_at IS NULL`,
+		pgx.NamedArgs{
+			"deploymentRevisionId": revisionID,
+			"operationId":          operationID,
+			"message":              message,
+			"type":                 statusType,
+			"errorReason":          errorReason,
+		})
 	if err != nil {
 		var pgError *pgconn.PgError
 		if errors.As(err, &pgError) && pgError.Code == pgerrcode.ForeignKeyViolation {
@@ -293,7 +503,12 @@ func CleanupDeploymentRevisionStatus(ctx context.Context) (int64, error) {
 		) max_created_at
 		WHERE drs.deployment_revision_id = max_created_at.deployment_revision_id
 			AND drs.created_at < max_created_at.max_created_at
-			AND current_timestamp - drs.created_at > @statusEntriesMaxAge`,
+			AND current_timestamp - drs.created_at > @statusEntriesMaxAge
+			AND NOT EXISTS (
+				SELECT 1 FROM DeploymentRevision dr JOIN Deployment d ON dr.deployment_id = d.id
+				WHERE dr.id = drs.deployment_revision_id
+					AND `+legalHoldOfDeploymentTargetExpr("d.deployment_target_id")+`
+			)`,
 		pgx.NamedArgs{"statusEntriesMaxAge": env.StatusEntriesMaxAge()},
 	); err != nil {
 		return 0, err
@@ -302,13 +517,22 @@ func CleanupDeploymentRevisionStatus(ctx context.Context) (int64, error) {
 	}
 }
 
+// DeploymentRevisionStatusPageSpec orders deployment status entries from newest to oldest.
+var DeploymentRevisionStatusPageSpec = pagination.Spec{
+	Name: "DeploymentRevisionStatus",
+	Columns: []pagination.Column{
+		{Expr: "created_at", Type: "TIMESTAMP", Desc: true},
+		{Expr: "id", Type: "UUID", Desc: true},
+	},
+}
+
 func GetDeploymentStatus(
 	ctx context.Context,
 	deploymentID uuid.UUID,
-	maxRows int,
+	page pagination.Page,
 	before time.Time,
 	after time.Time,
-) ([]types.DeploymentRevisionStatus, error) {
+) ([]types.DeploymentRevisionStatus, []any, error) {
 	if before.IsZero() {
 		before = time.Now()
 	}
@@ -320,33 +544,153 @@ func GetDeploymentStatus(
 		pgx.NamedArgs{"deploymentId": deploymentID},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query DeploymentRevision for status: %w", err)
+		return nil, nil, fmt.Errorf("failed to query DeploymentRevision for status: %w", err)
 	}
 	deploymentRevisionIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan DeploymentRevision for status: %w", err)
+		return nil, nil, fmt.Errorf("failed to scan DeploymentRevision for status: %w", err)
 	}
 
+	args := pgx.NamedArgs{
+		"deploymentRevisionIds": deploymentRevisionIDs,
+		"before":                before,
+		"after":                 after,
+	}
 	rows, err = db.Query(
 		ctx,
-		`SELECT id, created_at, deployment_revision_id, type, message
+		`SELECT id, created_at, deployment_revision_id, type, message, error_reason
 		FROM DeploymentRevisionStatus
 		WHERE deployment_revision_id = ANY (@deploymentRevisionIds)
 			AND created_at BETWEEN @after AND @before
-		ORDER BY created_at DESC
-		LIMIT @maxRows`,
+			AND `+DeploymentRevisionStatusPageSpec.Where(page, args)+`
+		`+DeploymentRevisionStatusPageSpec.OrderByLimit(page, args),
+		args,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query DeploymentRevisionStatus: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentRevisionStatus]); err != nil {
+		return nil, nil, fmt.Errorf("failed to get DeploymentRevisionStatus: %w", err)
+	} else {
+		result, next := pagination.Trim(page, result, func(s types.DeploymentRevisionStatus) []any {
+			return []any{s.CreatedAt, s.ID}
+		})
+		return result, next, nil
+	}
+}
+
+func UpsertDeploymentRevisionPullProgress(
+	ctx context.Context,
+	revisionID uuid.UUID,
+	progress []api.AgentImagePullProgress,
+) error {
+	db := internalctx.GetDb(ctx)
+	images := make([]string, len(progress))
+	bytesDone := make([]int64, len(progress))
+	bytesTotal := make([]int64, len(progress))
+	for i, p := range progress {
+		images[i], bytesDone[i], bytesTotal[i] = p.Image, p.BytesDone, p.BytesTotal
+	}
+	_, err := db.Exec(ctx,
+		`INSERT INTO DeploymentRevisionPullProgress (deployment_revision_id, image, bytes_done, bytes_total)
+		SELECT @deploymentRevisionId, p.image, p.bytes_done, p.bytes_total
+		FROM unnest(@images::TEXT[], @bytesDone::BIGINT[], @bytesTotal::BIGINT[]) AS p(image, bytes_done, bytes_total)
+		ON CONFLICT (deployment_revision_id, image) DO UPDATE SET
+			updated_at = current_timestamp,
+			bytes_done = EXCLUDED.bytes_done,
+			bytes_total = EXCLUDED.bytes_total`,
 		pgx.NamedArgs{
-			"deploymentRevisionIds": deploymentRevisionIDs,
-			"maxRows":               maxRows,
-			"before":                before,
-			"after":                 after,
+			"deploymentRevisionId": revisionID,
+			"images":               images,
+			"bytesDone":            bytesDone,
+			"bytesTotal":           bytesTotal,
 		},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query DeploymentRevisionStatus: %w", err)
-	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentRevisionStatus]); err != nil {
-		return nil, fmt.Errorf("failed to get DeploymentRevisionStatus: %w", err)
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.ForeignKeyViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrConflict, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetLatestDeploymentRevisionPullProgress returns the pull progress of all images of the latest revision of the
+// given deployment.
+func GetLatestDeploymentRevisionPullProgress(
+	ctx context.Context,
+	deploymentID uuid.UUID,
+) ([]types.DeploymentRevisionPullProgress, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT p.deployment_revision_id, p.image, p.updated_at, p.bytes_done, p.bytes_total
+		FROM DeploymentRevisionPullProgress p
+		WHERE p.deployment_revision_id = (
+			SELECT dr.id FROM DeploymentRevision dr
+			WHERE dr.deployment_id = @deploymentId
+			ORDER BY dr.created_at DESC
+			LIMIT 1
+		)
+		ORDER BY p.image`,
+		pgx.NamedArgs{"deploymentId": deploymentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DeploymentRevisionPullProgress: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.DeploymentRevisionPullProgress])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect DeploymentRevisionPullProgress: %w", err)
+	}
+	return result, nil
+}
+
+// RequestDeploymentUninstall marks the deployment for uninstallation by the agent. It returns apierrors.ErrConflict if
+// an uninstall has already been requested.
+func RequestDeploymentUninstall(
+	ctx context.Context,
+	deployment *types.Deployment,
+	userAccountID uuid.UUID,
+	deleteData bool,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`UPDATE Deployment AS d
+		SET uninstall_requested_at = current_timestamp,
+			uninstall_requested_by_user_account_id = @userAccountId,
+			uninstall_delete_data = @deleteData
+		WHERE id = @id AND uninstall_requested_at IS NULL
+		RETURNING`+deploymentOutputExpr,
+		pgx.NamedArgs{"id": deployment.ID, "userAccountId": userAccountID, "deleteData": deleteData},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Deployment: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.Deployment]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrConflict
+		}
+		return fmt.Errorf("could not update Deployment: %w", err)
 	} else {
-		return result, nil
+		*deployment = result
+		return nil
 	}
 }
+
+// MarkDeploymentUninstalled records that the agent has uninstalled the deployment of the given revision. Reports for
+// deployments without a pending uninstall are ignored.
+func MarkDeploymentUninstalled(ctx context.Context, revisionID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(
+		ctx,
+		`UPDATE Deployment d
+		SET uninstalled_at = current_timestamp
+		FROM DeploymentRevision dr
+		WHERE dr.id = @revisionId AND dr.deployment_id = d.id
+			AND d.uninstall_requested_at IS NOT NULL AND d.uninstalled_at IS NULL`,
+		pgx.NamedArgs{"revisionId": revisionID},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update Deployment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetDeploymentRevisionTimeline(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	g.Expect(revision.OperationID).NotTo(Equal(uuid.Nil))
+
+	err := db.CreateDeploymentRevisionStatus(
+		ctx, revision.ID, &revision.OperationID, types.DeploymentStatusTypeOK, "reported with operation ID", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	err = db.SaveDeploymentLogRecords(ctx, []api.DeploymentLogRecord{{
+		DeploymentID:         revision.DeploymentID,
+		DeploymentRevisionID: revision.ID,
+		OperationID:          &revision.OperationID,
+		Resource:             "app",
+		Timestamp:            revision.CreatedAt.Add(time.Second),
+		Severity:             "info",
+		Body:                 "started",
+	}})
+	g.Expect(err).NotTo(HaveOccurred())
+	// statuses of old agents do not contain the operation ID
+	err = db.BulkCreateDeploymentRevisionStatusWithCreatedAt(ctx, revision.ID, []types.DeploymentRevisionStatus{
+		{CreatedAt: revision.CreatedAt.Add(-time.Minute), Message: "before the revision window"},
+		{CreatedAt: revision.CreatedAt.Add(time.Minute), Message: "reported without operation ID"},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	withVersion, err := db.GetDeploymentRevision(ctx, revision.ID, revision.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+	events, err := db.GetDeploymentRevisionTimeline(ctx, *withVersion, 100)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(events).To(HaveLen(4))
+	g.Expect(events[0]).To(And(
+		HaveField("Type", types.DeploymentTimelineEventTypeRevisionCreated),
+		HaveField("Source", types.DeploymentTimelineEventSourceServer),
+	))
+	g.Expect(events[1]).To(And(
+		HaveField("Type", types.DeploymentTimelineEventTypeStatus),
+		HaveField("Message", "reported with operation ID"),
+		HaveField("Approximate", false),
+	))
+	g.Expect(events[2]).To(And(
+		HaveField("Type", types.DeploymentTimelineEventTypeLog),
+		HaveField("Resource", HaveValue(Equal("app"))),
+		HaveField("Approximate", false),
+	))
+	g.Expect(events[3]).To(And(
+		HaveField("Message", "reported without operation ID"),
+		HaveField("Approximate", true),
+	))
+
+	_, err = db.GetDeploymentRevision(ctx, revision.ID, uuid.New())
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestDeploymentRevisionStatusErrorReason(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	reason := types.DeploymentStatusErrorReasonSecretResolutionFailed
+
+	g.Expect(db.CreateDeploymentRevisionStatus(
+		ctx, revision.ID, nil, types.DeploymentStatusTypeOK, "ok", &reason)).To(Succeed())
+	g.Expect(db.CreateDeploymentRevisionStatus(
+		ctx, revision.ID, nil, types.DeploymentStatusTypeError, "could not resolve", &reason)).To(Succeed())
+
+	statuses, _, err := db.GetDeploymentStatus(ctx, revision.DeploymentID, pagination.Page{}, time.Time{}, time.Time{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(statuses).To(ConsistOf(
+		And(HaveField("Type", types.DeploymentStatusTypeOK), HaveField("ErrorReason", BeNil())),
+		And(HaveField("Type", types.DeploymentStatusTypeError), HaveField("ErrorReason", HaveValue(Equal(reason)))),
+	))
+}
+
+func TestDeploymentRevisionReason(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+	first := testutil.NewDeploymentRevision(ctx, t, target)
+	g.Expect(first.Reason).To(BeNil())
+
+	second, err := db.CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+		DeploymentID:         &first.DeploymentID,
+		DeploymentTargetID:   target.ID,
+		ApplicationVersionID: first.ApplicationVersionID,
+		Reason:               util.PtrTo("rotate certificates"),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(second.Reason).To(HaveValue(Equal("rotate certificates")))
+
+	revisions, err := db.GetDeploymentRevisions(ctx, first.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(revisions).To(ConsistOf(
+		And(HaveField("ID", first.ID), HaveField("Reason", BeNil())),
+		And(HaveField("ID", second.ID), HaveField("Reason", HaveValue(Equal("rotate certificates")))),
+	))
+}
@@ -2,8 +2,10 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/glasskube/distr/internal/apierrors"
 	internalctx "github.com/glasskube/distr/internal/context"
@@ -15,31 +17,72 @@ import (
 )
 
 const (
-	fileOutputExpr = "f.id, f.organization_id, f.created_at, f.content_type, f.data, f.file_name, f.file_size"
+	fileOutputExpr = "f.id, f.organization_id, f.created_at, f.content_type, f.data, f.file_name, f.file_size, " +
+		"f.content_hash, f.orphaned_at"
+	// fileReferencedExpr must contain a check for every column that references File
+	fileReferencedExpr = `(
+		EXISTS (SELECT 1 FROM UserAccount WHERE image_id = f.id)
+		OR EXISTS (SELECT 1 FROM Application WHERE image_id = f.id)
+		OR EXISTS (SELECT 1 FROM Artifact WHERE image_id = f.id)
+	)`
 )
 
+// CreateFile stores a new file. If an identical file with the same content type already exists in the same scope,
+// no new row is created and file is set to the existing one instead.
 func CreateFile(ctx context.Context, organizationID *uuid.UUID, file *types.File) error {
-	db := internalctx.GetDb(ctx)
-	rows, err := db.Query(ctx,
-		"INSERT INTO File AS f (organization_id, content_type, data, file_name, file_size) "+
-			"VALUES (@organization_id, @content_type, @data, @file_name, @file_size) "+
-			"RETURNING "+fileOutputExpr,
-		pgx.NamedArgs{
-			"organization_id": organizationID,
-			"content_type":    file.ContentType,
-			"data":            file.Data,
-			"file_name":       file.FileName,
-			"file_size":       file.FileSize,
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("could not query file: %w", err)
-	} else if created, err := pgx.CollectExactlyOneRow[types.File](rows, pgx.RowToStructByName); err != nil {
-		return fmt.Errorf("could not create file: %w", err)
-	} else {
-		*file = created
-		return nil
-	}
+	hash := sha256.Sum256(file.Data)
+	file.ContentHash = hash[:]
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		// Resetting orphaned_at locks the existing row, so a concurrent orphan cleanup can not delete it anymore.
+		rows, err := db.Query(ctx,
+			`UPDATE File AS f SET orphaned_at = NULL
+			WHERE f.id = (
+				SELECT id FROM File
+				WHERE content_hash = @content_hash
+					AND content_type = @content_type
+					AND organization_id IS NOT DISTINCT FROM @organization_id
+				ORDER BY created_at
+				LIMIT 1
+			)
+			RETURNING `+fileOutputExpr,
+			pgx.NamedArgs{
+				"organization_id": organizationID,
+				"content_type":    file.ContentType,
+				"content_hash":    file.ContentHash,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("could not query file: %w", err)
+		} else if existing, err := pgx.CollectExactlyOneRow[types.File](rows, pgx.RowToStructByName); err == nil {
+			*file = existing
+			return nil
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("could not map file: %w", err)
+		}
+
+		rows, err = db.Query(ctx,
+			"INSERT INTO File AS f (organization_id, content_type, data, file_name, file_size, content_hash) "+
+				"VALUES (@organization_id, @content_type, @data, @file_name, @file_size, @content_hash) "+
+				"RETURNING "+fileOutputExpr,
+			pgx.NamedArgs{
+				"organization_id": organizationID,
+				"content_type":    file.ContentType,
+				"data":            file.Data,
+				"file_name":       file.FileName,
+				"file_size":       file.FileSize,
+				"content_hash":    file.ContentHash,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("could not query file: %w", err)
+		} else if created, err := pgx.CollectExactlyOneRow[types.File](rows, pgx.RowToStructByName); err != nil {
+			return fmt.Errorf("could not create file: %w", err)
+		} else {
+			*file = created
+			return nil
+		}
+	})
 }
 
 func GetFileWithID(ctx context.Context, id uuid.UUID) (*types.File, error) {
@@ -61,15 +104,24 @@ func GetFileWithID(ctx context.Context, id uuid.UUID) (*types.File, error) {
 	}
 }
 
+// DeleteFileWithID deletes the file if it is not referenced anymore.
+// Since files are deduplicated, a file that is still referenced might be in use by someone else,
+// so apierrors.ErrConflict is returned in that case.
 func DeleteFileWithID(ctx context.Context, id uuid.UUID) error {
 	db := internalctx.GetDb(ctx)
-	cmd, err := db.Exec(ctx, `DELETE FROM file WHERE id = @id`, pgx.NamedArgs{"id": id})
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM File f WHERE f.id = @id AND NOT "+fileReferencedExpr,
+		pgx.NamedArgs{"id": id})
 	if err != nil {
 		if pgerr := (*pgconn.PgError)(nil); errors.As(err, &pgerr) && pgerr.Code == pgerrcode.ForeignKeyViolation {
 			err = fmt.Errorf("%w: %w", apierrors.ErrConflict, err)
 		}
 	} else if cmd.RowsAffected() == 0 {
-		err = apierrors.ErrNotFound
+		if _, err1 := GetFileWithID(ctx, id); err1 == nil {
+			err = fmt.Errorf("%w: file is still in use", apierrors.ErrConflict)
+		} else {
+			err = err1
+		}
 	}
 
 	if err != nil {
@@ -78,3 +130,47 @@ func DeleteFileWithID(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+// CleanupOrphanedFiles deletes all files that have not been referenced for longer than gracePeriod.
+//
+// Files are first marked as orphaned and only deleted in a later run, once the grace period has passed.
+// Candidates are locked before the reference check is repeated, so a file that gains a new reference concurrently
+// is never deleted.
+func CleanupOrphanedFiles(ctx context.Context, gracePeriod time.Duration) (count int64, size int64, err error) {
+	err = RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		if _, err := db.Exec(ctx,
+			"UPDATE File f SET orphaned_at = NULL WHERE f.orphaned_at IS NOT NULL AND "+fileReferencedExpr,
+		); err != nil {
+			return fmt.Errorf("could not unmark referenced files: %w", err)
+		}
+		if _, err := db.Exec(ctx,
+			"UPDATE File f SET orphaned_at = current_timestamp WHERE f.orphaned_at IS NULL AND NOT "+fileReferencedExpr,
+		); err != nil {
+			return fmt.Errorf("could not mark orphaned files: %w", err)
+		}
+		if _, err := db.Exec(ctx,
+			"SELECT f.id FROM File f WHERE f.orphaned_at < current_timestamp - @gracePeriod FOR UPDATE",
+			pgx.NamedArgs{"gracePeriod": gracePeriod},
+		); err != nil {
+			return fmt.Errorf("could not lock orphaned files: %w", err)
+		}
+		// this is a new statement, so it also sees references that were committed while waiting for the lock
+		rows, err := db.Query(ctx,
+			"DELETE FROM File f WHERE f.orphaned_at < current_timestamp - @gracePeriod AND NOT "+fileReferencedExpr+
+				" RETURNING f.file_size",
+			pgx.NamedArgs{"gracePeriod": gracePeriod},
+		)
+		if err != nil {
+			return fmt.Errorf("could not delete orphaned files: %w", err)
+		}
+		var fileSize int64
+		_, err = pgx.ForEachRow(rows, []any{&fileSize}, func() error {
+			count++
+			size += fileSize
+			return nil
+		})
+		return err
+	})
+	return
+}
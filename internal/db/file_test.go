@@ -0,0 +1,153 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func newFile(contentType string, data string) *types.File {
+	return &types.File{ContentType: contentType, Data: []byte(data), FileName: "file", FileSize: int64(len(data))}
+}
+
+func TestCreateFileDeduplicates(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+	other := testutil.NewOrganization(ctx, t)
+	data := "image-" + uuid.NewString()
+
+	file := newFile("image/png", data)
+	g.Expect(db.CreateFile(ctx, &org.ID, file)).To(Succeed())
+	g.Expect(file.ContentHash).To(HaveLen(32))
+
+	same := newFile("image/png", data)
+	g.Expect(db.CreateFile(ctx, &org.ID, same)).To(Succeed())
+	g.Expect(same.ID).To(Equal(file.ID))
+
+	otherType := newFile("image/jpeg", data)
+	g.Expect(db.CreateFile(ctx, &org.ID, otherType)).To(Succeed())
+	g.Expect(otherType.ID).NotTo(Equal(file.ID))
+
+	otherData := newFile("image/png", data+"-2")
+	g.Expect(db.CreateFile(ctx, &org.ID, otherData)).To(Succeed())
+	g.Expect(otherData.ID).NotTo(Equal(file.ID))
+
+	// files are only shared within the same scope
+	otherOrg := newFile("image/png", data)
+	g.Expect(db.CreateFile(ctx, &other.ID, otherOrg)).To(Succeed())
+	g.Expect(otherOrg.ID).NotTo(Equal(file.ID))
+	global := newFile("image/png", data)
+	g.Expect(db.CreateFile(ctx, nil, global)).To(Succeed())
+	g.Expect(global.ID).NotTo(Equal(file.ID))
+	sameGlobal := newFile("image/png", data)
+	g.Expect(db.CreateFile(ctx, nil, sameGlobal)).To(Succeed())
+	g.Expect(sameGlobal.ID).To(Equal(global.ID))
+}
+
+func TestCreateFileRevivesOrphanedFile(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+	data := "image-" + uuid.NewString()
+	file := newFile("image/png", data)
+	g.Expect(db.CreateFile(ctx, &org.ID, file)).To(Succeed())
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE File SET orphaned_at = now() - interval '2 hours' WHERE id = $1", file.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	same := newFile("image/png", data)
+	g.Expect(db.CreateFile(ctx, &org.ID, same)).To(Succeed())
+	g.Expect(same.ID).To(Equal(file.ID))
+	g.Expect(same.OrphanedAt).To(BeNil())
+}
+
+func TestCleanupOrphanedFiles(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+	user := testutil.NewUserAccount(ctx, t)
+	referenced := newFile("image/png", "referenced-"+uuid.NewString())
+	orphaned := newFile("image/png", "orphaned-"+uuid.NewString())
+	g.Expect(db.CreateFile(ctx, &org.ID, referenced)).To(Succeed())
+	g.Expect(db.CreateFile(ctx, &org.ID, orphaned)).To(Succeed())
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE UserAccount SET image_id = $1 WHERE id = $2", referenced.ID, user.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the first run only marks the unreferenced file
+	_, _, err = db.CleanupOrphanedFiles(ctx, time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	loaded, err := db.GetFileWithID(ctx, orphaned.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.OrphanedAt).NotTo(BeNil())
+	loaded, err = db.GetFileWithID(ctx, referenced.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.OrphanedAt).To(BeNil())
+
+	// the grace period has passed
+	_, err = internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE File SET orphaned_at = now() - interval '2 hours' WHERE id = ANY($1)",
+		[]uuid.UUID{referenced.ID, orphaned.ID})
+	g.Expect(err).NotTo(HaveOccurred())
+	count, size, err := db.CleanupOrphanedFiles(ctx, time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(count).To(BeNumerically(">=", 1))
+	g.Expect(size).To(BeNumerically(">=", orphaned.FileSize))
+	_, err = db.GetFileWithID(ctx, orphaned.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	loaded, err = db.GetFileWithID(ctx, referenced.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.OrphanedAt).To(BeNil(), "referenced files are unmarked")
+}
+
+func TestCleanupOrphanedFilesKeepsFileReferencedConcurrently(t *testing.T) {
+	g := NewWithT(t)
+	ctx := poolContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+	file := newFile("image/png", "concurrent-"+uuid.NewString())
+	g.Expect(db.CreateFile(ctx, nil, file)).To(Succeed())
+	t.Cleanup(func() {
+		deleteCommitted(ctx, t, `DELETE FROM UserAccount WHERE id = @id`, user.ID)
+		deleteCommitted(ctx, t, `DELETE FROM File WHERE id = @id`, file.ID)
+	})
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE File SET orphaned_at = now() - interval '2 hours' WHERE id = $1", file.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the new reference holds a key share lock on the file until it is committed
+	tx, err := testutil.Pool(t, nil).Begin(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = tx.Rollback(context.Background()) }()
+	_, err = tx.Exec(ctx, "UPDATE UserAccount SET image_id = $1 WHERE id = $2", file.ID, user.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := db.CleanupOrphanedFiles(ctx, time.Hour)
+		done <- err
+	}()
+	g.Eventually(func() (bool, error) {
+		var waiting bool
+		err := internalctx.GetDb(ctx).QueryRow(ctx,
+			`SELECT EXISTS (
+				SELECT 1 FROM pg_stat_activity
+				WHERE wait_event_type = 'Lock' AND query LIKE '%FROM File f WHERE f.orphaned_at <%FOR UPDATE%'
+			)`,
+		).Scan(&waiting)
+		return waiting, err
+	}).WithTimeout(5*time.Second).Should(BeTrue(), "the cleanup waits for the lock")
+	g.Expect(tx.Commit(ctx)).To(Succeed())
+
+	g.Eventually(done).WithTimeout(5 * time.Second).Should(Receive(Not(HaveOccurred())))
+	_, err = db.GetFileWithID(ctx, file.ID)
+	g.Expect(err).NotTo(HaveOccurred(), "the file is referenced again and must not be deleted")
+}
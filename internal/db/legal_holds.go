@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const legalHoldOutputExpr = `lh.id, lh.created_at, lh.organization_id, lh.user_account_id, lh.reason`
+
+// legalHoldExpr returns a condition that is true if the data of the organization and the user account given by the
+// two SQL expressions is under legal hold. userAccountIDExpr may evaluate to NULL for data without a user.
+// Cleanup queries must exclude all rows for which it is true.
+func legalHoldExpr(orgIDExpr, userAccountIDExpr string) string {
+	return `EXISTS (SELECT 1 FROM LegalHold lh WHERE lh.organization_id = ` + orgIDExpr +
+		` AND (lh.user_account_id IS NULL OR lh.user_account_id = ` + userAccountIDExpr + `))`
+}
+
+// legalHoldOfDeploymentTargetExpr is legalHoldExpr for data that belongs to the deployment target given by the SQL
+// expression. Deployment targets of customers are covered by a hold for their owner.
+func legalHoldOfDeploymentTargetExpr(deploymentTargetIDExpr string) string {
+	return `EXISTS (SELECT 1 FROM DeploymentTarget dt_lh WHERE dt_lh.id = ` + deploymentTargetIDExpr + ` AND ` +
+		legalHoldExpr("dt_lh.organization_id", "dt_lh.created_by_user_account_id") + `)`
+}
+
+func IsDeploymentTargetUnderLegalHold(ctx context.Context, deploymentTargetID uuid.UUID) (bool, error) {
+	db := internalctx.GetDb(ctx)
+	var result bool
+	if err := db.QueryRow(ctx, "SELECT "+legalHoldOfDeploymentTargetExpr("@deploymentTargetId"),
+		pgx.NamedArgs{"deploymentTargetId": deploymentTargetID}).Scan(&result); err != nil {
+		return false, fmt.Errorf("could not query LegalHold: %w", err)
+	}
+	return result, nil
+}
+
+func GetLegalHolds(ctx context.Context) (types.LegalHolds, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+legalHoldOutputExpr+" FROM LegalHold lh ORDER BY lh.organization_id, lh.created_at")
+	if err != nil {
+		return nil, fmt.Errorf("could not query LegalHold: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.LegalHold]); err != nil {
+		return nil, fmt.Errorf("could not collect LegalHold: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// CreateLegalHold places a legal hold. apierrors.ErrConflict is returned if a hold for the same scope exists.
+func CreateLegalHold(ctx context.Context, hold *types.LegalHold) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO LegalHold AS lh (organization_id, user_account_id, reason)
+		VALUES (@organizationId, @userAccountId, @reason)
+		RETURNING `+legalHoldOutputExpr,
+		pgx.NamedArgs{
+			"organizationId": hold.OrganizationID,
+			"userAccountId":  hold.UserAccountID,
+			"reason":         hold.Reason,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert LegalHold: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.LegalHold]); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrConflict, err)
+		}
+		return fmt.Errorf("could not insert LegalHold: %w", err)
+	} else {
+		*hold = result
+		return nil
+	}
+}
+
+// DeleteLegalHold releases the legal hold of an organization, or of one of its customer accounts if userAccountID is
+// not nil. The released hold is returned.
+func DeleteLegalHold(ctx context.Context, orgID uuid.UUID, userAccountID *uuid.UUID) (*types.LegalHold, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`DELETE FROM LegalHold lh
+		WHERE lh.organization_id = @organizationId AND lh.user_account_id IS NOT DISTINCT FROM @userAccountId
+		RETURNING `+legalHoldOutputExpr,
+		pgx.NamedArgs{"organizationId": orgID, "userAccountId": userAccountID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete LegalHold: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.LegalHold]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not delete LegalHold: %w", err)
+	} else {
+		return &result, nil
+	}
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// artifactLicensePullsExpr selects the pulls since @since of artifact versions covered by the license @licenseId
+// that were done by the license owner or by one of its seat holders.
+const artifactLicensePullsExpr = `
+	SELECT p.*, a.id AS artifact_id, a.name AS artifact_name
+	FROM ArtifactVersionPull p
+		JOIN ArtifactVersion v ON v.id = p.artifact_version_id
+		JOIN Artifact a ON a.id = v.artifact_id
+		JOIN ArtifactLicense al ON al.id = @licenseId
+	WHERE p.created_at >= @since
+		AND (al.owner_useraccount_id = p.useraccount_id OR EXISTS (
+			SELECT 1 FROM ArtifactLicenseSeat als
+			WHERE als.artifact_license_id = al.id AND als.useraccount_id = p.useraccount_id
+		))
+		AND EXISTS (
+			SELECT 1 FROM ArtifactLicense_Artifact ala
+			WHERE ala.artifact_license_id = al.id
+				AND ala.artifact_id = a.id
+				AND (ala.artifact_version_id IS NULL OR ala.artifact_version_id = v.id)
+		)
+`
+
+// GetArtifactLicenseImpact returns the recent pulls authorized by an artifact license, the deployment targets of the
+// users that did these pulls and the assigned seats of the license.
+func GetArtifactLicenseImpact(ctx context.Context, licenseID uuid.UUID, since time.Time) (*types.LicenseImpact, error) {
+	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{"licenseId": licenseID, "since": since}
+	impact := types.LicenseImpact{
+		Deployments: []types.LicenseImpactDeployment{},
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT p.artifact_id, p.artifact_name, u.id AS useraccount_id, u.email AS useraccount_email,
+			p.remote_address, count(*) AS pull_count, max(p.created_at) AS last_pulled_at
+		FROM (`+artifactLicensePullsExpr+`) p
+			JOIN UserAccount u ON u.id = p.useraccount_id
+		GROUP BY p.artifact_id, p.artifact_name, u.id, u.email, p.remote_address
+		ORDER BY last_pulled_at DESC`,
+		args,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query license pulls: %w", err)
+	} else if impact.RecentPulls, err = pgx.CollectRows(rows, pgx.RowToStructByName[types.LicenseImpactPull]); err != nil {
+		return nil, fmt.Errorf("could not collect license pulls: %w", err)
+	}
+	for i := range impact.RecentPulls {
+		if err := decryptPII(&impact.RecentPulls[i].UserAccountEmail); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err = db.Query(ctx,
+		`SELECT dt.id, dt.name
+		FROM DeploymentTarget dt
+			JOIN ArtifactLicense al ON al.id = @licenseId AND al.organization_id = dt.organization_id
+		WHERE dt.archived_at IS NULL
+			AND dt.created_by_user_account_id IN (SELECT p.useraccount_id FROM (`+artifactLicensePullsExpr+`) p)
+		ORDER BY dt.name`,
+		args,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query license deployment targets: %w", err)
+	} else if impact.DeploymentTargets, err = pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.LicenseImpactDeploymentTarget],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect license deployment targets: %w", err)
+	}
+
+	if impact.Seats, err = GetArtifactLicenseSeats(ctx, licenseID); err != nil {
+		return nil, err
+	}
+
+	return &impact, nil
+}
+
+// GetApplicationLicenseImpact returns the deployments that use an application license and its assigned seats.
+// Archived deployments are included, because they still reference the license.
+func GetApplicationLicenseImpact(ctx context.Context, licenseID uuid.UUID) (*types.LicenseImpact, error) {
+	db := internalctx.GetDb(ctx)
+	impact := types.LicenseImpact{
+		RecentPulls:       []types.LicenseImpactPull{},
+		DeploymentTargets: []types.LicenseImpactDeploymentTarget{},
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT d.id, dt.id AS deployment_target_id, dt.name AS deployment_target_name, d.archived_at
+		FROM Deployment d
+			JOIN DeploymentTarget dt ON dt.id = d.deployment_target_id
+		WHERE d.application_license_id = @licenseId
+		ORDER BY dt.name, d.created_at`,
+		pgx.NamedArgs{"licenseId": licenseID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query license deployments: %w", err)
+	} else if impact.Deployments, err = pgx.CollectRows(
+		rows, pgx.RowToStructByName[types.LicenseImpactDeployment],
+	); err != nil {
+		return nil, fmt.Errorf("could not collect license deployments: %w", err)
+	}
+
+	if impact.Seats, err = GetApplicationLicenseSeats(ctx, licenseID); err != nil {
+		return nil, err
+	}
+
+	return &impact, nil
+}
@@ -0,0 +1,130 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetArtifactLicenseImpact(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	withPIIKeys(t, piiKey1)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 3)
+	vendor, owner, seatHolder, other := org.Vendors[0], org.Customers[0], org.Customers[1], org.Customers[2]
+	licensed, licensedVersions := testutil.NewArtifactWithTags(ctx, t, org.ID, vendor.ID, "1.0.0", "2.0.0")
+	_, unlicensedVersions := testutil.NewArtifactWithTags(ctx, t, org.ID, vendor.ID, "1.0.0")
+	license := types.ArtifactLicenseBase{
+		Name:               "license",
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &owner.ID,
+		SeatCount:          util.PtrTo(1),
+	}
+	g.Expect(db.CreateArtifactLicense(ctx, &license)).To(Succeed())
+	g.Expect(db.AddArtifactToArtifactLicense(ctx, license.ID, licensed.ID, &licensedVersions[0].ID)).To(Succeed())
+	g.Expect(db.CreateArtifactLicenseSeat(ctx, license.ID, seatHolder.ID)).To(Succeed())
+
+	pull := func(versionID, userID uuid.UUID, remoteAddress string) {
+		t.Helper()
+		_, err := internalctx.GetDb(ctx).Exec(ctx,
+			`INSERT INTO ArtifactVersionPull (artifact_version_id, useraccount_id, remote_address)
+			VALUES ($1, $2, $3)`,
+			versionID, userID, remoteAddress)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	pull(licensedVersions[0].ID, owner.ID, "10.0.0.1")
+	pull(licensedVersions[0].ID, owner.ID, "10.0.0.1")
+	pull(licensedVersions[0].ID, seatHolder.ID, "10.0.0.2")
+	// pulls of users that do not hold the license and of versions that it does not cover are not affected
+	pull(licensedVersions[0].ID, other.ID, "10.0.0.3")
+	pull(licensedVersions[1].ID, owner.ID, "10.0.0.1")
+	pull(unlicensedVersions[0].ID, owner.ID, "10.0.0.1")
+
+	ownerTarget := testutil.NewDeploymentTarget(ctx, t, org.ID, owner.ID)
+	testutil.NewDeploymentTarget(ctx, t, org.ID, other.ID)
+	archived := testutil.NewDeploymentTarget(ctx, t, org.ID, seatHolder.ID)
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE DeploymentTarget SET archived_at = now() WHERE id = $1", archived.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	impact, err := db.GetArtifactLicenseImpact(ctx, license.ID, time.Now().Add(-time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(impact.RecentPulls).To(ConsistOf(
+		And(
+			HaveField("ArtifactID", licensed.ID),
+			HaveField("UserAccountID", owner.ID),
+			HaveField("UserAccountEmail", owner.Email),
+			HaveField("RemoteAddress", HaveValue(Equal("10.0.0.1"))),
+			HaveField("PullCount", 2),
+		),
+		And(
+			HaveField("UserAccountID", seatHolder.ID),
+			HaveField("UserAccountEmail", seatHolder.Email),
+			HaveField("PullCount", 1),
+		),
+	))
+	g.Expect(impact.DeploymentTargets).To(ConsistOf(HaveField("ID", ownerTarget.ID)))
+	g.Expect(impact.Deployments).To(BeEmpty())
+	g.Expect(impact.Seats).To(ConsistOf(HaveField("UserAccount.ID", seatHolder.ID)))
+	g.Expect(impact.IsEmpty()).To(BeFalse())
+
+	// pulls before the given time are not recent
+	impact, err = db.GetArtifactLicenseImpact(ctx, license.ID, time.Now().Add(time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(impact.RecentPulls).To(BeEmpty())
+	g.Expect(impact.DeploymentTargets).To(BeEmpty())
+}
+
+func TestGetApplicationLicenseImpact(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Customers[0].ID)
+	active := testutil.NewDeploymentRevision(ctx, t, target)
+	archived := testutil.NewDeploymentRevision(ctx, t, target)
+	unlicensed := testutil.NewDeploymentRevision(ctx, t, target)
+	app := types.Application{Name: "app", Type: types.DeploymentTypeDocker}
+	g.Expect(db.CreateApplication(ctx, &app, org.ID)).To(Succeed())
+	license := types.ApplicationLicenseBase{
+		Name:               "license",
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &org.Customers[0].ID,
+	}
+	g.Expect(db.CreateApplicationLicense(ctx, &license)).To(Succeed())
+
+	impact, err := db.GetApplicationLicenseImpact(ctx, license.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(impact.IsEmpty()).To(BeTrue())
+
+	_, err = internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE Deployment SET application_license_id = $1 WHERE id = ANY($2)",
+		license.ID, []uuid.UUID{active.DeploymentID, archived.DeploymentID})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE Deployment SET archived_at = now() WHERE id = $1", archived.DeploymentID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	impact, err = db.GetApplicationLicenseImpact(ctx, license.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(impact.Deployments).To(ConsistOf(
+		And(
+			HaveField("ID", active.DeploymentID),
+			HaveField("DeploymentTargetID", target.ID),
+			HaveField("DeploymentTargetName", target.Name),
+			HaveField("ArchivedAt", BeNil()),
+		),
+		And(HaveField("ID", archived.DeploymentID), HaveField("ArchivedAt", Not(BeNil()))),
+	))
+	g.Expect(impact.Deployments).NotTo(ContainElement(HaveField("ID", unlicensed.DeploymentID)))
+	g.Expect(impact.RecentPulls).To(BeEmpty())
+	g.Expect(impact.DeploymentTargets).To(BeEmpty())
+	g.Expect(impact.IsEmpty()).To(BeFalse())
+}
@@ -0,0 +1,139 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestApplicationLicenseSeats(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 0, 4)
+	owner, first, second, third := org.Customers[0], org.Customers[1], org.Customers[2], org.Customers[3]
+	app := types.Application{Name: "app", Type: types.DeploymentTypeDocker}
+	g.Expect(db.CreateApplication(ctx, &app, org.ID)).To(Succeed())
+	license := types.ApplicationLicenseBase{
+		Name:               "license",
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &owner.ID,
+		SeatCount:          util.PtrTo(2),
+	}
+	g.Expect(db.CreateApplicationLicense(ctx, &license)).To(Succeed())
+
+	apps, err := db.GetApplicationsWithLicenseOwnerID(ctx, first.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(BeEmpty())
+
+	g.Expect(db.CreateApplicationLicenseSeat(ctx, license.ID, first.ID)).To(Succeed())
+	// assigning a seat twice does not use another seat
+	g.Expect(db.CreateApplicationLicenseSeat(ctx, license.ID, first.ID)).To(Succeed())
+	g.Expect(db.CreateApplicationLicenseSeat(ctx, license.ID, second.ID)).To(Succeed())
+	g.Expect(db.CreateApplicationLicenseSeat(ctx, license.ID, third.ID)).To(MatchError(apierrors.ErrConflict))
+
+	loaded, err := db.GetApplicationLicenseByID(ctx, license.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.SeatsUsed).To(Equal(2))
+	g.Expect(loaded.IsHeldBy(first.ID)).To(BeTrue())
+	g.Expect(loaded.IsHeldBy(third.ID)).To(BeFalse())
+	seats, err := db.GetApplicationLicenseSeats(ctx, license.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(seats).To(ConsistOf(
+		HaveField("UserAccount.ID", first.ID),
+		HaveField("UserAccount.ID", second.ID),
+	))
+	apps, err = db.GetApplicationsWithLicenseOwnerID(ctx, first.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(ConsistOf(HaveField("ID", app.ID)))
+
+	// releasing a seat revokes access immediately and frees the seat for another user
+	g.Expect(db.DeleteApplicationLicenseSeat(ctx, license.ID, first.ID)).To(Succeed())
+	g.Expect(db.DeleteApplicationLicenseSeat(ctx, license.ID, first.ID)).To(MatchError(apierrors.ErrNotFound))
+	apps, err = db.GetApplicationsWithLicenseOwnerID(ctx, first.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apps).To(BeEmpty())
+	g.Expect(db.CreateApplicationLicenseSeat(ctx, license.ID, third.ID)).To(Succeed())
+
+	g.Expect(db.DeleteApplicationLicenseSeatsOfUserInOrg(ctx, second.ID, org.ID)).To(Succeed())
+	seats, err = db.GetApplicationLicenseSeats(ctx, license.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(seats).To(ConsistOf(HaveField("UserAccount.ID", third.ID)))
+}
+
+func TestApplicationLicenseWithoutSeats(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 0, 2)
+	app := types.Application{Name: "app", Type: types.DeploymentTypeDocker}
+	g.Expect(db.CreateApplication(ctx, &app, org.ID)).To(Succeed())
+	license := types.ApplicationLicenseBase{
+		Name:               "license",
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &org.Customers[0].ID,
+	}
+	g.Expect(db.CreateApplicationLicense(ctx, &license)).To(Succeed())
+
+	g.Expect(db.CreateApplicationLicenseSeat(ctx, license.ID, org.Customers[1].ID)).
+		To(MatchError(apierrors.ErrConflict))
+}
+
+func TestArtifactLicenseSeats(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 4)
+	owner, first, second, third := org.Customers[0], org.Customers[1], org.Customers[2], org.Customers[3]
+	artifact, _ := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0")
+	license := types.ArtifactLicenseBase{
+		Name:               "license",
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &owner.ID,
+		SeatCount:          util.PtrTo(2),
+	}
+	g.Expect(db.CreateArtifactLicense(ctx, &license)).To(Succeed())
+	g.Expect(db.AddArtifactToArtifactLicense(ctx, license.ID, artifact.ID, nil)).To(Succeed())
+
+	g.Expect(db.CheckLicenseForArtifact(ctx, *org.Slug, artifact.Name, "1.0.0", owner.ID)).To(Succeed())
+	g.Expect(db.CheckLicenseForArtifact(ctx, *org.Slug, artifact.Name, "1.0.0", first.ID)).
+		To(MatchError(apierrors.ErrForbidden))
+
+	g.Expect(db.CreateArtifactLicenseSeat(ctx, license.ID, first.ID)).To(Succeed())
+	g.Expect(db.CreateArtifactLicenseSeat(ctx, license.ID, first.ID)).To(Succeed())
+	g.Expect(db.CreateArtifactLicenseSeat(ctx, license.ID, second.ID)).To(Succeed())
+	g.Expect(db.CreateArtifactLicenseSeat(ctx, license.ID, third.ID)).To(MatchError(apierrors.ErrConflict))
+
+	loaded, err := db.GetArtifactLicenseByID(ctx, license.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.SeatCount).To(HaveValue(Equal(2)))
+	g.Expect(loaded.SeatsUsed).To(Equal(2))
+	g.Expect(db.CheckLicenseForArtifact(ctx, *org.Slug, artifact.Name, "1.0.0", first.ID)).To(Succeed())
+	g.Expect(db.CheckLicenseForArtifact(ctx, *org.Slug, artifact.Name, "1.0.0", third.ID)).
+		To(MatchError(apierrors.ErrForbidden))
+	artifacts, err := db.GetArtifactsByLicenseOwnerID(ctx, org.ID, first.ID, "", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(artifacts).To(ConsistOf(HaveField("ID", artifact.ID)))
+	impact, err := db.GetArtifactLicenseImpact(ctx, license.ID, time.Now().Add(-time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(impact.Seats).To(HaveLen(2))
+
+	// releasing a seat revokes access to the registry immediately and frees the seat for another user
+	g.Expect(db.DeleteArtifactLicenseSeat(ctx, license.ID, first.ID)).To(Succeed())
+	g.Expect(db.DeleteArtifactLicenseSeat(ctx, license.ID, first.ID)).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.CheckLicenseForArtifact(ctx, *org.Slug, artifact.Name, "1.0.0", first.ID)).
+		To(MatchError(apierrors.ErrForbidden))
+	g.Expect(db.CreateArtifactLicenseSeat(ctx, license.ID, third.ID)).To(Succeed())
+
+	g.Expect(db.DeleteArtifactLicenseSeatsOfUserInOrg(ctx, second.ID, org.ID)).To(Succeed())
+	g.Expect(db.CheckLicenseForArtifact(ctx, *org.Slug, artifact.Name, "1.0.0", second.ID)).
+		To(MatchError(apierrors.ErrForbidden))
+	seats, err := db.GetArtifactLicenseSeats(ctx, license.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(seats).To(ConsistOf(HaveField("UserAccount.ID", third.ID)))
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaintenanceModeChannel is the channel that is notified whenever maintenance mode is entered, changed or left.
+// Notifications are only delivered once the surrounding transaction is committed.
+const MaintenanceModeChannel = "maintenance_mode"
+
+const maintenanceModeOutputExpr = `
+	m.id, m.created_at, m.organization_id, m.created_by_useraccount_id, m.reason, m.retry_after_seconds
+`
+
+func GetMaintenanceModes(ctx context.Context) ([]types.MaintenanceMode, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, "SELECT "+maintenanceModeOutputExpr+" FROM MaintenanceMode m")
+	if err != nil {
+		return nil, fmt.Errorf("could not query MaintenanceMode: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.MaintenanceMode]); err != nil {
+		return nil, fmt.Errorf("could not collect MaintenanceMode: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// PutMaintenanceMode enters maintenance mode for mode.OrganizationID or updates reason and retry after if it is
+// already active.
+func PutMaintenanceMode(ctx context.Context, mode *types.MaintenanceMode) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO MaintenanceMode AS m (organization_id, created_by_useraccount_id, reason, retry_after_seconds)
+		VALUES (@organizationId, @createdBy, @reason, @retryAfterSeconds)
+		ON CONFLICT (organization_id) DO UPDATE SET
+			reason = EXCLUDED.reason,
+			retry_after_seconds = EXCLUDED.retry_after_seconds
+		RETURNING `+maintenanceModeOutputExpr,
+		pgx.NamedArgs{
+			"organizationId":    mode.OrganizationID,
+			"createdBy":         mode.CreatedByUserAccountID,
+			"reason":            mode.Reason,
+			"retryAfterSeconds": mode.RetryAfterSeconds,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert MaintenanceMode: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.MaintenanceMode]); err != nil {
+		return fmt.Errorf("could not insert MaintenanceMode: %w", err)
+	} else {
+		*mode = result
+		return notifyMaintenanceMode(ctx)
+	}
+}
+
+// DeleteMaintenanceMode leaves maintenance mode for an organization or the whole server if orgID is nil.
+// The deleted maintenance mode is returned.
+func DeleteMaintenanceMode(ctx context.Context, orgID *uuid.UUID) (*types.MaintenanceMode, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`DELETE FROM MaintenanceMode m
+		WHERE m.organization_id IS NOT DISTINCT FROM @organizationId
+		RETURNING `+maintenanceModeOutputExpr,
+		pgx.NamedArgs{"organizationId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete MaintenanceMode: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.MaintenanceMode]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not delete MaintenanceMode: %w", err)
+	} else {
+		return &result, notifyMaintenanceMode(ctx)
+	}
+}
+
+func notifyMaintenanceMode(ctx context.Context) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(ctx, "SELECT pg_notify(@channel, '')", pgx.NamedArgs{"channel": MaintenanceModeChannel})
+	if err != nil {
+		return fmt.Errorf("could not notify %v: %w", MaintenanceModeChannel, err)
+	}
+	return nil
+}
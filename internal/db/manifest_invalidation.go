@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+// ManifestInvalidationChannel is the channel that is notified whenever manifests have been pushed, so that all
+// instances of the registry can remove them from their manifest cache. The payload is a types.ManifestInvalidation
+// serialized as JSON. Notifications are only delivered once the surrounding transaction is committed.
+const ManifestInvalidationChannel = "manifest_invalidation"
+
+func NotifyManifestInvalidation(ctx context.Context, invalidation types.ManifestInvalidation) error {
+	db := internalctx.GetDb(ctx)
+	if payload, err := json.Marshal(invalidation); err != nil {
+		return err
+	} else if _, err := db.Exec(ctx, "SELECT pg_notify(@channel, @payload)",
+		pgx.NamedArgs{"channel": ManifestInvalidationChannel, "payload": string(payload)}); err != nil {
+		return fmt.Errorf("could not notify %v: %w", ManifestInvalidationChannel, err)
+	}
+	return nil
+}
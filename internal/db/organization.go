@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/glasskube/distr/internal/apierrors"
 	internalctx "github.com/glasskube/distr/internal/context"
@@ -23,34 +25,150 @@ const (
 		o.features,
 		o.app_domain,
 		o.registry_domain,
-		o.email_from_address
+		o.email_from_address,
+		o.status_badges_disabled,
+		o.deployment_reason_policy,
+		o.deployment_uninstall_policy,
+		o.deployment_auto_rollback_window_seconds,
+		o.secret_scan_policy,
+		o.timezone,
+		o.business_hours,
+		o.agent_resource_limits,
+		o.registry_index_child_check_disabled
 	`
 	organizationWithUserRoleOutputExpr = organizationOutputExpr + ", j.user_role, j.created_at as joined_org_at "
 )
 
+// CreateOrganization creates org. If another organization already has the same name (compared case-insensitively) or
+// slug, the smallest free numeric suffix is appended to them, e.g. "Acme (2)" and "acme-2", so that concurrent signups
+// of the same company can still be told apart. org contains the name and slug that have actually been used.
 func CreateOrganization(ctx context.Context, org *types.Organization) error {
+	return RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		// serializes the creation of organizations with the same name or slug until the end of the transaction
+		if _, err := db.Exec(ctx,
+			"SELECT pg_advisory_xact_lock(hashtext('Organization.name:' || lower(@name)))",
+			pgx.NamedArgs{"name": org.Name},
+		); err != nil {
+			return fmt.Errorf("could not lock organization name: %w", err)
+		}
+		if org.Slug != nil {
+			if _, err := db.Exec(ctx,
+				"SELECT pg_advisory_xact_lock(hashtext('Organization.slug:' || @slug))",
+				pgx.NamedArgs{"slug": org.Slug},
+			); err != nil {
+				return fmt.Errorf("could not lock organization slug: %w", err)
+			}
+		}
+
+		name, err := availableOrganizationName(ctx, org.Name)
+		if err != nil {
+			return err
+		}
+		slug := org.Slug
+		if slug != nil {
+			if available, err := availableOrganizationSlug(ctx, *slug); err != nil {
+				return err
+			} else {
+				slug = &available
+			}
+		}
+
+		rows, err := db.Query(ctx,
+			"INSERT INTO Organization AS o (name, slug) VALUES (@name, @slug) RETURNING "+organizationOutputExpr,
+			pgx.NamedArgs{"name": name, "slug": slug},
+		)
+		if err != nil {
+			return fmt.Errorf("could not create orgnization: %w", err)
+		}
+		result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.Organization])
+		if err != nil {
+			// the slug can still be taken by an organization that has been renamed concurrently
+			var pgError *pgconn.PgError
+			if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+				err = fmt.Errorf("%w: %w", apierrors.ErrConflict, err)
+			}
+			return fmt.Errorf("could not create orgnization: %w", err)
+		} else {
+			*org = *result
+			return nil
+		}
+	})
+}
+
+// availableOrganizationName returns name, or name with the smallest suffix " (n)" that is not used by another
+// organization.
+func availableOrganizationName(ctx context.Context, name string) (string, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
-		"INSERT INTO Organization AS o (name, slug) VALUES (@name, @slug) RETURNING "+organizationOutputExpr,
-		pgx.NamedArgs{"name": org.Name, "slug": org.Slug},
+		"SELECT lower(name) FROM Organization WHERE lower(name) = lower(@name) OR lower(name) LIKE lower(@pattern)",
+		pgx.NamedArgs{"name": name, "pattern": likeEscaper.Replace(name) + " (%)"},
 	)
 	if err != nil {
-		return fmt.Errorf("could not create orgnization: %w", err)
+		return "", fmt.Errorf("could not query organization names: %w", err)
 	}
-	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.Organization])
+	taken, err := pgx.CollectRows(rows, pgx.RowTo[string])
 	if err != nil {
-		return err
-	} else {
-		*org = *result
-		return nil
+		return "", fmt.Errorf("could not query organization names: %w", err)
+	}
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = fmt.Sprintf("%v (%d)", name, n)
+		}
+		if !slices.Contains(taken, strings.ToLower(candidate)) {
+			return candidate, nil
+		}
+	}
+}
+
+// availableOrganizationSlug returns slug, or slug with the smallest suffix "-n" that is not used by another
+// organization. slug is shortened if the result would be longer than [types.OrganizationSlugMaxLength].
+func availableOrganizationSlug(ctx context.Context, slug string) (string, error) {
+	db := internalctx.GetDb(ctx)
+	for n := 1; ; n++ {
+		candidate := slug
+		if n > 1 {
+			suffix := fmt.Sprintf("-%d", n)
+			base := slug[:min(len(slug), types.OrganizationSlugMaxLength-len(suffix))]
+			candidate = strings.TrimRight(base, "-._") + suffix
+		}
+		var exists bool
+		if err := db.QueryRow(ctx,
+			"SELECT exists(SELECT 1 FROM Organization WHERE slug = @slug)",
+			pgx.NamedArgs{"slug": candidate},
+		).Scan(&exists); err != nil {
+			return "", fmt.Errorf("could not query organization slugs: %w", err)
+		} else if !exists {
+			return candidate, nil
+		}
 	}
 }
 
 func UpdateOrganization(ctx context.Context, org *types.Organization) error {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
-		"UPDATE Organization AS o SET name = @name, slug = @slug WHERE id = @id RETURNING "+organizationOutputExpr,
-		pgx.NamedArgs{"id": org.ID, "name": org.Name, "slug": org.Slug},
+		"UPDATE Organization AS o SET name = @name, slug = @slug, status_badges_disabled = @statusBadgesDisabled, "+
+			"deployment_reason_policy = @deploymentReasonPolicy, deployment_uninstall_policy = @deploymentUninstallPolicy, "+
+			"deployment_auto_rollback_window_seconds = @deploymentAutoRollbackWindowSeconds, "+
+			"secret_scan_policy = @secretScanPolicy, "+
+			"timezone = @timezone, business_hours = @businessHours, agent_resource_limits = @agentResourceLimits, "+
+			"registry_index_child_check_disabled = @registryIndexChildCheckDisabled "+
+			"WHERE id = @id RETURNING "+organizationOutputExpr,
+		pgx.NamedArgs{
+			"id":                                  org.ID,
+			"name":                                org.Name,
+			"slug":                                org.Slug,
+			"statusBadgesDisabled":                org.StatusBadgesDisabled,
+			"deploymentReasonPolicy":              org.DeploymentReasonPolicy,
+			"deploymentUninstallPolicy":           org.DeploymentUninstallPolicy,
+			"timezone":                            org.Timezone,
+			"businessHours":                       org.BusinessHours,
+			"deploymentAutoRollbackWindowSeconds": org.DeploymentAutoRollbackWindowSeconds,
+			"secretScanPolicy":                    org.SecretScanPolicy,
+			"agentResourceLimits":                 org.AgentResourceLimits,
+			"registryIndexChildCheckDisabled":     org.RegistryIndexChildCheckDisabled,
+		},
 	)
 	if err != nil {
 		return err
@@ -88,6 +206,16 @@ func GetOrganizationsForUser(ctx context.Context, userID uuid.UUID) ([]types.Org
 	}
 }
 
+// GetOrganizations returns all organizations of the server.
+func GetOrganizations(ctx context.Context) ([]types.Organization, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, "SELECT "+organizationOutputExpr+" FROM Organization o ORDER BY o.created_at")
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[types.Organization])
+}
+
 func GetOrganizationByID(ctx context.Context, orgID uuid.UUID) (*types.Organization, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
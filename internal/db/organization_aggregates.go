@@ -0,0 +1,330 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const organizationAggregateOutputExpr = `
+	o.id AS organization_id,
+	n.name,
+	oa.data,
+	oa.computed_at,
+	oa.refresh_requested_at
+`
+
+// organizationAggregateDataExprs compute the data of each aggregate for the organization o.
+var organizationAggregateDataExprs = map[types.AggregateName]string{
+	types.AggregateStorageUsage: `(
+		SELECT jsonb_build_object(
+			'artifactCount', (SELECT count(*) FROM Artifact a WHERE a.organization_id = o.id),
+			'versionCount', (
+				SELECT count(*) FROM ArtifactVersion av JOIN Artifact a ON a.id = av.artifact_id
+				WHERE a.organization_id = o.id
+			),
+			'blobCount', count(b.digest),
+			'totalBytes', coalesce(sum(b.size), 0)
+		)
+		FROM (
+			SELECT blob.digest, max(blob.size) AS size
+			FROM Artifact a
+			JOIN ArtifactVersion av ON av.artifact_id = a.id
+			CROSS JOIN LATERAL (
+				SELECT av.manifest_blob_digest AS digest, av.manifest_blob_size AS size
+				UNION ALL
+				SELECT avp.artifact_blob_digest, avp.artifact_blob_size
+				FROM ArtifactVersionPart avp WHERE avp.artifact_version_id = av.id
+			) blob
+			WHERE a.organization_id = o.id
+			GROUP BY blob.digest
+		) b
+	)`,
+	types.AggregateTargetHealth: `(
+		SELECT jsonb_build_object(
+			'total', count(*),
+			'online', count(*) FILTER (WHERE s.created_at >= current_timestamp - INTERVAL '1 minute'),
+			'stale', count(*) FILTER (WHERE s.created_at < current_timestamp - INTERVAL '1 minute'),
+			'neverConnected', count(*) FILTER (WHERE s.created_at IS NULL),
+			'paused', count(*) FILTER (WHERE dt.paused_at IS NOT NULL)
+		)
+		FROM DeploymentTarget dt
+		LEFT JOIN LATERAL (
+			SELECT max(dts.created_at) AS created_at FROM DeploymentTargetStatus dts
+			WHERE dts.deployment_target_id = dt.id
+		) s ON true
+		WHERE dt.organization_id = o.id AND dt.archived_at IS NULL
+	)`,
+	types.AggregateTargetUptime: `(
+		SELECT coalesce(jsonb_agg(jsonb_build_object(
+			'deploymentTargetId', x.id,
+			'hoursOnline', x.online,
+			'hoursTotal', x.total,
+			'uptime', round(x.online::numeric / x.total, 4)
+		) ORDER BY x.id), '[]'::jsonb)
+		FROM (
+			SELECT
+				dt.id,
+				(
+					SELECT count(*) FROM DeploymentTargetUptimeHour u
+					WHERE u.deployment_target_id = dt.id AND u.hour >= w.start
+				) AS online,
+				(floor(extract(epoch FROM current_timestamp - w.start) / 3600) + 1)::int AS total
+			FROM DeploymentTarget dt
+			CROSS JOIN LATERAL (
+				SELECT greatest(
+					date_trunc('hour', current_timestamp - INTERVAL '30 days'),
+					date_trunc('hour', dt.created_at)
+				) AS start
+			) w
+			WHERE dt.organization_id = o.id AND dt.archived_at IS NULL
+		) x
+	)`,
+	types.AggregateLicenseUtilization: `jsonb_build_object(
+		'applicationLicenses', (
+			SELECT jsonb_build_object(
+				'total', count(*),
+				'assigned', count(*) FILTER (WHERE al.owner_useraccount_id IS NOT NULL),
+				'expired', count(*) FILTER (WHERE al.expires_at <= current_timestamp),
+				'inUse', count(*) FILTER (
+					WHERE (al.expires_at IS NULL OR al.expires_at > current_timestamp)
+						AND EXISTS (
+							SELECT 1 FROM Deployment d WHERE d.application_license_id = al.id AND d.archived_at IS NULL
+						)
+				)
+			)
+			FROM ApplicationLicense al WHERE al.organization_id = o.id
+		),
+		'artifactLicenses', (
+			SELECT jsonb_build_object(
+				'total', count(*),
+				'assigned', count(*) FILTER (WHERE al.owner_useraccount_id IS NOT NULL),
+				'expired', count(*) FILTER (WHERE al.expires_at <= current_timestamp)
+			)
+			FROM ArtifactLicense al WHERE al.organization_id = o.id
+		)
+	)`,
+	types.AggregateVersionDistribution: `(
+		SELECT coalesce(jsonb_agg(jsonb_build_object(
+			'applicationId', x.application_id,
+			'applicationName', x.application_name,
+			'applicationVersionId', x.application_version_id,
+			'applicationVersionName', x.application_version_name,
+			'deploymentCount', x.deployment_count,
+			'deploymentTargetCount', x.deployment_target_count
+		) ORDER BY x.application_name, x.application_id, x.created_at DESC, x.application_version_name), '[]'::jsonb)
+		FROM (
+			SELECT
+				a.id AS application_id,
+				a.name AS application_name,
+				av.id AS application_version_id,
+				av.name AS application_version_name,
+				av.created_at,
+				count(*) AS deployment_count,
+				count(DISTINCT dt.id) AS deployment_target_count
+			FROM (SELECT current_timestamp::TIMESTAMP AS at) p
+			` + versionDistributionJoinExpr + `
+			JOIN Application a ON a.id = av.application_id
+			WHERE dt.organization_id = o.id
+			GROUP BY a.id, av.id
+		) x
+	)`,
+}
+
+// GetOrganizationAggregates returns all aggregates of an organization, including those that have not been computed
+// yet.
+func GetOrganizationAggregates(ctx context.Context, orgID uuid.UUID) ([]types.OrganizationAggregate, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+organizationAggregateOutputExpr+`
+		FROM Organization o
+		CROSS JOIN unnest(@names::TEXT[]) n(name)
+		LEFT JOIN OrganizationAggregate oa ON oa.organization_id = o.id AND oa.name = n.name
+		WHERE o.id = @orgId
+		ORDER BY n.name`,
+		pgx.NamedArgs{"orgId": orgID, "names": aggregateNameStrings(types.AggregateNames)},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OrganizationAggregate: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.OrganizationAggregate]); err != nil {
+		return nil, fmt.Errorf("could not collect OrganizationAggregate: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+func GetOrganizationAggregate(
+	ctx context.Context,
+	orgID uuid.UUID,
+	name types.AggregateName,
+) (*types.OrganizationAggregate, error) {
+	if _, ok := organizationAggregateDataExprs[name]; !ok {
+		return nil, apierrors.ErrNotFound
+	}
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT`+organizationAggregateOutputExpr+`
+		FROM Organization o
+		CROSS JOIN (SELECT @name::TEXT AS name) n
+		LEFT JOIN OrganizationAggregate oa ON oa.organization_id = o.id AND oa.name = n.name
+		WHERE o.id = @orgId`,
+		pgx.NamedArgs{"orgId": orgID, "name": string(name)},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OrganizationAggregate: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToAddrOfStructByName[types.OrganizationAggregate],
+	); errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get OrganizationAggregate: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// RequestOrganizationAggregateRefresh marks the aggregates of an organization to be recomputed by the next job run
+// before any other aggregates. A pending request is kept unchanged.
+func RequestOrganizationAggregateRefresh(ctx context.Context, orgID uuid.UUID, names []types.AggregateName) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`INSERT INTO OrganizationAggregate AS oa (organization_id, name, refresh_requested_at)
+		SELECT @orgId, n.name, current_timestamp FROM unnest(@names::TEXT[]) n(name)
+		ON CONFLICT (organization_id, name) DO UPDATE
+		SET refresh_requested_at = coalesce(oa.refresh_requested_at, EXCLUDED.refresh_requested_at)`,
+		pgx.NamedArgs{"orgId": orgID, "names": aggregateNameStrings(names)},
+	); err != nil {
+		return fmt.Errorf("could not update OrganizationAggregate: %w", err)
+	}
+	return nil
+}
+
+// TryLockOrganizationAggregate acquires a transaction level lock for recomputing the aggregate name. It returns false
+// if the lock is held by another transaction, e.g. on another instance.
+func TryLockOrganizationAggregate(ctx context.Context, name types.AggregateName) (bool, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT pg_try_advisory_xact_lock(hashtext('OrganizationAggregate:' || @name))",
+		pgx.NamedArgs{"name": string(name)},
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not lock OrganizationAggregate: %w", err)
+	} else if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[bool]); err != nil {
+		return false, fmt.Errorf("could not lock OrganizationAggregate: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetDueOrganizationAggregateOrgIDs returns the IDs of up to limit organizations whose aggregate name has a pending
+// refresh request, has never been computed or was computed more than maxAge ago. Requested refreshes come first.
+// Organizations in exclude are skipped.
+func GetDueOrganizationAggregateOrgIDs(
+	ctx context.Context,
+	name types.AggregateName,
+	maxAge time.Duration,
+	exclude []uuid.UUID,
+	limit int,
+) ([]uuid.UUID, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT o.id
+		FROM Organization o
+		LEFT JOIN OrganizationAggregate oa ON oa.organization_id = o.id AND oa.name = @name
+		WHERE (
+				oa.computed_at IS NULL
+				OR oa.computed_at < current_timestamp - @maxAge::INTERVAL
+				OR oa.refresh_requested_at IS NOT NULL
+			)
+			AND o.id <> ALL(@exclude::UUID[])
+		ORDER BY oa.refresh_requested_at NULLS LAST, oa.computed_at NULLS FIRST
+		LIMIT @limit`,
+		pgx.NamedArgs{"name": string(name), "maxAge": maxAge, "exclude": exclude, "limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OrganizationAggregate: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID]); err != nil {
+		return nil, fmt.Errorf("could not collect OrganizationAggregate: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// RecomputeOrganizationAggregate computes the aggregate name for the given organizations and stores the result.
+// Refresh requests that were made before the computation started are cleared.
+func RecomputeOrganizationAggregate(ctx context.Context, name types.AggregateName, orgIDs []uuid.UUID) error {
+	dataExpr, ok := organizationAggregateDataExprs[name]
+	if !ok {
+		return fmt.Errorf("unknown aggregate: %v", name)
+	}
+	if name == types.AggregateTargetUptime {
+		if err := updateDeploymentTargetUptimeHours(ctx, orgIDs); err != nil {
+			return err
+		}
+	}
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`INSERT INTO OrganizationAggregate AS oa (organization_id, name, data, computed_at)
+		SELECT o.id, @name, `+dataExpr+`, current_timestamp
+		FROM Organization o
+		WHERE o.id = ANY(@orgIds)
+		ON CONFLICT (organization_id, name) DO UPDATE
+		SET data = EXCLUDED.data,
+			computed_at = EXCLUDED.computed_at,
+			refresh_requested_at = CASE
+				WHEN oa.refresh_requested_at > EXCLUDED.computed_at THEN oa.refresh_requested_at
+			END`,
+		pgx.NamedArgs{"name": string(name), "orgIds": orgIDs},
+	); err != nil {
+		return fmt.Errorf("could not update OrganizationAggregate %v: %w", name, err)
+	}
+	return nil
+}
+
+// updateDeploymentTargetUptimeHours records the hours in which the deployment targets of the given organizations
+// reported their status since the last update and removes hours that are no longer needed to compute the uptime.
+func updateDeploymentTargetUptimeHours(ctx context.Context, orgIDs []uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`INSERT INTO DeploymentTargetUptimeHour (deployment_target_id, hour)
+		SELECT DISTINCT dt.id, date_trunc('hour', dts.created_at)
+		FROM DeploymentTarget dt
+		LEFT JOIN LATERAL (
+			SELECT max(u.hour) AS hour FROM DeploymentTargetUptimeHour u WHERE u.deployment_target_id = dt.id
+		) latest ON true
+		JOIN DeploymentTargetStatus dts
+			ON dts.deployment_target_id = dt.id
+				AND dts.created_at >= greatest(current_timestamp - INTERVAL '30 days', latest.hour)
+		WHERE dt.organization_id = ANY(@orgIds)
+		ON CONFLICT DO NOTHING`,
+		pgx.NamedArgs{"orgIds": orgIDs},
+	); err != nil {
+		return fmt.Errorf("could not insert DeploymentTargetUptimeHour: %w", err)
+	}
+	if _, err := db.Exec(ctx,
+		`DELETE FROM DeploymentTargetUptimeHour u
+		USING DeploymentTarget dt
+		WHERE u.deployment_target_id = dt.id
+			AND dt.organization_id = ANY(@orgIds)
+			AND u.hour < current_timestamp - INTERVAL '31 days'`,
+		pgx.NamedArgs{"orgIds": orgIDs},
+	); err != nil {
+		return fmt.Errorf("could not delete DeploymentTargetUptimeHour: %w", err)
+	}
+	return nil
+}
+
+func aggregateNameStrings(names []types.AggregateName) []string {
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = string(name)
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package db_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestOrganizationAggregates(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	online := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Customers[0].ID)
+	testutil.NewDeploymentTarget(ctx, t, org.ID, org.Customers[0].ID)
+	g.Expect(db.CreateDeploymentTargetStatus(ctx, &online.DeploymentTarget, "ok")).To(Succeed())
+
+	aggregates, err := db.GetOrganizationAggregates(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(aggregates).To(HaveLen(len(types.AggregateNames)))
+	g.Expect(aggregates).To(HaveEach(HaveField("ComputedAt", BeNil())))
+
+	_, err = db.GetOrganizationAggregate(ctx, org.ID, "unknown")
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.RequestOrganizationAggregateRefresh(
+		ctx, org.ID, []types.AggregateName{types.AggregateTargetHealth},
+	)).To(Succeed())
+	aggregate, err := db.GetOrganizationAggregate(ctx, org.ID, types.AggregateTargetHealth)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(aggregate.RefreshRequestedAt).NotTo(BeNil())
+	g.Expect(aggregate.ComputedAt).To(BeNil())
+
+	due, err := db.GetDueOrganizationAggregateOrgIDs(ctx, types.AggregateTargetHealth, 0, nil, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).To(HaveLen(1))
+	due, err = db.GetDueOrganizationAggregateOrgIDs(ctx, types.AggregateTargetHealth, 0, []uuid.UUID{org.ID}, 1000)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).NotTo(ContainElement(org.ID))
+
+	locked, err := db.TryLockOrganizationAggregate(ctx, types.AggregateTargetHealth)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+
+	for _, name := range types.AggregateNames {
+		g.Expect(db.RecomputeOrganizationAggregate(ctx, name, []uuid.UUID{org.ID})).To(Succeed())
+	}
+
+	aggregate, err = db.GetOrganizationAggregate(ctx, org.ID, types.AggregateTargetHealth)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(aggregate.ComputedAt).NotTo(BeNil())
+	g.Expect(aggregate.RefreshRequestedAt).To(BeNil())
+	var health types.TargetHealth
+	g.Expect(json.Unmarshal(aggregate.Data, &health)).To(Succeed())
+	g.Expect(health).To(Equal(types.TargetHealth{Total: 2, Online: 1, NeverConnected: 1}))
+
+	aggregate, err = db.GetOrganizationAggregate(ctx, org.ID, types.AggregateTargetUptime)
+	g.Expect(err).NotTo(HaveOccurred())
+	var uptime []types.TargetUptime
+	g.Expect(json.Unmarshal(aggregate.Data, &uptime)).To(Succeed())
+	g.Expect(uptime).To(HaveLen(2))
+	g.Expect(uptime).To(ContainElement(types.TargetUptime{
+		DeploymentTargetID: online.ID,
+		HoursOnline:        1,
+		HoursTotal:         1,
+		Uptime:             1,
+	}))
+
+	aggregate, err = db.GetOrganizationAggregate(ctx, org.ID, types.AggregateLicenseUtilization)
+	g.Expect(err).NotTo(HaveOccurred())
+	var licenses types.LicenseUtilization
+	g.Expect(json.Unmarshal(aggregate.Data, &licenses)).To(Succeed())
+	g.Expect(licenses.ApplicationLicenses.InUse).To(HaveValue(BeZero()))
+
+	due, err = db.GetDueOrganizationAggregateOrgIDs(ctx, types.AggregateTargetHealth, 1<<40, nil, 1000)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(due).NotTo(ContainElement(org.ID))
+}
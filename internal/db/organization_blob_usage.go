@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// organizationStorageQuotaExpr is the storage quota of the organization o in bytes, or NULL if it is not limited.
+// Like the artifact tag limit, it is not enforced for organizations that store their blobs in their own bucket, unless
+// they have enabled the quota for their storage.
+const organizationStorageQuotaExpr = `CASE
+	WHEN os.id IS NOT NULL AND NOT os.quota_enabled THEN NULL
+	ELSE coalesce(o.storage_quota_bytes, nullif(@defaultQuota::BIGINT, 0))
+END`
+
+func GetOrganizationStorageUsage(ctx context.Context, orgID uuid.UUID) (*types.OrganizationStorageUsage, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT
+			(SELECT count(*) FROM OrganizationBlobUsage u WHERE u.organization_id = o.id) AS blob_count,
+			(SELECT coalesce(sum(u.size), 0) FROM OrganizationBlobUsage u WHERE u.organization_id = o.id)::BIGINT
+				AS blob_bytes,
+			`+organizationStorageQuotaExpr+` AS quota_bytes
+		FROM Organization o
+		LEFT JOIN OrganizationStorage os ON os.organization_id = o.id
+		WHERE o.id = @orgId`,
+		pgx.NamedArgs{"orgId": orgID, "defaultQuota": env.ArtifactStorageDefaultQuotaBytesPerOrg()},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OrganizationBlobUsage: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[types.OrganizationStorageUsage])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get OrganizationBlobUsage: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetRemainingOrganizationStorageQuota returns the number of bytes that the organization may store in addition to
+// its current blobs. It returns nil if the organization is not limited or if the blob with the given digest is already
+// counted for it, because storing it again does not use more storage. digest is nil if it is not known yet.
+//
+// The result can be negative if the quota has been lowered after the blobs were stored.
+func GetRemainingOrganizationStorageQuota(
+	ctx context.Context,
+	orgID uuid.UUID,
+	digest *types.Digest,
+) (*int64, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT CASE
+			WHEN @digest::TEXT IS NOT NULL AND EXISTS (
+				SELECT 1 FROM OrganizationBlobUsage u WHERE u.organization_id = o.id AND u.digest = @digest
+			) THEN NULL
+			ELSE `+organizationStorageQuotaExpr+` - (
+				SELECT coalesce(sum(u.size), 0) FROM OrganizationBlobUsage u WHERE u.organization_id = o.id
+			)
+		END::BIGINT
+		FROM Organization o
+		LEFT JOIN OrganizationStorage os ON os.organization_id = o.id
+		WHERE o.id = @orgId`,
+		pgx.NamedArgs{"orgId": orgID, "digest": digest, "defaultQuota": env.ArtifactStorageDefaultQuotaBytesPerOrg()},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not check storage quota: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[*int64])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not check storage quota: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// AddOrganizationBlobUsage counts the blob towards the storage usage of the organization, unless it is already counted.
+// The creation time is reset, so that the blob is not removed from the usage before it can be referenced.
+func AddOrganizationBlobUsage(ctx context.Context, orgID uuid.UUID, digest types.Digest, size int64) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx,
+		`INSERT INTO OrganizationBlobUsage (organization_id, digest, size)
+			VALUES (@orgId, @digest, @size)
+			ON CONFLICT (organization_id, digest) DO UPDATE SET created_at = EXCLUDED.created_at, size = EXCLUDED.size`,
+		pgx.NamedArgs{"orgId": orgID, "digest": digest, "size": size},
+	); err != nil {
+		return fmt.Errorf("could not save OrganizationBlobUsage: %w", err)
+	}
+	return nil
+}
+
+// DeleteUnreferencedOrganizationBlobUsage removes the blobs that were counted before the given time from the storage
+// usage of all organizations that do not reference them in any artifact version anymore. It returns the number of
+// removed entries.
+func DeleteUnreferencedOrganizationBlobUsage(ctx context.Context, before time.Time) (int64, error) {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`DELETE FROM OrganizationBlobUsage u
+		WHERE u.created_at < @before
+			AND NOT EXISTS (
+				SELECT 1 FROM ArtifactVersion av JOIN Artifact a ON a.id = av.artifact_id
+				WHERE a.organization_id = u.organization_id AND av.manifest_blob_digest = u.digest
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM ArtifactVersionPart avp
+				JOIN ArtifactVersion av ON av.id = avp.artifact_version_id
+				JOIN Artifact a ON a.id = av.artifact_id
+				WHERE a.organization_id = u.organization_id AND avp.artifact_blob_digest = u.digest
+			)`,
+		pgx.NamedArgs{"before": before},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete OrganizationBlobUsage: %w", err)
+	}
+	return cmd.RowsAffected(), nil
+}
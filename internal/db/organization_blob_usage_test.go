@@ -0,0 +1,74 @@
+package db_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/gomega"
+)
+
+func TestOrganizationStorageUsage(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	other := testutil.NewOrganization(ctx, t)
+	_, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "v1")
+	referenced := versions[0].ManifestBlobDigest
+	unreferenced := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("a", 64)})
+
+	g.Expect(db.GetRemainingOrganizationStorageQuota(ctx, org.ID, nil)).To(BeNil())
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE Organization SET storage_quota_bytes = 2048 WHERE id = $1", org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.GetRemainingOrganizationStorageQuota(ctx, org.ID, nil)).To(HaveValue(BeEquivalentTo(2048)))
+
+	g.Expect(db.AddOrganizationBlobUsage(ctx, org.ID, referenced, 1024)).To(Succeed())
+	g.Expect(db.AddOrganizationBlobUsage(ctx, org.ID, unreferenced, 512)).To(Succeed())
+	// blobs are counted once per organization, but by every organization that stores them
+	g.Expect(db.AddOrganizationBlobUsage(ctx, org.ID, unreferenced, 512)).To(Succeed())
+	g.Expect(db.AddOrganizationBlobUsage(ctx, other.ID, unreferenced, 512)).To(Succeed())
+
+	g.Expect(db.GetOrganizationStorageUsage(ctx, org.ID)).To(Equal(&types.OrganizationStorageUsage{
+		BlobCount:  2,
+		BlobBytes:  1536,
+		QuotaBytes: util.PtrTo(int64(2048)),
+	}))
+	g.Expect(db.GetRemainingOrganizationStorageQuota(ctx, org.ID, nil)).To(HaveValue(BeEquivalentTo(512)))
+	g.Expect(db.GetRemainingOrganizationStorageQuota(ctx, org.ID, &unreferenced)).To(BeNil())
+
+	// the usage of blobs that are not referenced anymore is removed after the grace period
+	g.Expect(db.DeleteUnreferencedOrganizationBlobUsage(ctx, time.Now().Add(-time.Hour))).To(BeZero())
+	g.Expect(db.DeleteUnreferencedOrganizationBlobUsage(ctx, time.Now().Add(time.Hour))).To(BeEquivalentTo(2))
+	g.Expect(db.GetOrganizationStorageUsage(ctx, org.ID)).To(Equal(&types.OrganizationStorageUsage{
+		BlobCount:  1,
+		BlobBytes:  1024,
+		QuotaBytes: util.PtrTo(int64(2048)),
+	}))
+}
+
+func TestOrganizationStorageUsageLargeValues(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+	digest := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("b", 64)})
+	// 2^53 + 1 can not be represented exactly as a float64
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE Organization SET storage_quota_bytes = 9007199254740993 WHERE id = $1", org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.AddOrganizationBlobUsage(ctx, org.ID, digest, 1<<53)).To(Succeed())
+
+	usage, err := db.GetOrganizationStorageUsage(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	data, err := json.Marshal(usage)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(`{"blobCount":1,"blobBytes":9007199254740992,"quotaBytes":9007199254740993}`))
+	g.Expect(db.GetRemainingOrganizationStorageQuota(ctx, org.ID, nil)).To(HaveValue(BeEquivalentTo(1)))
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	organizationMailConfigOutputExpr = `
+		c.id, c.created_at, c.organization_id, c.updated_at, c.updated_by_user_account_id, c.type, c.from_address,
+		c.smtp_host, c.smtp_port, c.smtp_username, c.smtp_password, c.sender_domain, c.dkim_selector, c.dkim_private_key,
+		c.verification_token, c.verified_at, c.failure_count, c.last_failure_at, c.last_failure_message, c.disabled_at
+	`
+)
+
+func GetOrganizationMailConfig(ctx context.Context, organizationID uuid.UUID) (*types.OrganizationMailConfig, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+organizationMailConfigOutputExpr+
+			"FROM OrganizationMailConfig c "+
+			"WHERE c.organization_id = @organizationId",
+		pgx.NamedArgs{"organizationId": organizationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OrganizationMailConfig: %w", err)
+	}
+	result, err := collectOrganizationMailConfig(rows)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get OrganizationMailConfig: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// UpsertOrganizationMailConfig creates or replaces the mail config of an organization.
+// Replacing a config always resets the verification and failure state. The SMTP password and the DKIM key are
+// encrypted with the key of the organization if PII encryption is configured.
+func UpsertOrganizationMailConfig(ctx context.Context, c *types.OrganizationMailConfig) error {
+	smtpPassword, err := encryptOrganizationMailConfigSecret(c.OrganizationID, c.SmtpPassword)
+	if err != nil {
+		return err
+	}
+	dkimPrivateKey, err := encryptOrganizationMailConfigSecret(c.OrganizationID, c.DkimPrivateKey)
+	if err != nil {
+		return err
+	}
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`INSERT INTO OrganizationMailConfig AS c
+			(organization_id, updated_by_user_account_id, type, from_address, smtp_host, smtp_port, smtp_username,
+			 smtp_password, sender_domain, dkim_selector, dkim_private_key, verification_token)
+			VALUES (@organizationId, @updatedBy, @type, @fromAddress, @smtpHost, @smtpPort, @smtpUsername,
+			        @smtpPassword, @senderDomain, @dkimSelector, @dkimPrivateKey, @verificationToken)
+			ON CONFLICT (organization_id) DO UPDATE SET
+				updated_at = current_timestamp,
+				updated_by_user_account_id = EXCLUDED.updated_by_user_account_id,
+				type = EXCLUDED.type,
+				from_address = EXCLUDED.from_address,
+				smtp_host = EXCLUDED.smtp_host,
+				smtp_port = EXCLUDED.smtp_port,
+				smtp_username = EXCLUDED.smtp_username,
+				smtp_password = EXCLUDED.smtp_password,
+				sender_domain = EXCLUDED.sender_domain,
+				dkim_selector = EXCLUDED.dkim_selector,
+				dkim_private_key = EXCLUDED.dkim_private_key,
+				verification_token = EXCLUDED.verification_token,
+				verified_at = NULL,
+				failure_count = 0,
+				last_failure_at = NULL,
+				last_failure_message = NULL,
+				disabled_at = NULL
+			RETURNING `+organizationMailConfigOutputExpr,
+		pgx.NamedArgs{
+			"organizationId":    c.OrganizationID,
+			"updatedBy":         c.UpdatedByUserAccountID,
+			"type":              c.Type,
+			"fromAddress":       c.FromAddress,
+			"smtpHost":          c.SmtpHost,
+			"smtpPort":          c.SmtpPort,
+			"smtpUsername":      c.SmtpUsername,
+			"smtpPassword":      smtpPassword,
+			"senderDomain":      c.SenderDomain,
+			"dkimSelector":      c.DkimSelector,
+			"dkimPrivateKey":    dkimPrivateKey,
+			"verificationToken": c.VerificationToken,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save OrganizationMailConfig: %w", err)
+	}
+	result, err := collectOrganizationMailConfig(rows)
+	if err != nil {
+		return fmt.Errorf("could not save OrganizationMailConfig: %w", err)
+	} else {
+		*c = result
+		return nil
+	}
+}
+
+func DeleteOrganizationMailConfig(ctx context.Context, organizationID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM OrganizationMailConfig WHERE organization_id = @organizationId",
+		pgx.NamedArgs{"organizationId": organizationID})
+	if err == nil && cmd.RowsAffected() == 0 {
+		err = apierrors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete OrganizationMailConfig: %w", err)
+	}
+	return nil
+}
+
+func UpdateOrganizationMailConfigVerified(ctx context.Context, c *types.OrganizationMailConfig) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE OrganizationMailConfig AS c SET verified_at = current_timestamp, disabled_at = NULL, failure_count = 0
+		WHERE c.id = @id
+		RETURNING `+organizationMailConfigOutputExpr,
+		pgx.NamedArgs{"id": c.ID})
+	if err != nil {
+		return fmt.Errorf("failed to update OrganizationMailConfig: %w", err)
+	}
+	result, err := collectOrganizationMailConfig(rows)
+	if err != nil {
+		return fmt.Errorf("could not save OrganizationMailConfig: %w", err)
+	} else {
+		*c = result
+		return nil
+	}
+}
+
+// RecordOrganizationMailConfigFailure increments the failure counter of the given config.
+// If the counter reaches maxFailures, the config is disabled as well.
+func RecordOrganizationMailConfigFailure(
+	ctx context.Context,
+	c *types.OrganizationMailConfig,
+	message string,
+	maxFailures int,
+) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE OrganizationMailConfig AS c SET
+			failure_count = c.failure_count + 1,
+			last_failure_at = current_timestamp,
+			last_failure_message = @message,
+			disabled_at = CASE
+				WHEN c.disabled_at IS NULL AND c.failure_count + 1 >= @maxFailures THEN current_timestamp
+				ELSE c.disabled_at
+			END
+		WHERE c.id = @id
+		RETURNING `+organizationMailConfigOutputExpr,
+		pgx.NamedArgs{"id": c.ID, "message": message, "maxFailures": maxFailures})
+	if err != nil {
+		return fmt.Errorf("failed to update OrganizationMailConfig: %w", err)
+	}
+	result, err := collectOrganizationMailConfig(rows)
+	if err != nil {
+		return fmt.Errorf("could not save OrganizationMailConfig: %w", err)
+	} else {
+		*c = result
+		return nil
+	}
+}
+
+func ResetOrganizationMailConfigFailures(ctx context.Context, id uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(ctx,
+		"UPDATE OrganizationMailConfig SET failure_count = 0 WHERE id = @id AND failure_count > 0",
+		pgx.NamedArgs{"id": id})
+	if err != nil {
+		return fmt.Errorf("could not reset OrganizationMailConfig failures: %w", err)
+	}
+	return nil
+}
+
+// EncryptOrganizationMailConfigs encrypts the SMTP password and the DKIM key of at most batchSize mail configs that
+// are stored in plain text or use a key other than the active key. It returns the number of updated configs.
+func EncryptOrganizationMailConfigs(ctx context.Context, batchSize int) (int, error) {
+	keyring := pii.Default()
+	if keyring == nil {
+		return 0, nil
+	}
+	var count int
+	err := RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		rows, err := db.Query(ctx,
+			`SELECT`+organizationMailConfigOutputExpr+`
+			FROM OrganizationMailConfig c
+			WHERE NOT starts_with(c.smtp_password, @prefix) OR NOT starts_with(c.dkim_private_key, @prefix)
+			LIMIT @batchSize
+			FOR UPDATE OF c SKIP LOCKED`,
+			pgx.NamedArgs{"prefix": fmt.Sprintf("pii:%v:", keyring.ActiveVersion()), "batchSize": batchSize},
+		)
+		if err != nil {
+			return fmt.Errorf("could not query OrganizationMailConfig: %w", err)
+		}
+		configs, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.OrganizationMailConfig])
+		if err != nil {
+			return fmt.Errorf("could not map OrganizationMailConfig: %w", err)
+		}
+		for _, config := range configs {
+			if err := decryptPII(config.SmtpPassword, config.DkimPrivateKey); err != nil {
+				return err
+			}
+			smtpPassword, err := encryptOrganizationMailConfigSecret(config.OrganizationID, config.SmtpPassword)
+			if err != nil {
+				return err
+			}
+			dkimPrivateKey, err := encryptOrganizationMailConfigSecret(config.OrganizationID, config.DkimPrivateKey)
+			if err != nil {
+				return err
+			}
+			if _, err := db.Exec(ctx,
+				`UPDATE OrganizationMailConfig
+				SET smtp_password = @smtpPassword, dkim_private_key = @dkimPrivateKey
+				WHERE id = @id`,
+				pgx.NamedArgs{"id": config.ID, "smtpPassword": smtpPassword, "dkimPrivateKey": dkimPrivateKey},
+			); err != nil {
+				return fmt.Errorf("could not update OrganizationMailConfig: %w", err)
+			}
+		}
+		count = len(configs)
+		return nil
+	})
+	return count, err
+}
+
+// collectOrganizationMailConfig collects exactly one row and decrypts the SMTP password and the DKIM key.
+// Values that have been stored before encryption was configured are returned unchanged.
+func collectOrganizationMailConfig(rows pgx.Rows) (types.OrganizationMailConfig, error) {
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.OrganizationMailConfig])
+	if err != nil {
+		return result, err
+	}
+	return result, decryptPII(result.SmtpPassword, result.DkimPrivateKey)
+}
+
+func encryptOrganizationMailConfigSecret(organizationID uuid.UUID, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	} else if encrypted, err := pii.Default().Encrypt(organizationID, *value); err != nil {
+		return nil, fmt.Errorf("could not encrypt OrganizationMailConfig: %w", err)
+	} else {
+		return &encrypted, nil
+	}
+}
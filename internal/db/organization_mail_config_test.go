@@ -0,0 +1,89 @@
+package db_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/glasskube/distr/api"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestOrganizationMailConfigSecretsAreEncrypted(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	withPIIKeys(t, piiKey1)
+	org := testutil.NewOrganization(ctx, t)
+
+	config := types.OrganizationMailConfig{
+		OrganizationID: org.ID,
+		Type:           types.MailConfigTypeSMTP,
+		FromAddress:    "mail@example.com",
+		SmtpHost:       util.PtrTo("smtp.example.com"),
+		SmtpUsername:   util.PtrTo("user"),
+		SmtpPassword:   util.PtrTo("smtp-secret"),
+		DkimPrivateKey: util.PtrTo("dkim-secret"),
+	}
+	g.Expect(db.UpsertOrganizationMailConfig(ctx, &config)).To(Succeed())
+	g.Expect(config.SmtpPassword).To(HaveValue(Equal("smtp-secret")))
+
+	var password, key string
+	g.Expect(internalctx.GetDb(ctx).QueryRow(ctx,
+		"SELECT smtp_password, dkim_private_key FROM OrganizationMailConfig WHERE id = $1", config.ID,
+	).Scan(&password, &key)).To(Succeed())
+	g.Expect(pii.IsEncrypted(password)).To(BeTrue())
+	g.Expect(pii.IsEncrypted(key)).To(BeTrue())
+
+	stored, err := db.GetOrganizationMailConfig(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored.SmtpPassword).To(HaveValue(Equal("smtp-secret")))
+	g.Expect(stored.DkimPrivateKey).To(HaveValue(Equal("dkim-secret")))
+
+	// secrets are never returned to clients
+	data, err := json.Marshal(api.OrganizationMailConfigResponse{OrganizationMailConfig: *stored})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).NotTo(ContainSubstring("secret"))
+	g.Expect(string(data)).To(ContainSubstring(`"smtpUsername":"user"`))
+}
+
+func TestOrganizationMailConfigPlainTextSecrets(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+
+	// configs that have been saved before encryption was configured can still be used
+	config := types.OrganizationMailConfig{
+		OrganizationID: org.ID,
+		Type:           types.MailConfigTypeSMTP,
+		FromAddress:    "mail@example.com",
+		SmtpHost:       util.PtrTo("smtp.example.com"),
+		SmtpUsername:   util.PtrTo("user"),
+		SmtpPassword:   util.PtrTo("smtp-secret"),
+	}
+	g.Expect(db.UpsertOrganizationMailConfig(ctx, &config)).To(Succeed())
+	withPIIKeys(t, piiKey1)
+	stored, err := db.GetOrganizationMailConfig(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored.SmtpPassword).To(HaveValue(Equal("smtp-secret")))
+	g.Expect(stored.DkimPrivateKey).To(BeNil())
+
+	// the encryption job encrypts them
+	for {
+		if count, err := db.EncryptOrganizationMailConfigs(ctx, 100); err != nil {
+			t.Fatal(err)
+		} else if count == 0 {
+			break
+		}
+	}
+	var password string
+	g.Expect(internalctx.GetDb(ctx).QueryRow(ctx,
+		"SELECT smtp_password FROM OrganizationMailConfig WHERE id = $1", config.ID,
+	).Scan(&password)).To(Succeed())
+	g.Expect(password).To(HavePrefix("pii:1:" + org.ID.String() + ":"))
+	g.Expect(db.GetOrganizationMailConfig(ctx, org.ID)).To(HaveField("SmtpPassword", HaveValue(Equal("smtp-secret"))))
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	organizationStorageOutputExpr = `
+		s.id, s.created_at, s.organization_id, s.updated_at, s.updated_by_user_account_id, s.bucket, s.region,
+		s.endpoint, s.use_path_style, s.allow_redirect, s.access_key_id, s.secret_access_key,
+		s.migrate_existing_blobs, s.quota_enabled, s.verified_at, s.failure_count, s.last_failure_at,
+		s.last_failure_message, s.alerted_at
+	`
+)
+
+func GetOrganizationStorage(ctx context.Context, organizationID uuid.UUID) (*types.OrganizationStorage, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+organizationStorageOutputExpr+
+			"FROM OrganizationStorage s "+
+			"WHERE s.organization_id = @organizationId",
+		pgx.NamedArgs{"organizationId": organizationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OrganizationStorage: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.OrganizationStorage])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get OrganizationStorage: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// GetMigratingOrganizationStorages returns the storages of all organizations that want their existing blobs to be
+// copied from the platform bucket.
+func GetMigratingOrganizationStorages(ctx context.Context) ([]types.OrganizationStorage, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+organizationStorageOutputExpr+
+			"FROM OrganizationStorage s "+
+			"WHERE s.migrate_existing_blobs "+
+			"ORDER BY s.created_at")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OrganizationStorage: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.OrganizationStorage])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OrganizationStorage: %w", err)
+	}
+	return result, nil
+}
+
+// UpsertOrganizationStorage creates or replaces the storage of an organization. The storage must have been verified
+// before it is saved. Replacing a storage always resets the failure state.
+func UpsertOrganizationStorage(ctx context.Context, s *types.OrganizationStorage) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`INSERT INTO OrganizationStorage AS s
+			(organization_id, updated_by_user_account_id, bucket, region, endpoint, use_path_style, allow_redirect,
+			 access_key_id, secret_access_key, migrate_existing_blobs, quota_enabled, verified_at)
+			VALUES (@organizationId, @updatedBy, @bucket, @region, @endpoint, @usePathStyle, @allowRedirect,
+			        @accessKeyId, @secretAccessKey, @migrateExistingBlobs, @quotaEnabled, @verifiedAt)
+			ON CONFLICT (organization_id) DO UPDATE SET
+				updated_at = current_timestamp,
+				updated_by_user_account_id = EXCLUDED.updated_by_user_account_id,
+				bucket = EXCLUDED.bucket,
+				region = EXCLUDED.region,
+				endpoint = EXCLUDED.endpoint,
+				use_path_style = EXCLUDED.use_path_style,
+				allow_redirect = EXCLUDED.allow_redirect,
+				access_key_id = EXCLUDED.access_key_id,
+				secret_access_key = EXCLUDED.secret_access_key,
+				migrate_existing_blobs = EXCLUDED.migrate_existing_blobs,
+				quota_enabled = EXCLUDED.quota_enabled,
+				verified_at = EXCLUDED.verified_at,
+				failure_count = 0,
+				last_failure_at = NULL,
+				last_failure_message = NULL,
+				alerted_at = NULL
+			RETURNING `+organizationStorageOutputExpr,
+		pgx.NamedArgs{
+			"organizationId":       s.OrganizationID,
+			"updatedBy":            s.UpdatedByUserAccountID,
+			"bucket":               s.Bucket,
+			"region":               s.Region,
+			"endpoint":             s.Endpoint,
+			"usePathStyle":         s.UsePathStyle,
+			"allowRedirect":        s.AllowRedirect,
+			"accessKeyId":          s.AccessKeyID,
+			"secretAccessKey":      s.SecretAccessKey,
+			"migrateExistingBlobs": s.MigrateExistingBlobs,
+			"quotaEnabled":         s.QuotaEnabled,
+			"verifiedAt":           s.VerifiedAt,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save OrganizationStorage: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.OrganizationStorage])
+	if err != nil {
+		return fmt.Errorf("could not save OrganizationStorage: %w", err)
+	} else {
+		*s = result
+		return nil
+	}
+}
+
+// DeleteOrganizationStorage deletes the storage of an organization. It fails with apierrors.ErrConflict while blobs
+// of the organization are stored in the bucket, because they could not be served anymore.
+func DeleteOrganizationStorage(ctx context.Context, organizationID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`DELETE FROM OrganizationStorage s
+		WHERE s.organization_id = @organizationId
+			AND NOT EXISTS (SELECT 1 FROM OrganizationBlob b WHERE b.organization_id = s.organization_id)`,
+		pgx.NamedArgs{"organizationId": organizationID})
+	if err != nil {
+		return fmt.Errorf("could not delete OrganizationStorage: %w", err)
+	} else if cmd.RowsAffected() > 0 {
+		return nil
+	} else if _, err := GetOrganizationStorage(ctx, organizationID); err != nil {
+		return err
+	} else {
+		return fmt.Errorf("%w: the bucket still contains blobs of the organization", apierrors.ErrConflict)
+	}
+}
+
+// RecordOrganizationStorageFailure stores the failure that caused the storage to become unavailable.
+// It returns true if the organization should be alerted, which is the case at most once per alertInterval.
+func RecordOrganizationStorageFailure(
+	ctx context.Context,
+	id uuid.UUID,
+	message string,
+	alertInterval time.Duration,
+) (*types.OrganizationStorage, bool, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`UPDATE OrganizationStorage AS s SET
+			failure_count = s.failure_count + 1,
+			last_failure_at = current_timestamp,
+			last_failure_message = @message,
+			alerted_at = CASE WHEN previous.alert THEN current_timestamp ELSE s.alerted_at END
+		FROM (
+			SELECT id, alerted_at IS NULL OR alerted_at < current_timestamp - @alertInterval::INTERVAL AS alert
+			FROM OrganizationStorage
+			WHERE id = @id
+			FOR UPDATE
+		) AS previous
+		WHERE s.id = previous.id
+		RETURNING `+organizationStorageOutputExpr+`, previous.alert`,
+		pgx.NamedArgs{"id": id, "message": message, "alertInterval": alertInterval})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update OrganizationStorage: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[struct {
+		types.OrganizationStorage
+		Alert bool `db:"alert"`
+	}])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, false, fmt.Errorf("could not save OrganizationStorage: %w", err)
+	} else {
+		return &result.OrganizationStorage, result.Alert, nil
+	}
+}
+
+func ResetOrganizationStorageFailures(ctx context.Context, id uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(ctx,
+		"UPDATE OrganizationStorage SET failure_count = 0 WHERE id = @id AND failure_count > 0",
+		pgx.NamedArgs{"id": id})
+	if err != nil {
+		return fmt.Errorf("could not reset OrganizationStorage failures: %w", err)
+	}
+	return nil
+}
+
+// OrganizationBlobExists returns true if the blob is stored in the bucket of the organization.
+func OrganizationBlobExists(ctx context.Context, organizationID uuid.UUID, digest types.Digest) (bool, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT EXISTS (SELECT 1 FROM OrganizationBlob WHERE organization_id = @organizationId AND digest = @digest)`,
+		pgx.NamedArgs{"organizationId": organizationID, "digest": digest})
+	if err != nil {
+		return false, fmt.Errorf("failed to query OrganizationBlob: %w", err)
+	}
+	exists, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[bool])
+	if err != nil {
+		return false, fmt.Errorf("failed to query OrganizationBlob: %w", err)
+	}
+	return exists, nil
+}
+
+func CreateOrganizationBlob(ctx context.Context, organizationID uuid.UUID, digest types.Digest) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(ctx,
+		`INSERT INTO OrganizationBlob (organization_id, digest) VALUES (@organizationId, @digest)
+		ON CONFLICT DO NOTHING`,
+		pgx.NamedArgs{"organizationId": organizationID, "digest": digest})
+	if err != nil {
+		return fmt.Errorf("could not save OrganizationBlob: %w", err)
+	}
+	return nil
+}
+
+func DeleteOrganizationBlob(ctx context.Context, organizationID uuid.UUID, digest types.Digest) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.Exec(ctx,
+		"DELETE FROM OrganizationBlob WHERE organization_id = @organizationId AND digest = @digest",
+		pgx.NamedArgs{"organizationId": organizationID, "digest": digest})
+	if err != nil {
+		return fmt.Errorf("could not delete OrganizationBlob: %w", err)
+	}
+	return nil
+}
+
+// GetOrganizationBlobsToMigrate returns up to limit blobs that are part of an artifact of the organization but are
+// still stored in the platform bucket. Only blobs with recorded metadata are considered.
+func GetOrganizationBlobsToMigrate(
+	ctx context.Context,
+	organizationID uuid.UUID,
+	limit int,
+) ([]types.BlobMetadata, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT DISTINCT `+blobMetadataOutputExpr+`
+		FROM ArtifactVersionPart avp
+			JOIN ArtifactVersion av ON av.id = avp.artifact_version_id
+			JOIN Artifact a ON a.id = av.artifact_id
+			JOIN BlobMetadata b ON b.digest = avp.artifact_blob_digest
+		WHERE a.organization_id = @organizationId
+			AND NOT EXISTS (
+				SELECT 1 FROM OrganizationBlob ob WHERE ob.organization_id = a.organization_id AND ob.digest = b.digest
+			)
+		ORDER BY b.digest
+		LIMIT @limit`,
+		pgx.NamedArgs{"organizationId": organizationID, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query BlobMetadata: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.BlobMetadata])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get BlobMetadata: %w", err)
+	}
+	return result, nil
+}
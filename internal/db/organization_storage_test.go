@@ -0,0 +1,80 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/gomega"
+)
+
+func TestOrganizationStorage(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	_, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "v1")
+
+	_, err := db.GetOrganizationStorage(ctx, org.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	// the tag limit applies until the organization has its own storage
+	_, err = internalctx.GetDb(ctx).Exec(ctx, "UPDATE Organization SET artifact_tag_limit = 1 WHERE id = $1", org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.EnsureArtifactTagLimitForInsert(ctx, org.ID)).To(BeFalse())
+
+	storage := types.OrganizationStorage{
+		OrganizationID:       org.ID,
+		Bucket:               "artifacts",
+		Region:               "eu-central-1",
+		AccessKeyID:          "access-key",
+		SecretAccessKey:      "secret-key",
+		MigrateExistingBlobs: true,
+		VerifiedAt:           time.Now(),
+	}
+	g.Expect(db.UpsertOrganizationStorage(ctx, &storage)).To(Succeed())
+	g.Expect(db.GetOrganizationStorage(ctx, org.ID)).To(HaveField("SecretAccessKey", "secret-key"))
+	g.Expect(db.GetMigratingOrganizationStorages(ctx)).To(ContainElement(HaveField("ID", storage.ID)))
+	g.Expect(db.EnsureArtifactTagLimitForInsert(ctx, org.ID)).To(BeTrue())
+	storage.QuotaEnabled = true
+	g.Expect(db.UpsertOrganizationStorage(ctx, &storage)).To(Succeed())
+	g.Expect(db.EnsureArtifactTagLimitForInsert(ctx, org.ID)).To(BeFalse())
+
+	// failures are alerted at most once per interval
+	_, alert, err := db.RecordOrganizationStorageFailure(ctx, storage.ID, "access denied", time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(alert).To(BeTrue())
+	updated, alert, err := db.RecordOrganizationStorageFailure(ctx, storage.ID, "access denied", time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(alert).To(BeFalse())
+	g.Expect(updated.FailureCount).To(Equal(2))
+	g.Expect(db.ResetOrganizationStorageFailures(ctx, storage.ID)).To(Succeed())
+	g.Expect(db.GetOrganizationStorage(ctx, org.ID)).To(HaveField("FailureCount", 0))
+
+	// blobs of the organization are migrated until they are stored in its bucket
+	digest := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0123456789abcdef", 4)})
+	g.Expect(db.CreateArtifactVersionPart(ctx, &types.ArtifactVersionPart{
+		ArtifactVersionID:  versions[0].ID,
+		ArtifactBlobDigest: digest,
+		ArtifactBlobSize:   42,
+	})).To(Succeed())
+	g.Expect(db.GetOrganizationBlobsToMigrate(ctx, org.ID, 10)).To(BeEmpty())
+	g.Expect(db.SaveBlobMetadata(ctx, &types.BlobMetadata{Digest: digest, Size: 42, ContentType: "test"})).
+		To(Succeed())
+	g.Expect(db.GetOrganizationBlobsToMigrate(ctx, org.ID, 10)).To(ConsistOf(HaveField("Digest", digest)))
+	g.Expect(db.OrganizationBlobExists(ctx, org.ID, digest)).To(BeFalse())
+	g.Expect(db.CreateOrganizationBlob(ctx, org.ID, digest)).To(Succeed())
+	g.Expect(db.OrganizationBlobExists(ctx, org.ID, digest)).To(BeTrue())
+	g.Expect(db.GetOrganizationBlobsToMigrate(ctx, org.ID, 10)).To(BeEmpty())
+
+	// the storage can not be deleted while it contains blobs
+	g.Expect(db.DeleteOrganizationStorage(ctx, org.ID)).To(MatchError(apierrors.ErrConflict))
+	g.Expect(db.DeleteOrganizationBlob(ctx, org.ID, digest)).To(Succeed())
+	g.Expect(db.DeleteOrganizationStorage(ctx, org.ID)).To(Succeed())
+	g.Expect(db.DeleteOrganizationStorage(ctx, org.ID)).To(MatchError(apierrors.ErrNotFound))
+}
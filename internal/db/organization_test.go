@@ -0,0 +1,109 @@
+package db_test
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/orgtime"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestUpdateOrganizationTimezone(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	g.Expect(org.Timezone).To(Equal("UTC"))
+	g.Expect(org.BusinessHours).To(BeNil())
+
+	org.Timezone = "America/New_York"
+	org.BusinessHours = &orgtime.BusinessHours{
+		Weekdays: []time.Weekday{time.Monday, time.Friday},
+		Start:    orgtime.Clock{Hour: 8, Minute: 30},
+		End:      orgtime.Clock{Hour: 17},
+	}
+	g.Expect(db.UpdateOrganization(ctx, &org.Organization)).To(Succeed())
+
+	byID, err := db.GetOrganizationByID(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(byID.Location().String()).To(Equal("America/New_York"))
+	g.Expect(byID.BusinessHours).To(Equal(org.BusinessHours))
+
+	// the organization is also loaded as a nested row during authentication
+	_, withUser, err := db.GetUserAccountAndOrg(ctx, org.Vendors[0].ID, org.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(withUser.Timezone).To(Equal("America/New_York"))
+	g.Expect(withUser.BusinessHours).To(Equal(org.BusinessHours))
+
+	withUser.BusinessHours = nil
+	g.Expect(db.UpdateOrganization(ctx, withUser)).To(Succeed())
+	g.Expect(withUser.BusinessHours).To(BeNil())
+}
+
+func TestCreateOrganizationDuplicateNameAndSlug(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	id := uuid.NewString()[:8]
+
+	for _, expected := range []struct{ name, slug string }{
+		{"Acme " + id, "acme-" + id},
+		{"ACME " + id + " (2)", "acme-" + id + "-2"},
+		{"Acme " + id + " (3)", "acme-" + id + "-3"},
+	} {
+		// names are compared case-insensitively, but keep the case of the request
+		org := types.Organization{Name: expected.name[:len("Acme ")+len(id)], Slug: util.PtrTo("acme-" + id)}
+		g.Expect(db.CreateOrganization(ctx, &org)).To(Succeed())
+		g.Expect(org.Name).To(Equal(expected.name))
+		g.Expect(org.Slug).To(HaveValue(Equal(expected.slug)))
+	}
+
+	// suffixed slugs are shortened to the maximum length without ending in a separator
+	long := id + strings.Repeat("x", types.OrganizationSlugMaxLength-len(id)-3) + "-yy"
+	g.Expect(long).To(HaveLen(types.OrganizationSlugMaxLength))
+	for range 2 {
+		g.Expect(db.CreateOrganization(ctx, &types.Organization{Name: long, Slug: &long})).To(Succeed())
+	}
+	org := types.Organization{Name: long, Slug: &long}
+	g.Expect(db.CreateOrganization(ctx, &org)).To(Succeed())
+	g.Expect(org.Slug).To(HaveValue(Equal(long[:types.OrganizationSlugMaxLength-3] + "-3")))
+}
+
+func TestCreateOrganizationConcurrently(t *testing.T) {
+	g := NewWithT(t)
+	ctx := poolContext(t)
+	id := uuid.NewString()[:8]
+
+	const count = 8
+	orgs := make([]types.Organization, count)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := range orgs {
+		orgs[i] = types.Organization{Name: "Acme " + id, Slug: util.PtrTo("acme-" + id)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = db.CreateOrganization(ctx, &orgs[i])
+		}()
+	}
+	wg.Wait()
+
+	names := make([]string, 0, count)
+	slugs := make([]string, 0, count)
+	for i, err := range errs {
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Cleanup(func() { deleteCommitted(ctx, t, `DELETE FROM Organization WHERE id = @id`, orgs[i].ID) })
+		names = append(names, orgs[i].Name)
+		slugs = append(slugs, *orgs[i].Slug)
+	}
+	g.Expect(names).To(ContainElements("Acme "+id, "Acme "+id+" (2)", "Acme "+id+" ("+strconv.Itoa(count)+")"))
+	g.Expect(slugs).To(ContainElements("acme-"+id, "acme-"+id+"-2", "acme-"+id+"-"+strconv.Itoa(count)))
+	g.Expect(slices.Compact(slices.Sorted(slices.Values(slugs)))).To(HaveLen(count))
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// userAccountPIIScopeExpr is the organization whose key encrypts the personally identifiable fields of the user
+// account u. User accounts can belong to several organizations, so the organization they joined first is used.
+// Accounts that do not belong to any organization use the nil UUID.
+const userAccountPIIScopeExpr = `coalesce((
+	SELECT oua.organization_id FROM Organization_UserAccount oua
+	WHERE oua.user_account_id = u.id
+	ORDER BY oua.created_at, oua.organization_id
+	LIMIT 1
+), '00000000-0000-0000-0000-000000000000'::UUID)`
+
+// userAccountPIIArgs returns the named arguments for the personally identifiable columns of userAccount, encrypted
+// with the default keyring. If encryption is not configured, the values are returned in plain text.
+func userAccountPIIArgs(ctx context.Context, userAccount *types.UserAccount) (pgx.NamedArgs, error) {
+	keyring := pii.Default()
+	if keyring == nil {
+		return pgx.NamedArgs{
+			"email":               userAccount.Email,
+			"name":                userAccount.Name,
+			"email_blind_index":   nil,
+			"pii_key_version":     nil,
+			"pii_organization_id": nil,
+		}, nil
+	}
+
+	scope := uuid.Nil
+	if userAccount.ID != uuid.Nil {
+		db := internalctx.GetDb(ctx)
+		rows, err := db.Query(ctx, "SELECT "+userAccountPIIScopeExpr+" FROM UserAccount u WHERE u.id = @id",
+			pgx.NamedArgs{"id": userAccount.ID})
+		if err != nil {
+			return nil, fmt.Errorf("could not query user account scope: %w", err)
+		}
+		scope, err = pgx.CollectExactlyOneRow(rows, pgx.RowTo[uuid.UUID])
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apierrors.ErrNotFound
+		} else if err != nil {
+			return nil, fmt.Errorf("could not query user account scope: %w", err)
+		}
+	}
+
+	email, err := keyring.Encrypt(scope, userAccount.Email)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt user account: %w", err)
+	}
+	name, err := keyring.Encrypt(scope, userAccount.Name)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt user account: %w", err)
+	}
+	return pgx.NamedArgs{
+		"email":               email,
+		"name":                name,
+		"email_blind_index":   keyring.BlindIndex(userAccount.Email),
+		"pii_key_version":     keyring.ActiveVersion(),
+		"pii_organization_id": scope,
+	}, nil
+}
+
+// ensureEmailNotTakenInPlainText returns ErrAlreadyExists if an account that has not been encrypted yet uses email.
+// Such accounts have no blind index, so the unique constraint does not cover them.
+func ensureEmailNotTakenInPlainText(ctx context.Context, email string, id uuid.UUID) error {
+	if pii.Default() == nil {
+		return nil
+	}
+	db := internalctx.GetDb(ctx)
+	var exists bool
+	if err := db.QueryRow(ctx,
+		"SELECT exists(SELECT 1 FROM UserAccount WHERE email = @email AND id <> @id)",
+		pgx.NamedArgs{"email": email, "id": id},
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("could not query users: %w", err)
+	} else if exists {
+		return fmt.Errorf("user account with email %v already exists: %w", email, apierrors.ErrAlreadyExists)
+	}
+	return nil
+}
+
+var userAccountTypes = []reflect.Type{
+	reflect.TypeFor[types.UserAccount](),
+	reflect.TypeFor[types.UserAccountWithUserRole](),
+}
+
+// containsUserAccountCache maps a reflect.Type to whether values of this type can contain a user account.
+var containsUserAccountCache sync.Map
+
+// decryptUserAccounts decrypts the personally identifiable fields of all user accounts in v in place. v must be a
+// pointer, and user accounts may be nested in structs, slices and pointers, so that query results can be decrypted
+// no matter where their user accounts are.
+func decryptUserAccounts(v any) error {
+	if pii.Default() == nil {
+		return nil
+	}
+	return decryptUserAccountsValue(reflect.ValueOf(v))
+}
+
+func decryptUserAccountsValue(v reflect.Value) error {
+	if !containsUserAccount(v.Type()) {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return decryptUserAccountsValue(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if err := decryptUserAccountsValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		if slices.Contains(userAccountTypes, v.Type()) {
+			return decryptPII(
+				v.FieldByName("Email").Addr().Interface().(*string),
+				v.FieldByName("Name").Addr().Interface().(*string),
+			)
+		}
+		for i := range v.NumField() {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			} else if err := decryptUserAccountsValue(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func containsUserAccount(t reflect.Type) bool {
+	if cached, ok := containsUserAccountCache.Load(t); ok {
+		return cached.(bool)
+	}
+	result := containsUserAccountVisiting(t, map[reflect.Type]struct{}{})
+	containsUserAccountCache.Store(t, result)
+	return result
+}
+
+func containsUserAccountVisiting(t reflect.Type, visiting map[reflect.Type]struct{}) bool {
+	if _, ok := visiting[t]; ok {
+		return false
+	}
+	visiting[t] = struct{}{}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return containsUserAccountVisiting(t.Elem(), visiting)
+	case reflect.Struct:
+		if slices.Contains(userAccountTypes, t) {
+			return true
+		}
+		for i := range t.NumField() {
+			if t.Field(i).IsExported() && containsUserAccountVisiting(t.Field(i).Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decryptPII decrypts values in place. Values that are not encrypted are left unchanged.
+func decryptPII(values ...*string) error {
+	keyring := pii.Default()
+	for _, value := range values {
+		if value == nil {
+			continue
+		} else if decrypted, err := keyring.Decrypt(*value); err != nil {
+			return fmt.Errorf("could not decrypt PII: %w", err)
+		} else {
+			*value = decrypted
+		}
+	}
+	return nil
+}
+
+// EncryptUserAccounts encrypts the personally identifiable fields of at most batchSize user accounts that are stored
+// in plain text, use a key other than the active key, or use the key of an organization they are not a member of
+// anymore. It returns the number of updated accounts.
+func EncryptUserAccounts(ctx context.Context, batchSize int) (int, error) {
+	keyring := pii.Default()
+	if keyring == nil {
+		return 0, nil
+	}
+	var count int
+	err := RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		rows, err := db.Query(ctx,
+			`SELECT u.id, u.email, coalesce(u.name, '') AS name, `+userAccountPIIScopeExpr+` AS scope
+			FROM UserAccount u
+			WHERE u.pii_key_version IS DISTINCT FROM @version
+				OR u.pii_organization_id IS DISTINCT FROM `+userAccountPIIScopeExpr+`
+			LIMIT @batchSize
+			FOR UPDATE OF u SKIP LOCKED`,
+			pgx.NamedArgs{"version": keyring.ActiveVersion(), "batchSize": batchSize},
+		)
+		if err != nil {
+			return fmt.Errorf("could not query users: %w", err)
+		}
+		accounts, err := pgx.CollectRows(rows, pgx.RowToStructByName[struct {
+			ID    uuid.UUID
+			Email string
+			Name  string
+			Scope uuid.UUID
+		}])
+		if err != nil {
+			return fmt.Errorf("could not map users: %w", err)
+		}
+
+		for _, account := range accounts {
+			if err := decryptPII(&account.Email, &account.Name); err != nil {
+				return err
+			}
+			email, err := keyring.Encrypt(account.Scope, account.Email)
+			if err != nil {
+				return err
+			}
+			name, err := keyring.Encrypt(account.Scope, account.Name)
+			if err != nil {
+				return err
+			}
+			if _, err := db.Exec(ctx,
+				`UPDATE UserAccount
+				SET email = @email,
+					name = CASE WHEN name IS NULL THEN NULL ELSE @name END,
+					email_blind_index = @emailBlindIndex,
+					pii_key_version = @version,
+					pii_organization_id = @scope
+				WHERE id = @id`,
+				pgx.NamedArgs{
+					"id":              account.ID,
+					"email":           email,
+					"name":            name,
+					"emailBlindIndex": keyring.BlindIndex(account.Email),
+					"version":         keyring.ActiveVersion(),
+					"scope":           account.Scope,
+				},
+			); err != nil {
+				return fmt.Errorf("could not update user: %w", err)
+			}
+		}
+		count = len(accounts)
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,142 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	piiKey1     = pii.Key{Version: 1, Secret: bytes.Repeat([]byte{1}, pii.MinKeyLength)}
+	piiKey2     = pii.Key{Version: 2, Secret: bytes.Repeat([]byte{2}, pii.MinKeyLength)}
+	piiIndexKey = bytes.Repeat([]byte{3}, pii.MinKeyLength)
+)
+
+// withPIIKeys enables encryption with the given keys until the test has finished.
+func withPIIKeys(t testing.TB, keys ...pii.Key) {
+	t.Helper()
+	keyring, err := pii.New(keys, piiIndexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := pii.Default()
+	pii.SetDefault(keyring)
+	t.Cleanup(func() { pii.SetDefault(previous) })
+}
+
+func storedUserAccount(ctx context.Context, t *testing.T, id uuid.UUID) (email, name string, version *int) {
+	t.Helper()
+	err := internalctx.GetDb(ctx).QueryRow(ctx,
+		"SELECT email, name, pii_key_version FROM UserAccount WHERE id = $1", id,
+	).Scan(&email, &name, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return email, name, version
+}
+
+func TestUserAccountEncryption(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	plain := testutil.NewUserAccount(ctx, t)
+	withPIIKeys(t, piiKey1)
+
+	user := testutil.NewUserAccount(ctx, t)
+	g.Expect(user.Email).To(HavePrefix("user-"))
+	email, name, version := storedUserAccount(ctx, t, user.ID)
+	g.Expect(pii.IsEncrypted(email)).To(BeTrue())
+	g.Expect(pii.IsEncrypted(name)).To(BeTrue())
+	g.Expect(version).To(HaveValue(Equal(1)))
+
+	// lookups work for encrypted accounts and for accounts that have not been encrypted yet
+	g.Expect(db.GetUserAccountByEmail(ctx, user.Email)).To(HaveField("ID", user.ID))
+	g.Expect(db.GetUserAccountByEmail(ctx, plain.Email)).To(HaveField("ID", plain.ID))
+	g.Expect(db.GetUserAccountByID(ctx, user.ID)).To(HaveField("Name", user.Name))
+
+	// emails stay unique across encrypted and plain text accounts
+	err := db.RunTx(ctx, func(ctx context.Context) error {
+		return db.CreateUserAccount(ctx, &types.UserAccount{Email: user.Email})
+	})
+	g.Expect(err).To(MatchError(apierrors.ErrAlreadyExists))
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		return db.CreateUserAccount(ctx, &types.UserAccount{Email: plain.Email})
+	})
+	g.Expect(err).To(MatchError(apierrors.ErrAlreadyExists))
+
+	// nested user accounts are decrypted as well
+	org := testutil.NewOrganization(ctx, t)
+	g.Expect(db.CreateUserAccountOrganizationAssignment(ctx, user.ID, org.ID, types.UserRoleVendor)).To(Succeed())
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, user.ID)
+	g.Expect(db.GetDeploymentTarget(ctx, dt.ID, &org.ID)).
+		To(HaveField("CreatedBy", HaveField("Email", user.Email)))
+	g.Expect(db.GetUserAccountsByOrgID(ctx, org.ID, nil)).To(ConsistOf(HaveField("Email", user.Email)))
+}
+
+func TestEncryptUserAccounts(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	user := org.Vendors[0]
+
+	withPIIKeys(t, piiKey1)
+	for {
+		if count, err := db.EncryptUserAccounts(ctx, 100); err != nil {
+			t.Fatal(err)
+		} else if count == 0 {
+			break
+		}
+	}
+	email, _, version := storedUserAccount(ctx, t, user.ID)
+	g.Expect(email).To(HavePrefix("pii:1:" + org.ID.String() + ":"))
+	g.Expect(version).To(HaveValue(Equal(1)))
+	g.Expect(db.GetUserAccountByEmail(ctx, user.Email)).To(HaveField("Name", user.Name))
+
+	// after a rotation, accounts are re-encrypted with the new key
+	withPIIKeys(t, piiKey2, piiKey1)
+	g.Expect(db.GetUserAccountByEmail(ctx, user.Email)).To(HaveField("ID", user.ID))
+	for {
+		if count, err := db.EncryptUserAccounts(ctx, 100); err != nil {
+			t.Fatal(err)
+		} else if count == 0 {
+			break
+		}
+	}
+	email, _, version = storedUserAccount(ctx, t, user.ID)
+	g.Expect(email).To(HavePrefix("pii:2:"))
+	g.Expect(version).To(HaveValue(Equal(2)))
+	withPIIKeys(t, piiKey2)
+	g.Expect(db.GetUserAccountByEmail(ctx, user.Email)).To(HaveField("ID", user.ID))
+}
+
+// BenchmarkGetUserAccountByEmail measures the lookup of the login path with and without encryption.
+func BenchmarkGetUserAccountByEmail(b *testing.B) {
+	ctx := testutil.DBContext(b)
+	for _, bc := range []struct {
+		name string
+		keys []pii.Key
+	}{
+		{"plain", nil},
+		{"encrypted", []pii.Key{piiKey1}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			if len(bc.keys) > 0 {
+				withPIIKeys(b, bc.keys...)
+			}
+			user := testutil.NewUserAccount(ctx, b)
+			for b.Loop() {
+				if _, err := db.GetUserAccountByEmail(ctx, user.Email); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	secretScanRuleOutputExpr      = ` r.id, r.created_at, r.organization_id, r.rule_id, r.description, r.pattern `
+	secretScanDismissalOutputExpr = `
+		d.id, d.created_at, d.organization_id, d.created_by_useraccount_id, d.fingerprint, d.reason
+	`
+	secretScanFindingOutputExpr = `
+		f.id, f.created_at, f.organization_id, f.useraccount_id, f.source, f.file, f.line, f.rule_id, f.redacted,
+		f.fingerprint, f.blocked
+	`
+)
+
+func GetSecretScanRules(ctx context.Context, orgID uuid.UUID) ([]types.SecretScanRule, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+secretScanRuleOutputExpr+"FROM SecretScanRule r WHERE r.organization_id = @orgId ORDER BY r.rule_id",
+		pgx.NamedArgs{"orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SecretScanRules: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.SecretScanRule])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SecretScanRules: %w", err)
+	}
+	return result, nil
+}
+
+func CreateSecretScanRule(ctx context.Context, rule *types.SecretScanRule) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO SecretScanRule AS r (organization_id, rule_id, description, pattern)
+			VALUES (@orgId, @ruleId, @description, @pattern)
+			RETURNING`+secretScanRuleOutputExpr,
+		pgx.NamedArgs{
+			"orgId":       rule.OrganizationID,
+			"ruleId":      rule.RuleID,
+			"description": rule.Description,
+			"pattern":     rule.Pattern,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert SecretScanRule: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.SecretScanRule]); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrAlreadyExists, err)
+		}
+		return err
+	} else {
+		*rule = result
+		return nil
+	}
+}
+
+func DeleteSecretScanRule(ctx context.Context, id, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`DELETE FROM SecretScanRule WHERE id = @id AND organization_id = @orgId`,
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return fmt.Errorf("failed to delete SecretScanRule: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+func GetSecretScanDismissals(ctx context.Context, orgID uuid.UUID) ([]types.SecretScanDismissal, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+secretScanDismissalOutputExpr+"FROM SecretScanDismissal d WHERE d.organization_id = @orgId "+
+			"ORDER BY d.created_at DESC",
+		pgx.NamedArgs{"orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SecretScanDismissals: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.SecretScanDismissal])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SecretScanDismissals: %w", err)
+	}
+	return result, nil
+}
+
+// CreateSecretScanDismissal dismisses a fingerprint. Dismissing a fingerprint again updates the reason.
+func CreateSecretScanDismissal(ctx context.Context, dismissal *types.SecretScanDismissal) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO SecretScanDismissal AS d (organization_id, created_by_useraccount_id, fingerprint, reason)
+			VALUES (@orgId, @createdBy, @fingerprint, @reason)
+			ON CONFLICT (organization_id, fingerprint) DO UPDATE SET reason = excluded.reason
+			RETURNING`+secretScanDismissalOutputExpr,
+		pgx.NamedArgs{
+			"orgId":       dismissal.OrganizationID,
+			"createdBy":   dismissal.CreatedByUserAccountID,
+			"fingerprint": dismissal.Fingerprint,
+			"reason":      dismissal.Reason,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert SecretScanDismissal: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.SecretScanDismissal]); err != nil {
+		return fmt.Errorf("failed to get SecretScanDismissal: %w", err)
+	} else {
+		*dismissal = result
+		return nil
+	}
+}
+
+func DeleteSecretScanDismissal(ctx context.Context, id, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`DELETE FROM SecretScanDismissal WHERE id = @id AND organization_id = @orgId`,
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return fmt.Errorf("failed to delete SecretScanDismissal: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+func CreateSecretScanFindings(ctx context.Context, findings []types.SecretScanFinding) error {
+	db := internalctx.GetDb(ctx)
+	_, err := db.CopyFrom(
+		ctx,
+		pgx.Identifier{"secretscanfinding"},
+		[]string{
+			"organization_id", "useraccount_id", "source", "file", "line", "rule_id", "redacted", "fingerprint", "blocked",
+		},
+		pgx.CopyFromSlice(len(findings), func(i int) ([]any, error) {
+			f := findings[i]
+			return []any{
+				f.OrganizationID, f.UserAccountID, f.Source, f.File, f.Line, f.RuleID, f.Redacted, f.Fingerprint, f.Blocked,
+			}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert SecretScanFindings: %w", err)
+	}
+	return nil
+}
+
+// SecretScanFindingsPageSpec orders findings from newest to oldest.
+var SecretScanFindingsPageSpec = pagination.Spec{
+	Name: "SecretScanFinding",
+	Columns: []pagination.Column{
+		{Expr: "f.created_at", Type: "TIMESTAMP", Desc: true},
+		{Expr: "f.id", Type: "UUID", Desc: true},
+	},
+}
+
+func GetSecretScanFindingsPage(
+	ctx context.Context,
+	orgID uuid.UUID,
+	page pagination.Page,
+) ([]types.SecretScanFinding, []any, error) {
+	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{"orgId": orgID}
+	rows, err := db.Query(ctx,
+		"SELECT"+secretScanFindingOutputExpr+"FROM SecretScanFinding f "+
+			"WHERE f.organization_id = @orgId AND "+SecretScanFindingsPageSpec.Where(page, args)+" "+
+			SecretScanFindingsPageSpec.OrderByLimit(page, args),
+		args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query SecretScanFindings: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.SecretScanFinding])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get SecretScanFindings: %w", err)
+	}
+	result, next := pagination.Trim(page, result, func(f types.SecretScanFinding) []any {
+		return []any{f.CreatedAt, f.ID}
+	})
+	return result, next, nil
+}
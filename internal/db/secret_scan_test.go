@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestSecretScanRules(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+	g.Expect(org.SecretScanPolicy).To(Equal(types.SecretScanPolicyBlock))
+
+	rule := types.SecretScanRule{OrganizationID: org.ID, RuleID: "acme-license", Pattern: `ACME-[0-9]{8}`}
+	g.Expect(db.CreateSecretScanRule(ctx, &rule)).To(Succeed())
+	duplicate := types.SecretScanRule{OrganizationID: org.ID, RuleID: "acme-license", Pattern: `ACME`}
+	g.Expect(db.CreateSecretScanRule(ctx, &duplicate)).To(MatchError(apierrors.ErrAlreadyExists))
+
+	rules, err := db.GetSecretScanRules(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rules).To(ConsistOf(HaveField("RuleID", "acme-license")))
+	g.Expect(db.GetSecretScanRules(ctx, testutil.NewOrganization(ctx, t).ID)).To(BeEmpty())
+
+	g.Expect(db.DeleteSecretScanRule(ctx, rule.ID, org.ID)).To(Succeed())
+	g.Expect(db.DeleteSecretScanRule(ctx, rule.ID, org.ID)).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestSecretScanDismissalsAndFindings(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	userID := org.Vendors[0].ID
+	fingerprint := strings.Repeat("ab", 32)
+
+	dismissal := types.SecretScanDismissal{
+		OrganizationID:         org.ID,
+		CreatedByUserAccountID: &userID,
+		Fingerprint:            fingerprint,
+		Reason:                 "test fixture",
+	}
+	g.Expect(db.CreateSecretScanDismissal(ctx, &dismissal)).To(Succeed())
+	// dismissing the same fingerprint again only updates the reason
+	again := dismissal
+	again.Reason = "example credentials"
+	g.Expect(db.CreateSecretScanDismissal(ctx, &again)).To(Succeed())
+	g.Expect(again.ID).To(Equal(dismissal.ID))
+	dismissals, err := db.GetSecretScanDismissals(ctx, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dismissals).To(ConsistOf(HaveField("Reason", "example credentials")))
+	g.Expect(db.DeleteSecretScanDismissal(ctx, dismissal.ID, org.ID)).To(Succeed())
+	g.Expect(db.DeleteSecretScanDismissal(ctx, dismissal.ID, org.ID)).To(MatchError(apierrors.ErrNotFound))
+
+	finding := types.SecretScanFinding{
+		OrganizationID: org.ID,
+		UserAccountID:  util.PtrTo(userID),
+		Source:         types.SecretScanSourceApplicationVersion,
+		File:           "composefile",
+		Line:           5,
+		RuleID:         "aws-access-key-id",
+		Redacted:       "AKIA********",
+		Fingerprint:    fingerprint,
+	}
+	warned := finding
+	warned.Source = types.SecretScanSourceDeployment
+	warned.Blocked = false
+	finding.Blocked = true
+	g.Expect(db.CreateSecretScanFindings(ctx, []types.SecretScanFinding{finding, warned})).To(Succeed())
+
+	findings, next, err := db.GetSecretScanFindingsPage(ctx, org.ID, pagination.Page{Limit: 10})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next).To(BeNil())
+	g.Expect(findings).To(ConsistOf(
+		And(HaveField("Source", types.SecretScanSourceApplicationVersion), HaveField("Blocked", true)),
+		And(HaveField("Source", types.SecretScanSourceDeployment), HaveField("Blocked", false)),
+	))
+}
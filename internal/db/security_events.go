@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const securityEventOutputExpr = `
+	e.id, e.created_at, e.useraccount_id, e.type, e.ip_address, e.user_agent, e.country, e.details, e.mail_sent_at
+`
+
+func CreateSecurityEvent(ctx context.Context, event *types.SecurityEvent) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO SecurityEvent AS e (useraccount_id, type, ip_address, user_agent, country, details)
+			VALUES (@userAccountId, @type, @ipAddress, @userAgent, @country, @details)
+			RETURNING`+securityEventOutputExpr,
+		pgx.NamedArgs{
+			"userAccountId": event.UserAccountID,
+			"type":          event.Type,
+			"ipAddress":     event.IPAddress,
+			"userAgent":     event.UserAgent,
+			"country":       event.Country,
+			"details":       event.Details,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert SecurityEvent: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.SecurityEvent]); err != nil {
+		return fmt.Errorf("failed to get SecurityEvent: %w", err)
+	} else {
+		*event = result
+		return nil
+	}
+}
+
+func UpdateSecurityEventMailSent(ctx context.Context, event *types.SecurityEvent) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"UPDATE SecurityEvent AS e SET mail_sent_at = current_timestamp WHERE e.id = @id RETURNING"+
+			securityEventOutputExpr,
+		pgx.NamedArgs{"id": event.ID})
+	if err != nil {
+		return fmt.Errorf("failed to update SecurityEvent: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.SecurityEvent])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apierrors.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get SecurityEvent: %w", err)
+	} else {
+		*event = result
+		return nil
+	}
+}
+
+// SecurityEventsPageSpec orders security events from newest to oldest.
+var SecurityEventsPageSpec = pagination.Spec{
+	Name: "SecurityEvent",
+	Columns: []pagination.Column{
+		{Expr: "e.created_at", Type: "TIMESTAMP", Desc: true},
+		{Expr: "e.id", Type: "UUID", Desc: true},
+	},
+}
+
+func GetSecurityEventsPage(
+	ctx context.Context,
+	userAccountID uuid.UUID,
+	page pagination.Page,
+) ([]types.SecurityEvent, []any, error) {
+	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{"userAccountId": userAccountID}
+	rows, err := db.Query(ctx,
+		"SELECT"+securityEventOutputExpr+"FROM SecurityEvent e "+
+			"WHERE e.useraccount_id = @userAccountId AND "+SecurityEventsPageSpec.Where(page, args)+" "+
+			SecurityEventsPageSpec.OrderByLimit(page, args),
+		args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query SecurityEvents: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.SecurityEvent])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get SecurityEvents: %w", err)
+	}
+	result, next := pagination.Trim(page, result, func(e types.SecurityEvent) []any {
+		return []any{e.CreatedAt, e.ID}
+	})
+	return result, next, nil
+}
+
+// GetLoginDevice reports whether the user has logged in before and whether a previous login used the same user
+// agent from the same country.
+func GetLoginDevice(
+	ctx context.Context,
+	userAccountID uuid.UUID,
+	userAgent, country *string,
+) (hasLogins bool, known bool, err error) {
+	db := internalctx.GetDb(ctx)
+	err = db.QueryRow(ctx,
+		`SELECT count(*) > 0,
+				coalesce(bool_or(
+					e.user_agent IS NOT DISTINCT FROM @userAgent AND e.country IS NOT DISTINCT FROM @country
+				), false)
+			FROM SecurityEvent e
+			WHERE e.useraccount_id = @userAccountId AND e.type IN ('login', 'new_device_login')`,
+		pgx.NamedArgs{"userAccountId": userAccountID, "userAgent": userAgent, "country": country},
+	).Scan(&hasLogins, &known)
+	if err != nil {
+		err = fmt.Errorf("failed to query SecurityEvents: %w", err)
+	}
+	return
+}
+
+// IncrementUserAccountFailedLoginAttempts increments the failed login attempts counter of a user and returns the new
+// value. The counter is reset by UpdateUserAccountLastLoggedIn.
+func IncrementUserAccountFailedLoginAttempts(ctx context.Context, userAccountID uuid.UUID) (int, error) {
+	db := internalctx.GetDb(ctx)
+	var attempts int
+	err := db.QueryRow(ctx,
+		`UPDATE UserAccount SET failed_login_attempts = failed_login_attempts + 1 WHERE id = @id
+			RETURNING failed_login_attempts`,
+		pgx.NamedArgs{"id": userAccountID},
+	).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, apierrors.ErrNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("could not update failed_login_attempts on UserAccount: %w", err)
+	}
+	return attempts, nil
+}
+
+// GetUserAccountSecurityEventMailsDisabled returns the security event types for which the user does not want to
+// receive mails.
+func GetUserAccountSecurityEventMailsDisabled(
+	ctx context.Context,
+	userAccountID uuid.UUID,
+) ([]types.SecurityEventType, error) {
+	db := internalctx.GetDb(ctx)
+	var result []types.SecurityEventType
+	err := db.QueryRow(ctx,
+		"SELECT security_event_mails_disabled FROM UserAccount WHERE id = @id",
+		pgx.NamedArgs{"id": userAccountID},
+	).Scan(&result)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get security_event_mails_disabled of UserAccount: %w", err)
+	}
+	return result, nil
+}
+
+func UpdateUserAccountSecurityEventMailsDisabled(
+	ctx context.Context,
+	userAccountID uuid.UUID,
+	disabled []types.SecurityEventType,
+) error {
+	if disabled == nil {
+		disabled = []types.SecurityEventType{}
+	}
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"UPDATE UserAccount SET security_event_mails_disabled = @disabled WHERE id = @id",
+		pgx.NamedArgs{"id": userAccountID, "disabled": disabled},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update security_event_mails_disabled on UserAccount: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
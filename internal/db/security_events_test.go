@@ -0,0 +1,88 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestSecurityEvents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+
+	hasLogins, known, err := db.GetLoginDevice(ctx, user.ID, util.PtrTo("curl/8"), nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(hasLogins).To(BeFalse())
+	g.Expect(known).To(BeFalse())
+
+	login := types.SecurityEvent{
+		UserAccountID: user.ID,
+		Type:          types.SecurityEventTypeLogin,
+		UserAgent:     util.PtrTo("curl/8"),
+	}
+	g.Expect(db.CreateSecurityEvent(ctx, &login)).To(Succeed())
+	g.Expect(login.ID).NotTo(Equal(uuid.Nil))
+	g.Expect(login.MailSentAt).To(BeNil())
+
+	hasLogins, known, err = db.GetLoginDevice(ctx, user.ID, util.PtrTo("curl/8"), nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(hasLogins).To(BeTrue())
+	g.Expect(known).To(BeTrue())
+	_, known, err = db.GetLoginDevice(ctx, user.ID, util.PtrTo("curl/8"), util.PtrTo("AT"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(known).To(BeFalse(), "a login from another country is a new device")
+
+	changed := types.SecurityEvent{UserAccountID: user.ID, Type: types.SecurityEventTypePasswordChanged}
+	g.Expect(db.CreateSecurityEvent(ctx, &changed)).To(Succeed())
+	g.Expect(db.UpdateSecurityEventMailSent(ctx, &changed)).To(Succeed())
+	g.Expect(changed.MailSentAt).NotTo(BeNil())
+
+	first, next, err := db.GetSecurityEventsPage(ctx, user.ID, pagination.Page{Limit: 1})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(first).To(HaveLen(1))
+	g.Expect(next).NotTo(BeNil())
+	second, next, err := db.GetSecurityEventsPage(ctx, user.ID, pagination.Page{Limit: 1, After: next})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(second).To(HaveLen(1))
+	g.Expect(next).To(BeNil())
+	g.Expect([]uuid.UUID{first[0].ID, second[0].ID}).To(ConsistOf(login.ID, changed.ID))
+
+	other := testutil.NewUserAccount(ctx, t)
+	events, _, err := db.GetSecurityEventsPage(ctx, other.ID, pagination.Page{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(events).To(BeEmpty())
+}
+
+func TestUserAccountFailedLoginAttempts(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+
+	g.Expect(db.IncrementUserAccountFailedLoginAttempts(ctx, user.ID)).To(Equal(1))
+	g.Expect(db.IncrementUserAccountFailedLoginAttempts(ctx, user.ID)).To(Equal(2))
+	g.Expect(db.UpdateUserAccountLastLoggedIn(ctx, user.ID)).To(Succeed())
+	g.Expect(db.IncrementUserAccountFailedLoginAttempts(ctx, user.ID)).To(Equal(1))
+	_, err := db.IncrementUserAccountFailedLoginAttempts(ctx, uuid.New())
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestUserAccountSecurityEventMailsDisabled(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+
+	g.Expect(db.GetUserAccountSecurityEventMailsDisabled(ctx, user.ID)).To(BeEmpty())
+	disabled := []types.SecurityEventType{types.SecurityEventTypeAccessTokenCreated}
+	g.Expect(db.UpdateUserAccountSecurityEventMailsDisabled(ctx, user.ID, disabled)).To(Succeed())
+	g.Expect(db.GetUserAccountSecurityEventMailsDisabled(ctx, user.ID)).To(Equal(disabled))
+	g.Expect(db.UpdateUserAccountSecurityEventMailsDisabled(ctx, user.ID, nil)).To(Succeed())
+	g.Expect(db.GetUserAccountSecurityEventMailsDisabled(ctx, user.ID)).To(BeEmpty())
+}
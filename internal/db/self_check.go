@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetSchemaVersion returns the version of the last migration that was applied to the database and whether it failed
+// half-way. The version is zero if no migration has been applied yet.
+func GetSchemaVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	db := internalctx.GetDb(ctx)
+	err = db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("could not query schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// GetRecentPlatformBlobDigests returns the digests of the most recently recorded blobs that are stored in the platform
+// bucket, i.e. not in the bucket of an organization.
+func GetRecentPlatformBlobDigests(ctx context.Context, limit int) ([]types.Digest, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`SELECT b.digest
+		FROM BlobMetadata b
+		WHERE NOT EXISTS (SELECT 1 FROM OrganizationBlob ob WHERE ob.digest = b.digest)
+		ORDER BY b.created_at DESC
+		LIMIT @limit`,
+		pgx.NamedArgs{"limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query BlobMetadata: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowTo[types.Digest]); err != nil {
+		return nil, fmt.Errorf("could not collect BlobMetadata: %w", err)
+	} else {
+		return result, nil
+	}
+}
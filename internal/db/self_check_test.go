@@ -0,0 +1,41 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/migrations"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetSchemaVersion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	expected, err := migrations.LatestVersion()
+	g.Expect(err).NotTo(HaveOccurred())
+	version, dirty, err := db.GetSchemaVersion(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(version).To(Equal(expected))
+	g.Expect(dirty).To(BeFalse())
+}
+
+func TestGetRecentPlatformBlobDigests(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	platform := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0123456789abcdef", 4)})
+	organization := types.Digest(v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("fedcba9876543210", 4)})
+	for _, digest := range []types.Digest{platform, organization} {
+		g.Expect(db.SaveBlobMetadata(ctx, &types.BlobMetadata{Digest: digest, Size: 1})).To(Succeed())
+	}
+	g.Expect(db.CreateOrganizationBlob(ctx, org.ID, organization)).To(Succeed())
+
+	digests, err := db.GetRecentPlatformBlobDigests(ctx, 100)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(digests).To(ContainElement(platform))
+	g.Expect(digests).NotTo(ContainElement(organization))
+}
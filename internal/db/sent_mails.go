@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const sentMailOutputExpr = `
+	m.id, m.created_at, m.organization_id, m.recipient, m.type, m.subject, m.provider_message_id, m.status,
+	m.status_details, m.status_updated_at
+`
+
+// CreateSentMail stores a sent mail. The recipient is encrypted with the key of the organization of the mail, if PII
+// encryption is configured.
+func CreateSentMail(ctx context.Context, sentMail *types.SentMail) error {
+	db := internalctx.GetDb(ctx)
+	recipient, version, err := encryptSentMailRecipient(sentMail.OrganizationID, sentMail.Recipient)
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(ctx,
+		`INSERT INTO SentMail AS m
+			(organization_id, recipient, pii_key_version, type, subject, provider_message_id, status, status_details)
+			VALUES (@organizationId, @recipient, @piiKeyVersion, @type, @subject, @providerMessageId, @status,
+				@statusDetails)
+			RETURNING`+sentMailOutputExpr,
+		pgx.NamedArgs{
+			"organizationId":    sentMail.OrganizationID,
+			"recipient":         recipient,
+			"piiKeyVersion":     version,
+			"type":              sentMail.Type,
+			"subject":           sentMail.Subject,
+			"providerMessageId": sentMail.ProviderMessageID,
+			"status":            sentMail.Status,
+			"statusDetails":     sentMail.StatusDetails,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert SentMail: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.SentMail]); err != nil {
+		return fmt.Errorf("failed to get SentMail: %w", err)
+	} else if err := decryptPII(&result.Recipient); err != nil {
+		return err
+	} else {
+		*sentMail = result
+		return nil
+	}
+}
+
+// UpdateSentMailStatusByProviderMessageID sets the status of all mails with the given provider message id and returns
+// the number of updated mails. A mail that bounced or caused a complaint is never marked as delivered again.
+func UpdateSentMailStatusByProviderMessageID(
+	ctx context.Context,
+	providerMessageID string,
+	status types.SentMailStatus,
+	details *string,
+) (int64, error) {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`UPDATE SentMail SET status = @status, status_details = @details, status_updated_at = current_timestamp
+			WHERE provider_message_id = @providerMessageId
+				AND NOT (@status = 'delivered' AND status IN ('bounced', 'complained'))`,
+		pgx.NamedArgs{"providerMessageId": providerMessageID, "status": status, "details": details},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not update status on SentMail: %w", err)
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// SentMailsPageSpec orders sent mails from newest to oldest.
+var SentMailsPageSpec = pagination.Spec{
+	Name: "SentMail",
+	Columns: []pagination.Column{
+		{Expr: "m.created_at", Type: "TIMESTAMP", Desc: true},
+		{Expr: "m.id", Type: "UUID", Desc: true},
+	},
+}
+
+func GetSentMailsPage(
+	ctx context.Context,
+	organizationID uuid.UUID,
+	page pagination.Page,
+) ([]types.SentMail, []any, error) {
+	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{"organizationId": organizationID}
+	rows, err := db.Query(ctx,
+		"SELECT"+sentMailOutputExpr+"FROM SentMail m "+
+			"WHERE m.organization_id = @organizationId AND "+SentMailsPageSpec.Where(page, args)+" "+
+			SentMailsPageSpec.OrderByLimit(page, args),
+		args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query SentMails: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.SentMail])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get SentMails: %w", err)
+	}
+	for i := range result {
+		if err := decryptPII(&result[i].Recipient); err != nil {
+			return nil, nil, err
+		}
+	}
+	result, next := pagination.Trim(page, result, func(m types.SentMail) []any {
+		return []any{m.CreatedAt, m.ID}
+	})
+	return result, next, nil
+}
+
+// EncryptSentMails encrypts the recipients of at most batchSize sent mails that are stored in plain text or use a key
+// other than the active key. It returns the number of updated mails.
+func EncryptSentMails(ctx context.Context, batchSize int) (int, error) {
+	keyring := pii.Default()
+	if keyring == nil {
+		return 0, nil
+	}
+	var count int
+	err := RunTx(ctx, func(ctx context.Context) error {
+		db := internalctx.GetDb(ctx)
+		rows, err := db.Query(ctx,
+			`SELECT`+sentMailOutputExpr+`
+			FROM SentMail m
+			WHERE m.pii_key_version IS DISTINCT FROM @version
+			LIMIT @batchSize
+			FOR UPDATE OF m SKIP LOCKED`,
+			pgx.NamedArgs{"version": keyring.ActiveVersion(), "batchSize": batchSize},
+		)
+		if err != nil {
+			return fmt.Errorf("could not query SentMail: %w", err)
+		}
+		mails, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.SentMail])
+		if err != nil {
+			return fmt.Errorf("could not map SentMail: %w", err)
+		}
+		for _, mail := range mails {
+			if err := decryptPII(&mail.Recipient); err != nil {
+				return err
+			}
+			recipient, version, err := encryptSentMailRecipient(mail.OrganizationID, mail.Recipient)
+			if err != nil {
+				return err
+			}
+			if _, err := db.Exec(ctx,
+				"UPDATE SentMail SET recipient = @recipient, pii_key_version = @version WHERE id = @id",
+				pgx.NamedArgs{"id": mail.ID, "recipient": recipient, "version": version},
+			); err != nil {
+				return fmt.Errorf("could not update SentMail: %w", err)
+			}
+		}
+		count = len(mails)
+		return nil
+	})
+	return count, err
+}
+
+// encryptSentMailRecipient returns the recipient encrypted with the key of the organization, or with the nil UUID for
+// mails that do not belong to an organization, and the version of the key. If encryption is not configured, the
+// recipient is returned in plain text without a version.
+func encryptSentMailRecipient(organizationID *uuid.UUID, recipient string) (string, *int, error) {
+	keyring := pii.Default()
+	if keyring == nil {
+		return recipient, nil, nil
+	}
+	scope := uuid.Nil
+	if organizationID != nil {
+		scope = *organizationID
+	}
+	if encrypted, err := keyring.Encrypt(scope, recipient); err != nil {
+		return "", nil, fmt.Errorf("could not encrypt SentMail: %w", err)
+	} else {
+		version := keyring.ActiveVersion()
+		return encrypted, &version, nil
+	}
+}
@@ -0,0 +1,145 @@
+package db_test
+
+import (
+	"testing"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestSentMails(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+
+	sent := types.SentMail{
+		OrganizationID:    &org.ID,
+		Recipient:         "jane.doe@example.com",
+		Type:              types.MailTypeInviteUser,
+		Subject:           "Welcome to Distr",
+		ProviderMessageID: util.PtrTo(uuid.NewString()),
+		Status:            types.SentMailStatusSent,
+	}
+	g.Expect(db.CreateSentMail(ctx, &sent)).To(Succeed())
+	g.Expect(sent.ID).NotTo(Equal(uuid.Nil))
+	failed := types.SentMail{
+		OrganizationID: &org.ID,
+		Recipient:      "john.doe@example.com",
+		Type:           types.MailTypePasswordReset,
+		Subject:        "Password reset",
+		Status:         types.SentMailStatusFailed,
+		StatusDetails:  util.PtrTo("connection refused"),
+	}
+	g.Expect(db.CreateSentMail(ctx, &failed)).To(Succeed())
+
+	g.Expect(db.UpdateSentMailStatusByProviderMessageID(
+		ctx, *sent.ProviderMessageID, types.SentMailStatusBounced, util.PtrTo("Permanent General"),
+	)).To(Equal(int64(1)))
+	g.Expect(db.UpdateSentMailStatusByProviderMessageID(
+		ctx, *sent.ProviderMessageID, types.SentMailStatusDelivered, nil,
+	)).To(Equal(int64(0)), "a bounced mail is never marked as delivered")
+	g.Expect(db.UpdateSentMailStatusByProviderMessageID(
+		ctx, uuid.NewString(), types.SentMailStatusBounced, nil,
+	)).To(Equal(int64(0)))
+
+	first, next, err := db.GetSentMailsPage(ctx, org.ID, pagination.Page{Limit: 1})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(first).To(HaveLen(1))
+	g.Expect(next).NotTo(BeNil())
+	second, next, err := db.GetSentMailsPage(ctx, org.ID, pagination.Page{Limit: 1, After: next})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(second).To(HaveLen(1))
+	g.Expect(next).To(BeNil())
+	all := append(first, second...)
+	g.Expect(all).To(ContainElement(And(
+		HaveField("ID", sent.ID),
+		HaveField("Status", types.SentMailStatusBounced),
+		HaveField("StatusDetails", HaveValue(Equal("Permanent General"))),
+	)))
+	g.Expect(all).To(ContainElement(HaveField("ID", failed.ID)))
+
+	other := testutil.NewOrganization(ctx, t)
+	mails, _, err := db.GetSentMailsPage(ctx, other.ID, pagination.Page{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mails).To(BeEmpty())
+}
+
+func TestSentMailRecipientIsEncrypted(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganization(ctx, t)
+	storedRecipient := func(id uuid.UUID) (recipient string, version *int) {
+		g.Expect(internalctx.GetDb(ctx).QueryRow(ctx,
+			"SELECT recipient, pii_key_version FROM SentMail WHERE id = $1", id,
+		).Scan(&recipient, &version)).To(Succeed())
+		return recipient, version
+	}
+	newMail := func(orgID *uuid.UUID, recipient string) *types.SentMail {
+		mail := types.SentMail{
+			OrganizationID: orgID,
+			Recipient:      recipient,
+			Type:           types.MailTypeInviteUser,
+			Subject:        "Welcome to Distr",
+			Status:         types.SentMailStatusSent,
+		}
+		g.Expect(db.CreateSentMail(ctx, &mail)).To(Succeed())
+		return &mail
+	}
+
+	plain := newMail(&org.ID, "plain@example.com")
+	withPIIKeys(t, piiKey1)
+	encrypted := newMail(&org.ID, "jane.doe@example.com")
+	g.Expect(encrypted.Recipient).To(Equal("jane.doe@example.com"))
+	recipient, version := storedRecipient(encrypted.ID)
+	g.Expect(recipient).To(HavePrefix("pii:1:" + org.ID.String() + ":"))
+	g.Expect(version).To(HaveValue(Equal(1)))
+	withoutOrg := newMail(nil, "system@example.com")
+	recipient, _ = storedRecipient(withoutOrg.ID)
+	g.Expect(recipient).To(HavePrefix("pii:1:" + uuid.Nil.String() + ":"))
+
+	// mails that have been sent before encryption was configured are encrypted by the encryption job
+	recipient, version = storedRecipient(plain.ID)
+	g.Expect(recipient).To(Equal("plain@example.com"))
+	g.Expect(version).To(BeNil())
+	mails, _, err := db.GetSentMailsPage(ctx, org.ID, pagination.Page{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mails).To(ConsistOf(
+		HaveField("Recipient", "plain@example.com"),
+		HaveField("Recipient", "jane.doe@example.com"),
+	))
+	for {
+		if count, err := db.EncryptSentMails(ctx, 100); err != nil {
+			t.Fatal(err)
+		} else if count == 0 {
+			break
+		}
+	}
+	recipient, version = storedRecipient(plain.ID)
+	g.Expect(pii.IsEncrypted(recipient)).To(BeTrue())
+	g.Expect(version).To(HaveValue(Equal(1)))
+
+	// rotating the key re-encrypts all mails
+	withPIIKeys(t, piiKey2, piiKey1)
+	for {
+		if count, err := db.EncryptSentMails(ctx, 100); err != nil {
+			t.Fatal(err)
+		} else if count == 0 {
+			break
+		}
+	}
+	recipient, _ = storedRecipient(encrypted.ID)
+	g.Expect(recipient).To(HavePrefix("pii:2:"))
+	mails, _, err = db.GetSentMailsPage(ctx, org.ID, pagination.Page{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mails).To(ConsistOf(
+		HaveField("Recipient", "plain@example.com"),
+		HaveField("Recipient", "jane.doe@example.com"),
+	))
+}
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/authkey"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	serviceAccountOutputExpr = `
+		sa.id, sa.created_at, sa.organization_id, sa.name, sa.description, sa.created_by_user_account_id, sa.revoked_at
+	`
+	serviceAccountTokenOutputExpr = `
+		tok.id, tok.created_at, tok.service_account_id, tok.label, tok.key_hash, tok.key_prefix, tok.expires_at,
+		tok.last_used_at, tok.registry_scopes
+	`
+)
+
+func GetServiceAccounts(ctx context.Context, orgID uuid.UUID) ([]types.ServiceAccount, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		"SELECT "+serviceAccountOutputExpr+` FROM ServiceAccount sa
+			WHERE sa.organization_id = @orgId
+			ORDER BY sa.revoked_at DESC NULLS FIRST, sa.name`,
+		pgx.NamedArgs{"orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ServiceAccounts: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ServiceAccount]); err != nil {
+		return nil, fmt.Errorf("could not query ServiceAccounts: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+func GetServiceAccount(ctx context.Context, id, orgID uuid.UUID) (*types.ServiceAccount, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		"SELECT "+serviceAccountOutputExpr+" FROM ServiceAccount sa WHERE sa.id = @id AND sa.organization_id = @orgId",
+		pgx.NamedArgs{"id": id, "orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ServiceAccount: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ServiceAccount]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not query ServiceAccount: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// CreateServiceAccount returns apierrors.ErrAlreadyExists if the organization already has a service account with the
+// same name that has not been revoked.
+func CreateServiceAccount(ctx context.Context, sa *types.ServiceAccount) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`INSERT INTO ServiceAccount AS sa (organization_id, name, description, created_by_user_account_id)
+			VALUES (@orgId, @name, @description, @createdBy)
+			RETURNING `+serviceAccountOutputExpr,
+		pgx.NamedArgs{
+			"orgId":       sa.OrganizationID,
+			"name":        sa.Name,
+			"description": sa.Description,
+			"createdBy":   sa.CreatedByUserAccountID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert ServiceAccount: %w", err)
+	}
+	return collectServiceAccount(rows, sa)
+}
+
+// UpdateServiceAccount updates the name and description of a service account that has not been revoked.
+// It returns apierrors.ErrNotFound if there is no such service account.
+func UpdateServiceAccount(ctx context.Context, sa *types.ServiceAccount) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`UPDATE ServiceAccount AS sa SET name = @name, description = @description
+			WHERE sa.id = @id AND sa.organization_id = @orgId AND sa.revoked_at IS NULL
+			RETURNING `+serviceAccountOutputExpr,
+		pgx.NamedArgs{
+			"id":          sa.ID,
+			"orgId":       sa.OrganizationID,
+			"name":        sa.Name,
+			"description": sa.Description,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not update ServiceAccount: %w", err)
+	}
+	return collectServiceAccount(rows, sa)
+}
+
+// RevokeServiceAccount marks a service account as revoked and deletes all of its tokens. It returns
+// apierrors.ErrNotFound if there is no such service account or if it has already been revoked.
+func RevokeServiceAccount(ctx context.Context, id, orgID uuid.UUID) (*types.ServiceAccount, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`WITH revoked AS (
+			UPDATE ServiceAccount SET revoked_at = now()
+			WHERE id = @id AND organization_id = @orgId AND revoked_at IS NULL
+			RETURNING *
+		), deleted AS (
+			DELETE FROM ServiceAccountToken WHERE service_account_id IN (SELECT id FROM revoked)
+		)
+		SELECT `+serviceAccountOutputExpr+` FROM revoked sa`,
+		pgx.NamedArgs{"id": id, "orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not revoke ServiceAccount: %w", err)
+	}
+	var result types.ServiceAccount
+	if err := collectServiceAccount(rows, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func collectServiceAccount(rows pgx.Rows, sa *types.ServiceAccount) error {
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ServiceAccount]); err != nil {
+		var pgError *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		} else if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = apierrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("could not save ServiceAccount: %w", err)
+	} else {
+		*sa = result
+		return nil
+	}
+}
+
+func GetServiceAccountTokens(ctx context.Context, serviceAccountID uuid.UUID) ([]types.ServiceAccountToken, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		"SELECT "+serviceAccountTokenOutputExpr+` FROM ServiceAccountToken tok
+			WHERE tok.service_account_id = @serviceAccountId
+			ORDER BY tok.created_at`,
+		pgx.NamedArgs{"serviceAccountId": serviceAccountID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ServiceAccountTokens: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.ServiceAccountToken]); err != nil {
+		return nil, fmt.Errorf("could not query ServiceAccountTokens: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+func CreateServiceAccountToken(ctx context.Context, token *types.ServiceAccountToken) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`INSERT INTO ServiceAccountToken AS tok
+			(service_account_id, label, key_hash, key_prefix, expires_at, registry_scopes)
+			VALUES (@serviceAccountId, @label, @keyHash, @keyPrefix, @expiresAt,
+				NULLIF(@registryScopes::jsonb, 'null'::jsonb))
+			RETURNING `+serviceAccountTokenOutputExpr,
+		pgx.NamedArgs{
+			"serviceAccountId": token.ServiceAccountID,
+			"label":            token.Label,
+			"keyHash":          token.KeyHash,
+			"keyPrefix":        token.KeyPrefix,
+			"expiresAt":        token.ExpiresAt,
+			"registryScopes":   token.RegistryScopes,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not create service account token: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.ServiceAccountToken]); err != nil {
+		return fmt.Errorf("could not create service account token: %w", err)
+	} else {
+		*token = result
+		return nil
+	}
+}
+
+// DeleteServiceAccountToken returns apierrors.ErrNotFound if the service account has no token with the given ID.
+func DeleteServiceAccountToken(ctx context.Context, id, serviceAccountID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	if cmd, err := db.Exec(
+		ctx,
+		"DELETE FROM ServiceAccountToken WHERE id = @id AND service_account_id = @serviceAccountId",
+		pgx.NamedArgs{"id": id, "serviceAccountId": serviceAccountID},
+	); err != nil {
+		return fmt.Errorf("could not delete service account token: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+// GetServiceAccountTokenByKeyUpdatingLastUsed returns the token with the given key, unless it has expired or its
+// service account has been revoked.
+func GetServiceAccountTokenByKeyUpdatingLastUsed(
+	ctx context.Context,
+	key authkey.Key,
+) (*types.ServiceAccountTokenWithServiceAccount, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`WITH updated AS (
+			UPDATE ServiceAccountToken
+			SET last_used_at = now()
+			WHERE key_hash = @keyHash AND (expires_at IS NULL OR expires_at > now())
+			RETURNING *
+		)
+		SELECT `+serviceAccountTokenOutputExpr+`, (`+serviceAccountOutputExpr+`) AS service_account
+		FROM updated tok
+		INNER JOIN ServiceAccount sa ON sa.id = tok.service_account_id
+		WHERE sa.revoked_at IS NULL`,
+		pgx.NamedArgs{"keyHash": key.Hash()},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying service account token: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(
+		rows, pgx.RowToStructByName[types.ServiceAccountTokenWithServiceAccount],
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get service account token: %w", err)
+	} else {
+		return &result, nil
+	}
+}
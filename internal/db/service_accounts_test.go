@@ -0,0 +1,81 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/authkey"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestServiceAccounts(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	other := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+
+	sa := types.ServiceAccount{OrganizationID: org.ID, Name: "ci"}
+	g.Expect(db.CreateServiceAccount(ctx, &sa)).To(Succeed())
+	g.Expect(sa.IsRevoked()).To(BeFalse())
+	g.Expect(db.RunTx(ctx, func(ctx context.Context) error {
+		return db.CreateServiceAccount(ctx, &types.ServiceAccount{OrganizationID: org.ID, Name: "ci"})
+	})).To(MatchError(apierrors.ErrAlreadyExists))
+	g.Expect(db.CreateServiceAccount(ctx, &types.ServiceAccount{OrganizationID: other.ID, Name: "ci"})).To(Succeed())
+
+	_, err := db.GetServiceAccount(ctx, sa.ID, other.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.GetServiceAccounts(ctx, org.ID)).To(HaveLen(1))
+
+	key, err := authkey.NewKey()
+	g.Expect(err).NotTo(HaveOccurred())
+	token := types.ServiceAccountToken{ServiceAccountID: sa.ID, KeyHash: key.Hash(), KeyPrefix: key.DisplayPrefix()}
+	g.Expect(db.CreateServiceAccountToken(ctx, &token)).To(Succeed())
+	g.Expect(token.RegistryScopes).To(BeNil())
+
+	loaded, err := db.GetServiceAccountTokenByKeyUpdatingLastUsed(ctx, key)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.ID).To(Equal(token.ID))
+	g.Expect(loaded.LastUsedAt).NotTo(BeNil())
+	g.Expect(loaded.ServiceAccount.ID).To(Equal(sa.ID))
+
+	revoked, err := db.RevokeServiceAccount(ctx, sa.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(revoked.IsRevoked()).To(BeTrue())
+	_, err = db.RevokeServiceAccount(ctx, sa.ID, org.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	_, err = db.GetServiceAccountTokenByKeyUpdatingLastUsed(ctx, key)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.GetServiceAccountTokens(ctx, sa.ID)).To(BeEmpty())
+
+	// the name of a revoked service account can be used again
+	g.Expect(db.CreateServiceAccount(ctx, &types.ServiceAccount{OrganizationID: org.ID, Name: "ci"})).To(Succeed())
+}
+
+func TestServiceAccountTokenExpiry(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	sa := types.ServiceAccount{OrganizationID: org.ID, Name: "ci"}
+	g.Expect(db.CreateServiceAccount(ctx, &sa)).To(Succeed())
+
+	key, err := authkey.NewKey()
+	g.Expect(err).NotTo(HaveOccurred())
+	token := types.ServiceAccountToken{
+		ServiceAccountID: sa.ID,
+		KeyHash:          key.Hash(),
+		KeyPrefix:        key.DisplayPrefix(),
+		ExpiresAt:        util.PtrTo(time.Now().Add(-time.Minute)),
+	}
+	g.Expect(db.CreateServiceAccountToken(ctx, &token)).To(Succeed())
+	_, err = db.GetServiceAccountTokenByKeyUpdatingLastUsed(ctx, key)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.DeleteServiceAccountToken(ctx, token.ID, org.ID)).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.DeleteServiceAccountToken(ctx, token.ID, sa.ID)).To(Succeed())
+}
@@ -9,6 +9,11 @@ import (
 	"go.uber.org/multierr"
 )
 
+// RunTx runs f in a database transaction that is committed if f returns no error and rolled back otherwise.
+//
+// If ctx already contains a transaction, f is executed in a savepoint of that transaction. An error only rolls back
+// the changes made by f and the outer transaction can continue, but the changes are only persisted once the outer
+// transaction is committed.
 func RunTx(ctx context.Context, f func(ctx context.Context) error) (finalErr error) {
 	db := internalctx.GetDb(ctx)
 	if tx, err := db.Begin(ctx); err != nil {
@@ -27,3 +32,9 @@ func RunTx(ctx context.Context, f func(ctx context.Context) error) (finalErr err
 		}
 	}
 }
+
+// RunAutonomous runs f outside of any transaction that might be contained in ctx, so that changes made by f are
+// persisted even if the surrounding transaction is rolled back later on (e.g. for writing audit records).
+func RunAutonomous(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(internalctx.WithDb(ctx, internalctx.GetRootDb(ctx)))
+}
@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RegisterTypes registers the custom PostgreSQL types of the schema with conn.
+// It must be called for every new connection, e.g. in pgxpool.Config.AfterConnect.
+func RegisterTypes(ctx context.Context, conn *pgx.Conn) error {
+	typeNames := []string{
+		"DEPLOYMENT_TYPE", "USER_ROLE", "HELM_CHART_TYPE",
+		"DEPLOYMENT_STATUS_TYPE", "FEATURE", "_FEATURE", "TUTORIAL", "MAIL_CONFIG_TYPE",
+		"CUSTOM_FIELD_TYPE", "CUSTOM_FIELD_TARGET", "DEPLOYMENT_REASON_POLICY",
+		"SECURITY_EVENT_TYPE", "_SECURITY_EVENT_TYPE", "FILE_RETRIEVAL_STATUS",
+	}
+	for _, typeName := range typeNames {
+		if pgType, err := conn.LoadType(ctx, typeName); err != nil {
+			return err
+		} else {
+			conn.TypeMap().RegisterType(pgType)
+		}
+	}
+	return nil
+}
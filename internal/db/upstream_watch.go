@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	upstreamWatchOutputExpr = `
+		w.id, w.created_at, w.organization_id, w.created_by_useraccount_id, w.reference, w.registry, w.digest, w.etag,
+		w.last_checked_at, w.last_error
+	`
+	upstreamWatchChangeOutputExpr = `
+		c.id, c.created_at, c.upstream_watch_id, c.previous_digest, c.digest
+	`
+)
+
+func GetUpstreamWatches(ctx context.Context, orgID uuid.UUID) ([]types.UpstreamWatch, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+upstreamWatchOutputExpr+"FROM UpstreamWatch w WHERE w.organization_id = @orgId ORDER BY w.reference",
+		pgx.NamedArgs{"orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query UpstreamWatches: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.UpstreamWatch])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UpstreamWatches: %w", err)
+	}
+	return result, nil
+}
+
+func GetUpstreamWatch(ctx context.Context, id, orgID uuid.UUID) (*types.UpstreamWatch, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+upstreamWatchOutputExpr+"FROM UpstreamWatch w WHERE w.id = @id AND w.organization_id = @orgId",
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query UpstreamWatch: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.UpstreamWatch])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apierrors.ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get UpstreamWatch: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// GetDueUpstreamWatches returns at most limit watches of all organizations that have not been checked since
+// checkedBefore, least recently checked first.
+func GetDueUpstreamWatches(ctx context.Context, checkedBefore time.Time, limit int) ([]types.UpstreamWatch, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+upstreamWatchOutputExpr+"FROM UpstreamWatch w "+
+			"WHERE w.last_checked_at IS NULL OR w.last_checked_at < @checkedBefore "+
+			"ORDER BY w.last_checked_at NULLS FIRST, w.created_at LIMIT @limit",
+		pgx.NamedArgs{"checkedBefore": checkedBefore, "limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query UpstreamWatches: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.UpstreamWatch])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UpstreamWatches: %w", err)
+	}
+	return result, nil
+}
+
+func CreateUpstreamWatch(ctx context.Context, watch *types.UpstreamWatch) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO UpstreamWatch AS w (organization_id, created_by_useraccount_id, reference, registry)
+			VALUES (@orgId, @createdById, @reference, @registry)
+			RETURNING`+upstreamWatchOutputExpr,
+		pgx.NamedArgs{
+			"orgId":       watch.OrganizationID,
+			"createdById": watch.CreatedByUserAccountID,
+			"reference":   watch.Reference,
+			"registry":    watch.Registry,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert UpstreamWatch: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.UpstreamWatch]); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrAlreadyExists, err)
+		}
+		return err
+	} else {
+		*watch = result
+		return nil
+	}
+}
+
+// UpdateUpstreamWatchCheck saves the result of the last check of a watch.
+func UpdateUpstreamWatchCheck(ctx context.Context, watch *types.UpstreamWatch) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		`UPDATE UpstreamWatch SET digest = @digest, etag = @etag, last_checked_at = @lastCheckedAt,
+			last_error = @lastError
+			WHERE id = @id`,
+		pgx.NamedArgs{
+			"id":            watch.ID,
+			"digest":        watch.Digest,
+			"etag":          watch.ETag,
+			"lastCheckedAt": watch.LastCheckedAt,
+			"lastError":     watch.LastError,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to update UpstreamWatch: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+func DeleteUpstreamWatch(ctx context.Context, id, orgID uuid.UUID) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(ctx,
+		"DELETE FROM UpstreamWatch WHERE id = @id AND organization_id = @orgId",
+		pgx.NamedArgs{"id": id, "orgId": orgID})
+	if err != nil {
+		return fmt.Errorf("failed to delete UpstreamWatch: %w", err)
+	} else if cmd.RowsAffected() == 0 {
+		return apierrors.ErrNotFound
+	}
+	return nil
+}
+
+func CreateUpstreamWatchChange(ctx context.Context, change *types.UpstreamWatchChange) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		`INSERT INTO UpstreamWatchChange AS c (upstream_watch_id, previous_digest, digest)
+			VALUES (@watchId, @previousDigest, @digest)
+			RETURNING`+upstreamWatchChangeOutputExpr,
+		pgx.NamedArgs{
+			"watchId":        change.UpstreamWatchID,
+			"previousDigest": change.PreviousDigest,
+			"digest":         change.Digest,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to insert UpstreamWatchChange: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.UpstreamWatchChange]); err != nil {
+		return fmt.Errorf("failed to get UpstreamWatchChange: %w", err)
+	} else {
+		*change = result
+		return nil
+	}
+}
+
+// GetUpstreamWatchChanges returns the detected changes of a watch, latest first.
+func GetUpstreamWatchChanges(ctx context.Context, watchID uuid.UUID) ([]types.UpstreamWatchChange, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT"+upstreamWatchChangeOutputExpr+"FROM UpstreamWatchChange c "+
+			"WHERE c.upstream_watch_id = @watchId ORDER BY c.created_at DESC",
+		pgx.NamedArgs{"watchId": watchID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query UpstreamWatchChanges: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.UpstreamWatchChange])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UpstreamWatchChanges: %w", err)
+	}
+	return result, nil
+}
@@ -1,12 +1,17 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/glasskube/distr/internal/apierrors"
 	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/pii"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
@@ -45,19 +50,30 @@ func CreateUserAccountWithOrganization(
 	}
 }
 
+// CreateUserAccount creates userAccount. Its email and name are encrypted if encryption is configured.
 func CreateUserAccount(ctx context.Context, userAccount *types.UserAccount) error {
 	db := internalctx.GetDb(ctx)
+	if err := ensureEmailNotTakenInPlainText(ctx, userAccount.Email, uuid.Nil); err != nil {
+		return err
+	}
+	args, err := userAccountPIIArgs(ctx, userAccount)
+	if err != nil {
+		return err
+	}
+	args["password_hash"] = userAccount.PasswordHash
+	args["password_salt"] = userAccount.PasswordSalt
+	args["email_verified_at"] = userAccount.EmailVerifiedAt
 	rows, err := db.Query(ctx,
-		"INSERT INTO UserAccount AS u (email, password_hash, password_salt, name, email_verified_at) "+
-			"VALUES (@email, @password_hash, @password_salt, @name, @email_verified_at) "+
-			"RETURNING "+userAccountOutputExpr,
-		pgx.NamedArgs{
-			"email":             userAccount.Email,
-			"password_hash":     userAccount.PasswordHash,
-			"password_salt":     userAccount.PasswordSalt,
-			"name":              userAccount.Name,
-			"email_verified_at": userAccount.EmailVerifiedAt,
-		},
+		`INSERT INTO UserAccount AS u (
+			email, password_hash, password_salt, name, email_verified_at,
+			email_blind_index, pii_key_version, pii_organization_id
+		)
+		VALUES (
+			@email, @password_hash, @password_salt, @name, @email_verified_at,
+			@email_blind_index, @pii_key_version, @pii_organization_id
+		)
+		RETURNING `+userAccountOutputExpr,
+		args,
 	)
 	if err != nil {
 		return fmt.Errorf("could not query users: %w", err)
@@ -66,31 +82,41 @@ func CreateUserAccount(ctx context.Context, userAccount *types.UserAccount) erro
 			return fmt.Errorf("user account with email %v can not be created: %w", userAccount.Email, apierrors.ErrAlreadyExists)
 		}
 		return fmt.Errorf("could not create user: %w", err)
+	} else if err := decryptUserAccounts(&created); err != nil {
+		return err
 	} else {
 		*userAccount = created
 		return nil
 	}
 }
 
+// UpdateUserAccount updates userAccount. Its email and name are encrypted if encryption is configured.
 func UpdateUserAccount(ctx context.Context, userAccount *types.UserAccount) error {
 	db := internalctx.GetDb(ctx)
+	if err := ensureEmailNotTakenInPlainText(ctx, userAccount.Email, userAccount.ID); err != nil {
+		return err
+	}
+	args, err := userAccountPIIArgs(ctx, userAccount)
+	if err != nil {
+		return err
+	}
+	args["id"] = userAccount.ID
+	args["password_hash"] = userAccount.PasswordHash
+	args["password_salt"] = userAccount.PasswordSalt
+	args["email_verified_at"] = userAccount.EmailVerifiedAt
 	rows, err := db.Query(ctx,
 		`UPDATE UserAccount AS u
 		SET email = @email,
 			name = @name,
 			password_hash = @password_hash,
 			password_salt = @password_salt,
-			email_verified_at = @email_verified_at
+			email_verified_at = @email_verified_at,
+			email_blind_index = @email_blind_index,
+			pii_key_version = @pii_key_version,
+			pii_organization_id = @pii_organization_id
 		WHERE id = @id
 		RETURNING `+userAccountOutputExpr,
-		pgx.NamedArgs{
-			"id":                userAccount.ID,
-			"email":             userAccount.Email,
-			"password_hash":     userAccount.PasswordHash,
-			"password_salt":     userAccount.PasswordSalt,
-			"name":              userAccount.Name,
-			"email_verified_at": userAccount.EmailVerifiedAt,
-		},
+		args,
 	)
 	if err != nil {
 		return fmt.Errorf("could not query users: %w", err)
@@ -101,6 +127,8 @@ func UpdateUserAccount(ctx context.Context, userAccount *types.UserAccount) erro
 			return fmt.Errorf("can not update user with email %v: %w", userAccount.Email, apierrors.ErrAlreadyExists)
 		}
 		return fmt.Errorf("could not update user: %w", err)
+	} else if err := decryptUserAccounts(&created); err != nil {
+		return err
 	} else {
 		*userAccount = created
 		return nil
@@ -127,6 +155,8 @@ func UpdateUserAccountEmailVerified(ctx context.Context, userAccount *types.User
 			return fmt.Errorf("can not update user with email %v: %w", userAccount.Email, apierrors.ErrAlreadyExists)
 		}
 		return fmt.Errorf("could not update user: %w", err)
+	} else if err := decryptUserAccounts(&created); err != nil {
+		return err
 	} else {
 		*userAccount = created
 		return nil
@@ -205,16 +235,49 @@ func UserOwnsArtifactLicensesInOrganization(ctx context.Context, userID, orgID u
 	return result.Exists, nil
 }
 
+// DeleteUserAccountFromOrganization removes the user from the organization. It returns apierrors.ErrConflict if the
+// user still owns licenses or manages deployment targets in the organization, because they would be left with an owner
+// that is not a member of the organization.
 func DeleteUserAccountFromOrganization(ctx context.Context, userID, orgID uuid.UUID) error {
 	db := internalctx.GetDb(ctx)
-	cmd, err := db.Exec(ctx, `
-		DELETE FROM Organization_UserAccount
-		WHERE user_account_id = @userId AND organization_id = @orgId`,
+	rows, err := db.Query(ctx, `
+		WITH deleted AS (
+			DELETE FROM Organization_UserAccount
+			WHERE user_account_id = @userId AND organization_id = @orgId
+				AND NOT EXISTS (
+					SELECT FROM DeploymentTarget dt
+					WHERE dt.organization_id = @orgId AND dt.created_by_user_account_id = @userId
+				)
+				AND NOT EXISTS (
+					SELECT FROM ApplicationLicense al
+					WHERE al.organization_id = @orgId AND al.owner_useraccount_id = @userId
+				)
+				AND NOT EXISTS (
+					SELECT FROM ArtifactLicense al
+					WHERE al.organization_id = @orgId AND al.owner_useraccount_id = @userId
+				)
+			RETURNING user_account_id
+		)
+		SELECT
+			EXISTS (SELECT FROM deleted),
+			EXISTS (
+				SELECT FROM Organization_UserAccount
+				WHERE user_account_id = @userId AND organization_id = @orgId
+			)`,
 		pgx.NamedArgs{"userId": userID, "orgId": orgID})
-	if err == nil && cmd.RowsAffected() == 0 {
-		err = apierrors.ErrNotFound
+	if err != nil {
+		return err
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[struct{ Deleted, Exists bool }])
+	if err != nil {
+		return err
+	} else if result.Deleted {
+		return nil
+	} else if result.Exists {
+		return fmt.Errorf("%w: user still owns resources in the organization", apierrors.ErrConflict)
+	} else {
+		return apierrors.ErrNotFound
 	}
-	return err
 }
 
 func CreateUserAccountOrganizationAssignment(ctx context.Context, userID, orgID uuid.UUID, role types.UserRole) error {
@@ -247,11 +310,69 @@ func GetUserAccountsByOrgID(ctx context.Context, orgID uuid.UUID, role *types.Us
 		return nil, fmt.Errorf("could not query users: %w", err)
 	} else if result, err := pgx.CollectRows[types.UserAccountWithUserRole](rows, pgx.RowToStructByName); err != nil {
 		return nil, fmt.Errorf("could not map users: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
 	} else {
+		if pii.Default() != nil {
+			// the database can only sort by the encrypted values
+			slices.SortStableFunc(result, func(a, b types.UserAccountWithUserRole) int {
+				return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Email, b.Email))
+			})
+		}
 		return result, nil
 	}
 }
 
+// UserAccountsPageSpec orders user accounts by name and email.
+var UserAccountsPageSpec = pagination.Spec{
+	Name: "UserAccount",
+	Columns: []pagination.Column{
+		{Expr: "coalesce(u.name, '')", Type: "TEXT"},
+		{Expr: "u.email", Type: "TEXT"},
+		{Expr: "u.id", Type: "UUID"},
+	},
+}
+
+// GetUserAccountsPageByOrgID returns one page of the users of an organization.
+// If customFieldsFilter is not empty, only customers with matching custom fields are returned.
+func GetUserAccountsPageByOrgID(
+	ctx context.Context,
+	orgID uuid.UUID,
+	customFieldsFilter types.CustomFields,
+	page pagination.Page,
+) ([]types.UserAccountWithUserRole, []any, error) {
+	db := internalctx.GetDb(ctx)
+	args := pgx.NamedArgs{
+		"orgId":              orgID,
+		"checkCustomFields":  len(customFieldsFilter) > 0,
+		"customFieldsFilter": nonNilCustomFields(customFieldsFilter),
+	}
+	rows, err := db.Query(ctx,
+		"SELECT "+userAccountWithRoleOutputExprWithAlias+`
+		FROM UserAccount u
+		INNER JOIN Organization_UserAccount j ON u.id = j.user_account_id
+		WHERE j.organization_id = @orgId
+			AND (NOT @checkCustomFields OR (j.user_role = 'customer' AND j.custom_fields @> @customFieldsFilter))
+			AND `+UserAccountsPageSpec.Where(page, args)+`
+		`+UserAccountsPageSpec.OrderByLimit(page, args),
+		args,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not query users: %w", err)
+	} else if result, err := pgx.CollectRows[types.UserAccountWithUserRole](rows, pgx.RowToStructByName); err != nil {
+		return nil, nil, fmt.Errorf("could not map users: %w", err)
+	} else {
+		// the cursor refers to the stored values, so they are only decrypted afterwards
+		result, next := pagination.Trim(page, result, func(u types.UserAccountWithUserRole) []any {
+			return []any{u.Name, u.Email, u.ID}
+		})
+		if err := decryptUserAccounts(&result); err != nil {
+			return nil, nil, err
+		}
+		return result, next, nil
+	}
+}
+
 func GetUserAccountByID(ctx context.Context, id uuid.UUID) (*types.UserAccount, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
@@ -266,16 +387,21 @@ func GetUserAccountByID(ctx context.Context, id uuid.UUID) (*types.UserAccount,
 		} else {
 			return nil, fmt.Errorf("could not map user: %w", err)
 		}
+	} else if err := decryptUserAccounts(&userAccount); err != nil {
+		return nil, err
 	} else {
 		return &userAccount, nil
 	}
 }
 
+// GetUserAccountByEmail returns the user account with the given email. Encrypted accounts are found by the blind
+// index of their email, accounts that have not been encrypted yet by the email itself.
 func GetUserAccountByEmail(ctx context.Context, email string) (*types.UserAccount, error) {
 	db := internalctx.GetDb(ctx)
 	rows, err := db.Query(ctx,
-		"SELECT "+userAccountOutputExpr+" FROM UserAccount u WHERE u.email = @email",
-		pgx.NamedArgs{"email": email},
+		"SELECT "+userAccountOutputExpr+` FROM UserAccount u
+		WHERE u.email_blind_index = @emailBlindIndex OR u.email = @email`,
+		pgx.NamedArgs{"email": email, "emailBlindIndex": pii.Default().BlindIndex(email)},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("could not query users: %w", err)
@@ -285,6 +411,8 @@ func GetUserAccountByEmail(ctx context.Context, email string) (*types.UserAccoun
 		} else {
 			return nil, fmt.Errorf("could not map user: %w", err)
 		}
+	} else if err := decryptUserAccounts(&userAccount); err != nil {
+		return nil, err
 	} else {
 		return &userAccount, nil
 	}
@@ -309,6 +437,8 @@ func GetUserAccountWithRole(ctx context.Context, userID, orgID uuid.UUID) (*type
 		} else {
 			return nil, fmt.Errorf("could not map user: %w", err)
 		}
+	} else if err := decryptUserAccounts(&userAccount); err != nil {
+		return nil, err
 	} else {
 		return &userAccount, nil
 	}
@@ -347,11 +477,15 @@ func GetUserAccountAndOrg(ctx context.Context, userID, orgID uuid.UUID, expected
 		} else {
 			return nil, nil, fmt.Errorf("could not map user or org: %w", err)
 		}
+	} else if err := decryptUserAccounts(&res.User); err != nil {
+		return nil, nil, err
 	} else {
 		return &res.User, &res.Org, nil
 	}
 }
 
+// GetUserAccountAndOrgForDeploymentTarget returns the user that owns the deployment target and its organization.
+// Archived deployment targets are not found, so that the tokens of their agents can not be used anymore.
 func GetUserAccountAndOrgForDeploymentTarget(
 	ctx context.Context,
 	id uuid.UUID,
@@ -365,7 +499,7 @@ func GetUserAccountAndOrgForDeploymentTarget(
 			JOIN UserAccount u ON u.id = dt.created_by_user_account_id
 			JOIN Organization_UserAccount j ON u.id = j.user_account_id
 				AND o.id = j.organization_id
-			WHERE dt.id = @id`,
+			WHERE dt.id = @id AND dt.archived_at IS NULL`,
 		pgx.NamedArgs{"id": id},
 	)
 	if err != nil {
@@ -381,16 +515,19 @@ func GetUserAccountAndOrgForDeploymentTarget(
 		} else {
 			return nil, nil, fmt.Errorf("could not map user or org: %w", err)
 		}
+	} else if err := decryptUserAccounts(&res.User); err != nil {
+		return nil, nil, err
 	} else {
 		return &res.User, &res.Org, nil
 	}
 }
 
+// UpdateUserAccountLastLoggedIn records a successful login and resets the failed login attempts counter.
 func UpdateUserAccountLastLoggedIn(ctx context.Context, userID uuid.UUID) error {
 	db := internalctx.GetDb(ctx)
 	cmd, err := db.Exec(
 		ctx,
-		`UPDATE UserAccount SET last_logged_in_at = now() WHERE id = @id`,
+		`UPDATE UserAccount SET last_logged_in_at = now(), failed_login_attempts = 0 WHERE id = @id`,
 		pgx.NamedArgs{"id": userID},
 	)
 	if err == nil && cmd.RowsAffected() == 0 {
@@ -413,3 +550,136 @@ func UpdateUserAccountImage(ctx context.Context, userAccount *types.UserAccountW
 	}
 	return nil
 }
+
+// unverifiedUserAccountExpr is true for user accounts that have registered themselves, but have not verified their
+// email address. Invited users are excluded, because they have no password until they accept the invitation.
+const unverifiedUserAccountExpr = `u.email_verified_at IS NULL AND u.password_hash IS NOT NULL`
+
+// GetUnverifiedUserAccountsForReminder returns the unverified user accounts that were created before createdBefore
+// and have not been reminded to verify their email address yet.
+func GetUnverifiedUserAccountsForReminder(ctx context.Context, createdBefore time.Time) ([]types.UserAccount, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+userAccountOutputExpr+` FROM UserAccount u
+		WHERE `+unverifiedUserAccountExpr+`
+			AND u.verification_reminder_sent_at IS NULL
+			AND u.created_at < @createdBefore
+		ORDER BY u.created_at`,
+		pgx.NamedArgs{"createdBefore": createdBefore},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query users: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.UserAccount]); err != nil {
+		return nil, fmt.Errorf("could not map users: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func UpdateUserAccountVerificationReminderSent(ctx context.Context, userID uuid.UUID, sentAt time.Time) error {
+	db := internalctx.GetDb(ctx)
+	cmd, err := db.Exec(
+		ctx,
+		`UPDATE UserAccount SET verification_reminder_sent_at = @sentAt WHERE id = @id`,
+		pgx.NamedArgs{"id": userID, "sentAt": sentAt},
+	)
+	if err == nil && cmd.RowsAffected() == 0 {
+		err = apierrors.ErrNotFound
+	}
+	if err != nil {
+		err = fmt.Errorf("could not update verification_reminder_sent_at on UserAccount: %w", err)
+	}
+	return err
+}
+
+// GetUnverifiedUserAccountsForDeletion returns the unverified user accounts that were created before createdBefore.
+// User accounts that are a member of an organization under legal hold are excluded.
+func GetUnverifiedUserAccountsForDeletion(ctx context.Context, createdBefore time.Time) ([]types.UserAccount, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+userAccountOutputExpr+` FROM UserAccount u
+		WHERE `+unverifiedUserAccountExpr+`
+			AND u.created_at < @createdBefore
+			AND NOT EXISTS (
+				SELECT FROM Organization_UserAccount j
+				WHERE j.user_account_id = u.id AND `+legalHoldExpr("j.organization_id", "u.id")+`
+			)
+		ORDER BY u.created_at`,
+		pgx.NamedArgs{"createdBefore": createdBefore},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query users: %w", err)
+	} else if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.UserAccount]); err != nil {
+		return nil, fmt.Errorf("could not map users: %w", err)
+	} else if err := decryptUserAccounts(&result); err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// DeleteUnverifiedUserAccount deletes an unverified user account together with the organizations that it is the only
+// member of. It must be called in a transaction.
+//
+// The same checks as for removing a user from an organization apply: apierrors.ErrConflict is returned if the user
+// still owns deployment targets or licenses in any organization. It is also returned if the user is the only member
+// of an organization that has applications, artifacts or deployment targets, because deleting the user would leave
+// this data without an owner. apierrors.ErrNotFound is returned if the user account does not exist or has been
+// verified in the meantime.
+func DeleteUnverifiedUserAccount(ctx context.Context, userID uuid.UUID) (organizationsDeleted int64, err error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT
+			EXISTS (
+				SELECT FROM UserAccount u WHERE u.id = @userId AND `+unverifiedUserAccountExpr+`
+			),
+			EXISTS (SELECT FROM DeploymentTarget dt WHERE dt.created_by_user_account_id = @userId)
+				OR EXISTS (SELECT FROM ApplicationLicense al WHERE al.owner_useraccount_id = @userId)
+				OR EXISTS (SELECT FROM ArtifactLicense al WHERE al.owner_useraccount_id = @userId)
+				OR EXISTS (
+					SELECT FROM Organization_UserAccount j
+					WHERE j.user_account_id = @userId
+						AND NOT EXISTS (
+							SELECT FROM Organization_UserAccount o
+							WHERE o.organization_id = j.organization_id AND o.user_account_id <> @userId
+						)
+						AND (
+							EXISTS (SELECT FROM Application a WHERE a.organization_id = j.organization_id)
+							OR EXISTS (SELECT FROM Artifact a WHERE a.organization_id = j.organization_id)
+							OR EXISTS (SELECT FROM DeploymentTarget dt WHERE dt.organization_id = j.organization_id)
+						)
+				)`,
+		pgx.NamedArgs{"userId": userID})
+	if err != nil {
+		return 0, fmt.Errorf("could not query UserAccount: %w", err)
+	}
+	result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByPos[struct{ Unverified, OwnsResources bool }])
+	if err != nil {
+		return 0, fmt.Errorf("could not query UserAccount: %w", err)
+	} else if !result.Unverified {
+		return 0, apierrors.ErrNotFound
+	} else if result.OwnsResources {
+		return 0, fmt.Errorf("%w: user still owns resources", apierrors.ErrConflict)
+	}
+
+	cmd, err := db.Exec(ctx, `
+		DELETE FROM Organization o
+		WHERE EXISTS (
+				SELECT FROM Organization_UserAccount j
+				WHERE j.organization_id = o.id AND j.user_account_id = @userId
+			)
+			AND NOT EXISTS (
+				SELECT FROM Organization_UserAccount j
+				WHERE j.organization_id = o.id AND j.user_account_id <> @userId
+			)`,
+		pgx.NamedArgs{"userId": userID})
+	if err != nil {
+		if pgerr := (*pgconn.PgError)(nil); errors.As(err, &pgerr) && pgerr.Code == pgerrcode.ForeignKeyViolation {
+			err = fmt.Errorf("%w: %w", apierrors.ErrConflict, err)
+		}
+		return 0, fmt.Errorf("could not delete Organization: %w", err)
+	}
+	return cmd.RowsAffected(), DeleteUserAccountWithID(ctx, userID)
+}
@@ -0,0 +1,404 @@
+package db_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/glasskube/distr/internal/security"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCreateUserAccount(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+	g.Expect(user.ID).NotTo(Equal(uuid.Nil))
+	g.Expect(user.CreatedAt).NotTo(BeZero())
+	g.Expect(security.VerifyPassword(*user, testutil.Password)).To(Succeed())
+
+	byID, err := db.GetUserAccountByID(ctx, user.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(byID.Email).To(Equal(user.Email))
+	byEmail, err := db.GetUserAccountByEmail(ctx, user.Email)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(byEmail.ID).To(Equal(user.ID))
+
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		return db.CreateUserAccount(ctx, &types.UserAccount{Email: user.Email})
+	})
+	g.Expect(err).To(MatchError(apierrors.ErrAlreadyExists))
+}
+
+func TestGetUserAccountNotFound(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	_, err := db.GetUserAccountByID(ctx, uuid.New())
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	_, err = db.GetUserAccountByEmail(ctx, "nobody@example.com")
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestCreateUserAccountWithOrganization(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := types.UserAccount{Email: "vendor-" + uuid.NewString() + "@example.com"}
+	org, err := db.CreateUserAccountWithOrganization(ctx, &user)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(org.Name).To(Equal(user.Email))
+
+	withRole, err := db.GetUserAccountWithRole(ctx, user.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(withRole.UserRole).To(Equal(types.UserRoleVendor))
+}
+
+func TestUpdateUserAccount(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+	other := testutil.NewUserAccount(ctx, t)
+
+	user.Name = "Updated Name"
+	g.Expect(db.UpdateUserAccount(ctx, user)).To(Succeed())
+	updated, err := db.GetUserAccountByID(ctx, user.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(updated.Name).To(Equal("Updated Name"))
+
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		return db.UpdateUserAccount(ctx, &types.UserAccount{ID: user.ID, Email: other.Email})
+	})
+	g.Expect(err).To(MatchError(apierrors.ErrAlreadyExists))
+
+	err = db.UpdateUserAccount(ctx, &types.UserAccount{ID: uuid.New(), Email: "nobody@example.com"})
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestUpdateUserAccountEmailVerified(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t, func(u *types.UserAccount) { u.EmailVerifiedAt = nil })
+	g.Expect(user.EmailVerifiedAt).To(BeNil())
+	g.Expect(db.UpdateUserAccountEmailVerified(ctx, user)).To(Succeed())
+	g.Expect(user.EmailVerifiedAt).NotTo(BeNil())
+
+	err := db.UpdateUserAccountEmailVerified(ctx, &types.UserAccount{ID: uuid.New()})
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestUpdateUserAccountLastLoggedIn(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+	g.Expect(db.UpdateUserAccountLastLoggedIn(ctx, user.ID)).To(Succeed())
+	g.Expect(db.UpdateUserAccountLastLoggedIn(ctx, uuid.New())).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestDeleteUserAccountWithID(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	customer := org.Customers[0]
+	testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+
+	err := db.RunTx(ctx, func(ctx context.Context) error {
+		return db.DeleteUserAccountWithID(ctx, customer.ID)
+	})
+	g.Expect(err).To(MatchError(apierrors.ErrConflict), "deployment targets reference the user that created them")
+
+	g.Expect(db.DeleteUserAccountWithID(ctx, org.Vendors[0].ID)).To(Succeed())
+	_, err = db.GetUserAccountByID(ctx, org.Vendors[0].ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.DeleteUserAccountWithID(ctx, uuid.New())).To(MatchError(apierrors.ErrNotFound))
+
+	owner := testutil.NewUserAccount(ctx, t)
+	g.Expect(db.CreateUserAccountOrganizationAssignment(ctx, owner.ID, org.ID, types.UserRoleCustomer)).To(Succeed())
+	license := types.ArtifactLicenseBase{Name: "test", OrganizationID: org.ID, OwnerUserAccountID: &owner.ID}
+	g.Expect(db.CreateArtifactLicense(ctx, &license)).To(Succeed())
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		return db.DeleteUserAccountWithID(ctx, owner.ID)
+	})
+	g.Expect(err).To(MatchError(apierrors.ErrConflict), "licenses reference the user that owns them")
+
+	g.Expect(consistencyIssuesOf(ctx, t, org.ID)).To(BeEmpty())
+}
+
+func TestDeleteUserAccountFromOrganization(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 2)
+	manager, owner := org.Customers[0], org.Customers[1]
+	testutil.NewDeploymentTarget(ctx, t, org.ID, manager.ID)
+	app := types.Application{Name: "app", Type: types.DeploymentTypeDocker}
+	g.Expect(db.CreateApplication(ctx, &app, org.ID)).To(Succeed())
+	license := types.ApplicationLicenseBase{
+		Name:               "license",
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &owner.ID,
+	}
+	g.Expect(db.CreateApplicationLicense(ctx, &license)).To(Succeed())
+
+	g.Expect(db.DeleteUserAccountFromOrganization(ctx, manager.ID, org.ID)).To(MatchError(apierrors.ErrConflict))
+	g.Expect(db.DeleteUserAccountFromOrganization(ctx, owner.ID, org.ID)).To(MatchError(apierrors.ErrConflict))
+	g.Expect(db.DeleteUserAccountFromOrganization(ctx, org.Vendors[0].ID, org.ID)).To(Succeed())
+	g.Expect(consistencyIssuesOf(ctx, t, org.ID)).To(BeEmpty())
+
+	g.Expect(db.DeleteApplicationLicenseWithID(ctx, license.ID)).To(Succeed())
+	g.Expect(db.DeleteUserAccountFromOrganization(ctx, owner.ID, org.ID)).To(Succeed())
+	g.Expect(db.DeleteUserAccountWithID(ctx, owner.ID)).To(Succeed())
+	g.Expect(consistencyIssuesOf(ctx, t, org.ID)).To(BeEmpty())
+}
+
+func TestUserAccountOrganizationAssignment(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	user := testutil.NewUserAccount(ctx, t)
+
+	_, err := db.GetUserAccountWithRole(ctx, user.ID, org.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.CreateUserAccountOrganizationAssignment(ctx, user.ID, org.ID, types.UserRoleCustomer)).To(Succeed())
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		return db.CreateUserAccountOrganizationAssignment(ctx, user.ID, org.ID, types.UserRoleVendor)
+	})
+	g.Expect(err).To(MatchError(apierrors.ErrAlreadyExists))
+
+	withRole, err := db.GetUserAccountWithRole(ctx, user.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(withRole.UserRole).To(Equal(types.UserRoleCustomer))
+
+	g.Expect(db.DeleteUserAccountFromOrganization(ctx, user.ID, org.ID)).To(Succeed())
+	g.Expect(db.DeleteUserAccountFromOrganization(ctx, user.ID, org.ID)).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestGetUserAccountsByOrgID(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 2, 3)
+	testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+
+	all, err := db.GetUserAccountsByOrgID(ctx, org.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(HaveLen(5))
+
+	vendors, err := db.GetUserAccountsByOrgID(ctx, org.ID, util.PtrTo(types.UserRoleVendor))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vendors).To(HaveLen(2))
+	g.Expect(vendors).To(HaveEach(HaveField("UserRole", types.UserRoleVendor)))
+
+	page, next, err := db.GetUserAccountsPageByOrgID(ctx, org.ID, nil, pagination.Page{Limit: 3})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(page).To(HaveLen(3))
+	g.Expect(next).NotTo(BeNil())
+	rest, next, err := db.GetUserAccountsPageByOrgID(ctx, org.ID, nil, pagination.Page{Limit: 3, After: next})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rest).To(HaveLen(2))
+	g.Expect(next).To(BeNil())
+}
+
+func TestGetUserAccountAndOrg(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	customer := org.Customers[0]
+
+	user, userOrg, err := db.GetUserAccountAndOrg(ctx, customer.ID, org.ID, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(user.ID).To(Equal(customer.ID))
+	g.Expect(user.UserRole).To(Equal(types.UserRoleCustomer))
+	g.Expect(userOrg.ID).To(Equal(org.ID))
+
+	_, _, err = db.GetUserAccountAndOrg(ctx, customer.ID, org.ID, util.PtrTo(types.UserRoleVendor))
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestGetUserAccountAndOrgForDeploymentTarget(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	customer := org.Customers[0]
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+
+	user, userOrg, err := db.GetUserAccountAndOrgForDeploymentTarget(ctx, dt.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(user.ID).To(Equal(customer.ID))
+	g.Expect(userOrg.ID).To(Equal(org.ID))
+
+	_, _, err = db.GetUserAccountAndOrgForDeploymentTarget(ctx, uuid.New())
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(db.SetDeploymentTargetArchived(ctx, dt.ID, org.ID, true)).To(Succeed())
+	_, _, err = db.GetUserAccountAndOrgForDeploymentTarget(ctx, dt.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	g.Expect(db.SetDeploymentTargetArchived(ctx, dt.ID, org.ID, false)).To(Succeed())
+
+	manages, err := db.UserManagesDeploymentTargetInOrganization(ctx, customer.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(manages).To(BeTrue())
+	manages, err = db.UserManagesDeploymentTargetInOrganization(ctx, org.Vendors[0].ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(manages).To(BeFalse())
+}
+
+func TestUserOwnsArtifactLicensesInOrganization(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	customer := org.Customers[0]
+	testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "1.0.0", "latest")
+
+	owns, err := db.UserOwnsArtifactLicensesInOrganization(ctx, customer.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(owns).To(BeFalse())
+
+	license := types.ArtifactLicenseBase{Name: "test", OrganizationID: org.ID, OwnerUserAccountID: &customer.ID}
+	g.Expect(db.CreateArtifactLicense(ctx, &license)).To(Succeed())
+	owns, err = db.UserOwnsArtifactLicensesInOrganization(ctx, customer.ID, org.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(owns).To(BeTrue())
+}
+
+func TestCreateUserAccountWithOrganizationConcurrently(t *testing.T) {
+	g := NewWithT(t)
+	ctx := poolContext(t)
+	email := "vendor-" + uuid.NewString() + "@example.com"
+
+	// simultaneous signups with the same email must fail like sequential ones instead of leaking constraint errors
+	const signups = 8
+	users := make([]types.UserAccount, signups)
+	orgs := make([]*types.Organization, signups)
+	errs := make([]error, signups)
+	var wg sync.WaitGroup
+	for i := range signups {
+		users[i].Email = email
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = db.RunTx(ctx, func(ctx context.Context) (err error) {
+				orgs[i], err = db.CreateUserAccountWithOrganization(ctx, &users[i])
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+
+	var created int
+	for i, err := range errs {
+		if err == nil {
+			created++
+			t.Cleanup(func() {
+				deleteCommitted(ctx, t, `DELETE FROM Organization WHERE id = @id`, orgs[i].ID)
+				deleteCommitted(ctx, t, `DELETE FROM UserAccount WHERE id = @id`, users[i].ID)
+			})
+		} else {
+			g.Expect(err).To(MatchError(apierrors.ErrAlreadyExists))
+		}
+	}
+	g.Expect(created).To(Equal(1))
+}
+
+// poolContext returns a context with a connection pool for tests that need concurrent transactions. Data that is
+// created with it is committed and has to be removed with deleteCommitted.
+func poolContext(t *testing.T) context.Context {
+	pool := testutil.Pool(t, nil)
+	return internalctx.WithDb(internalctx.WithLogger(context.Background(), zaptest.NewLogger(t)), pool)
+}
+
+func deleteCommitted(ctx context.Context, t *testing.T, sql string, id uuid.UUID) {
+	if _, err := internalctx.GetDb(ctx).Exec(ctx, sql, pgx.NamedArgs{"id": id}); err != nil {
+		t.Errorf("could not clean up %v: %v", id, err)
+	}
+}
+
+func TestUnverifiedUserAccounts(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	unverified := func(u *types.UserAccount) { u.EmailVerifiedAt = nil }
+	newMember := func(orgID uuid.UUID) *types.UserAccount {
+		user := testutil.NewUserAccount(ctx, t, unverified)
+		g.Expect(db.CreateUserAccountOrganizationAssignment(ctx, user.ID, orgID, types.UserRoleVendor)).To(Succeed())
+		return user
+	}
+	ids := func(users []types.UserAccount) []uuid.UUID {
+		result := make([]uuid.UUID, len(users))
+		for i, user := range users {
+			result[i] = user.ID
+		}
+		return result
+	}
+	// user accounts created in this transaction have the same created_at
+	due, notDue := time.Now().Add(time.Hour), time.Now().Add(-time.Hour)
+
+	registeredOrg := testutil.NewOrganization(ctx, t)
+	registered := newMember(registeredOrg.ID)
+	invited := testutil.NewUserAccount(ctx, t, unverified, func(u *types.UserAccount) { u.Password = "" })
+	ownerOrg := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	owner := newMember(ownerOrg.ID)
+	testutil.NewDeploymentTarget(ctx, t, ownerOrg.ID, owner.ID)
+	sharedOrg := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	member := newMember(sharedOrg.ID)
+	soleMemberOrg := testutil.NewOrganization(ctx, t)
+	soleMember := newMember(soleMemberOrg.ID)
+	testutil.NewDeploymentTarget(ctx, t, soleMemberOrg.ID, sharedOrg.Vendors[0].ID)
+	heldOrg := testutil.NewOrganization(ctx, t)
+	held := newMember(heldOrg.ID)
+	g.Expect(db.CreateLegalHold(ctx, &types.LegalHold{OrganizationID: heldOrg.ID, Reason: "case 42"})).To(Succeed())
+
+	users, err := db.GetUnverifiedUserAccountsForReminder(ctx, due)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ids(users)).To(ContainElements(registered.ID, owner.ID, member.ID, soleMember.ID, held.ID))
+	g.Expect(ids(users)).NotTo(ContainElements(invited.ID), "invited users are not reminded")
+	g.Expect(ids(users)).NotTo(ContainElements(sharedOrg.Vendors[0].ID), "verified users are not reminded")
+	g.Expect(db.UpdateUserAccountVerificationReminderSent(ctx, registered.ID, time.Now())).To(Succeed())
+	users, err = db.GetUnverifiedUserAccountsForReminder(ctx, due)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ids(users)).NotTo(ContainElements(registered.ID), "users are only reminded once")
+	users, err = db.GetUnverifiedUserAccountsForReminder(ctx, notDue)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ids(users)).NotTo(ContainElements(owner.ID))
+
+	users, err = db.GetUnverifiedUserAccountsForDeletion(ctx, due)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ids(users)).To(ContainElements(registered.ID, owner.ID, member.ID, soleMember.ID))
+	g.Expect(ids(users)).NotTo(ContainElements(held.ID), "users under legal hold are not deleted")
+	users, err = db.GetUnverifiedUserAccountsForDeletion(ctx, notDue)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ids(users)).NotTo(ContainElements(registered.ID))
+
+	deleteUser := func(id uuid.UUID) (count int64, err error) {
+		err = db.RunTx(ctx, func(ctx context.Context) (err error) {
+			count, err = db.DeleteUnverifiedUserAccount(ctx, id)
+			return err
+		})
+		return count, err
+	}
+
+	g.Expect(deleteUser(owner.ID)).Error().To(MatchError(apierrors.ErrConflict), "owns a deployment target")
+	g.Expect(deleteUser(soleMember.ID)).Error().
+		To(MatchError(apierrors.ErrConflict), "only member of an organization with a deployment target")
+	g.Expect(deleteUser(sharedOrg.Vendors[0].ID)).Error().To(MatchError(apierrors.ErrNotFound), "verified")
+	g.Expect(deleteUser(invited.ID)).Error().To(MatchError(apierrors.ErrNotFound), "invited")
+
+	g.Expect(deleteUser(registered.ID)).To(Equal(int64(1)))
+	_, err = db.GetUserAccountByID(ctx, registered.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	_, err = db.GetOrganizationByID(ctx, registeredOrg.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	g.Expect(deleteUser(member.ID)).To(BeZero())
+	_, err = db.GetOrganizationByID(ctx, sharedOrg.ID)
+	g.Expect(err).NotTo(HaveOccurred(), "organizations with other members are kept")
+}
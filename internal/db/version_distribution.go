@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// versionDistributionJoinExpr joins the points in time p (with a column at) with the deployments d and deployment
+// targets dt that were active at p.at and the application version av of the latest revision of each deployment that
+// had been released by then.
+const versionDistributionJoinExpr = `
+	JOIN DeploymentTarget dt ON dt.archived_at IS NULL OR dt.archived_at > p.at
+	JOIN Deployment d
+		ON d.deployment_target_id = dt.id
+			AND (d.archived_at IS NULL OR d.archived_at > p.at)
+			AND (d.uninstalled_at IS NULL OR d.uninstalled_at > p.at)
+	CROSS JOIN LATERAL (
+		SELECT dr.application_version_id FROM DeploymentRevision dr
+		WHERE dr.deployment_id = d.id
+			AND dr.created_at <= p.at
+			AND NOT (dr.acknowledgment_required AND (dr.acknowledged_at IS NULL OR dr.acknowledged_at > p.at))
+		ORDER BY dr.created_at DESC
+		LIMIT 1
+	) cur
+	JOIN ApplicationVersion av ON av.id = cur.application_version_id
+`
+
+// GetVersionDistribution counts the active deployments of the application by version at now. If weeks is not empty,
+// the deployments are instead counted at the end of each of the weeks that start at the given times, or at now for
+// the current week, and the entries contain the start of their week.
+func GetVersionDistribution(
+	ctx context.Context,
+	orgID, applicationID uuid.UUID,
+	options types.VersionDistributionOptions,
+	weeks []time.Time,
+	now time.Time,
+) ([]types.VersionDistributionEntry, error) {
+	// the points in time at which the deployments are counted
+	pointWeeks := []*time.Time{nil}
+	pointAts := []time.Time{now.UTC()}
+	if len(weeks) > 0 {
+		pointWeeks = make([]*time.Time, len(weeks))
+		pointAts = make([]time.Time, len(weeks))
+		for i, week := range weeks {
+			pointWeeks[i] = util.PtrTo(week.UTC())
+			if i+1 < len(weeks) && weeks[i+1].Before(now) {
+				pointAts[i] = weeks[i+1].UTC()
+			} else {
+				pointAts[i] = now.UTC()
+			}
+		}
+	}
+
+	args := pgx.NamedArgs{
+		"orgId":         orgID,
+		"applicationId": applicationID,
+		"customerId":    options.CustomerID,
+		"weeks":         pointWeeks,
+		"ats":           pointAts,
+	}
+	groupExpr := "NULL::TEXT"
+	switch options.GroupBy {
+	case types.VersionDistributionGroupByEnvironment:
+		groupExpr = "CASE WHEN dt.production THEN 'production' ELSE 'non-production' END"
+	case types.VersionDistributionGroupByLabel:
+		groupExpr = "dt.custom_fields ->> @label"
+		args["label"] = options.Label
+	}
+
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT
+			p.week,
+			av.id AS application_version_id,
+			av.name AS application_version_name,
+			`+groupExpr+` AS group_value,
+			count(*) AS deployment_count,
+			count(DISTINCT dt.id) AS deployment_target_count
+		FROM unnest(@weeks::TIMESTAMP[], @ats::TIMESTAMP[]) AS p(week, at)
+		`+versionDistributionJoinExpr+`
+		WHERE dt.organization_id = @orgId
+			AND av.application_id = @applicationId
+			AND (@customerId::UUID IS NULL OR dt.created_by_user_account_id = @customerId)
+		GROUP BY p.week, av.id, group_value
+		ORDER BY p.week, av.created_at DESC, av.name, group_value`,
+		args,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query version distribution: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.VersionDistributionEntry])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect version distribution: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/orgtime"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetVersionDistribution(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	vendor, customer := org.Vendors[0], org.Customers[0]
+	production := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID,
+		func(dt *types.DeploymentTargetWithCreatedBy) {
+			dt.Production = true
+			dt.CustomFields = types.CustomFields{"region": "eu"}
+		})
+	revision := testutil.NewDeploymentRevision(ctx, t, production)
+	backdateDeploymentRevision(ctx, t, revision.ID)
+	version, err := db.GetApplicationVersion(ctx, revision.ApplicationVersionID)
+	g.Expect(err).NotTo(HaveOccurred())
+	staging := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	request := api.DeploymentRequest{
+		DeploymentTargetID:   staging.ID,
+		ApplicationVersionID: version.ID,
+		DockerType:           util.PtrTo(types.DockerTypeCompose),
+	}
+	g.Expect(db.CreateDeployment(ctx, &request)).To(Succeed())
+	_, err = db.CreateDeploymentRevision(ctx, &request)
+	g.Expect(err).NotTo(HaveOccurred())
+	now := time.Now()
+
+	result, err := db.GetVersionDistribution(
+		ctx, org.ID, version.ApplicationID, types.VersionDistributionOptions{}, nil, now,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(ConsistOf(types.VersionDistributionEntry{
+		ApplicationVersionID:   version.ID,
+		ApplicationVersionName: "1.0.0",
+		DeploymentCount:        2,
+		DeploymentTargetCount:  2,
+	}))
+
+	result, err = db.GetVersionDistribution(ctx, org.ID, version.ApplicationID,
+		types.VersionDistributionOptions{GroupBy: types.VersionDistributionGroupByEnvironment}, nil, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(HaveLen(2))
+	g.Expect(result[0].Group).To(Equal(util.PtrTo("non-production")))
+	g.Expect(result[1].Group).To(Equal(util.PtrTo("production")))
+
+	result, err = db.GetVersionDistribution(ctx, org.ID, version.ApplicationID,
+		types.VersionDistributionOptions{GroupBy: types.VersionDistributionGroupByLabel, Label: "region"}, nil, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(HaveLen(2))
+	g.Expect(result[0].Group).To(Equal(util.PtrTo("eu")))
+	g.Expect(result[1].Group).To(BeNil())
+
+	// deployment targets of other customers are not counted
+	g.Expect(db.GetVersionDistribution(ctx, org.ID, version.ApplicationID,
+		types.VersionDistributionOptions{CustomerID: &vendor.ID}, nil, now)).To(BeEmpty())
+
+	// the current week is counted now
+	weeks := orgtime.Weeks(now, 2, time.UTC)
+	result, err = db.GetVersionDistribution(
+		ctx, org.ID, version.ApplicationID, types.VersionDistributionOptions{}, weeks, now,
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).NotTo(BeEmpty())
+	current := result[len(result)-1]
+	g.Expect(current.Week).To(HaveValue(BeTemporally("==", weeks[1])))
+	g.Expect(current.DeploymentCount).To(Equal(2))
+}
@@ -0,0 +1,242 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	versionEOLCampaignOutputExpr = `
+		c.id, c.created_at, c.organization_id, c.application_id, c.created_by_user_account_id,
+		c.application_version_ids, c.end_of_life_at, c.message
+	`
+	// versionEOLDeploymentsFromExpr joins the deployments of customers with the application version of their latest
+	// released revision as cur. It must be combined with versionEOLDeploymentsWhereExpr.
+	versionEOLDeploymentsFromExpr = `
+		Deployment d
+			JOIN DeploymentTarget dt ON d.deployment_target_id = dt.id
+			JOIN UserAccount u ON dt.created_by_user_account_id = u.id
+			JOIN Organization_UserAccount j
+				ON u.id = j.user_account_id AND dt.organization_id = j.organization_id AND j.user_role = 'customer'
+			CROSS JOIN LATERAL (
+				SELECT dr.application_version_id FROM DeploymentRevision dr
+				WHERE dr.deployment_id = d.id AND ` + deploymentRevisionReleasedExpr + `
+				ORDER BY dr.created_at DESC
+				LIMIT 1
+			) cur
+	`
+	// versionEOLDeploymentsWhereExpr limits versionEOLDeploymentsFromExpr to active deployments of the organization.
+	versionEOLDeploymentsWhereExpr = `
+		dt.organization_id = @orgId
+			AND d.archived_at IS NULL
+			AND d.uninstalled_at IS NULL
+			AND dt.archived_at IS NULL
+	`
+)
+
+// GetVersionEOLReport returns the deployments of customers that currently run one of the application versions. If
+// customerID is not nil, only the deployments of that customer are returned. The entries are ordered by customer.
+func GetVersionEOLReport(
+	ctx context.Context,
+	orgID uuid.UUID,
+	versionIDs []uuid.UUID,
+	customerID *uuid.UUID,
+) ([]types.VersionEOLReportEntry, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT
+			u.id AS customer_user_account_id,
+			u.email AS customer_email,
+			u.name AS customer_name,
+			dt.id AS deployment_target_id,
+			dt.name AS deployment_target_name,
+			`+deploymentTargetLastSeenExpr+` AS deployment_target_last_seen_at,
+			d.id AS deployment_id,
+			av.id AS application_version_id,
+			av.name AS application_version_name,
+			n.campaign_id AS notification_campaign_id,
+			n.sent_at AS notified_at
+		FROM `+versionEOLDeploymentsFromExpr+`
+			JOIN ApplicationVersion av ON cur.application_version_id = av.id
+			LEFT JOIN VersionEOLNotification n
+				ON n.application_version_id = av.id AND n.customer_user_account_id = u.id
+		WHERE `+versionEOLDeploymentsWhereExpr+`
+			AND av.id = any(@versionIds)
+			AND (@customerId::UUID IS NULL OR u.id = @customerId)
+		ORDER BY dt.name, av.name`,
+		pgx.NamedArgs{"orgId": orgID, "versionIds": versionIDs, "customerId": customerID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query version EOL report: %w", err)
+	}
+	result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.VersionEOLReportEntry])
+	if err != nil {
+		return nil, fmt.Errorf("could not collect version EOL report: %w", err)
+	}
+	for i := range result {
+		if err := decryptPII(&result[i].CustomerEmail, &result[i].CustomerName); err != nil {
+			return nil, err
+		}
+	}
+	// emails can only be compared after they have been decrypted
+	slices.SortStableFunc(result, func(a, b types.VersionEOLReportEntry) int {
+		return cmp.Compare(a.CustomerEmail, b.CustomerEmail)
+	})
+	return result, nil
+}
+
+func GetVersionEOLCampaigns(
+	ctx context.Context,
+	applicationID, orgID uuid.UUID,
+) ([]types.VersionEOLCampaignWithProgress, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT `+versionEOLCampaignOutputExpr+`,
+			(SELECT count(DISTINCT n.customer_user_account_id) FROM VersionEOLNotification n
+				WHERE n.campaign_id = c.id) AS customer_count,
+			(SELECT count(DISTINCT n.customer_user_account_id) FROM VersionEOLNotification n
+				WHERE n.campaign_id = c.id AND n.sent_at IS NULL) AS pending_customer_count,
+			(SELECT count(DISTINCT n.customer_user_account_id) FROM VersionEOLNotification n
+				WHERE n.campaign_id = c.id AND EXISTS (
+					SELECT 1 FROM `+versionEOLDeploymentsFromExpr+`
+					WHERE `+versionEOLDeploymentsWhereExpr+`
+						AND u.id = n.customer_user_account_id
+						AND cur.application_version_id = n.application_version_id
+				)) AS remaining_customer_count
+		FROM VersionEOLCampaign c
+		WHERE c.application_id = @applicationId AND c.organization_id = @orgId
+		ORDER BY c.created_at DESC`,
+		pgx.NamedArgs{"applicationId": applicationID, "orgId": orgID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query VersionEOLCampaigns: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.VersionEOLCampaignWithProgress]); err != nil {
+		return nil, fmt.Errorf("could not collect VersionEOLCampaigns: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+func GetVersionEOLCampaign(ctx context.Context, id uuid.UUID) (*types.VersionEOLCampaign, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx,
+		"SELECT "+versionEOLCampaignOutputExpr+" FROM VersionEOLCampaign c WHERE c.id = @id",
+		pgx.NamedArgs{"id": id},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query VersionEOLCampaign: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.VersionEOLCampaign]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not query VersionEOLCampaign: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// CreateVersionEOLCampaign stores the campaign and queues a notification for every customer that currently runs one
+// of its versions and has not been notified about that version yet. It returns the number of customers that will be
+// notified and must be called in a transaction.
+func CreateVersionEOLCampaign(ctx context.Context, campaign *types.VersionEOLCampaign) (int, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		INSERT INTO VersionEOLCampaign AS c
+			(organization_id, application_id, created_by_user_account_id, application_version_ids, end_of_life_at,
+				message)
+			VALUES (@orgId, @applicationId, @createdBy, @versionIds, @endOfLifeAt, @message)
+			RETURNING `+versionEOLCampaignOutputExpr,
+		pgx.NamedArgs{
+			"orgId":         campaign.OrganizationID,
+			"applicationId": campaign.ApplicationID,
+			"createdBy":     campaign.CreatedByUserAccountID,
+			"versionIds":    campaign.ApplicationVersionIDs,
+			"endOfLifeAt":   campaign.EndOfLifeAt,
+			"message":       campaign.Message,
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not insert VersionEOLCampaign: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.VersionEOLCampaign]); err != nil {
+		return 0, fmt.Errorf("could not insert VersionEOLCampaign: %w", err)
+	} else {
+		*campaign = result
+	}
+
+	rows, err = db.Query(ctx, `
+		WITH inserted AS (
+			INSERT INTO VersionEOLNotification (campaign_id, application_version_id, customer_user_account_id)
+				SELECT DISTINCT @campaignId::UUID, cur.application_version_id, u.id
+				FROM `+versionEOLDeploymentsFromExpr+`
+				WHERE `+versionEOLDeploymentsWhereExpr+` AND cur.application_version_id = any(@versionIds)
+				ON CONFLICT (application_version_id, customer_user_account_id) DO NOTHING
+				RETURNING customer_user_account_id
+		)
+		SELECT count(DISTINCT customer_user_account_id) FROM inserted`,
+		pgx.NamedArgs{
+			"campaignId": campaign.ID,
+			"orgId":      campaign.OrganizationID,
+			"versionIds": campaign.ApplicationVersionIDs,
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not insert VersionEOLNotifications: %w", err)
+	}
+	if count, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[int]); err != nil {
+		return 0, fmt.Errorf("could not insert VersionEOLNotifications: %w", err)
+	} else {
+		return count, nil
+	}
+}
+
+// GetPendingVersionEOLNotifications returns at most limit pairs of campaign and customer with notifications that
+// have not been sent, oldest first.
+func GetPendingVersionEOLNotifications(ctx context.Context, limit int) ([]types.PendingVersionEOLNotification, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(ctx, `
+		SELECT campaign_id, customer_user_account_id
+		FROM VersionEOLNotification
+		WHERE sent_at IS NULL
+		GROUP BY campaign_id, customer_user_account_id
+		ORDER BY min(created_at)
+		LIMIT @limit`,
+		pgx.NamedArgs{"limit": limit},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query pending VersionEOLNotifications: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.PendingVersionEOLNotification]); err != nil {
+		return nil, fmt.Errorf("could not collect pending VersionEOLNotifications: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+func UpdateVersionEOLNotificationsSent(
+	ctx context.Context,
+	pending types.PendingVersionEOLNotification,
+	at time.Time,
+) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx, `
+		UPDATE VersionEOLNotification SET sent_at = @at
+		WHERE campaign_id = @campaignId AND customer_user_account_id = @customerId AND sent_at IS NULL`,
+		pgx.NamedArgs{"campaignId": pending.CampaignID, "customerId": pending.CustomerUserAccountID, "at": at},
+	); err != nil {
+		return fmt.Errorf("could not update VersionEOLNotifications: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestVersionEOLCampaign(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	vendor, customer := org.Vendors[0], org.Customers[0]
+	target := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID)
+	revision := testutil.NewDeploymentRevision(ctx, t, target)
+	backdateDeploymentRevision(ctx, t, revision.ID)
+	// deployments of vendors are not part of the report
+	testutil.NewDeploymentRevision(ctx, t, testutil.NewDeploymentTarget(ctx, t, org.ID, vendor.ID))
+
+	v1, err := db.GetApplicationVersion(ctx, revision.ApplicationVersionID)
+	g.Expect(err).NotTo(HaveOccurred())
+	report, err := db.GetVersionEOLReport(ctx, org.ID, []uuid.UUID{v1.ID}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(report).To(HaveLen(1))
+	g.Expect(report[0].CustomerEmail).To(Equal(customer.Email))
+	g.Expect(report[0].DeploymentTargetID).To(Equal(target.ID))
+	g.Expect(report[0].ApplicationVersionName).To(Equal("1.0.0"))
+	g.Expect(report[0].NotificationCampaignID).To(BeNil())
+
+	campaign := types.VersionEOLCampaign{
+		OrganizationID:        org.ID,
+		ApplicationID:         v1.ApplicationID,
+		ApplicationVersionIDs: []uuid.UUID{v1.ID},
+		Message:               "please update",
+	}
+	g.Expect(db.CreateVersionEOLCampaign(ctx, &campaign)).To(Equal(1))
+	// customers are notified about a version only once
+	again := campaign
+	g.Expect(db.CreateVersionEOLCampaign(ctx, &again)).To(BeZero())
+
+	pending, err := db.GetPendingVersionEOLNotifications(ctx, 10)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(ConsistOf(types.PendingVersionEOLNotification{
+		CampaignID:            campaign.ID,
+		CustomerUserAccountID: customer.ID,
+	}))
+	g.Expect(db.UpdateVersionEOLNotificationsSent(ctx, pending[0], time.Now())).To(Succeed())
+	g.Expect(db.GetPendingVersionEOLNotifications(ctx, 10)).To(BeEmpty())
+	report, err = db.GetVersionEOLReport(ctx, org.ID, []uuid.UUID{v1.ID}, &customer.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(report).To(HaveLen(1))
+	g.Expect(report[0].NotificationCampaignID).To(Equal(&campaign.ID))
+	g.Expect(report[0].NotifiedAt).NotTo(BeNil())
+
+	progress := getVersionEOLCampaignProgress(ctx, t, campaign)
+	g.Expect(progress.CustomerCount).To(Equal(1))
+	g.Expect(progress.PendingCustomerCount).To(BeZero())
+	g.Expect(progress.RemainingCustomerCount).To(Equal(1))
+
+	// the report and the progress of the campaign are updated when the customer upgrades
+	v2 := types.ApplicationVersion{Name: "2.0.0", ApplicationID: v1.ApplicationID, ComposeFileData: v1.ComposeFileData}
+	g.Expect(db.CreateApplicationVersion(ctx, &v2)).To(Succeed())
+	_, err = db.CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+		DeploymentID:         &revision.DeploymentID,
+		DeploymentTargetID:   target.ID,
+		ApplicationVersionID: v2.ID,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(db.GetVersionEOLReport(ctx, org.ID, []uuid.UUID{v1.ID}, nil)).To(BeEmpty())
+	g.Expect(getVersionEOLCampaignProgress(ctx, t, campaign).RemainingCustomerCount).To(BeZero())
+}
+
+func getVersionEOLCampaignProgress(
+	ctx context.Context,
+	t *testing.T,
+	campaign types.VersionEOLCampaign,
+) types.VersionEOLCampaignWithProgress {
+	t.Helper()
+	campaigns, err := db.GetVersionEOLCampaigns(ctx, campaign.ApplicationID, campaign.OrganizationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range campaigns {
+		if c.ID == campaign.ID {
+			return c
+		}
+	}
+	t.Fatalf("campaign %v not found", campaign.ID)
+	return types.VersionEOLCampaignWithProgress{}
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	webAuthnCredentialOutputExpr = `
+		c.id, c.created_at, c.user_account_id, c.credential_id, c.public_key, c.sign_count, c.transports, c.nickname,
+		c.last_used_at
+	`
+	webAuthnChallengeOutputExpr = `c.id, c.created_at, c.expires_at, c.ceremony, c.user_account_id, c.challenge`
+)
+
+// CreateWebAuthnChallenge stores a new challenge. Expired challenges of abandoned ceremonies are removed as well.
+func CreateWebAuthnChallenge(ctx context.Context, challenge *types.WebAuthnChallenge) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(ctx, "DELETE FROM WebAuthnChallenge WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("could not delete expired WebAuthnChallenges: %w", err)
+	}
+	rows, err := db.Query(
+		ctx,
+		`INSERT INTO WebAuthnChallenge AS c (expires_at, ceremony, user_account_id, challenge)
+			VALUES (@expiresAt, @ceremony, @userAccountId, @challenge)
+			RETURNING `+webAuthnChallengeOutputExpr,
+		pgx.NamedArgs{
+			"expiresAt":     challenge.ExpiresAt,
+			"ceremony":      challenge.Ceremony,
+			"userAccountId": challenge.UserAccountID,
+			"challenge":     challenge.Challenge,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert WebAuthnChallenge: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.WebAuthnChallenge]); err != nil {
+		return fmt.Errorf("could not insert WebAuthnChallenge: %w", err)
+	} else {
+		*challenge = result
+		return nil
+	}
+}
+
+// ConsumeWebAuthnChallenge deletes and returns the challenge with the given ID, so that it can not be used again.
+// It returns apierrors.ErrNotFound if there is no such challenge for the ceremony or if it has expired.
+func ConsumeWebAuthnChallenge(
+	ctx context.Context,
+	id uuid.UUID,
+	ceremony types.WebAuthnCeremony,
+) (*types.WebAuthnChallenge, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`DELETE FROM WebAuthnChallenge c
+			WHERE c.id = @id AND c.ceremony = @ceremony AND c.expires_at > now()
+			RETURNING `+webAuthnChallengeOutputExpr,
+		pgx.NamedArgs{"id": id, "ceremony": ceremony},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete WebAuthnChallenge: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.WebAuthnChallenge]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not delete WebAuthnChallenge: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+func GetWebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]types.WebAuthnCredential, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		"SELECT "+webAuthnCredentialOutputExpr+` FROM WebAuthnCredential c
+			WHERE c.user_account_id = @userId
+			ORDER BY c.created_at`,
+		pgx.NamedArgs{"userId": userID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query WebAuthnCredentials: %w", err)
+	}
+	if result, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.WebAuthnCredential]); err != nil {
+		return nil, fmt.Errorf("could not query WebAuthnCredentials: %w", err)
+	} else {
+		return result, nil
+	}
+}
+
+// GetWebAuthnCredentialByCredentialID returns the credential with the ID that was chosen by the authenticator.
+func GetWebAuthnCredentialByCredentialID(ctx context.Context, credentialID []byte) (*types.WebAuthnCredential, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		"SELECT "+webAuthnCredentialOutputExpr+" FROM WebAuthnCredential c WHERE c.credential_id = @credentialId",
+		pgx.NamedArgs{"credentialId": credentialID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query WebAuthnCredential: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.WebAuthnCredential]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not query WebAuthnCredential: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+func CountWebAuthnCredentials(ctx context.Context, userID uuid.UUID) (int64, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		"SELECT count(*) FROM WebAuthnCredential WHERE user_account_id = @userId",
+		pgx.NamedArgs{"userId": userID},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not count WebAuthnCredentials: %w", err)
+	}
+	if count, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[int64]); err != nil {
+		return 0, fmt.Errorf("could not count WebAuthnCredentials: %w", err)
+	} else {
+		return count, nil
+	}
+}
+
+// CreateWebAuthnCredential returns apierrors.ErrAlreadyExists if the credential has already been registered.
+func CreateWebAuthnCredential(ctx context.Context, credential *types.WebAuthnCredential) error {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`INSERT INTO WebAuthnCredential AS c
+			(user_account_id, credential_id, public_key, sign_count, transports, nickname)
+			VALUES (
+				@userAccountId, @credentialId, @publicKey, @signCount, COALESCE(@transports::TEXT[], '{}'), @nickname
+			)
+			RETURNING `+webAuthnCredentialOutputExpr,
+		pgx.NamedArgs{
+			"userAccountId": credential.UserAccountID,
+			"credentialId":  credential.CredentialID,
+			"publicKey":     credential.PublicKey,
+			"signCount":     credential.SignCount,
+			"transports":    credential.Transports,
+			"nickname":      credential.Nickname,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert WebAuthnCredential: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.WebAuthnCredential]); err != nil {
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) && pgError.Code == pgerrcode.UniqueViolation {
+			err = apierrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("could not insert WebAuthnCredential: %w", err)
+	} else {
+		*credential = result
+		return nil
+	}
+}
+
+func UpdateWebAuthnCredentialNickname(
+	ctx context.Context,
+	id, userID uuid.UUID,
+	nickname string,
+) (*types.WebAuthnCredential, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`UPDATE WebAuthnCredential c SET nickname = @nickname
+			WHERE c.id = @id AND c.user_account_id = @userId
+			RETURNING `+webAuthnCredentialOutputExpr,
+		pgx.NamedArgs{"id": id, "userId": userID, "nickname": nickname},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not update WebAuthnCredential: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.WebAuthnCredential]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not update WebAuthnCredential: %w", err)
+	} else {
+		return &result, nil
+	}
+}
+
+// UpdateWebAuthnCredentialUsed stores the sign count of a successful authentication.
+func UpdateWebAuthnCredentialUsed(ctx context.Context, id uuid.UUID, signCount int64) error {
+	db := internalctx.GetDb(ctx)
+	if _, err := db.Exec(
+		ctx,
+		"UPDATE WebAuthnCredential SET sign_count = @signCount, last_used_at = now() WHERE id = @id",
+		pgx.NamedArgs{"id": id, "signCount": signCount},
+	); err != nil {
+		return fmt.Errorf("could not update WebAuthnCredential: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebAuthnCredential deletes and returns the credential with the given ID of the user. It returns
+// apierrors.ErrNotFound if the user has no such credential.
+func DeleteWebAuthnCredential(ctx context.Context, id, userID uuid.UUID) (*types.WebAuthnCredential, error) {
+	db := internalctx.GetDb(ctx)
+	rows, err := db.Query(
+		ctx,
+		`DELETE FROM WebAuthnCredential c
+			WHERE c.id = @id AND c.user_account_id = @userId
+			RETURNING `+webAuthnCredentialOutputExpr,
+		pgx.NamedArgs{"id": id, "userId": userID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete WebAuthnCredential: %w", err)
+	}
+	if result, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[types.WebAuthnCredential]); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = apierrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("could not delete WebAuthnCredential: %w", err)
+	} else {
+		return &result, nil
+	}
+}
@@ -0,0 +1,96 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestWebAuthnChallenge(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+
+	challenge := types.WebAuthnChallenge{
+		ExpiresAt:     time.Now().Add(time.Minute),
+		Ceremony:      types.WebAuthnCeremonyRegistration,
+		UserAccountID: &user.ID,
+		Challenge:     []byte("challenge"),
+	}
+	g.Expect(db.CreateWebAuthnChallenge(ctx, &challenge)).To(Succeed())
+
+	_, err := db.ConsumeWebAuthnChallenge(ctx, challenge.ID, types.WebAuthnCeremonyAuthentication)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	consumed, err := db.ConsumeWebAuthnChallenge(ctx, challenge.ID, types.WebAuthnCeremonyRegistration)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(consumed.Challenge).To(Equal([]byte("challenge")))
+	g.Expect(consumed.UserAccountID).To(Equal(&user.ID))
+	_, err = db.ConsumeWebAuthnChallenge(ctx, challenge.ID, types.WebAuthnCeremonyRegistration)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+
+	expired := types.WebAuthnChallenge{
+		ExpiresAt: time.Now().Add(-time.Minute),
+		Ceremony:  types.WebAuthnCeremonyAuthentication,
+		Challenge: []byte("expired"),
+	}
+	g.Expect(db.CreateWebAuthnChallenge(ctx, &expired)).To(Succeed())
+	_, err = db.ConsumeWebAuthnChallenge(ctx, expired.ID, types.WebAuthnCeremonyAuthentication)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+}
+
+func TestWebAuthnCredentials(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	user := testutil.NewUserAccount(ctx, t)
+	other := testutil.NewUserAccount(ctx, t)
+
+	credential := types.WebAuthnCredential{
+		UserAccountID: user.ID,
+		CredentialID:  []byte("credential"),
+		PublicKey:     []byte("public key"),
+		Nickname:      "Laptop",
+	}
+	g.Expect(db.CreateWebAuthnCredential(ctx, &credential)).To(Succeed())
+	g.Expect(credential.Transports).To(BeEmpty())
+	g.Expect(db.CountWebAuthnCredentials(ctx, user.ID)).To(Equal(int64(1)))
+	g.Expect(db.CountWebAuthnCredentials(ctx, other.ID)).To(BeZero())
+
+	duplicate := types.WebAuthnCredential{
+		UserAccountID: other.ID,
+		CredentialID:  []byte("credential"),
+		PublicKey:     []byte("public key"),
+		Nickname:      "Phone",
+	}
+	g.Expect(db.RunTx(ctx, func(ctx context.Context) error {
+		return db.CreateWebAuthnCredential(ctx, &duplicate)
+	})).To(MatchError(apierrors.ErrAlreadyExists))
+
+	loaded, err := db.GetWebAuthnCredentialByCredentialID(ctx, []byte("credential"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.ID).To(Equal(credential.ID))
+	g.Expect(loaded.LastUsedAt).To(BeNil())
+	g.Expect(db.UpdateWebAuthnCredentialUsed(ctx, credential.ID, 3)).To(Succeed())
+	loaded, err = db.GetWebAuthnCredentialByCredentialID(ctx, []byte("credential"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loaded.SignCount).To(Equal(int64(3)))
+	g.Expect(loaded.LastUsedAt).NotTo(BeNil())
+
+	_, err = db.UpdateWebAuthnCredentialNickname(ctx, credential.ID, other.ID, "Stolen")
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	renamed, err := db.UpdateWebAuthnCredentialNickname(ctx, credential.ID, user.ID, "Work laptop")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(renamed.Nickname).To(Equal("Work laptop"))
+
+	_, err = db.DeleteWebAuthnCredential(ctx, credential.ID, other.ID)
+	g.Expect(err).To(MatchError(apierrors.ErrNotFound))
+	deleted, err := db.DeleteWebAuthnCredential(ctx, credential.ID, user.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted.Nickname).To(Equal("Work laptop"))
+	g.Expect(db.GetWebAuthnCredentials(ctx, user.ID)).To(BeEmpty())
+}
@@ -0,0 +1,51 @@
+// Package depgraph implements the checks and the ordering for declared dependencies between deployments and between
+// applications. A graph is given as a map from each node to the nodes it depends on.
+package depgraph
+
+import "slices"
+
+// HasPath reports whether target can be reached from any of the nodes in from. It is used to reject a new dependency
+// that would form a cycle: adding the edges a -> from is only allowed if a can not be reached from them.
+func HasPath[K comparable](graph map[K][]K, from []K, target K) bool {
+	visited := map[K]bool{}
+	pending := slices.Clone(from)
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if node == target {
+			return true
+		} else if !visited[node] {
+			visited[node] = true
+			pending = append(pending, graph[node]...)
+		}
+	}
+	return false
+}
+
+// Sort returns items ordered so that every item comes after the items it depends on. Otherwise, the original order is
+// kept. Dependencies on nodes that are not among the items are ignored, and so are edges that would close a cycle.
+func Sort[T any, K comparable](items []T, key func(T) K, graph map[K][]K) []T {
+	index := make(map[K]int, len(items))
+	for i, item := range items {
+		index[key(item)] = i
+	}
+	result := make([]T, 0, len(items))
+	visited := make([]bool, len(items))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, dependency := range graph[key(items[i])] {
+			if j, ok := index[dependency]; ok {
+				visit(j)
+			}
+		}
+		result = append(result, items[i])
+	}
+	for i := range items {
+		visit(i)
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package depgraph_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/depgraph"
+	. "github.com/onsi/gomega"
+)
+
+func TestHasPath(t *testing.T) {
+	g := NewWithT(t)
+	graph := map[string][]string{"addon": {"platform"}, "platform": {"database"}}
+	g.Expect(depgraph.HasPath(graph, []string{"addon"}, "database")).To(BeTrue())
+	g.Expect(depgraph.HasPath(graph, []string{"database"}, "addon")).To(BeFalse())
+	g.Expect(depgraph.HasPath(graph, nil, "addon")).To(BeFalse())
+	g.Expect(depgraph.HasPath(graph, []string{"platform"}, "addon")).To(BeFalse())
+}
+
+func TestSort(t *testing.T) {
+	g := NewWithT(t)
+	identity := func(s string) string { return s }
+	graph := map[string][]string{"addon": {"platform", "missing"}, "platform": {"database"}}
+	g.Expect(depgraph.Sort([]string{"addon", "other", "platform", "database"}, identity, graph)).
+		To(Equal([]string{"database", "platform", "addon", "other"}))
+	g.Expect(depgraph.Sort([]string{"other", "database"}, identity, graph)).
+		To(Equal([]string{"other", "database"}))
+
+	cyclic := map[string][]string{"a": {"b"}, "b": {"a"}}
+	g.Expect(depgraph.Sort([]string{"a", "b"}, identity, cyclic)).To(ConsistOf("a", "b"))
+}
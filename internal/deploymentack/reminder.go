@@ -0,0 +1,59 @@
+// Package deploymentack reminds customers of updates that are waiting for their acknowledgment.
+package deploymentack
+
+import (
+	"context"
+	"time"
+
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailsending"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// Interval is the minimum time between the creation of a revision and the first reminder, and between two
+	// reminders for the same revision.
+	Interval time.Duration
+	// BatchSize is the maximum number of reminders that are sent in one run.
+	BatchSize int
+}
+
+type Reminder struct {
+	mailer mail.Mailer
+	opts   Options
+	now    func() time.Time
+}
+
+func NewReminder(mailer mail.Mailer, opts Options) *Reminder {
+	return &Reminder{mailer: mailer, opts: opts, now: clock.Now}
+}
+
+// Run sends reminders for a batch of outstanding acknowledgments.
+func (r *Reminder) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	ctx = internalctx.WithMailer(ctx, r.mailer)
+	due, err := db.GetDueDeploymentAcknowledgmentReminders(ctx, r.now().Add(-r.opts.Interval), r.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	var sent int
+	for _, acknowledgment := range due {
+		if err := mailsending.SendDeploymentAcknowledgmentRequiredMail(ctx, acknowledgment, true); err != nil {
+			log.Warn("could not send deployment acknowledgment reminder",
+				zap.Stringer("deploymentRevisionId", acknowledgment.DeploymentRevisionID), zap.Error(err))
+		} else {
+			sent++
+		}
+		// the reminder time is updated even if sending failed, so that a broken recipient does not block the batch
+		if err := db.UpdateDeploymentRevisionAcknowledgmentRemindedAt(
+			ctx, acknowledgment.DeploymentRevisionID, r.now(),
+		); err != nil {
+			return err
+		}
+	}
+	log.Info("deployment acknowledgment reminders finished", zap.Int("due", len(due)), zap.Int("sent", sent))
+	return nil
+}
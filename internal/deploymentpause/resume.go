@@ -0,0 +1,55 @@
+// Package deploymentpause ends pauses of deployments and deployment targets when their resume time is reached.
+package deploymentpause
+
+import (
+	"context"
+
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// RunAutoResume resumes all deployments and deployment targets whose resume time has passed. Each resumed pause is
+// recorded in the audit log of its organization.
+func RunAutoResume(ctx context.Context) error {
+	var deployments, targets []types.ResumedPause
+	err := db.RunTx(ctx, func(ctx context.Context) (err error) {
+		if deployments, targets, err = db.ResumeDuePauses(ctx, clock.Now()); err != nil {
+			return err
+		}
+		for _, p := range deployments {
+			if err := auditAutoResume(ctx, "Deployment", p); err != nil {
+				return err
+			}
+		}
+		for _, p := range targets {
+			if err := auditAutoResume(ctx, "DeploymentTarget", p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	internalctx.GetLogger(ctx).Info("deployment pause auto resume finished",
+		zap.Int("deployments", len(deployments)), zap.Int("deploymentTargets", len(targets)))
+	return nil
+}
+
+func auditAutoResume(ctx context.Context, resourceType string, p types.ResumedPause) error {
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: &p.OrganizationID,
+		Action:         "auto_resume",
+		ResourceType:   resourceType,
+		ResourceID:     p.ID,
+		Data: map[string]any{
+			"pausedAt":              p.PausedAt,
+			"pausedByUserAccountId": p.PausedByUserAccountID,
+			"reason":                p.PauseReason,
+			"resumeAt":              p.ResumeAt,
+		},
+	})
+}
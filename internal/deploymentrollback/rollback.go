@@ -0,0 +1,79 @@
+// Package deploymentrollback rolls back deployments whose latest revision keeps failing to the latest revision that
+// was healthy.
+package deploymentrollback
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// DefaultWindow is the rollback window of deployments for which neither the deployment nor its organization
+	// defines one.
+	DefaultWindow time.Duration
+	// BatchSize is the maximum number of deployments that are rolled back in one run.
+	BatchSize int
+}
+
+type RollbackJob struct {
+	mailer mail.Mailer
+	opts   Options
+	now    func() time.Time
+}
+
+func NewRollbackJob(mailer mail.Mailer, opts Options) *RollbackJob {
+	return &RollbackJob{mailer: mailer, opts: opts, now: clock.Now}
+}
+
+// Run rolls back a batch of failed deployments and notifies the vendor and the customer of each of them.
+func (j *RollbackJob) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	ctx = internalctx.WithMailer(ctx, j.mailer)
+	due, err := db.GetDueDeploymentAutoRollbacks(ctx, j.now(), j.opts.DefaultWindow, j.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	var rolledBack int
+	for _, rollback := range due {
+		log := log.With(
+			zap.Stringer("deploymentId", rollback.DeploymentID),
+			zap.Stringer("failedRevisionId", rollback.FailedRevisionID),
+		)
+		var revision *types.DeploymentRevision
+		if err := db.RunTx(ctx, func(ctx context.Context) (err error) {
+			revision, err = db.CreateDeploymentAutoRollbackRevision(ctx, rollback)
+			return
+		}); errors.Is(err, apierrors.ErrConflict) {
+			// another replica was faster
+			continue
+		} else if err != nil {
+			return err
+		}
+		rolledBack++
+		log.Info("deployment rolled back automatically", zap.Stringer("deploymentRevisionId", revision.ID))
+
+		if err := mailsending.SendDeploymentAutoRollbackMail(ctx, rollback); err != nil {
+			log.Warn("could not send automatic rollback mail", zap.Error(err))
+		}
+		// acknowledgment rules apply to automatic rollbacks like to any other revision
+		if revision.IsAcknowledgmentPending() {
+			if acknowledgment, err := db.GetPendingDeploymentAcknowledgment(ctx, revision.ID); err != nil {
+				log.Warn("could not get pending deployment acknowledgment", zap.Error(err))
+			} else if err := mailsending.SendDeploymentAcknowledgmentRequiredMail(ctx, *acknowledgment, false); err != nil {
+				log.Warn("could not send deployment acknowledgment mail", zap.Error(err))
+			}
+		}
+	}
+	log.Info("automatic deployment rollbacks finished", zap.Int("due", len(due)), zap.Int("rolledBack", rolledBack))
+	return nil
+}
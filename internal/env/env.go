@@ -9,44 +9,124 @@ import (
 
 	"github.com/glasskube/distr/internal/envparse"
 	"github.com/glasskube/distr/internal/envutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/joho/godotenv"
 )
 
 var (
-	databaseUrl                         string
-	databaseMaxConns                    *int
-	jwtSecret                           []byte
-	host                                string
-	registryHost                        string
-	mailerConfig                        MailerConfig
-	inviteTokenValidDuration            time.Duration
-	resetTokenValidDuration             time.Duration
-	agentTokenMaxValidDuration          time.Duration
-	agentInterval                       time.Duration
-	statusEntriesMaxAge                 *time.Duration
-	metricsEntriesMaxAge                *time.Duration
-	logRecordEntriesMaxCount            *int
-	sentryDSN                           string
-	sentryDebug                         bool
-	otelExporterSentryEnabled           bool
-	otelExporterOtlpEnabled             bool
-	enableQueryLogging                  bool
-	agentDockerConfig                   []byte
-	frontendSentryDSN                   *string
-	frontendSentryTraceSampleRate       *float64
-	frontendPosthogToken                *string
-	frontendPosthogAPIHost              *string
-	frontendPosthogUIHost               *string
-	userEmailVerificationRequired       bool
-	serverShutdownDelayDuration         *time.Duration
-	registration                        RegistrationMode
-	registryEnabled                     bool
-	registryS3Config                    S3Config
-	artifactTagsDefaultLimitPerOrg      int
-	cleanupDeploymentRevisionStatusCron *string
-	cleanupDeploymentTargetStatusCron   *string
-	cleanupDeploymentTargetMetricsCron  *string
-	cleanupDeploymentLogRecordCron      *string
+	databaseUrl                            string
+	databaseMaxConns                       *int
+	jwtSecret                              []byte
+	host                                   string
+	registryHost                           string
+	mailerConfig                           MailerConfig
+	organizationMailerMaxFailures          int
+	mailerSESWebhookToken                  *string
+	inviteTokenValidDuration               time.Duration
+	resetTokenValidDuration                time.Duration
+	agentTokenMaxValidDuration             time.Duration
+	agentInterval                          time.Duration
+	statusEntriesMaxAge                    *time.Duration
+	metricsEntriesMaxAge                   *time.Duration
+	logRecordEntriesMaxCount               *int
+	sentryDSN                              string
+	sentryDebug                            bool
+	sentryRequestHeadersAllowList          []string
+	scrubFieldPatterns                     []string
+	scrubEmailHMACKey                      []byte
+	otelExporterSentryEnabled              bool
+	otelExporterOtlpEnabled                bool
+	enableQueryLogging                     bool
+	agentDockerConfig                      []byte
+	frontendSentryDSN                      *string
+	frontendSentryTraceSampleRate          *float64
+	frontendPosthogToken                   *string
+	frontendPosthogAPIHost                 *string
+	frontendPosthogUIHost                  *string
+	userEmailVerificationRequired          bool
+	serverShutdownDelayDuration            *time.Duration
+	registration                           RegistrationMode
+	registryEnabled                        bool
+	registryS3Config                       S3Config
+	artifactTagsDefaultLimitPerOrg         int
+	artifactStorageDefaultQuotaBytesPerOrg int64
+	registryNameMaxDepth                   int
+	registryNameAliasDuration              time.Duration
+	registryManifestMaxSize                int
+	registryIndexMaxChildren               int
+	registryIndexMaxDepth                  int
+	registryIndexMaxDescriptors            int
+	registryIndexChildGracePeriod          time.Duration
+	registryManifestCacheTTL               time.Duration
+	registryManifestCacheSize              int
+	registryPullThroughTagTTL              time.Duration
+	registryDefaultPlatform                *v1.Platform
+	registryMetricsAddr                    string
+	requestBodyMaxSize                     int
+	uploadRequestBodyMaxSize               int
+	cleanupDeploymentRevisionStatusCron    *string
+	cleanupDeploymentTargetStatusCron      *string
+	cleanupDeploymentTargetMetricsCron     *string
+	cleanupDeploymentLogRecordCron         *string
+	cleanupOrphanedFilesCron               *string
+	orphanedFilesGracePeriod               time.Duration
+	cleanupBlobsCron                       *string
+	customerDataExportCron                 *string
+	blobGarbageCollectionGracePeriod       time.Duration
+	blobGarbageCollectionBatchSize         int
+	artifactDeletionCron                   *string
+	artifactDeletionCoolOff                time.Duration
+	applicationDeletionCron                *string
+	applicationDeletionCoolOff             time.Duration
+	cleanupDataPurgeCron                   *string
+	cleanupDataRetentionCron               *string
+	cleanupAnnouncementCron                *string
+	applicationBadgeRefreshCron            *string
+	upstreamWatchCron                      *string
+	upstreamWatchInterval                  time.Duration
+	upstreamWatchBatchSize                 int
+	upstreamWatchRegistryBudget            int
+	certificateCheckCron                   *string
+	certificateCheckInterval               time.Duration
+	certificateCheckBatchSize              int
+	deploymentAckReminderCron              *string
+	deploymentAckReminderInterval          time.Duration
+	deploymentAckReminderBatchSize         int
+	versionEOLNotificationCron             *string
+	artifactTagRetentionCron               *string
+	artifactTagRetentionBatchSize          int
+	versionEOLNotificationBatchSize        int
+	deploymentAutoRollbackCron             *string
+	deploymentAutoRollbackWindow           time.Duration
+	deploymentAutoRollbackBatchSize        int
+	deploymentPauseResumeCron              *string
+	deploymentTargetOutageCron             *string
+	deploymentTargetPreRegistrationCron    *string
+	deploymentTargetPairingTokenValidity   time.Duration
+	deploymentTargetPreRegReminderInterval time.Duration
+	deploymentTargetPreRegMaxReminders     int
+	deploymentTargetPreRegExpiry           time.Duration
+	deploymentTargetOutageWindow           time.Duration
+	deploymentTargetOutageThreshold        float64
+	deploymentTargetOutageMinTargets       int
+	aggregateRefreshCron                   *string
+	aggregateRefreshInterval               time.Duration
+	aggregateRefreshBatchSize              int
+	storageMigrationCron                   *string
+	storageMigrationBatchSize              int
+	piiEncryptionKeys                      []PIIEncryptionKey
+	piiBlindIndexKey                       []byte
+	piiEncryptionCron                      *string
+	piiEncryptionBatchSize                 int
+	geoIPDatabasePath                      *string
+	appMetricsMaxSeriesPerDeployment       int
+	apiV1Sunset                            *time.Time
+	selfCheckBlobSampleSize                int
+	selfCheckMaxMissingBlobRatio           float64
+	consistencyCheckCron                   *string
+	consistencyCheckRepair                 bool
+	unverifiedUserAccountCleanupCron       *string
+	unverifiedUserAccountMaxAge            time.Duration
 )
 
 func Initialize() {
@@ -65,6 +145,9 @@ func Initialize() {
 	statusEntriesMaxAge = envutil.GetEnvParsedOrNil("STATUS_ENTRIES_MAX_AGE", envparse.PositiveDuration)
 	metricsEntriesMaxAge = envutil.GetEnvParsedOrNil("METRICS_ENTRIES_MAX_AGE", envparse.PositiveDuration)
 	logRecordEntriesMaxCount = envutil.GetEnvParsedOrNil("LOG_RECORD_ENTRIES_MAX_COUNT", envparse.NonNegativeNumber)
+	orphanedFilesGracePeriod = envutil.GetEnvParsedOrDefault(
+		"ORPHANED_FILES_GRACE_PERIOD", envparse.PositiveDuration, 24*time.Hour,
+	)
 	enableQueryLogging = envutil.GetEnvParsedOrDefault("ENABLE_QUERY_LOGGING", strconv.ParseBool, false)
 	userEmailVerificationRequired = envutil.GetEnvParsedOrDefault(
 		"USER_EMAIL_VERIFICATION_REQUIRED", strconv.ParseBool, true,
@@ -94,6 +177,13 @@ func Initialize() {
 		}
 	}
 
+	if mailerConfig.Type == MailerTypeSES {
+		mailerSESWebhookToken = envutil.GetEnvOrNil("MAILER_SES_WEBHOOK_TOKEN")
+	}
+	organizationMailerMaxFailures = envutil.GetEnvParsedOrDefault(
+		"ORGANIZATION_MAILER_MAX_FAILURES", envparse.PositiveNumber, 5,
+	)
+
 	registryEnabled = envutil.GetEnvParsedOrDefault("REGISTRY_ENABLED", strconv.ParseBool, false)
 	if registryEnabled {
 		registryHost = envutil.GetEnvOrDefault(
@@ -110,9 +200,57 @@ func Initialize() {
 	artifactTagsDefaultLimitPerOrg = envutil.GetEnvParsedOrDefault(
 		"ARTIFACT_TAGS_DEFAULT_LIMIT_PER_ORG", envparse.NonNegativeNumber, 0,
 	)
+	artifactStorageDefaultQuotaBytesPerOrg = envutil.GetEnvParsedOrDefault(
+		"ARTIFACT_STORAGE_DEFAULT_QUOTA_BYTES_PER_ORG", envparse.NonNegativeInt64, 0,
+	)
+	registryNameMaxDepth = envutil.GetEnvParsedOrDefault("REGISTRY_NAME_MAX_DEPTH", envparse.NonNegativeNumber, 0)
+	registryNameAliasDuration = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_NAME_ALIAS_DURATION", envparse.PositiveDuration, 30*24*time.Hour,
+	)
+	artifactDeletionCoolOff = envutil.GetEnvParsedOrDefault(
+		"ARTIFACT_DELETION_COOL_OFF", envparse.PositiveDuration, 7*24*time.Hour,
+	)
+	applicationDeletionCoolOff = envutil.GetEnvParsedOrDefault(
+		"APPLICATION_DELETION_COOL_OFF", envparse.PositiveDuration, 7*24*time.Hour,
+	)
+	registryManifestMaxSize = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_MANIFEST_MAX_SIZE", envparse.NonNegativeNumber, 4*1024*1024,
+	)
+	registryIndexMaxChildren = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_INDEX_MAX_CHILDREN", envparse.NonNegativeNumber, 1000,
+	)
+	registryIndexMaxDepth = envutil.GetEnvParsedOrDefault("REGISTRY_INDEX_MAX_DEPTH", envparse.NonNegativeNumber, 4)
+	registryIndexMaxDescriptors = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_INDEX_MAX_DESCRIPTORS", envparse.NonNegativeNumber, 10000,
+	)
+	registryIndexChildGracePeriod = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_INDEX_CHILD_GRACE_PERIOD", envparse.NonNegativeDuration, 2*time.Second,
+	)
+	registryManifestCacheTTL = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_MANIFEST_CACHE_TTL", envparse.NonNegativeDuration, 5*time.Second,
+	)
+	registryManifestCacheSize = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_MANIFEST_CACHE_SIZE", envparse.NonNegativeNumber, 1000,
+	)
+	registryPullThroughTagTTL = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_PULL_THROUGH_TAG_TTL", envparse.NonNegativeDuration, 5*time.Minute,
+	)
+	registryDefaultPlatform = envutil.GetEnvParsedOrDefault(
+		"REGISTRY_DEFAULT_PLATFORM", v1.ParsePlatform, &v1.Platform{OS: "linux", Architecture: "amd64"},
+	)
+	registryMetricsAddr = envutil.GetEnv("REGISTRY_METRICS_ADDR")
+	requestBodyMaxSize = envutil.GetEnvParsedOrDefault("REQUEST_BODY_MAX_SIZE", envparse.PositiveNumber, 1024*1024)
+	uploadRequestBodyMaxSize = envutil.GetEnvParsedOrDefault(
+		"UPLOAD_REQUEST_BODY_MAX_SIZE", envparse.PositiveNumber, 5*1024*1024,
+	)
 
 	sentryDSN = envutil.GetEnv("SENTRY_DSN")
 	sentryDebug = envutil.GetEnvParsedOrDefault("SENTRY_DEBUG", strconv.ParseBool, false)
+	sentryRequestHeadersAllowList = envutil.GetEnvParsedOrDefault(
+		"SENTRY_REQUEST_HEADERS_ALLOWLIST", envparse.StringList, nil,
+	)
+	scrubFieldPatterns = envutil.GetEnvParsedOrDefault("SCRUB_FIELD_PATTERNS", envparse.StringList, nil)
+	scrubEmailHMACKey = envutil.GetEnvParsedOrDefault("SCRUB_EMAIL_HMAC_KEY", envparse.ByteSlice, nil)
 	otelExporterSentryEnabled = envutil.GetEnvParsedOrDefault("OTEL_EXPORTER_SENTRY_ENABLED", strconv.ParseBool, false)
 	otelExporterOtlpEnabled = envutil.GetEnvParsedOrDefault("OTEL_EXPORTER_OTLP_ENABLED", strconv.ParseBool, false)
 	agentDockerConfig = envutil.GetEnvParsedOrDefault("AGENT_DOCKER_CONFIG", envparse.ByteSlice, nil)
@@ -126,6 +264,118 @@ func Initialize() {
 	cleanupDeploymentTargetStatusCron = envutil.GetEnvOrNil("CLEANUP_DEPLOYMENT_TARGET_STATUS_CRON")
 	cleanupDeploymentTargetMetricsCron = envutil.GetEnvOrNil("CLEANUP_DEPLOYMENT_TARGET_METRICS_CRON")
 	cleanupDeploymentLogRecordCron = envutil.GetEnvOrNil("CLEANUP_DEPLOYMENT_LOG_RECORD_CRON")
+	cleanupOrphanedFilesCron = envutil.GetEnvOrNil("CLEANUP_ORPHANED_FILES_CRON")
+	cleanupBlobsCron = envutil.GetEnvOrNil("CLEANUP_BLOBS_CRON")
+	customerDataExportCron = envutil.GetEnvOrNil("CUSTOMER_DATA_EXPORT_CRON")
+	blobGarbageCollectionGracePeriod = envutil.GetEnvParsedOrDefault(
+		"BLOB_GARBAGE_COLLECTION_GRACE_PERIOD", envparse.PositiveDuration, 24*time.Hour,
+	)
+	blobGarbageCollectionBatchSize = envutil.GetEnvParsedOrDefault(
+		"BLOB_GARBAGE_COLLECTION_BATCH_SIZE", envparse.PositiveNumber, 1000,
+	)
+	artifactDeletionCron = envutil.GetEnvOrNil("ARTIFACT_DELETION_CRON")
+	applicationDeletionCron = envutil.GetEnvOrNil("APPLICATION_DELETION_CRON")
+	cleanupDataPurgeCron = envutil.GetEnvOrNil("CLEANUP_DATA_PURGE_CRON")
+	cleanupDataRetentionCron = envutil.GetEnvOrNil("CLEANUP_DATA_RETENTION_CRON")
+	cleanupAnnouncementCron = envutil.GetEnvOrNil("CLEANUP_ANNOUNCEMENT_CRON")
+	applicationBadgeRefreshCron = envutil.GetEnvOrNil("APPLICATION_BADGE_REFRESH_CRON")
+	upstreamWatchCron = envutil.GetEnvOrNil("UPSTREAM_WATCH_CRON")
+	upstreamWatchInterval = envutil.GetEnvParsedOrDefault(
+		"UPSTREAM_WATCH_INTERVAL", envparse.PositiveDuration, 6*time.Hour,
+	)
+	upstreamWatchBatchSize = envutil.GetEnvParsedOrDefault("UPSTREAM_WATCH_BATCH_SIZE", envparse.PositiveNumber, 100)
+	upstreamWatchRegistryBudget = envutil.GetEnvParsedOrDefault(
+		"UPSTREAM_WATCH_REGISTRY_BUDGET", envparse.PositiveNumber, 20,
+	)
+	certificateCheckCron = envutil.GetEnvOrNil("CERTIFICATE_CHECK_CRON")
+	certificateCheckInterval = envutil.GetEnvParsedOrDefault(
+		"CERTIFICATE_CHECK_INTERVAL", envparse.PositiveDuration, 12*time.Hour,
+	)
+	certificateCheckBatchSize = envutil.GetEnvParsedOrDefault(
+		"CERTIFICATE_CHECK_BATCH_SIZE", envparse.PositiveNumber, 100,
+	)
+	deploymentAckReminderCron = envutil.GetEnvOrNil("DEPLOYMENT_ACKNOWLEDGMENT_REMINDER_CRON")
+	deploymentAckReminderInterval = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_ACKNOWLEDGMENT_REMINDER_INTERVAL", envparse.PositiveDuration, 24*time.Hour,
+	)
+	deploymentAckReminderBatchSize = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_ACKNOWLEDGMENT_REMINDER_BATCH_SIZE", envparse.PositiveNumber, 100,
+	)
+	versionEOLNotificationCron = envutil.GetEnvOrNil("VERSION_EOL_NOTIFICATION_CRON")
+	versionEOLNotificationBatchSize = envutil.GetEnvParsedOrDefault(
+		"VERSION_EOL_NOTIFICATION_BATCH_SIZE", envparse.PositiveNumber, 100,
+	)
+	artifactTagRetentionCron = envutil.GetEnvOrNil("ARTIFACT_TAG_RETENTION_CRON")
+	artifactTagRetentionBatchSize = envutil.GetEnvParsedOrDefault(
+		"ARTIFACT_TAG_RETENTION_BATCH_SIZE", envparse.PositiveNumber, 100,
+	)
+	deploymentAutoRollbackCron = envutil.GetEnvOrNil("DEPLOYMENT_AUTO_ROLLBACK_CRON")
+	deploymentAutoRollbackWindow = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_AUTO_ROLLBACK_WINDOW", envparse.PositiveDuration, 10*time.Minute,
+	)
+	deploymentAutoRollbackBatchSize = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_AUTO_ROLLBACK_BATCH_SIZE", envparse.PositiveNumber, 100,
+	)
+	deploymentPauseResumeCron = envutil.GetEnvOrNil("DEPLOYMENT_PAUSE_RESUME_CRON")
+	deploymentTargetOutageCron = envutil.GetEnvOrNil("DEPLOYMENT_TARGET_OUTAGE_CRON")
+	deploymentTargetOutageWindow = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_TARGET_OUTAGE_WINDOW", envparse.PositiveDuration, 15*time.Minute,
+	)
+	deploymentTargetOutageThreshold = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_TARGET_OUTAGE_THRESHOLD", envparse.Float, 0.5,
+	)
+	deploymentTargetOutageMinTargets = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_TARGET_OUTAGE_MIN_TARGETS", envparse.PositiveNumber, 3,
+	)
+	deploymentTargetPreRegistrationCron = envutil.GetEnvOrNil("DEPLOYMENT_TARGET_PRE_REGISTRATION_CRON")
+	deploymentTargetPairingTokenValidity = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_TARGET_PAIRING_TOKEN_VALIDITY", envparse.PositiveDuration, 72*time.Hour,
+	)
+	deploymentTargetPreRegReminderInterval = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_TARGET_PRE_REGISTRATION_REMINDER_INTERVAL", envparse.PositiveDuration, 72*time.Hour,
+	)
+	deploymentTargetPreRegMaxReminders = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_TARGET_PRE_REGISTRATION_MAX_REMINDERS", envparse.NonNegativeNumber, 3,
+	)
+	deploymentTargetPreRegExpiry = envutil.GetEnvParsedOrDefault(
+		"DEPLOYMENT_TARGET_PRE_REGISTRATION_EXPIRY", envparse.PositiveDuration, 30*24*time.Hour,
+	)
+	aggregateRefreshCron = envutil.GetEnvOrNil("AGGREGATE_REFRESH_CRON")
+	aggregateRefreshInterval = envutil.GetEnvParsedOrDefault(
+		"AGGREGATE_REFRESH_INTERVAL", envparse.PositiveDuration, 15*time.Minute,
+	)
+	aggregateRefreshBatchSize = envutil.GetEnvParsedOrDefault(
+		"AGGREGATE_REFRESH_BATCH_SIZE", envparse.PositiveNumber, 50,
+	)
+	storageMigrationCron = envutil.GetEnvOrNil("ORGANIZATION_STORAGE_MIGRATION_CRON")
+	storageMigrationBatchSize = envutil.GetEnvParsedOrDefault(
+		"ORGANIZATION_STORAGE_MIGRATION_BATCH_SIZE", envparse.PositiveNumber, 100,
+	)
+	piiEncryptionKeys = envutil.GetEnvParsedOrDefault("PII_ENCRYPTION_KEYS", parsePIIEncryptionKeys, nil)
+	if len(piiEncryptionKeys) > 0 {
+		piiBlindIndexKey = envutil.RequireEnvParsed("PII_BLIND_INDEX_KEY", base64.StdEncoding.DecodeString)
+	}
+	piiEncryptionCron = envutil.GetEnvOrNil("PII_ENCRYPTION_CRON")
+	piiEncryptionBatchSize = envutil.GetEnvParsedOrDefault("PII_ENCRYPTION_BATCH_SIZE", envparse.PositiveNumber, 500)
+	geoIPDatabasePath = envutil.GetEnvOrNil("GEOIP_DATABASE_PATH")
+	appMetricsMaxSeriesPerDeployment = envutil.GetEnvParsedOrDefault(
+		"APP_METRICS_MAX_SERIES_PER_DEPLOYMENT", envparse.PositiveNumber, 200,
+	)
+	apiV1Sunset = envutil.GetEnvParsedOrNil("API_V1_SUNSET", func(s string) (time.Time, error) {
+		return time.Parse(time.DateOnly, s)
+	})
+	selfCheckBlobSampleSize = envutil.GetEnvParsedOrDefault(
+		"SELF_CHECK_BLOB_SAMPLE_SIZE", envparse.NonNegativeNumber, 20,
+	)
+	selfCheckMaxMissingBlobRatio = envutil.GetEnvParsedOrDefault(
+		"SELF_CHECK_MAX_MISSING_BLOB_RATIO", envparse.Float, 0.1,
+	)
+	consistencyCheckCron = envutil.GetEnvOrNil("CONSISTENCY_CHECK_CRON")
+	consistencyCheckRepair = envutil.GetEnvParsedOrDefault("CONSISTENCY_CHECK_REPAIR", strconv.ParseBool, false)
+	unverifiedUserAccountCleanupCron = envutil.GetEnvOrNil("UNVERIFIED_USER_ACCOUNT_CLEANUP_CRON")
+	unverifiedUserAccountMaxAge = envutil.GetEnvParsedOrDefault(
+		"UNVERIFIED_USER_ACCOUNT_MAX_AGE", envparse.PositiveDuration, 30*24*time.Hour,
+	)
 }
 
 func DatabaseUrl() string {
@@ -144,6 +394,14 @@ func JWTSecret() []byte {
 	return jwtSecret
 }
 
+// SetJWTSecretIfEmpty sets the JWT secret unless it has been initialized already. It is only meant for tests, which do
+// not call Initialize.
+func SetJWTSecretIfEmpty(secret []byte) {
+	if len(jwtSecret) == 0 {
+		jwtSecret = secret
+	}
+}
+
 func Host() string { return host }
 
 func RegistryHost() string { return registryHost }
@@ -152,6 +410,18 @@ func GetMailerConfig() MailerConfig {
 	return mailerConfig
 }
 
+// OrganizationMailerMaxFailures is the number of consecutive failures after which
+// a custom organization mail transport is disabled.
+func OrganizationMailerMaxFailures() int {
+	return organizationMailerMaxFailures
+}
+
+// MailerSESWebhookToken is the token that SNS notifications about SES deliveries, bounces and complaints must
+// provide. The webhook is disabled if it is nil.
+func MailerSESWebhookToken() *string {
+	return mailerSESWebhookToken
+}
+
 func InviteTokenValidDuration() time.Duration {
 	return inviteTokenValidDuration
 }
@@ -240,6 +510,109 @@ func ArtifactTagsDefaultLimitPerOrg() int {
 	return artifactTagsDefaultLimitPerOrg
 }
 
+// ArtifactStorageDefaultQuotaBytesPerOrg is the number of blob bytes that an organization may store in the registry,
+// unless its storage_quota_bytes is set. A value of zero means no limit.
+func ArtifactStorageDefaultQuotaBytesPerOrg() int64 {
+	return artifactStorageDefaultQuotaBytesPerOrg
+}
+
+func RegistryNameMaxDepth() int {
+	return registryNameMaxDepth
+}
+
+func RegistryNameAliasDuration() time.Duration {
+	return registryNameAliasDuration
+}
+
+// RegistryManifestMaxSize is the maximum size of a manifest in bytes that can be pushed to the registry.
+// A value of zero means no limit.
+func RegistryManifestMaxSize() int64 {
+	return int64(registryManifestMaxSize)
+}
+
+// RegistryIndexMaxChildren is the maximum number of manifests in an image index that is pushed to the registry.
+// A value of zero means no limit.
+func RegistryIndexMaxChildren() int {
+	return registryIndexMaxChildren
+}
+
+// RegistryIndexMaxDepth is the maximum nesting depth of image indexes that are pushed to the registry. An index of
+// images has a depth of one. A value of zero means no limit.
+func RegistryIndexMaxDepth() int {
+	return registryIndexMaxDepth
+}
+
+// RegistryIndexMaxDescriptors is the maximum number of descriptors in an image index that is pushed to the registry
+// and all indexes that it references. A value of zero means no limit.
+func RegistryIndexMaxDescriptors() int {
+	return registryIndexMaxDescriptors
+}
+
+// RegistryIndexChildGracePeriod is how long a push of an image index waits for manifests that it references but that
+// have not been pushed yet. Clients like buildx push attestation manifests concurrently with the index that references
+// them. A value of zero rejects such an index right away.
+func RegistryIndexChildGracePeriod() time.Duration {
+	return registryIndexChildGracePeriod
+}
+
+// RegistryManifestCacheTTL is how long the content of a manifest that was read from the registry is kept in memory.
+// A tag that is moved by a push on another instance can be served with its previous content for up to this duration.
+// A value of zero disables the cache, concurrent reads of the same manifest are still coalesced.
+func RegistryManifestCacheTTL() time.Duration {
+	return registryManifestCacheTTL
+}
+
+// RegistryManifestCacheSize is the maximum number of manifests that are kept in memory.
+func RegistryManifestCacheSize() int {
+	return registryManifestCacheSize
+}
+
+// RegistryPullThroughTagTTL is how long a tag of a proxy repository is served from the cache before it is checked
+// against the upstream again. Changes to the upstream of a repository also take effect after at most this duration.
+func RegistryPullThroughTagTTL() time.Duration {
+	return registryPullThroughTagTTL
+}
+
+// RegistryDefaultPlatform is the platform whose manifest is served instead of an image index to clients that do not
+// accept image indexes.
+func RegistryDefaultPlatform() *v1.Platform {
+	return registryDefaultPlatform
+}
+
+// RegistryMetricsAddr is the address of the internal listener that serves the Prometheus metrics of the registry on
+// /metrics. If it is empty, metrics are not served.
+func RegistryMetricsAddr() string {
+	return registryMetricsAddr
+}
+
+// RequestBodyMaxSize is the maximum size of API request bodies in bytes.
+func RequestBodyMaxSize() int64 {
+	return int64(requestBodyMaxSize)
+}
+
+// UploadRequestBodyMaxSize is the maximum size of request bodies in bytes for API routes that accept file uploads.
+func UploadRequestBodyMaxSize() int64 {
+	return int64(uploadRequestBodyMaxSize)
+}
+
+// SentryRequestHeadersAllowList overrides the request headers that are included in Sentry events.
+// If it is nil, the default allow-list is used.
+func SentryRequestHeadersAllowList() []string {
+	return sentryRequestHeadersAllowList
+}
+
+// ScrubFieldPatterns overrides the field name patterns whose values are redacted from logs and Sentry events.
+// If it is nil, the default patterns are used.
+func ScrubFieldPatterns() []string {
+	return scrubFieldPatterns
+}
+
+// ScrubEmailHMACKey is used to pseudonymize email addresses in logs and Sentry events.
+// If it is nil, email addresses are redacted completely.
+func ScrubEmailHMACKey() []byte {
+	return scrubEmailHMACKey
+}
+
 func OtelExporterSentryEnabled() bool {
 	return otelExporterSentryEnabled
 }
@@ -263,3 +636,302 @@ func CleanupDeploymentTargetMetricsCron() *string {
 func CleanupDeploymentLogRecordCron() *string {
 	return cleanupDeploymentLogRecordCron
 }
+
+func CleanupOrphanedFilesCron() *string {
+	return cleanupOrphanedFilesCron
+}
+
+func OrphanedFilesGracePeriod() time.Duration {
+	return orphanedFilesGracePeriod
+}
+
+// CleanupBlobsCron is the schedule of the job that deletes blobs of the platform bucket that are not referenced by any
+// artifact version. Unreferenced blobs are kept if it is nil.
+func CleanupBlobsCron() *string {
+	return cleanupBlobsCron
+}
+
+// BlobGarbageCollectionGracePeriod is the time after its upload during which an unreferenced blob is kept.
+func BlobGarbageCollectionGracePeriod() time.Duration {
+	return blobGarbageCollectionGracePeriod
+}
+
+// CustomerDataExportCron is the schedule of the job that assembles requested customer data exports and deletes
+// expired ones. Requested exports stay pending if it is nil.
+func CustomerDataExportCron() *string {
+	return customerDataExportCron
+}
+
+// BlobGarbageCollectionBatchSize is the maximum number of blobs that are deleted per run.
+func BlobGarbageCollectionBatchSize() int {
+	return blobGarbageCollectionBatchSize
+}
+
+func ArtifactDeletionCron() *string {
+	return artifactDeletionCron
+}
+
+// ArtifactDeletionCoolOff is the time between a deletion request for an artifact and its actual deletion.
+func ArtifactDeletionCoolOff() time.Duration {
+	return artifactDeletionCoolOff
+}
+
+func ApplicationDeletionCron() *string {
+	return applicationDeletionCron
+}
+
+// ApplicationDeletionCoolOff is the time during which a deleted application can be restored before it is purged.
+func ApplicationDeletionCoolOff() time.Duration {
+	return applicationDeletionCoolOff
+}
+
+func CleanupDataPurgeCron() *string {
+	return cleanupDataPurgeCron
+}
+
+// CleanupDataRetentionCron is the schedule of the job that deletes data according to the retention policies of all
+// organizations. Retention policies are not enforced if it is nil.
+func CleanupDataRetentionCron() *string {
+	return cleanupDataRetentionCron
+}
+
+func CleanupAnnouncementCron() *string {
+	return cleanupAnnouncementCron
+}
+
+func ApplicationBadgeRefreshCron() *string {
+	return applicationBadgeRefreshCron
+}
+
+func UpstreamWatchCron() *string {
+	return upstreamWatchCron
+}
+
+// UpstreamWatchInterval is the minimum time between two checks of the same upstream watch.
+func UpstreamWatchInterval() time.Duration {
+	return upstreamWatchInterval
+}
+
+// UpstreamWatchBatchSize is the maximum number of upstream watches that are checked in one job run.
+func UpstreamWatchBatchSize() int {
+	return upstreamWatchBatchSize
+}
+
+// UpstreamWatchRegistryBudget is the maximum number of requests that are sent to a single registry in one job run.
+func UpstreamWatchRegistryBudget() int {
+	return upstreamWatchRegistryBudget
+}
+
+func CertificateCheckCron() *string {
+	return certificateCheckCron
+}
+
+// CertificateCheckInterval is the minimum time between two certificate checks of the same deployment target endpoint.
+func CertificateCheckInterval() time.Duration {
+	return certificateCheckInterval
+}
+
+// CertificateCheckBatchSize is the maximum number of deployment target endpoints that are checked in one job run.
+func CertificateCheckBatchSize() int {
+	return certificateCheckBatchSize
+}
+
+func DeploymentAcknowledgmentReminderCron() *string {
+	return deploymentAckReminderCron
+}
+
+// DeploymentAcknowledgmentReminderInterval is the time after which a customer is reminded of an update that still
+// waits for their acknowledgment.
+func DeploymentAcknowledgmentReminderInterval() time.Duration {
+	return deploymentAckReminderInterval
+}
+
+// DeploymentAcknowledgmentReminderBatchSize is the maximum number of reminders that are sent in one job run.
+func DeploymentAcknowledgmentReminderBatchSize() int {
+	return deploymentAckReminderBatchSize
+}
+
+func VersionEOLNotificationCron() *string {
+	return versionEOLNotificationCron
+}
+
+// VersionEOLNotificationBatchSize is the maximum number of customers that are notified about the end of life of
+// application versions in one job run.
+func VersionEOLNotificationBatchSize() int {
+	return versionEOLNotificationBatchSize
+}
+
+func ArtifactTagRetentionCron() *string {
+	return artifactTagRetentionCron
+}
+
+// ArtifactTagRetentionBatchSize is the maximum number of artifact tag retention rules that are evaluated in one job
+// run.
+func ArtifactTagRetentionBatchSize() int {
+	return artifactTagRetentionBatchSize
+}
+
+func DeploymentAutoRollbackCron() *string {
+	return deploymentAutoRollbackCron
+}
+
+// DeploymentAutoRollbackWindow is how long a deployment must report an error before it is rolled back, if neither the
+// deployment nor its organization defines a window.
+func DeploymentAutoRollbackWindow() time.Duration {
+	return deploymentAutoRollbackWindow
+}
+
+// DeploymentAutoRollbackBatchSize is the maximum number of deployments that are rolled back in one job run.
+func DeploymentAutoRollbackBatchSize() int {
+	return deploymentAutoRollbackBatchSize
+}
+
+func DeploymentPauseResumeCron() *string {
+	return deploymentPauseResumeCron
+}
+
+func DeploymentTargetOutageCron() *string {
+	return deploymentTargetOutageCron
+}
+
+// DeploymentTargetOutageWindow is the time in which deployment targets must have reported their status to count
+// towards a suspected outage.
+func DeploymentTargetOutageWindow() time.Duration {
+	return deploymentTargetOutageWindow
+}
+
+// DeploymentTargetOutageThreshold is the fraction of recently reporting deployment targets of an organization or
+// customer that must be stale for an outage to be suspected.
+func DeploymentTargetOutageThreshold() float64 {
+	return deploymentTargetOutageThreshold
+}
+
+// DeploymentTargetOutageMinTargets is the minimum number of recently reporting deployment targets of an organization
+// or customer for an outage to be suspected.
+func DeploymentTargetOutageMinTargets() int {
+	return deploymentTargetOutageMinTargets
+}
+
+func DeploymentTargetPreRegistrationCron() *string {
+	return deploymentTargetPreRegistrationCron
+}
+
+// DeploymentTargetPairingTokenValidity is the time during which the pairing token in the connect instructions of a
+// pre-registered deployment target can be redeemed.
+func DeploymentTargetPairingTokenValidity() time.Duration {
+	return deploymentTargetPairingTokenValidity
+}
+
+// DeploymentTargetPreRegistrationReminderInterval is the time after which the customer contact of a pre-registered
+// deployment target that has not connected yet is sent the connect instructions again.
+func DeploymentTargetPreRegistrationReminderInterval() time.Duration {
+	return deploymentTargetPreRegReminderInterval
+}
+
+// DeploymentTargetPreRegistrationMaxReminders is the maximum number of reminders for one pre-registered deployment
+// target.
+func DeploymentTargetPreRegistrationMaxReminders() int {
+	return deploymentTargetPreRegMaxReminders
+}
+
+// DeploymentTargetPreRegistrationExpiry is the time after which a pre-registered deployment target that has never
+// connected is deleted.
+func DeploymentTargetPreRegistrationExpiry() time.Duration {
+	return deploymentTargetPreRegExpiry
+}
+
+func AggregateRefreshCron() *string {
+	return aggregateRefreshCron
+}
+
+// AggregateRefreshInterval is the age after which an organization aggregate is recomputed even if no refresh was
+// requested.
+func AggregateRefreshInterval() time.Duration {
+	return aggregateRefreshInterval
+}
+
+// AggregateRefreshBatchSize is the number of organizations whose aggregate is recomputed in one transaction.
+func AggregateRefreshBatchSize() int {
+	return aggregateRefreshBatchSize
+}
+
+func OrganizationStorageMigrationCron() *string {
+	return storageMigrationCron
+}
+
+// OrganizationStorageMigrationBatchSize is the maximum number of blobs that are copied from the platform bucket to the
+// bucket of an organization in one run.
+func OrganizationStorageMigrationBatchSize() int {
+	return storageMigrationBatchSize
+}
+
+// PIIEncryptionKeys are the master keys used to encrypt personally identifiable fields. The first key is used for
+// encryption, the others only for decryption. If it is empty, these fields are stored in plain text.
+func PIIEncryptionKeys() []PIIEncryptionKey {
+	return piiEncryptionKeys
+}
+
+// PIIBlindIndexKey is the key of the blind indexes that allow lookups of encrypted fields. It must never change.
+func PIIBlindIndexKey() []byte {
+	return piiBlindIndexKey
+}
+
+func PIIEncryptionCron() *string {
+	return piiEncryptionCron
+}
+
+// PIIEncryptionBatchSize is the maximum number of user accounts that are encrypted or re-encrypted in one run.
+func PIIEncryptionBatchSize() int {
+	return piiEncryptionBatchSize
+}
+
+// GeoIPDatabasePath is the path of a MaxMind DB file that is used to resolve the country of IP addresses in security
+// events. If it is nil, no country is recorded.
+func GeoIPDatabasePath() *string {
+	return geoIPDatabasePath
+}
+
+// AppMetricsMaxSeriesPerDeployment is the maximum number of application metric series that are stored for a single
+// deployment. Series relayed by an agent beyond this limit are dropped.
+func AppMetricsMaxSeriesPerDeployment() int {
+	return appMetricsMaxSeriesPerDeployment
+}
+
+// APIV1Sunset is the date after which deprecated API v1 routes may be removed. It is announced in the Sunset header of
+// these routes. If it is nil, no sunset is announced.
+func APIV1Sunset() *time.Time {
+	return apiV1Sunset
+}
+
+// SelfCheckBlobSampleSize is the number of recently stored blobs whose existence in the platform bucket is verified by
+// the self-check. A value of zero disables this part of the self-check.
+func SelfCheckBlobSampleSize() int {
+	return selfCheckBlobSampleSize
+}
+
+// SelfCheckMaxMissingBlobRatio is the ratio of sampled blobs that may be missing from the platform bucket before the
+// server reports that it is not ready.
+func SelfCheckMaxMissingBlobRatio() float64 {
+	return selfCheckMaxMissingBlobRatio
+}
+
+func ConsistencyCheckCron() *string {
+	return consistencyCheckCron
+}
+
+// ConsistencyCheckRepair is whether the consistency check repairs the issues it finds instead of only reporting them.
+func ConsistencyCheckRepair() bool {
+	return consistencyCheckRepair
+}
+
+// UnverifiedUserAccountCleanupCron is the schedule of the job that reminds self-registered users to verify their email
+// address and deletes the accounts that are still unverified after UnverifiedUserAccountMaxAge.
+func UnverifiedUserAccountCleanupCron() *string {
+	return unverifiedUserAccountCleanupCron
+}
+
+// UnverifiedUserAccountMaxAge is the time after its creation after which a user account that has not been verified is
+// deleted, so that the email address can be registered again.
+func UnverifiedUserAccountMaxAge() time.Duration {
+	return unverifiedUserAccountMaxAge
+}
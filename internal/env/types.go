@@ -1,8 +1,14 @@
 package env
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/glasskube/distr/internal/envparse"
 )
 
 type RegistrationMode string
@@ -61,3 +67,30 @@ type S3Config struct {
 	UsePathStyle    bool
 	AllowRedirect   bool
 }
+
+type PIIEncryptionKey struct {
+	Version int
+	Secret  []byte
+}
+
+// parsePIIEncryptionKeys parses a comma separated list of keys in the format "<version>:<base64 secret>".
+func parsePIIEncryptionKeys(value string) ([]PIIEncryptionKey, error) {
+	items, _ := envparse.StringList(value)
+	result := make([]PIIEncryptionKey, 0, len(items))
+	for _, item := range items {
+		version, secret, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, errors.New("invalid key: expected <version>:<base64 secret>")
+		}
+		parsedVersion, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key version %q: %w", version, err)
+		}
+		parsedSecret, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret of key %v: %w", parsedVersion, err)
+		}
+		result = append(result, PIIEncryptionKey{Version: parsedVersion, Secret: parsedSecret})
+	}
+	return result, nil
+}
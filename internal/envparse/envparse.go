@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/mail"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +16,14 @@ func PositiveDuration(value string) (time.Duration, error) {
 	return parsed, err
 }
 
+func NonNegativeDuration(value string) (time.Duration, error) {
+	parsed, err := time.ParseDuration(value)
+	if err == nil && parsed < 0 {
+		err = errors.New("duration must not be negative")
+	}
+	return parsed, err
+}
+
 func ByteSlice(s string) ([]byte, error) {
 	return []byte(s), nil
 }
@@ -35,6 +44,34 @@ func NonNegativeNumber(value string) (int, error) {
 	return parsed, err
 }
 
+// NonNegativeInt64 is like NonNegativeNumber for values that may exceed the range of int on 32-bit platforms, like
+// sizes in bytes.
+func NonNegativeInt64(value string) (int64, error) {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err == nil && parsed < 0 {
+		err = errors.New("number must not be negative")
+	}
+	return parsed, err
+}
+
 func Float(value string) (float64, error) {
 	return strconv.ParseFloat(value, 64)
 }
+
+func PositiveNumber(value string) (int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err == nil && parsed <= 0 {
+		err = errors.New("number must be positive")
+	}
+	return parsed, err
+}
+
+func StringList(value string) ([]string, error) {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
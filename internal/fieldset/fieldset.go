@@ -0,0 +1,116 @@
+// Package fieldset implements sparse fieldsets for list endpoints.
+//
+// Clients pass a comma separated list of top-level JSON field names in the fields query parameter and only these
+// fields are included in the response. Queries can use the Set to skip loading data that was not requested.
+package fieldset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const Param = "fields"
+
+var ErrUnknownField = errors.New("unknown field")
+
+// Set contains the requested JSON field names. A nil Set contains all fields.
+type Set map[string]struct{}
+
+// FromRequest parses the fields query parameter. Every name must be a top-level JSON field of T.
+// If the parameter is not present, a nil Set is returned.
+func FromRequest[T any](r *http.Request) (Set, error) {
+	if value := r.URL.Query().Get(Param); value == "" {
+		return nil, nil
+	} else {
+		return Parse[T](value)
+	}
+}
+
+// Parse parses a comma separated list of field names. Every name must be a top-level JSON field of T.
+func Parse[T any](value string) (Set, error) {
+	fields := Fields[T]()
+	result := Set{}
+	for name := range strings.SplitSeq(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		} else if !slices.Contains(fields, name) {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownField, name)
+		}
+		result[name] = struct{}{}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%v must not be empty", Param)
+	}
+	return result, nil
+}
+
+// Has returns true if at least one of names is contained in s.
+func (s Set) Has(names ...string) bool {
+	if s == nil {
+		return true
+	}
+	for _, name := range names {
+		if _, ok := s[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the JSON representation of each item reduced to the fields in s.
+// If s is nil, items is returned unchanged.
+func Apply[T any](s Set, items []T) (any, error) {
+	if s == nil {
+		return items, nil
+	}
+	result := make([]map[string]json.RawMessage, len(items))
+	for i, item := range items {
+		if data, err := json.Marshal(item); err != nil {
+			return nil, err
+		} else if err := json.Unmarshal(data, &result[i]); err != nil {
+			return nil, err
+		}
+		for name := range result[i] {
+			if !s.Has(name) {
+				delete(result[i], name)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Fields returns the names of all top-level JSON fields of T, including the fields of embedded structs.
+func Fields[T any]() []string {
+	return jsonFields(reflect.TypeFor[T]())
+}
+
+func jsonFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var result []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		} else if field.Anonymous && name == "" {
+			result = append(result, jsonFields(field.Type)...)
+		} else if name != "" {
+			result = append(result, name)
+		} else {
+			result = append(result, field.Name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package fieldset_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glasskube/distr/internal/fieldset"
+	. "github.com/onsi/gomega"
+)
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type item struct {
+	base
+	Name     string   `json:"name"`
+	Secret   string   `json:"-"`
+	Tags     []string `json:"tags,omitempty"`
+	Untagged int
+}
+
+func TestFields(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(fieldset.Fields[item]()).To(Equal([]string{"id", "name", "tags", "Untagged"}))
+}
+
+func TestParse(t *testing.T) {
+	g := NewWithT(t)
+	s, err := fieldset.Parse[item]("id, name")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.Has("id")).To(BeTrue())
+	g.Expect(s.Has("tags", "name")).To(BeTrue())
+	g.Expect(s.Has("tags")).To(BeFalse())
+
+	_, err = fieldset.Parse[item]("id,secret")
+	g.Expect(err).To(MatchError(fieldset.ErrUnknownField))
+	_, err = fieldset.Parse[item](",")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestFromRequest(t *testing.T) {
+	g := NewWithT(t)
+	s, err := fieldset.FromRequest[item](httptest.NewRequest("GET", "/", nil))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s).To(BeNil())
+	g.Expect(s.Has("anything")).To(BeTrue())
+
+	s, err = fieldset.FromRequest[item](httptest.NewRequest("GET", "/?fields=name", nil))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s).To(HaveKey("name"))
+
+	_, err = fieldset.FromRequest[item](httptest.NewRequest("GET", "/?fields=unknown", nil))
+	g.Expect(err).To(MatchError(fieldset.ErrUnknownField))
+}
+
+func TestApply(t *testing.T) {
+	g := NewWithT(t)
+	items := []item{{base: base{ID: "1"}, Name: "a", Tags: []string{"x"}}, {base: base{ID: "2"}, Name: "b"}}
+
+	all, err := fieldset.Apply(nil, items)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(all).To(Equal(items))
+
+	reduced, err := fieldset.Apply(fieldset.Set{"id": {}, "tags": {}}, items)
+	g.Expect(err).NotTo(HaveOccurred())
+	data, err := json.Marshal(reduced)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(MatchJSON(`[{"id":"1","tags":["x"]},{"id":"2"}]`))
+}
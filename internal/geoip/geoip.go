@@ -0,0 +1,339 @@
+// Package geoip resolves the country of an IP address using a MaxMind DB file, such as GeoLite2 Country or
+// GeoIP2 City.
+//
+// Only the parts of the MaxMind DB format that are needed for country lookups are implemented, so that no additional
+// dependency is required.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"sync"
+
+	"github.com/glasskube/distr/internal/env"
+)
+
+var (
+	ErrInvalidDatabase = errors.New("invalid MaxMind DB")
+
+	metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+)
+
+const dataSectionSeparatorSize = 16
+
+// Reader looks up IP addresses in a MaxMind DB. It is safe for concurrent use.
+type Reader struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	treeSize   uint
+	ipv4Start  uint
+}
+
+// Open reads the MaxMind DB file at path into memory.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(buf)
+}
+
+// New creates a Reader for a MaxMind DB contained in buf.
+func New(buf []byte) (*Reader, error) {
+	idx := bytes.LastIndex(buf, metadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+	metaStart := idx + len(metadataMarker)
+	meta, _, err := decoder{buf: buf[metaStart:]}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+	}
+	metaMap, ok := meta.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+	r := &Reader{
+		nodeCount:  toUint(metaMap["node_count"]),
+		recordSize: toUint(metaMap["record_size"]),
+		ipVersion:  toUint(metaMap["ip_version"]),
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %v", ErrInvalidDatabase, r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %v", ErrInvalidDatabase, r.ipVersion)
+	}
+	r.treeSize = r.nodeCount * r.recordSize / 4
+	if r.treeSize+dataSectionSeparatorSize > uint(idx) {
+		return nil, fmt.Errorf("%w: search tree exceeds file size", ErrInvalidDatabase)
+	}
+	r.buf = buf[:idx]
+	if r.ipVersion == 6 {
+		// IPv4 addresses are stored in the ::/96 subtree of IPv6 databases
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.readNode(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country of addr. If the database contains no country for addr,
+// an empty string is returned.
+func (r *Reader) Country(addr netip.Addr) (string, error) {
+	record, err := r.lookup(addr.Unmap())
+	if err != nil || record == nil {
+		return "", err
+	}
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := record[key].(map[string]any); ok {
+			if code, ok := country["iso_code"].(string); ok {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func (r *Reader) lookup(addr netip.Addr) (map[string]any, error) {
+	var ip []byte
+	node := uint(0)
+	if addr.Is4() {
+		ip = addr.AsSlice()
+		node = r.ipv4Start
+	} else if r.ipVersion == 6 {
+		ip = addr.AsSlice()
+	} else {
+		return nil, nil
+	}
+	for i := 0; i < len(ip)*8 && node < r.nodeCount; i++ {
+		bit := (ip[i/8] >> (7 - i%8)) & 1
+		node = r.readNode(node, uint(bit))
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	} else if node < r.nodeCount {
+		return nil, fmt.Errorf("%w: search tree is too deep", ErrInvalidDatabase)
+	}
+	offset := node - r.nodeCount - dataSectionSeparatorSize
+	value, _, err := decoder{buf: r.buf[r.treeSize+dataSectionSeparatorSize:]}.decode(offset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+	}
+	record, _ := value.(map[string]any)
+	return record, nil
+}
+
+func (r *Reader) readNode(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.buf[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.buf[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.buf[node*8+bit*4:]))
+	}
+}
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset and returns it together with the offset of the next value.
+func (d decoder) decode(offset uint) (any, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errors.New("offset out of range")
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		} else if pointer < uint(len(d.buf)) && d.buf[pointer]>>5 == typePointer {
+			return nil, 0, errors.New("pointer to pointer")
+		}
+		value, _, err := d.decode(pointer)
+		return value, next, err
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errors.New("offset out of range")
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		b, err := d.bytes(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+		size = 29
+		switch n {
+		case 2:
+			size = 285
+		case 3:
+			size = 65821
+		}
+		size += uintFromBytes(b)
+	}
+	switch typ {
+	case typeMap:
+		return d.decodeMap(offset, size)
+	case typeArray:
+		return d.decodeArray(offset, size)
+	case typeBool:
+		return size != 0, offset, nil
+	}
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes, typeUint128:
+		return b, offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		return uintFromBytes(b), offset, nil
+	case typeInt32:
+		return int32(uintFromBytes(b)), offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %v", typ)
+	}
+}
+
+func (d decoder) decodeMap(offset, size uint) (any, uint, error) {
+	result := make(map[string]any, size)
+	for range size {
+		key, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		keyString, ok := key.(string)
+		if !ok {
+			return nil, 0, errors.New("map key is not a string")
+		}
+		value, next, err := d.decode(next)
+		if err != nil {
+			return nil, 0, err
+		}
+		result[keyString] = value
+		offset = next
+	}
+	return result, offset, nil
+}
+
+func (d decoder) decodeArray(offset, size uint) (any, uint, error) {
+	result := make([]any, 0, size)
+	for range size {
+		value, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, value)
+		offset = next
+	}
+	return result, offset, nil
+}
+
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	value := uint(ctrl & 0x7)
+	switch n {
+	case 1:
+		value = value<<8 | uintFromBytes(b)
+	case 2:
+		value = (value<<16 | uintFromBytes(b)) + 2048
+	case 3:
+		value = (value<<24 | uintFromBytes(b)) + 526336
+	default:
+		value = uintFromBytes(b)
+	}
+	return value, offset + n, nil
+}
+
+func (d decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d.buf)) {
+		return nil, errors.New("value exceeds data section")
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+func uintFromBytes(b []byte) uint {
+	var result uint
+	for _, c := range b {
+		result = result<<8 | uint(c)
+	}
+	return result
+}
+
+func toUint(value any) uint {
+	if v, ok := value.(uint); ok {
+		return v
+	}
+	return 0
+}
+
+var defaultReader = sync.OnceValues(func() (*Reader, error) {
+	if path := env.GeoIPDatabasePath(); path != nil {
+		return Open(*path)
+	}
+	return nil, nil
+})
+
+// Default returns a Reader for the database configured with GEOIP_DATABASE_PATH.
+// It returns nil if no database is configured. The database is only read once.
+func Default() (*Reader, error) {
+	return defaultReader()
+}
@@ -0,0 +1,105 @@
+package geoip_test
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/glasskube/distr/internal/geoip"
+	. "github.com/onsi/gomega"
+)
+
+func encodeString(s string) []byte {
+	return append([]byte{0x40 | byte(len(s))}, s...)
+}
+
+func encodeUint32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32([]byte{0xC4}, v)
+}
+
+// buildDatabase creates a MaxMind DB that maps a single network, given as the bits of its prefix, to a record
+// with the given country.
+func buildDatabase(ipVersion uint32, recordSize uint32, prefix []byte, country string) []byte {
+	nodeCount := uint32(len(prefix))
+	data := []byte{0xE1}
+	data = append(data, encodeString("country")...)
+	data = append(data, 0xE1)
+	data = append(data, encodeString("iso_code")...)
+	data = append(data, encodeString(country)...)
+	dataRecord := nodeCount + 16
+
+	var tree []byte
+	for i, bit := range prefix {
+		records := [2]uint32{nodeCount, nodeCount}
+		if i == len(prefix)-1 {
+			records[bit] = dataRecord
+		} else {
+			records[bit] = uint32(i + 1)
+		}
+		switch recordSize {
+		case 24:
+			for _, r := range records {
+				tree = append(tree, byte(r>>16), byte(r>>8), byte(r))
+			}
+		case 28:
+			tree = append(tree, byte(records[0]>>16), byte(records[0]>>8), byte(records[0]),
+				byte(records[0]>>24)<<4|byte(records[1]>>24), byte(records[1]>>16), byte(records[1]>>8), byte(records[1]))
+		}
+	}
+
+	buf := append(tree, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, "\xAB\xCD\xEFMaxMind.com"...)
+	buf = append(buf, 0xE3)
+	buf = append(buf, encodeString("node_count")...)
+	buf = append(buf, encodeUint32(nodeCount)...)
+	buf = append(buf, encodeString("record_size")...)
+	buf = append(buf, encodeUint32(recordSize)...)
+	buf = append(buf, encodeString("ip_version")...)
+	buf = append(buf, encodeUint32(ipVersion)...)
+	return buf
+}
+
+func bits(prefix ...byte) []byte {
+	var result []byte
+	for _, b := range prefix {
+		for i := 7; i >= 0; i-- {
+			result = append(result, (b>>i)&1)
+		}
+	}
+	return result
+}
+
+func TestCountryIPv4Database(t *testing.T) {
+	g := NewWithT(t)
+	reader, err := geoip.New(buildDatabase(4, 24, bits(10), "AT"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(reader.Country(netip.MustParseAddr("10.1.2.3"))).To(Equal("AT"))
+	g.Expect(reader.Country(netip.MustParseAddr("::ffff:10.1.2.3"))).To(Equal("AT"))
+	g.Expect(reader.Country(netip.MustParseAddr("11.1.2.3"))).To(BeEmpty())
+	g.Expect(reader.Country(netip.MustParseAddr("2001:db8::1"))).To(BeEmpty())
+}
+
+func TestCountryIPv6Database(t *testing.T) {
+	g := NewWithT(t)
+	// IPv4 addresses are located below ::/96
+	prefix := append(make([]byte, 96), bits(192, 168)...)
+	reader, err := geoip.New(buildDatabase(6, 28, prefix, "DE"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(reader.Country(netip.MustParseAddr("192.168.0.1"))).To(Equal("DE"))
+	g.Expect(reader.Country(netip.MustParseAddr("::c0a8:1"))).To(Equal("DE"))
+	g.Expect(reader.Country(netip.MustParseAddr("192.169.0.1"))).To(BeEmpty())
+	g.Expect(reader.Country(netip.MustParseAddr("2001:db8::1"))).To(BeEmpty())
+}
+
+func TestNewInvalidDatabase(t *testing.T) {
+	g := NewWithT(t)
+	_, err := geoip.New([]byte("not a database"))
+	g.Expect(err).To(MatchError(geoip.ErrInvalidDatabase))
+
+	db := buildDatabase(4, 24, bits(10), "AT")
+	_, err = geoip.New(db[len(db)/2:])
+	g.Expect(err).To(MatchError(geoip.ErrInvalidDatabase))
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// errAccessGrantRequired is returned by requireDeploymentTargetAccess if the current user needs an active access grant
+// for the deployment target but has none.
+var errAccessGrantRequired = errors.New("an active access grant is required to access this deployment target")
+
+func AccessGrantsRouter(r chi.Router) {
+	r.Use(
+		middleware.RequireOrgAndRole,
+		requireUserRoleVendor,
+		middleware.FeatureFlagMiddleware(types.FeatureAccessGrants),
+	)
+	r.Get("/", getAccessGrants)
+	r.With(middleware.Transaction).Post("/", createAccessGrant)
+	r.With(middleware.Transaction).Post("/{accessGrantId}/revoke", revokeAccessGrant)
+}
+
+// getAccessGrants lists active and historical access grants. With deploymentTargetId, only the grants that cover
+// this deployment target are returned. With active=true, expired and revoked grants are omitted.
+func getAccessGrants(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	deploymentTargetID, err := QueryParam(r, "deploymentTargetId", uuid.Parse)
+	if err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	active, err := QueryParam(r, "active", strconv.ParseBool)
+	if err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var targetFilter *uuid.UUID
+	if deploymentTargetID != uuid.Nil {
+		targetFilter = &deploymentTargetID
+	}
+	if grants, err := db.GetAccessGrants(ctx, *auth.CurrentOrgID(), targetFilter, active); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get access grants", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, grants)
+	}
+}
+
+// createAccessGrant gives a vendor user temporary access to a deployment target of a customer or to all deployment
+// targets of a customer account. The customer is notified by mail. Vendor users can not grant access to themselves.
+func createAccessGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	orgID := *auth.CurrentOrgID()
+	body, err := JsonBody[api.CreateAccessGrantRequest](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(clock.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.UserAccountID == auth.CurrentUserID() {
+		// a grant must always be given by someone else, so that no vendor user can access customer data on their own
+		http.Error(w, "access grants can not be given to yourself", http.StatusBadRequest)
+		return
+	}
+
+	grantee, err := db.GetUserAccountWithRole(ctx, body.UserAccountID, orgID)
+	if errors.Is(err, apierrors.ErrNotFound) || err == nil && grantee.UserRole != types.UserRoleVendor {
+		http.Error(w, "userAccountId must be a vendor user of the organization", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Error("failed to get user account", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	var target *types.DeploymentTarget
+	var customer types.UserAccount
+	if body.DeploymentTargetID != nil {
+		dt, err := db.GetDeploymentTarget(ctx, *body.DeploymentTargetID, &orgID)
+		if errors.Is(err, apierrors.ErrNotFound) ||
+			err == nil && (dt.CreatedBy == nil || dt.CreatedBy.UserRole != types.UserRoleCustomer) {
+			http.Error(w, "deploymentTargetId must be a deployment target of a customer", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Error("failed to get deployment target", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		target = &dt.DeploymentTarget
+		customer = dt.CreatedBy.AsUserAccount()
+	} else {
+		user, err := db.GetUserAccountWithRole(ctx, *body.CustomerUserAccountID, orgID)
+		if errors.Is(err, apierrors.ErrNotFound) || err == nil && user.UserRole != types.UserRoleCustomer {
+			http.Error(w, "customerUserAccountId must be a customer of the organization", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Error("failed to get user account", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		customer = user.AsUserAccount()
+	}
+
+	grant := types.AccessGrant{
+		OrganizationID:         orgID,
+		UserAccountID:          grantee.ID,
+		DeploymentTargetID:     body.DeploymentTargetID,
+		CustomerUserAccountID:  body.CustomerUserAccountID,
+		GrantedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Reason:                 strings.TrimSpace(body.Reason),
+		ExpiresAt:              body.ExpiresAt,
+	}
+	if err := db.CreateAccessGrant(ctx, &grant); err != nil {
+		log.Error("failed to create access grant", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if err := auditAccessGrant(ctx, "create", grant); err != nil {
+		log.Warn("could not audit access grant", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err := mailsending.SendAccessGrantCreatedMail(
+		ctx, grant, grantee.AsUserAccount(), customer, target,
+	); err != nil {
+		log.Warn("could not send access grant mail", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+	}
+
+	RespondJSON(w, grant)
+}
+
+func revokeAccessGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	id, err := uuid.Parse(r.PathValue("accessGrantId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	grant, err := db.GetAccessGrant(ctx, id, *auth.CurrentOrgID())
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		log.Error("failed to get access grant", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := db.RevokeAccessGrant(ctx, grant, auth.CurrentUserID()); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "the access grant has already expired or been revoked", http.StatusConflict)
+	} else if err != nil {
+		log.Error("failed to revoke access grant", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := auditAccessGrant(ctx, "revoke", *grant); err != nil {
+		log.Warn("could not audit access grant", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, grant)
+	}
+}
+
+func auditAccessGrant(ctx context.Context, action string, grant types.AccessGrant) error {
+	auth := auth.Authentication.Require(ctx)
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "AccessGrant",
+		ResourceID:     grant.ID,
+		Data:           map[string]any{"grant": grant},
+		AccessGrantID:  &grant.ID,
+	})
+}
+
+// requireDeploymentTargetAccess checks whether the current user may access the details, logs and deployments of dt.
+// If the organization has enabled access grants, vendor users need an active grant for deployment targets of
+// customers, otherwise errAccessGrantRequired is returned. Access under a grant is recorded in the audit log and the
+// returned context contains the grant, so that all further audit log entries are tagged with it.
+func requireDeploymentTargetAccess(
+	ctx context.Context,
+	r *http.Request,
+	dt *types.DeploymentTargetWithCreatedBy,
+) (context.Context, error) {
+	auth := auth.Authentication.Require(ctx)
+	if !accessGrantsRequired(ctx) || dt.CreatedBy == nil || dt.CreatedBy.UserRole != types.UserRoleCustomer {
+		return ctx, nil
+	}
+	grant, err := db.GetActiveAccessGrant(ctx, auth.CurrentUserID(), &dt.DeploymentTarget, clock.Now())
+	if errors.Is(err, apierrors.ErrNotFound) {
+		return ctx, errAccessGrantRequired
+	} else if err != nil {
+		return ctx, err
+	}
+	ctx = internalctx.WithAccessGrant(ctx, grant)
+	return ctx, db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "access",
+		ResourceType:   "DeploymentTarget",
+		ResourceID:     dt.ID,
+		Data:           map[string]any{"method": r.Method, "path": r.URL.Path},
+	})
+}
+
+// requireDeploymentAccess is like requireDeploymentTargetAccess for the deployment target of deployment.
+func requireDeploymentAccess(
+	ctx context.Context,
+	r *http.Request,
+	deployment *types.Deployment,
+) (context.Context, error) {
+	if !accessGrantsRequired(ctx) {
+		return ctx, nil
+	}
+	auth := auth.Authentication.Require(ctx)
+	if dt, err := db.GetDeploymentTarget(ctx, deployment.DeploymentTargetID, auth.CurrentOrgID()); err != nil {
+		return ctx, err
+	} else {
+		return requireDeploymentTargetAccess(ctx, r, dt)
+	}
+}
+
+// accessGrantsRequired returns true if the current user needs access grants for deployment targets of customers.
+func accessGrantsRequired(ctx context.Context) bool {
+	auth := auth.Authentication.Require(ctx)
+	return *auth.CurrentUserRole() == types.UserRoleVendor && auth.CurrentOrg().HasFeature(types.FeatureAccessGrants)
+}
+
+// respondDeploymentTargetAccessError writes the response for an error returned by requireDeploymentTargetAccess.
+func respondDeploymentTargetAccessError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errAccessGrantRequired) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	} else {
+		ctx := r.Context()
+		internalctx.GetLogger(ctx).Error("failed to check deployment target access", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// getConsistencyReportHandler responds with the latest report of the consistency check. It only contains the issues
+// of the current organization.
+func getConsistencyReportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	report, err := db.GetLatestConsistencyReport(ctx)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.Error(w, "the consistency check has not run yet", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Error("failed to get consistency report", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report.Issues = slices.DeleteFunc(report.Issues, func(issue types.ConsistencyIssue) bool {
+		return issue.OrganizationID != *auth.CurrentOrgID()
+	})
+	RespondJSON(w, report)
+}
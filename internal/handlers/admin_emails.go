@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func AdminRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor, middleware.RequireOrgAndRole)
+	r.Route("/emails", func(r chi.Router) {
+		r.Get("/", getSentMailsHandler)
+		r.Post("/resend", resendMailHandler)
+		r.Get("/preview/{type}", previewMailHandler)
+	})
+	r.Get("/consistency-report", getConsistencyReportHandler)
+}
+
+func getSentMailsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	page, err := PageParam(r, db.SentMailsPageSpec, 25)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if mails, next, err := db.GetSentMailsPage(ctx, *auth.CurrentOrgID(), page); err != nil {
+		log.Error("failed to get sent mails", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSONPage(w, r, db.SentMailsPageSpec, next, mails)
+	}
+}
+
+func resendMailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	body, err := JsonBody[api.ResendMailRequest](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := db.GetUserAccountWithRole(ctx, body.UserAccountID, *auth.CurrentOrgID())
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Error("failed to get user account", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	org, err := db.GetOrganizationWithBranding(ctx, *auth.CurrentOrgID())
+	if err != nil {
+		log.Error("failed to get organization", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userAccount := user.AsUserAccount()
+	switch body.Type {
+	case types.MailTypeInviteUser, types.MailTypeInviteCustomer:
+		var inviteURL string
+		// users that have already set a password join with their existing account
+		if userAccount.PasswordHash == nil {
+			inviteURL, err = mailsending.InviteURL(userAccount, org.Organization)
+		}
+		if err == nil {
+			err = mailsending.SendUserInviteMail(ctx, userAccount, *org, user.UserRole, inviteURL)
+		}
+	case types.MailTypeVerifyEmail:
+		if userAccount.EmailVerifiedAt != nil {
+			http.Error(w, "email address is already verified", http.StatusBadRequest)
+			return
+		}
+		err = mailsending.SendUserVerificationMail(ctx, userAccount, org.Organization)
+	case types.MailTypePasswordReset:
+		err = mailsending.SendPasswordResetMail(ctx, userAccount, &org.Organization)
+	}
+
+	if err != nil {
+		log.Warn("could not re-send mail", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// previewMailHandler renders a mail as the user given by the userAccountId parameter would receive it.
+// If no user is given, a sample user is used instead.
+func previewMailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	mailType := types.MailType(r.PathValue("type"))
+	if !mailType.IsValid() {
+		http.NotFound(w, r)
+		return
+	}
+
+	userAccount := mailtemplates.SampleUserAccount
+	if userAccountID, err := QueryParam(r, "userAccountId", uuid.Parse); err == nil {
+		user, err := db.GetUserAccountWithRole(ctx, userAccountID, *auth.CurrentOrgID())
+		if errors.Is(err, apierrors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.Error("failed to get user account", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else {
+			userAccount = user.AsUserAccount()
+		}
+	} else if !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	org, err := db.GetOrganizationWithBranding(ctx, *auth.CurrentOrgID())
+	if err != nil {
+		log.Error("failed to get organization", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if tmpl, data, err := mailtemplates.Preview(mailType, userAccount, *org); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := tmpl.Execute(&buf, data); err != nil {
+		log.Error("failed to render mail preview", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
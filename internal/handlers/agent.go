@@ -9,17 +9,24 @@ import (
 	"io"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/api"
 	"github.com/glasskube/distr/internal/agentclient/useragent"
+	"github.com/glasskube/distr/internal/agentimage"
 	"github.com/glasskube/distr/internal/agentmanifest"
 	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/appmetricalerts"
+	"github.com/glasskube/distr/internal/appmetrics"
 	"github.com/glasskube/distr/internal/auth"
 	"github.com/glasskube/distr/internal/authjwt"
+	"github.com/glasskube/distr/internal/buildconfig"
+	"github.com/glasskube/distr/internal/clockskew"
 	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/customdomains"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/middleware"
@@ -33,12 +40,30 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// These errors are sent to agents with status 410 Gone and the matching api.AgentGoneCodeArchived or
+// api.AgentGoneCodeDeleted.
+// Agents treat this as terminal and stop polling.
+var (
+	errDeploymentTargetArchived = errors.New("deployment target is archived")
+	errDeploymentTargetDeleted  = errors.New("deployment target has been deleted")
+)
+
+// These errors are sent with status 403 Forbidden to agents that report data which the data collection policy of their
+// deployment target does not allow. The data is discarded.
+var (
+	errLogsCollectionDisabled        = errors.New("logs collection is disabled for this deployment target")
+	errMetricsCollectionDisabled     = errors.New("metrics collection is disabled for this deployment target")
+	errDiagnosticsCollectionDisabled = errors.New("diagnostics collection is disabled for this deployment target")
+	errInventoryCollectionDisabled   = errors.New("inventory collection is disabled for this deployment target")
+)
+
 func AgentRouter(r chi.Router) {
 	r.With(
 		queryAuthDeploymentTargetCtxMiddleware,
 	).Group(func(r chi.Router) {
 		r.Get("/connect", connectHandler())
 	})
+	r.Get("/connect/pairing", pairingConnectHandler)
 	r.Route("/agent", func(r chi.Router) {
 		// agent login (from basic auth to token)
 		r.Post("/login", agentLoginHandler)
@@ -48,6 +73,7 @@ func AgentRouter(r chi.Router) {
 			middleware.AgentSentryUser,
 			agentAuthDeploymentTargetCtxMiddleware,
 			rateLimitPerAgent,
+			middleware.ReadOnlyDuringMaintenance,
 		).Group(func(r chi.Router) {
 			// agent routes, authenticated via token
 			r.Get("/manifest", agentManifestHandler())
@@ -55,6 +81,12 @@ func AgentRouter(r chi.Router) {
 			r.Post("/status", angentPostStatusHandler)
 			r.Post("/metrics", agentPostMetricsHander)
 			r.Put("/logs", agentPutDeploymentLogsHandler())
+			r.Post("/connectivity", agentPostConnectivityHandler)
+			r.With(middleware.RequestBodyLimit(env.UploadRequestBodyMaxSize())).
+				Post("/file-retrievals", agentPostFileRetrievalHandler)
+			r.Post("/inventory", agentPostInventoryHandler)
+			r.Post("/resource-limits", agentPostResourceLimitsHandler)
+			r.Post("/app-metrics", agentPostAppMetricsHandler)
 		})
 	})
 }
@@ -65,6 +97,11 @@ func connectHandler() http.HandlerFunc {
 		log := internalctx.GetLogger(ctx)
 		deploymentTarget := internalctx.GetDeploymentTarget(ctx)
 
+		if deploymentTarget.ArchivedAt != nil {
+			respondDeploymentTargetGone(w, api.AgentGoneCodeArchived, errDeploymentTargetArchived)
+			return
+		}
+
 		if deploymentTarget.CurrentStatus != nil &&
 			deploymentTarget.CurrentStatus.CreatedAt.Add(2*env.AgentInterval()).After(time.Now()) {
 			http.Error(
@@ -85,8 +122,18 @@ func connectHandler() http.HandlerFunc {
 			return
 		}
 
+		platform := deploymentTarget.ReportedAgentPlatform
+		if s := r.URL.Query().Get("platform"); s != "" {
+			if parsed, err := agentimage.ParsePlatform(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else {
+				platform = &parsed
+			}
+		}
+
 		secret := r.URL.Query().Get("targetSecret")
-		if manifest, err := agentmanifest.Get(ctx, *deploymentTarget, *org, &secret); err != nil {
+		if manifest, err := agentmanifest.Get(ctx, *deploymentTarget, *org, &secret, platform); err != nil {
 			log.Error("could not get agent manifest", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -99,6 +146,94 @@ func connectHandler() http.HandlerFunc {
 	}
 }
 
+// errPairingTokenInvalid is returned if a pairing token is wrong, expired or has already been redeemed.
+var errPairingTokenInvalid = errors.New("pairing token is invalid")
+
+// pairingConnectHandler responds with the agent manifest of a pre-registered deployment target in exchange for its
+// single-use pairing token. Redeeming the token generates a new access key, which is contained in the manifest.
+func pairingConnectHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	targetID, err := uuid.Parse(r.URL.Query().Get("targetId"))
+	if err != nil {
+		http.Error(w, "targetId is not a valid UUID", http.StatusBadRequest)
+		return
+	} else if agentConnectPerTargetIdRateLimiter.RespondOnLimit(w, r, targetID.String()) {
+		return
+	}
+
+	var platform *string
+	if s := r.URL.Query().Get("platform"); s != "" {
+		if parsed, err := agentimage.ParsePlatform(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else {
+			platform = &parsed
+		}
+	}
+
+	var manifest bytes.Buffer
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		preRegistration, err := db.GetDeploymentTargetPreRegistration(ctx, targetID)
+		if errors.Is(err, apierrors.ErrNotFound) {
+			return errPairingTokenInvalid
+		} else if err != nil {
+			return err
+		} else if preRegistration.PairingTokenSalt == nil || preRegistration.PairingTokenHash == nil ||
+			preRegistration.PairingTokenExpiresAt.Before(time.Now()) {
+			return errPairingTokenInvalid
+		} else if err := security.VerifyAccessKey(
+			*preRegistration.PairingTokenSalt, *preRegistration.PairingTokenHash, r.URL.Query().Get("pairingToken"),
+		); err != nil {
+			return errPairingTokenInvalid
+		} else if err := db.RedeemDeploymentTargetPairingToken(
+			ctx, targetID, *preRegistration.PairingTokenHash,
+		); errors.Is(err, apierrors.ErrNotFound) {
+			return errPairingTokenInvalid
+		} else if err != nil {
+			return err
+		}
+
+		deploymentTarget, err := db.GetDeploymentTarget(ctx, targetID, nil)
+		if err != nil {
+			return err
+		} else if deploymentTarget.ArchivedAt != nil {
+			return errDeploymentTargetArchived
+		}
+		org, err := db.GetOrganizationByID(ctx, deploymentTarget.OrganizationID)
+		if err != nil {
+			return err
+		}
+		// a copy is updated, because the access update does not return the agent version that the manifest needs
+		target := deploymentTarget.DeploymentTarget
+		if access, err := generateDeploymentTargetAccess(ctx, &target, *org); err != nil {
+			return err
+		} else if reader, err := agentmanifest.Get(
+			ctx, *deploymentTarget, *org, &access.TargetSecret, platform,
+		); err != nil {
+			return err
+		} else {
+			_, err := io.Copy(&manifest, reader)
+			return err
+		}
+	})
+
+	if errors.Is(err, errPairingTokenInvalid) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	} else if errors.Is(err, errDeploymentTargetArchived) {
+		respondDeploymentTargetGone(w, api.AgentGoneCodeArchived, errDeploymentTargetArchived)
+	} else if err != nil {
+		log.Error("could not redeem pairing token", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		w.Header().Add("Content-Type", "application/yaml")
+		if _, err := io.Copy(w, &manifest); err != nil {
+			log.Warn("writing to client failed", zap.Error(err))
+		}
+	}
+}
+
 func agentLoginHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
@@ -113,6 +248,8 @@ func agentLoginHandler(w http.ResponseWriter, r *http.Request) {
 	} else if deploymentTarget, err := getVerifiedDeploymentTarget(ctx, parsedTargetId, targetSecret); err != nil {
 		log.Error("failed to get deployment target from query auth", zap.Error(err))
 		w.WriteHeader(http.StatusUnauthorized)
+	} else if deploymentTarget.ArchivedAt != nil {
+		respondDeploymentTargetGone(w, api.AgentGoneCodeArchived, errDeploymentTargetArchived)
 	} else {
 		// TODO maybe even randomize token valid duration
 		if _, token, err := authjwt.GenerateAgentTokenValidFor(
@@ -129,128 +266,261 @@ func agentLoginHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func agentResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	receivedAt := time.Now()
 	ctx := r.Context()
 	deploymentTarget := internalctx.GetDeploymentTarget(ctx)
 	log := internalctx.GetLogger(ctx).With(zap.String("deploymentTargetId", deploymentTarget.ID.String()))
 
+	maintenanceMode := internalctx.GetMaintenanceState(ctx).For(&deploymentTarget.OrganizationID)
+
 	statusMessage := "OK"
-	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, deploymentTarget.ID)
-	if err != nil {
-		msg := "failed to get latest Deployment from DB"
-		log.Error(msg, zap.Error(err))
-		statusMessage = fmt.Sprintf("%v: %v", msg, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if maintenanceMode == nil && isAgentResourceUnchanged(ctx, r, deploymentTarget, receivedAt) {
+		// nothing that the resource is built from has changed since the agent received it
+		w.Header().Set("ETag", agentResourceETag(deploymentTarget, receivedAt))
+		w.WriteHeader(http.StatusNotModified)
 	} else {
-		agentResource := api.AgentResource{
-			Version:        deploymentTarget.AgentVersion,
-			MetricsEnabled: deploymentTarget.MetricsEnabled,
-		}
-		if deploymentTarget.Namespace != nil {
-			agentResource.Namespace = *deploymentTarget.Namespace
-		}
-
-		for _, deployment := range deployments {
-			appVersion, err := db.GetApplicationVersion(ctx, deployment.ApplicationVersionID)
-			if err != nil {
-				msg := "failed to get ApplicationVersion from DB"
-				log.Error(msg, zap.Error(err))
-				statusMessage = fmt.Sprintf("%v: %v", msg, err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				break
+		var registryURLs []string
+		// archived deployments are sent as paused, so that the agent keeps them as they are instead of uninstalling them
+		deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, deploymentTarget.ID, true)
+		if err == nil {
+			// agents apply deployments in order, so dependencies are installed and updated before their dependents
+			deployments, err = sortDeploymentsByDependencies(ctx, deploymentTarget.OrganizationID, deployments)
+		}
+		if err != nil {
+			msg := "failed to get latest Deployment from DB"
+			log.Error(msg, zap.Error(err))
+			statusMessage = fmt.Sprintf("%v: %v", msg, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			agentResource := api.AgentResource{
+				Version:          deploymentTarget.AgentVersion,
+				MetricsEnabled:   deploymentTarget.MetricsEnabled,
+				InventoryEnabled: deploymentTarget.InventoryEnabled,
+				DataCollection:   deploymentTarget.DataCollection,
+				ResourceLimits:   deploymentTarget.EffectiveAgentResourceLimits,
 			}
-
-			agentDeployment := api.AgentDeployment{
-				ID:          deployment.ID,
-				RevisionID:  deployment.DeploymentRevisionID,
-				LogsEnabled: deployment.LogsEnabled,
+			if deploymentTarget.Namespace != nil {
+				agentResource.Namespace = *deploymentTarget.Namespace
 			}
 
-			if deployment.ApplicationLicenseID != nil {
-				if license, err := db.GetApplicationLicenseByID(ctx, *deployment.ApplicationLicenseID); err != nil {
-					msg := "failed to get ApplicationLicense from DB"
+			for _, deployment := range deployments {
+				appVersion, err := db.GetApplicationVersion(ctx, deployment.ApplicationVersionID)
+				if err != nil {
+					msg := "failed to get ApplicationVersion from DB"
 					log.Error(msg, zap.Error(err))
 					statusMessage = fmt.Sprintf("%v: %v", msg, err)
 					http.Error(w, err.Error(), http.StatusInternalServerError)
 					break
-				} else if license.RegistryURL != nil {
-					agentDeployment.RegistryAuth = map[string]api.AgentRegistryAuth{
-						*license.RegistryURL: {
-							Username: *license.RegistryUsername,
-							Password: *license.RegistryPassword,
-						},
-					}
 				}
-			}
 
-			if deploymentTarget.Type == types.DeploymentTypeDocker {
-				if composeYaml, err := appVersion.ParsedComposeFile(); err != nil {
-					log.Warn("parse error", zap.Error(err))
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				} else if patchedComposeFile, err := patchProjectName(composeYaml, deployment.ID); err != nil {
-					log.Warn("failed to patch project name", zap.Error(err))
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				} else {
-					agentDeployment.ComposeFile = patchedComposeFile
-					agentDeployment.EnvFile = deployment.EnvFileData
-					agentDeployment.DockerType = util.PtrCopy(deployment.DockerType)
+				agentDeployment := api.AgentDeployment{
+					ID:              deployment.ID,
+					RevisionID:      deployment.DeploymentRevisionID,
+					OperationID:     deployment.DeploymentRevisionOperationID,
+					LogsEnabled:     deployment.LogsEnabled,
+					MetricsEndpoint: appVersion.MetricsEndpoint,
+					Paused:          deployment.Paused || deployment.ArchivedAt != nil,
 				}
-			} else {
-				agentDeployment.ReleaseName = *deployment.ReleaseName
-				agentDeployment.ChartUrl = *appVersion.ChartUrl
-				agentDeployment.ChartVersion = *appVersion.ChartVersion
-				if versionValues, err := appVersion.ParsedValuesFile(); err != nil {
-					log.Warn("parse error", zap.Error(err))
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				} else if deploymentValues, err := deployment.ParsedValuesFile(); err != nil {
-					log.Warn("parse error", zap.Error(err))
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				} else if merged, err := util.MergeAllRecursive(versionValues, deploymentValues); err != nil {
-					log.Warn("merge error", zap.Error(err))
-					http.Error(w, fmt.Sprintf("error merging values files: %v", err), http.StatusInternalServerError)
-					return
+				if deployment.UninstallRequestedAt != nil {
+					agentDeployment.Uninstall = &api.AgentDeploymentUninstall{DeleteData: deployment.UninstallDeleteData}
+				}
+
+				if deployment.ApplicationLicenseID != nil {
+					if license, err := db.GetApplicationLicenseByID(ctx, *deployment.ApplicationLicenseID); err != nil {
+						msg := "failed to get ApplicationLicense from DB"
+						log.Error(msg, zap.Error(err))
+						statusMessage = fmt.Sprintf("%v: %v", msg, err)
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						break
+					} else if license.RegistryURL != nil {
+						registryURLs = append(registryURLs, *license.RegistryURL)
+						agentDeployment.RegistryAuth = map[string]api.AgentRegistryAuth{
+							*license.RegistryURL: {
+								Username: *license.RegistryUsername,
+								Password: *license.RegistryPassword,
+							},
+						}
+					}
+				}
+
+				if deploymentTarget.Type == types.DeploymentTypeDocker {
+					if composeYaml, err := appVersion.ParsedComposeFile(); err != nil {
+						log.Warn("parse error", zap.Error(err))
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					} else if patchedComposeFile, err := patchProjectName(composeYaml, deployment.ID); err != nil {
+						log.Warn("failed to patch project name", zap.Error(err))
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					} else {
+						agentDeployment.ComposeFile = patchedComposeFile
+						agentDeployment.EnvFile = deployment.EnvFileData
+						agentDeployment.DockerType = util.PtrCopy(deployment.DockerType)
+					}
 				} else {
-					agentDeployment.Values = merged
+					agentDeployment.ReleaseName = *deployment.ReleaseName
+					agentDeployment.ChartUrl = *appVersion.ChartUrl
+					agentDeployment.ChartVersion = *appVersion.ChartVersion
+					if versionValues, err := appVersion.ParsedValuesFile(); err != nil {
+						log.Warn("parse error", zap.Error(err))
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					} else if deploymentValues, err := deployment.ParsedValuesFile(); err != nil {
+						log.Warn("parse error", zap.Error(err))
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					} else if merged, err := util.MergeAllRecursive(versionValues, deploymentValues); err != nil {
+						log.Warn("merge error", zap.Error(err))
+						http.Error(w, fmt.Sprintf("error merging values files: %v", err), http.StatusInternalServerError)
+						return
+					} else {
+						agentDeployment.Values = merged
+					}
+					if *appVersion.ChartType == types.HelmChartTypeRepository {
+						agentDeployment.ChartName = *appVersion.ChartName
+					}
 				}
-				if *appVersion.ChartType == types.HelmChartTypeRepository {
-					agentDeployment.ChartName = *appVersion.ChartName
+				agentResource.Deployments = append(agentResource.Deployments, agentDeployment)
+
+				// Set the Deployment property to the first (i.e. oldest) deployment for backwards compatibility
+				//nolint:staticcheck
+				if agentResource.Deployment == nil {
+					agentResource.Deployment = &agentDeployment
 				}
 			}
-			agentResource.Deployments = append(agentResource.Deployments, agentDeployment)
 
-			// Set the Deployment property to the first (i.e. oldest) deployment for backwards compatibility
-			//nolint:staticcheck
-			if agentResource.Deployment == nil {
-				agentResource.Deployment = &agentDeployment
+			if statusMessage == "OK" {
+				if !deploymentTarget.DataCollection.DiagnosticsDisabled {
+					agentResource.ConnectivityCheck = getPendingAgentConnectivityCheck(ctx, deploymentTarget, registryURLs)
+					agentResource.FileRetrievals = getPendingAgentFileRetrievals(ctx, deploymentTarget)
+				}
+				// the resource can only be cached by the agent if it is complete and not held
+				cacheable := maintenanceMode == nil
+				if announcements, err := db.GetActiveDeploymentTargetAnnouncements(
+					ctx, deploymentTarget.ID, receivedAt,
+				); err != nil {
+					log.Warn("failed to get announcements", zap.Error(err))
+					cacheable = false
+				} else if len(announcements) > 0 {
+					agentResource.Announcements = api.AsAgentAnnouncements(announcements)
+				}
+				if deploymentTarget.MigrationConnectURL != nil {
+					agentResource.Migration = &api.AgentMigration{ConnectURL: *deploymentTarget.MigrationConnectURL}
+				}
+				// The complete resource is still sent, because agents that do not support holding would otherwise
+				// uninstall all deployments.
+				if maintenanceMode != nil {
+					agentResource.Hold = &api.AgentHold{
+						Reason:            maintenanceMode.Reason,
+						RetryAfterSeconds: maintenanceMode.RetryAfterSeconds,
+					}
+				}
+				agentResource.ApplyDataCollection()
+				if cacheable {
+					w.Header().Set("ETag", agentResourceETag(deploymentTarget, receivedAt))
+				}
+				RespondJSON(w, agentResource)
 			}
 		}
+	}
 
-		if statusMessage == "OK" {
-			RespondJSON(w, agentResource)
-		}
+	if maintenanceMode != nil {
+		// no status is recorded, so that the database is not written to during maintenance
+		return
 	}
 
+	updateAgentClockSkew(ctx, r, deploymentTarget, receivedAt)
+
 	// not in a TX because insertion should not be rolled back when the cleanup fails
 	if err := db.CreateDeploymentTargetStatus(ctx, &deploymentTarget.DeploymentTarget, statusMessage); err != nil {
 		log.Error("failed to create deployment target status – skipping cleanup of old statuses", zap.Error(err),
 			zap.String("deploymentTargetId", deploymentTarget.ID.String()),
 			zap.String("statusMessage", statusMessage))
+	} else if deploymentTarget.AwaitingConnection {
+		if err := db.MarkDeploymentTargetPreRegistrationConnected(ctx, deploymentTarget.ID); err != nil {
+			log.Warn("failed to mark pre-registered deployment target as connected", zap.Error(err),
+				zap.String("deploymentTargetId", deploymentTarget.ID.String()))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}
+}
+
+// agentResourceETag identifies the agent resource of dt that has been built at builtAt. Besides the resource version,
+// it contains the build time, so that changes that only depend on the time, like the start of an announcement, can be
+// detected, and the server version, so that agents get the resource in the format of a new version after an update.
+func agentResourceETag(dt *types.DeploymentTargetWithCreatedBy, builtAt time.Time) string {
+	return fmt.Sprintf(`"%v.%v.%v"`, dt.ResourceVersion, builtAt.UnixMilli(), buildconfig.Version())
+}
+
+// isAgentResourceUnchanged reports whether the agent resource of dt that the agent has according to the If-None-Match
+// header of r is still current at now. The deployments are not read to find out.
+func isAgentResourceUnchanged(
+	ctx context.Context,
+	r *http.Request,
+	dt *types.DeploymentTargetWithCreatedBy,
+	now time.Time,
+) bool {
+	parts := strings.SplitN(strings.Trim(r.Header.Get("If-None-Match"), `"`), ".", 3)
+	if len(parts) != 3 || parts[2] != buildconfig.Version() {
+		return false
+	} else if version, err := strconv.ParseInt(parts[0], 10, 64); err != nil || version != dt.ResourceVersion {
+		return false
+	} else if builtAt, err := strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return false
+	} else if changed, err := db.HasDeploymentTargetScheduledResourceChange(
+		ctx, dt.ID, time.UnixMilli(builtAt), now, connectivityCheckMaxAge, fileRetrievalMaxAge,
+	); err != nil {
+		internalctx.GetLogger(ctx).Warn("failed to check for scheduled resource changes", zap.Error(err))
+		return false
+	} else {
+		return !changed
+	}
+}
+
+// updateAgentClockSkew updates the clock skew estimate of a deployment target with the time reported by its agent.
+// Agents that do not report their time are ignored.
+func updateAgentClockSkew(
+	ctx context.Context,
+	r *http.Request,
+	dt *types.DeploymentTargetWithCreatedBy,
+	receivedAt time.Time,
+) {
+	log := internalctx.GetLogger(ctx)
+	header := r.Header.Get(useragent.DistrAgentTimeHeader)
+	if header == "" {
+		return
+	}
+	agentTime, err := time.Parse(time.RFC3339Nano, header)
+	if err != nil {
+		log.Warn("agent reported invalid time", zap.Error(err))
+		return
+	}
+	previous := dt.ClockSkew()
+	skew := clockskew.Estimate(previous, dt.ClockSkewMeasuredAt, clockskew.Sample(agentTime, receivedAt), receivedAt)
+	if clockskew.Exceeded(skew) && (previous == nil || !clockskew.Exceeded(*previous)) {
+		log.Warn("agent clock skew exceeds threshold",
+			zap.Stringer("deploymentTargetId", dt.ID), zap.Duration("skew", skew))
+	}
+	if err := db.UpdateDeploymentTargetClockSkew(ctx, dt, skew, receivedAt); err != nil {
+		log.Error("could not update clock skew", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
 	}
 }
 
 func agentPutDeploymentLogsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		receivedAt := time.Now()
 		ctx := r.Context()
 		log := internalctx.GetLogger(ctx)
 		auth := auth.AgentAuthentication.Require(ctx)
 		records, err := JsonBody[[]api.DeploymentLogRecord](w, r)
 		if err != nil {
 			return
+		} else if internalctx.GetDeploymentTarget(ctx).DataCollection.LogsDisabled {
+			http.Error(w, errLogsCollectionDisabled.Error(), http.StatusForbidden)
+			return
 		}
-		deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, auth.CurrentDeploymentTargetID())
+		deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, auth.CurrentDeploymentTargetID(), true)
 		if err != nil {
 			log.Error("error getting deployments", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
@@ -267,6 +537,14 @@ func agentPutDeploymentLogsHandler() http.HandlerFunc {
 			http.Error(w, fmt.Sprintf("invalid deploymentId at index %v", errIdx), http.StatusBadRequest)
 			return
 		}
+		// timestamps of agents with a skewed clock are corrected, so that logs can be compared to server events
+		var skew time.Duration
+		if s := internalctx.GetDeploymentTarget(ctx).ClockSkew(); s != nil {
+			skew = *s
+		}
+		for i := range records {
+			records[i].Timestamp = clockskew.ServerTime(records[i].Timestamp, skew, receivedAt)
+		}
 		if err := db.SaveDeploymentLogRecords(ctx, records); err != nil {
 			log.Error("error saving log records", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
@@ -297,7 +575,15 @@ func angentPostStatusHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
-	if err := db.CreateDeploymentRevisionStatus(ctx, status.RevisionID, status.Type, status.Message); err != nil {
+	if len(status.PullProgress) > 0 {
+		// progress is best effort, so failing to save it must not prevent the status from being saved
+		if err := db.UpsertDeploymentRevisionPullProgress(ctx, status.RevisionID, status.PullProgress); err != nil {
+			log.Warn("failed to save pull progress", zap.Error(err))
+		}
+	}
+	if err := db.CreateDeploymentRevisionStatus(
+		ctx, status.RevisionID, status.OperationID, status.Type, status.Message, status.ErrorReason,
+	); err != nil {
 		if errors.Is(err, apierrors.ErrConflict) {
 			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		} else {
@@ -306,6 +592,13 @@ func angentPostStatusHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
+	} else if status.Type == types.DeploymentStatusTypeUninstalled {
+		if err := db.MarkDeploymentUninstalled(ctx, status.RevisionID); err != nil {
+			log.Error("failed to mark deployment as uninstalled", zap.Error(err), zap.Reflect("status", status))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
@@ -320,6 +613,9 @@ func agentPostMetricsHander(w http.ResponseWriter, r *http.Request) {
 	metrics, err := JsonBody[api.AgentDeploymentTargetMetrics](w, r)
 	if err != nil {
 		return
+	} else if dt.DataCollection.MetricsDisabled {
+		http.Error(w, errMetricsCollectionDisabled.Error(), http.StatusForbidden)
+		return
 	}
 	if err := db.CreateDeploymentTargetMetrics(ctx, &dt.DeploymentTarget, &metrics); err != nil {
 		if errors.Is(err, apierrors.ErrConflict) {
@@ -335,6 +631,304 @@ func agentPostMetricsHander(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func agentPostConnectivityHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+
+	report, err := JsonBody[api.AgentConnectivityReport](w, r)
+	if err != nil {
+		return
+	} else if dt.DataCollection.DiagnosticsDisabled {
+		http.Error(w, errDiagnosticsCollectionDisabled.Error(), http.StatusForbidden)
+		return
+	}
+	if err := db.ReportDeploymentTargetConnectivityCheck(ctx, dt.ID, report.CheckID, report.Results); errors.Is(
+		err, apierrors.ErrNotFound) {
+		http.Error(w, "connectivity check does not exist or has already been reported", http.StatusBadRequest)
+	} else if err != nil {
+		log.Error("failed to save connectivity check results", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// agentPostFileRetrievalHandler stores the result of a file retrieval. The content has been redacted by the agent
+// with the default rules and is redacted again with the custom rules of the organization before it is stored.
+func agentPostFileRetrievalHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+
+	report, err := JsonBody[api.AgentFileRetrievalReport](w, r)
+	if err != nil {
+		return
+	} else if err := report.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if dt.DataCollection.DiagnosticsDisabled {
+		http.Error(w, errDiagnosticsCollectionDisabled.Error(), http.StatusForbidden)
+		return
+	}
+
+	result := types.DeploymentTargetFileRetrievalWithContent{
+		DeploymentTargetFileRetrieval: types.DeploymentTargetFileRetrieval{
+			ID:                 report.RetrievalID,
+			DeploymentTargetID: dt.ID,
+			Status:             report.Status,
+		},
+	}
+	if report.Error != "" {
+		result.Error = &report.Error
+	}
+	if report.Status == types.FileRetrievalStatusCompleted {
+		scanner, _, err := getSecretScanner(ctx, dt.OrganizationID)
+		if err != nil {
+			log.Error("failed to get secret scanner", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		// secrets found by the default rules have already been redacted, so only custom rules can find more
+		result.RedactedSecrets = util.PtrTo(report.RedactedSecrets + len(scanner.Scan("", report.Content)))
+		result.Content = scanner.Redact(report.Content)
+		result.Size = &report.Size
+	}
+
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.ReportDeploymentTargetFileRetrieval(ctx, &result, fileRetrievalMaxAge); err != nil {
+			return err
+		}
+		return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+			OrganizationID: &dt.OrganizationID,
+			Action:         "report_file_retrieval",
+			ResourceType:   "DeploymentTarget",
+			ResourceID:     dt.ID,
+			Data: map[string]any{
+				"fileRetrievalId": result.ID,
+				"path":            result.Path,
+				"status":          result.Status,
+				"error":           result.Error,
+			},
+		})
+	}); errors.Is(err, apierrors.ErrNotFound) {
+		http.Error(w, "file retrieval does not exist or has already been reported", http.StatusBadRequest)
+	} else if err != nil {
+		log.Error("failed to save file retrieval result", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// agentPostInventoryHandler replaces the inventory snapshot of the deployment target. Reports with more than
+// types.MaxInventoryItems items are truncated.
+func agentPostInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+
+	report, err := JsonBody[api.AgentInventoryReport](w, r)
+	if err != nil {
+		return
+	} else if !dt.InventoryEnabled || dt.DataCollection.InventoryDisabled {
+		http.Error(w, errInventoryCollectionDisabled.Error(), http.StatusForbidden)
+		return
+	}
+	inventory := types.DeploymentTargetInventory{
+		DeploymentTargetID: dt.ID,
+		Items:              report.Items,
+		Truncated:          report.Truncated,
+	}
+	if inventory.Items == nil {
+		inventory.Items = []types.InventoryItem{}
+	} else if len(inventory.Items) > types.MaxInventoryItems {
+		inventory.Items = inventory.Items[:types.MaxInventoryItems]
+		inventory.Truncated = true
+	}
+	if err := db.SaveDeploymentTargetInventory(ctx, &inventory); err != nil {
+		log.Error("failed to save inventory", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// agentPostResourceLimitsHandler saves the resource limits that the agent has applied, so that they can be compared
+// with the requested limits. Unknown names of unsupported limits are dropped.
+func agentPostResourceLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+
+	report, err := JsonBody[api.AgentResourceLimitsReport](w, r)
+	if err != nil {
+		return
+	}
+	unsupported := make([]string, 0, len(report.Unsupported))
+	for _, name := range report.Unsupported {
+		switch name {
+		case types.AgentResourceLimitMaxConcurrentPulls, types.AgentResourceLimitMaxDownloadBytesPerSecond,
+			types.AgentResourceLimitMaxProcs, types.AgentResourceLimitNiceLevel, types.AgentResourceLimitLogLevel:
+			if !slices.Contains(unsupported, name) {
+				unsupported = append(unsupported, name)
+			}
+		}
+	}
+	if err := db.UpdateDeploymentTargetAppliedAgentResourceLimits(ctx, dt, report.Applied, unsupported); err != nil {
+		log.Error("failed to save applied resource limits", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// agentPostAppMetricsHandler stores the application metrics relayed by an agent and evaluates the alert rules of the
+// application. Only series allowed by the current version of the deployment are stored, up to the configured maximum.
+func agentPostAppMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+
+	report, err := JsonBody[api.AgentAppMetricsReport](w, r)
+	if err != nil {
+		return
+	} else if dt.DataCollection.MetricsDisabled {
+		http.Error(w, errMetricsCollectionDisabled.Error(), http.StatusForbidden)
+		return
+	}
+	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, dt.ID, true)
+	if err != nil {
+		log.Error("error getting deployments", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	idx := slices.IndexFunc(deployments, func(d types.DeploymentWithLatestRevision) bool {
+		return d.ID == report.DeploymentID
+	})
+	if idx < 0 {
+		http.Error(w, "invalid deploymentId", http.StatusBadRequest)
+		return
+	}
+	deployment := deployments[idx]
+	version, err := db.GetApplicationVersion(ctx, deployment.ApplicationVersionID)
+	if err != nil {
+		log.Error("failed to get ApplicationVersion", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if version.MetricsEndpoint == nil {
+		http.Error(w, "application version does not declare a metrics endpoint", http.StatusBadRequest)
+		return
+	}
+
+	limit := env.AppMetricsMaxSeriesPerDeployment()
+	series := appmetrics.Filter(report.Series, *version.MetricsEndpoint, limit)
+	if len(series) == limit && len(report.Series) > limit {
+		log.Warn("app metrics exceed the maximum number of series and have been truncated",
+			zap.Stringer("deploymentId", deployment.ID), zap.Int("reported", len(report.Series)))
+	}
+	if err := db.ReplaceDeploymentAppMetrics(ctx, deployment.ID, series); err != nil {
+		log.Error("failed to save app metrics", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if err := appmetricalerts.Process(ctx, *dt, deployment, series); err != nil {
+		// the metrics have been saved, so the agent must not retry
+		log.Error("failed to evaluate app metric alert rules", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// getPendingAgentConnectivityCheck returns the connectivity check the agent should run, if any.
+// The endpoints are derived exclusively from the server configuration and the registries of the deployed licenses,
+// so that users can not use connectivity checks to probe arbitrary hosts from the deployment target network.
+func getPendingAgentConnectivityCheck(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	registryURLs []string,
+) *api.AgentConnectivityCheck {
+	log := internalctx.GetLogger(ctx)
+	check, err := db.GetPendingDeploymentTargetConnectivityCheck(ctx, dt.ID, connectivityCheckMaxAge)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		log.Warn("failed to get pending connectivity check", zap.Error(err))
+		return nil
+	}
+	org, err := db.GetOrganizationByID(ctx, dt.OrganizationID)
+	if err != nil {
+		log.Warn("failed to get organization for connectivity check", zap.Error(err))
+		return nil
+	}
+
+	endpoints := []api.AgentConnectivityEndpoint{{Name: "api", URL: customdomains.AppDomainOrDefault(*org)}}
+	if env.RegistryEnabled() {
+		scheme := "https://"
+		if buildconfig.IsDevelopment() {
+			scheme = "http://"
+		}
+		endpoints = append(endpoints, api.AgentConnectivityEndpoint{
+			Name: "registry",
+			URL:  scheme + customdomains.RegistryDomainOrDefault(*org),
+		})
+		if s3Config := env.RegistryS3Config(); s3Config.AllowRedirect {
+			blobURL := fmt.Sprintf("https://%v.s3.%v.amazonaws.com", s3Config.Bucket, s3Config.Region)
+			if s3Config.Endpoint != nil {
+				blobURL = *s3Config.Endpoint
+			}
+			endpoints = append(endpoints, api.AgentConnectivityEndpoint{Name: "blob-storage", URL: blobURL})
+		}
+	}
+	for _, registryURL := range registryURLs {
+		if !strings.Contains(registryURL, "://") {
+			registryURL = "https://" + registryURL
+		}
+		if !slices.ContainsFunc(endpoints, func(e api.AgentConnectivityEndpoint) bool { return e.URL == registryURL }) {
+			endpoints = append(endpoints, api.AgentConnectivityEndpoint{Name: "license-registry", URL: registryURL})
+		}
+	}
+	return &api.AgentConnectivityCheck{ID: check.ID, Endpoints: endpoints}
+}
+
+// getPendingAgentFileRetrievals returns the file retrievals the agent should handle, together with the current
+// allow-list of the deployment target.
+func getPendingAgentFileRetrievals(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+) []api.AgentFileRetrieval {
+	log := internalctx.GetLogger(ctx)
+	retrievals, err := db.GetPendingDeploymentTargetFileRetrievals(ctx, dt.ID, fileRetrievalMaxAge)
+	if err != nil {
+		log.Warn("failed to get pending file retrievals", zap.Error(err))
+		return nil
+	} else if len(retrievals) == 0 {
+		return nil
+	}
+	allowList, err := db.GetDeploymentTargetFileRetrievalAllowList(ctx, dt.ID)
+	if err != nil {
+		log.Warn("failed to get file retrieval allow-list", zap.Error(err))
+		return nil
+	}
+	result := make([]api.AgentFileRetrieval, len(retrievals))
+	for i, retrieval := range retrievals {
+		result[i] = api.AgentFileRetrieval{
+			ID:           retrieval.ID,
+			Path:         retrieval.Path,
+			AllowedPaths: allowList.AllowedPaths,
+		}
+	}
+	return result
+}
+
 func queryAuthDeploymentTargetCtxMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -367,7 +961,9 @@ func agentManifestHandler() http.HandlerFunc {
 			log.Error("could not get org for deployment target", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
-		} else if manifest, err := agentmanifest.Get(ctx, *deploymentTarget, *org, nil); err != nil {
+		} else if manifest, err := agentmanifest.Get(
+			ctx, *deploymentTarget, *org, nil, deploymentTarget.ReportedAgentPlatform,
+		); err != nil {
 			log.Error("could not get agent manifest", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -388,11 +984,16 @@ func agentAuthDeploymentTargetCtxMiddleware(next http.Handler) http.Handler {
 		orgId := auth.CurrentOrgID()
 		targetId := auth.CurrentDeploymentTargetID()
 
+		// The deployment target is loaded for every request, so that deleting or archiving it takes effect with the
+		// very next request of its agent on every replica, even though the token of the agent is still valid.
 		if deploymentTarget, err := db.GetDeploymentTarget(ctx, targetId, &orgId); errors.Is(err, apierrors.ErrNotFound) {
-			w.WriteHeader(http.StatusNotFound)
+			// the token has been issued by us, so the deployment target existed and has been deleted since
+			respondDeploymentTargetGone(w, api.AgentGoneCodeDeleted, errDeploymentTargetDeleted)
 		} else if err != nil {
 			log.Error("failed to get DeploymentTarget", zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
+		} else if deploymentTarget.ArchivedAt != nil {
+			respondDeploymentTargetGone(w, api.AgentGoneCodeArchived, errDeploymentTargetArchived)
 		} else {
 			if ua := r.UserAgent(); strings.HasPrefix(ua, fmt.Sprintf("%v/", useragent.DistrAgentUserAgent)) {
 				reportedVersionName := strings.TrimPrefix(ua, fmt.Sprintf("%v/", useragent.DistrAgentUserAgent))
@@ -408,12 +1009,29 @@ func agentAuthDeploymentTargetCtxMiddleware(next http.Handler) http.Handler {
 					}
 				}
 			}
+			if platform := r.Header.Get(useragent.DistrAgentPlatformHeader); platform != "" {
+				if platform, err := agentimage.ParsePlatform(platform); err != nil {
+					log.Warn("agent reported invalid platform", zap.Error(err))
+				} else if deploymentTarget.ReportedAgentPlatform == nil ||
+					*deploymentTarget.ReportedAgentPlatform != platform {
+					if err := db.UpdateDeploymentTargetReportedAgentPlatform(ctx, deploymentTarget, platform); err != nil {
+						log.Error("could not update reported agent platform", zap.Error(err))
+						sentry.GetHubFromContext(ctx).CaptureException(err)
+					}
+				}
+			}
 			ctx = internalctx.WithDeploymentTarget(ctx, deploymentTarget)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 	})
 }
 
+func respondDeploymentTargetGone(w http.ResponseWriter, code string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGone)
+	_ = json.NewEncoder(w).Encode(api.ErrorResponse{Error: api.Error{Code: code, Message: err.Error()}})
+}
+
 func getVerifiedDeploymentTarget(
 	ctx context.Context,
 	targetID uuid.UUID,
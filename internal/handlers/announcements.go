@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func AnnouncementsRouter(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole)
+	r.With(requireUserRoleVendor).Get("/", getAnnouncements)
+	r.With(requireUserRoleVendor).Post("/", createAnnouncement)
+	r.Get("/active", getActiveAnnouncements)
+	r.Route("/{announcementId}", func(r chi.Router) {
+		r.With(requireUserRoleVendor).Put("/", updateAnnouncement)
+		r.With(requireUserRoleVendor).Delete("/", deleteAnnouncement)
+		r.Post("/read", markAnnouncementReadHandler(false))
+		r.Post("/dismiss", markAnnouncementReadHandler(true))
+	})
+}
+
+func getAnnouncements(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if announcements, err := db.GetAnnouncements(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get announcements", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, announcements)
+	}
+}
+
+// getActiveAnnouncements returns the announcements that are currently shown to the user, excluding the dismissed
+// ones unless includeDismissed=true is passed.
+func getActiveAnnouncements(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	includeDismissed, err := QueryParam(r, "includeDismissed", strconv.ParseBool)
+	if err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if announcements, err := db.GetActiveUserAnnouncements(
+		ctx,
+		*auth.CurrentOrgID(),
+		auth.CurrentUserID(),
+		*auth.CurrentUserRole(),
+		time.Now(),
+		includeDismissed,
+	); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get active announcements", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, announcements)
+	}
+}
+
+func createAnnouncement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.AnnouncementRequest](w, r)
+	if err != nil {
+		return
+	}
+	announcement := types.Announcement{
+		OrganizationID:         *auth.CurrentOrgID(),
+		CreatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+	}
+	applyAnnouncementRequest(&announcement, request)
+	if err := announcement.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := db.CreateAnnouncement(ctx, &announcement); errors.Is(err, validation.ErrValidationFailed) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create announcement", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, announcement)
+	}
+}
+
+func updateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	id, err := uuid.Parse(r.PathValue("announcementId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	request, err := JsonBody[api.AnnouncementRequest](w, r)
+	if err != nil {
+		return
+	}
+	announcement, err := db.GetAnnouncement(ctx, id, *auth.CurrentOrgID())
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get announcement", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if request.StartsAt == nil {
+		// the start of an existing announcement is kept instead of being reset to now
+		request.StartsAt = &announcement.StartsAt
+	}
+	applyAnnouncementRequest(announcement, request)
+	if err := announcement.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := db.UpdateAnnouncement(ctx, announcement); errors.Is(err, validation.ErrValidationFailed) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to update announcement", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, announcement)
+	}
+}
+
+func deleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if id, err := uuid.Parse(r.PathValue("announcementId")); err != nil {
+		http.NotFound(w, r)
+	} else if err := db.DeleteAnnouncement(ctx, id, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete announcement", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// markAnnouncementReadHandler records that the current user has read or dismissed an announcement. Only
+// announcements that are currently shown to the user can be marked.
+func markAnnouncementReadHandler(dismiss bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := internalctx.GetLogger(ctx)
+		auth := auth.Authentication.Require(ctx)
+		id, err := uuid.Parse(r.PathValue("announcementId"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if _, err := db.GetActiveUserAnnouncement(
+			ctx, id, *auth.CurrentOrgID(), auth.CurrentUserID(), *auth.CurrentUserRole(), time.Now(),
+		); errors.Is(err, apierrors.ErrNotFound) {
+			http.NotFound(w, r)
+		} else if err != nil {
+			log.Error("failed to get announcement", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		} else if err := db.MarkAnnouncementRead(ctx, id, auth.CurrentUserID(), dismiss); err != nil {
+			log.Error("failed to mark announcement as read", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func applyAnnouncementRequest(announcement *types.Announcement, request api.AnnouncementRequest) {
+	announcement.Title = request.Title
+	announcement.Body = request.Body
+	announcement.Severity = request.Severity
+	announcement.Audience = request.Audience
+	announcement.CustomerIDs = request.CustomerIDs
+	announcement.DeploymentTargetIDs = request.DeploymentTargetIDs
+	announcement.StartsAt = time.Now()
+	if request.StartsAt != nil {
+		announcement.StartsAt = *request.StartsAt
+	}
+	announcement.EndsAt = request.EndsAt
+}
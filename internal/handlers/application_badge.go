@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/authkey"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/customdomains"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/statusbadge"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+	"go.uber.org/zap"
+)
+
+// badgeMaxAge is the max-age of public badge responses. Badge data is only refreshed by a scheduled job, so there is
+// no point in letting clients revalidate more often.
+const badgeMaxAge = 1 * time.Hour
+
+func applicationBadgeRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor)
+	r.Get("/", getApplicationBadge)
+	r.Post("/", rotateApplicationBadge)
+	r.Delete("/", deleteApplicationBadge)
+}
+
+// BadgesRouter serves public status badges. The token in the path is the only credential.
+func BadgesRouter(r chi.Router) {
+	r.Use(httprate.Limit(
+		60,
+		1*time.Minute,
+		httprate.WithKeyFuncs(httprate.KeyByRealIP, httprate.KeyByEndpoint),
+	))
+	r.Get("/{token}.svg", getBadgeSVG)
+	r.Get("/{token}.json", getBadgeJSON)
+}
+
+func getApplicationBadge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	if badge, err := db.GetApplicationBadge(ctx, application.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get application badge", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, asApplicationBadgeResponse(*badge))
+	}
+}
+
+// rotateApplicationBadge creates a new token for the badge of an application. If the application already has a
+// badge, all URLs containing the previous token stop working.
+func rotateApplicationBadge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	application := internalctx.GetApplication(ctx)
+
+	key, err := authkey.NewKey()
+	if err != nil {
+		log.Error("failed to generate badge token", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	badge := types.ApplicationBadge{
+		ApplicationID: application.ID,
+		TokenHash:     key.Hash(),
+		TokenPrefix:   key.DisplayPrefix(),
+	}
+	if err := db.SaveApplicationBadgeToken(ctx, &badge); err != nil {
+		log.Error("failed to save application badge", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if badge.RefreshedAt == nil {
+		// a new badge would show no data until the next scheduled refresh
+		if err := statusbadge.Refresh(ctx, application.ID); err != nil {
+			log.Warn("failed to refresh new application badge", zap.Error(err))
+		} else if refreshed, err := db.GetApplicationBadge(ctx, application.ID); err == nil {
+			badge = *refreshed
+		}
+	}
+
+	response := asApplicationBadgeResponse(badge)
+	response.Token = key.Serialize()
+	baseUrl := fmt.Sprintf("%v/api/v1/badges/%v", customdomains.AppDomainOrDefault(*auth.CurrentOrg()), response.Token)
+	response.SvgUrl = baseUrl + ".svg"
+	response.JsonUrl = baseUrl + ".json"
+	RespondJSON(w, response)
+}
+
+func deleteApplicationBadge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	if err := db.DeleteApplicationBadge(ctx, application.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete application badge", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getBadgeSVG(w http.ResponseWriter, r *http.Request) {
+	if badge := getPublicBadge(w, r); badge != nil {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		setBadgeCacheHeaders(w, badge)
+		message := statusbadge.Message(badge.LatestVersion, badge.InstallationCount)
+		if err := statusbadge.RenderSVG(w, badge.ApplicationName, message); err != nil {
+			internalctx.GetLogger(r.Context()).Warn("failed to render badge", zap.Error(err))
+		}
+	}
+}
+
+func getBadgeJSON(w http.ResponseWriter, r *http.Request) {
+	if badge := getPublicBadge(w, r); badge != nil {
+		setBadgeCacheHeaders(w, badge)
+		RespondJSON(w, api.ApplicationBadgeStatus{
+			Application:       badge.ApplicationName,
+			LatestVersion:     badge.LatestVersion,
+			InstallationCount: badge.InstallationCount,
+			UpdatedAt:         badge.RefreshedAt,
+		})
+	}
+}
+
+// getPublicBadge looks up the badge for the token in the request path. Invalid tokens, unknown tokens and badges of
+// organizations that disabled status badges are all answered with 404 so that callers can not tell them apart.
+func getPublicBadge(w http.ResponseWriter, r *http.Request) *types.ApplicationBadgeWithApplication {
+	ctx := r.Context()
+	key, err := authkey.Parse(r.PathValue("token"))
+	if err != nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	if badge, err := db.GetApplicationBadgeByTokenHash(ctx, key.Hash()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get application badge", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if badge.OrganizationDisabled {
+		http.NotFound(w, r)
+	} else {
+		return badge
+	}
+	return nil
+}
+
+func setBadgeCacheHeaders(w http.ResponseWriter, badge *types.ApplicationBadgeWithApplication) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(badgeMaxAge.Seconds())))
+	if badge.RefreshedAt != nil {
+		w.Header().Set("Last-Modified", badge.RefreshedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func asApplicationBadgeResponse(badge types.ApplicationBadge) api.ApplicationBadgeResponse {
+	return api.ApplicationBadgeResponse{
+		TokenPrefix:       badge.TokenPrefix,
+		CreatedAt:         badge.CreatedAt,
+		LatestVersion:     badge.LatestVersion,
+		InstallationCount: badge.InstallationCount,
+		RefreshedAt:       badge.RefreshedAt,
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// applicationDeletionCascade is what happens to the active deployments of an application that is deleted.
+type applicationDeletionCascade string
+
+const (
+	applicationDeletionCascadeArchive   applicationDeletionCascade = "archive"
+	applicationDeletionCascadeUninstall applicationDeletionCascade = "uninstall"
+)
+
+func parseApplicationDeletionCascade(value string) (applicationDeletionCascade, error) {
+	switch cascade := applicationDeletionCascade(value); cascade {
+	case applicationDeletionCascadeArchive, applicationDeletionCascadeUninstall:
+		return cascade, nil
+	default:
+		return "", errors.New("must be archive or uninstall")
+	}
+}
+
+// errApplicationDeletionResponded is returned inside the deletion transaction if an error response has already been
+// written.
+var errApplicationDeletionResponded = errors.New("application deletion failed")
+
+func getApplicationDeletionImpact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	if impact, err := db.GetApplicationDeletionImpact(ctx, application.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get application deletion impact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, impact)
+	}
+}
+
+// deleteApplication deletes an application, which can be restored for APPLICATION_DELETION_COOL_OFF. With
+// force=true, the application is purged immediately instead.
+// If the application has active deployments, the client must pass cascade=archive to archive them or
+// cascade=uninstall to uninstall them. Otherwise, the request fails and lists the blocking deployments.
+func deleteApplication(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	application := internalctx.GetApplication(ctx)
+
+	force, err := QueryParam(r, "force", strconv.ParseBool)
+	if err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if !force && application.IsDeleted() {
+		http.Error(w, "the application is already deleted", http.StatusConflict)
+		return
+	}
+	cascade, err := OptionalQueryParam(r, "cascade", parseApplicationDeletionCascade)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if force && cascade != nil && *cascade == applicationDeletionCascadeUninstall {
+		http.Error(w, "an application can not be purged while its deployments are uninstalled, omit force=true",
+			http.StatusBadRequest)
+		return
+	}
+
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		impact, err := db.GetApplicationDeletionImpact(ctx, application.ID)
+		if err != nil {
+			return err
+		} else if !impact.IsEmpty() {
+			if cascade == nil {
+				http.Error(w, applicationDeletionBlockedMessage(impact), http.StatusConflict)
+				return errApplicationDeletionResponded
+			} else if err := cascadeApplicationDeletion(ctx, w, *cascade, application, impact); err != nil {
+				return err
+			}
+		}
+
+		if force {
+			if err := db.PurgeApplication(ctx, application.ID); err != nil {
+				return err
+			}
+			return auditApplicationDeletion(ctx, "delete", application, impact)
+		}
+		scheduledAt := time.Now().Add(env.ApplicationDeletionCoolOff())
+		if err := db.RequestApplicationDeletion(ctx, application, auth.CurrentUserID(), scheduledAt); err != nil {
+			return err
+		}
+		return auditApplicationDeletion(ctx, "request_deletion", application, impact)
+	})
+
+	if errors.Is(err, errApplicationDeletionResponded) {
+		return
+	} else if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "the application is already deleted or still in use", http.StatusConflict)
+	} else if err != nil {
+		log.Error("failed to delete application", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if force {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		RespondJSON(w, api.AsApplication(*application))
+	}
+}
+
+func restoreApplication(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	application := internalctx.GetApplication(ctx)
+	if err := db.RestoreApplication(ctx, application); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "the application is not deleted", http.StatusConflict)
+	} else if err != nil {
+		log.Error("failed to restore application", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := auditApplicationDeletion(ctx, "restore", application, nil); err != nil {
+		log.Warn("could not audit application restore", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.AsApplication(*application))
+	}
+}
+
+// requireApplicationNotDeleted rejects changes to an application that is deleted. It must be restored first.
+func requireApplicationNotDeleted(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if internalctx.GetApplication(r.Context()).IsDeleted() {
+			http.Error(w, "the application is deleted, restore it first", http.StatusConflict)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func applicationDeletionBlockedMessage(impact *types.ApplicationDeletionImpact) string {
+	deployments := make([]string, len(impact.Deployments))
+	for i, d := range impact.Deployments {
+		deployments[i] = d.DeploymentTargetName
+		if d.ReleaseName != nil {
+			deployments[i] += " (" + *d.ReleaseName + ")"
+		}
+	}
+	return fmt.Sprintf("the application is still deployed to %v, check the deletion-impact endpoint and repeat the "+
+		"request with cascade=archive or cascade=uninstall", strings.Join(deployments, ", "))
+}
+
+// cascadeApplicationDeletion archives or uninstalls the active deployments of an application that is deleted.
+// Deployments are only uninstalled if no deployments of other applications depend on them.
+func cascadeApplicationDeletion(
+	ctx context.Context,
+	w http.ResponseWriter,
+	cascade applicationDeletionCascade,
+	application *types.Application,
+	impact *types.ApplicationDeletionImpact,
+) error {
+	auth := auth.Authentication.Require(ctx)
+	switch cascade {
+	case applicationDeletionCascadeArchive:
+		for _, d := range impact.Deployments {
+			if err := db.SetDeploymentArchived(ctx, &types.Deployment{Base: types.Base{ID: d.DeploymentID}}, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	case applicationDeletionCascadeUninstall:
+		var targetIDs []uuid.UUID
+		uninstalled := map[uuid.UUID][]uuid.UUID{}
+		for _, d := range impact.Deployments {
+			if d.UninstallRequestedAt != nil {
+				continue
+			} else if !slices.Contains(targetIDs, d.DeploymentTargetID) {
+				targetIDs = append(targetIDs, d.DeploymentTargetID)
+			}
+			uninstalled[d.DeploymentTargetID] = append(uninstalled[d.DeploymentTargetID], d.DeploymentID)
+		}
+		for _, targetID := range targetIDs {
+			deploymentIDs := uninstalled[targetID]
+			target, err := db.GetDeploymentTarget(ctx, targetID, auth.CurrentOrgID())
+			if err != nil {
+				return err
+			}
+			if dependents, err := getUninstallDependents(ctx, *auth.CurrentOrgID(), target, deploymentIDs); err != nil {
+				return err
+			} else if len(dependents) > 0 {
+				http.Error(w, fmt.Sprintf("the deployments of %v on %v can not be uninstalled because they are "+
+					"required by %v", application.Name, target.Name, strings.Join(dependents, ", ")),
+					http.StatusConflict)
+				return errApplicationDeletionResponded
+			}
+			for _, id := range deploymentIDs {
+				if err := db.RequestDeploymentUninstall(
+					ctx, &types.Deployment{Base: types.Base{ID: id}}, auth.CurrentUserID(), false,
+				); err != nil {
+					return err
+				} else if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+					OrganizationID: auth.CurrentOrgID(),
+					UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+					Action:         "request_uninstall",
+					ResourceType:   "Deployment",
+					ResourceID:     id,
+					Data:           map[string]any{"deleteData": false, "applicationDeleted": true},
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid cascade: %v", cascade)
+	}
+}
+
+// auditApplicationDeletion stores a step of the deletion of application in the audit log.
+func auditApplicationDeletion(
+	ctx context.Context,
+	action string,
+	application *types.Application,
+	impact *types.ApplicationDeletionImpact,
+) error {
+	auth := auth.Authentication.Require(ctx)
+	data := map[string]any{"application": types.Application{
+		ID:                  application.ID,
+		CreatedAt:           application.CreatedAt,
+		Name:                application.Name,
+		Type:                application.Type,
+		DeletedAt:           application.DeletedAt,
+		DeletionScheduledAt: application.DeletionScheduledAt,
+	}}
+	if impact != nil && !impact.IsEmpty() {
+		data["impact"] = impact
+	}
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "Application",
+		ResourceID:     application.ID,
+		Data:           data,
+	})
+}
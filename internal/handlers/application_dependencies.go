@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/depgraph"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func applicationDependenciesRouter(r chi.Router) {
+	r.Get("/", getApplicationDependencies)
+	r.With(requireUserRoleVendor).Put("/", putApplicationDependencies)
+}
+
+func getApplicationDependencies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	application := internalctx.GetApplication(ctx)
+	if dependencies, err := db.GetApplicationDependencies(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get application dependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, applicationDependenciesOrEmpty(dependencies[application.ID]))
+	}
+}
+
+// putApplicationDependencies replaces the applications that an application depends on. Dependencies must have the
+// same deployment type, because they have to be deployed to the same deployment target, and must not form a cycle.
+func putApplicationDependencies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	application := internalctx.GetApplication(ctx)
+	request, err := JsonBody[api.ApplicationDependenciesRequest](w, r)
+	if err != nil {
+		return
+	}
+
+	applications, err := db.GetApplicationsByOrgID(ctx, *auth.CurrentOrgID())
+	if err != nil {
+		log.Error("failed to get applications", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dependsOn := make([]uuid.UUID, 0, len(request.Dependencies))
+	dependencies := make([]types.ApplicationDependency, 0, len(request.Dependencies))
+	for _, d := range request.Dependencies {
+		if d.ApplicationID == application.ID {
+			http.Error(w, "Application can not depend on itself", http.StatusBadRequest)
+			return
+		} else if slices.Contains(dependsOn, d.ApplicationID) {
+			http.Error(w, fmt.Sprintf("Application %v is listed more than once", d.ApplicationID), http.StatusBadRequest)
+			return
+		} else if !slices.ContainsFunc(applications, func(a types.Application) bool {
+			return a.ID == d.ApplicationID && a.Type == application.Type
+		}) {
+			http.Error(w, fmt.Sprintf("Application %v does not exist or has a different type", d.ApplicationID),
+				http.StatusBadRequest)
+			return
+		} else if d.MinVersion != nil {
+			if _, err := semver.NewVersion(*d.MinVersion); err != nil {
+				http.Error(w, "minVersion must be a semantic version", http.StatusBadRequest)
+				return
+			}
+		}
+		dependsOn = append(dependsOn, d.ApplicationID)
+		dependencies = append(dependencies, types.ApplicationDependency{
+			ApplicationID:          application.ID,
+			DependsOnApplicationID: d.ApplicationID,
+			MinVersion:             d.MinVersion,
+		})
+	}
+
+	existing, err := db.GetApplicationDependencies(ctx, *auth.CurrentOrgID())
+	if err != nil {
+		log.Error("failed to get application dependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	graph := applicationDependencyGraph(existing)
+	graph[application.ID] = dependsOn
+	if depgraph.HasPath(graph, dependsOn, application.ID) {
+		http.Error(w, "Application dependencies must not form a cycle", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.PutApplicationDependencies(ctx, application.ID, dependencies); err != nil {
+		log.Error("failed to save application dependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if updated, err := db.GetApplicationDependencies(ctx, *auth.CurrentOrgID()); err != nil {
+		log.Error("failed to get application dependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, applicationDependenciesOrEmpty(updated[application.ID]))
+	}
+}
+
+// applicationDependencyGraph returns the IDs of the applications that each application depends on.
+func applicationDependencyGraph(dependencies map[uuid.UUID][]types.ApplicationDependency) map[uuid.UUID][]uuid.UUID {
+	graph := make(map[uuid.UUID][]uuid.UUID, len(dependencies))
+	for id, ds := range dependencies {
+		for _, d := range ds {
+			graph[id] = append(graph[id], d.DependsOnApplicationID)
+		}
+	}
+	return graph
+}
+
+func applicationDependenciesOrEmpty(dependencies []types.ApplicationDependency) []types.ApplicationDependency {
+	if dependencies == nil {
+		return []types.ApplicationDependency{}
+	}
+	return dependencies
+}
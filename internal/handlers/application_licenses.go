@@ -1,15 +1,17 @@
 package handlers
 
 import (
-	"context"
 	"errors"
 	"net/http"
 	"slices"
+	"time"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
 	"github.com/glasskube/distr/internal/apierrors"
 	"github.com/glasskube/distr/internal/auth"
 	"github.com/glasskube/distr/internal/authn/authinfo"
+	"github.com/glasskube/distr/internal/clock"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/middleware"
@@ -19,15 +21,23 @@ import (
 	"go.uber.org/zap"
 )
 
+var errLicenseVersionNotFound = errors.New("all versions must belong to the application of the license")
+
 func ApplicationLicensesRouter(r chi.Router) {
 	r.Use(middleware.RequireOrgAndRole, middleware.LicensingFeatureFlagEnabledMiddleware)
 	r.Get("/", getApplicationLicenses)
-	r.With(requireUserRoleVendor).Post("/", createApplicationLicense)
+	r.With(requireUserRoleVendor, middleware.Transaction).Post("/", createApplicationLicense)
 	r.Route("/{applicationLicenseId}", func(r chi.Router) {
 		r.With(applicationLicenseMiddleware).Group(func(r chi.Router) {
 			r.Get("/", getApplicationLicense)
-			r.With(requireUserRoleVendor).Delete("/", deleteApplicationLicense)
-			r.With(requireUserRoleVendor).Put("/", updateApplicationLicense)
+			r.With(requireUserRoleVendor).Get("/impact", getApplicationLicenseImpact)
+			r.With(requireUserRoleVendor, middleware.Transaction).Delete("/", deleteApplicationLicense)
+			r.With(requireUserRoleVendor, middleware.Transaction).Put("/", updateApplicationLicense)
+			r.With(requireApplicationLicenseSeatManagement).Route("/seats", func(r chi.Router) {
+				r.Get("/", getApplicationLicenseSeats)
+				r.Post("/", createApplicationLicenseSeat)
+				r.Delete("/{userAccountId}", deleteApplicationLicenseSeat)
+			})
 		})
 	})
 }
@@ -42,37 +52,58 @@ func createApplicationLicense(w http.ResponseWriter, r *http.Request) {
 	}
 	license.OrganizationID = *auth.CurrentOrgID()
 
+	if license.SeatCount != nil && *license.SeatCount < 0 {
+		http.Error(w, "Seat count must not be negative", http.StatusBadRequest)
+		return
+	}
+	if application, err := db.GetApplication(ctx, license.ApplicationID, license.OrganizationID); errors.Is(
+		err, apierrors.ErrNotFound,
+	) {
+		http.Error(w, "applicationId must be an application of the organization", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Warn("could not get application", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if application.IsDeleted() {
+		http.Error(w, "Application is deleted", http.StatusBadRequest)
+		return
+	} else if !validateLicenseOwner(w, r, license.OwnerUserAccountID) {
+		return
+	}
 	sanitizeRegistryInput(license)
 
-	_ = db.RunTx(ctx, func(ctx context.Context) error {
-		if err := db.CreateApplicationLicense(ctx, &license.ApplicationLicenseBase); errors.Is(err, apierrors.ErrConflict) {
-			http.Error(w, "A license with this name already exists", http.StatusBadRequest)
-			return err
+	if err := db.CreateApplicationLicense(ctx, &license.ApplicationLicenseBase); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "A license with this name already exists", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Warn("could not create license", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, version := range license.Versions {
+		if err := db.AddVersionToApplicationLicense(
+			ctx, &license.ApplicationLicenseBase, version.ID,
+		); errors.Is(err, apierrors.ErrNotFound) {
+			http.Error(w, errLicenseVersionNotFound.Error(), http.StatusBadRequest)
+			return
 		} else if err != nil {
-			log.Warn("could not create license", zap.Error(err))
+			log.Warn("could not add version to license", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return err
-		}
-		for _, version := range license.Versions {
-			if err := db.AddVersionToApplicationLicense(ctx, &license.ApplicationLicenseBase, version.ID); err != nil {
-				log.Warn("could not add version to license", zap.Error(err))
-				sentry.GetHubFromContext(ctx).CaptureException(err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return err
-			}
-		}
-
-		if createdLicense, err := db.GetApplicationLicenseByID(ctx, license.ID); err != nil {
-			log.Warn("could not read previously created license", zap.Error(err))
-			sentry.GetHubFromContext(ctx).CaptureException(err)
-			return err
-		} else {
-			RespondJSON(w, createdLicense)
+			return
 		}
+	}
 
-		return nil
-	})
+	if createdLicense, err := db.GetApplicationLicenseByID(ctx, license.ID); err != nil {
+		log.Warn("could not read previously created license", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, createdLicense)
+	}
 }
 
 func updateApplicationLicense(w http.ResponseWriter, r *http.Request) {
@@ -98,76 +129,103 @@ func updateApplicationLicense(w http.ResponseWriter, r *http.Request) {
 	} else if existing.ApplicationID != license.ApplicationID {
 		http.Error(w, "Changing the application is not allowed", http.StatusBadRequest)
 		return
+	} else if license.SeatCount != nil && *license.SeatCount < existing.SeatsUsed {
+		http.Error(w, "Seat count must not be lower than the number of assigned seats", http.StatusBadRequest)
+		return
+	} else if !validateLicenseOwner(w, r, license.OwnerUserAccountID) {
+		return
 	}
 	sanitizeRegistryInput(license)
 
-	_ = db.RunTx(ctx, func(ctx context.Context) error {
-		if err := db.UpdateApplicationLicense(ctx, &license.ApplicationLicenseBase); errors.Is(err, apierrors.ErrConflict) {
-			http.Error(w, "A license with this name already exists", http.StatusBadRequest)
-			return err
-		} else if err != nil {
-			log.Warn("could not update license", zap.Error(err))
-			sentry.GetHubFromContext(ctx).CaptureException(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return err
-		}
+	if err := db.UpdateApplicationLicense(ctx, &license.ApplicationLicenseBase); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "A license with this name already exists", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Warn("could not update license", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-		for _, version := range license.Versions {
-			alreadyExists := slices.ContainsFunc(existing.Versions, func(v types.ApplicationVersion) bool {
-				return v.ID == version.ID
-			})
-			if !alreadyExists {
-				if len(existing.Versions) == 0 {
-					// we don't allow narrowing down the scope yet. If the existing license allows all versions,
-					// setting some specific ones is not possible anymore
-					err = errors.New("narrowing down license scope is not allowed yet")
-					http.Error(w, err.Error(), http.StatusBadRequest)
-					return err
-				} else {
-					if err := db.AddVersionToApplicationLicense(ctx, &license.ApplicationLicenseBase, version.ID); err != nil {
-						log.Warn("could not add version to license", zap.Error(err))
-						sentry.GetHubFromContext(ctx).CaptureException(err)
-						http.Error(w, err.Error(), http.StatusInternalServerError)
-						return err
-					}
+	for _, version := range license.Versions {
+		alreadyExists := slices.ContainsFunc(existing.Versions, func(v types.ApplicationVersion) bool {
+			return v.ID == version.ID
+		})
+		if !alreadyExists {
+			if len(existing.Versions) == 0 {
+				// we don't allow narrowing down the scope yet. If the existing license allows all versions,
+				// setting some specific ones is not possible anymore
+				err = errors.New("narrowing down license scope is not allowed yet")
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else {
+				if err := db.AddVersionToApplicationLicense(
+					ctx, &license.ApplicationLicenseBase, version.ID,
+				); errors.Is(err, apierrors.ErrNotFound) {
+					http.Error(w, errLicenseVersionNotFound.Error(), http.StatusBadRequest)
+					return
+				} else if err != nil {
+					log.Warn("could not add version to license", zap.Error(err))
+					sentry.GetHubFromContext(ctx).CaptureException(err)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
 				}
 			}
 		}
+	}
 
-		for _, existingVersion := range existing.Versions {
-			stillExists := slices.ContainsFunc(license.Versions, func(v types.ApplicationVersion) bool {
-				return v.ID == existingVersion.ID
-			})
-			if !stillExists {
-				if len(license.Versions) > 0 {
-					// for now, removing specific versions from the license is not possible
-					// for removal we also would have to check whether this version is used in some deployment target
-					err = errors.New("narrowing down license scope is not allowed yet")
-					http.Error(w, err.Error(), http.StatusBadRequest)
-					return err
-				} else {
-					// however removing the relations is possible iff the user chose "all versions" by versions = []
-					if err := db.RemoveVersionFromApplicationLicense(
-						ctx, &license.ApplicationLicenseBase, existingVersion.ID); err != nil {
-						log.Warn("could not remove version from license", zap.Error(err))
-						sentry.GetHubFromContext(ctx).CaptureException(err)
-						http.Error(w, err.Error(), http.StatusInternalServerError)
-						return err
-					}
+	for _, existingVersion := range existing.Versions {
+		stillExists := slices.ContainsFunc(license.Versions, func(v types.ApplicationVersion) bool {
+			return v.ID == existingVersion.ID
+		})
+		if !stillExists {
+			if len(license.Versions) > 0 {
+				// for now, removing specific versions from the license is not possible
+				// for removal we also would have to check whether this version is used in some deployment target
+				err = errors.New("narrowing down license scope is not allowed yet")
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			} else {
+				// however removing the relations is possible iff the user chose "all versions" by versions = []
+				if err := db.RemoveVersionFromApplicationLicense(
+					ctx, &license.ApplicationLicenseBase, existingVersion.ID); err != nil {
+					log.Warn("could not remove version from license", zap.Error(err))
+					sentry.GetHubFromContext(ctx).CaptureException(err)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
 				}
 			}
 		}
+	}
 
-		if updatedLicense, err := db.GetApplicationLicenseByID(ctx, license.ID); err != nil {
-			log.Warn("could not read previously updated license", zap.Error(err))
-			sentry.GetHubFromContext(ctx).CaptureException(err)
-			return err
-		} else {
-			RespondJSON(w, updatedLicense)
-		}
+	if updatedLicense, err := db.GetApplicationLicenseByID(ctx, license.ID); err != nil {
+		log.Warn("could not read previously updated license", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, updatedLicense)
+	}
+}
 
-		return nil
-	})
+// validateLicenseOwner makes sure that ownerID, if set, is a customer of the current organization. Otherwise, an
+// error response is written and false is returned.
+func validateLicenseOwner(w http.ResponseWriter, r *http.Request, ownerID *uuid.UUID) bool {
+	if ownerID == nil {
+		return true
+	}
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	owner, err := db.GetUserAccountWithRole(ctx, *ownerID, *auth.CurrentOrgID())
+	if errors.Is(err, apierrors.ErrNotFound) || err == nil && owner.UserRole != types.UserRoleCustomer {
+		http.Error(w, "ownerUserAccountId must be a customer of the organization", http.StatusBadRequest)
+		return false
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Warn("could not get license owner", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+	return true
 }
 
 func sanitizeRegistryInput(license types.ApplicationLicenseWithVersions) {
@@ -217,6 +275,18 @@ func getApplicationLicense(w http.ResponseWriter, r *http.Request) {
 	RespondJSON(w, license)
 }
 
+func getApplicationLicenseImpact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	license := internalctx.GetApplicationLicense(ctx)
+	if impact, err := db.GetApplicationLicenseImpact(ctx, license.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get license impact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, impact)
+	}
+}
+
 func deleteApplicationLicense(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
@@ -224,16 +294,40 @@ func deleteApplicationLicense(w http.ResponseWriter, r *http.Request) {
 	auth := auth.Authentication.Require(ctx)
 	if license.OrganizationID != *auth.CurrentOrgID() {
 		http.NotFound(w, r)
-	} else if err := db.DeleteApplicationLicenseWithID(ctx, license.ID); errors.Is(err, apierrors.ErrConflict) {
+		return
+	}
+	impact, err := db.GetApplicationLicenseImpact(ctx, license.ID)
+	if err != nil {
+		log.Error("failed to get license impact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if !confirmLicenseDeletion(w, r, impact) {
+		return
+	}
+	if err := db.DeleteApplicationLicenseWithID(ctx, license.ID); errors.Is(err, apierrors.ErrConflict) {
 		http.Error(w, "could not delete license because it is still in use", http.StatusBadRequest)
 	} else if err != nil {
 		log.Warn("error deleting license", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else if err := auditLicenseDeletion(
+		ctx, "ApplicationLicense", license.ID, withoutRegistryPassword(license), impact,
+	); err != nil {
+		log.Warn("could not audit license deletion", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	} else {
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// withoutRegistryPassword returns a copy of license that is safe to persist outside of the license itself.
+func withoutRegistryPassword(license *types.ApplicationLicense) types.ApplicationLicense {
+	result := *license
+	result.RegistryPassword = nil
+	return result
+}
+
 func applicationLicenseMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -259,10 +353,120 @@ func canSeeLicense(auth authinfo.AuthInfo, license *types.ApplicationLicense) bo
 	if license.OrganizationID != *auth.CurrentOrgID() {
 		return false
 	}
-	if *auth.CurrentUserRole() == types.UserRoleCustomer {
-		if license.OwnerUserAccountID == nil || *license.OwnerUserAccountID != auth.CurrentUserID() {
-			return false
-		}
+	if *auth.CurrentUserRole() == types.UserRoleCustomer && !license.IsHeldBy(auth.CurrentUserID()) {
+		return false
 	}
 	return true
 }
+
+// requireApplicationLicenseSeatManagement only allows vendors and the customer owning the license to manage its seats.
+func requireApplicationLicenseSeatManagement(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		auth := auth.Authentication.Require(ctx)
+		license := internalctx.GetApplicationLicense(ctx)
+		if *auth.CurrentUserRole() != types.UserRoleVendor &&
+			(license.OwnerUserAccountID == nil || *license.OwnerUserAccountID != auth.CurrentUserID()) {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func getApplicationLicenseSeats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	license := internalctx.GetApplicationLicense(ctx)
+	if seats, err := db.GetApplicationLicenseSeats(ctx, license.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get license seats", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, seats)
+	}
+}
+
+func createApplicationLicenseSeat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	license := internalctx.GetApplicationLicense(ctx)
+	user, ok := getLicenseSeatCustomer(w, r, license.SeatCount, license.ExpiresAt)
+	if !ok {
+		return
+	}
+
+	if err := db.CreateApplicationLicenseSeat(ctx, license.ID, user.ID); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "All seats of this license are already assigned", http.StatusBadRequest)
+	} else if err != nil {
+		log.Warn("could not assign license seat", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if seats, err := db.GetApplicationLicenseSeats(ctx, license.ID); err != nil {
+		log.Warn("could not get license seats", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, seats)
+	}
+}
+
+// getLicenseSeatCustomer returns the user of a seat assignment request for a license with the given seat count and
+// expiry. If the request is invalid, an error response is written and false is returned.
+func getLicenseSeatCustomer(
+	w http.ResponseWriter,
+	r *http.Request,
+	seatCount *int,
+	expiresAt *time.Time,
+) (*types.UserAccount, bool) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.LicenseSeatRequest](w, r)
+	if err != nil {
+		return nil, false
+	}
+
+	if seatCount == nil {
+		http.Error(w, "This license does not have any seats", http.StatusBadRequest)
+		return nil, false
+	} else if expiresAt != nil && expiresAt.Before(clock.Now()) {
+		http.Error(w, "This license has expired", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Seats can only be assigned to customers of the same organization. Both "user does not exist" and "user is not
+	// a customer" result in the same response to prevent enumerating user accounts of other organizations.
+	user, err := db.GetUserAccountByEmail(ctx, request.Email)
+	if err == nil {
+		var member *types.UserAccountWithUserRole
+		if member, err = db.GetUserAccountWithRole(ctx, user.ID, *auth.CurrentOrgID()); err == nil &&
+			member.UserRole != types.UserRoleCustomer {
+			err = apierrors.ErrNotFound
+		}
+	}
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.Error(w, "No customer with this email address exists", http.StatusBadRequest)
+		return nil, false
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Warn("could not get user account", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
+
+func deleteApplicationLicenseSeat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	license := internalctx.GetApplicationLicense(ctx)
+	if userID, err := uuid.Parse(r.PathValue("userAccountId")); err != nil {
+		http.Error(w, "userAccountId is not a valid UUID", http.StatusBadRequest)
+	} else if err := db.DeleteApplicationLicenseSeat(ctx, license.ID, userID); errors.Is(err, apierrors.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Warn("could not unassign license seat", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func applicationMetricAlertRulesRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor)
+	r.Get("/", getApplicationMetricAlertRules)
+	r.Post("/", createApplicationMetricAlertRule)
+	r.Delete("/{ruleId}", deleteApplicationMetricAlertRule)
+}
+
+func getApplicationMetricAlertRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	if rules, err := db.GetApplicationMetricAlertRules(ctx, application.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get metric alert rules", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rules)
+	}
+}
+
+func createApplicationMetricAlertRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	request, err := JsonBody[api.ApplicationMetricAlertRuleRequest](w, r)
+	if err != nil {
+		return
+	}
+	rule := types.ApplicationMetricAlertRule{
+		ApplicationID: application.ID,
+		Metric:        request.Metric,
+		Operator:      request.Operator,
+		Value:         request.Value,
+		ForSeconds:    request.ForSeconds,
+	}
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := db.CreateApplicationMetricAlertRule(ctx, &rule); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to save metric alert rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rule)
+	}
+}
+
+func deleteApplicationMetricAlertRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	ruleID, err := uuid.Parse(r.PathValue("ruleId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := db.DeleteApplicationMetricAlertRule(ctx, ruleID, application.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete metric alert rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/promotion"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func applicationPromotionRulesRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor)
+	r.Get("/", getApplicationPromotionRules)
+	r.Put("/{channel}", putApplicationPromotionRule)
+	r.Delete("/{channel}", deleteApplicationPromotionRule)
+}
+
+func getApplicationPromotionRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	if rules, err := db.GetApplicationPromotionRules(ctx, application.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get promotion rules", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rules)
+	}
+}
+
+func putApplicationPromotionRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	channel := r.PathValue("channel")
+	if err := api.ValidateChannelName(channel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request, err := JsonBody[api.ApplicationPromotionRuleRequest](w, r)
+	if err != nil {
+		return
+	} else if err := promotion.ValidateChecks(request.Checks); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule := types.ApplicationPromotionRule{ApplicationID: application.ID, Channel: channel, Checks: request.Checks}
+	if rule.Checks == nil {
+		rule.Checks = []types.PromotionCheck{}
+	}
+	if err := db.UpsertApplicationPromotionRule(ctx, &rule); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to save promotion rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rule)
+	}
+}
+
+func deleteApplicationPromotionRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	if err := db.DeleteApplicationPromotionRule(ctx, application.ID, r.PathValue("channel")); errors.Is(
+		err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete promotion rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func putApplicationVersionScan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	version := getApplicationVersionFromPath(w, r)
+	if version == nil {
+		return
+	}
+	request, err := JsonBody[api.ApplicationVersionScanRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	scan := types.ApplicationVersionScan{
+		ApplicationVersionID: version.ID,
+		Scanner:              request.Scanner,
+		Critical:             request.Critical,
+		High:                 request.High,
+		Medium:               request.Medium,
+		Low:                  request.Low,
+	}
+	if err := db.CreateApplicationVersionScan(ctx, &scan); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to save vulnerability scan", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, scan)
+	}
+}
+
+func createApplicationVersionApproval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	version := getApplicationVersionFromPath(w, r)
+	if version == nil {
+		return
+	}
+	approval := types.ApplicationVersionApproval{
+		ApplicationVersionID: version.ID,
+		UserAccountID:        auth.CurrentUserID(),
+		UserRole:             *auth.CurrentUserRole(),
+	}
+	if err := db.CreateApplicationVersionApproval(ctx, &approval); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to save approval", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, approval)
+	}
+}
+
+func getApplicationVersionPromotions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	version := getApplicationVersionFromPath(w, r)
+	if version == nil {
+		return
+	}
+	promotions, err := db.GetApplicationVersionPromotions(ctx, version.ID)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get promotions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	channels, err := db.GetApplicationChannels(ctx, version.ApplicationID)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get channels", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	response := api.ApplicationVersionPromotionsResponse{Channels: []string{}, Promotions: promotions}
+	for _, c := range channels {
+		if c.ApplicationVersionID == version.ID {
+			response.Channels = append(response.Channels, c.Channel)
+		}
+	}
+	RespondJSON(w, response)
+}
+
+func getApplicationChannels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	application := internalctx.GetApplication(ctx)
+	if channels, err := db.GetApplicationChannels(ctx, application.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get channels", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, channels)
+	}
+}
+
+// promoteApplicationVersion evaluates the promotion rule for the requested channel and stores the result.
+// If all checks pass, the channel points to the version afterwards. If no rule exists for the channel, the version
+// is promoted without any checks.
+func promoteApplicationVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	application := internalctx.GetApplication(ctx)
+	version := getApplicationVersionFromPath(w, r)
+	if version == nil {
+		return
+	}
+	request, err := JsonBody[api.PromoteApplicationVersionRequest](w, r)
+	if err != nil {
+		return
+	} else if err := api.ValidateChannelName(request.Channel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var checks []types.PromotionCheck
+	if rule, err := db.GetApplicationPromotionRule(ctx, application.ID, request.Channel); err == nil {
+		checks = rule.Checks
+	} else if !errors.Is(err, apierrors.ErrNotFound) {
+		log.Error("failed to get promotion rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	results, promoted, err := promotion.Evaluate(ctx, *version, checks)
+	if err != nil {
+		log.Error("failed to evaluate promotion rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	result := types.ApplicationVersionPromotion{
+		ApplicationVersionID: version.ID,
+		UserAccountID:        util.PtrTo(auth.CurrentUserID()),
+		Channel:              request.Channel,
+		Promoted:             promoted,
+		Results:              results,
+	}
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.CreateApplicationVersionPromotion(ctx, &result); err != nil {
+			return err
+		} else if result.Promoted {
+			return db.SetApplicationChannel(ctx, &result)
+		}
+		return nil
+	}); err != nil {
+		log.Error("failed to save promotion", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, result)
+	}
+}
+
+// getApplicationVersionFromPath returns the version from the request path if it belongs to the application in the
+// request context. Otherwise, a 404 response is written and nil is returned.
+func getApplicationVersionFromPath(w http.ResponseWriter, r *http.Request) *types.ApplicationVersion {
+	application := internalctx.GetApplication(r.Context())
+	if id, err := uuid.Parse(r.PathValue("applicationVersionId")); err == nil {
+		for _, version := range application.Versions {
+			if version.ID == id {
+				return &version
+			}
+		}
+	}
+	http.NotFound(w, r)
+	return nil
+}
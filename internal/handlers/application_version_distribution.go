@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/orgtime"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxVersionDistributionWeeks is the maximum number of weeks of a version distribution time series.
+const maxVersionDistributionWeeks = 104
+
+var versionDistributionGroupBys = []types.VersionDistributionGroupBy{
+	types.VersionDistributionGroupByNone,
+	types.VersionDistributionGroupByEnvironment,
+	types.VersionDistributionGroupByLabel,
+}
+
+// getApplicationVersionDistribution responds with the number of active deployments and deployment targets per version
+// of the application. The query parameters are:
+//   - groupBy: environment or label, to count production and other deployment targets, or the values of the
+//     deployment target custom field label, separately
+//   - customerUserAccountId: only count the deployment targets of this customer
+//   - weeks: respond with the distribution at the end of each of the last weeks instead of the current distribution
+//   - format: json (default) or csv
+func getApplicationVersionDistribution(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	options, err := parseVersionDistributionOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var weeks []time.Time
+	if n, err := QueryParam(r, "weeks", strconv.Atoi, Min(1), Max(maxVersionDistributionWeeks)); err == nil {
+		weeks = orgtime.Weeks(clock.Now(), n, auth.CurrentOrg().Location())
+	} else if !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := db.GetVersionDistribution(
+		ctx, *auth.CurrentOrgID(), internalctx.GetApplication(ctx).ID, options, weeks, clock.Now(),
+	)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get version distribution", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		RespondJSON(w, entries)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="version-distribution.csv"`)
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{
+			"week", "applicationVersionId", "applicationVersionName", "group", "deploymentCount",
+			"deploymentTargetCount",
+		})
+		for _, entry := range entries {
+			var group string
+			if entry.Group != nil {
+				group = *entry.Group
+			}
+			_ = cw.Write([]string{
+				formatOptionalTime(entry.Week),
+				entry.ApplicationVersionID.String(),
+				entry.ApplicationVersionName,
+				group,
+				strconv.Itoa(entry.DeploymentCount),
+				strconv.Itoa(entry.DeploymentTargetCount),
+			})
+		}
+		if cw.Flush(); cw.Error() != nil {
+			internalctx.GetLogger(ctx).Warn("failed to write csv", zap.Error(cw.Error()))
+		}
+	default:
+		http.Error(w, "format must be one of json, csv", http.StatusBadRequest)
+	}
+}
+
+func parseVersionDistributionOptions(r *http.Request) (options types.VersionDistributionOptions, err error) {
+	options.GroupBy = types.VersionDistributionGroupBy(r.URL.Query().Get("groupBy"))
+	if !slices.Contains(versionDistributionGroupBys, options.GroupBy) {
+		return options, fmt.Errorf("groupBy must be one of %v", versionDistributionGroupBys[1:])
+	}
+	if options.GroupBy == types.VersionDistributionGroupByLabel {
+		if options.Label = r.URL.Query().Get("label"); options.Label == "" {
+			return options, errors.New("label is required if groupBy is label")
+		}
+	}
+	options.CustomerID, err = OptionalQueryParam(r, "customerUserAccountId", uuid.Parse)
+	return
+}
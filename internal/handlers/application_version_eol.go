@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/appversion"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var errNoVersionEOLRecipients = errors.New("no customers to notify")
+
+// applicationVersionEOLRouter reports which customers still run application versions that reach their end of life
+// and notifies them. Notifications are queued and sent by the VersionEOLNotification job.
+func applicationVersionEOLRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor)
+	r.Get("/report", getApplicationVersionEOLReport)
+	r.Get("/campaigns", getApplicationVersionEOLCampaigns)
+	r.With(requireApplicationNotDeleted).Post("/campaigns", createApplicationVersionEOLCampaign)
+}
+
+// getApplicationVersionEOLReport responds with the deployments of customers that currently run one of the selected
+// versions. The versions are selected with the applicationVersionId (repeatable) or versionConstraint query
+// parameters. With format=csv, the report is exported as CSV instead of JSON.
+func getApplicationVersionEOLReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	selection := api.VersionEOLSelection{VersionConstraint: r.URL.Query().Get("versionConstraint")}
+	for _, value := range r.URL.Query()["applicationVersionId"] {
+		if id, err := uuid.Parse(value); err != nil {
+			http.Error(w, "invalid applicationVersionId", http.StatusBadRequest)
+			return
+		} else {
+			selection.ApplicationVersionIDs = append(selection.ApplicationVersionIDs, id)
+		}
+	}
+	versions, err := resolveVersionEOLSelection(w, internalctx.GetApplication(ctx), selection)
+	if err != nil {
+		return
+	}
+
+	entries, err := db.GetVersionEOLReport(ctx, *auth.CurrentOrgID(), versionIDs(versions), nil)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get version EOL report", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		RespondJSON(w, api.VersionEOLReport{ApplicationVersions: versions, Entries: entries})
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="version-eol-report.csv"`)
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{
+			"customerEmail", "customerName", "deploymentTargetId", "deploymentTargetName", "deploymentTargetLastSeenAt",
+			"applicationVersionName", "notifiedAt",
+		})
+		for _, entry := range entries {
+			_ = cw.Write([]string{
+				entry.CustomerEmail,
+				entry.CustomerName,
+				entry.DeploymentTargetID.String(),
+				entry.DeploymentTargetName,
+				formatOptionalTime(entry.DeploymentTargetLastSeenAt),
+				entry.ApplicationVersionName,
+				formatOptionalTime(entry.NotifiedAt),
+			})
+		}
+		if cw.Flush(); cw.Error() != nil {
+			internalctx.GetLogger(ctx).Warn("failed to write csv", zap.Error(cw.Error()))
+		}
+	default:
+		http.Error(w, "format must be one of json, csv", http.StatusBadRequest)
+	}
+}
+
+func getApplicationVersionEOLCampaigns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	application := internalctx.GetApplication(ctx)
+	if campaigns, err := db.GetVersionEOLCampaigns(ctx, application.ID, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get version EOL campaigns", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, campaigns)
+	}
+}
+
+// createApplicationVersionEOLCampaign queues a notification for every customer that runs one of the selected versions
+// and has not been notified about it by an earlier campaign.
+func createApplicationVersionEOLCampaign(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	application := internalctx.GetApplication(ctx)
+	request, err := JsonBody[api.CreateVersionEOLCampaignRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	versions, err := resolveVersionEOLSelection(w, application, request.VersionEOLSelection)
+	if err != nil {
+		return
+	}
+
+	userID := auth.CurrentUserID()
+	campaign := types.VersionEOLCampaignWithProgress{
+		VersionEOLCampaign: types.VersionEOLCampaign{
+			OrganizationID:         *auth.CurrentOrgID(),
+			ApplicationID:          application.ID,
+			CreatedByUserAccountID: &userID,
+			ApplicationVersionIDs:  versionIDs(versions),
+			EndOfLifeAt:            request.EndOfLifeAt,
+			Message:                request.Message,
+		},
+	}
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		count, err := db.CreateVersionEOLCampaign(ctx, &campaign.VersionEOLCampaign)
+		if err != nil {
+			return err
+		} else if count == 0 {
+			return errNoVersionEOLRecipients
+		}
+		campaign.CustomerCount = count
+		campaign.PendingCustomerCount = count
+		campaign.RemainingCustomerCount = count
+		return nil
+	})
+	if errors.Is(err, errNoVersionEOLRecipients) {
+		http.Error(w, "all customers that run these versions have already been notified", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create version EOL campaign", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, campaign)
+	}
+}
+
+// resolveVersionEOLSelection returns the versions of application that are selected. The error has already been
+// written to w.
+func resolveVersionEOLSelection(
+	w http.ResponseWriter,
+	application *types.Application,
+	selection api.VersionEOLSelection,
+) ([]types.ApplicationVersion, error) {
+	if err := selection.Validate(); err != nil {
+		return nil, badRequestError(w, err.Error())
+	}
+	if selection.VersionConstraint != "" {
+		versions, err := appversion.Matching(application.Versions, selection.VersionConstraint)
+		if err != nil {
+			return nil, badRequestError(w, err.Error())
+		} else if len(versions) == 0 {
+			return nil, badRequestError(w, "no version matches the version constraint")
+		}
+		return versions, nil
+	}
+	versions := make([]types.ApplicationVersion, 0, len(selection.ApplicationVersionIDs))
+	for _, id := range selection.ApplicationVersionIDs {
+		i := slices.IndexFunc(application.Versions, func(v types.ApplicationVersion) bool { return v.ID == id })
+		if i < 0 {
+			return nil, badRequestError(w, fmt.Sprintf("application has no version with ID %v", id))
+		}
+		versions = append(versions, application.Versions[i])
+	}
+	return versions, nil
+}
+
+func versionIDs(versions []types.ApplicationVersion) []uuid.UUID {
+	result := make([]uuid.UUID, len(versions))
+	for i, v := range versions {
+		result[i] = v.ID
+	}
+	return result
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -6,15 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/api"
 	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/appversion"
 	"github.com/glasskube/distr/internal/auth"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/secretref"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/go-chi/chi/v5"
@@ -31,25 +34,44 @@ func ApplicationsRouter(r chi.Router) {
 		r.With(applicationMiddleware).Group(func(r chi.Router) {
 			r.Get("/", getApplication)
 			r.With(requireUserRoleVendor).Group(func(r chi.Router) {
+				r.Get("/deletion-impact", getApplicationDeletionImpact)
+				r.Get("/version-distribution", getApplicationVersionDistribution)
 				r.Delete("/", deleteApplication)
-				r.Put("/", updateApplication)
-				r.Patch("/", patchApplicationHandler())
-				r.Patch("/image", patchImageApplication)
+				r.Post("/restore", restoreApplication)
+				r.With(requireApplicationNotDeleted).Group(func(r chi.Router) {
+					r.Put("/", updateApplication)
+					r.Patch("/", patchApplicationHandler())
+					r.Patch("/image", patchImageApplication)
+				})
 			})
+			r.Route("/promotion-rules", applicationPromotionRulesRouter)
+			r.Get("/channels", getApplicationChannels)
+			r.Route("/badge", applicationBadgeRouter)
+			r.Route("/metric-alert-rules", applicationMetricAlertRulesRouter)
+			r.Route("/dependencies", applicationDependenciesRouter)
+			r.Route("/eol", applicationVersionEOLRouter)
 		})
 		r.Route("/versions", func(r chi.Router) {
 			// note that it would not be necessary to use the applicationMiddleware for the versions endpoints
 			// it loads the application from the db including all versions, but I guess for now this is easier
 			// when performance becomes more important, we should avoid this and do the request on the database layer
 			r.With(applicationMiddleware).Group(func(r chi.Router) {
-				r.With(requireUserRoleVendor).Post("/", createApplicationVersion)
+				r.With(requireUserRoleVendor, requireApplicationNotDeleted, multipartUpload).
+					Post("/", createApplicationVersion)
+				r.With(requireUserRoleVendor).Get("/next", getNextApplicationVersion)
 			})
 			r.Route("/{applicationVersionId}", func(r chi.Router) {
-				r.Get("/", getApplicationVersion)
-				r.With(requireUserRoleVendor, applicationMiddleware).Put("/", updateApplicationVersion)
-				r.Get("/compose-file", getApplicationVersionComposeFile)
-				r.Get("/template-file", getApplicationVersionTemplateFile)
-				r.Get("/values-file", getApplicationVersionValuesFile)
+				r.With(applicationMiddleware).Group(func(r chi.Router) {
+					r.Get("/", getApplicationVersion)
+					r.With(requireUserRoleVendor, requireApplicationNotDeleted).Put("/", updateApplicationVersion)
+					r.Get("/compose-file", getApplicationVersionComposeFile)
+					r.Get("/template-file", getApplicationVersionTemplateFile)
+					r.Get("/values-file", getApplicationVersionValuesFile)
+					r.With(requireUserRoleVendor).Put("/scan", putApplicationVersionScan)
+					r.Post("/approvals", createApplicationVersionApproval)
+					r.Get("/promotions", getApplicationVersionPromotions)
+					r.With(requireUserRoleVendor, requireApplicationNotDeleted).Post("/promotions", promoteApplicationVersion)
+				})
 			})
 		})
 	})
@@ -65,6 +87,10 @@ func createApplication(w http.ResponseWriter, r *http.Request) {
 	} else if application.Name == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
+	} else if err := validateResourceRequirements(w, application.ResourceRequirements); err != nil {
+		return
+	} else if err := validateApplicationVersionPolicy(w, application.VersionPolicy); err != nil {
+		return
 	}
 
 	if err = db.CreateApplication(ctx, &application, *auth.CurrentOrgID()); err != nil {
@@ -89,6 +115,10 @@ func updateApplication(w http.ResponseWriter, r *http.Request) {
 	} else if application.Name == "" {
 		http.Error(w, "name is required", http.StatusBadRequest)
 		return
+	} else if err := validateResourceRequirements(w, application.ResourceRequirements); err != nil {
+		return
+	} else if err := validateApplicationVersionPolicy(w, application.VersionPolicy); err != nil {
+		return
 	}
 	existing := internalctx.GetApplication(ctx)
 	if application.ID == uuid.Nil {
@@ -97,6 +127,9 @@ func updateApplication(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if application.VersionPolicy == "" {
+		application.VersionPolicy = existing.VersionPolicy
+	}
 
 	if err := db.UpdateApplication(ctx, &application, *auth.CurrentOrgID()); err != nil {
 		log.Warn("could not update application", zap.Error(err))
@@ -188,10 +221,20 @@ func getApplications(w http.ResponseWriter, r *http.Request) {
 	log := internalctx.GetLogger(ctx)
 
 	org := auth.CurrentOrg()
-	var err error
+	deleted, err := QueryParam(r, "deleted", strconv.ParseBool)
+	if err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if deleted && *auth.CurrentUserRole() != types.UserRoleVendor {
+		http.Error(w, "only vendors can list deleted applications", http.StatusForbidden)
+		return
+	}
+
 	var applications []types.Application
-	if org.HasFeature(types.FeatureLicensing) && *auth.CurrentUserRole() == types.UserRoleCustomer {
-		applications, err = db.GetApplicationsWithLicenseOwnerID(ctx, auth.CurrentUserID())
+	if deleted {
+		applications, err = db.GetDeletedApplicationsByOrgID(ctx, *auth.CurrentOrgID())
+	} else if org.HasFeature(types.FeatureLicensing) && *auth.CurrentUserRole() == types.UserRoleCustomer {
+		applications, err = db.GetApplicationsWithLicenseOwnerID(ctx, auth.CurrentUserID(), *auth.CurrentOrgID())
 	} else {
 		applications, err = db.GetApplicationsByOrgID(ctx, *auth.CurrentOrgID())
 	}
@@ -200,8 +243,12 @@ func getApplications(w http.ResponseWriter, r *http.Request) {
 		log.Error("failed to get applications", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if dependencies, err := db.GetApplicationDependencies(ctx, *auth.CurrentOrgID()); err != nil {
+		log.Error("failed to get application dependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	} else {
-		RespondJSON(w, api.MapApplicationsToResponse(applications))
+		RespondJSON(w, api.MapApplicationsToResponse(applications, dependencies))
 	}
 }
 
@@ -211,32 +258,38 @@ func getApplication(w http.ResponseWriter, r *http.Request) {
 	log := internalctx.GetLogger(ctx)
 
 	org := auth.CurrentOrg()
+	application := internalctx.GetApplication(ctx)
 	if org.HasFeature(types.FeatureLicensing) && *auth.CurrentUserRole() == types.UserRoleCustomer {
-		if applicationID, err := uuid.Parse(r.PathValue("applicationId")); err != nil {
+		var err error
+		application, err = db.GetApplicationWithLicenseOwnerID(
+			ctx, auth.CurrentUserID(), *auth.CurrentOrgID(), application.ID,
+		)
+		if errors.Is(err, apierrors.ErrNotFound) {
 			http.NotFound(w, r)
 			return
-		} else {
-			application, err := db.GetApplicationWithLicenseOwnerID(ctx, auth.CurrentUserID(), applicationID)
-			if errors.Is(err, apierrors.ErrNotFound) {
-				http.NotFound(w, r)
-			} else if err != nil {
-				log.Error("failed to get application", zap.Error(err))
-				sentry.GetHubFromContext(ctx).CaptureException(err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			} else {
-				RespondJSON(w, api.AsApplication(*application))
-			}
+		} else if err != nil {
+			log.Error("failed to get application", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
 		}
+	}
+
+	if dependencies, err := db.GetApplicationDependencies(ctx, *auth.CurrentOrgID()); err != nil {
+		log.Error("failed to get application dependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	} else {
-		RespondJSON(w, api.AsApplication(*internalctx.GetApplication(ctx)))
+		response := api.AsApplication(*application)
+		response.Dependencies = dependencies[application.ID]
+		RespondJSON(w, response)
 	}
 }
 
 func getApplicationVersion(w http.ResponseWriter, r *http.Request) {
-	applicationVersionID, err := uuid.Parse(r.PathValue("applicationVersionId"))
-	if err != nil {
-		http.NotFound(w, r)
-	} else if applicationVersion, err := db.GetApplicationVersion(r.Context(), applicationVersionID); err != nil {
+	if version := getApplicationVersionFromPath(w, r); version == nil {
+		return
+	} else if applicationVersion, err := db.GetApplicationVersion(r.Context(), version.ID); err != nil {
 		if errors.Is(err, apierrors.ErrNotFound) {
 			http.NotFound(w, r)
 		} else {
@@ -251,6 +304,10 @@ func createApplicationVersion(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
 
+	if err := r.ParseMultipartForm(102400); err != nil {
+		badRequestBody(w, err)
+		return
+	}
 	body := r.FormValue("applicationversion")
 	var applicationVersion types.ApplicationVersion
 	if err := json.NewDecoder(strings.NewReader(body)).Decode(&applicationVersion); err != nil {
@@ -282,9 +339,12 @@ func createApplicationVersion(w http.ResponseWriter, r *http.Request) {
 			return
 		} else {
 			applicationVersion.ValuesFileData = data
-			if _, err := applicationVersion.ParsedValuesFile(); err != nil {
+			if values, err := applicationVersion.ParsedValuesFile(); err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
+			} else if err := secretref.ValidateValues(values); err != nil {
+				http.Error(w, fmt.Sprintf("invalid values: %v", err), http.StatusBadRequest)
+				return
 			}
 		}
 		if data, ok := readMultipartFile(w, r, "templatefile"); !ok {
@@ -296,11 +356,25 @@ func createApplicationVersion(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	normalizeAcknowledgmentMessage(&applicationVersion)
 	if err := applicationVersion.Validate(application.Type); err != nil {
 		log.Error("invalid application version", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := validateResourceRequirements(w, applicationVersion.ResourceRequirements); err != nil {
+		return
+	} else if err := validateMetricsEndpoint(w, applicationVersion.MetricsEndpoint); err != nil {
+		return
+	} else if err := validateNewApplicationVersionName(w, r, application, applicationVersion.Name); err != nil {
+		return
+	} else if err := scanForSecrets(ctx, w, types.SecretScanSourceApplicationVersion, map[string][]byte{
+		"composefile":  applicationVersion.ComposeFileData,
+		"templatefile": applicationVersion.TemplateFileData,
+		"valuesfile":   applicationVersion.ValuesFileData,
+	}); err != nil {
+		return
+	}
 
 	if err := db.CreateApplicationVersion(ctx, &applicationVersion); err != nil {
 		if errors.Is(err, apierrors.ErrNotFound) {
@@ -324,7 +398,12 @@ func updateApplicationVersion(w http.ResponseWriter, r *http.Request) {
 	applicationVersion, err := JsonBody[types.ApplicationVersion](w, r)
 	if err != nil {
 		return
+	} else if err := validateResourceRequirements(w, applicationVersion.ResourceRequirements); err != nil {
+		return
+	} else if err := validateMetricsEndpoint(w, applicationVersion.MetricsEndpoint); err != nil {
+		return
 	}
+	normalizeAcknowledgmentMessage(&applicationVersion)
 
 	applicationVersionIdFromUrl, err := uuid.Parse(r.PathValue("applicationVersionId"))
 	if err != nil {
@@ -343,6 +422,17 @@ func updateApplicationVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	} else if applicationVersion.ID == uuid.Nil {
 		applicationVersion.ID = existingVersion.ID
+	} else if applicationVersion.ID != existingVersion.ID {
+		http.Error(w, "id in body does not match id in path", http.StatusBadRequest)
+		return
+	} else if applicationVersion.Name != existingVersion.Name && appversion.Parse(applicationVersion.Name) == nil {
+		switch existing.VersionPolicy {
+		case types.ApplicationVersionPolicyStrict:
+			http.Error(w, appversion.ErrNotSemver.Error(), http.StatusBadRequest)
+			return
+		case types.ApplicationVersionPolicyWarn:
+			w.Header().Add(api.WarningHeader, appversion.ErrNotSemver.Error())
+		}
 	}
 
 	if err := db.UpdateApplicationVersion(ctx, &applicationVersion); err != nil {
@@ -371,12 +461,11 @@ func getApplicationVersionFileHandler(fileAccessor func(types.ApplicationVersion
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		log := internalctx.GetLogger(ctx)
-		applicationVersionID, err := uuid.Parse(r.PathValue("applicationVersionId"))
-		if err != nil {
-			http.NotFound(w, r)
+		version := getApplicationVersionFromPath(w, r)
+		if version == nil {
 			return
 		}
-		if v, err := db.GetApplicationVersion(ctx, applicationVersionID); errors.Is(err, apierrors.ErrNotFound) {
+		if v, err := db.GetApplicationVersion(ctx, version.ID); errors.Is(err, apierrors.ErrNotFound) {
 			http.NotFound(w, r)
 		} else if err != nil {
 			log.Error("failed to get ApplicationVersion from DB", zap.Error(err))
@@ -393,25 +482,6 @@ func getApplicationVersionFileHandler(fileAccessor func(types.ApplicationVersion
 	}
 }
 
-func deleteApplication(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	log := internalctx.GetLogger(ctx)
-	application := internalctx.GetApplication(ctx)
-	auth := auth.Authentication.Require(ctx)
-	if application.OrganizationID != *auth.CurrentOrgID() {
-		http.NotFound(w, r)
-	} else if err := db.DeleteApplicationWithID(ctx, application.ID); err != nil {
-		if errors.Is(err, apierrors.ErrConflict) {
-			http.Error(w, "could not delete Application because it is still in use", http.StatusBadRequest)
-		} else {
-			log.Warn("error deleting application", zap.Error(err))
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-	} else {
-		w.WriteHeader(http.StatusNoContent)
-	}
-}
-
 var patchImageApplication = patchImageHandler(func(ctx context.Context, body api.PatchImageRequest) (any, error) {
 	application := internalctx.GetApplication(ctx)
 	if err := db.UpdateApplicationImage(ctx, application, body.ImageID); err != nil {
@@ -421,6 +491,79 @@ var patchImageApplication = patchImageHandler(func(ctx context.Context, body api
 	}
 })
 
+func validateResourceRequirements(w http.ResponseWriter, requirements *types.ResourceRequirements) error {
+	if requirements != nil {
+		if err := requirements.Validate(); err != nil {
+			return badRequestError(w, fmt.Sprintf("invalid resource requirements: %v", err))
+		}
+	}
+	return nil
+}
+
+func validateApplicationVersionPolicy(w http.ResponseWriter, policy types.ApplicationVersionPolicy) error {
+	switch policy {
+	case "", types.ApplicationVersionPolicyLenient, types.ApplicationVersionPolicyWarn,
+		types.ApplicationVersionPolicyStrict:
+		return nil
+	default:
+		return badRequestError(w, "versionPolicy is invalid")
+	}
+}
+
+// validateNewApplicationVersionName enforces the version policy of the application for the name of a new version.
+// With the warn policy, and for versions that are forced with the strict policy, violations are only reported in the
+// warning header.
+func validateNewApplicationVersionName(
+	w http.ResponseWriter,
+	r *http.Request,
+	application *types.Application,
+	name string,
+) error {
+	err := appversion.Check(name, appversion.Highest(application.Versions))
+	if err == nil || application.VersionPolicy == types.ApplicationVersionPolicyLenient {
+		return nil
+	}
+	force, forceErr := OptionalQueryParam(r, "force", strconv.ParseBool)
+	if forceErr != nil {
+		return badRequestError(w, forceErr.Error())
+	} else if application.VersionPolicy == types.ApplicationVersionPolicyWarn ||
+		(errors.Is(err, appversion.ErrNotIncreasing) && force != nil && *force) {
+		w.Header().Add(api.WarningHeader, err.Error())
+		return nil
+	} else if errors.Is(err, appversion.ErrNotIncreasing) {
+		w.Header().Set(api.ErrorCodeHeader, api.ErrorCodeVersionNotIncreasing)
+	}
+	return badRequestError(w, err.Error())
+}
+
+// getNextApplicationVersion suggests the name of the next version by bumping the highest existing version, including
+// archived versions.
+func getNextApplicationVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	bump, err := appversion.ParseBump(r.URL.Query().Get("bump"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	highest := appversion.Highest(internalctx.GetApplication(ctx).Versions)
+	if next, err := appversion.Next(highest, bump); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to compute next application version", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.NextApplicationVersionResponse{Version: next, BasedOn: highest})
+	}
+}
+
+func validateMetricsEndpoint(w http.ResponseWriter, endpoint *types.ApplicationMetricsEndpoint) error {
+	if endpoint != nil {
+		if err := endpoint.Validate(); err != nil {
+			return badRequestError(w, fmt.Sprintf("invalid metrics endpoint: %v", err))
+		}
+	}
+	return nil
+}
+
 func applicationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func getArtifactDeletionImpact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	if impact, err := getArtifactDeletionImpactForArtifact(ctx, artifact.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get artifact deletion impact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, impact)
+	}
+}
+
+// deleteArtifact puts an artifact into the pending deletion state for ARTIFACT_DELETION_COOL_OFF. With force=true,
+// the artifact is deleted immediately instead.
+// If licenses still grant access to the artifact, the client must also pass confirm=true.
+func deleteArtifact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+
+	force, err := QueryParam(r, "force", strconv.ParseBool)
+	if err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if !force && artifact.IsPendingDeletion() {
+		http.Error(w, "the artifact is already pending deletion", http.StatusConflict)
+		return
+	}
+
+	impact, err := getArtifactDeletionImpactForArtifact(ctx, artifact.ID)
+	if err != nil {
+		log.Error("failed to get artifact deletion impact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if !confirmArtifactDeletion(w, r, impact) {
+		return
+	}
+
+	if force {
+		if err := db.DeleteArtifact(ctx, artifact.ID); errors.Is(err, apierrors.ErrNotFound) {
+			http.NotFound(w, r)
+		} else if err != nil {
+			log.Error("failed to delete artifact", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		} else if err := auditArtifactDeletion(ctx, "delete", artifact.Artifact, impact); err != nil {
+			log.Warn("could not audit artifact deletion", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	scheduledAt := time.Now().Add(env.ArtifactDeletionCoolOff())
+	if err := db.RequestArtifactDeletion(
+		ctx, &artifact.Artifact, auth.CurrentUserID(), scheduledAt,
+	); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "the artifact is already pending deletion", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Error("failed to request artifact deletion", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if err := auditArtifactDeletion(ctx, "request_deletion", artifact.Artifact, impact); err != nil {
+		log.Warn("could not audit artifact deletion request", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err := mailsending.SendArtifactDeletionRequestedMail(
+		ctx, artifact.Artifact, *auth.CurrentUser(), impact.Licenses,
+	); err != nil {
+		log.Warn("could not send artifact deletion mail", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+	}
+
+	RespondJSON(w, artifact)
+}
+
+func cancelArtifactDeletion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	if err := db.CancelArtifactDeletion(ctx, &artifact.Artifact); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "the artifact is not pending deletion", http.StatusConflict)
+	} else if err != nil {
+		log.Error("failed to cancel artifact deletion", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := auditArtifactDeletion(ctx, "cancel_deletion", artifact.Artifact, nil); err != nil {
+		log.Warn("could not audit artifact deletion cancellation", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, artifact)
+	}
+}
+
+func getArtifactDeletionImpactForArtifact(
+	ctx context.Context,
+	artifactID uuid.UUID,
+) (*types.ArtifactDeletionImpact, error) {
+	if licenses, err := db.GetArtifactLicensesForArtifact(ctx, artifactID); err != nil {
+		return nil, err
+	} else {
+		return &types.ArtifactDeletionImpact{Licenses: licenses}, nil
+	}
+}
+
+// confirmArtifactDeletion makes sure that an artifact that is still referenced by licenses is only deleted if the
+// client passed confirm=true. Otherwise, an error response is written and false is returned.
+func confirmArtifactDeletion(w http.ResponseWriter, r *http.Request, impact *types.ArtifactDeletionImpact) bool {
+	if impact.IsEmpty() {
+		return true
+	}
+	if confirm, err := QueryParam(r, "confirm", strconv.ParseBool); err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	} else if !confirm {
+		http.Error(w,
+			"the artifact is still referenced by licenses, check the deletion-impact endpoint and repeat the request "+
+				"with confirm=true",
+			http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// auditArtifactDeletion stores a step of the deletion of artifact in the audit log.
+func auditArtifactDeletion(
+	ctx context.Context,
+	action string,
+	artifact types.Artifact,
+	impact *types.ArtifactDeletionImpact,
+) error {
+	auth := auth.Authentication.Require(ctx)
+	data := map[string]any{"artifact": artifact}
+	if impact != nil {
+		data["impact"] = impact
+	}
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "Artifact",
+		ResourceID:     artifact.ID,
+		Data:           data,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/internal/apierrors"
@@ -18,13 +19,19 @@ import (
 )
 
 func ArtifactLicensesRouter(r chi.Router) {
-	r.Use(middleware.RequireOrgAndRole, requireUserRoleVendor, middleware.LicensingFeatureFlagEnabledMiddleware)
-	r.Get("/", getArtifactLicenses)
-	r.Post("/", createArtifactLicense)
+	r.Use(middleware.RequireOrgAndRole, middleware.LicensingFeatureFlagEnabledMiddleware)
+	r.With(requireUserRoleVendor).Get("/", getArtifactLicenses)
+	r.With(requireUserRoleVendor, middleware.Transaction).Post("/", createArtifactLicense)
 	r.Route("/{artifactLicenseId}", func(r chi.Router) {
 		r.With(artifactLicenseMiddleware).Group(func(r chi.Router) {
-			r.Put("/", updateArtifactLicense)
-			r.Delete("/", deleteArtifactLicense)
+			r.With(requireUserRoleVendor, middleware.Transaction).Put("/", updateArtifactLicense)
+			r.With(requireUserRoleVendor).Get("/impact", getArtifactLicenseImpact)
+			r.With(requireUserRoleVendor, middleware.Transaction).Delete("/", deleteArtifactLicense)
+			r.With(requireArtifactLicenseSeatManagement).Route("/seats", func(r chi.Router) {
+				r.Get("/", getArtifactLicenseSeats)
+				r.Post("/", createArtifactLicenseSeat)
+				r.Delete("/{userAccountId}", deleteArtifactLicenseSeat)
+			})
 		})
 	})
 }
@@ -53,30 +60,33 @@ func createArtifactLicense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	license.OrganizationID = *auth.CurrentOrgID()
+	license.SeatsUsed = 0
 
 	if err = validateLicenseSelections(license); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
+	} else if license.SeatCount != nil && *license.SeatCount < 0 {
+		http.Error(w, "Seat count must not be negative", http.StatusBadRequest)
+		return
+	} else if !validateLicenseOwner(w, r, license.OwnerUserAccountID) {
+		return
 	}
 
-	_ = db.RunTx(ctx, func(ctx context.Context) error {
-		if err := db.CreateArtifactLicense(ctx, &license.ArtifactLicenseBase); errors.Is(err, apierrors.ErrConflict) {
-			http.Error(w, "An artifact license with this name already exists", http.StatusBadRequest)
-			return err
-		} else if err != nil {
-			log.Warn("could not create artifact license", zap.Error(err))
-			sentry.GetHubFromContext(ctx).CaptureException(err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return err
-		}
+	if err := db.CreateArtifactLicense(ctx, &license.ArtifactLicenseBase); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "An artifact license with this name already exists", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Warn("could not create artifact license", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		if err := addArtifacts(ctx, license, log, w); err != nil {
-			return err
-		}
+	if err := addArtifacts(ctx, license, log, w); err != nil {
+		return
+	}
 
-		RespondJSON(w, license)
-		return nil
-	})
+	RespondJSON(w, license)
 }
 
 func updateArtifactLicense(w http.ResponseWriter, r *http.Request) {
@@ -93,6 +103,8 @@ func updateArtifactLicense(w http.ResponseWriter, r *http.Request) {
 	if err = validateLicenseSelections(license); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
+	} else if !validateLicenseOwner(w, r, license.OwnerUserAccountID) {
+		return
 	}
 
 	existing := internalctx.GetArtifactLicense(ctx)
@@ -101,33 +113,34 @@ func updateArtifactLicense(w http.ResponseWriter, r *http.Request) {
 	} else if license.ID != existing.ID {
 		w.WriteHeader(http.StatusBadRequest)
 		return
+	} else if license.SeatCount != nil && *license.SeatCount < existing.SeatsUsed {
+		http.Error(w, "Seat count must not be lower than the number of assigned seats", http.StatusBadRequest)
+		return
 	}
+	license.SeatsUsed = existing.SeatsUsed
 
-	_ = db.RunTx(ctx, func(ctx context.Context) error {
-		if err := db.UpdateArtifactLicense(ctx, &license.ArtifactLicenseBase); errors.Is(err, apierrors.ErrConflict) {
-			http.Error(w, "An artifact license with this name already exists", http.StatusBadRequest)
-			return err
-		} else if err != nil {
-			log.Warn("could not update artifact license", zap.Error(err))
-			sentry.GetHubFromContext(ctx).CaptureException(err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return err
-		}
+	if err := db.UpdateArtifactLicense(ctx, &license.ArtifactLicenseBase); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "An artifact license with this name already exists", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Warn("could not update artifact license", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		if err := db.RemoveAllArtifactsFromLicense(ctx, license.ID); err != nil {
-			log.Warn("could not update artifct license selection", zap.Error(err))
-			sentry.GetHubFromContext(ctx).CaptureException(err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return err
-		}
+	if err := db.RemoveAllArtifactsFromLicense(ctx, license.ID); err != nil {
+		log.Warn("could not update artifct license selection", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		if err := addArtifacts(ctx, license, log, w); err != nil {
-			return err
-		}
+	if err := addArtifacts(ctx, license, log, w); err != nil {
+		return
+	}
 
-		RespondJSON(w, license)
-		return nil
-	})
+	RespondJSON(w, license)
 }
 
 func validateLicenseSelections(license types.ArtifactLicense) error {
@@ -152,17 +165,13 @@ func addArtifacts(ctx context.Context, license types.ArtifactLicense, log *zap.L
 	for _, selection := range license.Artifacts {
 		if len(selection.VersionIDs) == 0 {
 			if err := db.AddArtifactToArtifactLicense(ctx, license.ID, selection.ArtifactID, nil); err != nil {
-				log.Warn("could not add version to license", zap.Error(err))
-				sentry.GetHubFromContext(ctx).CaptureException(err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respondAddArtifactError(ctx, log, w, err)
 				return err
 			}
 		}
 		for _, versionID := range selection.VersionIDs {
 			if err := db.AddArtifactToArtifactLicense(ctx, license.ID, selection.ArtifactID, &versionID); err != nil {
-				log.Warn("could not add version to license", zap.Error(err))
-				sentry.GetHubFromContext(ctx).CaptureException(err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respondAddArtifactError(ctx, log, w, err)
 				return err
 			}
 		}
@@ -170,6 +179,33 @@ func addArtifacts(ctx context.Context, license types.ArtifactLicense, log *zap.L
 	return nil
 }
 
+func respondAddArtifactError(ctx context.Context, log *zap.Logger, w http.ResponseWriter, err error) {
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.Error(w, "all artifacts and versions must belong to the organization", http.StatusBadRequest)
+	} else {
+		log.Warn("could not add version to license", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func getArtifactLicenseImpact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	license := internalctx.GetArtifactLicense(ctx)
+	auth := auth.Authentication.Require(ctx)
+	if license.OrganizationID != *auth.CurrentOrgID() {
+		http.NotFound(w, r)
+	} else if impact, err := db.GetArtifactLicenseImpact(
+		ctx, license.ID, time.Now().Add(-licenseImpactPullWindow),
+	); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get license impact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, impact)
+	}
+}
+
 func deleteArtifactLicense(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
@@ -177,11 +213,26 @@ func deleteArtifactLicense(w http.ResponseWriter, r *http.Request) {
 	auth := auth.Authentication.Require(ctx)
 	if license.OrganizationID != *auth.CurrentOrgID() {
 		http.NotFound(w, r)
-	} else if err := db.DeleteArtifactLicenseWithID(ctx, license.ID); errors.Is(err, apierrors.ErrConflict) {
+		return
+	}
+	impact, err := db.GetArtifactLicenseImpact(ctx, license.ID, time.Now().Add(-licenseImpactPullWindow))
+	if err != nil {
+		log.Error("failed to get license impact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if !confirmLicenseDeletion(w, r, impact) {
+		return
+	}
+	if err := db.DeleteArtifactLicenseWithID(ctx, license.ID); errors.Is(err, apierrors.ErrConflict) {
 		http.Error(w, "could not delete license because it is still in use", http.StatusBadRequest)
 	} else if err != nil {
 		log.Warn("error deleting license", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else if err := auditLicenseDeletion(ctx, "ArtifactLicense", license.ID, license, impact); err != nil {
+		log.Warn("could not audit license deletion", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	} else {
 		w.WriteHeader(http.StatusNoContent)
 	}
@@ -190,9 +241,12 @@ func deleteArtifactLicense(w http.ResponseWriter, r *http.Request) {
 func artifactLicenseMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		auth := auth.Authentication.Require(ctx)
 		if licenseId, err := uuid.Parse(r.PathValue("artifactLicenseId")); err != nil {
 			http.Error(w, "artifactLicenseId is not a valid UUID", http.StatusBadRequest)
-		} else if license, err := db.GetArtifactLicenseByID(ctx, licenseId); errors.Is(err, apierrors.ErrNotFound) {
+		} else if license, err := db.GetArtifactLicenseByID(
+			ctx, licenseId, *auth.CurrentOrgID(),
+		); errors.Is(err, apierrors.ErrNotFound) {
 			w.WriteHeader(http.StatusNotFound)
 		} else if err != nil {
 			internalctx.GetLogger(r.Context()).Error("failed to get license", zap.Error(err))
@@ -204,3 +258,70 @@ func artifactLicenseMiddleware(next http.Handler) http.Handler {
 		}
 	})
 }
+
+// requireArtifactLicenseSeatManagement only allows vendors and the customer owning the license to manage its seats.
+func requireArtifactLicenseSeatManagement(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		auth := auth.Authentication.Require(ctx)
+		license := internalctx.GetArtifactLicense(ctx)
+		if *auth.CurrentUserRole() != types.UserRoleVendor &&
+			(license.OwnerUserAccountID == nil || *license.OwnerUserAccountID != auth.CurrentUserID()) {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func getArtifactLicenseSeats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	license := internalctx.GetArtifactLicense(ctx)
+	if seats, err := db.GetArtifactLicenseSeats(ctx, license.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get license seats", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, seats)
+	}
+}
+
+func createArtifactLicenseSeat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	license := internalctx.GetArtifactLicense(ctx)
+	user, ok := getLicenseSeatCustomer(w, r, license.SeatCount, license.ExpiresAt)
+	if !ok {
+		return
+	}
+
+	if err := db.CreateArtifactLicenseSeat(ctx, license.ID, user.ID); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "All seats of this license are already assigned", http.StatusBadRequest)
+	} else if err != nil {
+		log.Warn("could not assign license seat", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if seats, err := db.GetArtifactLicenseSeats(ctx, license.ID); err != nil {
+		log.Warn("could not get license seats", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, seats)
+	}
+}
+
+func deleteArtifactLicenseSeat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	license := internalctx.GetArtifactLicense(ctx)
+	if userID, err := uuid.Parse(r.PathValue("userAccountId")); err != nil {
+		http.Error(w, "userAccountId is not a valid UUID", http.StatusBadRequest)
+	} else if err := db.DeleteArtifactLicenseSeat(ctx, license.ID, userID); errors.Is(err, apierrors.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Warn("could not unassign license seat", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
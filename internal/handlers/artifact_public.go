@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"go.uber.org/zap"
+)
+
+// putArtifactPublic sets whether the artifact can be pulled from the registry without authentication. Public
+// artifacts can also be pulled by customers that are not licensed for them.
+func putArtifactPublic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	body, err := JsonBody[api.ArtifactPublicRequest](w, r)
+	if err != nil {
+		return
+	}
+
+	if err := db.UpdateArtifactPublic(ctx, &artifact.Artifact, body.Public); err != nil {
+		log.Error("failed to update artifact visibility", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := auditArtifactPublic(ctx, artifact.Artifact); err != nil {
+		log.Warn("could not audit artifact visibility update", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		log.Info("artifact visibility changed",
+			zap.Stringer("artifactId", artifact.ID),
+			zap.Bool("public", artifact.Public))
+		RespondJSON(w, api.AsArtifact(*artifact))
+	}
+}
+
+func auditArtifactPublic(ctx context.Context, artifact types.Artifact) error {
+	auth := auth.Authentication.Require(ctx)
+	action := "make_public"
+	if !artifact.Public {
+		action = "make_private"
+	}
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "Artifact",
+		ResourceID:     artifact.ID,
+	})
+}
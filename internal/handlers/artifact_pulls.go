@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -29,7 +28,6 @@ func getArtifactPullsHandler() http.HandlerFunc {
 		log := internalctx.GetLogger(ctx)
 		auth := auth.Authentication.Require(ctx)
 		before := time.Now()
-		count := 50
 		if s := r.FormValue("before"); s != "" {
 			if t, err := time.Parse(time.RFC3339Nano, s); err != nil {
 				http.Error(w, "before must be a date", http.StatusBadRequest)
@@ -38,21 +36,18 @@ func getArtifactPullsHandler() http.HandlerFunc {
 				before = t
 			}
 		}
-		if s := r.FormValue("count"); s != "" {
-			if n, err := strconv.Atoi(s); err != nil {
-				http.Error(w, "limit must be a date", http.StatusBadRequest)
-				return
-			} else {
-				count = n
-			}
+		page, err := PageParam(r, db.ArtifactVersionPullsPageSpec, 50)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		pulls, err := db.GetArtifactVersionPulls(ctx, *auth.CurrentOrgID(), count, before)
+		pulls, next, err := db.GetArtifactVersionPulls(ctx, *auth.CurrentOrgID(), page, before)
 		if err != nil {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			log.Warn("could not get pulls", zap.Error(err))
 			return
 		}
-		RespondJSON(w, pulls)
+		RespondJSONPage(w, r, db.ArtifactVersionPullsPageSpec, next, pulls)
 	}
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// putArtifactRecommendedVersion points the recommended version of the artifact at a tag or digest. Pointing it at a
+// tag does not follow later pushes, because tags can not be moved in the registry.
+func putArtifactRecommendedVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	body, err := JsonBody[api.ArtifactRecommendedVersionRequest](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := db.GetArtifactVersion(ctx, artifact.OrganizationSlug, artifact.Name, body.Reference)
+	if errors.Is(err, apierrors.ErrNotFound) || (err == nil && version.ArtifactID != artifact.ID) {
+		http.Error(w, "reference must be a tag or digest of the artifact", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Error("failed to get artifact version", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	updateArtifactRecommendedVersion(w, r, &version.ID)
+}
+
+func deleteArtifactRecommendedVersion(w http.ResponseWriter, r *http.Request) {
+	updateArtifactRecommendedVersion(w, r, nil)
+}
+
+func updateArtifactRecommendedVersion(w http.ResponseWriter, r *http.Request, versionID *uuid.UUID) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	previous := artifact.RecommendedReference
+	if err := db.UpdateArtifactRecommendedVersion(ctx, &artifact.Artifact, versionID); err != nil {
+		log.Error("failed to update recommended artifact version", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := auditArtifactRecommendedVersion(ctx, artifact.Artifact, previous); err != nil {
+		log.Warn("could not audit recommended artifact version update", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		log.Info("recommended artifact version changed",
+			zap.Stringer("artifactId", artifact.ID),
+			zap.Stringp("previousReference", previous),
+			zap.Stringp("reference", artifact.RecommendedReference))
+		RespondJSON(w, api.AsArtifact(*artifact))
+	}
+}
+
+func auditArtifactRecommendedVersion(ctx context.Context, artifact types.Artifact, previous *string) error {
+	auth := auth.Authentication.Require(ctx)
+	action := "set_recommended_version"
+	if artifact.RecommendedArtifactVersionID == nil {
+		action = "clear_recommended_version"
+	}
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "Artifact",
+		ResourceID:     artifact.ID,
+		Data: map[string]any{
+			"reference":         artifact.RecommendedReference,
+			"previousReference": previous,
+		},
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"go.uber.org/zap"
+)
+
+// putArtifactTagImmutability sets whether pushes can move the tags of the artifact to a different manifest. Pushing
+// the same manifest again is always allowed.
+func putArtifactTagImmutability(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	body, err := JsonBody[api.ArtifactTagImmutabilityRequest](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.MutableTagPatterns == nil {
+		body.MutableTagPatterns = []string{}
+	}
+
+	if err := db.UpdateArtifactTagImmutability(
+		ctx, &artifact.Artifact, body.ImmutableTags, body.MutableTagPatterns,
+	); err != nil {
+		log.Error("failed to update artifact tag immutability", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := auditArtifactTagImmutability(ctx, artifact.Artifact); err != nil {
+		log.Warn("could not audit artifact tag immutability update", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		log.Info("artifact tag immutability changed",
+			zap.Stringer("artifactId", artifact.ID),
+			zap.Bool("immutableTags", artifact.ImmutableTags),
+			zap.Strings("mutableTagPatterns", artifact.MutableTagPatterns))
+		RespondJSON(w, api.AsArtifact(*artifact))
+	}
+}
+
+func auditArtifactTagImmutability(ctx context.Context, artifact types.Artifact) error {
+	auth := auth.Authentication.Require(ctx)
+	action := "make_tags_immutable"
+	if !artifact.ImmutableTags {
+		action = "make_tags_mutable"
+	}
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "Artifact",
+		ResourceID:     artifact.ID,
+	})
+}
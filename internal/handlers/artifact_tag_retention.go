@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/tagretention"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func getArtifactTagRetentionRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	if rules, err := db.GetArtifactTagRetentionRules(ctx, artifact.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get tag retention rules", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rules)
+	}
+}
+
+func createArtifactTagRetentionRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	request, err := JsonBody[api.ArtifactTagRetentionRuleRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule := newArtifactTagRetentionRule(artifact.ID, request)
+	rule.CreatedByUserAccountID = util.PtrTo(auth.CurrentUserID())
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.CreateArtifactTagRetentionRule(ctx, &rule); err != nil {
+			return err
+		}
+		return auditArtifactTagRetentionRule(ctx, "create_tag_retention_rule", rule)
+	}); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create tag retention rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rule)
+	}
+}
+
+func updateArtifactTagRetentionRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	ruleID, err := uuid.Parse(r.PathValue("ruleId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	request, err := JsonBody[api.ArtifactTagRetentionRuleRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule := newArtifactTagRetentionRule(artifact.ID, request)
+	rule.ID = ruleID
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.UpdateArtifactTagRetentionRule(ctx, &rule); err != nil {
+			return err
+		}
+		return auditArtifactTagRetentionRule(ctx, "update_tag_retention_rule", rule)
+	}); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to update tag retention rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rule)
+	}
+}
+
+func deleteArtifactTagRetentionRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	ruleID, err := uuid.Parse(r.PathValue("ruleId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		rule, err := db.GetArtifactTagRetentionRule(ctx, artifact.ID, ruleID)
+		if err != nil {
+			return err
+		} else if err := db.DeleteArtifactTagRetentionRule(ctx, artifact.ID, ruleID); err != nil {
+			return err
+		}
+		return auditArtifactTagRetentionRule(ctx, "delete_tag_retention_rule", *rule)
+	}); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete tag retention rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// dryRunArtifactTagRetentionRule responds with the tags that the rule in the request body would currently remove and
+// the tags that it would keep because they are in use. The rule is not saved and nothing is removed.
+func dryRunArtifactTagRetentionRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	request, err := JsonBody[api.ArtifactTagRetentionRuleRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule := newArtifactTagRetentionRule(artifact.ID, request)
+	if report, err := tagretention.Report(ctx, artifact.ArtifactWithDownloads, rule, clock.Now()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to evaluate tag retention rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, report)
+	}
+}
+
+func newArtifactTagRetentionRule(
+	artifactID uuid.UUID,
+	request api.ArtifactTagRetentionRuleRequest,
+) types.ArtifactTagRetentionRule {
+	return types.ArtifactTagRetentionRule{
+		ArtifactID:   artifactID,
+		TagPattern:   request.TagPattern,
+		KeepLast:     request.KeepLast,
+		UnpulledDays: request.UnpulledDays,
+		DryRun:       request.DryRun,
+	}
+}
+
+func auditArtifactTagRetentionRule(ctx context.Context, action string, rule types.ArtifactTagRetentionRule) error {
+	auth := auth.Authentication.Require(ctx)
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "Artifact",
+		ResourceID:     rule.ArtifactID,
+		Data: map[string]any{
+			"ruleId":       rule.ID,
+			"tagPattern":   rule.TagPattern,
+			"keepLast":     rule.KeepLast,
+			"unpulledDays": rule.UnpulledDays,
+			"dryRun":       rule.DryRun,
+		},
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/registry/name"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// createProxyArtifact creates an artifact that is a pull-through cache of a repository in another registry. It
+// contains nothing until its manifests are pulled from the registry.
+func createProxyArtifact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.CreateProxyArtifactRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	org := auth.CurrentOrg()
+	if org.Slug == nil {
+		http.Error(w, "the organization needs a slug to use the registry", http.StatusBadRequest)
+		return
+	}
+	repo := name.Name{OrgName: *org.Slug, ArtifactName: request.Name}.String()
+	if err := name.Validate(repo, env.RegistryNameMaxDepth()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	artifact := types.Artifact{OrganizationID: org.ID, Name: request.Name}
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.CreateArtifact(ctx, &artifact); err != nil {
+			return err
+		}
+		upstream := newArtifactUpstream(ctx, artifact.ID, request.ArtifactUpstreamRequest)
+		if err := db.UpsertArtifactUpstream(ctx, &upstream); err != nil {
+			return err
+		}
+		artifact.UpstreamRepository = &upstream.Repository
+		return auditArtifactUpstream(ctx, artifact.ID, &upstream)
+	})
+	if errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "an artifact with this name already exists", http.StatusConflict)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create proxy artifact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, artifact)
+	}
+}
+
+func getArtifactUpstream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	if upstream, err := db.GetArtifactUpstream(ctx, artifact.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get artifact upstream", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, upstream)
+	}
+}
+
+// putArtifactUpstream makes the artifact a pull-through cache of the upstream repository or replaces its upstream.
+// Registry instances pick up the change after at most the pull-through tag TTL.
+func putArtifactUpstream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	request, err := JsonBody[api.ArtifactUpstreamRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upstream := newArtifactUpstream(ctx, artifact.ID, request)
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.UpsertArtifactUpstream(ctx, &upstream); err != nil {
+			return err
+		}
+		return auditArtifactUpstream(ctx, artifact.ID, &upstream)
+	}); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to save artifact upstream", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, upstream)
+	}
+}
+
+// deleteArtifactUpstream turns the artifact into a regular artifact. Everything that has been pulled from the upstream
+// so far is kept.
+func deleteArtifactUpstream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.DeleteArtifactUpstream(ctx, artifact.ID); err != nil {
+			return err
+		}
+		return auditArtifactUpstream(ctx, artifact.ID, nil)
+	}); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete artifact upstream", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newArtifactUpstream(
+	ctx context.Context,
+	artifactID uuid.UUID,
+	request api.ArtifactUpstreamRequest,
+) types.ArtifactUpstream {
+	auth := auth.Authentication.Require(ctx)
+	return types.ArtifactUpstream{
+		ArtifactID:             artifactID,
+		UpdatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Repository:             request.Repository,
+		Username:               request.Username,
+		Password:               request.Password,
+	}
+}
+
+// auditArtifactUpstream records that the upstream of the artifact has been set, or removed if upstream is nil. The
+// credentials are not recorded.
+func auditArtifactUpstream(ctx context.Context, artifactID uuid.UUID, upstream *types.ArtifactUpstream) error {
+	auth := auth.Authentication.Require(ctx)
+	entry := types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "remove_upstream",
+		ResourceType:   "Artifact",
+		ResourceID:     artifactID,
+	}
+	if upstream != nil {
+		entry.Action = "set_upstream"
+		entry.Data = map[string]any{"repository": upstream.Repository, "username": upstream.Username}
+	}
+	return db.CreateAuditLogEntry(ctx, &entry)
+}
@@ -4,17 +4,25 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/api"
 	"github.com/glasskube/distr/internal/apierrors"
 	"github.com/glasskube/distr/internal/auth"
 	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/customdomains"
 	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/fieldset"
+	"github.com/glasskube/distr/internal/mapping"
 	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/pullcommands"
+	"github.com/glasskube/distr/internal/registry/name"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/go-chi/chi/v5"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -22,10 +30,37 @@ import (
 func ArtifactsRouter(r chi.Router) {
 	r.Use(middleware.RequireOrgAndRole)
 	r.Get("/", getArtifacts)
+	r.With(requireUserRoleVendor).Get("/name-violations", getArtifactNameViolations)
+	r.With(requireUserRoleVendor).Post("/proxies", createProxyArtifact)
 	r.Route("/{artifactId}", func(r chi.Router) {
 		r.Use(artifactMiddleware)
 		r.Get("/", getArtifact)
-		r.With(requireUserRoleVendor).Patch("/image", patchImageArtifactHandler)
+		r.Get("/versions/{reference}/pull-instructions", getArtifactPullInstructions)
+		r.With(requireUserRoleVendor).Group(func(r chi.Router) {
+			r.Patch("/image", patchImageArtifactHandler)
+			r.Get("/aliases", getArtifactAliases)
+			r.Post("/rename", renameArtifact)
+			r.Get("/deletion-impact", getArtifactDeletionImpact)
+			r.Get("/downloads", getArtifactDownloads)
+			r.Delete("/", deleteArtifact)
+			r.Post("/cancel-deletion", cancelArtifactDeletion)
+			r.Put("/recommended-version", putArtifactRecommendedVersion)
+			r.Delete("/recommended-version", deleteArtifactRecommendedVersion)
+			r.Put("/public", putArtifactPublic)
+			r.Put("/tag-immutability", putArtifactTagImmutability)
+			r.Route("/upstream", func(r chi.Router) {
+				r.Get("/", getArtifactUpstream)
+				r.Put("/", putArtifactUpstream)
+				r.Delete("/", deleteArtifactUpstream)
+			})
+			r.Route("/retention-rules", func(r chi.Router) {
+				r.Get("/", getArtifactTagRetentionRules)
+				r.Post("/", createArtifactTagRetentionRule)
+				r.Post("/dry-run", dryRunArtifactTagRetentionRule)
+				r.Put("/{ruleId}", updateArtifactTagRetentionRule)
+				r.Delete("/{ruleId}", deleteArtifactTagRetentionRule)
+			})
+		})
 	})
 }
 
@@ -34,20 +69,32 @@ func getArtifacts(w http.ResponseWriter, r *http.Request) {
 	log := internalctx.GetLogger(ctx)
 	auth := auth.Authentication.Require(ctx)
 
+	fields, err := fieldset.FromRequest[api.ArtifactsResponse](r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
 	var artifacts []types.ArtifactWithDownloads
-	var err error
 	if *auth.CurrentUserRole() == types.UserRoleCustomer && auth.CurrentOrg().HasFeature(types.FeatureLicensing) {
-		artifacts, err = db.GetArtifactsByLicenseOwnerID(ctx, *auth.CurrentOrgID(), auth.CurrentUserID())
+		artifacts, err = db.GetArtifactsByLicenseOwnerID(
+			ctx, *auth.CurrentOrgID(), auth.CurrentUserID(), query, fields,
+		)
 	} else {
-		artifacts, err = db.GetArtifactsByOrgID(ctx, *auth.CurrentOrgID())
+		artifacts, err = db.GetArtifactsByOrgID(ctx, *auth.CurrentOrgID(), query, fields)
 	}
 
 	if err != nil {
 		log.Error("failed to get artifacts", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if result, err := fieldset.Apply(fields, api.MapArtifactsToResponse(artifacts)); err != nil {
+		log.Error("failed to apply fields", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	} else {
-		RespondJSON(w, api.MapArtifactsToResponse(artifacts))
+		RespondJSON(w, result)
 	}
 }
 
@@ -56,6 +103,135 @@ func getArtifact(w http.ResponseWriter, r *http.Request) {
 	RespondJSON(w, api.AsArtifact(*internalctx.GetArtifact(ctx)))
 }
 
+const (
+	artifactDownloadsDefaultRange = 30 * 24 * time.Hour
+	artifactDownloadsMaxRange     = 366 * 24 * time.Hour
+)
+
+// getArtifactDownloads responds with the pull statistics of the artifact between the from and to query parameters,
+// which default to the last 30 days.
+func getArtifactDownloads(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	parseTime := func(s string) (time.Time, error) { return time.Parse(time.RFC3339, s) }
+
+	to := time.Now()
+	if t, err := OptionalQueryParam(r, "to", parseTime); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if t != nil {
+		to = *t
+	}
+	from := to.Add(-artifactDownloadsDefaultRange)
+	if t, err := OptionalQueryParam(r, "from", parseTime); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if t != nil {
+		from = *t
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	} else if to.Sub(from) > artifactDownloadsMaxRange {
+		http.Error(w, "the range between from and to must not exceed 366 days", http.StatusBadRequest)
+		return
+	}
+
+	if downloads, err := db.GetArtifactDownloads(ctx, artifact.ID, from, to); err != nil {
+		log.Error("failed to get artifact downloads", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, downloads)
+	}
+}
+
+// getArtifactPullInstructions returns ready-to-copy commands to pull a version of the artifact from the registry
+// domain of the organization. The reference can be a tag or a digest. Customers of organizations with licensing
+// must be licensed for the version unless the artifact is public. Customers also get a list of their access tokens that
+// can be used for pulling.
+func getArtifactPullInstructions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	artifact := internalctx.GetArtifact(ctx)
+	reference := r.PathValue("reference")
+	isCustomer := *auth.CurrentUserRole() == types.UserRoleCustomer
+
+	version, err := db.GetArtifactVersion(ctx, artifact.OrganizationSlug, artifact.Name, reference)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Error("failed to get artifact version", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if isCustomer && !artifact.Public && auth.CurrentOrg().HasFeature(types.FeatureLicensing) {
+		if err := db.CheckLicenseForArtifact(
+			ctx, artifact.OrganizationSlug, artifact.Name, reference, auth.CurrentUserID(),
+		); errors.Is(err, apierrors.ErrForbidden) {
+			http.Error(w, "you are not licensed to pull this version", http.StatusForbidden)
+			return
+		} else if err != nil {
+			log.Error("failed to check license", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	registryHost := customdomains.RegistryDomainOrDefault(*auth.CurrentOrg())
+	repository := pullcommands.Repository(registryHost, artifact.OrganizationSlug, artifact.Name)
+	digest := v1.Hash(version.ManifestBlobDigest).String()
+	result := api.ArtifactPullInstructions{
+		RegistryHost: registryHost,
+		Repository:   repository,
+		Reference:    reference,
+		Digest:       digest,
+		Commands:     pullcommands.For(repository, reference, digest),
+		Recommended:  reference == types.ArtifactRecommendedTag,
+	}
+	if !result.Recommended && artifact.RecommendedArtifactVersionID != nil {
+		if recommended, err := db.GetArtifactVersion(
+			ctx, artifact.OrganizationSlug, artifact.Name, types.ArtifactRecommendedTag,
+		); err != nil {
+			log.Warn("failed to get recommended artifact version", zap.Error(err))
+		} else {
+			result.Recommended = recommended.ManifestBlobDigest == version.ManifestBlobDigest
+		}
+	}
+
+	if isCustomer {
+		tokens, err := db.GetAccessTokens(ctx, auth.CurrentUserID(), *auth.CurrentOrgID())
+		if err != nil {
+			log.Error("failed to get access tokens", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		result.AccessTokens = []api.AccessToken{}
+		for _, token := range tokens {
+			if token.ExpiresAt == nil || token.ExpiresAt.After(now) {
+				result.AccessTokens = append(result.AccessTokens, mapping.AccessTokenToDTO(token))
+			}
+		}
+		if len(result.AccessTokens) > 0 {
+			result.AccessTokenNote = "Log in to " + registryHost + " with one of these access tokens as password " +
+				"before pulling. The token is only shown once when it is created."
+		} else {
+			result.AccessTokenNote = "You have no active access tokens. Create one in your settings to log in to " +
+				registryHost + " before pulling."
+		}
+	}
+
+	RespondJSON(w, result)
+}
+
 var patchImageArtifactHandler = patchImageHandler(func(ctx context.Context, body api.PatchImageRequest) (any, error) {
 	artifact := internalctx.GetArtifact(ctx)
 	if err := db.UpdateArtifactImage(ctx, artifact, body.ImageID); err != nil {
@@ -65,6 +241,76 @@ var patchImageArtifactHandler = patchImageHandler(func(ctx context.Context, body
 	}
 })
 
+// getArtifactNameViolations lists all artifacts of the organization that can no longer be pushed to because their
+// names were accepted before the repository name grammar was enforced.
+func getArtifactNameViolations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	artifacts, err := db.GetArtifactsByOrgID(ctx, *auth.CurrentOrgID(), "", nil)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get artifacts", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	result := []api.ArtifactNameViolation{}
+	for _, artifact := range artifacts {
+		repo := name.Name{OrgName: artifact.OrganizationSlug, ArtifactName: artifact.Name}.String()
+		if err := name.Validate(repo, env.RegistryNameMaxDepth()); err != nil {
+			result = append(result, api.ArtifactNameViolation{
+				ArtifactID:    artifact.ID,
+				Name:          artifact.Name,
+				Reason:        err.Error(),
+				SuggestedName: name.Normalize(artifact.Name),
+			})
+		}
+	}
+	RespondJSON(w, result)
+}
+
+func getArtifactAliases(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	if aliases, err := db.GetArtifactAliases(ctx, artifact.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get artifact aliases", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, aliases)
+	}
+}
+
+// renameArtifact gives an artifact a new, valid name. The previous name stays usable for pulls as an alias for
+// REGISTRY_NAME_ALIAS_DURATION.
+func renameArtifact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	artifact := internalctx.GetArtifact(ctx)
+	request, err := JsonBody[api.RenameArtifactRequest](w, r)
+	if err != nil {
+		return
+	}
+	repo := name.Name{OrgName: artifact.OrganizationSlug, ArtifactName: request.Name}.String()
+	if err := name.Validate(repo, env.RegistryNameMaxDepth()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if request.Name == artifact.Name {
+		http.Error(w, "name is unchanged", http.StatusBadRequest)
+		return
+	}
+
+	if alias, err := db.RenameArtifact(
+		ctx, &artifact.Artifact, request.Name, env.RegistryNameAliasDuration(),
+	); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "an artifact with this name already exists", http.StatusConflict)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to rename artifact", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, alias)
+	}
+}
+
 func artifactMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -13,14 +13,12 @@ import (
 	"github.com/glasskube/distr/internal/auth"
 	"github.com/glasskube/distr/internal/authjwt"
 	internalctx "github.com/glasskube/distr/internal/context"
-	"github.com/glasskube/distr/internal/customdomains"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/env"
-	"github.com/glasskube/distr/internal/mail"
 	"github.com/glasskube/distr/internal/mailsending"
-	"github.com/glasskube/distr/internal/mailtemplates"
 	"github.com/glasskube/distr/internal/middleware"
 	"github.com/glasskube/distr/internal/security"
+	"github.com/glasskube/distr/internal/securityevents"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/httprate"
@@ -34,12 +32,16 @@ func AuthRouter(r chi.Router) {
 		1*time.Minute,
 		httprate.WithKeyFuncs(httprate.KeyByRealIP, httprate.KeyByEndpoint),
 	))
-	r.Post("/login", authLoginHandler)
+	r.Route("/login", func(r chi.Router) {
+		r.Post("/", authLoginHandler)
+		r.Post("/passkey/options", authPasskeyLoginOptionsHandler)
+		r.Post("/passkey", authPasskeyLoginHandler)
+	})
 	r.Route("/register", func(r chi.Router) {
 		r.Get("/", authRegisterGetHandler())
-		r.Post("/", authRegisterHandler)
+		r.With(middleware.ReadOnlyDuringMaintenance).Post("/", authRegisterHandler)
 	})
-	r.Post("/reset", authResetPasswordHandler)
+	r.With(middleware.ReadOnlyDuringMaintenance).Post("/reset", authResetPasswordHandler)
 	r.With(middleware.SentryUser, auth.Authentication.Middleware, middleware.RequireOrgAndRole).
 		Post("/switch-context", authSwitchContextHandler())
 }
@@ -89,8 +91,10 @@ func authLoginHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+	var user *types.UserAccount
+	var loggedIn bool
 	err = db.RunTx(ctx, func(ctx context.Context) error {
-		user, err := db.GetUserAccountByEmail(ctx, request.Email)
+		user, err = db.GetUserAccountByEmail(ctx, request.Email)
 		if errors.Is(err, apierrors.ErrNotFound) {
 			http.Error(w, "invalid username or password", http.StatusBadRequest)
 			return nil
@@ -103,28 +107,10 @@ func authLoginHandler(w http.ResponseWriter, r *http.Request) {
 			return nil
 		}
 
-		var org types.OrganizationWithUserRole
-		orgs, err := db.GetOrganizationsForUser(ctx, user.ID)
-		if err != nil {
-			return err
-		} else if len(orgs) < 1 {
-			org.Name = user.Email
-			org.UserRole = types.UserRoleVendor
-			if err := db.CreateOrganization(ctx, &org.Organization); err != nil {
-				return err
-			} else if err := db.CreateUserAccountOrganizationAssignment(
-				ctx, user.ID, org.ID, org.UserRole); err != nil {
-				return err
-			}
-		} else {
-			org = orgs[0]
-		}
-
-		if _, tokenString, err := authjwt.GenerateDefaultToken(*user, org); err != nil {
-			return fmt.Errorf("token creation failed: %w", err)
-		} else if err = db.UpdateUserAccountLastLoggedIn(ctx, user.ID); err != nil {
+		if tokenString, err := createLoginToken(ctx, *user); err != nil {
 			return err
 		} else {
+			loggedIn = true
 			RespondJSON(w, api.AuthLoginResponse{Token: tokenString})
 			return nil
 		}
@@ -133,6 +119,54 @@ func authLoginHandler(w http.ResponseWriter, r *http.Request) {
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		log.Warn("user login failed", zap.Error(err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	recordLoginSecurityEvent(ctx, r, user, loggedIn)
+}
+
+// createLoginToken returns a session token for user and records the login. If the user is not a member of any
+// organization yet, a new organization is created for them. It is shared by all login methods and must be called
+// in a transaction.
+func createLoginToken(ctx context.Context, user types.UserAccount) (string, error) {
+	var org types.OrganizationWithUserRole
+	orgs, err := db.GetOrganizationsForUser(ctx, user.ID)
+	if err != nil {
+		return "", err
+	} else if len(orgs) < 1 {
+		org.Name = user.Email
+		org.UserRole = types.UserRoleVendor
+		if err := db.CreateOrganization(ctx, &org.Organization); err != nil {
+			return "", err
+		} else if err := db.CreateUserAccountOrganizationAssignment(
+			ctx, user.ID, org.ID, org.UserRole); err != nil {
+			return "", err
+		}
+	} else {
+		org = orgs[0]
+	}
+
+	if _, tokenString, err := authjwt.GenerateDefaultToken(user, org); err != nil {
+		return "", fmt.Errorf("token creation failed: %w", err)
+	} else if err = db.UpdateUserAccountLastLoggedIn(ctx, user.ID); err != nil {
+		return "", err
+	} else {
+		return tokenString, nil
+	}
+}
+
+// recordLoginSecurityEvent records a successful or failed login attempt of user, unless user is nil.
+// Security events are recorded after the login transaction, so that sending mails does not delay the commit.
+func recordLoginSecurityEvent(ctx context.Context, r *http.Request, user *types.UserAccount, loggedIn bool) {
+	var err error
+	if user != nil && loggedIn {
+		err = securityevents.Login(ctx, r, *user)
+	} else if user != nil {
+		err = securityevents.FailedLogin(ctx, r, *user)
+	}
+	if err != nil {
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		internalctx.GetLogger(ctx).Warn("could not record security event", zap.Error(err))
 	}
 }
 
@@ -173,16 +207,31 @@ func authRegisterHandler(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusInternalServerError)
 				return err
 			} else if org, err = db.CreateUserAccountWithOrganization(ctx, &userAccount); err != nil {
-				if errors.Is(err, apierrors.ErrAlreadyExists) {
-					w.WriteHeader(http.StatusBadRequest)
-				} else {
+				// apierrors.ErrAlreadyExists is handled after the transaction
+				if errors.Is(err, apierrors.ErrConflict) {
+					w.WriteHeader(http.StatusConflict)
+				} else if !errors.Is(err, apierrors.ErrAlreadyExists) {
 					sentry.GetHubFromContext(ctx).CaptureException(err)
 					w.WriteHeader(http.StatusInternalServerError)
 				}
 				return err
 			}
 			return nil
-		}); err != nil {
+		}); errors.Is(err, apierrors.ErrAlreadyExists) {
+			// the existing account may have been abandoned before its email address was verified, so the verification
+			// mail is sent again. The password of the existing account is not changed.
+			if existing, existingOrg, err := getUnverifiedUserAccountByEmail(ctx, userAccount.Email); err != nil {
+				log.Warn("could not get existing user account", zap.Error(err))
+				sentry.GetHubFromContext(ctx).CaptureException(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			} else if existing == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			} else {
+				userAccount, org = *existing, existingOrg
+			}
+		} else if err != nil {
 			log.Warn("user registration failed", zap.Error(err))
 			return
 		}
@@ -196,10 +245,29 @@ func authRegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getUnverifiedUserAccountByEmail returns the self-registered user account with the given email address and its
+// first organization, if the email address has not been verified yet. Otherwise, nil is returned.
+func getUnverifiedUserAccountByEmail(
+	ctx context.Context,
+	email string,
+) (*types.UserAccount, *types.Organization, error) {
+	if userAccount, err := db.GetUserAccountByEmail(ctx, email); err != nil {
+		return nil, nil, err
+	} else if userAccount.EmailVerifiedAt != nil || userAccount.PasswordHash == nil {
+		// invited users verify their email address by accepting the invitation
+		return nil, nil, nil
+	} else if orgs, err := db.GetOrganizationsForUser(ctx, userAccount.ID); err != nil {
+		return nil, nil, err
+	} else if len(orgs) == 0 {
+		return nil, nil, nil
+	} else {
+		return userAccount, &orgs[0].Organization, nil
+	}
+}
+
 func authResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
-	mailer := internalctx.GetMailer(ctx)
 	if request, err := JsonBody[api.AuthResetPasswordRequest](w, r); err != nil {
 		return
 	} else if err := request.Validate(); err != nil {
@@ -218,26 +286,12 @@ func authResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 		log.Error("could not send reset mail", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, "something went wrong", http.StatusInternalServerError)
-	} else if _, token, err := authjwt.GenerateResetToken(*user); err != nil {
-		log.Error("could not send reset mail", zap.Error(err))
-		sentry.GetHubFromContext(ctx).CaptureException(err)
-		http.Error(w, "something went wrong", http.StatusInternalServerError)
 	} else {
 		var org *types.Organization
-		mailOpts := []mail.MailOpt{
-			mail.To(user.Email),
-			mail.Subject("Password reset"),
-		}
 		if len(orgs) > 0 {
 			org = &orgs[0].Organization
-			if from, err := customdomains.EmailFromAddressParsedOrDefault(*org); err == nil {
-				mailOpts = append(mailOpts, mail.From(*from))
-			} else {
-				log.Warn("error parsing custom from address", zap.Error(err))
-			}
 		}
-		mailOpts = append(mailOpts, mail.HtmlBodyTemplate(mailtemplates.PasswordReset(*user, org, token)))
-		if err := mailer.Send(ctx, mail.New(mailOpts...)); err != nil {
+		if err := mailsending.SendPasswordResetMail(ctx, *user, org); err != nil {
 			log.Warn("could not send reset mail", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, "something went wrong", http.StatusInternalServerError)
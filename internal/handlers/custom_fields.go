@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/customfields"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func CustomFieldsRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor, middleware.RequireOrgAndRole)
+	r.Get("/", getCustomFieldDefinitions)
+	r.Post("/", createCustomFieldDefinition)
+	r.Get("/violations", getCustomFieldViolationsHandler)
+	r.Route("/{customFieldId}", func(r chi.Router) {
+		r.Put("/", updateCustomFieldDefinition)
+		r.Delete("/", deleteCustomFieldDefinition)
+	})
+}
+
+func getCustomFieldDefinitions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if target, err := parseCustomFieldTarget(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if defs, err := db.GetCustomFieldDefinitions(ctx, *auth.CurrentOrgID(), target); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get custom field definitions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, defs)
+	}
+}
+
+func getCustomFieldViolationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if target, err := parseCustomFieldTarget(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if violations, err := getCustomFieldViolations(ctx, *auth.CurrentOrgID(), target); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get custom field violations", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, violations)
+	}
+}
+
+func createCustomFieldDefinition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.CustomFieldDefinitionRequest](w, r)
+	if err != nil {
+		return
+	}
+	def := types.CustomFieldDefinition{
+		OrganizationID:    *auth.CurrentOrgID(),
+		Target:            request.Target,
+		Key:               request.Key,
+		Type:              request.Type,
+		Required:          request.Required,
+		EnumValues:        request.EnumValues,
+		VisibleToCustomer: request.VisibleToCustomer,
+	}
+	if err := customfields.ValidateDefinition(def); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := db.CreateCustomFieldDefinition(ctx, &def); errors.Is(err, apierrors.ErrAlreadyExists) {
+		http.Error(w, "a custom field with this key already exists", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create custom field definition", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		respondCustomFieldDefinition(w, r, def)
+	}
+}
+
+func updateCustomFieldDefinition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	id, err := uuid.Parse(r.PathValue("customFieldId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	request, err := JsonBody[api.CustomFieldDefinitionRequest](w, r)
+	if err != nil {
+		return
+	}
+	existing, err := db.GetCustomFieldDefinition(ctx, id, *auth.CurrentOrgID())
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get custom field definition", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if (request.Target != "" && request.Target != existing.Target) ||
+		(request.Key != "" && request.Key != existing.Key) {
+		http.Error(w, "target and key of a custom field can not be changed", http.StatusBadRequest)
+		return
+	}
+	def := *existing
+	def.Type = request.Type
+	def.Required = request.Required
+	def.EnumValues = request.EnumValues
+	def.VisibleToCustomer = request.VisibleToCustomer
+	if err := customfields.ValidateDefinition(def); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := db.UpdateCustomFieldDefinition(ctx, &def); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to update custom field definition", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		respondCustomFieldDefinition(w, r, def)
+	}
+}
+
+func deleteCustomFieldDefinition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if id, err := uuid.Parse(r.PathValue("customFieldId")); err != nil {
+		http.NotFound(w, r)
+	} else if err := db.DeleteCustomFieldDefinition(ctx, id, *auth.CurrentOrgID()); errors.Is(
+		err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete custom field definition", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// respondCustomFieldDefinition responds with the definition and a report of all existing values that do not
+// conform to the definitions after the change. The values themselves are left untouched.
+func respondCustomFieldDefinition(w http.ResponseWriter, r *http.Request, def types.CustomFieldDefinition) {
+	ctx := r.Context()
+	if violations, err := getCustomFieldViolations(ctx, def.OrganizationID, def.Target); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get custom field violations", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.CustomFieldDefinitionResponse{Definition: def, Violations: violations})
+	}
+}
+
+func getCustomFieldViolations(
+	ctx context.Context,
+	orgID uuid.UUID,
+	target types.CustomFieldTarget,
+) ([]types.CustomFieldViolation, error) {
+	if defs, err := db.GetCustomFieldDefinitions(ctx, orgID, target); err != nil {
+		return nil, err
+	} else if values, err := db.GetCustomFieldValues(ctx, orgID, target, nil); err != nil {
+		return nil, err
+	} else {
+		return customfields.Report(defs, values), nil
+	}
+}
+
+func parseCustomFieldTarget(r *http.Request) (types.CustomFieldTarget, error) {
+	switch target := types.CustomFieldTarget(r.URL.Query().Get("target")); target {
+	case types.CustomFieldTargetDeploymentTarget, types.CustomFieldTargetCustomer:
+		return target, nil
+	default:
+		return "", validation.NewValidationFailedError(fmt.Sprintf("invalid target: %v", target))
+	}
+}
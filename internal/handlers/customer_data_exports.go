@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/dataexport"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// customerDataExportRouter serves the data exports of the customer in the context. It is used by vendors for any of
+// their customers and by customers for themselves.
+func customerDataExportRouter(r chi.Router) {
+	r.Get("/", getCustomerDataExports)
+	r.With(middleware.Transaction).Post("/", createCustomerDataExport)
+}
+
+// DataExportsRouter serves the archives of completed data exports. The key in the download link is the only
+// credential.
+func DataExportsRouter(r chi.Router) {
+	r.Use(httprate.Limit(
+		60,
+		1*time.Minute,
+		httprate.WithKeyFuncs(httprate.KeyByRealIP, httprate.KeyByEndpoint),
+	))
+	r.Get("/{exportId}/download", downloadCustomerDataExport)
+}
+
+func getCustomerDataExports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	customer := internalctx.GetUserAccount(ctx)
+	if exports, err := db.GetCustomerDataExports(ctx, *auth.CurrentOrgID(), customer.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get data exports", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, exports)
+	}
+}
+
+// createCustomerDataExport requests an export of all data that is stored about a customer. The archive is assembled
+// asynchronously by the data export job, which sends the download link to the requester. Only one export per customer
+// can be requested per dataexport.RequestInterval.
+func createCustomerDataExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	customer := internalctx.GetUserAccount(ctx)
+	if customer.UserRole != types.UserRoleCustomer {
+		http.Error(w, "data exports are only available for customers", http.StatusBadRequest)
+		return
+	}
+
+	export := types.CustomerDataExport{
+		OrganizationID:           *auth.CurrentOrgID(),
+		CustomerUserAccountID:    customer.ID,
+		RequestedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+	}
+	if err := db.CreateCustomerDataExport(
+		ctx, &export, time.Now().Add(-dataexport.RequestInterval),
+	); errors.Is(err, apierrors.ErrQuotaExceeded) {
+		http.Error(w, "a data export has already been requested within the last 24 hours", http.StatusTooManyRequests)
+	} else if err != nil {
+		log.Error("failed to create data export", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "request_data_export",
+		ResourceType:   "UserAccount",
+		ResourceID:     customer.ID,
+		Data:           map[string]any{"export": export},
+	}); err != nil {
+		log.Warn("could not audit data export request", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, export)
+	}
+}
+
+func downloadCustomerDataExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := uuid.Parse(r.PathValue("exportId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	encrypted, err := db.GetCustomerDataExportData(ctx, id, time.Now())
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get data export", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	// a wrong key is not distinguished from a missing export
+	archive, err := dataexport.Decrypt(r.URL.Query().Get("key"), encrypted)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="data-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(archive)
+}
+
+// currentUserAccountMiddleware adds the user account of the current user in the current organization to the context.
+func currentUserAccountMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		auth := auth.Authentication.Require(ctx)
+		if userAccount, err := db.GetUserAccountWithRole(ctx, auth.CurrentUserID(), *auth.CurrentOrgID()); err != nil {
+			internalctx.GetLogger(ctx).Warn("error getting user", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		} else {
+			h.ServeHTTP(w, r.WithContext(internalctx.WithUserAccount(ctx, userAccount)))
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"slices"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/api"
@@ -15,6 +16,7 @@ import (
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -22,6 +24,11 @@ import (
 func DashboardRouter(r chi.Router) {
 	r.With(requireUserRoleVendor, middleware.RequireOrgAndRole).Group(func(r chi.Router) {
 		r.Get("/artifacts-by-customer", getArtifactsByCustomer)
+		r.Route("/aggregates", func(r chi.Router) {
+			r.Get("/", getOrganizationAggregates)
+			r.With(refreshOrganizationAggregatesRateLimit).Post("/refresh", refreshOrganizationAggregates)
+			r.Get("/{aggregateName}", getOrganizationAggregate)
+		})
 	})
 }
 
@@ -35,7 +42,7 @@ func getArtifactsByCustomer(w http.ResponseWriter, r *http.Request) {
 		log.Error("failed to get customers", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-	} else if artifacts, err := db.GetArtifactsByOrgID(ctx, *auth.CurrentOrgID()); err != nil {
+	} else if artifacts, err := db.GetArtifactsByOrgID(ctx, *auth.CurrentOrgID(), "", nil); err != nil {
 		log.Error("failed to get artifacts", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -80,3 +87,71 @@ func getArtifactsByCustomer(w http.ResponseWriter, r *http.Request) {
 		RespondJSON(w, result)
 	}
 }
+
+// getOrganizationAggregates only reads the precomputed aggregates. They are recomputed by the
+// OrganizationAggregateRefresh job.
+func getOrganizationAggregates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	if aggregates, err := db.GetOrganizationAggregates(ctx, *auth.CurrentOrgID()); err != nil {
+		log.Error("failed to get organization aggregates", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, aggregates)
+	}
+}
+
+func getOrganizationAggregate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	name := types.AggregateName(r.PathValue("aggregateName"))
+	aggregate, err := db.GetOrganizationAggregate(ctx, *auth.CurrentOrgID(), name)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		log.Error("failed to get organization aggregate", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, aggregate)
+	}
+}
+
+// refreshOrganizationAggregates only requests a refresh. The aggregates are recomputed by the next job run, before
+// any aggregates that are merely outdated.
+func refreshOrganizationAggregates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	body, err := JsonBody[api.RefreshOrganizationAggregatesRequest](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	names := body.Names
+	if len(names) == 0 {
+		names = types.AggregateNames
+	}
+	if err := db.RequestOrganizationAggregateRefresh(ctx, *auth.CurrentOrgID(), names); err != nil {
+		log.Error("failed to request organization aggregate refresh", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if aggregates, err := db.GetOrganizationAggregates(ctx, *auth.CurrentOrgID()); err != nil {
+		log.Error("failed to get organization aggregates", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, aggregates)
+	}
+}
+
+var refreshOrganizationAggregatesRateLimit = httprate.Limit(
+	10,
+	10*time.Minute,
+	httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc),
+)
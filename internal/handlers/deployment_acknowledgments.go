@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func DeploymentAcknowledgmentsRouter(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole)
+	r.Get("/", getPendingDeploymentAcknowledgments)
+}
+
+// getPendingDeploymentAcknowledgments lists the updates that are waiting for the acknowledgment of a customer.
+// Vendors see the outstanding acknowledgments of all customers, customers only their own.
+func getPendingDeploymentAcknowledgments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	var customerFilter *uuid.UUID
+	if *auth.CurrentUserRole() != types.UserRoleVendor {
+		customerFilter = util.PtrTo(auth.CurrentUserID())
+	}
+	if result, err := db.GetPendingDeploymentAcknowledgments(ctx, *auth.CurrentOrgID(), customerFilter); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get pending deployment acknowledgments", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, result)
+	}
+}
+
+// acknowledgeDeploymentRevision releases a revision that is waiting for acknowledgment. Customers acknowledge the
+// update, vendors can only override the acknowledgment with a reason. Both are recorded in the audit log.
+func acknowledgeDeploymentRevision(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	deployment := internalctx.GetDeployment(ctx)
+	revisionID, err := uuid.Parse(r.PathValue("revisionId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := JsonBody[api.AcknowledgeDeploymentRevisionRequest](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(*auth.CurrentUserRole()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := db.GetDeploymentRevision(ctx, revisionID, deployment.ID)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		log.Error("failed to get deployment revision", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := db.AcknowledgeDeploymentRevision(
+		ctx, revision, auth.CurrentUserID(), body.Override,
+	); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "the deployment revision is not waiting for acknowledgment", http.StatusConflict)
+	} else if err != nil {
+		log.Error("failed to acknowledge deployment revision", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := auditDeploymentAcknowledgment(ctx, revision.DeploymentRevision, body.Reason); err != nil {
+		log.Warn("could not audit deployment acknowledgment", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, revision)
+	}
+}
+
+// handleDeploymentAcknowledgmentRequired is called after a revision has been created that is pending acknowledgment.
+// If a customer deployed the update themselves, it is acknowledged right away. Otherwise, the customer is notified.
+func handleDeploymentAcknowledgmentRequired(ctx context.Context, revision *types.DeploymentRevision) error {
+	auth := auth.Authentication.Require(ctx)
+	if *auth.CurrentUserRole() == types.UserRoleCustomer {
+		withVersion := types.DeploymentRevisionWithVersion{DeploymentRevision: *revision}
+		if err := db.AcknowledgeDeploymentRevision(ctx, &withVersion, auth.CurrentUserID(), false); err != nil {
+			return err
+		}
+		*revision = withVersion.DeploymentRevision
+		return auditDeploymentAcknowledgment(ctx, *revision, "")
+	}
+
+	if acknowledgment, err := db.GetPendingDeploymentAcknowledgment(ctx, revision.ID); err != nil {
+		return err
+	} else if err := mailsending.SendDeploymentAcknowledgmentRequiredMail(ctx, *acknowledgment, false); err != nil {
+		internalctx.GetLogger(ctx).Warn("could not send deployment acknowledgment mail", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+	}
+	return nil
+}
+
+func auditDeploymentAcknowledgment(ctx context.Context, revision types.DeploymentRevision, reason string) error {
+	auth := auth.Authentication.Require(ctx)
+	action := "acknowledge"
+	data := map[string]any{"deploymentId": revision.DeploymentID}
+	if revision.AcknowledgmentOverridden {
+		action = "override_acknowledgment"
+		data["reason"] = reason
+	}
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         action,
+		ResourceType:   "DeploymentRevision",
+		ResourceID:     revision.ID,
+		Data:           data,
+	})
+}
+
+// normalizeAcknowledgmentMessage removes an acknowledgment message that is blank, so that it does not require
+// acknowledgment.
+func normalizeAcknowledgmentMessage(version *types.ApplicationVersion) {
+	if version.AcknowledgmentMessage != nil {
+		if message := strings.TrimSpace(*version.AcknowledgmentMessage); message == "" {
+			version.AcknowledgmentMessage = nil
+		} else {
+			version.AcknowledgmentMessage = &message
+		}
+	}
+}
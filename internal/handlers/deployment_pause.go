@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// pauseDeployment stops the agent from applying the deployment, e.g. while it is patched manually during an incident.
+// The agent keeps reporting the status and new revisions are rejected until the deployment is resumed.
+func pauseDeployment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deployment := internalctx.GetDeployment(ctx)
+	pause, err := pauseFromRequest(w, r)
+	if err != nil {
+		return
+	} else if deployment.ArchivedAt != nil {
+		http.Error(w, "Deployment is archived", http.StatusBadRequest)
+		return
+	} else if deployment.UninstallRequestedAt != nil {
+		http.Error(w, "Deployment is uninstalled", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.PauseDeployment(ctx, deployment, *pause); err != nil {
+		respondPauseError(ctx, w, err)
+	} else if err := auditPause(ctx, "Deployment", deployment.ID, &deployment.Pause); err != nil {
+		respondPauseError(ctx, w, err)
+	} else {
+		RespondJSON(w, deployment)
+	}
+}
+
+// resumeDeployment ends the pause of the deployment. It stays paused if its deployment target is paused.
+func resumeDeployment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deployment := internalctx.GetDeployment(ctx)
+	if err := db.ResumeDeployment(ctx, deployment); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "Deployment is not paused", http.StatusBadRequest)
+	} else if err != nil {
+		respondPauseError(ctx, w, err)
+	} else if err := auditPause(ctx, "Deployment", deployment.ID, nil); err != nil {
+		respondPauseError(ctx, w, err)
+	} else {
+		RespondJSON(w, deployment)
+	}
+}
+
+// pauseDeploymentTarget pauses all deployments of the deployment target, like pauseDeployment.
+func pauseDeploymentTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	pause, err := pauseFromRequest(w, r)
+	if err != nil {
+		return
+	} else if dt.ArchivedAt != nil {
+		http.Error(w, "DeploymentTarget is archived", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.PauseDeploymentTarget(ctx, &dt.DeploymentTarget, *pause); err != nil {
+		respondPauseError(ctx, w, err)
+	} else if err := auditPause(ctx, "DeploymentTarget", dt.ID, &dt.Pause); err != nil {
+		respondPauseError(ctx, w, err)
+	} else {
+		RespondJSON(w, dt)
+	}
+}
+
+// resumeDeploymentTarget ends the pause of the deployment target. Deployments that have been paused individually stay
+// paused.
+func resumeDeploymentTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if err := db.ResumeDeploymentTarget(ctx, &dt.DeploymentTarget); errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "DeploymentTarget is not paused", http.StatusBadRequest)
+	} else if err != nil {
+		respondPauseError(ctx, w, err)
+	} else if err := auditPause(ctx, "DeploymentTarget", dt.ID, nil); err != nil {
+		respondPauseError(ctx, w, err)
+	} else {
+		RespondJSON(w, dt)
+	}
+}
+
+// pauseFromRequest returns the pause of the current user that is requested by the body of r. The error has already
+// been written to w.
+func pauseFromRequest(w http.ResponseWriter, r *http.Request) (*types.Pause, error) {
+	auth := auth.Authentication.Require(r.Context())
+	body, err := JsonBody[api.PauseRequest](w, r)
+	if err != nil {
+		return nil, err
+	} else if err := body.Validate(); err != nil {
+		return nil, badRequestError(w, err.Error())
+	} else if body.ResumeAt != nil && !body.ResumeAt.After(clock.Now()) {
+		return nil, badRequestError(w, "resumeAt must be in the future")
+	}
+	return &types.Pause{
+		PausedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		PauseReason:           &body.Reason,
+		ResumeAt:              body.ResumeAt,
+	}, nil
+}
+
+// auditPause records that the resource has been paused, or resumed if pause is nil.
+func auditPause(ctx context.Context, resourceType string, resourceID uuid.UUID, pause *types.Pause) error {
+	auth := auth.Authentication.Require(ctx)
+	entry := types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "resume",
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+	}
+	if pause != nil {
+		entry.Action = "pause"
+		entry.Data = map[string]any{"reason": pause.PauseReason, "resumeAt": pause.ResumeAt}
+	}
+	return db.CreateAuditLogEntry(ctx, &entry)
+}
+
+func respondPauseError(ctx context.Context, w http.ResponseWriter, err error) {
+	internalctx.GetLogger(ctx).Warn("could not update pause", zap.Error(err))
+	sentry.GetHubFromContext(ctx).CaptureException(err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// pausedError rejects a change of a paused deployment or deployment target with 409 Conflict. The response contains
+// the reason of the pause, so that the user knows whom to ask before resuming it.
+func pausedError(w http.ResponseWriter, resourceType string, pause *types.Pause) error {
+	msg := resourceType + " is paused"
+	if pause.PauseReason != nil {
+		msg += ": " + *pause.PauseReason
+	}
+	if pause.ResumeAt != nil {
+		msg += " (until " + pause.ResumeAt.Format(time.RFC3339) + ")"
+	}
+	http.Error(w, msg, http.StatusConflict)
+	return errors.New(msg)
+}
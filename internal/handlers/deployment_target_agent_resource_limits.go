@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"go.uber.org/zap"
+)
+
+// putDeploymentTargetAgentResourceLimits sets the agent resource limits that override the defaults of the
+// organization. Limits that are not set in the body fall back to the defaults.
+func putDeploymentTargetAgentResourceLimits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	body, err := JsonBody[types.AgentResourceLimits](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	previous := dt.EffectiveAgentResourceLimits
+	if err := db.UpdateDeploymentTargetAgentResourceLimits(ctx, dt, body); err != nil {
+		log.Error("failed to update agent resource limits", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "update_agent_resource_limits",
+		ResourceType:   "DeploymentTarget",
+		ResourceID:     dt.ID,
+		Data: map[string]any{
+			"agentResourceLimits": body,
+			"previousEffective":   previous,
+			"effective":           dt.EffectiveAgentResourceLimits,
+		},
+	}); err != nil {
+		log.Warn("could not audit agent resource limits update", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, dt)
+	}
+}
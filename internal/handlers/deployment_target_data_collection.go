@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"go.uber.org/zap"
+)
+
+// putDeploymentTargetDataCollection sets the data collection settings of the party of the current user, i.e. vendor
+// or customer. The settings of the other party are not changed and the stricter combination of both is effective.
+func putDeploymentTargetDataCollection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	body, err := JsonBody[types.DataCollection](w, r)
+	if err != nil {
+		return
+	}
+
+	previous := dt.DataCollection
+	if err := db.UpdateDeploymentTargetDataCollection(ctx, dt, *auth.CurrentUserRole(), body); err != nil {
+		log.Error("failed to update data collection", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "update_data_collection",
+		ResourceType:   "DeploymentTarget",
+		ResourceID:     dt.ID,
+		Data: map[string]any{
+			"role":              auth.CurrentUserRole(),
+			"dataCollection":    body,
+			"previousEffective": previous,
+			"effective":         dt.DataCollection,
+		},
+	}); err != nil {
+		log.Warn("could not audit data collection update", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, dt)
+	}
+}
+
+func getDeploymentTargetDataPurges(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if purges, err := db.GetDeploymentTargetDataPurges(ctx, dt.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get data purges", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, purges)
+	}
+}
+
+// createDeploymentTargetDataPurge requests the deletion of the collected data of a deployment target.
+// The data is deleted asynchronously by the data purge job.
+func createDeploymentTargetDataPurge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	body, err := JsonBody[api.DeploymentTargetDataPurgeRequest](w, r)
+	if err != nil {
+		return
+	} else if err := body.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	purge := types.DeploymentTargetDataPurge{
+		DeploymentTargetID:       dt.ID,
+		RequestedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Logs:                     body.Logs,
+		Metrics:                  body.Metrics,
+		Diagnostics:              body.Diagnostics,
+		Inventory:                body.Inventory,
+	}
+	if err := db.CreateDeploymentTargetDataPurge(ctx, &purge); err != nil {
+		log.Error("failed to create data purge", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "request_data_purge",
+		ResourceType:   "DeploymentTarget",
+		ResourceID:     dt.ID,
+		Data:           map[string]any{"purge": purge},
+	}); err != nil {
+		log.Warn("could not audit data purge request", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, purge)
+	}
+}
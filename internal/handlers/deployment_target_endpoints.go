@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/certcheck"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DeploymentTargetEndpointsRouter must be mounted below deploymentTargetMiddleware, which takes care of authorization.
+func DeploymentTargetEndpointsRouter(r chi.Router) {
+	r.Get("/", getDeploymentTargetEndpoints)
+	r.Post("/", createDeploymentTargetEndpoint)
+	r.Route("/{endpointId}", func(r chi.Router) {
+		r.Delete("/", deleteDeploymentTargetEndpoint)
+		r.Get("/certificates", getDeploymentTargetEndpointCertificates)
+	})
+}
+
+func getDeploymentTargetEndpoints(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	endpoints, err := db.GetDeploymentTargetEndpoints(ctx, dt.ID)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get deployment target endpoints", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ids := make([]uuid.UUID, len(endpoints))
+	for i, endpoint := range endpoints {
+		ids[i] = endpoint.ID
+	}
+	certs, err := db.GetLatestDeploymentTargetEndpointCertificates(ctx, ids)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get deployment target endpoint certificates", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	result := make([]api.DeploymentTargetEndpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		result[i] = api.DeploymentTargetEndpoint{DeploymentTargetEndpoint: endpoint}
+		if cert, ok := certs[endpoint.ID]; ok {
+			result[i].Certificate = &cert
+			result[i].ExpiresInDays = util.PtrTo(certcheck.DaysUntil(cert.NotAfter, now))
+			result[i].Warning = certcheck.Warning(endpoint, &cert, now)
+		}
+	}
+	RespondJSON(w, result)
+}
+
+func createDeploymentTargetEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	request, err := JsonBody[api.CreateDeploymentTargetEndpointRequest](w, r)
+	if err != nil {
+		return
+	}
+	url, err := certcheck.ParseURL(request.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if existing, err := db.GetDeploymentTargetEndpoints(ctx, dt.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get deployment target endpoints", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if len(existing) >= api.DeploymentTargetEndpointMaxCount {
+		http.Error(w,
+			fmt.Sprintf("a deployment target can have at most %v endpoints", api.DeploymentTargetEndpointMaxCount),
+			http.StatusBadRequest)
+		return
+	}
+	endpoint := types.DeploymentTargetEndpoint{
+		DeploymentTargetID: dt.ID,
+		URL:                url,
+		SelfSignedOK:       request.SelfSignedOK,
+	}
+	if err := db.CreateDeploymentTargetEndpoint(ctx, &endpoint); errors.Is(err, apierrors.ErrAlreadyExists) {
+		http.Error(w, "this endpoint is already being monitored", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create deployment target endpoint", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.DeploymentTargetEndpoint{DeploymentTargetEndpoint: endpoint})
+	}
+}
+
+func deleteDeploymentTargetEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if id, err := uuid.Parse(r.PathValue("endpointId")); err != nil {
+		http.NotFound(w, r)
+	} else if err := db.DeleteDeploymentTargetEndpoint(ctx, id, dt.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete deployment target endpoint", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getDeploymentTargetEndpointCertificates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if id, err := uuid.Parse(r.PathValue("endpointId")); err != nil {
+		http.NotFound(w, r)
+	} else if _, err := db.GetDeploymentTargetEndpoint(ctx, id, dt.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get deployment target endpoint", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if certs, err := db.GetDeploymentTargetEndpointCertificates(ctx, id); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get deployment target endpoint certificates", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, certs)
+	}
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/customdomains"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/depgraph"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/targetexport"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func exportDeploymentTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	request, err := JsonBody[api.DeploymentTargetExportRequest](w, r)
+	if err != nil {
+		return
+	} else if err := targetexport.ValidateTransportKey(request.TransportKey); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if export, err := buildDeploymentTargetExport(ctx, dt, *auth.CurrentOrg(), request.TransportKey); err != nil {
+		log.Error("could not export DeploymentTarget", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, export)
+	}
+}
+
+func buildDeploymentTargetExport(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	org types.Organization,
+	transportKey string,
+) (*api.DeploymentTargetExport, error) {
+	export := api.DeploymentTargetExport{
+		FormatVersion:  targetexport.FormatVersion,
+		ExportedAt:     time.Now().UTC(),
+		ID:             dt.ID,
+		Name:           dt.Name,
+		Type:           dt.Type,
+		Namespace:      dt.Namespace,
+		Scope:          dt.Scope,
+		MetricsEnabled: dt.MetricsEnabled,
+		CustomFields:   dt.CustomFields,
+		Deployments:    []api.DeploymentTargetExportEntry{},
+	}
+	if dt.CreatedBy != nil {
+		export.CreatedByEmail = dt.CreatedBy.Email
+	}
+
+	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, dt.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments {
+		entry := api.DeploymentTargetExportEntry{
+			ID:                     deployment.ID,
+			ApplicationName:        deployment.ApplicationName,
+			ApplicationVersionName: deployment.ApplicationVersionName,
+			ReleaseName:            deployment.ReleaseName,
+			DockerType:             deployment.DockerType,
+			LogsEnabled:            deployment.LogsEnabled,
+		}
+		if entry.EncryptedValuesYaml, err = targetexport.Encrypt(transportKey, deployment.ValuesYaml); err != nil {
+			return nil, err
+		}
+		if entry.EncryptedEnvFileData, err = targetexport.Encrypt(transportKey, deployment.EnvFileData); err != nil {
+			return nil, err
+		}
+		if deployment.ApplicationLicenseID != nil {
+			if license, err := db.GetApplicationLicenseByID(ctx, *deployment.ApplicationLicenseID); err != nil {
+				return nil, err
+			} else {
+				entry.ApplicationLicenseName = &license.Name
+			}
+		}
+		if dt.Type == types.DeploymentTypeDocker && env.RegistryEnabled() {
+			if version, err := db.GetApplicationVersion(ctx, deployment.ApplicationVersionID); err != nil {
+				return nil, err
+			} else if compose, err := version.ParsedComposeFile(); err != nil {
+				return nil, err
+			} else {
+				entry.Images = targetexport.DigestImages(compose, customdomains.RegistryDomainOrDefault(org))
+			}
+		}
+		export.Deployments = append(export.Deployments, entry)
+	}
+	return &export, nil
+}
+
+func importDeploymentTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.DeploymentTargetImportRequest](w, r)
+	if err != nil {
+		return
+	} else if err := targetexport.ValidateTransportKey(request.TransportKey); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if request.Export.FormatVersion != targetexport.FormatVersion {
+		http.Error(w, fmt.Sprintf("unsupported export format version %v", request.Export.FormatVersion),
+			http.StatusBadRequest)
+		return
+	}
+
+	// decrypt everything up front so that a wrong transport key does not leave a half imported target behind
+	values := make([][]byte, len(request.Export.Deployments))
+	envFiles := make([][]byte, len(request.Export.Deployments))
+	for i, entry := range request.Export.Deployments {
+		if values[i], err = targetexport.Decrypt(request.TransportKey, entry.EncryptedValuesYaml); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if envFiles[i], err = targetexport.Decrypt(request.TransportKey, entry.EncryptedEnvFileData); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	export := request.Export
+	dt := types.DeploymentTargetWithCreatedBy{
+		DeploymentTarget: types.DeploymentTarget{
+			Name:           export.Name,
+			Type:           export.Type,
+			Namespace:      export.Namespace,
+			Scope:          export.Scope,
+			MetricsEnabled: export.MetricsEnabled,
+			CustomFields:   export.CustomFields,
+		},
+	}
+	if err := dt.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := api.DeploymentTargetImportResponse{Gaps: []api.DeploymentTargetImportGap{}}
+	addGap := func(deploymentID *uuid.UUID, image string, format string, args ...any) {
+		response.Gaps = append(response.Gaps, api.DeploymentTargetImportGap{
+			DeploymentID: deploymentID,
+			Image:        image,
+			Message:      fmt.Sprintf(format, args...),
+		})
+	}
+
+	if err := mergeDeploymentTargetCustomFields(ctx, &dt, nil); errors.Is(err, validation.ErrValidationFailed) {
+		addGap(nil, "", "custom fields were not imported: %v", err)
+		dt.CustomFields = nil
+	} else if err != nil {
+		handleImportError(ctx, w, err)
+		return
+	}
+
+	createdByID, err := getImportedDeploymentTargetOwner(ctx, export.CreatedByEmail)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		addGap(nil, "", "user %v does not exist in this organization, the target is owned by you instead",
+			export.CreatedByEmail)
+		createdByID = auth.CurrentUserID()
+	} else if err != nil {
+		handleImportError(ctx, w, err)
+		return
+	}
+
+	agentVersion, err := db.GetCurrentAgentVersion(ctx)
+	if err != nil {
+		handleImportError(ctx, w, err)
+		return
+	}
+	dt.AgentVersionID = &agentVersion.ID
+
+	// Keeping the IDs allows a migrated agent to recognize its existing deployments. Otherwise, they are reinstalled.
+	preserveIDs := false
+	if _, err := db.GetDeploymentTarget(ctx, export.ID, nil); errors.Is(err, apierrors.ErrNotFound) {
+		preserveIDs = true
+		dt.ID = export.ID
+		err = db.CreateDeploymentTargetWithID(ctx, &dt, *auth.CurrentOrgID(), createdByID)
+		if err != nil {
+			handleImportError(ctx, w, err)
+			return
+		}
+	} else if err != nil {
+		handleImportError(ctx, w, err)
+		return
+	} else {
+		addGap(nil, "", "a deployment target with ID %v already exists, new IDs have been generated "+
+			"and a migrated agent will reinstall all deployments", export.ID)
+		if err := db.CreateDeploymentTarget(ctx, &dt, *auth.CurrentOrgID(), createdByID); err != nil {
+			handleImportError(ctx, w, err)
+			return
+		}
+	}
+
+	applications, err := db.GetApplicationsByOrgID(ctx, *auth.CurrentOrgID())
+	if err != nil {
+		handleImportError(ctx, w, err)
+		return
+	}
+	dependencies, err := db.GetApplicationDependencies(ctx, *auth.CurrentOrgID())
+	if err != nil {
+		handleImportError(ctx, w, err)
+		return
+	}
+
+	for _, i := range sortImportedDeployments(export.Deployments, applications, dt.Type, dependencies) {
+		entry := export.Deployments[i]
+		if err := importDeployment(
+			ctx, &dt, createdByID, applications, entry, values[i], envFiles[i], preserveIDs, addGap,
+		); err != nil {
+			handleImportError(ctx, w, err)
+			return
+		}
+	}
+
+	if access, err := generateDeploymentTargetAccess(ctx, &dt.DeploymentTarget, *auth.CurrentOrg()); err != nil {
+		handleImportError(ctx, w, err)
+		return
+	} else {
+		response.Access = *access
+	}
+
+	if imported, err := db.GetDeploymentTarget(ctx, dt.ID, auth.CurrentOrgID()); err != nil {
+		handleImportError(ctx, w, err)
+	} else {
+		response.DeploymentTarget = *imported
+		log.Info("imported deployment target",
+			zap.String("deploymentTargetId", dt.ID.String()), zap.Int("gaps", len(response.Gaps)))
+		RespondJSON(w, response)
+	}
+}
+
+func importDeployment(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	createdByID uuid.UUID,
+	applications []types.Application,
+	entry api.DeploymentTargetExportEntry,
+	valuesYaml, envFileData []byte,
+	preserveID bool,
+	addGap func(deploymentID *uuid.UUID, image string, format string, args ...any),
+) error {
+	auth := auth.Authentication.Require(ctx)
+	var app *types.Application
+	var version *types.ApplicationVersion
+	for _, a := range applications {
+		if a.Name == entry.ApplicationName && a.Type == dt.Type {
+			app = &a
+			for _, v := range a.Versions {
+				if v.Name == entry.ApplicationVersionName {
+					version = &v
+					break
+				}
+			}
+			break
+		}
+	}
+	if app == nil {
+		addGap(&entry.ID, "", "application %v does not exist, the deployment was not imported", entry.ApplicationName)
+		return nil
+	} else if version == nil {
+		addGap(&entry.ID, "", "version %v of application %v does not exist, the deployment was not imported",
+			entry.ApplicationVersionName, entry.ApplicationName)
+		return nil
+	}
+
+	for _, image := range entry.Images {
+		if err := db.CheckOrganizationForArtifactManifest(
+			ctx, targetexport.ImageDigest(image), *auth.CurrentOrgID(),
+		); errors.Is(err, apierrors.ErrNotFound) {
+			addGap(&entry.ID, image, "image digest does not exist in the registry of this organization")
+		} else if err != nil {
+			return err
+		}
+	}
+
+	request := api.DeploymentRequest{
+		DeploymentTargetID:   dt.ID,
+		ApplicationVersionID: version.ID,
+		ReleaseName:          entry.ReleaseName,
+		ValuesYaml:           valuesYaml,
+		DockerType:           entry.DockerType,
+		EnvFileData:          envFileData,
+	}
+	if preserveID {
+		request.DeploymentID = util.PtrTo(entry.ID)
+	}
+
+	if entry.ApplicationLicenseName != nil {
+		if !auth.CurrentOrg().HasFeature(types.FeatureLicensing) {
+			addGap(&entry.ID, "", "licensing is not enabled, license %v was not assigned", *entry.ApplicationLicenseName)
+		} else if licenses, err := db.GetApplicationLicensesWithOrganizationID(
+			ctx, *auth.CurrentOrgID(), &app.ID,
+		); err != nil {
+			return err
+		} else {
+			for _, license := range licenses {
+				if license.Name == *entry.ApplicationLicenseName && license.IsHeldBy(createdByID) {
+					request.ApplicationLicenseID = &license.ID
+					break
+				}
+			}
+			if request.ApplicationLicenseID == nil {
+				addGap(&entry.ID, "", "license %v does not exist or is not held by the owner of the target, "+
+					"the deployment was imported without a license", *entry.ApplicationLicenseName)
+			}
+		}
+	}
+
+	if err := db.CreateDeployment(ctx, &request); err != nil {
+		return err
+	} else if _, err := db.CreateDeploymentRevision(ctx, &request); err != nil {
+		return err
+	} else if entry.LogsEnabled {
+		deployment := types.Deployment{Base: types.Base{ID: *request.DeploymentID}, LogsEnabled: true}
+		return db.UpdateDeployment(ctx, &deployment)
+	}
+	return nil
+}
+
+// sortImportedDeployments returns the indices of the exported deployments in the order in which they are imported,
+// so that the deployments of applications that other applications depend on are created first.
+func sortImportedDeployments(
+	entries []api.DeploymentTargetExportEntry,
+	applications []types.Application,
+	deploymentType types.DeploymentType,
+	dependencies map[uuid.UUID][]types.ApplicationDependency,
+) []int {
+	applicationIDs := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		for _, a := range applications {
+			if a.Name == entry.ApplicationName && a.Type == deploymentType {
+				applicationIDs[i] = a.ID
+				break
+			}
+		}
+	}
+	indices := make([]int, len(entries))
+	graph := map[int][]int{}
+	for i := range entries {
+		indices[i] = i
+		for _, dependency := range dependencies[applicationIDs[i]] {
+			for j, id := range applicationIDs {
+				if id == dependency.DependsOnApplicationID {
+					graph[i] = append(graph[i], j)
+				}
+			}
+		}
+	}
+	return depgraph.Sort(indices, func(i int) int { return i }, graph)
+}
+
+// getImportedDeploymentTargetOwner returns the ID of the user with the given email address if they are a member of
+// the current organization.
+func getImportedDeploymentTargetOwner(ctx context.Context, email string) (uuid.UUID, error) {
+	auth := auth.Authentication.Require(ctx)
+	if email == "" {
+		return uuid.Nil, apierrors.ErrNotFound
+	} else if user, err := db.GetUserAccountByEmail(ctx, email); err != nil {
+		return uuid.Nil, err
+	} else if _, err := db.GetUserAccountWithRole(ctx, user.ID, *auth.CurrentOrgID()); err != nil {
+		return uuid.Nil, err
+	} else {
+		return user.ID, nil
+	}
+}
+
+func handleImportError(ctx context.Context, w http.ResponseWriter, err error) {
+	internalctx.GetLogger(ctx).Error("could not import DeploymentTarget", zap.Error(err))
+	sentry.GetHubFromContext(ctx).CaptureException(err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func putDeploymentTargetMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	request, err := JsonBody[api.DeploymentTargetMigrationRequest](w, r)
+	if err != nil {
+		return
+	} else if err := validateMigrationConnectURL(request.ConnectURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := db.SetDeploymentTargetMigration(
+		ctx, dt.ID, *auth.CurrentOrgID(), &request.ConnectURL,
+	); err != nil {
+		internalctx.GetLogger(ctx).Error("could not set DeploymentTarget migration", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func deleteDeploymentTargetMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if err := db.SetDeploymentTargetMigration(ctx, dt.ID, *auth.CurrentOrgID(), nil); err != nil {
+		internalctx.GetLogger(ctx).Error("could not cancel DeploymentTarget migration", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validateMigrationConnectURL checks that connectURL looks like a connect URL generated by another Distr instance.
+func validateMigrationConnectURL(connectURL string) error {
+	if u, err := url.Parse(connectURL); err != nil {
+		return fmt.Errorf("invalid connectUrl: %w", err)
+	} else if u.Scheme != "https" && u.Scheme != "http" {
+		return errors.New("connectUrl must be an http or https URL")
+	} else if !strings.HasSuffix(u.Path, "/api/v1/connect") {
+		return errors.New("connectUrl must point to the connect endpoint of a Distr instance")
+	} else if _, err := uuid.Parse(u.Query().Get("targetId")); err != nil {
+		return errors.New("connectUrl must contain a valid targetId")
+	} else if u.Query().Get("targetSecret") == "" {
+		return errors.New("connectUrl must contain a targetSecret")
+	} else {
+		return nil
+	}
+}
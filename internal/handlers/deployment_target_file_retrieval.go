@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// fileRetrievalMaxAge is the time after which a file retrieval that has not been reported is abandoned
+const fileRetrievalMaxAge = 15 * time.Minute
+
+func getDeploymentTargetFileRetrievalAllowList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if allowList, err := db.GetDeploymentTargetFileRetrievalAllowList(ctx, dt.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get file retrieval allow-list", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, allowList)
+	}
+}
+
+// putDeploymentTargetFileRetrievalAllowList replaces the allow-list of the paths that may be retrieved from the host
+// of the deployment target. The allow-list is the consent of the owner of the host, so for deployment targets of
+// customers, only customers can change it.
+func putDeploymentTargetFileRetrievalAllowList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	request, err := JsonBody[api.DeploymentTargetFileRetrievalAllowListRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ownerRole := types.UserRoleVendor
+	if dt.CreatedBy != nil && dt.CreatedBy.UserRole == types.UserRoleCustomer {
+		ownerRole = types.UserRoleCustomer
+	}
+	if *auth.CurrentUserRole() != ownerRole {
+		http.Error(w, "only the owner of the deployment target can change the allow-list", http.StatusForbidden)
+		return
+	}
+
+	allowList := types.DeploymentTargetFileRetrievalAllowList{
+		DeploymentTargetID:     dt.ID,
+		UpdatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		AllowedPaths:           request.AllowedPaths,
+	}
+	if allowList.AllowedPaths == nil {
+		allowList.AllowedPaths = []string{}
+	}
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.UpdateDeploymentTargetFileRetrievalAllowList(ctx, &allowList); err != nil {
+			return err
+		}
+		return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+			OrganizationID: auth.CurrentOrgID(),
+			UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+			Action:         "update_file_retrieval_allow_list",
+			ResourceType:   "DeploymentTarget",
+			ResourceID:     dt.ID,
+			Data:           map[string]any{"allowedPaths": allowList.AllowedPaths},
+		})
+	}); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to update file retrieval allow-list", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, allowList)
+	}
+}
+
+// getDeploymentTargetFileRetrievals responds with all file retrievals of the deployment target, so that customers
+// can see every file that has been requested from their hosts. The content is not included.
+func getDeploymentTargetFileRetrievals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if retrievals, err := db.GetDeploymentTargetFileRetrievals(ctx, dt.ID, fileRetrievalMaxAge); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get file retrievals", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, retrievals)
+	}
+}
+
+// createDeploymentTargetFileRetrieval requests a single file from the host of the deployment target. The path is not
+// checked against the allow-list here, the agent denies the retrieval if it is not allowed.
+func createDeploymentTargetFileRetrieval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	request, err := JsonBody[api.DeploymentTargetFileRetrievalRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if dt.DataCollection.DiagnosticsDisabled {
+		http.Error(w, errDiagnosticsCollectionDisabled.Error(), http.StatusBadRequest)
+		return
+	}
+
+	retrieval := types.DeploymentTargetFileRetrieval{
+		DeploymentTargetID:       dt.ID,
+		RequestedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Path:                     request.Path,
+	}
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.CreateDeploymentTargetFileRetrieval(ctx, &retrieval, fileRetrievalMaxAge); err != nil {
+			return err
+		}
+		return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+			OrganizationID: auth.CurrentOrgID(),
+			UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+			Action:         "request_file_retrieval",
+			ResourceType:   "DeploymentTarget",
+			ResourceID:     dt.ID,
+			Data:           map[string]any{"fileRetrievalId": retrieval.ID, "path": retrieval.Path},
+		})
+	}); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to request file retrieval", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, retrieval)
+	}
+}
+
+// getDeploymentTargetFileRetrieval responds with a file retrieval including the redacted content of the file. Every
+// download of the content is recorded in the audit log.
+func getDeploymentTargetFileRetrieval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	id, err := uuid.Parse(r.PathValue("fileRetrievalId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var retrieval *types.DeploymentTargetFileRetrievalWithContent
+	if err := db.RunTx(ctx, func(ctx context.Context) error {
+		retrieval, err = db.GetDeploymentTargetFileRetrieval(ctx, dt.ID, id, fileRetrievalMaxAge)
+		if err != nil || retrieval.Status != types.FileRetrievalStatusCompleted {
+			return err
+		}
+		return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+			OrganizationID: auth.CurrentOrgID(),
+			UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+			Action:         "download_file_retrieval",
+			ResourceType:   "DeploymentTarget",
+			ResourceID:     dt.ID,
+			Data:           map[string]any{"fileRetrievalId": retrieval.ID, "path": retrieval.Path},
+		})
+	}); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get file retrieval", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, retrieval)
+	}
+}
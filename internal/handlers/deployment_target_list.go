@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/customfields"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// parseDeploymentTargetFilter reads the filter of a deployment target list from the query parameters. Custom field
+// filters are parsed with customfields.ParseFilter, so customers can only filter by fields that are visible to them.
+func parseDeploymentTargetFilter(
+	r *http.Request,
+	defs []types.CustomFieldDefinition,
+	forCustomer bool,
+) (filter types.DeploymentTargetFilter, err error) {
+	if filter.CustomFields, err = customfields.ParseFilter(defs, r.URL.Query(), forCustomer); err != nil {
+		return
+	}
+	if includeArchived, err := QueryParam(r, "includeArchived", strconv.ParseBool); err == nil {
+		filter.IncludeArchived = includeArchived
+	} else if !errors.Is(err, ErrParamNotDefined) {
+		return filter, err
+	}
+	if filter.Health, err = OptionalQueryParam(r, "health", parseDeploymentTargetHealth); err != nil {
+		return
+	}
+	if filter.Production, err = OptionalQueryParam(r, "production", strconv.ParseBool); err != nil {
+		return
+	}
+	if filter.Paused, err = OptionalQueryParam(r, "paused", strconv.ParseBool); err != nil {
+		return
+	}
+	if filter.ReportedAgentVersionID, err = OptionalQueryParam(r, "agentVersionId", uuid.Parse); err != nil {
+		return
+	}
+	if filter.LastSeenBefore, err = OptionalQueryParam(
+		r, "lastSeenBefore", ParseTimeFunc(time.RFC3339Nano),
+	); err != nil {
+		return
+	}
+	if filter.LastSeenAfter, err = OptionalQueryParam(
+		r, "lastSeenAfter", ParseTimeFunc(time.RFC3339Nano),
+	); err != nil {
+		return
+	}
+	if filter.ApplicationID, err = OptionalQueryParam(r, "applicationId", uuid.Parse); err != nil {
+		return
+	}
+	if filter.ApplicationVersionID, err = OptionalQueryParam(r, "applicationVersionId", uuid.Parse); err != nil {
+		return
+	}
+	filter.VersionOlderThan, err = OptionalQueryParam(r, "versionOlderThan", parseSemanticVersion)
+	return
+}
+
+func parseDeploymentTargetHealth(value string) (types.DeploymentTargetHealth, error) {
+	if health := types.DeploymentTargetHealth(value); slices.Contains(types.DeploymentTargetHealths, health) {
+		return health, nil
+	}
+	return "", fmt.Errorf("must be one of %v", types.DeploymentTargetHealths)
+}
+
+// parseSemanticVersion checks that value is a semantic version and returns it unchanged, because it is compared with
+// the version names in the database.
+func parseSemanticVersion(value string) (string, error) {
+	if _, err := semver.NewVersion(value); err != nil {
+		return "", errors.New("must be a semantic version")
+	}
+	return value, nil
+}
+
+// writeDeploymentTargetsCSV writes one row per deployment target. Deployments are listed as application@version,
+// separated by semicolons, and custom fields are written as JSON.
+func writeDeploymentTargetsCSV(ctx context.Context, w http.ResponseWriter, dts []types.DeploymentTargetWithCreatedBy) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="deployment-targets.csv"`)
+	now := time.Now()
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"id", "name", "type", "production", "createdBy", "health", "lastSeenAt", "agentVersion",
+		"reportedAgentVersionId", "deployments", "customFields", "archivedAt",
+	})
+	for _, dt := range dts {
+		var createdBy, lastSeenAt, reportedAgentVersionID, archivedAt string
+		if dt.CreatedBy != nil {
+			createdBy = dt.CreatedBy.Email
+		}
+		if dt.CurrentStatus != nil {
+			lastSeenAt = dt.CurrentStatus.CreatedAt.Format(time.RFC3339)
+		}
+		if dt.ReportedAgentVersionID != nil {
+			reportedAgentVersionID = dt.ReportedAgentVersionID.String()
+		}
+		if dt.ArchivedAt != nil {
+			archivedAt = dt.ArchivedAt.Format(time.RFC3339)
+		}
+		deployments := make([]string, len(dt.Deployments))
+		for i, d := range dt.Deployments {
+			deployments[i] = d.ApplicationName + "@" + d.ApplicationVersionName
+		}
+		customFields, _ := json.Marshal(dt.CustomFields)
+		_ = cw.Write([]string{
+			dt.ID.String(),
+			dt.Name,
+			string(dt.Type),
+			strconv.FormatBool(dt.Production),
+			createdBy,
+			string(dt.Health(now)),
+			lastSeenAt,
+			dt.AgentVersion.Name,
+			reportedAgentVersionID,
+			strings.Join(deployments, ";"),
+			string(customFields),
+			archivedAt,
+		})
+	}
+	if cw.Flush(); cw.Error() != nil {
+		internalctx.GetLogger(ctx).Warn("failed to write csv", zap.Error(cw.Error()))
+	}
+}
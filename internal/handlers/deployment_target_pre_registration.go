@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/preregistration"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/glasskube/distr/internal/validation"
+	"go.uber.org/zap"
+)
+
+// preRegisterDeploymentTarget creates a deployment target that is owned by a customer and mails the connect
+// instructions with a single-use pairing token to the customer contact. The target is awaiting connection until its
+// agent checks in for the first time and is deleted by the pre-registration job if that does not happen in time.
+func preRegisterDeploymentTarget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	orgID := *auth.CurrentOrgID()
+	request, err := JsonBody[api.DeploymentTargetPreRegistrationRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	customer, err := db.GetUserAccountWithRole(ctx, request.CustomerUserAccountID, orgID)
+	if errors.Is(err, apierrors.ErrNotFound) || (err == nil && customer.UserRole != types.UserRoleCustomer) {
+		http.Error(w, "customer not found", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		handlePreRegistrationError(ctx, w, err)
+		return
+	}
+	if request.ApplicationVersionID != nil {
+		if _, err := db.GetApplicationForApplicationVersionID(
+			ctx, *request.ApplicationVersionID, orgID,
+		); errors.Is(err, apierrors.ErrNotFound) {
+			http.Error(w, "application version not found", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			handlePreRegistrationError(ctx, w, err)
+			return
+		}
+	}
+
+	dt := types.DeploymentTargetWithCreatedBy{
+		DeploymentTarget: types.DeploymentTarget{
+			Name:      request.Name,
+			Type:      request.Type,
+			Namespace: request.Namespace,
+			Scope:     request.Scope,
+		},
+	}
+	if err := dt.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err := mergeDeploymentTargetCustomFields(ctx, &dt, nil); errors.Is(err, validation.ErrValidationFailed) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		handlePreRegistrationError(ctx, w, err)
+		return
+	}
+	agentVersion, err := db.GetCurrentAgentVersion(ctx)
+	if err != nil {
+		handlePreRegistrationError(ctx, w, err)
+		return
+	}
+	dt.AgentVersionID = &agentVersion.ID
+	if err := db.CreateDeploymentTarget(ctx, &dt, orgID, customer.ID); err != nil {
+		handlePreRegistrationError(ctx, w, err)
+		return
+	}
+
+	preRegistration := types.DeploymentTargetPreRegistration{
+		DeploymentTargetID:     dt.ID,
+		CreatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		ContactEmail:           request.ContactEmail,
+		ApplicationVersionID:   request.ApplicationVersionID,
+		ExpiresAt:              time.Now().Add(env.DeploymentTargetPreRegistrationExpiry()),
+	}
+	if preRegistration.ContactEmail == "" {
+		preRegistration.ContactEmail = customer.Email
+	}
+	token, err := preregistration.NewPairingToken(&preRegistration, env.DeploymentTargetPairingTokenValidity())
+	if err != nil {
+		handlePreRegistrationError(ctx, w, err)
+		return
+	} else if err := db.CreateDeploymentTargetPreRegistration(ctx, &preRegistration, orgID); err != nil {
+		handlePreRegistrationError(ctx, w, err)
+		return
+	}
+	dt.AwaitingConnection = true
+
+	// the transaction is rolled back if the mail can not be sent, so that no target is left that nobody knows about
+	if err := preregistration.SendConnectInstructions(
+		ctx, dt.DeploymentTarget, preRegistration, token, false,
+	); err != nil {
+		log.Warn("could not send deployment target connect instructions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, "could not send connect instructions", http.StatusInternalServerError)
+	} else if err := filterDeploymentTargetCustomFields(ctx, &dt); err != nil {
+		handlePreRegistrationError(ctx, w, err)
+	} else {
+		RespondJSON(w, api.DeploymentTargetPreRegistrationResponse{
+			DeploymentTarget: dt,
+			PreRegistration:  preRegistration,
+		})
+	}
+}
+
+func getDeploymentTargetPreRegistration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	preRegistration, err := db.GetDeploymentTargetPreRegistration(ctx, dt.ID)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		handlePreRegistrationError(ctx, w, err)
+	} else {
+		RespondJSON(w, preRegistration)
+	}
+}
+
+func handlePreRegistrationError(ctx context.Context, w http.ResponseWriter, err error) {
+	internalctx.GetLogger(ctx).Error("deployment target pre-registration failed", zap.Error(err))
+	sentry.GetHubFromContext(ctx).CaptureException(err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -1,63 +1,163 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentimage"
 	"github.com/glasskube/distr/internal/apierrors"
 	"github.com/glasskube/distr/internal/auth"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/customdomains"
+	"github.com/glasskube/distr/internal/customfields"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/fieldset"
 	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/pagination"
 	"github.com/glasskube/distr/internal/security"
 	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 func DeploymentTargetsRouter(r chi.Router) {
 	r.Use(middleware.RequireOrgAndRole)
-	r.Get("/", getDeploymentTargets)
+	r.With(replacedInV2("/deployment-targets")).Get("/", getDeploymentTargets)
 	r.Post("/", createDeploymentTarget)
+	r.With(requireUserRoleVendor, middleware.Transaction).Post("/import", importDeploymentTarget)
+	r.With(requireUserRoleVendor, middleware.Transaction).Post("/pre-register", preRegisterDeploymentTarget)
 	r.Route("/{deploymentTargetId}", func(r chi.Router) {
 		r.Use(deploymentTargetMiddleware)
 		r.Get("/", getDeploymentTarget)
 		r.Put("/", updateDeploymentTarget)
 		r.Delete("/", deleteDeploymentTarget)
 		r.Post("/access-request", createAccessForDeploymentTarget)
+		r.Post("/archive", archiveDeploymentTargetHandler(true))
+		r.Delete("/archive", archiveDeploymentTargetHandler(false))
+		r.With(middleware.Transaction).Post("/pause", pauseDeploymentTarget)
+		r.With(middleware.Transaction).Delete("/pause", resumeDeploymentTarget)
+		r.Get("/connectivity", getDeploymentTargetConnectivity)
+		r.With(requestConnectivityCheckRateLimit).Post("/connectivity", requestDeploymentTargetConnectivityCheck)
+		r.Get("/inventory", getDeploymentTargetInventory)
+		r.Get("/file-retrieval-allow-list", getDeploymentTargetFileRetrievalAllowList)
+		r.Put("/file-retrieval-allow-list", putDeploymentTargetFileRetrievalAllowList)
+		r.Route("/file-retrievals", func(r chi.Router) {
+			r.Get("/", getDeploymentTargetFileRetrievals)
+			r.With(requireUserRoleVendor).Post("/", createDeploymentTargetFileRetrieval)
+			r.Get("/{fileRetrievalId}", getDeploymentTargetFileRetrieval)
+		})
+		r.Route("/endpoints", DeploymentTargetEndpointsRouter)
+		r.With(middleware.Transaction).Put("/data-collection", putDeploymentTargetDataCollection)
+		r.With(middleware.Transaction).Put("/agent-resource-limits", putDeploymentTargetAgentResourceLimits)
+		r.Get("/data-purges", getDeploymentTargetDataPurges)
+		r.With(middleware.Transaction).Post("/data-purges", createDeploymentTargetDataPurge)
+		r.With(requireUserRoleVendor).Group(func(r chi.Router) {
+			r.Post("/export", exportDeploymentTarget)
+			r.Get("/pre-registration", getDeploymentTargetPreRegistration)
+			r.Put("/migration", putDeploymentTargetMigration)
+			r.Delete("/migration", deleteDeploymentTargetMigration)
+		})
 	})
 }
 
+func DeploymentTargetsV2Router(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole)
+	r.Get("/", getDeploymentTargets)
+}
+
+// getDeploymentTargets responds with one page of the deployment targets that match the filter in the query
+// parameters (see parseDeploymentTargetFilter). With format=csv, the page is exported as CSV instead of JSON.
 func getDeploymentTargets(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	auth := auth.Authentication.Require(ctx)
-	deploymentTargets, err := db.GetDeploymentTargets(
+	isCustomer := *auth.CurrentUserRole() == types.UserRoleCustomer
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be one of json, csv", http.StatusBadRequest)
+		return
+	}
+	defs, err := db.GetCustomFieldDefinitions(ctx, *auth.CurrentOrgID(), types.CustomFieldTargetDeploymentTarget)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get custom field definitions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	filter, err := parseDeploymentTargetFilter(r, defs, isCustomer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := PageParam(r, db.DeploymentTargetsPageSpec, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var fields fieldset.Set
+	if format != "csv" {
+		if fields, err = fieldset.FromRequest[types.DeploymentTargetWithCreatedBy](r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	deploymentTargets, next, err := db.GetDeploymentTargets(
 		ctx,
 		*auth.CurrentOrgID(),
 		auth.CurrentUserID(),
 		*auth.CurrentUserRole(),
+		filter,
+		page,
+		fields,
 	)
 	if err != nil {
 		internalctx.GetLogger(ctx).Error("failed to get DeploymentTargets", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if isCustomer {
+		for i := range deploymentTargets {
+			deploymentTargets[i].CustomFields = customfields.FilterVisible(defs, deploymentTargets[i].CustomFields)
+		}
+	}
+	if format == "csv" {
+		if err := pagination.SetNextCursor(w, db.DeploymentTargetsPageSpec, env.JWTSecret(), next); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeDeploymentTargetsCSV(ctx, w, deploymentTargets)
+	} else if result, err := fieldset.Apply(fields, deploymentTargets); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to apply fields", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
 	} else {
-		RespondJSON(w, deploymentTargets)
+		RespondJSONPage(w, r, db.DeploymentTargetsPageSpec, next, result)
 	}
 }
 
 func getDeploymentTarget(w http.ResponseWriter, r *http.Request) {
-	dt := internalctx.GetDeploymentTarget(r.Context())
-	RespondJSON(w, dt)
+	ctx := r.Context()
+	dt := *internalctx.GetDeploymentTarget(ctx)
+	if err := filterDeploymentTargetCustomFields(ctx, &dt); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get custom field definitions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, dt)
+	}
 }
 
 func createDeploymentTarget(w http.ResponseWriter, r *http.Request) {
@@ -68,16 +168,32 @@ func createDeploymentTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	} else if err = dt.Validate(); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := mergeDeploymentTargetCustomFields(ctx, &dt, nil); err != nil {
+		if errors.Is(err, validation.ErrValidationFailed) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Warn("could not merge custom fields", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	} else if agentVersion, err := db.GetCurrentAgentVersion(ctx); err != nil {
 		log.Warn("could not get current agent version", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	} else {
 		dt.AgentVersionID = &agentVersion.ID
+		if *auth.CurrentUserRole() != types.UserRoleVendor {
+			// only vendors decide which deployment targets require a deployment reason
+			dt.Production = false
+		}
 		if err = db.CreateDeploymentTarget(ctx, &dt, *auth.CurrentOrgID(), auth.CurrentUserID()); err != nil {
 			log.Warn("could not create DeploymentTarget", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if err := filterDeploymentTargetCustomFields(ctx, &dt); err != nil {
+			log.Warn("could not filter custom fields", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		} else {
 			RespondJSON(w, dt)
 		}
@@ -88,10 +204,8 @@ func updateDeploymentTarget(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
 	auth := auth.Authentication.Require(ctx)
-	var dt types.DeploymentTargetWithCreatedBy
-	if err := json.NewDecoder(r.Body).Decode(&dt); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintln(w, err)
+	dt, err := JsonBody[types.DeploymentTargetWithCreatedBy](w, r)
+	if err != nil {
 		return
 	}
 
@@ -108,11 +222,38 @@ func updateDeploymentTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if *auth.CurrentUserRole() != types.UserRoleVendor {
+		dt.Production = existing.Production
+	}
+
+	if err := mergeDeploymentTargetCustomFields(ctx, &dt, existing.CustomFields); err != nil {
+		if errors.Is(err, validation.ErrValidationFailed) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Warn("could not merge custom fields", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
 	if err := db.UpdateDeploymentTarget(ctx, &dt, *auth.CurrentOrgID()); err != nil {
 		log.Warn("could not update DeploymentTarget", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintln(w, err)
+		return
+	}
+
+	if dt.AgentVersionID != nil &&
+		(existing.AgentVersionID == nil || *existing.AgentVersionID != *dt.AgentVersionID) {
+		warnAgentVersionPlatforms(ctx, w, *auth.CurrentOrgID(), existing.Type, *dt.AgentVersionID)
+	}
+
+	if err := filterDeploymentTargetCustomFields(ctx, &dt); err != nil {
+		log.Warn("could not filter custom fields", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
 	} else if err = json.NewEncoder(w).Encode(dt); err != nil {
 		log.Error("failed to encode json", zap.Error(err))
 	}
@@ -160,38 +301,41 @@ func createAccessForDeploymentTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var targetSecret string
-	var err error
-	if targetSecret, err = security.GenerateAccessKey(); err != nil {
-		log.Error("failed to generate access key", zap.Error(err))
+	if access, err := generateDeploymentTargetAccess(
+		ctx, &deploymentTarget.DeploymentTarget, *auth.CurrentOrg(),
+	); err != nil {
+		log.Error("could not create access for DeploymentTarget", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		w.WriteHeader(http.StatusInternalServerError)
-		return
+	} else if err = json.NewEncoder(w).Encode(access); err != nil {
+		log.Error("failed to encode json", zap.Error(err))
 	}
-	if salt, hash, err := security.HashAccessKey(targetSecret); err != nil {
-		log.Error("failed to hash access key", zap.Error(err))
-		sentry.GetHubFromContext(ctx).CaptureException(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+}
+
+// generateDeploymentTargetAccess replaces the access key of the deployment target with a new one.
+func generateDeploymentTargetAccess(
+	ctx context.Context,
+	deploymentTarget *types.DeploymentTarget,
+	org types.Organization,
+) (*api.DeploymentTargetAccessTokenResponse, error) {
+	if targetSecret, err := security.GenerateAccessKey(); err != nil {
+		return nil, fmt.Errorf("failed to generate access key: %w", err)
+	} else if salt, hash, err := security.HashAccessKey(targetSecret); err != nil {
+		return nil, fmt.Errorf("failed to hash access key: %w", err)
 	} else {
 		deploymentTarget.AccessKeySalt = &salt
 		deploymentTarget.AccessKeyHash = &hash
-	}
-
-	if err := db.UpdateDeploymentTargetAccess(ctx, &deploymentTarget.DeploymentTarget, *auth.CurrentOrgID()); err != nil {
-		log.Warn("could not update DeploymentTarget", zap.Error(err))
-		sentry.GetHubFromContext(ctx).CaptureException(err)
-		w.WriteHeader(http.StatusInternalServerError)
-	} else if connectUrl, err := buildConnectUrl(deploymentTarget.ID, *auth.CurrentOrg(), targetSecret); err != nil {
-		log.Error("could not create connecturl", zap.Error(err))
-		sentry.GetHubFromContext(ctx).CaptureException(err)
-		w.WriteHeader(http.StatusInternalServerError)
-	} else if err = json.NewEncoder(w).Encode(api.DeploymentTargetAccessTokenResponse{
-		ConnectURL:   connectUrl,
-		TargetID:     deploymentTarget.ID,
-		TargetSecret: targetSecret,
-	}); err != nil {
-		log.Error("failed to encode json", zap.Error(err))
+		if err := db.UpdateDeploymentTargetAccess(ctx, deploymentTarget, org.ID); err != nil {
+			return nil, err
+		} else if connectUrl, err := buildConnectUrl(deploymentTarget.ID, org, targetSecret); err != nil {
+			return nil, fmt.Errorf("could not create connect url: %w", err)
+		} else {
+			return &api.DeploymentTargetAccessTokenResponse{
+				ConnectURL:   connectUrl,
+				TargetID:     deploymentTarget.ID,
+				TargetSecret: targetSecret,
+			}, nil
+		}
 	}
 }
 
@@ -208,6 +352,134 @@ func buildConnectUrl(targetID uuid.UUID, org types.Organization, targetSecret st
 	}
 }
 
+func getDeploymentTargetConnectivity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if check, err := db.GetDeploymentTargetConnectivityCheck(ctx, dt.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get connectivity check", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		check.ClockSkewMs = dt.ClockSkewMs
+		RespondJSON(w, check)
+	}
+}
+
+// getDeploymentTargetInventory returns the latest inventory snapshot. If the managed parameter is set, only managed or
+// only foreign workloads are returned.
+func getDeploymentTargetInventory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dt := internalctx.GetDeploymentTarget(ctx)
+	managed, err := OptionalQueryParam(r, "managed", strconv.ParseBool)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if inventory, err := db.GetDeploymentTargetInventory(ctx, dt.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get inventory", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		if managed != nil {
+			inventory.FilterManaged(*managed)
+		}
+		RespondJSON(w, inventory)
+	}
+}
+
+func requestDeploymentTargetConnectivityCheck(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	dt := internalctx.GetDeploymentTarget(ctx)
+	if dt.DataCollection.DiagnosticsDisabled {
+		http.Error(w, errDiagnosticsCollectionDisabled.Error(), http.StatusConflict)
+		return
+	}
+	check, err := db.RequestDeploymentTargetConnectivityCheck(
+		ctx, dt.ID, auth.CurrentUserID(), connectivityCheckMinInterval)
+	if errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, "a connectivity check has been requested recently, please try again later",
+			http.StatusTooManyRequests)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to request connectivity check", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, check)
+	}
+}
+
+func archiveDeploymentTargetHandler(archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := internalctx.GetLogger(ctx)
+		auth := auth.Authentication.Require(ctx)
+		dt := internalctx.GetDeploymentTarget(ctx)
+		if *auth.CurrentUserRole() != types.UserRoleVendor && dt.CreatedByUserAccountID != auth.CurrentUserID() {
+			http.Error(w, "must be vendor or creator", http.StatusForbidden)
+		} else if err := db.SetDeploymentTargetArchived(ctx, dt.ID, *auth.CurrentOrgID(), archived); errors.Is(
+			err, apierrors.ErrNotFound) {
+			http.NotFound(w, r)
+		} else if err != nil {
+			log.Warn("could not archive DeploymentTarget", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if updated, err := db.GetDeploymentTarget(ctx, dt.ID, auth.CurrentOrgID()); err != nil {
+			log.Warn("could not get DeploymentTarget", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if err := filterDeploymentTargetCustomFields(ctx, updated); err != nil {
+			log.Warn("could not filter custom fields", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			RespondJSON(w, updated)
+		}
+	}
+}
+
+// mergeDeploymentTargetCustomFields validates the custom fields of dt and merges them with the existing values.
+// Customers can only write fields that are visible to them.
+func mergeDeploymentTargetCustomFields(
+	ctx context.Context,
+	dt *types.DeploymentTargetWithCreatedBy,
+	existing types.CustomFields,
+) error {
+	auth := auth.Authentication.Require(ctx)
+	if defs, err := db.GetCustomFieldDefinitions(
+		ctx, *auth.CurrentOrgID(), types.CustomFieldTargetDeploymentTarget,
+	); err != nil {
+		return err
+	} else if merged, err := customfields.Merge(
+		defs, existing, dt.CustomFields, *auth.CurrentUserRole() == types.UserRoleCustomer,
+	); err != nil {
+		return err
+	} else {
+		dt.CustomFields = merged
+		return nil
+	}
+}
+
+// filterDeploymentTargetCustomFields removes all custom fields that are not visible to customers
+// if the current user is a customer.
+func filterDeploymentTargetCustomFields(ctx context.Context, dt *types.DeploymentTargetWithCreatedBy) error {
+	auth := auth.Authentication.Require(ctx)
+	if *auth.CurrentUserRole() != types.UserRoleCustomer {
+		return nil
+	} else if defs, err := db.GetCustomFieldDefinitions(
+		ctx, *auth.CurrentOrgID(), types.CustomFieldTargetDeploymentTarget,
+	); err != nil {
+		return err
+	} else {
+		dt.CustomFields = customfields.FilterVisible(defs, dt.CustomFields)
+		return nil
+	}
+}
+
 func deploymentTargetMiddleware(wh http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -227,9 +499,55 @@ func deploymentTargetMiddleware(wh http.Handler) http.Handler {
 			internalctx.GetLogger(ctx).Error("failed to get DeploymentTarget", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			w.WriteHeader(http.StatusInternalServerError)
+		} else if ctx, err := requireDeploymentTargetAccess(ctx, r, deploymentTarget); err != nil {
+			respondDeploymentTargetAccessError(w, r, err)
 		} else {
 			ctx = internalctx.WithDeploymentTarget(ctx, deploymentTarget)
 			wh.ServeHTTP(w, r.WithContext(ctx))
 		}
 	})
 }
+
+const (
+	// connectivityCheckMinInterval is the minimum time between two connectivity checks of the same deployment target
+	connectivityCheckMinInterval = 5 * time.Minute
+	// connectivityCheckMaxAge is the time after which a connectivity check that has not been reported is abandoned
+	connectivityCheckMaxAge = 15 * time.Minute
+)
+
+// connectivity checks make the agent open connections, so in addition to the per target interval,
+// the number of checks a single user can request across all targets is limited as well
+var requestConnectivityCheckRateLimit = httprate.Limit(
+	10,
+	time.Hour,
+	httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc),
+)
+
+// warnAgentVersionPlatforms sets a Warning header if the agent image of the pinned version is not available for all
+// platforms reported by agents of the same type in the organization.
+// Pinning is not rejected, because the image index might be temporarily unavailable and agents on a missing platform
+// keep running their current version.
+func warnAgentVersionPlatforms(
+	ctx context.Context,
+	w http.ResponseWriter,
+	orgID uuid.UUID,
+	deploymentType types.DeploymentType,
+	agentVersionID uuid.UUID,
+) {
+	log := internalctx.GetLogger(ctx)
+	if agentVersion, err := db.GetAgentVersionByID(ctx, agentVersionID); err != nil {
+		log.Warn("could not get agent version", zap.Error(err))
+	} else if platforms, err := db.GetDeploymentTargetPlatforms(ctx, orgID, deploymentType); err != nil {
+		log.Warn("could not get deployment target platforms", zap.Error(err))
+	} else if len(platforms) == 0 {
+		return
+	} else if missing, err := agentimage.DefaultResolver().
+		MissingPlatforms(ctx, agentimage.Image(deploymentType), agentVersion.Name, platforms); err != nil {
+		log.Warn("could not verify agent image platforms", zap.Error(err))
+	} else if len(missing) > 0 {
+		log.Warn("pinned agent version is not available for all platforms",
+			zap.String("agentVersion", agentVersion.Name), zap.Strings("missingPlatforms", missing))
+		w.Header().Add("Warning", fmt.Sprintf(`299 - "agent version %v has no image for platforms %v"`,
+			agentVersion.Name, strings.Join(missing, ", ")))
+	}
+}
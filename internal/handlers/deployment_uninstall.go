@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/depgraph"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// uninstallDeployment requests the agent to uninstall a deployment. The deployment is kept and transitions to
+// uninstalled when the agent reports completion. Deployments that other installed deployments on the same deployment
+// target depend on can not be uninstalled, and deleting the data requires the application name as confirmation.
+func uninstallDeployment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	deployment := internalctx.GetDeployment(ctx)
+
+	body, err := JsonBody[api.DeploymentUninstallRequest](w, r)
+	if err != nil {
+		return
+	}
+	if *auth.CurrentUserRole() == types.UserRoleCustomer &&
+		auth.CurrentOrg().DeploymentUninstallPolicy != types.DeploymentUninstallPolicyVendorAndCustomer {
+		http.Error(w, "only vendors may uninstall deployments in this organization", http.StatusForbidden)
+		return
+	}
+
+	target, err := db.GetDeploymentTargetForDeploymentID(ctx, deployment.ID)
+	if err != nil {
+		log.Warn("could not get DeploymentTarget", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if target.ArchivedAt != nil {
+		http.Error(w, "DeploymentTarget is archived", http.StatusBadRequest)
+		return
+	} else if target.IsPaused() {
+		_ = pausedError(w, "DeploymentTarget", &target.Pause)
+		return
+	} else if deployment.IsPaused() {
+		_ = pausedError(w, "Deployment", &deployment.Pause)
+		return
+	}
+	idx := slices.IndexFunc(target.Deployments, func(d types.DeploymentWithLatestRevision) bool {
+		return d.ID == deployment.ID
+	})
+	if idx < 0 {
+		http.Error(w, "Deployment has no released revision", http.StatusBadRequest)
+		return
+	} else if body.DeleteData && body.ConfirmApplicationName != target.Deployments[idx].ApplicationName {
+		http.Error(w, "confirmApplicationName must be the name of the application to delete the data",
+			http.StatusBadRequest)
+		return
+	}
+
+	dependents, err := getUninstallDependents(ctx, *auth.CurrentOrgID(), target, []uuid.UUID{deployment.ID})
+	if err != nil {
+		log.Warn("could not get dependents of Deployment", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if len(dependents) > 0 {
+		http.Error(w, fmt.Sprintf("Deployment can not be uninstalled because it is required by %v",
+			strings.Join(dependents, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	if err := db.RequestDeploymentUninstall(ctx, deployment, auth.CurrentUserID(), body.DeleteData); errors.Is(
+		err, apierrors.ErrConflict,
+	) {
+		http.Error(w, "Deployment is already uninstalled", http.StatusBadRequest)
+	} else if err != nil {
+		log.Warn("could not request Deployment uninstall", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "request_uninstall",
+		ResourceType:   "Deployment",
+		ResourceID:     deployment.ID,
+		Data:           map[string]any{"deleteData": body.DeleteData},
+	}); err != nil {
+		log.Warn("could not audit Deployment uninstall", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, deployment)
+	}
+}
+
+// getUninstallDependents returns the application names of the installed deployments on target that require one of
+// the uninstalled deployments, either directly or through a dependency between their applications that no remaining
+// deployment satisfies.
+func getUninstallDependents(
+	ctx context.Context,
+	orgID uuid.UUID,
+	target *types.DeploymentTargetWithCreatedBy,
+	uninstalledIDs []uuid.UUID,
+) ([]string, error) {
+	dependencies, err := db.GetDeploymentDependencies(ctx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	applicationDependencies, err := db.GetApplicationDependencies(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	isUninstalledApplication := func(applicationID uuid.UUID) bool {
+		return slices.ContainsFunc(target.Deployments, func(d types.DeploymentWithLatestRevision) bool {
+			return d.ApplicationID == applicationID && slices.Contains(uninstalledIDs, d.ID)
+		})
+	}
+	var dependents []string
+	for _, d := range target.Deployments {
+		if slices.Contains(uninstalledIDs, d.ID) || d.ArchivedAt != nil || d.UninstalledAt != nil {
+			continue
+		} else if slices.ContainsFunc(dependencies[d.ID], func(id uuid.UUID) bool {
+			return slices.Contains(uninstalledIDs, id)
+		}) {
+			dependents = append(dependents, d.ApplicationName)
+		} else if slices.ContainsFunc(applicationDependencies[d.ApplicationID], func(ad types.ApplicationDependency) bool {
+			return isUninstalledApplication(ad.DependsOnApplicationID) &&
+				!isApplicationDependencySatisfied(target.Deployments, ad, uninstalledIDs...)
+		}) {
+			dependents = append(dependents, d.ApplicationName)
+		}
+	}
+	return dependents, nil
+}
+
+func getDeploymentDependencies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deployment := internalctx.GetDeployment(ctx)
+	if dependencies, err := db.GetDeploymentDependencies(ctx, deployment.DeploymentTargetID); err != nil {
+		internalctx.GetLogger(ctx).Warn("could not get DeploymentDependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, toDeploymentDependenciesResponse(deployment.ID, dependencies))
+	}
+}
+
+// putDeploymentDependencies declares the deployments on the same deployment target that a deployment depends on.
+// Dependencies must not form a cycle, because none of the deployments in a cycle could be uninstalled.
+func putDeploymentDependencies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	deployment := internalctx.GetDeployment(ctx)
+	body, err := JsonBody[api.DeploymentDependenciesRequest](w, r)
+	if err != nil {
+		return
+	}
+	if deployment.UninstallRequestedAt != nil {
+		http.Error(w, "Deployment is uninstalled", http.StatusBadRequest)
+		return
+	}
+
+	deployments, err := db.GetDeploymentsForDeploymentTarget(ctx, deployment.DeploymentTargetID, false)
+	if err != nil {
+		log.Warn("could not get Deployments", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	for _, id := range body.DependsOn {
+		if id == deployment.ID {
+			http.Error(w, "Deployment can not depend on itself", http.StatusBadRequest)
+			return
+		} else if !slices.ContainsFunc(deployments, func(d types.DeploymentWithLatestRevision) bool {
+			return d.ID == id && d.UninstallRequestedAt == nil
+		}) {
+			http.Error(w, fmt.Sprintf("Deployment %v is not installed on the same DeploymentTarget", id),
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	dependencies, err := db.GetDeploymentDependencies(ctx, deployment.DeploymentTargetID)
+	if err != nil {
+		log.Warn("could not get DeploymentDependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	dependencies[deployment.ID] = body.DependsOn
+	if depgraph.HasPath(dependencies, body.DependsOn, deployment.ID) {
+		http.Error(w, "Deployment dependencies must not form a cycle", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.PutDeploymentDependencies(ctx, deployment.ID, body.DependsOn); err != nil {
+		log.Warn("could not save DeploymentDependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, toDeploymentDependenciesResponse(deployment.ID, dependencies))
+	}
+}
+
+func toDeploymentDependenciesResponse(
+	deploymentID uuid.UUID,
+	dependencies map[uuid.UUID][]uuid.UUID,
+) api.DeploymentDependenciesResponse {
+	response := api.DeploymentDependenciesResponse{
+		DependsOn:    dependencies[deploymentID],
+		DependedOnBy: []uuid.UUID{},
+	}
+	if response.DependsOn == nil {
+		response.DependsOn = []uuid.UUID{}
+	}
+	for id, dependsOn := range dependencies {
+		if slices.Contains(dependsOn, deploymentID) {
+			response.DependedOnBy = append(response.DependedOnBy, id)
+		}
+	}
+	return response
+}
+
+// sortDeploymentsByDependencies orders the deployments of a deployment target so that every deployment comes after
+// the deployments it depends on, either directly or through a dependency between their applications.
+func sortDeploymentsByDependencies(
+	ctx context.Context,
+	orgID uuid.UUID,
+	deployments []types.DeploymentWithLatestRevision,
+) ([]types.DeploymentWithLatestRevision, error) {
+	if len(deployments) < 2 {
+		return deployments, nil
+	}
+	graph, err := db.GetDeploymentDependencies(ctx, deployments[0].DeploymentTargetID)
+	if err != nil {
+		return nil, err
+	}
+	applicationDependencies, err := db.GetApplicationDependencies(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments {
+		for _, ad := range applicationDependencies[d.ApplicationID] {
+			for _, dependency := range deployments {
+				if dependency.ApplicationID == ad.DependsOnApplicationID {
+					graph[d.ID] = append(graph[d.ID], dependency.ID)
+				}
+			}
+		}
+	}
+	return depgraph.Sort(deployments, func(d types.DeploymentWithLatestRevision) uuid.UUID { return d.ID }, graph), nil
+}
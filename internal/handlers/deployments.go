@@ -2,19 +2,25 @@ package handlers
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/api"
 	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/apiversion"
 	"github.com/glasskube/distr/internal/auth"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/preflight"
+	"github.com/glasskube/distr/internal/secretref"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/go-chi/chi/v5"
@@ -24,17 +30,36 @@ import (
 
 func DeploymentsRouter(r chi.Router) {
 	r.Use(middleware.RequireOrgAndRole)
-	r.Put("/", putDeployment)
+	r.With(replacedInV2("/deployments"), middleware.Transaction).Put("/", putDeployment)
 	r.Route("/{deploymentId}", func(r chi.Router) {
 		r.Use(deploymentMiddleware)
 		r.Patch("/", patchDeploymentHandler())
-		r.Delete("/", deleteDeploymentHandler())
+		r.With(middleware.Transaction).Delete("/", deleteDeploymentHandler())
+		r.Post("/archive", archiveDeploymentHandler(true))
+		r.Delete("/archive", archiveDeploymentHandler(false))
+		r.Post("/uninstall", uninstallDeployment)
+		r.With(middleware.Transaction).Post("/pause", pauseDeployment)
+		r.With(middleware.Transaction).Delete("/pause", resumeDeployment)
+		r.Get("/dependencies", getDeploymentDependencies)
+		r.With(middleware.Transaction).Put("/dependencies", putDeploymentDependencies)
 		r.Get("/status", getDeploymentStatus)
+		r.Get("/revisions", getDeploymentRevisions)
+		r.Get("/revisions/{revisionId}/timeline", getDeploymentRevisionTimeline)
+		r.With(middleware.Transaction).Post("/revisions/{revisionId}/acknowledgment", acknowledgeDeploymentRevision)
+		r.Get("/pull-progress", getDeploymentPullProgress)
 		r.Get("/logs", getDeploymentLogsHandler())
 		r.Get("/logs/resources", getDeploymentLogsResourcesHandler())
+		r.Get("/app-metrics", getDeploymentAppMetrics)
 	})
 }
 
+func DeploymentsV2Router(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole)
+	r.With(middleware.Transaction).Post("/", putDeployment)
+}
+
+// putDeployment creates a deployment or, if the request contains a deployment ID, a new revision of an existing
+// deployment. API v1 responds without content, API v2 responds with the created revision.
 func putDeployment(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
@@ -44,34 +69,57 @@ func putDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = db.RunTx(ctx, func(ctx context.Context) error {
-		if err := validateDeploymentRequest(ctx, w, deploymentRequest); err != nil {
-			return err
+	if deploymentRequest.Reason != nil {
+		if reason := strings.TrimSpace(*deploymentRequest.Reason); reason == "" {
+			deploymentRequest.Reason = nil
+		} else {
+			deploymentRequest.Reason = &reason
 		}
+	}
 
-		if deploymentRequest.DeploymentID == nil {
-			if err = db.CreateDeployment(ctx, &deploymentRequest); errors.Is(err, apierrors.ErrConflict) {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return err
-			} else if err != nil {
-				log.Warn("could not create deployment", zap.Error(err))
-				sentry.GetHubFromContext(ctx).CaptureException(err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return err
-			}
-		}
+	// the context returned by the validation contains the access grant of the request, if any
+	ctx, err = validateDeploymentRequest(ctx, w, r, deploymentRequest)
+	if err != nil {
+		return
+	}
 
-		if _, err := db.CreateDeploymentRevision(ctx, &deploymentRequest); err != nil {
-			log.Warn("could not create deployment revision", zap.Error(err))
+	if deploymentRequest.DeploymentID == nil {
+		if err = db.CreateDeployment(ctx, &deploymentRequest); errors.Is(err, apierrors.ErrConflict) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Warn("could not create deployment", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return err
+			return
 		}
+	}
 
-		// TODO: We might need to send a proper deployment object back, but not sure yet what it looks like
+	revision, err := db.CreateDeploymentRevision(ctx, &deploymentRequest)
+	if err != nil {
+		log.Warn("could not create deployment revision", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if revision.IsAcknowledgmentPending() {
+		if err := handleDeploymentAcknowledgmentRequired(ctx, revision); err != nil {
+			log.Warn("could not handle deployment acknowledgment", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if apiversion.FromContext(ctx) == apiversion.V1 {
 		w.WriteHeader(http.StatusNoContent)
-		return nil
-	})
+	} else {
+		RespondJSON(w, api.DeploymentRevisionResponse{
+			DeploymentID:           revision.DeploymentID,
+			RevisionID:             revision.ID,
+			CreatedAt:              revision.CreatedAt,
+			AcknowledgmentRequired: revision.AcknowledgmentRequired,
+		})
+	}
 }
 
 func patchDeploymentHandler() http.HandlerFunc {
@@ -91,6 +139,16 @@ func patchDeploymentHandler() http.HandlerFunc {
 			needsUpdate = true
 		}
 
+		if req.AutoRollback != nil {
+			if req.AutoRollback.WindowSeconds != nil && *req.AutoRollback.WindowSeconds < 0 {
+				http.Error(w, "autoRollback.windowSeconds must not be negative", http.StatusBadRequest)
+				return
+			}
+			deployment.AutoRollbackEnabled = req.AutoRollback.Enabled
+			deployment.AutoRollbackWindowSeconds = req.AutoRollback.WindowSeconds
+			needsUpdate = true
+		}
+
 		if needsUpdate {
 			if err := db.UpdateDeployment(ctx, deployment); err != nil {
 				log.Warn("deployment update failed", zap.Error(err))
@@ -111,37 +169,66 @@ func deleteDeploymentHandler() http.HandlerFunc {
 		auth := auth.Authentication.Require(ctx)
 		orgId := *auth.CurrentOrgID()
 		deployment := internalctx.GetDeployment(ctx)
-		_ = db.RunTx(ctx, func(ctx context.Context) error {
-			target, err := db.GetDeploymentTargetForDeploymentID(ctx, deployment.ID)
-			if err != nil {
-				log.Warn("could not get DeploymentTarget", zap.Error(err))
-				sentry.GetHubFromContext(ctx).CaptureException(err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return err
-			}
-			if target.OrganizationID != orgId ||
-				(*auth.CurrentUserRole() != types.UserRoleVendor && target.CreatedByUserAccountID != auth.CurrentUserID()) {
-				http.NotFound(w, r)
-				return apierrors.ErrNotFound
-			}
+		target, err := db.GetDeploymentTargetForDeploymentID(ctx, deployment.ID)
+		if err != nil {
+			log.Warn("could not get DeploymentTarget", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if target.OrganizationID != orgId ||
+			(*auth.CurrentUserRole() != types.UserRoleVendor && target.CreatedByUserAccountID != auth.CurrentUserID()) {
+			http.NotFound(w, r)
+			return
+		}
 
-			if err := db.DeleteDeploymentWithID(ctx, deployment.ID); err != nil {
-				log.Warn("could not delete Deployment", zap.Error(err))
-				sentry.GetHubFromContext(ctx).CaptureException(err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return err
-			}
+		if err := db.DeleteDeploymentWithID(ctx, deployment.ID); err != nil {
+			log.Warn("could not delete Deployment", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
 
-			return nil
-		})
+func archiveDeploymentHandler(archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := internalctx.GetLogger(ctx)
+		auth := auth.Authentication.Require(ctx)
+		deployment := internalctx.GetDeployment(ctx)
+		target, err := db.GetDeploymentTargetForDeploymentID(ctx, deployment.ID)
+		if err != nil {
+			log.Warn("could not get DeploymentTarget", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if target.OrganizationID != *auth.CurrentOrgID() ||
+			(*auth.CurrentUserRole() != types.UserRoleVendor && target.CreatedByUserAccountID != auth.CurrentUserID()) {
+			http.NotFound(w, r)
+			return
+		}
+		if !archived && target.ArchivedAt != nil {
+			http.Error(w, "DeploymentTarget is archived", http.StatusBadRequest)
+			return
+		}
+
+		if err := db.SetDeploymentArchived(ctx, deployment, archived); err != nil {
+			log.Warn("could not archive Deployment", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			RespondJSON(w, deployment)
+		}
 	}
 }
 
 func validateDeploymentRequest(
 	ctx context.Context,
 	w http.ResponseWriter,
+	r *http.Request,
 	request api.DeploymentRequest,
-) error {
+) (context.Context, error) {
 	log := internalctx.GetLogger(ctx)
 	auth := auth.Authentication.Require(ctx)
 	orgId := *auth.CurrentOrgID()
@@ -156,32 +243,46 @@ func validateDeploymentRequest(
 
 	if app, err = db.GetApplicationForApplicationVersionID(ctx, request.ApplicationVersionID, orgId); err != nil {
 		if errors.Is(err, apierrors.ErrNotFound) {
-			return badRequestError(w, "Application does not exist")
+			return ctx, badRequestError(w, "Application does not exist")
 		} else {
 			log.Warn("could not get Application", zap.Error(err))
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			return err
+			return ctx, err
 		}
 	}
+	if app.IsDeleted() {
+		return ctx, badRequestError(w, "Application is deleted")
+	}
 
 	if version, err = db.GetApplicationVersion(ctx, request.ApplicationVersionID); err != nil {
 		if errors.Is(err, apierrors.ErrNotFound) {
-			return badRequestError(w, "ApplicationVersion does not exist")
+			return ctx, badRequestError(w, "ApplicationVersion does not exist")
 		} else {
 			log.Warn("could not get ApplicationVersion", zap.Error(err))
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			return err
+			return ctx, err
 		}
 	}
 
 	if target, err = db.GetDeploymentTarget(ctx, request.DeploymentTargetID, &orgId); err != nil {
 		if errors.Is(err, apierrors.ErrNotFound) {
-			return badRequestError(w, "DeploymentTarget does not exist")
+			return ctx, badRequestError(w, "DeploymentTarget does not exist")
 		} else {
 			log.Warn("could not get DeploymentTarget", zap.Error(err))
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			return err
+			return ctx, err
 		}
+	} else if target.ArchivedAt != nil {
+		return ctx, badRequestError(w, "DeploymentTarget is archived")
+	} else if target.IsPaused() {
+		return ctx, pausedError(w, "DeploymentTarget", &target.Pause)
+	} else if ctx, err = requireDeploymentTargetAccess(ctx, r, target); err != nil {
+		respondDeploymentTargetAccessError(w, r, err)
+		return ctx, err
+	}
+
+	if err := validateDeploymentRequestReason(w, request, org, target); err != nil {
+		return ctx, err
 	}
 
 	var existingDeployment *types.DeploymentWithLatestRevision
@@ -193,7 +294,13 @@ func validateDeploymentRequest(
 			}
 		}
 		if existingDeployment == nil {
-			return badRequestError(w, "DeploymentTarget doesn't have Deployment with the specified ID")
+			return ctx, badRequestError(w, "DeploymentTarget doesn't have Deployment with the specified ID")
+		} else if existingDeployment.ArchivedAt != nil {
+			return ctx, badRequestError(w, "Deployment is archived")
+		} else if existingDeployment.UninstallRequestedAt != nil {
+			return ctx, badRequestError(w, "Deployment is uninstalled")
+		} else if existingDeployment.IsPaused() {
+			return ctx, pausedError(w, "Deployment", &existingDeployment.Pause)
 		}
 	}
 
@@ -203,12 +310,12 @@ func validateDeploymentRequest(
 				request.ApplicationLicenseID = existingDeployment.ApplicationLicenseID
 			}
 		} else if existingDeployment.ApplicationLicenseID == nil {
-			return badRequestError(w, "can not update license")
+			return ctx, badRequestError(w, "can not update license")
 		} else if *request.ApplicationLicenseID != *existingDeployment.ApplicationLicenseID {
-			return badRequestError(w, "can not update license")
+			return ctx, badRequestError(w, "can not update license")
 		}
 		if existingDeployment.ApplicationID != app.ID {
-			return badRequestError(w, "can not change application of existing deployment")
+			return ctx, badRequestError(w, "can not change application of existing deployment")
 		}
 	}
 
@@ -216,32 +323,41 @@ func validateDeploymentRequest(
 		if request.ApplicationLicenseID != nil {
 			if license, err = db.GetApplicationLicenseByID(ctx, *request.ApplicationLicenseID); err != nil {
 				if errors.Is(err, apierrors.ErrNotFound) {
-					return licenseNotFoundError(w)
+					return ctx, licenseNotFoundError(w)
 				} else {
 					log.Error("could not ApplicationLicense", zap.Error(err))
 					sentry.GetHubFromContext(ctx).CaptureException(err)
 					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return err
+					return ctx, err
 				}
 			}
 		} else if *auth.CurrentUserRole() == types.UserRoleCustomer {
 			// license ID is required for customer but optional for vendor
-			return badRequestError(w, "applicationLicenseId is required")
+			return ctx, badRequestError(w, "applicationLicenseId is required")
 		}
 	} else if request.ApplicationLicenseID != nil {
-		return badRequestError(w, "unexpected applicationLicenseId")
+		return ctx, badRequestError(w, "unexpected applicationLicenseId")
 	}
 
 	if err = validateDeploymentRequestLicense(ctx, w, request, license, app, target, existingDeployment); err != nil {
-		return err
+		return ctx, err
 	} else if err = validateDeploymentRequestDeploymentType(w, target, app); err != nil {
-		return err
+		return ctx, err
+	} else if err = validateDeploymentRequestDependencies(ctx, w, target, app); err != nil {
+		return ctx, err
 	} else if err = validateDeploymentRequestDeploymentTarget(ctx, w, request, target); err != nil {
-		return err
+		return ctx, err
 	} else if err = validateDeploymentRequestValues(w, request, version); err != nil {
-		return err
+		return ctx, err
+	} else if err = validateDeploymentRequestResourceRequirements(ctx, w, request, app, version, target); err != nil {
+		return ctx, err
+	} else if err = scanForSecrets(ctx, w, types.SecretScanSourceDeployment, map[string][]byte{
+		"valuesYaml":  request.ValuesYaml,
+		"envFileData": request.EnvFileData,
+	}); err != nil {
+		return ctx, err
 	} else {
-		return nil
+		return ctx, nil
 	}
 }
 
@@ -276,10 +392,10 @@ func validateDeploymentRequestLicense(
 		if license.OwnerUserAccountID == nil {
 			return invalidLicenseError(w)
 		}
-		if *auth.CurrentUserRole() == types.UserRoleCustomer && *license.OwnerUserAccountID != auth.CurrentUserID() {
+		if *auth.CurrentUserRole() == types.UserRoleCustomer && !license.IsHeldBy(auth.CurrentUserID()) {
 			return licenseNotFoundError(w)
 		}
-		if target.CreatedByUserAccountID != *license.OwnerUserAccountID {
+		if !license.IsHeldBy(target.CreatedByUserAccountID) {
 			return invalidLicenseError(w)
 		}
 		if len(license.Versions) > 0 && !license.HasVersionWithID(request.ApplicationVersionID) {
@@ -306,6 +422,54 @@ func validateDeploymentRequestDeploymentType(
 	return nil
 }
 
+// validateDeploymentRequestDependencies responds with 422 Unprocessable Entity if the deployment target does not run a
+// satisfying version of every application that the application depends on.
+func validateDeploymentRequestDependencies(
+	ctx context.Context,
+	w http.ResponseWriter,
+	target *types.DeploymentTargetWithCreatedBy,
+	application *types.Application,
+) error {
+	auth := auth.Authentication.Require(ctx)
+	dependencies, err := db.GetApplicationDependencies(ctx, *auth.CurrentOrgID())
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("could not get ApplicationDependencies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	var missing []string
+	for _, dependency := range dependencies[application.ID] {
+		if !isApplicationDependencySatisfied(target.Deployments, dependency) {
+			if dependency.MinVersion != nil {
+				missing = append(missing, fmt.Sprintf("%v >= %v", dependency.DependsOnApplicationName,
+					*dependency.MinVersion))
+			} else {
+				missing = append(missing, dependency.DependsOnApplicationName)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		msg := fmt.Sprintf("%v requires %v to be deployed to the deployment target first",
+			application.Name, strings.Join(missing, ", "))
+		http.Error(w, msg, http.StatusUnprocessableEntity)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// isApplicationDependencySatisfied reports whether a deployment other than the excluded ones satisfies dependency.
+func isApplicationDependencySatisfied(
+	deployments []types.DeploymentWithLatestRevision,
+	dependency types.ApplicationDependency,
+	excludedIDs ...uuid.UUID,
+) bool {
+	return slices.ContainsFunc(deployments, func(d types.DeploymentWithLatestRevision) bool {
+		return !slices.Contains(excludedIDs, d.ID) && d.ApplicationID == dependency.DependsOnApplicationID &&
+			d.ArchivedAt == nil && d.UninstallRequestedAt == nil && dependency.IsSatisfiedBy(d.ApplicationVersionName)
+	})
+}
+
 func validateDeploymentRequestDeploymentTarget(
 	ctx context.Context,
 	w http.ResponseWriter,
@@ -331,6 +495,22 @@ func validateDeploymentRequestDeploymentTarget(
 	return nil
 }
 
+func validateDeploymentRequestReason(
+	w http.ResponseWriter,
+	request api.DeploymentRequest,
+	org *types.Organization,
+	target *types.DeploymentTargetWithCreatedBy,
+) error {
+	err := request.ValidateReason(org.RequiresDeploymentReason(&target.DeploymentTarget))
+	if errors.Is(err, api.ErrDeploymentReasonRequired) {
+		w.Header().Set(api.ErrorCodeHeader, api.ErrorCodeDeploymentReasonRequired)
+	}
+	if err != nil {
+		return badRequestError(w, err.Error())
+	}
+	return nil
+}
+
 func validateDeploymentRequestValues(
 	w http.ResponseWriter,
 	deploymentRequest api.DeploymentRequest,
@@ -338,6 +518,10 @@ func validateDeploymentRequestValues(
 ) error {
 	if deploymentValues, err := deploymentRequest.ParsedValuesFile(); err != nil {
 		return badRequestError(w, fmt.Sprintf("invalid values: %v", err.Error()))
+	} else if err := secretref.ValidateValues(deploymentValues); err != nil {
+		return badRequestError(w, fmt.Sprintf("invalid values: %v", err))
+	} else if err := secretref.ValidateEnvFile(deploymentRequest.EnvFileData); err != nil {
+		return badRequestError(w, err.Error())
 	} else if appVersionValues, err := appVersion.ParsedValuesFile(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
@@ -347,13 +531,46 @@ func validateDeploymentRequestValues(
 	return nil
 }
 
+// validateDeploymentRequestResourceRequirements compares the resource requirements of the application version with
+// the capacity last reported by the agent of the deployment target. Unmet requirements only block the creation of new
+// deployments, so that existing deployments can still be updated.
+func validateDeploymentRequestResourceRequirements(
+	ctx context.Context,
+	w http.ResponseWriter,
+	request api.DeploymentRequest,
+	app *types.Application,
+	version *types.ApplicationVersion,
+	target *types.DeploymentTargetWithCreatedBy,
+) error {
+	requirements := types.EffectiveResourceRequirements(*app, *version)
+	if requirements == nil {
+		return nil
+	}
+	metrics, err := db.GetLatestDeploymentTargetMetricsByID(ctx, target.ID)
+	if err != nil && !errors.Is(err, apierrors.ErrNotFound) {
+		internalctx.GetLogger(ctx).Warn("could not get DeploymentTargetMetrics", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	unmet := preflight.Check(*requirements, preflight.CapacityOf(metrics, target.ReportedAgentPlatform))
+	if len(unmet) == 0 {
+		return nil
+	} else if requirements.IsBlocking() && request.DeploymentID == nil {
+		w.Header().Set(api.ErrorCodeHeader, api.ErrorCodeResourceRequirementsNotMet)
+		return badRequestError(w, "deployment target does not meet the resource requirements of the application: "+
+			strings.Join(unmet, "; "))
+	}
+	for _, msg := range unmet {
+		w.Header().Add(api.WarningHeader, "application "+msg)
+	}
+	return nil
+}
+
 func getDeploymentStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	deployment := internalctx.GetDeployment(ctx)
-	limit, err := QueryParam(r, "limit", strconv.Atoi, Max(100))
-	if errors.Is(err, ErrParamNotDefined) {
-		limit = 25
-	} else if err != nil {
+	page, err := PageParam(r, db.DeploymentRevisionStatusPageSpec, 25)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -367,13 +584,131 @@ func getDeploymentStatus(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if deploymentStatus, err := db.GetDeploymentStatus(ctx, deployment.ID, limit, before, after); err != nil {
+	if deploymentStatus, next, err := db.GetDeploymentStatus(ctx, deployment.ID, page, before, after); err != nil {
 		internalctx.GetLogger(ctx).Error("failed to get deploymentstatus", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	} else {
-		RespondJSON(w, deploymentStatus)
+		RespondJSONPage(w, r, db.DeploymentRevisionStatusPageSpec, next, deploymentStatus)
+	}
+}
+
+// getDeploymentAppMetrics responds with the latest application metrics relayed by the agent of the deployment.
+func getDeploymentAppMetrics(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deployment := internalctx.GetDeployment(ctx)
+	if metrics, err := db.GetDeploymentAppMetrics(ctx, deployment.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get app metrics", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, metrics)
+	}
+}
+
+// getDeploymentPullProgress responds with the combined image pull progress of the latest deployment revision.
+// Images with unknown total size are listed, but do not contribute to the percentage.
+// getDeploymentRevisions responds with the revision history of a deployment. With format=csv, the history is
+// exported as CSV instead of JSON.
+func getDeploymentRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deployment := internalctx.GetDeployment(ctx)
+	revisions, err := db.GetDeploymentRevisions(ctx, deployment.ID)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get deployment revisions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		RespondJSON(w, revisions)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="deployment-%v-revisions.csv"`, deployment.ID))
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"id", "createdAt", "applicationVersionId", "applicationVersionName", "reason"})
+		for _, revision := range revisions {
+			var reason string
+			if revision.Reason != nil {
+				reason = *revision.Reason
+			}
+			_ = cw.Write([]string{
+				revision.ID.String(),
+				revision.CreatedAt.Format(time.RFC3339),
+				revision.ApplicationVersionID.String(),
+				revision.ApplicationVersionName,
+				reason,
+			})
+		}
+		if cw.Flush(); cw.Error() != nil {
+			internalctx.GetLogger(ctx).Warn("failed to write csv", zap.Error(cw.Error()))
+		}
+	default:
+		http.Error(w, "format must be one of json, csv", http.StatusBadRequest)
+	}
+}
+
+// deploymentTimelineMaxEvents is the maximum number of agent events in a deployment revision timeline.
+const deploymentTimelineMaxEvents = 1000
+
+func getDeploymentRevisionTimeline(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	deployment := internalctx.GetDeployment(ctx)
+	revisionID, err := uuid.Parse(r.PathValue("revisionId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if revision, err := db.GetDeploymentRevision(ctx, revisionID, deployment.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		log.Error("failed to get deployment revision", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else if events, err := db.GetDeploymentRevisionTimeline(ctx, *revision, deploymentTimelineMaxEvents); err != nil {
+		log.Error("failed to get deployment revision timeline", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.DeploymentRevisionTimeline{
+			DeploymentRevisionWithVersion: *revision,
+			Events:                        events,
+			Approximate: slices.ContainsFunc(events, func(e types.DeploymentTimelineEvent) bool {
+				return e.Approximate
+			}),
+		})
+	}
+}
+
+func getDeploymentPullProgress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deployment := internalctx.GetDeployment(ctx)
+	progress, err := db.GetLatestDeploymentRevisionPullProgress(ctx, deployment.ID)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get pull progress", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if len(progress) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	result := api.DeploymentPullProgress{DeploymentRevisionID: progress[0].DeploymentRevisionID, Images: progress}
+	for _, p := range progress {
+		if p.BytesTotal > 0 {
+			result.BytesDone += min(p.BytesDone, p.BytesTotal)
+			result.BytesTotal += p.BytesTotal
+		}
+	}
+	if result.BytesTotal > 0 {
+		result.Percent = int(result.BytesDone * 100 / result.BytesTotal)
 	}
+	RespondJSON(w, result)
 }
 
 func getDeploymentLogsResourcesHandler() http.HandlerFunc {
@@ -454,6 +789,8 @@ func deploymentMiddleware(next http.Handler) http.Handler {
 			internalctx.GetLogger(ctx).Error("failed to get deployment", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			w.WriteHeader(http.StatusInternalServerError)
+		} else if ctx, err := requireDeploymentAccess(ctx, r, deployment); err != nil {
+			respondDeploymentTargetAccessError(w, r, err)
 		} else {
 			ctx = internalctx.WithDeployment(ctx, deployment)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -23,7 +23,7 @@ import (
 
 func FileRouter(r chi.Router) {
 	r.With(middleware.RequireOrgAndRole).Group(func(r chi.Router) {
-		r.Post("/", createFileHandler)
+		r.With(multipartUpload).Post("/", createFileHandler)
 		r.Route("/{fileId}", func(r chi.Router) {
 			r.Use(fileMiddleware)
 			r.Get("/", getFileHandler)
@@ -74,7 +74,7 @@ func createFileHandler(w http.ResponseWriter, r *http.Request) {
 	auth := auth.Authentication.Require(ctx)
 
 	if file, err := getFileFromRequest(r); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequestBody(w, err)
 	} else {
 		var orgID *uuid.UUID
 		scope := r.FormValue("scope")
@@ -159,6 +159,18 @@ func patchImageHandler(patchImage func(ctx context.Context, body api.PatchImageR
 			return
 		}
 
+		auth := auth.Authentication.Require(ctx)
+		if file, err := db.GetFileWithID(ctx, body.ImageID); errors.Is(err, apierrors.ErrNotFound) ||
+			err == nil && file.OrganizationID != nil && *file.OrganizationID != *auth.CurrentOrgID() {
+			http.Error(w, "imageId must be a file of the organization", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Error("failed to get file", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
 		if result, err := patchImage(ctx, body); err != nil {
 			log.Warn("error patching image id", zap.Error(err))
 			if errors.Is(err, apierrors.ErrNotFound) {
@@ -4,13 +4,61 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/glasskube/distr/api"
+	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/selfcheck"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/go-chi/chi/v5"
 )
 
-func InternalRouter(r chi.Router) {
-	r.Handle("/environment", getFrontendEnvironmentHandler())
+func InternalRouter(selfCheck *selfcheck.Checker) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Handle("/environment", getFrontendEnvironmentHandler())
+		r.Get("/health", getHealthHandler(selfCheck))
+		r.Get("/ready", getReadyHandler(selfCheck))
+		r.Post("/self-check", runSelfCheckHandler(selfCheck))
+	}
+}
+
+// getHealthHandler only exposes server-wide maintenance mode and the result of the self-check, because the request is
+// not authenticated.
+func getHealthHandler(selfCheck *selfcheck.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := "ok"
+		if !selfCheck.Ready() {
+			status = "degraded"
+		}
+		RespondJSON(w, struct {
+			Status      string                `json:"status"`
+			Maintenance api.MaintenanceStatus `json:"maintenance"`
+			SelfCheck   *selfcheck.Result     `json:"selfCheck,omitempty"`
+		}{
+			Status:      status,
+			Maintenance: api.AsMaintenanceStatus(internalctx.GetMaintenanceState(r.Context()).Server),
+			SelfCheck:   selfCheck.Result(),
+		})
+	}
+}
+
+// getReadyHandler responds with 503 Service Unavailable while the last self-check has failed, so that the instance
+// does not receive traffic.
+func getReadyHandler(selfCheck *selfcheck.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if selfCheck.Ready() {
+			RespondJSON(w, map[string]string{"status": "ok"})
+		} else {
+			http.Error(w, "self-check failed", http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// runSelfCheckHandler runs the self-check on demand, e.g. after the storage configuration was fixed. Checks are rate
+// limited by the checker, so the endpoint can be exposed without authentication.
+func runSelfCheckHandler(selfCheck *selfcheck.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RespondJSON(w, selfCheck.Run(r.Context()))
+	}
 }
 
 func getFrontendEnvironmentHandler() http.HandlerFunc {
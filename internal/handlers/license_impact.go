@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+)
+
+// licenseImpactPullWindow is how far back artifact pulls are considered when computing the impact of a license.
+const licenseImpactPullWindow = 30 * 24 * time.Hour
+
+// confirmLicenseDeletion makes sure that a license with a non-empty impact is only deleted if the client passed
+// confirm=true. Otherwise, an error response is written and false is returned.
+func confirmLicenseDeletion(w http.ResponseWriter, r *http.Request, impact *types.LicenseImpact) bool {
+	if impact.IsEmpty() {
+		return true
+	}
+	if confirm, err := QueryParam(r, "confirm", strconv.ParseBool); err != nil && !errors.Is(err, ErrParamNotDefined) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	} else if !confirm {
+		http.Error(w,
+			"the license is still in use, check the impact endpoint and repeat the request with confirm=true",
+			http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// auditLicenseDeletion stores the deleted license together with the impact of its deletion in the audit log.
+func auditLicenseDeletion(
+	ctx context.Context,
+	resourceType string,
+	id uuid.UUID,
+	license any,
+	impact *types.LicenseImpact,
+) error {
+	auth := auth.Authentication.Require(ctx)
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "delete",
+		ResourceType:   resourceType,
+		ResourceID:     id,
+		Data: map[string]any{
+			"license": license,
+			"impact":  impact,
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/maintenance"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// MaintenanceRouter must not be wrapped with middleware.ReadOnlyDuringMaintenance, otherwise vendors could not
+// leave maintenance mode of their organization.
+func MaintenanceRouter(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole)
+	r.Get("/", getMaintenanceStatus)
+	r.With(requireUserRoleVendor, middleware.ReadOnlyDuringServerMaintenance).Group(func(r chi.Router) {
+		r.Put("/", putOrganizationMaintenanceMode)
+		r.Delete("/", deleteOrganizationMaintenanceMode)
+	})
+}
+
+// getMaintenanceStatus returns the maintenance mode that applies to the current organization, to be displayed
+// as a banner.
+func getMaintenanceStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	RespondJSON(w, api.AsMaintenanceStatus(internalctx.GetMaintenanceState(ctx).For(auth.CurrentOrgID())))
+}
+
+func putOrganizationMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.MaintenanceModeRequest](w, r)
+	if err != nil {
+		return
+	}
+	mode := types.MaintenanceMode{
+		OrganizationID:         auth.CurrentOrgID(),
+		CreatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Reason:                 request.Reason,
+		RetryAfterSeconds:      request.RetryAfterSeconds,
+	}
+	if err := maintenance.Enter(ctx, &mode, "api"); errors.Is(err, maintenance.ErrInvalidRetryAfter) ||
+		errors.Is(err, maintenance.ErrReasonTooLong) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to enter maintenance mode", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.AsMaintenanceStatus(&mode))
+	}
+}
+
+func deleteOrganizationMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	err := maintenance.Leave(ctx, auth.CurrentOrgID(), util.PtrTo(auth.CurrentUserID()), "api")
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to leave maintenance mode", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
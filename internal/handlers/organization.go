@@ -13,6 +13,7 @@ import (
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/orgtime"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -26,13 +27,19 @@ func OrganizationRouter(r chi.Router) {
 		r.Use(requireUserRoleVendor)
 		r.Put("/", updateOrganization)
 		r.Post("/", createOrganization)
+		r.With(requireRegistryEnabled).Get("/usage", getOrganizationStorageUsage)
 	})
 	r.Route("/branding", OrganizationBrandingRouter)
+	r.Route("/mail-config", OrganizationMailConfigRouter)
+	r.Route("/storage", OrganizationStorageRouter)
+	r.Route("/data-retention", OrganizationDataRetentionRouter)
+	r.Route("/access-tokens", OrganizationAccessTokensRouter)
 }
 
 func OrganizationsRouter(r chi.Router) {
 	r.Use(middleware.RequireOrgAndRole)
 	r.Get("/", getOrganizations)
+	r.Route("/{organizationId}/service-accounts", ServiceAccountsRouter)
 }
 
 func getOrganization(w http.ResponseWriter, r *http.Request) {
@@ -59,6 +66,25 @@ func updateOrganization(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	// organizations that already use a reserved slug can keep it until they choose another one
+	if organization.Slug != nil && types.IsReservedOrganizationSlug(*organization.Slug) &&
+		(existingOrganization.Slug == nil || *existingOrganization.Slug != *organization.Slug) {
+		http.Error(w, "Slug is reserved", http.StatusBadRequest)
+		return
+	}
+
+	if organization.DeploymentReasonPolicy == "" {
+		organization.DeploymentReasonPolicy = existingOrganization.DeploymentReasonPolicy
+	}
+	if organization.DeploymentUninstallPolicy == "" {
+		organization.DeploymentUninstallPolicy = existingOrganization.DeploymentUninstallPolicy
+	}
+	if organization.SecretScanPolicy == "" {
+		organization.SecretScanPolicy = existingOrganization.SecretScanPolicy
+	}
+	if organization.Timezone == "" {
+		organization.Timezone = existingOrganization.Timezone
+	}
 
 	if organization.ID == uuid.Nil {
 		organization.ID = existingOrganization.ID
@@ -88,6 +114,9 @@ func createOrganization(w http.ResponseWriter, r *http.Request) {
 		return
 	} else if ok := validateOrganizationRequest(w, &organization); !ok {
 		return
+	} else if organization.Slug != nil && types.IsReservedOrganizationSlug(*organization.Slug) {
+		http.Error(w, "Slug is reserved", http.StatusBadRequest)
+		return
 	}
 
 	if err := db.RunTx(ctx, func(ctx context.Context) error {
@@ -123,15 +152,53 @@ func validateOrganizationRequest(w http.ResponseWriter, organization *types.Orga
 	}
 	if organization.Slug != nil {
 		slugPattern := "^[a-z0-9]+((\\.|_|__|-+)[a-z0-9]+)*$"
-		slugMaxLength := 64
 		if matched, _ := regexp.MatchString(slugPattern, *organization.Slug); !matched {
 			http.Error(w, "Slug is invalid", http.StatusBadRequest)
 			return false
-		} else if len(*organization.Slug) > slugMaxLength {
+		} else if len(*organization.Slug) > types.OrganizationSlugMaxLength {
 			http.Error(w, "Slug too long (max 64 chars)", http.StatusBadRequest)
 			return false
 		}
 	}
+	switch organization.DeploymentReasonPolicy {
+	case "", types.DeploymentReasonPolicyOptional, types.DeploymentReasonPolicyProduction,
+		types.DeploymentReasonPolicyRequired:
+	default:
+		http.Error(w, "deploymentReasonPolicy is invalid", http.StatusBadRequest)
+		return false
+	}
+	switch organization.DeploymentUninstallPolicy {
+	case "", types.DeploymentUninstallPolicyVendor, types.DeploymentUninstallPolicyVendorAndCustomer:
+	default:
+		http.Error(w, "deploymentUninstallPolicy is invalid", http.StatusBadRequest)
+		return false
+	}
+	switch organization.SecretScanPolicy {
+	case "", types.SecretScanPolicyBlock, types.SecretScanPolicyWarn:
+	default:
+		http.Error(w, "secretScanPolicy is invalid", http.StatusBadRequest)
+		return false
+	}
+	if organization.DeploymentAutoRollbackWindowSeconds != nil && *organization.DeploymentAutoRollbackWindowSeconds < 0 {
+		http.Error(w, "deploymentAutoRollbackWindowSeconds must not be negative", http.StatusBadRequest)
+		return false
+	}
+	if organization.Timezone != "" {
+		if _, err := orgtime.LoadLocation(organization.Timezone); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	if organization.BusinessHours != nil {
+		if err := organization.BusinessHours.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	if err := organization.AgentResourceLimits.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
 	return true
 }
 
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mapping"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// OrganizationAccessTokensRouter lets vendors review the access tokens of all users and service accounts of their
+// organization and revoke them, for example when a user leaves.
+func OrganizationAccessTokensRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor)
+	r.Get("/", getOrganizationAccessTokensHandler)
+	r.Post("/revoke", revokeOrganizationAccessTokensOfUserHandler)
+	r.Delete("/{tokenId}", revokeOrganizationAccessTokenHandler)
+}
+
+func getOrganizationAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if tokens, err := db.GetAccessTokensOfOrganization(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get access tokens", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, mapping.List(tokens, mapping.OrganizationAccessTokenToDTO))
+	}
+}
+
+func revokeOrganizationAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tokenID, err := uuid.Parse(r.PathValue("tokenId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := revokeOrganizationAccessTokens(ctx, []uuid.UUID{tokenID}, nil); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to revoke access token", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// revokeOrganizationAccessTokensOfUserHandler revokes all access tokens of a user in the current organization and
+// responds with the revoked tokens.
+func revokeOrganizationAccessTokensOfUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	request, err := JsonBody[api.RevokeAccessTokensRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tokens, err := revokeOrganizationAccessTokens(ctx, nil, &request.UserAccountID)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		tokens = []types.OrganizationAccessToken{}
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to revoke access tokens", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	RespondJSON(w, mapping.List(tokens, mapping.OrganizationAccessTokenToDTO))
+}
+
+// revokeOrganizationAccessTokens revokes the tokens and records the revocation of each token with the current user in
+// the audit log. It returns apierrors.ErrNotFound if no token has been revoked.
+func revokeOrganizationAccessTokens(
+	ctx context.Context,
+	ids []uuid.UUID,
+	userID *uuid.UUID,
+) ([]types.OrganizationAccessToken, error) {
+	auth := auth.Authentication.Require(ctx)
+	var result []types.OrganizationAccessToken
+	err := db.RunTx(ctx, func(ctx context.Context) error {
+		tokens, err := db.RevokeAccessTokens(ctx, *auth.CurrentOrgID(), ids, userID)
+		if err != nil {
+			return err
+		} else if len(tokens) == 0 {
+			return apierrors.ErrNotFound
+		}
+		for _, token := range tokens {
+			resourceType := "AccessToken"
+			data := map[string]any{"label": token.Label, "keyPrefix": token.KeyPrefix}
+			if token.ServiceAccount != nil {
+				resourceType = "ServiceAccountToken"
+				data["serviceAccountId"] = token.ServiceAccount.ID
+			} else {
+				data["ownerUserAccountId"] = token.UserAccount.ID
+				data["ownerUserRole"] = token.UserRole
+			}
+			if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+				OrganizationID: auth.CurrentOrgID(),
+				UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+				Action:         "revoke",
+				ResourceType:   resourceType,
+				ResourceID:     token.ID,
+				Data:           data,
+			}); err != nil {
+				return err
+			}
+		}
+		result = tokens
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range result {
+		internalctx.GetLogger(ctx).Info("access token revoked",
+			zap.Stringer("tokenId", token.ID),
+			zap.Stringer("revokedBy", auth.CurrentUserID()))
+	}
+	return result, nil
+}
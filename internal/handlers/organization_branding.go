@@ -23,7 +23,7 @@ import (
 func OrganizationBrandingRouter(r chi.Router) {
 	r.Use(middleware.RequireOrgAndRole)
 	r.Get("/", getOrganizationBranding)
-	r.With(requireUserRoleVendor).Group(func(r chi.Router) {
+	r.With(requireUserRoleVendor, multipartUpload).Group(func(r chi.Router) {
 		r.Post("/", createOrganizationBranding)
 		r.Put("/", updateOrganizationBranding)
 	})
@@ -51,7 +51,7 @@ func createOrganizationBranding(w http.ResponseWriter, r *http.Request) {
 	log := internalctx.GetLogger(ctx)
 
 	if organizationBranding, err := getOrganizationBrandingFromRequest(r); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequestBody(w, err)
 	} else if err := setMetadataForOrganizationBranding(ctx, organizationBranding); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	} else if err = db.CreateOrganizationBranding(r.Context(), organizationBranding); err != nil {
@@ -68,7 +68,7 @@ func updateOrganizationBranding(w http.ResponseWriter, r *http.Request) {
 	log := internalctx.GetLogger(ctx)
 
 	if organizationBranding, err := getOrganizationBrandingFromRequest(r); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequestBody(w, err)
 	} else if err := setMetadataForOrganizationBranding(ctx, organizationBranding); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	} else if err = db.UpdateOrganizationBranding(r.Context(), organizationBranding); err != nil {
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// OrganizationDataRetentionRouter lets vendors configure how long the data of their organization is kept. Legal holds
+// are only shown here, they are managed by platform admins with the hub CLI.
+func OrganizationDataRetentionRouter(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole, requireUserRoleVendor)
+	r.Get("/", getOrganizationDataRetention)
+	r.With(middleware.Transaction).Put("/", putOrganizationDataRetention)
+}
+
+func getOrganizationDataRetention(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if response, err := getDataRetentionResponse(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get data retention", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, response)
+	}
+}
+
+func putOrganizationDataRetention(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.DataRetentionRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policies := make([]types.DataRetentionPolicy, len(request.Policies))
+	for i, p := range request.Policies {
+		policies[i] = types.DataRetentionPolicy{Category: p.Category, RetentionDays: p.RetentionDays}
+	}
+	if err := db.PutDataRetentionPolicies(ctx, *auth.CurrentOrgID(), policies); err != nil {
+		log.Error("failed to save data retention policies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if err := db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: auth.CurrentOrgID(),
+		UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+		Action:         "update",
+		ResourceType:   "DataRetentionPolicy",
+		ResourceID:     *auth.CurrentOrgID(),
+		Data:           map[string]any{"policies": request.Policies},
+	}); err != nil {
+		log.Error("failed to audit data retention policies", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if response, err := getDataRetentionResponse(ctx, *auth.CurrentOrgID()); err != nil {
+		log.Error("failed to get data retention", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, response)
+	}
+}
+
+func getDataRetentionResponse(ctx context.Context, orgID uuid.UUID) (*api.DataRetentionResponse, error) {
+	policies, err := db.GetDataRetentionPolicies(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := db.GetLegalHolds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policies == nil {
+		policies = []types.DataRetentionPolicy{}
+	}
+	return &api.DataRetentionResponse{Policies: policies, LegalHold: holds.CoversAnyOf(orgID)}, nil
+}
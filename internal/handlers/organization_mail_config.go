@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/authkey"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail/dkim"
+	"github.com/glasskube/distr/internal/mail/orgmailer"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+	"go.uber.org/zap"
+)
+
+func OrganizationMailConfigRouter(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole, requireUserRoleVendor)
+	r.Get("/", getOrganizationMailConfig)
+	r.Put("/", putOrganizationMailConfig)
+	r.Delete("/", deleteOrganizationMailConfig)
+	r.With(verifyOrganizationMailConfigRateLimit).Post("/verify", verifyOrganizationMailConfig)
+}
+
+func getOrganizationMailConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if config, err := db.GetOrganizationMailConfig(ctx, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get organization mail config", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, toOrganizationMailConfigResponse(*config))
+	}
+}
+
+func putOrganizationMailConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+
+	request, err := JsonBody[api.OrganizationMailConfigRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config := types.OrganizationMailConfig{
+		OrganizationID:         *auth.CurrentOrgID(),
+		UpdatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Type:                   request.Type,
+		FromAddress:            request.FromAddress,
+		SmtpHost:               request.SmtpHost,
+		SmtpPort:               request.SmtpPort,
+		SmtpUsername:           request.SmtpUsername,
+		SmtpPassword:           request.SmtpPassword,
+	}
+	if request.Type == types.MailConfigTypeDomain {
+		// Validate has already checked that the address can be parsed
+		address, _ := mail.ParseAddress(request.FromAddress)
+		domain := strings.ToLower(address.Address[strings.LastIndex(address.Address, "@")+1:])
+		config.SenderDomain = &domain
+		config.DkimSelector = request.DkimSelector
+		if config.DkimSelector == nil {
+			config.DkimSelector = util.PtrTo(orgmailer.DefaultDkimSelector)
+		}
+		// mails are sent by the platform transport, so they are signed with a key that only this config uses
+		if key, err := dkim.NewKey(); err != nil {
+			log.Error("failed to generate DKIM key", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else {
+			config.DkimPrivateKey = &key
+		}
+		if key, err := authkey.NewKey(); err != nil {
+			log.Error("failed to generate verification token", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else {
+			config.VerificationToken = util.PtrTo(hex.EncodeToString(key[:]))
+		}
+	}
+
+	if err := db.UpsertOrganizationMailConfig(ctx, &config); err != nil {
+		log.Error("failed to save organization mail config", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, toOrganizationMailConfigResponse(config))
+	}
+}
+
+func deleteOrganizationMailConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if err := db.DeleteOrganizationMailConfig(ctx, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete organization mail config", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func verifyOrganizationMailConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+
+	config, err := db.GetOrganizationMailConfig(ctx, *auth.CurrentOrgID())
+	if errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Error("failed to get organization mail config", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	verifyCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := orgmailer.Verify(verifyCtx, *config); errors.Is(err, orgmailer.ErrVerificationFailed) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err != nil {
+		log.Error("failed to verify organization mail config", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if err := db.UpdateOrganizationMailConfigVerified(ctx, config); err != nil {
+		log.Error("failed to save organization mail config", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, toOrganizationMailConfigResponse(*config))
+	}
+}
+
+func toOrganizationMailConfigResponse(config types.OrganizationMailConfig) api.OrganizationMailConfigResponse {
+	return api.OrganizationMailConfigResponse{
+		OrganizationMailConfig: config,
+		DnsRecords:             orgmailer.DnsRecords(config),
+	}
+}
+
+// verification performs DNS lookups or connects to a user supplied host, so it is limited more strictly
+var verifyOrganizationMailConfigRateLimit = httprate.Limit(
+	5,
+	10*time.Minute,
+	httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc),
+)
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/registry/blob/s3"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+	"go.uber.org/zap"
+)
+
+func OrganizationStorageRouter(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole, requireUserRoleVendor, requireRegistryEnabled)
+	r.Get("/", getOrganizationStorage)
+	r.With(putOrganizationStorageRateLimit).Put("/", putOrganizationStorage)
+	r.Delete("/", deleteOrganizationStorage)
+}
+
+func requireRegistryEnabled(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !env.RegistryEnabled() {
+			http.NotFound(w, r)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func getOrganizationStorage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if storage, err := db.GetOrganizationStorage(ctx, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get organization storage", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, storage)
+	}
+}
+
+// getOrganizationStorageUsage returns the blob storage that the organization uses in the registry and its storage
+// quota. Blobs that are not referenced anymore are removed from the usage by the blob garbage collection.
+func getOrganizationStorageUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if usage, err := db.GetOrganizationStorageUsage(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get organization storage usage", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, usage)
+	}
+}
+
+// putOrganizationStorage saves the storage of the organization after it has been verified by writing, reading and
+// deleting an object. All blobs that are pushed afterwards are stored in this bucket.
+func putOrganizationStorage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+
+	request, err := JsonBody[api.OrganizationStorageRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storage := types.OrganizationStorage{
+		OrganizationID:         *auth.CurrentOrgID(),
+		UpdatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Bucket:                 request.Bucket,
+		Region:                 request.Region,
+		Endpoint:               request.Endpoint,
+		UsePathStyle:           request.UsePathStyle,
+		AllowRedirect:          request.AllowRedirect,
+		AccessKeyID:            request.AccessKeyID,
+		SecretAccessKey:        request.SecretAccessKey,
+		MigrateExistingBlobs:   request.MigrateExistingBlobs,
+		QuotaEnabled:           request.QuotaEnabled,
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := s3.ProbeOrganizationStorage(probeCtx, storage); errors.Is(err, s3.ErrProbeFailed) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Error("failed to probe organization storage", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	storage.VerifiedAt = time.Now()
+	if err := db.UpsertOrganizationStorage(ctx, &storage); err != nil {
+		log.Error("failed to save organization storage", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, storage)
+	}
+}
+
+func deleteOrganizationStorage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if err := db.DeleteOrganizationStorage(ctx, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if errors.Is(err, apierrors.ErrConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete organization storage", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// saving the storage connects to a user supplied endpoint, so it is limited more strictly
+var putOrganizationStorageRateLimit = httprate.Limit(
+	5,
+	10*time.Minute,
+	httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc),
+)
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apiversion"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/pagination"
+)
+
+// v2DefaultLimit is used by API v2 for list endpoints that return all items by default in API v1.
+const v2DefaultLimit = 50
+
+// PageParam parses the limit and cursor query parameters for a list endpoint.
+// A defaultLimit of 0 means that all items are returned if the client does not specify a limit. Starting with API v2,
+// lists are always paginated.
+func PageParam(r *http.Request, spec pagination.Spec, defaultLimit int) (pagination.Page, error) {
+	if defaultLimit == 0 && apiversion.FromContext(r.Context()) != apiversion.V1 {
+		defaultLimit = v2DefaultLimit
+	}
+	return pagination.FromRequest(r, spec, env.JWTSecret(), defaultLimit)
+}
+
+// RespondJSONPage responds with one page of a list. In API v1, the X-Next-Cursor header is set if there are more
+// items. Starting with API v2, the page is wrapped in an api.ListResponse that contains the cursor.
+func RespondJSONPage(w http.ResponseWriter, r *http.Request, spec pagination.Spec, next []any, data any) {
+	if apiversion.FromContext(r.Context()) == apiversion.V1 {
+		if err := pagination.SetNextCursor(w, spec, env.JWTSecret(), next); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		RespondJSON(w, data)
+		return
+	}
+	response := api.ListResponse{Items: data}
+	if next != nil {
+		if cursor, err := spec.EncodeCursor(env.JWTSecret(), next); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else {
+			response.NextCursor = &cursor
+		}
+	}
+	RespondJSON(w, response)
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/mapping"
+	"github.com/glasskube/distr/internal/securityevents"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/glasskube/distr/internal/webauthn"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func passkeysRouter(r chi.Router) {
+	r.Get("/", getPasskeysHandler)
+	r.Post("/", createPasskeyHandler)
+	r.Post("/registration", beginPasskeyRegistrationHandler)
+	r.Route("/{passkeyId}", func(r chi.Router) {
+		r.Put("/", updatePasskeyHandler)
+		r.Delete("/", deletePasskeyHandler)
+	})
+}
+
+func relyingParty() (webauthn.RelyingParty, error) {
+	return webauthn.NewRelyingParty("Distr", env.Host())
+}
+
+// newWebAuthnChallenge creates and stores the challenge for a new ceremony.
+func newWebAuthnChallenge(
+	ctx context.Context,
+	ceremony types.WebAuthnCeremony,
+	userID *uuid.UUID,
+) (*types.WebAuthnChallenge, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	result := types.WebAuthnChallenge{
+		ExpiresAt:     time.Now().Add(webauthn.Timeout),
+		Ceremony:      ceremony,
+		UserAccountID: userID,
+		Challenge:     challenge,
+	}
+	if err := db.CreateWebAuthnChallenge(ctx, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func getPasskeysHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if credentials, err := db.GetWebAuthnCredentials(ctx, auth.CurrentUserID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get passkeys", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, mapping.List(credentials, mapping.PasskeyToDTO))
+	}
+}
+
+func beginPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	user := auth.CurrentUser()
+	rp, err := relyingParty()
+	if err != nil {
+		log.Error("invalid WebAuthn relying party", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	credentials, err := db.GetWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to get passkeys", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	exclude := make([]webauthn.CredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		exclude[i] = webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.CredentialID,
+			Transports: credential.Transports,
+		}
+	}
+
+	challenge, err := newWebAuthnChallenge(ctx, types.WebAuthnCeremonyRegistration, &user.ID)
+	if err != nil {
+		log.Error("failed to create WebAuthn challenge", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	displayName := user.Name
+	if displayName == "" {
+		displayName = user.Email
+	}
+	RespondJSON(w, api.PasskeyRegistrationOptions{
+		ChallengeID: challenge.ID,
+		PublicKey: webauthn.NewCreationOptions(
+			rp,
+			webauthn.UserEntity{ID: webauthn.UserHandle(user.ID), Name: user.Email, DisplayName: displayName},
+			challenge.Challenge,
+			exclude,
+		),
+	})
+}
+
+func createPasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.CreatePasskeyRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rp, err := relyingParty()
+	if err != nil {
+		log.Error("invalid WebAuthn relying party", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var credential *types.WebAuthnCredential
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		challenge, err := db.ConsumeWebAuthnChallenge(ctx, request.ChallengeID, types.WebAuthnCeremonyRegistration)
+		if errors.Is(err, apierrors.ErrNotFound) ||
+			(err == nil && (challenge.UserAccountID == nil || *challenge.UserAccountID != auth.CurrentUserID())) {
+			http.Error(w, "invalid or expired challenge", http.StatusBadRequest)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		verified, err := webauthn.VerifyRegistration(rp, challenge.Challenge, request.Credential)
+		if errors.Is(err, webauthn.ErrVerificationFailed) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		credential = &types.WebAuthnCredential{
+			UserAccountID: auth.CurrentUserID(),
+			CredentialID:  verified.ID,
+			PublicKey:     verified.PublicKey,
+			SignCount:     int64(verified.SignCount),
+			Transports:    request.Credential.Response.Transports,
+			Nickname:      request.Nickname,
+		}
+		if err := db.CreateWebAuthnCredential(ctx, credential); errors.Is(err, apierrors.ErrAlreadyExists) {
+			credential = nil
+			http.Error(w, "passkey is already registered", http.StatusBadRequest)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		RespondJSON(w, mapping.PasskeyToDTO(*credential))
+		return nil
+	})
+	if err != nil {
+		log.Warn("passkey registration failed", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if credential != nil {
+		event := securityevents.New(r, *auth.CurrentUser(), types.SecurityEventTypePasskeyAdded)
+		event.Details = util.PtrTo("Nickname: " + credential.Nickname)
+		if err := securityevents.Record(ctx, *auth.CurrentUser(), &event); err != nil {
+			log.Warn("could not record security event", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}
+}
+
+func updatePasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	id, err := uuid.Parse(r.PathValue("passkeyId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	request, err := JsonBody[api.UpdatePasskeyRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if credential, err := db.UpdateWebAuthnCredentialNickname(
+		ctx, id, auth.CurrentUserID(), request.Nickname); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to update passkey", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, mapping.PasskeyToDTO(*credential))
+	}
+}
+
+func deletePasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	id, err := uuid.Parse(r.PathValue("passkeyId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if credential, err := db.DeleteWebAuthnCredential(ctx, id, auth.CurrentUserID()); errors.Is(
+		err, apierrors.ErrNotFound,
+	) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		log.Error("failed to delete passkey", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+		event := securityevents.New(r, *auth.CurrentUser(), types.SecurityEventTypePasskeyRemoved)
+		event.Details = util.PtrTo("Nickname: " + credential.Nickname)
+		if err := securityevents.Record(ctx, *auth.CurrentUser(), &event); err != nil {
+			log.Warn("could not record security event", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+		}
+	}
+}
+
+func authPasskeyLoginOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	rp, err := relyingParty()
+	if err != nil {
+		log.Error("invalid WebAuthn relying party", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if challenge, err := newWebAuthnChallenge(ctx, types.WebAuthnCeremonyAuthentication, nil); err != nil {
+		log.Error("failed to create WebAuthn challenge", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.PasskeyLoginOptions{
+			ChallengeID: challenge.ID,
+			PublicKey:   webauthn.NewRequestOptions(rp, challenge.Challenge),
+		})
+	}
+}
+
+// authPasskeyLoginHandler logs a user in with a passkey instead of a password. The session token is issued in the
+// same way as for a login with password.
+func authPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	request, err := JsonBody[api.PasskeyLoginRequest](w, r)
+	if err != nil {
+		return
+	}
+	rp, err := relyingParty()
+	if err != nil {
+		log.Error("invalid WebAuthn relying party", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var user *types.UserAccount
+	var loggedIn bool
+	err = db.RunTx(ctx, func(ctx context.Context) error {
+		challenge, err := db.ConsumeWebAuthnChallenge(ctx, request.ChallengeID, types.WebAuthnCeremonyAuthentication)
+		if errors.Is(err, apierrors.ErrNotFound) {
+			http.Error(w, "invalid or expired challenge", http.StatusBadRequest)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		credential, err := db.GetWebAuthnCredentialByCredentialID(ctx, request.Credential.RawID)
+		if errors.Is(err, apierrors.ErrNotFound) {
+			http.Error(w, "invalid passkey", http.StatusBadRequest)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if user, err = db.GetUserAccountByID(ctx, credential.UserAccountID); err != nil {
+			return err
+		}
+		log = log.With(zap.Any("userId", user.ID))
+
+		signCount, err := webauthn.VerifyAssertion(
+			rp,
+			challenge.Challenge,
+			request.Credential,
+			webauthn.UserHandle(user.ID),
+			credential.PublicKey,
+			uint32(credential.SignCount),
+		)
+		if errors.Is(err, webauthn.ErrVerificationFailed) {
+			log.Info("passkey verification failed", zap.Error(err))
+			http.Error(w, "invalid passkey", http.StatusBadRequest)
+			return nil
+		} else if err != nil {
+			return err
+		} else if err := db.UpdateWebAuthnCredentialUsed(ctx, credential.ID, int64(signCount)); err != nil {
+			return err
+		}
+
+		if tokenString, err := createLoginToken(ctx, *user); err != nil {
+			return err
+		} else {
+			loggedIn = true
+			RespondJSON(w, api.AuthLoginResponse{Token: tokenString})
+			return nil
+		}
+	})
+	if err != nil {
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		log.Warn("user login with passkey failed", zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	recordLoginSecurityEvent(ctx, r, user, loggedIn)
+}
@@ -30,6 +30,22 @@ func QueryParam[T any](
 	}
 }
 
+// OptionalQueryParam is like QueryParam but returns nil instead of ErrParamNotDefined if the parameter is not set.
+func OptionalQueryParam[T any](
+	r *http.Request,
+	name string,
+	parseFunc func(string) (T, error),
+	validatorFunc ...func(T) error,
+) (*T, error) {
+	if value, err := QueryParam(r, name, parseFunc, validatorFunc...); errors.Is(err, ErrParamNotDefined) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else {
+		return &value, nil
+	}
+}
+
 func ParseTimeFunc(layout string) func(string) (time.Time, error) {
 	return func(value string) (time.Time, error) {
 		return time.Parse(layout, value)
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/secretscan"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func SecretScanRouter(r chi.Router) {
+	r.Use(middleware.RequireOrgAndRole, requireUserRoleVendor)
+	r.Route("/rules", func(r chi.Router) {
+		r.Get("/", getSecretScanRules)
+		r.Post("/", createSecretScanRule)
+		r.Delete("/{ruleId}", deleteSecretScanRule)
+	})
+	r.Route("/dismissals", func(r chi.Router) {
+		r.Get("/", getSecretScanDismissals)
+		r.Post("/", createSecretScanDismissal)
+		r.Delete("/{dismissalId}", deleteSecretScanDismissal)
+	})
+	r.Get("/findings", getSecretScanFindings)
+}
+
+func getSecretScanRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if rules, err := db.GetSecretScanRules(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get secret scan rules", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rules)
+	}
+}
+
+func createSecretScanRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.SecretScanRuleRequest](w, r)
+	if err != nil {
+		return
+	}
+	request.RuleID = strings.TrimSpace(request.RuleID)
+	if _, err := secretscan.NewRule(request.RuleID, request.Description, request.Pattern); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule := types.SecretScanRule{
+		OrganizationID: *auth.CurrentOrgID(),
+		RuleID:         request.RuleID,
+		Description:    request.Description,
+		Pattern:        request.Pattern,
+	}
+	if err := db.CreateSecretScanRule(ctx, &rule); errors.Is(err, apierrors.ErrAlreadyExists) {
+		http.Error(w, "a rule with this ID already exists", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create secret scan rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, rule)
+	}
+}
+
+func deleteSecretScanRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if id, err := uuid.Parse(r.PathValue("ruleId")); err != nil {
+		http.NotFound(w, r)
+	} else if err := db.DeleteSecretScanRule(ctx, id, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete secret scan rule", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getSecretScanDismissals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if dismissals, err := db.GetSecretScanDismissals(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get secret scan dismissals", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, dismissals)
+	}
+}
+
+func createSecretScanDismissal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.SecretScanDismissalRequest](w, r)
+	if err != nil {
+		return
+	}
+	if b, err := hex.DecodeString(request.Fingerprint); err != nil || len(b) != 32 {
+		http.Error(w, "fingerprint is invalid", http.StatusBadRequest)
+		return
+	}
+	dismissal := types.SecretScanDismissal{
+		OrganizationID:         *auth.CurrentOrgID(),
+		CreatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Fingerprint:            strings.ToLower(request.Fingerprint),
+		Reason:                 strings.TrimSpace(request.Reason),
+	}
+	if err := db.CreateSecretScanDismissal(ctx, &dismissal); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create secret scan dismissal", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, dismissal)
+	}
+}
+
+func deleteSecretScanDismissal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if id, err := uuid.Parse(r.PathValue("dismissalId")); err != nil {
+		http.NotFound(w, r)
+	} else if err := db.DeleteSecretScanDismissal(ctx, id, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete secret scan dismissal", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getSecretScanFindings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	page, err := PageParam(r, db.SecretScanFindingsPageSpec, 25)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if findings, next, err := db.GetSecretScanFindingsPage(ctx, *auth.CurrentOrgID(), page); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get secret scan findings", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSONPage(w, r, db.SecretScanFindingsPageSpec, next, findings)
+	}
+}
+
+// scanForSecrets scans files with the default rules and the custom rules of the organization. Findings that were
+// dismissed are ignored. All other findings are recorded and, depending on the SecretScanPolicy of the organization,
+// either reject the request with 422 or are added to the response as warnings.
+func scanForSecrets(
+	ctx context.Context,
+	w http.ResponseWriter,
+	source types.SecretScanSource,
+	files map[string][]byte,
+) error {
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	orgID := *auth.CurrentOrgID()
+
+	scanner, dismissed, err := getSecretScanner(ctx, orgID)
+	if err != nil {
+		log.Error("could not prepare secret scan", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	var findings []secretscan.Finding
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		for _, finding := range scanner.Scan(name, files[name]) {
+			if _, ok := dismissed[finding.Fingerprint]; !ok {
+				findings = append(findings, finding)
+			}
+		}
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+
+	blocked := auth.CurrentOrg().SecretScanPolicy != types.SecretScanPolicyWarn
+	records := make([]types.SecretScanFinding, len(findings))
+	messages := make([]string, len(findings))
+	for i, finding := range findings {
+		records[i] = types.SecretScanFinding{
+			OrganizationID: orgID,
+			UserAccountID:  util.PtrTo(auth.CurrentUserID()),
+			Source:         source,
+			File:           finding.File,
+			Line:           finding.Line,
+			RuleID:         finding.RuleID,
+			Redacted:       finding.Redacted,
+			Fingerprint:    finding.Fingerprint,
+			Blocked:        blocked,
+		}
+		messages[i] = fmt.Sprintf("%v:%v: %v (rule %v, fingerprint %v)",
+			finding.File, finding.Line, finding.Description, finding.RuleID, finding.Fingerprint)
+	}
+	if err := db.CreateSecretScanFindings(ctx, records); err != nil {
+		log.Error("could not record secret scan findings", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	if blocked {
+		msg := "possible secrets found: " + strings.Join(messages, "; ")
+		w.Header().Set(api.ErrorCodeHeader, api.ErrorCodeSecretsFound)
+		http.Error(w, msg, http.StatusUnprocessableEntity)
+		return errors.New(msg)
+	}
+	for _, msg := range messages {
+		w.Header().Add(api.WarningHeader, "possible secret found: "+msg)
+	}
+	return nil
+}
+
+// getSecretScanner returns a scanner with the custom rules of the organization and the dismissed fingerprints.
+// Custom rules that do not compile anymore are skipped.
+func getSecretScanner(ctx context.Context, orgID uuid.UUID) (*secretscan.Scanner, map[string]struct{}, error) {
+	customRules, err := db.GetSecretScanRules(ctx, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	dismissals, err := db.GetSecretScanDismissals(ctx, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules := make([]secretscan.Rule, 0, len(customRules))
+	for _, customRule := range customRules {
+		if rule, err := secretscan.NewRule(customRule.RuleID, customRule.Description, customRule.Pattern); err != nil {
+			internalctx.GetLogger(ctx).Warn("skipping invalid secret scan rule",
+				zap.String("ruleId", customRule.RuleID), zap.Error(err))
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	dismissed := make(map[string]struct{}, len(dismissals))
+	for _, dismissal := range dismissals {
+		dismissed[dismissal.Fingerprint] = struct{}{}
+	}
+	return secretscan.New(rules...), dismissed, nil
+}
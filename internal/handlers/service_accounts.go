@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/authkey"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mapping"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ServiceAccountsRouter lets vendors manage the service accounts of their organization and issue registry tokens for
+// them. Only the current organization can be addressed with the organizationId path parameter.
+func ServiceAccountsRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor, requireCurrentOrganizationPath)
+	r.Get("/", getServiceAccountsHandler)
+	r.Post("/", createServiceAccountHandler)
+	r.With(serviceAccountMiddleware).Route("/{serviceAccountId}", func(r chi.Router) {
+		r.Get("/", getServiceAccountHandler)
+		r.Put("/", updateServiceAccountHandler)
+		r.Delete("/", revokeServiceAccountHandler)
+		r.Route("/tokens", func(r chi.Router) {
+			r.Get("/", getServiceAccountTokensHandler)
+			r.Post("/", createServiceAccountTokenHandler)
+			r.Delete("/{tokenId}", deleteServiceAccountTokenHandler)
+		})
+	})
+}
+
+func requireCurrentOrganizationPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := auth.Authentication.Require(r.Context())
+		if orgID, err := uuid.Parse(r.PathValue("organizationId")); err != nil || orgID != *auth.CurrentOrgID() {
+			http.NotFound(w, r)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func getServiceAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if serviceAccounts, err := db.GetServiceAccounts(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get service accounts", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, serviceAccounts)
+	}
+}
+
+func createServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.CreateServiceAccountRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := auth.CurrentUserID()
+	serviceAccount := types.ServiceAccount{
+		OrganizationID:         *auth.CurrentOrgID(),
+		Name:                   request.Name,
+		Description:            request.Description,
+		CreatedByUserAccountID: &userID,
+	}
+	if err := db.CreateServiceAccount(ctx, &serviceAccount); errors.Is(err, apierrors.ErrAlreadyExists) {
+		http.Error(w, "a service account with this name already exists", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create service account", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, serviceAccount)
+	}
+}
+
+func getServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	RespondJSON(w, internalctx.GetServiceAccount(r.Context()))
+}
+
+func updateServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	request, err := JsonBody[api.UpdateServiceAccountRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serviceAccount := *internalctx.GetServiceAccount(ctx)
+	serviceAccount.Name = request.Name
+	serviceAccount.Description = request.Description
+	if err := db.UpdateServiceAccount(ctx, &serviceAccount); errors.Is(err, apierrors.ErrNotFound) {
+		http.Error(w, "service account has been revoked", http.StatusBadRequest)
+	} else if errors.Is(err, apierrors.ErrAlreadyExists) {
+		http.Error(w, "a service account with this name already exists", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to update service account", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, serviceAccount)
+	}
+}
+
+func revokeServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceAccount := internalctx.GetServiceAccount(ctx)
+	if revoked, err := db.RevokeServiceAccount(
+		ctx, serviceAccount.ID, serviceAccount.OrganizationID,
+	); errors.Is(err, apierrors.ErrNotFound) {
+		http.Error(w, "service account has already been revoked", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to revoke service account", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, revoked)
+	}
+}
+
+func getServiceAccountTokensHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceAccount := internalctx.GetServiceAccount(ctx)
+	if tokens, err := db.GetServiceAccountTokens(ctx, serviceAccount.ID); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get service account tokens", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, mapping.List(tokens, mapping.ServiceAccountTokenToDTO))
+	}
+}
+
+func createServiceAccountTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	serviceAccount := internalctx.GetServiceAccount(ctx)
+	request, err := JsonBody[api.CreateAccessTokenRequest](w, r)
+	if err != nil {
+		return
+	} else if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if serviceAccount.IsRevoked() {
+		http.Error(w, "service account has been revoked", http.StatusBadRequest)
+		return
+	}
+
+	key, err := authkey.NewKey()
+	if err != nil {
+		log.Warn("error creating token", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token := types.ServiceAccountToken{
+		ServiceAccountID: serviceAccount.ID,
+		Label:            request.Label,
+		KeyHash:          key.Hash(),
+		KeyPrefix:        key.DisplayPrefix(),
+		ExpiresAt:        request.ExpiresAt,
+		RegistryScopes:   request.RegistryScopes,
+	}
+	if err := db.CreateServiceAccountToken(ctx, &token); err != nil {
+		log.Warn("error creating token", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, mapping.ServiceAccountTokenToDTO(token).WithKey(key))
+	}
+}
+
+func deleteServiceAccountTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceAccount := internalctx.GetServiceAccount(ctx)
+	tokenID, err := uuid.Parse(r.PathValue("tokenId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := db.DeleteServiceAccountToken(ctx, tokenID, serviceAccount.ID); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete service account token", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func serviceAccountMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		serviceAccountID, err := uuid.Parse(r.PathValue("serviceAccountId"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		auth := auth.Authentication.Require(ctx)
+		serviceAccount, err := db.GetServiceAccount(ctx, serviceAccountID, *auth.CurrentOrgID())
+		if errors.Is(err, apierrors.ErrNotFound) {
+			http.NotFound(w, r)
+		} else if err != nil {
+			internalctx.GetLogger(ctx).Error("failed to get service account", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			next.ServeHTTP(w, r.WithContext(internalctx.WithServiceAccount(ctx, serviceAccount)))
+		}
+	})
+}
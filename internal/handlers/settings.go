@@ -16,6 +16,7 @@ import (
 	"github.com/glasskube/distr/internal/mapping"
 	"github.com/glasskube/distr/internal/middleware"
 	"github.com/glasskube/distr/internal/security"
+	"github.com/glasskube/distr/internal/securityevents"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/go-chi/chi/v5"
@@ -30,6 +31,7 @@ func SettingsRouter(r chi.Router) {
 		r.With(requestVerificationMailRateLimitPerUser).Post("/request", userSettingsVerifyRequestHandler)
 		r.Post("/confirm", userSettingsVerifyConfirmHandler)
 	})
+	r.Route("/passkeys", passkeysRouter)
 	r.Route("/tokens", func(r chi.Router) {
 		r.Use(middleware.RequireOrgAndRole)
 		r.Get("/", getAccessTokensHandler())
@@ -65,6 +67,7 @@ func userSettingsUpdateHandler(w http.ResponseWriter, r *http.Request) {
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			log.Error("failed to hash password", zap.Error(err))
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 	}
 
@@ -80,6 +83,13 @@ func userSettingsUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	} else {
+		if body.Password != nil {
+			event := securityevents.New(r, *user, types.SecurityEventTypePasswordChanged)
+			if err := securityevents.Record(ctx, *user, &event); err != nil {
+				log.Warn("could not record security event", zap.Error(err))
+				sentry.GetHubFromContext(ctx).CaptureException(err)
+			}
+		}
 		RespondJSON(w, user)
 	}
 }
@@ -144,6 +154,14 @@ func createAccessTokenHandler() http.HandlerFunc {
 		request, err := JsonBody[api.CreateAccessTokenRequest](w, r)
 		if err != nil {
 			return
+		} else if err := request.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if *auth.CurrentUserRole() != types.UserRoleVendor &&
+			(request.RegistryScopes.Grants(types.RegistryActionPush) ||
+				request.RegistryScopes.Grants(types.RegistryActionDelete)) {
+			http.Error(w, "only vendors can create tokens with push or delete scopes", http.StatusBadRequest)
+			return
 		}
 
 		key, err := authkey.NewKey()
@@ -158,15 +176,25 @@ func createAccessTokenHandler() http.HandlerFunc {
 			ExpiresAt:      request.ExpiresAt,
 			Label:          request.Label,
 			UserAccountID:  auth.CurrentUserID(),
-			Key:            key,
+			KeyHash:        key.Hash(),
+			KeyPrefix:      key.DisplayPrefix(),
 			OrganizationID: *auth.CurrentOrgID(),
+			RegistryScopes: request.RegistryScopes,
 		}
 		if err := db.CreateAccessToken(ctx, &token); err != nil {
 			log.Warn("error creating token", zap.Error(err))
 			sentry.GetHubFromContext(ctx).CaptureException(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		} else {
-			RespondJSON(w, mapping.AccessTokenToDTO(token).WithKey(token.Key))
+			event := securityevents.New(r, *auth.CurrentUser(), types.SecurityEventTypeAccessTokenCreated)
+			if token.Label != nil {
+				event.Details = util.PtrTo("Label: " + *token.Label)
+			}
+			if err := securityevents.Record(ctx, *auth.CurrentUser(), &event); err != nil {
+				log.Warn("could not record security event", zap.Error(err))
+				sentry.GetHubFromContext(ctx).CaptureException(err)
+			}
+			RespondJSON(w, mapping.AccessTokenToDTO(token).WithKey(key))
 		}
 	}
 }
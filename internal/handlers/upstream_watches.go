@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/upstreamwatch"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func UpstreamWatchesRouter(r chi.Router) {
+	r.Use(requireUserRoleVendor, middleware.RequireOrgAndRole)
+	r.Get("/", getUpstreamWatches)
+	r.Post("/", createUpstreamWatch)
+	r.Route("/{upstreamWatchId}", func(r chi.Router) {
+		r.Delete("/", deleteUpstreamWatch)
+		r.Get("/changes", getUpstreamWatchChanges)
+	})
+}
+
+func getUpstreamWatches(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if watches, err := db.GetUpstreamWatches(ctx, *auth.CurrentOrgID()); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get upstream watches", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, watches)
+	}
+}
+
+func createUpstreamWatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	request, err := JsonBody[api.CreateUpstreamWatchRequest](w, r)
+	if err != nil {
+		return
+	}
+	reference, registry, err := upstreamwatch.ParseReference(request.Reference)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	watch := types.UpstreamWatch{
+		OrganizationID:         *auth.CurrentOrgID(),
+		CreatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
+		Reference:              reference,
+		Registry:               registry,
+	}
+	if err := db.CreateUpstreamWatch(ctx, &watch); errors.Is(err, apierrors.ErrAlreadyExists) {
+		http.Error(w, "this image is already being watched", http.StatusBadRequest)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to create upstream watch", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, watch)
+	}
+}
+
+func deleteUpstreamWatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if id, err := uuid.Parse(r.PathValue("upstreamWatchId")); err != nil {
+		http.NotFound(w, r)
+	} else if err := db.DeleteUpstreamWatch(ctx, id, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to delete upstream watch", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getUpstreamWatchChanges(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	auth := auth.Authentication.Require(ctx)
+	if id, err := uuid.Parse(r.PathValue("upstreamWatchId")); err != nil {
+		http.NotFound(w, r)
+	} else if _, err := db.GetUpstreamWatch(ctx, id, *auth.CurrentOrgID()); errors.Is(err, apierrors.ErrNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get upstream watch", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if changes, err := db.GetUpstreamWatchChanges(ctx, id); err != nil {
+		internalctx.GetLogger(ctx).Error("failed to get upstream watch changes", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, changes)
+	}
+}
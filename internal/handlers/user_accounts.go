@@ -5,19 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
+	"slices"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/api"
 	"github.com/glasskube/distr/internal/apierrors"
 	"github.com/glasskube/distr/internal/auth"
-	"github.com/glasskube/distr/internal/authjwt"
 	internalctx "github.com/glasskube/distr/internal/context"
-	"github.com/glasskube/distr/internal/customdomains"
+	"github.com/glasskube/distr/internal/customfields"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/mailsending"
 	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/securityevents"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -26,27 +26,104 @@ import (
 
 func UserAccountsRouter(r chi.Router) {
 	r.With(requireUserRoleVendor, middleware.RequireOrgAndRole).Group(func(r chi.Router) {
-		r.Get("/", getUserAccountsHandler)
+		r.With(replacedInV2("/user-accounts")).Get("/", getUserAccountsHandler)
 		r.Post("/", createUserAccountHandler)
 		r.Route("/{userId}", func(r chi.Router) {
 			r.Use(userAccountMiddleware)
 			r.Delete("/", deleteUserAccountHandler)
 			r.Patch("/image", patchImageUserAccount)
+			r.Put("/custom-fields", putUserAccountCustomFields)
+			r.Route("/data-exports", customerDataExportRouter)
 		})
 	})
 	r.Get("/status", getUserAccountStatusHandler)
+	r.Route("/me", func(r chi.Router) {
+		r.Get("/security-events", getSecurityEventsHandler)
+		r.Get("/security-event-preferences", getSecurityEventPreferencesHandler)
+		r.Put("/security-event-preferences", putSecurityEventPreferencesHandler)
+		r.With(
+			middleware.RequireOrgAndRole,
+			middleware.UserRoleMiddleware(types.UserRoleCustomer),
+			currentUserAccountMiddleware,
+		).Route("/data-exports", customerDataExportRouter)
+	})
+}
+
+func UserAccountsV2Router(r chi.Router) {
+	r.With(requireUserRoleVendor, middleware.RequireOrgAndRole).Get("/", getUserAccountsHandler)
 }
 
 func getUserAccountsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
 	auth := auth.Authentication.Require(ctx)
-	if userAccounts, err := db.GetUserAccountsByOrgID(ctx, *auth.CurrentOrgID(), nil); err != nil {
+	defs, err := db.GetCustomFieldDefinitions(ctx, *auth.CurrentOrgID(), types.CustomFieldTargetCustomer)
+	if err != nil {
+		log.Error("failed to get custom field definitions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filter, err := customfields.ParseFilter(defs, r.URL.Query(), false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := PageParam(r, db.UserAccountsPageSpec, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if userAccounts, next, err := db.GetUserAccountsPageByOrgID(ctx, *auth.CurrentOrgID(), filter, page); err != nil {
 		log.Error("failed to get user accounts", zap.Error(err))
 		sentry.GetHubFromContext(ctx).CaptureException(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else if customFields, err := db.GetCustomFieldValues(
+		ctx, *auth.CurrentOrgID(), types.CustomFieldTargetCustomer, filter,
+	); err != nil {
+		log.Error("failed to get custom field values", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	} else {
-		RespondJSON(w, api.MapUserAccountsToResponse(userAccounts))
+		result := api.MapUserAccountsToResponse(userAccounts)
+		for i := range result {
+			result[i].CustomFields = customFields[result[i].ID]
+		}
+		RespondJSONPage(w, r, db.UserAccountsPageSpec, next, result)
+	}
+}
+
+func putUserAccountCustomFields(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	userAccount := internalctx.GetUserAccount(ctx)
+	if userAccount.UserRole != types.UserRoleCustomer {
+		http.Error(w, "custom fields can only be set for customers", http.StatusBadRequest)
+		return
+	}
+	body, err := JsonBody[types.CustomFields](w, r)
+	if err != nil {
+		return
+	}
+	if defs, err := db.GetCustomFieldDefinitions(ctx, *auth.CurrentOrgID(), types.CustomFieldTargetCustomer); err != nil {
+		log.Error("failed to get custom field definitions", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else if existing, err := db.GetCustomFieldValues(
+		ctx, *auth.CurrentOrgID(), types.CustomFieldTargetCustomer, nil,
+	); err != nil {
+		log.Error("failed to get custom field values", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else if merged, err := customfields.Merge(defs, existing[userAccount.ID], body, false); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err := db.UpdateCustomerCustomFields(ctx, *auth.CurrentOrgID(), userAccount.ID, merged); err != nil {
+		log.Error("failed to update custom field values", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, merged)
 	}
 }
 
@@ -59,6 +136,61 @@ func getUserAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func getSecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	page, err := PageParam(r, db.SecurityEventsPageSpec, 25)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if events, next, err := db.GetSecurityEventsPage(ctx, auth.CurrentUserID(), page); err != nil {
+		log.Error("failed to get security events", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSONPage(w, r, db.SecurityEventsPageSpec, next, events)
+	}
+}
+
+func getSecurityEventPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	if disabled, err := db.GetUserAccountSecurityEventMailsDisabled(ctx, auth.CurrentUserID()); err != nil {
+		log.Error("failed to get security event preferences", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.SecurityEventPreferences{MailsDisabled: disabled, Notifiable: securityevents.Notifiable()})
+	}
+}
+
+func putSecurityEventPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	auth := auth.Authentication.Require(ctx)
+	body, err := JsonBody[api.SecurityEventPreferences](w, r)
+	if err != nil {
+		return
+	}
+	notifiable := securityevents.Notifiable()
+	for _, t := range body.MailsDisabled {
+		if !slices.Contains(notifiable, t) {
+			http.Error(w, fmt.Sprintf("mails can not be disabled for event type %q", t), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := db.UpdateUserAccountSecurityEventMailsDisabled(ctx, auth.CurrentUserID(), body.MailsDisabled); err != nil {
+		log.Error("failed to update security event preferences", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		RespondJSON(w, api.SecurityEventPreferences{MailsDisabled: body.MailsDisabled, Notifiable: notifiable})
+	}
+}
+
 func createUserAccountHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := internalctx.GetLogger(ctx)
@@ -120,17 +252,12 @@ func createUserAccountHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if !userHasExisted {
-			// TODO: Should probably use a different mechanism for invite tokens but for now this should work OK
-			if _, token, err := authjwt.GenerateVerificationTokenValidFor(userAccount); err != nil {
+			if result, err := mailsending.InviteURL(userAccount, organization.Organization); err != nil {
 				sentry.GetHubFromContext(ctx).CaptureException(err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return err
 			} else {
-				inviteURL = fmt.Sprintf(
-					"%v/join?jwt=%v",
-					customdomains.AppDomainOrDefault(organization.Organization),
-					url.QueryEscape(token),
-				)
+				inviteURL = result
 			}
 		}
 
@@ -176,6 +303,10 @@ func deleteUserAccountHandler(w http.ResponseWriter, r *http.Request) {
 			if errors.Is(err, apierrors.ErrNotFound) {
 				w.WriteHeader(http.StatusNoContent)
 				return nil
+			} else if errors.Is(err, apierrors.ErrConflict) {
+				http.Error(w, "Please ensure there are no deployment targets and licenses owned by this user and try again",
+					http.StatusBadRequest)
+				return nil
 			} else {
 				return err
 			}
@@ -183,6 +314,10 @@ func deleteUserAccountHandler(w http.ResponseWriter, r *http.Request) {
 			return err
 		} else if err := db.DeleteTutorialProgressesOfUserInOrg(ctx, userAccount.ID, *auth.CurrentOrgID()); err != nil {
 			return err
+		} else if err := db.DeleteApplicationLicenseSeatsOfUserInOrg(ctx, userAccount.ID, *auth.CurrentOrgID()); err != nil {
+			return err
+		} else if err := db.DeleteArtifactLicenseSeatsOfUserInOrg(ctx, userAccount.ID, *auth.CurrentOrgID()); err != nil {
+			return err
 		} else {
 			w.WriteHeader(http.StatusNoContent)
 			return nil
@@ -9,8 +9,10 @@ import (
 	"net/http"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/apiversion"
 	"github.com/glasskube/distr/internal/contenttype"
 	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/middleware"
 	"github.com/glasskube/distr/internal/types"
 	"go.uber.org/zap"
@@ -18,13 +20,26 @@ import (
 
 func JsonBody[T any](w http.ResponseWriter, r *http.Request) (T, error) {
 	var t T
+	if err := middleware.CheckContentType(r, contenttype.MediaTypeJSON); err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return t, err
+	}
 	err := json.NewDecoder(r.Body).Decode(&t)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequestBody(w, err)
 	}
 	return t, err
 }
 
+// badRequestBody responds with 413 if err was caused by a request body that exceeds the limit and with 400 otherwise.
+func badRequestBody(w http.ResponseWriter, err error) {
+	if limit, ok := middleware.IsRequestBodyTooLarge(err); ok {
+		middleware.RequestBodyTooLarge(w, limit)
+	} else {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
 func RespondJSON(w http.ResponseWriter, data any) {
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
@@ -34,6 +49,20 @@ func RespondJSON(w http.ResponseWriter, data any) {
 
 var requireUserRoleVendor = middleware.UserRoleMiddleware(types.UserRoleVendor)
 
+// replacedInV2 marks an API v1 route as deprecated in favor of route in API v2.
+func replacedInV2(route string) func(http.Handler) http.Handler {
+	return apiversion.Deprecated(apiversion.Deprecation{
+		DeprecatedAt: apiversion.V2ReleasedAt,
+		Sunset:       env.APIV1Sunset(),
+		Successor:    apiversion.V2.Path(route),
+	})
+}
+
+// multipartUpload must be used for all routes that accept file uploads instead of JSON.
+func multipartUpload(next http.Handler) http.Handler {
+	return middleware.MultipartUpload(env.UploadRequestBodyMaxSize())(next)
+}
+
 func readMultipartFile(w http.ResponseWriter, r *http.Request, formKey string) ([]byte, bool) {
 	log := internalctx.GetLogger(r.Context())
 	if file, head, err := r.FormFile(formKey); err != nil {
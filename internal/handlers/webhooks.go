@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/mail/ses"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// WebhooksRouter contains routes that are called by external providers. They are not authenticated with user tokens
+// and accept the content type chosen by the provider.
+func WebhooksRouter(r chi.Router) {
+	r.Post("/ses", sesWebhookHandler)
+}
+
+var snsHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// sesWebhookHandler receives SES bounce, complaint and delivery notifications from an SNS HTTPS subscription and
+// updates the status of the corresponding sent mails. The subscription URL must contain the configured token.
+func sesWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := internalctx.GetLogger(ctx)
+	token := env.MailerSESWebhookToken()
+	if token == nil {
+		http.NotFound(w, r)
+		return
+	} else if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(*token)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var msg ses.SNSMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		badRequestBody(w, err)
+		return
+	}
+	log = log.With(zap.String("snsMessageId", msg.MessageID), zap.String("topicArn", msg.TopicArn))
+
+	switch msg.Type {
+	case ses.SNSMessageTypeSubscriptionConfirmation:
+		if err := ses.ConfirmSubscription(ctx, snsHTTPClient, msg); err != nil {
+			log.Warn("could not confirm SNS subscription", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Info("SNS subscription confirmed")
+	case ses.SNSMessageTypeNotification:
+		notification, err := ses.ParseNotification(msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if status, details, ok := notification.SentMailStatus(); ok {
+			if n, err := db.UpdateSentMailStatusByProviderMessageID(
+				ctx, notification.Mail.MessageID, status, details,
+			); err != nil {
+				log.Error("failed to update sent mail status", zap.Error(err))
+				sentry.GetHubFromContext(ctx).CaptureException(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			} else if n == 0 {
+				log.Debug("SES notification for unknown mail", zap.String("messageId", notification.Mail.MessageID))
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,82 @@
+// Package legalhold places and releases legal holds, which suspend all automated deletion of the data of an
+// organization or of one of its customer accounts. Holds are managed by platform admins with the hub CLI only, so
+// that neither vendors nor customers can lift them.
+package legalhold
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	maxReasonLength = 500
+
+	auditResourceType  = "LegalHold"
+	auditActionPlace   = "place"
+	auditActionRelease = "release"
+)
+
+var (
+	ErrReasonRequired = errors.New("reason is required")
+	ErrReasonTooLong  = errors.New("reason must not be longer than 500 characters")
+	ErrNotCustomer    = errors.New("user account is not a customer of the organization")
+)
+
+// Place places a legal hold on hold.OrganizationID, or only on the data of the customer account hold.UserAccountID,
+// and records this in the audit log. apierrors.ErrConflict is returned if the scope is already held.
+func Place(ctx context.Context, hold *types.LegalHold, source string) error {
+	hold.Reason = strings.TrimSpace(hold.Reason)
+	if hold.Reason == "" {
+		return ErrReasonRequired
+	} else if len(hold.Reason) > maxReasonLength {
+		return ErrReasonTooLong
+	}
+	return db.RunTx(ctx, func(ctx context.Context) error {
+		if _, err := db.GetOrganizationByID(ctx, hold.OrganizationID); err != nil {
+			return err
+		}
+		if hold.UserAccountID != nil {
+			if user, err := db.GetUserAccountWithRole(ctx, *hold.UserAccountID, hold.OrganizationID); err != nil {
+				return err
+			} else if user.UserRole != types.UserRoleCustomer {
+				return ErrNotCustomer
+			}
+		}
+		if err := db.CreateLegalHold(ctx, hold); err != nil {
+			return err
+		}
+		return audit(ctx, auditActionPlace, *hold, source)
+	})
+}
+
+// Release releases the legal hold of an organization, or of one of its customer accounts if userAccountID is not
+// nil, and records this in the audit log. apierrors.ErrNotFound is returned if there is no such hold.
+func Release(ctx context.Context, orgID uuid.UUID, userAccountID *uuid.UUID, source string) error {
+	return db.RunTx(ctx, func(ctx context.Context) error {
+		if hold, err := db.DeleteLegalHold(ctx, orgID, userAccountID); err != nil {
+			return err
+		} else {
+			return audit(ctx, auditActionRelease, *hold, source)
+		}
+	})
+}
+
+func audit(ctx context.Context, action string, hold types.LegalHold, source string) error {
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: &hold.OrganizationID,
+		Action:         action,
+		ResourceType:   auditResourceType,
+		ResourceID:     hold.ID,
+		Data: map[string]any{
+			"userAccountId": hold.UserAccountID,
+			"reason":        hold.Reason,
+			"since":         hold.CreatedAt,
+			"source":        source,
+		},
+	})
+}
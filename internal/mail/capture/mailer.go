@@ -0,0 +1,36 @@
+// Package capture provides a mail.Mailer that records mails instead of sending them, so that tests can make assertions
+// about them.
+package capture
+
+import (
+	"context"
+	"sync"
+
+	"github.com/glasskube/distr/internal/mail"
+)
+
+type Mailer struct {
+	mu     sync.Mutex
+	outbox []mail.Mail
+}
+
+var _ mail.Mailer = &Mailer{}
+
+func New() *Mailer { return &Mailer{} }
+
+// Send implements mail.Mailer by adding mail to the outbox.
+func (m *Mailer) Send(ctx context.Context, mail mail.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = append(m.outbox, mail)
+	return nil
+}
+
+// Drain returns all mails that have been sent since the last call and empties the outbox.
+func (m *Mailer) Drain() []mail.Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outbox := m.outbox
+	m.outbox = nil
+	return outbox
+}
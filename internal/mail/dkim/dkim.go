@@ -0,0 +1,207 @@
+// Package dkim signs mails with DomainKeys Identified Mail signatures (RFC 6376), so that recipients can verify that
+// a mail sent on behalf of a sender domain has been authorized by the owner of the domain.
+//
+// Signatures use rsa-sha256 and relaxed canonicalization for the header and the body, which tolerates the changes
+// that relays commonly make, like refolding header fields.
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// KeyBits is the size of the keys created by NewKey.
+const KeyBits = 2048
+
+// SignedHeaders are the header fields that are included in a signature, if they are present.
+var SignedHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID", "MIME-Version", "Content-Type",
+}
+
+// Signer signs mails for Domain with the key that is published in the DNS record of Selector.
+type Signer struct {
+	Domain   string
+	Selector string
+	Key      *rsa.PrivateKey
+}
+
+// NewKey creates a new private key and returns it PEM encoded.
+func NewKey() (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, KeyBits)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseKey parses a PEM encoded private key created by NewKey.
+func ParseKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid DKIM key: no PEM data found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DKIM key: %w", err)
+	}
+	if rsaKey, ok := key.(*rsa.PrivateKey); !ok {
+		return nil, fmt.Errorf("invalid DKIM key: unsupported key type %T", key)
+	} else {
+		return rsaKey, nil
+	}
+}
+
+// PublicKeyRecord returns the value of the DNS TXT record that recipients use to verify signatures created with key.
+func PublicKeyRecord(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+}
+
+// Sign returns the value of the DKIM-Signature header field for message, which must be a complete message with CRLF
+// line endings. The header field must be added to the message without changing anything else.
+func (s *Signer) Sign(message []byte, now time.Time) (string, error) {
+	header, body, found := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !found {
+		header, body = bytes.TrimSuffix(message, []byte("\r\n")), nil
+	}
+	fields := parseHeader(string(header))
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+	var names []string
+	hash := sha256.New()
+	for _, name := range SignedHeaders {
+		if value, ok := fields[strings.ToLower(name)]; ok {
+			names = append(names, name)
+			hash.Write([]byte(canonicalHeader(name, value) + "\r\n"))
+		}
+	}
+	signature := fmt.Sprintf("v=1; a=rsa-sha256; c=relaxed/relaxed; d=%v; s=%v; t=%v; h=%v; bh=%v; b=",
+		s.Domain, s.Selector, now.Unix(), strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+	// the signature header field itself is signed with an empty b= tag and without the trailing CRLF
+	hash.Write([]byte(canonicalHeader("DKIM-Signature", signature)))
+
+	if b, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, hash.Sum(nil)); err != nil {
+		return "", err
+	} else {
+		return signature + base64.StdEncoding.EncodeToString(b), nil
+	}
+}
+
+// Verify checks the DKIM-Signature header field of message against the public key. Only signatures with the
+// algorithm and canonicalization used by Signer are supported.
+func Verify(message []byte, key *rsa.PublicKey) error {
+	header, body, found := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !found {
+		header, body = bytes.TrimSuffix(message, []byte("\r\n")), nil
+	}
+	fields := parseHeader(string(header))
+	value, ok := fields["dkim-signature"]
+	if !ok {
+		return errors.New("DKIM-Signature not found")
+	}
+	tags := map[string]string{}
+	for tag := range strings.SplitSeq(value, ";") {
+		if name, v, ok := strings.Cut(tag, "="); ok {
+			tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(v), "")
+		}
+	}
+	if tags["a"] != "rsa-sha256" || tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unsupported DKIM signature: a=%v c=%v", tags["a"], tags["c"])
+	}
+
+	bodyHash := sha256.Sum256(canonicalBody(body))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return errors.New("DKIM body hash mismatch")
+	}
+	hash := sha256.New()
+	for name := range strings.SplitSeq(tags["h"], ":") {
+		if v, ok := fields[strings.ToLower(name)]; ok {
+			hash.Write([]byte(canonicalHeader(name, v) + "\r\n"))
+		}
+	}
+	hash.Write([]byte(canonicalHeader("DKIM-Signature", signatureTagPattern.ReplaceAllString(value, "$1"))))
+	if signature, err := base64.StdEncoding.DecodeString(tags["b"]); err != nil {
+		return fmt.Errorf("invalid DKIM signature: %w", err)
+	} else {
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash.Sum(nil), signature)
+	}
+}
+
+// signatureTagPattern matches the b= tag of a signature, so that its value can be removed.
+var signatureTagPattern = regexp.MustCompile(`((?:^|;)\s*b\s*=)[^;]*`)
+
+// parseHeader returns the unfolded values of the header fields by their lower case name. If a field occurs more than
+// once, the last occurrence is used, which is the one that verifiers pick first.
+func parseHeader(header string) map[string]string {
+	fields := map[string]string{}
+	var name string
+	for line := range strings.SplitSeq(header, "\r\n") {
+		if line == "" {
+			continue
+		} else if (line[0] == ' ' || line[0] == '\t') && name != "" {
+			fields[name] += line
+		} else if n, value, ok := strings.Cut(line, ":"); ok {
+			name = strings.ToLower(strings.TrimRight(n, " \t"))
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// canonicalHeader implements the relaxed header canonicalization of RFC 6376, section 3.4.2.
+func canonicalHeader(name, value string) string {
+	return strings.ToLower(name) + ":" + strings.TrimSpace(collapseWhitespace(value))
+}
+
+// canonicalBody implements the relaxed body canonicalization of RFC 6376, section 3.4.4.
+func canonicalBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseWhitespace(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func collapseWhitespace(value string) string {
+	var b strings.Builder
+	space := false
+	for _, c := range value {
+		if c == ' ' || c == '\t' {
+			space = true
+			continue
+		} else if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(c)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
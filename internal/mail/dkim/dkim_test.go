@@ -0,0 +1,68 @@
+package dkim_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/mail/dkim"
+	. "github.com/onsi/gomega"
+)
+
+const testMessage = "From: Vendor <mail@example.com>\r\n" +
+	"To: user@example.org\r\n" +
+	"Subject: Welcome\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"\r\n" +
+	"Hello  world\r\n" +
+	"\r\n"
+
+func newSigner(t *testing.T) *dkim.Signer {
+	t.Helper()
+	data, err := dkim.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := dkim.ParseKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dkim.Signer{Domain: "example.com", Selector: "distr", Key: key}
+}
+
+func signed(g Gomega, signer *dkim.Signer, message string) string {
+	signature, err := signer.Sign([]byte(message), time.Unix(1700000000, 0))
+	g.Expect(err).NotTo(HaveOccurred())
+	return "DKIM-Signature: " + signature + "\r\n" + message
+}
+
+func TestSign(t *testing.T) {
+	g := NewWithT(t)
+	signer := newSigner(t)
+	message := signed(g, signer, testMessage)
+	g.Expect(message).To(ContainSubstring("d=example.com; s=distr; t=1700000000; h=From:Subject:To:Content-Type;"))
+	g.Expect(dkim.Verify([]byte(message), &signer.Key.PublicKey)).To(Succeed())
+
+	// relaxed canonicalization tolerates refolded header fields and changed whitespace
+	relayed := strings.Replace(message, "Subject: Welcome", "Subject:\r\n\tWelcome ", 1)
+	relayed = strings.Replace(relayed, "Hello  world\r\n", "Hello world \r\n\r\n", 1)
+	g.Expect(dkim.Verify([]byte(relayed), &signer.Key.PublicKey)).To(Succeed())
+
+	g.Expect(dkim.Verify([]byte(strings.Replace(message, "Hello", "Bye", 1)), &signer.Key.PublicKey)).
+		To(MatchError(ContainSubstring("body hash mismatch")))
+	g.Expect(dkim.Verify([]byte(strings.Replace(message, "Welcome", "Invoice", 1)), &signer.Key.PublicKey)).
+		NotTo(Succeed())
+	g.Expect(dkim.Verify([]byte(message), &newSigner(t).Key.PublicKey)).NotTo(Succeed())
+	g.Expect(dkim.Verify([]byte(testMessage), &signer.Key.PublicKey)).To(MatchError(ContainSubstring("not found")))
+}
+
+func TestPublicKeyRecord(t *testing.T) {
+	g := NewWithT(t)
+	signer := newSigner(t)
+	record, err := dkim.PublicKeyRecord(signer.Key)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(record).To(HavePrefix("v=DKIM1; k=rsa; p=MIIB"))
+
+	_, err = dkim.ParseKey("not a key")
+	g.Expect(err).To(HaveOccurred())
+}
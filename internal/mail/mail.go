@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"html/template"
 	"net/mail"
+
+	"github.com/glasskube/distr/internal/mail/dkim"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
 )
 
 type Mail struct {
@@ -14,6 +18,13 @@ type Mail struct {
 	Subject      string
 	HtmlBodyFunc func() (string, error)
 	TextBodyFunc func() (string, error)
+	// OrganizationID is the organization in whose scope the mail is sent.
+	// It is used to select an organization specific transport, if one is configured.
+	OrganizationID *uuid.UUID
+	// Type is recorded in the sent mail log. Mails without a type are logged as types.MailTypeOther.
+	Type types.MailType
+	// DKIM signs the mail on behalf of the domain of the sender, if it is set.
+	DKIM *dkim.Signer
 }
 
 type MailOpt func(mail *Mail)
@@ -70,6 +81,18 @@ func TextBody(body string) MailOpt {
 	}
 }
 
+func Organization(id uuid.UUID) MailOpt {
+	return func(mail *Mail) {
+		mail.OrganizationID = &id
+	}
+}
+
+func Type(t types.MailType) MailOpt {
+	return func(mail *Mail) {
+		mail.Type = t
+	}
+}
+
 type mailOpts []MailOpt
 
 func (opts mailOpts) Apply(mail *Mail) {
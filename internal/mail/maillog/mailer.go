@@ -0,0 +1,59 @@
+// Package maillog records every mail that is sent in the sent mail log of its organization.
+package maillog
+
+import (
+	"context"
+	"strings"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mail/orgmailer"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type mailer struct {
+	next   mail.Mailer
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+var _ mail.Mailer = &mailer{}
+
+func New(next mail.Mailer, pool *pgxpool.Pool, logger *zap.Logger) *mailer {
+	return &mailer{next: next, pool: pool, logger: logger}
+}
+
+// Send implements mail.Mailer.
+// Failing to write the log entry is only logged, so that it never prevents a mail from being sent.
+func (m *mailer) Send(ctx context.Context, msg mail.Mail) error {
+	sendCtx, messageID := mail.WithMessageIDRecorder(ctx)
+	sendErr := m.next.Send(sendCtx, msg)
+
+	entry := types.SentMail{
+		OrganizationID: orgmailer.OrganizationID(ctx, msg),
+		Recipient:      strings.Join(msg.To, ", "),
+		Type:           msg.Type,
+		Subject:        msg.Subject,
+		Status:         types.SentMailStatusSent,
+	}
+	if entry.Type == "" {
+		entry.Type = types.MailTypeOther
+	}
+	if id := messageID(); id != "" {
+		entry.ProviderMessageID = &id
+	}
+	if sendErr != nil {
+		entry.Status = types.SentMailStatusFailed
+		entry.StatusDetails = util.PtrTo(sendErr.Error())
+	}
+	// The entry is written outside of any transaction that might be present in ctx, because the mail has been sent
+	// even if the surrounding transaction is rolled back.
+	if err := db.CreateSentMail(internalctx.WithDb(ctx, m.pool), &entry); err != nil {
+		m.logger.Warn("could not create sent mail log entry", zap.Error(err))
+	}
+	return sendErr
+}
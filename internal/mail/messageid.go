@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+type messageIDRecorderKey struct{}
+
+type messageIDRecorder struct {
+	mutex sync.Mutex
+	id    string
+}
+
+// WithMessageIDRecorder returns a context in which transports can report the id that their provider assigned to a
+// sent mail, and a function that returns the last reported id.
+// The id is passed through the context, because wrapping mailers might send a mail with any of several transports.
+func WithMessageIDRecorder(ctx context.Context) (context.Context, func() string) {
+	recorder := &messageIDRecorder{}
+	return context.WithValue(ctx, messageIDRecorderKey{}, recorder), func() string {
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+		return recorder.id
+	}
+}
+
+// RecordMessageID reports id to the recorder of ctx, if there is one.
+func RecordMessageID(ctx context.Context, id string) {
+	if recorder, ok := ctx.Value(messageIDRecorderKey{}).(*messageIDRecorder); ok {
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+		recorder.id = id
+	}
+}
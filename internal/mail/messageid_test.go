@@ -0,0 +1,20 @@
+package mail_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glasskube/distr/internal/mail"
+	. "github.com/onsi/gomega"
+)
+
+func TestMessageIDRecorder(t *testing.T) {
+	g := NewWithT(t)
+	mail.RecordMessageID(context.Background(), "ignored")
+
+	ctx, messageID := mail.WithMessageIDRecorder(context.Background())
+	g.Expect(messageID()).To(BeEmpty())
+	mail.RecordMessageID(ctx, "a")
+	mail.RecordMessageID(ctx, "b")
+	g.Expect(messageID()).To(Equal("b"))
+}
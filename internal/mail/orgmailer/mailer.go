@@ -0,0 +1,160 @@
+package orgmailer
+
+import (
+	"context"
+	"errors"
+	netmail "net/mail"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// mailer routes every mail to the transport configured for the organization in whose scope it is sent.
+// If an organization has no usable mail config or sending with it fails, the platform default is used instead.
+type mailer struct {
+	fallback    mail.Mailer
+	pool        *pgxpool.Pool
+	logger      *zap.Logger
+	maxFailures int
+}
+
+var _ mail.Mailer = &mailer{}
+
+func New(fallback mail.Mailer, pool *pgxpool.Pool, logger *zap.Logger, maxFailures int) *mailer {
+	return &mailer{fallback: fallback, pool: pool, logger: logger, maxFailures: maxFailures}
+}
+
+// Send implements mail.Mailer.
+func (m *mailer) Send(ctx context.Context, msg mail.Mail) error {
+	orgID := OrganizationID(ctx, msg)
+	if orgID == nil {
+		return m.fallback.Send(ctx, msg)
+	}
+
+	// The config is read and failures are recorded outside of any transaction that might be present in ctx,
+	// so that the failure state is persisted even if the surrounding transaction is rolled back.
+	dbCtx := internalctx.WithDb(ctx, m.pool)
+	log := m.logger.With(zap.Stringer("organizationId", orgID))
+
+	config, err := db.GetOrganizationMailConfig(dbCtx, *orgID)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		return m.fallback.Send(ctx, msg)
+	} else if err != nil {
+		log.Warn("could not get organization mail config, using default transport", zap.Error(err))
+		return m.fallback.Send(ctx, msg)
+	} else if !config.IsUsable() {
+		return m.fallback.Send(ctx, msg)
+	}
+
+	switch config.Type {
+	case types.MailConfigTypeDomain:
+		if orgMsg, err := withFromAddress(msg, config.FromAddress); err != nil {
+			log.Warn("invalid organization from address, using default transport", zap.Error(err))
+			return m.fallback.Send(ctx, msg)
+		} else if signer, err := dkimSigner(*config); err != nil {
+			log.Warn("invalid organization DKIM key, using default transport", zap.Error(err))
+			return m.fallback.Send(ctx, msg)
+		} else {
+			orgMsg.DKIM = signer
+			return m.fallback.Send(ctx, orgMsg)
+		}
+	case types.MailConfigTypeSMTP:
+		if err := m.sendSMTP(ctx, config, msg); err != nil {
+			log.Warn("sending with organization SMTP transport failed, using default transport", zap.Error(err))
+			m.recordFailure(dbCtx, log, config, err)
+			return m.fallback.Send(ctx, msg)
+		} else if config.FailureCount > 0 {
+			if err := db.ResetOrganizationMailConfigFailures(dbCtx, config.ID); err != nil {
+				log.Warn("could not reset organization mail config failures", zap.Error(err))
+			}
+		}
+		return nil
+	default:
+		return m.fallback.Send(ctx, msg)
+	}
+}
+
+func (m *mailer) sendSMTP(ctx context.Context, config *types.OrganizationMailConfig, msg mail.Mail) error {
+	if orgMsg, err := withFromAddress(msg, config.FromAddress); err != nil {
+		return err
+	} else if transport, err := newSMTPTransport(config); err != nil {
+		return err
+	} else {
+		return transport.Send(ctx, orgMsg)
+	}
+}
+
+func (m *mailer) recordFailure(
+	ctx context.Context,
+	log *zap.Logger,
+	config *types.OrganizationMailConfig,
+	sendErr error,
+) {
+	wasDisabled := config.DisabledAt != nil
+	if err := db.RecordOrganizationMailConfigFailure(ctx, config, sendErr.Error(), m.maxFailures); err != nil {
+		log.Warn("could not record organization mail config failure", zap.Error(err))
+	} else if !wasDisabled && config.DisabledAt != nil {
+		log.Warn("organization mail config has been disabled", zap.Int("failureCount", config.FailureCount))
+		if err := m.notifyDisabled(ctx, *config); err != nil {
+			log.Warn("could not send organization mail config disabled notification", zap.Error(err))
+		}
+	}
+}
+
+// notifyDisabled informs all vendor users of the organization that their mail config has been disabled.
+// It always uses the fallback transport, because the organization transport is known to be broken.
+func (m *mailer) notifyDisabled(ctx context.Context, config types.OrganizationMailConfig) error {
+	org, err := db.GetOrganizationWithBranding(ctx, config.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, config.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, user := range users {
+		errs = append(errs, m.fallback.Send(ctx, mail.New(
+			mail.To(user.Email),
+			mail.Subject("Your custom mail configuration has been disabled"),
+			mail.Type(types.MailTypeOrganizationMailConfigDisabled),
+			mail.HtmlBodyTemplate(mailtemplates.OrganizationMailConfigDisabled(*org, config)),
+		)))
+	}
+	return errors.Join(errs...)
+}
+
+// OrganizationID returns the organization in whose scope msg is sent. If msg does not specify one, the current
+// organization of the authenticated user is used.
+func OrganizationID(ctx context.Context, msg mail.Mail) *uuid.UUID {
+	if msg.OrganizationID != nil {
+		return msg.OrganizationID
+	}
+	if auth, err := auth.Authentication.Get(ctx); err == nil {
+		return auth.CurrentOrgID()
+	}
+	return nil
+}
+
+// withFromAddress returns a copy of msg that is sent from the given address.
+// The display name of the original sender is kept, unless address specifies its own.
+func withFromAddress(msg mail.Mail, address string) (mail.Mail, error) {
+	from, err := netmail.ParseAddress(address)
+	if err != nil {
+		return msg, err
+	}
+	if from.Name == "" && msg.From != nil {
+		from.Name = msg.From.Name
+	}
+	msg.From = from
+	return msg, nil
+}
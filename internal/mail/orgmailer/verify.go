@@ -0,0 +1,147 @@
+package orgmailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mail/dkim"
+	"github.com/glasskube/distr/internal/mail/smtp"
+	"github.com/glasskube/distr/internal/types"
+	gomail "github.com/wneessen/go-mail"
+)
+
+const (
+	verificationRecordPrefix = "_distr-challenge."
+	// DefaultDkimSelector is used for sender domain configs that do not specify a selector.
+	DefaultDkimSelector = "distr"
+)
+
+var ErrVerificationFailed = errors.New("verification failed")
+
+// DnsRecords returns the DNS records a vendor has to publish before a sender domain config can be verified.
+func DnsRecords(config types.OrganizationMailConfig) []api.MailDnsRecord {
+	if config.Type != types.MailConfigTypeDomain || config.SenderDomain == nil || config.VerificationToken == nil {
+		return nil
+	}
+	records := []api.MailDnsRecord{{
+		Type:        "TXT",
+		Name:        verificationRecordPrefix + *config.SenderDomain,
+		Value:       verificationRecordValue(*config.VerificationToken),
+		Description: "Proves that you control this domain.",
+	}}
+	if signer, err := dkimSigner(config); err == nil && signer != nil {
+		if value, err := dkim.PublicKeyRecord(signer.Key); err == nil {
+			records = append(records, api.MailDnsRecord{
+				Type:        "TXT",
+				Name:        dkimRecordName(signer.Selector, signer.Domain),
+				Value:       value,
+				Description: "Allows recipients to verify the DKIM signature of mails sent on behalf of your domain.",
+			})
+		}
+	}
+	return records
+}
+
+// dkimSigner returns the signer for mails sent with a sender domain config. It returns nil if the config has no DKIM
+// key, which is the case for configs that have been created before mails were signed.
+func dkimSigner(config types.OrganizationMailConfig) (*dkim.Signer, error) {
+	if config.SenderDomain == nil || config.DkimSelector == nil || config.DkimPrivateKey == nil {
+		return nil, nil
+	} else if key, err := dkim.ParseKey(*config.DkimPrivateKey); err != nil {
+		return nil, err
+	} else {
+		return &dkim.Signer{Domain: *config.SenderDomain, Selector: *config.DkimSelector, Key: key}, nil
+	}
+}
+
+// Verify checks whether the given config is functional.
+// For SMTP configs, a connection to the server is established. For sender domain configs, the DNS records returned
+// by DnsRecords are checked.
+func Verify(ctx context.Context, config types.OrganizationMailConfig) error {
+	switch config.Type {
+	case types.MailConfigTypeSMTP:
+		if transport, err := newSMTPTransport(&config); err != nil {
+			return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+		} else if err := transport.Verify(ctx); err != nil {
+			return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+		}
+		return nil
+	case types.MailConfigTypeDomain:
+		return verifyDomain(ctx, config)
+	default:
+		return fmt.Errorf("unknown mail config type: %v", config.Type)
+	}
+}
+
+func verifyDomain(ctx context.Context, config types.OrganizationMailConfig) error {
+	if config.SenderDomain == nil || config.VerificationToken == nil {
+		return fmt.Errorf("%w: sender domain not configured", ErrVerificationFailed)
+	}
+	resolver := net.DefaultResolver
+	name := verificationRecordPrefix + *config.SenderDomain
+	if values, err := resolver.LookupTXT(ctx, name); err != nil {
+		return fmt.Errorf("%w: could not look up TXT record %v: %w", ErrVerificationFailed, name, err)
+	} else if !slices.Contains(values, verificationRecordValue(*config.VerificationToken)) {
+		return fmt.Errorf("%w: TXT record %v does not contain the verification token", ErrVerificationFailed, name)
+	}
+	if signer, err := dkimSigner(config); err != nil {
+		return err
+	} else if signer != nil {
+		name := dkimRecordName(signer.Selector, signer.Domain)
+		if expected, err := dkim.PublicKeyRecord(signer.Key); err != nil {
+			return err
+		} else if values, err := resolver.LookupTXT(ctx, name); err != nil {
+			return fmt.Errorf("%w: could not look up DKIM record %v: %w", ErrVerificationFailed, name, err)
+		} else if !slices.ContainsFunc(values, func(v string) bool { return hasPublicKey(v, expected) }) {
+			return fmt.Errorf("%w: DKIM record %v does not contain the public key", ErrVerificationFailed, name)
+		}
+	}
+	return nil
+}
+
+// hasPublicKey returns true if the DKIM record value contains the public key of the expected record. Whitespace is
+// ignored, because DNS providers split long values into several strings.
+func hasPublicKey(value, expected string) bool {
+	_, key, _ := strings.Cut(expected, "p=")
+	return strings.Contains(strings.Join(strings.Fields(value), ""), "p="+key)
+}
+
+func verificationRecordValue(token string) string {
+	return "distr-verification=" + token
+}
+
+func dkimRecordName(selector, domain string) string {
+	return selector + "._domainkey." + domain
+}
+
+func newSMTPTransport(config *types.OrganizationMailConfig) (interface {
+	mail.Mailer
+	Verify(ctx context.Context) error
+}, error) {
+	if config.SmtpHost == nil {
+		return nil, errors.New("SMTP host not configured")
+	}
+	smtpConfig := smtp.Config{
+		MailerConfig: mail.MailerConfig{
+			FromAddressSrc: []mail.FromAddressSrcFn{mail.MailOverrideFromAddress()},
+		},
+		Host:      *config.SmtpHost,
+		TLSPolicy: gomail.TLSMandatory,
+	}
+	if config.SmtpPort != nil {
+		smtpConfig.Port = *config.SmtpPort
+	}
+	if config.SmtpUsername != nil {
+		smtpConfig.Username = *config.SmtpUsername
+	}
+	if config.SmtpPassword != nil {
+		smtpConfig.Password = *config.SmtpPassword
+	}
+	return smtp.New(smtpConfig)
+}
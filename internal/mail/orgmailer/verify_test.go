@@ -0,0 +1,38 @@
+package orgmailer_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/mail/dkim"
+	"github.com/glasskube/distr/internal/mail/orgmailer"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestDnsRecords(t *testing.T) {
+	g := NewWithT(t)
+	key, err := dkim.NewKey()
+	g.Expect(err).NotTo(HaveOccurred())
+	config := types.OrganizationMailConfig{
+		Type:              types.MailConfigTypeDomain,
+		FromAddress:       "mail@example.com",
+		SenderDomain:      util.PtrTo("example.com"),
+		DkimSelector:      util.PtrTo(orgmailer.DefaultDkimSelector),
+		DkimPrivateKey:    &key,
+		VerificationToken: util.PtrTo("token"),
+	}
+	parsed, err := dkim.ParseKey(key)
+	g.Expect(err).NotTo(HaveOccurred())
+	publicKey, err := dkim.PublicKeyRecord(parsed)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(orgmailer.DnsRecords(config)).To(ConsistOf(
+		HaveField("Value", "distr-verification=token"),
+		And(HaveField("Name", "distr._domainkey.example.com"), HaveField("Value", publicKey)),
+	))
+
+	// configs that have been created before mails were signed have no DKIM record
+	config.DkimPrivateKey = nil
+	g.Expect(orgmailer.DnsRecords(config)).To(HaveLen(1))
+}
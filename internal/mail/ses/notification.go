@@ -0,0 +1,121 @@
+package ses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+)
+
+const (
+	SNSMessageTypeNotification             = "Notification"
+	SNSMessageTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	SNSMessageTypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// SNSMessage is a message that SNS posts to HTTPS subscriptions.
+type SNSMessage struct {
+	Type         string `json:"Type"`
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// Notification is a SES notification about a sent mail, published either as notification or as event.
+type Notification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Mail             struct {
+		MessageID string `json:"messageId"`
+	} `json:"mail"`
+	Bounce *struct {
+		BounceType        string `json:"bounceType"`
+		BounceSubType     string `json:"bounceSubType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint *struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+	} `json:"complaint"`
+}
+
+// SentMailStatus returns the status of the sent mail that the notification reports and a description.
+// It returns false if the notification does not report a status that is tracked.
+func (n *Notification) SentMailStatus() (types.SentMailStatus, *string, bool) {
+	notificationType := n.NotificationType
+	if notificationType == "" {
+		notificationType = n.EventType
+	}
+	switch notificationType {
+	case "Delivery":
+		return types.SentMailStatusDelivered, nil, true
+	case "Bounce":
+		if n.Bounce == nil {
+			return types.SentMailStatusBounced, nil, true
+		}
+		details := []string{strings.TrimSpace(n.Bounce.BounceType + " " + n.Bounce.BounceSubType)}
+		for _, recipient := range n.Bounce.BouncedRecipients {
+			if recipient.DiagnosticCode != "" {
+				details = append(details, recipient.EmailAddress+": "+recipient.DiagnosticCode)
+			}
+		}
+		return types.SentMailStatusBounced, util.PtrTo(strings.Join(details, "; ")), true
+	case "Complaint":
+		if n.Complaint == nil || n.Complaint.ComplaintFeedbackType == "" {
+			return types.SentMailStatusComplained, nil, true
+		}
+		return types.SentMailStatusComplained, util.PtrTo(n.Complaint.ComplaintFeedbackType), true
+	default:
+		return "", nil, false
+	}
+}
+
+// ParseNotification returns the SES notification that is contained in msg.
+func ParseNotification(msg SNSMessage) (*Notification, error) {
+	if msg.Type != SNSMessageTypeNotification {
+		return nil, fmt.Errorf("unexpected SNS message type: %v", msg.Type)
+	}
+	var notification Notification
+	if err := json.Unmarshal([]byte(msg.Message), &notification); err != nil {
+		return nil, fmt.Errorf("invalid SES notification: %w", err)
+	} else if notification.Mail.MessageID == "" {
+		return nil, errors.New("invalid SES notification: messageId is empty")
+	}
+	return &notification, nil
+}
+
+// ConfirmSubscription visits the subscribe URL of msg. Only URLs of SNS endpoints are visited, so that the webhook
+// can not be used to make the server send requests to arbitrary hosts.
+func ConfirmSubscription(ctx context.Context, client *http.Client, msg SNSMessage) error {
+	subscribeURL, err := url.Parse(msg.SubscribeURL)
+	if err != nil {
+		return fmt.Errorf("invalid SubscribeURL: %w", err)
+	} else if subscribeURL.Scheme != "https" || !snsHostPattern.MatchString(subscribeURL.Hostname()) {
+		return fmt.Errorf("SubscribeURL is not an SNS endpoint: %v", subscribeURL.Host)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+	return nil
+}
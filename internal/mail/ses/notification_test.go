@@ -0,0 +1,79 @@
+package ses_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/glasskube/distr/internal/mail/ses"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestParseNotificationBounce(t *testing.T) {
+	g := NewWithT(t)
+	notification, err := ses.ParseNotification(ses.SNSMessage{
+		Type: ses.SNSMessageTypeNotification,
+		Message: `{"notificationType":"Bounce","mail":{"messageId":"0100-abc"},"bounce":{"bounceType":"Permanent",
+			"bounceSubType":"General","bouncedRecipients":[{"emailAddress":"jane@example.com",
+			"diagnosticCode":"smtp; 550 5.1.1 user unknown"}]}}`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(notification.Mail.MessageID).To(Equal("0100-abc"))
+	status, details, ok := notification.SentMailStatus()
+	g.Expect(ok).To(BeTrue())
+	g.Expect(status).To(Equal(types.SentMailStatusBounced))
+	g.Expect(details).To(HaveValue(Equal("Permanent General; jane@example.com: smtp; 550 5.1.1 user unknown")))
+}
+
+func TestParseNotificationEvents(t *testing.T) {
+	g := NewWithT(t)
+	for message, expected := range map[string]types.SentMailStatus{
+		`{"notificationType":"Delivery","mail":{"messageId":"a"}}`:                    types.SentMailStatusDelivered,
+		`{"eventType":"Delivery","mail":{"messageId":"a"}}`:                           types.SentMailStatusDelivered,
+		`{"notificationType":"Complaint","mail":{"messageId":"a"},"complaint":{}}`:    types.SentMailStatusComplained,
+		`{"eventType":"Bounce","mail":{"messageId":"a"},"bounce":{"bounceType":"T"}}`: types.SentMailStatusBounced,
+	} {
+		notification, err := ses.ParseNotification(ses.SNSMessage{Type: ses.SNSMessageTypeNotification, Message: message})
+		g.Expect(err).NotTo(HaveOccurred())
+		status, _, ok := notification.SentMailStatus()
+		g.Expect(ok).To(BeTrue())
+		g.Expect(status).To(Equal(expected), message)
+	}
+
+	notification, err := ses.ParseNotification(ses.SNSMessage{
+		Type:    ses.SNSMessageTypeNotification,
+		Message: `{"eventType":"Open","mail":{"messageId":"a"}}`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, ok := notification.SentMailStatus()
+	g.Expect(ok).To(BeFalse(), "untracked event types are ignored")
+}
+
+func TestParseNotificationInvalid(t *testing.T) {
+	g := NewWithT(t)
+	_, err := ses.ParseNotification(ses.SNSMessage{Type: ses.SNSMessageTypeSubscriptionConfirmation})
+	g.Expect(err).To(HaveOccurred())
+	_, err = ses.ParseNotification(ses.SNSMessage{Type: ses.SNSMessageTypeNotification, Message: "{"})
+	g.Expect(err).To(HaveOccurred())
+	_, err = ses.ParseNotification(ses.SNSMessage{
+		Type:    ses.SNSMessageTypeNotification,
+		Message: `{"notificationType":"Bounce"}`,
+	})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestConfirmSubscriptionRejectsOtherHosts(t *testing.T) {
+	g := NewWithT(t)
+	for _, subscribeURL := range []string{
+		"https://example.com/confirm",
+		"http://sns.eu-central-1.amazonaws.com/?Action=ConfirmSubscription",
+		"https://sns.eu-central-1.amazonaws.com.example.com/",
+	} {
+		err := ses.ConfirmSubscription(context.Background(), http.DefaultClient, ses.SNSMessage{
+			Type:         ses.SNSMessageTypeSubscriptionConfirmation,
+			SubscribeURL: subscribeURL,
+		})
+		g.Expect(err).To(MatchError(ContainSubstring("not an SNS endpoint")), subscribeURL)
+	}
+}
@@ -1,6 +1,7 @@
 package ses
 
 import (
+	"bytes"
 	"context"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -8,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ses"
 	"github.com/aws/aws-sdk-go-v2/service/ses/types"
 	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mail/smtp"
 	"github.com/glasskube/distr/internal/util"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
@@ -42,38 +44,68 @@ func NewFromContext(ctx context.Context, config Config) (*sesMailer, error) {
 }
 
 // Send implements Mailer.
-func (s *sesMailer) Send(ctx context.Context, mail mail.Mail) error {
+func (s *sesMailer) Send(ctx context.Context, msg mail.Mail) error {
+	if msg.DKIM != nil {
+		return s.sendRaw(ctx, msg)
+	}
 	message := ses.SendEmailInput{
 		Destination: &types.Destination{
-			ToAddresses:  mail.To,
-			BccAddresses: mail.Bcc,
+			ToAddresses:  msg.To,
+			BccAddresses: msg.Bcc,
 		},
 		Message: &types.Message{
-			Subject: &types.Content{Data: &mail.Subject},
+			Subject: &types.Content{Data: &msg.Subject},
 			Body:    &types.Body{},
 		},
 	}
-	message.Source = util.PtrTo(s.config.GetActualFromAddress(ctx, mail))
-	if mail.ReplyTo != "" {
-		message.ReplyToAddresses = []string{mail.ReplyTo}
+	message.Source = util.PtrTo(s.config.GetActualFromAddress(ctx, msg))
+	if msg.ReplyTo != "" {
+		message.ReplyToAddresses = []string{msg.ReplyTo}
 	}
-	if mail.TextBodyFunc != nil {
-		if body, err := mail.TextBodyFunc(); err != nil {
+	if msg.TextBodyFunc != nil {
+		if body, err := msg.TextBodyFunc(); err != nil {
 			return err
 		} else {
 			message.Message.Body.Text = &types.Content{Data: &body}
 		}
 	}
-	if mail.HtmlBodyFunc != nil {
-		if body, err := mail.HtmlBodyFunc(); err != nil {
+	if msg.HtmlBodyFunc != nil {
+		if body, err := msg.HtmlBodyFunc(); err != nil {
 			return err
 		} else {
 			message.Message.Body.Html = &types.Content{Data: &body}
 		}
 	}
-	if _, err := s.client.SendEmail(ctx, &message); err != nil {
+	if output, err := s.client.SendEmail(ctx, &message); err != nil {
 		return err
 	} else {
+		if output.MessageId != nil {
+			mail.RecordMessageID(ctx, *output.MessageId)
+		}
+		return nil
+	}
+}
+
+// sendRaw sends msg as a raw MIME message, which is required to include the DKIM signature of the sender domain.
+func (s *sesMailer) sendRaw(ctx context.Context, msg mail.Mail) error {
+	message, err := smtp.NewMsg(ctx, s.config, msg)
+	if err != nil {
+		return err
+	}
+	var data bytes.Buffer
+	if _, err := message.WriteTo(&data); err != nil {
+		return err
+	}
+	if output, err := s.client.SendRawEmail(ctx, &ses.SendRawEmailInput{
+		Source:       util.PtrTo(s.config.GetActualFromAddress(ctx, msg)),
+		Destinations: append(append([]string{}, msg.To...), msg.Bcc...),
+		RawMessage:   &types.RawMessage{Data: data.Bytes()},
+	}); err != nil {
+		return err
+	} else {
+		if output.MessageId != nil {
+			mail.RecordMessageID(ctx, *output.MessageId)
+		}
 		return nil
 	}
 }
@@ -1,7 +1,9 @@
 package smtp
 
 import (
+	"bytes"
 	"context"
+	"time"
 
 	"github.com/glasskube/distr/internal/mail"
 	gomail "github.com/wneessen/go-mail"
@@ -46,36 +48,74 @@ func New(config Config) (*smtpMailer, error) {
 }
 
 // Send implements mail.Mailer.
-func (s *smtpMailer) Send(ctx context.Context, mail mail.Mail) error {
-	message := gomail.NewMsg()
-	message.Subject(mail.Subject)
-	if err := message.To(mail.To...); err != nil {
+func (s *smtpMailer) Send(ctx context.Context, msg mail.Mail) error {
+	message, err := NewMsg(ctx, s.config, msg)
+	if err != nil {
 		return err
 	}
-	if err := message.Bcc(mail.Bcc...); err != nil {
+	if err := s.client.DialAndSendWithContext(ctx, message); err != nil {
 		return err
 	}
-	if err := message.From(s.config.GetActualFromAddress(ctx, mail)); err != nil {
-		return err
+	mail.RecordMessageID(ctx, message.GetMessageID())
+	return nil
+}
+
+// NewMsg creates the MIME message for msg, including the DKIM signature if msg has a signer. It is also used by
+// transports that send raw messages.
+func NewMsg(ctx context.Context, config mail.MailerConfig, msg mail.Mail) (*gomail.Msg, error) {
+	message := gomail.NewMsg()
+	message.Subject(msg.Subject)
+	if err := message.To(msg.To...); err != nil {
+		return nil, err
+	}
+	if err := message.Bcc(msg.Bcc...); err != nil {
+		return nil, err
 	}
-	if mail.ReplyTo != "" {
-		if err := message.ReplyTo(mail.ReplyTo); err != nil {
-			return err
+	if err := message.From(config.GetActualFromAddress(ctx, msg)); err != nil {
+		return nil, err
+	}
+	if msg.ReplyTo != "" {
+		if err := message.ReplyTo(msg.ReplyTo); err != nil {
+			return nil, err
 		}
 	}
-	if mail.HtmlBodyFunc != nil {
-		if body, err := mail.HtmlBodyFunc(); err != nil {
-			return err
+	if msg.HtmlBodyFunc != nil {
+		if body, err := msg.HtmlBodyFunc(); err != nil {
+			return nil, err
 		} else {
 			message.SetBodyString(gomail.TypeTextHTML, body)
 		}
 	}
-	if mail.TextBodyFunc != nil {
-		if body, err := mail.TextBodyFunc(); err != nil {
-			return err
+	if msg.TextBodyFunc != nil {
+		if body, err := msg.TextBodyFunc(); err != nil {
+			return nil, err
 		} else {
 			message.SetBodyString(gomail.TypeTextPlain, body)
 		}
 	}
-	return s.client.DialAndSendWithContext(ctx, message)
+	message.SetMessageID()
+	if msg.DKIM != nil {
+		// the message is rendered again when it is sent, which must produce the same output: the date and the
+		// multipart boundaries are fixed by the first rendering
+		now := time.Now()
+		message.SetDateWithValue(now)
+		var b bytes.Buffer
+		if _, err := message.WriteTo(&b); err != nil {
+			return nil, err
+		} else if signature, err := msg.DKIM.Sign(b.Bytes(), now); err != nil {
+			return nil, err
+		} else {
+			message.SetGenHeaderPreformatted("DKIM-Signature", signature)
+		}
+	}
+	return message, nil
+}
+
+// Verify checks that a connection to the SMTP server can be established and,
+// if credentials are configured, that they are accepted.
+func (s *smtpMailer) Verify(ctx context.Context) error {
+	if err := s.client.DialWithContext(ctx); err != nil {
+		return err
+	}
+	return s.client.Close()
 }
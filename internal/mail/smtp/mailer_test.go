@@ -0,0 +1,52 @@
+package smtp_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mail/dkim"
+	"github.com/glasskube/distr/internal/mail/smtp"
+	. "github.com/onsi/gomega"
+)
+
+func TestNewMsgWithDKIM(t *testing.T) {
+	g := NewWithT(t)
+	data, err := dkim.NewKey()
+	g.Expect(err).NotTo(HaveOccurred())
+	key, err := dkim.ParseKey(data)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config := mail.MailerConfig{FromAddressSrc: []mail.FromAddressSrcFn{mail.StaticFromAddress("mail@example.com")}}
+	msg := mail.New(
+		mail.To("user@example.org"),
+		mail.Subject("Welcome"),
+		mail.HtmlBody("<p>Hello</p>"),
+		mail.TextBody("Hello"),
+	)
+	msg.DKIM = &dkim.Signer{Domain: "example.com", Selector: "distr", Key: key}
+	message, err := smtp.NewMsg(context.Background(), config, msg)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the message is rendered again when it is sent, so the signature must stay valid
+	var first, second bytes.Buffer
+	_, err = message.WriteTo(&first)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = message.WriteTo(&second)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(second.String()).To(Equal(first.String()))
+	g.Expect(first.String()).To(ContainSubstring("DKIM-Signature: v=1; a=rsa-sha256;"))
+	g.Expect(dkim.Verify(first.Bytes(), &key.PublicKey)).To(Succeed())
+}
+
+func TestNewMsgWithoutDKIM(t *testing.T) {
+	g := NewWithT(t)
+	config := mail.MailerConfig{FromAddressSrc: []mail.FromAddressSrcFn{mail.StaticFromAddress("mail@example.com")}}
+	message, err := smtp.NewMsg(context.Background(), config, mail.New(mail.To("user@example.org")))
+	g.Expect(err).NotTo(HaveOccurred())
+	var b bytes.Buffer
+	_, err = message.WriteTo(&b)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(b.String()).NotTo(ContainSubstring("DKIM-Signature"))
+}
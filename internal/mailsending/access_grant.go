@@ -0,0 +1,34 @@
+package mailsending
+
+import (
+	"context"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// SendAccessGrantCreatedMail informs customer that grantedTo has been given access to target or, if target is nil,
+// to all deployment targets of customer.
+func SendAccessGrantCreatedMail(
+	ctx context.Context,
+	grant types.AccessGrant,
+	grantedTo types.UserAccount,
+	customer types.UserAccount,
+	target *types.DeploymentTarget,
+) error {
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, grant.OrganizationID)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(ctx, mail.New(
+		mail.To(customer.Email),
+		mail.Subject("Temporary access has been granted to "+org.Name),
+		mail.Type(types.MailTypeAccessGrantCreated),
+		mail.HtmlBodyTemplate(mailtemplates.AccessGrantCreated(*org, grant, grantedTo, target)),
+		mail.Organization(grant.OrganizationID),
+	))
+}
@@ -0,0 +1,43 @@
+package mailsending
+
+import (
+	"context"
+	"errors"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+)
+
+// SendArtifactDeletionRequestedMail informs all vendor users of the organization of artifact that its deletion has
+// been requested by requestedBy. licenses are the artifact licenses that grant access to the artifact.
+func SendArtifactDeletionRequestedMail(
+	ctx context.Context,
+	artifact types.Artifact,
+	requestedBy types.UserAccount,
+	licenses []types.ArtifactLicenseBase,
+) error {
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, artifact.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, artifact.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, user := range users {
+		errs = append(errs, mailer.Send(ctx, mail.New(
+			mail.To(user.Email),
+			mail.Subject("Artifact "+artifact.Name+" is scheduled for deletion"),
+			mail.Type(types.MailTypeArtifactDeletionRequested),
+			mail.HtmlBodyTemplate(mailtemplates.ArtifactDeletionRequested(*org, artifact, requestedBy, licenses)),
+			mail.Organization(artifact.OrganizationID),
+		)))
+	}
+	return errors.Join(errs...)
+}
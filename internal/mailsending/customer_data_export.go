@@ -0,0 +1,38 @@
+package mailsending
+
+import (
+	"context"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// SendCustomerDataExportReadyMail sends the download link of a completed data export to the user who requested it or,
+// if that user no longer exists, to the customer. key is the key that the archive is encrypted with.
+func SendCustomerDataExportReadyMail(ctx context.Context, export types.CustomerDataExport, key string) error {
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, export.OrganizationID)
+	if err != nil {
+		return err
+	}
+	customer, err := db.GetUserAccountByID(ctx, export.CustomerUserAccountID)
+	if err != nil {
+		return err
+	}
+	recipient := customer
+	if export.RequestedByUserAccountID != nil {
+		if recipient, err = db.GetUserAccountByID(ctx, *export.RequestedByUserAccountID); err != nil {
+			return err
+		}
+	}
+	return mailer.Send(ctx, mail.New(
+		mail.To(recipient.Email),
+		mail.Subject("Your data export is ready"),
+		mail.Type(types.MailTypeCustomerDataExportReady),
+		mail.HtmlBodyTemplate(mailtemplates.CustomerDataExportReady(*org, export, *customer, key)),
+		mail.Organization(export.OrganizationID),
+	))
+}
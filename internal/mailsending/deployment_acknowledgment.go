@@ -0,0 +1,40 @@
+package mailsending
+
+import (
+	"context"
+	"errors"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// SendDeploymentAcknowledgmentRequiredMail asks the customer who owns the deployment target to acknowledge an update.
+// If reminder is true, the customer has already been asked before.
+func SendDeploymentAcknowledgmentRequiredMail(
+	ctx context.Context,
+	acknowledgment types.PendingDeploymentAcknowledgment,
+	reminder bool,
+) error {
+	if acknowledgment.CustomerEmail == nil {
+		return errors.New("deployment target has no customer")
+	}
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, acknowledgment.OrganizationID)
+	if err != nil {
+		return err
+	}
+	subject := "Update of " + acknowledgment.ApplicationName + " requires your acknowledgment"
+	if reminder {
+		subject = "Reminder: " + subject
+	}
+	return mailer.Send(ctx, mail.New(
+		mail.To(*acknowledgment.CustomerEmail),
+		mail.Subject(subject),
+		mail.Type(types.MailTypeDeploymentAcknowledgmentRequired),
+		mail.HtmlBodyTemplate(mailtemplates.DeploymentAcknowledgmentRequired(*org, acknowledgment, reminder)),
+		mail.Organization(acknowledgment.OrganizationID),
+	))
+}
@@ -0,0 +1,45 @@
+package mailsending
+
+import (
+	"context"
+	"errors"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+)
+
+// SendDeploymentAutoRollbackMail informs all vendor users of the organization and the customer who owns the
+// deployment target that a deployment has been rolled back automatically.
+func SendDeploymentAutoRollbackMail(ctx context.Context, rollback types.DeploymentAutoRollback) error {
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, rollback.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, rollback.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, 0, len(users)+1)
+	for _, user := range users {
+		recipients = append(recipients, user.Email)
+	}
+	if rollback.CustomerEmail != nil {
+		recipients = append(recipients, *rollback.CustomerEmail)
+	}
+	var errs []error
+	for _, recipient := range recipients {
+		errs = append(errs, mailer.Send(ctx, mail.New(
+			mail.To(recipient),
+			mail.Subject(rollback.ApplicationName+" on "+rollback.DeploymentTargetName+" has been rolled back"),
+			mail.Type(types.MailTypeDeploymentAutoRollback),
+			mail.HtmlBodyTemplate(mailtemplates.DeploymentAutoRollback(*org, rollback)),
+			mail.Organization(rollback.OrganizationID),
+		)))
+	}
+	return errors.Join(errs...)
+}
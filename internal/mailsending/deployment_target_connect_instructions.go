@@ -0,0 +1,41 @@
+package mailsending
+
+import (
+	"context"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// SendDeploymentTargetConnectInstructionsMail sends the connect command of a pre-registered deployment target to its
+// customer contact.
+func SendDeploymentTargetConnectInstructionsMail(
+	ctx context.Context,
+	target types.DeploymentTarget,
+	preRegistration types.DeploymentTargetPreRegistration,
+	connectCommand string,
+	prerequisites []string,
+	reminder bool,
+) error {
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, target.OrganizationID)
+	if err != nil {
+		return err
+	}
+	subject := "Connect your deployment target " + target.Name
+	if reminder {
+		subject = "Reminder: " + subject
+	}
+	return mailer.Send(ctx, mail.New(
+		mail.To(preRegistration.ContactEmail),
+		mail.Subject(subject),
+		mail.Type(types.MailTypeDeploymentTargetConnectInstructions),
+		mail.HtmlBodyTemplate(mailtemplates.DeploymentTargetConnectInstructions(
+			*org, target, preRegistration, connectCommand, prerequisites, reminder,
+		)),
+		mail.Organization(target.OrganizationID),
+	))
+}
@@ -0,0 +1,68 @@
+package mailsending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+)
+
+// SendDeploymentTargetOutageMail informs all vendor users of the organization and, if the outage is limited to the
+// deployment targets of one customer, that customer that a mass outage is suspected or, if resolved is true, that it
+// has been resolved.
+func SendDeploymentTargetOutageMail(ctx context.Context, outage types.DeploymentTargetOutage, resolved bool) error {
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, outage.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, outage.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	var customer *types.UserAccount
+	if outage.CustomerUserAccountID != nil {
+		if customer, err = db.GetUserAccountByID(ctx, *outage.CustomerUserAccountID); err != nil {
+			return err
+		}
+	}
+	recipients := make([]string, 0, len(users)+1)
+	for _, user := range users {
+		recipients = append(recipients, user.Email)
+	}
+	if customer != nil {
+		recipients = append(recipients, customer.Email)
+	}
+
+	var subject string
+	var mailType types.MailType
+	var body mail.MailOpt
+	if resolved {
+		subject = "Deployment targets are reporting again"
+		mailType = types.MailTypeDeploymentTargetOutageResolved
+		body = mail.HtmlBodyTemplate(mailtemplates.DeploymentTargetOutageResolved(*org, outage, customer))
+	} else {
+		subject = fmt.Sprintf("Mass outage suspected: %v of %v deployment targets stopped reporting",
+			len(outage.AffectedDeploymentTargetIDs), outage.TargetCount)
+		mailType = types.MailTypeDeploymentTargetOutageSuspected
+		body = mail.HtmlBodyTemplate(mailtemplates.DeploymentTargetOutageSuspected(*org, outage, customer))
+	}
+
+	var errs []error
+	for _, recipient := range recipients {
+		errs = append(errs, mailer.Send(ctx, mail.New(
+			mail.To(recipient),
+			mail.Subject(subject),
+			mail.Type(mailType),
+			body,
+			mail.Organization(outage.OrganizationID),
+		)))
+	}
+	return errors.Join(errs...)
+}
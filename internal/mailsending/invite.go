@@ -3,8 +3,10 @@ package mailsending
 import (
 	"context"
 	"fmt"
+	"net/url"
 
 	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/authjwt"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/customdomains"
 	"github.com/glasskube/distr/internal/db"
@@ -14,6 +16,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// InviteURL returns a URL with a new token that userAccount can use to join organization.
+func InviteURL(userAccount types.UserAccount, organization types.Organization) (string, error) {
+	// TODO: Should probably use a different mechanism for invite tokens but for now this should work OK
+	if _, token, err := authjwt.GenerateVerificationTokenValidFor(userAccount); err != nil {
+		return "", err
+	} else {
+		return fmt.Sprintf(
+			"%v/join?jwt=%v",
+			customdomains.AppDomainOrDefault(organization),
+			url.QueryEscape(token),
+		), nil
+	}
+}
+
 func SendUserInviteMail(
 	ctx context.Context,
 	userAccount types.UserAccount,
@@ -40,9 +56,11 @@ func SendUserInviteMail(
 			email = mail.New(
 				mail.To(userAccount.Email),
 				mail.From(*from),
+				mail.Organization(organization.ID),
 				mail.Bcc(currentUser.Email),
 				mail.ReplyTo(currentUser.Email),
 				mail.Subject("Welcome to Distr"),
+				mail.Type(types.MailTypeInviteCustomer),
 				mail.HtmlBodyTemplate(mailtemplates.InviteCustomer(userAccount, organization, inviteURL)),
 			)
 		}
@@ -50,7 +68,9 @@ func SendUserInviteMail(
 		email = mail.New(
 			mail.To(userAccount.Email),
 			mail.From(*from),
+			mail.Organization(organization.ID),
 			mail.Subject("Welcome to Distr"),
+			mail.Type(types.MailTypeInviteUser),
 			mail.HtmlBodyTemplate(mailtemplates.InviteUser(userAccount, organization, inviteURL)),
 		)
 	default:
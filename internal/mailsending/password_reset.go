@@ -0,0 +1,53 @@
+package mailsending
+
+import (
+	"context"
+
+	"github.com/glasskube/distr/internal/authjwt"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/customdomains"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// SendPasswordResetMail sends a mail with a new password reset token to userAccount.
+// If org is not nil, the mail is sent in the scope and with the custom domain of org.
+func SendPasswordResetMail(ctx context.Context, userAccount types.UserAccount, org *types.Organization) error {
+	mailer := internalctx.GetMailer(ctx)
+	log := internalctx.GetLogger(ctx)
+
+	_, token, err := authjwt.GenerateResetToken(userAccount)
+	if err != nil {
+		log.Error("could not generate reset token for password reset", zap.Error(err))
+		return err
+	}
+	mailOpts := []mail.MailOpt{
+		mail.To(userAccount.Email),
+		mail.Subject("Password reset"),
+		mail.Type(types.MailTypePasswordReset),
+	}
+	if org != nil {
+		mailOpts = append(mailOpts, mail.Organization(org.ID))
+		if from, err := customdomains.EmailFromAddressParsedOrDefault(*org); err == nil {
+			mailOpts = append(mailOpts, mail.From(*from))
+		} else {
+			log.Warn("error parsing custom from address", zap.Error(err))
+		}
+	}
+	passkeys, err := db.CountWebAuthnCredentials(ctx, userAccount.ID)
+	if err != nil {
+		log.Error("could not count passkeys for password reset", zap.Error(err))
+		return err
+	}
+	mailOpts = append(mailOpts,
+		mail.HtmlBodyTemplate(mailtemplates.PasswordReset(userAccount, org, token, passkeys > 0)))
+	if err := mailer.Send(ctx, mail.New(mailOpts...)); err != nil {
+		log.Error("could not send reset mail", zap.Error(err), zap.String("user", userAccount.Email))
+		return err
+	}
+	log.Info("reset mail has been sent", zap.String("user", userAccount.Email))
+	return nil
+}
@@ -22,7 +22,9 @@ func SendUserVerificationMail(ctx context.Context, userAccount types.UserAccount
 	} else {
 		mail := mail.New(
 			mail.To(userAccount.Email),
+			mail.Organization(org.ID),
 			mail.Subject("Verify your Distr account"),
+			mail.Type(types.MailTypeVerifyEmail),
 			mail.HtmlBodyTemplate(mailtemplates.VerifyEmail(userAccount, org, token)),
 		)
 		if err := mailer.Send(ctx, mail); err != nil {
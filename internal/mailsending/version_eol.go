@@ -0,0 +1,37 @@
+package mailsending
+
+import (
+	"context"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// SendVersionEOLMail informs the customer about the end of life of the versions that run on the deployments in
+// entries.
+func SendVersionEOLMail(
+	ctx context.Context,
+	campaign types.VersionEOLCampaign,
+	customer types.UserAccount,
+	entries []types.VersionEOLReportEntry,
+) error {
+	mailer := internalctx.GetMailer(ctx)
+	org, err := db.GetOrganizationWithBranding(ctx, campaign.OrganizationID)
+	if err != nil {
+		return err
+	}
+	application, err := db.GetApplication(ctx, campaign.ApplicationID, campaign.OrganizationID)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(ctx, mail.New(
+		mail.To(customer.Email),
+		mail.Subject("End of life of "+application.Name+" versions"),
+		mail.Type(types.MailTypeVersionEOL),
+		mail.HtmlBodyTemplate(mailtemplates.VersionEOL(*org, application.Name, campaign, customer, entries)),
+		mail.Organization(campaign.OrganizationID),
+	))
+}
@@ -0,0 +1,211 @@
+package mailtemplates
+
+import (
+	"errors"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/glasskube/distr/internal/agentmanifest"
+	"github.com/glasskube/distr/internal/customdomains"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+)
+
+// MaskedSecret replaces tokens and other secrets in previews.
+const MaskedSecret = "********"
+
+var ErrPreviewNotSupported = errors.New("mail type can not be previewed")
+
+// SampleUserAccount is used in previews if no real recipient is given.
+var SampleUserAccount = types.UserAccount{Name: "Jane Doe", Email: "jane.doe@example.com"}
+
+// Preview returns the template and data of a mail of type t as userAccount would receive it from organization.
+// Everything except userAccount and organization is sample data and tokens are replaced with MaskedSecret.
+func Preview(
+	t types.MailType,
+	userAccount types.UserAccount,
+	organization types.OrganizationWithBranding,
+) (*template.Template, any, error) {
+	now := time.Now()
+	inviteURL := customdomains.AppDomainOrDefault(organization.Organization) + "/join?jwt=" + MaskedSecret
+	switch t {
+	case types.MailTypeInviteUser:
+		tmpl, data := InviteUser(userAccount, organization, inviteURL)
+		return tmpl, data, nil
+	case types.MailTypeInviteCustomer:
+		tmpl, data := InviteCustomer(userAccount, organization, inviteURL)
+		return tmpl, data, nil
+	case types.MailTypeVerifyEmail:
+		tmpl, data := VerifyEmail(userAccount, organization.Organization, MaskedSecret)
+		return tmpl, data, nil
+	case types.MailTypePasswordReset:
+		tmpl, data := PasswordReset(userAccount, &organization.Organization, MaskedSecret, false)
+		return tmpl, data, nil
+	case types.MailTypeSecurityEvent:
+		tmpl, data := SecurityEvent(userAccount, types.SecurityEvent{
+			CreatedAt: now,
+			Type:      types.SecurityEventTypeNewDeviceLogin,
+			IPAddress: util.PtrTo("192.0.2.1"),
+			UserAgent: util.PtrTo("Mozilla/5.0 (X11; Linux x86_64)"),
+			Country:   util.PtrTo("AT"),
+		})
+		return tmpl, data, nil
+	case types.MailTypeUpstreamWatchChanged:
+		tmpl, data := UpstreamWatchChanged(
+			organization,
+			types.UpstreamWatch{Reference: "registry.example.com/example/app:1.0"},
+			types.UpstreamWatchChange{
+				CreatedAt:      now,
+				PreviousDigest: "sha256:" + strings.Repeat("a", 64),
+				Digest:         "sha256:" + strings.Repeat("b", 64),
+			},
+		)
+		return tmpl, data, nil
+	case types.MailTypeCertificateExpiring:
+		tmpl, data := CertificateExpiring(
+			organization,
+			"Example Target",
+			types.DeploymentTargetEndpoint{URL: "https://app.example.com"},
+			types.DeploymentTargetEndpointCertificate{
+				Subject:  "CN=app.example.com",
+				Issuer:   "CN=Example CA",
+				NotAfter: now.AddDate(0, 0, 14),
+			},
+			14,
+		)
+		return tmpl, data, nil
+	case types.MailTypeAppMetricAlertFiring:
+		tmpl, data := AppMetricAlertFiring(organization, "Example Target", "Example App",
+			types.ApplicationMetricAlertRule{
+				Metric:     "queue_length",
+				Operator:   types.AppMetricAlertOperatorGreaterThan,
+				Value:      100,
+				ForSeconds: 300,
+			})
+		return tmpl, data, nil
+	case types.MailTypeOrganizationMailConfigDisabled:
+		tmpl, data := OrganizationMailConfigDisabled(organization, types.OrganizationMailConfig{
+			Type:               types.MailConfigTypeSMTP,
+			FromAddress:        "noreply@example.com",
+			FailureCount:       5,
+			LastFailureMessage: util.PtrTo("dial tcp: connection refused"),
+		})
+		return tmpl, data, nil
+	case types.MailTypeOrganizationStorageFailing:
+		tmpl, data := OrganizationStorageFailing(organization, types.OrganizationStorage{
+			Bucket:             "example-artifacts",
+			Region:             "eu-central-1",
+			FailureCount:       5,
+			LastFailureMessage: util.PtrTo("operation error S3: GetObject, StatusCode: 403, InvalidAccessKeyId"),
+		})
+		return tmpl, data, nil
+	case types.MailTypeArtifactDeletionRequested:
+		tmpl, data := ArtifactDeletionRequested(
+			organization,
+			types.Artifact{
+				Name:                "example/app",
+				DeletionRequestedAt: &now,
+				DeletionScheduledAt: util.PtrTo(now.AddDate(0, 0, 7)),
+			},
+			userAccount,
+			[]types.ArtifactLicenseBase{{Name: "Example Customer License"}},
+		)
+		return tmpl, data, nil
+	case types.MailTypeAccessGrantCreated:
+		tmpl, data := AccessGrantCreated(
+			organization,
+			types.AccessGrant{Reason: "Support ticket #1234", ExpiresAt: now.Add(24 * time.Hour)},
+			types.UserAccount{Name: "John Doe", Email: "john.doe@example.com"},
+			&types.DeploymentTarget{Name: "production"},
+		)
+		return tmpl, data, nil
+	case types.MailTypeDeploymentAcknowledgmentRequired:
+		tmpl, data := DeploymentAcknowledgmentRequired(
+			organization,
+			types.PendingDeploymentAcknowledgment{
+				CreatedAt:              now,
+				DeploymentTargetName:   "production",
+				ApplicationName:        "Example App",
+				ApplicationVersionName: "2.0.0",
+				AcknowledgmentMessage:  "This update migrates the database and requires about 10 minutes of downtime.",
+			},
+			false,
+		)
+		return tmpl, data, nil
+	case types.MailTypeDeploymentAutoRollback:
+		tmpl, data := DeploymentAutoRollback(
+			organization,
+			types.DeploymentAutoRollback{
+				DeploymentTargetName:           "production",
+				ApplicationName:                "Example App",
+				FailedApplicationVersionName:   "2.0.0",
+				FailureMessage:                 "UPGRADE FAILED: context deadline exceeded",
+				FailedSince:                    now.Add(-15 * time.Minute),
+				RollbackApplicationVersionName: "1.9.0",
+			},
+		)
+		return tmpl, data, nil
+	case types.MailTypeDeploymentTargetOutageSuspected:
+		tmpl, data := DeploymentTargetOutageSuspected(organization, sampleDeploymentTargetOutage(now), &userAccount)
+		return tmpl, data, nil
+	case types.MailTypeDeploymentTargetOutageResolved:
+		outage := sampleDeploymentTargetOutage(now)
+		outage.ResolvedAt = &now
+		tmpl, data := DeploymentTargetOutageResolved(organization, outage, &userAccount)
+		return tmpl, data, nil
+	case types.MailTypeCustomerDataExportReady:
+		export := types.CustomerDataExport{
+			ID:        uuid.New(),
+			CreatedAt: now.Add(-time.Hour),
+			ExpiresAt: util.PtrTo(now.Add(7 * 24 * time.Hour)),
+		}
+		tmpl, data := CustomerDataExportReady(organization, export, userAccount, MaskedSecret)
+		return tmpl, data, nil
+	case types.MailTypeDeploymentTargetConnectInstructions:
+		target := types.DeploymentTarget{
+			Base: types.Base{ID: uuid.New()},
+			Name: "production",
+			Type: types.DeploymentTypeDocker,
+		}
+		pairingURL, err := agentmanifest.PairingURL(organization.Organization, target.ID, MaskedSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		tmpl, data := DeploymentTargetConnectInstructions(
+			organization,
+			target,
+			types.DeploymentTargetPreRegistration{PairingTokenExpiresAt: now.Add(72 * time.Hour)},
+			agentmanifest.ConnectCommand(target, pairingURL),
+			[]string{"Docker Engine with the Docker Compose plugin", "at least 2 CPU cores", "at least 4 GiB of memory"},
+			false,
+		)
+		return tmpl, data, nil
+	case types.MailTypeVersionEOL:
+		tmpl, data := VersionEOL(
+			organization,
+			"Example App",
+			types.VersionEOLCampaign{
+				EndOfLifeAt: util.PtrTo(now.AddDate(0, 3, 0)),
+				Message:     "Version 1.x is no longer maintained. Please update to version 2.0.0 or later.",
+			},
+			userAccount,
+			[]types.VersionEOLReportEntry{
+				{DeploymentTargetName: "production", ApplicationVersionName: "1.9.0"},
+				{DeploymentTargetName: "staging", ApplicationVersionName: "1.8.2"},
+			},
+		)
+		return tmpl, data, nil
+	default:
+		return nil, nil, ErrPreviewNotSupported
+	}
+}
+
+func sampleDeploymentTargetOutage(now time.Time) types.DeploymentTargetOutage {
+	return types.DeploymentTargetOutage{
+		CreatedAt:                   now.Add(-time.Hour),
+		TargetCount:                 40,
+		AffectedDeploymentTargetIDs: make([]uuid.UUID, 38),
+	}
+}
@@ -0,0 +1,36 @@
+package mailtemplates_test
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestPreview(t *testing.T) {
+	org := types.OrganizationWithBranding{Organization: types.Organization{Name: "Example Inc."}}
+	for _, mailType := range types.MailTypes {
+		t.Run(string(mailType), func(t *testing.T) {
+			g := NewWithT(t)
+			tmpl, data, err := mailtemplates.Preview(mailType, mailtemplates.SampleUserAccount, org)
+			g.Expect(err).NotTo(HaveOccurred())
+			var buf bytes.Buffer
+			g.Expect(tmpl.Execute(&buf, data)).To(Succeed())
+			g.Expect(buf.String()).NotTo(BeEmpty())
+			switch mailType {
+			case types.MailTypeInviteUser, types.MailTypeInviteCustomer, types.MailTypeVerifyEmail,
+				types.MailTypePasswordReset:
+				g.Expect(buf.String()).To(Or(
+					ContainSubstring("jwt="+mailtemplates.MaskedSecret),
+					ContainSubstring("jwt="+url.QueryEscape(mailtemplates.MaskedSecret)),
+				))
+			}
+		})
+	}
+
+	_, _, err := mailtemplates.Preview(types.MailTypeOther, mailtemplates.SampleUserAccount, org)
+	NewWithT(t).Expect(err).To(MatchError(mailtemplates.ErrPreviewNotSupported))
+}
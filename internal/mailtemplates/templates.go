@@ -2,6 +2,7 @@ package mailtemplates
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
 	"io/fs"
 	"net/url"
@@ -88,7 +89,14 @@ func VerifyEmail(userAccount types.UserAccount, org types.Organization, token st
 	}
 }
 
-func PasswordReset(userAccount types.UserAccount, org *types.Organization, token string) (*template.Template, any) {
+// PasswordReset is also the way to recover an account whose passkeys have been lost. If hasPasskeys is true, the mail
+// warns that the passkeys of the account remain valid after the reset.
+func PasswordReset(
+	userAccount types.UserAccount,
+	org *types.Organization,
+	token string,
+	hasPasskeys bool,
+) (*template.Template, any) {
 	host := env.Host()
 	if org != nil {
 		host = customdomains.AppDomainOrDefault(*org)
@@ -97,5 +105,218 @@ func PasswordReset(userAccount types.UserAccount, org *types.Organization, token
 		"UserAccount": userAccount,
 		"Host":        host,
 		"Token":       token,
+		"HasPasskeys": hasPasskeys,
+	}
+}
+
+func OrganizationMailConfigDisabled(
+	organization types.OrganizationWithBranding,
+	config types.OrganizationMailConfig,
+) (*template.Template, any) {
+	return templates.Lookup("organization-mail-config-disabled.html"), map[string]any{
+		"Organization": organization,
+		"MailConfig":   config,
+		"Host":         customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func OrganizationStorageFailing(
+	organization types.OrganizationWithBranding,
+	storage types.OrganizationStorage,
+) (*template.Template, any) {
+	return templates.Lookup("organization-storage-failing.html"), map[string]any{
+		"Organization": organization,
+		"Storage":      storage,
+		"Host":         customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func UpstreamWatchChanged(
+	organization types.OrganizationWithBranding,
+	watch types.UpstreamWatch,
+	change types.UpstreamWatchChange,
+) (*template.Template, any) {
+	return templates.Lookup("upstream-watch-changed.html"), map[string]any{
+		"Organization": organization,
+		"Watch":        watch,
+		"Change":       change,
+		"Host":         customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func CertificateExpiring(
+	organization types.OrganizationWithBranding,
+	deploymentTargetName string,
+	endpoint types.DeploymentTargetEndpoint,
+	cert types.DeploymentTargetEndpointCertificate,
+	daysLeft int,
+) (*template.Template, any) {
+	return templates.Lookup("certificate-expiring.html"), map[string]any{
+		"Organization":         organization,
+		"DeploymentTargetName": deploymentTargetName,
+		"Endpoint":             endpoint,
+		"Certificate":          cert,
+		"DaysLeft":             daysLeft,
+		"Host":                 customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func AppMetricAlertFiring(
+	organization types.OrganizationWithBranding,
+	deploymentTargetName string,
+	applicationName string,
+	rule types.ApplicationMetricAlertRule,
+) (*template.Template, any) {
+	return templates.Lookup("app-metric-alert-firing.html"), map[string]any{
+		"Organization":         organization,
+		"DeploymentTargetName": deploymentTargetName,
+		"ApplicationName":      applicationName,
+		"Rule":                 rule,
+		"Host":                 customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func ArtifactDeletionRequested(
+	organization types.OrganizationWithBranding,
+	artifact types.Artifact,
+	requestedBy types.UserAccount,
+	licenses []types.ArtifactLicenseBase,
+) (*template.Template, any) {
+	return templates.Lookup("artifact-deletion-requested.html"), map[string]any{
+		"Organization": organization,
+		"Artifact":     artifact,
+		"RequestedBy":  requestedBy,
+		"Licenses":     licenses,
+		"Host":         customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+// AccessGrantCreated informs a customer that grantedTo has been given access to target or, if target is nil, to all
+// deployment targets of the customer.
+func AccessGrantCreated(
+	organization types.OrganizationWithBranding,
+	grant types.AccessGrant,
+	grantedTo types.UserAccount,
+	target *types.DeploymentTarget,
+) (*template.Template, any) {
+	return templates.Lookup("access-grant-created.html"), map[string]any{
+		"Organization":     organization,
+		"Grant":            grant,
+		"GrantedTo":        grantedTo,
+		"DeploymentTarget": target,
+		"Host":             customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+// DeploymentAcknowledgmentRequired asks a customer to acknowledge an update of a deployment. If reminder is true, the
+// customer has already been asked before.
+func DeploymentAcknowledgmentRequired(
+	organization types.OrganizationWithBranding,
+	acknowledgment types.PendingDeploymentAcknowledgment,
+	reminder bool,
+) (*template.Template, any) {
+	return templates.Lookup("deployment-acknowledgment-required.html"), map[string]any{
+		"Organization":   organization,
+		"Acknowledgment": acknowledgment,
+		"Reminder":       reminder,
+		"Host":           customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+// VersionEOL informs a customer that application versions that run on their deployment targets reach their end of
+// life. Entries are the affected deployments of the customer.
+func VersionEOL(
+	organization types.OrganizationWithBranding,
+	applicationName string,
+	campaign types.VersionEOLCampaign,
+	customer types.UserAccount,
+	entries []types.VersionEOLReportEntry,
+) (*template.Template, any) {
+	return templates.Lookup("version-eol.html"), map[string]any{
+		"Organization":    organization,
+		"ApplicationName": applicationName,
+		"Campaign":        campaign,
+		"Customer":        customer,
+		"Entries":         entries,
+		"Host":            customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func DeploymentTargetConnectInstructions(
+	organization types.OrganizationWithBranding,
+	target types.DeploymentTarget,
+	preRegistration types.DeploymentTargetPreRegistration,
+	connectCommand string,
+	prerequisites []string,
+	reminder bool,
+) (*template.Template, any) {
+	return templates.Lookup("deployment-target-connect-instructions.html"), map[string]any{
+		"Organization":          organization,
+		"Target":                target,
+		"PairingTokenExpiresAt": preRegistration.PairingTokenExpiresAt,
+		"ConnectCommand":        connectCommand,
+		"Prerequisites":         prerequisites,
+		"Reminder":              reminder,
+		"Host":                  customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func DeploymentAutoRollback(
+	organization types.OrganizationWithBranding,
+	rollback types.DeploymentAutoRollback,
+) (*template.Template, any) {
+	return templates.Lookup("deployment-auto-rollback.html"), map[string]any{
+		"Organization": organization,
+		"Rollback":     rollback,
+		"Host":         customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func DeploymentTargetOutageSuspected(
+	organization types.OrganizationWithBranding,
+	outage types.DeploymentTargetOutage,
+	customer *types.UserAccount,
+) (*template.Template, any) {
+	return templates.Lookup("deployment-target-outage-suspected.html"), map[string]any{
+		"Organization": organization,
+		"Outage":       outage,
+		"Customer":     customer,
+		"Host":         customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func DeploymentTargetOutageResolved(
+	organization types.OrganizationWithBranding,
+	outage types.DeploymentTargetOutage,
+	customer *types.UserAccount,
+) (*template.Template, any) {
+	return templates.Lookup("deployment-target-outage-resolved.html"), map[string]any{
+		"Organization": organization,
+		"Outage":       outage,
+		"Customer":     customer,
+		"Host":         customdomains.AppDomainOrDefault(organization.Organization),
+	}
+}
+
+func SecurityEvent(userAccount types.UserAccount, event types.SecurityEvent) (*template.Template, any) {
+	return templates.Lookup("security-event.html"), map[string]any{
+		"UserAccount": userAccount,
+		"Event":       event,
+		"Host":        env.Host(),
+	}
+}
+
+func CustomerDataExportReady(
+	organization types.OrganizationWithBranding,
+	export types.CustomerDataExport,
+	customer types.UserAccount,
+	key string,
+) (*template.Template, any) {
+	host := customdomains.AppDomainOrDefault(organization.Organization)
+	return templates.Lookup("customer-data-export-ready.html"), map[string]any{
+		"Organization": organization,
+		"Export":       export,
+		"Customer":     customer,
+		"DownloadURL":  fmt.Sprintf("%v/api/v1/data-exports/%v/download?key=%v", host, export.ID, url.QueryEscape(key)),
 	}
 }
@@ -0,0 +1,86 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultRetryAfter is used if no retry after is given when entering maintenance mode.
+	DefaultRetryAfter = 5 * 60
+	maxRetryAfter     = 24 * 60 * 60
+	maxReasonLength   = 500
+	defaultReason     = "planned maintenance"
+
+	auditResourceType = "MaintenanceMode"
+	auditActionEnter  = "enter"
+	auditActionLeave  = "leave"
+)
+
+var (
+	ErrInvalidRetryAfter = errors.New("retryAfterSeconds must be between 1 and 86400")
+	ErrReasonTooLong     = errors.New("reason must not be longer than 500 characters")
+)
+
+// Enter activates maintenance mode for mode.OrganizationID, or the whole server if it is nil, and records this in
+// the audit log. source describes where the change was made, for example "api" or "cli".
+func Enter(ctx context.Context, mode *types.MaintenanceMode, source string) error {
+	mode.Reason = strings.TrimSpace(mode.Reason)
+	if mode.Reason == "" {
+		mode.Reason = defaultReason
+	} else if len(mode.Reason) > maxReasonLength {
+		return ErrReasonTooLong
+	}
+	if mode.RetryAfterSeconds == 0 {
+		mode.RetryAfterSeconds = DefaultRetryAfter
+	} else if mode.RetryAfterSeconds < 0 || mode.RetryAfterSeconds > maxRetryAfter {
+		return ErrInvalidRetryAfter
+	}
+	// if maintenance mode is already active, the creator is not changed
+	userAccountID := mode.CreatedByUserAccountID
+	return db.RunTx(ctx, func(ctx context.Context) error {
+		if err := db.PutMaintenanceMode(ctx, mode); err != nil {
+			return err
+		}
+		return audit(ctx, auditActionEnter, *mode, userAccountID, source)
+	})
+}
+
+// Leave deactivates maintenance mode for an organization, or the whole server if orgID is nil, and records this in
+// the audit log. apierrors.ErrNotFound is returned if maintenance mode is not active.
+func Leave(ctx context.Context, orgID *uuid.UUID, userAccountID *uuid.UUID, source string) error {
+	return db.RunTx(ctx, func(ctx context.Context) error {
+		if mode, err := db.DeleteMaintenanceMode(ctx, orgID); err != nil {
+			return err
+		} else {
+			return audit(ctx, auditActionLeave, *mode, userAccountID, source)
+		}
+	})
+}
+
+func audit(
+	ctx context.Context,
+	action string,
+	mode types.MaintenanceMode,
+	userAccountID *uuid.UUID,
+	source string,
+) error {
+	return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+		OrganizationID: mode.OrganizationID,
+		UserAccountID:  userAccountID,
+		Action:         action,
+		ResourceType:   auditResourceType,
+		ResourceID:     mode.ID,
+		Data: map[string]any{
+			"reason":            mode.Reason,
+			"retryAfterSeconds": mode.RetryAfterSeconds,
+			"since":             mode.CreatedAt,
+			"source":            source,
+		},
+	})
+}
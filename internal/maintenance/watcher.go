@@ -0,0 +1,101 @@
+// Package maintenance keeps the maintenance mode state of all replicas in sync.
+//
+// The state is stored in the database. Every change is announced on db.MaintenanceModeChannel, so that all replicas
+// that LISTEN on this channel can reload it immediately. In case a notification is lost, for example while the
+// listening connection is re-established, the state is also reloaded periodically.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const defaultReloadInterval = 10 * time.Second
+
+type Watcher struct {
+	pool           *pgxpool.Pool
+	logger         *zap.Logger
+	reloadInterval time.Duration
+	state          atomic.Pointer[types.MaintenanceState]
+}
+
+func NewWatcher(pool *pgxpool.Pool, logger *zap.Logger) *Watcher {
+	w := &Watcher{pool: pool, logger: logger, reloadInterval: defaultReloadInterval}
+	w.state.Store(&types.MaintenanceState{})
+	return w
+}
+
+// State returns the last known maintenance state. Before the first successful load, no maintenance mode is active.
+func (w *Watcher) State() types.MaintenanceState {
+	return *w.state.Load()
+}
+
+// Reload reads the current state from the database.
+func (w *Watcher) Reload(ctx context.Context) error {
+	if modes, err := db.GetMaintenanceModes(internalctx.WithDb(ctx, w.pool)); err != nil {
+		return err
+	} else {
+		state := types.NewMaintenanceState(modes)
+		if previous := w.state.Swap(&state); (previous.Server == nil) != (state.Server == nil) {
+			w.logger.Warn("server-wide maintenance mode changed", zap.Bool("active", state.Server != nil))
+		}
+		return nil
+	}
+}
+
+// Start loads the initial state and keeps it up to date until ctx is done.
+func (w *Watcher) Start(ctx context.Context) {
+	if err := w.Reload(ctx); err != nil {
+		w.logger.Warn("could not load maintenance state", zap.Error(err))
+	}
+	go func() {
+		for ctx.Err() == nil {
+			if err := w.listen(ctx); err != nil && ctx.Err() == nil {
+				w.logger.Warn("listening for maintenance state changes failed", zap.Error(err))
+				select {
+				case <-ctx.Done():
+				case <-time.After(w.reloadInterval):
+				}
+			}
+		}
+	}()
+}
+
+func (w *Watcher) listen(ctx context.Context) error {
+	poolConn, err := w.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("could not acquire connection: %w", err)
+	}
+	// The connection is removed from the pool, so that no other query is executed on a connection with an active
+	// LISTEN.
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{db.MaintenanceModeChannel}.Sanitize()); err != nil {
+		return fmt.Errorf("could not listen: %w", err)
+	}
+	for {
+		// changes that happened while no connection was listening are picked up here as well
+		if err := w.Reload(ctx); err != nil {
+			w.logger.Warn("could not reload maintenance state", zap.Error(err))
+		}
+		waitCtx, cancel := context.WithTimeout(ctx, w.reloadInterval)
+		err := conn.PgConn().WaitForNotification(waitCtx)
+		timedOut := waitCtx.Err() != nil
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil && !timedOut {
+			return err
+		}
+	}
+}
@@ -7,10 +7,52 @@ import (
 
 func AccessTokenToDTO(model types.AccessToken) api.AccessToken {
 	return api.AccessToken{
-		ID:         model.ID,
-		CreatedAt:  model.CreatedAt,
-		ExpiresAt:  model.ExpiresAt,
-		LastUsedAt: model.LastUsedAt,
-		Label:      model.Label,
+		ID:             model.ID,
+		CreatedAt:      model.CreatedAt,
+		ExpiresAt:      model.ExpiresAt,
+		LastUsedAt:     model.LastUsedAt,
+		Label:          model.Label,
+		KeyPrefix:      model.KeyPrefix,
+		RegistryScopes: model.RegistryScopes,
+	}
+}
+
+func OrganizationAccessTokenToDTO(model types.OrganizationAccessToken) api.OrganizationAccessToken {
+	dto := api.OrganizationAccessToken{
+		AccessToken: api.AccessToken{
+			ID:             model.ID,
+			CreatedAt:      model.CreatedAt,
+			ExpiresAt:      model.ExpiresAt,
+			LastUsedAt:     model.LastUsedAt,
+			Label:          model.Label,
+			KeyPrefix:      model.KeyPrefix,
+			RegistryScopes: model.RegistryScopes,
+		},
+	}
+	if model.UserAccount != nil {
+		dto.UserAccountID = &model.UserAccount.ID
+		dto.UserEmail = model.UserAccount.Email
+		dto.UserName = model.UserAccount.Name
+	}
+	if model.UserRole != nil {
+		dto.UserRole = *model.UserRole
+		dto.Customer = *model.UserRole == types.UserRoleCustomer
+	}
+	if model.ServiceAccount != nil {
+		dto.ServiceAccountID = &model.ServiceAccount.ID
+		dto.ServiceAccountName = model.ServiceAccount.Name
+	}
+	return dto
+}
+
+func ServiceAccountTokenToDTO(model types.ServiceAccountToken) api.AccessToken {
+	return api.AccessToken{
+		ID:             model.ID,
+		CreatedAt:      model.CreatedAt,
+		ExpiresAt:      model.ExpiresAt,
+		LastUsedAt:     model.LastUsedAt,
+		Label:          model.Label,
+		KeyPrefix:      model.KeyPrefix,
+		RegistryScopes: model.RegistryScopes,
 	}
 }
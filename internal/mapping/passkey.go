@@ -0,0 +1,16 @@
+package mapping
+
+import (
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/types"
+)
+
+func PasskeyToDTO(model types.WebAuthnCredential) api.Passkey {
+	return api.Passkey{
+		ID:         model.ID,
+		CreatedAt:  model.CreatedAt,
+		Nickname:   model.Nickname,
+		Transports: model.Transports,
+		LastUsedAt: model.LastUsedAt,
+	}
+}
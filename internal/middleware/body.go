@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+
+	"github.com/glasskube/distr/internal/contenttype"
+)
+
+// RequestBodyLimit limits request bodies to maxBytes. Reading a larger body fails with an [http.MaxBytesError] once
+// the limit is exceeded, so that no more than maxBytes are ever read.
+//
+// An inner RequestBodyLimit replaces the limit of an outer one, so that single routes, like file uploads, can allow
+// larger bodies than the default.
+func RequestBodyLimit(maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := r.Body
+			if limited, ok := body.(*limitedBody); ok {
+				body = limited.original
+			}
+			r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, body, maxBytes), original: body}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	original io.ReadCloser
+}
+
+// RequestBodyTooLarge responds with 413 Content Too Large. Handlers should use it if reading the request body failed
+// with an [http.MaxBytesError].
+func RequestBodyTooLarge(w http.ResponseWriter, maxBytes int64) {
+	http.Error(w, fmt.Sprintf("request body too large (max %v bytes)", maxBytes), http.StatusRequestEntityTooLarge)
+}
+
+// IsRequestBodyTooLarge returns the limit that has been exceeded if err was caused by [RequestBodyLimit].
+func IsRequestBodyTooLarge(err error) (int64, bool) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr.Limit, true
+	}
+	return 0, false
+}
+
+// RejectContentEncoding rejects requests with a compressed body, because no handler is able to decode them.
+func RejectContentEncoding(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+			http.Error(w, "unsupported Content-Encoding: "+encoding, http.StatusUnsupportedMediaType)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// RequireContentType rejects requests that have a body with a media type other than the given ones.
+// Requests without a body are always accepted.
+func RequireContentType(mediaTypes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := CheckContentType(r, mediaTypes...); err != nil {
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			} else {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// CheckContentType returns an error if r has a body with a media type other than the given ones.
+func CheckContentType(r *http.Request, mediaTypes ...string) error {
+	if r.ContentLength == 0 {
+		return nil
+	} else if r.Header.Get("Content-Type") == "" {
+		return errors.New("missing Content-Type")
+	}
+	return contenttype.HasMediaType(textproto.MIMEHeader(r.Header), mediaTypes...)
+}
+
+// MultipartUpload is used for routes that accept file uploads. It allows request bodies of up to maxBytes and
+// requires them to be multipart/form-data.
+func MultipartUpload(maxBytes int64) func(next http.Handler) http.Handler {
+	limit := RequestBodyLimit(maxBytes)
+	requireMultipart := RequireContentType(contenttype.MediaTypeMultipartForm)
+	return func(next http.Handler) http.Handler {
+		return limit(requireMultipart(next))
+	}
+}
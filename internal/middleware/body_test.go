@@ -0,0 +1,116 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/internal/middleware"
+	. "github.com/onsi/gomega"
+)
+
+// readBody reads the whole request body like a handler using JsonBody would.
+var readBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if _, err := io.ReadAll(r.Body); err != nil {
+		if limit, ok := middleware.IsRequestBodyTooLarge(err); ok {
+			middleware.RequestBodyTooLarge(w, limit)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+})
+
+// apiRoute mimics the middlewares that the API router applies to all routes.
+func apiRoute(h http.Handler) http.Handler {
+	return middleware.RequestBodyLimit(16)(middleware.RejectContentEncoding(
+		middleware.RequireContentType("application/json", "multipart/form-data")(h),
+	))
+}
+
+func request(method string, contentType string, body string) *http.Request {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+func serveRequest(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	g := NewWithT(t)
+	h := apiRoute(readBody)
+
+	w := serveRequest(h, request(http.MethodPost, "application/json", `{"name":"ok"}`))
+	g.Expect(w.Code).To(Equal(http.StatusNoContent))
+
+	w = serveRequest(h, request(http.MethodPost, "application/json", `{"name":"much too large"}`))
+	g.Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	g.Expect(w.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+	g.Expect(w.Body.String()).To(Equal("request body too large (max 16 bytes)\n"))
+
+	r := request(http.MethodPost, "application/json", `{"name":"much too large"}`)
+	r.ContentLength = -1
+	w = serveRequest(h, r)
+	g.Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge), "bodies of unknown length must be limited as well")
+}
+
+func TestMultipartUploadOverridesLimit(t *testing.T) {
+	g := NewWithT(t)
+	h := apiRoute(middleware.MultipartUpload(64)(readBody))
+	body := strings.Repeat("x", 32)
+
+	w := serveRequest(h, request(http.MethodPost, "multipart/form-data; boundary=x", body))
+	g.Expect(w.Code).To(Equal(http.StatusNoContent))
+
+	w = serveRequest(h, request(http.MethodPost, "multipart/form-data; boundary=x", body+body+body))
+	g.Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	g.Expect(w.Body.String()).To(Equal("request body too large (max 64 bytes)\n"))
+
+	w = serveRequest(h, request(http.MethodPost, "application/json", body))
+	g.Expect(w.Code).To(Equal(http.StatusUnsupportedMediaType))
+}
+
+func TestRequireContentType(t *testing.T) {
+	g := NewWithT(t)
+	h := apiRoute(readBody)
+
+	w := serveRequest(h, request(http.MethodPut, "application/json; charset=utf-8", "{}"))
+	g.Expect(w.Code).To(Equal(http.StatusNoContent))
+
+	w = serveRequest(h, request(http.MethodPost, "", ""))
+	g.Expect(w.Code).To(Equal(http.StatusNoContent), "requests without a body do not need a Content-Type")
+
+	w = serveRequest(h, request(http.MethodPost, "", "{}"))
+	g.Expect(w.Code).To(Equal(http.StatusUnsupportedMediaType))
+	g.Expect(w.Body.String()).To(Equal("missing Content-Type\n"))
+
+	w = serveRequest(h, request(http.MethodPost, "text/plain", "{}"))
+	g.Expect(w.Code).To(Equal(http.StatusUnsupportedMediaType))
+	g.Expect(w.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+	g.Expect(w.Body.String()).To(ContainSubstring("unacceptable media type: text/plain"))
+}
+
+func TestRejectContentEncoding(t *testing.T) {
+	g := NewWithT(t)
+	h := apiRoute(readBody)
+
+	r := request(http.MethodPost, "application/json", "{}")
+	r.Header.Set("Content-Encoding", "gzip")
+	w := serveRequest(h, r)
+	g.Expect(w.Code).To(Equal(http.StatusUnsupportedMediaType))
+	g.Expect(w.Body.String()).To(Equal("unsupported Content-Encoding: gzip\n"))
+
+	r = request(http.MethodPost, "application/json", "{}")
+	r.Header.Set("Content-Encoding", "identity")
+	w = serveRequest(h, r)
+	g.Expect(w.Code).To(Equal(http.StatusNoContent))
+}
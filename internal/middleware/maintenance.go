@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/maintenance"
+	"github.com/google/uuid"
+)
+
+// MaintenanceCtxMiddleware adds a snapshot of the current maintenance state to the request context, so that all
+// checks within a request see the same state.
+func MaintenanceCtxMiddleware(watcher *maintenance.Watcher) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := internalctx.WithMaintenanceState(r.Context(), watcher.State())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ReadOnlyDuringMaintenance rejects mutating requests while maintenance mode is active for the whole server or for
+// the organization of the authenticated user or agent.
+var ReadOnlyDuringMaintenance = readOnlyDuringMaintenance(true)
+
+// ReadOnlyDuringServerMaintenance is like ReadOnlyDuringMaintenance but ignores maintenance mode of organizations.
+// It is used for the endpoints that control maintenance mode of an organization.
+var ReadOnlyDuringServerMaintenance = readOnlyDuringMaintenance(false)
+
+func readOnlyDuringMaintenance(includeOrganization bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			var orgID *uuid.UUID
+			if includeOrganization {
+				orgID = maintenanceOrgID(ctx)
+			}
+			if mode := internalctx.GetMaintenanceState(ctx).For(orgID); mode != nil {
+				w.Header().Set("Retry-After", strconv.Itoa(mode.RetryAfterSeconds))
+				http.Error(w, "Distr is in read-only maintenance mode: "+mode.Reason, http.StatusServiceUnavailable)
+			} else {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func maintenanceOrgID(ctx context.Context) *uuid.UUID {
+	if userAuth, err := auth.Authentication.Get(ctx); err == nil {
+		return userAuth.CurrentOrgID()
+	} else if agentAuth, err := auth.AgentAuthentication.Get(ctx); err == nil {
+		orgID := agentAuth.CurrentOrgID()
+		return &orgID
+	}
+	return nil
+}
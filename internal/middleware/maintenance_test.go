@@ -0,0 +1,74 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func serveWithMaintenance(
+	handler func(http.Handler) http.Handler,
+	state types.MaintenanceState,
+	method string,
+) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/", nil)
+	r = r.WithContext(internalctx.WithMaintenanceState(r.Context(), state))
+	handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(w, r)
+	return w
+}
+
+func TestReadOnlyDuringMaintenance(t *testing.T) {
+	g := NewWithT(t)
+	server := types.NewMaintenanceState([]types.MaintenanceMode{{Reason: "database upgrade", RetryAfterSeconds: 120}})
+
+	w := serveWithMaintenance(middleware.ReadOnlyDuringMaintenance, server, http.MethodPost)
+	g.Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+	g.Expect(w.Header().Get("Retry-After")).To(Equal("120"))
+	g.Expect(w.Body.String()).To(ContainSubstring("database upgrade"))
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		w = serveWithMaintenance(middleware.ReadOnlyDuringMaintenance, server, method)
+		g.Expect(w.Code).To(Equal(http.StatusNoContent), method)
+	}
+
+	w = serveWithMaintenance(middleware.ReadOnlyDuringMaintenance, types.MaintenanceState{}, http.MethodPut)
+	g.Expect(w.Code).To(Equal(http.StatusNoContent))
+}
+
+func TestReadOnlyDuringServerMaintenance(t *testing.T) {
+	g := NewWithT(t)
+	orgID := uuid.New()
+	orgOnly := types.NewMaintenanceState([]types.MaintenanceMode{{OrganizationID: &orgID, RetryAfterSeconds: 60}})
+
+	// without authentication, only server-wide maintenance mode applies
+	w := serveWithMaintenance(middleware.ReadOnlyDuringMaintenance, orgOnly, http.MethodDelete)
+	g.Expect(w.Code).To(Equal(http.StatusNoContent))
+	w = serveWithMaintenance(middleware.ReadOnlyDuringServerMaintenance, orgOnly, http.MethodDelete)
+	g.Expect(w.Code).To(Equal(http.StatusNoContent))
+}
+
+func TestMaintenanceStateFor(t *testing.T) {
+	g := NewWithT(t)
+	orgID := uuid.New()
+	otherOrgID := uuid.New()
+	state := types.NewMaintenanceState([]types.MaintenanceMode{{OrganizationID: &orgID, Reason: "org"}})
+	g.Expect(state.For(&orgID)).To(HaveField("Reason", "org"))
+	g.Expect(state.For(&otherOrgID)).To(BeNil())
+	g.Expect(state.For(nil)).To(BeNil())
+
+	state = types.NewMaintenanceState([]types.MaintenanceMode{
+		{OrganizationID: &orgID, Reason: "org"},
+		{Reason: "server"},
+	})
+	g.Expect(state.For(&orgID)).To(HaveField("Reason", "server"), "server-wide maintenance takes precedence")
+	g.Expect(state.For(nil)).To(HaveField("Reason", "server"))
+}
@@ -12,16 +12,16 @@ import (
 	"github.com/glasskube/distr/internal/authn"
 	"github.com/glasskube/distr/internal/authn/authinfo"
 	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db/queryable"
 	"github.com/glasskube/distr/internal/mail"
 	"github.com/glasskube/distr/internal/types"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"go.uber.org/zap"
 )
 
-func ContextInjectorMiddleware(db *pgxpool.Pool, mailer mail.Mailer) func(next http.Handler) http.Handler {
+func ContextInjectorMiddleware(db queryable.Queryable, mailer mail.Mailer) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"go.uber.org/zap"
+)
+
+var errRollback = errors.New("handler responded with non-success status")
+
+// Transaction runs the wrapped handler in a database transaction.
+// The transaction is committed if the handler responds with a 2xx status code and rolled back otherwise, or if the
+// handler panics.
+//
+// The response is buffered until the transaction has been committed, so that clients never receive a success
+// response for changes that have not been persisted. Handlers that stream their response must therefore not be
+// wrapped with this middleware.
+// Handlers can use db.RunAutonomous for writes that should be persisted regardless of the transaction outcome.
+func Transaction(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bw := newBufferedResponseWriter()
+		err := db.RunTx(ctx, func(ctx context.Context) error {
+			next.ServeHTTP(bw, r.WithContext(ctx))
+			if !bw.isSuccess() {
+				return errRollback
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errRollback) {
+			internalctx.GetLogger(ctx).Error("transaction failed", zap.Error(err))
+			sentry.GetHubFromContext(ctx).CaptureException(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		bw.writeTo(w)
+	})
+}
+
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+var _ http.ResponseWriter = &bufferedResponseWriter{}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: http.Header{}}
+}
+
+// Header implements http.ResponseWriter.
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// Write implements http.ResponseWriter.
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(data)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *bufferedResponseWriter) isSuccess() bool {
+	// like net/http, we assume 200 if the handler did not write anything
+	return w.status == 0 || (w.status >= 200 && w.status < 300)
+}
+
+func (w *bufferedResponseWriter) writeTo(target http.ResponseWriter) {
+	for key, values := range w.header {
+		target.Header()[key] = values
+	}
+	if w.status != 0 {
+		target.WriteHeader(w.status)
+	}
+	_, _ = w.body.WriteTo(target)
+}
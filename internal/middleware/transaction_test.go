@@ -0,0 +1,157 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/db/queryable"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	. "github.com/onsi/gomega"
+)
+
+// fakeDb records statements passed to Exec as "rows". Rows written in a transaction only become visible in
+// committed after the transaction was committed. Nested transactions behave like savepoints, their rows are added to
+// the outer transaction when they are committed.
+type fakeDb struct {
+	queryable.Queryable
+	committed []string
+	begun     int
+}
+
+func (d *fakeDb) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	d.committed = append(d.committed, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (d *fakeDb) Begin(ctx context.Context) (pgx.Tx, error) {
+	d.begun++
+	return &fakeTx{db: d, commit: func(rows []string) { d.committed = append(d.committed, rows...) }}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	db      *fakeDb
+	commit  func(rows []string)
+	pending []string
+	closed  bool
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &fakeTx{db: tx.db, commit: func(rows []string) { tx.pending = append(tx.pending, rows...) }}, nil
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	tx.pending = append(tx.pending, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.commit(tx.pending)
+	tx.closed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	return nil
+}
+
+func serve(d *fakeDb, h http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r = r.WithContext(internalctx.WithDb(r.Context(), d))
+	w := httptest.NewRecorder()
+	middleware.Transaction(h).ServeHTTP(w, r)
+	return w
+}
+
+func exec(r *http.Request, sql string) {
+	_, _ = internalctx.GetDb(r.Context()).Exec(r.Context(), sql)
+}
+
+func TestTransactionCommitsOnSuccess(t *testing.T) {
+	g := NewWithT(t)
+	d := &fakeDb{}
+	w := serve(d, func(w http.ResponseWriter, r *http.Request) {
+		exec(r, "INSERT license")
+		exec(r, "INSERT assignment")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("ok"))
+	})
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+	g.Expect(w.Body.String()).To(Equal("ok"))
+	g.Expect(d.committed).To(Equal([]string{"INSERT license", "INSERT assignment"}))
+}
+
+func TestTransactionRollsBackOnErrorStatus(t *testing.T) {
+	g := NewWithT(t)
+	d := &fakeDb{}
+	w := serve(d, func(w http.ResponseWriter, r *http.Request) {
+		exec(r, "INSERT license")
+		http.Error(w, "assignment failed", http.StatusInternalServerError)
+	})
+	g.Expect(w.Code).To(Equal(http.StatusInternalServerError))
+	g.Expect(d.committed).To(BeEmpty())
+}
+
+func TestTransactionRollsBackOnPanic(t *testing.T) {
+	g := NewWithT(t)
+	d := &fakeDb{}
+	g.Expect(func() {
+		serve(d, func(w http.ResponseWriter, r *http.Request) {
+			exec(r, "INSERT license")
+			panic("boom")
+		})
+	}).To(PanicWith("boom"))
+	g.Expect(d.committed).To(BeEmpty())
+}
+
+func TestTransactionNested(t *testing.T) {
+	g := NewWithT(t)
+	d := &fakeDb{}
+	w := serve(d, func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		_ = db.RunTx(ctx, func(ctx context.Context) error {
+			_, err := internalctx.GetDb(ctx).Exec(ctx, "INSERT license")
+			return err
+		})
+		_ = db.RunAutonomous(ctx, func(ctx context.Context) error {
+			_, err := internalctx.GetDb(ctx).Exec(ctx, "INSERT audit")
+			return err
+		})
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(d.begun).To(Equal(1), "nested RunTx must use a savepoint of the outer transaction")
+	g.Expect(d.committed).To(Equal([]string{"INSERT audit"}))
+}
+
+func TestTransactionNestedRollback(t *testing.T) {
+	g := NewWithT(t)
+	d := &fakeDb{}
+	w := serve(d, func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		err := db.RunTx(ctx, func(ctx context.Context) error {
+			exec(r.WithContext(ctx), "INSERT license")
+			return errors.New("assignment failed")
+		})
+		if err != nil {
+			exec(r, "INSERT fallback")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(d.committed).To(Equal([]string{"INSERT fallback"}), "only the nested transaction is rolled back")
+}
@@ -4,13 +4,16 @@ import (
 	"database/sql"
 	"embed"
 	"errors"
+	"os"
 	"strings"
 
 	"github.com/glasskube/distr/internal/env"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 )
@@ -38,8 +41,13 @@ func (l *Logger) Verbose() bool {
 
 var _ migrate.Logger = &Logger{}
 
-func Up(log *zap.Logger) (err error) {
-	db, err := sql.Open("pgx", env.DatabaseUrl())
+func Up(log *zap.Logger) error {
+	return UpURL(log, env.DatabaseUrl())
+}
+
+// UpURL is like Up but migrates the database at databaseURL instead of the one that is configured in the environment.
+func UpURL(log *zap.Logger, databaseURL string) (err error) {
+	db, err := openDB(log, databaseURL)
 	if err != nil {
 		return err
 	}
@@ -56,7 +64,7 @@ func Up(log *zap.Logger) (err error) {
 }
 
 func Down(log *zap.Logger) (err error) {
-	db, err := sql.Open("pgx", env.DatabaseUrl())
+	db, err := openDB(log, env.DatabaseUrl())
 	if err != nil {
 		return err
 	}
@@ -70,7 +78,7 @@ func Down(log *zap.Logger) (err error) {
 }
 
 func Migrate(log *zap.Logger, to uint) (err error) {
-	db, err := sql.Open("pgx", env.DatabaseUrl())
+	db, err := openDB(log, env.DatabaseUrl())
 	if err != nil {
 		return err
 	}
@@ -86,6 +94,20 @@ func Migrate(log *zap.Logger, to uint) (err error) {
 	return nil
 }
 
+// openDB opens the database at databaseURL. Notices that are raised by migrations, e.g. reports of data that has to be
+// fixed manually, are logged as warnings.
+func openDB(log *zap.Logger, databaseURL string) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	config.OnNotice = func(_ *pgconn.PgConn, notice *pgconn.Notice) {
+		log.Warn("migration notice", zap.String("severity", notice.Severity), zap.String("message", notice.Message),
+			zap.String("detail", notice.Detail), zap.String("hint", notice.Hint))
+	}
+	return stdlib.OpenDB(*config), nil
+}
+
 func getInstance(db *sql.DB, log *zap.Logger) (*migrate.Migrate, error) {
 	if driver, err := postgres.WithInstance(db, &postgres.Config{}); err != nil {
 		return nil, err
@@ -98,3 +120,23 @@ func getInstance(db *sql.DB, log *zap.Logger) (*migrate.Migrate, error) {
 		return instance, nil
 	}
 }
+
+// LatestVersion returns the version of the newest migration that is embedded in the binary.
+func LatestVersion() (uint, error) {
+	sourceInstance, err := iofs.New(fs, "sql")
+	if err != nil {
+		return 0, err
+	}
+	defer sourceInstance.Close()
+	version, err := sourceInstance.First()
+	for err == nil {
+		var next uint
+		if next, err = sourceInstance.Next(version); err == nil {
+			version = next
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return version, nil
+	}
+	return 0, err
+}
@@ -0,0 +1 @@
+ALTER TABLE AccessToken DROP COLUMN IF EXISTS registry_scopes;
@@ -0,0 +1,2 @@
+-- access tokens without scopes may do everything in the registry that the role of their user allows
+ALTER TABLE AccessToken ADD COLUMN IF NOT EXISTS registry_scopes JSONB;
@@ -0,0 +1,4 @@
+-- enum values can not be removed from SECURITY_EVENT_TYPE, so passkey_added and passkey_removed are kept
+
+DROP TABLE IF EXISTS WebAuthnChallenge;
+DROP TABLE IF EXISTS WebAuthnCredential;
@@ -0,0 +1,31 @@
+ALTER TYPE SECURITY_EVENT_TYPE ADD VALUE IF NOT EXISTS 'passkey_added';
+ALTER TYPE SECURITY_EVENT_TYPE ADD VALUE IF NOT EXISTS 'passkey_removed';
+
+CREATE TABLE IF NOT EXISTS WebAuthnCredential (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  user_account_id UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  -- the credential ID chosen by the authenticator
+  credential_id BYTEA NOT NULL UNIQUE,
+  -- the public key in the COSE_Key format
+  public_key BYTEA NOT NULL,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  transports TEXT[] NOT NULL DEFAULT '{}',
+  nickname TEXT NOT NULL,
+  last_used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS fk_WebAuthnCredential_user_account_id ON WebAuthnCredential (user_account_id);
+
+-- challenges of ongoing registration and authentication ceremonies, removed when they are completed
+CREATE TABLE IF NOT EXISTS WebAuthnChallenge (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  expires_at TIMESTAMP NOT NULL,
+  ceremony TEXT NOT NULL,
+  -- the user who registers a credential, NULL for authentication
+  user_account_id UUID REFERENCES UserAccount (id) ON DELETE CASCADE,
+  challenge BYTEA NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS WebAuthnChallenge_expires_at ON WebAuthnChallenge (expires_at);
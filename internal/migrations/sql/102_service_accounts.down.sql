@@ -0,0 +1,4 @@
+ALTER TABLE ArtifactVersionPull DROP COLUMN IF EXISTS service_account_id;
+
+DROP TABLE IF EXISTS ServiceAccountToken;
+DROP TABLE IF EXISTS ServiceAccount;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS ServiceAccount (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  description TEXT,
+  created_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  -- revoked service accounts are kept, so that they can still be identified in the audit log
+  revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ServiceAccount_organization_id_name
+  ON ServiceAccount (organization_id, name) WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS ServiceAccountToken (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  service_account_id UUID NOT NULL REFERENCES ServiceAccount (id) ON DELETE CASCADE,
+  label TEXT,
+  key_hash BYTEA NOT NULL UNIQUE,
+  key_prefix TEXT NOT NULL,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  registry_scopes JSONB
+);
+
+CREATE INDEX IF NOT EXISTS fk_ServiceAccountToken_service_account_id ON ServiceAccountToken (service_account_id);
+
+ALTER TABLE ArtifactVersionPull
+  ADD COLUMN IF NOT EXISTS service_account_id UUID REFERENCES ServiceAccount (id) ON DELETE SET NULL;
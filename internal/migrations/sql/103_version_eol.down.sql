@@ -0,0 +1,4 @@
+-- enum values can not be removed from MAIL_TYPE, so version_eol is kept
+
+DROP TABLE IF EXISTS VersionEOLNotification;
+DROP TABLE IF EXISTS VersionEOLCampaign;
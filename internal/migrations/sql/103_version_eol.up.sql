@@ -0,0 +1,36 @@
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'version_eol';
+
+-- a campaign announces the end of life of application versions to the customers that still run them
+CREATE TABLE IF NOT EXISTS VersionEOLCampaign (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  application_id UUID NOT NULL REFERENCES Application (id) ON DELETE CASCADE,
+  created_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  application_version_ids UUID[] NOT NULL,
+  end_of_life_at TIMESTAMP,
+  message TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS fk_VersionEOLCampaign_organization_id ON VersionEOLCampaign (organization_id);
+CREATE INDEX IF NOT EXISTS fk_VersionEOLCampaign_application_id ON VersionEOLCampaign (application_id);
+CREATE INDEX IF NOT EXISTS fk_VersionEOLCampaign_created_by_user_account_id
+  ON VersionEOLCampaign (created_by_user_account_id);
+
+-- a customer is notified about the end of life of a version at most once, regardless of the campaign
+-- notifications without sent_at are pending and are sent by the VersionEOLNotification job
+CREATE TABLE IF NOT EXISTS VersionEOLNotification (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  campaign_id UUID NOT NULL REFERENCES VersionEOLCampaign (id) ON DELETE CASCADE,
+  application_version_id UUID NOT NULL REFERENCES ApplicationVersion (id) ON DELETE CASCADE,
+  customer_user_account_id UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  sent_at TIMESTAMP,
+  UNIQUE (application_version_id, customer_user_account_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_VersionEOLNotification_campaign_id ON VersionEOLNotification (campaign_id);
+CREATE INDEX IF NOT EXISTS fk_VersionEOLNotification_customer_user_account_id
+  ON VersionEOLNotification (customer_user_account_id);
+CREATE INDEX IF NOT EXISTS VersionEOLNotification_pending
+  ON VersionEOLNotification (created_at) WHERE sent_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE Artifact
+  DROP COLUMN IF EXISTS mutable_tag_patterns,
+  DROP COLUMN IF EXISTS immutable_tags;
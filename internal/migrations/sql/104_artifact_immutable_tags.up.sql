@@ -0,0 +1,5 @@
+-- tags of artifacts with immutable tags can not be moved to a different manifest, except for tags that match one of
+-- the mutable tag patterns
+ALTER TABLE Artifact
+  ADD COLUMN IF NOT EXISTS immutable_tags BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS mutable_tag_patterns TEXT[] NOT NULL DEFAULT '{}';
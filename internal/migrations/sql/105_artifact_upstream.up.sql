@@ -0,0 +1,11 @@
+-- artifacts with an upstream are pull-through caches of a repository in another registry
+CREATE TABLE IF NOT EXISTS ArtifactUpstream (
+  artifact_id UUID PRIMARY KEY REFERENCES Artifact (id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  updated_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  updated_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  -- the repository including the registry host, e.g. ghcr.io/glasskube/distr
+  repository TEXT NOT NULL,
+  username TEXT,
+  password TEXT
+);
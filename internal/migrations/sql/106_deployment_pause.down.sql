@@ -0,0 +1,34 @@
+CREATE OR REPLACE TRIGGER DeploymentTarget_resource_version
+  BEFORE UPDATE ON DeploymentTarget
+  FOR EACH ROW
+  WHEN ((
+    OLD.type, OLD.namespace, OLD.agent_version_id, OLD.metrics_enabled, OLD.inventory_enabled,
+    OLD.migration_connect_url, OLD.agent_resource_limits,
+    OLD.vendor_logs_disabled, OLD.vendor_metrics_disabled, OLD.vendor_diagnostics_disabled,
+    OLD.vendor_inventory_disabled,
+    OLD.customer_logs_disabled, OLD.customer_metrics_disabled, OLD.customer_diagnostics_disabled,
+    OLD.customer_inventory_disabled
+  ) IS DISTINCT FROM (
+    NEW.type, NEW.namespace, NEW.agent_version_id, NEW.metrics_enabled, NEW.inventory_enabled,
+    NEW.migration_connect_url, NEW.agent_resource_limits,
+    NEW.vendor_logs_disabled, NEW.vendor_metrics_disabled, NEW.vendor_diagnostics_disabled,
+    NEW.vendor_inventory_disabled,
+    NEW.customer_logs_disabled, NEW.customer_metrics_disabled, NEW.customer_diagnostics_disabled,
+    NEW.customer_inventory_disabled
+  ))
+  EXECUTE FUNCTION DeploymentTarget_resource_version();
+
+DROP INDEX IF EXISTS DeploymentTarget_resume_at;
+DROP INDEX IF EXISTS Deployment_resume_at;
+
+ALTER TABLE DeploymentTarget
+  DROP COLUMN IF EXISTS paused_at,
+  DROP COLUMN IF EXISTS paused_by_user_account_id,
+  DROP COLUMN IF EXISTS pause_reason,
+  DROP COLUMN IF EXISTS resume_at;
+
+ALTER TABLE Deployment
+  DROP COLUMN IF EXISTS paused_at,
+  DROP COLUMN IF EXISTS paused_by_user_account_id,
+  DROP COLUMN IF EXISTS pause_reason,
+  DROP COLUMN IF EXISTS resume_at;
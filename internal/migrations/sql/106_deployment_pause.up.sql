@@ -0,0 +1,39 @@
+-- while a deployment or deployment target is paused, the agent does not apply its deployments but still reports their
+-- status, and no new revisions can be created. resume_at is the time at which the pause is ended automatically.
+ALTER TABLE Deployment
+  ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS paused_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS pause_reason TEXT,
+  ADD COLUMN IF NOT EXISTS resume_at TIMESTAMP;
+
+ALTER TABLE DeploymentTarget
+  ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS paused_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS pause_reason TEXT,
+  ADD COLUMN IF NOT EXISTS resume_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS Deployment_resume_at ON Deployment (resume_at) WHERE resume_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS DeploymentTarget_resume_at ON DeploymentTarget (resume_at) WHERE resume_at IS NOT NULL;
+
+-- the pause of a deployment target is part of the agent resource of all its deployments
+CREATE OR REPLACE TRIGGER DeploymentTarget_resource_version
+  BEFORE UPDATE ON DeploymentTarget
+  FOR EACH ROW
+  WHEN ((
+    OLD.type, OLD.namespace, OLD.agent_version_id, OLD.metrics_enabled, OLD.inventory_enabled,
+    OLD.migration_connect_url, OLD.agent_resource_limits,
+    OLD.vendor_logs_disabled, OLD.vendor_metrics_disabled, OLD.vendor_diagnostics_disabled,
+    OLD.vendor_inventory_disabled,
+    OLD.customer_logs_disabled, OLD.customer_metrics_disabled, OLD.customer_diagnostics_disabled,
+    OLD.customer_inventory_disabled,
+    OLD.paused_at
+  ) IS DISTINCT FROM (
+    NEW.type, NEW.namespace, NEW.agent_version_id, NEW.metrics_enabled, NEW.inventory_enabled,
+    NEW.migration_connect_url, NEW.agent_resource_limits,
+    NEW.vendor_logs_disabled, NEW.vendor_metrics_disabled, NEW.vendor_diagnostics_disabled,
+    NEW.vendor_inventory_disabled,
+    NEW.customer_logs_disabled, NEW.customer_metrics_disabled, NEW.customer_diagnostics_disabled,
+    NEW.customer_inventory_disabled,
+    NEW.paused_at
+  ))
+  EXECUTE FUNCTION DeploymentTarget_resource_version();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS ArtifactAlias_name_normalized;
+DROP INDEX IF EXISTS Artifact_name_normalized_trgm;
+DROP INDEX IF EXISTS Artifact_unique_name_normalized;
+
+ALTER TABLE Artifact DROP COLUMN IF EXISTS name_normalized;
@@ -0,0 +1,31 @@
+-- artifact names are looked up case-insensitively, so names that only differ in case can not coexist. They have to be
+-- renamed manually before this migration can succeed.
+DO $$
+DECLARE
+  collisions TEXT;
+BEGIN
+  SELECT string_agg(o.slug || ': ' || c.names, '; ' ORDER BY o.slug, c.names)
+  INTO collisions
+  FROM (
+    SELECT organization_id, string_agg(name, ', ' ORDER BY name) AS names
+    FROM Artifact
+    GROUP BY organization_id, lower(name)
+    HAVING count(*) > 1
+  ) c
+  JOIN Organization o ON o.id = c.organization_id;
+
+  IF collisions IS NOT NULL THEN
+    RAISE EXCEPTION 'artifact names collide when compared case-insensitively: %', collisions
+      USING HINT = 'Rename or delete all but one artifact of each group and run the migration again.';
+  END IF;
+END $$;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE Artifact
+  ADD COLUMN IF NOT EXISTS name_normalized TEXT GENERATED ALWAYS AS (lower(name)) STORED;
+
+CREATE UNIQUE INDEX IF NOT EXISTS Artifact_unique_name_normalized ON Artifact (organization_id, name_normalized);
+CREATE INDEX IF NOT EXISTS Artifact_name_normalized_trgm ON Artifact USING gin (name_normalized gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS ArtifactAlias_name_normalized ON ArtifactAlias (organization_id, lower(name));
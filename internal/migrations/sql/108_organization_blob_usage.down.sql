@@ -0,0 +1,3 @@
+ALTER TABLE Organization DROP COLUMN IF EXISTS storage_quota_bytes;
+
+DROP TABLE IF EXISTS OrganizationBlobUsage;
@@ -0,0 +1,26 @@
+-- blobs that count towards the storage quota of an organization. A blob that is part of multiple artifacts of the
+-- same organization is counted once.
+CREATE TABLE IF NOT EXISTS OrganizationBlobUsage
+(
+  organization_id UUID      NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  digest          TEXT      NOT NULL, --- "sha256:..."
+  size            BIGINT    NOT NULL,
+  created_at      TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (organization_id, digest)
+);
+
+-- overrides the default storage quota of the organization, like artifact_tag_limit
+ALTER TABLE Organization ADD COLUMN IF NOT EXISTS storage_quota_bytes BIGINT;
+
+INSERT INTO OrganizationBlobUsage (organization_id, digest, size)
+SELECT a.organization_id, blob.digest, max(blob.size)
+FROM Artifact a
+JOIN ArtifactVersion av ON av.artifact_id = a.id
+CROSS JOIN LATERAL (
+  SELECT av.manifest_blob_digest AS digest, av.manifest_blob_size AS size
+  UNION ALL
+  SELECT avp.artifact_blob_digest, avp.artifact_blob_size
+  FROM ArtifactVersionPart avp WHERE avp.artifact_version_id = av.id
+) blob
+GROUP BY a.organization_id, blob.digest
+ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS Organization_name_lower;
@@ -0,0 +1,23 @@
+-- slugs that have been reserved later on can still be used by existing organizations, but they should be asked to
+-- choose another one. They are reported here, so that they can be contacted before renaming is enforced.
+DO $$
+DECLARE
+  reserved TEXT;
+BEGIN
+  SELECT string_agg(slug || ' (' || id || ')', ', ' ORDER BY slug)
+  INTO reserved
+  FROM Organization
+  WHERE slug IN (
+    'admin', 'api', 'app', 'assets', 'auth', 'distr', 'docs', 'health', 'help', 'internal', 'login', 'logout',
+    'metrics', 'register', 'registry', 'root', 'settings', 'signup', 'static', 'status', 'support', 'system', 'v2',
+    'www'
+  );
+
+  IF reserved IS NOT NULL THEN
+    RAISE WARNING 'organizations use reserved slugs: %', reserved
+      USING HINT = 'Ask these organizations to choose another slug.';
+  END IF;
+END $$;
+
+-- names of new organizations are compared case-insensitively to append a suffix to duplicates
+CREATE INDEX IF NOT EXISTS Organization_name_lower ON Organization (lower(name));
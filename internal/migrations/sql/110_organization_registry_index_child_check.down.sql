@@ -0,0 +1 @@
+ALTER TABLE Organization DROP COLUMN IF EXISTS registry_index_child_check_disabled;
@@ -0,0 +1 @@
+ALTER TABLE Organization ADD COLUMN IF NOT EXISTS registry_index_child_check_disabled BOOLEAN NOT NULL DEFAULT false;
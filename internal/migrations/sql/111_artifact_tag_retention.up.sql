@@ -0,0 +1,17 @@
+-- retention rules untag old versions of an artifact, see tagretention.Evaluator
+CREATE TABLE IF NOT EXISTS ArtifactTagRetentionRule (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  artifact_id UUID NOT NULL REFERENCES Artifact (id) ON DELETE CASCADE,
+  created_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  -- a path.Match pattern, e.g. *-rc*
+  tag_pattern TEXT NOT NULL,
+  keep_last INT CHECK (keep_last >= 0),
+  unpulled_days INT CHECK (unpulled_days > 0),
+  dry_run BOOLEAN NOT NULL DEFAULT false,
+  last_evaluated_at TIMESTAMP,
+  last_report JSONB,
+  CHECK (keep_last IS NOT NULL OR unpulled_days IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS ArtifactTagRetentionRule_artifact_id ON ArtifactTagRetentionRule (artifact_id);
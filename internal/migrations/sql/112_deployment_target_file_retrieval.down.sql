@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS DeploymentTargetFileRetrieval;
+DROP TYPE IF EXISTS FILE_RETRIEVAL_STATUS;
+DROP TABLE IF EXISTS DeploymentTargetFileRetrievalAllowList;
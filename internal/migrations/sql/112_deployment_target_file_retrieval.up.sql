@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS DeploymentTargetFileRetrievalAllowList
+(
+  deployment_target_id       UUID PRIMARY KEY REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  updated_at                 TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  updated_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  allowed_paths              TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE TYPE FILE_RETRIEVAL_STATUS AS ENUM ('pending', 'completed', 'denied', 'failed');
+
+CREATE TABLE IF NOT EXISTS DeploymentTargetFileRetrieval
+(
+  id                           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at                   TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  deployment_target_id         UUID NOT NULL REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  requested_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  path                         TEXT NOT NULL,
+  status                       FILE_RETRIEVAL_STATUS NOT NULL DEFAULT 'pending',
+  reported_at                  TIMESTAMP,
+  content                      BYTEA,
+  size                         BIGINT,
+  redacted_secrets             INT,
+  error                        TEXT
+);
+
+CREATE INDEX IF NOT EXISTS fk_DeploymentTargetFileRetrieval_deployment_target_id
+  ON DeploymentTargetFileRetrieval (deployment_target_id);
+
+CREATE OR REPLACE TRIGGER DeploymentTargetFileRetrievalAllowList_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON DeploymentTargetFileRetrievalAllowList
+  FOR EACH ROW
+  EXECUTE FUNCTION deployment_target_child_resource_version();
+
+CREATE OR REPLACE TRIGGER DeploymentTargetFileRetrieval_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON DeploymentTargetFileRetrieval
+  FOR EACH ROW
+  EXECUTE FUNCTION deployment_target_child_resource_version();
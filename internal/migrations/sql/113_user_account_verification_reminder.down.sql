@@ -0,0 +1 @@
+ALTER TABLE UserAccount DROP COLUMN IF EXISTS verification_reminder_sent_at;
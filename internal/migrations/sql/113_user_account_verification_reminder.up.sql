@@ -0,0 +1,2 @@
+-- self-registered users that have not verified their email address are reminded once, see unverifiedaccounts.Job
+ALTER TABLE UserAccount ADD COLUMN IF NOT EXISTS verification_reminder_sent_at TIMESTAMP;
@@ -0,0 +1 @@
+ALTER TABLE OrganizationMailConfig DROP COLUMN IF EXISTS dkim_private_key;
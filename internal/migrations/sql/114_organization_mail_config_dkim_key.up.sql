@@ -0,0 +1,3 @@
+-- sender domain configs sign mails with their own key, which is encrypted with the key of the organization like the
+-- SMTP password
+ALTER TABLE OrganizationMailConfig ADD COLUMN IF NOT EXISTS dkim_private_key TEXT;
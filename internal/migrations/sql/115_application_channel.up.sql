@@ -0,0 +1,13 @@
+-- the version that a channel of an application currently points to, set by a successful promotion
+CREATE TABLE IF NOT EXISTS ApplicationChannel
+(
+  application_id         UUID NOT NULL REFERENCES Application (id) ON DELETE CASCADE,
+  channel                TEXT NOT NULL,
+  application_version_id UUID NOT NULL REFERENCES ApplicationVersion (id) ON DELETE CASCADE,
+  promotion_id           UUID REFERENCES ApplicationVersionPromotion (id) ON DELETE SET NULL,
+  promoted_at            TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (application_id, channel)
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationChannel_application_version_id
+  ON ApplicationChannel (application_version_id);
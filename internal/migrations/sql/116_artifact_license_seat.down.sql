@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ArtifactLicenseSeat;
+
+ALTER TABLE ArtifactLicense DROP COLUMN IF EXISTS seat_count;
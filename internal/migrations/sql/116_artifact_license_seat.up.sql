@@ -0,0 +1,12 @@
+ALTER TABLE ArtifactLicense
+  ADD COLUMN seat_count INT CONSTRAINT ArtifactLicense_seat_count_non_negative CHECK (seat_count >= 0);
+
+CREATE TABLE IF NOT EXISTS ArtifactLicenseSeat
+(
+  artifact_license_id UUID NOT NULL REFERENCES ArtifactLicense (id) ON DELETE CASCADE,
+  useraccount_id      UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  created_at          TIMESTAMP DEFAULT current_timestamp,
+  PRIMARY KEY (artifact_license_id, useraccount_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_ArtifactLicenseSeat_useraccount_id ON ArtifactLicenseSeat (useraccount_id);
@@ -0,0 +1 @@
+ALTER TABLE SentMail DROP COLUMN IF EXISTS pii_key_version;
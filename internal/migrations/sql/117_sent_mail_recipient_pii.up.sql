@@ -0,0 +1,3 @@
+-- the recipient is encrypted like the email addresses of user accounts, the key version tells the encryption job which
+-- rows still have to be encrypted or re-encrypted
+ALTER TABLE SentMail ADD COLUMN IF NOT EXISTS pii_key_version INT;
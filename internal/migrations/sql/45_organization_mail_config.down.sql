@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS OrganizationMailConfig CASCADE;
+
+DROP TYPE IF EXISTS MAIL_CONFIG_TYPE;
@@ -0,0 +1,26 @@
+CREATE TYPE MAIL_CONFIG_TYPE AS ENUM ('smtp', 'domain');
+
+CREATE TABLE IF NOT EXISTS OrganizationMailConfig
+(
+  id                         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at                 TIMESTAMP        DEFAULT current_timestamp,
+  organization_id            UUID UNIQUE NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  updated_at                 TIMESTAMP        DEFAULT current_timestamp,
+  updated_by_user_account_id UUID        REFERENCES UserAccount (id) ON DELETE SET NULL,
+  type                       MAIL_CONFIG_TYPE NOT NULL,
+  from_address               TEXT        NOT NULL,
+  smtp_host                  TEXT,
+  smtp_port                  INT,
+  smtp_username              TEXT,
+  smtp_password              TEXT,
+  sender_domain              TEXT,
+  dkim_selector              TEXT,
+  verification_token         TEXT,
+  verified_at                TIMESTAMP,
+  failure_count              INT         NOT NULL DEFAULT 0,
+  last_failure_at            TIMESTAMP,
+  last_failure_message       TEXT,
+  disabled_at                TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS fk_OrganizationMailConfig_organization_id ON OrganizationMailConfig (organization_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS DeploymentTargetConnectivityCheck
+(
+  id                           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at                   TIMESTAMP DEFAULT current_timestamp,
+  deployment_target_id         UUID UNIQUE NOT NULL REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  requested_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  requested_at                 TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  reported_at                  TIMESTAMP,
+  results                      JSONB
+);
+
+CREATE INDEX IF NOT EXISTS fk_DeploymentTargetConnectivityCheck_deployment_target_id
+  ON DeploymentTargetConnectivityCheck (deployment_target_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ApplicationVersionPromotion;
+DROP TABLE IF EXISTS ApplicationVersionApproval;
+DROP TABLE IF EXISTS ApplicationVersionScan;
+DROP TABLE IF EXISTS ApplicationPromotionRule;
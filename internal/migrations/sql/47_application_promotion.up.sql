@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS ApplicationPromotionRule
+(
+  id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at     TIMESTAMP DEFAULT current_timestamp,
+  application_id UUID  NOT NULL REFERENCES Application (id) ON DELETE CASCADE,
+  channel        TEXT  NOT NULL,
+  checks         JSONB NOT NULL DEFAULT '[]'::jsonb,
+  CONSTRAINT ApplicationPromotionRule_channel_unique UNIQUE (application_id, channel)
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationPromotionRule_application_id ON ApplicationPromotionRule (application_id);
+
+CREATE TABLE IF NOT EXISTS ApplicationVersionScan
+(
+  id                     UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at             TIMESTAMP DEFAULT current_timestamp,
+  application_version_id UUID NOT NULL REFERENCES ApplicationVersion (id) ON DELETE CASCADE,
+  scanner                TEXT,
+  critical               INT  NOT NULL DEFAULT 0,
+  high                   INT  NOT NULL DEFAULT 0,
+  medium                 INT  NOT NULL DEFAULT 0,
+  low                    INT  NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationVersionScan_application_version_id
+  ON ApplicationVersionScan (application_version_id, created_at);
+
+CREATE TABLE IF NOT EXISTS ApplicationVersionApproval
+(
+  id                     UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at             TIMESTAMP DEFAULT current_timestamp,
+  application_version_id UUID      NOT NULL REFERENCES ApplicationVersion (id) ON DELETE CASCADE,
+  user_account_id        UUID      NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  user_role              USER_ROLE NOT NULL,
+  CONSTRAINT ApplicationVersionApproval_user_unique UNIQUE (application_version_id, user_account_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationVersionApproval_application_version_id
+  ON ApplicationVersionApproval (application_version_id);
+
+CREATE TABLE IF NOT EXISTS ApplicationVersionPromotion
+(
+  id                     UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at             TIMESTAMP DEFAULT current_timestamp,
+  application_version_id UUID    NOT NULL REFERENCES ApplicationVersion (id) ON DELETE CASCADE,
+  user_account_id        UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  channel                TEXT    NOT NULL,
+  promoted               BOOLEAN NOT NULL,
+  results                JSONB   NOT NULL DEFAULT '[]'::jsonb
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationVersionPromotion_application_version_id
+  ON ApplicationVersionPromotion (application_version_id, created_at);
@@ -0,0 +1,9 @@
+-- The plaintext keys can not be restored from their hashes, so all existing tokens have to be deleted.
+DELETE FROM AccessToken;
+
+ALTER TABLE AccessToken
+  DROP COLUMN key_hash,
+  DROP COLUMN key_prefix,
+  ADD COLUMN key BYTEA UNIQUE NOT NULL;
+
+CREATE INDEX IF NOT EXISTS AccessToken_key ON AccessToken (key);
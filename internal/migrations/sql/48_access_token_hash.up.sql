@@ -0,0 +1,14 @@
+-- Existing tokens are hashed in place. They keep working, because verification only needs the hash.
+ALTER TABLE AccessToken
+  ADD COLUMN key_hash BYTEA,
+  ADD COLUMN key_prefix TEXT;
+
+UPDATE AccessToken SET key_hash = sha256(key), key_prefix = 'distr-' || left(encode(key, 'hex'), 4);
+
+ALTER TABLE AccessToken
+  ALTER COLUMN key_hash SET NOT NULL,
+  ALTER COLUMN key_prefix SET NOT NULL,
+  ADD CONSTRAINT AccessToken_key_hash_unique UNIQUE (key_hash);
+
+DROP INDEX IF EXISTS AccessToken_key;
+ALTER TABLE AccessToken DROP COLUMN key;
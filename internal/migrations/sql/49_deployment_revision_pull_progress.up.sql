@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS DeploymentRevisionPullProgress
+(
+  deployment_revision_id UUID   NOT NULL REFERENCES DeploymentRevision (id) ON DELETE CASCADE,
+  image                  TEXT   NOT NULL,
+  updated_at             TIMESTAMP DEFAULT current_timestamp,
+  bytes_done             BIGINT NOT NULL,
+  bytes_total            BIGINT NOT NULL,
+  PRIMARY KEY (deployment_revision_id, image)
+);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS Organization_UserAccount_custom_fields;
+ALTER TABLE Organization_UserAccount DROP COLUMN IF EXISTS custom_fields;
+
+DROP INDEX IF EXISTS DeploymentTarget_custom_fields;
+ALTER TABLE DeploymentTarget DROP COLUMN IF EXISTS custom_fields;
+
+DROP TABLE IF EXISTS CustomFieldDefinition CASCADE;
+
+DROP TYPE IF EXISTS CUSTOM_FIELD_TARGET;
+DROP TYPE IF EXISTS CUSTOM_FIELD_TYPE;
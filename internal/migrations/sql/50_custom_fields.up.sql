@@ -0,0 +1,29 @@
+CREATE TYPE CUSTOM_FIELD_TYPE AS ENUM ('string', 'number', 'bool', 'enum');
+CREATE TYPE CUSTOM_FIELD_TARGET AS ENUM ('deployment_target', 'customer');
+
+CREATE TABLE IF NOT EXISTS CustomFieldDefinition
+(
+  id                  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at          TIMESTAMP DEFAULT current_timestamp,
+  organization_id     UUID                NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  target              CUSTOM_FIELD_TARGET NOT NULL,
+  key                 TEXT                NOT NULL,
+  type                CUSTOM_FIELD_TYPE   NOT NULL,
+  required            BOOLEAN             NOT NULL DEFAULT false,
+  enum_values         TEXT[]              NOT NULL DEFAULT '{}',
+  visible_to_customer BOOLEAN             NOT NULL DEFAULT false,
+  CONSTRAINT CustomFieldDefinition_key_unique UNIQUE (organization_id, target, key)
+);
+
+CREATE INDEX IF NOT EXISTS fk_CustomFieldDefinition_organization_id ON CustomFieldDefinition (organization_id);
+
+ALTER TABLE DeploymentTarget
+  ADD COLUMN custom_fields JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX IF NOT EXISTS DeploymentTarget_custom_fields ON DeploymentTarget USING GIN (custom_fields);
+
+ALTER TABLE Organization_UserAccount
+  ADD COLUMN custom_fields JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX IF NOT EXISTS Organization_UserAccount_custom_fields
+  ON Organization_UserAccount USING GIN (custom_fields);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS fk_Artifact_image_id;
+DROP INDEX IF EXISTS fk_Application_image_id;
+DROP INDEX IF EXISTS fk_UserAccount_image_id;
+
+DROP INDEX IF EXISTS File_content_hash;
+
+ALTER TABLE File
+  DROP COLUMN IF EXISTS orphaned_at,
+  DROP COLUMN IF EXISTS content_hash;
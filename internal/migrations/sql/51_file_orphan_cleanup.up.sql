@@ -0,0 +1,13 @@
+ALTER TABLE File
+  ADD COLUMN content_hash BYTEA,
+  ADD COLUMN orphaned_at  TIMESTAMP;
+
+UPDATE File SET content_hash = sha256(data);
+
+ALTER TABLE File ALTER COLUMN content_hash SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS File_content_hash ON File (content_hash);
+
+CREATE INDEX IF NOT EXISTS fk_UserAccount_image_id ON UserAccount (image_id);
+CREATE INDEX IF NOT EXISTS fk_Application_image_id ON Application (image_id);
+CREATE INDEX IF NOT EXISTS fk_Artifact_image_id ON Artifact (image_id);
@@ -0,0 +1,2 @@
+ALTER TABLE Deployment DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE DeploymentTarget DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,2 @@
+ALTER TABLE DeploymentTarget ADD COLUMN archived_at TIMESTAMP;
+ALTER TABLE Deployment ADD COLUMN archived_at TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ApplicationBadge;
+
+ALTER TABLE Organization DROP COLUMN IF EXISTS status_badges_disabled;
@@ -0,0 +1,13 @@
+ALTER TABLE Organization ADD COLUMN status_badges_disabled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS ApplicationBadge
+(
+  application_id     UUID PRIMARY KEY REFERENCES Application (id) ON DELETE CASCADE,
+  created_at         TIMESTAMP DEFAULT current_timestamp,
+  token_hash         BYTEA NOT NULL,
+  token_prefix       TEXT  NOT NULL,
+  latest_version     TEXT,
+  installation_count INT,
+  refreshed_at       TIMESTAMP,
+  CONSTRAINT ApplicationBadge_token_hash_unique UNIQUE (token_hash)
+);
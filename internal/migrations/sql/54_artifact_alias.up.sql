@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS ArtifactAlias
+(
+  id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at      TIMESTAMP DEFAULT current_timestamp,
+  organization_id UUID      NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  artifact_id     UUID      NOT NULL REFERENCES Artifact (id) ON DELETE CASCADE,
+  name            TEXT      NOT NULL,
+  expires_at      TIMESTAMP NOT NULL,
+  CONSTRAINT ArtifactAlias_unique_name UNIQUE (organization_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS fk_ArtifactAlias_artifact_id ON ArtifactAlias (artifact_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ApplicationLicenseSeat;
+
+ALTER TABLE ApplicationLicense DROP COLUMN IF EXISTS seat_count;
@@ -0,0 +1,12 @@
+ALTER TABLE ApplicationLicense
+  ADD COLUMN seat_count INT CONSTRAINT ApplicationLicense_seat_count_non_negative CHECK (seat_count >= 0);
+
+CREATE TABLE IF NOT EXISTS ApplicationLicenseSeat
+(
+  application_license_id UUID NOT NULL REFERENCES ApplicationLicense (id) ON DELETE CASCADE,
+  useraccount_id         UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  created_at             TIMESTAMP DEFAULT current_timestamp,
+  PRIMARY KEY (application_license_id, useraccount_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationLicenseSeat_useraccount_id ON ApplicationLicenseSeat (useraccount_id);
@@ -0,0 +1 @@
+ALTER TABLE DeploymentTarget ADD COLUMN migration_connect_url TEXT;
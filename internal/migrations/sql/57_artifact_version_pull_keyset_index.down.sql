@@ -0,0 +1 @@
+DROP INDEX IF EXISTS ArtifactVersionPull_created_at_id;
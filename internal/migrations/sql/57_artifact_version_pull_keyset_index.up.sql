@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS ArtifactVersionPull_created_at_id ON ArtifactVersionPull (created_at DESC, id DESC);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS AuditLogEntry (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  action TEXT NOT NULL,
+  resource_type TEXT NOT NULL,
+  resource_id UUID NOT NULL,
+  data JSONB
+);
+
+CREATE INDEX IF NOT EXISTS AuditLogEntry_organization_id_created_at
+  ON AuditLogEntry (organization_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS fk_AuditLogEntry_useraccount_id ON AuditLogEntry (useraccount_id);
@@ -0,0 +1 @@
+ALTER TABLE DeploymentTarget DROP COLUMN IF EXISTS reported_agent_platform;
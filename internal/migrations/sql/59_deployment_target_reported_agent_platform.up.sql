@@ -0,0 +1 @@
+ALTER TABLE DeploymentTarget ADD COLUMN IF NOT EXISTS reported_agent_platform TEXT;
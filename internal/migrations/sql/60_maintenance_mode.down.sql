@@ -0,0 +1,4 @@
+DELETE FROM AuditLogEntry WHERE organization_id IS NULL;
+ALTER TABLE AuditLogEntry ALTER COLUMN organization_id SET NOT NULL;
+
+DROP TABLE IF EXISTS MaintenanceMode;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS MaintenanceMode (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID REFERENCES Organization (id) ON DELETE CASCADE,
+  created_by_useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  reason TEXT NOT NULL,
+  retry_after_seconds INT NOT NULL CHECK (retry_after_seconds > 0)
+);
+
+-- at most one server-wide (organization_id IS NULL) and one entry per organization
+CREATE UNIQUE INDEX IF NOT EXISTS MaintenanceMode_organization_id
+  ON MaintenanceMode (organization_id) NULLS NOT DISTINCT;
+CREATE INDEX IF NOT EXISTS fk_MaintenanceMode_created_by_useraccount_id
+  ON MaintenanceMode (created_by_useraccount_id);
+
+-- server-wide maintenance mode is not scoped to an organization
+ALTER TABLE AuditLogEntry ALTER COLUMN organization_id DROP NOT NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE DeploymentRevision DROP COLUMN IF EXISTS reason;
+
+ALTER TABLE DeploymentTarget DROP COLUMN IF EXISTS production;
+
+ALTER TABLE Organization DROP COLUMN IF EXISTS deployment_reason_policy;
+
+DROP TYPE IF EXISTS DEPLOYMENT_REASON_POLICY;
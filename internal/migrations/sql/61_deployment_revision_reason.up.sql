@@ -0,0 +1,8 @@
+CREATE TYPE DEPLOYMENT_REASON_POLICY AS ENUM ('optional', 'production', 'required');
+
+ALTER TABLE Organization
+  ADD COLUMN IF NOT EXISTS deployment_reason_policy DEPLOYMENT_REASON_POLICY NOT NULL DEFAULT 'optional';
+
+ALTER TABLE DeploymentTarget ADD COLUMN IF NOT EXISTS production BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE DeploymentRevision ADD COLUMN IF NOT EXISTS reason TEXT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS UpstreamWatchChange;
+
+DROP TABLE IF EXISTS UpstreamWatch;
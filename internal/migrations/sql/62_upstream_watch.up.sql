@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS UpstreamWatch (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  created_by_useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  reference TEXT NOT NULL,
+  registry TEXT NOT NULL,
+  digest TEXT,
+  etag TEXT,
+  last_checked_at TIMESTAMP,
+  last_error TEXT,
+  CONSTRAINT UpstreamWatch_organization_id_reference UNIQUE (organization_id, reference)
+);
+
+CREATE INDEX IF NOT EXISTS UpstreamWatch_last_checked_at ON UpstreamWatch (last_checked_at NULLS FIRST);
+CREATE INDEX IF NOT EXISTS fk_UpstreamWatch_created_by_useraccount_id ON UpstreamWatch (created_by_useraccount_id);
+
+CREATE TABLE IF NOT EXISTS UpstreamWatchChange (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  upstream_watch_id UUID NOT NULL REFERENCES UpstreamWatch (id) ON DELETE CASCADE,
+  previous_digest TEXT NOT NULL,
+  digest TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS fk_UpstreamWatchChange_upstream_watch_id
+  ON UpstreamWatchChange (upstream_watch_id, created_at DESC);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS DeploymentTargetEndpointCertificate;
+
+DROP TABLE IF EXISTS DeploymentTargetEndpoint;
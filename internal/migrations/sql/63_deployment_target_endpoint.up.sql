@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS DeploymentTargetEndpoint (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  deployment_target_id UUID NOT NULL REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  self_signed_ok BOOLEAN NOT NULL DEFAULT false,
+  last_checked_at TIMESTAMP,
+  last_error TEXT,
+  -- the lowest expiry notification threshold (in days) that has been sent for the current certificate
+  notified_days INT,
+  CONSTRAINT DeploymentTargetEndpoint_deployment_target_id_url UNIQUE (deployment_target_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS DeploymentTargetEndpoint_last_checked_at
+  ON DeploymentTargetEndpoint (last_checked_at NULLS FIRST);
+
+-- every certificate that has been seen on an endpoint, a new row is only created when the certificate is rotated
+CREATE TABLE IF NOT EXISTS DeploymentTargetEndpointCertificate (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  deployment_target_endpoint_id UUID NOT NULL REFERENCES DeploymentTargetEndpoint (id) ON DELETE CASCADE,
+  last_seen_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  fingerprint TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  issuer TEXT NOT NULL,
+  not_after TIMESTAMP NOT NULL,
+  chain_valid BOOLEAN NOT NULL,
+  chain_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS fk_DeploymentTargetEndpointCertificate_deployment_target_endpoint_id
+  ON DeploymentTargetEndpointCertificate (deployment_target_endpoint_id, created_at DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE DeploymentLogRecord DROP COLUMN IF EXISTS operation_id;
+ALTER TABLE DeploymentRevisionStatus DROP COLUMN IF EXISTS operation_id;
+ALTER TABLE DeploymentRevision DROP COLUMN IF EXISTS operation_id;
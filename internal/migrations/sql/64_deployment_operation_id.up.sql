@@ -0,0 +1,5 @@
+ALTER TABLE DeploymentRevision ADD COLUMN IF NOT EXISTS operation_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+-- operation_id is NULL for reports of agents that do not echo the operation ID
+ALTER TABLE DeploymentRevisionStatus ADD COLUMN IF NOT EXISTS operation_id UUID;
+ALTER TABLE DeploymentLogRecord ADD COLUMN IF NOT EXISTS operation_id UUID;
@@ -0,0 +1,3 @@
+ALTER TABLE Organization
+  DROP COLUMN IF EXISTS business_hours,
+  DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,3 @@
+ALTER TABLE Organization
+  ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC',
+  ADD COLUMN IF NOT EXISTS business_hours JSONB;
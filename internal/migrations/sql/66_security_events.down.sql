@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS SecurityEvent;
+
+ALTER TABLE UserAccount
+  DROP COLUMN IF EXISTS security_event_mails_disabled,
+  DROP COLUMN IF EXISTS failed_login_attempts;
+
+DROP TYPE IF EXISTS SECURITY_EVENT_TYPE;
@@ -0,0 +1,21 @@
+CREATE TYPE SECURITY_EVENT_TYPE AS ENUM (
+  'login', 'new_device_login', 'failed_login_attempts', 'password_changed', 'access_token_created'
+);
+
+ALTER TABLE UserAccount
+  ADD COLUMN IF NOT EXISTS failed_login_attempts INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS security_event_mails_disabled SECURITY_EVENT_TYPE[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS SecurityEvent (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  useraccount_id UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  type SECURITY_EVENT_TYPE NOT NULL,
+  ip_address TEXT,
+  user_agent TEXT,
+  country TEXT,
+  details TEXT,
+  mail_sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS fk_SecurityEvent_useraccount_id ON SecurityEvent (useraccount_id, created_at DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE DeploymentTarget
+  DROP COLUMN IF EXISTS clock_skew_measured_at,
+  DROP COLUMN IF EXISTS clock_skew_ms;
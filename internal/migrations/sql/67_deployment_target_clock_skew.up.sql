@@ -0,0 +1,3 @@
+ALTER TABLE DeploymentTarget
+  ADD COLUMN IF NOT EXISTS clock_skew_ms BIGINT,
+  ADD COLUMN IF NOT EXISTS clock_skew_measured_at TIMESTAMP;
@@ -0,0 +1,8 @@
+ALTER TABLE DeploymentTargetMetrics
+  DROP COLUMN IF EXISTS disk_bytes;
+
+ALTER TABLE ApplicationVersion
+  DROP COLUMN IF EXISTS resource_requirements;
+
+ALTER TABLE Application
+  DROP COLUMN IF EXISTS resource_requirements;
@@ -0,0 +1,8 @@
+ALTER TABLE Application
+  ADD COLUMN IF NOT EXISTS resource_requirements JSONB;
+
+ALTER TABLE ApplicationVersion
+  ADD COLUMN IF NOT EXISTS resource_requirements JSONB;
+
+ALTER TABLE DeploymentTargetMetrics
+  ADD COLUMN IF NOT EXISTS disk_bytes BIGINT;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS DeploymentAppMetricAlert;
+
+DROP TABLE IF EXISTS ApplicationMetricAlertRule;
+
+DROP TYPE IF EXISTS APP_METRIC_ALERT_OPERATOR;
+
+DROP TABLE IF EXISTS DeploymentAppMetric;
+
+ALTER TABLE ApplicationVersion
+  DROP COLUMN IF EXISTS metrics_endpoint;
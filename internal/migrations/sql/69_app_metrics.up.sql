@@ -0,0 +1,40 @@
+ALTER TABLE ApplicationVersion
+  ADD COLUMN IF NOT EXISTS metrics_endpoint JSONB;
+
+CREATE TABLE IF NOT EXISTS DeploymentAppMetric
+(
+  deployment_id UUID             NOT NULL REFERENCES Deployment (id) ON DELETE CASCADE,
+  name          TEXT             NOT NULL,
+  labels        JSONB            NOT NULL DEFAULT '{}'::jsonb,
+  value         DOUBLE PRECISION NOT NULL,
+  updated_at    TIMESTAMP        NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS fk_DeploymentAppMetric_deployment_id ON DeploymentAppMetric (deployment_id, name);
+
+CREATE TYPE APP_METRIC_ALERT_OPERATOR AS ENUM ('gt', 'gte', 'lt', 'lte', 'eq', 'ne');
+
+CREATE TABLE IF NOT EXISTS ApplicationMetricAlertRule
+(
+  id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at     TIMESTAMP                 NOT NULL DEFAULT current_timestamp,
+  application_id UUID                      NOT NULL REFERENCES Application (id) ON DELETE CASCADE,
+  metric         TEXT                      NOT NULL,
+  operator       APP_METRIC_ALERT_OPERATOR NOT NULL,
+  value          DOUBLE PRECISION          NOT NULL,
+  for_seconds    INT                       NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationMetricAlertRule_application_id
+  ON ApplicationMetricAlertRule (application_id);
+
+CREATE TABLE IF NOT EXISTS DeploymentAppMetricAlert
+(
+  application_metric_alert_rule_id UUID NOT NULL REFERENCES ApplicationMetricAlertRule (id) ON DELETE CASCADE,
+  deployment_id                    UUID NOT NULL REFERENCES Deployment (id) ON DELETE CASCADE,
+  pending_since                    TIMESTAMP,
+  firing_since                     TIMESTAMP,
+  PRIMARY KEY (application_metric_alert_rule_id, deployment_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_DeploymentAppMetricAlert_deployment_id ON DeploymentAppMetricAlert (deployment_id);
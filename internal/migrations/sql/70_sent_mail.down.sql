@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS SentMail;
+
+DROP TYPE IF EXISTS SENT_MAIL_STATUS;
+
+DROP TYPE IF EXISTS MAIL_TYPE;
@@ -0,0 +1,22 @@
+CREATE TYPE MAIL_TYPE AS ENUM (
+  'other', 'invite_user', 'invite_customer', 'verify_email', 'password_reset', 'security_event',
+  'upstream_watch_changed', 'certificate_expiring', 'app_metric_alert_firing', 'organization_mail_config_disabled'
+);
+
+CREATE TYPE SENT_MAIL_STATUS AS ENUM ('sent', 'failed', 'delivered', 'bounced', 'complained');
+
+CREATE TABLE IF NOT EXISTS SentMail (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID REFERENCES Organization (id) ON DELETE CASCADE,
+  recipient TEXT NOT NULL,
+  type MAIL_TYPE NOT NULL,
+  subject TEXT NOT NULL,
+  provider_message_id TEXT,
+  status SENT_MAIL_STATUS NOT NULL,
+  status_details TEXT,
+  status_updated_at TIMESTAMP NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS fk_SentMail_organization_id ON SentMail (organization_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS SentMail_provider_message_id ON SentMail (provider_message_id);
@@ -0,0 +1,8 @@
+-- enum values can not be removed from MAIL_TYPE, so artifact_deletion_requested is kept
+
+DROP INDEX IF EXISTS Artifact_deletion_scheduled_at;
+
+ALTER TABLE Artifact
+  DROP COLUMN IF EXISTS deletion_requested_at,
+  DROP COLUMN IF EXISTS deletion_requested_by_useraccount_id,
+  DROP COLUMN IF EXISTS deletion_scheduled_at;
@@ -0,0 +1,9 @@
+ALTER TABLE Artifact
+  ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS deletion_requested_by_useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS Artifact_deletion_scheduled_at ON Artifact (deletion_scheduled_at)
+  WHERE deletion_scheduled_at IS NOT NULL;
+
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'artifact_deletion_requested';
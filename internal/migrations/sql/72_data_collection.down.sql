@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS DeploymentTargetDataPurge;
+
+ALTER TABLE DeploymentTarget
+  DROP COLUMN IF EXISTS vendor_logs_disabled,
+  DROP COLUMN IF EXISTS vendor_metrics_disabled,
+  DROP COLUMN IF EXISTS vendor_diagnostics_disabled,
+  DROP COLUMN IF EXISTS customer_logs_disabled,
+  DROP COLUMN IF EXISTS customer_metrics_disabled,
+  DROP COLUMN IF EXISTS customer_diagnostics_disabled;
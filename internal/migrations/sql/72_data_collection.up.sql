@@ -0,0 +1,24 @@
+ALTER TABLE DeploymentTarget
+  ADD COLUMN IF NOT EXISTS vendor_logs_disabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS vendor_metrics_disabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS vendor_diagnostics_disabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS customer_logs_disabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS customer_metrics_disabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS customer_diagnostics_disabled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS DeploymentTargetDataPurge (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  deployment_target_id UUID NOT NULL REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  requested_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  logs BOOLEAN NOT NULL,
+  metrics BOOLEAN NOT NULL,
+  diagnostics BOOLEAN NOT NULL,
+  completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS DeploymentTargetDataPurge_deployment_target_id
+  ON DeploymentTargetDataPurge (deployment_target_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS DeploymentTargetDataPurge_pending
+  ON DeploymentTargetDataPurge (created_at) WHERE completed_at IS NULL;
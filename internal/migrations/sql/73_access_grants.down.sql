@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS AuditLogEntry_access_grant_id;
+
+ALTER TABLE AuditLogEntry DROP COLUMN IF EXISTS access_grant_id;
+
+DROP TABLE IF EXISTS AccessGrant;
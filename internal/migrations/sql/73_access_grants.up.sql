@@ -0,0 +1,29 @@
+ALTER TYPE FEATURE ADD VALUE IF NOT EXISTS 'access_grants';
+
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'access_grant_created';
+
+CREATE TABLE IF NOT EXISTS AccessGrant (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  user_account_id UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  deployment_target_id UUID REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  customer_user_account_id UUID REFERENCES UserAccount (id) ON DELETE CASCADE,
+  granted_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  reason TEXT NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  revoked_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  CONSTRAINT AccessGrant_scope CHECK ((deployment_target_id IS NULL) <> (customer_user_account_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS AccessGrant_organization_id ON AccessGrant (organization_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS AccessGrant_user_account_id ON AccessGrant (user_account_id, expires_at);
+CREATE INDEX IF NOT EXISTS fk_AccessGrant_deployment_target_id ON AccessGrant (deployment_target_id);
+CREATE INDEX IF NOT EXISTS fk_AccessGrant_customer_user_account_id ON AccessGrant (customer_user_account_id);
+
+-- no foreign key, so that the audit log keeps the reference after the grant has been deleted
+ALTER TABLE AuditLogEntry ADD COLUMN IF NOT EXISTS access_grant_id UUID;
+
+CREATE INDEX IF NOT EXISTS AuditLogEntry_access_grant_id ON AuditLogEntry (access_grant_id)
+  WHERE access_grant_id IS NOT NULL;
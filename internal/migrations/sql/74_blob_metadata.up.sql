@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS BlobMetadata (
+  digest TEXT PRIMARY KEY, --- "sha256:..."
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  size BIGINT NOT NULL,
+  content_type TEXT NOT NULL
+);
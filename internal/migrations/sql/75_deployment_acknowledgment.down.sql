@@ -0,0 +1,13 @@
+-- enum values can not be removed from MAIL_TYPE, so deployment_acknowledgment_required is kept
+
+DROP INDEX IF EXISTS fk_DeploymentRevision_acknowledged_by_user_account_id;
+DROP INDEX IF EXISTS DeploymentRevision_pending_acknowledgment;
+
+ALTER TABLE DeploymentRevision
+  DROP COLUMN IF EXISTS acknowledgment_reminded_at,
+  DROP COLUMN IF EXISTS acknowledgment_overridden,
+  DROP COLUMN IF EXISTS acknowledged_by_user_account_id,
+  DROP COLUMN IF EXISTS acknowledged_at,
+  DROP COLUMN IF EXISTS acknowledgment_required;
+
+ALTER TABLE ApplicationVersion DROP COLUMN IF EXISTS acknowledgment_message;
@@ -0,0 +1,15 @@
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'deployment_acknowledgment_required';
+
+ALTER TABLE ApplicationVersion ADD COLUMN IF NOT EXISTS acknowledgment_message TEXT;
+
+ALTER TABLE DeploymentRevision
+  ADD COLUMN IF NOT EXISTS acknowledgment_required BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS acknowledged_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS acknowledgment_overridden BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS acknowledgment_reminded_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS DeploymentRevision_pending_acknowledgment ON DeploymentRevision (deployment_id, created_at)
+  WHERE acknowledgment_required AND acknowledged_at IS NULL;
+CREATE INDEX IF NOT EXISTS fk_DeploymentRevision_acknowledged_by_user_account_id
+  ON DeploymentRevision (acknowledged_by_user_account_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS OrganizationAggregate (
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  data JSONB,
+  computed_at TIMESTAMP,
+  refresh_requested_at TIMESTAMP,
+  PRIMARY KEY (organization_id, name)
+);
+
+-- every hour in which a deployment target reported its status at least once, used to compute the uptime incrementally
+-- because older status entries are deleted by the cleanup job
+CREATE TABLE IF NOT EXISTS DeploymentTargetUptimeHour (
+  deployment_target_id UUID NOT NULL REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  hour TIMESTAMP NOT NULL,
+  PRIMARY KEY (deployment_target_id, hour)
+);
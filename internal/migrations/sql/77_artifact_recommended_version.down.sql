@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS fk_Artifact_recommended_artifact_version_id;
+
+ALTER TABLE Artifact DROP COLUMN IF EXISTS recommended_artifact_version_id;
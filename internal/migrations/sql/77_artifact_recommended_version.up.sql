@@ -0,0 +1,5 @@
+-- versions are only deleted together with their artifact, but the pointer is cleared in case that ever changes
+ALTER TABLE Artifact
+  ADD COLUMN IF NOT EXISTS recommended_artifact_version_id UUID REFERENCES ArtifactVersion (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS fk_Artifact_recommended_artifact_version_id ON Artifact (recommended_artifact_version_id);
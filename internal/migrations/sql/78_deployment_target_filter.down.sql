@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS DeploymentRevision_deployment_id_created_at;
+DROP INDEX IF EXISTS ApplicationVersion_application_id_version_sort_key;
+
+ALTER TABLE ApplicationVersion DROP COLUMN IF EXISTS version_sort_key;
+
+DROP FUNCTION IF EXISTS semver_sort_key(TEXT);
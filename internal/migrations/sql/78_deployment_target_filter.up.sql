@@ -0,0 +1,21 @@
+-- semver_sort_key converts a semantic version into a string that sorts like the version with the C collation. Each
+-- numeric part is zero-padded and releases sort after their pre-releases. Pre-release identifiers are compared as
+-- text and build metadata is ignored. NULL is returned for names that are not semantic versions.
+CREATE OR REPLACE FUNCTION semver_sort_key(name TEXT) RETURNS TEXT
+  LANGUAGE SQL IMMUTABLE PARALLEL SAFE
+  AS $$
+    SELECT lpad(m[1], 10, '0') || '.' || lpad(coalesce(m[2], '0'), 10, '0') || '.' ||
+      lpad(coalesce(m[3], '0'), 10, '0') || coalesce('-' || m[4], '~')
+    FROM regexp_match(
+      name, '^v?(\d{1,10})(?:\.(\d{1,10}))?(?:\.(\d{1,10}))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$'
+    ) m
+  $$;
+
+ALTER TABLE ApplicationVersion
+  ADD COLUMN IF NOT EXISTS version_sort_key TEXT COLLATE "C" GENERATED ALWAYS AS (semver_sort_key(name)) STORED;
+
+CREATE INDEX IF NOT EXISTS ApplicationVersion_application_id_version_sort_key
+  ON ApplicationVersion (application_id, version_sort_key);
+
+-- finding the latest revision of each deployment while filtering deployment targets
+CREATE INDEX IF NOT EXISTS DeploymentRevision_deployment_id_created_at ON DeploymentRevision (deployment_id, created_at DESC);
@@ -0,0 +1,26 @@
+DROP TABLE IF EXISTS DeploymentDependency;
+
+DROP INDEX IF EXISTS Deployment_release_name_unique;
+DELETE FROM Deployment WHERE uninstalled_at IS NOT NULL;
+ALTER TABLE Deployment ADD CONSTRAINT release_name_unique UNIQUE (deployment_target_id, release_name);
+
+ALTER TABLE Deployment
+  DROP COLUMN IF EXISTS uninstall_requested_at,
+  DROP COLUMN IF EXISTS uninstall_requested_by_user_account_id,
+  DROP COLUMN IF EXISTS uninstall_delete_data,
+  DROP COLUMN IF EXISTS uninstalled_at;
+
+-- Credits: https://stackoverflow.com/a/25812436
+ALTER TYPE DEPLOYMENT_STATUS_TYPE RENAME TO DEPLOYMENT_STATUS_TYPE_OLD;
+
+CREATE TYPE DEPLOYMENT_STATUS_TYPE AS ENUM ('ok', 'error', 'progressing');
+
+ALTER TABLE DeploymentRevisionStatus
+  ALTER COLUMN type TYPE DEPLOYMENT_STATUS_TYPE
+    USING (CASE WHEN type::text = 'uninstalled' THEN 'ok' ELSE type::text END::DEPLOYMENT_STATUS_TYPE);
+
+DROP TYPE DEPLOYMENT_STATUS_TYPE_OLD;
+
+ALTER TABLE Organization DROP COLUMN IF EXISTS deployment_uninstall_policy;
+
+DROP TYPE IF EXISTS DEPLOYMENT_UNINSTALL_POLICY;
@@ -0,0 +1,29 @@
+CREATE TYPE DEPLOYMENT_UNINSTALL_POLICY AS ENUM ('vendor', 'vendor_and_customer');
+
+ALTER TABLE Organization
+  ADD COLUMN IF NOT EXISTS deployment_uninstall_policy DEPLOYMENT_UNINSTALL_POLICY NOT NULL DEFAULT 'vendor_and_customer';
+
+ALTER TYPE DEPLOYMENT_STATUS_TYPE ADD VALUE IF NOT EXISTS 'uninstalled';
+
+ALTER TABLE Deployment
+  ADD COLUMN IF NOT EXISTS uninstall_requested_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS uninstall_requested_by_user_account_id UUID
+    REFERENCES UserAccount (id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS uninstall_delete_data BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS uninstalled_at TIMESTAMP;
+
+-- an uninstalled deployment is kept in the history, so its release name can be used again
+ALTER TABLE Deployment DROP CONSTRAINT IF EXISTS release_name_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS Deployment_release_name_unique
+  ON Deployment (deployment_target_id, release_name) WHERE uninstalled_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS DeploymentDependency (
+  deployment_id UUID NOT NULL REFERENCES Deployment (id) ON DELETE CASCADE,
+  depends_on_deployment_id UUID NOT NULL REFERENCES Deployment (id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (deployment_id, depends_on_deployment_id),
+  CHECK (deployment_id <> depends_on_deployment_id)
+);
+
+CREATE INDEX IF NOT EXISTS DeploymentDependency_depends_on_deployment_id
+  ON DeploymentDependency (depends_on_deployment_id);
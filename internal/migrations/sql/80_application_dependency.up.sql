@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS ApplicationDependency (
+  application_id UUID NOT NULL REFERENCES Application (id) ON DELETE CASCADE,
+  depends_on_application_id UUID NOT NULL REFERENCES Application (id) ON DELETE CASCADE,
+  -- semantic version, versions of the dependency that are lower or not semantic versions do not satisfy it
+  min_version TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (application_id, depends_on_application_id),
+  CHECK (application_id <> depends_on_application_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_ApplicationDependency_depends_on_application_id
+  ON ApplicationDependency (depends_on_application_id);
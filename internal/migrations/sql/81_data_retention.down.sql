@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS LegalHold;
+
+DROP TABLE IF EXISTS DataRetentionPolicy;
+
+DROP TYPE IF EXISTS DATA_RETENTION_CATEGORY;
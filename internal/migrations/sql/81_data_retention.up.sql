@@ -0,0 +1,25 @@
+CREATE TYPE DATA_RETENTION_CATEGORY AS ENUM ('artifact_audit', 'admin_audit', 'deployment_logs', 'security_events');
+
+-- data of a category without a policy is kept until it is deleted by one of the other cleanup jobs
+CREATE TABLE IF NOT EXISTS DataRetentionPolicy (
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  category DATA_RETENTION_CATEGORY NOT NULL,
+  retention_days INT NOT NULL CHECK (retention_days > 0),
+  updated_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (organization_id, category)
+);
+
+-- a hold without user_account_id covers the whole organization, otherwise only the data of one customer account
+CREATE TABLE IF NOT EXISTS LegalHold (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  user_account_id UUID REFERENCES UserAccount (id) ON DELETE CASCADE,
+  reason TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS LegalHold_organization_id_unique
+  ON LegalHold (organization_id) WHERE user_account_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS LegalHold_organization_id_user_account_id_unique
+  ON LegalHold (organization_id, user_account_id) WHERE user_account_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS fk_LegalHold_user_account_id ON LegalHold (user_account_id);
@@ -0,0 +1,3 @@
+ALTER TABLE Application DROP COLUMN IF EXISTS version_policy;
+
+DROP TYPE IF EXISTS APPLICATION_VERSION_POLICY;
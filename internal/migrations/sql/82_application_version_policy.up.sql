@@ -0,0 +1,4 @@
+CREATE TYPE APPLICATION_VERSION_POLICY AS ENUM ('lenient', 'warn', 'strict');
+
+ALTER TABLE Application
+  ADD COLUMN IF NOT EXISTS version_policy APPLICATION_VERSION_POLICY NOT NULL DEFAULT 'lenient';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS Application_deletion_scheduled_at;
+
+ALTER TABLE Application
+  DROP COLUMN IF EXISTS deleted_at,
+  DROP COLUMN IF EXISTS deleted_by_useraccount_id,
+  DROP COLUMN IF EXISTS deletion_scheduled_at;
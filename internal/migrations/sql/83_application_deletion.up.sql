@@ -0,0 +1,7 @@
+ALTER TABLE Application
+  ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS deleted_by_useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS Application_deletion_scheduled_at ON Application (deletion_scheduled_at)
+  WHERE deletion_scheduled_at IS NOT NULL;
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS AnnouncementReceipt;
+
+DROP TABLE IF EXISTS Announcement_DeploymentTarget;
+
+DROP TABLE IF EXISTS Announcement_Customer;
+
+DROP TABLE IF EXISTS Announcement;
+
+DROP TYPE IF EXISTS ANNOUNCEMENT_AUDIENCE;
+
+DROP TYPE IF EXISTS ANNOUNCEMENT_SEVERITY;
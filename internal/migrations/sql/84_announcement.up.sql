@@ -0,0 +1,47 @@
+CREATE TYPE ANNOUNCEMENT_SEVERITY AS ENUM ('info', 'warning', 'critical');
+CREATE TYPE ANNOUNCEMENT_AUDIENCE AS ENUM ('all_customers', 'customers', 'deployment_targets');
+
+CREATE TABLE IF NOT EXISTS Announcement (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  created_by_useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  title TEXT NOT NULL,
+  body TEXT NOT NULL,
+  severity ANNOUNCEMENT_SEVERITY NOT NULL,
+  audience ANNOUNCEMENT_AUDIENCE NOT NULL,
+  starts_at TIMESTAMP NOT NULL,
+  ends_at TIMESTAMP NOT NULL,
+  CHECK (starts_at < ends_at)
+);
+
+CREATE INDEX IF NOT EXISTS fk_Announcement_organization_id ON Announcement (organization_id, starts_at);
+CREATE INDEX IF NOT EXISTS fk_Announcement_created_by_useraccount_id ON Announcement (created_by_useraccount_id);
+CREATE INDEX IF NOT EXISTS Announcement_ends_at ON Announcement (ends_at);
+
+CREATE TABLE IF NOT EXISTS Announcement_Customer (
+  announcement_id UUID NOT NULL REFERENCES Announcement (id) ON DELETE CASCADE,
+  useraccount_id UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  PRIMARY KEY (announcement_id, useraccount_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_Announcement_Customer_useraccount_id ON Announcement_Customer (useraccount_id);
+
+CREATE TABLE IF NOT EXISTS Announcement_DeploymentTarget (
+  announcement_id UUID NOT NULL REFERENCES Announcement (id) ON DELETE CASCADE,
+  deployment_target_id UUID NOT NULL REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  PRIMARY KEY (announcement_id, deployment_target_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_Announcement_DeploymentTarget_deployment_target_id
+  ON Announcement_DeploymentTarget (deployment_target_id);
+
+CREATE TABLE IF NOT EXISTS AnnouncementReceipt (
+  announcement_id UUID NOT NULL REFERENCES Announcement (id) ON DELETE CASCADE,
+  useraccount_id UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  read_at TIMESTAMP,
+  dismissed_at TIMESTAMP,
+  PRIMARY KEY (announcement_id, useraccount_id)
+);
+
+CREATE INDEX IF NOT EXISTS fk_AnnouncementReceipt_useraccount_id ON AnnouncementReceipt (useraccount_id);
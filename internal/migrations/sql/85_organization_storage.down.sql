@@ -0,0 +1,4 @@
+-- enum values can not be removed from MAIL_TYPE, so organization_storage_failing is kept
+
+DROP TABLE IF EXISTS OrganizationBlob;
+DROP TABLE IF EXISTS OrganizationStorage;
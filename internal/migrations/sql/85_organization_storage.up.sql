@@ -0,0 +1,35 @@
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'organization_storage_failing';
+
+CREATE TABLE IF NOT EXISTS OrganizationStorage
+(
+  id                         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at                 TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id            UUID UNIQUE NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  updated_at                 TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  updated_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  bucket                     TEXT      NOT NULL,
+  region                     TEXT      NOT NULL,
+  endpoint                   TEXT,
+  use_path_style             BOOLEAN   NOT NULL DEFAULT false,
+  allow_redirect             BOOLEAN   NOT NULL DEFAULT true,
+  access_key_id              TEXT      NOT NULL,
+  secret_access_key          TEXT      NOT NULL,
+  migrate_existing_blobs     BOOLEAN   NOT NULL DEFAULT false,
+  quota_enabled              BOOLEAN   NOT NULL DEFAULT false,
+  verified_at                TIMESTAMP NOT NULL,
+  failure_count              INT       NOT NULL DEFAULT 0,
+  last_failure_at            TIMESTAMP,
+  last_failure_message       TEXT,
+  alerted_at                 TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS fk_OrganizationStorage_organization_id ON OrganizationStorage (organization_id);
+
+-- blobs of an organization that are stored in its own bucket instead of the platform bucket
+CREATE TABLE IF NOT EXISTS OrganizationBlob
+(
+  organization_id UUID      NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  digest          TEXT      NOT NULL, --- "sha256:..."
+  created_at      TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (organization_id, digest)
+);
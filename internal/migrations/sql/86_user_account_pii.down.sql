@@ -0,0 +1,7 @@
+-- encrypted emails and names are not decrypted, so this must only be rolled back if encryption was never enabled
+DROP INDEX IF EXISTS UserAccount_pii_key_version;
+
+ALTER TABLE UserAccount
+  DROP COLUMN pii_organization_id,
+  DROP COLUMN pii_key_version,
+  DROP COLUMN email_blind_index;
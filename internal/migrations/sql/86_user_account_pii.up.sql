@@ -0,0 +1,7 @@
+ALTER TABLE UserAccount
+  ADD COLUMN email_blind_index TEXT UNIQUE,
+  ADD COLUMN pii_key_version INT,
+  ADD COLUMN pii_organization_id UUID;
+
+-- finds the accounts that still have to be encrypted or re-encrypted with the active key
+CREATE INDEX IF NOT EXISTS UserAccount_pii_key_version ON UserAccount (pii_key_version);
@@ -0,0 +1,11 @@
+-- enum values can not be removed from MAIL_TYPE, so deployment_auto_rollback is kept
+
+ALTER TABLE DeploymentRevision
+  DROP COLUMN IF EXISTS automatic_rollback_of_revision_id,
+  DROP COLUMN IF EXISTS healthy_at;
+
+ALTER TABLE Deployment
+  DROP COLUMN IF EXISTS auto_rollback_window_seconds,
+  DROP COLUMN IF EXISTS auto_rollback_enabled;
+
+ALTER TABLE Organization DROP COLUMN IF EXISTS deployment_auto_rollback_window_seconds;
@@ -0,0 +1,23 @@
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'deployment_auto_rollback';
+
+-- NULL disables automatic rollback for all deployments of the organization that do not enable it themselves
+ALTER TABLE Organization
+  ADD COLUMN IF NOT EXISTS deployment_auto_rollback_window_seconds INT
+    CHECK (deployment_auto_rollback_window_seconds >= 0);
+
+-- NULL inherits the setting of the organization
+ALTER TABLE Deployment
+  ADD COLUMN IF NOT EXISTS auto_rollback_enabled BOOLEAN,
+  ADD COLUMN IF NOT EXISTS auto_rollback_window_seconds INT CHECK (auto_rollback_window_seconds >= 0);
+
+-- a failed revision is rolled back automatically at most once, so the reference is unique
+ALTER TABLE DeploymentRevision
+  ADD COLUMN IF NOT EXISTS healthy_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS automatic_rollback_of_revision_id UUID UNIQUE
+    REFERENCES DeploymentRevision (id) ON DELETE SET NULL;
+
+UPDATE DeploymentRevision dr
+SET healthy_at = (
+  SELECT min(drs.created_at) FROM DeploymentRevisionStatus drs
+  WHERE drs.deployment_revision_id = dr.id AND drs.type = 'ok'
+);
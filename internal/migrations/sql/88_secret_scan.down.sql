@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS SecretScanFinding;
+DROP TABLE IF EXISTS SecretScanDismissal;
+DROP TABLE IF EXISTS SecretScanRule;
+
+ALTER TABLE Organization DROP COLUMN IF EXISTS secret_scan_policy;
+
+DROP TYPE IF EXISTS SECRET_SCAN_POLICY;
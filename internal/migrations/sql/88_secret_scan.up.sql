@@ -0,0 +1,45 @@
+CREATE TYPE SECRET_SCAN_POLICY AS ENUM ('block', 'warn');
+
+ALTER TABLE Organization
+  ADD COLUMN IF NOT EXISTS secret_scan_policy SECRET_SCAN_POLICY NOT NULL DEFAULT 'block';
+
+CREATE TABLE IF NOT EXISTS SecretScanRule (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  rule_id TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  pattern TEXT NOT NULL,
+  CONSTRAINT SecretScanRule_rule_id_unique UNIQUE (organization_id, rule_id)
+);
+
+-- a dismissal suppresses all findings with the same fingerprint, i.e. the same secret found by the same rule
+CREATE TABLE IF NOT EXISTS SecretScanDismissal (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  created_by_useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  fingerprint TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  CONSTRAINT SecretScanDismissal_fingerprint_unique UNIQUE (organization_id, fingerprint)
+);
+
+CREATE TABLE IF NOT EXISTS SecretScanFinding (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  useraccount_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  source TEXT NOT NULL,
+  file TEXT NOT NULL,
+  line INT NOT NULL,
+  rule_id TEXT NOT NULL,
+  redacted TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  -- blocked is false if the upload was accepted with a warning
+  blocked BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS fk_SecretScanFinding_organization_id ON SecretScanFinding (organization_id, created_at);
+CREATE INDEX IF NOT EXISTS fk_SecretScanFinding_useraccount_id ON SecretScanFinding (useraccount_id);
+CREATE INDEX IF NOT EXISTS fk_SecretScanDismissal_created_by_useraccount_id
+  ON SecretScanDismissal (created_by_useraccount_id);
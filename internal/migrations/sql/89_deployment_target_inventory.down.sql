@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS DeploymentTargetInventory;
+
+ALTER TABLE DeploymentTargetDataPurge DROP COLUMN IF EXISTS inventory;
+
+ALTER TABLE DeploymentTarget
+  DROP COLUMN IF EXISTS inventory_enabled,
+  DROP COLUMN IF EXISTS vendor_inventory_disabled,
+  DROP COLUMN IF EXISTS customer_inventory_disabled;
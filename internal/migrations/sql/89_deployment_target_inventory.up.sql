@@ -0,0 +1,18 @@
+-- inventory reports are opt-in, in addition to the data collection settings of vendor and customer
+ALTER TABLE DeploymentTarget
+  ADD COLUMN IF NOT EXISTS inventory_enabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS vendor_inventory_disabled BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS customer_inventory_disabled BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE DeploymentTargetDataPurge
+  ADD COLUMN IF NOT EXISTS inventory BOOLEAN NOT NULL DEFAULT false;
+
+-- only the latest snapshot is kept, together with its difference to the previous one
+CREATE TABLE IF NOT EXISTS DeploymentTargetInventory (
+  deployment_target_id UUID PRIMARY KEY REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  reported_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  previous_reported_at TIMESTAMP,
+  items JSONB NOT NULL,
+  truncated BOOLEAN NOT NULL DEFAULT false,
+  diff JSONB
+);
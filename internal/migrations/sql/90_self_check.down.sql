@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS OrganizationBlob_digest;
+DROP INDEX IF EXISTS BlobMetadata_created_at;
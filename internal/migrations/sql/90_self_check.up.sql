@@ -0,0 +1,3 @@
+-- the startup self-check samples the most recently recorded blobs of the platform bucket
+CREATE INDEX IF NOT EXISTS BlobMetadata_created_at ON BlobMetadata (created_at);
+CREATE INDEX IF NOT EXISTS OrganizationBlob_digest ON OrganizationBlob (digest);
@@ -0,0 +1,5 @@
+ALTER TABLE DeploymentTarget
+  DROP COLUMN IF EXISTS agent_resource_limits,
+  DROP COLUMN IF EXISTS applied_agent_resource_limits;
+
+ALTER TABLE Organization DROP COLUMN IF EXISTS agent_resource_limits;
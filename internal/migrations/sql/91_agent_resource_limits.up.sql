@@ -0,0 +1,8 @@
+-- limits are stored as JSON objects without the limits that are not set, so that the override of a deployment target
+-- can be merged with the defaults of its organization using the || operator
+ALTER TABLE Organization
+  ADD COLUMN IF NOT EXISTS agent_resource_limits JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE DeploymentTarget
+  ADD COLUMN IF NOT EXISTS agent_resource_limits JSONB NOT NULL DEFAULT '{}',
+  ADD COLUMN IF NOT EXISTS applied_agent_resource_limits JSONB;
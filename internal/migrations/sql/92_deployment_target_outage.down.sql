@@ -0,0 +1,4 @@
+-- enum values can not be removed from MAIL_TYPE, so deployment_target_outage_suspected and
+-- deployment_target_outage_resolved are kept
+
+DROP TABLE IF EXISTS DeploymentTargetOutage;
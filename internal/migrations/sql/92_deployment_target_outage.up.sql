@@ -0,0 +1,22 @@
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'deployment_target_outage_suspected';
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'deployment_target_outage_resolved';
+
+-- an outage without customer_user_account_id affects the deployment targets of the whole organization
+CREATE TABLE IF NOT EXISTS DeploymentTargetOutage (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  customer_user_account_id UUID REFERENCES UserAccount (id) ON DELETE CASCADE,
+  target_count INT NOT NULL,
+  affected_deployment_target_ids UUID[] NOT NULL,
+  resolved_at TIMESTAMP
+);
+
+-- there is at most one open outage per organization and customer
+CREATE UNIQUE INDEX IF NOT EXISTS DeploymentTargetOutage_organization_id_open
+  ON DeploymentTargetOutage (organization_id) WHERE customer_user_account_id IS NULL AND resolved_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS DeploymentTargetOutage_organization_id_customer_user_account_id_open
+  ON DeploymentTargetOutage (organization_id, customer_user_account_id)
+  WHERE customer_user_account_id IS NOT NULL AND resolved_at IS NULL;
+CREATE INDEX IF NOT EXISTS fk_DeploymentTargetOutage_customer_user_account_id
+  ON DeploymentTargetOutage (customer_user_account_id);
@@ -0,0 +1,3 @@
+-- enum values can not be removed from MAIL_TYPE, so customer_data_export_ready is kept
+
+DROP TABLE IF EXISTS CustomerDataExport;
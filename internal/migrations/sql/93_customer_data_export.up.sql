@@ -0,0 +1,20 @@
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'customer_data_export_ready';
+
+CREATE TABLE IF NOT EXISTS CustomerDataExport (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  organization_id UUID NOT NULL REFERENCES Organization (id) ON DELETE CASCADE,
+  customer_user_account_id UUID NOT NULL REFERENCES UserAccount (id) ON DELETE CASCADE,
+  requested_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  completed_at TIMESTAMP,
+  expires_at TIMESTAMP,
+  size BIGINT,
+  -- the zip file, encrypted with a key that is only part of the download link
+  data BYTEA
+);
+
+CREATE INDEX IF NOT EXISTS CustomerDataExport_customer_user_account_id
+  ON CustomerDataExport (customer_user_account_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS CustomerDataExport_pending
+  ON CustomerDataExport (created_at) WHERE completed_at IS NULL;
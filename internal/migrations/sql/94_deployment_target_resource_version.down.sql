@@ -0,0 +1,23 @@
+DROP TRIGGER IF EXISTS DeploymentTargetConnectivityCheck_resource_version ON DeploymentTargetConnectivityCheck;
+DROP TRIGGER IF EXISTS Announcement_DeploymentTarget_resource_version ON Announcement_DeploymentTarget;
+DROP TRIGGER IF EXISTS Announcement_resource_version ON Announcement;
+DROP TRIGGER IF EXISTS ApplicationLicense_resource_version ON ApplicationLicense;
+DROP TRIGGER IF EXISTS ApplicationDependency_resource_version ON ApplicationDependency;
+DROP TRIGGER IF EXISTS ApplicationVersion_resource_version ON ApplicationVersion;
+DROP TRIGGER IF EXISTS DeploymentDependency_resource_version ON DeploymentDependency;
+DROP TRIGGER IF EXISTS DeploymentRevision_resource_version ON DeploymentRevision;
+DROP TRIGGER IF EXISTS Deployment_resource_version ON Deployment;
+DROP TRIGGER IF EXISTS Organization_resource_version ON Organization;
+DROP TRIGGER IF EXISTS DeploymentTarget_resource_version ON DeploymentTarget;
+
+DROP FUNCTION IF EXISTS Announcement_resource_version();
+DROP FUNCTION IF EXISTS ApplicationLicense_resource_version();
+DROP FUNCTION IF EXISTS ApplicationDependency_resource_version();
+DROP FUNCTION IF EXISTS ApplicationVersion_resource_version();
+DROP FUNCTION IF EXISTS DeploymentRevision_resource_version();
+DROP FUNCTION IF EXISTS deployment_target_child_resource_version();
+DROP FUNCTION IF EXISTS Organization_resource_version();
+DROP FUNCTION IF EXISTS DeploymentTarget_resource_version();
+DROP FUNCTION IF EXISTS bump_deployment_target_resource_version(UUID[]);
+
+ALTER TABLE DeploymentTarget DROP COLUMN IF EXISTS resource_version;
@@ -0,0 +1,182 @@
+-- resource_version is increased by every change of the data that the agent resource of a deployment target is built
+-- from, so that polling agents can be told that nothing has changed without building the resource
+ALTER TABLE DeploymentTarget ADD COLUMN IF NOT EXISTS resource_version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_deployment_target_resource_version(ids UUID[]) RETURNS VOID
+  LANGUAGE SQL
+  AS $$
+    UPDATE DeploymentTarget SET resource_version = resource_version + 1 WHERE id = ANY (ids)
+  $$;
+
+-- only the columns that are part of the agent resource are compared, because agents update other columns, like the
+-- reported version and the clock skew, with every request
+CREATE OR REPLACE FUNCTION DeploymentTarget_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      NEW.resource_version := OLD.resource_version + 1;
+      RETURN NEW;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER DeploymentTarget_resource_version
+  BEFORE UPDATE ON DeploymentTarget
+  FOR EACH ROW
+  WHEN ((
+    OLD.type, OLD.namespace, OLD.agent_version_id, OLD.metrics_enabled, OLD.inventory_enabled,
+    OLD.migration_connect_url, OLD.agent_resource_limits,
+    OLD.vendor_logs_disabled, OLD.vendor_metrics_disabled, OLD.vendor_diagnostics_disabled,
+    OLD.vendor_inventory_disabled,
+    OLD.customer_logs_disabled, OLD.customer_metrics_disabled, OLD.customer_diagnostics_disabled,
+    OLD.customer_inventory_disabled
+  ) IS DISTINCT FROM (
+    NEW.type, NEW.namespace, NEW.agent_version_id, NEW.metrics_enabled, NEW.inventory_enabled,
+    NEW.migration_connect_url, NEW.agent_resource_limits,
+    NEW.vendor_logs_disabled, NEW.vendor_metrics_disabled, NEW.vendor_diagnostics_disabled,
+    NEW.vendor_inventory_disabled,
+    NEW.customer_logs_disabled, NEW.customer_metrics_disabled, NEW.customer_diagnostics_disabled,
+    NEW.customer_inventory_disabled
+  ))
+  EXECUTE FUNCTION DeploymentTarget_resource_version();
+
+-- the effective resource limits of a deployment target include the defaults of its organization
+CREATE OR REPLACE FUNCTION Organization_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      PERFORM bump_deployment_target_resource_version(
+        ARRAY(SELECT id FROM DeploymentTarget WHERE organization_id = NEW.id)
+      );
+      RETURN NULL;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER Organization_resource_version
+  AFTER UPDATE ON Organization
+  FOR EACH ROW
+  WHEN (OLD.agent_resource_limits IS DISTINCT FROM NEW.agent_resource_limits)
+  EXECUTE FUNCTION Organization_resource_version();
+
+-- used for all tables with a deployment_target_id column. OLD is NULL for inserts and NEW is NULL for deletes, so
+-- functions that are called for all operations use both.
+CREATE OR REPLACE FUNCTION deployment_target_child_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      PERFORM bump_deployment_target_resource_version(ARRAY[OLD.deployment_target_id, NEW.deployment_target_id]);
+      RETURN NULL;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER Deployment_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON Deployment
+  FOR EACH ROW
+  EXECUTE FUNCTION deployment_target_child_resource_version();
+
+-- used for DeploymentRevision and DeploymentDependency
+CREATE OR REPLACE FUNCTION DeploymentRevision_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      PERFORM bump_deployment_target_resource_version(ARRAY(
+        SELECT deployment_target_id FROM Deployment WHERE id IN (OLD.deployment_id, NEW.deployment_id)
+      ));
+      RETURN NULL;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER DeploymentRevision_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON DeploymentRevision
+  FOR EACH ROW
+  EXECUTE FUNCTION DeploymentRevision_resource_version();
+
+CREATE OR REPLACE TRIGGER DeploymentDependency_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON DeploymentDependency
+  FOR EACH ROW
+  EXECUTE FUNCTION DeploymentRevision_resource_version();
+
+-- application versions are only referenced by revisions, so inserting them does not change any agent resource
+CREATE OR REPLACE FUNCTION ApplicationVersion_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      PERFORM bump_deployment_target_resource_version(ARRAY(
+        SELECT d.deployment_target_id
+        FROM DeploymentRevision dr
+        JOIN Deployment d ON dr.deployment_id = d.id
+        WHERE dr.application_version_id = NEW.id
+      ));
+      RETURN NULL;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER ApplicationVersion_resource_version
+  AFTER UPDATE ON ApplicationVersion
+  FOR EACH ROW
+  EXECUTE FUNCTION ApplicationVersion_resource_version();
+
+-- application dependencies determine the order of the deployments in the agent resource
+CREATE OR REPLACE FUNCTION ApplicationDependency_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      PERFORM bump_deployment_target_resource_version(ARRAY(
+        SELECT d.deployment_target_id
+        FROM DeploymentRevision dr
+        JOIN Deployment d ON dr.deployment_id = d.id
+        JOIN ApplicationVersion av ON dr.application_version_id = av.id
+        WHERE av.application_id IN (OLD.application_id, NEW.application_id)
+      ));
+      RETURN NULL;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER ApplicationDependency_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON ApplicationDependency
+  FOR EACH ROW
+  EXECUTE FUNCTION ApplicationDependency_resource_version();
+
+-- licenses contain the registry credentials of their deployments
+CREATE OR REPLACE FUNCTION ApplicationLicense_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      PERFORM bump_deployment_target_resource_version(ARRAY(
+        SELECT deployment_target_id FROM Deployment WHERE application_license_id = NEW.id
+      ));
+      RETURN NULL;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER ApplicationLicense_resource_version
+  AFTER UPDATE ON ApplicationLicense
+  FOR EACH ROW
+  EXECUTE FUNCTION ApplicationLicense_resource_version();
+
+-- announcements are added to and removed from deployment targets through Announcement_DeploymentTarget, which is
+-- also used for changes of the announcement itself
+CREATE OR REPLACE FUNCTION Announcement_resource_version() RETURNS TRIGGER
+  LANGUAGE plpgsql
+  AS $$
+    BEGIN
+      PERFORM bump_deployment_target_resource_version(ARRAY(
+        SELECT deployment_target_id FROM Announcement_DeploymentTarget WHERE announcement_id = NEW.id
+      ));
+      RETURN NULL;
+    END;
+  $$;
+
+CREATE OR REPLACE TRIGGER Announcement_resource_version
+  AFTER UPDATE ON Announcement
+  FOR EACH ROW
+  EXECUTE FUNCTION Announcement_resource_version();
+
+CREATE OR REPLACE TRIGGER Announcement_DeploymentTarget_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON Announcement_DeploymentTarget
+  FOR EACH ROW
+  EXECUTE FUNCTION deployment_target_child_resource_version();
+
+CREATE OR REPLACE TRIGGER DeploymentTargetConnectivityCheck_resource_version
+  AFTER INSERT OR UPDATE OR DELETE ON DeploymentTargetConnectivityCheck
+  FOR EACH ROW
+  EXECUTE FUNCTION deployment_target_child_resource_version();
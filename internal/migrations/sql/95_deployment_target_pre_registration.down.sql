@@ -0,0 +1,3 @@
+-- enum values can not be removed from MAIL_TYPE, so deployment_target_connect_instructions is kept
+
+DROP TABLE IF EXISTS DeploymentTargetPreRegistration;
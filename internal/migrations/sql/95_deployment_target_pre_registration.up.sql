@@ -0,0 +1,23 @@
+ALTER TYPE MAIL_TYPE ADD VALUE IF NOT EXISTS 'deployment_target_connect_instructions';
+
+CREATE TABLE IF NOT EXISTS DeploymentTargetPreRegistration (
+  deployment_target_id UUID PRIMARY KEY REFERENCES DeploymentTarget (id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  created_by_user_account_id UUID REFERENCES UserAccount (id) ON DELETE SET NULL,
+  contact_email TEXT NOT NULL,
+  -- the version whose resource requirements are sent as prerequisites
+  application_version_id UUID REFERENCES ApplicationVersion (id) ON DELETE SET NULL,
+  -- the single-use pairing token, removed when it is redeemed or replaced by a reminder
+  pairing_token_salt BYTEA,
+  pairing_token_hash BYTEA,
+  pairing_token_expires_at TIMESTAMP NOT NULL,
+  paired_at TIMESTAMP,
+  connected_at TIMESTAMP,
+  reminded_at TIMESTAMP,
+  reminder_count INT NOT NULL DEFAULT 0,
+  -- the deployment target is deleted if it has not connected until then
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS DeploymentTargetPreRegistration_awaiting_connection
+  ON DeploymentTargetPreRegistration (expires_at) WHERE connected_at IS NULL;
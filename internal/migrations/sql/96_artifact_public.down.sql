@@ -0,0 +1 @@
+ALTER TABLE Artifact DROP COLUMN IF EXISTS public;
@@ -0,0 +1,2 @@
+-- public artifacts can be pulled by anonymous clients of the registry
+ALTER TABLE Artifact ADD COLUMN IF NOT EXISTS public BOOLEAN NOT NULL DEFAULT false;
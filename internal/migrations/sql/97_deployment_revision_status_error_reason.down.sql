@@ -0,0 +1 @@
+ALTER TABLE DeploymentRevisionStatus DROP COLUMN IF EXISTS error_reason;
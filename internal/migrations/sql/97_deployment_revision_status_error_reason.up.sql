@@ -0,0 +1,2 @@
+-- machine-readable cause of an error status, e.g. that a secret reference could not be resolved by the agent
+ALTER TABLE DeploymentRevisionStatus ADD COLUMN IF NOT EXISTS error_reason TEXT;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS ConsistencyReport;
+
+ALTER TABLE ApplicationLicense
+  DROP CONSTRAINT IF EXISTS applicationlicense_owner_useraccount_id_fkey,
+  ADD CONSTRAINT applicationlicense_owner_useraccount_id_fkey
+    FOREIGN KEY (owner_useraccount_id) REFERENCES UserAccount (id) NOT VALID;
+ALTER TABLE ArtifactLicense
+  DROP CONSTRAINT IF EXISTS artifactlicense_owner_useraccount_id_fkey,
+  ADD CONSTRAINT artifactlicense_owner_useraccount_id_fkey
+    FOREIGN KEY (owner_useraccount_id) REFERENCES UserAccount (id) NOT VALID;
@@ -0,0 +1,35 @@
+-- Constraints that were dropped manually are added again. They are NOT VALID, so that rows that were orphaned in the
+-- meantime do not fail the migration. The consistency check reports these rows and can repair them.
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT FROM pg_constraint WHERE conname = 'organization_useraccount_organization_id_fkey') THEN
+    ALTER TABLE Organization_UserAccount ADD CONSTRAINT organization_useraccount_organization_id_fkey
+      FOREIGN KEY (organization_id) REFERENCES Organization (id) ON DELETE CASCADE NOT VALID;
+  END IF;
+  IF NOT EXISTS (SELECT FROM pg_constraint WHERE conname = 'organization_useraccount_user_account_id_fkey') THEN
+    ALTER TABLE Organization_UserAccount ADD CONSTRAINT organization_useraccount_user_account_id_fkey
+      FOREIGN KEY (user_account_id) REFERENCES UserAccount (id) ON DELETE CASCADE NOT VALID;
+  END IF;
+  IF NOT EXISTS (SELECT FROM pg_constraint WHERE conname = 'deploymenttarget_created_by_user_account_id_fkey') THEN
+    ALTER TABLE DeploymentTarget ADD CONSTRAINT deploymenttarget_created_by_user_account_id_fkey
+      FOREIGN KEY (created_by_user_account_id) REFERENCES UserAccount (id) ON DELETE RESTRICT NOT VALID;
+  END IF;
+END $$;
+
+-- a user account that still owns licenses can not be deleted
+ALTER TABLE ApplicationLicense
+  DROP CONSTRAINT IF EXISTS applicationlicense_owner_useraccount_id_fkey,
+  ADD CONSTRAINT applicationlicense_owner_useraccount_id_fkey
+    FOREIGN KEY (owner_useraccount_id) REFERENCES UserAccount (id) ON DELETE RESTRICT NOT VALID;
+ALTER TABLE ArtifactLicense
+  DROP CONSTRAINT IF EXISTS artifactlicense_owner_useraccount_id_fkey,
+  ADD CONSTRAINT artifactlicense_owner_useraccount_id_fkey
+    FOREIGN KEY (owner_useraccount_id) REFERENCES UserAccount (id) ON DELETE RESTRICT NOT VALID;
+
+-- only the report of the latest run of the consistency check is kept
+CREATE TABLE IF NOT EXISTS ConsistencyReport (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+  repair BOOLEAN NOT NULL,
+  issues JSONB NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS ArtifactVersionPull_artifact_version_id_created_at;
+
+ALTER TABLE ArtifactVersionPull
+  DROP COLUMN IF EXISTS token_subject,
+  DROP COLUMN IF EXISTS method;
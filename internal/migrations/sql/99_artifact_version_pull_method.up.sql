@@ -0,0 +1,7 @@
+-- pulls recorded before this migration can not be told apart, so they count as GET requests
+ALTER TABLE ArtifactVersionPull
+  ADD COLUMN IF NOT EXISTS method TEXT NOT NULL DEFAULT 'GET',
+  ADD COLUMN IF NOT EXISTS token_subject TEXT;
+
+CREATE INDEX IF NOT EXISTS ArtifactVersionPull_artifact_version_id_created_at
+  ON ArtifactVersionPull (artifact_version_id, created_at);
@@ -0,0 +1,193 @@
+// Package orgtime contains helpers to work with wall clock times in the timezone of an organization.
+//
+// All helpers evaluate dates and times in the given location, so that daylight saving time transitions are handled
+// consistently: a wall clock time that is skipped by a transition is moved to the first instant after the gap, and a
+// wall clock time that occurs twice refers to its first occurrence.
+package orgtime
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidTimezone      = errors.New("timezone must be an IANA timezone name like Europe/Vienna")
+	ErrInvalidClock         = errors.New("time must have the format HH:MM")
+	ErrInvalidBusinessHours = errors.New("business hours must end after they start and contain at least one weekday")
+)
+
+var locations sync.Map
+
+// LoadLocation is like [time.LoadLocation] but caches loaded locations and only accepts IANA timezone names.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTimezone, err)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// Clock is a wall clock time with minute precision. It is represented as "HH:MM" in JSON.
+type Clock struct {
+	Hour   int
+	Minute int
+}
+
+func ParseClock(value string) (Clock, error) {
+	if t, err := time.Parse("15:04", value); err != nil {
+		return Clock{}, fmt.Errorf("%w: %w", ErrInvalidClock, err)
+	} else {
+		return Clock{Hour: t.Hour(), Minute: t.Minute()}, nil
+	}
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", c.Hour, c.Minute)
+}
+
+func (c Clock) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Clock) UnmarshalText(text []byte) (err error) {
+	*c, err = ParseClock(string(text))
+	return
+}
+
+func (c Clock) minutes() int {
+	return c.Hour*60 + c.Minute
+}
+
+// At returns the instant at which the wall clock in loc shows clock on the date of day in loc.
+func At(day time.Time, clock Clock, loc *time.Location) time.Time {
+	y, m, d := day.In(loc).Date()
+	wall := time.Date(y, m, d, clock.Hour, clock.Minute, 0, 0, time.UTC)
+	candidate := time.Date(y, m, d, clock.Hour, clock.Minute, 0, 0, loc)
+	zoneStart, zoneEnd := candidate.ZoneBounds()
+
+	// time.Date does not specify which instant is chosen around a transition, so all offsets that are in effect on
+	// this day are tried and the first instant that shows the requested wall clock time wins.
+	_, offset := candidate.Zone()
+	offsets := []int{offset}
+	if !zoneStart.IsZero() {
+		_, offset := zoneStart.Add(-time.Second).Zone()
+		offsets = append(offsets, offset)
+	}
+	if !zoneEnd.IsZero() {
+		_, offset := zoneEnd.Zone()
+		offsets = append(offsets, offset)
+	}
+	var result time.Time
+	for _, offset := range offsets {
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if wallClock(t).Equal(wall) && (result.IsZero() || t.Before(result)) {
+			result = t
+		}
+	}
+	if !result.IsZero() {
+		return result
+	}
+
+	// the wall clock time does not exist on this day, because it is skipped by a transition
+	if wallClock(candidate).Before(wall) {
+		return zoneEnd.In(loc)
+	}
+	return zoneStart.In(loc)
+}
+
+func wallClock(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// StartOfDay returns the first instant of the day of t in loc. This is not always midnight.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	return At(t, Clock{}, loc)
+}
+
+// addDays returns a time on the date that is days after the date of t in loc.
+func addDays(t time.Time, days int, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	// noon is never affected by daylight saving time transitions
+	return time.Date(y, m, d+days, 12, 0, 0, 0, loc)
+}
+
+// StartOfWeek returns the first instant of the week of t in loc. Weeks start on Monday.
+func StartOfWeek(t time.Time, loc *time.Location) time.Time {
+	offset := (int(t.In(loc).Weekday()) + 6) % 7
+	return StartOfDay(addDays(t, -offset, loc), loc)
+}
+
+// Weeks returns the first instants of the n weeks in loc up to and including the week of t, oldest first.
+func Weeks(t time.Time, n int, loc *time.Location) []time.Time {
+	result := make([]time.Time, n)
+	current := StartOfWeek(t, loc)
+	for i := range result {
+		result[i] = StartOfDay(addDays(current, -7*(n-1-i), loc), loc)
+	}
+	return result
+}
+
+// Month returns the first instant of the month of t in loc and the first instant of the following month.
+func Month(t time.Time, loc *time.Location) (start time.Time, end time.Time) {
+	y, m, _ := t.In(loc).Date()
+	start = StartOfDay(time.Date(y, m, 1, 12, 0, 0, 0, loc), loc)
+	end = StartOfDay(time.Date(y, m+1, 1, 12, 0, 0, 0, loc), loc)
+	return
+}
+
+// DaysUntil returns the number of calendar days in loc from the date of now to the date of t. It is negative if t is
+// on an earlier date.
+func DaysUntil(t, now time.Time, loc *time.Location) int {
+	return int(wallDate(t, loc).Sub(wallDate(now, loc)).Hours() / 24)
+}
+
+func wallDate(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// NextDaily returns the first instant after after at which something that is scheduled daily at clock in loc is due.
+// It is due exactly once per day, also on days with a daylight saving time transition.
+func NextDaily(after time.Time, clock Clock, loc *time.Location) time.Time {
+	if next := At(after, clock, loc); next.After(after) {
+		return next
+	}
+	return At(addDays(after, 1, loc), clock, loc)
+}
+
+// BusinessHours are the working hours of an organization, evaluated in its timezone.
+type BusinessHours struct {
+	Weekdays []time.Weekday `json:"weekdays"`
+	Start    Clock          `json:"start"`
+	End      Clock          `json:"end"`
+}
+
+func (bh BusinessHours) Validate() error {
+	if len(bh.Weekdays) == 0 || bh.Start.minutes() >= bh.End.minutes() {
+		return ErrInvalidBusinessHours
+	}
+	for _, day := range bh.Weekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return ErrInvalidBusinessHours
+		}
+	}
+	return nil
+}
+
+// Contains reports whether t is within business hours in loc.
+func (bh BusinessHours) Contains(t time.Time, loc *time.Location) bool {
+	t = t.In(loc)
+	clock := Clock{Hour: t.Hour(), Minute: t.Minute()}.minutes()
+	return slices.Contains(bh.Weekdays, t.Weekday()) && bh.Start.minutes() <= clock && clock < bh.End.minutes()
+}
@@ -0,0 +1,148 @@
+package orgtime_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/orgtime"
+	. "github.com/onsi/gomega"
+)
+
+func location(t *testing.T, name string) *time.Location {
+	loc, err := orgtime.LoadLocation(name)
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	return loc
+}
+
+func TestLoadLocation(t *testing.T) {
+	g := NewWithT(t)
+	loc, err := orgtime.LoadLocation("Europe/Vienna")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(loc.String()).To(Equal("Europe/Vienna"))
+	for _, name := range []string{"", "Local", "Europe/Nowhere", "+02:00"} {
+		_, err := orgtime.LoadLocation(name)
+		g.Expect(err).To(MatchError(orgtime.ErrInvalidTimezone), name)
+	}
+}
+
+func TestAtDuringTransitions(t *testing.T) {
+	g := NewWithT(t)
+	berlin := location(t, "Europe/Berlin")
+	newYork := location(t, "America/New_York")
+	clock := orgtime.Clock{Hour: 2, Minute: 30}
+
+	// 02:30 is skipped, the first instant after the gap is 03:00 CEST
+	g.Expect(orgtime.At(time.Date(2025, 3, 30, 12, 0, 0, 0, berlin), clock, berlin)).
+		To(BeTemporally("==", time.Date(2025, 3, 30, 1, 0, 0, 0, time.UTC)))
+	g.Expect(orgtime.At(time.Date(2025, 3, 9, 12, 0, 0, 0, newYork), clock, newYork)).
+		To(BeTemporally("==", time.Date(2025, 3, 9, 7, 0, 0, 0, time.UTC)))
+
+	// 02:30 (Berlin) and 01:30 (New York) occur twice, the first occurrence is used
+	g.Expect(orgtime.At(time.Date(2025, 10, 26, 12, 0, 0, 0, berlin), clock, berlin)).
+		To(BeTemporally("==", time.Date(2025, 10, 26, 0, 30, 0, 0, time.UTC)))
+	g.Expect(orgtime.At(time.Date(2025, 11, 2, 12, 0, 0, 0, newYork), orgtime.Clock{Hour: 1, Minute: 30}, newYork)).
+		To(BeTemporally("==", time.Date(2025, 11, 2, 5, 30, 0, 0, time.UTC)))
+}
+
+func TestStartOfDayWithoutMidnight(t *testing.T) {
+	g := NewWithT(t)
+	// in Santiago, clocks jumped from 00:00 to 01:00 on 2024-09-08
+	santiago := location(t, "America/Santiago")
+	start := orgtime.StartOfDay(time.Date(2024, 9, 8, 15, 0, 0, 0, santiago), santiago)
+	g.Expect(start).To(BeTemporally("==", time.Date(2024, 9, 8, 4, 0, 0, 0, time.UTC)))
+	g.Expect(start.In(santiago).Day()).To(Equal(8))
+	g.Expect(start.In(santiago).Hour()).To(Equal(1))
+}
+
+func TestNextDailyFiresOncePerDay(t *testing.T) {
+	g := NewWithT(t)
+	for _, tc := range []struct {
+		name  string
+		from  time.Time
+		clock orgtime.Clock
+	}{
+		{"Europe/Berlin", time.Date(2025, 3, 28, 0, 0, 0, 0, time.UTC), orgtime.Clock{Hour: 2, Minute: 30}},
+		{"Europe/Berlin", time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC), orgtime.Clock{Hour: 2, Minute: 30}},
+		{"America/New_York", time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC), orgtime.Clock{Hour: 2, Minute: 30}},
+		{"America/New_York", time.Date(2025, 10, 31, 0, 0, 0, 0, time.UTC), orgtime.Clock{Hour: 1, Minute: 30}},
+		{"Australia/Lord_Howe", time.Date(2025, 4, 4, 0, 0, 0, 0, time.UTC), orgtime.Clock{Hour: 1, Minute: 45}},
+	} {
+		loc := location(t, tc.name)
+		// starting at noon, the first occurrence is on the next day
+		from := time.Date(tc.from.Year(), tc.from.Month(), tc.from.Day(), 12, 0, 0, 0, loc)
+		var dates, expected []string
+		next := from
+		for i := 1; i <= 5; i++ {
+			next = orgtime.NextDaily(next, tc.clock, loc)
+			g.Expect(next.In(loc).Hour()).To(BeNumerically("~", tc.clock.Hour, 1), tc.name)
+			dates = append(dates, next.In(loc).Format(time.DateOnly))
+			expected = append(expected, from.AddDate(0, 0, i).Format(time.DateOnly))
+		}
+		g.Expect(dates).To(Equal(expected), tc.name)
+	}
+}
+
+func TestMonth(t *testing.T) {
+	g := NewWithT(t)
+	vienna := location(t, "Europe/Vienna")
+	// 23:30 UTC on March 31st already is April 1st in Vienna
+	start, end := orgtime.Month(time.Date(2025, 3, 31, 23, 30, 0, 0, time.UTC), vienna)
+	g.Expect(start).To(BeTemporally("==", time.Date(2025, 3, 31, 22, 0, 0, 0, time.UTC)))
+	g.Expect(end).To(BeTemporally("==", time.Date(2025, 4, 30, 22, 0, 0, 0, time.UTC)))
+
+	start, end = orgtime.Month(time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC), vienna)
+	g.Expect(start).To(BeTemporally("==", time.Date(2025, 2, 28, 23, 0, 0, 0, time.UTC)))
+	g.Expect(end.Sub(start)).To(Equal(31*24*time.Hour-time.Hour), "March has one hour less in Vienna")
+}
+
+func TestWeeks(t *testing.T) {
+	g := NewWithT(t)
+	vienna := location(t, "Europe/Vienna")
+	// 23:30 UTC on Sunday, April 6th already is Monday in Vienna
+	now := time.Date(2025, 4, 6, 23, 30, 0, 0, time.UTC)
+	g.Expect(orgtime.StartOfWeek(now, vienna)).To(BeTemporally("==", time.Date(2025, 4, 6, 22, 0, 0, 0, time.UTC)))
+	g.Expect(orgtime.StartOfWeek(now, time.UTC)).To(BeTemporally("==", time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)))
+
+	weeks := orgtime.Weeks(now, 3, vienna)
+	g.Expect(weeks).To(HaveLen(3))
+	g.Expect(weeks[0]).To(BeTemporally("==", time.Date(2025, 3, 23, 23, 0, 0, 0, time.UTC)))
+	// daylight saving time starts on March 30th
+	g.Expect(weeks[1]).To(BeTemporally("==", time.Date(2025, 3, 30, 22, 0, 0, 0, time.UTC)))
+	g.Expect(weeks[2]).To(BeTemporally("==", time.Date(2025, 4, 6, 22, 0, 0, 0, time.UTC)))
+	g.Expect(orgtime.Weeks(now, 0, vienna)).To(BeEmpty())
+}
+
+func TestDaysUntil(t *testing.T) {
+	g := NewWithT(t)
+	newYork := location(t, "America/New_York")
+	now := time.Date(2025, 3, 8, 23, 0, 0, 0, newYork)
+	g.Expect(orgtime.DaysUntil(time.Date(2025, 3, 9, 0, 30, 0, 0, newYork), now, newYork)).To(Equal(1))
+	g.Expect(orgtime.DaysUntil(time.Date(2025, 3, 10, 0, 0, 0, 0, newYork), now, newYork)).To(Equal(2))
+	g.Expect(orgtime.DaysUntil(time.Date(2025, 3, 8, 1, 0, 0, 0, newYork), now, newYork)).To(Equal(0))
+	g.Expect(orgtime.DaysUntil(time.Date(2025, 3, 1, 0, 0, 0, 0, newYork), now, newYork)).To(Equal(-7))
+	// the same instants are on different dates in UTC
+	g.Expect(orgtime.DaysUntil(time.Date(2025, 3, 9, 0, 30, 0, 0, newYork), now, time.UTC)).To(Equal(0))
+}
+
+func TestBusinessHours(t *testing.T) {
+	g := NewWithT(t)
+	var bh orgtime.BusinessHours
+	err := json.Unmarshal([]byte(`{"weekdays":[1,2,3,4,5],"start":"09:00","end":"17:30"}`), &bh)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(bh.Validate()).To(Succeed())
+	g.Expect(json.Marshal(bh)).To(MatchJSON(`{"weekdays":[1,2,3,4,5],"start":"09:00","end":"17:30"}`))
+
+	tokyo := location(t, "Asia/Tokyo")
+	// Monday 09:00 in Tokyo is Sunday in UTC
+	g.Expect(bh.Contains(time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), tokyo)).To(BeTrue())
+	g.Expect(bh.Contains(time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.UTC)).To(BeFalse())
+	g.Expect(bh.Contains(time.Date(2025, 6, 2, 17, 30, 0, 0, tokyo), tokyo)).To(BeFalse())
+	g.Expect(bh.Contains(time.Date(2025, 6, 7, 10, 0, 0, 0, tokyo), tokyo)).To(BeFalse())
+
+	g.Expect(json.Unmarshal([]byte(`{"start":"9am"}`), &bh)).To(MatchError(orgtime.ErrInvalidClock))
+	g.Expect(orgtime.BusinessHours{Weekdays: []time.Weekday{1}, Start: orgtime.Clock{Hour: 17}}.Validate()).
+		To(MatchError(orgtime.ErrInvalidBusinessHours))
+	g.Expect(orgtime.BusinessHours{Start: orgtime.Clock{Hour: 8}, End: orgtime.Clock{Hour: 9}}.Validate()).
+		To(MatchError(orgtime.ErrInvalidBusinessHours))
+}
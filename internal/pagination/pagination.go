@@ -0,0 +1,205 @@
+// Package pagination implements keyset pagination with opaque, signed cursors.
+//
+// A cursor contains the sort keys of the last item of a page and is signed with HMAC, so that clients can not craft
+// cursors with arbitrary values. Because the next page is selected by comparing the sort keys instead of skipping a
+// number of rows, pages stay stable when items are inserted concurrently, as long as the last column of a Spec is
+// unique.
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	LimitParam       = "limit"
+	CursorParam      = "cursor"
+	NextCursorHeader = "X-Next-Cursor"
+	MaxLimit         = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Column is a sort key of a paginated query.
+type Column struct {
+	// Expr is the SQL expression that is sorted by. It must not evaluate to NULL.
+	Expr string
+	// Type is the SQL type that cursor values for this column are cast to.
+	Type string
+	Desc bool
+}
+
+// Spec describes the order of a paginated query.
+type Spec struct {
+	// Name is included in the cursor signature, so that a cursor can only be used for the query it was created for.
+	Name string
+	// Columns are the sort keys. The last column must be unique.
+	Columns []Column
+}
+
+// Page is a request for one page of a list.
+type Page struct {
+	// Limit is the maximum number of items. Zero means that all remaining items are returned.
+	Limit int
+	// After contains the sort keys of the last item of the previous page or nil for the first page.
+	After []any
+}
+
+// FromRequest parses the limit and cursor query parameters.
+// If no limit is given, defaultLimit is used.
+func FromRequest(r *http.Request, spec Spec, key []byte, defaultLimit int) (Page, error) {
+	page := Page{Limit: defaultLimit}
+	if s := r.FormValue(LimitParam); s != "" {
+		if limit, err := strconv.Atoi(s); err != nil || limit < 1 || limit > MaxLimit {
+			return page, fmt.Errorf("parameter %v must be a number between 1 and %v", LimitParam, MaxLimit)
+		} else {
+			page.Limit = limit
+		}
+	}
+	if s := r.FormValue(CursorParam); s != "" {
+		if after, err := spec.DecodeCursor(key, s); err != nil {
+			return page, err
+		} else {
+			page.After = after
+		}
+	}
+	return page, nil
+}
+
+// Where returns a SQL condition that selects all rows after page.After.
+// The cursor values are added to args.
+func (spec Spec) Where(page Page, args pgx.NamedArgs) string {
+	if page.After == nil {
+		return "TRUE"
+	}
+	params := make([]string, len(spec.Columns))
+	for i, col := range spec.Columns {
+		name := fmt.Sprintf("pageCursor%v", i)
+		args[name] = page.After[i]
+		params[i] = fmt.Sprintf("CAST(@%v AS %v)", name, col.Type)
+	}
+	if spec.hasUniformDirection() {
+		// a row comparison can make use of a multi-column index
+		exprs := make([]string, len(spec.Columns))
+		for i, col := range spec.Columns {
+			exprs[i] = col.Expr
+		}
+		return fmt.Sprintf("(%v) %v (%v)",
+			strings.Join(exprs, ", "), spec.Columns[0].operator(), strings.Join(params, ", "))
+	}
+	var alternatives []string
+	for i, col := range spec.Columns {
+		var conditions []string
+		for j := range i {
+			conditions = append(conditions, fmt.Sprintf("%v = %v", spec.Columns[j].Expr, params[j]))
+		}
+		conditions = append(conditions, fmt.Sprintf("%v %v %v", col.Expr, col.operator(), params[i]))
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+// OrderByLimit returns the ORDER BY and LIMIT clauses for page.
+// One more row than requested is selected, so that Trim can tell whether there is a next page.
+func (spec Spec) OrderByLimit(page Page, args pgx.NamedArgs) string {
+	exprs := make([]string, len(spec.Columns))
+	for i, col := range spec.Columns {
+		exprs[i] = col.Expr
+		if col.Desc {
+			exprs[i] += " DESC"
+		}
+	}
+	result := "ORDER BY " + strings.Join(exprs, ", ")
+	if page.Limit > 0 {
+		args["pageLimit"] = page.Limit + 1
+		result += " LIMIT @pageLimit"
+	}
+	return result
+}
+
+func (spec Spec) hasUniformDirection() bool {
+	for _, col := range spec.Columns {
+		if col.Desc != spec.Columns[0].Desc {
+			return false
+		}
+	}
+	return true
+}
+
+func (col Column) operator() string {
+	if col.Desc {
+		return "<"
+	}
+	return ">"
+}
+
+// Trim removes the additional item selected by OrderByLimit and returns the sort keys for the next page,
+// or nil if items is the last page.
+func Trim[T any](page Page, items []T, keys func(T) []any) ([]T, []any) {
+	if page.Limit <= 0 || len(items) <= page.Limit {
+		return items, nil
+	}
+	items = items[:page.Limit]
+	return items, keys(items[len(items)-1])
+}
+
+// SetNextCursor sets the X-Next-Cursor header if next is not nil.
+func SetNextCursor(w http.ResponseWriter, spec Spec, key []byte, next []any) error {
+	if next == nil {
+		return nil
+	} else if cursor, err := spec.EncodeCursor(key, next); err != nil {
+		return err
+	} else {
+		w.Header().Set(NextCursorHeader, cursor)
+		return nil
+	}
+}
+
+func (spec Spec) EncodeCursor(key []byte, values []any) (string, error) {
+	if len(values) != len(spec.Columns) {
+		return "", fmt.Errorf("expected %v cursor values, got %v", len(spec.Columns), len(values))
+	}
+	payload, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(spec.sign(key, payload)), nil
+}
+
+func (spec Spec) DecodeCursor(key []byte, cursor string) ([]any, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, spec.sign(key, payload)) {
+		return nil, ErrInvalidCursor
+	}
+	var values []any
+	if err := json.Unmarshal(payload, &values); err != nil || len(values) != len(spec.Columns) {
+		return nil, ErrInvalidCursor
+	}
+	return values, nil
+}
+
+func (spec Spec) sign(key []byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(spec.Name))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,129 @@
+package pagination_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	key  = []byte("secret")
+	spec = pagination.Spec{
+		Name: "Test",
+		Columns: []pagination.Column{
+			{Expr: "created_at", Type: "TIMESTAMP", Desc: true},
+			{Expr: "id", Type: "UUID", Desc: true},
+		},
+	}
+	mixedSpec = pagination.Spec{
+		Name: "Mixed",
+		Columns: []pagination.Column{
+			{Expr: "name", Type: "TEXT"},
+			{Expr: "id", Type: "UUID", Desc: true},
+		},
+	}
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	g := NewWithT(t)
+	id := uuid.New()
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)
+	cursor, err := spec.EncodeCursor(key, []any{createdAt, id})
+	g.Expect(err).NotTo(HaveOccurred())
+	values, err := spec.DecodeCursor(key, cursor)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(values).To(Equal([]any{"2025-01-02T03:04:05.000006Z", id.String()}))
+}
+
+func TestCursorTampered(t *testing.T) {
+	g := NewWithT(t)
+	cursor, err := spec.EncodeCursor(key, []any{time.Now(), uuid.New()})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	forged, err := spec.EncodeCursor([]byte("other"), []any{"1970-01-01T00:00:00Z", uuid.New()})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = spec.DecodeCursor(key, forged)
+	g.Expect(err).To(MatchError(pagination.ErrInvalidCursor))
+
+	_, err = mixedSpec.DecodeCursor(key, cursor)
+	g.Expect(err).To(MatchError(pagination.ErrInvalidCursor), "cursor must be bound to its spec")
+
+	_, err = spec.DecodeCursor(key, "x"+cursor)
+	g.Expect(err).To(MatchError(pagination.ErrInvalidCursor))
+	_, err = spec.DecodeCursor(key, "garbage")
+	g.Expect(err).To(MatchError(pagination.ErrInvalidCursor))
+}
+
+func TestWhere(t *testing.T) {
+	g := NewWithT(t)
+	args := pgx.NamedArgs{}
+	g.Expect(spec.Where(pagination.Page{}, args)).To(Equal("TRUE"))
+	g.Expect(args).To(BeEmpty())
+
+	page := pagination.Page{Limit: 10, After: []any{"2025-01-01T00:00:00Z", "id"}}
+	g.Expect(spec.Where(page, args)).
+		To(Equal("(created_at, id) < (CAST(@pageCursor0 AS TIMESTAMP), CAST(@pageCursor1 AS UUID))"))
+	g.Expect(spec.OrderByLimit(page, args)).To(Equal("ORDER BY created_at DESC, id DESC LIMIT @pageLimit"))
+	g.Expect(args).To(Equal(pgx.NamedArgs{
+		"pageCursor0": "2025-01-01T00:00:00Z",
+		"pageCursor1": "id",
+		"pageLimit":   11,
+	}))
+
+	args = pgx.NamedArgs{}
+	g.Expect(mixedSpec.Where(pagination.Page{After: []any{"a", "id"}}, args)).To(Equal(
+		"((name > CAST(@pageCursor0 AS TEXT)) OR " +
+			"(name = CAST(@pageCursor0 AS TEXT) AND id < CAST(@pageCursor1 AS UUID)))",
+	))
+	g.Expect(mixedSpec.OrderByLimit(pagination.Page{}, args)).To(Equal("ORDER BY name, id DESC"))
+	g.Expect(args).NotTo(HaveKey("pageLimit"))
+}
+
+func TestTrim(t *testing.T) {
+	g := NewWithT(t)
+	keys := func(i int) []any { return []any{i} }
+
+	items, next := pagination.Trim(pagination.Page{Limit: 2}, []int{1, 2, 3}, keys)
+	g.Expect(items).To(Equal([]int{1, 2}))
+	g.Expect(next).To(Equal([]any{2}))
+
+	items, next = pagination.Trim(pagination.Page{Limit: 2}, []int{1, 2}, keys)
+	g.Expect(items).To(Equal([]int{1, 2}))
+	g.Expect(next).To(BeNil())
+
+	items, next = pagination.Trim(pagination.Page{}, []int{1, 2, 3}, keys)
+	g.Expect(items).To(Equal([]int{1, 2, 3}))
+	g.Expect(next).To(BeNil())
+}
+
+func TestFromRequest(t *testing.T) {
+	g := NewWithT(t)
+	page, err := pagination.FromRequest(httptest.NewRequest("GET", "/", nil), spec, key, 25)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(page).To(Equal(pagination.Page{Limit: 25}))
+
+	cursor, err := spec.EncodeCursor(key, []any{"2025-01-01T00:00:00Z", "id"})
+	g.Expect(err).NotTo(HaveOccurred())
+	page, err = pagination.FromRequest(httptest.NewRequest("GET", "/?limit=5&cursor="+cursor, nil), spec, key, 25)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(page).To(Equal(pagination.Page{Limit: 5, After: []any{"2025-01-01T00:00:00Z", "id"}}))
+
+	_, err = pagination.FromRequest(httptest.NewRequest("GET", "/?limit=1000", nil), spec, key, 25)
+	g.Expect(err).To(HaveOccurred())
+	_, err = pagination.FromRequest(httptest.NewRequest("GET", "/?cursor=abc.def", nil), spec, key, 25)
+	g.Expect(err).To(MatchError(pagination.ErrInvalidCursor))
+}
+
+func TestSetNextCursor(t *testing.T) {
+	g := NewWithT(t)
+	w := httptest.NewRecorder()
+	g.Expect(pagination.SetNextCursor(w, spec, key, nil)).To(Succeed())
+	g.Expect(w.Header().Get(pagination.NextCursorHeader)).To(BeEmpty())
+	g.Expect(pagination.SetNextCursor(w, spec, key, []any{"2025-01-01T00:00:00Z", "id"})).To(Succeed())
+	g.Expect(w.Header().Get(pagination.NextCursorHeader)).NotTo(BeEmpty())
+}
@@ -0,0 +1,244 @@
+// Package payloadshape shapes the JSON payloads of events before they are sent to an external receiver, so that a
+// receiver only gets the fields it needs and never gets secrets. The event itself is not changed.
+//
+// Fields are selected with paths of field names separated by dots, e.g. "deployment.application.name". Elements of
+// arrays have the path of the array. A path pattern is either a wildcard pattern or a regular expression:
+//
+//	deployment.*.name      "*" matches a single field name, it can also be part of a name, e.g. "*Id"
+//	deployment.**          "**" matches any number of field names, including none
+//	/^deployment\.(id|name)$/  a pattern between slashes is a regular expression that is matched against the path
+package payloadshape
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/glasskube/distr/internal/scrub"
+	"github.com/glasskube/distr/internal/secretscan"
+)
+
+// TruncatedMarker is appended to string values that have been shortened to fit into Options.MaxBytes.
+const TruncatedMarker = "…[truncated]"
+
+var (
+	ErrInvalidPattern = errors.New("invalid path pattern")
+	// ErrTooLarge is returned if a payload exceeds Options.MaxBytes even after all strings have been truncated.
+	ErrTooLarge = errors.New("payload too large")
+)
+
+// ValuesFields are the names of the fields that contain the values and env files of deployments. They are removed
+// unless Options.IncludeValues is set.
+var ValuesFields = []string{"valuesYaml", "envFileData"}
+
+type Options struct {
+	// AllowPaths are the patterns of the fields that are included. If it is empty, all fields are included.
+	AllowPaths []string
+	// DenyPaths are the patterns of the fields that are removed, even if they match AllowPaths.
+	DenyPaths []string
+	// IncludeValues includes the ValuesFields. Secrets in them are redacted nevertheless.
+	IncludeValues bool
+	// MaxBytes is the maximum size of a shaped payload. Longer strings are truncated first. Zero means no limit.
+	MaxBytes int
+}
+
+// Shaper applies Options to payloads.
+type Shaper struct {
+	opts     Options
+	allow    []matcher
+	deny     []matcher
+	scrubber *scrub.Scrubber
+	scanner  *secretscan.Scanner
+}
+
+// New returns a Shaper for opts. The values of fields that scrubber considers sensitive are replaced with
+// scrub.Redacted and secrets that the secret scanner finds in other strings are redacted as well.
+func New(opts Options, scrubber *scrub.Scrubber) (*Shaper, error) {
+	if opts.MaxBytes < 0 {
+		return nil, errors.New("MaxBytes must not be negative")
+	}
+	s := Shaper{opts: opts, scrubber: scrubber, scanner: secretscan.New()}
+	var err error
+	if s.allow, err = compile(opts.AllowPaths); err != nil {
+		return nil, err
+	} else if s.deny, err = compile(opts.DenyPaths); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Shape returns the JSON encoding of payload after applying the options.
+func (s *Shaper) Shape(payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// numbers are not converted to float64, which would change large integers
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	value, _ = s.shape(nil, value, len(s.allow) == 0)
+	if result, err := json.Marshal(value); err != nil {
+		return nil, err
+	} else if s.opts.MaxBytes == 0 || len(result) <= s.opts.MaxBytes {
+		return result, nil
+	}
+	return s.truncate(value)
+}
+
+// shape returns the shaped value and whether it is kept. Allowed is true if the value or one of its parents matches
+// an allow pattern.
+func (s *Shaper) shape(fields []string, value any, allowed bool) (any, bool) {
+	if !allowed && matchesAny(s.allow, fields) {
+		allowed = true
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for name, child := range v {
+			childFields := append(slices.Clip(fields), name)
+			if matchesAny(s.deny, childFields) || (!s.opts.IncludeValues && slices.Contains(ValuesFields, name)) {
+				continue
+			} else if s.scrubber.IsSensitiveField(name) && isScalar(child) {
+				if matchesAny(s.allow, childFields) || allowed {
+					result[name] = scrub.Redacted
+				}
+			} else if shaped, ok := s.shape(childFields, child, allowed); ok {
+				result[name] = shaped
+			}
+		}
+		return result, allowed || len(result) > 0 || fields == nil
+	case []any:
+		result := make([]any, 0, len(v))
+		for _, child := range v {
+			if shaped, ok := s.shape(fields, child, allowed); ok {
+				result = append(result, shaped)
+			}
+		}
+		return result, allowed || len(result) > 0
+	case string:
+		return string(s.scanner.Redact([]byte(v))), allowed
+	default:
+		return v, allowed
+	}
+}
+
+// truncate shortens the longest strings in value until its encoding fits into MaxBytes.
+func (s *Shaper) truncate(value any) ([]byte, error) {
+	for limit := longestString(value) / 2; ; limit /= 2 {
+		if result, err := json.Marshal(truncateStrings(value, limit)); err != nil {
+			return nil, err
+		} else if len(result) <= s.opts.MaxBytes {
+			return result, nil
+		} else if limit == 0 {
+			return nil, fmt.Errorf("%w: %v bytes exceed the limit of %v bytes", ErrTooLarge, len(result),
+				s.opts.MaxBytes)
+		}
+	}
+}
+
+func truncateStrings(value any, limit int) any {
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for name, child := range v {
+			result[name] = truncateStrings(child, limit)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, child := range v {
+			result[i] = truncateStrings(child, limit)
+		}
+		return result
+	case string:
+		if runes := []rune(v); len(runes) > limit {
+			return string(runes[:limit]) + TruncatedMarker
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func longestString(value any) int {
+	var result int
+	switch v := value.(type) {
+	case map[string]any:
+		for _, child := range v {
+			result = max(result, longestString(child))
+		}
+	case []any:
+		for _, child := range v {
+			result = max(result, longestString(child))
+		}
+	case string:
+		result = len([]rune(v))
+	}
+	return result
+}
+
+func isScalar(value any) bool {
+	switch value.(type) {
+	case map[string]any, []any:
+		return false
+	default:
+		return true
+	}
+}
+
+// matcher reports whether the field names of a path match a pattern.
+type matcher func(fields []string) bool
+
+func compile(patterns []string) ([]matcher, error) {
+	result := make([]matcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+			}
+			result = append(result, func(fields []string) bool { return re.MatchString(strings.Join(fields, ".")) })
+		} else if segments := strings.Split(pattern, "."); slices.Contains(segments, "") {
+			return nil, fmt.Errorf("%w %q: field names must not be empty", ErrInvalidPattern, pattern)
+		} else {
+			for _, segment := range segments {
+				if _, err := path.Match(segment, ""); err != nil {
+					return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+				}
+			}
+			result = append(result, func(fields []string) bool { return matchSegments(segments, fields) })
+		}
+	}
+	return result, nil
+}
+
+func matchSegments(segments, fields []string) bool {
+	if len(segments) == 0 {
+		return len(fields) == 0
+	} else if segments[0] == "**" {
+		for i := 0; i <= len(fields); i++ {
+			if matchSegments(segments[1:], fields[i:]) {
+				return true
+			}
+		}
+		return false
+	} else if len(fields) == 0 {
+		return false
+	} else if ok, _ := path.Match(segments[0], fields[0]); !ok {
+		return false
+	}
+	return matchSegments(segments[1:], fields[1:])
+}
+
+func matchesAny(matchers []matcher, fields []string) bool {
+	return fields != nil && slices.ContainsFunc(matchers, func(m matcher) bool { return m(fields) })
+}
@@ -0,0 +1,118 @@
+package payloadshape_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/internal/payloadshape"
+	"github.com/glasskube/distr/internal/scrub"
+	. "github.com/onsi/gomega"
+)
+
+var scrubber = scrub.New(scrub.DefaultFieldPatterns, nil, nil)
+
+func event() map[string]any {
+	return map[string]any{
+		"type": "deployment.updated",
+		"deployment": map[string]any{
+			"id":          "0b9d4a8e-3e4c-4d6f-9a57-2a1d7c3f5e10",
+			"releaseName": "app",
+			"valuesYaml":  "replicas: 2\n",
+			"envFileData": "DB_PASSWORD=hunter2\n",
+			"application": map[string]any{"id": "app-id", "name": "App"},
+			"target":      map[string]any{"id": "target-id", "name": "Production", "apiToken": "abc"},
+		},
+		"tags": []any{map[string]any{"name": "stable", "id": "tag-id"}},
+		"size": 9007199254740993,
+	}
+}
+
+func shape(g *WithT, opts payloadshape.Options, payload any) string {
+	s, err := payloadshape.New(opts, scrubber)
+	g.Expect(err).NotTo(HaveOccurred())
+	result, err := s.Shape(payload)
+	g.Expect(err).NotTo(HaveOccurred())
+	return string(result)
+}
+
+func TestShapeDefaults(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(shape(g, payloadshape.Options{}, event())).To(MatchJSON(`{
+		"type": "deployment.updated",
+		"deployment": {
+			"id": "0b9d4a8e-3e4c-4d6f-9a57-2a1d7c3f5e10",
+			"releaseName": "app",
+			"application": {"id": "app-id", "name": "App"},
+			"target": {"id": "target-id", "name": "Production", "apiToken": "[redacted]"}
+		},
+		"tags": [{"name": "stable", "id": "tag-id"}],
+		"size": 9007199254740993
+	}`))
+}
+
+func TestShapeIncludeValues(t *testing.T) {
+	g := NewWithT(t)
+	result := shape(g, payloadshape.Options{IncludeValues: true, AllowPaths: []string{"deployment.valuesYaml"}},
+		event())
+	g.Expect(result).To(MatchJSON(`{"deployment": {"valuesYaml": "replicas: 2\n"}}`))
+
+	// secrets in the values are redacted, even if the field name does not look sensitive
+	payload := map[string]any{"valuesYaml": "token: ghp_" + strings.Repeat("aB3", 12)}
+	result = shape(g, payloadshape.Options{IncludeValues: true}, payload)
+	g.Expect(result).NotTo(ContainSubstring("aB3aB3"))
+	g.Expect(result).To(ContainSubstring("ghp_"))
+}
+
+func TestShapeWildcards(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(shape(g, payloadshape.Options{AllowPaths: []string{"deployment.*.name", "type"}}, event())).
+		To(MatchJSON(`{"type": "deployment.updated", "deployment": {
+			"application": {"name": "App"}, "target": {"name": "Production"}
+		}}`))
+	g.Expect(shape(g, payloadshape.Options{AllowPaths: []string{"**.id"}}, event())).
+		To(MatchJSON(`{"deployment": {
+			"id": "0b9d4a8e-3e4c-4d6f-9a57-2a1d7c3f5e10", "application": {"id": "app-id"}, "target": {"id": "target-id"}
+		}, "tags": [{"id": "tag-id"}]}`))
+	// the whole subtree of an allowed field is included, except for denied fields
+	g.Expect(shape(g, payloadshape.Options{
+		AllowPaths: []string{"deployment.target"},
+		DenyPaths:  []string{"**.i*"},
+	}, event())).
+		To(MatchJSON(`{"deployment": {"target": {"name": "Production", "apiToken": "[redacted]"}}}`))
+}
+
+func TestShapeRegex(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(shape(g, payloadshape.Options{AllowPaths: []string{`/^deployment\.(id|releaseName)$/`}}, event())).
+		To(MatchJSON(`{"deployment": {"id": "0b9d4a8e-3e4c-4d6f-9a57-2a1d7c3f5e10", "releaseName": "app"}}`))
+	g.Expect(shape(g, payloadshape.Options{DenyPaths: []string{`/^(deployment|tags)\b/`}}, event())).
+		To(MatchJSON(`{"type": "deployment.updated", "size": 9007199254740993}`))
+}
+
+func TestShapeMaxBytes(t *testing.T) {
+	g := NewWithT(t)
+	payload := map[string]any{"id": "event-id", "message": strings.Repeat("x", 1000)}
+	result := shape(g, payloadshape.Options{MaxBytes: 200}, payload)
+	g.Expect(len(result)).To(BeNumerically("<=", 200))
+	g.Expect(result).To(ContainSubstring(`"id":"event-id"`))
+	g.Expect(result).To(ContainSubstring(payloadshape.TruncatedMarker))
+
+	s, err := payloadshape.New(payloadshape.Options{MaxBytes: 10}, scrubber)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = s.Shape(payload)
+	g.Expect(err).To(MatchError(payloadshape.ErrTooLarge))
+}
+
+func TestNewInvalidPattern(t *testing.T) {
+	g := NewWithT(t)
+	for _, opts := range []payloadshape.Options{
+		{AllowPaths: []string{"/(/"}},
+		{DenyPaths: []string{"deployment..id"}},
+		{DenyPaths: []string{"deployment.[id"}},
+	} {
+		_, err := payloadshape.New(opts, scrubber)
+		g.Expect(err).To(MatchError(payloadshape.ErrInvalidPattern))
+	}
+	_, err := payloadshape.New(payloadshape.Options{MaxBytes: -1}, scrubber)
+	g.Expect(err).To(HaveOccurred())
+}
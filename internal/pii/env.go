@@ -0,0 +1,17 @@
+package pii
+
+import "github.com/glasskube/distr/internal/env"
+
+// FromEnv creates a Keyring using the keys from the environment. It returns nil if no keys are configured.
+// env.Initialize must be called before.
+func FromEnv() (*Keyring, error) {
+	envKeys := env.PIIEncryptionKeys()
+	if len(envKeys) == 0 {
+		return nil, nil
+	}
+	keys := make([]Key, len(envKeys))
+	for i, key := range envKeys {
+		keys[i] = Key{Version: key.Version, Secret: key.Secret}
+	}
+	return New(keys, env.PIIBlindIndexKey())
+}
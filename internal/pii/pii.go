@@ -0,0 +1,210 @@
+// Package pii encrypts personally identifiable fields before they are stored in the database.
+//
+// Values are encrypted with AES-GCM using a key that is derived from a versioned master key and the organization the
+// value belongs to. Encrypted values are self-describing: they contain the version of the master key and the
+// organization, so they can be decrypted without further context. Since encryption is randomized, equality lookups
+// use a blind index, which is a keyed HMAC of the plaintext.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+const (
+	prefix = "pii:"
+	// MinKeyLength is the minimum length of master keys and the blind index key.
+	MinKeyLength = 32
+)
+
+var (
+	ErrDecryptionFailed = errors.New("decryption failed")
+	ErrNoKeys           = errors.New("value is encrypted, but no encryption keys are configured")
+)
+
+// Key is a master key. Its Version is stored with every value encrypted with it.
+type Key struct {
+	Version int
+	Secret  []byte
+}
+
+// Keyring encrypts values with its active key and decrypts values encrypted with any of its keys.
+//
+// A nil Keyring is valid: it does not encrypt, and it returns values that are not encrypted unchanged.
+type Keyring struct {
+	active   Key
+	keys     map[int][]byte
+	indexKey []byte
+	aeads    sync.Map
+}
+
+type aeadID struct {
+	version int
+	scope   uuid.UUID
+}
+
+// New creates a Keyring. The first key is the active key, which is used for encryption. The remaining keys are only
+// used to decrypt values that have not been re-encrypted with the active key yet.
+//
+// The indexKey must never change, because blind indexes are not recomputed.
+func New(keys []Key, indexKey []byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	} else if len(indexKey) < MinKeyLength {
+		return nil, fmt.Errorf("blind index key must be at least %v bytes long", MinKeyLength)
+	}
+	k := Keyring{active: keys[0], keys: make(map[int][]byte, len(keys)), indexKey: indexKey}
+	for _, key := range keys {
+		if key.Version <= 0 {
+			return nil, fmt.Errorf("key version must be positive: %v", key.Version)
+		} else if len(key.Secret) < MinKeyLength {
+			return nil, fmt.Errorf("key %v must be at least %v bytes long", key.Version, MinKeyLength)
+		} else if _, ok := k.keys[key.Version]; ok {
+			return nil, fmt.Errorf("duplicate key version: %v", key.Version)
+		}
+		k.keys[key.Version] = key.Secret
+	}
+	return &k, nil
+}
+
+// ActiveVersion returns the version of the key that is used for encryption, or 0 if k is nil.
+func (k *Keyring) ActiveVersion() int {
+	if k == nil {
+		return 0
+	}
+	return k.active.Version
+}
+
+// Encrypt encrypts value with the active key for the given scope, which is usually an organization ID.
+// Empty values are not encrypted.
+func (k *Keyring) Encrypt(scope uuid.UUID, value string) (string, error) {
+	if k == nil || value == "" {
+		return value, nil
+	}
+	aead, err := k.aead(k.active.Version, scope)
+	if err != nil {
+		return "", err
+	}
+	header := encodeHeader(k.active.Version, scope)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(header))
+	return header + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt. Values that are not encrypted are returned unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	} else if k == nil {
+		return "", ErrNoKeys
+	}
+	version, scope, data, err := decodeHeader(value)
+	if err != nil {
+		return "", err
+	}
+	aead, err := k.aead(version, scope)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrDecryptionFailed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	if result, err := aead.Open(nil, nonce, ciphertext, []byte(value[:len(value)-len(data)])); err != nil {
+		return "", ErrDecryptionFailed
+	} else {
+		return string(result), nil
+	}
+}
+
+// BlindIndex returns a deterministic, keyed hash of value that can be used for equality lookups.
+// It returns nil if k is nil.
+func (k *Keyring) BlindIndex(value string) *string {
+	if k == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	result := hex.EncodeToString(mac.Sum(nil))
+	return &result
+}
+
+// IsEncrypted returns true if value has been encrypted by a Keyring.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// aead returns the cipher for the given key version and scope. Ciphers are cached, because deriving the key and
+// expanding the AES key schedule would otherwise dominate the cost of decrypting a short value.
+func (k *Keyring) aead(version int, scope uuid.UUID) (cipher.AEAD, error) {
+	id := aeadID{version, scope}
+	if aead, ok := k.aeads.Load(id); ok {
+		return aead.(cipher.AEAD), nil
+	}
+	secret, ok := k.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key version %v", ErrDecryptionFailed, version)
+	}
+	key, err := hkdf.Key(sha256.New, secret, nil, scope.String(), 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	k.aeads.Store(id, aead)
+	return aead, nil
+}
+
+// encodeHeader returns the prefix of an encrypted value, which is also its additional authenticated data.
+func encodeHeader(version int, scope uuid.UUID) string {
+	return prefix + strconv.Itoa(version) + ":" + scope.String() + ":"
+}
+
+func decodeHeader(value string) (version int, scope uuid.UUID, data string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 3)
+	if len(parts) != 3 {
+		return 0, uuid.Nil, "", ErrDecryptionFailed
+	} else if version, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, uuid.Nil, "", ErrDecryptionFailed
+	} else if scope, err = uuid.Parse(parts[1]); err != nil {
+		return 0, uuid.Nil, "", ErrDecryptionFailed
+	} else {
+		return version, scope, parts[2], nil
+	}
+}
+
+var defaultKeyring atomic.Pointer[Keyring]
+
+// Default returns the Keyring that is used by the database layer. It is nil if encryption is not configured.
+func Default() *Keyring {
+	return defaultKeyring.Load()
+}
+
+// SetDefault replaces the Keyring that is returned by Default.
+func SetDefault(k *Keyring) {
+	defaultKeyring.Store(k)
+}
@@ -0,0 +1,150 @@
+package pii_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	key1     = pii.Key{Version: 1, Secret: bytes.Repeat([]byte{1}, pii.MinKeyLength)}
+	key2     = pii.Key{Version: 2, Secret: bytes.Repeat([]byte{2}, pii.MinKeyLength)}
+	indexKey = bytes.Repeat([]byte{3}, pii.MinKeyLength)
+)
+
+func newKeyring(t testing.TB, keys ...pii.Key) *pii.Keyring {
+	k, err := pii.New(keys, indexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestNew(t *testing.T) {
+	g := NewWithT(t)
+	_, err := pii.New(nil, indexKey)
+	g.Expect(err).To(HaveOccurred())
+	_, err = pii.New([]pii.Key{key1}, []byte("short"))
+	g.Expect(err).To(HaveOccurred())
+	_, err = pii.New([]pii.Key{{Version: 1, Secret: []byte("short")}}, indexKey)
+	g.Expect(err).To(HaveOccurred())
+	_, err = pii.New([]pii.Key{key1, {Version: 1, Secret: key2.Secret}}, indexKey)
+	g.Expect(err).To(HaveOccurred())
+	_, err = pii.New([]pii.Key{{Version: 0, Secret: key1.Secret}}, indexKey)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	g := NewWithT(t)
+	k := newKeyring(t, key1)
+	scope := uuid.New()
+
+	encrypted, err := k.Encrypt(scope, "jane.doe@example.com")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pii.IsEncrypted(encrypted)).To(BeTrue())
+	g.Expect(encrypted).To(HavePrefix("pii:1:" + scope.String() + ":"))
+	g.Expect(encrypted).NotTo(ContainSubstring("jane"))
+	g.Expect(k.Decrypt(encrypted)).To(Equal("jane.doe@example.com"))
+
+	// encryption is randomized
+	again, err := k.Encrypt(scope, "jane.doe@example.com")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again).NotTo(Equal(encrypted))
+
+	// empty and plain text values are passed through
+	g.Expect(k.Encrypt(scope, "")).To(BeEmpty())
+	g.Expect(k.Decrypt("jane.doe@example.com")).To(Equal("jane.doe@example.com"))
+}
+
+func TestDecryptTampered(t *testing.T) {
+	g := NewWithT(t)
+	k := newKeyring(t, key1)
+	scope := uuid.New()
+	encrypted, err := k.Encrypt(scope, "jane.doe@example.com")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the header is authenticated, so a value can not be moved to another scope
+	moved := strings.Replace(encrypted, scope.String(), uuid.NewString(), 1)
+	_, err = k.Decrypt(moved)
+	g.Expect(err).To(MatchError(pii.ErrDecryptionFailed))
+
+	_, err = k.Decrypt(encrypted[:len(encrypted)-4])
+	g.Expect(err).To(MatchError(pii.ErrDecryptionFailed))
+	_, err = k.Decrypt("pii:1:garbage")
+	g.Expect(err).To(MatchError(pii.ErrDecryptionFailed))
+
+	var disabled *pii.Keyring
+	_, err = disabled.Decrypt(encrypted)
+	g.Expect(err).To(MatchError(pii.ErrNoKeys))
+}
+
+func TestRotation(t *testing.T) {
+	g := NewWithT(t)
+	scope := uuid.New()
+	old := newKeyring(t, key1)
+	encrypted, err := old.Encrypt(scope, "jane.doe@example.com")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	rotated := newKeyring(t, key2, key1)
+	g.Expect(rotated.ActiveVersion()).To(Equal(2))
+	g.Expect(rotated.Decrypt(encrypted)).To(Equal("jane.doe@example.com"))
+	reencrypted, err := rotated.Encrypt(scope, "jane.doe@example.com")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reencrypted).To(HavePrefix("pii:2:"))
+
+	// values of removed keys can not be decrypted anymore
+	_, err = newKeyring(t, key2).Decrypt(encrypted)
+	g.Expect(err).To(MatchError(pii.ErrDecryptionFailed))
+}
+
+func TestBlindIndex(t *testing.T) {
+	g := NewWithT(t)
+	k := newKeyring(t, key1)
+	index := k.BlindIndex("jane.doe@example.com")
+	g.Expect(index).NotTo(BeNil())
+	g.Expect(*index).NotTo(ContainSubstring("jane"))
+	// the index does not depend on the encryption keys, so it survives key rotation
+	g.Expect(newKeyring(t, key2, key1).BlindIndex("jane.doe@example.com")).To(Equal(index))
+	g.Expect(k.BlindIndex("john.doe@example.com")).NotTo(Equal(index))
+
+	var disabled *pii.Keyring
+	g.Expect(disabled.BlindIndex("jane.doe@example.com")).To(BeNil())
+	g.Expect(disabled.ActiveVersion()).To(BeZero())
+}
+
+// The login path computes one blind index and decrypts the email and name of one user account, so these benchmarks
+// bound the overhead that encryption adds to it.
+
+func BenchmarkBlindIndex(b *testing.B) {
+	k := newKeyring(b, key1)
+	for b.Loop() {
+		k.BlindIndex("jane.doe@example.com")
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	k := newKeyring(b, key1)
+	scope := uuid.New()
+	for b.Loop() {
+		if _, err := k.Encrypt(scope, "jane.doe@example.com"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	k := newKeyring(b, key1)
+	encrypted, err := k.Encrypt(uuid.New(), "jane.doe@example.com")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		if _, err := k.Decrypt(encrypted); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package preflight checks whether a deployment target meets the resource requirements of an application before a
+// deployment is created.
+package preflight
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/agentimage"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// Capacity describes the resources of a deployment target as reported by its agent.
+// Fields are nil or empty if the agent has not reported them.
+type Capacity struct {
+	CPUCoresMillis *int64
+	MemoryBytes    *int64
+	DiskBytes      *int64
+	Architecture   string
+}
+
+// CapacityOf returns the capacity described by the latest metrics and the platform reported by an agent.
+// Both may be nil.
+func CapacityOf(metrics *api.AgentDeploymentTargetMetrics, platform *string) Capacity {
+	var capacity Capacity
+	if metrics != nil {
+		if metrics.CPUCoresMillis > 0 {
+			capacity.CPUCoresMillis = &metrics.CPUCoresMillis
+		}
+		if metrics.MemoryBytes > 0 {
+			capacity.MemoryBytes = &metrics.MemoryBytes
+		}
+		capacity.DiskBytes = metrics.DiskBytes
+	}
+	if platform != nil {
+		capacity.Architecture = agentimage.Arch(*platform)
+	}
+	return capacity
+}
+
+// Check returns a description of every requirement that capacity does not meet.
+// Requirements are skipped if the corresponding capacity is unknown, because agents of older versions or agents
+// with disabled metrics do not report all of them.
+func Check(requirements types.ResourceRequirements, capacity Capacity) []string {
+	var unmet []string
+	if requirements.MinCPUCoresMillis != nil && capacity.CPUCoresMillis != nil &&
+		*capacity.CPUCoresMillis < *requirements.MinCPUCoresMillis {
+		unmet = append(unmet, fmt.Sprintf("requires %v CPU cores but the deployment target has %v",
+			formatCores(*requirements.MinCPUCoresMillis), formatCores(*capacity.CPUCoresMillis)))
+	}
+	if requirements.MinMemoryBytes != nil && capacity.MemoryBytes != nil &&
+		*capacity.MemoryBytes < *requirements.MinMemoryBytes {
+		unmet = append(unmet, fmt.Sprintf("requires %v of memory but the deployment target has %v",
+			formatBytes(*requirements.MinMemoryBytes), formatBytes(*capacity.MemoryBytes)))
+	}
+	if requirements.MinDiskBytes != nil && capacity.DiskBytes != nil &&
+		*capacity.DiskBytes < *requirements.MinDiskBytes {
+		unmet = append(unmet, fmt.Sprintf("requires %v of disk space but the deployment target has %v",
+			formatBytes(*requirements.MinDiskBytes), formatBytes(*capacity.DiskBytes)))
+	}
+	if len(requirements.Architectures) > 0 && capacity.Architecture != "" &&
+		!slices.Contains(requirements.Architectures, capacity.Architecture) {
+		unmet = append(unmet, fmt.Sprintf("requires one of the architectures %v but the deployment target is %v",
+			strings.Join(requirements.Architectures, ", "), capacity.Architecture))
+	}
+	return unmet
+}
+
+func formatCores(millis int64) string {
+	return strconv.FormatFloat(float64(millis)/1000, 'f', -1, 64)
+}
+
+// formatBytes formats n using the largest binary unit that results in a value of at least 1, e.g. "7.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	var exp int
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + " " + []string{"", "KiB", "MiB", "GiB", "TiB"}[exp]
+}
+
+// Prerequisites returns a checklist of what a host must provide before the agent of target is installed. It contains
+// the tooling that the connect command of the deployment type needs and every requirement in requirements, which may
+// be nil.
+func Prerequisites(target types.DeploymentTarget, requirements *types.ResourceRequirements) []string {
+	var result []string
+	switch target.Type {
+	case types.DeploymentTypeDocker:
+		result = append(result, "Docker Engine with the Docker Compose plugin")
+	case types.DepolymentTypeKubernetes:
+		if target.Scope != nil && *target.Scope == types.DeploymentTargetScopeNamespace && target.Namespace != nil {
+			result = append(result, fmt.Sprintf("kubectl with permission to manage resources in the namespace %v",
+				*target.Namespace))
+		} else {
+			result = append(result, "kubectl with permission to manage cluster-wide resources")
+		}
+	}
+	if requirements == nil {
+		return result
+	}
+	if requirements.MinCPUCoresMillis != nil {
+		result = append(result, fmt.Sprintf("at least %v CPU cores", formatCores(*requirements.MinCPUCoresMillis)))
+	}
+	if requirements.MinMemoryBytes != nil {
+		result = append(result, fmt.Sprintf("at least %v of memory", formatBytes(*requirements.MinMemoryBytes)))
+	}
+	if requirements.MinDiskBytes != nil {
+		result = append(result, fmt.Sprintf("at least %v of disk space", formatBytes(*requirements.MinDiskBytes)))
+	}
+	if len(requirements.Architectures) > 0 {
+		result = append(result, fmt.Sprintf("one of the CPU architectures %v",
+			strings.Join(requirements.Architectures, ", ")))
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package preflight_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/preflight"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+const gib = 1024 * 1024 * 1024
+
+func TestCheck(t *testing.T) {
+	g := NewWithT(t)
+	requirements := types.ResourceRequirements{
+		MinCPUCoresMillis: util.PtrTo(int64(2000)),
+		MinMemoryBytes:    util.PtrTo(int64(8 * gib)),
+		MinDiskBytes:      util.PtrTo(int64(20 * gib)),
+		Architectures:     []string{"amd64"},
+	}
+	capacity := preflight.CapacityOf(&api.AgentDeploymentTargetMetrics{
+		CPUCoresMillis: 4000,
+		MemoryBytes:    2 * gib,
+		DiskBytes:      util.PtrTo(int64(100 * gib)),
+	}, util.PtrTo("linux/arm64"))
+
+	g.Expect(preflight.Check(requirements, capacity)).To(ConsistOf(
+		"requires 8 GiB of memory but the deployment target has 2 GiB",
+		"requires one of the architectures amd64 but the deployment target is arm64",
+	))
+	g.Expect(preflight.Check(types.ResourceRequirements{}, capacity)).To(BeEmpty())
+
+	capacity.MemoryBytes = util.PtrTo(int64(7.5 * gib))
+	capacity.CPUCoresMillis = util.PtrTo(int64(1500))
+	g.Expect(preflight.Check(requirements, capacity)).To(ContainElements(
+		"requires 8 GiB of memory but the deployment target has 7.5 GiB",
+		"requires 2 CPU cores but the deployment target has 1.5",
+	))
+}
+
+func TestCheckSkipsUnknownCapacity(t *testing.T) {
+	g := NewWithT(t)
+	requirements := types.ResourceRequirements{
+		MinMemoryBytes: util.PtrTo(int64(8 * gib)),
+		MinDiskBytes:   util.PtrTo(int64(20 * gib)),
+		Architectures:  []string{"amd64"},
+	}
+	g.Expect(preflight.Check(requirements, preflight.CapacityOf(nil, nil))).To(BeEmpty())
+	capacity := preflight.CapacityOf(&api.AgentDeploymentTargetMetrics{MemoryBytes: 16 * gib}, nil)
+	g.Expect(preflight.Check(requirements, capacity)).To(BeEmpty(), "old agents do not report the disk size")
+}
+
+func TestPrerequisites(t *testing.T) {
+	g := NewWithT(t)
+	requirements := types.ResourceRequirements{
+		MinCPUCoresMillis: util.PtrTo(int64(1500)),
+		MinMemoryBytes:    util.PtrTo(int64(4 * gib)),
+		Architectures:     []string{"amd64", "arm64"},
+	}
+	g.Expect(preflight.Prerequisites(types.DeploymentTarget{Type: types.DeploymentTypeDocker}, &requirements)).
+		To(Equal([]string{
+			"Docker Engine with the Docker Compose plugin",
+			"at least 1.5 CPU cores",
+			"at least 4 GiB of memory",
+			"one of the CPU architectures amd64, arm64",
+		}))
+	g.Expect(preflight.Prerequisites(types.DeploymentTarget{
+		Type:      types.DepolymentTypeKubernetes,
+		Namespace: util.PtrTo("app"),
+		Scope:     util.PtrTo(types.DeploymentTargetScopeNamespace),
+	}, nil)).To(Equal([]string{"kubectl with permission to manage resources in the namespace app"}))
+}
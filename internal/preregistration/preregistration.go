@@ -0,0 +1,135 @@
+// Package preregistration sends the connect instructions of deployment targets that vendors register on behalf of
+// their customers, reminds customers of targets that have not connected and deletes targets that never connect.
+package preregistration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/glasskube/distr/internal/agentmanifest"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/preflight"
+	"github.com/glasskube/distr/internal/security"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// NewPairingToken generates a pairing token that is valid for validity and stores its salt, hash and expiry in
+// preRegistration. The token itself is only returned and must be sent to the customer contact.
+func NewPairingToken(preRegistration *types.DeploymentTargetPreRegistration, validity time.Duration) (string, error) {
+	if token, err := security.GenerateAccessKey(); err != nil {
+		return "", fmt.Errorf("failed to generate pairing token: %w", err)
+	} else if salt, hash, err := security.HashAccessKey(token); err != nil {
+		return "", fmt.Errorf("failed to hash pairing token: %w", err)
+	} else {
+		preRegistration.PairingTokenSalt = &salt
+		preRegistration.PairingTokenHash = &hash
+		preRegistration.PairingTokenExpiresAt = time.Now().Add(validity)
+		return token, nil
+	}
+}
+
+// SendConnectInstructions mails the connect command with pairingToken and the prerequisites of target to the customer
+// contact of preRegistration.
+func SendConnectInstructions(
+	ctx context.Context,
+	target types.DeploymentTarget,
+	preRegistration types.DeploymentTargetPreRegistration,
+	pairingToken string,
+	reminder bool,
+) error {
+	org, err := db.GetOrganizationByID(ctx, target.OrganizationID)
+	if err != nil {
+		return err
+	}
+	pairingURL, err := agentmanifest.PairingURL(*org, target.ID, pairingToken)
+	if err != nil {
+		return fmt.Errorf("could not create pairing url: %w", err)
+	}
+	var requirements *types.ResourceRequirements
+	if preRegistration.ApplicationVersionID != nil {
+		if app, err := db.GetApplicationForApplicationVersionID(
+			ctx, *preRegistration.ApplicationVersionID, target.OrganizationID,
+		); err != nil {
+			return err
+		} else if version, err := db.GetApplicationVersion(ctx, *preRegistration.ApplicationVersionID); err != nil {
+			return err
+		} else {
+			requirements = types.EffectiveResourceRequirements(*app, *version)
+		}
+	}
+	return mailsending.SendDeploymentTargetConnectInstructionsMail(
+		ctx,
+		target,
+		preRegistration,
+		agentmanifest.ConnectCommand(target, pairingURL),
+		preflight.Prerequisites(target, requirements),
+		reminder,
+	)
+}
+
+type Options struct {
+	// PairingTokenValidity is the validity of the pairing token that is sent with each reminder.
+	PairingTokenValidity time.Duration
+	// ReminderInterval is the minimum time between the pre-registration and the first reminder, and between two
+	// reminders for the same deployment target.
+	ReminderInterval time.Duration
+	// MaxReminders is the maximum number of reminders for one deployment target.
+	MaxReminders int
+}
+
+type Job struct {
+	mailer mail.Mailer
+	opts   Options
+	now    func() time.Time
+}
+
+func NewJob(mailer mail.Mailer, opts Options) *Job {
+	return &Job{mailer: mailer, opts: opts, now: clock.Now}
+}
+
+// Run deletes pre-registered deployment targets that have expired without connecting and sends reminders with a new
+// pairing token for those that are due.
+func (j *Job) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	ctx = internalctx.WithMailer(ctx, j.mailer)
+	deleted, err := db.DeleteExpiredPreRegisteredDeploymentTargets(ctx)
+	if err != nil {
+		return err
+	}
+	due, err := db.GetDueDeploymentTargetPreRegistrationReminders(
+		ctx, j.now().Add(-j.opts.ReminderInterval), j.opts.MaxReminders,
+	)
+	if err != nil {
+		return err
+	}
+	var sent int
+	for _, preRegistration := range due {
+		log := log.With(zap.Stringer("deploymentTargetId", preRegistration.DeploymentTargetID))
+		target, err := db.GetDeploymentTarget(ctx, preRegistration.DeploymentTargetID, nil)
+		if err != nil {
+			return err
+		}
+		// the renewal counts as a reminder even if sending fails, so that a broken recipient does not block the job
+		token, err := NewPairingToken(&preRegistration, j.opts.PairingTokenValidity)
+		if err != nil {
+			return err
+		} else if err := db.RenewDeploymentTargetPairingToken(ctx, &preRegistration, true); err != nil {
+			return err
+		} else if err := SendConnectInstructions(
+			ctx, target.DeploymentTarget, preRegistration, token, true,
+		); err != nil {
+			log.Warn("could not send deployment target connect instructions reminder", zap.Error(err))
+		} else {
+			sent++
+		}
+	}
+	log.Info("deployment target pre-registrations finished",
+		zap.Int64("deleted", deleted), zap.Int("due", len(due)), zap.Int("sent", sent))
+	return nil
+}
@@ -0,0 +1,67 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+)
+
+// Check is a single requirement that an application version must fulfill before it can be promoted.
+//
+// To add a new kind of check, implement this interface and register a constructor in checkFactories.
+type Check interface {
+	Evaluate(ctx context.Context, version types.ApplicationVersion) (types.PromotionCheckResult, error)
+}
+
+type checkFactory func(config types.PromotionCheck) (Check, error)
+
+var checkFactories = map[types.PromotionCheckType]checkFactory{
+	types.PromotionCheckTypeVulnerabilityScan:  newVulnerabilityScanCheck,
+	types.PromotionCheckTypeStagingDeployments: newStagingDeploymentsCheck,
+	types.PromotionCheckTypeManualApproval:     newManualApprovalCheck,
+}
+
+// NewCheck creates the Check for the given configuration.
+// A validation error is returned if the configuration is invalid or the check type is unknown.
+func NewCheck(config types.PromotionCheck) (Check, error) {
+	if factory, ok := checkFactories[config.Type]; !ok {
+		return nil, validation.NewValidationFailedError(fmt.Sprintf("unknown check type: %v", config.Type))
+	} else {
+		return factory(config)
+	}
+}
+
+// ValidateChecks returns an error if any of the given check configurations is invalid.
+func ValidateChecks(configs []types.PromotionCheck) error {
+	for _, config := range configs {
+		if _, err := NewCheck(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate runs all checks against the version. The version can be promoted if all results have passed.
+func Evaluate(
+	ctx context.Context,
+	version types.ApplicationVersion,
+	configs []types.PromotionCheck,
+) ([]types.PromotionCheckResult, bool, error) {
+	results := make([]types.PromotionCheckResult, 0, len(configs))
+	passed := true
+	for _, config := range configs {
+		check, err := NewCheck(config)
+		if err != nil {
+			return nil, false, err
+		}
+		result, err := check.Evaluate(ctx, version)
+		if err != nil {
+			return nil, false, fmt.Errorf("%v check failed to evaluate: %w", config.Type, err)
+		}
+		results = append(results, result)
+		passed = passed && result.Passed
+	}
+	return results, passed, nil
+}
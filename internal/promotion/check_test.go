@@ -0,0 +1,189 @@
+package promotion_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/promotion"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+func TestNewCheck(t *testing.T) {
+	targets := []uuid.UUID{uuid.New(), uuid.New()}
+	for _, tc := range []struct {
+		name   string
+		config types.PromotionCheck
+		valid  bool
+	}{
+		{"unknown type", types.PromotionCheck{Type: "signature"}, false},
+		{"scan without severity", types.PromotionCheck{Type: types.PromotionCheckTypeVulnerabilityScan}, false},
+		{"scan with invalid severity", types.PromotionCheck{
+			Type:     types.PromotionCheckTypeVulnerabilityScan,
+			Severity: util.PtrTo(types.VulnerabilitySeverity("none")),
+		}, false},
+		{"scan", types.PromotionCheck{
+			Type:     types.PromotionCheckTypeVulnerabilityScan,
+			Severity: util.PtrTo(types.VulnerabilitySeverityHigh),
+		}, true},
+		{"staging without targets", types.PromotionCheck{Type: types.PromotionCheckTypeStagingDeployments}, false},
+		{"staging with too many required", types.PromotionCheck{
+			Type:                types.PromotionCheckTypeStagingDeployments,
+			DeploymentTargetIDs: targets,
+			MinCount:            util.PtrTo(3),
+		}, false},
+		{"staging with zero required", types.PromotionCheck{
+			Type:                types.PromotionCheckTypeStagingDeployments,
+			DeploymentTargetIDs: targets,
+			MinCount:            util.PtrTo(0),
+		}, false},
+		{"staging", types.PromotionCheck{
+			Type:                types.PromotionCheckTypeStagingDeployments,
+			DeploymentTargetIDs: targets,
+			MinCount:            util.PtrTo(2),
+		}, true},
+		{"approval with zero required", types.PromotionCheck{
+			Type:     types.PromotionCheckTypeManualApproval,
+			MinCount: util.PtrTo(0),
+		}, false},
+		{"approval with invalid role", types.PromotionCheck{
+			Type: types.PromotionCheckTypeManualApproval,
+			Role: util.PtrTo(types.UserRole("admin")),
+		}, false},
+		{"approval", types.PromotionCheck{
+			Type: types.PromotionCheckTypeManualApproval,
+			Role: util.PtrTo(types.UserRoleVendor),
+		}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			check, err := promotion.NewCheck(tc.config)
+			if tc.valid {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(check).NotTo(BeNil())
+			} else {
+				g.Expect(err).To(HaveOccurred())
+			}
+			g.Expect(promotion.ValidateChecks([]types.PromotionCheck{tc.config}) == nil).To(Equal(tc.valid))
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendorID := org.Vendors[0].ID
+	staging := testutil.NewDeploymentTarget(ctx, t, org.ID, vendorID)
+	revision := testutil.NewDeploymentRevision(ctx, t, staging)
+	version, err := db.GetApplicationVersion(ctx, revision.ApplicationVersionID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configs := []types.PromotionCheck{
+		{Type: types.PromotionCheckTypeVulnerabilityScan, Severity: util.PtrTo(types.VulnerabilitySeverityHigh)},
+		{Type: types.PromotionCheckTypeStagingDeployments, DeploymentTargetIDs: []uuid.UUID{staging.ID}},
+		{Type: types.PromotionCheckTypeManualApproval, Role: util.PtrTo(types.UserRoleVendor)},
+	}
+	evaluate := func() ([]bool, bool) {
+		results, passed, err := promotion.Evaluate(ctx, *version, configs)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(results).To(HaveLen(len(configs)))
+		return []bool{results[0].Passed, results[1].Passed, results[2].Passed}, passed
+	}
+	results, passed := evaluate()
+	g.Expect(results).To(Equal([]bool{false, false, false}))
+	g.Expect(passed).To(BeFalse())
+
+	g.Expect(db.CreateApplicationVersionScan(ctx, &types.ApplicationVersionScan{
+		ApplicationVersionID: version.ID, High: 1,
+	})).To(Succeed())
+	results, _ = evaluate()
+	g.Expect(results[0]).To(BeFalse())
+	// only the latest scan counts
+	scan := types.ApplicationVersionScan{ApplicationVersionID: version.ID, Low: 3}
+	g.Expect(db.CreateApplicationVersionScan(ctx, &scan)).To(Succeed())
+	exec(ctx, t, "UPDATE ApplicationVersionScan SET created_at = created_at + interval '1 minute' WHERE id = $1", scan.ID)
+	results, _ = evaluate()
+	g.Expect(results[0]).To(BeTrue())
+
+	// only the latest status of the current revision counts
+	addStatus(ctx, t, revision.ID, types.DeploymentStatusTypeOK, time.Minute)
+	results, _ = evaluate()
+	g.Expect(results[1]).To(BeTrue())
+	addStatus(ctx, t, revision.ID, types.DeploymentStatusTypeError, 2*time.Minute)
+	results, _ = evaluate()
+	g.Expect(results[1]).To(BeFalse())
+	addStatus(ctx, t, revision.ID, types.DeploymentStatusTypeOK, 3*time.Minute)
+	results, _ = evaluate()
+	g.Expect(results[1]).To(BeTrue())
+
+	approval := types.ApplicationVersionApproval{
+		ApplicationVersionID: version.ID, UserAccountID: vendorID, UserRole: types.UserRoleVendor,
+	}
+	g.Expect(db.CreateApplicationVersionApproval(ctx, &approval)).To(Succeed())
+	results, passed = evaluate()
+	g.Expect(results).To(Equal([]bool{true, true, true}))
+	g.Expect(passed).To(BeTrue())
+
+	// the staging deployment does not count anymore once it has been updated to another version
+	next := types.ApplicationVersion{
+		Name:            "2.0.0",
+		ApplicationID:   version.ApplicationID,
+		ComposeFileData: []byte("services:\n  app:\n    image: nginx\n"),
+	}
+	g.Expect(db.CreateApplicationVersion(ctx, &next)).To(Succeed())
+	nextRevision, err := db.CreateDeploymentRevision(ctx, &api.DeploymentRequest{
+		DeploymentID:         &revision.DeploymentID,
+		DeploymentTargetID:   staging.ID,
+		ApplicationVersionID: next.ID,
+		DockerType:           util.PtrTo(types.DockerTypeCompose),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	exec(ctx, t, "UPDATE DeploymentRevision SET created_at = created_at + interval '1 hour' WHERE id = $1",
+		nextRevision.ID)
+	results, passed = evaluate()
+	g.Expect(results).To(Equal([]bool{true, false, true}))
+	g.Expect(passed).To(BeFalse())
+}
+
+func TestEvaluateWithoutChecks(t *testing.T) {
+	g := NewWithT(t)
+	results, passed, err := promotion.Evaluate(context.Background(), types.ApplicationVersion{}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(BeEmpty())
+	g.Expect(passed).To(BeTrue())
+
+	_, _, err = promotion.Evaluate(context.Background(), types.ApplicationVersion{},
+		[]types.PromotionCheck{{Type: "signature"}})
+	g.Expect(err).To(HaveOccurred())
+}
+
+// addStatus reports a status for the revision. Statuses created in the same transaction share the same default
+// timestamp, so they are ordered by the given offset from now instead.
+func addStatus(
+	ctx context.Context,
+	t *testing.T,
+	revisionID uuid.UUID,
+	statusType types.DeploymentStatusType,
+	offset time.Duration,
+) {
+	t.Helper()
+	exec(ctx, t,
+		`INSERT INTO DeploymentRevisionStatus (deployment_revision_id, type, message, created_at)
+		VALUES ($1, $2, '', now() + make_interval(secs => $3))`,
+		revisionID, statusType, offset.Seconds())
+}
+
+func exec(ctx context.Context, t *testing.T, sql string, args ...any) {
+	t.Helper()
+	if _, err := internalctx.GetDb(ctx).Exec(ctx, sql, args...); err != nil {
+		t.Fatal(err)
+	}
+}
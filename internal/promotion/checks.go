@@ -0,0 +1,122 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+)
+
+type vulnerabilityScanCheck struct {
+	severity types.VulnerabilitySeverity
+}
+
+func newVulnerabilityScanCheck(config types.PromotionCheck) (Check, error) {
+	if config.Severity == nil {
+		return nil, validation.NewValidationFailedError("vulnerabilityScan check requires severity")
+	}
+	switch *config.Severity {
+	case types.VulnerabilitySeverityCritical, types.VulnerabilitySeverityHigh,
+		types.VulnerabilitySeverityMedium, types.VulnerabilitySeverityLow:
+		return &vulnerabilityScanCheck{severity: *config.Severity}, nil
+	default:
+		return nil, validation.NewValidationFailedError(fmt.Sprintf("invalid severity: %v", *config.Severity))
+	}
+}
+
+// Evaluate implements Check.
+func (c *vulnerabilityScanCheck) Evaluate(
+	ctx context.Context,
+	version types.ApplicationVersion,
+) (types.PromotionCheckResult, error) {
+	result := types.PromotionCheckResult{Type: types.PromotionCheckTypeVulnerabilityScan}
+	if scan, err := db.GetLatestApplicationVersionScan(ctx, version.ID); errors.Is(err, apierrors.ErrNotFound) {
+		result.Message = "no vulnerability scan has been reported for this version"
+	} else if err != nil {
+		return result, err
+	} else if count := scan.CountAtLeast(c.severity); count > 0 {
+		result.Message = fmt.Sprintf("latest scan has %v findings with severity %v or higher", count, c.severity)
+	} else {
+		result.Passed = true
+		result.Message = fmt.Sprintf("latest scan has no findings with severity %v or higher", c.severity)
+	}
+	return result, nil
+}
+
+type stagingDeploymentsCheck struct {
+	minCount            int
+	deploymentTargetIDs []uuid.UUID
+}
+
+func newStagingDeploymentsCheck(config types.PromotionCheck) (Check, error) {
+	if len(config.DeploymentTargetIDs) == 0 {
+		return nil, validation.NewValidationFailedError("stagingDeployments check requires deploymentTargetIds")
+	}
+	check := stagingDeploymentsCheck{minCount: 1, deploymentTargetIDs: config.DeploymentTargetIDs}
+	if config.MinCount != nil {
+		if *config.MinCount < 1 || *config.MinCount > len(config.DeploymentTargetIDs) {
+			return nil, validation.NewValidationFailedError(
+				"stagingDeployments minCount must be between 1 and the number of deploymentTargetIds")
+		}
+		check.minCount = *config.MinCount
+	}
+	return &check, nil
+}
+
+// Evaluate implements Check.
+func (c *stagingDeploymentsCheck) Evaluate(
+	ctx context.Context,
+	version types.ApplicationVersion,
+) (types.PromotionCheckResult, error) {
+	result := types.PromotionCheckResult{Type: types.PromotionCheckTypeStagingDeployments}
+	count, err := db.CountSuccessfulDeploymentTargetsForApplicationVersion(ctx, version.ID, c.deploymentTargetIDs)
+	if err != nil {
+		return result, err
+	}
+	result.Passed = count >= c.minCount
+	result.Message = fmt.Sprintf("deployed successfully to %v of %v required staging targets", count, c.minCount)
+	return result, nil
+}
+
+type manualApprovalCheck struct {
+	minCount int
+	role     *types.UserRole
+}
+
+func newManualApprovalCheck(config types.PromotionCheck) (Check, error) {
+	check := manualApprovalCheck{minCount: 1, role: config.Role}
+	if config.MinCount != nil {
+		if *config.MinCount < 1 {
+			return nil, validation.NewValidationFailedError("manualApproval minCount must be at least 1")
+		}
+		check.minCount = *config.MinCount
+	}
+	if config.Role != nil && *config.Role != types.UserRoleVendor && *config.Role != types.UserRoleCustomer {
+		return nil, validation.NewValidationFailedError(fmt.Sprintf("invalid role: %v", *config.Role))
+	}
+	return &check, nil
+}
+
+// Evaluate implements Check.
+func (c *manualApprovalCheck) Evaluate(
+	ctx context.Context,
+	version types.ApplicationVersion,
+) (types.PromotionCheckResult, error) {
+	result := types.PromotionCheckResult{Type: types.PromotionCheckTypeManualApproval}
+	count, err := db.CountApplicationVersionApprovals(ctx, version.ID, c.role)
+	if err != nil {
+		return result, err
+	}
+	result.Passed = count >= c.minCount
+	if c.role != nil {
+		result.Message = fmt.Sprintf("approved by %v of %v required users with role %v", count, c.minCount, *c.role)
+	} else {
+		result.Message = fmt.Sprintf("approved by %v of %v required users", count, c.minCount)
+	}
+	return result, nil
+}
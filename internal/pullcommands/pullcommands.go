@@ -0,0 +1,60 @@
+// Package pullcommands renders the commands that users copy to pull artifacts from the registry.
+package pullcommands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glasskube/distr/api"
+)
+
+const (
+	ToolDocker = "docker"
+	ToolHelm   = "helm"
+	ToolOras   = "oras"
+)
+
+// Repository returns the repository of an artifact as it is used in pull commands, e.g. registry.example.com/org/app.
+func Repository(registryHost, orgSlug, artifactName string) string {
+	return strings.Join([]string{strings.TrimSuffix(registryHost, "/"), orgSlug, artifactName}, "/")
+}
+
+// For returns the commands to pull reference of repository with docker, helm and oras. The digest-pinned variant of
+// each command uses digest instead of reference, so that it always pulls the same content even if a tag is moved.
+func For(repository, reference, digest string) []api.PullCommand {
+	return []api.PullCommand{
+		{
+			Tool:          ToolDocker,
+			Command:       fmt.Sprintf("docker pull %v", imageRef(repository, reference)),
+			DigestCommand: fmt.Sprintf("docker pull %v", imageRef(repository, digest)),
+		},
+		{
+			Tool:          ToolHelm,
+			Command:       helmPull(repository, reference),
+			DigestCommand: helmPull(repository, digest),
+		},
+		{
+			Tool:          ToolOras,
+			Command:       fmt.Sprintf("oras pull %v", imageRef(repository, reference)),
+			DigestCommand: fmt.Sprintf("oras pull %v", imageRef(repository, digest)),
+		},
+	}
+}
+
+func imageRef(repository, reference string) string {
+	if isDigest(reference) {
+		return repository + "@" + reference
+	}
+	return repository + ":" + reference
+}
+
+func helmPull(repository, reference string) string {
+	if isDigest(reference) {
+		return fmt.Sprintf("helm pull oci://%v@%v", repository, reference)
+	}
+	return fmt.Sprintf("helm pull oci://%v --version %v", repository, reference)
+}
+
+func isDigest(reference string) bool {
+	return strings.HasPrefix(reference, "sha256:")
+}
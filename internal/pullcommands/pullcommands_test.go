@@ -0,0 +1,38 @@
+package pullcommands_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/pullcommands"
+	. "github.com/onsi/gomega"
+)
+
+func TestFor(t *testing.T) {
+	g := NewWithT(t)
+	repo := pullcommands.Repository("registry.example.com/", "acme", "charts/app")
+	g.Expect(repo).To(Equal("registry.example.com/acme/charts/app"))
+	digest := "sha256:0123"
+
+	g.Expect(pullcommands.For(repo, "1.0.0", digest)).To(Equal([]api.PullCommand{
+		{
+			Tool:          pullcommands.ToolDocker,
+			Command:       "docker pull registry.example.com/acme/charts/app:1.0.0",
+			DigestCommand: "docker pull registry.example.com/acme/charts/app@sha256:0123",
+		},
+		{
+			Tool:          pullcommands.ToolHelm,
+			Command:       "helm pull oci://registry.example.com/acme/charts/app --version 1.0.0",
+			DigestCommand: "helm pull oci://registry.example.com/acme/charts/app@sha256:0123",
+		},
+		{
+			Tool:          pullcommands.ToolOras,
+			Command:       "oras pull registry.example.com/acme/charts/app:1.0.0",
+			DigestCommand: "oras pull registry.example.com/acme/charts/app@sha256:0123",
+		},
+	}))
+
+	for _, command := range pullcommands.For(repo, digest, digest) {
+		g.Expect(command.Command).To(Equal(command.DigestCommand))
+	}
+}
@@ -4,13 +4,21 @@ import (
 	"context"
 
 	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/authkey"
+	"github.com/glasskube/distr/internal/authn/authinfo"
 	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/registry/name"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 )
 
 type ArtifactAuditor interface {
-	AuditPull(ctx context.Context, name, reference string) error
+	// AuditPull records a request of the manifest of name with reference. The method distinguishes pulls (GET) from
+	// requests that only check whether the manifest exists (HEAD).
+	AuditPull(ctx context.Context, name, reference, method string) error
 }
 
 type auditor struct{}
@@ -20,18 +28,43 @@ func NewAuditor() ArtifactAuditor {
 }
 
 // AuditPull implements ArtifactAuditor.
-func (a *auditor) AuditPull(ctx context.Context, nameStr string, reference string) error {
+func (a *auditor) AuditPull(ctx context.Context, nameStr, reference, method string) error {
 	auth := auth.ArtifactsAuthentication.Require(ctx)
 	if name, err := name.Parse(nameStr); err != nil {
 		return err
 	} else if digestVersion, err := db.GetArtifactVersion(ctx, name.OrgName, name.ArtifactName, reference); err != nil {
 		return err
 	} else {
+		var userID *uuid.UUID
+		if !auth.IsAnonymous() && !auth.IsServiceAccount() {
+			userID = util.PtrTo(auth.CurrentUserID())
+		}
 		return db.CreateArtifactPullLogEntry(
 			ctx,
 			digestVersion.ID,
-			auth.CurrentUserID(),
+			userID,
+			auth.CurrentServiceAccountID(),
 			internalctx.GetRequestIPAddress(ctx),
+			method,
+			tokenSubject(auth),
 		)
 	}
 }
+
+// tokenSubject returns the subject of the token that authenticated the request, see types.TokenSubjectAccessToken.
+func tokenSubject(auth *authinfo.DbAuthInfo) string {
+	if auth.IsAnonymous() {
+		return types.TokenSubjectAnonymous
+	}
+	switch token := auth.Token().(type) {
+	case authkey.Key:
+		if auth.IsServiceAccount() {
+			return types.TokenSubjectServiceAccount + token.DisplayPrefix()
+		}
+		return types.TokenSubjectAccessToken + token.DisplayPrefix()
+	case jwt.Token:
+		return types.TokenSubjectDeploymentTarget + token.Subject()
+	default:
+		return ""
+	}
+}
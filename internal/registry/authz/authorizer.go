@@ -3,13 +3,16 @@ package authz
 import (
 	"context"
 	"errors"
+	"slices"
 
 	"github.com/glasskube/distr/internal/apierrors"
 	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/authn/authinfo"
 	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/registry/name"
 	"github.com/glasskube/distr/internal/types"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
 )
 
 type Action string
@@ -23,7 +26,8 @@ const (
 type Authorizer interface {
 	Authorize(ctx context.Context, name string, action Action) error
 	AuthorizeReference(ctx context.Context, name string, reference string, action Action) error
-	AuthorizeBlob(ctx context.Context, digest v1.Hash, action Action) error
+	AuthorizeBlob(ctx context.Context, name string, digest v1.Hash, action Action) error
+	AuthorizeCatalog(ctx context.Context) error
 }
 
 type authorizer struct{}
@@ -35,8 +39,13 @@ func NewAuthorizer() Authorizer {
 // Authorize implements ArtifactsAuthorizer.
 func (a *authorizer) Authorize(ctx context.Context, nameStr string, action Action) error {
 	auth := auth.ArtifactsAuthentication.Require(ctx)
-	if action == ActionWrite && *auth.CurrentUserRole() != types.UserRoleVendor {
+	// anonymous clients may only pull manifests and blobs, which excludes listing tags and mounting blobs
+	if auth.IsAnonymous() {
 		return ErrAccessDenied
+	} else if action == ActionWrite && *auth.CurrentUserRole() != types.UserRoleVendor {
+		return ErrAccessDenied
+	} else if err := checkRegistryScopes(auth, nameStr, action); err != nil {
+		return err
 	}
 
 	org := auth.CurrentOrg()
@@ -51,8 +60,12 @@ func (a *authorizer) Authorize(ctx context.Context, nameStr string, action Actio
 // AuthorizeReference implements ArtifactsAuthorizer.
 func (a *authorizer) AuthorizeReference(ctx context.Context, nameStr string, reference string, action Action) error {
 	auth := auth.ArtifactsAuthentication.Require(ctx)
-	if action == ActionWrite && *auth.CurrentUserRole() != types.UserRoleVendor {
+	if auth.IsAnonymous() {
+		return a.authorizeAnonymous(ctx, auth, nameStr, action)
+	} else if action == ActionWrite && *auth.CurrentUserRole() != types.UserRoleVendor {
 		return ErrAccessDenied
+	} else if err := checkRegistryScopes(auth, nameStr, action); err != nil {
+		return err
 	}
 
 	org := auth.CurrentOrg()
@@ -64,7 +77,8 @@ func (a *authorizer) AuthorizeReference(ctx context.Context, nameStr string, ref
 		if org.HasFeature(types.FeatureLicensing) {
 			err := db.CheckLicenseForArtifact(ctx, name.OrgName, name.ArtifactName, reference, auth.CurrentUserID())
 			if errors.Is(err, apierrors.ErrForbidden) {
-				return ErrAccessDenied
+				// public artifacts can be pulled by everyone, including customers without a license
+				return checkPublicArtifact(ctx, *name)
 			} else if err != nil {
 				return err
 			}
@@ -74,16 +88,21 @@ func (a *authorizer) AuthorizeReference(ctx context.Context, nameStr string, ref
 }
 
 // AuthorizeBlob implements ArtifactsAuthorizer.
-func (a *authorizer) AuthorizeBlob(ctx context.Context, digest v1.Hash, action Action) error {
+func (a *authorizer) AuthorizeBlob(ctx context.Context, nameStr string, digest v1.Hash, action Action) error {
 	auth := auth.ArtifactsAuthentication.Require(ctx)
+	if err := checkBlobRegistryScopes(ctx, auth, nameStr, digest, action); err != nil {
+		return err
+	}
 
 	if *auth.CurrentUserRole() != types.UserRoleVendor {
 		if action == ActionWrite {
 			return ErrAccessDenied
+		} else if auth.IsAnonymous() {
+			return checkPublicArtifactBlob(ctx, digest, *auth.CurrentOrgID())
 		} else if auth.CurrentOrg().HasFeature(types.FeatureLicensing) {
 			err := db.CheckLicenseForArtifactBlob(ctx, digest.String(), auth.CurrentUserID())
 			if errors.Is(err, apierrors.ErrForbidden) {
-				return ErrAccessDenied
+				return checkPublicArtifactBlob(ctx, digest, *auth.CurrentOrgID())
 			} else if err != nil {
 				return err
 			}
@@ -91,3 +110,109 @@ func (a *authorizer) AuthorizeBlob(ctx context.Context, digest v1.Hash, action A
 	}
 	return nil
 }
+
+// AuthorizeCatalog implements ArtifactsAuthorizer. Clients with registry scopes may not list the catalog, because it
+// contains repositories outside of their scopes.
+func (a *authorizer) AuthorizeCatalog(ctx context.Context) error {
+	if auth := auth.ArtifactsAuthentication.Require(ctx); auth.IsAnonymous() || auth.RegistryScopes() != nil {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// registryAction returns the action that the registry scopes of a client must allow for action.
+func (action Action) registryAction() types.RegistryAction {
+	if action == ActionWrite {
+		return types.RegistryActionPush
+	}
+	return types.RegistryActionPull
+}
+
+// checkRegistryScopes returns ErrAccessDenied if the client is limited to registry scopes that do not allow action on
+// the repository.
+func checkRegistryScopes(auth *authinfo.DbAuthInfo, nameStr string, action Action) error {
+	if !auth.RegistryScopes().Allow(nameStr, action.registryAction()) {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// checkBlobRegistryScopes is like checkRegistryScopes for blobs, which can be requested from any repository of the
+// organization by their digest. Clients with registry scopes may therefore only read blobs that actually belong to
+// the repository. Whether a blob exists may be checked with push access as well, which clients do before uploading.
+func checkBlobRegistryScopes(
+	ctx context.Context,
+	auth *authinfo.DbAuthInfo,
+	nameStr string,
+	digest v1.Hash,
+	action Action,
+) error {
+	scopes := auth.RegistryScopes()
+	if scopes == nil {
+		return nil
+	}
+	switch action {
+	case ActionStat:
+		if !scopes.Allow(nameStr, types.RegistryActionPull) && !scopes.Allow(nameStr, types.RegistryActionPush) {
+			return ErrAccessDenied
+		}
+	case ActionRead:
+		if !scopes.Allow(nameStr, types.RegistryActionPull) {
+			return ErrAccessDenied
+		} else if name, err := name.Parse(nameStr); err != nil {
+			return err
+		} else if err := db.CheckArtifactForBlob(ctx, name.OrgName, name.ArtifactName, types.Digest(digest)); errors.Is(
+			err, apierrors.ErrNotFound,
+		) {
+			return ErrAccessDenied
+		} else if err != nil {
+			return err
+		}
+	default:
+		return checkRegistryScopes(auth, nameStr, action)
+	}
+	return nil
+}
+
+// authorizeAnonymous allows anonymous clients to pull from the public repositories in their pull scope.
+func (a *authorizer) authorizeAnonymous(
+	ctx context.Context,
+	auth *authinfo.DbAuthInfo,
+	nameStr string,
+	action Action,
+) error {
+	if action != ActionRead && action != ActionStat {
+		return ErrAccessDenied
+	}
+	org := auth.CurrentOrg()
+	if name, err := name.Parse(nameStr); err != nil {
+		return err
+	} else if org.Slug == nil || *org.Slug != name.OrgName {
+		return ErrAccessDenied
+	} else if !slices.Contains(auth.AnonymousPullScope(), name.String()) {
+		return ErrAccessDenied
+	} else {
+		return checkPublicArtifact(ctx, *name)
+	}
+}
+
+// checkPublicArtifact returns ErrAccessDenied unless the artifact with the given name exists and is public.
+func checkPublicArtifact(ctx context.Context, n name.Name) error {
+	artifact, err := db.GetArtifactByName(ctx, n.OrgName, n.ArtifactName)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		return ErrAccessDenied
+	} else if err != nil {
+		return err
+	} else if !artifact.Public {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func checkPublicArtifactBlob(ctx context.Context, digest v1.Hash, orgID uuid.UUID) error {
+	if err := db.CheckPublicArtifactBlob(ctx, digest.String(), orgID); errors.Is(err, apierrors.ErrForbidden) {
+		return ErrAccessDenied
+	} else {
+		return err
+	}
+}
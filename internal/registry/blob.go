@@ -19,11 +19,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"path"
 	"strings"
 
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
 	"github.com/glasskube/distr/internal/registry/authz"
 	"github.com/glasskube/distr/internal/registry/blob"
 	registryerror "github.com/glasskube/distr/internal/registry/error"
@@ -56,9 +57,11 @@ func isBlob(req *http.Request) bool {
 
 // blobs
 type blobs struct {
-	blobHandler blob.BlobHandler
-	authz       authz.Authorizer
-	log         *zap.SugaredLogger
+	blobHandler  blob.BlobHandler
+	authz        authz.Authorizer
+	log          *zap.SugaredLogger
+	nameMaxDepth int
+	pullThrough  *pullThrough
 }
 
 func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
@@ -69,11 +72,7 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 	}
 	// Must have a path of form /v2/{name}/blobs/{upload,sha256:}
 	if len(elem) < 4 {
-		return &regError{
-			Status:  http.StatusBadRequest,
-			Code:    "NAME_INVALID",
-			Message: "blobs must be attached to a repo",
-		}
+		return regErrBlobNameMissing
 	}
 	target := elem[len(elem)-1]
 	service := elem[len(elem)-2]
@@ -81,23 +80,29 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 	contentRange := req.Header.Get("Content-Range")
 	rangeHeader := req.Header.Get("Range")
 	repo := req.URL.Host + path.Join(elem[1:len(elem)-2]...)
+	if service == uploads {
+		// path is of form /v2/{name}/blobs/uploads/{session}
+		repo = req.URL.Host + path.Join(elem[1:len(elem)-3]...)
+	}
 
 	switch req.Method {
 	case http.MethodHead:
-		if h, err := v1.NewHash(target); err != nil {
-			return regErrDigestInvalid
-		} else if err := b.authz.AuthorizeBlob(req.Context(), h, authz.ActionStat); err != nil {
+		if h, digestErr := parseDigest(target); digestErr != nil {
+			return digestErr
+		} else if err := b.authz.AuthorizeBlob(req.Context(), repo, h, authz.ActionStat); err != nil {
 			if errors.Is(err, authz.ErrAccessDenied) {
 				return regErrDenied
 			} else if errors.Is(err, registryerror.ErrInvalidArtifactName) {
 				return regErrNameInvalid
 			}
 			return regErrInternal(err)
+		} else {
+			b.fetchFromUpstream(req.Context(), repo, h)
 		}
 		return b.handleHead(resp, req, repo, target)
 	case http.MethodGet:
-		if h, err := v1.NewHash(target); err == nil {
-			if err := b.authz.AuthorizeBlob(req.Context(), h, authz.ActionRead); err != nil {
+		if h, digestErr := parseDigest(target); digestErr == nil {
+			if err := b.authz.AuthorizeBlob(req.Context(), repo, h, authz.ActionRead); err != nil {
 				if errors.Is(err, authz.ErrAccessDenied) {
 					return regErrDenied
 				} else if errors.Is(err, registryerror.ErrInvalidArtifactName) {
@@ -105,11 +110,15 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 				}
 				return regErrInternal(err)
 			}
+			b.fetchFromUpstream(req.Context(), repo, h)
 		} else if _, err := uuid.Parse(target); err != nil {
-			return regErrDigestInvalid
+			return digestErr
 		}
 		return b.handleGet(resp, req, repo, target, rangeHeader)
 	case http.MethodPost:
+		if err := validateRepoName(repo, b.nameMaxDepth); err != nil {
+			return err
+		}
 		if err := b.authz.Authorize(req.Context(), repo, authz.ActionWrite); err != nil {
 			if errors.Is(err, authz.ErrAccessDenied) {
 				return regErrDenied
@@ -118,8 +127,23 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 			}
 			return regErrInternal(err)
 		}
-		return b.handlePost(resp, req, repo, target, digest)
+		mount, from := req.URL.Query().Get("mount"), req.URL.Query().Get("from")
+		if mount != "" && from != "" {
+			if err := validateRepoName(from, b.nameMaxDepth); err != nil {
+				mount = ""
+			} else if err := b.authz.Authorize(req.Context(), from, authz.ActionRead); err != nil {
+				if !errors.Is(err, authz.ErrAccessDenied) && !errors.Is(err, registryerror.ErrInvalidArtifactName) {
+					return regErrInternal(err)
+				}
+				// blobs of repositories that can not be read are uploaded again instead
+				mount = ""
+			}
+		}
+		return b.handlePost(resp, req, repo, target, digest, mount, from)
 	case http.MethodPatch:
+		if err := validateRepoName(repo, b.nameMaxDepth); err != nil {
+			return err
+		}
 		if err := b.authz.Authorize(req.Context(), repo, authz.ActionWrite); err != nil {
 			if errors.Is(err, authz.ErrAccessDenied) {
 				return regErrDenied
@@ -130,9 +154,12 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 		return b.handlePatch(resp, req, target, service, contentRange)
 	case http.MethodPut:
-		if h, err := v1.NewHash(digest); err != nil {
-			return regErrDigestInvalid
-		} else if err := b.authz.AuthorizeBlob(req.Context(), h, authz.ActionWrite); err != nil {
+		if err := validateRepoName(repo, b.nameMaxDepth); err != nil {
+			return err
+		}
+		if h, digestErr := parseDigest(digest); digestErr != nil {
+			return digestErr
+		} else if err := b.authz.AuthorizeBlob(req.Context(), repo, h, authz.ActionWrite); err != nil {
 			if errors.Is(err, authz.ErrAccessDenied) {
 				return regErrDenied
 			} else if errors.Is(err, registryerror.ErrInvalidArtifactName) {
@@ -142,7 +169,7 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 		return b.handlePut(resp, req, service, repo, target, digest, contentRange)
 	// case http.MethodDelete:
-	// 	if err := b.authz.AuthorizeBlob(req.Context(), targetHash, authz.ActionWrite); err != nil {
+	// 	if err := b.authz.AuthorizeBlob(req.Context(), repo, targetHash, authz.ActionWrite); err != nil {
 	// 		if errors.Is(err, authz.ErrAccessDenied) {
 	// 			return regErrDenied
 	// 		}
@@ -150,17 +177,18 @@ func (b *blobs) handle(resp http.ResponseWriter, req *http.Request) *regError {
 	// 	}
 	// 	return b.handleDelete(resp, req, repo, target)
 	default:
-		return regErrMethodUnknown
+		return regErrMethodNotAllowed
 	}
 }
 
 func (b *blobs) handleHead(resp http.ResponseWriter, req *http.Request, repo, target string) *regError {
-	h, err := v1.NewHash(target)
-	if err != nil {
-		return regErrDigestInvalid
+	h, digestErr := parseDigest(target)
+	if digestErr != nil {
+		return digestErr
 	}
 
 	var size int64
+	var err error
 	if bsh, ok := b.blobHandler.(blob.BlobStatHandler); ok {
 		size, err = bsh.Stat(req.Context(), repo, h)
 		if errors.Is(err, blob.ErrNotFound) {
@@ -199,10 +227,10 @@ func (b *blobs) handleHead(resp http.ResponseWriter, req *http.Request, repo, ta
 }
 
 func (b *blobs) handleGet(resp http.ResponseWriter, req *http.Request, repo, target, rangeHeader string) *regError {
-	h, err := v1.NewHash(target)
+	h, err := blob.ParseDigest(target)
 	if err != nil {
-		if id, err := uuid.Parse(target); err != nil {
-			return regErrDigestInvalid
+		if id, uerr := uuid.Parse(target); uerr != nil {
+			return parseDigestError(err)
 		} else if bph, ok := b.blobHandler.(blob.BlobPutHandler); !ok {
 			return regErrUnsupported
 		} else if uploaded, err := bph.GetUploadedPartsSize(req.Context(), id.String()); err != nil {
@@ -230,6 +258,19 @@ func (b *blobs) handleGet(resp http.ResponseWriter, req *http.Request, repo, tar
 			return regErrInternal(err)
 		}
 
+		if brh, ok := b.blobHandler.(blob.BlobRangeHandler); ok {
+			resp.Header().Set("Accept-Ranges", "bytes")
+			if rangeHeader != "" {
+				start, end, err := parseByteRange(rangeHeader, size)
+				if errors.Is(err, errRangeNotSatisfiable) {
+					resp.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+					return regErrRangeInvalid(fmt.Sprintf("range %q is not satisfiable for size %d", rangeHeader, size))
+				} else if err == nil {
+					return b.handleGetRange(resp, req, brh, repo, h, start, end, size)
+				}
+			}
+		}
+
 		rc, err := b.blobHandler.Get(req.Context(), repo, h, true)
 		if errors.Is(err, blob.ErrNotFound) {
 			return regErrBlobUnknown
@@ -267,47 +308,10 @@ func (b *blobs) handleGet(resp http.ResponseWriter, req *http.Request, repo, tar
 		r = &buf
 	}
 
-	if rangeHeader != "" {
-		start, end := int64(0), int64(0)
-		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil {
-			return &regError{
-				Status:  http.StatusRequestedRangeNotSatisfiable,
-				Code:    "BLOB_UNKNOWN",
-				Message: "We don't understand your Range",
-			}
-		}
-
-		n := (end + 1) - start
-		if ra, ok := r.(io.ReaderAt); ok {
-			if end+1 > size {
-				return &regError{
-					Status:  http.StatusRequestedRangeNotSatisfiable,
-					Code:    "BLOB_UNKNOWN",
-					Message: fmt.Sprintf("range end %d > %d size", end+1, size),
-				}
-			}
-			r = io.NewSectionReader(ra, start, n)
-		} else {
-			if _, err := io.CopyN(io.Discard, r, start); err != nil {
-				return &regError{
-					Status:  http.StatusRequestedRangeNotSatisfiable,
-					Code:    "BLOB_UNKNOWN",
-					Message: fmt.Sprintf("Failed to discard %d bytes", start),
-				}
-			}
-
-			r = io.LimitReader(r, n)
-		}
-
-		resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
-		resp.Header().Set("Content-Length", fmt.Sprint(n))
-		resp.Header().Set("Docker-Content-Digest", h.String())
-		resp.WriteHeader(http.StatusPartialContent)
-	} else {
-		resp.Header().Set("Content-Length", fmt.Sprint(size))
-		resp.Header().Set("Docker-Content-Digest", h.String())
-		resp.WriteHeader(http.StatusOK)
-	}
+	// Range headers that have not been served above are ignored and the whole blob is served.
+	resp.Header().Set("Content-Length", fmt.Sprint(size))
+	resp.Header().Set("Docker-Content-Digest", h.String())
+	resp.WriteHeader(http.StatusOK)
 
 	if _, err := io.Copy(resp, r); err != nil {
 		return regErrInternal(err)
@@ -315,7 +319,13 @@ func (b *blobs) handleGet(resp http.ResponseWriter, req *http.Request, repo, tar
 	return nil
 }
 
-func (b *blobs) handlePost(resp http.ResponseWriter, req *http.Request, repo, target, digest string) *regError {
+// handlePost uploads a blob in a single request if digest is set, or mounts the blob mount from the repository from if
+// both are set. Otherwise, and if the blob can not be mounted, an upload session is started.
+func (b *blobs) handlePost(
+	resp http.ResponseWriter,
+	req *http.Request,
+	repo, target, digest, mount, from string,
+) *regError {
 	bph, ok := b.blobHandler.(blob.BlobPutHandler)
 	if !ok {
 		return regErrUnsupported
@@ -324,17 +334,13 @@ func (b *blobs) handlePost(resp http.ResponseWriter, req *http.Request, repo, ta
 	// It is weird that this is "target" instead of "service", but
 	// that's how the index math works out above.
 	if target != uploads {
-		return &regError{
-			Status:  http.StatusBadRequest,
-			Code:    "METHOD_UNKNOWN",
-			Message: fmt.Sprintf("POST to /blobs must be followed by /uploads, got %s", target),
-		}
+		return regErrRouteUnknown(fmt.Sprintf("POST to /blobs must be followed by /uploads, got %s", target))
 	}
 
 	if digest != "" {
-		h, err := v1.NewHash(digest)
-		if err != nil {
-			return regErrDigestInvalid
+		h, digestErr := parseDigest(digest)
+		if digestErr != nil {
+			return digestErr
 		}
 
 		vrc, err := verify.ReadCloser(req.Body, req.ContentLength, h)
@@ -343,10 +349,12 @@ func (b *blobs) handlePost(resp http.ResponseWriter, req *http.Request, repo, ta
 		}
 		defer vrc.Close()
 
-		if err = bph.Put(req.Context(), repo, h, "", vrc); err != nil {
-			if errors.As(err, &verify.Error{}) {
-				log.Printf("Digest mismatch: %v", err)
+		if err = bph.Put(req.Context(), repo, h, req.Header.Get("Content-Type"), vrc); err != nil {
+			if errors.As(err, &verify.Error{}) || errors.Is(err, blob.ErrDigestMismatch) {
+				internalctx.GetLogger(req.Context()).Info("blob digest mismatch", zap.Error(err))
 				return regErrDigestMismatch
+			} else if errors.Is(err, apierrors.ErrQuotaExceeded) {
+				return regErrDeniedStorageQuotaExceeded
 			}
 			return regErrInternal(err)
 		}
@@ -356,6 +364,25 @@ func (b *blobs) handlePost(resp http.ResponseWriter, req *http.Request, repo, ta
 		return nil
 	}
 
+	if mount != "" {
+		h, digestErr := parseDigest(mount)
+		if digestErr != nil {
+			return digestErr
+		}
+		if bmh, ok := b.blobHandler.(blob.BlobMountHandler); ok {
+			if err := bmh.Mount(req.Context(), repo, from, h); err == nil {
+				resp.Header().Set("Docker-Content-Digest", h.String())
+				resp.Header().Set("Location", req.URL.JoinPath("..", h.String()).Path)
+				resp.WriteHeader(http.StatusCreated)
+				return nil
+			} else if errors.Is(err, apierrors.ErrQuotaExceeded) {
+				return regErrDeniedStorageQuotaExceeded
+			} else if !errors.Is(err, blob.ErrNotFound) {
+				return regErrInternal(err)
+			}
+		}
+	}
+
 	if id, err := bph.StartSession(req.Context(), repo); err != nil {
 		return regErrInternal(err)
 	} else {
@@ -377,31 +404,22 @@ func (b *blobs) handlePatch(
 	}
 
 	if service != uploads {
-		return &regError{
-			Status:  http.StatusBadRequest,
-			Code:    "METHOD_UNKNOWN",
-			Message: fmt.Sprintf("PATCH to /blobs must be followed by /uploads, got %s", service),
-		}
+		return regErrRouteUnknown(fmt.Sprintf("PATCH to /blobs must be followed by /uploads, got %s", service))
 	}
 
-	var start, end int64 = 0, 0
+	var start int64
 	if contentRange != "" {
-		if _, err := fmt.Sscanf(contentRange, "%d-%d", &start, &end); err != nil {
-			return &regError{
-				Status:  http.StatusRequestedRangeNotSatisfiable,
-				Code:    "BLOB_UPLOAD_UNKNOWN",
-				Message: "We don't understand your Content-Range",
-			}
+		var regErr *regError
+		if start, _, regErr = parseUploadRange(contentRange); regErr != nil {
+			return regErr
 		}
 	}
 
-	size, err := bph.PutChunk(req.Context(), target, req.Body, start)
+	size, err := bph.PutChunk(req.Context(), target, req.Header.Get("Content-Type"), req.Body, start)
 	if errors.Is(err, blob.ErrBadUpload) {
-		return &regError{
-			Status:  http.StatusRequestedRangeNotSatisfiable,
-			Code:    "BLOB_UPLOAD_INVALID",
-			Message: err.Error(),
-		}
+		return regErrBlobUploadInvalid(err.Error())
+	} else if errors.Is(err, apierrors.ErrQuotaExceeded) {
+		return regErrDeniedStorageQuotaExceeded
 	} else if err != nil {
 		return regErrInternal(err)
 	}
@@ -412,6 +430,17 @@ func (b *blobs) handlePatch(
 	return nil
 }
 
+// parseUploadRange parses the Content-Range of an upload chunk, which has the form <start>-<end>. Positions that do not
+// fit into an int64 or are negative are rejected instead of being truncated.
+func parseUploadRange(contentRange string) (start, end int64, regErr *regError) {
+	if _, err := fmt.Sscanf(contentRange, "%d-%d", &start, &end); err != nil {
+		return 0, 0, regErrBlobUploadInvalid("We don't understand your Content-Range")
+	} else if start < 0 || end < 0 {
+		return 0, 0, regErrBlobUploadInvalid("Content-Range must not be negative")
+	}
+	return start, end, nil
+}
+
 func (b *blobs) handlePut(
 	resp http.ResponseWriter,
 	req *http.Request,
@@ -423,57 +452,47 @@ func (b *blobs) handlePut(
 	}
 
 	if service != uploads {
-		return &regError{
-			Status:  http.StatusBadRequest,
-			Code:    "METHOD_UNKNOWN",
-			Message: fmt.Sprintf("PUT to /blobs must be followed by /uploads, got %s", service),
-		}
+		return regErrRouteUnknown(fmt.Sprintf("PUT to /blobs must be followed by /uploads, got %s", service))
 	}
 
 	if digest == "" {
-		return &regError{
-			Status:  http.StatusBadRequest,
-			Code:    "DIGEST_INVALID",
-			Message: "digest not specified",
-		}
+		return regErrDigestMissing
 	}
 
-	h, err := v1.NewHash(digest)
-	if err != nil {
-		return regErrDigestInvalid
+	h, digestErr := parseDigest(digest)
+	if digestErr != nil {
+		return digestErr
 	}
 
 	if req.ContentLength > 0 {
-		var start, end int64 = 0, 0
+		var start, end int64
 		if contentRange != "" {
-			if _, err := fmt.Sscanf(contentRange, "%d-%d", &start, &end); err != nil {
-				return &regError{
-					Status:  http.StatusRequestedRangeNotSatisfiable,
-					Code:    "BLOB_UPLOAD_UNKNOWN",
-					Message: "We don't understand your Content-Range",
-				}
+			var regErr *regError
+			if start, end, regErr = parseUploadRange(contentRange); regErr != nil {
+				return regErr
 			}
 		}
-		size, err := bph.PutChunk(req.Context(), target, req.Body, start)
+		size, err := bph.PutChunk(req.Context(), target, req.Header.Get("Content-Type"), req.Body, start)
 		if errors.Is(err, blob.ErrBadUpload) {
-			return &regError{
-				Status:  http.StatusRequestedRangeNotSatisfiable,
-				Code:    "BLOB_UPLOAD_INVALID",
-				Message: err.Error(),
-			}
+			return regErrBlobUploadInvalid(err.Error())
+		} else if errors.Is(err, apierrors.ErrQuotaExceeded) {
+			return regErrDeniedStorageQuotaExceeded
 		} else if err != nil {
 			return regErrInternal(err)
 		} else if contentRange != "" && size != end {
-			return &regError{
-				Status:  http.StatusRequestedRangeNotSatisfiable,
-				Code:    "BLOB_UPLOAD_INVALID",
-				Message: "size of uploaded chunks does not match requested range",
-			}
+			return regErrBlobUploadInvalid("size of uploaded chunks does not match requested range")
 		}
 	}
 
-	err = bph.CompleteSession(req.Context(), repo, target, h)
-	if err != nil {
+	err := bph.CompleteSession(req.Context(), repo, target, h)
+	if errors.Is(err, blob.ErrDigestMismatch) {
+		internalctx.GetLogger(req.Context()).Info("blob digest mismatch", zap.Error(err))
+		return regErrDigestMismatch
+	} else if errors.Is(err, blob.ErrBadUpload) {
+		return regErrBlobUploadUnknown(err)
+	} else if errors.Is(err, apierrors.ErrQuotaExceeded) {
+		return regErrDeniedStorageQuotaExceeded
+	} else if err != nil {
 		return regErrInternal(err)
 	}
 
@@ -483,6 +502,22 @@ func (b *blobs) handlePut(
 	return nil
 }
 
+// parseDigest parses the digest of a blob. Only sha256 and sha512 digests are supported.
+func parseDigest(digest string) (v1.Hash, *regError) {
+	if h, err := blob.ParseDigest(digest); err != nil {
+		return h, parseDigestError(err)
+	} else {
+		return h, nil
+	}
+}
+
+func parseDigestError(err error) *regError {
+	if errors.Is(err, blob.ErrUnsupportedDigest) {
+		return regErrDigestUnsupported
+	}
+	return regErrDigestInvalid
+}
+
 // func (b *blobs) handleDelete(resp http.ResponseWriter, req *http.Request, repo, target string) *regError {
 // 	bdh, ok := b.blobHandler.(blob.BlobDeleteHandler)
 // 	if !ok {
@@ -0,0 +1,84 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DefaultContentType is recorded for blobs that were uploaded without a content type.
+const DefaultContentType = "application/octet-stream"
+
+// ParseDigest parses a digest of the form algorithm:hex. In contrast to v1.NewHash, it also accepts sha512, which the
+// OCI distribution specification allows for blobs. An error wrapping ErrUnsupportedDigest is returned for any other
+// algorithm.
+func ParseDigest(s string) (v1.Hash, error) {
+	algorithm, encoded, ok := strings.Cut(s, ":")
+	if !ok {
+		return v1.Hash{}, fmt.Errorf("cannot parse digest: %q", s)
+	}
+	hasher, err := Hasher(algorithm)
+	if err != nil {
+		return v1.Hash{}, err
+	} else if len(encoded) != hex.EncodedLen(hasher.Size()) || strings.Trim(encoded, "0123456789abcdef") != "" {
+		return v1.Hash{}, fmt.Errorf("invalid %v digest: %q", algorithm, s)
+	}
+	return v1.Hash{Algorithm: algorithm, Hex: encoded}, nil
+}
+
+// Hasher returns a hash.Hash for the digest algorithm, which must be either sha256 or sha512.
+func Hasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedDigest, algorithm)
+	}
+}
+
+// DigestWriter computes the digest of everything written to it, so that uploads can be verified while they are
+// streamed to the storage backend.
+type DigestWriter struct {
+	hasher hash.Hash
+	want   v1.Hash
+	size   int64
+}
+
+var _ io.Writer = &DigestWriter{}
+
+// NewDigestWriter returns a DigestWriter that verifies contents against want.
+func NewDigestWriter(want v1.Hash) (*DigestWriter, error) {
+	if hasher, err := Hasher(want.Algorithm); err != nil {
+		return nil, err
+	} else {
+		return &DigestWriter{hasher: hasher, want: want}, nil
+	}
+}
+
+// Write implements io.Writer.
+func (w *DigestWriter) Write(p []byte) (int, error) {
+	n, err := w.hasher.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Size returns the number of bytes written so far.
+func (w *DigestWriter) Size() int64 {
+	return w.size
+}
+
+// Verify returns an error wrapping ErrDigestMismatch if the contents written so far do not match the expected digest.
+func (w *DigestWriter) Verify() error {
+	if got := hex.EncodeToString(w.hasher.Sum(nil)); got != w.want.Hex {
+		return NewErrDigestMismatch(w.want, w.want.Algorithm+":"+got)
+	}
+	return nil
+}
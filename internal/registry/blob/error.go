@@ -3,6 +3,8 @@ package blob
 import (
 	"errors"
 	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // RedirectError represents a signal that the blob handler doesn't have the blob
@@ -26,3 +28,14 @@ var ErrBadUpload = errors.New("bad upload")
 func NewErrBadUpload(msg string) error {
 	return fmt.Errorf("%w: %v", ErrBadUpload, msg)
 }
+
+// ErrDigestMismatch is returned by BlobPutHandler implementations if the uploaded contents do not match the
+// digest provided by the client.
+var ErrDigestMismatch = errors.New("digest does not match contents")
+
+func NewErrDigestMismatch(want v1.Hash, got string) error {
+	return fmt.Errorf("%w: got %v, want %v", ErrDigestMismatch, got, want)
+}
+
+// ErrUnsupportedDigest is returned for digests with an algorithm other than sha256 and sha512.
+var ErrUnsupportedDigest = errors.New("unsupported digest algorithm")
@@ -3,30 +3,59 @@ package inmemory
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"sync"
 
 	"github.com/glasskube/distr/internal/registry/and"
 	"github.com/glasskube/distr/internal/registry/blob"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
 )
 
+type blobMetadata struct {
+	size        int64
+	contentType string
+}
+
+type uploadSession struct {
+	data        []byte
+	contentType string
+}
+
 type blobHandler struct {
-	m    map[string][]byte
-	lock sync.Mutex
+	m        map[string][]byte
+	metadata map[string]blobMetadata
+	sessions map[string]*uploadSession
+	lock     sync.Mutex
 }
 
-func NewBlobHandler() blob.BlobHandler { return &blobHandler{m: map[string][]byte{}} }
+var (
+	_ blob.BlobHandler       = &blobHandler{}
+	_ blob.BlobStatHandler   = &blobHandler{}
+	_ blob.BlobRangeHandler  = &blobHandler{}
+	_ blob.BlobPutHandler    = &blobHandler{}
+	_ blob.BlobMountHandler  = &blobHandler{}
+	_ blob.BlobDeleteHandler = &blobHandler{}
+)
+
+func NewBlobHandler() blob.BlobHandler {
+	return &blobHandler{
+		m:        map[string][]byte{},
+		metadata: map[string]blobMetadata{},
+		sessions: map[string]*uploadSession{},
+	}
+}
 
 func (m *blobHandler) Stat(_ context.Context, _ string, h v1.Hash) (int64, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	b, found := m.m[h.String()]
+	md, found := m.metadata[h.String()]
 	if !found {
 		return 0, blob.ErrNotFound
 	}
-	return int64(len(b)), nil
+	return md.size, nil
 }
 
 func (m *blobHandler) Get(_ context.Context, _ string, h v1.Hash, _ bool) (io.ReadCloser, error) {
@@ -40,18 +69,131 @@ func (m *blobHandler) Get(_ context.Context, _ string, h v1.Hash, _ bool) (io.Re
 	return &and.BytesCloser{Reader: bytes.NewReader(b)}, nil
 }
 
-func (m *blobHandler) Put(_ context.Context, _ string, h v1.Hash, _ string, r io.Reader) error {
+func (m *blobHandler) GetRange(
+	_ context.Context,
+	_ string,
+	h v1.Hash,
+	_ bool,
+	offset, length int64,
+) (io.ReadCloser, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
+	b, found := m.m[h.String()]
+	if !found {
+		return nil, blob.ErrNotFound
+	} else if offset < 0 || length < 0 || offset+length > int64(len(b)) {
+		return nil, fmt.Errorf("range %d+%d is out of bounds of blob with size %d", offset, length, len(b))
+	}
+	return &and.BytesCloser{Reader: bytes.NewReader(b[offset : offset+length])}, nil
+}
+
+func (m *blobHandler) Put(_ context.Context, _ string, h v1.Hash, contentType string, r io.Reader) error {
 	if rc, ok := r.(io.ReadCloser); ok {
 		defer rc.Close()
 	}
-	all, err := io.ReadAll(r)
+	dw, err := blob.NewDigestWriter(h)
 	if err != nil {
 		return err
 	}
+	all, err := io.ReadAll(io.TeeReader(r, dw))
+	if err != nil {
+		return err
+	} else if err := dw.Verify(); err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = blob.DefaultContentType
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	m.m[h.String()] = all
+	m.metadata[h.String()] = blobMetadata{size: int64(len(all)), contentType: contentType}
+	return nil
+}
+
+func (m *blobHandler) StartSession(_ context.Context, _ string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	id := uuid.NewString()
+	m.sessions[id] = &uploadSession{}
+	return id, nil
+}
+
+func (m *blobHandler) PutChunk(_ context.Context, id, contentType string, r io.Reader, start int64) (int64, error) {
+	if rc, ok := r.(io.ReadCloser); ok {
+		defer rc.Close()
+	}
+	// The chunk is read completely before it is added to the session, so that a failed read does not leave a
+	// partial chunk behind.
+	chunk, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	session, found := m.sessions[id]
+	if !found {
+		return 0, blob.NewErrBadUpload("unknown upload session")
+	} else if int64(len(session.data)) != start {
+		return 0, blob.NewErrBadUpload("range is not as expected")
+	}
+	if start == 0 {
+		session.contentType = contentType
+	}
+	session.data = append(session.data, chunk...)
+	return int64(len(session.data)), nil
+}
+
+func (m *blobHandler) GetUploadedPartsSize(_ context.Context, id string) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if session, found := m.sessions[id]; !found {
+		return 0, blob.NewErrBadUpload("unknown upload session")
+	} else {
+		return int64(len(session.data)), nil
+	}
+}
+
+func (m *blobHandler) CompleteSession(_ context.Context, _, id string, digest v1.Hash) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	session, found := m.sessions[id]
+	if !found {
+		return blob.NewErrBadUpload("unknown upload session")
+	}
+	// The session is discarded in any case, so that a failed upload leaves nothing behind.
+	delete(m.sessions, id)
+
+	if dw, err := blob.NewDigestWriter(digest); err != nil {
+		return err
+	} else if _, err := dw.Write(session.data); err != nil {
+		return err
+	} else if err := dw.Verify(); err != nil {
+		return err
+	}
+	contentType := session.contentType
+	if contentType == "" {
+		contentType = blob.DefaultContentType
+	}
+	m.m[digest.String()] = session.data
+	m.metadata[digest.String()] = blobMetadata{size: int64(len(session.data)), contentType: contentType}
+	return nil
+}
+
+// Mount implements blob.BlobMountHandler. Blobs are not stored per repository, so every existing blob can be mounted.
+func (m *blobHandler) Mount(_ context.Context, _, _ string, h v1.Hash) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, found := m.m[h.String()]; !found {
+		return blob.ErrNotFound
+	}
 	return nil
 }
 
@@ -64,5 +206,6 @@ func (m *blobHandler) Delete(_ context.Context, _ string, h v1.Hash) error {
 	}
 
 	delete(m.m, h.String())
+	delete(m.metadata, h.String())
 	return nil
 }
@@ -14,11 +14,26 @@ type BlobHandler interface {
 	Get(ctx context.Context, repo string, h v1.Hash, allowRedirect bool) (io.ReadCloser, error)
 }
 
+// BlobRangeHandler is an extension interface representing a blob storage
+// backend that can serve a part of the blob contents without reading the
+// bytes before it. Backends that do not implement it serve the whole blob
+// even if a range is requested.
+type BlobRangeHandler interface {
+	// GetRange gets length bytes of the blob contents starting at offset, or
+	// errNotFound if the blob wasn't found. Like Get, it returns
+	// redirectError if redirects are allowed and the blob can be found
+	// elsewhere. The range is within the size returned by Stat.
+	GetRange(ctx context.Context, repo string, h v1.Hash, allowRedirect bool, offset, length int64) (io.ReadCloser, error)
+}
+
 // BlobStatHandler is an extension interface representing a blob storage
 // backend that can serve metadata about blobs.
 type BlobStatHandler interface {
 	// Stat returns the size of the blob, or errNotFound if the blob wasn't
 	// found, or redirectError if the blob can be found elsewhere.
+	//
+	// Implementations should answer from the metadata recorded when the blob
+	// was put instead of accessing the underlying storage.
 	Stat(ctx context.Context, repo string, h v1.Hash) (int64, error)
 }
 
@@ -27,17 +42,40 @@ type BlobStatHandler interface {
 type BlobPutHandler interface {
 	// Put puts the blob contents.
 	//
-	// The contents will be verified against the expected size and digest
-	// as the contents are read, and an error will be returned if these
-	// don't match. Implementations should return that error, or a wrapper
-	// around that error, to return the correct error when these don't match.
+	// Implementations must compute the digest of the contents while they are
+	// read and return an error wrapping ErrDigestMismatch if it does not match
+	// h. The contents may additionally be verified by the caller, in which
+	// case implementations should return that error, or a wrapper around that
+	// error. In both cases, no object may be left behind. The size and
+	// content type of the blob must be recorded for Stat.
+	//
+	// h is either a sha256 or a sha512 digest. NewDigestWriter supports both.
 	Put(ctx context.Context, repo string, h v1.Hash, contentType string, r io.Reader) error
 	StartSession(ctx context.Context, repo string) (string, error)
+	// CompleteSession moves the chunks uploaded in the session to the blob
+	// identified by digest.
+	//
+	// Like Put, implementations must verify the contents against digest,
+	// return an error wrapping ErrDigestMismatch and discard the upload if
+	// they do not match, and record the size and content type of the blob
+	// for Stat.
 	CompleteSession(ctx context.Context, repo, id string, digest v1.Hash) error
-	PutChunk(ctx context.Context, id string, r io.Reader, start int64) (int64, error)
+	// PutChunk appends the chunk to the upload session. The content type of
+	// the first chunk is the content type of the blob.
+	PutChunk(ctx context.Context, id, contentType string, r io.Reader, start int64) (int64, error)
 	GetUploadedPartsSize(ctx context.Context, id string) (int64, error)
 }
 
+// BlobMountHandler is an extension interface representing a blob storage
+// backend that can make a blob of one repository available in another
+// repository without transferring its contents.
+type BlobMountHandler interface {
+	// Mount makes the blob identified by h that is part of the repository from
+	// available in repo. It returns ErrNotFound if the blob is not part of from
+	// or does not exist, in which case the client has to upload it.
+	Mount(ctx context.Context, repo, from string, h v1.Hash) error
+}
+
 // BlobDeleteHandler is an extension interface representing a blob storage
 // backend that can delete blob contents.
 type BlobDeleteHandler interface {
@@ -0,0 +1,65 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/registry/blob"
+)
+
+type breakerTransition int
+
+const (
+	breakerUnchanged breakerTransition = iota
+	breakerOpened
+	breakerClosed
+)
+
+// breaker stops using a bucket after breakerThreshold consecutive failures. After breakerTimeout, requests are let
+// through again and the breaker opens again immediately if the next request fails as well.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+// record counts err if it is a failure of the storage, as opposed to an error caused by the client.
+func (b *breaker) record(now time.Time, err error) breakerTransition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		tripped := b.failures >= breakerThreshold
+		b.failures = 0
+		if tripped {
+			return breakerClosed
+		}
+		return breakerUnchanged
+	} else if !isStorageFailure(err) {
+		return breakerUnchanged
+	}
+	b.failures++
+	if b.failures >= breakerThreshold && !now.Before(b.openUntil) {
+		b.openUntil = now.Add(breakerTimeout)
+		return breakerOpened
+	}
+	return breakerUnchanged
+}
+
+func isStorageFailure(err error) bool {
+	var redirect blob.RedirectError
+	return !errors.As(err, &redirect) &&
+		!errors.Is(err, blob.ErrNotFound) &&
+		!errors.Is(err, blob.ErrBadUpload) &&
+		!errors.Is(err, blob.ErrDigestMismatch) &&
+		!errors.Is(err, apierrors.ErrQuotaExceeded) &&
+		!errors.Is(err, context.Canceled)
+}
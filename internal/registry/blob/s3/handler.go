@@ -13,13 +13,18 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/glasskube/distr/internal/apierrors"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
 	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/types"
 	"github.com/glasskube/distr/internal/util"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.uber.org/zap"
 )
 
 const (
@@ -36,12 +41,18 @@ type blobHandler struct {
 var (
 	_ blob.BlobHandler       = &blobHandler{}
 	_ blob.BlobStatHandler   = &blobHandler{}
+	_ blob.BlobRangeHandler  = &blobHandler{}
 	_ blob.BlobPutHandler    = &blobHandler{}
 	_ blob.BlobDeleteHandler = &blobHandler{}
 )
 
+// NewBlobHandler returns a blob.BlobHandler that stores blobs in the platform bucket, unless the organization of the
+// request has configured its own storage.
 func NewBlobHandler(ctx context.Context) blob.BlobHandler {
-	s3Config := env.RegistryS3Config()
+	return newRoutingBlobHandler(newBucketBlobHandler(ctx, env.RegistryS3Config()))
+}
+
+func newBucketBlobHandler(ctx context.Context, s3Config env.S3Config) *blobHandler {
 	var s3Client *s3.Client
 	if awsconfig, err := awsconfig.LoadDefaultConfig(ctx); err != nil {
 		s3Client = s3.New(s3.Options{}, clientOpts(s3Config))
@@ -99,14 +110,52 @@ func (handler *blobHandler) Get(
 	}
 }
 
+// GetRange implements blob.BlobRangeHandler. If redirects are allowed, the client is redirected to the whole object
+// and sends its Range header to the bucket.
+func (handler *blobHandler) GetRange(
+	ctx context.Context,
+	repo string,
+	h v1.Hash,
+	allowRedirect bool,
+	offset, length int64,
+) (io.ReadCloser, error) {
+	if handler.allowRedirect && allowRedirect {
+		return handler.Get(ctx, repo, h, allowRedirect)
+	}
+	key := h.String()
+	obj, err := handler.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &handler.bucket,
+		Key:    &key,
+		Range:  util.PtrTo(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, convertErrNotFound(err)
+	}
+	return obj.Body, nil
+}
+
 // Stat implements blob.BlobStatHandler.
 func (handler *blobHandler) Stat(ctx context.Context, repo string, h v1.Hash) (int64, error) {
+	if metadata, err := db.GetBlobMetadata(ctx, types.Digest(h)); err == nil {
+		return metadata.Size, nil
+	} else if !errors.Is(err, apierrors.ErrNotFound) {
+		return 0, err
+	}
+
+	// Blobs that were uploaded before metadata was recorded are looked up in the bucket once.
 	key := h.String()
 	obj, err := handler.s3Client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &handler.bucket, Key: &key})
 	if err != nil {
 		return 0, convertErrNotFound(err)
 	}
-	return *obj.ContentLength, nil
+	metadata := types.BlobMetadata{Digest: types.Digest(h), Size: *obj.ContentLength, ContentType: blob.DefaultContentType}
+	if obj.ContentType != nil {
+		metadata.ContentType = *obj.ContentType
+	}
+	if err := db.SaveBlobMetadata(ctx, &metadata); err != nil {
+		internalctx.GetLogger(ctx).Warn("could not save blob metadata", zap.Error(err))
+	}
+	return metadata.Size, nil
 }
 
 // Put implements blob.BlobPutHandler.
@@ -116,30 +165,38 @@ func (handler *blobHandler) Put(ctx context.Context, repo string, h v1.Hash, con
 		defer rc.Close()
 	}
 
-	// The AWS S3 SDK requires a io.ReadSeeker event though the interface only specifies io.Reader
-	if _, ok := r.(io.Seeker); !ok {
-		if data, err := io.ReadAll(r); err != nil {
-			return err
-		} else {
-			r = bytes.NewReader(data)
-		}
+	dw, err := blob.NewDigestWriter(h)
+	if err != nil {
+		return err
 	}
 
-	input := s3.PutObjectInput{
-		Bucket: &handler.bucket,
-		Key:    &key,
-		Body:   r,
+	// The AWS S3 SDK requires a io.ReadSeeker event though the interface only specifies io.Reader.
+	// Reading the contents completely also means that they can be verified before anything is written to the bucket.
+	data, err := io.ReadAll(io.TeeReader(r, dw))
+	if err != nil {
+		return err
+	} else if err := dw.Verify(); err != nil {
+		return err
 	}
 
-	if contentType != "" {
-		input.ContentType = &contentType
+	if contentType == "" {
+		contentType = blob.DefaultContentType
 	}
 
-	_, err := handler.s3Client.PutObject(ctx, &input)
-	if err != nil {
+	if _, err := handler.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &handler.bucket,
+		Key:         &key,
+		Body:        bytes.NewReader(data),
+		ContentType: &contentType,
+	}); err != nil {
 		return convertErrNotFound(err)
 	}
-	return nil
+
+	return db.SaveBlobMetadata(ctx, &types.BlobMetadata{
+		Digest:      types.Digest(h),
+		Size:        dw.Size(),
+		ContentType: contentType,
+	})
 }
 
 func (handler *blobHandler) StartSession(ctx context.Context, repo string) (string, error) {
@@ -150,7 +207,14 @@ func (handler *blobHandler) StartSession(ctx context.Context, repo string) (stri
 	}
 }
 
-func (handler *blobHandler) PutChunk(ctx context.Context, id string, r io.Reader, start int64) (int64, error) {
+// PutChunk implements blob.BlobPutHandler. The content type is stored with the multipart upload, which is created for
+// the first chunk.
+func (handler *blobHandler) PutChunk(
+	ctx context.Context,
+	id, contentType string,
+	r io.Reader,
+	start int64,
+) (int64, error) {
 	if rc, ok := r.(io.Closer); ok {
 		defer rc.Close()
 	}
@@ -160,6 +224,17 @@ func (handler *blobHandler) PutChunk(ctx context.Context, id string, r io.Reader
 	var partNumber int32
 	var size int64
 
+	// The chunk is read completely before the multipart upload is created, so that a failed read does not leave an
+	// empty upload behind.
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if contentType == "" {
+		contentType = blob.DefaultContentType
+	}
+
 	if start == 0 {
 		if _, err := handler.getUploadID(ctx, uploadKey); err == nil {
 			// upload ID must not exist if start == 0!
@@ -167,8 +242,9 @@ func (handler *blobHandler) PutChunk(ctx context.Context, id string, r io.Reader
 		} else if !errors.Is(err, blob.ErrBadUpload) {
 			return 0, err
 		} else if upload, err := handler.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
-			Bucket: &handler.bucket,
-			Key:    &uploadKey,
+			Bucket:      &handler.bucket,
+			Key:         &uploadKey,
+			ContentType: &contentType,
 		}); err != nil {
 			return 0, err
 		} else {
@@ -196,25 +272,25 @@ func (handler *blobHandler) PutChunk(ctx context.Context, id string, r io.Reader
 		return 0, blob.NewErrBadUpload("range is not as expected")
 	}
 
-	var br *bytes.Reader
-	if data, err := io.ReadAll(r); err != nil {
-		return 0, err
-	} else {
-		size += int64(len(data))
-		br = bytes.NewReader(data)
-	}
-
 	if _, err := handler.s3Client.UploadPart(ctx, &s3.UploadPartInput{
 		Bucket:     &handler.bucket,
 		Key:        &uploadKey,
 		UploadId:   uploadID,
 		PartNumber: &partNumber,
-		Body:       br,
+		Body:       bytes.NewReader(data),
 	}); err != nil {
+		if start == 0 {
+			_, abortErr := handler.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   &handler.bucket,
+				Key:      &uploadKey,
+				UploadId: uploadID,
+			})
+			err = errors.Join(err, abortErr)
+		}
 		return 0, err
 	}
 
-	return size, nil
+	return size + int64(len(data)), nil
 }
 
 func (handler *blobHandler) GetUploadedPartsSize(ctx context.Context, id string) (int64, error) {
@@ -240,24 +316,35 @@ func (handler *blobHandler) CompleteSession(ctx context.Context, repo, id string
 	} else if uploadedParts, err := handler.getExistingParts(ctx, uploadKey, uploadID); err != nil {
 		return err
 	} else {
-		completionParts := make([]types.CompletedPart, len(uploadedParts))
+		completionParts := make([]s3types.CompletedPart, len(uploadedParts))
 		for i, part := range uploadedParts {
-			completionParts[i] = types.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag}
+			completionParts[i] = s3types.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag}
 		}
 
-		// TODO:
-		//   CompleteSession should check if the completed object has the correct digest before copying it to the
-		//   final location. AWS supports calculating checksums automatically, but we would need a SHA256 for the
-		//   complete object which, unfortunately, is explicitly not supported.
-		//   https://docs.aws.amazon.com/AmazonS3/latest/userguide/checking-object-integrity.html#Full-object-checksums
 		if _, err := handler.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
 			Bucket:          &handler.bucket,
 			Key:             &uploadKey,
 			UploadId:        &uploadID,
-			MultipartUpload: &types.CompletedMultipartUpload{Parts: completionParts},
+			MultipartUpload: &s3types.CompletedMultipartUpload{Parts: completionParts},
 		}); err != nil {
 			return err
-		} else if _, err := handler.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		}
+
+		// AWS supports calculating checksums automatically, but we would need a SHA256 for the complete object which,
+		// unfortunately, is explicitly not supported. Therefore, the completed object is read back and hashed before it
+		// is copied to the final location.
+		// https://docs.aws.amazon.com/AmazonS3/latest/userguide/checking-object-integrity.html#Full-object-checksums
+		size, contentType, err := handler.verifyObject(ctx, uploadKey, digest)
+		if err != nil {
+			_, deleteErr := handler.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: &handler.bucket,
+				Key:    &uploadKey,
+			})
+			return errors.Join(err, deleteErr)
+		}
+
+		// the content type of the multipart upload is copied along with the object
+		if _, err := handler.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
 			Bucket:     &handler.bucket,
 			Key:        util.PtrTo(digest.String()),
 			CopySource: util.PtrTo(path.Join(handler.bucket, uploadKey)),
@@ -269,11 +356,43 @@ func (handler *blobHandler) CompleteSession(ctx context.Context, repo, id string
 		}); err != nil {
 			return err
 		} else {
-			return nil
+			return db.SaveBlobMetadata(ctx, &types.BlobMetadata{
+				Digest:      types.Digest(digest),
+				Size:        size,
+				ContentType: contentType,
+			})
 		}
 	}
 }
 
+// verifyObject streams the object stored at key through a blob.DigestWriter and returns its size and content type if it
+// matches digest.
+func (handler *blobHandler) verifyObject(
+	ctx context.Context,
+	key string,
+	digest v1.Hash,
+) (size int64, contentType string, err error) {
+	dw, err := blob.NewDigestWriter(digest)
+	if err != nil {
+		return 0, "", err
+	}
+	obj, err := handler.s3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: &handler.bucket, Key: &key})
+	if err != nil {
+		return 0, "", err
+	}
+	defer obj.Body.Close()
+	if _, err := io.Copy(dw, obj.Body); err != nil {
+		return 0, "", err
+	} else if err := dw.Verify(); err != nil {
+		return 0, "", err
+	}
+	contentType = blob.DefaultContentType
+	if obj.ContentType != nil {
+		contentType = *obj.ContentType
+	}
+	return dw.Size(), contentType, nil
+}
+
 // Delete implements blob.BlobDeleteHandler.
 func (handler *blobHandler) Delete(ctx context.Context, repo string, h v1.Hash) error {
 	key := h.String()
@@ -281,7 +400,7 @@ func (handler *blobHandler) Delete(ctx context.Context, repo string, h v1.Hash)
 	if err != nil {
 		return convertErrNotFound(err)
 	}
-	return nil
+	return db.DeleteBlobMetadata(ctx, types.Digest(h))
 }
 
 func (handler *blobHandler) getUploadID(ctx context.Context, uploadKey string) (string, error) {
@@ -309,7 +428,7 @@ func (handler *blobHandler) getExistingParts(
 	ctx context.Context,
 	uploadKey string,
 	uploadID string,
-) ([]types.Part, error) {
+) ([]s3types.Part, error) {
 	if result, err := handler.s3Client.ListParts(ctx, &s3.ListPartsInput{
 		Bucket:   &handler.bucket,
 		Key:      &uploadKey,
@@ -326,8 +445,8 @@ func (handler *blobHandler) getExistingParts(
 }
 
 func convertErrNotFound(err error) error {
-	var nf *types.NotFound
-	var nsk *types.NoSuchKey
+	var nf *s3types.NotFound
+	var nsk *s3types.NoSuchKey
 	if errors.As(err, &nf) || errors.As(err, &nsk) {
 		err = fmt.Errorf("%w: %w", blob.ErrNotFound, err)
 	}
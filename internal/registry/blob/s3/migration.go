@@ -0,0 +1,80 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"go.uber.org/zap"
+)
+
+type MigratorOptions struct {
+	// BatchSize is the maximum number of blobs that are copied per organization and run.
+	BatchSize int
+}
+
+// Migrator copies the blobs of organizations that have configured their own storage from the platform bucket to the
+// bucket of the organization. The copies in the platform bucket are kept, because other organizations might refer
+// to the same blob.
+type Migrator struct {
+	platform *blobHandler
+	opts     MigratorOptions
+}
+
+func NewMigrator(ctx context.Context, opts MigratorOptions) *Migrator {
+	return &Migrator{platform: newBucketBlobHandler(ctx, env.RegistryS3Config()), opts: opts}
+}
+
+func (m *Migrator) Run(ctx context.Context) error {
+	storages, err := db.GetMigratingOrganizationStorages(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, storage := range storages {
+		if err := m.migrate(ctx, storage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Migrator) migrate(ctx context.Context, storage types.OrganizationStorage) error {
+	log := internalctx.GetLogger(ctx).With(zap.Stringer("organizationId", storage.OrganizationID))
+	blobs, err := db.GetOrganizationBlobsToMigrate(ctx, storage.OrganizationID, m.opts.BatchSize)
+	if err != nil || len(blobs) == 0 {
+		return err
+	}
+	handler := newBucketBlobHandler(ctx, organizationS3Config(storage))
+	var copied int
+	for _, metadata := range blobs {
+		h := v1.Hash(metadata.Digest)
+		key := h.String()
+		obj, err := m.platform.s3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: &m.platform.bucket, Key: &key})
+		if err != nil {
+			if errors.Is(convertErrNotFound(err), blob.ErrNotFound) {
+				log.Warn("blob not found in platform bucket", zap.String("digest", key))
+				continue
+			}
+			return fmt.Errorf("could not read blob %v: %w", key, err)
+		}
+		// Put closes the body and verifies the contents against the digest
+		if err := handler.Put(ctx, "", h, metadata.ContentType, obj.Body); err != nil {
+			// the organization bucket is probably unavailable, so the remaining blobs are not attempted in this run
+			log.Warn("blob migration failed", zap.String("digest", key), zap.Int("copied", copied), zap.Error(err))
+			return fmt.Errorf("could not copy blob %v: %w", key, err)
+		} else if err := db.CreateOrganizationBlob(ctx, storage.OrganizationID, metadata.Digest); err != nil {
+			return err
+		}
+		copied++
+	}
+	log.Info("blob migration finished", zap.Int("copied", copied), zap.Int("remaining", len(blobs)-copied))
+	return nil
+}
@@ -0,0 +1,83 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
+)
+
+const probePrefix = "distr-probe"
+
+var ErrProbeFailed = errors.New("storage probe failed")
+
+// ProbeOrganizationStorage verifies that objects can be written to, read from and deleted from the bucket of storage.
+// The returned error wraps ErrProbeFailed if the bucket can not be used.
+func ProbeOrganizationStorage(ctx context.Context, storage types.OrganizationStorage) error {
+	handler := newBucketBlobHandler(ctx, organizationS3Config(storage))
+	key := path.Join(probePrefix, uuid.NewString())
+	data := []byte(key)
+
+	if _, err := handler.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &handler.bucket,
+		Key:    &key,
+		Body:   bytes.NewReader(data),
+	}); err != nil {
+		return fmt.Errorf("%w: could not write object: %w", ErrProbeFailed, err)
+	}
+
+	readErr := func() error {
+		obj, err := handler.s3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: &handler.bucket, Key: &key})
+		if err != nil {
+			return fmt.Errorf("%w: could not read object: %w", ErrProbeFailed, err)
+		}
+		defer obj.Body.Close()
+		if read, err := io.ReadAll(obj.Body); err != nil {
+			return fmt.Errorf("%w: could not read object: %w", ErrProbeFailed, err)
+		} else if !bytes.Equal(read, data) {
+			return fmt.Errorf("%w: object contents do not match", ErrProbeFailed)
+		}
+		return nil
+	}()
+
+	// the object is deleted even if reading failed, so that the probe does not leave anything behind
+	if _, err := handler.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &handler.bucket,
+		Key:    &key,
+	}); err != nil {
+		return errors.Join(readErr, fmt.Errorf("%w: could not delete object: %w", ErrProbeFailed, err))
+	}
+	return readErr
+}
+
+// NewPlatformBlobExistsFunc returns a function that reports whether a blob exists in the platform bucket. Unlike Stat,
+// it always asks the bucket instead of relying on the recorded blob metadata.
+func NewPlatformBlobExistsFunc(ctx context.Context) func(ctx context.Context, digest types.Digest) (bool, error) {
+	handler := newBucketBlobHandler(ctx, env.RegistryS3Config())
+	return func(ctx context.Context, digest types.Digest) (bool, error) {
+		key := v1.Hash(digest).String()
+		_, err := handler.s3Client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &handler.bucket, Key: &key})
+		if err == nil {
+			return true, nil
+		} else if err := convertErrNotFound(err); errors.Is(err, blob.ErrNotFound) {
+			return false, nil
+		} else {
+			return false, err
+		}
+	}
+}
+
+// NewPlatformBlobDeleteHandler returns a blob.BlobDeleteHandler that always deletes from the platform bucket,
+// regardless of the storage of the current organization.
+func NewPlatformBlobDeleteHandler(ctx context.Context) blob.BlobDeleteHandler {
+	return newBucketBlobHandler(ctx, env.RegistryS3Config())
+}
@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+)
+
+// storageUsage counts the blobs that are stored by the current organization towards its storage usage and enforces
+// its storage quota.
+//
+// Concurrent uploads are checked against the same usage, so an organization can exceed its quota by the size of the
+// blobs it uploads at the same time.
+type storageUsage struct {
+	orgID uuid.UUID
+	// remaining is nil if the organization is not limited or the blob is already counted.
+	remaining *int64
+}
+
+// currentStorageUsage returns the storage usage of the current organization for the blob with the given digest, or
+// nil if the request does not belong to an organization. digest is nil if it is not known yet.
+func currentStorageUsage(ctx context.Context, digest *types.Digest) (*storageUsage, error) {
+	auth, err := auth.ArtifactsAuthentication.Get(ctx)
+	if err != nil || auth.CurrentOrgID() == nil {
+		return nil, nil
+	}
+	remaining, err := db.GetRemainingOrganizationStorageQuota(ctx, *auth.CurrentOrgID(), digest)
+	if err != nil {
+		return nil, err
+	}
+	return &storageUsage{orgID: *auth.CurrentOrgID(), remaining: remaining}, nil
+}
+
+// check returns an error wrapping apierrors.ErrQuotaExceeded if storing size more bytes would exceed the quota.
+func (u *storageUsage) check(size int64) error {
+	if u != nil && u.remaining != nil && size > *u.remaining {
+		return fmt.Errorf("%w: storage quota exceeded by %v bytes", apierrors.ErrQuotaExceeded, size-*u.remaining)
+	}
+	return nil
+}
+
+// limit returns a reader that fails with the error of check as soon as more than the remaining bytes have been read.
+// start is the number of bytes of the blob that have been uploaded before.
+func (u *storageUsage) limit(r io.Reader, start int64) *quotaReader {
+	return &quotaReader{Reader: r, usage: u, size: start}
+}
+
+func (u *storageUsage) record(ctx context.Context, digest types.Digest, size int64) error {
+	if u == nil {
+		return nil
+	}
+	return db.AddOrganizationBlobUsage(ctx, u.orgID, digest, size)
+}
+
+type quotaReader struct {
+	io.Reader
+	usage *storageUsage
+	size  int64
+}
+
+func (r *quotaReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.size += int64(n)
+	if quotaErr := r.usage.check(r.size); quotaErr != nil {
+		return n, quotaErr
+	}
+	return n, err
+}
+
+// Close closes the underlying reader, because blob handlers close the readers that implement io.Closer.
+func (r *quotaReader) Close() error {
+	if c, ok := r.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
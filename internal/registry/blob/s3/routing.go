@@ -0,0 +1,380 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/registry/name"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// breakerThreshold is the number of consecutive failures after which the bucket of an organization is not used
+	// anymore for breakerTimeout.
+	breakerThreshold = 5
+	breakerTimeout   = time.Minute
+	// alertInterval is the minimum time between two alerts about the same failing storage.
+	alertInterval = 24 * time.Hour
+)
+
+var errStorageUnavailable = errors.New("organization storage is unavailable")
+
+// routingBlobHandler stores the blobs of organizations that have configured their own storage in their bucket and
+// all other blobs in the platform bucket. Blobs that were stored before an organization configured its storage keep
+// being served from the platform bucket until they are migrated.
+//
+// The organization is the current organization of the authenticated request.
+type routingBlobHandler struct {
+	platform *blobHandler
+	mu       sync.Mutex
+	buckets  map[uuid.UUID]*organizationBucket
+}
+
+type organizationBucket struct {
+	storage types.OrganizationStorage
+	handler *blobHandler
+	breaker breaker
+}
+
+var (
+	_ blob.BlobHandler       = &routingBlobHandler{}
+	_ blob.BlobStatHandler   = &routingBlobHandler{}
+	_ blob.BlobRangeHandler  = &routingBlobHandler{}
+	_ blob.BlobPutHandler    = &routingBlobHandler{}
+	_ blob.BlobMountHandler  = &routingBlobHandler{}
+	_ blob.BlobDeleteHandler = &routingBlobHandler{}
+)
+
+func newRoutingBlobHandler(platform *blobHandler) *routingBlobHandler {
+	return &routingBlobHandler{platform: platform, buckets: map[uuid.UUID]*organizationBucket{}}
+}
+
+// Get implements blob.BlobHandler.
+func (r *routingBlobHandler) Get(
+	ctx context.Context,
+	repo string,
+	h v1.Hash,
+	allowRedirect bool,
+) (result io.ReadCloser, err error) {
+	if bucket, err := r.blobBucket(ctx, h); err != nil {
+		return nil, err
+	} else if bucket == nil {
+		return r.platform.Get(ctx, repo, h, allowRedirect)
+	} else {
+		err = r.call(ctx, bucket, func(handler *blobHandler) (err error) {
+			result, err = handler.Get(ctx, repo, h, allowRedirect)
+			return err
+		})
+		return result, err
+	}
+}
+
+// GetRange implements blob.BlobRangeHandler.
+func (r *routingBlobHandler) GetRange(
+	ctx context.Context,
+	repo string,
+	h v1.Hash,
+	allowRedirect bool,
+	offset, length int64,
+) (result io.ReadCloser, err error) {
+	if bucket, err := r.blobBucket(ctx, h); err != nil {
+		return nil, err
+	} else if bucket == nil {
+		return r.platform.GetRange(ctx, repo, h, allowRedirect, offset, length)
+	} else {
+		err = r.call(ctx, bucket, func(handler *blobHandler) (err error) {
+			result, err = handler.GetRange(ctx, repo, h, allowRedirect, offset, length)
+			return err
+		})
+		return result, err
+	}
+}
+
+// Stat implements blob.BlobStatHandler.
+func (r *routingBlobHandler) Stat(ctx context.Context, repo string, h v1.Hash) (size int64, err error) {
+	if bucket, err := r.blobBucket(ctx, h); err != nil {
+		return 0, err
+	} else if bucket == nil {
+		return r.platform.Stat(ctx, repo, h)
+	} else {
+		err = r.call(ctx, bucket, func(handler *blobHandler) (err error) {
+			size, err = handler.Stat(ctx, repo, h)
+			return err
+		})
+		return size, err
+	}
+}
+
+// Put implements blob.BlobPutHandler.
+//
+// The blob is counted towards the storage usage of the current organization. It fails with an error wrapping
+// apierrors.ErrQuotaExceeded before anything is stored if the blob exceeds the storage quota.
+func (r *routingBlobHandler) Put(ctx context.Context, repo string, h v1.Hash, contentType string, rd io.Reader) error {
+	usage, err := currentStorageUsage(ctx, util.PtrTo(types.Digest(h)))
+	if err != nil {
+		return err
+	}
+	qr := usage.limit(rd, 0)
+	if bucket, err := r.organizationBucket(ctx); err != nil {
+		return err
+	} else if bucket == nil {
+		if err := r.platform.Put(ctx, repo, h, contentType, qr); err != nil {
+			return err
+		}
+	} else if err := r.call(ctx, bucket, func(handler *blobHandler) error {
+		return handler.Put(ctx, repo, h, contentType, qr)
+	}); err != nil {
+		return err
+	} else if err := db.CreateOrganizationBlob(ctx, bucket.storage.OrganizationID, types.Digest(h)); err != nil {
+		return err
+	}
+	return usage.record(ctx, types.Digest(h), qr.size)
+}
+
+// StartSession implements blob.BlobPutHandler.
+func (r *routingBlobHandler) StartSession(ctx context.Context, repo string) (string, error) {
+	return r.platform.StartSession(ctx, repo)
+}
+
+// PutChunk implements blob.BlobPutHandler.
+//
+// The digest of the blob is not known yet, so the chunk is rejected if the upload would exceed the storage quota even
+// if the blob is already counted for the current organization.
+func (r *routingBlobHandler) PutChunk(
+	ctx context.Context,
+	id, contentType string,
+	rd io.Reader,
+	start int64,
+) (size int64, err error) {
+	if usage, err := currentStorageUsage(ctx, nil); err != nil {
+		return 0, err
+	} else {
+		rd = usage.limit(rd, start)
+	}
+	if bucket, err := r.organizationBucket(ctx); err != nil {
+		return 0, err
+	} else if bucket == nil {
+		return r.platform.PutChunk(ctx, id, contentType, rd, start)
+	} else {
+		err = r.call(ctx, bucket, func(handler *blobHandler) (err error) {
+			size, err = handler.PutChunk(ctx, id, contentType, rd, start)
+			return err
+		})
+		return size, err
+	}
+}
+
+// GetUploadedPartsSize implements blob.BlobPutHandler.
+func (r *routingBlobHandler) GetUploadedPartsSize(ctx context.Context, id string) (size int64, err error) {
+	if bucket, err := r.organizationBucket(ctx); err != nil {
+		return 0, err
+	} else if bucket == nil {
+		return r.platform.GetUploadedPartsSize(ctx, id)
+	} else {
+		err = r.call(ctx, bucket, func(handler *blobHandler) (err error) {
+			size, err = handler.GetUploadedPartsSize(ctx, id)
+			return err
+		})
+		return size, err
+	}
+}
+
+// CompleteSession implements blob.BlobPutHandler.
+//
+// Like Put, it counts the blob towards the storage usage of the current organization and enforces its storage quota.
+func (r *routingBlobHandler) CompleteSession(ctx context.Context, repo, id string, digest v1.Hash) error {
+	usage, err := currentStorageUsage(ctx, util.PtrTo(types.Digest(digest)))
+	if err != nil {
+		return err
+	}
+	size, err := r.GetUploadedPartsSize(ctx, id)
+	if err != nil {
+		return err
+	} else if err := usage.check(size); err != nil {
+		return err
+	}
+	if bucket, err := r.organizationBucket(ctx); err != nil {
+		return err
+	} else if bucket == nil {
+		if err := r.platform.CompleteSession(ctx, repo, id, digest); err != nil {
+			return err
+		}
+	} else if err := r.call(ctx, bucket, func(handler *blobHandler) error {
+		return handler.CompleteSession(ctx, repo, id, digest)
+	}); err != nil {
+		return err
+	} else if err := db.CreateOrganizationBlob(ctx, bucket.storage.OrganizationID, types.Digest(digest)); err != nil {
+		return err
+	}
+	return usage.record(ctx, types.Digest(digest), size)
+}
+
+// Mount implements blob.BlobMountHandler.
+//
+// Blobs are stored once per bucket and not per repository, so a blob can be mounted if a version of the artifact from
+// contains it and it exists in storage. It becomes part of repo when a manifest that references it is pushed. A blob
+// that is mounted from another organization is counted towards the storage usage of the current organization.
+func (r *routingBlobHandler) Mount(ctx context.Context, repo, from string, h v1.Hash) error {
+	if n, err := name.Parse(from); err != nil {
+		return err
+	} else if err := db.CheckArtifactForBlob(ctx, n.OrgName, n.ArtifactName, types.Digest(h)); err != nil {
+		if errors.Is(err, apierrors.ErrNotFound) {
+			return blob.ErrNotFound
+		}
+		return err
+	} else if size, err := r.Stat(ctx, repo, h); err != nil {
+		return err
+	} else if usage, err := currentStorageUsage(ctx, util.PtrTo(types.Digest(h))); err != nil {
+		return err
+	} else if err := usage.check(size); err != nil {
+		return err
+	} else {
+		return usage.record(ctx, types.Digest(h), size)
+	}
+}
+
+// Delete implements blob.BlobDeleteHandler.
+func (r *routingBlobHandler) Delete(ctx context.Context, repo string, h v1.Hash) error {
+	if bucket, err := r.blobBucket(ctx, h); err != nil {
+		return err
+	} else if bucket == nil {
+		return r.platform.Delete(ctx, repo, h)
+	} else if err := r.call(ctx, bucket, func(handler *blobHandler) error {
+		return handler.Delete(ctx, repo, h)
+	}); err != nil {
+		return err
+	} else {
+		return db.DeleteOrganizationBlob(ctx, bucket.storage.OrganizationID, types.Digest(h))
+	}
+}
+
+// organizationBucket returns the bucket of the current organization, or nil if it has not configured its own storage.
+// Clients are reused until the storage is updated.
+func (r *routingBlobHandler) organizationBucket(ctx context.Context) (*organizationBucket, error) {
+	auth, err := auth.ArtifactsAuthentication.Get(ctx)
+	if err != nil || auth.CurrentOrgID() == nil {
+		return nil, nil
+	}
+	orgID := *auth.CurrentOrgID()
+
+	storage, err := db.GetOrganizationStorage(ctx, orgID)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		r.mu.Lock()
+		delete(r.buckets, orgID)
+		r.mu.Unlock()
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bucket, ok := r.buckets[orgID]; ok && bucket.storage.UpdatedAt.Equal(storage.UpdatedAt) {
+		return bucket, nil
+	}
+	bucket := &organizationBucket{
+		storage: *storage,
+		handler: newBucketBlobHandler(ctx, organizationS3Config(*storage)),
+	}
+	r.buckets[orgID] = bucket
+	return bucket, nil
+}
+
+// blobBucket returns the bucket of the current organization if the blob is stored there, or nil if it is stored in
+// the platform bucket.
+func (r *routingBlobHandler) blobBucket(ctx context.Context, h v1.Hash) (*organizationBucket, error) {
+	if bucket, err := r.organizationBucket(ctx); err != nil || bucket == nil {
+		return nil, err
+	} else if exists, err := db.OrganizationBlobExists(ctx, bucket.storage.OrganizationID, types.Digest(h)); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, nil
+	} else {
+		return bucket, nil
+	}
+}
+
+// call runs f with the handler of bucket unless its breaker is open. When the breaker opens, the failure is recorded
+// and the vendors of the organization are alerted.
+func (r *routingBlobHandler) call(ctx context.Context, bucket *organizationBucket, f func(*blobHandler) error) error {
+	now := time.Now()
+	if !bucket.breaker.allow(now) {
+		return fmt.Errorf("%w: bucket %v", errStorageUnavailable, bucket.storage.Bucket)
+	}
+	err := f(bucket.handler)
+	switch bucket.breaker.record(now, err) {
+	case breakerOpened:
+		r.recordFailure(ctx, bucket.storage, err)
+	case breakerClosed:
+		if err := db.ResetOrganizationStorageFailures(ctx, bucket.storage.ID); err != nil {
+			internalctx.GetLogger(ctx).Warn("could not reset organization storage failures", zap.Error(err))
+		}
+	}
+	return err
+}
+
+func (r *routingBlobHandler) recordFailure(ctx context.Context, storage types.OrganizationStorage, failure error) {
+	log := internalctx.GetLogger(ctx).With(zap.Stringer("organizationId", storage.OrganizationID))
+	log.Warn("organization storage is unavailable", zap.Error(failure))
+	if updated, alert, err := db.RecordOrganizationStorageFailure(
+		ctx, storage.ID, failure.Error(), alertInterval,
+	); err != nil {
+		log.Warn("could not record organization storage failure", zap.Error(err))
+	} else if alert {
+		if err := notifyFailing(ctx, *updated); err != nil {
+			log.Warn("could not send organization storage failing notification", zap.Error(err))
+		}
+	}
+}
+
+// notifyFailing informs all vendor users of the organization that their storage can not be accessed.
+func notifyFailing(ctx context.Context, storage types.OrganizationStorage) error {
+	org, err := db.GetOrganizationWithBranding(ctx, storage.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, storage.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	mailer := internalctx.GetMailer(ctx)
+	var errs []error
+	for _, user := range users {
+		errs = append(errs, mailer.Send(ctx, mail.New(
+			mail.To(user.Email),
+			mail.Organization(storage.OrganizationID),
+			mail.Subject("Your artifact storage is unavailable"),
+			mail.Type(types.MailTypeOrganizationStorageFailing),
+			mail.HtmlBodyTemplate(mailtemplates.OrganizationStorageFailing(*org, storage)),
+		)))
+	}
+	return errors.Join(errs...)
+}
+
+func organizationS3Config(storage types.OrganizationStorage) env.S3Config {
+	return env.S3Config{
+		Bucket:          storage.Bucket,
+		Region:          storage.Region,
+		Endpoint:        storage.Endpoint,
+		AccessKeyID:     &storage.AccessKeyID,
+		SecretAccessKey: &storage.SecretAccessKey,
+		UsePathStyle:    storage.UsePathStyle,
+		AllowRedirect:   storage.AllowRedirect,
+	}
+}
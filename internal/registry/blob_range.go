@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/glasskube/distr/internal/registry/blob"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+var (
+	// errRangeUnsupported is returned for Range headers that are not a single byte range. They are ignored and the
+	// whole blob is served, as permitted by RFC 9110.
+	errRangeUnsupported    = errors.New("range is not a single byte range")
+	errRangeNotSatisfiable = errors.New("range is not satisfiable")
+)
+
+// parseByteRange returns the first and last byte position of the single byte range requested by the Range header
+// value for a blob of the given size (RFC 9110, section 14.1.2). Positions after the end of the blob are reduced to
+// its last byte. It returns errRangeNotSatisfiable if the range does not overlap the blob.
+func parseByteRange(header string, size int64) (start, end int64, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, errRangeUnsupported
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, errRangeUnsupported
+	}
+
+	if first == "" {
+		// a suffix range requests the last bytes of the blob
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, errRangeUnsupported
+		} else if n == 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		return max(size-n, 0), size - 1, nil
+	}
+
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil || start < 0 {
+		return 0, 0, errRangeUnsupported
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, errRangeUnsupported
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	return start, end, nil
+}
+
+// handleGetRange serves the bytes from start to end of the blob with 206 Partial Content.
+func (b *blobs) handleGetRange(
+	resp http.ResponseWriter,
+	req *http.Request,
+	brh blob.BlobRangeHandler,
+	repo string,
+	h v1.Hash,
+	start, end, size int64,
+) *regError {
+	rc, err := brh.GetRange(req.Context(), repo, h, true, start, end-start+1)
+	if errors.Is(err, blob.ErrNotFound) {
+		return regErrBlobUnknown
+	} else if err != nil {
+		var rerr blob.RedirectError
+		if errors.As(err, &rerr) {
+			http.Redirect(resp, req, rerr.Location, rerr.Code)
+			return nil
+		}
+		return regErrInternal(err)
+	}
+	defer rc.Close()
+
+	resp.Header().Set("Accept-Ranges", "bytes")
+	resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	resp.Header().Set("Content-Length", fmt.Sprint(end-start+1))
+	resp.Header().Set("Docker-Content-Digest", h.String())
+	resp.WriteHeader(http.StatusPartialContent)
+	if _, err := io.Copy(resp, rc); err != nil {
+		return regErrInternal(err)
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/authz"
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/registry/blob/inmemory"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+// corruptReader flips a byte in the middle of the contents, as if they had been corrupted during the upload.
+func corruptReader(data []byte) io.Reader {
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)/2] ^= 0xff
+	return bytes.NewReader(corrupted)
+}
+
+func newBlobTestRegistry() http.Handler {
+	return registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithMiddlewares(middleware.LoggerCtxMiddleware(zap.NewNop())),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithBlobHandler(inmemory.NewBlobHandler()),
+	)
+}
+
+func serve(h http.Handler, method, target string, body io.Reader) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	r = r.WithContext(sentry.SetHubOnContext(r.Context(), sentry.NewHub(nil, sentry.NewScope())))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func errorCode(g Gomega, w *httptest.ResponseRecorder) string {
+	var body struct {
+		Errors []struct {
+			Code string `json:"code"`
+		} `json:"errors"`
+	}
+	g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+	g.Expect(body.Errors).To(HaveLen(1))
+	return body.Errors[0].Code
+}
+
+func TestBlobPutCorrupted(t *testing.T) {
+	g := NewWithT(t)
+	h := newBlobTestRegistry()
+	data := []byte("this is the content of a layer blob")
+	digest, _, err := v1.SHA256(bytes.NewReader(data))
+	g.Expect(err).NotTo(HaveOccurred())
+	target := "/v2/org/app/blobs/uploads/?digest=" + digest.String()
+
+	w := serve(h, http.MethodPost, target, corruptReader(data))
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("DIGEST_INVALID"))
+	g.Expect(serve(h, http.MethodHead, "/v2/org/app/blobs/"+digest.String(), nil).Code).
+		To(Equal(http.StatusNotFound))
+
+	w = serve(h, http.MethodPost, target,
+		io.MultiReader(bytes.NewReader(data[:10]), iotest.ErrReader(errors.New("connection reset"))))
+	g.Expect(w.Code).To(Equal(http.StatusInternalServerError))
+	g.Expect(serve(h, http.MethodHead, "/v2/org/app/blobs/"+digest.String(), nil).Code).
+		To(Equal(http.StatusNotFound))
+
+	w = serve(h, http.MethodPost, target, bytes.NewReader(data))
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+	w = serve(h, http.MethodHead, "/v2/org/app/blobs/"+digest.String(), nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Header().Get("Content-Length")).To(Equal(fmt.Sprint(len(data))))
+}
+
+func TestBlobPutDigestAlgorithm(t *testing.T) {
+	g := NewWithT(t)
+	h := newBlobTestRegistry()
+	data := []byte("this is the content of a layer blob")
+	sum := sha512.Sum512(data)
+	digest := "sha512:" + hex.EncodeToString(sum[:])
+
+	w := serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?digest="+digest, corruptReader(data))
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("DIGEST_INVALID"))
+
+	w = serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?digest="+digest, bytes.NewReader(data))
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+	w = serve(h, http.MethodHead, "/v2/org/app/blobs/"+digest, nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Header().Get("Docker-Content-Digest")).To(Equal(digest))
+
+	md5 := "md5:" + strings.Repeat("0", 32)
+	w = serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?digest="+md5, bytes.NewReader(data))
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("UNSUPPORTED"))
+	w = serve(h, http.MethodHead, "/v2/org/app/blobs/"+md5, nil)
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+	w = serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?digest=sha256:abc", bytes.NewReader(data))
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("DIGEST_INVALID"))
+}
+
+func TestBlobChunkedUploadCorrupted(t *testing.T) {
+	g := NewWithT(t)
+	h := newBlobTestRegistry()
+	data := []byte("this is the content of a layer blob")
+	digest, _, err := v1.SHA256(bytes.NewReader(data))
+	g.Expect(err).NotTo(HaveOccurred())
+	half := len(data) / 2
+
+	upload := func(last io.Reader) (string, *httptest.ResponseRecorder) {
+		w := serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/", nil)
+		g.Expect(w.Code).To(Equal(http.StatusAccepted))
+		location := w.Header().Get("Location")
+
+		r := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader(data[:half]))
+		r.Header.Set("Content-Range", fmt.Sprintf("0-%d", half-1))
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		g.Expect(w.Code).To(Equal(http.StatusAccepted))
+
+		r = httptest.NewRequest(http.MethodPut, location+"?digest="+digest.String(), last)
+		r.Header.Set("Content-Range", fmt.Sprintf("%d-%d", half, len(data)))
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return location, w
+	}
+
+	location, w := upload(corruptReader(data[half:]))
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("DIGEST_INVALID"))
+	g.Expect(serve(h, http.MethodHead, "/v2/org/app/blobs/"+digest.String(), nil).Code).
+		To(Equal(http.StatusNotFound))
+
+	// the failed upload session has been discarded
+	w = serve(h, http.MethodPut, location+"?digest="+digest.String(), nil)
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+	g.Expect(errorCode(g, w)).To(Equal("BLOB_UPLOAD_UNKNOWN"))
+
+	_, w = upload(bytes.NewReader(data[half:]))
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+	w = serve(h, http.MethodHead, "/v2/org/app/blobs/"+digest.String(), nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Header().Get("Content-Length")).To(Equal(fmt.Sprint(len(data))))
+}
+
+// denyRepository denies all access to one repository.
+type denyRepository struct {
+	allowAll
+	name string
+}
+
+func (d denyRepository) Authorize(_ context.Context, name string, _ authz.Action) error {
+	if name == d.name {
+		return authz.ErrAccessDenied
+	}
+	return nil
+}
+
+func TestBlobMount(t *testing.T) {
+	g := NewWithT(t)
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(denyRepository{name: "org/secret"}),
+		registry.WithBlobHandler(inmemory.NewBlobHandler()),
+	)
+	data := []byte("this is the content of a shared base layer")
+	digest, _, err := v1.SHA256(bytes.NewReader(data))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(serve(h, http.MethodPost, "/v2/org/base/blobs/uploads/?digest="+digest.String(), bytes.NewReader(data)).
+		Code).To(Equal(http.StatusCreated))
+
+	w := serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?mount="+digest.String()+"&from=org/base", nil)
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+	g.Expect(w.Header().Get("Location")).To(Equal("/v2/org/app/blobs/" + digest.String()))
+	g.Expect(w.Header().Get("Docker-Content-Digest")).To(Equal(digest.String()))
+	g.Expect(serve(h, http.MethodHead, "/v2/org/app/blobs/"+digest.String(), nil).Code).To(Equal(http.StatusOK))
+
+	missing, _, err := v1.SHA256(bytes.NewReader([]byte("this layer has never been pushed")))
+	g.Expect(err).NotTo(HaveOccurred())
+	w = serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?mount="+missing.String()+"&from=org/base", nil)
+	g.Expect(w.Code).To(Equal(http.StatusAccepted))
+	g.Expect(w.Header().Get("Location")).To(HavePrefix("/v2/org/app/blobs/uploads/"))
+
+	w = serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?mount="+digest.String()+"&from=org/secret", nil)
+	g.Expect(w.Code).To(Equal(http.StatusAccepted))
+
+	w = serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?mount=invalid&from=org/base", nil)
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("DIGEST_INVALID"))
+}
+
+func TestBlobGetRange(t *testing.T) {
+	g := NewWithT(t)
+	h := newBlobTestRegistry()
+	data := []byte("this is the content of a layer blob")
+	digest, _, err := v1.SHA256(bytes.NewReader(data))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?digest="+digest.String(), bytes.NewReader(data)).Code).
+		To(Equal(http.StatusCreated))
+	get := func(rangeHeader string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v2/org/app/blobs/"+digest.String(), nil)
+		r = r.WithContext(sentry.SetHubOnContext(r.Context(), sentry.NewHub(nil, sentry.NewScope())))
+		r.Header.Set("Range", rangeHeader)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for rangeHeader, expected := range map[string]struct{ start, end int }{
+		"bytes=8-10":  {8, 10},
+		"bytes=8-":    {8, len(data) - 1},
+		"bytes=8-999": {8, len(data) - 1},
+		"bytes=-4":    {len(data) - 4, len(data) - 1},
+		"bytes=-999":  {0, len(data) - 1},
+	} {
+		w := get(rangeHeader)
+		g.Expect(w.Code).To(Equal(http.StatusPartialContent), rangeHeader)
+		g.Expect(w.Header().Get("Content-Range")).
+			To(Equal(fmt.Sprintf("bytes %d-%d/%d", expected.start, expected.end, len(data))), rangeHeader)
+		g.Expect(w.Body.Bytes()).To(Equal(data[expected.start:expected.end+1]), rangeHeader)
+	}
+
+	for _, rangeHeader := range []string{"bytes=100-", "bytes=-0"} {
+		w := get(rangeHeader)
+		g.Expect(w.Code).To(Equal(http.StatusRequestedRangeNotSatisfiable), rangeHeader)
+		g.Expect(w.Header().Get("Content-Range")).To(Equal(fmt.Sprintf("bytes */%d", len(data))), rangeHeader)
+	}
+
+	// other ranges are ignored
+	for _, rangeHeader := range []string{"", "bytes=0-1,4-5", "bytes=5-2", "items=0-1"} {
+		w := get(rangeHeader)
+		g.Expect(w.Code).To(Equal(http.StatusOK), rangeHeader)
+		g.Expect(w.Header().Get("Accept-Ranges")).To(Equal("bytes"))
+		g.Expect(w.Body.Bytes()).To(Equal(data), rangeHeader)
+	}
+}
+
+// largeBlobSize does not fit into the mantissa of a float64.
+const largeBlobSize = 1<<53 + 1
+
+// largeBlobHandler pretends that every blob has largeBlobSize bytes of zeros.
+type largeBlobHandler struct{}
+
+func (largeBlobHandler) Get(context.Context, string, v1.Hash, bool) (io.ReadCloser, error) {
+	return nil, errors.New("blob is too large to be read completely")
+}
+
+func (largeBlobHandler) GetRange(
+	_ context.Context,
+	_ string,
+	_ v1.Hash,
+	_ bool,
+	_, length int64,
+) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(make([]byte, length))), nil
+}
+
+func (largeBlobHandler) Stat(context.Context, string, v1.Hash) (int64, error) {
+	return largeBlobSize, nil
+}
+
+func TestBlobLargeSize(t *testing.T) {
+	g := NewWithT(t)
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithBlobHandler(largeBlobHandler{}),
+	)
+	target := "/v2/org/app/blobs/sha256:" + strings.Repeat("a", 64)
+
+	w := serve(h, http.MethodHead, target, nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Header().Get("Content-Length")).To(Equal("9007199254740993"))
+
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Range", "bytes=9007199254740990-")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusPartialContent))
+	g.Expect(w.Header().Get("Content-Range")).To(Equal("bytes 9007199254740990-9007199254740992/9007199254740993"))
+	g.Expect(w.Header().Get("Content-Length")).To(Equal("3"))
+	g.Expect(w.Body.Len()).To(Equal(3))
+}
+
+func TestBlobUploadContentRangeInvalid(t *testing.T) {
+	g := NewWithT(t)
+	h := newBlobTestRegistry()
+	w := serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/", nil)
+	g.Expect(w.Code).To(Equal(http.StatusAccepted))
+	location := w.Header().Get("Location")
+
+	for _, contentRange := range []string{"99999999999999999999-99999999999999999999", "-1-9", "0-x"} {
+		r := httptest.NewRequest(http.MethodPatch, location, strings.NewReader("chunk"))
+		r.Header.Set("Content-Range", contentRange)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		g.Expect(w.Code).To(Equal(http.StatusRequestedRangeNotSatisfiable), contentRange)
+		g.Expect(errorCode(g, w)).To(Equal("BLOB_UPLOAD_INVALID"), contentRange)
+	}
+}
+
+// contentTypeRecorder records the content types that are passed to the blob handler.
+type contentTypeRecorder struct {
+	blob.BlobHandler
+	blob.BlobStatHandler
+	blob.BlobPutHandler
+	contentTypes []string
+}
+
+func newContentTypeRecorder() *contentTypeRecorder {
+	h := inmemory.NewBlobHandler()
+	return &contentTypeRecorder{
+		BlobHandler:     h,
+		BlobStatHandler: h.(blob.BlobStatHandler),
+		BlobPutHandler:  h.(blob.BlobPutHandler),
+	}
+}
+
+func (r *contentTypeRecorder) Put(ctx context.Context, repo string, h v1.Hash, contentType string, rd io.Reader) error {
+	r.contentTypes = append(r.contentTypes, contentType)
+	return r.BlobPutHandler.Put(ctx, repo, h, contentType, rd)
+}
+
+func (r *contentTypeRecorder) PutChunk(
+	ctx context.Context,
+	id, contentType string,
+	rd io.Reader,
+	start int64,
+) (int64, error) {
+	r.contentTypes = append(r.contentTypes, contentType)
+	return r.BlobPutHandler.PutChunk(ctx, id, contentType, rd, start)
+}
+
+func TestBlobUploadContentType(t *testing.T) {
+	g := NewWithT(t)
+	recorder := newContentTypeRecorder()
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithBlobHandler(recorder),
+	)
+	data := []byte("this is the content of a layer blob")
+	digest, _, err := v1.SHA256(bytes.NewReader(data))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// monolithic upload
+	r := httptest.NewRequest(http.MethodPost, "/v2/org/app/blobs/uploads/?digest="+digest.String(),
+		bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/x-monolithic")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+
+	// chunked upload with a PATCH and a PUT that both contain a chunk
+	w = serve(h, http.MethodPost, "/v2/org/other/blobs/uploads/", nil)
+	g.Expect(w.Code).To(Equal(http.StatusAccepted))
+	location := w.Header().Get("Location")
+	half := len(data) / 2
+	r = httptest.NewRequest(http.MethodPatch, location, bytes.NewReader(data[:half]))
+	r.Header.Set("Content-Type", "application/x-patch")
+	r.Header.Set("Content-Range", fmt.Sprintf("0-%d", half-1))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusAccepted))
+	r = httptest.NewRequest(http.MethodPut, location+"?digest="+digest.String(), bytes.NewReader(data[half:]))
+	r.Header.Set("Content-Type", "application/x-put")
+	r.Header.Set("Content-Range", fmt.Sprintf("%d-%d", half, len(data)))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+
+	g.Expect(recorder.contentTypes).
+		To(Equal([]string{"application/x-monolithic", "application/x-patch", "application/x-put"}))
+}
@@ -0,0 +1,102 @@
+// Package cachesync keeps the manifest caches of all replicas of the registry in sync.
+//
+// Every push is announced on db.ManifestInvalidationChannel, so that all replicas that LISTEN on this channel remove
+// the pushed manifests from their cache. Notifications that are sent while the listening connection is
+// re-established are lost, so the caches are cleared completely whenever listening starts.
+package cachesync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const retryInterval = 10 * time.Second
+
+type Notifier struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+
+	mutex       sync.Mutex
+	subscribers []func(*types.ManifestInvalidation)
+}
+
+func NewNotifier(pool *pgxpool.Pool, logger *zap.Logger) *Notifier {
+	return &Notifier{pool: pool, logger: logger}
+}
+
+// Notify announces invalidation to all replicas, including this one, once the transaction in ctx is committed.
+func (n *Notifier) Notify(ctx context.Context, invalidation types.ManifestInvalidation) error {
+	return db.NotifyManifestInvalidation(ctx, invalidation)
+}
+
+// Subscribe registers f to be called with every announced invalidation. f is called with nil if invalidations may
+// have been missed, in which case every cached manifest must be invalidated.
+func (n *Notifier) Subscribe(f func(invalidation *types.ManifestInvalidation)) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.subscribers = append(n.subscribers, f)
+}
+
+// Start listens for invalidations until ctx is done.
+func (n *Notifier) Start(ctx context.Context) {
+	go func() {
+		for ctx.Err() == nil {
+			if err := n.listen(ctx); err != nil && ctx.Err() == nil {
+				n.logger.Warn("listening for manifest invalidations failed", zap.Error(err))
+				select {
+				case <-ctx.Done():
+				case <-time.After(retryInterval):
+				}
+			}
+		}
+	}()
+}
+
+func (n *Notifier) listen(ctx context.Context) error {
+	poolConn, err := n.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("could not acquire connection: %w", err)
+	}
+	// The connection is removed from the pool, so that no other query is executed on a connection with an active
+	// LISTEN.
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{db.ManifestInvalidationChannel}.Sanitize()); err != nil {
+		return fmt.Errorf("could not listen: %w", err)
+	}
+	// pushes that happened while no connection was listening are not known
+	n.publish(nil)
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var invalidation types.ManifestInvalidation
+		if err := json.Unmarshal([]byte(notification.Payload), &invalidation); err != nil {
+			n.logger.Warn("invalid manifest invalidation", zap.Error(err))
+			n.publish(nil)
+		} else {
+			n.publish(&invalidation)
+		}
+	}
+}
+
+func (n *Notifier) publish(invalidation *types.ManifestInvalidation) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, f := range n.subscribers {
+		f(invalidation)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build conformance
+
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/blob/inmemory"
+	manifestinmemory "github.com/glasskube/distr/internal/registry/manifest/inmemory"
+	"go.uber.org/zap"
+)
+
+var conformanceAddr = flag.String("conformance.addr", "localhost:5000", "address of the conformance test registry")
+
+// TestConformanceServer serves the registry with in-memory backends until the test times out, so that the
+// distribution-spec conformance suite can be run against it. It is not a test on its own, see the build-hub workflow.
+//
+//	go test -tags conformance -run TestConformanceServer -timeout 15m ./internal/registry/
+func TestConformanceServer(t *testing.T) {
+	var mu sync.Mutex
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(noAudit{}),
+		registry.WithBlobHandler(inmemory.NewBlobHandler()),
+		registry.WithManifestHandler(manifestinmemory.NewManifestHandler()),
+		registry.WithReferrersSupport(true),
+		registry.WithMiddlewares(
+			// the in-memory manifest handler is not safe for concurrent use
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					defer mu.Unlock()
+					next.ServeHTTP(w, r)
+				})
+			},
+			txContext,
+		),
+	)
+	server := &http.Server{Addr: *conformanceAddr, Handler: h}
+	if deadline, ok := t.Deadline(); ok {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-5*time.Second))
+		defer cancel()
+		context.AfterFunc(ctx, func() { _ = server.Close() })
+	}
+	t.Logf("serving registry on %v", *conformanceAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		t.Fatal(err)
+	}
+}
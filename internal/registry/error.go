@@ -16,14 +16,48 @@ package registry
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+
+	"github.com/glasskube/distr/internal/registry/name"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
+// Error codes defined by the OCI distribution specification. Clients switch on these codes, so regError must only
+// be created with one of them.
+//
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#error-codes
+const (
+	errCodeBlobUnknown         = "BLOB_UNKNOWN"
+	errCodeBlobUploadInvalid   = "BLOB_UPLOAD_INVALID"
+	errCodeBlobUploadUnknown   = "BLOB_UPLOAD_UNKNOWN"
+	errCodeDigestInvalid       = "DIGEST_INVALID"
+	errCodeManifestBlobUnknown = "MANIFEST_BLOB_UNKNOWN"
+	errCodeManifestInvalid     = "MANIFEST_INVALID"
+	errCodeManifestUnknown     = "MANIFEST_UNKNOWN"
+	errCodeNameInvalid         = "NAME_INVALID"
+	errCodeNameUnknown         = "NAME_UNKNOWN"
+	errCodeSizeInvalid         = "SIZE_INVALID"
+	errCodeDenied              = "DENIED"
+	errCodeUnsupported         = "UNSUPPORTED"
+)
+
+// errCodeUnknown is not part of the OCI distribution specification, which only defines codes for client errors. It is
+// the code of the docker registry API for server errors and must only be used with a 5xx status.
+const errCodeUnknown = "UNKNOWN"
+
+// errCodeTagInvalid is not part of the OCI distribution specification either. It is the code of the docker registry
+// API for tags that can not be pushed.
+const errCodeTagInvalid = "TAG_INVALID"
+
 type regError struct {
 	Status  int
 	Code    string
 	Message string
-	Error   error
+	// Detail is optional structured data about the error, e.g. the digest of a missing blob.
+	Detail any
+	Error  error
 }
 
 func (r *regError) Write(resp http.ResponseWriter) error {
@@ -32,6 +66,7 @@ func (r *regError) Write(resp http.ResponseWriter) error {
 	type err struct {
 		Code    string `json:"code"`
 		Message string `json:"message"`
+		Detail  any    `json:"detail,omitempty"`
 	}
 	type wrap struct {
 		Errors []err `json:"errors"`
@@ -41,6 +76,7 @@ func (r *regError) Write(resp http.ResponseWriter) error {
 			{
 				Code:    r.Code,
 				Message: r.Message,
+				Detail:  r.Detail,
 			},
 		},
 	})
@@ -50,7 +86,7 @@ func (r *regError) Write(resp http.ResponseWriter) error {
 func regErrInternal(err error) *regError {
 	return &regError{
 		Status:  http.StatusInternalServerError,
-		Code:    "INTERNAL_SERVER_ERROR",
+		Code:    errCodeUnknown,
 		Message: err.Error(),
 		Error:   err,
 	}
@@ -59,68 +95,219 @@ func regErrInternal(err error) *regError {
 func regErrManifestInvalid(err error) *regError {
 	return &regError{
 		Status:  http.StatusBadRequest,
-		Code:    "MANIFEST_INVALID",
+		Code:    errCodeManifestInvalid,
 		Message: err.Error(),
 		Error:   err,
 	}
 }
 
+func regErrManifestTooLarge(maxSize int64) *regError {
+	return &regError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    errCodeManifestInvalid,
+		Message: fmt.Sprintf("manifest exceeds the maximum size of %v bytes", maxSize),
+	}
+}
+
 var regErrBlobUnknown = &regError{
 	Status:  http.StatusNotFound,
-	Code:    "BLOB_UNKNOWN",
+	Code:    errCodeBlobUnknown,
 	Message: "Unknown blob",
 }
 
 var regErrUnsupported = &regError{
 	Status:  http.StatusMethodNotAllowed,
-	Code:    "UNSUPPORTED",
+	Code:    errCodeUnsupported,
 	Message: "Unsupported operation",
 }
 
 var regErrDigestMismatch = &regError{
 	Status:  http.StatusBadRequest,
-	Code:    "DIGEST_INVALID",
+	Code:    errCodeDigestInvalid,
 	Message: "digest does not match contents",
 }
 
 var regErrDigestInvalid = &regError{
 	Status:  http.StatusBadRequest,
-	Code:    "DIGEST_INVALID",
+	Code:    errCodeDigestInvalid,
 	Message: "invalid digest",
 }
 
+// regErrDigestUnsupported is returned for digests with an algorithm other than sha256 and sha512.
+var regErrDigestUnsupported = &regError{
+	Status:  http.StatusBadRequest,
+	Code:    errCodeUnsupported,
+	Message: "unsupported digest algorithm, only sha256 and sha512 are supported",
+}
+
 var regErrNameInvalid = &regError{
 	Status:  http.StatusBadRequest,
-	Code:    "NAME_INVALID",
+	Code:    errCodeNameInvalid,
 	Message: "invalid name",
 }
 
+// validateRepoName returns NAME_INVALID with a description of the problem if repo does not conform to the
+// repository name grammar.
+func validateRepoName(repo string, maxDepth int) *regError {
+	if err := name.Validate(repo, maxDepth); err != nil {
+		return &regError{
+			Status:  http.StatusBadRequest,
+			Code:    errCodeNameInvalid,
+			Message: err.Error(),
+		}
+	}
+	return nil
+}
+
 var regErrManifestUnknown = &regError{
 	Status:  http.StatusNotFound,
-	Code:    "MANIFEST_UNKNOWN",
+	Code:    errCodeManifestUnknown,
 	Message: "Unknown manifest",
 }
 
+// regErrPlatformManifestUnknown is returned if a client that does not accept image indexes requests an image index
+// that has no manifest for platform that the client accepts.
+func regErrPlatformManifestUnknown(platform *v1.Platform) *regError {
+	return &regError{
+		Status: http.StatusNotFound,
+		Code:   errCodeManifestUnknown,
+		Message: fmt.Sprintf(
+			"image index found, but it has no manifest for platform %v with an accepted media type", platform,
+		),
+	}
+}
+
 var regErrNameUnknown = &regError{
 	Status:  http.StatusNotFound,
-	Code:    "NAME_UNKNOWN",
+	Code:    errCodeNameUnknown,
 	Message: "Unknown name",
 }
 
-var regErrMethodUnknown = &regError{
-	Status:  http.StatusBadRequest,
-	Code:    "METHOD_UNKNOWN",
+// regErrMethodNotAllowed is returned for requests to a known route with a method that is not handled.
+var regErrMethodNotAllowed = &regError{
+	Status:  http.StatusMethodNotAllowed,
+	Code:    errCodeUnsupported,
 	Message: "We don't understand your method + url",
 }
 
+// regErrRouteUnknown is returned for requests that do not match any route of the registry.
+func regErrRouteUnknown(message string) *regError {
+	return &regError{
+		Status:  http.StatusNotFound,
+		Code:    errCodeUnsupported,
+		Message: message,
+	}
+}
+
+// regErrQueryInvalid is returned if a query parameter can not be parsed. The specification has no dedicated code for
+// this case.
+func regErrQueryInvalid(message string) *regError {
+	return &regError{
+		Status:  http.StatusBadRequest,
+		Code:    errCodeUnsupported,
+		Message: message,
+	}
+}
+
+// regErrRangeInvalid is returned if the Range of a blob request can not be satisfied.
+func regErrRangeInvalid(message string) *regError {
+	return &regError{
+		Status:  http.StatusRequestedRangeNotSatisfiable,
+		Code:    errCodeBlobUnknown,
+		Message: message,
+	}
+}
+
+// regErrBlobUploadInvalid is returned if an upload chunk does not continue the upload or its Content-Range can not be
+// parsed.
+func regErrBlobUploadInvalid(message string) *regError {
+	return &regError{
+		Status:  http.StatusRequestedRangeNotSatisfiable,
+		Code:    errCodeBlobUploadInvalid,
+		Message: message,
+	}
+}
+
+func regErrBlobUploadUnknown(err error) *regError {
+	return &regError{
+		Status:  http.StatusNotFound,
+		Code:    errCodeBlobUploadUnknown,
+		Message: err.Error(),
+		Error:   err,
+	}
+}
+
+var regErrDigestMissing = &regError{
+	Status:  http.StatusBadRequest,
+	Code:    errCodeDigestInvalid,
+	Message: "digest not specified",
+}
+
+var regErrBlobNameMissing = &regError{
+	Status:  http.StatusBadRequest,
+	Code:    errCodeNameInvalid,
+	Message: "blobs must be attached to a repo",
+}
+
+// regErrManifestBlobUnknown is returned if an index references a manifest that has not been pushed.
+func regErrManifestBlobUnknown(digest v1.Hash) *regError {
+	return &regError{
+		Status:  http.StatusBadRequest,
+		Code:    errCodeManifestBlobUnknown,
+		Message: fmt.Sprintf("Sub-manifest %q not found", digest),
+		Detail:  digest.String(),
+	}
+}
+
+// regErrManifestLayerUnknown is returned if an image manifest references a config or layer blob that has not been
+// uploaded.
+func regErrManifestLayerUnknown(digest v1.Hash) *regError {
+	return &regError{
+		Status:  http.StatusBadRequest,
+		Code:    errCodeManifestBlobUnknown,
+		Message: fmt.Sprintf("Blob %q referenced by the manifest not found", digest),
+		Detail:  digest.String(),
+	}
+}
+
 var regErrDenied = &regError{
 	Status:  http.StatusForbidden,
-	Code:    "DENIED",
+	Code:    errCodeDenied,
 	Message: "Access to the resource has been denied",
 }
 
 var regErrDeniedQuotaExceeded = &regError{
 	Status:  http.StatusForbidden,
-	Code:    "DENIED",
+	Code:    errCodeDenied,
 	Message: "You have exhausted your organizations tag quota",
 }
+
+var regErrDeniedStorageQuotaExceeded = &regError{
+	Status:  http.StatusForbidden,
+	Code:    errCodeDenied,
+	Message: "You have exhausted your organizations storage quota",
+}
+
+var regErrDeniedPendingDeletion = &regError{
+	Status:  http.StatusForbidden,
+	Code:    errCodeDenied,
+	Message: "The repository is pending deletion and does not accept pushes",
+}
+
+var regErrDeniedReservedTag = &regError{
+	Status:  http.StatusForbidden,
+	Code:    errCodeDenied,
+	Message: "The tag " + types.ArtifactRecommendedTag + " is managed in the web interface and can not be pushed",
+}
+
+// regErrTagImmutable is returned if a push would move an immutable tag to a different manifest.
+func regErrTagImmutable(tag string) *regError {
+	return &regError{
+		Status: http.StatusConflict,
+		Code:   errCodeTagInvalid,
+		Message: fmt.Sprintf(
+			"The tag %v is immutable and already points at a different manifest, push the manifest with a new tag",
+			tag,
+		),
+	}
+}
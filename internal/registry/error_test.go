@@ -0,0 +1,122 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// canonicalErrorCodes are the error codes defined by the OCI distribution specification.
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#error-codes
+var canonicalErrorCodes = []string{
+	"BLOB_UNKNOWN",
+	"BLOB_UPLOAD_INVALID",
+	"BLOB_UPLOAD_UNKNOWN",
+	"DIGEST_INVALID",
+	"MANIFEST_BLOB_UNKNOWN",
+	"MANIFEST_INVALID",
+	"MANIFEST_UNKNOWN",
+	"NAME_INVALID",
+	"NAME_UNKNOWN",
+	"SIZE_INVALID",
+	"UNAUTHORIZED",
+	"DENIED",
+	"UNSUPPORTED",
+	"TOOMANYREQUESTS",
+}
+
+// TestErrorCodesAreCanonical asserts that every regError is defined in error.go and uses one of the error code
+// constants, which in turn must be codes of the specification. Only server errors may use the UNKNOWN code and only
+// conflicts with immutable tags may use the TAG_INVALID code.
+func TestErrorCodesAreCanonical(t *testing.T) {
+	g := NewWithT(t)
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("*.go")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	constants := map[string]string{}
+	var literals []*ast.CompositeLit
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		g.Expect(err).NotTo(HaveOccurred())
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				for i, ident := range n.Names {
+					if i >= len(n.Values) {
+						break
+					} else if lit, ok := n.Values[i].(*ast.BasicLit); ok && strings.HasPrefix(ident.Name, "errCode") {
+						constants[ident.Name], _ = strconv.Unquote(lit.Value)
+					}
+				}
+			case *ast.CompositeLit:
+				if ident, ok := n.Type.(*ast.Ident); ok && ident.Name == "regError" {
+					g.Expect(name).To(Equal("error.go"), "regError defined at %v", fset.Position(n.Pos()))
+					literals = append(literals, n)
+				}
+			}
+			return true
+		})
+	}
+
+	g.Expect(literals).NotTo(BeEmpty())
+	for _, lit := range literals {
+		var code, status string
+		for _, elt := range lit.Elts {
+			kv := elt.(*ast.KeyValueExpr)
+			switch kv.Key.(*ast.Ident).Name {
+			case "Code":
+				ident, ok := kv.Value.(*ast.Ident)
+				g.Expect(ok).To(BeTrue(), "code must be a constant at %v", fset.Position(kv.Pos()))
+				g.Expect(constants).To(HaveKey(ident.Name), "unknown code at %v", fset.Position(kv.Pos()))
+				code = constants[ident.Name]
+			case "Status":
+				status = kv.Value.(*ast.SelectorExpr).Sel.Name
+			}
+		}
+		if code == "UNKNOWN" {
+			g.Expect(status).To(Equal("StatusInternalServerError"), "at %v", fset.Position(lit.Pos()))
+		} else if code == "TAG_INVALID" {
+			g.Expect(status).To(Equal("StatusConflict"), "at %v", fset.Position(lit.Pos()))
+		} else {
+			g.Expect(canonicalErrorCodes).To(ContainElement(code), "at %v", fset.Position(lit.Pos()))
+		}
+	}
+}
+
+func TestErrorCodesOfUnknownRequests(t *testing.T) {
+	h := newBlobTestRegistry()
+	for _, tc := range []struct {
+		method, target string
+		status         int
+	}{
+		{http.MethodGet, "/v2/unknown", http.StatusNotFound},
+		{http.MethodPost, "/v2/org/app/blobs/sha256:" + strings.Repeat("a", 64), http.StatusNotFound},
+		{http.MethodDelete, "/v2/org/app/manifests/latest", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/v2/_catalog", http.StatusMethodNotAllowed},
+	} {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			g := NewWithT(t)
+			w := serve(h, tc.method, tc.target, nil)
+			g.Expect(w.Code).To(Equal(tc.status))
+			var body struct {
+				Errors []struct {
+					Code string `json:"code"`
+				} `json:"errors"`
+			}
+			g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+			g.Expect(body.Errors).To(ConsistOf(HaveField("Code", "UNSUPPORTED")))
+		})
+	}
+}
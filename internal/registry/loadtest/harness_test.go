@@ -0,0 +1,325 @@
+//go:build loadtest
+
+package loadtest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail/noop"
+	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/audit"
+	"github.com/glasskube/distr/internal/registry/authz"
+	"github.com/glasskube/distr/internal/registry/blob/inmemory"
+	manifestdb "github.com/glasskube/distr/internal/registry/manifest/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/go-containerregistry/pkg/v1"
+	ocitypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	concurrency = flag.Int("loadtest.concurrency", 8, "number of concurrent clients per operation")
+	duration    = flag.Duration("loadtest.duration", 5*time.Second, "duration of each operation in TestRegistryLoad")
+	seedTags    = flag.Int("loadtest.tags", 50, "number of tags that are pushed to the seeded repository")
+	cacheTTL    = flag.Duration("loadtest.manifest-cache-ttl", 0,
+		"TTL of the manifest cache, zero only coalesces concurrent reads")
+)
+
+type queryCountKey struct{}
+
+// queryCounter counts the queries of every context that was created by client.do.
+type queryCounter struct{}
+
+var _ pgx.QueryTracer = queryCounter{}
+
+func (queryCounter) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	if count, ok := ctx.Value(queryCountKey{}).(*atomic.Int64); ok {
+		count.Add(1)
+	}
+	return ctx
+}
+
+func (queryCounter) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+type result struct {
+	status  int
+	queries int64
+	latency time.Duration
+	body    []byte
+}
+
+func (r result) failed() bool {
+	return r.status >= http.StatusBadRequest
+}
+
+// plus combines the results of multiple requests that form a single operation. The status of the first failed
+// request is kept.
+func (r result) plus(other result) result {
+	if r.status == 0 || (!r.failed() && other.failed()) {
+		r.status = other.status
+		r.body = other.body
+	}
+	r.queries += other.queries
+	r.latency += other.latency
+	return r
+}
+
+type client struct {
+	handler http.Handler
+	key     string
+}
+
+func (c *client) do(method, path string, body []byte, contentType string) result {
+	queries := new(atomic.Int64)
+	ctx := context.WithValue(context.Background(), queryCountKey{}, queries)
+	req := httptest.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	req.SetBasicAuth("loadtest", c.key)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	start := time.Now()
+	c.handler.ServeHTTP(w, req)
+	return result{status: w.Code, queries: queries.Load(), latency: time.Since(start), body: w.Body.Bytes()}
+}
+
+type image struct {
+	digest v1.Hash
+	layer  v1.Hash
+}
+
+// pushImage pushes an image with a single layer whose content is derived from seed. Pushes with the same seed push
+// identical content.
+func (c *client) pushImage(repo, tag, seed string) (image, result) {
+	config := []byte(fmt.Sprintf(
+		`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]},"config":{"Labels":{"seed":%q}}}`,
+		seed))
+	layer := []byte("layer " + seed)
+	configDesc, res := c.pushBlob(repo, config, ocitypes.OCIConfigJSON)
+	layerDesc, layerRes := c.pushBlob(repo, layer, ocitypes.OCIUncompressedLayer)
+	res = res.plus(layerRes)
+	manifest, err := json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     ocitypes.OCIManifestSchema1,
+		Config:        configDesc,
+		Layers:        []v1.Descriptor{layerDesc},
+	})
+	if err != nil {
+		panic(err)
+	}
+	digest, _, _ := v1.SHA256(bytes.NewReader(manifest))
+	res = res.plus(c.do(http.MethodPut, "/v2/"+repo+"/manifests/"+tag, manifest, string(ocitypes.OCIManifestSchema1)))
+	return image{digest: digest, layer: layerDesc.Digest}, res
+}
+
+func (c *client) pushBlob(repo string, data []byte, mediaType ocitypes.MediaType) (v1.Descriptor, result) {
+	digest, size, _ := v1.SHA256(bytes.NewReader(data))
+	res := c.do(http.MethodPost, "/v2/"+repo+"/blobs/uploads/?digest="+digest.String(), data,
+		"application/octet-stream")
+	return v1.Descriptor{MediaType: mediaType, Size: size, Digest: digest}, res
+}
+
+func (c *client) pullManifest(repo, reference string) result {
+	return c.do(http.MethodGet, "/v2/"+repo+"/manifests/"+reference, nil, "")
+}
+
+func (c *client) pullBlob(repo string, digest v1.Hash) result {
+	return c.do(http.MethodGet, "/v2/"+repo+"/blobs/"+digest.String(), nil, "")
+}
+
+func (c *client) listTags(repo string) result {
+	return c.do(http.MethodGet, "/v2/"+repo+"/tags/list", nil, "")
+}
+
+// harness is a registry with a seeded organization. The organization has the licensing feature enabled and a
+// customer whose license covers all artifacts, so that pulls of the customer are authorized with licenses.
+type harness struct {
+	vendor   *client
+	customer *client
+	// repo has seedTags tags and smallRepo has a single tag
+	repo      string
+	smallRepo string
+	pushRepo  string
+	tags      []string
+	images    []image
+}
+
+func newHarness(t testing.TB) *harness {
+	t.Helper()
+	pool := testutil.Pool(t, queryCounter{})
+	ctx := internalctx.WithDb(internalctx.WithLogger(context.Background(), zap.NewNop()), pool)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	t.Cleanup(func() { deleteOrganization(ctx, t, org) })
+	if _, err := pool.Exec(ctx,
+		`UPDATE Organization SET features = array_append(features, @feature) WHERE id = @id`,
+		pgx.NamedArgs{"id": org.ID, "feature": types.FeatureLicensing},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithBlobHandler(inmemory.NewBlobHandler()),
+		registry.WithManifestHandler(manifestdb.NewManifestHandler()),
+		registry.WithAuthorizer(authz.NewAuthorizer()),
+		registry.WithAuditor(audit.NewAuditor()),
+		registry.WithManifestCache(*cacheTTL, 1000),
+		registry.WithMiddlewares(
+			middleware.LoggerCtxMiddleware(zap.NewNop()),
+			middleware.ContextInjectorMiddleware(pool, noop.New()),
+			auth.ArtifactsAuthentication.Middleware,
+			middleware.RequireOrgAndRole,
+		),
+	)
+	_, vendorKey := testutil.NewAccessToken(ctx, t, org.Vendors[0].ID, org.ID)
+	_, customerKey := testutil.NewAccessToken(ctx, t, org.Customers[0].ID, org.ID)
+	h := harness{
+		vendor:    &client{handler: handler, key: vendorKey},
+		customer:  &client{handler: handler, key: customerKey},
+		repo:      *org.Slug + "/app",
+		smallRepo: *org.Slug + "/small",
+		pushRepo:  *org.Slug + "/push",
+	}
+
+	for i := range *seedTags {
+		tag := fmt.Sprintf("1.0.%v", i)
+		img, res := h.vendor.pushImage(h.repo, tag, tag)
+		if res.failed() {
+			t.Fatalf("could not seed %v:%v: %v %s", h.repo, tag, res.status, res.body)
+		}
+		h.tags = append(h.tags, tag)
+		h.images = append(h.images, img)
+	}
+	for _, repo := range []string{h.smallRepo, h.pushRepo} {
+		if _, res := h.vendor.pushImage(repo, "1.0.0", repo); res.failed() {
+			t.Fatalf("could not seed %v: %v %s", repo, res.status, res.body)
+		}
+	}
+
+	license := types.ArtifactLicenseBase{
+		Name:               "loadtest",
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &org.Customers[0].ID,
+	}
+	if err := db.CreateArtifactLicense(ctx, &license); err != nil {
+		t.Fatal(err)
+	}
+	for _, artifactID := range getArtifactIDs(ctx, t, org.ID) {
+		if err := db.AddArtifactToArtifactLicense(ctx, license.ID, artifactID, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &h
+}
+
+func getArtifactIDs(ctx context.Context, t testing.TB, orgID uuid.UUID) []uuid.UUID {
+	t.Helper()
+	rows, err := internalctx.GetDb(ctx).Query(ctx,
+		`SELECT id FROM Artifact WHERE organization_id = @orgId`, pgx.NamedArgs{"orgId": orgID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// deleteOrganization removes the seeded data. Artifacts and licenses restrict the deletion of their organization, so
+// they are deleted first. Failures are only logged, because the test database is not shared.
+func deleteOrganization(ctx context.Context, t testing.TB, org *testutil.OrganizationWithUsers) {
+	logErr := func(err error) {
+		if err != nil {
+			t.Logf("could not clean up organization %v: %v", org.ID, err)
+		}
+	}
+	for _, artifactID := range getArtifactIDs(ctx, t, org.ID) {
+		logErr(db.DeleteArtifact(ctx, artifactID))
+	}
+	_, err := internalctx.GetDb(ctx).Exec(ctx, `
+		DELETE FROM ArtifactLicense WHERE organization_id = @id;
+		DELETE FROM Organization WHERE id = @id`,
+		pgx.NamedArgs{"id": org.ID})
+	logErr(err)
+	for _, user := range slices.Concat(org.Vendors, org.Customers) {
+		logErr(db.DeleteUserAccountWithID(ctx, user.ID))
+	}
+}
+
+// stats collects the results of an operation. It keeps all latencies, so that exact quantiles can be reported.
+type stats struct {
+	mu         sync.Mutex
+	latencies  []time.Duration
+	queries    int64
+	maxQueries int64
+	failures   map[int]int
+}
+
+func (s *stats) record(r result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, r.latency)
+	s.queries += r.queries
+	s.maxQueries = max(s.maxQueries, r.queries)
+	if r.failed() {
+		if s.failures == nil {
+			s.failures = map[int]int{}
+		}
+		s.failures[r.status]++
+	}
+}
+
+func (s *stats) quantile(q float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	return s.latencies[int(q*float64(len(s.latencies)-1))]
+}
+
+// report returns a summary of the operation followed by a histogram of the latencies with buckets of doubling width,
+// starting at 100µs.
+func (s *stats) report(elapsed time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slices.Sort(s.latencies)
+	n := len(s.latencies)
+	if n == 0 {
+		return "no requests"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v ops (%.1f/s), failures: %v, queries: avg %.1f max %v\n",
+		n, float64(n)/elapsed.Seconds(), s.failures, float64(s.queries)/float64(n), s.maxQueries)
+	fmt.Fprintf(&b, "latency: p50 %v, p90 %v, p99 %v, max %v\n",
+		s.quantile(0.5), s.quantile(0.9), s.quantile(0.99), s.latencies[n-1])
+	bound := 100 * time.Microsecond
+	for i := 0; i < n; bound *= 2 {
+		count := 0
+		for ; i < n && s.latencies[i] <= bound; i++ {
+			count++
+		}
+		if count > 0 {
+			fmt.Fprintf(&b, "  <= %-8v %6v %s\n", bound, count, strings.Repeat("#", (count*50+n-1)/n))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+//go:build loadtest
+
+// Package loadtest_test drives the registry handlers end to end against a seeded database. The database is taken from
+// TEST_DATABASE_URL, blobs are kept in memory.
+//
+//	# query budgets per operation, also run in CI
+//	go test -tags loadtest -run TestRegistryQueryBudgets ./internal/registry/loadtest/
+//	# concurrent load with latency histograms
+//	go test -tags loadtest -run TestRegistryLoad -v ./internal/registry/loadtest/ \
+//		-loadtest.concurrency=32 -loadtest.duration=30s
+//	# benchmarks with queries per operation
+//	go test -tags loadtest -run '^$' -bench . ./internal/registry/loadtest/
+package loadtest_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+type operation struct {
+	name string
+	// budget is the maximum number of database queries of a single operation. Budgets are generous, they are meant to
+	// catch regressions by an order of magnitude, e.g. a query per tag or per license.
+	budget int64
+	run    func(h *harness, i int) result
+}
+
+var operations = []operation{
+	{name: "pull-by-tag", budget: 20, run: func(h *harness, i int) result {
+		return h.customer.pullManifest(h.repo, h.tags[i%len(h.tags)])
+	}},
+	{name: "pull-by-digest", budget: 20, run: func(h *harness, i int) result {
+		return h.customer.pullManifest(h.repo, h.images[i%len(h.images)].digest.String())
+	}},
+	{name: "pull-blob", budget: 15, run: func(h *harness, i int) result {
+		return h.customer.pullBlob(h.repo, h.images[i%len(h.images)].layer)
+	}},
+	{name: "list-tags", budget: 15, run: func(h *harness, i int) result {
+		return h.customer.listTags(h.repo)
+	}},
+	// a push consists of two blob uploads and the manifest upload
+	{name: "push-distinct", budget: 60, run: func(h *harness, i int) result {
+		id := uuid.NewString()
+		_, res := h.vendor.pushImage(h.pushRepo, "distinct-"+id, id)
+		return res
+	}},
+	{name: "push-identical", budget: 60, run: func(h *harness, i int) result {
+		_, res := h.vendor.pushImage(h.pushRepo, "identical", "identical")
+		return res
+	}},
+}
+
+func TestRegistryQueryBudgets(t *testing.T) {
+	h := newHarness(t)
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			g := NewWithT(t)
+			for i := range 3 {
+				res := op.run(h, i)
+				g.Expect(res.failed()).To(BeFalse(), "status %v: %s", res.status, res.body)
+				g.Expect(res.queries).To(BeNumerically("<=", op.budget))
+			}
+		})
+	}
+
+	// the number of queries must not depend on the number of tags of a repository
+	t.Run("independent-of-tags", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(h.customer.listTags(h.repo).queries).To(Equal(h.customer.listTags(h.smallRepo).queries))
+		g.Expect(h.customer.pullManifest(h.repo, h.tags[len(h.tags)-1]).queries).
+			To(Equal(h.customer.pullManifest(h.smallRepo, "1.0.0").queries))
+	})
+}
+
+func TestRegistryLoad(t *testing.T) {
+	h := newHarness(t)
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			var s stats
+			var next atomic.Int64
+			var wg sync.WaitGroup
+			start := time.Now()
+			deadline := start.Add(*duration)
+			for range *concurrency {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for time.Now().Before(deadline) {
+						s.record(op.run(h, int(next.Add(1))))
+					}
+				}()
+			}
+			wg.Wait()
+			t.Logf("%v with concurrency %v:\n%v", op.name, *concurrency, s.report(time.Since(start)))
+		})
+	}
+}
+
+func BenchmarkRegistry(b *testing.B) {
+	h := newHarness(b)
+	for _, op := range operations {
+		b.Run(op.name, func(b *testing.B) {
+			var next, queries, failures atomic.Int64
+			b.SetParallelism(*concurrency)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					res := op.run(h, int(next.Add(1)))
+					queries.Add(res.queries)
+					if res.failed() {
+						failures.Add(1)
+					}
+				}
+			})
+			b.ReportMetric(float64(queries.Load())/float64(b.N), "queries/op")
+			if n := failures.Load(); n > 0 {
+				b.Log(fmt.Sprintf("%v of %v operations failed", n, b.N))
+			}
+		})
+	}
+}
@@ -22,8 +22,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/internal/apierrors"
@@ -34,10 +37,12 @@ import (
 	"github.com/glasskube/distr/internal/registry/blob"
 	registryerror "github.com/glasskube/distr/internal/registry/error"
 	"github.com/glasskube/distr/internal/registry/manifest"
+	internaltypes "github.com/glasskube/distr/internal/types"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 type catalog struct {
@@ -55,8 +60,34 @@ type manifests struct {
 	authz           authz.Authorizer
 	audit           audit.ArtifactAuditor
 	log             *zap.SugaredLogger
+	nameMaxDepth    int
+	maxSize         int64
+	indexLimits     IndexLimits
+	cache           *manifestCache
+	cacheNotifier   ManifestCacheNotifier
+	defaultPlatform *v1.Platform
+	pullThrough     *pullThrough
+
+	// indexChildGracePeriod is how long a pushed image index waits for the manifests that it references
+	indexChildGracePeriod time.Duration
 }
 
+// maxPageSize is the maximum number of tags or repositories that are listed in one response. Requests without n or
+// with a larger n receive a page of this size and a Link header that points to the next page, so that the size of a
+// response does not grow with the size of the repository.
+const maxPageSize = 1000
+
+// maxConcurrentBlobChecks is the maximum number of blobs whose existence is checked concurrently when a manifest is
+// pushed.
+const maxConcurrentBlobChecks = 8
+
+// maxBufferedManifestSize is the size in bytes above which manifests are streamed from the blob handler by every
+// request instead of being read into memory and cached.
+const maxBufferedManifestSize = 256 * 1024
+
+// errManifestBlobUnavailable is returned by readManifest if the manifest exists but its blob can not be fetched.
+var errManifestBlobUnavailable = errors.New("manifest blob unavailable")
+
 func isManifest(req *http.Request) bool {
 	elems := strings.Split(req.URL.Path, "/")
 	elems = elems[1:]
@@ -113,6 +144,7 @@ func (handler *manifests) handle(resp http.ResponseWriter, req *http.Request) *r
 			}
 			return regErrInternal(err)
 		}
+		handler.refreshFromUpstream(req.Context(), repo, target)
 		return handler.handleGet(resp, req, repo, target)
 	case http.MethodHead:
 		if err := handler.authz.AuthorizeReference(req.Context(), repo, target, authz.ActionStat); err != nil {
@@ -123,8 +155,12 @@ func (handler *manifests) handle(resp http.ResponseWriter, req *http.Request) *r
 			}
 			return regErrInternal(err)
 		}
+		handler.refreshFromUpstream(req.Context(), repo, target)
 		return handler.handleHead(resp, req, repo, target)
 	case http.MethodPut:
+		if err := validateRepoName(repo, handler.nameMaxDepth); err != nil {
+			return err
+		}
 		if err := handler.authz.AuthorizeReference(req.Context(), repo, target, authz.ActionWrite); err != nil {
 			if errors.Is(err, authz.ErrAccessDenied) {
 				return regErrDenied
@@ -143,7 +179,7 @@ func (handler *manifests) handle(resp http.ResponseWriter, req *http.Request) *r
 	// 	}
 	// 	return handler.handleDelete(resp, req, repo, target)
 	default:
-		return regErrMethodUnknown
+		return regErrMethodNotAllowed
 	}
 }
 
@@ -163,82 +199,109 @@ func (m *manifests) handleTags(resp http.ResponseWriter, req *http.Request) *reg
 		}
 
 		last := req.URL.Query().Get("last")
-		n := 10000
-		if ns := req.URL.Query().Get("n"); ns != "" {
-			if parsed, err := strconv.Atoi(ns); err != nil {
-				return &regError{
-					Status:  http.StatusBadRequest,
-					Code:    "BAD_REQUEST",
-					Message: fmt.Sprintf("parsing n: %v", err),
-				}
-			} else {
-				n = parsed
-			}
+		n, regErr := parsePageSize(req)
+		if regErr != nil {
+			return regErr
 		}
 
-		references, err := m.manifestHandler.ListTags(req.Context(), repo, n, last)
+		references, more, err := m.manifestHandler.ListTags(req.Context(), repo, n, last)
 		if errors.Is(err, manifest.ErrNameUnknown) {
-			return regErrNameUnknown
+			return m.regErrNameUnknown(repo)
 		} else if err != nil {
 			return regErrInternal(err)
 		}
-
-		tagsToList := listTags{
-			Name: repo,
-			Tags: references,
+		if more && len(references) > 0 {
+			resp.Header().Set("Link", nextTagsLink(repo, n, references[len(references)-1]))
 		}
 
-		msg, err := json.Marshal(tagsToList)
-		if err != nil {
-			return regErrInternal(err)
-		}
-		resp.Header().Set("Content-Length", fmt.Sprint(len(msg)))
-		resp.WriteHeader(http.StatusOK)
-		if _, err := io.Copy(resp, bytes.NewReader(msg)); err != nil {
-			return regErrInternal(err)
-		}
+		m.writeList(resp, listTags{Name: repo, Tags: references})
 		return nil
 	}
 
-	return regErrMethodUnknown
+	return regErrMethodNotAllowed
 }
 
-func (m *manifests) handleCatalog(resp http.ResponseWriter, req *http.Request) *regError {
-	query := req.URL.Query()
-	nStr := query.Get("n")
-	n := 10000
-	if nStr != "" {
-		n, _ = strconv.Atoi(nStr)
+// nextTagsLink returns the RFC5988 Link header value that points to the page of tags after last.
+func nextTagsLink(repo string, n int, last string) string {
+	next := url.URL{
+		Path:     "/v2/" + repo + "/tags/list",
+		RawQuery: url.Values{"n": {strconv.Itoa(n)}, "last": {last}}.Encode(),
+	}
+	return fmt.Sprintf(`<%v>; rel="next"`, next.String())
+}
+
+// nextCatalogLink returns the RFC5988 Link header value that points to the page of repositories after last.
+func nextCatalogLink(n int, last string) string {
+	next := url.URL{
+		Path:     "/v2/_catalog",
+		RawQuery: url.Values{"n": {strconv.Itoa(n)}, "last": {last}}.Encode(),
+	}
+	return fmt.Sprintf(`<%v>; rel="next"`, next.String())
+}
+
+// parsePageSize returns the n query parameter of a paginated list request. It returns maxPageSize if n is not set, is
+// zero or exceeds maxPageSize.
+func parsePageSize(req *http.Request) (int, *regError) {
+	ns := req.URL.Query().Get("n")
+	if ns == "" {
+		return maxPageSize, nil
+	} else if n, err := strconv.Atoi(ns); err != nil {
+		return 0, regErrQueryInvalid(fmt.Sprintf("parsing n: %v", err))
+	} else if n < 0 {
+		return 0, regErrQueryInvalid("n must not be negative")
+	} else if n == 0 || n > maxPageSize {
+		return maxPageSize, nil
+	} else {
+		return n, nil
+	}
+}
+
+// writeList encodes a page of tags or repositories directly to the response. The response is sent without a
+// Content-Length, so that it does not have to be buffered. Errors can not be reported to the client once the status
+// has been written, so they are only logged.
+func (m *manifests) writeList(resp http.ResponseWriter, list any) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(resp).Encode(list); err != nil {
+		m.log.Warnw("could not write list", "error", err)
 	}
+}
 
+func (m *manifests) handleCatalog(resp http.ResponseWriter, req *http.Request) *regError {
 	if req.Method == http.MethodGet {
-		repos, err := m.manifestHandler.List(req.Context(), n)
-		if err != nil {
+		if err := m.authz.AuthorizeCatalog(req.Context()); err != nil {
+			if errors.Is(err, authz.ErrAccessDenied) {
+				return regErrDenied
+			}
 			return regErrInternal(err)
 		}
+		last := req.URL.Query().Get("last")
+		n, regErr := parsePageSize(req)
+		if regErr != nil {
+			return regErr
+		}
 
-		repositoriesToList := catalog{Repos: repos}
-
-		msg, err := json.Marshal(repositoriesToList)
+		repos, more, err := m.manifestHandler.List(req.Context(), n, last)
 		if err != nil {
 			return regErrInternal(err)
 		}
-		resp.Header().Set("Content-Length", fmt.Sprint(len(msg)))
-		resp.WriteHeader(http.StatusOK)
-		if _, err := io.Copy(resp, bytes.NewReader(msg)); err != nil {
-			return regErrInternal(err)
+		if more && len(repos) > 0 {
+			resp.Header().Set("Link", nextCatalogLink(n, repos[len(repos)-1]))
 		}
+
+		m.writeList(resp, catalog{Repos: repos})
 		return nil
 	}
 
-	return regErrMethodUnknown
+	return regErrMethodNotAllowed
 }
 
-// TODO: implement handling of artifactType querystring
+// handleReferrers lists the manifests whose subject is the target digest. If the artifactType query parameter is set,
+// only referrers of that artifact type are listed and the OCI-Filters-Applied header is set.
 func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request) *regError {
 	// Ensure this is a GET request
 	if req.Method != http.MethodGet {
-		return regErrMethodUnknown
+		return regErrMethodNotAllowed
 	}
 
 	elem := strings.Split(req.URL.Path, "/")
@@ -257,16 +320,14 @@ func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request)
 
 	// Validate that incoming target is a valid digest
 	if _, err := v1.NewHash(target); err != nil {
-		return &regError{
-			Status:  http.StatusBadRequest,
-			Code:    "UNSUPPORTED",
-			Message: "Target must be a valid digest",
-		}
+		return regErrDigestInvalid
 	}
 
+	artifactTypeFilter := req.URL.Query().Get("artifactType")
+
 	digests, err := m.manifestHandler.ListDigests(req.Context(), repo)
 	if errors.Is(err, manifest.ErrNameUnknown) {
-		return regErrNameUnknown
+		return m.regErrNameUnknown(repo)
 	} else if err != nil {
 		return regErrInternal(err)
 	}
@@ -284,11 +345,10 @@ func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request)
 
 		b, err := m.blobHandler.Get(req.Context(), repo, manifest.Blob.Digest, false)
 		if err != nil {
-			return &regError{
-				Status:  http.StatusNotFound,
-				Code:    "BAD_REQUEST",
-				Message: err.Error(),
+			if errors.Is(err, blob.ErrNotFound) {
+				return regErrBlobUnknown
 			}
+			return regErrInternal(err)
 		}
 		defer b.Close()
 		var buf bytes.Buffer
@@ -309,16 +369,25 @@ func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request)
 		}
 		// At this point, we know the current digest references the target
 		var imageAsArtifact struct {
-			Config struct {
+			ArtifactType string `json:"artifactType"`
+			Config       struct {
 				MediaType string `json:"mediaType"`
 			} `json:"config"`
 		}
 		_ = json.Unmarshal(buf.Bytes(), &imageAsArtifact)
+		// the artifact type of a manifest defaults to its config media type
+		artifactType := imageAsArtifact.ArtifactType
+		if artifactType == "" {
+			artifactType = imageAsArtifact.Config.MediaType
+		}
+		if artifactTypeFilter != "" && artifactType != artifactTypeFilter {
+			continue
+		}
 		im.Manifests = append(im.Manifests, v1.Descriptor{
 			MediaType:    types.MediaType(manifest.ContentType),
 			Size:         int64(buf.Len()),
 			Digest:       reference,
-			ArtifactType: imageAsArtifact.Config.MediaType,
+			ArtifactType: artifactType,
 		})
 	}
 	msg, err := json.Marshal(&im)
@@ -327,6 +396,9 @@ func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request)
 	}
 	resp.Header().Set("Content-Length", fmt.Sprint(len(msg)))
 	resp.Header().Set("Content-Type", string(types.OCIImageIndex))
+	if artifactTypeFilter != "" {
+		resp.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
 	resp.WriteHeader(http.StatusOK)
 	if _, err := io.Copy(resp, bytes.NewReader(msg)); err != nil {
 		return regErrInternal(err)
@@ -336,10 +408,99 @@ func (m *manifests) handleReferrers(resp http.ResponseWriter, req *http.Request)
 
 func (handler *manifests) handleGet(resp http.ResponseWriter, req *http.Request, repo, target string) *regError {
 	ctx := req.Context()
-	m, err := handler.manifestHandler.Get(ctx, repo, target)
+	content, rerr := handler.getManifest(ctx, repo, target)
+	if rerr != nil {
+		return rerr
+	}
+
+	if handler.needsPlatformManifest(req, content.manifest) {
+		desc, rerr := handler.platformManifest(ctx, req, repo, content)
+		if rerr != nil {
+			return rerr
+		}
+		if content, rerr = handler.getManifest(ctx, repo, desc.Digest.String()); rerr != nil {
+			return rerr
+		}
+	}
+
+	if notModified(resp, req, content.manifest.Blob.Digest) {
+		return nil
+	}
+
+	if content.redirect != nil {
+		if err := handler.audit.AuditPull(ctx, repo, target, req.Method); err != nil {
+			log := internalctx.GetLogger(ctx)
+			log.Warn("failed to audit-log pull", zap.Error(err))
+			sentry.GetHubFromContext(ctx)
+		}
+		http.Redirect(resp, req, content.redirect.Location, content.redirect.Code)
+		return nil
+	}
+
+	if content.unbuffered {
+		if rerr := handler.streamManifest(resp, req, repo, content.manifest); rerr != nil {
+			return rerr
+		}
+	} else {
+		resp.Header().Set("Docker-Content-Digest", content.manifest.Blob.Digest.String())
+		resp.Header().Set("Content-Type", content.manifest.ContentType)
+		resp.Header().Set("Content-Length", fmt.Sprint(len(content.data)))
+		resp.WriteHeader(http.StatusOK)
+		if _, err := resp.Write(content.data); err != nil {
+			return regErrInternal(err)
+		}
+	}
+	if err := handler.audit.AuditPull(ctx, repo, target, req.Method); err != nil {
+		log := internalctx.GetLogger(ctx)
+		log.Warn("failed to audit-log pull", zap.Error(err))
+		sentry.GetHubFromContext(ctx)
+	}
+	return nil
+}
+
+// getManifest returns the content of the manifest of repo with reference from the cache.
+func (handler *manifests) getManifest(ctx context.Context, repo, reference string) (*manifestContent, *regError) {
+	content, err := handler.cache.get(ctx, repo, reference, func(ctx context.Context) (*manifestContent, error) {
+		return handler.readManifest(ctx, repo, reference)
+	})
 	if errors.Is(err, manifest.ErrNameUnknown) {
-		return regErrNameUnknown
-	} else if errors.Is(err, manifest.ErrManifestUnknown) {
+		return nil, handler.regErrNameUnknown(repo)
+	} else if errors.Is(err, manifest.ErrManifestUnknown) || errors.Is(err, errManifestBlobUnavailable) {
+		// TODO: More nuanced
+		return nil, regErrManifestUnknown
+	} else if err != nil {
+		return nil, regErrInternal(err)
+	}
+	return content, nil
+}
+
+// readManifest looks up the manifest of repo with reference and fetches its content from the blob handler, unless it
+// is larger than maxBufferedManifestSize.
+func (handler *manifests) readManifest(ctx context.Context, repo, reference string) (*manifestContent, error) {
+	m, err := handler.manifestHandler.Get(ctx, repo, reference)
+	if err != nil {
+		return nil, err
+	} else if m.Blob.Size > maxBufferedManifestSize {
+		return &manifestContent{manifest: *m, unbuffered: true}, nil
+	}
+	return handler.readManifestBlob(ctx, repo, *m, true)
+}
+
+// streamManifest writes the content of m to resp while it is read from the blob handler, or redirects the client if the
+// blob handler supports it.
+func (handler *manifests) streamManifest(
+	resp http.ResponseWriter,
+	req *http.Request,
+	repo string,
+	m manifest.Manifest,
+) *regError {
+	ctx := req.Context()
+	bsh, ok := handler.blobHandler.(blob.BlobStatHandler)
+	if !ok {
+		return regErrInternal(errors.New("cannot stat blob"))
+	}
+	size, err := bsh.Stat(ctx, repo, m.Blob.Digest)
+	if errors.Is(err, blob.ErrNotFound) {
 		return regErrManifestUnknown
 	} else if err != nil {
 		return regErrInternal(err)
@@ -349,50 +510,85 @@ func (handler *manifests) handleGet(resp http.ResponseWriter, req *http.Request,
 	if err != nil {
 		var rerr blob.RedirectError
 		if errors.As(err, &rerr) {
-			if err := handler.audit.AuditPull(ctx, repo, target); err != nil {
-				log := internalctx.GetLogger(ctx)
-				log.Warn("failed to audit-log pull", zap.Error(err))
-				sentry.GetHubFromContext(ctx)
-			}
 			http.Redirect(resp, req, rerr.Location, rerr.Code)
 			return nil
+		} else if errors.Is(err, blob.ErrNotFound) {
+			return regErrManifestUnknown
 		}
-		// TODO: More nuanced
-		return regErrManifestUnknown
-	}
-	defer b.Close()
-
-	buf := bytes.Buffer{}
-	if _, err = io.Copy(&buf, b); err != nil {
 		return regErrInternal(err)
 	}
+	defer b.Close()
 
 	resp.Header().Set("Docker-Content-Digest", m.Blob.Digest.String())
 	resp.Header().Set("Content-Type", m.ContentType)
-	resp.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+	resp.Header().Set("Content-Length", fmt.Sprint(size))
 	resp.WriteHeader(http.StatusOK)
-	if _, err := io.Copy(resp, &buf); err != nil {
-		return regErrInternal(err)
-	}
-	if err := handler.audit.AuditPull(ctx, repo, target); err != nil {
-		log := internalctx.GetLogger(ctx)
-		log.Warn("failed to audit-log pull", zap.Error(err))
-		sentry.GetHubFromContext(ctx)
+	if _, err := io.Copy(resp, b); err != nil {
+		// the status has been sent already, so the client can only notice the error by the missing content
+		internalctx.GetLogger(ctx).Warn("failed to stream manifest", zap.Error(err))
 	}
 	return nil
 }
 
+// readManifestBlob fetches the content of m from the blob handler. If allowRedirect is true and the blob handler
+// redirects clients to another location, only the redirect is returned.
+func (handler *manifests) readManifestBlob(
+	ctx context.Context,
+	repo string,
+	m manifest.Manifest,
+	allowRedirect bool,
+) (*manifestContent, error) {
+	b, err := handler.blobHandler.Get(ctx, repo, m.Blob.Digest, allowRedirect)
+	if err != nil {
+		var rerr blob.RedirectError
+		if errors.As(err, &rerr) {
+			return &manifestContent{manifest: m, redirect: &rerr}, nil
+		}
+		return nil, fmt.Errorf("%w: %w", errManifestBlobUnavailable, err)
+	}
+	defer b.Close()
+
+	buf := bytes.Buffer{}
+	if _, err = io.Copy(&buf, b); err != nil {
+		return nil, err
+	}
+	return &manifestContent{manifest: m, data: buf.Bytes()}, nil
+}
+
 func (handler *manifests) handleHead(resp http.ResponseWriter, req *http.Request, repo, target string) *regError {
 	ctx := req.Context()
 	m, err := handler.manifestHandler.Get(ctx, repo, target)
 	if errors.Is(err, manifest.ErrNameUnknown) {
-		return regErrNameUnknown
+		return handler.regErrNameUnknown(repo)
 	} else if errors.Is(err, manifest.ErrManifestUnknown) {
 		return regErrManifestUnknown
 	} else if err != nil {
 		return regErrInternal(err)
 	}
 
+	if handler.needsPlatformManifest(req, *m) {
+		content, rerr := handler.getManifest(ctx, repo, target)
+		if rerr != nil {
+			return rerr
+		}
+		desc, rerr := handler.platformManifest(ctx, req, repo, content)
+		if rerr != nil {
+			return rerr
+		} else if notModified(resp, req, desc.Digest) {
+			return nil
+		}
+		if err := handler.audit.AuditPull(ctx, repo, target, req.Method); err != nil {
+			log := internalctx.GetLogger(ctx)
+			log.Warn("failed to audit-log pull", zap.Error(err))
+			sentry.GetHubFromContext(ctx)
+		}
+		resp.Header().Set("Docker-Content-Digest", desc.Digest.String())
+		resp.Header().Set("Content-Type", string(desc.MediaType))
+		resp.Header().Set("Content-Length", fmt.Sprint(desc.Size))
+		resp.WriteHeader(http.StatusOK)
+		return nil
+	}
+
 	bsh, ok := handler.blobHandler.(blob.BlobStatHandler)
 	if !ok {
 		return regErrInternal(errors.New("cannot stat blob"))
@@ -402,9 +598,11 @@ func (handler *manifests) handleHead(resp http.ResponseWriter, req *http.Request
 	if err != nil {
 		// TODO: More nuanced
 		return regErrManifestUnknown
+	} else if notModified(resp, req, m.Blob.Digest) {
+		return nil
 	}
 
-	if err := handler.audit.AuditPull(ctx, repo, target); err != nil {
+	if err := handler.audit.AuditPull(ctx, repo, target, req.Method); err != nil {
 		log := internalctx.GetLogger(ctx)
 		log.Warn("failed to audit-log pull", zap.Error(err))
 		sentry.GetHubFromContext(ctx)
@@ -417,65 +615,101 @@ func (handler *manifests) handleHead(resp http.ResponseWriter, req *http.Request
 	return nil
 }
 
+// notModified sets the digest of the manifest as its strong ETag. If the client already has the manifest according to
+// If-None-Match, a 304 response without body is sent and true is returned. These requests are not audited as pulls,
+// so that clients polling for new releases do not inflate the pull statistics.
+func notModified(resp http.ResponseWriter, req *http.Request, digest v1.Hash) bool {
+	etag := `"` + digest.String() + `"`
+	resp.Header().Set("ETag", etag)
+	for _, header := range req.Header.Values("If-None-Match") {
+		for value := range strings.SplitSeq(header, ",") {
+			// If-None-Match uses the weak comparison
+			if value = strings.TrimPrefix(strings.TrimSpace(value), "W/"); value == "*" || value == etag {
+				resp.Header().Set("Docker-Content-Digest", digest.String())
+				resp.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (handler *manifests) handlePut(resp http.ResponseWriter, req *http.Request, repo, target string) *regError {
+	body := req.Body
+	if handler.maxSize > 0 {
+		if req.ContentLength > handler.maxSize {
+			return regErrManifestTooLarge(handler.maxSize)
+		}
+		body = http.MaxBytesReader(resp, req.Body, handler.maxSize)
+	}
 	buf := &bytes.Buffer{}
-	if _, err := io.Copy(buf, req.Body); err != nil {
+	if _, err := io.Copy(buf, body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return regErrManifestTooLarge(handler.maxSize)
+		}
 		return regErrInternal(err)
 	}
 
+	digest, regErr := handler.putManifest(
+		req.Context(), repo, target, req.Header.Get("Content-Type"), buf.Bytes(), true,
+	)
+	if regErr != nil {
+		return regErr
+	}
+	resp.Header().Set("Docker-Content-Digest", digest.String())
+	resp.Header().Set("OCI-Subject", digest.String())
+	resp.Header().Set("Location", req.URL.JoinPath(digest.String()).Path)
+	resp.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// putManifest stores the manifest data of repo by its digest and by target, which can be a tag or the same digest,
+// and returns its digest. If verifyBlobs is true, the manifest is rejected if a blob it references does not exist.
+// Pull-through caches fetch blobs on demand and store manifests without verifying them.
+func (handler *manifests) putManifest(
+	ctx context.Context,
+	repo, target, contentType string,
+	data []byte,
+	verifyBlobs bool,
+) (v1.Hash, *regError) {
 	mf := manifest.Manifest{
-		ContentType: req.Header.Get("Content-Type"),
+		ContentType: contentType,
 		Blob: manifest.Blob{
-			Size: int64(buf.Len()),
+			Size: int64(len(data)),
 		},
 	}
-	if manifestDigest, _, err := v1.SHA256(bytes.NewReader(buf.Bytes())); err != nil {
-		return regErrInternal(err)
+	if manifestDigest, _, err := v1.SHA256(bytes.NewReader(data)); err != nil {
+		return v1.Hash{}, regErrInternal(err)
 	} else {
 		mf.Blob.Digest = manifestDigest
 	}
 
 	var blobs []manifest.Blob
+	// required are the blobs that must have been uploaded before the manifest
+	var required []v1.Hash
 
 	// If the manifest is a manifest list, check that the manifest
 	// list's constituent manifests are already uploaded.
 	// This isn't strictly required by the registry API, but some
 	// registries require this.
 	if types.MediaType(mf.ContentType).IsIndex() {
-		if err := func(ctx context.Context) *regError {
-			im, err := v1.ParseIndexManifest(bytes.NewReader(buf.Bytes()))
-			if err != nil {
-				return regErrManifestInvalid(err)
-			}
-			for _, desc := range im.Manifests {
-				if !desc.MediaType.IsDistributable() {
-					continue
-				}
-				if desc.MediaType.IsIndex() || desc.MediaType.IsImage() {
-					if _, err := handler.manifestHandler.Get(ctx, repo, desc.Digest.String()); err != nil {
-						return &regError{
-							Status:  http.StatusNotFound,
-							Code:    "MANIFEST_UNKNOWN",
-							Message: fmt.Sprintf("Sub-manifest %q not found", desc.Digest),
-						}
-					}
-					blobs = append(blobs, manifest.Blob{Digest: desc.Digest, Size: desc.Size})
-				} else {
-					// TODO: Probably want to do an existence check for blobs.
-					handler.log.Warnf("TODO: Check blobs for %q (MediaType: %v)", desc.Digest, desc.MediaType)
-				}
-			}
-			return nil
-		}(req.Context()); err != nil {
-			return err
+		im, err := v1.ParseIndexManifest(bytes.NewReader(data))
+		if err != nil {
+			return v1.Hash{}, regErrManifestInvalid(err)
+		}
+		var regErr *regError
+		if blobs, required, regErr = handler.checkIndex(ctx, repo, im); regErr != nil {
+			return v1.Hash{}, regErr
 		}
 	} else if types.MediaType(mf.ContentType).IsImage() {
 		if err := func() *regError {
-			m, err := v1.ParseManifest(bytes.NewReader(buf.Bytes()))
+			m, err := v1.ParseManifest(bytes.NewReader(data))
 			if err != nil {
 				return regErrManifestInvalid(err)
 			}
 			blobs = append(blobs, manifest.Blob{Digest: m.Config.Digest, Size: m.Config.Size})
+			required = append(required, m.Config.Digest)
 			if m.Subject != nil {
 				blobs = append(blobs, manifest.Blob{Digest: m.Subject.Digest, Size: m.Subject.Size})
 			}
@@ -483,45 +717,96 @@ func (handler *manifests) handlePut(resp http.ResponseWriter, req *http.Request,
 				if !desc.MediaType.IsDistributable() {
 					continue
 				}
-				// TODO: Maybe check if the layer was already uploaded
 				blobs = append(blobs, manifest.Blob{Digest: desc.Digest, Size: desc.Size})
+				required = append(required, desc.Digest)
 			}
 			return nil
 		}(); err != nil {
-			return err
+			return v1.Hash{}, err
 		}
 	}
 
-	if err := checkIncompatibleManifest(buf.Bytes()); err != nil {
-		return err
+	if err := checkIncompatibleManifest(data); err != nil {
+		return v1.Hash{}, err
+	}
+	if verifyBlobs {
+		if err := handler.checkBlobsExist(ctx, repo, required); err != nil {
+			return v1.Hash{}, err
+		}
 	}
 
 	if bph, ok := handler.blobHandler.(blob.BlobPutHandler); !ok {
-		return regErrInternal(errors.New("blob handler is not a BlobPutHandler"))
+		return v1.Hash{}, regErrInternal(errors.New("blob handler is not a BlobPutHandler"))
 	} else {
-		if err := bph.Put(req.Context(), repo, mf.Blob.Digest, mf.ContentType, buf); err != nil {
-			return regErrInternal(err)
+		err := bph.Put(ctx, repo, mf.Blob.Digest, mf.ContentType, bytes.NewReader(data))
+		if errors.Is(err, apierrors.ErrQuotaExceeded) {
+			return v1.Hash{}, regErrDeniedStorageQuotaExceeded
+		} else if err != nil {
+			return v1.Hash{}, regErrInternal(err)
 		}
 	}
 
 	// Allow future references by target (tag) and immutable digest.
 	// See https://docs.docker.com/engine/reference/commandline/pull/#pull-an-image-by-digest-immutable-identifier.
-	err := db.RunTx(req.Context(), func(ctx context.Context) error {
-		return multierr.Combine(
+	err := db.RunTx(ctx, func(ctx context.Context) error {
+		if err := multierr.Combine(
 			handler.manifestHandler.Put(ctx, repo, mf.Blob.Digest.String(), mf, blobs),
 			handler.manifestHandler.Put(ctx, repo, target, mf, blobs),
-		)
+		); err != nil {
+			return err
+		} else if handler.cacheNotifier != nil {
+			return handler.cacheNotifier.Notify(ctx, internaltypes.ManifestInvalidation{
+				Repo:       repo,
+				References: []string{mf.Blob.Digest.String(), target},
+			})
+		}
+		return nil
 	})
 	if errors.Is(err, apierrors.ErrQuotaExceeded) {
-		return regErrDeniedQuotaExceeded
+		return v1.Hash{}, regErrDeniedQuotaExceeded
+	} else if errors.Is(err, manifest.ErrPendingDeletion) {
+		return v1.Hash{}, regErrDeniedPendingDeletion
+	} else if errors.Is(err, manifest.ErrReservedTag) {
+		return v1.Hash{}, regErrDeniedReservedTag
+	} else if errors.Is(err, manifest.ErrImmutableTag) {
+		return v1.Hash{}, regErrTagImmutable(target)
 	} else if err != nil {
-		return regErrInternal(err)
+		return v1.Hash{}, regErrInternal(err)
 	}
+	handler.cache.invalidate(repo, mf.Blob.Digest.String(), target)
+	return mf.Blob.Digest, nil
+}
 
-	resp.Header().Set("Docker-Content-Digest", mf.Blob.Digest.String())
-	resp.Header().Set("OCI-Subject", mf.Blob.Digest.String())
-	resp.Header().Set("Location", req.URL.JoinPath(mf.Blob.Digest.String()).Path)
-	resp.WriteHeader(http.StatusCreated)
+// checkBlobsExist returns MANIFEST_BLOB_UNKNOWN with the smallest digest in digests whose blob does not exist in repo.
+// Up to maxConcurrentBlobChecks blobs are checked concurrently.
+func (handler *manifests) checkBlobsExist(ctx context.Context, repo string, digests []v1.Hash) *regError {
+	bsh, ok := handler.blobHandler.(blob.BlobStatHandler)
+	if !ok {
+		return regErrInternal(errors.New("cannot stat blob"))
+	}
+	// the missing blob that is reported must not depend on the order in which the checks finish
+	digests = slices.Clone(digests)
+	slices.SortFunc(digests, func(a, b v1.Hash) int { return strings.Compare(a.String(), b.String()) })
+	digests = slices.Compact(digests)
+	missing := make([]bool, len(digests))
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(maxConcurrentBlobChecks)
+	for i, digest := range digests {
+		group.Go(func() error {
+			var rerr blob.RedirectError
+			if _, err := bsh.Stat(groupCtx, repo, digest); errors.Is(err, blob.ErrNotFound) {
+				missing[i] = true
+			} else if err != nil && !errors.As(err, &rerr) {
+				return err
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return regErrInternal(err)
+	} else if i := slices.Index(missing, true); i >= 0 {
+		return regErrManifestLayerUnknown(digests[i])
+	}
 	return nil
 }
 
@@ -538,6 +823,16 @@ func (handler *manifests) handlePut(resp http.ResponseWriter, req *http.Request,
 // 	return nil
 // }
 
+// regErrNameUnknown returns NAME_INVALID instead of NAME_UNKNOWN if repo does not conform to the name grammar.
+// Repositories with non-conforming names that existed before the grammar was enforced, as well as aliases of renamed
+// repositories, can still be read, so the name is only checked after the lookup has failed.
+func (handler *manifests) regErrNameUnknown(repo string) *regError {
+	if err := validateRepoName(repo, 0); err != nil {
+		return err
+	}
+	return regErrNameUnknown
+}
+
 func checkIncompatibleManifest(data []byte) *regError {
 	var mf struct {
 		Blobs []any `json:"blobs"`
@@ -22,9 +22,20 @@ func NewManifestHandler() manifest.ManifestHandler {
 	return &handler{}
 }
 
-// Delete implements manifest.ManifestHandler.
-func (h *handler) Delete(ctx context.Context, name string, reference string) error {
-	panic("TODO: implement")
+// Delete implements manifest.ManifestHandler. Deleting a tag only removes the tag, deleting a digest also removes
+// all tags that point at it.
+func (h *handler) Delete(ctx context.Context, nameStr string, reference string) error {
+	if name, err := name.Parse(nameStr); err != nil {
+		return fmt.Errorf("%w: %w", manifest.ErrNameUnknown, err)
+	} else if reference == types.ArtifactRecommendedTag {
+		return manifest.ErrReservedTag
+	} else if err := db.DeleteArtifactVersion(ctx, name.OrgName, name.ArtifactName, reference); err != nil {
+		if errors.Is(err, apierrors.ErrNotFound) {
+			return fmt.Errorf("%w: %w", manifest.ErrManifestUnknown, err)
+		}
+		return err
+	}
+	return nil
 }
 
 // Get implements manifest.ManifestHandler.
@@ -47,29 +58,55 @@ func (h *handler) Get(ctx context.Context, nameStr string, reference string) (*m
 	}
 }
 
-// List implements manifest.ManifestHandler.
-func (h *handler) List(ctx context.Context, n int) ([]string, error) {
-	auth := auth.ArtifactsAuthentication.Require(ctx)
-	var artifacts []types.ArtifactWithDownloads
-	var err error
-	if *auth.CurrentUserRole() == types.UserRoleCustomer && auth.CurrentOrg().HasFeature(types.FeatureLicensing) {
-		artifacts, err = db.GetArtifactsByLicenseOwnerID(ctx, *auth.CurrentOrgID(), auth.CurrentUserID())
-	} else {
-		artifacts, err = db.GetArtifactsByOrgID(ctx, *auth.CurrentOrgID())
-	}
-	if err != nil {
+// GetAll implements manifest.ManifestBatchHandler.
+func (h *handler) GetAll(
+	ctx context.Context,
+	nameStr string,
+	references []string,
+) (map[string]manifest.Manifest, error) {
+	if name, err := name.Parse(nameStr); err != nil {
+		return nil, fmt.Errorf("%w: %w", manifest.ErrNameUnknown, err)
+	} else if versions, err :=
+		db.GetArtifactVersionsByNames(ctx, name.OrgName, name.ArtifactName, references); err != nil {
 		return nil, err
+	} else {
+		result := make(map[string]manifest.Manifest, len(versions))
+		for _, av := range versions {
+			result[av.Name] = manifest.Manifest{
+				Blob: manifest.Blob{
+					Digest: v1.Hash(av.ManifestBlobDigest),
+					Size:   av.ManifestBlobSize,
+				},
+				ContentType: av.ManifestContentType,
+			}
+		}
+		return result, nil
 	}
-	result := make([]string, len(artifacts))
-	for i, artifact := range artifacts {
-		name := name.Name{OrgName: artifact.OrganizationSlug, ArtifactName: artifact.Name}
-		result[i] = name.String()
+}
+
+// AllowMissingIndexChildren implements manifest.MissingIndexChildrenHandler. Pushes are only authorized for the
+// current organization, so its setting applies to all repositories that can be pushed to.
+func (h *handler) AllowMissingIndexChildren(ctx context.Context, nameStr string) (bool, error) {
+	auth := auth.ArtifactsAuthentication.Require(ctx)
+	return auth.CurrentOrg().RegistryIndexChildCheckDisabled, nil
+}
+
+// List implements manifest.ManifestHandler. The page is selected by the database, so that only n+1 names are loaded
+// regardless of the number of artifacts.
+func (h *handler) List(ctx context.Context, n int, last string) ([]string, bool, error) {
+	auth := auth.ArtifactsAuthentication.Require(ctx)
+	var licenseUserID *uuid.UUID
+	if *auth.CurrentUserRole() == types.UserRoleCustomer && auth.CurrentOrg().HasFeature(types.FeatureLicensing) {
+		licenseUserID = util.PtrTo(auth.CurrentUserID())
 	}
-	// TODO: move to DB
-	if 0 < n && n < len(result) {
-		result = result[:n]
+	if names, err := db.GetArtifactRepositoryNames(
+		ctx, *auth.CurrentOrgID(), licenseUserID, last, pageSize(n),
+	); err != nil {
+		return nil, false, err
+	} else {
+		page, more := truncatePage(names, n)
+		return page, more, nil
 	}
-	return result, nil
 }
 
 // ListDigests implements manifest.ManifestHandler.
@@ -103,10 +140,11 @@ func (h *handler) ListDigests(ctx context.Context, nameStr string) ([]v1.Hash, e
 	}
 }
 
-// ListTags implements manifest.ManifestHandler.
-func (h *handler) ListTags(ctx context.Context, nameStr string, n int, last string) ([]string, error) {
+// ListTags implements manifest.ManifestHandler. The page is selected by the database, so that only n+1 tags are
+// loaded regardless of the number of tags in the repository.
+func (h *handler) ListTags(ctx context.Context, nameStr string, n int, last string) ([]string, bool, error) {
 	if name, err := name.Parse(nameStr); err != nil {
-		return nil, fmt.Errorf("%w: %w", manifest.ErrNameUnknown, err)
+		return nil, false, fmt.Errorf("%w: %w", manifest.ErrNameUnknown, err)
 	} else {
 		auth := auth.ArtifactsAuthentication.Require(ctx)
 		var licenseUserID *uuid.UUID
@@ -115,48 +153,76 @@ func (h *handler) ListTags(ctx context.Context, nameStr string, n int, last stri
 		}
 		if artifact, err := db.GetArtifactByName(ctx, name.OrgName, name.ArtifactName); err != nil {
 			if errors.Is(err, apierrors.ErrNotFound) {
-				return nil, fmt.Errorf("%w: %w", manifest.ErrNameUnknown, err)
+				return nil, false, fmt.Errorf("%w: %w", manifest.ErrNameUnknown, err)
 			}
-			return nil, err
-		} else if versions, err := db.GetVersionsForArtifact(ctx, artifact.ID, licenseUserID); err != nil {
-			return nil, err
+			return nil, false, err
+		} else if tags, err := db.GetArtifactTagNames(ctx, artifact.ID, licenseUserID, last, pageSize(n)); err != nil {
+			return nil, false, err
 		} else {
-			var result []string
-			for _, version := range versions {
-				for _, tag := range version.Tags {
-					result = append(result, tag.Name)
-				}
-			}
-			return result, nil
+			page, more := truncatePage(tags, n)
+			return page, more, nil
 		}
 	}
 }
 
+// pageSize returns the number of rows to load for a page of n entries. One more entry than requested is loaded to
+// find out if there are more pages.
+func pageSize(n int) int {
+	if n > 0 {
+		return n + 1
+	}
+	return 0
+}
+
+// truncatePage cuts the entries that were loaded according to pageSize to n and reports whether there are more.
+func truncatePage(entries []string, n int) ([]string, bool) {
+	if 0 < n && n < len(entries) {
+		return entries[:n], true
+	}
+	return entries, false
+}
+
 // Put implements manifest.ManifestHandler.
 func (h *handler) Put(
 	ctx context.Context,
 	nameStr, reference string,
-	manifest manifest.Manifest,
+	mf manifest.Manifest,
 	blobs []manifest.Blob,
 ) error {
 	auth := auth.ArtifactsAuthentication.Require(ctx)
 	name, err := name.Parse(nameStr)
 	if err != nil {
 		return err
+	} else if reference == types.ArtifactRecommendedTag {
+		return manifest.ErrReservedTag
+	}
+	digests := []types.Digest{types.Digest(mf.Blob.Digest)}
+	for _, blob := range blobs {
+		digests = append(digests, types.Digest(blob.Digest))
 	}
 	return db.RunTx(ctx, func(ctx context.Context) error {
+		// keeps the blob garbage collection from deleting the blobs before they are referenced
+		if err := db.LockBlobMetadata(ctx, digests); err != nil {
+			return err
+		}
 		artifact, err := db.GetOrCreateArtifact(ctx, *auth.CurrentOrgID(), name.ArtifactName)
 		if err != nil {
 			return err
+		} else if artifact.IsPendingDeletion() {
+			return manifest.ErrPendingDeletion
 		}
 
 		version := types.ArtifactVersion{
-			CreatedByUserAccountID: util.PtrTo(auth.CurrentUserID()),
-			Name:                   reference,
-			ManifestBlobDigest:     types.Digest(manifest.Blob.Digest),
-			ManifestBlobSize:       manifest.Blob.Size,
-			ManifestContentType:    manifest.ContentType,
-			ArtifactID:             artifact.ID,
+			Name:                reference,
+			ManifestBlobDigest:  types.Digest(mf.Blob.Digest),
+			ManifestBlobSize:    mf.Blob.Size,
+			ManifestContentType: mf.ContentType,
+			ArtifactID:          artifact.ID,
+		}
+		// service accounts and anonymous clients, which can fill proxy repositories from their upstream, have no user
+		// account that could be recorded as creator
+		if !auth.IsServiceAccount() && !auth.IsAnonymous() {
+			version.CreatedByUserAccountID = util.PtrTo(auth.CurrentUserID())
 		}
 
 		existingVersion, err := db.GetArtifactVersion(ctx, name.OrgName, name.ArtifactName, reference)
@@ -170,13 +236,30 @@ func (h *handler) Put(
 			} else if err := db.CreateArtifactVersion(ctx, &version); err != nil {
 				return err
 			}
-		} else if existingVersion.ManifestBlobDigest != version.ManifestBlobDigest ||
-			existingVersion.ManifestContentType != version.ManifestContentType {
+		} else if existingVersion.ManifestBlobDigest == version.ManifestBlobDigest &&
+			existingVersion.ManifestContentType == version.ManifestContentType {
+			version = *existingVersion
+		} else if _, err := v1.NewHash(reference); err == nil {
 			return fmt.Errorf("reference already exists with different manifest digest")
+		} else if artifact.IsTagImmutable(reference) {
+			return fmt.Errorf("%w: %v already points at %v", manifest.ErrImmutableTag, reference,
+				existingVersion.ManifestBlobDigest)
 		} else {
-			version = *existingVersion
+			// the tag is moved to the new manifest
+			version.ID = existingVersion.ID
+			version.UpdatedByUserAccountID = version.CreatedByUserAccountID
+			if err := db.UpdateArtifactVersionManifest(ctx, &version); err != nil {
+				return err
+			}
 		}
 
+		// Blobs that already exist, e.g. because another organization has pushed them, are not uploaded again, so
+		// they are counted towards the storage usage when they are referenced.
+		if err := db.AddOrganizationBlobUsage(
+			ctx, *auth.CurrentOrgID(), types.Digest(mf.Blob.Digest), mf.Blob.Size,
+		); err != nil {
+			return err
+		}
 		for _, blob := range blobs {
 			part := types.ArtifactVersionPart{
 				ArtifactVersionID:  version.ID,
@@ -185,6 +268,10 @@ func (h *handler) Put(
 			}
 			if err := db.CreateArtifactVersionPart(ctx, &part); err != nil {
 				return err
+			} else if err := db.AddOrganizationBlobUsage(
+				ctx, *auth.CurrentOrgID(), part.ArtifactBlobDigest, part.ArtifactBlobSize,
+			); err != nil {
+				return err
 			}
 		}
 		return nil
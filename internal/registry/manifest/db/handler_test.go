@@ -0,0 +1,131 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	manifestdb "github.com/glasskube/distr/internal/registry/manifest/db"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+// authenticated returns ctx with the registry authentication of an access token of the user, like it is created by the
+// middleware of the registry.
+func authenticated(ctx context.Context, t *testing.T, userID, orgID uuid.UUID) context.Context {
+	t.Helper()
+	_, key := testutil.NewAccessToken(ctx, t, userID, orgID)
+	r := httptest.NewRequestWithContext(ctx, http.MethodGet, "/v2/", nil)
+	r.SetBasicAuth("test", key)
+	var result context.Context
+	auth.ArtifactsAuthentication.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	if result == nil {
+		t.Fatal("could not authenticate")
+	}
+	return result
+}
+
+func TestListTags(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	ctx = authenticated(ctx, t, org.Vendors[0].ID, org.ID)
+	h := manifestdb.NewManifestHandler()
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID, "b", "C", "a-1", "a")
+	repo := *org.Slug + "/" + artifact.Name
+
+	// pages are in byte order, regardless of the collation of the database
+	tags, more, err := h.ListTags(ctx, repo, 2, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tags).To(Equal([]string{"C", "a"}))
+	g.Expect(more).To(BeTrue())
+	tags, more, err = h.ListTags(ctx, repo, 2, "a")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tags).To(Equal([]string{"a-1", "b"}))
+	g.Expect(more).To(BeFalse())
+
+	_, err = internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE Artifact SET recommended_artifact_version_id = $1 WHERE id = $2", versions[0].ID, artifact.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	tags, more, err = h.ListTags(ctx, repo, 0, "a-1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tags).To(Equal([]string{"b", types.ArtifactRecommendedTag}))
+	g.Expect(more).To(BeFalse())
+}
+
+func TestListTagsIsBounded(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	ctx = authenticated(ctx, t, org.Vendors[0].ID, org.ID)
+	h := manifestdb.NewManifestHandler()
+	artifact, versions := testutil.NewArtifactWithTags(ctx, t, org.ID, org.Vendors[0].ID)
+	repo := *org.Slug + "/" + artifact.Name
+	const tagCount = 50_000
+	// long tags make the size of the tags dominate the allocations of a listing
+	_, err := internalctx.GetDb(ctx).Exec(ctx, `
+		INSERT INTO ArtifactVersion (name, created_by_useraccount_id, manifest_blob_digest, manifest_blob_size,
+			manifest_content_type, artifact_id)
+		SELECT lpad(i::TEXT, 5, '0') || '-' || repeat('x', 64), av.created_by_useraccount_id, av.manifest_blob_digest,
+			av.manifest_blob_size, av.manifest_content_type, av.artifact_id
+		FROM ArtifactVersion av, generate_series(0, $2 - 1) i
+		WHERE av.id = $1`,
+		versions[0].ID, tagCount)
+	g.Expect(err).NotTo(HaveOccurred())
+	tag := func(i int) string { return fmt.Sprintf("%05d-%v", i, strings.Repeat("x", 64)) }
+
+	tags, more, err := h.ListTags(ctx, repo, 10, tag(100))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tags).To(HaveLen(10))
+	g.Expect(tags[0]).To(Equal(tag(101)))
+	g.Expect(tags[9]).To(Equal(tag(110)))
+	g.Expect(more).To(BeTrue())
+	tags, more, err = h.ListTags(ctx, repo, 10, tag(tagCount-5))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tags).To(HaveLen(4))
+	g.Expect(more).To(BeFalse())
+
+	// all tags together are about 3.5 MB, a page of 10 tags must only load a few rows
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	const runs = 5
+	for range runs {
+		_, _, err := h.ListTags(ctx, repo, 10, "")
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	runtime.ReadMemStats(&after)
+	g.Expect((after.TotalAlloc - before.TotalAlloc) / runs).To(BeNumerically("<", 256<<10))
+}
+
+func TestList(t *testing.T) {
+	g := NewWithT(t)
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	ctx = authenticated(ctx, t, org.Vendors[0].ID, org.ID)
+	h := manifestdb.NewManifestHandler()
+	for _, name := range []string{"b", "C", "a/app", "a"} {
+		g.Expect(db.CreateArtifact(ctx, &types.Artifact{Name: name, OrganizationID: org.ID})).To(Succeed())
+	}
+	prefix := *org.Slug + "/"
+
+	repos, more, err := h.List(ctx, 3, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(repos).To(Equal([]string{prefix + "C", prefix + "a", prefix + "a/app"}))
+	g.Expect(more).To(BeTrue())
+	repos, more, err = h.List(ctx, 3, repos[2])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(repos).To(Equal([]string{prefix + "b"}))
+	g.Expect(more).To(BeFalse())
+}
@@ -5,4 +5,10 @@ import "errors"
 var (
 	ErrNameUnknown     = errors.New("unknown name")
 	ErrManifestUnknown = errors.New("unknown manifest")
+	// ErrPendingDeletion is returned when a manifest is pushed to an artifact that is pending deletion.
+	ErrPendingDeletion = errors.New("artifact is pending deletion")
+	// ErrReservedTag is returned when a manifest is pushed with a tag that is resolved by the registry itself.
+	ErrReservedTag = errors.New("tag is reserved")
+	// ErrImmutableTag is returned when a manifest is pushed with an immutable tag that points at a different manifest.
+	ErrImmutableTag = errors.New("tag is immutable")
 )
@@ -4,7 +4,6 @@ import (
 	"context"
 	"maps"
 	"slices"
-	"sort"
 
 	"github.com/glasskube/distr/internal/registry/manifest"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -40,20 +39,28 @@ func (h *handler) Get(ctx context.Context, name string, reference string) (*mani
 	}
 }
 
-// List implements manifest.ManifestHandler.
-func (h *handler) List(ctx context.Context, n int) ([]string, error) {
-	names := slices.Collect(maps.Keys(h.manifests))
-	if 0 < n && n < len(names) {
-		names = names[:n]
+// GetAll implements manifest.ManifestBatchHandler.
+func (h *handler) GetAll(ctx context.Context, name string, references []string) (map[string]manifest.Manifest, error) {
+	result := make(map[string]manifest.Manifest, len(references))
+	for _, reference := range references {
+		if m, ok := h.manifests[name][reference]; ok {
+			result[reference] = m
+		}
 	}
-	return names, nil
+	return result, nil
+}
+
+// List implements manifest.ManifestHandler.
+func (h *handler) List(ctx context.Context, n int, last string) ([]string, bool, error) {
+	names, more := manifest.Paginate(slices.Collect(maps.Keys(h.manifests)), n, last)
+	return names, more, nil
 }
 
 // ListTags implements manifest.ManifestHandler.
-func (h *handler) ListTags(ctx context.Context, name string, n int, last string) ([]string, error) {
+func (h *handler) ListTags(ctx context.Context, name string, n int, last string) ([]string, bool, error) {
 	referencesMap, ok := h.manifests[name]
 	if !ok {
-		return nil, manifest.ErrNameUnknown
+		return nil, false, manifest.ErrNameUnknown
 	}
 	var references []string
 	for reference := range referencesMap {
@@ -64,22 +71,8 @@ func (h *handler) ListTags(ctx context.Context, name string, n int, last string)
 		}
 	}
 
-	sort.Strings(references)
-
-	if last != "" {
-		for i, reference := range references {
-			if reference > last {
-				references = references[i:]
-				break
-			}
-		}
-	}
-
-	if 0 < n && n < len(references) {
-		references = references[:n]
-	}
-
-	return references, nil
+	references, more := manifest.Paginate(references, n, last)
+	return references, more, nil
 }
 
 // ListDigests implements manifest.ManifestHandler.
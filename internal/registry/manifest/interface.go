@@ -7,7 +7,16 @@ import (
 )
 
 type ManifestHandler interface {
-	List(ctx context.Context, n int) ([]string, error)
+	// List
+	//
+	// Spec for implementation:
+	//
+	// n: Limit the number of entries in each response. If not present, all entries will be returned.
+	//
+	// last: Result set will include values lexically after last.
+	//
+	// more must be true if the result set has been truncated to n entries.
+	List(ctx context.Context, n int, last string) (repos []string, more bool, err error)
 	// ListTags
 	//
 	// Spec for implementation:
@@ -17,9 +26,30 @@ type ManifestHandler interface {
 	// n: Limit the number of entries in each response. If not present, all entries will be returned.
 	//
 	// last: Result set will include values lexically after last.
-	ListTags(ctx context.Context, name string, n int, last string) ([]string, error)
+	//
+	// more must be true if the result set has been truncated to n entries.
+	ListTags(ctx context.Context, name string, n int, last string) (tags []string, more bool, err error)
 	ListDigests(ctx context.Context, name string) ([]v1.Hash, error)
 	Get(ctx context.Context, name string, reference string) (*Manifest, error)
 	Put(ctx context.Context, name string, reference string, manifest Manifest, blobs []Blob) error
 	Delete(ctx context.Context, name string, reference string) error
 }
+
+// ManifestBatchHandler is an extension interface representing a manifest
+// backend that can look up many manifests of a repository at once.
+type ManifestBatchHandler interface {
+	// GetAll returns the manifests of the references that exist in the
+	// repository name, keyed by reference. References that do not exist are
+	// omitted. Implementations must not issue a request per reference.
+	GetAll(ctx context.Context, name string, references []string) (map[string]Manifest, error)
+}
+
+// MissingIndexChildrenHandler is an extension interface representing a
+// manifest backend that decides whether an image index may be pushed
+// although some manifests that it references do not exist yet.
+type MissingIndexChildrenHandler interface {
+	// AllowMissingIndexChildren reports whether an image index that
+	// references manifests which do not exist can be pushed to the
+	// repository name.
+	AllowMissingIndexChildren(ctx context.Context, name string) (bool, error)
+}
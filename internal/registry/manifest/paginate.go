@@ -0,0 +1,23 @@
+package manifest
+
+import (
+	"slices"
+)
+
+// Paginate sorts names lexically, removes duplicates and returns at most n of those that are lexically after last.
+// more is true if names after the returned ones exist. If n is not positive, all names after last are returned.
+// Tags and repositories are both paginated this way.
+func Paginate(names []string, n int, last string) (page []string, more bool) {
+	names = slices.Compact(slices.Sorted(slices.Values(names)))
+	if last != "" {
+		start, found := slices.BinarySearch(names, last)
+		if found {
+			start++
+		}
+		names = names[start:]
+	}
+	if 0 < n && n < len(names) {
+		return names[:n], true
+	}
+	return names, false
+}
@@ -0,0 +1,218 @@
+package registry
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/registry/manifest"
+	"github.com/glasskube/distr/internal/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/singleflight"
+)
+
+// manifestContent is the result of reading a manifest. If the blob handler redirects clients to another location,
+// redirect is set and data is empty. Manifests that are larger than maxBufferedManifestSize are not read into memory,
+// unbuffered is set and data is empty.
+type manifestContent struct {
+	manifest   manifest.Manifest
+	data       []byte
+	redirect   *blob.RedirectError
+	unbuffered bool
+}
+
+type manifestCacheEntry struct {
+	key       string
+	content   *manifestContent
+	expiresAt time.Time
+}
+
+// ManifestCacheNotifier distributes the invalidation of cached manifests to the manifest caches of all instances of
+// the registry.
+type ManifestCacheNotifier interface {
+	// Notify announces invalidation to all instances. It is called in the transaction of the push, so implementations
+	// should only deliver it once the transaction is committed.
+	Notify(ctx context.Context, invalidation types.ManifestInvalidation) error
+	// Subscribe registers f to be called with every announced invalidation. f is called with nil if invalidations may
+	// have been missed, in which case every cached manifest must be invalidated.
+	Subscribe(f func(invalidation *types.ManifestInvalidation))
+}
+
+// manifestCache coalesces concurrent reads of the same manifest, so that only one of them looks up the manifest and
+// fetches its blob. Manifests that are not served by redirect are also kept in memory for ttl, but at most size of
+// them.
+//
+// Pushes on this instance invalidate the affected entries right away. Pushes on other instances are only visible once
+// the invalidation has been received from the ManifestCacheNotifier or, without a notifier, after the entries expired.
+// A ttl of zero disables the cache but keeps coalescing.
+type manifestCache struct {
+	ttl   time.Duration
+	size  int
+	group singleflight.Group
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	// generation is incremented by every invalidation. Reads that were started before an invalidation are not stored.
+	generation uint64
+
+	requests metric.Int64Counter
+}
+
+func newManifestCache(ttl time.Duration, size int) *manifestCache {
+	requests, _ := otel.Meter("github.com/glasskube/distr/internal/registry").Int64Counter(
+		"registry.manifest.cache.requests",
+		metric.WithDescription("Manifest reads by result: hit (served from the cache), "+
+			"coalesced (served by a concurrent read) or miss"),
+	)
+	return &manifestCache{
+		ttl:      ttl,
+		size:     size,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+		requests: requests,
+	}
+}
+
+// get returns the content of the manifest of repo with reference. If it is neither cached nor being read by another
+// request, read is called. The context passed to read is not canceled when ctx is, because other requests may be
+// waiting for the result.
+func (c *manifestCache) get(
+	ctx context.Context,
+	repo, reference string,
+	read func(ctx context.Context) (*manifestContent, error),
+) (*manifestContent, error) {
+	if c == nil {
+		return read(ctx)
+	}
+
+	key := manifestCacheKey(repo, reference)
+	if content := c.lookup(key); content != nil {
+		c.record(ctx, "hit")
+		return content, nil
+	}
+
+	var executed bool
+	result, err, _ := c.group.Do(key, func() (any, error) {
+		executed = true
+		generation := c.currentGeneration()
+		started := time.Now()
+		content, err := read(context.WithoutCancel(ctx))
+		if err == nil {
+			c.store(key, content, generation, started)
+		}
+		return content, err
+	})
+	if executed {
+		c.record(ctx, "miss")
+	} else {
+		c.record(ctx, "coalesced")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.(*manifestContent), nil
+}
+
+// invalidate removes the cached manifests of repo with the given references and makes sure that reads which are
+// currently in progress are neither stored nor joined by later requests.
+func (c *manifestCache) invalidate(repo string, references ...string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	for _, reference := range references {
+		key := manifestCacheKey(repo, reference)
+		c.group.Forget(key)
+		if element, ok := c.entries[key]; ok {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateAll removes all cached manifests and makes sure that reads which are currently in progress are not stored.
+func (c *manifestCache) invalidateAll() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	for key := range c.entries {
+		c.group.Forget(key)
+	}
+	clear(c.entries)
+	c.order.Init()
+}
+
+// subscribe invalidates the cache with every invalidation announced by notifier.
+func (c *manifestCache) subscribe(notifier ManifestCacheNotifier) {
+	if c == nil {
+		return
+	}
+	notifier.Subscribe(func(invalidation *types.ManifestInvalidation) {
+		if invalidation == nil {
+			c.invalidateAll()
+		} else {
+			c.invalidate(invalidation.Repo, invalidation.References...)
+		}
+	})
+}
+
+func (c *manifestCache) lookup(key string) *manifestContent {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; !ok {
+		return nil
+	} else if entry := element.Value.(*manifestCacheEntry); time.Now().Before(entry.expiresAt) {
+		return entry.content
+	} else {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+}
+
+// store adds content to the cache. The entry expires ttl after the read was started, so that the time spent reading
+// does not extend how long a moved tag can be served.
+func (c *manifestCache) store(key string, content *manifestContent, generation uint64, started time.Time) {
+	if c.ttl <= 0 || c.size <= 0 || content.redirect != nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	for c.order.Len() >= c.size {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*manifestCacheEntry).key)
+	}
+	c.entries[key] = c.order.PushBack(&manifestCacheEntry{key: key, content: content, expiresAt: started.Add(c.ttl)})
+}
+
+func (c *manifestCache) currentGeneration() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+func (c *manifestCache) record(ctx context.Context, result string) {
+	c.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+func manifestCacheKey(repo, reference string) string {
+	return repo + "@" + reference
+}
@@ -0,0 +1,218 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/glasskube/distr/internal/registry/manifest"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// minIndexChildRetryDelay is the delay before manifests that are referenced by a pushed image index are looked up
+	// again for the first time. It is doubled for every further lookup, up to maxIndexChildRetryDelay.
+	minIndexChildRetryDelay = 50 * time.Millisecond
+	maxIndexChildRetryDelay = 500 * time.Millisecond
+)
+
+// IndexLimits restrict the image indexes that can be pushed, so that a single push can not make the registry look up
+// an unbounded number of manifests. A value of zero means no limit.
+type IndexLimits struct {
+	// MaxChildren is the maximum number of manifests in a single image index.
+	MaxChildren int
+	// MaxDepth is the maximum nesting depth of image indexes. An index that only references images has a depth of one.
+	MaxDepth int
+	// MaxDescriptors is the maximum number of descriptors in a pushed index and all indexes it references.
+	MaxDescriptors int
+}
+
+// checkIndex verifies that all manifests referenced by the pushed image index im exist in repo and that im, together
+// with the indexes it references, is within the index limits. It returns the blobs of the referenced manifests and the
+// digests of the other blobs that im references directly, whose existence is checked by the caller.
+//
+// The manifests of each nesting level are looked up in a single batch. Nested indexes are only read if a depth or
+// descriptor limit is set, and the walk stops as soon as one of them is exceeded.
+func (handler *manifests) checkIndex(ctx context.Context, repo string, im *v1.IndexManifest) (
+	blobs []manifest.Blob, required []v1.Hash, regErr *regError,
+) {
+	limits := handler.indexLimits
+	if limits.MaxChildren > 0 && len(im.Manifests) > limits.MaxChildren {
+		return nil, nil, regErrManifestInvalid(fmt.Errorf(
+			"image index has %v manifests, but at most %v are allowed", len(im.Manifests), limits.MaxChildren))
+	}
+	descriptors := len(im.Manifests)
+	if limits.MaxDescriptors > 0 && descriptors > limits.MaxDescriptors {
+		return nil, nil, regErrManifestInvalid(fmt.Errorf(
+			"image index has more than %v descriptors in total", limits.MaxDescriptors))
+	}
+
+	level := im.Manifests
+	visited := make(map[v1.Hash]struct{})
+	for depth := 1; len(level) > 0; depth++ {
+		found, err := handler.getManifests(ctx, repo, manifestReferences(level))
+		if err != nil {
+			return nil, nil, regErrInternal(err)
+		}
+		allowMissing := false
+		if depth == 1 {
+			if found, allowMissing, err = handler.awaitIndexChildren(ctx, repo, level, found); err != nil {
+				return nil, nil, regErrInternal(err)
+			}
+		}
+		var next []v1.Descriptor
+		for _, desc := range level {
+			if !desc.MediaType.IsDistributable() {
+				continue
+			}
+			if !desc.MediaType.IsIndex() && !desc.MediaType.IsImage() {
+				if depth == 1 {
+					required = append(required, desc.Digest)
+				}
+				continue
+			}
+			m, ok := found[desc.Digest.String()]
+			if depth == 1 {
+				// the manifests referenced by nested indexes have been checked when those were pushed
+				if !ok && !allowMissing {
+					return nil, nil, regErrManifestBlobUnknown(desc.Digest)
+				}
+				blobs = append(blobs, manifest.Blob{Digest: desc.Digest, Size: desc.Size})
+			}
+			if !ok || !types.MediaType(m.ContentType).IsIndex() || (limits.MaxDepth == 0 && limits.MaxDescriptors == 0) {
+				continue
+			} else if _, ok := visited[desc.Digest]; ok {
+				continue
+			}
+			visited[desc.Digest] = struct{}{}
+			if limits.MaxDepth > 0 && depth+1 > limits.MaxDepth {
+				return nil, nil, regErrManifestInvalid(fmt.Errorf(
+					"image indexes are nested more than %v levels deep", limits.MaxDepth))
+			}
+			child, err := handler.readIndex(ctx, repo, m)
+			if err != nil {
+				return nil, nil, regErrInternal(err)
+			}
+			descriptors += len(child.Manifests)
+			if limits.MaxDescriptors > 0 && descriptors > limits.MaxDescriptors {
+				return nil, nil, regErrManifestInvalid(fmt.Errorf(
+					"image index has more than %v descriptors in total", limits.MaxDescriptors))
+			}
+			next = append(next, child.Manifests...)
+		}
+		level = next
+	}
+	return blobs, required, nil
+}
+
+// awaitIndexChildren looks up the manifests of descriptors that are missing in found again until all of them exist or
+// the grace period has passed, because clients like buildx push attestation manifests concurrently with the index that
+// references them. It returns found with the manifests that have been pushed in the meantime. If some manifests are
+// still missing, it also reports whether the manifest handler allows pushing the index anyway.
+func (handler *manifests) awaitIndexChildren(
+	ctx context.Context,
+	repo string,
+	descriptors []v1.Descriptor,
+	found map[string]manifest.Manifest,
+) (map[string]manifest.Manifest, bool, error) {
+	if found == nil {
+		found = make(map[string]manifest.Manifest)
+	}
+	missing := missingReferences(descriptors, found)
+	if len(missing) > 0 && handler.indexChildGracePeriod > 0 {
+		deadline := time.Now().Add(handler.indexChildGracePeriod)
+		for delay := minIndexChildRetryDelay; len(missing) > 0; delay = min(2*delay, maxIndexChildRetryDelay) {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			case <-time.After(min(delay, remaining)):
+			}
+			pushed, err := handler.getManifests(ctx, repo, missing)
+			if err != nil {
+				return nil, false, err
+			}
+			maps.Copy(found, pushed)
+			missing = missingReferences(descriptors, found)
+		}
+	}
+	if len(missing) == 0 {
+		return found, false, nil
+	} else if mh, ok := handler.manifestHandler.(manifest.MissingIndexChildrenHandler); !ok {
+		return found, false, nil
+	} else if allow, err := mh.AllowMissingIndexChildren(ctx, repo); err != nil {
+		return nil, false, err
+	} else {
+		if allow {
+			handler.log.Infow("accepting image index that references missing manifests", "repo", repo, "missing", missing)
+		}
+		return found, allow, nil
+	}
+}
+
+// missingReferences returns the references of the manifests in descriptors that are not contained in found.
+func missingReferences(descriptors []v1.Descriptor, found map[string]manifest.Manifest) []string {
+	var result []string
+	for _, reference := range manifestReferences(descriptors) {
+		if _, ok := found[reference]; !ok {
+			result = append(result, reference)
+		}
+	}
+	return result
+}
+
+// getManifests looks up the manifests of references in repo, in a single batch if the manifest handler supports it.
+// References that do not exist are omitted.
+func (handler *manifests) getManifests(ctx context.Context, repo string, references []string) (
+	map[string]manifest.Manifest, error,
+) {
+	if len(references) == 0 {
+		return nil, nil
+	} else if mbh, ok := handler.manifestHandler.(manifest.ManifestBatchHandler); ok {
+		if result, err := mbh.GetAll(ctx, repo, references); errors.Is(err, manifest.ErrNameUnknown) {
+			return nil, nil
+		} else {
+			return result, err
+		}
+	}
+	result := make(map[string]manifest.Manifest, len(references))
+	for _, reference := range references {
+		if m, err := handler.manifestHandler.Get(ctx, repo, reference); err == nil {
+			result[reference] = *m
+		} else if !errors.Is(err, manifest.ErrNameUnknown) && !errors.Is(err, manifest.ErrManifestUnknown) {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// readIndex reads and parses the stored image index m.
+func (handler *manifests) readIndex(ctx context.Context, repo string, m manifest.Manifest) (*v1.IndexManifest, error) {
+	content, err := handler.readManifestBlob(ctx, repo, m, false)
+	if err != nil {
+		return nil, err
+	}
+	return v1.ParseIndexManifest(bytes.NewReader(content.data))
+}
+
+// manifestReferences returns the distinct digests of the manifests in descriptors.
+func manifestReferences(descriptors []v1.Descriptor) []string {
+	seen := make(map[v1.Hash]struct{}, len(descriptors))
+	var result []string
+	for _, desc := range descriptors {
+		if _, ok := seen[desc.Digest]; ok {
+			continue
+		} else if desc.MediaType.IsDistributable() && (desc.MediaType.IsIndex() || desc.MediaType.IsImage()) {
+			seen[desc.Digest] = struct{}{}
+			result = append(result, desc.Digest.String())
+		}
+	}
+	return result
+}
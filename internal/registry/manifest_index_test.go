@@ -0,0 +1,238 @@
+package registry_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/manifest"
+	manifestinmemory "github.com/glasskube/distr/internal/registry/manifest/inmemory"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+const (
+	ociIndex         = "application/vnd.oci.image.index.v1+json"
+	ociImageManifest = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// countingManifestHandler counts the single and batched manifest lookups.
+type countingManifestHandler struct {
+	manifest.ManifestHandler
+	gets    atomic.Int32
+	getAlls atomic.Int32
+}
+
+func (h *countingManifestHandler) Get(ctx context.Context, name, reference string) (*manifest.Manifest, error) {
+	h.gets.Add(1)
+	return h.ManifestHandler.Get(ctx, name, reference)
+}
+
+func (h *countingManifestHandler) GetAll(
+	ctx context.Context,
+	name string,
+	references []string,
+) (map[string]manifest.Manifest, error) {
+	h.getAlls.Add(1)
+	return h.ManifestHandler.(manifest.ManifestBatchHandler).GetAll(ctx, name, references)
+}
+
+func newIndexTestRegistry(
+	manifests manifest.ManifestHandler,
+	limits registry.IndexLimits,
+	opts ...registry.Option,
+) http.Handler {
+	return registry.New(append([]registry.Option{
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(noAudit{}),
+		registry.WithBlobHandler(newFakeBlobsExist()),
+		registry.WithManifestHandler(manifests),
+		registry.WithIndexLimits(limits),
+		registry.WithMiddlewares(txContext),
+	}, opts...)...)
+}
+
+// lateManifestHandler hides the manifest with the reference hidden from the first batch lookup, like a manifest that
+// is pushed concurrently with the index that references it.
+type lateManifestHandler struct {
+	countingManifestHandler
+	hidden       string
+	allowMissing bool
+}
+
+func (h *lateManifestHandler) GetAll(
+	ctx context.Context,
+	name string,
+	references []string,
+) (map[string]manifest.Manifest, error) {
+	result, err := h.countingManifestHandler.GetAll(ctx, name, references)
+	if h.getAlls.Load() == 1 {
+		delete(result, h.hidden)
+	}
+	return result, err
+}
+
+func (h *lateManifestHandler) AllowMissingIndexChildren(ctx context.Context, name string) (bool, error) {
+	return h.allowMissing, nil
+}
+
+type child struct {
+	mediaType string
+	digest    string
+}
+
+// putIndex pushes an image index with the given children and returns the response.
+func putIndex(h http.Handler, target string, children ...child) *httptest.ResponseRecorder {
+	descriptors := make([]string, len(children))
+	for i, c := range children {
+		descriptors[i] = fmt.Sprintf(`{"mediaType":%q,"digest":%q,"size":2}`, c.mediaType, c.digest)
+	}
+	data := `{"schemaVersion":2,"mediaType":"` + ociIndex + `","manifests":[` + strings.Join(descriptors, ",") + `]}`
+	r := httptest.NewRequest(http.MethodPut, target, strings.NewReader(data))
+	r.Header.Set("Content-Type", ociIndex)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func pushIndex(g Gomega, h http.Handler, target string, children ...child) child {
+	w := putIndex(h, target, children...)
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+	return child{ociIndex, w.Header().Get("Docker-Content-Digest")}
+}
+
+func pushImages(g Gomega, h http.Handler, n int) []child {
+	images := make([]child, n)
+	for i := range images {
+		pushManifest(g, h, fmt.Sprintf("/v2/org/app/manifests/image-%v", i),
+			fmt.Sprintf("sha256:%064x", i))
+		w := serve(h, http.MethodHead, fmt.Sprintf("/v2/org/app/manifests/image-%v", i), nil)
+		images[i] = child{ociImageManifest, w.Header().Get("Docker-Content-Digest")}
+	}
+	return images
+}
+
+func TestIndexMaxChildren(t *testing.T) {
+	g := NewWithT(t)
+	manifests := &countingManifestHandler{ManifestHandler: manifestinmemory.NewManifestHandler()}
+	h := newIndexTestRegistry(manifests, registry.IndexLimits{MaxChildren: 3})
+	images := pushImages(g, h, 4)
+	manifests.gets.Store(0)
+
+	pushIndex(g, h, "/v2/org/app/manifests/index", images[:3]...)
+	g.Expect(manifests.getAlls.Load()).To(BeEquivalentTo(1))
+	g.Expect(manifests.gets.Load()).To(BeZero())
+
+	w := putIndex(h, "/v2/org/app/manifests/index", images...)
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_INVALID"))
+}
+
+func TestIndexMaxDepth(t *testing.T) {
+	g := NewWithT(t)
+	h := newIndexTestRegistry(manifestinmemory.NewManifestHandler(), registry.IndexLimits{MaxDepth: 2})
+	images := pushImages(g, h, 1)
+
+	index := pushIndex(g, h, "/v2/org/app/manifests/depth-1", images...)
+	indexOfIndexes := pushIndex(g, h, "/v2/org/app/manifests/depth-2", index)
+	w := putIndex(h, "/v2/org/app/manifests/depth-3", indexOfIndexes)
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_INVALID"))
+}
+
+func TestIndexMaxDescriptors(t *testing.T) {
+	g := NewWithT(t)
+	h := newIndexTestRegistry(manifestinmemory.NewManifestHandler(), registry.IndexLimits{MaxDescriptors: 6})
+	images := pushImages(g, h, 3)
+
+	first := pushIndex(g, h, "/v2/org/app/manifests/first", images...)
+	second := pushIndex(g, h, "/v2/org/app/manifests/second", images[2], images[1], images[0])
+	// 2 descriptors in the pushed index, 3 in each referenced index
+	w := putIndex(h, "/v2/org/app/manifests/both", first, second)
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_INVALID"))
+
+	// an index that is referenced twice is only counted once
+	pushIndex(g, h, "/v2/org/app/manifests/twice", first, first)
+}
+
+func TestIndexChildrenAreLookedUpInOneBatch(t *testing.T) {
+	g := NewWithT(t)
+	manifests := &countingManifestHandler{ManifestHandler: manifestinmemory.NewManifestHandler()}
+	h := newIndexTestRegistry(manifests, registry.IndexLimits{})
+	images := pushImages(g, h, 1)
+	manifests.gets.Store(0)
+
+	children := make([]child, 20000)
+	for i := range children {
+		children[i] = child{ociImageManifest, fmt.Sprintf("sha256:%064x", i+1)}
+	}
+	children[0] = images[0]
+	w := putIndex(h, "/v2/org/app/manifests/huge", children...)
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_BLOB_UNKNOWN"))
+	g.Expect(manifests.getAlls.Load()).To(BeEquivalentTo(1))
+	g.Expect(manifests.gets.Load()).To(BeZero())
+}
+
+func TestIndexChildGracePeriod(t *testing.T) {
+	g := NewWithT(t)
+	manifests := &lateManifestHandler{
+		countingManifestHandler: countingManifestHandler{ManifestHandler: manifestinmemory.NewManifestHandler()},
+	}
+	h := newIndexTestRegistry(manifests, registry.IndexLimits{}, registry.WithIndexChildGracePeriod(2*time.Second))
+	images := pushImages(g, h, 2)
+	manifests.hidden = images[1].digest
+	manifests.getAlls.Store(0)
+
+	pushIndex(g, h, "/v2/org/app/manifests/index", images...)
+	g.Expect(manifests.getAlls.Load()).To(BeEquivalentTo(2))
+
+	// manifests that do not appear within the grace period are still rejected
+	manifests.getAlls.Store(0)
+	h = newIndexTestRegistry(manifests, registry.IndexLimits{},
+		registry.WithIndexChildGracePeriod(100*time.Millisecond))
+	w := putIndex(h, "/v2/org/app/manifests/index", images[0], child{ociImageManifest, fmt.Sprintf("sha256:%064x", 1)})
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_BLOB_UNKNOWN"))
+	g.Expect(manifests.getAlls.Load()).To(BeNumerically(">", 2))
+}
+
+func TestIndexChildGracePeriodDisabled(t *testing.T) {
+	g := NewWithT(t)
+	manifests := &lateManifestHandler{
+		countingManifestHandler: countingManifestHandler{ManifestHandler: manifestinmemory.NewManifestHandler()},
+	}
+	h := newIndexTestRegistry(manifests, registry.IndexLimits{})
+	images := pushImages(g, h, 2)
+	manifests.hidden = images[1].digest
+	manifests.getAlls.Store(0)
+
+	w := putIndex(h, "/v2/org/app/manifests/index", images...)
+	g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_BLOB_UNKNOWN"))
+	g.Expect(manifests.getAlls.Load()).To(BeEquivalentTo(1))
+}
+
+func TestIndexMissingChildrenAllowed(t *testing.T) {
+	g := NewWithT(t)
+	manifests := &lateManifestHandler{
+		countingManifestHandler: countingManifestHandler{ManifestHandler: manifestinmemory.NewManifestHandler()},
+		allowMissing:            true,
+	}
+	h := newIndexTestRegistry(manifests, registry.IndexLimits{})
+	images := pushImages(g, h, 1)
+
+	missing := child{ociImageManifest, fmt.Sprintf("sha256:%064x", 1)}
+	index := pushIndex(g, h, "/v2/org/app/manifests/index", images[0], missing)
+	w := serve(h, http.MethodHead, "/v2/org/app/manifests/index", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Header().Get("Docker-Content-Digest")).To(Equal(index.digest))
+}
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/glasskube/distr/internal/registry/manifest"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// indexMediaTypes are the media types of manifests that reference the manifests of several platforms.
+var indexMediaTypes = []types.MediaType{types.OCIImageIndex, types.DockerManifestList}
+
+// acceptedMediaTypes returns the media types of all Accept headers of req without their parameters.
+func acceptedMediaTypes(req *http.Request) []string {
+	var result []string
+	for _, header := range req.Header.Values("Accept") {
+		for value := range strings.SplitSeq(header, ",") {
+			if mediaType, _, err := mime.ParseMediaType(value); err == nil {
+				result = append(result, mediaType)
+			}
+		}
+	}
+	return result
+}
+
+func acceptsMediaType(accepted []string, mediaType types.MediaType) bool {
+	return slices.Contains(accepted, string(mediaType)) || slices.Contains(accepted, "*/*")
+}
+
+// needsPlatformManifest reports whether the client must get the manifest of the default platform instead of m,
+// because m is an image index and the client does not accept any image index media type. Clients that do not send an
+// Accept header get the image index, like before content negotiation was supported.
+func (handler *manifests) needsPlatformManifest(req *http.Request, m manifest.Manifest) bool {
+	if handler.defaultPlatform == nil || !types.MediaType(m.ContentType).IsIndex() {
+		return false
+	}
+	accepted := acceptedMediaTypes(req)
+	return len(accepted) > 0 && !slices.ContainsFunc(indexMediaTypes, func(mediaType types.MediaType) bool {
+		return acceptsMediaType(accepted, mediaType)
+	})
+}
+
+// platformManifest returns the descriptor of the manifest for the default platform in the image index content, like
+// Docker Hub does for clients that do not accept image indexes. Only manifests with a media type that the client
+// accepts are considered.
+func (handler *manifests) platformManifest(
+	ctx context.Context,
+	req *http.Request,
+	repo string,
+	content *manifestContent,
+) (*v1.Descriptor, *regError) {
+	data := content.data
+	if content.redirect != nil || content.unbuffered {
+		// the index must be parsed here, so it can not be served by redirect or streamed
+		if direct, err := handler.readManifestBlob(ctx, repo, content.manifest, false); err != nil {
+			return nil, regErrInternal(err)
+		} else {
+			data = direct.data
+		}
+	}
+	index, err := v1.ParseIndexManifest(bytes.NewReader(data))
+	if err != nil {
+		return nil, regErrInternal(err)
+	}
+	accepted := acceptedMediaTypes(req)
+	for _, desc := range index.Manifests {
+		if desc.Platform != nil && desc.Platform.Satisfies(*handler.defaultPlatform) &&
+			acceptsMediaType(accepted, desc.MediaType) {
+			return &desc, nil
+		}
+	}
+	return nil, regErrPlatformManifestUnknown(handler.defaultPlatform)
+}
@@ -0,0 +1,756 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/authz"
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/registry/blob/inmemory"
+	"github.com/glasskube/distr/internal/registry/manifest"
+	manifestinmemory "github.com/glasskube/distr/internal/registry/manifest/inmemory"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/jackc/pgx/v5"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+type allowAll struct{}
+
+func (allowAll) Authorize(context.Context, string, authz.Action) error                  { return nil }
+func (allowAll) AuthorizeReference(context.Context, string, string, authz.Action) error { return nil }
+func (allowAll) AuthorizeBlob(context.Context, string, v1.Hash, authz.Action) error     { return nil }
+func (allowAll) AuthorizeCatalog(context.Context) error                                 { return nil }
+
+func TestManifestMaxSize(t *testing.T) {
+	g := NewWithT(t)
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithManifestMaxSize(16),
+	)
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`
+
+	for _, contentLength := range []int64{int64(len(manifest)), -1} {
+		r := httptest.NewRequest(http.MethodPut, "/v2/org/app/manifests/latest", strings.NewReader(manifest))
+		r.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		r.ContentLength = contentLength
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		g.Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge))
+
+		var body struct {
+			Errors []struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+		g.Expect(body.Errors).To(HaveLen(1))
+		g.Expect(body.Errors[0].Code).To(Equal("MANIFEST_INVALID"))
+		g.Expect(body.Errors[0].Message).To(ContainSubstring("16 bytes"))
+	}
+}
+
+// inMemoryBlobHandler is the set of interfaces implemented by the in-memory blob handler.
+type inMemoryBlobHandler interface {
+	blob.BlobHandler
+	blob.BlobStatHandler
+	blob.BlobPutHandler
+	blob.BlobMountHandler
+	blob.BlobDeleteHandler
+}
+
+// fakeBlobsExist reports blobs that have never been uploaded as existing, so that tests can push manifests that
+// reference made-up config digests.
+type fakeBlobsExist struct{ inMemoryBlobHandler }
+
+func newFakeBlobsExist() *fakeBlobsExist {
+	return &fakeBlobsExist{inmemory.NewBlobHandler().(inMemoryBlobHandler)}
+}
+
+func (h *fakeBlobsExist) Stat(ctx context.Context, repo string, digest v1.Hash) (int64, error) {
+	if size, err := h.inMemoryBlobHandler.Stat(ctx, repo, digest); !errors.Is(err, blob.ErrNotFound) {
+		return size, err
+	}
+	return 2, nil
+}
+
+type noAudit struct{}
+
+func (noAudit) AuditPull(context.Context, string, string, string) error { return nil }
+
+// txContext marks the request context as running in a transaction, so that pushes can be handled without a database.
+func txContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(internalctx.WithDb(r.Context(), fakeTx{})))
+	})
+}
+
+// fakeTx is a transaction without a database. Nested transactions are the same transaction and committing or rolling
+// back has no effect.
+type fakeTx struct{ pgx.Tx }
+
+func (tx fakeTx) Begin(context.Context) (pgx.Tx, error) { return tx, nil }
+
+func (fakeTx) Commit(context.Context) error { return nil }
+
+func (fakeTx) Rollback(context.Context) error { return nil }
+
+// gatedManifestHandler counts the calls to Get and blocks them until gate is closed.
+type gatedManifestHandler struct {
+	manifest.ManifestHandler
+	gate chan struct{}
+	gets atomic.Int32
+}
+
+func (h *gatedManifestHandler) Get(ctx context.Context, name, reference string) (*manifest.Manifest, error) {
+	h.gets.Add(1)
+	<-h.gate
+	return h.ManifestHandler.Get(ctx, name, reference)
+}
+
+func newManifestCacheTestRegistry(manifests manifest.ManifestHandler, ttl time.Duration) http.Handler {
+	return registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(noAudit{}),
+		registry.WithBlobHandler(newFakeBlobsExist()),
+		registry.WithManifestHandler(manifests),
+		registry.WithManifestCache(ttl, 10),
+		registry.WithMiddlewares(txContext),
+	)
+}
+
+func pushManifest(g Gomega, h http.Handler, target, config string) {
+	data := fmt.Sprintf(
+		`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+			`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%v","size":2},"layers":[]}`,
+		config,
+	)
+	r := httptest.NewRequest(http.MethodPut, target, strings.NewReader(data))
+	r.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+}
+
+func TestManifestCacheCoalescesConcurrentReads(t *testing.T) {
+	g := NewWithT(t)
+	manifests := &gatedManifestHandler{ManifestHandler: manifestinmemory.NewManifestHandler(), gate: make(chan struct{})}
+	h := newManifestCacheTestRegistry(manifests, time.Hour)
+	close(manifests.gate)
+	pushManifest(g, h, "/v2/org/app/manifests/latest", "sha256:"+strings.Repeat("a", 64))
+	manifests.gate = make(chan struct{})
+	manifests.gets.Store(0)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = serve(h, http.MethodGet, "/v2/org/app/manifests/latest", nil).Code
+		}()
+	}
+	g.Eventually(manifests.gets.Load).To(BeEquivalentTo(1))
+	time.Sleep(50 * time.Millisecond)
+	close(manifests.gate)
+	wg.Wait()
+
+	g.Expect(codes).To(HaveEach(http.StatusOK))
+	g.Expect(manifests.gets.Load()).To(BeEquivalentTo(1))
+	g.Expect(serve(h, http.MethodGet, "/v2/org/app/manifests/latest", nil).Code).To(Equal(http.StatusOK))
+	g.Expect(manifests.gets.Load()).To(BeEquivalentTo(1))
+}
+
+func TestManifestCacheInvalidatedByPush(t *testing.T) {
+	for _, ttl := range []time.Duration{0, time.Hour} {
+		t.Run(ttl.String(), func(t *testing.T) {
+			g := NewWithT(t)
+			h := newManifestCacheTestRegistry(manifestinmemory.NewManifestHandler(), ttl)
+			first, second := "sha256:"+strings.Repeat("a", 64), "sha256:"+strings.Repeat("b", 64)
+
+			pushManifest(g, h, "/v2/org/app/manifests/latest", first)
+			w := serve(h, http.MethodGet, "/v2/org/app/manifests/latest", nil)
+			g.Expect(w.Code).To(Equal(http.StatusOK))
+			g.Expect(w.Body.String()).To(ContainSubstring(first))
+
+			// moving the tag must be visible right away on the instance that handled the push
+			pushManifest(g, h, "/v2/org/app/manifests/latest", second)
+			w = serve(h, http.MethodGet, "/v2/org/app/manifests/latest", nil)
+			g.Expect(w.Code).To(Equal(http.StatusOK))
+			g.Expect(w.Body.String()).To(ContainSubstring(second))
+			digest, _, err := v1.SHA256(strings.NewReader(w.Body.String()))
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(w.Header().Get("Docker-Content-Digest")).To(Equal(digest.String()))
+		})
+	}
+}
+
+// fakeCacheNotifier delivers every invalidation to all subscribers right away, like the database would after the
+// transaction of the push has been committed.
+type fakeCacheNotifier struct {
+	mutex       sync.Mutex
+	subscribers []func(*types.ManifestInvalidation)
+}
+
+func (n *fakeCacheNotifier) Notify(_ context.Context, invalidation types.ManifestInvalidation) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, f := range n.subscribers {
+		f(&invalidation)
+	}
+	return nil
+}
+
+func (n *fakeCacheNotifier) Subscribe(f func(*types.ManifestInvalidation)) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.subscribers = append(n.subscribers, f)
+}
+
+func TestManifestCacheInvalidatedByPushOnOtherInstance(t *testing.T) {
+	g := NewWithT(t)
+	manifests := &gatedManifestHandler{ManifestHandler: manifestinmemory.NewManifestHandler(), gate: make(chan struct{})}
+	close(manifests.gate)
+	blobs := newFakeBlobsExist()
+	notifier := &fakeCacheNotifier{}
+	newInstance := func() http.Handler {
+		return registry.New(
+			registry.WithLogger(zap.NewNop()),
+			registry.WithAuthorizer(allowAll{}),
+			registry.WithAuditor(noAudit{}),
+			registry.WithBlobHandler(blobs),
+			registry.WithManifestHandler(manifests),
+			registry.WithManifestCache(time.Hour, 10),
+			registry.WithManifestCacheNotifier(notifier),
+			registry.WithMiddlewares(txContext),
+		)
+	}
+	pushing, reading := newInstance(), newInstance()
+	first, second := "sha256:"+strings.Repeat("a", 64), "sha256:"+strings.Repeat("b", 64)
+
+	pushManifest(g, pushing, "/v2/org/app/manifests/latest", first)
+	manifests.gets.Store(0)
+	for range 2 {
+		w := serve(reading, http.MethodGet, "/v2/org/app/manifests/latest", nil)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		g.Expect(w.Body.String()).To(ContainSubstring(first))
+	}
+	g.Expect(manifests.gets.Load()).To(BeEquivalentTo(1), "the second read must be served from the cache")
+
+	// the tag is moved on the other instance before the cached entry expires
+	pushManifest(g, pushing, "/v2/org/app/manifests/latest", second)
+	w := serve(reading, http.MethodGet, "/v2/org/app/manifests/latest", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(ContainSubstring(second))
+
+	// after invalidations may have been missed, the cache is cleared completely
+	g.Expect(serve(reading, http.MethodGet, "/v2/org/app/manifests/latest", nil).Code).To(Equal(http.StatusOK))
+	manifests.gets.Store(0)
+	for _, f := range notifier.subscribers {
+		f(nil)
+	}
+	g.Expect(serve(reading, http.MethodGet, "/v2/org/app/manifests/latest", nil).Code).To(Equal(http.StatusOK))
+	g.Expect(manifests.gets.Load()).To(BeEquivalentTo(1))
+}
+
+// immutableTagsManifestHandler rejects pushes that move the immutable tags of artifact like the database handler.
+type immutableTagsManifestHandler struct {
+	manifest.ManifestHandler
+	artifact types.Artifact
+}
+
+func (h immutableTagsManifestHandler) Put(
+	ctx context.Context,
+	name, reference string,
+	m manifest.Manifest,
+	blobs []manifest.Blob,
+) error {
+	if existing, err := h.Get(ctx, name, reference); err == nil && existing.Blob.Digest != m.Blob.Digest &&
+		h.artifact.IsTagImmutable(reference) {
+		return manifest.ErrImmutableTag
+	}
+	return h.ManifestHandler.Put(ctx, name, reference, m, blobs)
+}
+
+func TestManifestPutMissingBlobs(t *testing.T) {
+	g := NewWithT(t)
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(noAudit{}),
+		registry.WithBlobHandler(inmemory.NewBlobHandler()),
+		registry.WithManifestHandler(manifestinmemory.NewManifestHandler()),
+		registry.WithMiddlewares(txContext),
+	)
+	digest := func(content string) v1.Hash {
+		digest, _, err := v1.SHA256(strings.NewReader(content))
+		g.Expect(err).NotTo(HaveOccurred())
+		return digest
+	}
+	upload := func(content string) {
+		g.Expect(serve(h, http.MethodPost, "/v2/org/app/blobs/uploads/?digest="+digest(content).String(),
+			strings.NewReader(content)).Code).To(Equal(http.StatusCreated))
+	}
+	config, layers := digest("{}"), []v1.Hash{digest("layer 1"), digest("layer 2")}
+	foreign := digest("foreign layer")
+	data := fmt.Sprintf(
+		`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+			`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%v","size":2},"layers":[`+
+			`{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"%v","size":7},`+
+			`{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"%v","size":7},`+
+			`{"mediaType":"application/vnd.docker.image.rootfs.foreign.diff.tar.gzip","digest":"%v","size":13}]}`,
+		config, layers[0], layers[1], foreign,
+	)
+	put := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/v2/org/app/manifests/latest", strings.NewReader(data))
+		r.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	missingDetail := func(w *httptest.ResponseRecorder) string {
+		var body struct {
+			Errors []struct {
+				Code   string `json:"code"`
+				Detail string `json:"detail"`
+			} `json:"errors"`
+		}
+		g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+		g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+		g.Expect(body.Errors).To(HaveLen(1))
+		g.Expect(body.Errors[0].Code).To(Equal("MANIFEST_BLOB_UNKNOWN"))
+		return body.Errors[0].Detail
+	}
+
+	// the smallest missing digest is reported, independent of the order in which the checks finish
+	missing := []v1.Hash{config, layers[0], layers[1]}
+	slices.SortFunc(missing, func(a, b v1.Hash) int { return strings.Compare(a.String(), b.String()) })
+	g.Expect(missingDetail(put())).To(Equal(missing[0].String()))
+
+	upload("{}")
+	upload("layer 1")
+	g.Expect(missingDetail(put())).To(Equal(layers[1].String()))
+	g.Expect(serve(h, http.MethodHead, "/v2/org/app/manifests/latest", nil).Code).To(Equal(http.StatusNotFound))
+
+	// foreign layers are not distributed by the registry and do not have to be uploaded
+	upload("layer 2")
+	g.Expect(put().Code).To(Equal(http.StatusCreated))
+}
+
+func TestManifestPutImmutableTag(t *testing.T) {
+	g := NewWithT(t)
+	h := newManifestCacheTestRegistry(immutableTagsManifestHandler{
+		ManifestHandler: manifestinmemory.NewManifestHandler(),
+		artifact:        types.Artifact{ImmutableTags: true, MutableTagPatterns: []string{"latest", "*-snapshot"}},
+	}, 0)
+	first, second := "sha256:"+strings.Repeat("a", 64), "sha256:"+strings.Repeat("b", 64)
+	for _, tag := range []string{"1.4.2", "latest", "1.5.0-snapshot"} {
+		pushManifest(g, h, "/v2/org/app/manifests/"+tag, first)
+		// pushing the same manifest again is allowed
+		pushManifest(g, h, "/v2/org/app/manifests/"+tag, first)
+	}
+
+	pushManifest(g, h, "/v2/org/app/manifests/latest", second)
+	pushManifest(g, h, "/v2/org/app/manifests/1.5.0-snapshot", second)
+
+	r := httptest.NewRequest(http.MethodPut, "/v2/org/app/manifests/1.4.2", strings.NewReader(fmt.Sprintf(
+		`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+			`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%v","size":2},"layers":[]}`,
+		second,
+	)))
+	r.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusConflict))
+	g.Expect(errorCode(g, w)).To(Equal("TAG_INVALID"))
+
+	w = serve(h, http.MethodGet, "/v2/org/app/manifests/1.4.2", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(ContainSubstring(first))
+}
+
+func TestLargeManifestIsStreamed(t *testing.T) {
+	g := NewWithT(t)
+	h := newManifestCacheTestRegistry(manifestinmemory.NewManifestHandler(), time.Hour)
+	data := fmt.Sprintf(
+		`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+			`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:%v","size":2},`+
+			`"layers":[],"annotations":{"padding":"%v"}}`,
+		strings.Repeat("a", 64), strings.Repeat("x", 512*1024),
+	)
+	r := httptest.NewRequest(http.MethodPut, "/v2/org/app/manifests/latest", strings.NewReader(data))
+	r.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusCreated))
+
+	for range 2 {
+		w = serve(h, http.MethodGet, "/v2/org/app/manifests/latest", nil)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		g.Expect(w.Header().Get("Content-Length")).To(Equal(fmt.Sprint(len(data))))
+		g.Expect(w.Header().Get("Content-Type")).To(Equal("application/vnd.oci.image.manifest.v1+json"))
+		g.Expect(w.Body.String()).To(Equal(data))
+	}
+}
+
+func TestManifestContentNegotiation(t *testing.T) {
+	g := NewWithT(t)
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(noAudit{}),
+		registry.WithBlobHandler(newFakeBlobsExist()),
+		registry.WithManifestHandler(manifestinmemory.NewManifestHandler()),
+		registry.WithDefaultPlatform(&v1.Platform{OS: "linux", Architecture: "amd64"}),
+		registry.WithMiddlewares(txContext),
+	)
+	const (
+		dockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+		dockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+		ociManifest        = "application/vnd.oci.image.manifest.v1+json"
+		ociIndex           = "application/vnd.oci.image.index.v1+json"
+	)
+	push := func(target, contentType, data string) string {
+		r := httptest.NewRequest(http.MethodPut, "/v2/org/app/manifests/"+target, strings.NewReader(data))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		g.Expect(w.Code).To(Equal(http.StatusCreated))
+		return w.Header().Get("Docker-Content-Digest")
+	}
+	image := func(config string) string {
+		return fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":`+
+			`"application/vnd.docker.container.image.v1+json","digest":"sha256:%v","size":2},"layers":[]}`,
+			dockerManifest, strings.Repeat(config, 64))
+	}
+	amd64Data, arm64Data := image("a"), image("b")
+	amd64 := push("amd64", dockerManifest, amd64Data)
+	arm64 := push("arm64", dockerManifest, arm64Data)
+	indexData := fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"manifests":[`+
+		`{"mediaType":%q,"digest":%q,"size":%v,"platform":{"os":"linux","architecture":"arm64"}},`+
+		`{"mediaType":%q,"digest":%q,"size":%v,"platform":{"os":"linux","architecture":"amd64"}}]}`,
+		ociIndex, dockerManifest, arm64, len(arm64Data), dockerManifest, amd64, len(amd64Data))
+	index := push("latest", ociIndex, indexData)
+
+	for _, tc := range []struct {
+		name   string
+		accept []string
+		digest string
+		body   string
+	}{
+		{"no accept header", nil, index, indexData},
+		{"wildcard", []string{"*/*"}, index, indexData},
+		{
+			"docker 1.10",
+			[]string{dockerManifest, "application/vnd.docker.distribution.manifest.v1+prettyjws", "application/json"},
+			amd64, amd64Data,
+		},
+		{
+			"single header with parameters",
+			[]string{dockerManifest + "; q=0.9, application/json; q=0.5"},
+			amd64, amd64Data,
+		},
+		{"docker 17.06", []string{dockerManifest, dockerManifestList, ociManifest}, index, indexData},
+		{"containerd", []string{dockerManifest, dockerManifestList, ociManifest, ociIndex, "*/*"}, index, indexData},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				r := httptest.NewRequest(method, "/v2/org/app/manifests/latest", nil)
+				for _, accept := range tc.accept {
+					r.Header.Add("Accept", accept)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				g.Expect(w.Code).To(Equal(http.StatusOK))
+				g.Expect(w.Header().Get("Docker-Content-Digest")).To(Equal(tc.digest))
+				g.Expect(w.Header().Get("Content-Length")).To(Equal(fmt.Sprint(len(tc.body))))
+				if method == http.MethodGet {
+					g.Expect(w.Body.String()).To(Equal(tc.body))
+				}
+			}
+		})
+	}
+
+	// the index has no OCI manifest for the default platform
+	r := httptest.NewRequest(http.MethodGet, "/v2/org/app/manifests/"+index, nil)
+	r.Header.Set("Accept", ociManifest)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_UNKNOWN"))
+}
+
+func TestTagsPagination(t *testing.T) {
+	g := NewWithT(t)
+	h := newManifestCacheTestRegistry(manifestinmemory.NewManifestHandler(), 0)
+	config := "sha256:" + strings.Repeat("a", 64)
+	expected := []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0", "2.0.0-rc.1", "latest"}
+	for _, tag := range expected {
+		pushManifest(g, h, "/v2/org/team/app/manifests/"+tag, config)
+	}
+
+	var tags []string
+	target := "/v2/org/team/app/tags/list?n=4"
+	for range len(expected) {
+		w := serve(h, http.MethodGet, target, nil)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		var body struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}
+		g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+		g.Expect(body.Name).To(Equal("org/team/app"))
+		g.Expect(len(body.Tags)).To(BeNumerically("<=", 4))
+		tags = append(tags, body.Tags...)
+
+		link := w.Header().Get("Link")
+		if link == "" {
+			break
+		}
+		g.Expect(link).To(HavePrefix("</v2/org/team/app/tags/list?"))
+		g.Expect(link).To(HaveSuffix(`>; rel="next"`))
+		target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		next, err := url.Parse(target)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(next.Query().Get("n")).To(Equal("4"))
+		g.Expect(next.Query().Get("last")).To(Equal(body.Tags[len(body.Tags)-1]))
+	}
+	g.Expect(tags).To(Equal(expected))
+
+	// the last page must not link to an empty page
+	w := serve(h, http.MethodGet, "/v2/org/team/app/tags/list?n=6", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Header().Get("Link")).To(BeEmpty())
+}
+
+// seededTags lists the same sorted tags for every repository. Whether the manifest handler of the database loads only
+// a single page is tested in the manifest/db package.
+type seededTags struct {
+	manifest.ManifestHandler
+	tags []string
+}
+
+func (h seededTags) ListTags(_ context.Context, _ string, n int, last string) ([]string, bool, error) {
+	tags := h.tags
+	if last != "" {
+		start, found := slices.BinarySearch(tags, last)
+		if found {
+			start++
+		}
+		tags = tags[start:]
+	}
+	if 0 < n && n < len(tags) {
+		return tags[:n], true, nil
+	}
+	return tags, false, nil
+}
+
+func TestTagsPaginationIsBounded(t *testing.T) {
+	g := NewWithT(t)
+	const tagCount = 50_000
+	// long tags make the size of the response dominate the allocations of a request
+	tags := make([]string, tagCount)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("%05d-%v", i, strings.Repeat("x", 64))
+	}
+	h := newManifestCacheTestRegistry(seededTags{tags: tags}, 0)
+
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	for _, query := range []string{"", "?n=0", "?n=100000"} {
+		target := "/v2/org/app/tags/list" + query
+		w := serve(h, http.MethodGet, target, nil)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		g.Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+		g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+		g.Expect(body.Tags).To(HaveLen(1000))
+		g.Expect(w.Header().Get("Link")).To(ContainSubstring("n=1000"))
+	}
+
+	var listed []string
+	target := "/v2/org/app/tags/list"
+	for range tagCount/1000 + 1 {
+		w := serve(h, http.MethodGet, target, nil)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+		listed = append(listed, body.Tags...)
+		link := w.Header().Get("Link")
+		if link == "" {
+			break
+		}
+		target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+	}
+	g.Expect(listed).To(Equal(tags))
+}
+
+func TestCatalogPagination(t *testing.T) {
+	g := NewWithT(t)
+	h := newManifestCacheTestRegistry(manifestinmemory.NewManifestHandler(), 0)
+	config := "sha256:" + strings.Repeat("a", 64)
+	expected := []string{"org/a", "org/b", "org/b-c", "org/c", "org/team/app"}
+	for _, repo := range slices.Backward(expected) {
+		pushManifest(g, h, "/v2/"+repo+"/manifests/latest", config)
+	}
+
+	var repos []string
+	target := "/v2/_catalog?n=2"
+	for range len(expected) {
+		w := serve(h, http.MethodGet, target, nil)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		var body struct {
+			Repos []string `json:"repositories"`
+		}
+		g.Expect(json.NewDecoder(w.Body).Decode(&body)).To(Succeed())
+		g.Expect(len(body.Repos)).To(BeNumerically("<=", 2))
+		repos = append(repos, body.Repos...)
+
+		link := w.Header().Get("Link")
+		if link == "" {
+			break
+		}
+		g.Expect(link).To(HavePrefix("</v2/_catalog?"))
+		target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		next, err := url.Parse(target)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(next.Query().Get("n")).To(Equal("2"))
+		g.Expect(next.Query().Get("last")).To(Equal(body.Repos[len(body.Repos)-1]))
+	}
+	g.Expect(repos).To(Equal(expected))
+
+	for _, n := range []string{"abc", "-1"} {
+		w := serve(h, http.MethodGet, "/v2/_catalog?n="+n, nil)
+		g.Expect(w.Code).To(Equal(http.StatusBadRequest))
+	}
+}
+
+func TestReferrersArtifactTypeFilter(t *testing.T) {
+	g := NewWithT(t)
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(noAudit{}),
+		registry.WithBlobHandler(newFakeBlobsExist()),
+		registry.WithManifestHandler(manifestinmemory.NewManifestHandler()),
+		registry.WithReferrersSupport(true),
+		registry.WithMiddlewares(txContext),
+	)
+	subject := "sha256:" + strings.Repeat("c", 64)
+	pushReferrer := func(tag, artifactType, configMediaType string) {
+		data := fmt.Sprintf(
+			`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",%v`+
+				`"config":{"mediaType":"%v","digest":"sha256:%v","size":2},"layers":[],`+
+				`"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%v","size":2}}`,
+			artifactType, configMediaType, strings.Repeat("a", 64), subject,
+		)
+		r := httptest.NewRequest(http.MethodPut, "/v2/org/app/manifests/"+tag, strings.NewReader(data))
+		r.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		g.Expect(w.Code).To(Equal(http.StatusCreated))
+	}
+	pushReferrer("sig", `"artifactType":"application/vnd.dev.cosign.artifact.sig.v1+json",`,
+		"application/vnd.oci.empty.v1+json")
+	pushReferrer("sbom", "", "application/spdx+json")
+
+	getReferrers := func(query string) (*httptest.ResponseRecorder, []string) {
+		w := serve(h, http.MethodGet, "/v2/org/app/referrers/"+subject+query, nil)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		var index v1.IndexManifest
+		g.Expect(json.NewDecoder(w.Body).Decode(&index)).To(Succeed())
+		artifactTypes := make([]string, len(index.Manifests))
+		for i, desc := range index.Manifests {
+			artifactTypes[i] = desc.ArtifactType
+		}
+		return w, artifactTypes
+	}
+
+	w, artifactTypes := getReferrers("")
+	g.Expect(w.Header().Get("OCI-Filters-Applied")).To(BeEmpty())
+	g.Expect(artifactTypes).To(ConsistOf("application/vnd.dev.cosign.artifact.sig.v1+json", "application/spdx+json"))
+
+	w, artifactTypes = getReferrers("?artifactType=application/spdx%2Bjson")
+	g.Expect(w.Header().Get("OCI-Filters-Applied")).To(Equal("artifactType"))
+	g.Expect(artifactTypes).To(ConsistOf("application/spdx+json"))
+
+	w, artifactTypes = getReferrers("?artifactType=application/vnd.example")
+	g.Expect(w.Header().Get("OCI-Filters-Applied")).To(Equal("artifactType"))
+	g.Expect(artifactTypes).To(BeEmpty())
+}
+
+// countingAudit counts the audited pulls and how many of them were HEAD requests.
+type countingAudit struct{ pulls, heads atomic.Int32 }
+
+func (a *countingAudit) AuditPull(_ context.Context, _, _, method string) error {
+	a.pulls.Add(1)
+	if method == http.MethodHead {
+		a.heads.Add(1)
+	}
+	return nil
+}
+
+func TestManifestConditionalRequests(t *testing.T) {
+	g := NewWithT(t)
+	audit := &countingAudit{}
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(audit),
+		registry.WithBlobHandler(newFakeBlobsExist()),
+		registry.WithManifestHandler(manifestinmemory.NewManifestHandler()),
+		registry.WithMiddlewares(txContext),
+	)
+	pushManifest(g, h, "/v2/org/app/manifests/latest", "sha256:"+strings.Repeat("a", 64))
+
+	w := serve(h, http.MethodGet, "/v2/org/app/manifests/latest", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	digest := w.Header().Get("Docker-Content-Digest")
+	g.Expect(w.Header().Get("ETag")).To(Equal(`"` + digest + `"`))
+	g.Expect(audit.pulls.Load()).To(BeEquivalentTo(1))
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		for _, ifNoneMatch := range []string{`"` + digest + `"`, `"other", W/"` + digest + `"`, "*"} {
+			r := httptest.NewRequest(method, "/v2/org/app/manifests/latest", nil)
+			r.Header.Set("If-None-Match", ifNoneMatch)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			g.Expect(w.Code).To(Equal(http.StatusNotModified))
+			g.Expect(w.Body.Len()).To(BeZero())
+			g.Expect(w.Header().Get("ETag")).To(Equal(`"` + digest + `"`))
+			g.Expect(w.Header().Get("Docker-Content-Digest")).To(Equal(digest))
+		}
+	}
+	// conditional requests that are answered with 304 are not counted as pulls
+	g.Expect(audit.pulls.Load()).To(BeEquivalentTo(1))
+
+	// a moved tag has a different ETag
+	pushManifest(g, h, "/v2/org/app/manifests/latest", "sha256:"+strings.Repeat("b", 64))
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		r := httptest.NewRequest(method, "/v2/org/app/manifests/latest", nil)
+		r.Header.Set("If-None-Match", `"`+digest+`"`)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		g.Expect(w.Code).To(Equal(http.StatusOK))
+		g.Expect(w.Header().Get("ETag")).NotTo(Equal(`"` + digest + `"`))
+	}
+	g.Expect(audit.pulls.Load()).To(BeEquivalentTo(3))
+	g.Expect(audit.heads.Load()).To(BeEquivalentTo(1))
+}
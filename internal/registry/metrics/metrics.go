@@ -0,0 +1,105 @@
+// Package metrics collects Prometheus metrics of the registry traffic and of the cleanup jobs.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry contains all collectors of this package and the collectors of the Go runtime and the process.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distr_registry_requests_total",
+			Help: "Number of registry requests by handler, method, repository, status and error code.",
+		},
+		[]string{"handler", "method", "repository", "status", "code"},
+	)
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "distr_registry_request_duration_seconds",
+			Help:    "Duration of registry requests by handler and method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"handler", "method"},
+	)
+	uploadsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "distr_registry_uploads_in_flight",
+			Help: "Number of blob upload requests that are currently being served.",
+		},
+	)
+	cleanupDeleted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distr_cleanup_deleted_total",
+			Help: "Number of rows or objects deleted by cleanup jobs by cleanup.",
+		},
+		[]string{"cleanup"},
+	)
+	cleanupReclaimedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "distr_cleanup_reclaimed_bytes_total",
+			Help: "Number of bytes of storage reclaimed by cleanup jobs by cleanup.",
+		},
+		[]string{"cleanup"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		requests,
+		requestDuration,
+		uploadsInFlight,
+		cleanupDeleted,
+		cleanupReclaimedBytes,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Request describes a registry request that has been served.
+type Request struct {
+	// Handler is the name of the registry handler that served the request, for example "manifests" or "blobs".
+	Handler string
+	Method  string
+	// Repository is the name of the repository that the request addressed. It is empty for requests that do not
+	// address a repository, such as the catalog.
+	Repository string
+	Status     int
+	// Code is the error code of the OCI distribution specification that was returned. It is empty for successful
+	// requests.
+	Code     string
+	Duration time.Duration
+}
+
+// ObserveRequest records a served registry request.
+func ObserveRequest(r Request) {
+	requests.WithLabelValues(r.Handler, r.Method, r.Repository, strconv.Itoa(r.Status), r.Code).Inc()
+	requestDuration.WithLabelValues(r.Handler, r.Method).Observe(r.Duration.Seconds())
+}
+
+// UploadStarted records that a blob upload request is being served. The returned function must be called when the
+// request is done.
+func UploadStarted() func() {
+	uploadsInFlight.Inc()
+	return uploadsInFlight.Dec
+}
+
+// ObserveCleanup records the result of a cleanup run. Cleanups that do not free any storage, like the deletion of old
+// status rows, pass 0 for reclaimedBytes.
+func ObserveCleanup(cleanup string, deleted, reclaimedBytes int64) {
+	cleanupDeleted.WithLabelValues(cleanup).Add(float64(deleted))
+	cleanupReclaimedBytes.WithLabelValues(cleanup).Add(float64(reclaimedBytes))
+}
+
+// Handler serves the metrics of Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,61 @@
+package registry_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/glasskube/distr/internal/registry/metrics"
+	. "github.com/onsi/gomega"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	h := newBlobTestRegistry()
+	for _, tc := range []struct {
+		method, target string
+		labels         map[string]string
+	}{
+		{http.MethodGet, "/v2/", map[string]string{
+			"handler": "base", "method": "GET", "repository": "", "status": "200", "code": "",
+		}},
+		{http.MethodPost, "/v2/org/group/app/blobs/uploads/", map[string]string{
+			"handler": "blobs", "method": "POST", "repository": "org/group/app", "status": "202", "code": "",
+		}},
+		{http.MethodDelete, "/v2/org/app/manifests/latest", map[string]string{
+			"handler": "manifests", "method": "DELETE", "repository": "org/app", "status": "405", "code": "UNSUPPORTED",
+		}},
+	} {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			g := NewWithT(t)
+			before := requestCount(t, tc.labels)
+			serve(h, tc.method, tc.target, nil)
+			g.Expect(requestCount(t, tc.labels)).To(Equal(before + 1))
+		})
+	}
+}
+
+// requestCount returns the value of distr_registry_requests_total with exactly the given labels.
+func requestCount(t *testing.T, labels map[string]string) float64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "distr_registry_requests_total" {
+			continue
+		}
+	metric:
+		for _, m := range family.GetMetric() {
+			if len(m.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metric
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
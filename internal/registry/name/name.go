@@ -3,11 +3,23 @@ package name
 import (
 	"fmt"
 	"path"
+	"regexp"
 	"strings"
 
 	registryerror "github.com/glasskube/distr/internal/registry/error"
 )
 
+// MaxLength is the maximum length of a complete repository name including the organization slug.
+const MaxLength = 255
+
+var (
+	// componentPattern is the grammar of a single path component as defined by the OCI distribution spec
+	componentPattern = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*$`)
+	invalidChars     = regexp.MustCompile(`[^a-z0-9._-]+`)
+	separatorRuns    = regexp.MustCompile(`[._-]{2,}`)
+	validSeparator   = regexp.MustCompile(`^(__|-+)$`)
+)
+
 type Name struct {
 	OrgName      string
 	ArtifactName string
@@ -21,6 +33,52 @@ func Parse(input string) (*Name, error) {
 	}
 }
 
+// Validate checks that input is a valid repository name according to the OCI distribution spec and consists of
+// an organization slug followed by at least one and at most maxDepth-1 further path components.
+// A maxDepth of zero disables the depth check.
+func Validate(input string, maxDepth int) error {
+	if len(input) > MaxLength {
+		return fmt.Errorf("%w: must not be longer than %v characters", registryerror.ErrInvalidArtifactName, MaxLength)
+	}
+	components := strings.Split(input, "/")
+	if len(components) < 2 {
+		return fmt.Errorf("%w: %v: missing organization", registryerror.ErrInvalidArtifactName, input)
+	} else if maxDepth > 0 && len(components) > maxDepth {
+		return fmt.Errorf("%w: %v: must not have more than %v path components",
+			registryerror.ErrInvalidArtifactName, input, maxDepth)
+	}
+	for _, component := range components {
+		if !componentPattern.MatchString(component) {
+			return fmt.Errorf("%w: %v: path component %q must be lowercase alphanumeric, optionally separated by "+
+				"\".\", \"_\", \"__\" or \"-\"", registryerror.ErrInvalidArtifactName, input, component)
+		}
+	}
+	return nil
+}
+
+// Normalize returns a suggestion for a valid artifact name (without the organization slug) derived from input.
+// The result is not guaranteed to be valid, e.g. if input does not contain any alphanumeric characters.
+func Normalize(input string) string {
+	components := strings.Split(strings.ToLower(input), "/")
+	result := make([]string, 0, len(components))
+	for _, component := range components {
+		component = invalidChars.ReplaceAllString(component, "-")
+		component = separatorRuns.ReplaceAllStringFunc(component, func(s string) string {
+			if validSeparator.MatchString(s) {
+				return s
+			} else if strings.Contains(s, ".") {
+				return "."
+			}
+			return "-"
+		})
+		component = strings.Trim(component, "._-")
+		if component != "" {
+			result = append(result, component)
+		}
+	}
+	return strings.Join(result, "/")
+}
+
 func (obj Name) String() string {
 	return path.Join(obj.OrgName, obj.ArtifactName)
 }
@@ -0,0 +1,31 @@
+package name_test
+
+import (
+	"strings"
+	"testing"
+
+	registryerror "github.com/glasskube/distr/internal/registry/error"
+	"github.com/glasskube/distr/internal/registry/name"
+	. "github.com/onsi/gomega"
+)
+
+func TestValidate(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(name.Validate("acme/app", 0)).To(Succeed())
+	g.Expect(name.Validate("acme/some-team/app__x.y", 3)).To(Succeed())
+	g.Expect(name.Validate("acme/some-team/app", 2)).To(MatchError(registryerror.ErrInvalidArtifactName))
+	g.Expect(name.Validate("Acme/App", 0)).To(MatchError(registryerror.ErrInvalidArtifactName))
+	g.Expect(name.Validate("acme", 0)).To(MatchError(registryerror.ErrInvalidArtifactName))
+	g.Expect(name.Validate("acme/app_-x", 0)).To(MatchError(registryerror.ErrInvalidArtifactName))
+	g.Expect(name.Validate("acme//app", 0)).To(MatchError(registryerror.ErrInvalidArtifactName))
+	g.Expect(name.Validate("acme/"+strings.Repeat("a", name.MaxLength), 0)).
+		To(MatchError(registryerror.ErrInvalidArtifactName))
+}
+
+func TestNormalize(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(name.Normalize("App")).To(Equal("app"))
+	g.Expect(name.Normalize("My App/Sub..Thing_-x")).To(Equal("my-app/sub.thing-x"))
+	g.Expect(name.Normalize("/-app-/")).To(Equal("app"))
+	g.Expect(name.Normalize("a__b---c")).To(Equal("a__b---c"))
+}
@@ -0,0 +1,329 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/registry/blob"
+	"github.com/glasskube/distr/internal/registry/manifest"
+	"github.com/glasskube/distr/internal/registry/upstream"
+	"github.com/glasskube/distr/internal/registryclient"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	upstreamManifestTimeout = 30 * time.Second
+	upstreamBlobTimeout     = time.Hour
+	// maxUpstreamManifestSize limits manifests fetched from an upstream if the registry has no manifest size limit
+	maxUpstreamManifestSize = 4 * 1024 * 1024
+	// pullThroughMaxEntries is the number of remembered upstreams and tag checks above which expired ones are removed
+	pullThroughMaxEntries = 10000
+)
+
+var upstreamManifestTypes = []string{
+	string(types.OCIImageIndex),
+	string(types.DockerManifestList),
+	string(types.OCIManifestSchema1),
+	string(types.DockerManifestSchema2),
+}
+
+// pullThrough fetches manifests and blobs of proxy repositories that are missing from their upstream repository and
+// stores them with the blob and manifest handler, so that subsequent requests are served from the cached copy.
+//
+// Digests never change, so they are only fetched once. Tags are checked against the upstream again if they were last
+// checked more than ttl ago, and moved if the upstream tag points at a different manifest. The upstreams of
+// repositories are remembered for ttl as well. If the upstream can not be reached, the cached copy is served.
+type pullThrough struct {
+	resolver upstream.Resolver
+	client   *registryclient.Client
+	ttl      time.Duration
+	group    singleflight.Group
+
+	mutex     sync.Mutex
+	upstreams map[string]resolvedUpstream
+	checked   map[string]time.Time
+}
+
+type resolvedUpstream struct {
+	// upstream is nil if the repository is not a proxy repository
+	upstream  *upstream.Upstream
+	expiresAt time.Time
+}
+
+func newPullThrough(resolver upstream.Resolver, client *http.Client, ttl time.Duration) *pullThrough {
+	return &pullThrough{
+		resolver:  resolver,
+		client:    registryclient.New(client),
+		ttl:       ttl,
+		upstreams: map[string]resolvedUpstream{},
+		checked:   map[string]time.Time{},
+	}
+}
+
+// resolve returns the upstream of repo, or nil if repo is not a proxy repository.
+func (p *pullThrough) resolve(ctx context.Context, repo string) (*upstream.Upstream, error) {
+	p.mutex.Lock()
+	resolved, ok := p.upstreams[repo]
+	p.mutex.Unlock()
+	if ok && time.Now().Before(resolved.expiresAt) {
+		return resolved.upstream, nil
+	}
+
+	up, err := p.resolver.Resolve(ctx, repo)
+	if errors.Is(err, upstream.ErrNotProxy) {
+		up = nil
+	} else if err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	if len(p.upstreams) >= pullThroughMaxEntries {
+		for key, resolved := range p.upstreams {
+			if !now.Before(resolved.expiresAt) {
+				delete(p.upstreams, key)
+			}
+		}
+	}
+	p.upstreams[repo] = resolvedUpstream{upstream: up, expiresAt: now.Add(p.ttl)}
+	return up, nil
+}
+
+// isFresh returns true if the tag with the given key has been checked against the upstream less than ttl ago.
+func (p *pullThrough) isFresh(key string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	checkedAt, ok := p.checked[key]
+	return ok && time.Since(checkedAt) < p.ttl
+}
+
+func (p *pullThrough) markChecked(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	if len(p.checked) >= pullThroughMaxEntries {
+		for key, checkedAt := range p.checked {
+			if now.Sub(checkedAt) >= p.ttl {
+				delete(p.checked, key)
+			}
+		}
+	}
+	p.checked[key] = now
+}
+
+func (p *pullThrough) clientFor(up *upstream.Upstream) *registryclient.Client {
+	if up.Username != "" {
+		return p.client.WithCredentials(up.Username, up.Password)
+	}
+	return p.client
+}
+
+// refreshFromUpstream fetches the manifest of reference from the upstream if repo is a proxy repository and the
+// manifest is missing or, for tags, may be outdated. Errors are only logged, the request is then served from the
+// cached copy if there is one.
+func (handler *manifests) refreshFromUpstream(ctx context.Context, repo, reference string) {
+	p := handler.pullThrough
+	if p == nil {
+		return
+	}
+	_, err := v1.NewHash(reference)
+	isDigest := err == nil
+	key := repo + "@" + reference
+	if !isDigest && p.isFresh(key) {
+		return
+	}
+	up, err := p.resolve(ctx, repo)
+	if err != nil {
+		handler.log.Warnw("could not resolve upstream", "repo", repo, "error", err)
+		return
+	} else if up == nil {
+		return
+	}
+	// other requests may be waiting for the result, so it must not be canceled with this request
+	_, err, _ = p.group.Do("manifest:"+key, func() (any, error) {
+		return nil, handler.fetchManifestFromUpstream(context.WithoutCancel(ctx), up, repo, reference)
+	})
+	if err != nil {
+		handler.log.Warnw("could not fetch manifest from upstream, serving cached copy if there is one",
+			"repo", repo, "reference", reference, "upstream", up.Repository.String(), "error", err)
+	}
+}
+
+// fetchManifestFromUpstream stores the manifest of reference from the upstream, unless the cached copy is up to date.
+func (handler *manifests) fetchManifestFromUpstream(
+	ctx context.Context,
+	up *upstream.Upstream,
+	repo, reference string,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, upstreamManifestTimeout)
+	defer cancel()
+	p := handler.pullThrough
+	key := repo + "@" + reference
+	var ref name.Reference
+	if _, err := v1.NewHash(reference); err == nil {
+		ref = up.Repository.Digest(reference)
+	} else {
+		ref = up.Repository.Tag(reference)
+	}
+
+	cached, err := handler.manifestHandler.Get(ctx, repo, reference)
+	if errors.Is(err, manifest.ErrNameUnknown) || errors.Is(err, manifest.ErrManifestUnknown) {
+		cached = nil
+	} else if err != nil {
+		return err
+	} else if _, ok := ref.(name.Digest); ok {
+		return nil
+	}
+
+	client := p.clientFor(up)
+	if cached != nil {
+		// a HEAD request is enough to find out whether the tag still points at the cached manifest
+		if digest, err := upstreamManifestDigest(ctx, client, ref); err != nil {
+			p.markChecked(key)
+			return err
+		} else if digest == cached.Blob.Digest.String() {
+			p.markChecked(key)
+			return nil
+		}
+	}
+
+	contentType, data, err := handler.readUpstreamManifest(ctx, client, ref)
+	if err != nil {
+		if cached != nil {
+			p.markChecked(key)
+		}
+		return err
+	}
+	if digest, ok := ref.(name.Digest); ok {
+		if actual, _, err := v1.SHA256(bytes.NewReader(data)); err != nil {
+			return err
+		} else if actual.String() != digest.DigestStr() {
+			return fmt.Errorf("upstream manifest has digest %v instead of %v", actual, digest.DigestStr())
+		}
+	}
+
+	// the manifests referenced by an image index must exist before the index can be stored
+	if types.MediaType(contentType).IsIndex() {
+		im, err := v1.ParseIndexManifest(bytes.NewReader(data))
+		if err != nil {
+			return err
+		} else if limit := handler.indexLimits.MaxChildren; limit > 0 && len(im.Manifests) > limit {
+			return fmt.Errorf("upstream image index has %v manifests, but at most %v are allowed",
+				len(im.Manifests), limit)
+		}
+		for _, desc := range im.Manifests {
+			if desc.MediaType.IsIndex() || desc.MediaType.IsImage() {
+				if err := handler.fetchManifestFromUpstream(ctx, up, repo, desc.Digest.String()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if _, rerr := handler.putManifest(ctx, repo, reference, contentType, data, false); rerr != nil {
+		if cached != nil {
+			p.markChecked(key)
+		}
+		if rerr.Error != nil {
+			return rerr.Error
+		}
+		return fmt.Errorf("%v: %v", rerr.Code, rerr.Message)
+	}
+	p.markChecked(key)
+	return nil
+}
+
+// upstreamManifestDigest returns the digest of the manifest of ref in the upstream registry.
+func upstreamManifestDigest(ctx context.Context, client *registryclient.Client, ref name.Reference) (string, error) {
+	resp, err := client.Manifest(ctx, http.MethodHead, ref, upstreamManifestTypes, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status getting manifest %v: %v", ref, resp.Status)
+	}
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// readUpstreamManifest returns the content type and content of the manifest of ref in the upstream registry.
+func (handler *manifests) readUpstreamManifest(
+	ctx context.Context,
+	client *registryclient.Client,
+	ref name.Reference,
+) (string, []byte, error) {
+	resp, err := client.Manifest(ctx, http.MethodGet, ref, upstreamManifestTypes, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("unexpected status getting manifest %v: %v", ref, resp.Status)
+	}
+	maxSize := handler.maxSize
+	if maxSize <= 0 {
+		maxSize = maxUpstreamManifestSize
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return "", nil, err
+	} else if int64(len(data)) > maxSize {
+		return "", nil, fmt.Errorf("upstream manifest %v is larger than %v bytes", ref, maxSize)
+	}
+	return resp.Header.Get("Content-Type"), data, nil
+}
+
+// fetchFromUpstream stores the blob h from the upstream if repo is a proxy repository and the blob is missing. Errors
+// are only logged, the request is then answered as if the repository was not a proxy repository.
+func (b *blobs) fetchFromUpstream(ctx context.Context, repo string, h v1.Hash) {
+	p := b.pullThrough
+	if p == nil {
+		return
+	}
+	bsh, ok := b.blobHandler.(blob.BlobStatHandler)
+	if !ok {
+		return
+	}
+	bph, ok := b.blobHandler.(blob.BlobPutHandler)
+	if !ok {
+		return
+	}
+	up, err := p.resolve(ctx, repo)
+	if err != nil {
+		b.log.Warnw("could not resolve upstream", "repo", repo, "error", err)
+		return
+	} else if up == nil {
+		return
+	} else if _, err := bsh.Stat(ctx, repo, h); !errors.Is(err, blob.ErrNotFound) {
+		return
+	}
+
+	_, err, _ = p.group.Do("blob:"+repo+"@"+h.String(), func() (any, error) {
+		// other requests may be waiting for the result, so it must not be canceled with this request
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), upstreamBlobTimeout)
+		defer cancel()
+		resp, err := p.clientFor(up).Blob(ctx, up.Repository, h.String())
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status getting blob %v: %v", h, resp.Status)
+		}
+		// the blob handler verifies the digest while the blob is stored
+		return nil, bph.Put(ctx, repo, h, resp.Header.Get("Content-Type"), resp.Body)
+	})
+	if err != nil {
+		b.log.Warnw("could not fetch blob from upstream",
+			"repo", repo, "digest", h.String(), "upstream", up.Repository.String(), "error", err)
+	}
+}
@@ -0,0 +1,226 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/blob/inmemory"
+	manifestinmemory "github.com/glasskube/distr/internal/registry/manifest/inmemory"
+	"github.com/glasskube/distr/internal/registry/upstream"
+	"github.com/google/go-containerregistry/pkg/name"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+type upstreamManifest struct {
+	contentType string
+	data        string
+}
+
+// fakeUpstream is a registry that serves manifests and blobs to clients with basic authentication.
+type fakeUpstream struct {
+	mutex     sync.Mutex
+	manifests map[string]upstreamManifest
+	blobs     map[string]string
+	down      bool
+	requests  atomic.Int32
+}
+
+func newFakeUpstream() *fakeUpstream {
+	return &fakeUpstream{manifests: map[string]upstreamManifest{}, blobs: map[string]string{}}
+}
+
+func (u *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.requests.Add(1)
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	} else if username, password, ok := r.BasicAuth(); !ok || username != "robot" || password != "secret" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="upstream"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if reference, ok := strings.CutPrefix(r.URL.Path, "/v2/library/app/manifests/"); ok {
+		if m, ok := u.manifests[reference]; ok {
+			w.Header().Set("Content-Type", m.contentType)
+			w.Header().Set("Docker-Content-Digest", sha256Digest(m.data))
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(m.data))
+			}
+			return
+		}
+	} else if digest, ok := strings.CutPrefix(r.URL.Path, "/v2/library/app/blobs/"); ok {
+		if data, ok := u.blobs[digest]; ok {
+			_, _ = w.Write([]byte(data))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// tag stores the manifest under its digest and under tag.
+func (u *fakeUpstream) tag(tag, contentType, data string) string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	digest := sha256Digest(data)
+	u.manifests[tag] = upstreamManifest{contentType: contentType, data: data}
+	u.manifests[digest] = upstreamManifest{contentType: contentType, data: data}
+	return digest
+}
+
+func (u *fakeUpstream) blob(data string) string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	digest := sha256Digest(data)
+	u.blobs[digest] = data
+	return digest
+}
+
+func (u *fakeUpstream) setDown(down bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.down = down
+}
+
+func sha256Digest(data string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(data)))
+}
+
+func imageManifest(config, layer string) string {
+	return fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%v","size":2},`+
+		`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"%v","size":5}]}`,
+		config, layer)
+}
+
+// staticResolver makes org/proxy a proxy repository of library/app in the upstream registry at host.
+type staticResolver struct{ host string }
+
+func (r staticResolver) Resolve(_ context.Context, repo string) (*upstream.Upstream, error) {
+	if repo != "org/proxy" {
+		return nil, upstream.ErrNotProxy
+	}
+	repository, err := name.NewRepository(r.host+"/library/app", name.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	return &upstream.Upstream{Repository: repository, Username: "robot", Password: "secret"}, nil
+}
+
+func newPullThroughTestRegistry(t *testing.T, ttl time.Duration) (http.Handler, *fakeUpstream) {
+	fake := newFakeUpstream()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithAuditor(noAudit{}),
+		registry.WithBlobHandler(inmemory.NewBlobHandler()),
+		registry.WithManifestHandler(manifestinmemory.NewManifestHandler()),
+		registry.WithPullThroughCache(staticResolver{host: server.Listener.Addr().String()}, server.Client(), ttl),
+		registry.WithMiddlewares(txContext),
+	), fake
+}
+
+func TestPullThroughCache(t *testing.T) {
+	g := NewWithT(t)
+	h, fake := newPullThroughTestRegistry(t, time.Hour)
+	config, layer := fake.blob("{}"), fake.blob("layer")
+	data := imageManifest(config, layer)
+	digest := fake.tag("1.0", "application/vnd.oci.image.manifest.v1+json", data)
+
+	w := serve(h, http.MethodGet, "/v2/org/proxy/manifests/1.0", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal(data))
+	g.Expect(w.Header().Get("Docker-Content-Digest")).To(Equal(digest))
+	w = serve(h, http.MethodGet, "/v2/org/proxy/blobs/"+layer, nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal("layer"))
+	g.Expect(serve(h, http.MethodHead, "/v2/org/proxy/blobs/"+config, nil).Code).To(Equal(http.StatusOK))
+
+	// the cached copies are served without asking the upstream again
+	fake.requests.Store(0)
+	g.Expect(serve(h, http.MethodGet, "/v2/org/proxy/manifests/1.0", nil).Code).To(Equal(http.StatusOK))
+	g.Expect(serve(h, http.MethodHead, "/v2/org/proxy/manifests/"+digest, nil).Code).To(Equal(http.StatusOK))
+	g.Expect(serve(h, http.MethodGet, "/v2/org/proxy/blobs/"+layer, nil).Code).To(Equal(http.StatusOK))
+	g.Expect(fake.requests.Load()).To(BeZero())
+
+	// other repositories are not proxies
+	w = serve(h, http.MethodGet, "/v2/org/other/manifests/1.0", nil)
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+	g.Expect(fake.requests.Load()).To(BeZero())
+
+	w = serve(h, http.MethodGet, "/v2/org/proxy/manifests/2.0", nil)
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+	g.Expect(errorCode(g, w)).To(Equal("MANIFEST_UNKNOWN"))
+}
+
+func TestPullThroughCacheRefreshesTags(t *testing.T) {
+	g := NewWithT(t)
+	h, fake := newPullThroughTestRegistry(t, 0)
+	first := imageManifest(fake.blob("{}"), fake.blob("first"))
+	firstDigest := fake.tag("latest", "application/vnd.oci.image.manifest.v1+json", first)
+	w := serve(h, http.MethodGet, "/v2/org/proxy/manifests/latest", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal(first))
+
+	second := imageManifest(fake.blob("{}"), fake.blob("second"))
+	fake.tag("latest", "application/vnd.oci.image.manifest.v1+json", second)
+	w = serve(h, http.MethodGet, "/v2/org/proxy/manifests/latest", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal(second))
+
+	// the cached copy is served while the upstream can not be reached
+	fake.setDown(true)
+	w = serve(h, http.MethodGet, "/v2/org/proxy/manifests/latest", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal(second))
+	w = serve(h, http.MethodGet, "/v2/org/proxy/manifests/"+firstDigest, nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal(first))
+}
+
+func TestPullThroughCacheImageIndex(t *testing.T) {
+	g := NewWithT(t)
+	h, fake := newPullThroughTestRegistry(t, time.Hour)
+	child := imageManifest(fake.blob("{}"), fake.blob("layer"))
+	childDigest := fake.tag("child", "application/vnd.oci.image.manifest.v1+json", child)
+	index := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json",`+
+		`"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%v","size":%v,`+
+		`"platform":{"os":"linux","architecture":"amd64"}}]}`, childDigest, len(child))
+	fake.tag("1.0", "application/vnd.oci.image.index.v1+json", index)
+
+	w := serve(h, http.MethodGet, "/v2/org/proxy/manifests/1.0", nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal(index))
+
+	// the manifests of the index have been stored together with it
+	fake.setDown(true)
+	w = serve(h, http.MethodGet, "/v2/org/proxy/manifests/"+childDigest, nil)
+	g.Expect(w.Code).To(Equal(http.StatusOK))
+	g.Expect(w.Body.String()).To(Equal(child))
+}
+
+func TestPullThroughCacheRejectsCorruptedBlobs(t *testing.T) {
+	g := NewWithT(t)
+	h, fake := newPullThroughTestRegistry(t, time.Hour)
+	digest := sha256Digest("original")
+	fake.mutex.Lock()
+	fake.blobs[digest] = "tampered"
+	fake.mutex.Unlock()
+
+	w := serve(h, http.MethodGet, "/v2/org/proxy/blobs/"+digest, nil)
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+	g.Expect(errorCode(g, w)).To(Equal("BLOB_UNKNOWN"))
+}
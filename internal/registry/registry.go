@@ -29,10 +29,14 @@ import (
 	"math/rand"
 	"net/http"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/maintenance"
 	"github.com/glasskube/distr/internal/middleware"
 	"github.com/glasskube/distr/internal/registry/audit"
 	"github.com/glasskube/distr/internal/registry/authz"
@@ -40,7 +44,11 @@ import (
 	"github.com/glasskube/distr/internal/registry/blob/s3"
 	"github.com/glasskube/distr/internal/registry/manifest"
 	"github.com/glasskube/distr/internal/registry/manifest/db"
+	"github.com/glasskube/distr/internal/registry/metrics"
+	"github.com/glasskube/distr/internal/registry/token"
+	"github.com/glasskube/distr/internal/registry/upstream"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -52,6 +60,7 @@ type registry struct {
 	blobs            blobs
 	manifests        manifests
 	referrersEnabled bool
+	token            http.Handler
 	warnings         map[float64]string
 	middlewares      []func(http.Handler) http.Handler
 }
@@ -68,6 +77,10 @@ func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
 		}
 	}
 
+	if r.token != nil && isToken(req) {
+		r.token.ServeHTTP(resp, req)
+		return nil
+	}
 	if isBlob(req) {
 		return r.blobs.handle(resp, req)
 	}
@@ -85,26 +98,79 @@ func (r *registry) v2(resp http.ResponseWriter, req *http.Request) *regError {
 	}
 	resp.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
 	if req.URL.Path != "/v2/" && req.URL.Path != "/v2" {
-		return &regError{
-			Status:  http.StatusNotFound,
-			Code:    "METHOD_UNKNOWN",
-			Message: "We don't understand your method + url",
-		}
+		return regErrRouteUnknown("We don't understand your method + url")
 	}
 	resp.WriteHeader(200)
 	return nil
 }
 
 func (r *registry) root(resp http.ResponseWriter, req *http.Request) {
-	if rerr := r.v2(resp, req); rerr != nil {
+	start := time.Now()
+	handler := r.handlerName(req)
+	if handler == "blobs" && req.Method != http.MethodGet && req.Method != http.MethodHead &&
+		strings.Contains(req.URL.Path, "/blobs/"+uploads) {
+		defer metrics.UploadStarted()()
+	}
+	ww := chimiddleware.NewWrapResponseWriter(resp, req.ProtoMajor)
+	observed := metrics.Request{
+		Handler:    handler,
+		Method:     req.Method,
+		Repository: requestRepository(req.URL.Path, handler),
+	}
+	if rerr := r.v2(ww, req); rerr != nil {
 		r.log.Warnf("%s %s %d %s %s", req.Method, req.URL, rerr.Status, rerr.Code, rerr.Message)
 		if rerr.Status == http.StatusInternalServerError && rerr.Error != nil {
 			sentry.GetHubFromContext(req.Context()).CaptureException(rerr.Error)
 		}
-		_ = rerr.Write(resp)
-		return
+		_ = rerr.Write(ww)
+		observed.Code = rerr.Code
+		if rerr.Code == errCodeNameUnknown || rerr.Code == errCodeNameInvalid {
+			// arbitrary names must not create new label values
+			observed.Repository = ""
+		}
+	} else {
+		r.log.Infof("%s %s", req.Method, req.URL)
+	}
+	observed.Status = ww.Status()
+	if observed.Status == 0 {
+		observed.Status = http.StatusOK
 	}
-	r.log.Infof("%s %s", req.Method, req.URL)
+	observed.Duration = time.Since(start)
+	metrics.ObserveRequest(observed)
+}
+
+// handlerName returns the name of the handler that serves req in v2.
+func (r *registry) handlerName(req *http.Request) string {
+	switch {
+	case r.token != nil && isToken(req):
+		return "token"
+	case isBlob(req):
+		return "blobs"
+	case isManifest(req):
+		return "manifests"
+	case isTags(req):
+		return "tags"
+	case isCatalog(req):
+		return "catalog"
+	case r.referrersEnabled && isReferrers(req):
+		return "referrers"
+	default:
+		return "base"
+	}
+}
+
+// requestRepository returns the repository name in path, which is served by handler. The name is everything between
+// /v2/ and the last path component that names the handler, e.g. /v2/{name}/manifests/{reference}.
+func requestRepository(path, handler string) string {
+	switch handler {
+	case "blobs", "manifests", "tags", "referrers":
+		if rest, ok := strings.CutPrefix(path, "/v2/"); ok {
+			if i := strings.LastIndex(rest, "/"+handler+"/"); i > 0 {
+				return rest[:i]
+			}
+		}
+	}
+	return ""
 }
 
 // New returns a handler which implements the docker registry protocol.
@@ -116,6 +182,9 @@ func New(opts ...Option) http.Handler {
 	for _, o := range opts {
 		o(reg)
 	}
+	if reg.manifests.cacheNotifier != nil {
+		reg.manifests.cache.subscribe(reg.manifests.cacheNotifier)
+	}
 	var h http.Handler = http.HandlerFunc(reg.root)
 	slices.Reverse(reg.middlewares)
 	for _, mw := range reg.middlewares {
@@ -130,6 +199,8 @@ func NewDefault(
 	pool *pgxpool.Pool,
 	mailer mail.Mailer,
 	tracer *trace.TracerProvider,
+	maintenanceWatcher *maintenance.Watcher,
+	cacheNotifier ManifestCacheNotifier,
 ) http.Handler {
 	return New(
 		WithLogger(logger),
@@ -137,6 +208,19 @@ func NewDefault(
 		WithManifestHandler(db.NewManifestHandler()),
 		WithAuthorizer(authz.NewAuthorizer()),
 		WithAuditor(audit.NewAuditor()),
+		WithTokenHandler(token.NewHandler()),
+		WithNameMaxDepth(env.RegistryNameMaxDepth()),
+		WithManifestMaxSize(env.RegistryManifestMaxSize()),
+		WithIndexLimits(IndexLimits{
+			MaxChildren:    env.RegistryIndexMaxChildren(),
+			MaxDepth:       env.RegistryIndexMaxDepth(),
+			MaxDescriptors: env.RegistryIndexMaxDescriptors(),
+		}),
+		WithIndexChildGracePeriod(env.RegistryIndexChildGracePeriod()),
+		WithManifestCache(env.RegistryManifestCacheTTL(), env.RegistryManifestCacheSize()),
+		WithManifestCacheNotifier(cacheNotifier),
+		WithDefaultPlatform(env.RegistryDefaultPlatform()),
+		WithPullThroughCache(upstream.NewResolver(), http.DefaultClient, env.RegistryPullThroughTagTTL()),
 		WithMiddlewares(
 			chimiddleware.Recoverer,
 			chimiddleware.RequestID,
@@ -146,8 +230,11 @@ func NewDefault(
 			middleware.LoggerCtxMiddleware(logger),
 			middleware.LoggingMiddleware,
 			middleware.ContextInjectorMiddleware(pool, mailer),
-			auth.ArtifactsAuthentication.Middleware,
-			middleware.RequireOrgAndRole,
+			middleware.MaintenanceCtxMiddleware(maintenanceWatcher),
+			exceptToken(auth.ArtifactsAuthentication.Middleware),
+			exceptToken(middleware.RequireOrgAndRole),
+			// pushes are rejected during maintenance but pulls are still possible
+			middleware.ReadOnlyDuringMaintenance,
 		),
 	)
 }
@@ -207,8 +294,102 @@ func WithAuthorizer(a authz.Authorizer) Option {
 	}
 }
 
+// WithNameMaxDepth limits the number of path components of repository names that can be pushed to.
+// A value of zero means no limit.
+func WithNameMaxDepth(depth int) Option {
+	return func(r *registry) {
+		r.blobs.nameMaxDepth = depth
+		r.manifests.nameMaxDepth = depth
+	}
+}
+
+// WithManifestMaxSize limits the size in bytes of manifests that can be pushed.
+// A value of zero means no limit.
+func WithManifestMaxSize(size int64) Option {
+	return func(r *registry) {
+		r.manifests.maxSize = size
+	}
+}
+
+// WithIndexLimits limits the size and nesting of image indexes that can be pushed.
+func WithIndexLimits(limits IndexLimits) Option {
+	return func(r *registry) {
+		r.manifests.indexLimits = limits
+	}
+}
+
+// WithIndexChildGracePeriod makes a push of an image index wait up to d for the manifests that it references, if some
+// of them have not been pushed yet. A duration of zero rejects such an index right away, unless the manifest handler
+// allows it.
+func WithIndexChildGracePeriod(d time.Duration) Option {
+	return func(r *registry) {
+		r.manifests.indexChildGracePeriod = d
+	}
+}
+
+// WithManifestCache coalesces concurrent reads of the same manifest and keeps manifests that were read in memory for
+// ttl, but at most size of them. Without WithManifestCacheNotifier, a push on another instance that moves a tag is
+// visible to new reads after at most ttl. A ttl of zero only enables coalescing.
+func WithManifestCache(ttl time.Duration, size int) Option {
+	return func(r *registry) {
+		r.manifests.cache = newManifestCache(ttl, size)
+	}
+}
+
+// WithManifestCacheNotifier announces every push with notifier and invalidates the manifest cache with the pushes
+// announced by other instances.
+func WithManifestCacheNotifier(notifier ManifestCacheNotifier) Option {
+	return func(r *registry) {
+		r.manifests.cacheNotifier = notifier
+	}
+}
+
+// WithDefaultPlatform enables content negotiation for image indexes: clients that do not accept image indexes get the
+// manifest of platform instead. If platform is nil, image indexes are served to all clients.
+func WithDefaultPlatform(platform *v1.Platform) Option {
+	return func(r *registry) {
+		r.manifests.defaultPlatform = platform
+	}
+}
+
+// WithPullThroughCache enables proxy repositories, whose upstream is returned by resolver. Manifests and blobs that are
+// missing in a proxy repository are fetched from the upstream with client and stored with the blob and manifest
+// handler. Tags are checked against the upstream again after ttl.
+func WithPullThroughCache(resolver upstream.Resolver, client *http.Client, ttl time.Duration) Option {
+	return func(r *registry) {
+		p := newPullThrough(resolver, client, ttl)
+		r.blobs.pullThrough = p
+		r.manifests.pullThrough = p
+	}
+}
+
 func WithAuditor(a audit.ArtifactAuditor) Option {
 	return func(r *registry) {
 		r.manifests.audit = a
 	}
 }
+
+// WithTokenHandler serves the token endpoint at auth.RegistryTokenPath with h.
+func WithTokenHandler(h http.Handler) Option {
+	return func(r *registry) {
+		r.token = h
+	}
+}
+
+func isToken(req *http.Request) bool {
+	return req.URL.Path == auth.RegistryTokenPath
+}
+
+// exceptToken skips mw for requests to the token endpoint, which must be reachable without authentication.
+func exceptToken(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isToken(r) {
+				next.ServeHTTP(w, r)
+			} else {
+				wrapped.ServeHTTP(w, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Package token implements the token endpoint of the registry. OCI clients that follow the Bearer challenge of the
+// registry request the token for their subsequent requests there.
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/authjwt"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/name"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// anonymousTokenValidity is short because clients request a new token for every pull anyway.
+const anonymousTokenValidity = 5 * time.Minute
+
+var errScopeNotPublic = errors.New("scope is not limited to pulling public repositories")
+
+type response struct {
+	Token       string     `json:"token"`
+	AccessToken string     `json:"access_token"`
+	ExpiresIn   int        `json:"expires_in,omitempty"`
+	IssuedAt    *time.Time `json:"issued_at,omitempty"`
+}
+
+// NewHandler returns the handler of the token endpoint. Clients with credentials get the verified credential itself as
+// token, so that they are authenticated exactly as if they had sent it with basic auth. Clients without credentials
+// get an anonymous token if the requested scope only covers pulling public repositories of one organization.
+func NewHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		} else if r.Header.Get("Authorization") != "" {
+			auth.ArtifactsAuthentication.Middleware(http.HandlerFunc(respondPresentedCredential)).ServeHTTP(w, r)
+		} else {
+			respondAnonymousToken(w, r)
+		}
+	})
+}
+
+func respondPresentedCredential(w http.ResponseWriter, r *http.Request) {
+	credential, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, password, ok := r.BasicAuth(); ok {
+		credential = password
+	}
+	respondJSON(w, response{Token: credential, AccessToken: credential})
+}
+
+func respondAnonymousToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orgID, repositories, err := anonymousPullScope(ctx, r.URL.Query()["scope"])
+	if errors.Is(err, errScopeNotPublic) {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to check anonymous pull scope", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	token, signed, err := authjwt.GenerateAnonymousTokenValidFor(orgID, repositories, anonymousTokenValidity)
+	if err != nil {
+		internalctx.GetLogger(ctx).Error("failed to generate anonymous token", zap.Error(err))
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	issuedAt := token.IssuedAt()
+	respondJSON(w, response{
+		Token:       signed,
+		AccessToken: signed,
+		ExpiresIn:   int(anonymousTokenValidity.Seconds()),
+		IssuedAt:    &issuedAt,
+	})
+}
+
+// anonymousPullScope returns the organization and the repositories of scopes. It returns errScopeNotPublic unless
+// every scope is a pull of a public repository and all repositories belong to the same organization.
+// Clients may send several scopes in one parameter, separated by spaces.
+func anonymousPullScope(ctx context.Context, scopes []string) (uuid.UUID, []string, error) {
+	var repositories []string
+	for _, scope := range scopes {
+		for _, scope := range strings.Fields(scope) {
+			if repository, err := parsePullScope(scope); err != nil {
+				return uuid.Nil, nil, err
+			} else if !slices.Contains(repositories, repository) {
+				repositories = append(repositories, repository)
+			}
+		}
+	}
+	if len(repositories) == 0 {
+		return uuid.Nil, nil, errScopeNotPublic
+	}
+
+	var orgID uuid.UUID
+	for i, repository := range repositories {
+		if n, err := name.Parse(repository); err != nil {
+			return uuid.Nil, nil, errScopeNotPublic
+		} else if artifact, err := db.GetArtifactByName(ctx, n.OrgName, n.ArtifactName); errors.Is(
+			err, apierrors.ErrNotFound,
+		) {
+			return uuid.Nil, nil, errScopeNotPublic
+		} else if err != nil {
+			return uuid.Nil, nil, err
+		} else if !artifact.Public || (i > 0 && artifact.OrganizationID != orgID) {
+			return uuid.Nil, nil, errScopeNotPublic
+		} else {
+			orgID = artifact.OrganizationID
+		}
+	}
+	return orgID, repositories, nil
+}
+
+// parsePullScope returns the repository of a scope in the form repository:{name}:pull.
+func parsePullScope(scope string) (string, error) {
+	parts := strings.Split(scope, ":")
+	if len(parts) != 3 || parts[0] != "repository" || parts[1] == "" {
+		return "", errScopeNotPublic
+	}
+	for _, action := range strings.Split(parts[2], ",") {
+		if action != "pull" {
+			return "", errScopeNotPublic
+		}
+	}
+	return parts[1], nil
+}
+
+func respondJSON(w http.ResponseWriter, data response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,51 @@
+package registry_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/token"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+func TestBearerChallenge(t *testing.T) {
+	g := NewWithT(t)
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithMiddlewares(auth.ArtifactsAuthentication.Middleware),
+	)
+	w := serve(h, http.MethodGet, "/v2/", nil)
+	g.Expect(w.Code).To(Equal(http.StatusUnauthorized))
+	g.Expect(w.Header().Get("WWW-Authenticate")).
+		To(Equal(`Bearer realm="https://example.com/v2/token",service="example.com"`))
+}
+
+func TestAnonymousTokenRejectsScopes(t *testing.T) {
+	h := registry.New(
+		registry.WithLogger(zap.NewNop()),
+		registry.WithAuthorizer(allowAll{}),
+		registry.WithTokenHandler(token.NewHandler()),
+	)
+	// none of these scopes may be granted anonymously, which is decided before any artifact is looked up
+	for _, tc := range []struct {
+		name, target string
+	}{
+		{"no scope", "/v2/token?service=example.com"},
+		{"push", "/v2/token?scope=repository:org/app:pull,push"},
+		{"catalog", "/v2/token?scope=registry:catalog:*"},
+		{"one of several", "/v2/token?scope=repository:org/app:pull&scope=repository:org/other:push"},
+		{"space separated", "/v2/token?scope=repository:org/app:pull+repository:org/app:delete"},
+		{"missing name", "/v2/token?scope=repository::pull"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(serve(h, http.MethodGet, tc.target, nil).Code).To(Equal(http.StatusUnauthorized))
+		})
+	}
+	g := NewWithT(t)
+	g.Expect(serve(h, http.MethodPost, "/v2/token", nil).Code).To(Equal(http.StatusMethodNotAllowed))
+}
@@ -0,0 +1,68 @@
+// Package upstream looks up the upstream repositories of proxy repositories, which are pull-through caches of a
+// repository in another registry.
+package upstream
+
+import (
+	"context"
+	"errors"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/auth"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/name"
+	ggcrname "github.com/google/go-containerregistry/pkg/name"
+)
+
+// ErrNotProxy is returned by Resolver.Resolve for repositories that are not proxy repositories.
+var ErrNotProxy = errors.New("repository is not a proxy")
+
+// Upstream is the repository in another registry that a proxy repository caches.
+type Upstream struct {
+	Repository ggcrname.Repository
+	Username   string
+	Password   string
+}
+
+type Resolver interface {
+	// Resolve returns the upstream of the proxy repository repo. It returns ErrNotProxy if repo is not a proxy
+	// repository or if nothing can be stored in it right now, in which case only cached manifests and blobs are
+	// served.
+	Resolve(ctx context.Context, repo string) (*Upstream, error)
+}
+
+type resolver struct{}
+
+func NewResolver() Resolver {
+	return &resolver{}
+}
+
+// Resolve implements Resolver. Proxy repositories are not refreshed during maintenance, because pushes are rejected.
+func (r *resolver) Resolve(ctx context.Context, repo string) (*Upstream, error) {
+	n, err := name.Parse(repo)
+	if err != nil {
+		return nil, ErrNotProxy
+	}
+	upstream, err := db.GetArtifactUpstreamByName(ctx, n.OrgName, n.ArtifactName)
+	if errors.Is(err, apierrors.ErrNotFound) {
+		return nil, ErrNotProxy
+	} else if err != nil {
+		return nil, err
+	}
+	auth := auth.ArtifactsAuthentication.Require(ctx)
+	if internalctx.GetMaintenanceState(ctx).For(auth.CurrentOrgID()) != nil {
+		return nil, ErrNotProxy
+	}
+	repository, err := ggcrname.NewRepository(upstream.Repository)
+	if err != nil {
+		return nil, err
+	}
+	result := Upstream{Repository: repository}
+	if upstream.Username != nil {
+		result.Username = *upstream.Username
+	}
+	if upstream.Password != nil {
+		result.Password = *upstream.Password
+	}
+	return &result, nil
+}
@@ -25,6 +25,7 @@ import (
 	"io"
 
 	"github.com/glasskube/distr/internal/registry/and"
+	"github.com/glasskube/distr/internal/registry/blob"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
@@ -80,7 +81,7 @@ func (vc *verifyReader) Read(b []byte) (int, error) {
 // A size of SizeUnknown (-1) indicates disables size verification when the size
 // is unknown ahead of time.
 func ReadCloser(r io.ReadCloser, size int64, h v1.Hash) (io.ReadCloser, error) {
-	w, err := v1.Hasher(h.Algorithm)
+	w, err := blob.Hasher(h.Algorithm)
 	if err != nil {
 		return nil, err
 	}
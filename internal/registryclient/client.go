@@ -0,0 +1,174 @@
+// Package registryclient sends requests to the OCI distribution API of remote registries, either anonymously or with
+// the credentials of a registry user.
+package registryclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+type Client struct {
+	HTTP     *http.Client
+	username string
+	password string
+}
+
+func New(client *http.Client) *Client {
+	return &Client{HTTP: client}
+}
+
+// WithCredentials returns a copy of c that authenticates with username and password. They are sent to the token
+// endpoint of registries that use bearer tokens and with every request to registries that use basic authentication.
+func (c *Client) WithCredentials(username, password string) *Client {
+	return &Client{HTTP: c.HTTP, username: username, password: password}
+}
+
+// Manifest requests the manifest of ref with the given method. If the registry responds with an authentication
+// challenge, a pull token is requested or the credentials of c are sent, and the request is repeated once.
+// header is added to every request and may be nil. The caller must close the body of the returned response.
+func (c *Client) Manifest(
+	ctx context.Context,
+	method string,
+	ref name.Reference,
+	accept []string,
+	header http.Header,
+) (*http.Response, error) {
+	repo := ref.Context()
+	manifestURL := fmt.Sprintf("%v://%v/v2/%v/manifests/%v",
+		repo.Scheme(), repo.RegistryStr(), repo.RepositoryStr(), ref.Identifier())
+	return c.request(ctx, method, manifestURL, accept, header)
+}
+
+// Blob requests the blob of repo with the given digest. Redirects to another location, such as a storage bucket, are
+// followed. The caller must close the body of the returned response.
+func (c *Client) Blob(ctx context.Context, repo name.Repository, digest string) (*http.Response, error) {
+	blobURL := fmt.Sprintf("%v://%v/v2/%v/blobs/%v", repo.Scheme(), repo.RegistryStr(), repo.RepositoryStr(), digest)
+	return c.request(ctx, http.MethodGet, blobURL, nil, nil)
+}
+
+func (c *Client) request(
+	ctx context.Context,
+	method, url string,
+	accept []string,
+	header http.Header,
+) (*http.Response, error) {
+	resp, err := c.do(ctx, method, url, accept, header, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+		if authorization, err := c.authorization(ctx, challenge); err != nil {
+			return nil, err
+		} else {
+			return c.do(ctx, method, url, accept, header, authorization)
+		}
+	}
+	return resp, nil
+}
+
+func (c *Client) do(
+	ctx context.Context,
+	method, url string,
+	accept []string,
+	header http.Header,
+	authorization string,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.HTTP.Do(req)
+}
+
+// authorization returns the value of the Authorization header that answers an authentication challenge.
+func (c *Client) authorization(ctx context.Context, challenge string) (string, error) {
+	scheme, _, _ := strings.Cut(challenge, " ")
+	if strings.EqualFold(scheme, "Basic") && c.username != "" {
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(c.username, c.password)
+		return req.Header.Get("Authorization"), nil
+	} else if token, err := c.token(ctx, challenge); err != nil {
+		return "", err
+	} else {
+		return "Bearer " + token, nil
+	}
+}
+
+// token requests a pull token as described by a bearer challenge. The token is requested anonymously, unless c has
+// credentials.
+func (c *Client) token(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", errors.New("registry requires unsupported authentication: " + challenge)
+	}
+	values := url.Values{}
+	var realm string
+	for _, param := range strings.Split(params, ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok {
+			value = strings.Trim(value, `"`)
+			if key == "realm" {
+				realm = value
+			} else {
+				values.Set(key, value)
+			}
+		}
+	}
+	if realm == "" {
+		return "", errors.New("bearer challenge has no realm")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+values.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &TokenStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	} else if body.Token != "" {
+		return body.Token, nil
+	} else {
+		return body.AccessToken, nil
+	}
+}
+
+// TokenStatusError is returned if the token endpoint of a registry responds with an unexpected status.
+type TokenStatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+}
+
+func (err *TokenStatusError) Error() string {
+	return "unexpected status getting registry token: " + err.Status
+}
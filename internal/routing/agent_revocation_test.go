@@ -0,0 +1,61 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glasskube/distr/api"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/testutil"
+	. "github.com/onsi/gomega"
+)
+
+// TestAgentRevocation deletes and archives deployment targets on one replica and checks that the very next request of
+// their agent is rejected with a terminal response by another replica. Every replica is a separate router, so the
+// replicas share nothing but the database.
+func TestAgentRevocation(t *testing.T) {
+	ctx := testutil.DBContext(t)
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 0)
+	vendorKey := newAccessKey(ctx, t, org.Vendors[0].ID, org.ID)
+	database := requestDb{internalctx.GetDb(ctx)}
+	replicaA, replicaB := newRouter(database), newRouter(database)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		code   string
+	}{
+		{"delete", http.MethodDelete, "", api.AgentGoneCodeDeleted},
+		{"archive", http.MethodPost, "/archive", api.AgentGoneCodeArchived},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			dt := testutil.NewDeploymentTarget(ctx, t, org.ID, org.Vendors[0].ID)
+			token := testutil.NewAgentToken(t, dt)
+
+			g.Expect(serveAgent(replicaA, token)).To(HaveField("Code", http.StatusOK))
+			status, response := serve(t, database, tc.method, "/v1/deployment-targets/"+dt.ID.String()+tc.path, vendorKey, nil)
+			g.Expect(status).To(BeNumerically("<", 300), response)
+
+			recorder := serveAgent(replicaB, token)
+			g.Expect(recorder.Code).To(Equal(http.StatusGone))
+			var body api.ErrorResponse
+			g.Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+			g.Expect(body.Error.Code).To(Equal(tc.code))
+
+			// the agent can not get a new token either
+			g.Expect(serveAgent(replicaA, token)).To(HaveField("Code", http.StatusGone))
+		})
+	}
+}
+
+func serveAgent(router http.Handler, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/v1/agent/resources", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
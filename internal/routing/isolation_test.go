@@ -0,0 +1,461 @@
+package routing_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/db/queryable"
+	"github.com/glasskube/distr/internal/mail/noop"
+	"github.com/glasskube/distr/internal/maintenance"
+	"github.com/glasskube/distr/internal/routing"
+	"github.com/glasskube/distr/internal/testutil"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/go-chi/chi/v5"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+// skippedRoutePrefixes are routes that are not authenticated with a user of an organization.
+var skippedRoutePrefixes = []string{
+	"/v1/auth/", "/v1/badges/", "/v1/data-exports/", "/v1/webhooks/", "/v1/maintenance/",
+}
+
+// organizationListRoutes list all organizations of the current user, including the other organization.
+var organizationListRoutes = []string{"/v1/context/", "/v1/organizations/"}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// TestOrganizationIsolation sends a request to every route of the API as a user of organization A that is a member
+// of organization B as well. Every route is requested twice: once with all path parameters pointing to resources of
+// organization B and once with path parameters of organization A. In both cases, all references in the request body
+// point to resources of organization B. No response may contain an identifier of organization B that was not part of
+// the request, and no row that references organization B may be changed.
+//
+// New routes are covered automatically. If a route uses a new path parameter, it must be added to seedTenant.
+func TestOrganizationIsolation(t *testing.T) {
+	ctx := testutil.DBContext(t)
+	sharedVendor := testutil.NewUserAccount(ctx, t)
+	sharedCustomer := testutil.NewUserAccount(ctx, t)
+	orgA := seedTenant(ctx, t, "A", sharedVendor, sharedCustomer)
+	orgB := seedTenant(ctx, t, "B", sharedVendor, sharedCustomer)
+
+	requesters := []struct {
+		name string
+		key  string
+	}{
+		{"vendor", newAccessKey(ctx, t, sharedVendor.ID, orgA.ID)},
+		{"customer", newAccessKey(ctx, t, sharedCustomer.ID, orgA.ID)},
+	}
+	snapshot := newRowSnapshot(ctx, t, orgB.identifiers)
+	before, err := snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range apiRoutes(t) {
+		for _, requester := range requesters {
+			for _, pathTenant := range []*tenant{orgB, orgA} {
+				name := fmt.Sprintf(
+					"%v %v as %v with path of %v", route.method, route.pattern, requester.name, pathTenant.label,
+				)
+				t.Run(name, func(t *testing.T) {
+					path := pathTenant.resolve(t, route.pattern)
+					body := orgB.requestBody(route.pattern)
+					data, err := json.Marshal(body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					request := string(data) + " " + path
+
+					tx, err := internalctx.GetDb(ctx).Begin(ctx)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer func() { _ = tx.Rollback(ctx) }()
+
+					status, response := serve(t, requestDb{tx}, route.method, path, requester.key, data)
+					for value, description := range orgB.identifiers {
+						if strings.Contains(request, value) ||
+							slices.Contains(organizationListRoutes, route.pattern) && orgB.isOrganization(value) {
+							continue
+						}
+						if strings.Contains(response, value) {
+							t.Errorf("%v %v responded with %v of organization B (status %v)",
+								route.method, path, description, status)
+						}
+					}
+
+					if after, err := snapshot(internalctx.WithDb(ctx, tx)); err != nil {
+						t.Errorf("%v %v left the database in an unusable state (status %v): %v",
+							route.method, path, status, err)
+					} else {
+						for table, rows := range after {
+							if before[table] != rows {
+								t.Errorf("%v %v modified rows of organization B in table %v (status %v)",
+									route.method, path, table, status)
+							}
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// requestDb hides that the database of a request is a transaction, so that db.RunTx starts a nested transaction and
+// failed requests are rolled back like they are in production.
+type requestDb struct{ queryable.Queryable }
+
+func newRouter(db queryable.Queryable) http.Handler {
+	return routing.ApiRouter(
+		zap.NewNop(),
+		db,
+		noop.New(),
+		trace.NewTracerProvider(),
+		maintenance.NewWatcher(nil, zap.NewNop()),
+	)
+}
+
+type apiRoute struct {
+	method  string
+	pattern string
+}
+
+func apiRoutes(t *testing.T) []apiRoute {
+	var routes []apiRoute
+	err := chi.Walk(
+		newRouter(nil).(chi.Routes),
+		func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			if !strings.Contains(route, "*") && !slices.ContainsFunc(skippedRoutePrefixes, func(prefix string) bool {
+				return strings.HasPrefix(route, prefix)
+			}) {
+				routes = append(routes, apiRoute{method: method, pattern: route})
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(routes, func(a, b apiRoute) int {
+		return strings.Compare(a.pattern+" "+a.method, b.pattern+" "+b.method)
+	})
+	return routes
+}
+
+// serve sends a request to a new router, so that rate limits do not apply across requests.
+func serve(t *testing.T, db queryable.Queryable, method, path, key string, body []byte) (status int, response string) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("%v %v panicked: %v", method, path, r)
+		}
+	}()
+	request := httptest.NewRequest(method, path, bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "AccessToken "+key)
+	recorder := httptest.NewRecorder()
+	newRouter(db).ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.String()
+}
+
+// tenant is an organization with one resource of every kind. All tenants use the same names and contents, so that
+// only the identifiers distinguish them.
+type tenant struct {
+	*testutil.OrganizationWithUsers
+	label string
+	// params contains a value for every path parameter of the API
+	params map[string]string
+	// identifiers maps every value that identifies a resource of the tenant to a description of it
+	identifiers map[string]string
+}
+
+func seedTenant(
+	ctx context.Context,
+	t *testing.T,
+	label string,
+	sharedVendor, sharedCustomer *types.UserAccount,
+) *tenant {
+	t.Helper()
+	org := testutil.NewOrganizationWithUsers(ctx, t, 1, 1)
+	tn := tenant{
+		OrganizationWithUsers: org,
+		label:                 label,
+		params: map[string]string{
+			"aggregateName": string(types.AggregateStorageUsage),
+			"channel":       "stable",
+			"reference":     "latest",
+			"tutorial":      string(types.TutorialBranding),
+			"type":          string(types.MailTypeInviteUser),
+			"ruleId":        uuid.NewString(),
+		},
+		identifiers: map[string]string{},
+	}
+	vendor, customer := org.Vendors[0], org.Customers[0]
+	must(t, db.CreateUserAccountOrganizationAssignment(ctx, sharedVendor.ID, org.ID, types.UserRoleVendor))
+	must(t, db.CreateUserAccountOrganizationAssignment(ctx, sharedCustomer.ID, org.ID, types.UserRoleCustomer))
+	_, err := internalctx.GetDb(ctx).Exec(ctx,
+		"UPDATE Organization SET features = ARRAY['licensing']::FEATURE[] WHERE id = @id",
+		pgx.NamedArgs{"id": org.ID},
+	)
+	must(t, err)
+	tn.add("organization id", org.ID)
+	tn.identifiers[org.Name] = "organization name"
+	tn.add("vendor id", vendor.ID)
+	tn.identifiers[vendor.Email] = "vendor email"
+	tn.add("customer id", customer.ID)
+	tn.identifiers[customer.Email] = "customer email"
+	tn.params["userId"] = customer.ID.String()
+	tn.params["userAccountId"] = customer.ID.String()
+	secret := "secret of organization " + org.ID.String()
+	tn.identifiers[secret] = "secret file content"
+
+	app := types.Application{Name: "isolation-app", Type: types.DeploymentTypeDocker}
+	must(t, db.CreateApplication(ctx, &app, org.ID))
+	tn.param("applicationId", "application id", app.ID)
+	version := types.ApplicationVersion{
+		Name:            "1.0.0",
+		ApplicationID:   app.ID,
+		ComposeFileData: []byte("services:\n  app:\n    image: nginx\n# " + secret + "\n"),
+	}
+	must(t, db.CreateApplicationVersion(ctx, &version))
+	tn.param("applicationVersionId", "application version id", version.ID)
+
+	dt := testutil.NewDeploymentTarget(ctx, t, org.ID, customer.ID, func(dt *types.DeploymentTargetWithCreatedBy) {
+		dt.Name = "isolation-target"
+	})
+	tn.param("deploymentTargetId", "deployment target id", dt.ID)
+	request := api.DeploymentRequest{
+		DeploymentTargetID:   dt.ID,
+		ApplicationVersionID: version.ID,
+		DockerType:           util.PtrTo(types.DockerTypeCompose),
+	}
+	must(t, db.CreateDeployment(ctx, &request))
+	tn.param("deploymentId", "deployment id", *request.DeploymentID)
+	revision, err := db.CreateDeploymentRevision(ctx, &request)
+	must(t, err)
+	tn.param("revisionId", "deployment revision id", revision.ID)
+	endpoint := types.DeploymentTargetEndpoint{DeploymentTargetID: dt.ID, URL: "https://isolation.example.com"}
+	must(t, db.CreateDeploymentTargetEndpoint(ctx, &endpoint))
+	tn.param("endpointId", "deployment target endpoint id", endpoint.ID)
+	grant := types.AccessGrant{
+		OrganizationID:     org.ID,
+		UserAccountID:      vendor.ID,
+		DeploymentTargetID: &dt.ID,
+		Reason:             "isolation",
+		ExpiresAt:          time.Now().Add(time.Hour),
+	}
+	must(t, db.CreateAccessGrant(ctx, &grant))
+	tn.param("accessGrantId", "access grant id", grant.ID)
+
+	// the artifacts of both tenants have the same name and consist of the same manifests
+	artifact := types.Artifact{Name: "isolation/artifact", OrganizationID: org.ID}
+	must(t, db.CreateArtifact(ctx, &artifact))
+	tn.param("artifactId", "artifact id", artifact.ID)
+	child := tn.newArtifactVersion(ctx, t, artifact.ID, vendor.ID, "", "child", "manifest")
+	index := tn.newArtifactVersion(ctx, t, artifact.ID, vendor.ID, "", "index", "index")
+	tn.newArtifactVersion(ctx, t, artifact.ID, vendor.ID, "latest", "index", "index")
+	must(t, db.CreateArtifactVersionPart(ctx, &types.ArtifactVersionPart{
+		ArtifactVersionID:  index.ID,
+		ArtifactBlobDigest: child.ManifestBlobDigest,
+		ArtifactBlobSize:   child.ManifestBlobSize,
+	}))
+	must(t, db.CreateArtifactPullLogEntry(ctx, child.ID, &customer.ID, nil, "192.0.2.1", http.MethodGet, ""))
+
+	applicationLicense := types.ApplicationLicenseBase{
+		Name:               "isolation-license",
+		ApplicationID:      app.ID,
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &sharedCustomer.ID,
+	}
+	must(t, db.CreateApplicationLicense(ctx, &applicationLicense))
+	tn.param("applicationLicenseId", "application license id", applicationLicense.ID)
+	artifactLicense := types.ArtifactLicenseBase{
+		Name:               "isolation-license",
+		OrganizationID:     org.ID,
+		OwnerUserAccountID: &sharedCustomer.ID,
+	}
+	must(t, db.CreateArtifactLicense(ctx, &artifactLicense))
+	must(t, db.AddArtifactToArtifactLicense(ctx, artifactLicense.ID, artifact.ID, nil))
+	tn.param("artifactLicenseId", "artifact license id", artifactLicense.ID)
+
+	file := types.File{ContentType: "text/plain", Data: []byte(secret), FileName: "isolation.txt"}
+	file.FileSize = int64(len(file.Data))
+	must(t, db.CreateFile(ctx, &org.ID, &file))
+	tn.param("fileId", "file id", file.ID)
+	field := types.CustomFieldDefinition{
+		OrganizationID: org.ID,
+		Target:         types.CustomFieldTargetCustomer,
+		Key:            "tier",
+		Type:           types.CustomFieldTypeString,
+	}
+	must(t, db.CreateCustomFieldDefinition(ctx, &field))
+	tn.param("customFieldId", "custom field id", field.ID)
+	watch := types.UpstreamWatch{
+		OrganizationID:         org.ID,
+		CreatedByUserAccountID: &vendor.ID,
+		Reference:              "library/nginx",
+		Registry:               "docker.io",
+	}
+	must(t, db.CreateUpstreamWatch(ctx, &watch))
+	tn.param("upstreamWatchId", "upstream watch id", watch.ID)
+	token, _ := testutil.NewAccessToken(ctx, t, vendor.ID, org.ID)
+	tn.param("id", "access token id", token.ID)
+	return &tn
+}
+
+func (tn *tenant) add(description string, id uuid.UUID) {
+	tn.identifiers[id.String()] = description
+}
+
+func (tn *tenant) param(name, description string, id uuid.UUID) {
+	tn.params[name] = id.String()
+	tn.add(description, id)
+}
+
+func (tn *tenant) newArtifactVersion(
+	ctx context.Context,
+	t *testing.T,
+	artifactID, createdByID uuid.UUID,
+	name, content, kind string,
+) types.ArtifactVersion {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	digest := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])}
+	if name == "" {
+		name = digest.String()
+	}
+	version := types.ArtifactVersion{
+		Name:                   name,
+		CreatedByUserAccountID: &createdByID,
+		ManifestBlobDigest:     types.Digest(digest),
+		ManifestBlobSize:       512,
+		ManifestContentType:    "application/vnd.oci.image." + kind + ".v1+json",
+		ArtifactID:             artifactID,
+	}
+	must(t, db.CreateArtifactVersion(ctx, &version))
+	tn.add("artifact version id", version.ID)
+	return version
+}
+
+func (tn *tenant) isOrganization(value string) bool {
+	return value == tn.ID.String() || value == tn.Name
+}
+
+// resolve replaces all path parameters of pattern with resources of the tenant.
+func (tn *tenant) resolve(t *testing.T, pattern string) string {
+	t.Helper()
+	return pathParamPattern.ReplaceAllStringFunc(pattern, func(param string) string {
+		name := pathParamPattern.FindStringSubmatch(param)[1]
+		if value, ok := tn.params[name]; ok {
+			return value
+		}
+		t.Fatalf("path parameter %v of %v is unknown, please add a value for it to seedTenant", name, pattern)
+		return ""
+	})
+}
+
+// requestBody returns a body that references resources of the tenant in all fields that are commonly used for
+// references. The id field refers to the same kind of resource as the last path parameter of pattern.
+func (tn *tenant) requestBody(pattern string) map[string]any {
+	body := map[string]any{
+		"name":                  "isolation",
+		"applicationId":         tn.params["applicationId"],
+		"applicationVersionId":  tn.params["applicationVersionId"],
+		"applicationLicenseId":  tn.params["applicationLicenseId"],
+		"deploymentTargetId":    tn.params["deploymentTargetId"],
+		"deploymentId":          tn.params["deploymentId"],
+		"artifactId":            tn.params["artifactId"],
+		"userAccountId":         tn.params["userAccountId"],
+		"customerUserAccountId": tn.params["userAccountId"],
+		"ownerUserAccountId":    tn.params["userAccountId"],
+		"imageId":               tn.params["fileId"],
+		"versions":              []map[string]string{{"id": tn.params["applicationVersionId"]}},
+		"artifacts": []map[string]any{{
+			"artifactId": tn.params["artifactId"],
+			"versionIds": []string{},
+		}},
+	}
+	if params := pathParamPattern.FindAllStringSubmatch(pattern, -1); len(params) > 0 {
+		body["id"] = tn.params[params[len(params)-1][1]]
+	}
+	return body
+}
+
+func newAccessKey(ctx context.Context, t *testing.T, userID, orgID uuid.UUID) string {
+	t.Helper()
+	_, key := testutil.NewAccessToken(ctx, t, userID, orgID)
+	return key
+}
+
+// newRowSnapshot returns a function that computes a checksum of all rows of every table that contain one of the
+// given identifiers.
+func newRowSnapshot(
+	ctx context.Context,
+	t *testing.T,
+	identifiers map[string]string,
+) func(ctx context.Context) (map[string]string, error) {
+	t.Helper()
+	rows, err := internalctx.GetDb(ctx).Query(ctx,
+		`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+		ORDER BY table_name`,
+	)
+	must(t, err)
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	must(t, err)
+	queries := make([]string, len(tables))
+	for i, table := range tables {
+		queries[i] = fmt.Sprintf(
+			`SELECT '%v', count(*) || ':' || coalesce(md5(string_agg(t::text, ',' ORDER BY t::text)), '')
+			FROM %v t WHERE t::text LIKE ANY(@patterns)`,
+			table, pgx.Identifier{table}.Sanitize(),
+		)
+	}
+	query := strings.Join(queries, " UNION ALL ")
+	patterns := make([]string, 0, len(identifiers))
+	for value := range identifiers {
+		patterns = append(patterns, "%"+value+"%")
+	}
+	return func(ctx context.Context) (map[string]string, error) {
+		rows, err := internalctx.GetDb(ctx).Query(ctx, query, pgx.NamedArgs{"patterns": patterns})
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		result := map[string]string{}
+		for rows.Next() {
+			var table, checksum string
+			if err := rows.Scan(&table, &checksum); err != nil {
+				return nil, err
+			}
+			result[table] = checksum
+		}
+		return result, rows.Err()
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
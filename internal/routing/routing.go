@@ -4,11 +4,17 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/glasskube/distr/internal/apiversion"
 	"github.com/glasskube/distr/internal/auth"
+	"github.com/glasskube/distr/internal/contenttype"
+	"github.com/glasskube/distr/internal/db/queryable"
+	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/frontend"
 	"github.com/glasskube/distr/internal/handlers"
 	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/maintenance"
 	"github.com/glasskube/distr/internal/middleware"
+	"github.com/glasskube/distr/internal/selfcheck"
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/httprate"
@@ -18,21 +24,35 @@ import (
 	"go.uber.org/zap"
 )
 
-func NewRouter(logger *zap.Logger, db *pgxpool.Pool, mailer mail.Mailer, tracer *trace.TracerProvider) http.Handler {
+func NewRouter(
+	logger *zap.Logger,
+	db *pgxpool.Pool,
+	mailer mail.Mailer,
+	tracer *trace.TracerProvider,
+	maintenanceWatcher *maintenance.Watcher,
+	selfCheck *selfcheck.Checker,
+) http.Handler {
 	router := chi.NewRouter()
 	router.Use(
 		// Handles panics
 		chimiddleware.Recoverer,
-		// Reject bodies larger than 1MiB
-		chimiddleware.RequestSize(1048576),
+		// Routes that accept file uploads override this limit
+		middleware.RequestBodyLimit(env.RequestBodyMaxSize()),
+		middleware.RejectContentEncoding,
 	)
-	router.Mount("/api", ApiRouter(logger, db, mailer, tracer))
-	router.Mount("/internal", InternalRouter())
+	router.Mount("/api", ApiRouter(logger, db, mailer, tracer, maintenanceWatcher))
+	router.Mount("/internal", InternalRouter(maintenanceWatcher, selfCheck))
 	router.Mount("/", FrontendRouter())
 	return router
 }
 
-func ApiRouter(logger *zap.Logger, db *pgxpool.Pool, mailer mail.Mailer, tracer *trace.TracerProvider) http.Handler {
+func ApiRouter(
+	logger *zap.Logger,
+	db queryable.Queryable,
+	mailer mail.Mailer,
+	tracer *trace.TracerProvider,
+	maintenanceWatcher *maintenance.Watcher,
+) http.Handler {
 	r := chi.NewRouter()
 	r.Use(
 		chimiddleware.RequestID,
@@ -42,58 +62,102 @@ func ApiRouter(logger *zap.Logger, db *pgxpool.Pool, mailer mail.Mailer, tracer
 		middleware.LoggerCtxMiddleware(logger),
 		middleware.LoggingMiddleware,
 		middleware.ContextInjectorMiddleware(db, mailer),
+		middleware.MaintenanceCtxMiddleware(maintenanceWatcher),
 	)
 
+	// the rate limits are shared by all API versions
+	authenticated := []func(http.Handler) http.Handler{
+		middleware.SentryUser,
+		auth.Authentication.Middleware,
+		httprate.Limit(30, 1*time.Second, httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc)),
+		httprate.Limit(60, 1*time.Minute, httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc)),
+		httprate.Limit(2000, 1*time.Hour, httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc)),
+		apiversion.Usage,
+
+		// TODO (low-prio) in the future, additionally check token audience and require it to be "api"/"user",
+		// such that agents cant access anything here (they also can't now, because their tokens will not
+		// pass the Authentication chain (DbAuthenticator can't find the user -> 401)
+	}
+
 	r.Route("/v1", func(r chi.Router) {
-		// public routes go here
-		r.Group(func(r chi.Router) {
-			r.Route("/auth", handlers.AuthRouter)
-		})
+		r.Use(apiversion.Middleware(apiversion.V1))
 
-		// authenticated routes go here
+		// webhooks of external providers go here, they are called with the content type chosen by the provider
+		r.Route("/webhooks", handlers.WebhooksRouter)
+
+		// Routes that accept file uploads additionally require multipart/form-data, all other routes use JsonBody
 		r.Group(func(r chi.Router) {
-			r.Use(
-				middleware.SentryUser,
-				auth.Authentication.Middleware,
-				httprate.Limit(30, 1*time.Second, httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc)),
-				httprate.Limit(60, 1*time.Minute, httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc)),
-				httprate.Limit(2000, 1*time.Hour, httprate.WithKeyFuncs(middleware.RateLimitCurrentUserIdKeyFunc)),
-
-				// TODO (low-prio) in the future, additionally check token audience and require it to be "api"/"user",
-				// such that agents cant access anything here (they also can't now, because their tokens will not
-				// pass the Authentication chain (DbAuthenticator can't find the user -> 401)
-			)
-			r.Route("/applications", handlers.ApplicationsRouter)
-			r.Route("/application-licenses", handlers.ApplicationLicensesRouter)
-			r.Route("/agent-versions", handlers.AgentVersionsRouter)
-			r.Route("/artifacts", handlers.ArtifactsRouter)
-			r.Route("/artifact-licenses", handlers.ArtifactLicensesRouter)
-			r.Route("/artifact-pulls", handlers.ArtifactPullsRouter)
-			r.Route("/context", handlers.ContextRouter)
-			r.Route("/dashboard", handlers.DashboardRouter)
-			r.Route("/deployments", handlers.DeploymentsRouter)
-			r.Route("/deployment-targets", handlers.DeploymentTargetsRouter)
-			r.Route("/deployment-target-metrics", handlers.DeploymentTargetMetricsRouter)
-			r.Route("/files", handlers.FileRouter)
-			r.Route("/organization", handlers.OrganizationRouter)
-			r.Route("/organizations", handlers.OrganizationsRouter)
-			r.Route("/settings", handlers.SettingsRouter)
-			r.Route("/user-accounts", handlers.UserAccountsRouter)
-			r.Route("/tutorial-progress", handlers.TutorialsRouter)
+			r.Use(middleware.RequireContentType(contenttype.MediaTypeJSON, contenttype.MediaTypeMultipartForm))
+			// public routes go here
+			r.Group(func(r chi.Router) {
+				r.Route("/auth", handlers.AuthRouter)
+				r.Route("/badges", handlers.BadgesRouter)
+				r.Route("/data-exports", handlers.DataExportsRouter)
+			})
+
+			// authenticated routes go here
+			r.Group(func(r chi.Router) {
+				r.Use(authenticated...)
+				r.Route("/maintenance", handlers.MaintenanceRouter)
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.ReadOnlyDuringMaintenance)
+					r.Route("/access-grants", handlers.AccessGrantsRouter)
+					r.Route("/announcements", handlers.AnnouncementsRouter)
+					r.Route("/applications", handlers.ApplicationsRouter)
+					r.Route("/application-licenses", handlers.ApplicationLicensesRouter)
+					r.Route("/agent-versions", handlers.AgentVersionsRouter)
+					r.Route("/artifacts", handlers.ArtifactsRouter)
+					r.Route("/artifact-licenses", handlers.ArtifactLicensesRouter)
+					r.Route("/artifact-pulls", handlers.ArtifactPullsRouter)
+					r.Route("/context", handlers.ContextRouter)
+					r.Route("/custom-fields", handlers.CustomFieldsRouter)
+					r.Route("/dashboard", handlers.DashboardRouter)
+					r.Route("/deployments", handlers.DeploymentsRouter)
+					r.Route("/deployment-acknowledgments", handlers.DeploymentAcknowledgmentsRouter)
+					r.Route("/deployment-targets", handlers.DeploymentTargetsRouter)
+					r.Route("/deployment-target-metrics", handlers.DeploymentTargetMetricsRouter)
+					r.Route("/files", handlers.FileRouter)
+					r.Route("/organization", handlers.OrganizationRouter)
+					r.Route("/organizations", handlers.OrganizationsRouter)
+					r.Route("/secret-scan", handlers.SecretScanRouter)
+					r.Route("/settings", handlers.SettingsRouter)
+					r.Route("/user-accounts", handlers.UserAccountsRouter)
+					r.Route("/tutorial-progress", handlers.TutorialsRouter)
+					r.Route("/upstream-watches", handlers.UpstreamWatchesRouter)
+					r.Route("/admin", handlers.AdminRouter)
+				})
+			})
+
+			// agent connect and download routes go here (authenticated but with accessKeyId and accessKeySecret)
+			r.Group(func(r chi.Router) {
+				r.Route("/", handlers.AgentRouter)
+			})
 		})
+	})
 
-		// agent connect and download routes go here (authenticated but with accessKeyId and accessKeySecret)
+	// v2 shares the handlers of v1 and only contains routes whose response differs
+	r.Route("/v2", func(r chi.Router) {
+		r.Use(
+			apiversion.Middleware(apiversion.V2),
+			middleware.RequireContentType(contenttype.MediaTypeJSON),
+		)
+		r.Get("/openapi.json", apiversion.OpenAPIHandler(apiversion.V2))
 		r.Group(func(r chi.Router) {
-			r.Route("/", handlers.AgentRouter)
+			r.Use(authenticated...)
+			r.Use(middleware.ReadOnlyDuringMaintenance)
+			r.Route("/deployments", handlers.DeploymentsV2Router)
+			r.Route("/deployment-targets", handlers.DeploymentTargetsV2Router)
+			r.Route("/user-accounts", handlers.UserAccountsV2Router)
 		})
 	})
 
 	return r
 }
 
-func InternalRouter() http.Handler {
+func InternalRouter(maintenanceWatcher *maintenance.Watcher, selfCheck *selfcheck.Checker) http.Handler {
 	router := chi.NewRouter()
-	router.Route("/", handlers.InternalRouter)
+	router.Use(middleware.MaintenanceCtxMiddleware(maintenanceWatcher))
+	router.Route("/", handlers.InternalRouter(selfCheck))
 	return router
 }
 
@@ -0,0 +1,17 @@
+package scrub
+
+import "github.com/glasskube/distr/internal/env"
+
+// FromEnv creates a Scrubber using the configuration from the environment, falling back to the defaults.
+// env.Initialize must be called before.
+func FromEnv() *Scrubber {
+	fieldPatterns := env.ScrubFieldPatterns()
+	if fieldPatterns == nil {
+		fieldPatterns = DefaultFieldPatterns
+	}
+	headerAllowList := env.SentryRequestHeadersAllowList()
+	if headerAllowList == nil {
+		headerAllowList = DefaultHeaderAllowList
+	}
+	return New(fieldPatterns, env.ScrubEmailHMACKey(), headerAllowList)
+}
@@ -0,0 +1,180 @@
+// Package scrub removes secrets and personal data from Sentry events and log entries before they leave the process.
+package scrub
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const Redacted = "[redacted]"
+
+var (
+	DefaultFieldPatterns = []string{
+		"password", "passwd", "token", "secret", "authorization", "cookie", "apikey", "api_key", "api-key",
+		"accesskey", "access_key", "credential",
+	}
+	DefaultHeaderAllowList = []string{
+		"Accept", "Accept-Encoding", "Accept-Language", "Content-Length", "Content-Type", "Host", "Origin", "Referer",
+		"User-Agent", "X-Request-Id",
+	}
+)
+
+var (
+	emailRegex       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	authHeaderRegex  = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=\-]+`)
+	jwtRegex         = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	accessKeyRegex   = regexp.MustCompile(`\bdistr-[0-9a-f]{16,}\b`)
+	queryParamRegex  = regexp.MustCompile(`([?&;])([^=&;#\s]+)=([^&;#\s]*)`)
+	userInfoURLRegex = regexp.MustCompile(`(://)[^/@\s:]+:[^/@\s]+@`)
+)
+
+type Scrubber struct {
+	fieldPatterns   []string
+	emailHMACKey    []byte
+	headerAllowList []string
+}
+
+// New creates a Scrubber.
+//
+// Fields whose names contain one of fieldPatterns (case-insensitive) are replaced with Redacted.
+// Email addresses are replaced with a pseudonym derived via HMAC if emailHMACKey is not empty, otherwise they are
+// redacted as well.
+// Only request headers contained in headerAllowList are sent to Sentry.
+func New(fieldPatterns []string, emailHMACKey []byte, headerAllowList []string) *Scrubber {
+	s := Scrubber{emailHMACKey: emailHMACKey}
+	for _, p := range fieldPatterns {
+		s.fieldPatterns = append(s.fieldPatterns, strings.ToLower(p))
+	}
+	for _, h := range headerAllowList {
+		s.headerAllowList = append(s.headerAllowList, http.CanonicalHeaderKey(h))
+	}
+	return &s
+}
+
+// IsSensitiveField returns true if a field with this name must never be sent or logged.
+func (s *Scrubber) IsSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	return slices.ContainsFunc(s.fieldPatterns, func(p string) bool { return strings.Contains(name, p) })
+}
+
+// String removes credentials, tokens and email addresses from value.
+func (s *Scrubber) String(value string) string {
+	value = userInfoURLRegex.ReplaceAllString(value, "${1}"+Redacted+"@")
+	value = queryParamRegex.ReplaceAllStringFunc(value, func(param string) string {
+		match := queryParamRegex.FindStringSubmatch(param)
+		if s.IsSensitiveField(match[2]) {
+			return match[1] + match[2] + "=" + Redacted
+		}
+		return param
+	})
+	value = authHeaderRegex.ReplaceAllString(value, "$1 "+Redacted)
+	value = jwtRegex.ReplaceAllString(value, Redacted)
+	value = accessKeyRegex.ReplaceAllString(value, Redacted)
+	value = emailRegex.ReplaceAllStringFunc(value, s.Email)
+	return value
+}
+
+// Email returns a pseudonym for the given email address or Redacted if no HMAC key is configured.
+// The same address always results in the same pseudonym, so that events of the same user can still be correlated.
+func (s *Scrubber) Email(email string) string {
+	if email == "" {
+		return ""
+	} else if len(s.emailHMACKey) == 0 {
+		return Redacted
+	}
+	mac := hmac.New(sha256.New, s.emailHMACKey)
+	mac.Write([]byte(strings.ToLower(email)))
+	return "email-" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// Value recursively scrubs maps, slices and strings.
+func (s *Scrubber) Value(value any) any {
+	switch v := value.(type) {
+	case string:
+		return s.String(v)
+	case map[string]any:
+		return s.Map(v)
+	case map[string]string:
+		result := make(map[string]string, len(v))
+		for key, value := range v {
+			if s.IsSensitiveField(key) {
+				result[key] = Redacted
+			} else {
+				result[key] = s.String(value)
+			}
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, value := range v {
+			result[i] = s.Value(value)
+		}
+		return result
+	case []string:
+		result := make([]string, len(v))
+		for i, value := range v {
+			result[i] = s.String(value)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// Map returns a scrubbed copy of m.
+func (s *Scrubber) Map(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]any, len(m))
+	for key, value := range m {
+		if s.IsSensitiveField(key) {
+			result[key] = Redacted
+		} else {
+			result[key] = s.Value(value)
+		}
+	}
+	return result
+}
+
+// JSON scrubs a JSON document. Data that is not valid JSON is redacted completely, because it can not be inspected.
+// Numbers are kept as they are instead of being converted to float64, which would change large integers.
+func (s *Scrubber) JSON(data string) string {
+	if data == "" {
+		return ""
+	}
+	var parsed any
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&parsed); err != nil {
+		return Redacted
+	} else if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return Redacted
+	} else if result, err := json.Marshal(s.Value(parsed)); err != nil {
+		return Redacted
+	} else {
+		return string(result)
+	}
+}
+
+// Headers only keeps allow-listed headers.
+func (s *Scrubber) Headers(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	result := make(map[string]string)
+	for key, value := range headers {
+		if slices.Contains(s.headerAllowList, http.CanonicalHeaderKey(key)) {
+			result[key] = s.String(value)
+		}
+	}
+	return result
+}
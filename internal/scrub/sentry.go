@@ -0,0 +1,57 @@
+package scrub
+
+import (
+	"github.com/getsentry/sentry-go"
+)
+
+// SentryEvent can be used as sentry.ClientOptions.BeforeSend and BeforeSendTransaction.
+func (s *Scrubber) SentryEvent(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+	if event == nil {
+		return nil
+	}
+	event.Message = s.String(event.Message)
+	event.Transaction = s.String(event.Transaction)
+	for i := range event.Exception {
+		event.Exception[i].Value = s.String(event.Exception[i].Value)
+	}
+	for i, breadcrumb := range event.Breadcrumbs {
+		event.Breadcrumbs[i] = s.SentryBreadcrumb(breadcrumb, nil)
+	}
+	event.Extra = s.Map(event.Extra)
+	for key, value := range event.Tags {
+		if s.IsSensitiveField(key) {
+			event.Tags[key] = Redacted
+		} else {
+			event.Tags[key] = s.String(value)
+		}
+	}
+	for key, value := range event.Contexts {
+		event.Contexts[key] = s.Map(value)
+	}
+	event.User.Email = s.Email(event.User.Email)
+	event.User.Username = s.String(event.User.Username)
+	event.User.IPAddress = ""
+	if event.Request != nil {
+		event.Request.URL = s.String(event.Request.URL)
+		event.Request.QueryString = s.String("?" + event.Request.QueryString)[1:]
+		event.Request.Cookies = ""
+		event.Request.Headers = s.Headers(event.Request.Headers)
+		event.Request.Data = s.JSON(event.Request.Data)
+		event.Request.Env = nil
+	}
+	for _, span := range event.Spans {
+		span.Description = s.String(span.Description)
+		span.Data = s.Map(span.Data)
+	}
+	return event
+}
+
+// SentryBreadcrumb can be used as sentry.ClientOptions.BeforeBreadcrumb.
+func (s *Scrubber) SentryBreadcrumb(breadcrumb *sentry.Breadcrumb, hint *sentry.BreadcrumbHint) *sentry.Breadcrumb {
+	if breadcrumb == nil {
+		return nil
+	}
+	breadcrumb.Message = s.String(breadcrumb.Message)
+	breadcrumb.Data = s.Map(breadcrumb.Data)
+	return breadcrumb
+}
@@ -0,0 +1,68 @@
+package scrub
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type zapCore struct {
+	zapcore.Core
+	scrubber *Scrubber
+}
+
+// ZapCore wraps core so that all log messages and fields are scrubbed before they are written.
+// It can be used with zap.WrapCore.
+func (s *Scrubber) ZapCore(core zapcore.Core) zapcore.Core {
+	return &zapCore{Core: core, scrubber: s}
+}
+
+func (c *zapCore) With(fields []zapcore.Field) zapcore.Core {
+	return &zapCore{Core: c.Core.With(c.scrubber.zapFields(fields)), scrubber: c.scrubber}
+}
+
+func (c *zapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *zapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.scrubber.String(entry.Message)
+	return c.Core.Write(entry, c.scrubber.zapFields(fields))
+}
+
+func (s *Scrubber) zapFields(fields []zapcore.Field) []zapcore.Field {
+	result := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		result[i] = s.zapField(field)
+	}
+	return result
+}
+
+func (s *Scrubber) zapField(field zapcore.Field) zapcore.Field {
+	if s.IsSensitiveField(field.Key) {
+		return zap.String(field.Key, Redacted)
+	}
+	switch field.Type {
+	case zapcore.StringType:
+		return zap.String(field.Key, s.String(field.String))
+	case zapcore.ByteStringType:
+		if b, ok := field.Interface.([]byte); ok {
+			return zap.String(field.Key, s.String(string(b)))
+		}
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			return zap.String(field.Key, s.String(err.Error()))
+		}
+	case zapcore.StringerType:
+		if stringer, ok := field.Interface.(fmt.Stringer); ok {
+			return zap.String(field.Key, s.String(stringer.String()))
+		}
+	case zapcore.ReflectType:
+		return zap.Any(field.Key, s.Value(field.Interface))
+	}
+	return field
+}
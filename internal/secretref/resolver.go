@@ -0,0 +1,354 @@
+package secretref
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/glasskube/distr/internal/agentenv"
+	"github.com/glasskube/distr/internal/types"
+)
+
+// maxResponseSize limits the responses of secret stores. Secrets Manager allows secrets of up to 64 KiB.
+const maxResponseSize = 1 << 20
+
+// ResolutionError is returned if a secret reference can not be resolved. Its message never contains secret values.
+type ResolutionError struct {
+	Reference string
+	Err       error
+}
+
+func (err *ResolutionError) Error() string {
+	return fmt.Sprintf("could not resolve secret reference %v: %v", err.Reference, err.Err)
+}
+
+func (err *ResolutionError) Unwrap() error {
+	return err.Err
+}
+
+// DeploymentStatusErrorReason is the error reason that the agent reports for a ResolutionError.
+func (err *ResolutionError) DeploymentStatusErrorReason() types.DeploymentStatusErrorReason {
+	return types.DeploymentStatusErrorReasonSecretResolutionFailed
+}
+
+type Config struct {
+	VaultAddress string
+	VaultToken   string
+	// VaultTokenFile is read for every request if VaultToken is empty, so that the token can be rotated.
+	VaultTokenFile string
+	VaultNamespace string
+	// AWSRegion is the region of secrets that are referenced by name. Secrets that are referenced by ARN are always
+	// requested from the region in the ARN. If it is empty, the region of the default AWS configuration is used.
+	AWSRegion string
+	// AWSEndpoint replaces the public endpoint of AWS Secrets Manager, e.g. with a VPC endpoint.
+	AWSEndpoint string
+	// AWSCredentials sign the requests to AWS Secrets Manager. If it is nil, the default credential chain is used.
+	AWSCredentials aws.CredentialsProvider
+	HTTPClient     *http.Client
+}
+
+type Resolver struct {
+	config        Config
+	awsConfigOnce sync.Once
+	awsConfig     aws.Config
+	awsConfigErr  error
+}
+
+func NewResolver(config Config) *Resolver {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Resolver{config: config}
+}
+
+// NewResolverFromEnv returns a resolver that uses the secret store settings of the agent environment.
+func NewResolverFromEnv() *Resolver {
+	return NewResolver(Config{
+		VaultAddress:   agentenv.VaultAddress,
+		VaultToken:     agentenv.VaultToken,
+		VaultTokenFile: agentenv.VaultTokenFile,
+		VaultNamespace: agentenv.VaultNamespace,
+		AWSRegion:      agentenv.AWSSecretsManagerRegion,
+		AWSEndpoint:    agentenv.AWSSecretsManagerEndpoint,
+	})
+}
+
+// Resolve returns the secret value of ref. All errors are of type *ResolutionError.
+func (r *Resolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	var value string
+	var err error
+	switch ref.Scheme {
+	case SchemeVault:
+		value, err = r.resolveVault(ctx, ref)
+	case SchemeAWSSM:
+		value, err = r.resolveAWSSM(ctx, ref)
+	default:
+		err = fmt.Errorf("unsupported secret store %q", ref.Scheme)
+	}
+	if err != nil {
+		return "", &ResolutionError{Reference: ref.String(), Err: err}
+	}
+	return value, nil
+}
+
+// ResolveValues returns a copy of values in which every secret reference is replaced with its secret value.
+// values is not modified.
+func (r *Resolver) ResolveValues(ctx context.Context, values map[string]any) (map[string]any, error) {
+	cache := map[string]string{}
+	var resolve func(value any) (any, error)
+	resolve = func(value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			if IsReference(v) {
+				return r.resolveCached(ctx, cache, v)
+			}
+			return v, nil
+		case map[string]any:
+			result := make(map[string]any, len(v))
+			for key, item := range v {
+				if resolved, err := resolve(item); err != nil {
+					return nil, err
+				} else {
+					result[key] = resolved
+				}
+			}
+			return result, nil
+		case []any:
+			result := make([]any, len(v))
+			for i, item := range v {
+				if resolved, err := resolve(item); err != nil {
+					return nil, err
+				} else {
+					result[i] = resolved
+				}
+			}
+			return result, nil
+		default:
+			return v, nil
+		}
+	}
+	if result, err := resolve(values); err != nil {
+		return nil, err
+	} else {
+		return result.(map[string]any), nil
+	}
+}
+
+// ResolveEnvFile returns a copy of the env file data in which every variable that is a secret reference is
+// overridden with its secret value. The overrides are appended, because the last definition of a variable wins.
+func (r *Resolver) ResolveEnvFile(ctx context.Context, data []byte) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+	env, err := parseEnvFile(data)
+	if err != nil {
+		return nil, err
+	}
+	cache := map[string]string{}
+	var overrides bytes.Buffer
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		if value := env[name]; IsReference(value) {
+			if resolved, err := r.resolveCached(ctx, cache, value); err != nil {
+				return nil, err
+			} else {
+				fmt.Fprintf(&overrides, "%v=\"%v\"\n", name, envFileEscaper.Replace(resolved))
+			}
+		}
+	}
+	if overrides.Len() == 0 {
+		return data, nil
+	}
+	result := slices.Clone(data)
+	if len(result) > 0 && result[len(result)-1] != '\n' {
+		result = append(result, '\n')
+	}
+	return append(result, overrides.Bytes()...), nil
+}
+
+// envFileEscaper escapes a value for a double-quoted env file value, which must not be interpolated.
+var envFileEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+
+func (r *Resolver) resolveCached(ctx context.Context, cache map[string]string, value string) (string, error) {
+	if resolved, ok := cache[value]; ok {
+		return resolved, nil
+	} else if ref, err := Parse(value); err != nil {
+		return "", &ResolutionError{Reference: value, Err: err}
+	} else if resolved, err := r.Resolve(ctx, ref); err != nil {
+		return "", err
+	} else {
+		cache[value] = resolved
+		return resolved, nil
+	}
+}
+
+func (r *Resolver) resolveVault(ctx context.Context, ref Reference) (string, error) {
+	if r.config.VaultAddress == "" {
+		return "", errors.New("vault is not configured for this agent")
+	}
+	token := r.config.VaultToken
+	if token == "" && r.config.VaultTokenFile != "" {
+		if data, err := os.ReadFile(r.config.VaultTokenFile); err != nil {
+			return "", fmt.Errorf("could not read vault token: %w", err)
+		} else {
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	endpoint, err := url.JoinPath(r.config.VaultAddress, "v1", ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("invalid vault address: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if r.config.VaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.config.VaultNamespace)
+	}
+
+	var body struct {
+		Data   map[string]any `json:"data"`
+		Errors []string       `json:"errors"`
+	}
+	if status, err := r.doJSON(req, &body); err != nil {
+		return "", fmt.Errorf("vault request failed: %w", err)
+	} else if status != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %v: %v", status, strings.Join(body.Errors, "; "))
+	}
+	data := body.Data
+	// secrets of the KV version 2 engine are nested in another data object next to their metadata
+	if nested, ok := data["data"].(map[string]any); ok && data["metadata"] != nil {
+		data = nested
+	}
+	return keyOf(data, ref.Key)
+}
+
+func (r *Resolver) resolveAWSSM(ctx context.Context, ref Reference) (string, error) {
+	region, err := awsRegionOf(ref.Path)
+	if err != nil {
+		return "", err
+	} else if region == "" {
+		region = r.config.AWSRegion
+	}
+	credentials := r.config.AWSCredentials
+	if region == "" || credentials == nil {
+		if awsConfig, err := r.loadAWSConfig(ctx); err != nil {
+			return "", fmt.Errorf("could not load AWS configuration: %w", err)
+		} else {
+			region = cmp.Or(region, awsConfig.Region)
+			if credentials == nil {
+				credentials = awsConfig.Credentials
+			}
+		}
+	}
+	if region == "" {
+		return "", errors.New("AWS region is not configured for this agent")
+	} else if credentials == nil {
+		return "", errors.New("AWS credentials are not configured for this agent")
+	}
+	creds, err := credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not retrieve AWS credentials: %w", err)
+	}
+
+	endpoint := cmp.Or(r.config.AWSEndpoint, fmt.Sprintf("https://secretsmanager.%v.amazonaws.com", region))
+	payload, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	payloadHash := sha256.Sum256(payload)
+	if err := v4.NewSigner().SignHTTP(
+		ctx, creds, req, hex.EncodeToString(payloadHash[:]), "secretsmanager", region, time.Now(),
+	); err != nil {
+		return "", fmt.Errorf("could not sign AWS request: %w", err)
+	}
+
+	var body struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+		Type         string  `json:"__type"`
+		Message      string  `json:"message"`
+	}
+	if status, err := r.doJSON(req, &body); err != nil {
+		return "", fmt.Errorf("AWS Secrets Manager request failed: %w", err)
+	} else if status != http.StatusOK {
+		// the error type may be prefixed with a namespace, e.g. "com.amazonaws#ResourceNotFoundException"
+		_, errorType, _ := strings.Cut(body.Type, "#")
+		return "", fmt.Errorf("AWS Secrets Manager returned status %v: %v %v",
+			status, cmp.Or(errorType, body.Type), body.Message)
+	}
+
+	var secret string
+	if body.SecretString != nil {
+		secret = *body.SecretString
+	} else {
+		secret = string(body.SecretBinary)
+	}
+	if ref.Key == "" {
+		return secret, nil
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(secret), &data); err != nil {
+		return "", errors.New("secret is not a JSON object, so it has no keys")
+	}
+	return keyOf(data, ref.Key)
+}
+
+func (r *Resolver) loadAWSConfig(ctx context.Context) (aws.Config, error) {
+	r.awsConfigOnce.Do(func() {
+		r.awsConfig, r.awsConfigErr = awsconfig.LoadDefaultConfig(ctx)
+	})
+	return r.awsConfig, r.awsConfigErr
+}
+
+// doJSON sends req and decodes the response body into v regardless of the response status, which is returned.
+func (r *Resolver) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := r.config.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil &&
+		resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("could not decode response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// keyOf returns the value of key in data. Values that are not strings are returned as JSON.
+func keyOf(data map[string]any, key string) (string, error) {
+	if value, ok := data[key]; !ok {
+		return "", fmt.Errorf("secret has no key %v", key)
+	} else if s, ok := value.(string); ok {
+		return s, nil
+	} else if encoded, err := json.Marshal(value); err != nil {
+		return "", err
+	} else {
+		return string(encoded), nil
+	}
+}
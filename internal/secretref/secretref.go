@@ -0,0 +1,100 @@
+// Package secretref handles references to secrets in external secret stores that can be used instead of the secret
+// itself in deployment values and env files. Distr only ever stores and validates the reference. The agent resolves it
+// with the secret stores of the customer right before the deployment is applied, so the secret never passes Distr.
+//
+// A reference takes up a whole value and has one of these forms:
+//
+//	ref+vault://<path>#<key>       a key of a secret in HashiCorp Vault, e.g. ref+vault://secret/data/app#password
+//	ref+awssm://<secret-id>[#key]  a secret in AWS Secrets Manager, optionally a key of a JSON secret
+package secretref
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const Prefix = "ref+"
+
+type Scheme string
+
+const (
+	SchemeVault Scheme = "vault"
+	SchemeAWSSM Scheme = "awssm"
+)
+
+var ErrInvalidReference = errors.New("invalid secret reference")
+
+type Reference struct {
+	Scheme Scheme
+	// Path is the API path of the secret in Vault or the name or ARN of the secret in AWS Secrets Manager.
+	Path string
+	// Key is the key of the secret value. It is required for Vault and optional for AWS Secrets Manager.
+	Key string
+}
+
+// IsReference reports whether value is meant to be a secret reference. It does not check whether the reference is
+// valid.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Parse returns the reference in value. It returns ErrInvalidReference if value is not a valid reference.
+func Parse(value string) (Reference, error) {
+	scheme, rest, ok := strings.Cut(strings.TrimPrefix(value, Prefix), "://")
+	if !IsReference(value) || !ok {
+		return Reference{}, fmt.Errorf("%w: must have the form %vvault://path#key or %vawssm://id[#key]",
+			ErrInvalidReference, Prefix, Prefix)
+	}
+	path, key, hasKey := strings.Cut(rest, "#")
+	ref := Reference{Scheme: Scheme(scheme), Path: path, Key: key}
+	if strings.ContainsFunc(rest, isInvalidRune) {
+		return ref, fmt.Errorf("%w: must not contain whitespace or control characters", ErrInvalidReference)
+	} else if hasKey && key == "" {
+		return ref, fmt.Errorf("%w: key must not be empty", ErrInvalidReference)
+	}
+	switch ref.Scheme {
+	case SchemeVault:
+		if path == "" || strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") {
+			return ref, fmt.Errorf("%w: vault path must not be empty or start or end with a slash", ErrInvalidReference)
+		} else if strings.Contains(path, "?") || strings.Contains("/"+path+"/", "/../") {
+			return ref, fmt.Errorf("%w: vault path must not contain a query or parent directory", ErrInvalidReference)
+		} else if !hasKey {
+			return ref, fmt.Errorf("%w: vault reference must specify a key after #", ErrInvalidReference)
+		}
+	case SchemeAWSSM:
+		if path == "" {
+			return ref, fmt.Errorf("%w: secret id must not be empty", ErrInvalidReference)
+		} else if _, err := awsRegionOf(path); err != nil {
+			return ref, err
+		}
+	default:
+		return ref, fmt.Errorf("%w: unsupported secret store %q", ErrInvalidReference, scheme)
+	}
+	return ref, nil
+}
+
+func (ref Reference) String() string {
+	s := Prefix + string(ref.Scheme) + "://" + ref.Path
+	if ref.Key != "" {
+		s += "#" + ref.Key
+	}
+	return s
+}
+
+// awsRegionOf returns the region of a secret ARN or an empty string if id is the name of a secret.
+func awsRegionOf(id string) (string, error) {
+	if !strings.HasPrefix(id, "arn:") {
+		return "", nil
+	}
+	// arn:partition:secretsmanager:region:account-id:secret:name
+	parts := strings.SplitN(id, ":", 7)
+	if len(parts) != 7 || parts[2] != "secretsmanager" || parts[3] == "" || parts[5] != "secret" || parts[6] == "" {
+		return "", fmt.Errorf("%w: %v is not the ARN of a secret in AWS Secrets Manager", ErrInvalidReference, id)
+	}
+	return parts[3], nil
+}
+
+func isInvalidRune(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
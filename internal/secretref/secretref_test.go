@@ -0,0 +1,185 @@
+package secretref_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/glasskube/distr/internal/secretref"
+	"github.com/glasskube/distr/internal/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestParse(t *testing.T) {
+	g := NewWithT(t)
+	ref, err := secretref.Parse("ref+vault://secret/data/app#password")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref).To(Equal(secretref.Reference{Scheme: secretref.SchemeVault, Path: "secret/data/app", Key: "password"}))
+	g.Expect(ref.String()).To(Equal("ref+vault://secret/data/app#password"))
+
+	arn := "arn:aws:secretsmanager:eu-central-1:123456789012:secret:app-AbCdEf"
+	ref, err = secretref.Parse("ref+awssm://" + arn)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref).To(Equal(secretref.Reference{Scheme: secretref.SchemeAWSSM, Path: arn}))
+	_, err = secretref.Parse("ref+awssm://app#password")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for _, value := range []string{
+		"ref+vault://secret/data/app",
+		"ref+vault://secret/data/app#",
+		"ref+vault:///secret/data/app#password",
+		"ref+vault://secret/../sys/seal#key",
+		"ref+vault://secret/data/app?version=1#password",
+		"ref+vault://secret/data/my app#password",
+		"ref+awssm://",
+		"ref+awssm://arn:aws:s3:::bucket",
+		"ref+gcpsm://projects/p/secrets/s",
+		"ref+vault:secret/data/app#password",
+		"vault://secret/data/app#password",
+	} {
+		_, err := secretref.Parse(value)
+		g.Expect(err).To(MatchError(secretref.ErrInvalidReference), value)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(secretref.ValidateValues(map[string]any{
+		"image":    "nginx",
+		"password": "ref+vault://secret/data/app#password",
+		"replicas": 2,
+	})).To(Succeed())
+	err := secretref.ValidateValues(map[string]any{
+		"db": map[string]any{"users": []any{map[string]any{"password": "ref+vault://secret/data/app"}}},
+	})
+	g.Expect(err).To(MatchError(secretref.ErrInvalidReference))
+	g.Expect(err).To(MatchError(ContainSubstring("db.users[0].password")))
+
+	g.Expect(secretref.ValidateEnvFile([]byte("IMAGE=nginx\nPASSWORD=\"ref+awssm://app#password\"\n"))).To(Succeed())
+	g.Expect(secretref.ValidateEnvFile([]byte("PASSWORD=ref+awssm://\n"))).
+		To(MatchError(ContainSubstring("PASSWORD")))
+}
+
+func TestResolveVault(t *testing.T) {
+	g := NewWithT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"p@ss$word"},"metadata":{"version":3}}}`))
+		case "/v1/kv/app":
+			_, _ = w.Write([]byte(`{"data":{"password":"kv1","port":5432}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+	resolver := secretref.NewResolver(secretref.Config{
+		VaultAddress:   server.URL,
+		VaultToken:     "s.token",
+		VaultNamespace: "team",
+	})
+
+	values := map[string]any{
+		"image": "nginx",
+		"db": map[string]any{
+			"password": "ref+vault://secret/data/app#password",
+			"hosts":    []any{"ref+vault://kv/app#password", "localhost"},
+			"port":     "ref+vault://kv/app#port",
+		},
+	}
+	resolved, err := resolver.ResolveValues(context.Background(), values)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved).To(Equal(map[string]any{
+		"image": "nginx",
+		"db": map[string]any{
+			"password": "p@ss$word",
+			"hosts":    []any{"kv1", "localhost"},
+			"port":     "5432",
+		},
+	}))
+	g.Expect(values["db"]).To(HaveKeyWithValue("password", "ref+vault://secret/data/app#password"),
+		"the original values are not modified")
+
+	_, err = resolver.ResolveValues(context.Background(), map[string]any{"a": "ref+vault://secret/data/app#user"})
+	g.Expect(err).To(MatchError(ContainSubstring("secret has no key user")))
+	_, err = resolver.ResolveValues(context.Background(), map[string]any{"a": "ref+vault://secret/data/other#key"})
+	g.Expect(err).To(MatchError(ContainSubstring("status 404")))
+
+	var resolutionErr *secretref.ResolutionError
+	g.Expect(err).To(BeAssignableToTypeOf(resolutionErr))
+	g.Expect(err.(*secretref.ResolutionError).DeploymentStatusErrorReason()).
+		To(Equal(types.DeploymentStatusErrorReasonSecretResolutionFailed))
+
+	_, err = secretref.NewResolver(secretref.Config{}).
+		ResolveValues(context.Background(), map[string]any{"a": "ref+vault://secret/data/app#password"})
+	g.Expect(err).To(MatchError(ContainSubstring("vault is not configured")))
+}
+
+func TestResolveAWSSM(t *testing.T) {
+	g := NewWithT(t)
+	arn := "arn:aws:secretsmanager:eu-central-1:123456789012:secret:app-AbCdEf"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ SecretId string }
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-central-1/secretsmanager/aws4_request") ||
+			r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			w.WriteHeader(http.StatusForbidden)
+		} else if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SecretId != arn {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"secret not found"}`))
+		} else {
+			_, _ = w.Write([]byte(`{"ARN":"` + arn + `","SecretString":"{\"user\":\"app\",\"password\":\"a\\\"b\"}"}`))
+		}
+	}))
+	defer server.Close()
+	resolver := secretref.NewResolver(secretref.Config{
+		AWSRegion:      "us-east-1",
+		AWSEndpoint:    server.URL,
+		AWSCredentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	})
+
+	envFile := []byte("IMAGE=nginx\nDB_PASSWORD=ref+awssm://" + arn + "#password\nDB=ref+awssm://" + arn)
+	resolved, err := resolver.ResolveEnvFile(context.Background(), envFile)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(resolved)).To(HavePrefix(string(envFile) + "\n"))
+	env, err := dotenv.UnmarshalBytesWithLookup(resolved, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(env).To(Equal(map[string]string{
+		"IMAGE":       "nginx",
+		"DB_PASSWORD": `a"b`,
+		"DB":          `{"user":"app","password":"a\"b"}`,
+	}))
+
+	unchanged, err := resolver.ResolveEnvFile(context.Background(), []byte("IMAGE=nginx\n"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(unchanged)).To(Equal("IMAGE=nginx\n"))
+
+	_, err = resolver.ResolveEnvFile(context.Background(), []byte("DB_PASSWORD=ref+awssm://app#password"))
+	g.Expect(err).To(MatchError(ContainSubstring("status 403")), "secrets referenced by name use the configured region")
+}
+
+func TestResolveEnvFileEscaping(t *testing.T) {
+	g := NewWithT(t)
+	secret := "line1\nli\\ne2 \"quoted\" $HOME ${USER} 'single'"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"value": secret}})
+	}))
+	defer server.Close()
+	resolver := secretref.NewResolver(secretref.Config{VaultAddress: server.URL})
+
+	resolved, err := resolver.ResolveEnvFile(context.Background(), []byte("SECRET=ref+vault://kv/app#value\n"))
+	g.Expect(err).NotTo(HaveOccurred())
+	env, err := dotenv.UnmarshalBytesWithLookup(resolved, func(string) (string, bool) { return "expanded", true })
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(env).To(HaveKeyWithValue("SECRET", secret))
+}
@@ -0,0 +1,79 @@
+package secretref
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/compose-spec/compose-go/v2/dotenv"
+)
+
+// ValidateValues returns an error that wraps ErrInvalidReference if any string in values, which may be nested, looks
+// like a secret reference but is not a valid one.
+func ValidateValues(values map[string]any) error {
+	return walkValues(values, func(path string, value string) error {
+		if _, err := Parse(value); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// ValidateEnvFile returns an error that wraps ErrInvalidReference if any variable in the env file data looks like a
+// secret reference but is not a valid one.
+func ValidateEnvFile(data []byte) error {
+	env, err := parseEnvFile(data)
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		if value := env[name]; IsReference(value) {
+			if _, err := Parse(value); err != nil {
+				return fmt.Errorf("%v: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func parseEnvFile(data []byte) (map[string]string, error) {
+	if env, err := dotenv.UnmarshalBytesWithLookup(data, nil); err != nil {
+		return nil, fmt.Errorf("invalid env file: %w", err)
+	} else {
+		return env, nil
+	}
+}
+
+// walkValues calls f with the path and value of every string in values that is a secret reference.
+func walkValues(values map[string]any, f func(path string, value string) error) error {
+	var walk func(path string, value any) error
+	walk = func(path string, value any) error {
+		switch v := value.(type) {
+		case string:
+			if IsReference(v) {
+				return f(path, v)
+			}
+		case map[string]any:
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				if err := walk(joinPath(path, key), v[key]); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for i, item := range v {
+				if err := walk(fmt.Sprintf("%v[%v]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk("", values)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,210 @@
+// Package secretscan finds credentials in files before they are stored, similar to gitleaks.
+//
+// Rules are regular expressions. If a rule has a capture group, the first group is the secret, otherwise the whole
+// match. Rules with a minimum entropy only report secrets that look random, which keeps placeholders like
+// "password: changeme" from being reported.
+package secretscan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// AllowPragma suppresses all findings on the line that contains it, e.g. in a comment.
+const AllowPragma = "distr:allow-secret"
+
+// MaxPatternLength is the maximum length of the pattern of a custom rule.
+const MaxPatternLength = 1000
+
+type Rule struct {
+	ID          string
+	Description string
+	Pattern     *regexp.Regexp
+	// MinEntropy is the minimum Shannon entropy in bits per character of the secret. Zero disables the check.
+	MinEntropy float64
+}
+
+// Finding is a secret found in a file. The secret itself is not part of the finding, only a redacted version of it.
+type Finding struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description"`
+	Redacted    string `json:"redacted"`
+	// Fingerprint identifies the secret found by a rule without revealing it. It does not depend on the file or the
+	// line, so that a dismissal applies to all occurrences of the secret.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// templatedValue matches values that are filled in at deployment time, e.g. "${DB_PASSWORD}",
+// "{{ .Values.password }}" or the secret reference "ref+vault://secret/data/db#password". They are never secrets
+// themselves.
+var templatedValue = regexp.MustCompile(`^(\$\{?[A-Za-z_][A-Za-z0-9_]*|\{\{.*\}\}?|<[^>]*>?|ref\+[a-z]+://)`)
+
+// DefaultRules are the rules that are applied to all files.
+var DefaultRules = []Rule{
+	{
+		ID:          "private-key",
+		Description: "Private key",
+		Pattern:     regexp.MustCompile(`-----BEGIN[ A-Z0-9_-]{0,100}PRIVATE KEY( BLOCK)?-----`),
+	},
+	{
+		ID:          "aws-access-key-id",
+		Description: "AWS access key ID",
+		Pattern:     regexp.MustCompile(`\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[0-9A-Z]{16})\b`),
+	},
+	{
+		ID:          "github-token",
+		Description: "GitHub token",
+		Pattern:     regexp.MustCompile(`\b((?:ghp|gho|ghu|ghs|ghr)_[0-9A-Za-z]{36}|github_pat_[0-9A-Za-z_]{82})\b`),
+	},
+	{
+		ID:          "gitlab-token",
+		Description: "GitLab personal access token",
+		Pattern:     regexp.MustCompile(`\b(glpat-[0-9A-Za-z_-]{20})\b`),
+	},
+	{
+		ID:          "slack-token",
+		Description: "Slack token",
+		Pattern:     regexp.MustCompile(`\b(xox[baprs]-[0-9A-Za-z-]{10,})\b`),
+	},
+	{
+		ID:          "stripe-secret-key",
+		Description: "Stripe secret key",
+		Pattern:     regexp.MustCompile(`\b((?:sk|rk)_live_[0-9A-Za-z]{24,})\b`),
+	},
+	{
+		ID:          "google-api-key",
+		Description: "Google API key",
+		Pattern:     regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})\b`),
+	},
+	{
+		ID:          "jwt",
+		Description: "JSON web token",
+		Pattern:     regexp.MustCompile(`\b(eyJ[0-9A-Za-z_-]{10,}\.eyJ[0-9A-Za-z_-]{10,}\.[0-9A-Za-z_-]{10,})`),
+	},
+	{
+		ID:          "generic-secret",
+		Description: "Secret assigned to a key that looks like a credential",
+		Pattern: regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|` +
+			`private[_-]?key|client[_-]?secret)[A-Za-z0-9_-]*["']?\s*[:=]\s*["']?([^\s"',;#]{12,})`),
+		MinEntropy: 3.5,
+	},
+}
+
+// NewRule compiles a custom rule. Patterns use the RE2 syntax of package regexp, so matching takes linear time
+// regardless of the pattern.
+func NewRule(id, description, pattern string) (Rule, error) {
+	if id == "" {
+		return Rule{}, errors.New("rule ID must not be empty")
+	} else if len(pattern) > MaxPatternLength {
+		return Rule{}, fmt.Errorf("pattern must not be longer than %v characters", MaxPatternLength)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid pattern: %w", err)
+	} else if re.MatchString("") {
+		return Rule{}, errors.New("pattern must not match the empty string")
+	}
+	return Rule{ID: id, Description: description, Pattern: re}, nil
+}
+
+// Scanner applies a set of rules to files.
+type Scanner struct {
+	rules []Rule
+}
+
+// New returns a Scanner that applies the DefaultRules and the additional rules.
+func New(additional ...Rule) *Scanner {
+	rules := make([]Rule, 0, len(DefaultRules)+len(additional))
+	return &Scanner{rules: append(append(rules, DefaultRules...), additional...)}
+}
+
+// Scan returns the findings in data. Lines that contain the AllowPragma are skipped. Rules are applied in order, so
+// custom rules never report a secret that a default rule has already found on the same line.
+func (s *Scanner) Scan(file string, data []byte) []Finding {
+	var findings []Finding
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		s.scanLine(line, func(rule Rule, secret string) {
+			findings = append(findings, Finding{
+				File:        file,
+				Line:        i + 1,
+				RuleID:      rule.ID,
+				Description: rule.Description,
+				Redacted:    redact(secret),
+				Fingerprint: fingerprint(rule.ID, secret),
+			})
+		})
+	}
+	return findings
+}
+
+// Redact returns a copy of data in which every secret that Scan would report is replaced by its redacted version.
+func (s *Scanner) Redact(data []byte) []byte {
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		s.scanLine(line, func(rule Rule, secret string) {
+			line = bytes.ReplaceAll(line, []byte(secret), []byte(redact(secret)))
+		})
+		lines[i] = line
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// scanLine calls f for every secret found in line.
+func (s *Scanner) scanLine(line []byte, f func(rule Rule, secret string)) {
+	if len(line) == 0 || bytes.Contains(line, []byte(AllowPragma)) {
+		return
+	}
+	// a secret is only reported by the first rule that finds it, so that e.g. an AWS access key ID is not
+	// reported again as a generic secret
+	reported := map[string]struct{}{}
+	for _, rule := range s.rules {
+		for _, match := range rule.Pattern.FindAllSubmatch(line, -1) {
+			secret := string(match[0])
+			if len(match) > 1 && match[1] != nil {
+				secret = string(match[1])
+			}
+			if _, ok := reported[secret]; ok || templatedValue.MatchString(secret) ||
+				(rule.MinEntropy > 0 && entropy(secret) < rule.MinEntropy) {
+				continue
+			}
+			reported[secret] = struct{}{}
+			f(rule, secret)
+		}
+	}
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	var n int
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var result float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		result -= p * math.Log2(p)
+	}
+	return result
+}
+
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", 8)
+}
+
+func fingerprint(ruleID, secret string) string {
+	sum := sha256.Sum256([]byte(ruleID + "\x00" + secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+// Package securityevents records security relevant actions concerning user accounts and notifies the account owners
+// by mail.
+package securityevents
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/geoip"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"go.uber.org/zap"
+)
+
+// FailedLoginAttemptsThreshold is the number of consecutive failed login attempts after which the account owner is
+// notified. The counter is reset by a successful login.
+const FailedLoginAttemptsThreshold = 10
+
+var mailSubjects = map[types.SecurityEventType]string{
+	types.SecurityEventTypeNewDeviceLogin:      "New login to your Distr account",
+	types.SecurityEventTypeFailedLoginAttempts: "Failed login attempts on your Distr account",
+	types.SecurityEventTypePasswordChanged:     "Your Distr password has been changed",
+	types.SecurityEventTypeAccessTokenCreated:  "A personal access token has been created for your Distr account",
+	types.SecurityEventTypePasskeyAdded:        "A passkey has been added to your Distr account",
+	types.SecurityEventTypePasskeyRemoved:      "A passkey has been removed from your Distr account",
+}
+
+// New creates an event of the given type with the IP address, country and user agent of the client that sent r.
+func New(r *http.Request, user types.UserAccount, eventType types.SecurityEventType) types.SecurityEvent {
+	event := types.SecurityEvent{UserAccountID: user.ID, Type: eventType}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		event.UserAgent = &userAgent
+	}
+	if addr, err := parseAddr(r.RemoteAddr); err == nil {
+		event.IPAddress = util.PtrTo(addr.String())
+		if reader, err := geoip.Default(); err != nil {
+			internalctx.GetLogger(r.Context()).Warn("could not open GeoIP database", zap.Error(err))
+		} else if reader != nil {
+			if country, err := reader.Country(addr); err != nil {
+				internalctx.GetLogger(r.Context()).Warn("GeoIP lookup failed", zap.Error(err))
+			} else if country != "" {
+				event.Country = &country
+			}
+		}
+	}
+	return event
+}
+
+func parseAddr(remoteAddr string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(remoteAddr); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(remoteAddr)
+	return addr.Unmap(), err
+}
+
+// Login records a successful login of user. If the user has logged in before, but never with the same user agent
+// from the same country, a new device login is recorded instead.
+func Login(ctx context.Context, r *http.Request, user types.UserAccount) error {
+	event := New(r, user, types.SecurityEventTypeLogin)
+	if hasLogins, known, err := db.GetLoginDevice(ctx, user.ID, event.UserAgent, event.Country); err != nil {
+		return err
+	} else if hasLogins && !known {
+		event.Type = types.SecurityEventTypeNewDeviceLogin
+	}
+	return Record(ctx, user, &event)
+}
+
+// FailedLogin counts a failed login attempt of user. An event is recorded when the count reaches
+// FailedLoginAttemptsThreshold.
+func FailedLogin(ctx context.Context, r *http.Request, user types.UserAccount) error {
+	attempts, err := db.IncrementUserAccountFailedLoginAttempts(ctx, user.ID)
+	if err != nil || attempts != FailedLoginAttemptsThreshold {
+		return err
+	}
+	event := New(r, user, types.SecurityEventTypeFailedLoginAttempts)
+	event.Details = util.PtrTo(fmt.Sprintf("%v failed login attempts since the last successful login", attempts))
+	return Record(ctx, user, &event)
+}
+
+// Record stores event and sends a mail to user, unless the user has disabled mails for this type of event.
+// The event is stored before the mail is sent, so that a failed delivery does not cause the event to be lost.
+// Delivery errors are only logged.
+func Record(ctx context.Context, user types.UserAccount, event *types.SecurityEvent) error {
+	if err := db.CreateSecurityEvent(ctx, event); err != nil {
+		return err
+	}
+	subject, ok := mailSubjects[event.Type]
+	if !ok {
+		return nil
+	}
+	log := internalctx.GetLogger(ctx).With(zap.Stringer("securityEventId", event.ID))
+	if disabled, err := db.GetUserAccountSecurityEventMailsDisabled(ctx, user.ID); err != nil {
+		log.Warn("could not get security event mail preferences", zap.Error(err))
+		return nil
+	} else if slices.Contains(disabled, event.Type) {
+		return nil
+	}
+	err := internalctx.GetMailer(ctx).Send(ctx, mail.New(
+		mail.To(user.Email),
+		mail.Subject(subject),
+		mail.Type(types.MailTypeSecurityEvent),
+		mail.HtmlBodyTemplate(mailtemplates.SecurityEvent(user, *event)),
+	))
+	if err != nil {
+		log.Warn("could not send security event mail", zap.Error(err))
+	} else if err := db.UpdateSecurityEventMailSent(ctx, event); err != nil {
+		log.Warn("could not update security event", zap.Error(err))
+	}
+	return nil
+}
+
+// Notifiable returns all event types for which mails are sent.
+func Notifiable() []types.SecurityEventType {
+	result := make([]types.SecurityEventType, 0, len(mailSubjects))
+	for _, t := range types.SecurityEventTypes {
+		if _, ok := mailSubjects[t]; ok {
+			result = append(result, t)
+		}
+	}
+	return result
+}
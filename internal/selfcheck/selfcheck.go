@@ -0,0 +1,241 @@
+// Package selfcheck verifies that the blob storage, the database schema and the registry configuration of a server are
+// consistent with each other. A server whose bucket does not contain the blobs that are recorded in its database, for
+// example because it was started with the bucket of another environment, reports that it is not ready.
+package selfcheck
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/name"
+	"github.com/glasskube/distr/internal/types"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxMissingDigests is the maximum number of missing digests that are included in a result.
+	maxMissingDigests = 5
+	// minInterval is the minimum time between two checks. Checks that are requested earlier return the last result, so
+	// that requesting a check can not be used to flood the blob storage with requests.
+	minInterval = time.Minute
+)
+
+// BlobExistsFunc reports whether the blob with the given digest exists in the blob storage.
+type BlobExistsFunc func(ctx context.Context, digest types.Digest) (bool, error)
+
+type Options struct {
+	// BlobExists looks up sampled blobs. If it is nil, no blobs are sampled.
+	BlobExists BlobExistsFunc
+	// BlobSampleSize is the number of recently stored blobs that are looked up.
+	BlobSampleSize int
+	// MaxMissingBlobRatio is the ratio of missing sampled blobs above which the server is not ready.
+	MaxMissingBlobRatio float64
+	// RegistryHost is the default host of the registry. It is empty if the registry is disabled.
+	RegistryHost string
+	// SchemaVersion is the version of the newest database migration that is known to the binary.
+	SchemaVersion uint
+}
+
+// Result is the outcome of a check. It is exposed without authentication, so it must not contain any data of an
+// organization.
+type Result struct {
+	CheckedAt time.Time      `json:"checkedAt"`
+	Ready     bool           `json:"ready"`
+	Schema    SchemaResult   `json:"schema"`
+	Blobs     BlobResult     `json:"blobs"`
+	Registry  RegistryResult `json:"registry"`
+	Errors    []string       `json:"errors,omitempty"`
+}
+
+type SchemaResult struct {
+	Version  uint `json:"version"`
+	Expected uint `json:"expected"`
+	Dirty    bool `json:"dirty"`
+}
+
+type BlobResult struct {
+	Sampled      int     `json:"sampled"`
+	Missing      int     `json:"missing"`
+	Failed       int     `json:"failed"`
+	MissingRatio float64 `json:"missingRatio"`
+	// MissingDigests contains at most maxMissingDigests examples.
+	MissingDigests []string `json:"missingDigests,omitempty"`
+}
+
+type RegistryResult struct {
+	Host                   string `json:"host,omitempty"`
+	HostValid              bool   `json:"hostValid"`
+	Organizations          int    `json:"organizations"`
+	InvalidSlugs           int    `json:"invalidSlugs"`
+	InvalidRegistryDomains int    `json:"invalidRegistryDomains"`
+}
+
+type Checker struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+	opts   Options
+	mutex  sync.Mutex
+	result atomic.Pointer[Result]
+}
+
+func NewChecker(pool *pgxpool.Pool, logger *zap.Logger, opts Options) *Checker {
+	return &Checker{pool: pool, logger: logger, opts: opts}
+}
+
+// Result returns the result of the last check or nil if no check has completed yet.
+func (c *Checker) Result() *Result {
+	return c.result.Load()
+}
+
+// Ready reports whether the last check passed. Before the first check has completed, the server is considered ready.
+func (c *Checker) Ready() bool {
+	if result := c.Result(); result != nil {
+		return result.Ready
+	}
+	return true
+}
+
+// Start runs the first check in the background.
+func (c *Checker) Start(ctx context.Context) {
+	go c.Run(ctx)
+}
+
+// Run runs a check and returns its result. If the last check has completed less than minInterval ago, its result is
+// returned instead.
+func (c *Checker) Run(ctx context.Context) Result {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if last := c.Result(); last != nil && time.Since(last.CheckedAt) < minInterval {
+		return *last
+	}
+	result := c.check(internalctx.WithDb(ctx, c.pool))
+	c.result.Store(&result)
+	if !result.Ready {
+		c.logger.Error("self-check failed, the server reports that it is not ready",
+			zap.Int("sampledBlobs", result.Blobs.Sampled),
+			zap.Int("missingBlobs", result.Blobs.Missing),
+			zap.Strings("missingDigests", result.Blobs.MissingDigests),
+			zap.Strings("errors", result.Errors))
+	} else if len(result.Errors) > 0 {
+		c.logger.Warn("self-check found inconsistencies", zap.Strings("errors", result.Errors))
+	}
+	return result
+}
+
+func (c *Checker) check(ctx context.Context) Result {
+	result := Result{CheckedAt: time.Now(), Ready: true}
+	c.checkSchema(ctx, &result)
+	c.checkBlobs(ctx, &result)
+	c.checkRegistry(ctx, &result)
+	return result
+}
+
+func (c *Checker) checkSchema(ctx context.Context, result *Result) {
+	result.Schema.Expected = c.opts.SchemaVersion
+	if version, dirty, err := db.GetSchemaVersion(ctx); err != nil {
+		c.logger.Warn("self-check could not get schema version", zap.Error(err))
+		result.Errors = append(result.Errors, "could not get schema version")
+	} else {
+		result.Schema.Version = version
+		result.Schema.Dirty = dirty
+		if dirty {
+			result.Errors = append(result.Errors, fmt.Sprintf("migration %v has not completed", version))
+		}
+		// A newer schema is expected while a new version is rolled out, but an older one is missing migrations.
+		if version < c.opts.SchemaVersion {
+			result.Errors = append(result.Errors, fmt.Sprintf(
+				"schema version %v is older than the expected version %v", version, c.opts.SchemaVersion))
+		}
+	}
+}
+
+func (c *Checker) checkBlobs(ctx context.Context, result *Result) {
+	if c.opts.BlobExists == nil || c.opts.BlobSampleSize <= 0 {
+		return
+	}
+	digests, err := db.GetRecentPlatformBlobDigests(ctx, c.opts.BlobSampleSize)
+	if err != nil {
+		c.logger.Warn("self-check could not get blob digests", zap.Error(err))
+		result.Errors = append(result.Errors, "could not get blob digests")
+		return
+	}
+	result.Blobs.Sampled = len(digests)
+	for _, digest := range digests {
+		if exists, err := c.opts.BlobExists(ctx, digest); err != nil {
+			c.logger.Warn("self-check could not look up blob", zap.Error(err))
+			result.Blobs.Failed++
+		} else if !exists {
+			result.Blobs.Missing++
+			if len(result.Blobs.MissingDigests) < maxMissingDigests {
+				result.Blobs.MissingDigests = append(result.Blobs.MissingDigests, v1.Hash(digest).String())
+			}
+		}
+	}
+	if result.Blobs.Failed > 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf("could not look up %v blobs", result.Blobs.Failed))
+	}
+	if result.Blobs.Sampled > 0 {
+		result.Blobs.MissingRatio = float64(result.Blobs.Missing) / float64(result.Blobs.Sampled)
+	}
+	if result.Blobs.Missing > 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf("%v of %v sampled blobs are missing from the blob storage",
+			result.Blobs.Missing, result.Blobs.Sampled))
+	}
+	if result.Blobs.MissingRatio > c.opts.MaxMissingBlobRatio {
+		result.Ready = false
+	}
+}
+
+func (c *Checker) checkRegistry(ctx context.Context, result *Result) {
+	if c.opts.RegistryHost == "" {
+		return
+	}
+	result.Registry.Host = c.opts.RegistryHost
+	result.Registry.HostValid = isHost(c.opts.RegistryHost)
+	if !result.Registry.HostValid {
+		result.Errors = append(result.Errors, "registry host must be a host name with an optional port")
+	}
+	orgs, err := db.GetOrganizations(ctx)
+	if err != nil {
+		c.logger.Warn("self-check could not get organizations", zap.Error(err))
+		result.Errors = append(result.Errors, "could not get organizations")
+		return
+	}
+	result.Registry.Organizations = len(orgs)
+	for _, org := range orgs {
+		// organizations without a slug can not use the registry
+		if org.Slug != nil && name.Validate(*org.Slug+"/artifact", 0) != nil {
+			c.logger.Warn("organization slug can not be used in registry references",
+				zap.Stringer("organizationId", org.ID), zap.String("slug", *org.Slug))
+			result.Registry.InvalidSlugs++
+		}
+		if org.RegistryDomain != nil && !isHost(*org.RegistryDomain) {
+			c.logger.Warn("organization registry domain must be a host name with an optional port",
+				zap.Stringer("organizationId", org.ID), zap.String("registryDomain", *org.RegistryDomain))
+			result.Registry.InvalidRegistryDomains++
+		}
+	}
+	if result.Registry.InvalidSlugs > 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf(
+			"%v organization slugs can not be used in registry references", result.Registry.InvalidSlugs))
+	}
+	if result.Registry.InvalidRegistryDomains > 0 {
+		result.Errors = append(result.Errors, fmt.Sprintf(
+			"%v organization registry domains are not host names", result.Registry.InvalidRegistryDomains))
+	}
+}
+
+// isHost reports whether host is a URI authority without scheme or path, so that it can be used as the first part of
+// an image reference.
+func isHost(host string) bool {
+	u, err := url.Parse("//" + host)
+	return err == nil && host != "" && u.Host == host
+}
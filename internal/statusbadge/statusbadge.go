@@ -0,0 +1,129 @@
+package statusbadge
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"unicode/utf8"
+
+	"github.com/Masterminds/semver/v3"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// MinOwners is the minimum number of distinct deployment target owners that an application must have before its
+// installation count is published. Below that, the count could be attributed to individual customers.
+const MinOwners = 5
+
+const maxLabelLength = 40
+
+// LatestStableVersion returns the highest version name that is a valid semantic version without a pre-release
+// suffix. If no name is a stable semantic version, the last name is returned, because names are expected to be
+// ordered by appversion.Compare.
+func LatestStableVersion(names []string) *string {
+	var latest *semver.Version
+	var latestName string
+	for _, name := range names {
+		if v, err := semver.NewVersion(name); err == nil && v.Prerelease() == "" {
+			if latest == nil || v.GreaterThan(latest) {
+				latest = v
+				latestName = name
+			}
+		}
+	}
+	if latest != nil {
+		return &latestName
+	} else if len(names) > 0 {
+		return &names[len(names)-1]
+	}
+	return nil
+}
+
+// AnonymizedCount returns count if it was contributed by at least MinOwners distinct owners and nil otherwise.
+func AnonymizedCount(count, owners int) *int {
+	if owners < MinOwners {
+		return nil
+	}
+	return &count
+}
+
+// Refresh recomputes the data shown on the badge of the given application.
+func Refresh(ctx context.Context, applicationID uuid.UUID) error {
+	if versions, installations, owners, err := db.GetApplicationInstallationStats(ctx, applicationID); err != nil {
+		return err
+	} else {
+		return db.UpdateApplicationBadgeData(
+			ctx, applicationID, LatestStableVersion(versions), AnonymizedCount(installations, owners))
+	}
+}
+
+func RunApplicationBadgeRefresh(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	ids, err := db.GetApplicationBadgeApplicationIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := Refresh(ctx, id); err != nil {
+			return fmt.Errorf("failed to refresh badge of application %v: %w", id, err)
+		}
+	}
+	log.Info("ApplicationBadge refresh finished", zap.Int("badges", len(ids)))
+	return nil
+}
+
+// Message returns the text for the right hand side of the badge.
+func Message(latestVersion *string, installationCount *int) string {
+	if latestVersion == nil {
+		return "no release"
+	} else if installationCount == nil {
+		return *latestVersion
+	} else {
+		return fmt.Sprintf("%v | %v active", *latestVersion, *installationCount)
+	}
+}
+
+var badgeTemplate = template.Must(template.New("badge").Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{ .Width }}" height="20" role="img" aria-label="{{ .Label }}: ` +
+		`{{ .Message }}">` +
+		`<title>{{ .Label }}: {{ .Message }}</title>` +
+		`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/>` +
+		`<stop offset="1" stop-opacity=".1"/></linearGradient>` +
+		`<clipPath id="r"><rect width="{{ .Width }}" height="20" rx="3" fill="#fff"/></clipPath>` +
+		`<g clip-path="url(#r)"><rect width="{{ .LabelWidth }}" height="20" fill="#555"/>` +
+		`<rect x="{{ .LabelWidth }}" width="{{ .MessageWidth }}" height="20" fill="#4c1"/>` +
+		`<rect width="{{ .Width }}" height="20" fill="url(#s)"/></g>` +
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` +
+		`<text x="{{ .LabelX }}" y="14">{{ .Label }}</text>` +
+		`<text x="{{ .MessageX }}" y="14">{{ .Message }}</text></g></svg>`,
+))
+
+type badgeData struct {
+	Label, Message                                    string
+	Width, LabelWidth, MessageWidth, LabelX, MessageX int
+}
+
+// RenderSVG writes a flat badge in the style of shields.io. Text widths are estimated from the number of
+// characters, which is good enough for the short strings shown on a badge.
+func RenderSVG(w io.Writer, label, message string) error {
+	if runes := []rune(label); len(runes) > maxLabelLength {
+		label = string(runes[:maxLabelLength-1]) + "…"
+	}
+	data := badgeData{
+		Label:        label,
+		Message:      message,
+		LabelWidth:   textWidth(label),
+		MessageWidth: textWidth(message),
+	}
+	data.Width = data.LabelWidth + data.MessageWidth
+	data.LabelX = data.LabelWidth / 2
+	data.MessageX = data.LabelWidth + data.MessageWidth/2
+	return badgeTemplate.Execute(w, data)
+}
+
+func textWidth(s string) int {
+	return utf8.RuneCountInString(s)*7 + 10
+}
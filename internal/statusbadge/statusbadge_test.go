@@ -0,0 +1,33 @@
+package statusbadge_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glasskube/distr/internal/statusbadge"
+	"github.com/glasskube/distr/internal/util"
+	. "github.com/onsi/gomega"
+)
+
+func TestLatestStableVersion(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(statusbadge.LatestStableVersion(nil)).To(BeNil())
+	g.Expect(statusbadge.LatestStableVersion([]string{"1.2.0", "2.0.0-rc.1", "1.10.0", "1.9.0"})).
+		To(Equal(util.PtrTo("1.10.0")))
+	g.Expect(statusbadge.LatestStableVersion([]string{"first", "second"})).To(Equal(util.PtrTo("second")))
+}
+
+func TestAnonymizedCount(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(statusbadge.AnonymizedCount(148, statusbadge.MinOwners-1)).To(BeNil())
+	g.Expect(statusbadge.AnonymizedCount(148, statusbadge.MinOwners)).To(Equal(util.PtrTo(148)))
+}
+
+func TestRenderSVGEscapesText(t *testing.T) {
+	g := NewWithT(t)
+	var sb strings.Builder
+	g.Expect(statusbadge.RenderSVG(&sb, "<app>", "1.0.0 | 5 active")).To(Succeed())
+	g.Expect(sb.String()).To(HavePrefix("<svg "))
+	g.Expect(sb.String()).To(ContainSubstring("&lt;app&gt;"))
+	g.Expect(sb.String()).NotTo(ContainSubstring("<app>"))
+}
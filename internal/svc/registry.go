@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/glasskube/distr/internal/aggregates"
 	"net/http"
 	"syscall"
 
@@ -11,24 +12,51 @@ import (
 	sentryotel "github.com/getsentry/sentry-go/otel"
 	"github.com/glasskube/distr/internal/auth"
 	"github.com/glasskube/distr/internal/buildconfig"
+	"github.com/glasskube/distr/internal/certcheck"
 	"github.com/glasskube/distr/internal/cleanup"
+	"github.com/glasskube/distr/internal/consistency"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/dataexport"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/deploymentack"
+	"github.com/glasskube/distr/internal/deploymentpause"
+	"github.com/glasskube/distr/internal/deploymentrollback"
 	"github.com/glasskube/distr/internal/env"
 	"github.com/glasskube/distr/internal/jobs"
 	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mail/maillog"
 	"github.com/glasskube/distr/internal/mail/noop"
+	"github.com/glasskube/distr/internal/mail/orgmailer"
 	"github.com/glasskube/distr/internal/mail/ses"
 	"github.com/glasskube/distr/internal/mail/smtp"
+	"github.com/glasskube/distr/internal/maintenance"
 	"github.com/glasskube/distr/internal/migrations"
+	"github.com/glasskube/distr/internal/pii"
+	"github.com/glasskube/distr/internal/preregistration"
 	"github.com/glasskube/distr/internal/registry"
+	"github.com/glasskube/distr/internal/registry/blob/s3"
+	"github.com/glasskube/distr/internal/registry/cachesync"
+	manifestdb "github.com/glasskube/distr/internal/registry/manifest/db"
+	"github.com/glasskube/distr/internal/registry/metrics"
 	"github.com/glasskube/distr/internal/routing"
+	"github.com/glasskube/distr/internal/scrub"
+	"github.com/glasskube/distr/internal/selfcheck"
 	"github.com/glasskube/distr/internal/server"
+	"github.com/glasskube/distr/internal/statusbadge"
+	"github.com/glasskube/distr/internal/tagretention"
+	"github.com/glasskube/distr/internal/targetoutage"
+	"github.com/glasskube/distr/internal/unverifiedaccounts"
+	"github.com/glasskube/distr/internal/upstreamwatch"
+	"github.com/glasskube/distr/internal/versioneol"
 	"github.com/go-logr/zapr"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	gomail "github.com/wneessen/go-mail"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -41,7 +69,11 @@ type Registry struct {
 	execDbMigrations  bool
 	artifactsRegistry http.Handler
 	tracer            *trace.TracerProvider
+	meter             *sdkmetric.MeterProvider
 	jobsScheduler     *jobs.Scheduler
+	maintenance       *maintenance.Watcher
+	manifestCacheSync *cachesync.Notifier
+	selfCheck         *selfcheck.Checker
 }
 
 func New(ctx context.Context, options ...RegistryOption) (*Registry, error) {
@@ -71,12 +103,24 @@ func newRegistry(ctx context.Context, reg *Registry) (*Registry, error) {
 		reg.tracer = tracer
 	}
 
+	if meter, err := reg.createMeter(ctx); err != nil {
+		return nil, err
+	} else {
+		reg.meter = meter
+	}
+
 	if mailer, err := createMailer(ctx); err != nil {
 		return nil, err
 	} else {
 		reg.mailer = mailer
 	}
 
+	if keyring, err := pii.FromEnv(); err != nil {
+		return nil, fmt.Errorf("invalid PII encryption keys: %w", err)
+	} else {
+		pii.SetDefault(keyring)
+	}
+
 	if reg.execDbMigrations {
 		if err := migrations.Up(reg.logger); err != nil {
 			return nil, err
@@ -89,7 +133,26 @@ func newRegistry(ctx context.Context, reg *Registry) (*Registry, error) {
 		reg.dbPool = db
 	}
 
-	if scheduler, err := reg.createJobsScheduler(); err != nil {
+	// organization specific mail transports are looked up in the database, so the platform mailer can only be wrapped
+	// after the pool has been created
+	reg.mailer = orgmailer.New(
+		reg.mailer,
+		reg.dbPool,
+		reg.logger.With(zap.String("component", "mailer")),
+		env.OrganizationMailerMaxFailures(),
+	)
+	reg.mailer = maillog.New(reg.mailer, reg.dbPool, reg.logger.With(zap.String("component", "mailer")))
+
+	reg.maintenance = maintenance.NewWatcher(reg.dbPool, reg.logger.With(zap.String("component", "maintenance")))
+	reg.manifestCacheSync = cachesync.NewNotifier(reg.dbPool, reg.logger.With(zap.String("component", "cachesync")))
+
+	if selfCheck, err := reg.createSelfCheck(ctx); err != nil {
+		return nil, err
+	} else {
+		reg.selfCheck = selfCheck
+	}
+
+	if scheduler, err := reg.createJobsScheduler(ctx); err != nil {
 		return nil, err
 	} else {
 		reg.jobsScheduler = scheduler
@@ -112,6 +175,10 @@ func (r *Registry) Shutdown(ctx context.Context) error {
 		r.logger.Warn("tracer shutdown failed", zap.Error(err))
 	}
 
+	if err := r.meter.Shutdown(ctx); err != nil {
+		r.logger.Warn("meter shutdown failed", zap.Error(err))
+	}
+
 	// some devices like stdout and stderr can not be synced by the OS
 	if err := r.logger.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
 		return fmt.Errorf("logger sync failed: %w", err)
@@ -143,20 +210,7 @@ func (reg *Registry) createDBPool(ctx context.Context) (*pgxpool.Pool, error) {
 	if err != nil {
 		return nil, err
 	}
-	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
-		typeNames := []string{
-			"DEPLOYMENT_TYPE", "USER_ROLE", "HELM_CHART_TYPE",
-			"DEPLOYMENT_STATUS_TYPE", "FEATURE", "_FEATURE", "TUTORIAL",
-		}
-		for _, typeName := range typeNames {
-			if pgType, err := conn.LoadType(ctx, typeName); err != nil {
-				return err
-			} else {
-				conn.TypeMap().RegisterType(pgType)
-			}
-		}
-		return nil
-	}
+	config.AfterConnect = db.RegisterTypes
 	if maxConns := env.DatabaseMaxConns(); maxConns != nil {
 		config.MaxConns = int32(*maxConns)
 	}
@@ -228,7 +282,24 @@ func createMailer(ctx context.Context) (mail.Mailer, error) {
 
 func (reg *Registry) createArtifactsRegistry(ctx context.Context) http.Handler {
 	logger := reg.logger.With(zap.String("component", "registry"))
-	return registry.NewDefault(ctx, logger, reg.dbPool, reg.mailer, reg.tracer)
+	return registry.NewDefault(ctx, logger, reg.dbPool, reg.mailer, reg.tracer, reg.maintenance, reg.manifestCacheSync)
+}
+
+func (reg *Registry) createSelfCheck(ctx context.Context) (*selfcheck.Checker, error) {
+	schemaVersion, err := migrations.LatestVersion()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine latest migration: %w", err)
+	}
+	opts := selfcheck.Options{
+		BlobSampleSize:      env.SelfCheckBlobSampleSize(),
+		MaxMissingBlobRatio: env.SelfCheckMaxMissingBlobRatio(),
+		SchemaVersion:       schemaVersion,
+	}
+	if env.RegistryEnabled() {
+		opts.BlobExists = s3.NewPlatformBlobExistsFunc(ctx)
+		opts.RegistryHost = env.RegistryHost()
+	}
+	return selfcheck.NewChecker(reg.dbPool, reg.logger.With(zap.String("component", "selfcheck")), opts), nil
 }
 
 func (r *Registry) GetMailer() mail.Mailer {
@@ -236,12 +307,13 @@ func (r *Registry) GetMailer() mail.Mailer {
 }
 
 func createLogger() *zap.Logger {
+	scrubCore := zap.WrapCore(scrub.FromEnv().ZapCore)
 	if buildconfig.IsRelease() {
 		config := zap.NewProductionConfig()
 		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		return zap.Must(config.Build())
+		return zap.Must(config.Build(scrubCore))
 	} else {
-		return zap.Must(zap.NewDevelopment())
+		return zap.Must(zap.NewDevelopment(scrubCore))
 	}
 }
 
@@ -271,12 +343,27 @@ func (reg *Registry) createTracer(ctx context.Context) (*trace.TracerProvider, e
 	return tp, nil
 }
 
+// createMeter sets up the global meter provider. Metrics are only exported if the OTLP exporter is enabled.
+func (reg *Registry) createMeter(ctx context.Context) (*sdkmetric.MeterProvider, error) {
+	var mpopts []sdkmetric.Option
+	if env.OtelExporterOtlpEnabled() {
+		if exp, err := otlpmetricgrpc.New(ctx); err != nil {
+			return nil, err
+		} else {
+			mpopts = append(mpopts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp)))
+		}
+	}
+	mp := sdkmetric.NewMeterProvider(mpopts...)
+	otel.SetMeterProvider(mp)
+	return mp, nil
+}
+
 func (r *Registry) GetLogger() *zap.Logger {
 	return r.logger
 }
 
 func (r *Registry) GetRouter() http.Handler {
-	return routing.NewRouter(r.logger, r.dbPool, r.mailer, r.tracer)
+	return routing.NewRouter(r.logger, r.dbPool, r.mailer, r.tracer, r.maintenance, r.selfCheck)
 }
 
 func (r *Registry) GetArtifactsRouter() http.Handler {
@@ -295,7 +382,19 @@ func (r *Registry) GetArtifactsServer() server.Server {
 	}
 }
 
-func (r *Registry) createJobsScheduler() (*jobs.Scheduler, error) {
+// GetMetricsServer returns the internal server for the Prometheus metrics of the registry and the cleanup jobs. It is a
+// no-op server if the registry is disabled or no metrics address is configured.
+func (r *Registry) GetMetricsServer() server.Server {
+	if env.RegistryEnabled() && env.RegistryMetricsAddr() != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics.Handler())
+		return server.NewServer(mux, r.logger.With(zap.String("server", "metrics")))
+	} else {
+		return server.NewNoop()
+	}
+}
+
+func (r *Registry) createJobsScheduler(ctx context.Context) (*jobs.Scheduler, error) {
 	scheduler, err := jobs.NewScheduler(r.GetLogger(), r.GetDbPool())
 	if err != nil {
 		return nil, err
@@ -341,6 +440,281 @@ func (r *Registry) createJobsScheduler() (*jobs.Scheduler, error) {
 		}
 	}
 
+	if cron := env.CleanupOrphanedFilesCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("OrphanedFileCleanup", cleanup.RunOrphanedFileCleanup),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.CleanupBlobsCron(); cron != nil && env.RegistryEnabled() {
+		collector := cleanup.NewBlobGarbageCollector(
+			s3.NewPlatformBlobDeleteHandler(ctx),
+			cleanup.BlobGarbageCollectionOptions{
+				GracePeriod: env.BlobGarbageCollectionGracePeriod(),
+				BatchSize:   env.BlobGarbageCollectionBatchSize(),
+			},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("BlobGarbageCollection", collector.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.CustomerDataExportCron(); cron != nil {
+		exporter := dataexport.NewExporter(r.GetMailer())
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("CustomerDataExport", exporter.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.ArtifactDeletionCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("ArtifactDeletion", cleanup.RunArtifactDeletion),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.ArtifactTagRetentionCron(); cron != nil && env.RegistryEnabled() {
+		evaluator := tagretention.NewEvaluator(
+			manifestdb.NewManifestHandler(),
+			tagretention.Options{BatchSize: env.ArtifactTagRetentionBatchSize()},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("ArtifactTagRetention", evaluator.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.ApplicationDeletionCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("ApplicationDeletion", cleanup.RunApplicationDeletion),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.CleanupDataPurgeCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("DataPurge", cleanup.RunDataPurge),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.CleanupDataRetentionCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("DataRetentionCleanup", cleanup.RunDataRetentionCleanup),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.CleanupAnnouncementCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("AnnouncementCleanup", cleanup.RunAnnouncementCleanup),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.ConsistencyCheckCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("ConsistencyCheck", consistency.NewChecker(env.ConsistencyCheckRepair()).Run),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.ApplicationBadgeRefreshCron(); cron != nil {
+		err = scheduler.RegisterCronJob(
+			*cron,
+			jobs.NewJob("ApplicationBadgeRefresh", statusbadge.RunApplicationBadgeRefresh),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.UpstreamWatchCron(); cron != nil {
+		checker := upstreamwatch.NewChecker(
+			upstreamwatch.NewRemoteFetcher(http.DefaultClient),
+			r.GetMailer(),
+			upstreamwatch.Options{
+				Interval:       env.UpstreamWatchInterval(),
+				BatchSize:      env.UpstreamWatchBatchSize(),
+				RegistryBudget: env.UpstreamWatchRegistryBudget(),
+			},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("UpstreamWatchCheck", checker.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.CertificateCheckCron(); cron != nil {
+		checker := certcheck.NewChecker(
+			certcheck.Probe,
+			r.GetMailer(),
+			certcheck.Options{
+				Interval:  env.CertificateCheckInterval(),
+				BatchSize: env.CertificateCheckBatchSize(),
+			},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("DeploymentTargetEndpointCertificateCheck", checker.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.DeploymentAcknowledgmentReminderCron(); cron != nil {
+		reminder := deploymentack.NewReminder(
+			r.GetMailer(),
+			deploymentack.Options{
+				Interval:  env.DeploymentAcknowledgmentReminderInterval(),
+				BatchSize: env.DeploymentAcknowledgmentReminderBatchSize(),
+			},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("DeploymentAcknowledgmentReminder", reminder.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.VersionEOLNotificationCron(); cron != nil {
+		notifier := versioneol.NewNotifier(
+			r.GetMailer(),
+			versioneol.Options{BatchSize: env.VersionEOLNotificationBatchSize()},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("VersionEOLNotification", notifier.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.DeploymentTargetPreRegistrationCron(); cron != nil {
+		preRegistrationJob := preregistration.NewJob(
+			r.GetMailer(),
+			preregistration.Options{
+				PairingTokenValidity: env.DeploymentTargetPairingTokenValidity(),
+				ReminderInterval:     env.DeploymentTargetPreRegistrationReminderInterval(),
+				MaxReminders:         env.DeploymentTargetPreRegistrationMaxReminders(),
+			},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("DeploymentTargetPreRegistration", preRegistrationJob.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.UnverifiedUserAccountCleanupCron(); cron != nil {
+		cleanupJob := unverifiedaccounts.NewJob(
+			r.GetMailer(),
+			unverifiedaccounts.Options{MaxAge: env.UnverifiedUserAccountMaxAge()},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("UnverifiedUserAccountCleanup", cleanupJob.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.DeploymentAutoRollbackCron(); cron != nil {
+		rollbackJob := deploymentrollback.NewRollbackJob(
+			r.GetMailer(),
+			deploymentrollback.Options{
+				DefaultWindow: env.DeploymentAutoRollbackWindow(),
+				BatchSize:     env.DeploymentAutoRollbackBatchSize(),
+			},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("DeploymentAutoRollback", rollbackJob.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.DeploymentPauseResumeCron(); cron != nil {
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("DeploymentPauseResume", deploymentpause.RunAutoResume))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.DeploymentTargetOutageCron(); cron != nil {
+		detector := targetoutage.NewDetector(
+			r.GetMailer(),
+			targetoutage.Options{
+				Window:     env.DeploymentTargetOutageWindow(),
+				Threshold:  env.DeploymentTargetOutageThreshold(),
+				MinTargets: env.DeploymentTargetOutageMinTargets(),
+			},
+		)
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("DeploymentTargetOutage", detector.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.AggregateRefreshCron(); cron != nil {
+		refresher := aggregates.NewRefresher(aggregates.Options{
+			Interval:  env.AggregateRefreshInterval(),
+			BatchSize: env.AggregateRefreshBatchSize(),
+		})
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("OrganizationAggregateRefresh", refresher.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.OrganizationStorageMigrationCron(); cron != nil && env.RegistryEnabled() {
+		migrator := s3.NewMigrator(ctx, s3.MigratorOptions{BatchSize: env.OrganizationStorageMigrationBatchSize()})
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("OrganizationStorageMigration", migrator.Run))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cron := env.PIIEncryptionCron(); cron != nil && pii.Default() != nil {
+		batchSize := env.PIIEncryptionBatchSize()
+		err = scheduler.RegisterCronJob(*cron, jobs.NewJob("UserAccountEncryption", func(ctx context.Context) error {
+			if count, err := db.EncryptUserAccounts(ctx, batchSize); err != nil {
+				return err
+			} else if count > 0 {
+				internalctx.GetLogger(ctx).Info("user accounts encrypted", zap.Int("count", count))
+			}
+			if count, err := db.EncryptOrganizationMailConfigs(ctx, batchSize); err != nil {
+				return err
+			} else if count > 0 {
+				internalctx.GetLogger(ctx).Info("organization mail configs encrypted", zap.Int("count", count))
+			}
+			if count, err := db.EncryptSentMails(ctx, batchSize); err != nil {
+				return err
+			} else if count > 0 {
+				internalctx.GetLogger(ctx).Info("sent mails encrypted", zap.Int("count", count))
+			}
+			return nil
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return scheduler, nil
 }
 
@@ -348,6 +722,23 @@ func (r *Registry) GetJobsScheduler() *jobs.Scheduler {
 	return r.jobsScheduler
 }
 
+// GetSelfCheck returns the checker for the consistency of storage, database schema and registry configuration. It
+// must be started to run the first check.
+func (r *Registry) GetSelfCheck() *selfcheck.Checker {
+	return r.selfCheck
+}
+
+// GetMaintenanceWatcher returns the watcher for the maintenance state. It must be started to receive updates.
+func (r *Registry) GetMaintenanceWatcher() *maintenance.Watcher {
+	return r.maintenance
+}
+
+// GetManifestCacheSync returns the notifier that keeps the manifest caches of all instances in sync. It must be
+// started to receive the pushes of other instances.
+func (r *Registry) GetManifestCacheSync() *cachesync.Notifier {
+	return r.manifestCacheSync
+}
+
 func (r *Registry) GetTracer() *trace.TracerProvider {
 	return r.tracer
 }
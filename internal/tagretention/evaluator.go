@@ -0,0 +1,164 @@
+package tagretention
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/registry/manifest"
+	registryname "github.com/glasskube/distr/internal/registry/name"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// BatchSize is the maximum number of rules that are evaluated in one run.
+	BatchSize int
+}
+
+// Evaluator evaluates the retention rules of all artifacts in batches and removes the selected tags through the
+// manifest handler of the registry.
+type Evaluator struct {
+	manifests manifest.ManifestHandler
+	opts      Options
+	now       func() time.Time
+}
+
+func NewEvaluator(manifests manifest.ManifestHandler, opts Options) *Evaluator {
+	return &Evaluator{manifests: manifests, opts: opts, now: clock.Now}
+}
+
+// Run evaluates the batch of rules that have not been evaluated for the longest time and stores their reports. A
+// rule that can not be evaluated does not keep the others from being evaluated.
+func (e *Evaluator) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	rules, err := db.GetArtifactTagRetentionRulesForEvaluation(ctx, e.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	var removed int
+	for _, rule := range rules {
+		log := log.With(zap.Stringer("ruleId", rule.ID), zap.Stringer("artifactId", rule.ArtifactID))
+		artifact, err := db.GetArtifactByID(ctx, rule.OrganizationID, rule.ArtifactID, nil)
+		if errors.Is(err, apierrors.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		report, err := e.Evaluate(ctx, artifact.ArtifactWithDownloads, rule)
+		if err != nil {
+			log.Warn("could not evaluate tag retention rule", zap.Error(err))
+			errs = append(errs, err)
+			continue
+		}
+		if err := db.UpdateArtifactTagRetentionRuleReport(ctx, rule.ID, *report); err != nil {
+			return err
+		}
+		if !report.DryRun {
+			removed += len(report.Removed)
+		}
+	}
+	log.Info("tag retention finished", zap.Int("rules", len(rules)), zap.Int("removed", removed))
+	return errors.Join(errs...)
+}
+
+// Evaluate removes the tags of the artifact that the rule selects, unless it is a dry run rule. Every removed tag is
+// recorded in the audit log.
+func (e *Evaluator) Evaluate(
+	ctx context.Context,
+	artifact types.ArtifactWithDownloads,
+	rule types.ArtifactTagRetentionRule,
+) (*types.ArtifactTagRetentionReport, error) {
+	report, err := Report(ctx, artifact, rule, e.now())
+	if err != nil || rule.DryRun {
+		return report, err
+	}
+
+	log := internalctx.GetLogger(ctx)
+	repo := registryname.Name{OrgName: artifact.OrganizationSlug, ArtifactName: artifact.Name}.String()
+	selected := report.Removed
+	report.DryRun = false
+	report.Removed = []string{}
+	for _, tag := range selected {
+		err := db.RunTx(ctx, func(ctx context.Context) error {
+			if err := e.manifests.Delete(ctx, repo, tag); err != nil {
+				return err
+			}
+			return db.CreateAuditLogEntry(ctx, &types.AuditLogEntry{
+				OrganizationID: &artifact.OrganizationID,
+				Action:         "remove_tag",
+				ResourceType:   "Artifact",
+				ResourceID:     artifact.ID,
+				Data:           map[string]any{"tag": tag, "retentionRuleId": rule.ID},
+			})
+		})
+		if errors.Is(err, manifest.ErrManifestUnknown) {
+			// the tag has been removed in the meantime
+			continue
+		} else if err != nil {
+			log.Warn("could not remove tag", zap.String("tag", tag), zap.Error(err))
+			report.Failed = append(report.Failed, tag)
+		} else {
+			report.Removed = append(report.Removed, tag)
+		}
+	}
+	return report, nil
+}
+
+// Report evaluates the rule against the current tags of the artifact without removing any of them.
+func Report(
+	ctx context.Context,
+	artifact types.ArtifactWithDownloads,
+	rule types.ArtifactTagRetentionRule,
+	now time.Time,
+) (*types.ArtifactTagRetentionReport, error) {
+	tags, err := db.GetArtifactTagRetentionCandidates(ctx, artifact.ID)
+	if err != nil {
+		return nil, err
+	}
+	images, err := deployedImages(ctx, artifact.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	repo := registryname.Name{OrgName: artifact.OrganizationSlug, ArtifactName: artifact.Name}.String()
+	report := Select(rule, tags, DeployedReferences(repo, images), now)
+	report.DryRun = true
+	return &report, nil
+}
+
+// deployedImages returns the images of the compose files and the OCI charts of all active deployments of the
+// organization, and the images that are reported in the inventories of its deployment targets. Images in helm values
+// are only found in the inventories.
+func deployedImages(ctx context.Context, orgID uuid.UUID) ([]string, error) {
+	images, err := db.GetDeploymentTargetInventoryImages(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := db.GetActiveDeploymentApplicationVersions(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		if version.ChartType != nil && *version.ChartType == types.HelmChartTypeOCI &&
+			version.ChartUrl != nil && version.ChartVersion != nil {
+			images = append(images, *version.ChartUrl+":"+*version.ChartVersion)
+		} else if compose, err := version.ParsedComposeFile(); err != nil {
+			return nil, err
+		} else if services, ok := compose["services"].(map[string]any); ok {
+			for _, service := range services {
+				if service, ok := service.(map[string]any); ok {
+					if image, ok := service["image"].(string); ok {
+						images = append(images, image)
+					}
+				}
+			}
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,103 @@
+// Package tagretention removes the tags of artifacts that are no longer needed according to the retention rules of
+// the artifacts. Tags that are still in use are never removed.
+package tagretention
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Select evaluates the rule against tags, which must be ordered by push time, most recent first. Tags that the rule
+// would remove, but that are assigned to a license, recommended or contained in deployed, are reported as protected
+// instead. deployed contains the tags and digests of the artifact that are in use, see DeployedReferences.
+func Select(
+	rule types.ArtifactTagRetentionRule,
+	tags []types.ArtifactTagRetentionCandidate,
+	deployed map[string]bool,
+	now time.Time,
+) types.ArtifactTagRetentionReport {
+	report := types.ArtifactTagRetentionReport{
+		EvaluatedAt: now,
+		DryRun:      rule.DryRun,
+		Removed:     []string{},
+		Protected:   []types.ArtifactTagRetentionProtectedTag{},
+	}
+	var matched int
+	for _, tag := range tags {
+		if ok, _ := path.Match(rule.TagPattern, tag.Name); !ok {
+			continue
+		}
+		matched++
+		if rule.KeepLast != nil && matched <= *rule.KeepLast {
+			continue
+		} else if rule.UnpulledDays != nil &&
+			(tag.LastPulledAt != nil || tag.PushedAt.After(now.AddDate(0, 0, -*rule.UnpulledDays))) {
+			continue
+		}
+		if reason, ok := protection(tag, deployed); ok {
+			report.Protected = append(report.Protected, types.ArtifactTagRetentionProtectedTag{
+				Tag:    tag.Name,
+				Reason: reason,
+			})
+		} else {
+			report.Removed = append(report.Removed, tag.Name)
+		}
+	}
+	return report
+}
+
+func protection(
+	tag types.ArtifactTagRetentionCandidate,
+	deployed map[string]bool,
+) (types.ArtifactTagRetentionProtection, bool) {
+	switch {
+	case tag.Licensed:
+		return types.ArtifactTagRetentionProtectionLicense, true
+	case tag.Recommended:
+		return types.ArtifactTagRetentionProtectionRecommended, true
+	case deployed[tag.Name] || deployed[v1.Hash(tag.ManifestBlobDigest).String()]:
+		return types.ArtifactTagRetentionProtectionDeployment, true
+	default:
+		return "", false
+	}
+}
+
+// DeployedReferences returns the tags and digests of repository, e.g. "my-org/my-app", that are referenced by images.
+// The registry host of the images is ignored, because the registry can be reached under custom domains. Images may be
+// OCI chart URLs with an oci:// prefix. Images that can not be parsed are ignored.
+func DeployedReferences(repository string, images []string) map[string]bool {
+	result := map[string]bool{}
+	for _, image := range images {
+		if repo, tag, digest, ok := parseImage(image); ok && strings.EqualFold(repo, repository) {
+			if tag != "" {
+				result[tag] = true
+			}
+			if digest != "" {
+				result[digest] = true
+			}
+		}
+	}
+	return result
+}
+
+// parseImage splits an image reference like host/repo:tag@digest into its parts. The tag is "latest" if the image has
+// neither a tag nor a digest.
+func parseImage(image string) (repository, tag, digest string, ok bool) {
+	image = strings.TrimPrefix(strings.TrimSpace(image), "oci://")
+	image, digest, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, tag = image[:i], image[i+1:]
+	} else if digest == "" {
+		tag = name.DefaultTag
+	}
+	if repo, err := name.NewRepository(image); err != nil {
+		return "", "", "", false
+	} else {
+		return repo.RepositoryStr(), tag, digest, true
+	}
+}
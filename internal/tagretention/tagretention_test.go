@@ -0,0 +1,82 @@
+package tagretention_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/tagretention"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	now    = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	digest = v1.Hash{Algorithm: "sha256", Hex: "3f4a8c1e9b2d7f6a5c0e8b1d4a7f2c9e6b3d0a8f5c2e9b6d3a0f7c4e1b8d5a2f"}
+)
+
+func candidate(name string, pushedDaysAgo int) types.ArtifactTagRetentionCandidate {
+	return types.ArtifactTagRetentionCandidate{Name: name, PushedAt: now.AddDate(0, 0, -pushedDaysAgo)}
+}
+
+func TestSelectKeepLast(t *testing.T) {
+	g := NewWithT(t)
+	tags := []types.ArtifactTagRetentionCandidate{
+		candidate("1.3.0-rc1", 1), candidate("1.2.0", 2), candidate("1.2.0-rc2", 3), candidate("1.2.0-rc1", 4),
+		candidate("1.1.0-rc1", 5),
+	}
+	rule := types.ArtifactTagRetentionRule{TagPattern: "*-rc*", KeepLast: util.PtrTo(2)}
+	report := tagretention.Select(rule, tags, nil, now)
+	g.Expect(report.Removed).To(Equal([]string{"1.2.0-rc1", "1.1.0-rc1"}))
+	g.Expect(report.Protected).To(BeEmpty())
+}
+
+func TestSelectUnpulled(t *testing.T) {
+	g := NewWithT(t)
+	pulled := candidate("1.0.0", 60)
+	pulled.LastPulledAt = util.PtrTo(now.AddDate(0, 0, -50))
+	tags := []types.ArtifactTagRetentionCandidate{candidate("1.2.0", 10), candidate("1.1.0", 40), pulled}
+	rule := types.ArtifactTagRetentionRule{TagPattern: "*", UnpulledDays: util.PtrTo(30)}
+	g.Expect(tagretention.Select(rule, tags, nil, now).Removed).To(Equal([]string{"1.1.0"}))
+
+	// both conditions must be met
+	rule.KeepLast = util.PtrTo(2)
+	g.Expect(tagretention.Select(rule, tags, nil, now).Removed).To(BeEmpty())
+}
+
+func TestSelectProtected(t *testing.T) {
+	g := NewWithT(t)
+	licensed, recommended, deployedByDigest := candidate("1.0.0", 10), candidate("1.1.0", 10), candidate("1.2.0", 10)
+	licensed.Licensed = true
+	recommended.Recommended = true
+	deployedByDigest.ManifestBlobDigest = types.Digest(digest)
+	tags := []types.ArtifactTagRetentionCandidate{
+		candidate("2.0.0", 1), licensed, recommended, deployedByDigest, candidate("1.3.0", 10), candidate("1.4.0", 10),
+	}
+	deployed := tagretention.DeployedReferences("my-org/my-app", []string{
+		"registry.example.com/my-org/my-app@" + digest.String(),
+		"distr.example.com/my-org/my-app:1.3.0",
+		"registry.example.com/other-org/my-app:1.4.0",
+	})
+	rule := types.ArtifactTagRetentionRule{TagPattern: "*", KeepLast: util.PtrTo(1)}
+	report := tagretention.Select(rule, tags, deployed, now)
+	g.Expect(report.Removed).To(Equal([]string{"1.4.0"}))
+	g.Expect(report.Protected).To(Equal([]types.ArtifactTagRetentionProtectedTag{
+		{Tag: "1.0.0", Reason: types.ArtifactTagRetentionProtectionLicense},
+		{Tag: "1.1.0", Reason: types.ArtifactTagRetentionProtectionRecommended},
+		{Tag: "1.2.0", Reason: types.ArtifactTagRetentionProtectionDeployment},
+		{Tag: "1.3.0", Reason: types.ArtifactTagRetentionProtectionDeployment},
+	}))
+}
+
+func TestDeployedReferences(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(tagretention.DeployedReferences("my-org/charts/my-app", []string{
+		"oci://registry.example.com/my-org/charts/my-app:1.0.0",
+		"registry.example.com:5000/my-org/charts/my-app",
+		"registry.example.com/my-org/charts/my-app:2.0.0@" + digest.String(),
+		"registry.example.com/my-org/charts/my-app-other:3.0.0",
+		"not a valid image",
+	})).To(Equal(map[string]bool{"1.0.0": true, "latest": true, "2.0.0": true, digest.String(): true}))
+}
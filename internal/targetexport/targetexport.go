@@ -0,0 +1,116 @@
+// Package targetexport contains helpers for moving deployment targets between Distr instances.
+package targetexport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// FormatVersion is incremented whenever the export format changes in an incompatible way.
+const FormatVersion = 1
+
+// MinTransportKeyLength is the minimum length of the passphrase used to encrypt secrets in an export.
+const MinTransportKeyLength = 16
+
+const (
+	saltLen = 16
+	keyLen  = 32
+)
+
+var ErrDecryptionFailed = errors.New("decryption failed, the transport key is probably wrong")
+
+func ValidateTransportKey(transportKey string) error {
+	if len(transportKey) < MinTransportKeyLength {
+		return fmt.Errorf("transport key must be at least %v characters long", MinTransportKeyLength)
+	}
+	return nil
+}
+
+// Encrypt encrypts data with a key derived from transportKey using AES-GCM.
+// The result contains the salt and nonce and is encoded as base64.
+// Encrypting nil returns an empty string.
+func Encrypt(transportKey string, data []byte) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(transportKey, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	result := slices.Concat(salt, nonce, gcm.Seal(nil, nonce, data, nil))
+	return base64.StdEncoding.EncodeToString(result), nil
+}
+
+// Decrypt reverses Encrypt. Decrypting an empty string returns nil.
+func Decrypt(transportKey string, encrypted string) ([]byte, error) {
+	if encrypted == "" {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted data: %w", err)
+	} else if len(data) < saltLen {
+		return nil, ErrDecryptionFailed
+	}
+	gcm, err := newGCM(transportKey, data[:saltLen])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltLen:]
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	if result, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil); err != nil {
+		return nil, ErrDecryptionFailed
+	} else {
+		return result, nil
+	}
+}
+
+func newGCM(transportKey string, salt []byte) (cipher.AEAD, error) {
+	if block, err := aes.NewCipher(argon2.IDKey([]byte(transportKey), salt, 1, 64*1024, 4, keyLen)); err != nil {
+		return nil, err
+	} else {
+		return cipher.NewGCM(block)
+	}
+}
+
+// DigestImages returns all images of a parsed compose file that are pinned by digest
+// and hosted on the given registry host.
+func DigestImages(composeFile map[string]any, registryHost string) []string {
+	var result []string
+	if services, ok := composeFile["services"].(map[string]any); ok {
+		for _, svc := range services {
+			if svc, ok := svc.(map[string]any); ok {
+				if image, ok := svc["image"].(string); ok &&
+					strings.HasPrefix(image, registryHost+"/") && strings.Contains(image, "@") {
+					result = append(result, image)
+				}
+			}
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// ImageDigest returns the digest part of a digest pinned image reference.
+func ImageDigest(image string) string {
+	_, digest, _ := strings.Cut(image, "@")
+	return digest
+}
@@ -0,0 +1,47 @@
+package targetexport_test
+
+import (
+	"testing"
+
+	"github.com/glasskube/distr/internal/targetexport"
+	. "github.com/onsi/gomega"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	g := NewWithT(t)
+	encrypted, err := targetexport.Encrypt("correct horse battery staple", []byte("SECRET=value"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(encrypted).NotTo(ContainSubstring("SECRET"))
+
+	decrypted, err := targetexport.Decrypt("correct horse battery staple", encrypted)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(decrypted)).To(Equal("SECRET=value"))
+
+	_, err = targetexport.Decrypt("wrong horse battery staple!", encrypted)
+	g.Expect(err).To(MatchError(targetexport.ErrDecryptionFailed))
+}
+
+func TestEncryptNil(t *testing.T) {
+	g := NewWithT(t)
+	encrypted, err := targetexport.Encrypt("correct horse battery staple", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(encrypted).To(BeEmpty())
+	decrypted, err := targetexport.Decrypt("correct horse battery staple", encrypted)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(decrypted).To(BeNil())
+}
+
+func TestDigestImages(t *testing.T) {
+	g := NewWithT(t)
+	compose := map[string]any{
+		"services": map[string]any{
+			"a": map[string]any{"image": "registry.example.com/acme/app@sha256:abc"},
+			"b": map[string]any{"image": "registry.example.com/acme/app:1.0.0"},
+			"c": map[string]any{"image": "docker.io/library/postgres@sha256:def"},
+			"d": map[string]any{"image": "registry.example.com/acme/app@sha256:abc"},
+		},
+	}
+	images := targetexport.DigestImages(compose, "registry.example.com")
+	g.Expect(images).To(Equal([]string{"registry.example.com/acme/app@sha256:abc"}))
+	g.Expect(targetexport.ImageDigest(images[0])).To(Equal("sha256:abc"))
+}
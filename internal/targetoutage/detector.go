@@ -0,0 +1,137 @@
+// Package targetoutage detects mass outages: if a large fraction of the deployment targets of an organization, or of
+// one of its customers, stops reporting at about the same time, the cause is most likely shared, like a network or
+// firewall change, so a single notification is sent instead of one per deployment target.
+package targetoutage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// Window is the time in which deployment targets must have reported their status to be considered. Deployment
+	// targets that have been stale for longer do not count towards an outage.
+	Window time.Duration
+	// Threshold is the fraction of the considered deployment targets that must be stale for an outage to be suspected.
+	// An outage is resolved once the fraction of its affected deployment targets that are still stale drops below it.
+	Threshold float64
+	// MinTargets is the minimum number of considered deployment targets, so that a single stale deployment target of
+	// a small customer is not reported as an outage.
+	MinTargets int
+}
+
+type Detector struct {
+	mailer mail.Mailer
+	opts   Options
+	now    func() time.Time
+}
+
+func NewDetector(mailer mail.Mailer, opts Options) *Detector {
+	return &Detector{mailer: mailer, opts: opts, now: clock.Now}
+}
+
+type scope struct {
+	organizationID        uuid.UUID
+	customerUserAccountID uuid.UUID
+}
+
+func scopeOf(organizationID uuid.UUID, customerUserAccountID *uuid.UUID) scope {
+	if customerUserAccountID == nil {
+		return scope{organizationID: organizationID}
+	}
+	return scope{organizationID: organizationID, customerUserAccountID: *customerUserAccountID}
+}
+
+// Run resolves the open outages whose deployment targets are reporting again and detects new outages.
+//
+// While an outage of an organization is open, no outages of its customers are reported, because they are already
+// part of it.
+func (d *Detector) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	ctx = internalctx.WithMailer(ctx, d.mailer)
+	now := d.now()
+
+	open, err := db.GetOpenDeploymentTargetOutages(ctx, now)
+	if err != nil {
+		return err
+	}
+	openScopes := make(map[scope]struct{}, len(open))
+	var resolved int
+	for _, outage := range open {
+		if d.reachesThreshold(outage.StaleCount, outage.TargetCount) {
+			openScopes[scopeOf(outage.OrganizationID, outage.CustomerUserAccountID)] = struct{}{}
+			continue
+		}
+		if err := db.ResolveDeploymentTargetOutage(ctx, outage.ID, now); errors.Is(err, apierrors.ErrConflict) {
+			// another replica was faster
+			continue
+		} else if err != nil {
+			return err
+		}
+		resolved++
+		outage.ResolvedAt = &now
+		log.Info("deployment target outage resolved", zap.Stringer("outageId", outage.ID))
+		if err := mailsending.SendDeploymentTargetOutageMail(ctx, outage.DeploymentTargetOutage, true); err != nil {
+			log.Warn("could not send deployment target outage resolved mail", zap.Error(err))
+		}
+	}
+
+	connectivity, err := db.GetDeploymentTargetConnectivity(ctx, now, now.Add(-d.opts.Window))
+	if err != nil {
+		return err
+	}
+	var detected int
+	for _, c := range connectivity {
+		if c.TargetCount < d.opts.MinTargets || !d.reachesThreshold(len(c.StaleDeploymentTargetIDs), c.TargetCount) {
+			continue
+		}
+		if _, ok := openScopes[scopeOf(c.OrganizationID, c.CustomerUserAccountID)]; ok {
+			continue
+		}
+		// the aggregate of the organization comes first, so an outage that was detected for it in this run is found
+		if _, ok := openScopes[scopeOf(c.OrganizationID, nil)]; ok {
+			continue
+		}
+		outage := types.DeploymentTargetOutage{
+			OrganizationID:              c.OrganizationID,
+			CustomerUserAccountID:       c.CustomerUserAccountID,
+			TargetCount:                 c.TargetCount,
+			AffectedDeploymentTargetIDs: c.StaleDeploymentTargetIDs,
+		}
+		if err := db.CreateDeploymentTargetOutage(ctx, &outage); errors.Is(err, apierrors.ErrConflict) {
+			// another replica was faster
+			continue
+		} else if err != nil {
+			return err
+		}
+		detected++
+		openScopes[scopeOf(c.OrganizationID, c.CustomerUserAccountID)] = struct{}{}
+		log.Warn("deployment target outage suspected",
+			zap.Stringer("outageId", outage.ID),
+			zap.Stringer("organizationId", outage.OrganizationID),
+			zap.Int("stale", len(outage.AffectedDeploymentTargetIDs)),
+			zap.Int("total", outage.TargetCount))
+		if err := mailsending.SendDeploymentTargetOutageMail(ctx, outage, false); err != nil {
+			log.Warn("could not send deployment target outage suspected mail", zap.Error(err))
+		}
+	}
+
+	log.Info("deployment target outage detection finished",
+		zap.Int("open", len(open)-resolved+detected), zap.Int("detected", detected), zap.Int("resolved", resolved))
+	return nil
+}
+
+func (d *Detector) reachesThreshold(stale, total int) bool {
+	return total > 0 && float64(stale)/float64(total) >= d.opts.Threshold
+}
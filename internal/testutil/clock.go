@@ -0,0 +1,33 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/internal/clock"
+)
+
+var fakeClock *clock.Fake
+
+// FreezeTime replaces the clock that is used by expiry checks and scheduled jobs with one that stands still at now,
+// until t ends. Tests that use it must not run in parallel.
+func FreezeTime(t testing.TB, now time.Time) *clock.Fake {
+	t.Helper()
+	fakeClock = clock.NewFake(now)
+	clock.Set(fakeClock)
+	t.Cleanup(func() {
+		fakeClock = nil
+		clock.Reset()
+	})
+	return fakeClock
+}
+
+// AdvanceTime moves the clock forward by d and returns the new time. The clock is frozen at the current time first,
+// unless FreezeTime has already been called.
+func AdvanceTime(t testing.TB, d time.Duration) time.Time {
+	t.Helper()
+	if fakeClock == nil {
+		FreezeTime(t, time.Now())
+	}
+	return fakeClock.Advance(d)
+}
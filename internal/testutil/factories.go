@@ -0,0 +1,190 @@
+package testutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/glasskube/distr/api"
+	"github.com/glasskube/distr/internal/authjwt"
+	"github.com/glasskube/distr/internal/authkey"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/env"
+	"github.com/glasskube/distr/internal/security"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
+)
+
+// Password is the password of all user accounts that are created by NewUserAccount.
+const Password = "distr-test-password"
+
+// NewUserAccount creates a verified user account with a unique email address. Modifiers are applied before the user
+// account is saved.
+func NewUserAccount(ctx context.Context, t testing.TB, modifiers ...func(*types.UserAccount)) *types.UserAccount {
+	t.Helper()
+	id := uuid.NewString()
+	user := types.UserAccount{
+		Email:           "user-" + id + "@example.com",
+		Name:            "Test User " + id[:8],
+		EmailVerifiedAt: util.PtrTo(time.Now()),
+		Password:        Password,
+	}
+	for _, modify := range modifiers {
+		modify(&user)
+	}
+	if user.Password != "" {
+		must(t, security.HashPassword(&user))
+	}
+	must(t, db.CreateUserAccount(ctx, &user))
+	return &user
+}
+
+// NewOrganization creates an organization with a unique name and slug.
+func NewOrganization(ctx context.Context, t testing.TB) *types.Organization {
+	t.Helper()
+	id := uuid.NewString()[:8]
+	org := types.Organization{Name: "Test Organization " + id, Slug: util.PtrTo("test-org-" + id)}
+	must(t, db.CreateOrganization(ctx, &org))
+	return &org
+}
+
+type OrganizationWithUsers struct {
+	types.Organization
+	Vendors   []types.UserAccount
+	Customers []types.UserAccount
+}
+
+// NewOrganizationWithUsers creates an organization together with the given number of vendor and customer users.
+func NewOrganizationWithUsers(ctx context.Context, t testing.TB, vendors, customers int) *OrganizationWithUsers {
+	t.Helper()
+	result := OrganizationWithUsers{Organization: *NewOrganization(ctx, t)}
+	for range vendors {
+		user := NewUserAccount(ctx, t)
+		must(t, db.CreateUserAccountOrganizationAssignment(ctx, user.ID, result.ID, types.UserRoleVendor))
+		result.Vendors = append(result.Vendors, *user)
+	}
+	for range customers {
+		user := NewUserAccount(ctx, t)
+		must(t, db.CreateUserAccountOrganizationAssignment(ctx, user.ID, result.ID, types.UserRoleCustomer))
+		result.Customers = append(result.Customers, *user)
+	}
+	return &result
+}
+
+// NewArtifactWithTags creates an artifact with a single manifest that is available by its digest and by all given
+// tags, like after a push of an image with multiple tags. The digest version is the first of the returned versions.
+func NewArtifactWithTags(
+	ctx context.Context,
+	t testing.TB,
+	orgID, createdByID uuid.UUID,
+	tags ...string,
+) (*types.Artifact, []types.ArtifactVersion) {
+	t.Helper()
+	artifact := types.Artifact{Name: "test-artifact-" + uuid.NewString()[:8], OrganizationID: orgID}
+	must(t, db.CreateArtifact(ctx, &artifact))
+	sum := sha256.Sum256([]byte(artifact.Name))
+	digest := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])}
+	versions := make([]types.ArtifactVersion, 0, len(tags)+1)
+	for _, name := range append([]string{digest.String()}, tags...) {
+		version := types.ArtifactVersion{
+			Name:                   name,
+			CreatedByUserAccountID: &createdByID,
+			ManifestBlobDigest:     types.Digest(digest),
+			ManifestBlobSize:       1024,
+			ManifestContentType:    "application/vnd.oci.image.manifest.v1+json",
+			ArtifactID:             artifact.ID,
+		}
+		must(t, db.CreateArtifactVersion(ctx, &version))
+		versions = append(versions, version)
+	}
+	return &artifact, versions
+}
+
+// NewDeploymentTarget creates a docker deployment target that uses the current agent version. Modifiers are applied
+// before the deployment target is saved.
+func NewDeploymentTarget(
+	ctx context.Context,
+	t testing.TB,
+	orgID, createdByID uuid.UUID,
+	modifiers ...func(*types.DeploymentTargetWithCreatedBy),
+) *types.DeploymentTargetWithCreatedBy {
+	t.Helper()
+	must(t, db.CreateAgentVersion(ctx))
+	agentVersion, err := db.GetCurrentAgentVersion(ctx)
+	must(t, err)
+	dt := types.DeploymentTargetWithCreatedBy{
+		DeploymentTarget: types.DeploymentTarget{
+			Name:           "test-target-" + uuid.NewString()[:8],
+			Type:           types.DeploymentTypeDocker,
+			AgentVersionID: &agentVersion.ID,
+		},
+	}
+	for _, modify := range modifiers {
+		modify(&dt)
+	}
+	must(t, db.CreateDeploymentTarget(ctx, &dt, orgID, createdByID))
+	return &dt
+}
+
+// NewDeploymentRevision creates a docker application with a single version and deploys it to target.
+func NewDeploymentRevision(
+	ctx context.Context,
+	t testing.TB,
+	target *types.DeploymentTargetWithCreatedBy,
+) *types.DeploymentRevision {
+	t.Helper()
+	app := types.Application{Name: "test-app-" + uuid.NewString()[:8], Type: types.DeploymentTypeDocker}
+	must(t, db.CreateApplication(ctx, &app, target.OrganizationID))
+	version := types.ApplicationVersion{
+		Name:            "1.0.0",
+		ApplicationID:   app.ID,
+		ComposeFileData: []byte("services:\n  app:\n    image: nginx\n"),
+	}
+	must(t, db.CreateApplicationVersion(ctx, &version))
+	request := api.DeploymentRequest{
+		DeploymentTargetID:   target.ID,
+		ApplicationVersionID: version.ID,
+		DockerType:           util.PtrTo(types.DockerTypeCompose),
+	}
+	must(t, db.CreateDeployment(ctx, &request))
+	revision, err := db.CreateDeploymentRevision(ctx, &request)
+	must(t, err)
+	return revision
+}
+
+// NewAccessToken creates a personal access token of the user for the organization. The returned key is used to
+// authenticate requests with the header "Authorization: AccessToken <key>".
+func NewAccessToken(ctx context.Context, t testing.TB, userID, orgID uuid.UUID) (*types.AccessToken, string) {
+	t.Helper()
+	key, err := authkey.NewKey()
+	must(t, err)
+	token := types.AccessToken{
+		KeyHash:        key.Hash(),
+		KeyPrefix:      key.DisplayPrefix(),
+		UserAccountID:  userID,
+		OrganizationID: orgID,
+	}
+	must(t, db.CreateAccessToken(ctx, &token))
+	return &token, key.Serialize()
+}
+
+func must(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// NewAgentToken returns a token for the agent of the deployment target, like the one returned by the agent login.
+// A JWT secret is configured for the test binary if there is none.
+func NewAgentToken(t testing.TB, dt *types.DeploymentTargetWithCreatedBy) string {
+	t.Helper()
+	env.SetJWTSecretIfEmpty([]byte("distr-test-jwt-secret"))
+	_, token, err := authjwt.GenerateAgentTokenValidFor(dt.ID, dt.OrganizationID, time.Hour)
+	must(t, err)
+	return token
+}
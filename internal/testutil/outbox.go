@@ -0,0 +1,27 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mail/capture"
+)
+
+// CaptureMail returns a context with a mailer that records all mails instead of sending them. Use DrainOutbox to
+// inspect them.
+func CaptureMail(ctx context.Context) context.Context {
+	return internalctx.WithMailer(ctx, capture.New())
+}
+
+// DrainOutbox returns the mails that have been sent with the mailer of ctx since the last call. ctx must have been
+// created by CaptureMail.
+func DrainOutbox(ctx context.Context, t testing.TB) []mail.Mail {
+	t.Helper()
+	mailer, ok := internalctx.GetMailer(ctx).(*capture.Mailer)
+	if !ok {
+		t.Fatal("mailer of context does not capture mails, use testutil.CaptureMail")
+	}
+	return mailer.Drain()
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessGrant gives a vendor user temporary access to a deployment target of a customer or to all deployment targets
+// of a customer account. Exactly one of DeploymentTargetID and CustomerUserAccountID is set.
+type AccessGrant struct {
+	ID                     uuid.UUID  `db:"id" json:"id"`
+	CreatedAt              time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID         uuid.UUID  `db:"organization_id" json:"-"`
+	UserAccountID          uuid.UUID  `db:"user_account_id" json:"userAccountId"`
+	DeploymentTargetID     *uuid.UUID `db:"deployment_target_id" json:"deploymentTargetId,omitempty"`
+	CustomerUserAccountID  *uuid.UUID `db:"customer_user_account_id" json:"customerUserAccountId,omitempty"`
+	GrantedByUserAccountID *uuid.UUID `db:"granted_by_user_account_id" json:"grantedByUserAccountId,omitempty"`
+	Reason                 string     `db:"reason" json:"reason"`
+	ExpiresAt              time.Time  `db:"expires_at" json:"expiresAt"`
+	RevokedAt              *time.Time `db:"revoked_at" json:"revokedAt,omitempty"`
+	RevokedByUserAccountID *uuid.UUID `db:"revoked_by_user_account_id" json:"revokedByUserAccountId,omitempty"`
+}
+
+// IsActive returns true if the grant has neither expired nor been revoked at t.
+func (g *AccessGrant) IsActive(t time.Time) bool {
+	return g.RevokedAt == nil && g.ExpiresAt.After(t)
+}
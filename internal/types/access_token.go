@@ -3,23 +3,26 @@ package types
 import (
 	"time"
 
-	"github.com/glasskube/distr/internal/authkey"
+	"github.com/glasskube/distr/internal/clock"
 	"github.com/google/uuid"
 )
 
 type AccessToken struct {
-	ID             uuid.UUID   `db:"id"`
-	CreatedAt      time.Time   `db:"created_at"`
-	ExpiresAt      *time.Time  `db:"expires_at"`
-	LastUsedAt     *time.Time  `db:"last_used_at"`
-	Label          *string     `db:"label"`
-	Key            authkey.Key `db:"key"`
-	UserAccountID  uuid.UUID   `db:"user_account_id"`
-	OrganizationID uuid.UUID   `db:"organization_id"`
+	ID             uuid.UUID  `db:"id"`
+	CreatedAt      time.Time  `db:"created_at"`
+	ExpiresAt      *time.Time `db:"expires_at"`
+	LastUsedAt     *time.Time `db:"last_used_at"`
+	Label          *string    `db:"label"`
+	KeyHash        []byte     `db:"key_hash"`
+	KeyPrefix      string     `db:"key_prefix"`
+	UserAccountID  uuid.UUID  `db:"user_account_id"`
+	OrganizationID uuid.UUID  `db:"organization_id"`
+	// RegistryScopes limit the token to these actions in the registry. A token with scopes can not be used for the API.
+	RegistryScopes RegistryScopes `db:"registry_scopes"`
 }
 
 func (tok AccessToken) HasExpired() bool {
-	return tok.ExpiresAt == nil || tok.ExpiresAt.After(time.Now())
+	return tok.ExpiresAt != nil && !tok.ExpiresAt.After(clock.Now())
 }
 
 type AccessTokenWithUserAccount struct {
@@ -27,3 +30,19 @@ type AccessTokenWithUserAccount struct {
 	UserAccount UserAccount `db:"user_account"`
 	UserRole    UserRole    `db:"user_role"`
 }
+
+// OrganizationAccessToken is an access token of a user or of a service account of an organization.
+type OrganizationAccessToken struct {
+	ID             uuid.UUID      `db:"id"`
+	CreatedAt      time.Time      `db:"created_at"`
+	ExpiresAt      *time.Time     `db:"expires_at"`
+	LastUsedAt     *time.Time     `db:"last_used_at"`
+	Label          *string        `db:"label"`
+	KeyPrefix      string         `db:"key_prefix"`
+	RegistryScopes RegistryScopes `db:"registry_scopes"`
+	// UserAccount is set for tokens of users. UserRole is nil if the user is not a member of the organization anymore.
+	UserAccount *UserAccount `db:"user_account"`
+	UserRole    *UserRole    `db:"user_role"`
+	// ServiceAccount is set for tokens of service accounts.
+	ServiceAccount *ServiceAccount `db:"service_account"`
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/glasskube/distr/internal/validation"
+)
+
+const (
+	MaxAgentConcurrentPulls        = 16
+	MinAgentDownloadBytesPerSecond = 64 * 1024
+	MaxAgentMaxProcs               = 1024
+	MaxAgentNiceLevel              = 19
+)
+
+// The names of the limits, as reported in [AppliedAgentResourceLimits.Unsupported].
+const (
+	AgentResourceLimitMaxConcurrentPulls        = "maxConcurrentPulls"
+	AgentResourceLimitMaxDownloadBytesPerSecond = "maxDownloadBytesPerSecond"
+	AgentResourceLimitMaxProcs                  = "maxProcs"
+	AgentResourceLimitNiceLevel                 = "niceLevel"
+	AgentResourceLimitLogLevel                  = "logLevel"
+)
+
+// AgentLogLevels are the log levels that an agent can be configured with.
+var AgentLogLevels = []string{"debug", "info", "warn", "error"}
+
+// AgentResourceLimits restrict the resources that an agent uses on its host. A limit that is nil is not set and the
+// agent uses its built-in default. Organizations define defaults that can be overridden per deployment target.
+//
+// The limits are sent to agents as part of their resources. Agents that do not know a limit ignore it, so new limits
+// must always be optional.
+type AgentResourceLimits struct {
+	// MaxConcurrentPulls is the maximum number of images that are pulled at the same time.
+	MaxConcurrentPulls *int `json:"maxConcurrentPulls,omitempty"`
+	// MaxDownloadBytesPerSecond caps the bandwidth of image pulls.
+	MaxDownloadBytesPerSecond *int64 `json:"maxDownloadBytesPerSecond,omitempty"`
+	// MaxProcs is a hint for the number of CPUs that the agent process uses (GOMAXPROCS).
+	MaxProcs *int `json:"maxProcs,omitempty"`
+	// NiceLevel is the scheduling priority of the agent process, from 0 (default) to 19 (lowest).
+	NiceLevel *int `json:"niceLevel,omitempty"`
+	// LogLevel is one of AgentLogLevels.
+	LogLevel *string `json:"logLevel,omitempty"`
+}
+
+func (l AgentResourceLimits) Validate() error {
+	if l.MaxConcurrentPulls != nil && (*l.MaxConcurrentPulls < 1 || *l.MaxConcurrentPulls > MaxAgentConcurrentPulls) {
+		return validation.NewValidationFailedError(
+			fmt.Sprintf("maxConcurrentPulls must be between 1 and %v", MaxAgentConcurrentPulls))
+	}
+	if l.MaxDownloadBytesPerSecond != nil && *l.MaxDownloadBytesPerSecond < MinAgentDownloadBytesPerSecond {
+		return validation.NewValidationFailedError(
+			fmt.Sprintf("maxDownloadBytesPerSecond must be at least %v", MinAgentDownloadBytesPerSecond))
+	}
+	if l.MaxProcs != nil && (*l.MaxProcs < 1 || *l.MaxProcs > MaxAgentMaxProcs) {
+		return validation.NewValidationFailedError(fmt.Sprintf("maxProcs must be between 1 and %v", MaxAgentMaxProcs))
+	}
+	if l.NiceLevel != nil && (*l.NiceLevel < 0 || *l.NiceLevel > MaxAgentNiceLevel) {
+		return validation.NewValidationFailedError(fmt.Sprintf("niceLevel must be between 0 and %v", MaxAgentNiceLevel))
+	}
+	if l.LogLevel != nil && !slices.Contains(AgentLogLevels, *l.LogLevel) {
+		return validation.NewValidationFailedError(fmt.Sprintf("logLevel must be one of %v", AgentLogLevels))
+	}
+	return nil
+}
+
+// AppliedAgentResourceLimits are the limits that an agent reported after applying the limits it received.
+type AppliedAgentResourceLimits struct {
+	// Limits are the values that are in effect on the host. They can differ from the requested limits, for example
+	// if the agent had to clamp a value.
+	Limits AgentResourceLimits `json:"limits"`
+	// Unsupported lists the JSON names of the requested limits that the agent or its host does not support.
+	Unsupported []string  `json:"unsupported,omitempty"`
+	ReportedAt  time.Time `json:"reportedAt"`
+}
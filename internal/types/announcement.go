@@ -0,0 +1,93 @@
+package types
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/glasskube/distr/internal/validation"
+	"github.com/google/uuid"
+)
+
+type (
+	AnnouncementSeverity string
+	AnnouncementAudience string
+)
+
+const (
+	AnnouncementSeverityInfo     AnnouncementSeverity = "info"
+	AnnouncementSeverityWarning  AnnouncementSeverity = "warning"
+	AnnouncementSeverityCritical AnnouncementSeverity = "critical"
+
+	AnnouncementAudienceAllCustomers      AnnouncementAudience = "all_customers"
+	AnnouncementAudienceCustomers         AnnouncementAudience = "customers"
+	AnnouncementAudienceDeploymentTargets AnnouncementAudience = "deployment_targets"
+)
+
+// Announcement is a message of a vendor to its customers, e.g. about planned maintenance, which is shown in the
+// portal between StartsAt and EndsAt. The Body is formatted as markdown.
+// Depending on the Audience, it is shown to all customers, to the customer accounts in CustomerIDs or to the owners
+// of the deployment targets in DeploymentTargetIDs.
+type Announcement struct {
+	Base
+	OrganizationID         uuid.UUID            `db:"organization_id" json:"-"`
+	CreatedByUserAccountID *uuid.UUID           `db:"created_by_useraccount_id" json:"-"`
+	Title                  string               `db:"title" json:"title"`
+	Body                   string               `db:"body" json:"body"`
+	Severity               AnnouncementSeverity `db:"severity" json:"severity"`
+	Audience               AnnouncementAudience `db:"audience" json:"audience"`
+	CustomerIDs            []uuid.UUID          `db:"customer_ids" json:"customerIds"`
+	DeploymentTargetIDs    []uuid.UUID          `db:"deployment_target_ids" json:"deploymentTargetIds"`
+	StartsAt               time.Time            `db:"starts_at" json:"startsAt"`
+	EndsAt                 time.Time            `db:"ends_at" json:"endsAt"`
+}
+
+func (a *Announcement) Validate() error {
+	if strings.TrimSpace(a.Title) == "" {
+		return validation.NewValidationFailedError("title must not be empty")
+	}
+	if !slices.Contains(
+		[]AnnouncementSeverity{AnnouncementSeverityInfo, AnnouncementSeverityWarning, AnnouncementSeverityCritical},
+		a.Severity,
+	) {
+		return validation.NewValidationFailedError("severity must be info, warning or critical")
+	}
+	switch a.Audience {
+	case AnnouncementAudienceAllCustomers:
+		if len(a.CustomerIDs) > 0 || len(a.DeploymentTargetIDs) > 0 {
+			return validation.NewValidationFailedError(
+				"customerIds and deploymentTargetIds must be empty for audience all_customers",
+			)
+		}
+	case AnnouncementAudienceCustomers:
+		if len(a.CustomerIDs) == 0 || len(a.DeploymentTargetIDs) > 0 {
+			return validation.NewValidationFailedError(
+				"audience customers requires customerIds and no deploymentTargetIds",
+			)
+		}
+	case AnnouncementAudienceDeploymentTargets:
+		if len(a.DeploymentTargetIDs) == 0 || len(a.CustomerIDs) > 0 {
+			return validation.NewValidationFailedError(
+				"audience deployment_targets requires deploymentTargetIds and no customerIds",
+			)
+		}
+	default:
+		return validation.NewValidationFailedError("audience must be all_customers, customers or deployment_targets")
+	}
+	if !a.StartsAt.Before(a.EndsAt) {
+		return validation.NewValidationFailedError("endsAt must be after startsAt")
+	}
+	return nil
+}
+
+// IsActive reports whether the announcement is shown at the given time.
+func (a *Announcement) IsActive(now time.Time) bool {
+	return !now.Before(a.StartsAt) && now.Before(a.EndsAt)
+}
+
+// UserAnnouncement is an announcement together with the receipt of the user it is shown to.
+type UserAnnouncement struct {
+	Announcement
+	ReadAt      *time.Time `db:"read_at" json:"readAt,omitempty"`
+	DismissedAt *time.Time `db:"dismissed_at" json:"dismissedAt,omitempty"`
+}
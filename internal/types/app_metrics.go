@@ -0,0 +1,156 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AppMetricAlertOperator string
+
+const (
+	AppMetricAlertOperatorGreaterThan        AppMetricAlertOperator = "gt"
+	AppMetricAlertOperatorGreaterThanOrEqual AppMetricAlertOperator = "gte"
+	AppMetricAlertOperatorLessThan           AppMetricAlertOperator = "lt"
+	AppMetricAlertOperatorLessThanOrEqual    AppMetricAlertOperator = "lte"
+	AppMetricAlertOperatorEqual              AppMetricAlertOperator = "eq"
+	AppMetricAlertOperatorNotEqual           AppMetricAlertOperator = "ne"
+
+	// MaxAppMetricsSeriesAllowList is the maximum number of metric names a vendor can allow per application version.
+	MaxAppMetricsSeriesAllowList = 50
+)
+
+// Symbol returns the comparison operator in mathematical notation, e.g. ">=" for gte.
+func (o AppMetricAlertOperator) Symbol() string {
+	switch o {
+	case AppMetricAlertOperatorGreaterThan:
+		return ">"
+	case AppMetricAlertOperatorGreaterThanOrEqual:
+		return ">="
+	case AppMetricAlertOperatorLessThan:
+		return "<"
+	case AppMetricAlertOperatorLessThanOrEqual:
+		return "<="
+	case AppMetricAlertOperatorEqual:
+		return "=="
+	case AppMetricAlertOperatorNotEqual:
+		return "!="
+	default:
+		return string(o)
+	}
+}
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// ApplicationMetricsEndpoint declares the Prometheus metrics endpoint of an application. Agents scrape it and relay
+// all series whose metric name is contained in Series to the server.
+type ApplicationMetricsEndpoint struct {
+	// Service is the name of the Kubernetes service exposing the endpoint. It is not used for docker deployments,
+	// where the endpoint is scraped on the host network.
+	Service string   `json:"service,omitempty"`
+	Port    int      `json:"port"`
+	Path    string   `json:"path"`
+	Series  []string `json:"series"`
+}
+
+func (e ApplicationMetricsEndpoint) Validate() error {
+	if e.Port < 1 || e.Port > 65535 {
+		return errors.New("port must be between 1 and 65535")
+	} else if !strings.HasPrefix(e.Path, "/") {
+		return errors.New("path must start with /")
+	} else if len(e.Series) == 0 {
+		return errors.New("series must not be empty")
+	} else if len(e.Series) > MaxAppMetricsSeriesAllowList {
+		return fmt.Errorf("series must not contain more than %v metric names", MaxAppMetricsSeriesAllowList)
+	}
+	for _, name := range e.Series {
+		if !metricNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid metric name: %v", name)
+		}
+	}
+	return nil
+}
+
+// Allows returns true if series with the given metric name may be relayed to the server.
+func (e ApplicationMetricsEndpoint) Allows(name string) bool {
+	return slices.Contains(e.Series, name)
+}
+
+// AppMetricSeries is a single sample of a metric scraped from an application.
+type AppMetricSeries struct {
+	Name   string            `db:"name" json:"name"`
+	Labels map[string]string `db:"labels" json:"labels,omitempty"`
+	Value  float64           `db:"value" json:"value"`
+}
+
+// DeploymentAppMetric is the latest sample of a series relayed by the agent of a deployment.
+type DeploymentAppMetric struct {
+	AppMetricSeries
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// ApplicationMetricAlertRule fires for a deployment of the application if any series of Metric has satisfied the
+// comparison with Value for at least ForSeconds.
+type ApplicationMetricAlertRule struct {
+	ID            uuid.UUID              `db:"id" json:"id"`
+	CreatedAt     time.Time              `db:"created_at" json:"createdAt"`
+	ApplicationID uuid.UUID              `db:"application_id" json:"applicationId"`
+	Metric        string                 `db:"metric" json:"metric"`
+	Operator      AppMetricAlertOperator `db:"operator" json:"operator"`
+	Value         float64                `db:"value" json:"value"`
+	ForSeconds    int                    `db:"for_seconds" json:"forSeconds"`
+}
+
+func (r ApplicationMetricAlertRule) Validate() error {
+	if !metricNamePattern.MatchString(r.Metric) {
+		return fmt.Errorf("invalid metric name: %v", r.Metric)
+	} else if r.ForSeconds < 0 {
+		return errors.New("forSeconds must not be negative")
+	}
+	switch r.Operator {
+	case AppMetricAlertOperatorGreaterThan, AppMetricAlertOperatorGreaterThanOrEqual, AppMetricAlertOperatorLessThan,
+		AppMetricAlertOperatorLessThanOrEqual, AppMetricAlertOperatorEqual, AppMetricAlertOperatorNotEqual:
+		return nil
+	default:
+		return fmt.Errorf("invalid operator: %v", r.Operator)
+	}
+}
+
+// For returns the duration the condition must hold before the rule fires.
+func (r ApplicationMetricAlertRule) For() time.Duration {
+	return time.Duration(r.ForSeconds) * time.Second
+}
+
+// Matches returns true if value satisfies the condition of the rule.
+func (r ApplicationMetricAlertRule) Matches(value float64) bool {
+	switch r.Operator {
+	case AppMetricAlertOperatorGreaterThan:
+		return value > r.Value
+	case AppMetricAlertOperatorGreaterThanOrEqual:
+		return value >= r.Value
+	case AppMetricAlertOperatorLessThan:
+		return value < r.Value
+	case AppMetricAlertOperatorLessThanOrEqual:
+		return value <= r.Value
+	case AppMetricAlertOperatorEqual:
+		return value == r.Value
+	case AppMetricAlertOperatorNotEqual:
+		return value != r.Value
+	default:
+		return false
+	}
+}
+
+// DeploymentAppMetricAlert is the state of an alert rule for a single deployment.
+// PendingSince is set while the condition holds, FiringSince once it has held for the duration of the rule.
+type DeploymentAppMetricAlert struct {
+	ApplicationMetricAlertRuleID uuid.UUID  `db:"application_metric_alert_rule_id" json:"ruleId"`
+	DeploymentID                 uuid.UUID  `db:"deployment_id" json:"deploymentId"`
+	PendingSince                 *time.Time `db:"pending_since" json:"pendingSince,omitempty"`
+	FiringSince                  *time.Time `db:"firing_since" json:"firingSince,omitempty"`
+}
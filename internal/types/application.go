@@ -7,11 +7,39 @@ import (
 )
 
 type Application struct {
-	ID             uuid.UUID            `db:"id" json:"id"`
-	CreatedAt      time.Time            `db:"created_at" json:"createdAt"`
-	OrganizationID uuid.UUID            `db:"organization_id" json:"-"`
-	Name           string               `db:"name" json:"name"`
-	Type           DeploymentType       `db:"type" json:"type"`
-	ImageID        *uuid.UUID           `db:"image_id" json:"-"`
-	Versions       []ApplicationVersion `db:"versions" json:"versions"`
+	ID                   uuid.UUID                `db:"id" json:"id"`
+	CreatedAt            time.Time                `db:"created_at" json:"createdAt"`
+	OrganizationID       uuid.UUID                `db:"organization_id" json:"-"`
+	Name                 string                   `db:"name" json:"name"`
+	Type                 DeploymentType           `db:"type" json:"type"`
+	ImageID              *uuid.UUID               `db:"image_id" json:"-"`
+	ResourceRequirements *ResourceRequirements    `db:"resource_requirements" json:"resourceRequirements,omitempty"`
+	VersionPolicy        ApplicationVersionPolicy `db:"version_policy" json:"versionPolicy"`
+	// DeletedAt is set while the application is deleted but can still be restored. Deleted applications remain
+	// resolvable by their ID for the history of deployments, but are omitted from listings and licensing.
+	DeletedAt              *time.Time           `db:"deleted_at" json:"deletedAt,omitempty"`
+	DeletedByUserAccountID *uuid.UUID           `db:"deleted_by_useraccount_id" json:"-"`
+	DeletionScheduledAt    *time.Time           `db:"deletion_scheduled_at" json:"deletionScheduledAt,omitempty"`
+	Versions               []ApplicationVersion `db:"versions" json:"versions"`
+}
+
+func (a *Application) IsDeleted() bool {
+	return a.DeletedAt != nil
+}
+
+// ApplicationDeletionImpact lists the active deployments of an application, which block its deletion.
+type ApplicationDeletionImpact struct {
+	Deployments []ApplicationDeletionDeployment `json:"deployments"`
+}
+
+func (i *ApplicationDeletionImpact) IsEmpty() bool {
+	return len(i.Deployments) == 0
+}
+
+type ApplicationDeletionDeployment struct {
+	DeploymentID         uuid.UUID  `db:"deployment_id" json:"deploymentId"`
+	DeploymentTargetID   uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	DeploymentTargetName string     `db:"deployment_target_name" json:"deploymentTargetName"`
+	ReleaseName          *string    `db:"release_name" json:"releaseName,omitempty"`
+	UninstallRequestedAt *time.Time `db:"uninstall_requested_at" json:"uninstallRequestedAt,omitempty"`
 }
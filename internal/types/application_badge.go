@@ -0,0 +1,28 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ApplicationBadge holds the capability token of a public status badge together with the aggregated data that is
+// shown on it. The data is recomputed periodically, so that serving a badge never has to query deployments.
+type ApplicationBadge struct {
+	ApplicationID uuid.UUID `db:"application_id" json:"applicationId"`
+	CreatedAt     time.Time `db:"created_at" json:"createdAt"`
+	TokenHash     []byte    `db:"token_hash" json:"-"`
+	TokenPrefix   string    `db:"token_prefix" json:"tokenPrefix"`
+	LatestVersion *string   `db:"latest_version" json:"latestVersion"`
+	// InstallationCount is nil if there are too few customers to publish the count without revealing them
+	InstallationCount *int       `db:"installation_count" json:"installationCount"`
+	RefreshedAt       *time.Time `db:"refreshed_at" json:"refreshedAt"`
+}
+
+// ApplicationBadgeWithApplication is used to serve a public badge. OrganizationDisabled is true if the organization
+// that owns the application has turned off status badges.
+type ApplicationBadgeWithApplication struct {
+	ApplicationBadge
+	ApplicationName      string `db:"application_name"`
+	OrganizationDisabled bool   `db:"status_badges_disabled"`
+}
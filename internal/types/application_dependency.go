@@ -0,0 +1,32 @@
+package types
+
+import (
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/uuid"
+)
+
+// ApplicationDependency declares that an application can only be deployed to a deployment target that already runs
+// the application it depends on.
+type ApplicationDependency struct {
+	ApplicationID            uuid.UUID `db:"application_id" json:"applicationId"`
+	DependsOnApplicationID   uuid.UUID `db:"depends_on_application_id" json:"dependsOnApplicationId"`
+	DependsOnApplicationName string    `db:"depends_on_application_name" json:"dependsOnApplicationName"`
+	// MinVersion is the lowest semantic version of the dependency that satisfies it. Any version does if it is nil.
+	MinVersion *string   `db:"min_version" json:"minVersion,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+}
+
+// IsSatisfiedBy reports whether a deployment of the dependency with the given version name satisfies d.
+func (d ApplicationDependency) IsSatisfiedBy(versionName string) bool {
+	if d.MinVersion == nil {
+		return true
+	}
+	minVersion, err := semver.NewVersion(*d.MinVersion)
+	if err != nil {
+		return false
+	}
+	version, err := semver.NewVersion(versionName)
+	return err == nil && !version.LessThan(minVersion)
+}
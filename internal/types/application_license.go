@@ -1,6 +1,7 @@
 package types
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +18,7 @@ type ApplicationLicenseBase struct {
 	RegistryURL        *string    `db:"registry_url" json:"registryUrl,omitempty"`
 	RegistryUsername   *string    `db:"registry_username" json:"registryUsername,omitempty"`
 	RegistryPassword   *string    `db:"registry_password" json:"registryPassword,omitempty"`
+	SeatCount          *int       `db:"seat_count" json:"seatCount,omitempty"`
 }
 
 type ApplicationLicenseWithVersions struct {
@@ -26,8 +28,10 @@ type ApplicationLicenseWithVersions struct {
 
 type ApplicationLicense struct {
 	ApplicationLicenseWithVersions
-	Application Application  `db:"application" json:"application"`
-	Owner       *UserAccount `db:"owner" json:"owner,omitempty"`
+	Application   Application  `db:"application" json:"application"`
+	Owner         *UserAccount `db:"owner" json:"owner,omitempty"`
+	SeatHolderIDs []uuid.UUID  `db:"seat_holder_ids" json:"-"`
+	SeatsUsed     int          `db:"seats_used" json:"seatsUsed"`
 }
 
 func (license *ApplicationLicenseWithVersions) HasVersionWithID(id uuid.UUID) bool {
@@ -38,3 +42,11 @@ func (license *ApplicationLicenseWithVersions) HasVersionWithID(id uuid.UUID) bo
 	}
 	return false
 }
+
+// IsHeldBy reports whether the user owns the license or holds one of its seats.
+func (license *ApplicationLicense) IsHeldBy(userID uuid.UUID) bool {
+	if license.OwnerUserAccountID != nil && *license.OwnerUserAccountID == userID {
+		return true
+	}
+	return slices.Contains(license.SeatHolderIDs, userID)
+}
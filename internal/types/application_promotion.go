@@ -0,0 +1,104 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	PromotionCheckType    string
+	VulnerabilitySeverity string
+)
+
+const (
+	PromotionCheckTypeVulnerabilityScan  PromotionCheckType = "vulnerabilityScan"
+	PromotionCheckTypeStagingDeployments PromotionCheckType = "stagingDeployments"
+	PromotionCheckTypeManualApproval     PromotionCheckType = "manualApproval"
+
+	VulnerabilitySeverityCritical VulnerabilitySeverity = "critical"
+	VulnerabilitySeverityHigh     VulnerabilitySeverity = "high"
+	VulnerabilitySeverityMedium   VulnerabilitySeverity = "medium"
+	VulnerabilitySeverityLow      VulnerabilitySeverity = "low"
+)
+
+// PromotionCheck is the configuration of a single check that must pass before a version can be promoted.
+// Which of the optional fields are used depends on the Type.
+type PromotionCheck struct {
+	Type PromotionCheckType `json:"type"`
+	// Severity is the lowest severity of vulnerability findings that lets a vulnerabilityScan check fail.
+	Severity *VulnerabilitySeverity `json:"severity,omitempty"`
+	// MinCount is the number of successful deployments or approvals required.
+	MinCount *int `json:"minCount,omitempty"`
+	// DeploymentTargetIDs are the staging targets considered by a stagingDeployments check.
+	DeploymentTargetIDs []uuid.UUID `json:"deploymentTargetIds,omitempty"`
+	// Role restricts which users can approve a version for a manualApproval check.
+	Role *UserRole `json:"role,omitempty"`
+}
+
+type ApplicationPromotionRule struct {
+	ID            uuid.UUID        `db:"id" json:"id"`
+	CreatedAt     time.Time        `db:"created_at" json:"createdAt"`
+	ApplicationID uuid.UUID        `db:"application_id" json:"applicationId"`
+	Channel       string           `db:"channel" json:"channel"`
+	Checks        []PromotionCheck `db:"checks" json:"checks"`
+}
+
+type ApplicationVersionScan struct {
+	ID                   uuid.UUID `db:"id" json:"id"`
+	CreatedAt            time.Time `db:"created_at" json:"createdAt"`
+	ApplicationVersionID uuid.UUID `db:"application_version_id" json:"applicationVersionId"`
+	Scanner              *string   `db:"scanner" json:"scanner,omitempty"`
+	Critical             int       `db:"critical" json:"critical"`
+	High                 int       `db:"high" json:"high"`
+	Medium               int       `db:"medium" json:"medium"`
+	Low                  int       `db:"low" json:"low"`
+}
+
+// CountAtLeast returns the number of findings with the given severity or higher.
+func (s ApplicationVersionScan) CountAtLeast(severity VulnerabilitySeverity) int {
+	switch severity {
+	case VulnerabilitySeverityCritical:
+		return s.Critical
+	case VulnerabilitySeverityHigh:
+		return s.Critical + s.High
+	case VulnerabilitySeverityMedium:
+		return s.Critical + s.High + s.Medium
+	default:
+		return s.Critical + s.High + s.Medium + s.Low
+	}
+}
+
+type ApplicationVersionApproval struct {
+	ID                   uuid.UUID `db:"id" json:"id"`
+	CreatedAt            time.Time `db:"created_at" json:"createdAt"`
+	ApplicationVersionID uuid.UUID `db:"application_version_id" json:"applicationVersionId"`
+	UserAccountID        uuid.UUID `db:"user_account_id" json:"userAccountId"`
+	UserRole             UserRole  `db:"user_role" json:"userRole"`
+}
+
+type PromotionCheckResult struct {
+	Type    PromotionCheckType `json:"type"`
+	Passed  bool               `json:"passed"`
+	Message string             `json:"message"`
+}
+
+// ApplicationVersionPromotion records the evaluation of a promotion request, regardless of whether it succeeded.
+type ApplicationVersionPromotion struct {
+	ID                   uuid.UUID              `db:"id" json:"id"`
+	CreatedAt            time.Time              `db:"created_at" json:"createdAt"`
+	ApplicationVersionID uuid.UUID              `db:"application_version_id" json:"applicationVersionId"`
+	UserAccountID        *uuid.UUID             `db:"user_account_id" json:"userAccountId,omitempty"`
+	Channel              string                 `db:"channel" json:"channel"`
+	Promoted             bool                   `db:"promoted" json:"promoted"`
+	Results              []PromotionCheckResult `db:"results" json:"results"`
+}
+
+// ApplicationChannel points to the version that has been promoted to the channel most recently.
+type ApplicationChannel struct {
+	ApplicationID        uuid.UUID  `db:"application_id" json:"applicationId"`
+	Channel              string     `db:"channel" json:"channel"`
+	ApplicationVersionID uuid.UUID  `db:"application_version_id" json:"applicationVersionId"`
+	PromotionID          *uuid.UUID `db:"promotion_id" json:"promotionId,omitempty"`
+	PromotedAt           time.Time  `db:"promoted_at" json:"promotedAt"`
+}
@@ -11,15 +11,20 @@ import (
 
 type ApplicationVersion struct {
 	// unfortunately Base nested type doesn't work when ApplicationVersion is a nested row in an SQL query
-	ID            uuid.UUID      `db:"id" json:"id"`
-	CreatedAt     time.Time      `db:"created_at" json:"createdAt"`
-	ArchivedAt    *time.Time     `db:"archived_at" json:"archivedAt,omitempty"`
-	Name          string         `db:"name" json:"name"`
-	ApplicationID uuid.UUID      `db:"application_id" json:"applicationId"`
-	ChartType     *HelmChartType `db:"chart_type" json:"chartType,omitempty"`
-	ChartName     *string        `db:"chart_name" json:"chartName,omitempty"`
-	ChartUrl      *string        `db:"chart_url" json:"chartUrl,omitempty"`
-	ChartVersion  *string        `db:"chart_version" json:"chartVersion,omitempty"`
+	ID                   uuid.UUID                   `db:"id" json:"id"`
+	CreatedAt            time.Time                   `db:"created_at" json:"createdAt"`
+	ArchivedAt           *time.Time                  `db:"archived_at" json:"archivedAt,omitempty"`
+	Name                 string                      `db:"name" json:"name"`
+	ApplicationID        uuid.UUID                   `db:"application_id" json:"applicationId"`
+	ChartType            *HelmChartType              `db:"chart_type" json:"chartType,omitempty"`
+	ChartName            *string                     `db:"chart_name" json:"chartName,omitempty"`
+	ChartUrl             *string                     `db:"chart_url" json:"chartUrl,omitempty"`
+	ChartVersion         *string                     `db:"chart_version" json:"chartVersion,omitempty"`
+	ResourceRequirements *ResourceRequirements       `db:"resource_requirements" json:"resourceRequirements,omitempty"`
+	MetricsEndpoint      *ApplicationMetricsEndpoint `db:"metrics_endpoint" json:"metricsEndpoint,omitempty"`
+	// AcknowledgmentMessage is shown to customers before this version is deployed to their deployment targets.
+	// If it is set, deployments of this version remain pending until a customer user has acknowledged them.
+	AcknowledgmentMessage *string `db:"acknowledgment_message" json:"acknowledgmentMessage,omitempty"`
 
 	// awful but relevant: the following must be defined after the ChartType, because somehow order matters
 	// for pgx at collecting the subrows (relevant at getting application + list of its versions with these
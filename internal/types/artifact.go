@@ -1,6 +1,7 @@
 package types
 
 import (
+	"path"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +13,53 @@ type Artifact struct {
 	OrganizationID uuid.UUID  `db:"organization_id" json:"-"`
 	Name           string     `db:"name" json:"name"`
 	ImageID        *uuid.UUID `db:"image_id" json:"-"`
+	// DeletionRequestedAt is set while the artifact is pending deletion. Pushes are rejected during that time.
+	DeletionRequestedAt              *time.Time `db:"deletion_requested_at" json:"deletionRequestedAt,omitempty"`
+	DeletionRequestedByUserAccountID *uuid.UUID `db:"deletion_requested_by_useraccount_id" json:"-"`
+	DeletionScheduledAt              *time.Time `db:"deletion_scheduled_at" json:"deletionScheduledAt,omitempty"`
+	// RecommendedArtifactVersionID points at the tag or digest that customers should use if they do not need a
+	// specific version. It is served by the registry as ArtifactRecommendedTag.
+	RecommendedArtifactVersionID *uuid.UUID `db:"recommended_artifact_version_id" json:"recommendedVersionId,omitempty"`
+	// RecommendedReference is the tag or digest of the recommended version.
+	RecommendedReference *string `db:"recommended_reference" json:"recommendedReference,omitempty"`
+	// Public artifacts can be pulled from the registry without authentication.
+	Public bool `db:"public" json:"public"`
+	// ImmutableTags keeps pushes from moving an existing tag to a different manifest. Tags that match one of the
+	// MutableTagPatterns, such as "latest" or "*-snapshot", can still be moved.
+	ImmutableTags      bool     `db:"immutable_tags" json:"immutableTags"`
+	MutableTagPatterns []string `db:"mutable_tag_patterns" json:"mutableTagPatterns"`
+	// UpstreamRepository is set if the artifact is a pull-through cache of a repository in another registry.
+	UpstreamRepository *string `db:"upstream_repository" json:"upstreamRepository,omitempty"`
+}
+
+// ArtifactRecommendedTag is a virtual tag that the registry resolves to the recommended version of an artifact. It
+// can not be pushed.
+const ArtifactRecommendedTag = "recommended"
+
+func (a *Artifact) IsPendingDeletion() bool {
+	return a.DeletionRequestedAt != nil
+}
+
+// IsTagImmutable returns true if tag must not be moved to a different manifest. Patterns are matched with path.Match.
+func (a *Artifact) IsTagImmutable(tag string) bool {
+	if !a.ImmutableTags {
+		return false
+	}
+	for _, pattern := range a.MutableTagPatterns {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ArtifactDeletionImpact lists everything that references an artifact and is affected by its deletion.
+type ArtifactDeletionImpact struct {
+	Licenses []ArtifactLicenseBase `json:"licenses"`
+}
+
+func (i *ArtifactDeletionImpact) IsEmpty() bool {
+	return len(i.Licenses) == 0
 }
 
 type DownloadMetrics struct {
@@ -51,3 +99,13 @@ type ArtifactWithTaggedVersion struct {
 	ArtifactWithDownloads
 	Versions []TaggedArtifactVersion `db:"versions" json:"versions,omitempty"`
 }
+
+// ArtifactAlias is a former name of an artifact that can still be used to pull it until it expires.
+type ArtifactAlias struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	CreatedAt      time.Time `db:"created_at" json:"createdAt"`
+	OrganizationID uuid.UUID `db:"organization_id" json:"-"`
+	ArtifactID     uuid.UUID `db:"artifact_id" json:"artifactId"`
+	Name           string    `db:"name" json:"name"`
+	ExpiresAt      time.Time `db:"expires_at" json:"expiresAt"`
+}
@@ -13,6 +13,7 @@ type ArtifactLicenseBase struct {
 	ExpiresAt          *time.Time `db:"expires_at" json:"expiresAt,omitempty"`
 	OrganizationID     uuid.UUID  `db:"organization_id" json:"-"`
 	OwnerUserAccountID *uuid.UUID `db:"owner_useraccount_id" json:"ownerUserAccountId,omitempty"`
+	SeatCount          *int       `db:"seat_count" json:"seatCount,omitempty"`
 }
 
 type ArtifactLicenseSelection struct {
@@ -23,4 +24,5 @@ type ArtifactLicenseSelection struct {
 type ArtifactLicense struct {
 	ArtifactLicenseBase
 	Artifacts []ArtifactLicenseSelection `db:"artifacts" json:"artifacts,omitempty"`
+	SeatsUsed int                        `db:"seats_used" json:"seatsUsed"`
 }
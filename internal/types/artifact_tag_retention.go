@@ -0,0 +1,68 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArtifactTagRetentionRule removes the tags of an artifact that match TagPattern and are no longer needed. A tag is
+// removed if it is not one of the KeepLast most recently pushed matching tags and, if UnpulledDays is set, it has
+// been pushed more than UnpulledDays ago and has never been pulled. At least one of KeepLast and UnpulledDays is set.
+type ArtifactTagRetentionRule struct {
+	ID                     uuid.UUID  `db:"id" json:"id"`
+	CreatedAt              time.Time  `db:"created_at" json:"createdAt"`
+	ArtifactID             uuid.UUID  `db:"artifact_id" json:"artifactId"`
+	OrganizationID         uuid.UUID  `db:"organization_id" json:"-"`
+	CreatedByUserAccountID *uuid.UUID `db:"created_by_user_account_id" json:"-"`
+	// TagPattern is matched with path.Match, e.g. "*-rc*" or "*" for all tags.
+	TagPattern   string `db:"tag_pattern" json:"tagPattern"`
+	KeepLast     *int   `db:"keep_last" json:"keepLast,omitempty"`
+	UnpulledDays *int   `db:"unpulled_days" json:"unpulledDays,omitempty"`
+	// DryRun rules are evaluated by the retention job, but only report the tags they would remove.
+	DryRun          bool                        `db:"dry_run" json:"dryRun"`
+	LastEvaluatedAt *time.Time                  `db:"last_evaluated_at" json:"lastEvaluatedAt,omitempty"`
+	LastReport      *ArtifactTagRetentionReport `db:"last_report" json:"lastReport,omitempty"`
+}
+
+type ArtifactTagRetentionProtection string
+
+const (
+	// ArtifactTagRetentionProtectionLicense protects tags that are assigned to an artifact license.
+	ArtifactTagRetentionProtectionLicense ArtifactTagRetentionProtection = "license"
+	// ArtifactTagRetentionProtectionRecommended protects the recommended version of the artifact.
+	ArtifactTagRetentionProtectionRecommended ArtifactTagRetentionProtection = "recommended"
+	// ArtifactTagRetentionProtectionDeployment protects tags that are used by an active deployment or are reported in
+	// the inventory of a deployment target.
+	ArtifactTagRetentionProtectionDeployment ArtifactTagRetentionProtection = "deployment"
+)
+
+// ArtifactTagRetentionReport is the result of evaluating an ArtifactTagRetentionRule.
+type ArtifactTagRetentionReport struct {
+	EvaluatedAt time.Time `json:"evaluatedAt"`
+	DryRun      bool      `json:"dryRun"`
+	// Removed are the tags that have been removed, or would have been removed in a dry run.
+	Removed []string `json:"removed"`
+	// Protected are the tags that would have been removed, but are still in use.
+	Protected []ArtifactTagRetentionProtectedTag `json:"protected"`
+	// Failed are the tags that could not be removed. They are retried in the next evaluation.
+	Failed []string `json:"failed,omitempty"`
+}
+
+type ArtifactTagRetentionProtectedTag struct {
+	Tag    string                         `json:"tag"`
+	Reason ArtifactTagRetentionProtection `json:"reason"`
+}
+
+// ArtifactTagRetentionCandidate is a tag of an artifact with everything that is needed to evaluate retention rules.
+type ArtifactTagRetentionCandidate struct {
+	ID                 uuid.UUID `db:"id"`
+	Name               string    `db:"name"`
+	ManifestBlobDigest Digest    `db:"manifest_blob_digest"`
+	// PushedAt is the time the tag has been created or last moved to a different manifest.
+	PushedAt time.Time `db:"pushed_at"`
+	// LastPulledAt is the last pull of the tag or of any other reference to its manifest.
+	LastPulledAt *time.Time `db:"last_pulled_at"`
+	Licensed     bool       `db:"licensed"`
+	Recommended  bool       `db:"recommended"`
+}
@@ -0,0 +1,20 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArtifactUpstream makes an artifact a pull-through cache of a repository in another registry. Manifests and blobs
+// that are missing in the artifact are fetched from the upstream repository on demand.
+type ArtifactUpstream struct {
+	ArtifactID             uuid.UUID  `db:"artifact_id" json:"artifactId"`
+	CreatedAt              time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt              time.Time  `db:"updated_at" json:"updatedAt"`
+	UpdatedByUserAccountID *uuid.UUID `db:"updated_by_user_account_id" json:"-"`
+	// Repository includes the registry host, e.g. ghcr.io/glasskube/distr.
+	Repository string  `db:"repository" json:"repository"`
+	Username   *string `db:"username" json:"username,omitempty"`
+	Password   *string `db:"password" json:"-"`
+}
@@ -1,11 +1,63 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Token subjects of artifact pulls. The subject of a pull with a personal access token is TokenSubjectAccessToken
+// followed by the display prefix of the token, the subject of a pull by an agent is TokenSubjectDeploymentTarget
+// followed by the ID of the deployment target and the subject of a pull by a service account is
+// TokenSubjectServiceAccount followed by the display prefix of its token.
+const (
+	TokenSubjectAccessToken      = "access_token:"
+	TokenSubjectDeploymentTarget = "deployment_target:"
+	TokenSubjectServiceAccount   = "service_account:"
+	TokenSubjectAnonymous        = "anonymous"
+)
 
 type ArtifactVersionPull struct {
-	CreatedAt       time.Time       `json:"createdAt"`
-	RemoteAddress   *string         `json:"remoteAddress,omitempty"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"createdAt"`
+	RemoteAddress *string   `json:"remoteAddress,omitempty"`
+	// Method is GET for pulls of the manifest and HEAD for requests that only check whether it exists.
+	Method          string          `json:"method"`
+	TokenSubject    *string         `json:"tokenSubject,omitempty"`
 	UserAccount     *UserAccount    `json:"userAccount,omitempty"`
+	ServiceAccount  *ServiceAccount `json:"serviceAccount,omitempty"`
 	Artifact        Artifact        `json:"artifact"`
 	ArtifactVersion ArtifactVersion `json:"artifactVersion"`
 }
+
+// ArtifactDownloadCount is the number of pulls and HEAD requests of an artifact. Only pulls count as downloads.
+type ArtifactDownloadCount struct {
+	Pulls        int64 `db:"pulls" json:"pulls"`
+	HeadRequests int64 `db:"head_requests" json:"headRequests"`
+}
+
+// ArtifactDownloadsByTag are the downloads by the reference that was pulled, which is a digest for pulls by digest.
+type ArtifactDownloadsByTag struct {
+	Tag string `db:"tag" json:"tag"`
+	ArtifactDownloadCount
+}
+
+type ArtifactDownloadsByDay struct {
+	Day time.Time `db:"day" json:"day"`
+	ArtifactDownloadCount
+}
+
+// ArtifactDownloadsByConsumer are the downloads by a single user or service account with a single token. Both are
+// nil for anonymous pulls and for users that have been deleted.
+type ArtifactDownloadsByConsumer struct {
+	UserAccount    *UserAccount    `db:"user_account" json:"userAccount,omitempty"`
+	ServiceAccount *ServiceAccount `db:"service_account" json:"serviceAccount,omitempty"`
+	TokenSubject   *string         `db:"token_subject" json:"tokenSubject,omitempty"`
+	ArtifactDownloadCount
+}
+
+type ArtifactDownloads struct {
+	ByTag      []ArtifactDownloadsByTag      `json:"byTag"`
+	ByDay      []ArtifactDownloadsByDay      `json:"byDay"`
+	ByConsumer []ArtifactDownloadsByConsumer `json:"byConsumer"`
+}
@@ -0,0 +1,20 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditLogEntry struct {
+	ID             uuid.UUID  `db:"id" json:"id"`
+	CreatedAt      time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID *uuid.UUID `db:"organization_id" json:"-"`
+	UserAccountID  *uuid.UUID `db:"useraccount_id" json:"userAccountId,omitempty"`
+	Action         string     `db:"action" json:"action"`
+	ResourceType   string     `db:"resource_type" json:"resourceType"`
+	ResourceID     uuid.UUID  `db:"resource_id" json:"resourceId"`
+	Data           any        `db:"data" json:"data,omitempty"`
+	// AccessGrantID is the grant under which the action was performed, if any.
+	AccessGrantID *uuid.UUID `db:"access_grant_id" json:"accessGrantId,omitempty"`
+}
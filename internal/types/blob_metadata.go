@@ -0,0 +1,12 @@
+package types
+
+import "time"
+
+// BlobMetadata is recorded for every blob stored in the registry, so that it can be described without accessing the
+// object storage.
+type BlobMetadata struct {
+	Digest      Digest    `db:"digest" json:"digest"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+	Size        int64     `db:"size" json:"size"`
+	ContentType string    `db:"content_type" json:"contentType"`
+}
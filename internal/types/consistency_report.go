@@ -0,0 +1,46 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ConsistencyIssueType string
+
+const (
+	// ConsistencyIssueTypeOrphanedOrganizationUserAccount is an organization membership of a user account or an
+	// organization that does not exist anymore.
+	ConsistencyIssueTypeOrphanedOrganizationUserAccount ConsistencyIssueType = "orphaned_organization_user_account"
+	// ConsistencyIssueTypeDanglingApplicationLicenseOwner is an application license that is owned by a user account
+	// that is not a member of the organization of the license.
+	ConsistencyIssueTypeDanglingApplicationLicenseOwner ConsistencyIssueType = "dangling_application_license_owner"
+	// ConsistencyIssueTypeDanglingArtifactLicenseOwner is an artifact license that is owned by a user account that is
+	// not a member of the organization of the license.
+	ConsistencyIssueTypeDanglingArtifactLicenseOwner ConsistencyIssueType = "dangling_artifact_license_owner"
+	// ConsistencyIssueTypeDanglingDeploymentTargetCreator is a deployment target that was created by a user account
+	// that is not a member of the organization of the deployment target.
+	ConsistencyIssueTypeDanglingDeploymentTargetCreator ConsistencyIssueType = "dangling_deployment_target_creator"
+)
+
+// Repairable reports whether issues of this type are repaired by the consistency check. Deployment targets are never
+// repaired automatically, because a vendor has to decide whether they are deleted or handed over to another user.
+func (t ConsistencyIssueType) Repairable() bool {
+	return t != ConsistencyIssueTypeDanglingDeploymentTargetCreator
+}
+
+type ConsistencyIssue struct {
+	Type           ConsistencyIssueType `db:"type" json:"type"`
+	OrganizationID uuid.UUID            `db:"organization_id" json:"organizationId"`
+	// EntityID is the ID of the license or deployment target. It is nil for organization memberships.
+	EntityID      *uuid.UUID `db:"entity_id" json:"entityId,omitempty"`
+	UserAccountID uuid.UUID  `db:"user_account_id" json:"userAccountId"`
+	Repaired      bool       `db:"-" json:"repaired"`
+}
+
+type ConsistencyReport struct {
+	ID        uuid.UUID          `db:"id" json:"id"`
+	CreatedAt time.Time          `db:"created_at" json:"createdAt"`
+	Repair    bool               `db:"repair" json:"repair"`
+	Issues    []ConsistencyIssue `db:"issues" json:"issues"`
+}
@@ -0,0 +1,40 @@
+package types
+
+import "github.com/google/uuid"
+
+type (
+	CustomFieldType   string
+	CustomFieldTarget string
+)
+
+const (
+	CustomFieldTypeString CustomFieldType = "string"
+	CustomFieldTypeNumber CustomFieldType = "number"
+	CustomFieldTypeBool   CustomFieldType = "bool"
+	CustomFieldTypeEnum   CustomFieldType = "enum"
+
+	CustomFieldTargetDeploymentTarget CustomFieldTarget = "deployment_target"
+	CustomFieldTargetCustomer         CustomFieldTarget = "customer"
+)
+
+// CustomFields holds the values of vendor-defined custom fields, keyed by CustomFieldDefinition.Key.
+type CustomFields map[string]any
+
+type CustomFieldDefinition struct {
+	Base
+	OrganizationID    uuid.UUID         `db:"organization_id" json:"-"`
+	Target            CustomFieldTarget `db:"target" json:"target"`
+	Key               string            `db:"key" json:"key"`
+	Type              CustomFieldType   `db:"type" json:"type"`
+	Required          bool              `db:"required" json:"required"`
+	EnumValues        []string          `db:"enum_values" json:"enumValues"`
+	VisibleToCustomer bool              `db:"visible_to_customer" json:"visibleToCustomer"`
+}
+
+// CustomFieldViolation describes an existing value that does not conform to the current definitions.
+// Violations are only reported, the offending values are never removed automatically.
+type CustomFieldViolation struct {
+	EntityID uuid.UUID `json:"entityId"`
+	Key      string    `json:"key"`
+	Reason   string    `json:"reason"`
+}
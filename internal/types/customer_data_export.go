@@ -0,0 +1,32 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CustomerDataExport is a request for an archive of all data that is stored about a customer. The archive is
+// assembled asynchronously and can be downloaded until ExpiresAt with the link that is sent to the requester.
+type CustomerDataExport struct {
+	ID                       uuid.UUID  `db:"id" json:"id"`
+	CreatedAt                time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID           uuid.UUID  `db:"organization_id" json:"-"`
+	CustomerUserAccountID    uuid.UUID  `db:"customer_user_account_id" json:"customerUserAccountId"`
+	RequestedByUserAccountID *uuid.UUID `db:"requested_by_user_account_id" json:"-"`
+	CompletedAt              *time.Time `db:"completed_at" json:"completedAt,omitempty"`
+	ExpiresAt                *time.Time `db:"expires_at" json:"expiresAt,omitempty"`
+	// Size is the size of the encrypted archive in bytes.
+	Size *int64 `db:"size" json:"size,omitempty"`
+}
+
+// DeploymentTargetDataSummary summarizes the logs and metrics that have been collected from a deployment target.
+type DeploymentTargetDataSummary struct {
+	DeploymentTargetID uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	MetricsCount       int64      `db:"metrics_count" json:"metricsCount"`
+	MetricsFrom        *time.Time `db:"metrics_from" json:"metricsFrom,omitempty"`
+	MetricsTo          *time.Time `db:"metrics_to" json:"metricsTo,omitempty"`
+	LogRecordCount     int64      `db:"log_record_count" json:"logRecordCount"`
+	LogRecordsFrom     *time.Time `db:"log_records_from" json:"logRecordsFrom,omitempty"`
+	LogRecordsTo       *time.Time `db:"log_records_to" json:"logRecordsTo,omitempty"`
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DataCollection restricts the data that the agent of a deployment target collects and reports.
+// The zero value allows all data to be collected.
+type DataCollection struct {
+	LogsDisabled    bool `json:"logsDisabled"`
+	MetricsDisabled bool `json:"metricsDisabled"`
+	// DiagnosticsDisabled prevents connectivity checks from being run and reported.
+	DiagnosticsDisabled bool `json:"diagnosticsDisabled"`
+	// InventoryDisabled prevents the inventory of workloads on the host from being reported.
+	InventoryDisabled bool `json:"inventoryDisabled"`
+}
+
+// Merge returns the stricter combination of d and other, i.e. a category is disabled if it is disabled in either.
+func (d DataCollection) Merge(other DataCollection) DataCollection {
+	return DataCollection{
+		LogsDisabled:        d.LogsDisabled || other.LogsDisabled,
+		MetricsDisabled:     d.MetricsDisabled || other.MetricsDisabled,
+		DiagnosticsDisabled: d.DiagnosticsDisabled || other.DiagnosticsDisabled,
+		InventoryDisabled:   d.InventoryDisabled || other.InventoryDisabled,
+	}
+}
+
+// DeploymentTargetDataPurge is a request to delete the collected data of a deployment target. It is executed
+// asynchronously by the data purge job.
+type DeploymentTargetDataPurge struct {
+	ID                       uuid.UUID  `db:"id" json:"id"`
+	CreatedAt                time.Time  `db:"created_at" json:"createdAt"`
+	DeploymentTargetID       uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	RequestedByUserAccountID *uuid.UUID `db:"requested_by_user_account_id" json:"-"`
+	Logs                     bool       `db:"logs" json:"logs"`
+	Metrics                  bool       `db:"metrics" json:"metrics"`
+	Diagnostics              bool       `db:"diagnostics" json:"diagnostics"`
+	Inventory                bool       `db:"inventory" json:"inventory"`
+	CompletedAt              *time.Time `db:"completed_at" json:"completedAt,omitempty"`
+}
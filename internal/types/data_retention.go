@@ -0,0 +1,64 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DataRetentionCategory string
+
+const (
+	DataRetentionCategoryArtifactAudit  DataRetentionCategory = "artifact_audit"
+	DataRetentionCategoryAdminAudit     DataRetentionCategory = "admin_audit"
+	DataRetentionCategoryDeploymentLogs DataRetentionCategory = "deployment_logs"
+	DataRetentionCategorySecurityEvents DataRetentionCategory = "security_events"
+)
+
+var DataRetentionCategories = []DataRetentionCategory{
+	DataRetentionCategoryArtifactAudit,
+	DataRetentionCategoryAdminAudit,
+	DataRetentionCategoryDeploymentLogs,
+	DataRetentionCategorySecurityEvents,
+}
+
+// DataRetentionPolicy is the number of days for which an organization keeps the data of a category.
+type DataRetentionPolicy struct {
+	OrganizationID uuid.UUID             `db:"organization_id" json:"-"`
+	Category       DataRetentionCategory `db:"category" json:"category"`
+	RetentionDays  int                   `db:"retention_days" json:"retentionDays"`
+	UpdatedAt      time.Time             `db:"updated_at" json:"updatedAt"`
+}
+
+// LegalHold suspends all automated deletion of the data of an organization, or only of the data related to one
+// customer account if UserAccountID is set.
+type LegalHold struct {
+	ID             uuid.UUID  `db:"id" json:"id"`
+	CreatedAt      time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID uuid.UUID  `db:"organization_id" json:"organizationId"`
+	UserAccountID  *uuid.UUID `db:"user_account_id" json:"userAccountId,omitempty"`
+	Reason         string     `db:"reason" json:"reason"`
+}
+
+type LegalHolds []LegalHold
+
+// Covers reports whether the data of the organization that belongs to the given user account, if any, is held.
+func (h LegalHolds) Covers(orgID uuid.UUID, userAccountID *uuid.UUID) bool {
+	for _, hold := range h {
+		if hold.OrganizationID == orgID &&
+			(hold.UserAccountID == nil || (userAccountID != nil && *hold.UserAccountID == *userAccountID)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CoversAnyOf reports whether any data of the organization is held, including holds for single customer accounts.
+func (h LegalHolds) CoversAnyOf(orgID uuid.UUID) bool {
+	for _, hold := range h {
+		if hold.OrganizationID == orgID {
+			return true
+		}
+	}
+	return false
+}
@@ -15,19 +15,45 @@ type Deployment struct {
 	ApplicationLicenseID *uuid.UUID  `db:"application_license_id" json:"applicationLicenseId,omitempty"`
 	DockerType           *DockerType `db:"docker_type" json:"dockerType,omitempty"`
 	LogsEnabled          bool        `db:"logs_enabled" json:"logsEnabled"`
+	ArchivedAt           *time.Time  `db:"archived_at" json:"archivedAt,omitempty"`
+	// UninstallRequestedAt is set when a user requested to uninstall the deployment. The deployment is sent to the
+	// agent with the uninstall instruction until the agent reports that it has been uninstalled.
+	UninstallRequestedAt              *time.Time `db:"uninstall_requested_at" json:"uninstallRequestedAt,omitempty"`
+	UninstallRequestedByUserAccountID *uuid.UUID `db:"uninstall_requested_by_user_account_id" json:"uninstallRequestedByUserAccountId,omitempty"` //nolint:lll
+	// UninstallDeleteData is true if the volumes of the deployment are deleted on uninstall.
+	UninstallDeleteData bool `db:"uninstall_delete_data" json:"uninstallDeleteData"`
+	// UninstalledAt is set when the agent reported that the deployment has been uninstalled. Uninstalled deployments
+	// are kept in the history like archived deployments, but can not be changed anymore.
+	UninstalledAt *time.Time `db:"uninstalled_at" json:"uninstalledAt,omitempty"`
+	// AutoRollbackEnabled overrides whether failed revisions are rolled back automatically. If it is nil, they are
+	// rolled back if the organization has a [Organization.DeploymentAutoRollbackWindowSeconds].
+	AutoRollbackEnabled *bool `db:"auto_rollback_enabled" json:"autoRollbackEnabled"`
+	// AutoRollbackWindowSeconds is how long a revision must report an error before it is rolled back. If it is nil,
+	// the window of the organization is used.
+	AutoRollbackWindowSeconds *int `db:"auto_rollback_window_seconds" json:"autoRollbackWindowSeconds"`
+	Pause
+}
+
+// IsUninstalling reports whether an uninstall has been requested but not yet completed.
+func (d *Deployment) IsUninstalling() bool {
+	return d.UninstallRequestedAt != nil && d.UninstalledAt == nil
 }
 
 type DeploymentWithLatestRevision struct {
 	Deployment
-	DeploymentRevisionID        uuid.UUID                 `db:"deployment_revision_id" json:"deploymentRevisionId"`
-	DeploymentRevisionCreatedAt time.Time                 `db:"deployment_revision_created_at" json:"deploymentRevisionCreatedAt"` //nolint:lll
-	ApplicationID               uuid.UUID                 `db:"application_id" json:"applicationId"`
-	ApplicationName             string                    `db:"application_name" json:"applicationName"`
-	ApplicationVersionID        uuid.UUID                 `db:"application_version_id" json:"applicationVersionId"`
-	ApplicationVersionName      string                    `db:"application_version_name" json:"applicationVersionName"`
-	ValuesYaml                  []byte                    `db:"values_yaml" json:"valuesYaml,omitempty"`
-	EnvFileData                 []byte                    `db:"env_file_data" json:"envFileData,omitempty"`
-	LatestStatus                *DeploymentRevisionStatus `db:"latest_status" json:"latestStatus,omitempty"`
+	DeploymentRevisionID          uuid.UUID                 `db:"deployment_revision_id" json:"deploymentRevisionId"`
+	DeploymentRevisionCreatedAt   time.Time                 `db:"deployment_revision_created_at" json:"deploymentRevisionCreatedAt"` //nolint:lll
+	DeploymentRevisionOperationID uuid.UUID                 `db:"deployment_revision_operation_id" json:"-"`
+	ApplicationID                 uuid.UUID                 `db:"application_id" json:"applicationId"`
+	ApplicationName               string                    `db:"application_name" json:"applicationName"`
+	ApplicationVersionID          uuid.UUID                 `db:"application_version_id" json:"applicationVersionId"`
+	ApplicationVersionName        string                    `db:"application_version_name" json:"applicationVersionName"`
+	ValuesYaml                    []byte                    `db:"values_yaml" json:"valuesYaml,omitempty"`
+	EnvFileData                   []byte                    `db:"env_file_data" json:"envFileData,omitempty"`
+	LatestStatus                  *DeploymentRevisionStatus `db:"latest_status" json:"latestStatus,omitempty"`
+	// Paused is true if the deployment or its deployment target is paused. The latest status of a paused deployment is
+	// still reported by the agent, but it may be caused by manual changes that the agent does not revert.
+	Paused bool `db:"paused" json:"paused"`
 }
 
 func (d DeploymentWithLatestRevision) ParsedValuesFile() (result map[string]any, err error) {
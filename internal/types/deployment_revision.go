@@ -1,6 +1,10 @@
 package types
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type DeploymentRevision struct {
 	Base
@@ -8,4 +12,69 @@ type DeploymentRevision struct {
 	ApplicationVersionID uuid.UUID `db:"application_version_id" json:"applicationVersionId"`
 	ValuesYaml           []byte    `db:"-" json:"valuesYaml,omitempty"`
 	EnvFileData          []byte    `db:"-" json:"-"`
+	Reason               *string   `db:"reason" json:"reason,omitempty"`
+	// OperationID is sent to the agent, which echoes it in all status and log reports that belong to this revision.
+	OperationID uuid.UUID `db:"operation_id" json:"operationId"`
+	// AcknowledgmentRequired is true if the revision updates a deployment of a customer to an application version that
+	// requires acknowledgment. Such a revision is not sent to the agent until it has been acknowledged.
+	AcknowledgmentRequired      bool       `db:"acknowledgment_required" json:"acknowledgmentRequired"`
+	AcknowledgedAt              *time.Time `db:"acknowledged_at" json:"acknowledgedAt,omitempty"`
+	AcknowledgedByUserAccountID *uuid.UUID `db:"acknowledged_by_user_account_id" json:"acknowledgedByUserAccountId,omitempty"` //nolint:lll
+	// AcknowledgmentOverridden is true if a vendor user has released the revision without the acknowledgment of the
+	// customer.
+	AcknowledgmentOverridden bool       `db:"acknowledgment_overridden" json:"acknowledgmentOverridden"`
+	AcknowledgmentRemindedAt *time.Time `db:"acknowledgment_reminded_at" json:"-"`
+	// HealthyAt is the time of the first "ok" status of the revision. Only healthy revisions are targets of an
+	// automatic rollback.
+	HealthyAt *time.Time `db:"healthy_at" json:"healthyAt,omitempty"`
+	// AutomaticRollbackOfRevisionID is set if the revision has been created by an automatic rollback of the failed
+	// revision with this ID.
+	AutomaticRollbackOfRevisionID *uuid.UUID `db:"automatic_rollback_of_revision_id" json:"automaticRollbackOfRevisionId,omitempty"` //nolint:lll
+}
+
+// IsAcknowledgmentPending returns true if the revision must be acknowledged before it can be deployed.
+func (r DeploymentRevision) IsAcknowledgmentPending() bool {
+	return r.AcknowledgmentRequired && r.AcknowledgedAt == nil
+}
+
+type DeploymentRevisionWithVersion struct {
+	DeploymentRevision
+	ApplicationVersionName string `db:"application_version_name" json:"applicationVersionName"`
+}
+
+// PendingDeploymentAcknowledgment is the latest revision of a deployment that has not been acknowledged by the
+// customer yet.
+type PendingDeploymentAcknowledgment struct {
+	DeploymentRevisionID     uuid.UUID  `db:"deployment_revision_id" json:"deploymentRevisionId"`
+	CreatedAt                time.Time  `db:"created_at" json:"createdAt"`
+	DeploymentID             uuid.UUID  `db:"deployment_id" json:"deploymentId"`
+	DeploymentTargetID       uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	DeploymentTargetName     string     `db:"deployment_target_name" json:"deploymentTargetName"`
+	OrganizationID           uuid.UUID  `db:"organization_id" json:"-"`
+	CustomerUserAccountID    *uuid.UUID `db:"customer_user_account_id" json:"customerUserAccountId,omitempty"`
+	CustomerEmail            *string    `db:"customer_email" json:"customerEmail,omitempty"`
+	ApplicationName          string     `db:"application_name" json:"applicationName"`
+	ApplicationVersionName   string     `db:"application_version_name" json:"applicationVersionName"`
+	AcknowledgmentMessage    string     `db:"acknowledgment_message" json:"acknowledgmentMessage"`
+	AcknowledgmentRemindedAt *time.Time `db:"acknowledgment_reminded_at" json:"acknowledgmentRemindedAt,omitempty"`
+}
+
+// DeploymentAutoRollback is the released revision of a deployment that has failed for longer than the automatic
+// rollback window, together with the latest earlier revision that was healthy.
+type DeploymentAutoRollback struct {
+	OrganizationID                 uuid.UUID `db:"organization_id"`
+	DeploymentID                   uuid.UUID `db:"deployment_id"`
+	DeploymentTargetID             uuid.UUID `db:"deployment_target_id"`
+	DeploymentTargetName           string    `db:"deployment_target_name"`
+	CustomerEmail                  *string   `db:"customer_email"`
+	ApplicationName                string    `db:"application_name"`
+	FailedRevisionID               uuid.UUID `db:"failed_revision_id"`
+	FailedApplicationVersionName   string    `db:"failed_application_version_name"`
+	FailureMessage                 string    `db:"failure_message"`
+	FailedSince                    time.Time `db:"failed_since"`
+	RollbackRevisionID             uuid.UUID `db:"rollback_revision_id"`
+	RollbackApplicationVersionID   uuid.UUID `db:"rollback_application_version_id"`
+	RollbackApplicationVersionName string    `db:"rollback_application_version_name"`
+	RollbackValuesYaml             []byte    `db:"rollback_values_yaml"`
+	RollbackEnvFileData            []byte    `db:"rollback_env_file_data"`
 }
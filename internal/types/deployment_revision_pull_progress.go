@@ -0,0 +1,15 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeploymentRevisionPullProgress struct {
+	DeploymentRevisionID uuid.UUID `db:"deployment_revision_id" json:"deploymentRevisionId"`
+	Image                string    `db:"image" json:"image"`
+	UpdatedAt            time.Time `db:"updated_at" json:"updatedAt"`
+	BytesDone            int64     `db:"bytes_done" json:"bytesDone"`
+	BytesTotal           int64     `db:"bytes_total" json:"bytesTotal"`
+}
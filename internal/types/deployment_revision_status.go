@@ -6,10 +6,18 @@ import (
 	"github.com/google/uuid"
 )
 
+// DeploymentStatusErrorReason is the machine-readable cause of an error status.
+type DeploymentStatusErrorReason string
+
+// DeploymentStatusErrorReasonSecretResolutionFailed is reported by the agent if a secret reference in the deployment
+// values could not be resolved with the secret stores that are configured for the agent.
+const DeploymentStatusErrorReasonSecretResolutionFailed DeploymentStatusErrorReason = "secret_resolution_failed"
+
 type DeploymentRevisionStatus struct {
-	ID                   uuid.UUID            `db:"id" json:"id"`
-	CreatedAt            time.Time            `db:"created_at" json:"createdAt"`
-	DeploymentRevisionID string               `db:"deployment_revision_id" json:"deploymentRevisionId"`
-	Type                 DeploymentStatusType `db:"type" json:"type"`
-	Message              string               `db:"message" json:"message"`
+	ID                   uuid.UUID                    `db:"id" json:"id"`
+	CreatedAt            time.Time                    `db:"created_at" json:"createdAt"`
+	DeploymentRevisionID string                       `db:"deployment_revision_id" json:"deploymentRevisionId"`
+	Type                 DeploymentStatusType         `db:"type" json:"type"`
+	Message              string                       `db:"message" json:"message"`
+	ErrorReason          *DeploymentStatusErrorReason `db:"error_reason" json:"errorReason,omitempty"`
 }
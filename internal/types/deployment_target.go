@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	"github.com/glasskube/distr/internal/validation"
 	"github.com/google/uuid"
 )
@@ -19,6 +21,39 @@ type DeploymentTarget struct {
 	AgentVersionID         *uuid.UUID              `db:"agent_version_id" json:"-"`
 	ReportedAgentVersionID *uuid.UUID              `db:"reported_agent_version_id" json:"reportedAgentVersionId,omitempty"`
 	MetricsEnabled         bool                    `db:"metrics_enabled" json:"metricsEnabled"`
+	InventoryEnabled       bool                    `db:"inventory_enabled" json:"inventoryEnabled"`
+	CustomFields           CustomFields            `db:"custom_fields" json:"customFields"`
+	ArchivedAt             *time.Time              `db:"archived_at" json:"archivedAt,omitempty"`
+	MigrationConnectURL    *string                 `db:"migration_connect_url" json:"-"`
+	ReportedAgentPlatform  *string                 `db:"reported_agent_platform" json:"reportedAgentPlatform,omitempty"`
+	Production             bool                    `db:"production" json:"production"`
+	// ClockSkewMs is the estimated difference between the agent clock and the server clock in milliseconds.
+	// It is positive if the agent clock is ahead.
+	ClockSkewMs         *int64     `db:"clock_skew_ms" json:"clockSkewMs,omitempty"`
+	ClockSkewMeasuredAt *time.Time `db:"clock_skew_measured_at" json:"clockSkewMeasuredAt,omitempty"`
+	// VendorDataCollection and CustomerDataCollection are configured by the vendor and the customer respectively.
+	// DataCollection is the stricter combination of both and is enforced for the agent.
+	VendorDataCollection   DataCollection `db:"vendor_data_collection" json:"vendorDataCollection"`
+	CustomerDataCollection DataCollection `db:"customer_data_collection" json:"customerDataCollection"`
+	DataCollection         DataCollection `db:"data_collection" json:"dataCollection"`
+	// AgentResourceLimits overrides the defaults of the organization. EffectiveAgentResourceLimits is the combination
+	// of both and is sent to the agent, which reports the values it applied as AppliedAgentResourceLimits.
+	AgentResourceLimits          AgentResourceLimits         `db:"agent_resource_limits" json:"agentResourceLimits"`
+	EffectiveAgentResourceLimits AgentResourceLimits         `db:"effective_agent_resource_limits" json:"effectiveAgentResourceLimits"`       //nolint:lll
+	AppliedAgentResourceLimits   *AppliedAgentResourceLimits `db:"applied_agent_resource_limits" json:"appliedAgentResourceLimits,omitempty"` //nolint:lll
+	// ResourceVersion is increased by the database with every change of the data that the agent resource is built from.
+	ResourceVersion int64 `db:"resource_version" json:"-"`
+	// AwaitingConnection is true if the deployment target has been pre-registered and its agent has not connected yet.
+	AwaitingConnection bool `db:"awaiting_connection" json:"awaitingConnection"`
+	Pause
+}
+
+func (dt *DeploymentTarget) ClockSkew() *time.Duration {
+	if dt.ClockSkewMs == nil {
+		return nil
+	}
+	skew := time.Duration(*dt.ClockSkewMs) * time.Millisecond
+	return &skew
 }
 
 func (dt *DeploymentTarget) Validate() error {
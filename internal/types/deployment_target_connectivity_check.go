@@ -0,0 +1,32 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeploymentTargetConnectivityCheck struct {
+	ID                       uuid.UUID                 `db:"id" json:"id"`
+	CreatedAt                time.Time                 `db:"created_at" json:"createdAt"`
+	DeploymentTargetID       uuid.UUID                 `db:"deployment_target_id" json:"-"`
+	RequestedByUserAccountID *uuid.UUID                `db:"requested_by_user_account_id" json:"-"`
+	RequestedAt              time.Time                 `db:"requested_at" json:"requestedAt"`
+	ReportedAt               *time.Time                `db:"reported_at" json:"reportedAt,omitempty"`
+	Results                  []ConnectivityCheckResult `db:"results" json:"results,omitempty"`
+	// ClockSkewMs is the current clock skew estimate of the deployment target, see DeploymentTarget.ClockSkewMs.
+	ClockSkewMs *int64 `db:"-" json:"clockSkewMs,omitempty"`
+}
+
+// ConnectivityCheckResult is the outcome of testing a single endpoint from the deployment target.
+type ConnectivityCheckResult struct {
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	ResolvedIPs []string   `json:"resolvedIps,omitempty"`
+	Reachable   bool       `json:"reachable"`
+	LatencyMs   *int64     `json:"latencyMs,omitempty"`
+	TLSValid    *bool      `json:"tlsValid,omitempty"`
+	TLSExpiry   *time.Time `json:"tlsExpiry,omitempty"`
+	TLSIssuer   string     `json:"tlsIssuer,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeploymentTargetEndpoint is a URL served by an installation on a deployment target, whose TLS certificate is
+// checked periodically.
+type DeploymentTargetEndpoint struct {
+	ID                 uuid.UUID  `db:"id" json:"id"`
+	CreatedAt          time.Time  `db:"created_at" json:"createdAt"`
+	DeploymentTargetID uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	URL                string     `db:"url" json:"url"`
+	SelfSignedOK       bool       `db:"self_signed_ok" json:"selfSignedOk"`
+	LastCheckedAt      *time.Time `db:"last_checked_at" json:"lastCheckedAt,omitempty"`
+	LastError          *string    `db:"last_error" json:"lastError,omitempty"`
+	NotifiedDays       *int       `db:"notified_days" json:"-"`
+}
+
+type DeploymentTargetEndpointCertificate struct {
+	ID                         uuid.UUID `db:"id" json:"id"`
+	CreatedAt                  time.Time `db:"created_at" json:"createdAt"`
+	DeploymentTargetEndpointID uuid.UUID `db:"deployment_target_endpoint_id" json:"-"`
+	LastSeenAt                 time.Time `db:"last_seen_at" json:"lastSeenAt"`
+	Fingerprint                string    `db:"fingerprint" json:"fingerprint"`
+	Subject                    string    `db:"subject" json:"subject"`
+	Issuer                     string    `db:"issuer" json:"issuer"`
+	NotAfter                   time.Time `db:"not_after" json:"notAfter"`
+	ChainValid                 bool      `db:"chain_valid" json:"chainValid"`
+	ChainError                 *string   `db:"chain_error" json:"chainError,omitempty"`
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxFileRetrievalSize is the maximum size of a file in bytes that the agent retrieves. Larger files are not uploaded
+// at all, rather than truncated.
+const MaxFileRetrievalSize = 1024 * 1024
+
+type FileRetrievalStatus string
+
+const (
+	FileRetrievalStatusPending   FileRetrievalStatus = "pending"
+	FileRetrievalStatusCompleted FileRetrievalStatus = "completed"
+	// FileRetrievalStatusDenied is reported by the agent if the path is not in the allow-list of the deployment
+	// target.
+	FileRetrievalStatusDenied FileRetrievalStatus = "denied"
+	FileRetrievalStatusFailed FileRetrievalStatus = "failed"
+	// FileRetrievalStatusExpired is never stored. It is returned for retrievals that are still pending, but that the
+	// agent is not asked to handle anymore.
+	FileRetrievalStatusExpired FileRetrievalStatus = "expired"
+)
+
+// DeploymentTargetFileRetrievalAllowList contains the glob patterns of the paths on the host of a deployment target
+// that may be retrieved, in the syntax of path.Match. It is maintained by the owner of the host, see
+// DeploymentTargetWithCreatedBy.CreatedBy.
+type DeploymentTargetFileRetrievalAllowList struct {
+	DeploymentTargetID     uuid.UUID  `db:"deployment_target_id" json:"-"`
+	UpdatedAt              *time.Time `db:"updated_at" json:"updatedAt,omitempty"`
+	UpdatedByUserAccountID *uuid.UUID `db:"updated_by_user_account_id" json:"-"`
+	AllowedPaths           []string   `db:"allowed_paths" json:"allowedPaths"`
+}
+
+// DeploymentTargetFileRetrieval is a request to upload a single file from the host of a deployment target and the
+// result reported by the agent. Secrets in the content are redacted before it is stored.
+type DeploymentTargetFileRetrieval struct {
+	ID                       uuid.UUID           `db:"id" json:"id"`
+	CreatedAt                time.Time           `db:"created_at" json:"createdAt"`
+	DeploymentTargetID       uuid.UUID           `db:"deployment_target_id" json:"deploymentTargetId"`
+	RequestedByUserAccountID *uuid.UUID          `db:"requested_by_user_account_id" json:"-"`
+	RequestedBy              *string             `db:"requested_by" json:"requestedBy,omitempty"`
+	Path                     string              `db:"path" json:"path"`
+	Status                   FileRetrievalStatus `db:"status" json:"status"`
+	ReportedAt               *time.Time          `db:"reported_at" json:"reportedAt,omitempty"`
+	Size                     *int64              `db:"size" json:"size,omitempty"`
+	RedactedSecrets          *int                `db:"redacted_secrets" json:"redactedSecrets,omitempty"`
+	Error                    *string             `db:"error" json:"error,omitempty"`
+}
+
+type DeploymentTargetFileRetrievalWithContent struct {
+	DeploymentTargetFileRetrieval
+	Content []byte `db:"content" json:"content"`
+}
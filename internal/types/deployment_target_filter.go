@@ -0,0 +1,61 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeploymentTargetHealth string
+
+const (
+	// DeploymentTargetHealthOnline applies to deployment targets that reported their status within the last minute.
+	DeploymentTargetHealthOnline DeploymentTargetHealth = "online"
+	// DeploymentTargetHealthStale applies to deployment targets that reported their status, but not within the last
+	// minute.
+	DeploymentTargetHealthStale DeploymentTargetHealth = "stale"
+	// DeploymentTargetHealthNeverConnected applies to deployment targets that never reported their status.
+	DeploymentTargetHealthNeverConnected DeploymentTargetHealth = "never_connected"
+)
+
+var DeploymentTargetHealths = []DeploymentTargetHealth{
+	DeploymentTargetHealthOnline,
+	DeploymentTargetHealthStale,
+	DeploymentTargetHealthNeverConnected,
+}
+
+// Health returns the health of dt at now based on its current status, which must have been loaded.
+func (dt *DeploymentTarget) Health(now time.Time) DeploymentTargetHealth {
+	if dt.CurrentStatus == nil {
+		return DeploymentTargetHealthNeverConnected
+	} else if now.Sub(dt.CurrentStatus.CreatedAt) <= time.Minute {
+		return DeploymentTargetHealthOnline
+	} else {
+		return DeploymentTargetHealthStale
+	}
+}
+
+// DeploymentTargetFilter restricts a list of deployment targets. All conditions must be met. Nil values and an empty
+// CustomFields map do not filter.
+//
+// The application conditions are met by a deployment target if a single one of its non-archived deployments meets all
+// of them. The deployed version of a deployment is the version of its latest released revision.
+type DeploymentTargetFilter struct {
+	// CustomFields must be contained in the custom fields of the deployment target.
+	CustomFields    CustomFields
+	IncludeArchived bool
+	Health          *DeploymentTargetHealth
+	Production      *bool
+	// Paused matches deployment targets that are paused or have a paused deployment.
+	Paused *bool
+	// ReportedAgentVersionID is the agent version that the deployment target is actually running.
+	ReportedAgentVersionID *uuid.UUID
+	// LastSeenBefore and LastSeenAfter compare the time of the latest status of the deployment target. Deployment
+	// targets that never reported their status do not meet either of them.
+	LastSeenBefore       *time.Time
+	LastSeenAfter        *time.Time
+	ApplicationID        *uuid.UUID
+	ApplicationVersionID *uuid.UUID
+	// VersionOlderThan is a semantic version. Versions whose names are not semantic versions are never older.
+	VersionOlderThan *string
+}
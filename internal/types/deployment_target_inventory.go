@@ -0,0 +1,91 @@
+package types
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxInventoryItems is the maximum number of items of an inventory snapshot. Agents and the server truncate larger
+// snapshots and mark them as truncated.
+const MaxInventoryItems = 500
+
+// InventoryItem is a workload that exists on a deployment target, i.e. a docker container or a kubernetes workload in
+// the namespace of the agent.
+type InventoryItem struct {
+	// Kind is "container" for docker or the kind of the kubernetes resource, e.g. "Deployment".
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Images []string `json:"images"`
+	State  string   `json:"state"`
+	// Managed is true if the workload belongs to a deployment managed by Distr, false if it is foreign.
+	Managed bool `json:"managed"`
+}
+
+func (i InventoryItem) key() string {
+	return i.Kind + "/" + i.Name
+}
+
+func (i InventoryItem) equal(other InventoryItem) bool {
+	return i.State == other.State && i.Managed == other.Managed && slices.Equal(i.Images, other.Images)
+}
+
+// InventoryDiff is the difference between two inventory snapshots. Changed contains the current version of items
+// whose images, state or managed flag have changed.
+type InventoryDiff struct {
+	Added   []InventoryItem `json:"added"`
+	Removed []InventoryItem `json:"removed"`
+	Changed []InventoryItem `json:"changed"`
+}
+
+// DiffInventory compares two snapshots. Items are identified by their kind and name.
+func DiffInventory(previous, current []InventoryItem) InventoryDiff {
+	diff := InventoryDiff{Added: []InventoryItem{}, Removed: []InventoryItem{}, Changed: []InventoryItem{}}
+	previousByKey := make(map[string]InventoryItem, len(previous))
+	for _, item := range previous {
+		previousByKey[item.key()] = item
+	}
+	for _, item := range current {
+		if old, ok := previousByKey[item.key()]; !ok {
+			diff.Added = append(diff.Added, item)
+		} else {
+			if !old.equal(item) {
+				diff.Changed = append(diff.Changed, item)
+			}
+			delete(previousByKey, item.key())
+		}
+	}
+	for _, item := range previous {
+		if _, ok := previousByKey[item.key()]; ok {
+			diff.Removed = append(diff.Removed, item)
+		}
+	}
+	return diff
+}
+
+// DeploymentTargetInventory is the latest inventory snapshot reported by the agent of a deployment target. Diff is
+// nil for the first snapshot.
+type DeploymentTargetInventory struct {
+	DeploymentTargetID uuid.UUID       `db:"deployment_target_id" json:"deploymentTargetId"`
+	ReportedAt         time.Time       `db:"reported_at" json:"reportedAt"`
+	PreviousReportedAt *time.Time      `db:"previous_reported_at" json:"previousReportedAt,omitempty"`
+	Items              []InventoryItem `db:"items" json:"items"`
+	// Truncated is true if the agent found more than MaxInventoryItems workloads.
+	Truncated bool           `db:"truncated" json:"truncated"`
+	Diff      *InventoryDiff `db:"diff" json:"diff,omitempty"`
+}
+
+// FilterManaged removes all items, including those in the diff, that are not managed or not foreign, depending on
+// managed.
+func (inv *DeploymentTargetInventory) FilterManaged(managed bool) {
+	filter := func(items []InventoryItem) []InventoryItem {
+		return slices.DeleteFunc(items, func(item InventoryItem) bool { return item.Managed != managed })
+	}
+	inv.Items = filter(inv.Items)
+	if inv.Diff != nil {
+		inv.Diff.Added = filter(inv.Diff.Added)
+		inv.Diff.Removed = filter(inv.Diff.Removed)
+		inv.Diff.Changed = filter(inv.Diff.Changed)
+	}
+}
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeploymentTargetOutage is a suspected mass outage: a large fraction of the deployment targets of an organization, or
+// of one of its customers if CustomerUserAccountID is set, stopped reporting their status at about the same time.
+type DeploymentTargetOutage struct {
+	ID                    uuid.UUID  `db:"id" json:"id"`
+	CreatedAt             time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID        uuid.UUID  `db:"organization_id" json:"organizationId"`
+	CustomerUserAccountID *uuid.UUID `db:"customer_user_account_id" json:"customerUserAccountId,omitempty"`
+	// TargetCount is the number of deployment targets in scope that reported their status within the detection window
+	// when the outage was detected.
+	TargetCount int `db:"target_count" json:"targetCount"`
+	// AffectedDeploymentTargetIDs are the deployment targets that had gone stale when the outage was detected.
+	AffectedDeploymentTargetIDs []uuid.UUID `db:"affected_deployment_target_ids" json:"affectedDeploymentTargetIds"`
+	ResolvedAt                  *time.Time  `db:"resolved_at" json:"resolvedAt,omitempty"`
+}
+
+// OpenDeploymentTargetOutage is an outage that has not been resolved yet.
+type OpenDeploymentTargetOutage struct {
+	DeploymentTargetOutage
+	// StaleCount is the number of affected deployment targets that are still stale. Archived deployment targets are
+	// not counted.
+	StaleCount int `db:"stale_count"`
+}
+
+// DeploymentTargetConnectivity counts the deployment targets of an organization, or of one of its customers if
+// CustomerUserAccountID is set, that reported their status within a window, and lists those of them that are stale.
+type DeploymentTargetConnectivity struct {
+	OrganizationID           uuid.UUID   `db:"organization_id"`
+	CustomerUserAccountID    *uuid.UUID  `db:"customer_user_account_id"`
+	TargetCount              int         `db:"target_count"`
+	StaleDeploymentTargetIDs []uuid.UUID `db:"stale_deployment_target_ids"`
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeploymentTargetPreRegistration is created together with a deployment target that a vendor registers on behalf of a
+// customer. The connect instructions, which contain a single-use pairing token, are sent to ContactEmail. The
+// deployment target is deleted if its agent has not connected until ExpiresAt.
+type DeploymentTargetPreRegistration struct {
+	DeploymentTargetID     uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	CreatedAt              time.Time  `db:"created_at" json:"createdAt"`
+	CreatedByUserAccountID *uuid.UUID `db:"created_by_user_account_id" json:"-"`
+	ContactEmail           string     `db:"contact_email" json:"contactEmail"`
+	// ApplicationVersionID is the version whose resource requirements are sent as prerequisites.
+	ApplicationVersionID  *uuid.UUID `db:"application_version_id" json:"applicationVersionId,omitempty"`
+	PairingTokenSalt      *[]byte    `db:"pairing_token_salt" json:"-"`
+	PairingTokenHash      *[]byte    `db:"pairing_token_hash" json:"-"`
+	PairingTokenExpiresAt time.Time  `db:"pairing_token_expires_at" json:"pairingTokenExpiresAt"`
+	// PairedAt is the time at which the pairing token was redeemed, ConnectedAt the time of the first status of the
+	// agent.
+	PairedAt      *time.Time `db:"paired_at" json:"pairedAt,omitempty"`
+	ConnectedAt   *time.Time `db:"connected_at" json:"connectedAt,omitempty"`
+	RemindedAt    *time.Time `db:"reminded_at" json:"remindedAt,omitempty"`
+	ReminderCount int        `db:"reminder_count" json:"reminderCount"`
+	ExpiresAt     time.Time  `db:"expires_at" json:"expiresAt"`
+}
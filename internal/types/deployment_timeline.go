@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+type DeploymentTimelineEventType string
+
+const (
+	DeploymentTimelineEventTypeRevisionCreated DeploymentTimelineEventType = "revision_created"
+	DeploymentTimelineEventTypeStatus          DeploymentTimelineEventType = "status"
+	DeploymentTimelineEventTypeLog             DeploymentTimelineEventType = "log"
+)
+
+type DeploymentTimelineEventSource string
+
+const (
+	DeploymentTimelineEventSourceServer DeploymentTimelineEventSource = "server"
+	DeploymentTimelineEventSourceAgent  DeploymentTimelineEventSource = "agent"
+)
+
+// DeploymentTimelineEvent is a server or agent event that belongs to the operation of a deployment revision.
+type DeploymentTimelineEvent struct {
+	Time   time.Time                     `db:"time" json:"time"`
+	Source DeploymentTimelineEventSource `db:"source" json:"source"`
+	Type   DeploymentTimelineEventType   `db:"type" json:"type"`
+	// Severity is the status type for status events and the log severity for log events.
+	Severity string  `db:"severity" json:"severity,omitempty"`
+	Resource *string `db:"resource" json:"resource,omitempty"`
+	Message  string  `db:"message" json:"message"`
+	// Approximate is true if the event was reported by an agent that does not echo operation IDs. Such events are
+	// correlated by the time between this revision and the next one.
+	Approximate bool `db:"approximate" json:"approximate"`
+}
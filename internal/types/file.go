@@ -14,4 +14,6 @@ type File struct {
 	Data           []byte     `db:"data" json:"data"`
 	FileName       string     `db:"file_name" json:"fileName"`
 	FileSize       int64      `db:"file_size" json:"fileSize"`
+	ContentHash    []byte     `db:"content_hash" json:"-"`
+	OrphanedAt     *time.Time `db:"orphaned_at" json:"-"`
 }
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LicenseImpact describes what would stop working if a license was deleted.
+type LicenseImpact struct {
+	RecentPulls       []LicenseImpactPull             `json:"recentPulls"`
+	DeploymentTargets []LicenseImpactDeploymentTarget `json:"deploymentTargets"`
+	Deployments       []LicenseImpactDeployment       `json:"deployments"`
+	Seats             []LicenseSeat                   `json:"seats"`
+}
+
+// LicenseImpactPull summarizes the recent pulls of one artifact by one client that were authorized by a license.
+type LicenseImpactPull struct {
+	ArtifactID       uuid.UUID `db:"artifact_id" json:"artifactId"`
+	ArtifactName     string    `db:"artifact_name" json:"artifactName"`
+	UserAccountID    uuid.UUID `db:"useraccount_id" json:"userAccountId"`
+	UserAccountEmail string    `db:"useraccount_email" json:"userAccountEmail"`
+	RemoteAddress    *string   `db:"remote_address" json:"remoteAddress,omitempty"`
+	PullCount        int       `db:"pull_count" json:"pullCount"`
+	LastPulledAt     time.Time `db:"last_pulled_at" json:"lastPulledAt"`
+}
+
+type LicenseImpactDeploymentTarget struct {
+	ID   uuid.UUID `db:"id" json:"id"`
+	Name string    `db:"name" json:"name"`
+}
+
+type LicenseImpactDeployment struct {
+	ID                   uuid.UUID  `db:"id" json:"id"`
+	DeploymentTargetID   uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	DeploymentTargetName string     `db:"deployment_target_name" json:"deploymentTargetName"`
+	ArchivedAt           *time.Time `db:"archived_at" json:"archivedAt,omitempty"`
+}
+
+func (impact *LicenseImpact) IsEmpty() bool {
+	return len(impact.RecentPulls) == 0 && len(impact.DeploymentTargets) == 0 && len(impact.Deployments) == 0 &&
+		len(impact.Seats) == 0
+}
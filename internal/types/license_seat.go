@@ -0,0 +1,9 @@
+package types
+
+import "time"
+
+// LicenseSeat is a seat of an application or artifact license that is assigned to a user.
+type LicenseSeat struct {
+	CreatedAt   time.Time   `db:"created_at" json:"createdAt"`
+	UserAccount UserAccount `db:"user_account" json:"userAccount"`
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MaintenanceMode struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	// OrganizationID is nil if maintenance mode is active for the whole server.
+	OrganizationID         *uuid.UUID `db:"organization_id" json:"organizationId,omitempty"`
+	CreatedByUserAccountID *uuid.UUID `db:"created_by_useraccount_id" json:"-"`
+	Reason                 string     `db:"reason" json:"reason"`
+	RetryAfterSeconds      int        `db:"retry_after_seconds" json:"retryAfterSeconds"`
+}
+
+func (m MaintenanceMode) RetryAfter() time.Duration {
+	return time.Duration(m.RetryAfterSeconds) * time.Second
+}
+
+// MaintenanceState is a snapshot of all active maintenance modes.
+type MaintenanceState struct {
+	Server        *MaintenanceMode
+	Organizations map[uuid.UUID]MaintenanceMode
+}
+
+func NewMaintenanceState(modes []MaintenanceMode) MaintenanceState {
+	state := MaintenanceState{Organizations: make(map[uuid.UUID]MaintenanceMode)}
+	for _, mode := range modes {
+		if mode.OrganizationID == nil {
+			state.Server = &mode
+		} else {
+			state.Organizations[*mode.OrganizationID] = mode
+		}
+	}
+	return state
+}
+
+// For returns the maintenance mode that applies to an organization, or nil if there is none.
+// Server-wide maintenance mode takes precedence.
+func (s MaintenanceState) For(orgID *uuid.UUID) *MaintenanceMode {
+	if s.Server != nil {
+		return s.Server
+	} else if orgID != nil {
+		if mode, ok := s.Organizations[*orgID]; ok {
+			return &mode
+		}
+	}
+	return nil
+}
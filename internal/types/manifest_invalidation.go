@@ -0,0 +1,8 @@
+package types
+
+// ManifestInvalidation announces that the manifests of a repository with the given references (tags or digests) have
+// changed, so that they must not be served from a cache anymore.
+type ManifestInvalidation struct {
+	Repo       string   `json:"repo"`
+	References []string `json:"references"`
+}
@@ -6,25 +6,63 @@ import (
 	"slices"
 	"time"
 
+	"github.com/glasskube/distr/internal/orgtime"
 	"github.com/glasskube/distr/internal/util"
 	"github.com/google/uuid"
 )
 
 type Organization struct {
-	ID               uuid.UUID `db:"id" json:"id"`
-	CreatedAt        time.Time `db:"created_at" json:"createdAt"`
-	Name             string    `db:"name" json:"name"`
-	Slug             *string   `db:"slug" json:"slug"`
-	Features         []Feature `db:"features" json:"features"`
-	AppDomain        *string   `db:"app_domain" json:"appDomain"`
-	RegistryDomain   *string   `db:"registry_domain" json:"registryDomain"`
-	EmailFromAddress *string   `db:"email_from_address" json:"emailFromAddress"`
+	ID                     uuid.UUID              `db:"id" json:"id"`
+	CreatedAt              time.Time              `db:"created_at" json:"createdAt"`
+	Name                   string                 `db:"name" json:"name"`
+	Slug                   *string                `db:"slug" json:"slug"`
+	Features               []Feature              `db:"features" json:"features"`
+	AppDomain              *string                `db:"app_domain" json:"appDomain"`
+	RegistryDomain         *string                `db:"registry_domain" json:"registryDomain"`
+	EmailFromAddress       *string                `db:"email_from_address" json:"emailFromAddress"`
+	StatusBadgesDisabled   bool                   `db:"status_badges_disabled" json:"statusBadgesDisabled"`
+	DeploymentReasonPolicy DeploymentReasonPolicy `db:"deployment_reason_policy" json:"deploymentReasonPolicy"`
+	// DeploymentUninstallPolicy decides whether customers may uninstall deployments on their own deployment targets.
+	DeploymentUninstallPolicy DeploymentUninstallPolicy `db:"deployment_uninstall_policy" json:"deploymentUninstallPolicy"`
+	// DeploymentAutoRollbackWindowSeconds is the default of [Deployment.AutoRollbackWindowSeconds]. If it is nil,
+	// only deployments that enable automatic rollback themselves are rolled back.
+	DeploymentAutoRollbackWindowSeconds *int `db:"deployment_auto_rollback_window_seconds" json:"deploymentAutoRollbackWindowSeconds"` //nolint:lll
+	// SecretScanPolicy decides whether uploads that contain secrets are rejected or accepted with a warning.
+	SecretScanPolicy SecretScanPolicy       `db:"secret_scan_policy" json:"secretScanPolicy"`
+	Timezone         string                 `db:"timezone" json:"timezone"`
+	BusinessHours    *orgtime.BusinessHours `db:"business_hours" json:"businessHours"`
+	// AgentResourceLimits are the defaults for the agents of all deployment targets.
+	AgentResourceLimits AgentResourceLimits `db:"agent_resource_limits" json:"agentResourceLimits"`
+	// RegistryIndexChildCheckDisabled allows pushing image indexes that reference manifests which have not been pushed
+	// yet, e.g. attestations that buildx pushes after the index.
+	RegistryIndexChildCheckDisabled bool `db:"registry_index_child_check_disabled" json:"registryIndexChildCheckDisabled"` //nolint:lll
 }
 
 func (org *Organization) HasFeature(feature Feature) bool {
 	return slices.Contains(org.Features, feature)
 }
 
+// Location returns the timezone of the organization. Dates, like the start of a day or month, must always be
+// computed in this location using the helpers of package orgtime.
+func (org *Organization) Location() *time.Location {
+	if loc, err := orgtime.LoadLocation(org.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// RequiresDeploymentReason reports whether creating or updating a deployment on target must include a reason.
+func (org *Organization) RequiresDeploymentReason(target *DeploymentTarget) bool {
+	switch org.DeploymentReasonPolicy {
+	case DeploymentReasonPolicyRequired:
+		return true
+	case DeploymentReasonPolicyProduction:
+		return target != nil && target.Production
+	default:
+		return false
+	}
+}
+
 type OrganizationWithUserRole struct {
 	Organization
 	UserRole    UserRole  `db:"user_role" json:"userRole"`
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AggregateName string
+
+const (
+	AggregateStorageUsage        AggregateName = "storage_usage"
+	AggregateTargetHealth        AggregateName = "target_health"
+	AggregateTargetUptime        AggregateName = "target_uptime"
+	AggregateLicenseUtilization  AggregateName = "license_utilization"
+	AggregateVersionDistribution AggregateName = "version_distribution"
+)
+
+// AggregateNames are all aggregates that are maintained for every organization.
+var AggregateNames = []AggregateName{
+	AggregateStorageUsage,
+	AggregateTargetHealth,
+	AggregateTargetUptime,
+	AggregateLicenseUtilization,
+	AggregateVersionDistribution,
+}
+
+// OrganizationAggregate holds the precomputed value of an expensive organization-level aggregate. The data is
+// recomputed by a job, so that API requests never have to compute it. Data and ComputedAt are nil if the aggregate has
+// not been computed yet.
+type OrganizationAggregate struct {
+	OrganizationID     uuid.UUID       `db:"organization_id" json:"-"`
+	Name               AggregateName   `db:"name" json:"name"`
+	Data               json.RawMessage `db:"data" json:"data"`
+	ComputedAt         *time.Time      `db:"computed_at" json:"computedAt"`
+	RefreshRequestedAt *time.Time      `db:"refresh_requested_at" json:"refreshRequestedAt,omitempty"`
+}
+
+// StorageUsage is the data of AggregateStorageUsage. Blobs that are shared by multiple versions are counted once.
+type StorageUsage struct {
+	ArtifactCount int   `json:"artifactCount"`
+	VersionCount  int   `json:"versionCount"`
+	BlobCount     int   `json:"blobCount"`
+	TotalBytes    int64 `json:"totalBytes"`
+}
+
+// TargetHealth is the data of AggregateTargetHealth. A deployment target is online if it reported its status within
+// the last minute. Archived deployment targets are not counted. Paused deployment targets are counted in Paused in
+// addition to their connection state.
+type TargetHealth struct {
+	Total          int `json:"total"`
+	Online         int `json:"online"`
+	Stale          int `json:"stale"`
+	NeverConnected int `json:"neverConnected"`
+	Paused         int `json:"paused"`
+}
+
+// TargetUptime is an element of the data of AggregateTargetUptime. Uptime is the share of the hours of the last 30
+// days, or since the deployment target was created, in which the deployment target reported its status at least once.
+type TargetUptime struct {
+	DeploymentTargetID uuid.UUID `json:"deploymentTargetId"`
+	HoursOnline        int       `json:"hoursOnline"`
+	HoursTotal         int       `json:"hoursTotal"`
+	Uptime             float64   `json:"uptime"`
+}
+
+// LicenseUtilization is the data of AggregateLicenseUtilization.
+type LicenseUtilization struct {
+	ApplicationLicenses LicenseCounts `json:"applicationLicenses"`
+	ArtifactLicenses    LicenseCounts `json:"artifactLicenses"`
+}
+
+// LicenseCounts counts the licenses of an organization. InUse is the number of active licenses that are used by at
+// least one deployment. It is only computed for application licenses.
+type LicenseCounts struct {
+	Total    int  `json:"total"`
+	Assigned int  `json:"assigned"`
+	Expired  int  `json:"expired"`
+	InUse    *int `json:"inUse,omitempty"`
+}
+
+// VersionDistribution is an element of the data of AggregateVersionDistribution. It counts the active deployments of
+// all applications by version, like [VersionDistributionEntry].
+type VersionDistribution struct {
+	ApplicationID          uuid.UUID `json:"applicationId"`
+	ApplicationName        string    `json:"applicationName"`
+	ApplicationVersionID   uuid.UUID `json:"applicationVersionId"`
+	ApplicationVersionName string    `json:"applicationVersionName"`
+	DeploymentCount        int       `json:"deploymentCount"`
+	DeploymentTargetCount  int       `json:"deploymentTargetCount"`
+}
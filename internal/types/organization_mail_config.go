@@ -0,0 +1,46 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationMailConfig struct {
+	ID                     uuid.UUID      `db:"id" json:"id"`
+	CreatedAt              time.Time      `db:"created_at" json:"createdAt"`
+	OrganizationID         uuid.UUID      `db:"organization_id" json:"-"`
+	UpdatedAt              time.Time      `db:"updated_at" json:"updatedAt"`
+	UpdatedByUserAccountID *uuid.UUID     `db:"updated_by_user_account_id" json:"-"`
+	Type                   MailConfigType `db:"type" json:"type"`
+	FromAddress            string         `db:"from_address" json:"fromAddress"`
+	SmtpHost               *string        `db:"smtp_host" json:"smtpHost,omitempty"`
+	SmtpPort               *int           `db:"smtp_port" json:"smtpPort,omitempty"`
+	SmtpUsername           *string        `db:"smtp_username" json:"smtpUsername,omitempty"`
+	SmtpPassword           *string        `db:"smtp_password" json:"-"`
+	SenderDomain           *string        `db:"sender_domain" json:"senderDomain,omitempty"`
+	DkimSelector           *string        `db:"dkim_selector" json:"dkimSelector,omitempty"`
+	DkimPrivateKey         *string        `db:"dkim_private_key" json:"-"`
+	VerificationToken      *string        `db:"verification_token" json:"verificationToken,omitempty"`
+	VerifiedAt             *time.Time     `db:"verified_at" json:"verifiedAt,omitempty"`
+	FailureCount           int            `db:"failure_count" json:"failureCount"`
+	LastFailureAt          *time.Time     `db:"last_failure_at" json:"lastFailureAt,omitempty"`
+	LastFailureMessage     *string        `db:"last_failure_message" json:"lastFailureMessage,omitempty"`
+	DisabledAt             *time.Time     `db:"disabled_at" json:"disabledAt,omitempty"`
+}
+
+// IsUsable returns true if mails for the organization should be sent using this config.
+// SMTP configs are usable unless they have been disabled, domain configs must be verified first.
+func (c *OrganizationMailConfig) IsUsable() bool {
+	if c.DisabledAt != nil {
+		return false
+	}
+	switch c.Type {
+	case MailConfigTypeSMTP:
+		return c.SmtpHost != nil
+	case MailConfigTypeDomain:
+		return c.VerifiedAt != nil
+	default:
+		return false
+	}
+}
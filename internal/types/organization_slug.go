@@ -0,0 +1,40 @@
+package types
+
+import "slices"
+
+const OrganizationSlugMaxLength = 64
+
+// reservedOrganizationSlugs can not be chosen as the slug of an organization, because the slug is used as the first
+// path segment of registry references and could be confused with routes of Distr or the registry.
+// Migration 109 reports existing organizations that use one of them.
+var reservedOrganizationSlugs = []string{
+	"admin",
+	"api",
+	"app",
+	"assets",
+	"auth",
+	"distr",
+	"docs",
+	"health",
+	"help",
+	"internal",
+	"login",
+	"logout",
+	"metrics",
+	"register",
+	"registry",
+	"root",
+	"settings",
+	"signup",
+	"static",
+	"status",
+	"support",
+	"system",
+	"v2",
+	"www",
+}
+
+// IsReservedOrganizationSlug reports whether slug can not be chosen as the slug of an organization.
+func IsReservedOrganizationSlug(slug string) bool {
+	return slices.Contains(reservedOrganizationSlugs, slug)
+}
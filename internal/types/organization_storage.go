@@ -0,0 +1,43 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationStorage is an S3 compatible bucket that is owned by an organization. Once it is configured, all new
+// blobs of the organization are stored in this bucket instead of the platform bucket.
+type OrganizationStorage struct {
+	ID                     uuid.UUID  `db:"id" json:"id"`
+	CreatedAt              time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID         uuid.UUID  `db:"organization_id" json:"-"`
+	UpdatedAt              time.Time  `db:"updated_at" json:"updatedAt"`
+	UpdatedByUserAccountID *uuid.UUID `db:"updated_by_user_account_id" json:"-"`
+	Bucket                 string     `db:"bucket" json:"bucket"`
+	Region                 string     `db:"region" json:"region"`
+	Endpoint               *string    `db:"endpoint" json:"endpoint,omitempty"`
+	UsePathStyle           bool       `db:"use_path_style" json:"usePathStyle"`
+	AllowRedirect          bool       `db:"allow_redirect" json:"allowRedirect"`
+	AccessKeyID            string     `db:"access_key_id" json:"accessKeyId"`
+	SecretAccessKey        string     `db:"secret_access_key" json:"-"`
+	// MigrateExistingBlobs enables copying the blobs that are still stored in the platform bucket to this bucket.
+	// Otherwise, they keep being served from the platform bucket.
+	MigrateExistingBlobs bool `db:"migrate_existing_blobs" json:"migrateExistingBlobs"`
+	// QuotaEnabled enables the artifact tag limit and the storage quota, which are not enforced for organizations with
+	// their own bucket by default.
+	QuotaEnabled       bool       `db:"quota_enabled" json:"quotaEnabled"`
+	VerifiedAt         time.Time  `db:"verified_at" json:"verifiedAt"`
+	FailureCount       int        `db:"failure_count" json:"failureCount"`
+	LastFailureAt      *time.Time `db:"last_failure_at" json:"lastFailureAt,omitempty"`
+	LastFailureMessage *string    `db:"last_failure_message" json:"lastFailureMessage,omitempty"`
+	AlertedAt          *time.Time `db:"alerted_at" json:"alertedAt,omitempty"`
+}
+
+// OrganizationStorageUsage is the blob storage that is used by an organization. A blob that is part of multiple
+// artifacts of the organization is counted once. QuotaBytes is nil if the organization is not limited.
+type OrganizationStorageUsage struct {
+	BlobCount  int    `db:"blob_count" json:"blobCount"`
+	BlobBytes  int64  `db:"blob_bytes" json:"blobBytes"`
+	QuotaBytes *int64 `db:"quota_bytes" json:"quotaBytes"`
+}
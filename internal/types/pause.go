@@ -0,0 +1,28 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pause is embedded by [Deployment] and [DeploymentTarget]. While a deployment or its deployment target is paused, the
+// agent does not apply the deployment, but keeps reporting its status, and no new revisions can be created.
+type Pause struct {
+	PausedAt              *time.Time `db:"paused_at" json:"pausedAt,omitempty"`
+	PausedByUserAccountID *uuid.UUID `db:"paused_by_user_account_id" json:"pausedByUserAccountId,omitempty"`
+	PauseReason           *string    `db:"pause_reason" json:"pauseReason,omitempty"`
+	// ResumeAt is the time at which the pause is ended by the DeploymentPauseResume job, if any.
+	ResumeAt *time.Time `db:"resume_at" json:"resumeAt,omitempty"`
+}
+
+func (p *Pause) IsPaused() bool {
+	return p.PausedAt != nil
+}
+
+// ResumedPause is a pause of a deployment or deployment target that has been ended by the DeploymentPauseResume job.
+type ResumedPause struct {
+	ID             uuid.UUID `db:"id"`
+	OrganizationID uuid.UUID `db:"organization_id"`
+	Pause
+}
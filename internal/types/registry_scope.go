@@ -0,0 +1,71 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RegistryAction is an action on a repository, as in the scopes of the Docker registry token specification.
+type RegistryAction string
+
+const (
+	RegistryActionPull RegistryAction = "pull"
+	RegistryActionPush RegistryAction = "push"
+	// RegistryActionDelete allows deleting manifests and blobs. It does not imply push.
+	RegistryActionDelete RegistryAction = "delete"
+)
+
+func (a RegistryAction) IsValid() bool {
+	return a == RegistryActionPull || a == RegistryActionPush || a == RegistryActionDelete
+}
+
+// RegistryScope grants actions on the repositories whose name matches Repository. A Repository that ends with * is a
+// prefix that matches every repository whose name starts with it, e.g. my-org/ci-* matches my-org/ci-runner.
+type RegistryScope struct {
+	Repository string           `json:"repository"`
+	Actions    []RegistryAction `json:"actions"`
+}
+
+func (s RegistryScope) Validate() error {
+	if s.Repository == "" || s.Repository == "*" {
+		return errors.New("repository must not be empty")
+	} else if i := strings.Index(s.Repository, "*"); i >= 0 && i != len(s.Repository)-1 {
+		return fmt.Errorf("repository %v may only contain * at the end", s.Repository)
+	} else if len(s.Actions) == 0 {
+		return fmt.Errorf("repository %v has no actions", s.Repository)
+	}
+	for _, action := range s.Actions {
+		if !action.IsValid() {
+			return fmt.Errorf("invalid action %q for repository %v", action, s.Repository)
+		}
+	}
+	return nil
+}
+
+func (s RegistryScope) Matches(repository string) bool {
+	if prefix, ok := strings.CutSuffix(s.Repository, "*"); ok {
+		return strings.HasPrefix(repository, prefix)
+	}
+	return repository == s.Repository
+}
+
+// RegistryScopes limit a credential to the union of its scopes. A nil RegistryScopes does not limit the credential,
+// which may then do everything that the role of its user allows.
+type RegistryScopes []RegistryScope
+
+// Allow reports whether action on repository is within the scopes.
+func (scopes RegistryScopes) Allow(repository string, action RegistryAction) bool {
+	if scopes == nil {
+		return true
+	}
+	return slices.ContainsFunc(scopes, func(s RegistryScope) bool {
+		return s.Matches(repository) && slices.Contains(s.Actions, action)
+	})
+}
+
+// Grants reports whether any of the scopes contains action.
+func (scopes RegistryScopes) Grants(action RegistryAction) bool {
+	return slices.ContainsFunc(scopes, func(s RegistryScope) bool { return slices.Contains(s.Actions, action) })
+}
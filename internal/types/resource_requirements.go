@@ -0,0 +1,60 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+type ResourceRequirementsEnforcement string
+
+const (
+	// ResourceRequirementsEnforcementWarn allows deployments to targets that do not meet the requirements, but reports
+	// the unmet requirements to the user.
+	ResourceRequirementsEnforcementWarn ResourceRequirementsEnforcement = "warn"
+	// ResourceRequirementsEnforcementBlock rejects deployments to targets that do not meet the requirements.
+	ResourceRequirementsEnforcementBlock ResourceRequirementsEnforcement = "block"
+)
+
+// ResourceRequirements are the minimum resources a deployment target must provide to run an application.
+// They can be set on an Application and overridden on an ApplicationVersion.
+type ResourceRequirements struct {
+	MinCPUCoresMillis *int64 `json:"minCpuCoresMillis,omitempty"`
+	MinMemoryBytes    *int64 `json:"minMemoryBytes,omitempty"`
+	MinDiskBytes      *int64 `json:"minDiskBytes,omitempty"`
+	// Architectures lists the supported CPU architectures, e.g. "amd64" or "arm64". All are supported if empty.
+	Architectures []string                        `json:"architectures,omitempty"`
+	Enforcement   ResourceRequirementsEnforcement `json:"enforcement,omitempty"`
+}
+
+func (r ResourceRequirements) Validate() error {
+	if r.MinCPUCoresMillis != nil && *r.MinCPUCoresMillis <= 0 {
+		return errors.New("minCpuCoresMillis must be positive")
+	} else if r.MinMemoryBytes != nil && *r.MinMemoryBytes <= 0 {
+		return errors.New("minMemoryBytes must be positive")
+	} else if r.MinDiskBytes != nil && *r.MinDiskBytes <= 0 {
+		return errors.New("minDiskBytes must be positive")
+	} else if slices.Contains(r.Architectures, "") {
+		return errors.New("architectures must not be empty")
+	}
+	switch r.Enforcement {
+	case "", ResourceRequirementsEnforcementWarn, ResourceRequirementsEnforcementBlock:
+		return nil
+	default:
+		return fmt.Errorf("invalid enforcement: %v", r.Enforcement)
+	}
+}
+
+// IsBlocking returns true if deployments to targets that do not meet the requirements must be rejected.
+// Requirements are only reported as warnings by default.
+func (r ResourceRequirements) IsBlocking() bool {
+	return r.Enforcement == ResourceRequirementsEnforcementBlock
+}
+
+// EffectiveResourceRequirements returns the requirements of version if it has any and those of app otherwise.
+func EffectiveResourceRequirements(app Application, version ApplicationVersion) *ResourceRequirements {
+	if version.ResourceRequirements != nil {
+		return version.ResourceRequirements
+	}
+	return app.ResourceRequirements
+}
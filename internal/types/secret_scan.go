@@ -0,0 +1,57 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	SecretScanPolicy string
+	SecretScanSource string
+)
+
+const (
+	// SecretScanPolicyBlock rejects uploads that contain secrets.
+	SecretScanPolicyBlock SecretScanPolicy = "block"
+	// SecretScanPolicyWarn accepts uploads that contain secrets with a warning. The findings are still recorded.
+	SecretScanPolicyWarn SecretScanPolicy = "warn"
+
+	SecretScanSourceApplicationVersion SecretScanSource = "application_version"
+	SecretScanSourceDeployment         SecretScanSource = "deployment"
+)
+
+// SecretScanRule is a custom rule of an organization that is applied in addition to the default rules of package
+// secretscan.
+type SecretScanRule struct {
+	Base
+	OrganizationID uuid.UUID `db:"organization_id" json:"-"`
+	RuleID         string    `db:"rule_id" json:"ruleId"`
+	Description    string    `db:"description" json:"description"`
+	Pattern        string    `db:"pattern" json:"pattern"`
+}
+
+// SecretScanDismissal marks findings with the given fingerprint as false positives.
+type SecretScanDismissal struct {
+	Base
+	OrganizationID         uuid.UUID  `db:"organization_id" json:"-"`
+	CreatedByUserAccountID *uuid.UUID `db:"created_by_useraccount_id" json:"createdByUserAccountId"`
+	Fingerprint            string     `db:"fingerprint" json:"fingerprint"`
+	Reason                 string     `db:"reason" json:"reason"`
+}
+
+// SecretScanFinding records a secret found in an upload. Blocked is false if the upload was accepted anyway because
+// of [SecretScanPolicyWarn].
+type SecretScanFinding struct {
+	ID             uuid.UUID        `db:"id" json:"id"`
+	CreatedAt      time.Time        `db:"created_at" json:"createdAt"`
+	OrganizationID uuid.UUID        `db:"organization_id" json:"-"`
+	UserAccountID  *uuid.UUID       `db:"useraccount_id" json:"userAccountId"`
+	Source         SecretScanSource `db:"source" json:"source"`
+	File           string           `db:"file" json:"file"`
+	Line           int              `db:"line" json:"line"`
+	RuleID         string           `db:"rule_id" json:"ruleId"`
+	Redacted       string           `db:"redacted" json:"redacted"`
+	Fingerprint    string           `db:"fingerprint" json:"fingerprint"`
+	Blocked        bool             `db:"blocked" json:"blocked"`
+}
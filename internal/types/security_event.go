@@ -0,0 +1,49 @@
+package types
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SecurityEventType string
+
+const (
+	SecurityEventTypeLogin               SecurityEventType = "login"
+	SecurityEventTypeNewDeviceLogin      SecurityEventType = "new_device_login"
+	SecurityEventTypeFailedLoginAttempts SecurityEventType = "failed_login_attempts"
+	SecurityEventTypePasswordChanged     SecurityEventType = "password_changed"
+	SecurityEventTypeAccessTokenCreated  SecurityEventType = "access_token_created"
+	SecurityEventTypePasskeyAdded        SecurityEventType = "passkey_added"
+	SecurityEventTypePasskeyRemoved      SecurityEventType = "passkey_removed"
+)
+
+// SecurityEventTypes are all event types in the order they are presented to users.
+var SecurityEventTypes = []SecurityEventType{
+	SecurityEventTypeLogin,
+	SecurityEventTypeNewDeviceLogin,
+	SecurityEventTypeFailedLoginAttempts,
+	SecurityEventTypePasswordChanged,
+	SecurityEventTypeAccessTokenCreated,
+	SecurityEventTypePasskeyAdded,
+	SecurityEventTypePasskeyRemoved,
+}
+
+func (t SecurityEventType) IsValid() bool {
+	return slices.Contains(SecurityEventTypes, t)
+}
+
+// SecurityEvent is a security relevant action concerning a user account.
+// IPAddress, UserAgent and Country describe the request that caused the event, if any.
+type SecurityEvent struct {
+	ID            uuid.UUID         `db:"id" json:"id"`
+	CreatedAt     time.Time         `db:"created_at" json:"createdAt"`
+	UserAccountID uuid.UUID         `db:"useraccount_id" json:"-"`
+	Type          SecurityEventType `db:"type" json:"type"`
+	IPAddress     *string           `db:"ip_address" json:"ipAddress,omitempty"`
+	UserAgent     *string           `db:"user_agent" json:"userAgent,omitempty"`
+	Country       *string           `db:"country" json:"country,omitempty"`
+	Details       *string           `db:"details" json:"details,omitempty"`
+	MailSentAt    *time.Time        `db:"mail_sent_at" json:"mailSentAt,omitempty"`
+}
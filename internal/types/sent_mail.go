@@ -0,0 +1,86 @@
+package types
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MailType string
+
+const (
+	MailTypeOther                               MailType = "other"
+	MailTypeInviteUser                          MailType = "invite_user"
+	MailTypeInviteCustomer                      MailType = "invite_customer"
+	MailTypeVerifyEmail                         MailType = "verify_email"
+	MailTypePasswordReset                       MailType = "password_reset"
+	MailTypeSecurityEvent                       MailType = "security_event"
+	MailTypeUpstreamWatchChanged                MailType = "upstream_watch_changed"
+	MailTypeCertificateExpiring                 MailType = "certificate_expiring"
+	MailTypeAppMetricAlertFiring                MailType = "app_metric_alert_firing"
+	MailTypeOrganizationMailConfigDisabled      MailType = "organization_mail_config_disabled"
+	MailTypeArtifactDeletionRequested           MailType = "artifact_deletion_requested"
+	MailTypeAccessGrantCreated                  MailType = "access_grant_created"
+	MailTypeDeploymentAcknowledgmentRequired    MailType = "deployment_acknowledgment_required"
+	MailTypeOrganizationStorageFailing          MailType = "organization_storage_failing"
+	MailTypeDeploymentAutoRollback              MailType = "deployment_auto_rollback"
+	MailTypeDeploymentTargetOutageSuspected     MailType = "deployment_target_outage_suspected"
+	MailTypeDeploymentTargetOutageResolved      MailType = "deployment_target_outage_resolved"
+	MailTypeCustomerDataExportReady             MailType = "customer_data_export_ready"
+	MailTypeDeploymentTargetConnectInstructions MailType = "deployment_target_connect_instructions"
+	MailTypeVersionEOL                          MailType = "version_eol"
+)
+
+// MailTypes are all mail types that can be previewed.
+var MailTypes = []MailType{
+	MailTypeInviteUser,
+	MailTypeInviteCustomer,
+	MailTypeVerifyEmail,
+	MailTypePasswordReset,
+	MailTypeSecurityEvent,
+	MailTypeUpstreamWatchChanged,
+	MailTypeCertificateExpiring,
+	MailTypeAppMetricAlertFiring,
+	MailTypeOrganizationMailConfigDisabled,
+	MailTypeArtifactDeletionRequested,
+	MailTypeAccessGrantCreated,
+	MailTypeDeploymentAcknowledgmentRequired,
+	MailTypeOrganizationStorageFailing,
+	MailTypeDeploymentAutoRollback,
+	MailTypeDeploymentTargetOutageSuspected,
+	MailTypeDeploymentTargetOutageResolved,
+	MailTypeCustomerDataExportReady,
+	MailTypeDeploymentTargetConnectInstructions,
+	MailTypeVersionEOL,
+}
+
+func (t MailType) IsValid() bool {
+	return slices.Contains(MailTypes, t)
+}
+
+type SentMailStatus string
+
+const (
+	SentMailStatusSent       SentMailStatus = "sent"
+	SentMailStatusFailed     SentMailStatus = "failed"
+	SentMailStatusDelivered  SentMailStatus = "delivered"
+	SentMailStatusBounced    SentMailStatus = "bounced"
+	SentMailStatusComplained SentMailStatus = "complained"
+)
+
+// SentMail is a log entry of a mail that was passed to a transport.
+// ProviderMessageID is the id assigned by the transport, if it reports one. It is used to correlate delivery
+// notifications of the provider with the log entry.
+type SentMail struct {
+	ID                uuid.UUID      `db:"id" json:"id"`
+	CreatedAt         time.Time      `db:"created_at" json:"createdAt"`
+	OrganizationID    *uuid.UUID     `db:"organization_id" json:"-"`
+	Recipient         string         `db:"recipient" json:"recipient"`
+	Type              MailType       `db:"type" json:"type"`
+	Subject           string         `db:"subject" json:"subject"`
+	ProviderMessageID *string        `db:"provider_message_id" json:"providerMessageId,omitempty"`
+	Status            SentMailStatus `db:"status" json:"status"`
+	StatusDetails     *string        `db:"status_details" json:"statusDetails,omitempty"`
+	StatusUpdatedAt   time.Time      `db:"status_updated_at" json:"statusUpdatedAt"`
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceAccount is an identity of an organization that is not tied to a user account. It is used to access the
+// artifact registry from automated systems with the permissions of a vendor, so its access is not lost when users
+// leave the organization.
+type ServiceAccount struct {
+	ID                     uuid.UUID  `db:"id" json:"id"`
+	CreatedAt              time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID         uuid.UUID  `db:"organization_id" json:"-"`
+	Name                   string     `db:"name" json:"name"`
+	Description            *string    `db:"description" json:"description,omitempty"`
+	CreatedByUserAccountID *uuid.UUID `db:"created_by_user_account_id" json:"createdByUserAccountId,omitempty"`
+	// RevokedAt is set when the service account has been revoked. Its tokens are deleted at that time.
+	RevokedAt *time.Time `db:"revoked_at" json:"revokedAt,omitempty"`
+}
+
+func (sa ServiceAccount) IsRevoked() bool {
+	return sa.RevokedAt != nil
+}
+
+type ServiceAccountToken struct {
+	ID               uuid.UUID  `db:"id"`
+	CreatedAt        time.Time  `db:"created_at"`
+	ServiceAccountID uuid.UUID  `db:"service_account_id"`
+	Label            *string    `db:"label"`
+	KeyHash          []byte     `db:"key_hash"`
+	KeyPrefix        string     `db:"key_prefix"`
+	ExpiresAt        *time.Time `db:"expires_at"`
+	LastUsedAt       *time.Time `db:"last_used_at"`
+	// RegistryScopes further limit the token to these actions in the registry.
+	RegistryScopes RegistryScopes `db:"registry_scopes"`
+}
+
+type ServiceAccountTokenWithServiceAccount struct {
+	ServiceAccountToken
+	ServiceAccount ServiceAccount `db:"service_account"`
+}
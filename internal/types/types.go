@@ -3,6 +3,8 @@ package types
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -11,15 +13,19 @@ import (
 )
 
 type (
-	DeploymentType        string
-	UserRole              string
-	HelmChartType         string
-	DeploymentStatusType  string
-	DeploymentTargetScope string
-	Feature               string
-	DockerType            string
-	Tutorial              string
-	FileScope             string
+	DeploymentType            string
+	UserRole                  string
+	HelmChartType             string
+	DeploymentStatusType      string
+	DeploymentTargetScope     string
+	Feature                   string
+	DockerType                string
+	Tutorial                  string
+	FileScope                 string
+	MailConfigType            string
+	DeploymentReasonPolicy    string
+	DeploymentUninstallPolicy string
+	ApplicationVersionPolicy  string
 )
 
 const (
@@ -38,11 +44,16 @@ const (
 	DeploymentStatusTypeOK          DeploymentStatusType = "ok"
 	DeploymentStatusTypeProgressing DeploymentStatusType = "progressing"
 	DeploymentStatusTypeError       DeploymentStatusType = "error"
+	// DeploymentStatusTypeUninstalled is reported by the agent when it has uninstalled a deployment. It is terminal.
+	DeploymentStatusTypeUninstalled DeploymentStatusType = "uninstalled"
 
 	DeploymentTargetScopeCluster   DeploymentTargetScope = "cluster"
 	DeploymentTargetScopeNamespace DeploymentTargetScope = "namespace"
 
 	FeatureLicensing Feature = "licensing"
+	// FeatureAccessGrants restricts vendor access to deployment targets of customers to users with an active
+	// AccessGrant.
+	FeatureAccessGrants Feature = "access_grants"
 
 	TutorialBranding Tutorial = "branding"
 	TutorialAgents   Tutorial = "agents"
@@ -50,6 +61,25 @@ const (
 
 	FileScopePlatform     FileScope = "platform"
 	FileScopeOrganization FileScope = "organization"
+
+	MailConfigTypeSMTP   MailConfigType = "smtp"
+	MailConfigTypeDomain MailConfigType = "domain"
+
+	DeploymentReasonPolicyOptional   DeploymentReasonPolicy = "optional"
+	DeploymentReasonPolicyProduction DeploymentReasonPolicy = "production"
+	DeploymentReasonPolicyRequired   DeploymentReasonPolicy = "required"
+
+	DeploymentUninstallPolicyVendor            DeploymentUninstallPolicy = "vendor"
+	DeploymentUninstallPolicyVendorAndCustomer DeploymentUninstallPolicy = "vendor_and_customer"
+
+	// ApplicationVersionPolicyLenient accepts any version name.
+	ApplicationVersionPolicyLenient ApplicationVersionPolicy = "lenient"
+	// ApplicationVersionPolicyWarn accepts any version name but warns about names that are not semantic versions or
+	// that are not higher than the highest existing version.
+	ApplicationVersionPolicyWarn ApplicationVersionPolicy = "warn"
+	// ApplicationVersionPolicyStrict rejects version names that are not semantic versions. Versions that are not
+	// higher than the highest existing version are only accepted if they are forced.
+	ApplicationVersionPolicyStrict ApplicationVersionPolicy = "strict"
 )
 
 type Base struct {
@@ -70,13 +100,15 @@ var (
 	_ pgtype.TextValuer = &Digest{}
 )
 
+// Scan implements sql.Scanner. Digests are validated before they are stored, so only their form is checked. This
+// allows blobs to have sha512 digests, which v1.NewHash does not accept.
 func (target *Digest) Scan(src any) error {
 	if srcStr, ok := src.(string); !ok {
 		return errors.New("src must be a string")
-	} else if h, err := v1.NewHash(srcStr); err != nil {
-		return err
+	} else if algorithm, hex, ok := strings.Cut(srcStr, ":"); !ok || algorithm == "" || hex == "" {
+		return fmt.Errorf("cannot parse digest: %q", srcStr)
 	} else {
-		*target = Digest(h)
+		*target = Digest(v1.Hash{Algorithm: algorithm, Hex: hex})
 		return nil
 	}
 }
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UpstreamWatch is an image reference outside of Distr, usually a base image, whose digest is checked periodically.
+type UpstreamWatch struct {
+	ID                     uuid.UUID  `db:"id" json:"id"`
+	CreatedAt              time.Time  `db:"created_at" json:"createdAt"`
+	OrganizationID         uuid.UUID  `db:"organization_id" json:"-"`
+	CreatedByUserAccountID *uuid.UUID `db:"created_by_useraccount_id" json:"-"`
+	Reference              string     `db:"reference" json:"reference"`
+	Registry               string     `db:"registry" json:"registry"`
+	Digest                 *string    `db:"digest" json:"digest,omitempty"`
+	ETag                   *string    `db:"etag" json:"-"`
+	LastCheckedAt          *time.Time `db:"last_checked_at" json:"lastCheckedAt,omitempty"`
+	LastError              *string    `db:"last_error" json:"lastError,omitempty"`
+}
+
+type UpstreamWatchChange struct {
+	ID              uuid.UUID `db:"id" json:"id"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+	UpstreamWatchID uuid.UUID `db:"upstream_watch_id" json:"upstreamWatchId"`
+	PreviousDigest  string    `db:"previous_digest" json:"previousDigest"`
+	Digest          string    `db:"digest" json:"digest"`
+}
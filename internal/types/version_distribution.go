@@ -0,0 +1,40 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VersionDistributionGroupBy string
+
+const (
+	VersionDistributionGroupByNone VersionDistributionGroupBy = ""
+	// VersionDistributionGroupByEnvironment groups deployment targets into "production" and "non-production".
+	VersionDistributionGroupByEnvironment VersionDistributionGroupBy = "environment"
+	// VersionDistributionGroupByLabel groups deployment targets by the value of one of their custom fields.
+	VersionDistributionGroupByLabel VersionDistributionGroupBy = "label"
+)
+
+// VersionDistributionOptions select how the deployments of an application are counted by version.
+type VersionDistributionOptions struct {
+	GroupBy VersionDistributionGroupBy
+	// Label is the key of the deployment target custom field that is used with VersionDistributionGroupByLabel.
+	Label string
+	// CustomerID restricts the distribution to the deployment targets of a customer.
+	CustomerID *uuid.UUID
+}
+
+// VersionDistributionEntry counts the active deployments whose latest released revision has the application version.
+type VersionDistributionEntry struct {
+	// Week is the start of the week at whose end the deployments have been counted. For the current week, they are
+	// counted now. It is nil if the current distribution has been requested.
+	Week                   *time.Time `db:"week" json:"week,omitempty"`
+	ApplicationVersionID   uuid.UUID  `db:"application_version_id" json:"applicationVersionId"`
+	ApplicationVersionName string     `db:"application_version_name" json:"applicationVersionName"`
+	// Group is the environment or label value of the deployment targets. It is nil if the distribution is not grouped
+	// or the deployment targets do not have the label.
+	Group                 *string `db:"group_value" json:"group,omitempty"`
+	DeploymentCount       int     `db:"deployment_count" json:"deploymentCount"`
+	DeploymentTargetCount int     `db:"deployment_target_count" json:"deploymentTargetCount"`
+}
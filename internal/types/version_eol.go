@@ -0,0 +1,55 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VersionEOLCampaign announces the end of life of application versions to the customers that run them. Each
+// customer is notified about a version at most once, so a campaign only notifies the customers that have not been
+// notified by an earlier campaign.
+type VersionEOLCampaign struct {
+	ID                     uuid.UUID   `db:"id" json:"id"`
+	CreatedAt              time.Time   `db:"created_at" json:"createdAt"`
+	OrganizationID         uuid.UUID   `db:"organization_id" json:"-"`
+	ApplicationID          uuid.UUID   `db:"application_id" json:"applicationId"`
+	CreatedByUserAccountID *uuid.UUID  `db:"created_by_user_account_id" json:"createdByUserAccountId,omitempty"`
+	ApplicationVersionIDs  []uuid.UUID `db:"application_version_ids" json:"applicationVersionIds"`
+	EndOfLifeAt            *time.Time  `db:"end_of_life_at" json:"endOfLifeAt,omitempty"`
+	Message                string      `db:"message" json:"message"`
+}
+
+// VersionEOLCampaignWithProgress tracks how many of the notified customers have upgraded since.
+type VersionEOLCampaignWithProgress struct {
+	VersionEOLCampaign
+	// CustomerCount is the number of customers that are notified by the campaign.
+	CustomerCount int `db:"customer_count" json:"customerCount"`
+	// PendingCustomerCount is the number of customers whose notification has not been sent yet.
+	PendingCustomerCount int `db:"pending_customer_count" json:"pendingCustomerCount"`
+	// RemainingCustomerCount is the number of notified customers that still run one of the versions.
+	RemainingCustomerCount int `db:"remaining_customer_count" json:"remainingCustomerCount"`
+}
+
+// VersionEOLReportEntry is a deployment of a customer whose current revision uses one of the reported versions.
+type VersionEOLReportEntry struct {
+	CustomerUserAccountID      uuid.UUID  `db:"customer_user_account_id" json:"customerUserAccountId"`
+	CustomerEmail              string     `db:"customer_email" json:"customerEmail"`
+	CustomerName               string     `db:"customer_name" json:"customerName,omitempty"`
+	DeploymentTargetID         uuid.UUID  `db:"deployment_target_id" json:"deploymentTargetId"`
+	DeploymentTargetName       string     `db:"deployment_target_name" json:"deploymentTargetName"`
+	DeploymentTargetLastSeenAt *time.Time `db:"deployment_target_last_seen_at" json:"deploymentTargetLastSeenAt,omitempty"`
+	DeploymentID               uuid.UUID  `db:"deployment_id" json:"deploymentId"`
+	ApplicationVersionID       uuid.UUID  `db:"application_version_id" json:"applicationVersionId"`
+	ApplicationVersionName     string     `db:"application_version_name" json:"applicationVersionName"`
+	// NotificationCampaignID is the campaign that notified the customer about the end of life of the version.
+	NotificationCampaignID *uuid.UUID `db:"notification_campaign_id" json:"notificationCampaignId,omitempty"`
+	// NotifiedAt is nil while the notification is pending or if the customer has not been notified.
+	NotifiedAt *time.Time `db:"notified_at" json:"notifiedAt,omitempty"`
+}
+
+// PendingVersionEOLNotification identifies the notifications of a campaign for one customer that have not been sent.
+type PendingVersionEOLNotification struct {
+	CampaignID            uuid.UUID `db:"campaign_id"`
+	CustomerUserAccountID uuid.UUID `db:"customer_user_account_id"`
+}
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey that a user can log in with.
+type WebAuthnCredential struct {
+	ID            uuid.UUID `db:"id"`
+	CreatedAt     time.Time `db:"created_at"`
+	UserAccountID uuid.UUID `db:"user_account_id"`
+	CredentialID  []byte    `db:"credential_id"`
+	// PublicKey is in the COSE_Key format
+	PublicKey  []byte     `db:"public_key"`
+	SignCount  int64      `db:"sign_count"`
+	Transports []string   `db:"transports"`
+	Nickname   string     `db:"nickname"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+}
+
+type WebAuthnCeremony string
+
+const (
+	WebAuthnCeremonyRegistration   WebAuthnCeremony = "registration"
+	WebAuthnCeremonyAuthentication WebAuthnCeremony = "authentication"
+)
+
+// WebAuthnChallenge is the challenge of an ongoing ceremony. It can only be used once.
+type WebAuthnChallenge struct {
+	ID            uuid.UUID        `db:"id"`
+	CreatedAt     time.Time        `db:"created_at"`
+	ExpiresAt     time.Time        `db:"expires_at"`
+	Ceremony      WebAuthnCeremony `db:"ceremony"`
+	UserAccountID *uuid.UUID       `db:"user_account_id"`
+	Challenge     []byte           `db:"challenge"`
+}
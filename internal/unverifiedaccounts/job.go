@@ -0,0 +1,111 @@
+// Package unverifiedaccounts reminds self-registered users to verify their email address and deletes the accounts that
+// are never verified, so that their email addresses can be registered again.
+package unverifiedaccounts
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glasskube/distr/internal/apierrors"
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailsending"
+	"github.com/glasskube/distr/internal/types"
+	"go.uber.org/zap"
+)
+
+// ReminderDelay is the time after the registration after which an unverified user is reminded once.
+const ReminderDelay = 3 * 24 * time.Hour
+
+type Options struct {
+	// MaxAge is the time after the registration after which an unverified user account is deleted.
+	MaxAge time.Duration
+}
+
+type Job struct {
+	mailer mail.Mailer
+	opts   Options
+	now    func() time.Time
+}
+
+func NewJob(mailer mail.Mailer, opts Options) *Job {
+	return &Job{mailer: mailer, opts: opts, now: clock.Now}
+}
+
+// Run sends the due reminders and deletes the expired user accounts. User accounts that still own resources are kept,
+// see db.DeleteUnverifiedUserAccount.
+func (j *Job) Run(ctx context.Context) error {
+	ctx = internalctx.WithMailer(ctx, j.mailer)
+	return errors.Join(j.remind(ctx), j.delete(ctx))
+}
+
+func (j *Job) remind(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	users, err := db.GetUnverifiedUserAccountsForReminder(ctx, j.now().Add(-ReminderDelay))
+	if err != nil {
+		return err
+	}
+	var sent int
+	for _, user := range users {
+		if org, err := firstOrganization(ctx, user); err != nil {
+			log.Warn("could not get organization of unverified user", zap.Stringer("userId", user.ID), zap.Error(err))
+		} else if err := mailsending.SendUserVerificationMail(ctx, user, *org); err != nil {
+			log.Warn("could not send verification reminder", zap.Stringer("userId", user.ID), zap.Error(err))
+		} else {
+			sent++
+		}
+		// the reminder is only sent once, even if sending failed
+		if err := db.UpdateUserAccountVerificationReminderSent(ctx, user.ID, j.now()); err != nil {
+			return err
+		}
+	}
+	log.Info("unverified user account reminders finished", zap.Int("due", len(users)), zap.Int("sent", sent))
+	return nil
+}
+
+func (j *Job) delete(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	users, err := db.GetUnverifiedUserAccountsForDeletion(ctx, j.now().Add(-j.opts.MaxAge))
+	if err != nil {
+		return err
+	}
+	var accountsDeleted, blocked int
+	var organizationsDeleted int64
+	var errs []error
+	for _, user := range users {
+		var count int64
+		err := db.RunTx(ctx, func(ctx context.Context) (err error) {
+			count, err = db.DeleteUnverifiedUserAccount(ctx, user.ID)
+			return err
+		})
+		if errors.Is(err, apierrors.ErrNotFound) {
+			continue
+		} else if errors.Is(err, apierrors.ErrConflict) {
+			log.Debug("skipping deletion of unverified user account", zap.Stringer("userId", user.ID), zap.Error(err))
+			blocked++
+		} else if err != nil {
+			log.Warn("could not delete unverified user account", zap.Stringer("userId", user.ID), zap.Error(err))
+			errs = append(errs, err)
+		} else {
+			log.Info("deleted unverified user account", zap.Stringer("userId", user.ID))
+			accountsDeleted++
+			organizationsDeleted += count
+		}
+	}
+	log.Info("unverified user account deletion finished", zap.Int("accountsDeleted", accountsDeleted),
+		zap.Int64("organizationsDeleted", organizationsDeleted), zap.Int("accountsBlocked", blocked))
+	return errors.Join(errs...)
+}
+
+func firstOrganization(ctx context.Context, user types.UserAccount) (*types.Organization, error) {
+	if orgs, err := db.GetOrganizationsForUser(ctx, user.ID); err != nil {
+		return nil, err
+	} else if len(orgs) == 0 {
+		return nil, apierrors.ErrNotFound
+	} else {
+		return &orgs[0].Organization, nil
+	}
+}
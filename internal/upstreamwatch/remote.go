@@ -0,0 +1,75 @@
+package upstreamwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/glasskube/distr/internal/registryclient"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const remoteTimeout = 10 * time.Second
+
+var acceptedManifestTypes = []string{
+	string(types.OCIImageIndex),
+	string(types.DockerManifestList),
+	string(types.OCIManifestSchema1),
+	string(types.DockerManifestSchema2),
+}
+
+type remoteFetcher struct {
+	client *registryclient.Client
+}
+
+// NewRemoteFetcher returns a DigestFetcher that queries registries anonymously. It only sends HEAD requests, which
+// are not counted as pulls by Docker Hub, and uses conditional requests if an ETag is known.
+func NewRemoteFetcher(client *http.Client) DigestFetcher {
+	return remoteFetcher{client: registryclient.New(client)}
+}
+
+func (f remoteFetcher) Digest(ctx context.Context, ref name.Tag, etag string) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	resp, err := f.client.Manifest(ctx, http.MethodHead, ref, acceptedManifestTypes, header)
+	var tokenErr *registryclient.TokenStatusError
+	if errors.As(err, &tokenErr) && tokenErr.StatusCode == http.StatusTooManyRequests {
+		return Result{}, &RateLimitError{RetryAfter: retryAfter(tokenErr.Header)}
+	} else if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return Result{NotModified: true}, nil
+	case http.StatusTooManyRequests:
+		return Result{}, &RateLimitError{RetryAfter: retryAfter(resp.Header)}
+	case http.StatusOK:
+		digest := resp.Header.Get("Docker-Content-Digest")
+		if digest == "" {
+			return Result{}, fmt.Errorf("registry did not return a digest for %v", ref)
+		}
+		return Result{Digest: digest, ETag: resp.Header.Get("ETag")}, nil
+	default:
+		return Result{}, fmt.Errorf("unexpected status getting manifest %v: %v", ref, resp.Status)
+	}
+}
+
+// retryAfter parses the Retry-After header, which can either be a number of seconds or an HTTP date.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
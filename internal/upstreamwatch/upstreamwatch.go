@@ -0,0 +1,239 @@
+// Package upstreamwatch periodically checks the digests of upstream images that vendors depend on and notifies them
+// when a watched tag has been updated.
+package upstreamwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailtemplates"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/go-containerregistry/pkg/name"
+	"go.uber.org/zap"
+)
+
+const (
+	minBackoff = time.Minute
+	maxBackoff = 6 * time.Hour
+)
+
+var ErrInvalidReference = errors.New("reference must be an image tag like docker.io/library/alpine:3")
+
+// Result is the outcome of a single digest check.
+type Result struct {
+	Digest string
+	ETag   string
+	// NotModified is true if the registry confirmed that the manifest still matches the ETag of the previous check.
+	// Digest is empty in this case.
+	NotModified bool
+}
+
+// RateLimitError is returned by a DigestFetcher if the registry rejected a request because of rate limiting.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (err *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by registry, retry after %v", err.RetryAfter)
+}
+
+// DigestFetcher returns the current digest of a tag. etag is the ETag of the previous check and may be empty.
+type DigestFetcher interface {
+	Digest(ctx context.Context, ref name.Tag, etag string) (Result, error)
+}
+
+// ParseReference validates an image tag reference and returns its canonical form and registry.
+func ParseReference(ref string) (reference string, registry string, err error) {
+	tag, err := name.NewTag(strings.TrimSpace(ref), name.StrictValidation)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidReference, err)
+	}
+	return tag.Name(), tag.RegistryStr(), nil
+}
+
+type Options struct {
+	// Interval is the minimum time between two checks of the same watch.
+	Interval time.Duration
+	// BatchSize is the maximum number of watches that are considered in one run.
+	BatchSize int
+	// RegistryBudget is the maximum number of requests per registry in one run.
+	RegistryBudget int
+}
+
+// Checker checks due watches in batches. It remembers rate limit responses per registry across runs, so that all
+// watches of a registry are skipped until its backoff has expired.
+type Checker struct {
+	fetcher DigestFetcher
+	mailer  mail.Mailer
+	opts    Options
+	now     func() time.Time
+
+	mu      sync.Mutex
+	backoff map[string]*registryBackoff
+}
+
+type registryBackoff struct {
+	until time.Time
+	delay time.Duration
+}
+
+func NewChecker(fetcher DigestFetcher, mailer mail.Mailer, opts Options) *Checker {
+	return &Checker{
+		fetcher: fetcher,
+		mailer:  mailer,
+		opts:    opts,
+		now:     clock.Now,
+		backoff: make(map[string]*registryBackoff),
+	}
+}
+
+// Run checks a batch of due watches, saves the results and notifies vendors of detected changes.
+func (c *Checker) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	due, err := db.GetDueUpstreamWatches(ctx, c.now().Add(-c.opts.Interval), c.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	checked, changes := c.Check(ctx, due)
+	for i := range checked {
+		if err := db.UpdateUpstreamWatchCheck(ctx, &checked[i]); err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		if err := db.CreateUpstreamWatchChange(ctx, &change.UpstreamWatchChange); err != nil {
+			return err
+		}
+		if err := c.notify(ctx, change.Watch, change.UpstreamWatchChange); err != nil {
+			log.Warn("could not send upstream watch notification", zap.Error(err))
+		}
+	}
+	log.Info("UpstreamWatch check finished",
+		zap.Int("due", len(due)), zap.Int("checked", len(checked)), zap.Int("changes", len(changes)))
+	return nil
+}
+
+// Change is a detected digest change together with the watch it belongs to.
+type Change struct {
+	types.UpstreamWatchChange
+	Watch types.UpstreamWatch
+}
+
+// Check queries the digests of watches, respecting the per-registry budget and backoff. It returns the watches
+// that have been checked, with updated check state, and all detected changes. Watches that were skipped are not
+// returned and stay due.
+func (c *Checker) Check(ctx context.Context, watches []types.UpstreamWatch) ([]types.UpstreamWatch, []Change) {
+	log := internalctx.GetLogger(ctx)
+	var checked []types.UpstreamWatch
+	var changes []Change
+	requests := make(map[string]int)
+	for _, watch := range watches {
+		if c.backingOff(watch.Registry) || requests[watch.Registry] >= c.opts.RegistryBudget {
+			continue
+		}
+		requests[watch.Registry]++
+
+		var etag string
+		if watch.ETag != nil {
+			etag = *watch.ETag
+		}
+		tag, err := name.NewTag(watch.Reference, name.StrictValidation)
+		var result Result
+		if err == nil {
+			result, err = c.fetcher.Digest(ctx, tag, etag)
+		}
+		var rateLimitErr *RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			delay := c.startBackoff(watch.Registry, rateLimitErr.RetryAfter)
+			log.Warn("registry is rate limiting upstream watch checks",
+				zap.String("registry", watch.Registry), zap.Duration("backoff", delay))
+			continue
+		}
+
+		watch.LastCheckedAt = util.PtrTo(c.now())
+		if err != nil {
+			watch.LastError = util.PtrTo(err.Error())
+		} else {
+			c.resetBackoff(watch.Registry)
+			watch.LastError = nil
+			if !result.NotModified {
+				if watch.Digest != nil && *watch.Digest != result.Digest {
+					changes = append(changes, Change{
+						UpstreamWatchChange: types.UpstreamWatchChange{
+							UpstreamWatchID: watch.ID,
+							PreviousDigest:  *watch.Digest,
+							Digest:          result.Digest,
+						},
+						Watch: watch,
+					})
+				}
+				watch.Digest = &result.Digest
+				watch.ETag = &result.ETag
+				if result.ETag == "" {
+					watch.ETag = nil
+				}
+			}
+		}
+		checked = append(checked, watch)
+	}
+	return checked, changes
+}
+
+func (c *Checker) backingOff(registry string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.backoff[registry]
+	return ok && c.now().Before(b.until)
+}
+
+// startBackoff doubles the backoff of a registry, but waits at least as long as the registry asked for.
+func (c *Checker) startBackoff(registry string, retryAfter time.Duration) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.backoff[registry]
+	if !ok {
+		b = &registryBackoff{}
+		c.backoff[registry] = b
+	}
+	b.delay = min(max(b.delay*2, minBackoff, retryAfter), maxBackoff)
+	b.until = c.now().Add(b.delay)
+	return b.delay
+}
+
+func (c *Checker) resetBackoff(registry string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.backoff, registry)
+}
+
+// notify informs all vendor users of the organization of a watch about a detected change.
+func (c *Checker) notify(ctx context.Context, watch types.UpstreamWatch, change types.UpstreamWatchChange) error {
+	org, err := db.GetOrganizationWithBranding(ctx, watch.OrganizationID)
+	if err != nil {
+		return err
+	}
+	users, err := db.GetUserAccountsByOrgID(ctx, watch.OrganizationID, util.PtrTo(types.UserRoleVendor))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, user := range users {
+		errs = append(errs, c.mailer.Send(ctx, mail.New(
+			mail.To(user.Email),
+			mail.Subject("Upstream image "+watch.Reference+" has been updated"),
+			mail.Type(types.MailTypeUpstreamWatchChanged),
+			mail.HtmlBodyTemplate(mailtemplates.UpstreamWatchChanged(*org, watch, change)),
+			mail.Organization(watch.OrganizationID),
+		)))
+	}
+	return errors.Join(errs...)
+}
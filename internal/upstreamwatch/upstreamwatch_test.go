@@ -0,0 +1,121 @@
+package upstreamwatch_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/types"
+	"github.com/glasskube/distr/internal/upstreamwatch"
+	"github.com/glasskube/distr/internal/util"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+type fakeFetcher struct {
+	digests     map[string]string
+	rateLimited map[string]bool
+	requests    []string
+}
+
+func (f *fakeFetcher) Digest(ctx context.Context, ref name.Tag, etag string) (upstreamwatch.Result, error) {
+	f.requests = append(f.requests, ref.Name())
+	if f.rateLimited[ref.RegistryStr()] {
+		return upstreamwatch.Result{}, &upstreamwatch.RateLimitError{RetryAfter: time.Hour}
+	}
+	digest := f.digests[ref.Name()]
+	if etag == digest {
+		return upstreamwatch.Result{NotModified: true}, nil
+	}
+	return upstreamwatch.Result{Digest: digest, ETag: digest}, nil
+}
+
+func watch(reference string, digest *string) types.UpstreamWatch {
+	ref, registry, err := upstreamwatch.ParseReference(reference)
+	if err != nil {
+		panic(err)
+	}
+	return types.UpstreamWatch{ID: uuid.New(), Reference: ref, Registry: registry, Digest: digest, ETag: digest}
+}
+
+func testCtx() context.Context {
+	return internalctx.WithLogger(context.Background(), zap.NewNop())
+}
+
+func TestParseReference(t *testing.T) {
+	g := NewWithT(t)
+	ref, registry, err := upstreamwatch.ParseReference(" ghcr.io/glasskube/distr/hub:1.0.0 ")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref).To(Equal("ghcr.io/glasskube/distr/hub:1.0.0"))
+	g.Expect(registry).To(Equal("ghcr.io"))
+
+	_, registry, err = upstreamwatch.ParseReference("index.docker.io/library/alpine:3")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(registry).To(Equal("index.docker.io"))
+
+	_, _, err = upstreamwatch.ParseReference("alpine")
+	g.Expect(err).To(MatchError(upstreamwatch.ErrInvalidReference))
+}
+
+func TestCheckDetectsChanges(t *testing.T) {
+	g := NewWithT(t)
+	fetcher := &fakeFetcher{digests: map[string]string{
+		"ghcr.io/a/a:1": "sha256:new",
+		"ghcr.io/a/b:1": "sha256:same",
+		"ghcr.io/a/c:1": "sha256:first",
+	}}
+	checker := upstreamwatch.NewChecker(fetcher, nil, upstreamwatch.Options{RegistryBudget: 10})
+	checked, changes := checker.Check(testCtx(), []types.UpstreamWatch{
+		watch("ghcr.io/a/a:1", util.PtrTo("sha256:old")),
+		watch("ghcr.io/a/b:1", util.PtrTo("sha256:same")),
+		watch("ghcr.io/a/c:1", nil),
+	})
+	g.Expect(checked).To(HaveLen(3))
+	g.Expect(checked).To(HaveEach(HaveField("LastCheckedAt", Not(BeNil()))))
+	g.Expect(*checked[0].Digest).To(Equal("sha256:new"))
+	g.Expect(*checked[1].Digest).To(Equal("sha256:same"))
+	g.Expect(*checked[2].Digest).To(Equal("sha256:first"))
+	g.Expect(changes).To(HaveLen(1), "the first check of a watch is not a change")
+	g.Expect(changes[0].PreviousDigest).To(Equal("sha256:old"))
+	g.Expect(changes[0].Digest).To(Equal("sha256:new"))
+	g.Expect(changes[0].UpstreamWatchID).To(Equal(checked[0].ID))
+}
+
+func TestCheckRespectsRegistryBudget(t *testing.T) {
+	g := NewWithT(t)
+	fetcher := &fakeFetcher{}
+	checker := upstreamwatch.NewChecker(fetcher, nil, upstreamwatch.Options{RegistryBudget: 2})
+	checked, _ := checker.Check(testCtx(), []types.UpstreamWatch{
+		watch("ghcr.io/a/a:1", nil),
+		watch("ghcr.io/a/b:1", nil),
+		watch("ghcr.io/a/c:1", nil),
+		watch("quay.io/a/a:1", nil),
+	})
+	g.Expect(checked).To(HaveLen(3))
+	g.Expect(fetcher.requests).To(ConsistOf("ghcr.io/a/a:1", "ghcr.io/a/b:1", "quay.io/a/a:1"))
+}
+
+func TestCheckBacksOffPerRegistry(t *testing.T) {
+	g := NewWithT(t)
+	fetcher := &fakeFetcher{rateLimited: map[string]bool{"index.docker.io": true}}
+	checker := upstreamwatch.NewChecker(fetcher, nil, upstreamwatch.Options{RegistryBudget: 10})
+	watches := []types.UpstreamWatch{
+		watch("index.docker.io/library/alpine:3", nil),
+		watch("index.docker.io/library/debian:12", nil),
+		watch("ghcr.io/a/a:1", nil),
+	}
+
+	checked, _ := checker.Check(testCtx(), watches)
+	g.Expect(checked).To(HaveLen(1), "rate limited watches must stay due and not record an error")
+	g.Expect(checked[0].Reference).To(Equal("ghcr.io/a/a:1"))
+	g.Expect(fetcher.requests).To(HaveLen(2), "no further requests are sent to a rate limited registry")
+
+	fetcher.requests = nil
+	fetcher.rateLimited = nil
+	checked, _ = checker.Check(testCtx(), watches)
+	g.Expect(checked).To(HaveLen(1), "the registry is skipped until the backoff has expired")
+	g.Expect(fetcher.requests).To(Equal([]string{"ghcr.io/a/a:1"}))
+}
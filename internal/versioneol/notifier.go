@@ -0,0 +1,73 @@
+// Package versioneol sends the notifications of version end of life campaigns that are queued by vendors.
+package versioneol
+
+import (
+	"context"
+	"time"
+
+	"github.com/glasskube/distr/internal/clock"
+	internalctx "github.com/glasskube/distr/internal/context"
+	"github.com/glasskube/distr/internal/db"
+	"github.com/glasskube/distr/internal/mail"
+	"github.com/glasskube/distr/internal/mailsending"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// BatchSize is the maximum number of customers that are notified in one run.
+	BatchSize int
+}
+
+type Notifier struct {
+	mailer mail.Mailer
+	opts   Options
+	now    func() time.Time
+}
+
+func NewNotifier(mailer mail.Mailer, opts Options) *Notifier {
+	return &Notifier{mailer: mailer, opts: opts, now: clock.Now}
+}
+
+// Run sends a batch of pending notifications. Each customer receives one mail per campaign that lists all of their
+// deployments that still run one of the versions of the campaign.
+func (n *Notifier) Run(ctx context.Context) error {
+	log := internalctx.GetLogger(ctx)
+	ctx = internalctx.WithMailer(ctx, n.mailer)
+	pending, err := db.GetPendingVersionEOLNotifications(ctx, n.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	var sent int
+	for _, p := range pending {
+		log := log.With(zap.Stringer("campaignId", p.CampaignID), zap.Stringer("customerId", p.CustomerUserAccountID))
+		campaign, err := db.GetVersionEOLCampaign(ctx, p.CampaignID)
+		if err != nil {
+			return err
+		}
+		customer, err := db.GetUserAccountByID(ctx, p.CustomerUserAccountID)
+		if err != nil {
+			return err
+		}
+		entries, err := db.GetVersionEOLReport(
+			ctx, campaign.OrganizationID, campaign.ApplicationVersionIDs, &p.CustomerUserAccountID,
+		)
+		if err != nil {
+			return err
+		}
+		// customers that have upgraded since the campaign was created are not notified anymore
+		if len(entries) > 0 {
+			if err := mailsending.SendVersionEOLMail(ctx, *campaign, *customer, entries); err != nil {
+				log.Warn("could not send version EOL notification", zap.Error(err))
+			} else {
+				sent++
+			}
+		}
+		// the notification is marked as sent even if sending failed, so that a broken recipient does not block the
+		// batch. Failed mails are listed in the sent mail log.
+		if err := db.UpdateVersionEOLNotificationsSent(ctx, p, n.now()); err != nil {
+			return err
+		}
+	}
+	log.Info("version EOL notifications finished", zap.Int("pending", len(pending)), zap.Int("sent", sent))
+	return nil
+}
@@ -0,0 +1,119 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE algorithm identifiers, see https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	algES256 = -7
+	algEdDSA = -8
+	algRS256 = -257
+)
+
+// supportedAlgorithms are offered to authenticators in the order of preference.
+var supportedAlgorithms = []int{algES256, algEdDSA, algRS256}
+
+const (
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// coseKey is a public key in the COSE_Key format. The meaning of parameters with negative labels depends on the key
+// type, so they are decoded separately.
+type coseKey struct {
+	KeyType   int `cbor:"1,keyasint"`
+	Algorithm int `cbor:"3,keyasint"`
+}
+
+type coseCurveKey struct {
+	Curve int    `cbor:"-1,keyasint"`
+	X     []byte `cbor:"-2,keyasint"`
+	Y     []byte `cbor:"-3,keyasint,omitempty"`
+}
+
+type coseRSAKey struct {
+	N []byte `cbor:"-1,keyasint"`
+	E []byte `cbor:"-2,keyasint"`
+}
+
+type publicKey struct {
+	algorithm int
+	key       crypto.PublicKey
+}
+
+func parsePublicKey(data []byte) (*publicKey, error) {
+	var key coseKey
+	if err := cbor.Unmarshal(data, &key); err != nil {
+		return nil, verificationFailed("invalid public key: %v", err)
+	}
+	switch {
+	case key.KeyType == coseKeyTypeEC2 && key.Algorithm == algES256:
+		var ecKey coseCurveKey
+		if err := cbor.Unmarshal(data, &ecKey); err != nil {
+			return nil, verificationFailed("invalid EC2 public key: %v", err)
+		} else if ecKey.Curve != coseCurveP256 || len(ecKey.X) != 32 || len(ecKey.Y) != 32 {
+			return nil, verificationFailed("unsupported EC2 public key")
+		}
+		// ecdh validates that the point is on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, ecKey.X...), ecKey.Y...)); err != nil {
+			return nil, verificationFailed("invalid P-256 public key: %v", err)
+		}
+		return &publicKey{algorithm: algES256, key: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(ecKey.X),
+			Y:     new(big.Int).SetBytes(ecKey.Y),
+		}}, nil
+	case key.KeyType == coseKeyTypeOKP && key.Algorithm == algEdDSA:
+		var okpKey coseCurveKey
+		if err := cbor.Unmarshal(data, &okpKey); err != nil {
+			return nil, verificationFailed("invalid OKP public key: %v", err)
+		} else if okpKey.Curve != coseCurveEd25519 || len(okpKey.X) != ed25519.PublicKeySize {
+			return nil, verificationFailed("unsupported OKP public key")
+		}
+		return &publicKey{algorithm: algEdDSA, key: ed25519.PublicKey(okpKey.X)}, nil
+	case key.KeyType == coseKeyTypeRSA && key.Algorithm == algRS256:
+		var rsaKey coseRSAKey
+		if err := cbor.Unmarshal(data, &rsaKey); err != nil {
+			return nil, verificationFailed("invalid RSA public key: %v", err)
+		}
+		n := new(big.Int).SetBytes(rsaKey.N)
+		e := new(big.Int).SetBytes(rsaKey.E)
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, verificationFailed("unsupported RSA public key")
+		}
+		return &publicKey{algorithm: algRS256, key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	default:
+		return nil, verificationFailed("unsupported public key type %v with algorithm %v", key.KeyType, key.Algorithm)
+	}
+}
+
+func (k *publicKey) verify(data, signature []byte) error {
+	hash := sha256.Sum256(data)
+	var ok bool
+	switch k.algorithm {
+	case algES256:
+		ok = ecdsa.VerifyASN1(k.key.(*ecdsa.PublicKey), hash[:], signature)
+	case algEdDSA:
+		ok = ed25519.Verify(k.key.(ed25519.PublicKey), data, signature)
+	case algRS256:
+		ok = rsa.VerifyPKCS1v15(k.key.(*rsa.PublicKey), crypto.SHA256, hash[:], signature) == nil
+	}
+	if !ok {
+		return verificationFailed("invalid signature")
+	}
+	return nil
+}
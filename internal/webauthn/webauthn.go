@@ -0,0 +1,339 @@
+// Package webauthn implements the relying party side of the WebAuthn registration and authentication ceremonies
+// for passkeys.
+//
+// Attestation statements are not verified, because credentials are only used to identify a user and not to assert
+// properties of the authenticator. Options therefore always request no attestation.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+)
+
+// Timeout is the time that a user has to complete a ceremony.
+const Timeout = 5 * time.Minute
+
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+)
+
+var ErrVerificationFailed = errors.New("webauthn verification failed")
+
+func verificationFailed(format string, a ...any) error {
+	return fmt.Errorf("%w: %v", ErrVerificationFailed, fmt.Sprintf(format, a...))
+}
+
+// Base64URL is binary data that is encoded as unpadded base64url in JSON, as expected by the WebAuthn JSON
+// serialization of browsers.
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	// some clients add padding even though the specification does not
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingParty is the web application that users authenticate to. Credentials are bound to its ID.
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// NewRelyingParty returns the relying party for an application served at host, which must be an absolute URL.
+func NewRelyingParty(name, host string) (RelyingParty, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return RelyingParty{}, err
+	} else if u.Scheme == "" || u.Hostname() == "" {
+		return RelyingParty{}, fmt.Errorf("host %v is not an absolute URL", host)
+	}
+	return RelyingParty{ID: u.Hostname(), Name: name, Origin: u.Scheme + "://" + u.Host}, nil
+}
+
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	_, err := rand.Read(challenge)
+	return challenge, err
+}
+
+// UserHandle returns the WebAuthn user handle of the user with the given ID.
+func UserHandle(userID uuid.UUID) []byte {
+	return userID[:]
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are the options of navigator.credentials.create() in their JSON serialization.
+type CreationOptions struct {
+	RelyingParty           RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              Base64URL              `json:"challenge"`
+	CredentialParameters   []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options of navigator.credentials.get() in their JSON serialization.
+type RequestOptions struct {
+	Challenge        Base64URL              `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RelyingPartyID   string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// NewCreationOptions returns the options to register a discoverable credential for a user. Existing credentials of
+// the user are excluded, so that an authenticator is not registered twice.
+func NewCreationOptions(
+	rp RelyingParty,
+	user UserEntity,
+	challenge []byte,
+	exclude []CredentialDescriptor,
+) CreationOptions {
+	params := make([]CredentialParameter, len(supportedAlgorithms))
+	for i, alg := range supportedAlgorithms {
+		params[i] = CredentialParameter{Type: "public-key", Algorithm: alg}
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return CreationOptions{
+		RelyingParty:         RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:                 user,
+		Challenge:            challenge,
+		CredentialParameters: params,
+		Timeout:              Timeout.Milliseconds(),
+		ExcludeCredentials:   exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// NewRequestOptions returns the options to authenticate with a discoverable credential. No credentials are allowed
+// explicitly, so the authenticator lets the user choose one and nothing about registered users is disclosed.
+func NewRequestOptions(rp RelyingParty, challenge []byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout.Milliseconds(),
+		RelyingPartyID:   rp.ID,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: "required",
+	}
+}
+
+// AttestationResponse is the JSON serialization of a PublicKeyCredential returned by navigator.credentials.create().
+type AttestationResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AttestationObject Base64URL `json:"attestationObject"`
+		Transports        []string  `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON serialization of a PublicKeyCredential returned by navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AuthenticatorData Base64URL `json:"authenticatorData"`
+		Signature         Base64URL `json:"signature"`
+		UserHandle        Base64URL `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is a verified newly registered credential.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+// VerifyRegistration verifies the response of an authenticator to CreationOptions with the given challenge.
+func VerifyRegistration(rp RelyingParty, challenge []byte, response AttestationResponse) (*Credential, error) {
+	if response.Type != "public-key" {
+		return nil, verificationFailed("unexpected credential type %q", response.Type)
+	} else if err := verifyClientData(rp, "webauthn.create", challenge, response.Response.ClientDataJSON); err != nil {
+		return nil, err
+	}
+	var attestation struct {
+		Format   string `cbor:"fmt"`
+		AuthData []byte `cbor:"authData"`
+	}
+	if err := cbor.Unmarshal(response.Response.AttestationObject, &attestation); err != nil {
+		return nil, verificationFailed("invalid attestation object: %v", err)
+	}
+	data, err := parseAuthenticatorData(rp, attestation.AuthData)
+	if err != nil {
+		return nil, err
+	} else if data.credentialID == nil {
+		return nil, verificationFailed("attested credential data is missing")
+	} else if !bytes.Equal(data.credentialID, response.RawID) {
+		return nil, verificationFailed("credential ID does not match")
+	} else if _, err := parsePublicKey(data.publicKey); err != nil {
+		return nil, err
+	}
+	return &Credential{ID: data.credentialID, PublicKey: data.publicKey, SignCount: data.signCount}, nil
+}
+
+// VerifyAssertion verifies the response of an authenticator to RequestOptions with the given challenge, using the
+// public key and the sign count that were stored for the credential. It returns the new sign count of the credential.
+func VerifyAssertion(
+	rp RelyingParty,
+	challenge []byte,
+	response AssertionResponse,
+	userHandle []byte,
+	publicKey []byte,
+	signCount uint32,
+) (uint32, error) {
+	if response.Type != "public-key" {
+		return 0, verificationFailed("unexpected credential type %q", response.Type)
+	} else if response.Response.UserHandle != nil && !bytes.Equal(response.Response.UserHandle, userHandle) {
+		return 0, verificationFailed("user handle does not match")
+	} else if err := verifyClientData(rp, "webauthn.get", challenge, response.Response.ClientDataJSON); err != nil {
+		return 0, err
+	}
+	data, err := parseAuthenticatorData(rp, response.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(bytes.Clone(response.Response.AuthenticatorData), clientDataHash[:]...)
+	if err := key.verify(signed, response.Response.Signature); err != nil {
+		return 0, err
+	}
+	// authenticators that do not count signatures always report zero, all others must increase the count, otherwise
+	// the credential may have been cloned
+	if (data.signCount != 0 || signCount != 0) && data.signCount <= signCount {
+		return 0, verificationFailed("sign count did not increase")
+	}
+	return data.signCount, nil
+}
+
+func verifyClientData(rp RelyingParty, ceremony string, challenge []byte, clientDataJSON []byte) error {
+	var clientData struct {
+		Type      string    `json:"type"`
+		Challenge Base64URL `json:"challenge"`
+		Origin    string    `json:"origin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return verificationFailed("invalid client data: %v", err)
+	} else if clientData.Type != ceremony {
+		return verificationFailed("unexpected client data type %q", clientData.Type)
+	} else if subtle.ConstantTimeCompare(clientData.Challenge, challenge) != 1 {
+		return verificationFailed("challenge does not match")
+	} else if clientData.Origin != rp.Origin {
+		return verificationFailed("unexpected origin %q", clientData.Origin)
+	}
+	return nil
+}
+
+type authenticatorData struct {
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(rp RelyingParty, data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, verificationFailed("authenticator data is too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, verificationFailed("relying party ID does not match")
+	}
+	flags := data[32]
+	if flags&flagUserPresent == 0 {
+		return nil, verificationFailed("user was not present")
+	} else if flags&flagUserVerified == 0 {
+		return nil, verificationFailed("user was not verified")
+	}
+	result := authenticatorData{signCount: binary.BigEndian.Uint32(data[33:37])}
+	if flags&flagAttestedCredentialData != 0 {
+		// 16 bytes AAGUID followed by the length of the credential ID
+		rest := data[37:]
+		if len(rest) < 18 {
+			return nil, verificationFailed("attested credential data is too short")
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLen {
+			return nil, verificationFailed("credential ID is too short")
+		}
+		result.credentialID = bytes.Clone(rest[:idLen])
+		rest = rest[idLen:]
+		// the public key may be followed by extension data
+		var key cbor.RawMessage
+		if _, err := cbor.UnmarshalFirst(rest, &key); err != nil {
+			return nil, verificationFailed("invalid credential public key: %v", err)
+		}
+		result.publicKey = bytes.Clone(key)
+	}
+	return &result, nil
+}
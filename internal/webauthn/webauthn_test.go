@@ -0,0 +1,202 @@
+package webauthn_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/glasskube/distr/internal/webauthn"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+var rp = webauthn.RelyingParty{ID: "app.example.com", Name: "Distr", Origin: "https://app.example.com"}
+
+// authenticator is a minimal software authenticator that creates credentials with the given signer.
+type authenticator struct {
+	signer       crypto.Signer
+	publicKey    []byte
+	credentialID []byte
+	signCount    uint32
+}
+
+func newES256Authenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := cbor.Marshal(map[int]any{
+		1: 2, 3: -7, -1: 1, -2: key.X.FillBytes(make([]byte, 32)), -3: key.Y.FillBytes(make([]byte, 32)),
+	})
+	return &authenticator{signer: key, publicKey: publicKey, credentialID: []byte("es256-credential")}
+}
+
+func newEd25519Authenticator(t *testing.T) *authenticator {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := cbor.Marshal(map[int]any{1: 1, 3: -8, -1: 6, -2: []byte(pub)})
+	return &authenticator{signer: key, publicKey: publicKey, credentialID: []byte("ed25519-credential")}
+}
+
+func (a *authenticator) authenticatorData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.publicKey...)
+	}
+	return data
+}
+
+func clientData(ceremony string, challenge []byte, origin string) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": webauthn.Base64URL(challenge),
+		"origin":    origin,
+	})
+	return data
+}
+
+func (a *authenticator) create(challenge []byte) webauthn.AttestationResponse {
+	var response webauthn.AttestationResponse
+	response.RawID = a.credentialID
+	response.Type = "public-key"
+	response.Response.ClientDataJSON = clientData("webauthn.create", challenge, rp.Origin)
+	response.Response.AttestationObject, _ = cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(rp.ID, 0x45, true),
+	})
+	return response
+}
+
+func (a *authenticator) get(challenge []byte, userHandle []byte) webauthn.AssertionResponse {
+	a.signCount++
+	var response webauthn.AssertionResponse
+	response.RawID = a.credentialID
+	response.Type = "public-key"
+	response.Response.ClientDataJSON = clientData("webauthn.get", challenge, rp.Origin)
+	response.Response.AuthenticatorData = a.authenticatorData(rp.ID, 0x05, false)
+	response.Response.UserHandle = userHandle
+	response.Response.Signature = a.sign(response)
+	return response
+}
+
+func (a *authenticator) sign(response webauthn.AssertionResponse) []byte {
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte{}, response.Response.AuthenticatorData...), clientDataHash[:]...)
+	var signature []byte
+	var err error
+	if _, ok := a.signer.(ed25519.PrivateKey); ok {
+		signature, err = a.signer.Sign(rand.Reader, signed, crypto.Hash(0))
+	} else {
+		hash := sha256.Sum256(signed)
+		signature, err = a.signer.Sign(rand.Reader, hash[:], crypto.SHA256)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	for name, newAuthenticator := range map[string]func(*testing.T) *authenticator{
+		"ES256":   newES256Authenticator,
+		"Ed25519": newEd25519Authenticator,
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			a := newAuthenticator(t)
+			userHandle := webauthn.UserHandle(uuid.New())
+
+			challenge, err := webauthn.NewChallenge()
+			g.Expect(err).NotTo(HaveOccurred())
+			credential, err := webauthn.VerifyRegistration(rp, challenge, a.create(challenge))
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(credential.ID).To(Equal(a.credentialID))
+			g.Expect(credential.PublicKey).To(Equal(a.publicKey))
+
+			challenge, _ = webauthn.NewChallenge()
+			signCount, err := webauthn.VerifyAssertion(
+				rp, challenge, a.get(challenge, userHandle), userHandle, credential.PublicKey, credential.SignCount)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(signCount).To(Equal(uint32(1)))
+
+			// a replayed response does not increase the sign count
+			response := a.get(challenge, userHandle)
+			_, err = webauthn.VerifyAssertion(rp, challenge, response, userHandle, credential.PublicKey, 2)
+			g.Expect(err).To(MatchError(webauthn.ErrVerificationFailed))
+		})
+	}
+}
+
+func TestVerifyRegistrationRejectsInvalidResponses(t *testing.T) {
+	g := NewWithT(t)
+	a := newES256Authenticator(t)
+	challenge, _ := webauthn.NewChallenge()
+	otherChallenge, _ := webauthn.NewChallenge()
+
+	_, err := webauthn.VerifyRegistration(rp, otherChallenge, a.create(challenge))
+	g.Expect(err).To(MatchError(ContainSubstring("challenge does not match")))
+
+	otherRP := rp
+	otherRP.Origin = "https://evil.example.com"
+	_, err = webauthn.VerifyRegistration(otherRP, challenge, a.create(challenge))
+	g.Expect(err).To(MatchError(ContainSubstring("unexpected origin")))
+
+	otherRP = rp
+	otherRP.ID = "example.com"
+	_, err = webauthn.VerifyRegistration(otherRP, challenge, a.create(challenge))
+	g.Expect(err).To(MatchError(ContainSubstring("relying party ID does not match")))
+
+	response := a.create(challenge)
+	response.Response.AttestationObject, _ = cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(rp.ID, 0x41, true),
+	})
+	_, err = webauthn.VerifyRegistration(rp, challenge, response)
+	g.Expect(err).To(MatchError(ContainSubstring("user was not verified")))
+}
+
+func TestVerifyAssertionRejectsInvalidResponses(t *testing.T) {
+	g := NewWithT(t)
+	a := newES256Authenticator(t)
+	other := newES256Authenticator(t)
+	userHandle := webauthn.UserHandle(uuid.New())
+	challenge, _ := webauthn.NewChallenge()
+
+	response := a.get(challenge, userHandle)
+	_, err := webauthn.VerifyAssertion(rp, challenge, response, userHandle, other.publicKey, 0)
+	g.Expect(err).To(MatchError(ContainSubstring("invalid signature")))
+
+	_, err = webauthn.VerifyAssertion(rp, challenge, response, webauthn.UserHandle(uuid.New()), a.publicKey, 0)
+	g.Expect(err).To(MatchError(ContainSubstring("user handle does not match")))
+
+	response.Response.ClientDataJSON = clientData("webauthn.create", challenge, rp.Origin)
+	response.Response.Signature = a.sign(response)
+	_, err = webauthn.VerifyAssertion(rp, challenge, response, userHandle, a.publicKey, 0)
+	g.Expect(err).To(MatchError(ContainSubstring("unexpected client data type")))
+}
+
+func TestBase64URL(t *testing.T) {
+	g := NewWithT(t)
+	data, err := json.Marshal(webauthn.Base64URL{0xfb, 0xff})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(`"-_8"`))
+	var decoded webauthn.Base64URL
+	g.Expect(json.Unmarshal([]byte(`"-_8="`), &decoded)).To(Succeed())
+	g.Expect([]byte(decoded)).To(Equal([]byte{0xfb, 0xff}))
+}
@@ -1,9 +1,13 @@
 import {
   Application,
+  ApplicationDeletionCascade,
+  ApplicationDeletionImpact,
   ApplicationVersion,
+  ApplicationVersionBump,
   DeploymentRequest,
   DeploymentTarget,
   DeploymentTargetAccessResponse,
+  NextApplicationVersion,
 } from '../types';
 import {ConditionalPartial, defaultClientConfig} from './config';
 
@@ -20,6 +24,18 @@ export type ApplicationVersionFiles = {
   templateFile?: string;
 };
 
+export type CreateApplicationVersionOptions = {
+  /** Accept a version that is not higher than the highest version of an application with the 'strict' policy. */
+  force?: boolean;
+};
+
+export type DeleteApplicationOptions = {
+  /** Archive or uninstall the active deployments of the application. Required if there are any. */
+  cascade?: ApplicationDeletionCascade;
+  /** Purge the application immediately instead of keeping it restorable. */
+  force?: boolean;
+};
+
 /**
  * The low-level Distr API client. Each method represents on API endpoint.
  */
@@ -55,7 +71,8 @@ export class Client {
   public async createApplicationVersion(
     applicationId: string,
     version: ApplicationVersion,
-    files?: ApplicationVersionFiles
+    files?: ApplicationVersionFiles,
+    options?: CreateApplicationVersionOptions
   ): Promise<ApplicationVersion> {
     const formData = new FormData();
     formData.append('applicationversion', JSON.stringify(version));
@@ -68,7 +85,7 @@ export class Client {
     if (files?.templateFile) {
       formData.append('templatefile', new Blob([files.templateFile], {type: 'application/yaml'}));
     }
-    const path = `applications/${applicationId}/versions`;
+    const path = `applications/${applicationId}/versions${options?.force ? '?force=true' : ''}`;
     const response = await fetch(`${this.config.apiBase}${path}`, {
       method: 'POST',
       headers: {
@@ -80,6 +97,33 @@ export class Client {
     return this.handleResponse<ApplicationVersion>(response, 'POST', path);
   }
 
+  public async getApplicationDeletionImpact(applicationId: string): Promise<ApplicationDeletionImpact> {
+    return this.get<ApplicationDeletionImpact>(`applications/${applicationId}/deletion-impact`);
+  }
+
+  public async deleteApplication(applicationId: string, options?: DeleteApplicationOptions): Promise<void> {
+    const params = new URLSearchParams();
+    if (options?.cascade) {
+      params.set('cascade', options.cascade);
+    }
+    if (options?.force) {
+      params.set('force', 'true');
+    }
+    const query = params.toString();
+    return this.delete(`applications/${applicationId}${query ? `?${query}` : ''}`);
+  }
+
+  public async restoreApplication(applicationId: string): Promise<Application> {
+    return this.post<Application>(`applications/${applicationId}/restore`);
+  }
+
+  public async getNextApplicationVersion(
+    applicationId: string,
+    bump: ApplicationVersionBump = 'patch'
+  ): Promise<NextApplicationVersion> {
+    return this.get<NextApplicationVersion>(`applications/${applicationId}/versions/next?bump=${bump}`);
+  }
+
   public async getDeploymentTargets(): Promise<DeploymentTarget[]> {
     return this.get<DeploymentTarget[]>('deployment-targets');
   }
@@ -137,6 +181,19 @@ export class Client {
     return await this.handleResponse<T>(response, 'PUT', path);
   }
 
+  private async delete(path: string): Promise<void> {
+    const response = await fetch(`${this.config.apiBase}${path}`, {
+      method: 'DELETE',
+      headers: {
+        Accept: 'application/json',
+        Authorization: `AccessToken ${this.config.apiKey}`,
+      },
+    });
+    if (response.status < 200 || response.status >= 300) {
+      throw new Error(`DELETE ${path} failed: ${response.status} ${response.statusText} "${await response.text()}"`);
+    }
+  }
+
   private async handleResponse<T>(response: Response, method: string, path: string) {
     if (response.status < 200 || response.status >= 300) {
       throw new Error(`${method} ${path} failed: ${response.status} ${response.statusText} "${await response.text()}"`);
@@ -0,0 +1,24 @@
+import {BaseModel} from './base';
+
+/**
+ * Temporary access of a vendor user to a deployment target of a customer or to all deployment targets of a customer
+ * account. Exactly one of deploymentTargetId and customerUserAccountId is set.
+ */
+export interface AccessGrant extends BaseModel {
+  userAccountId: string;
+  deploymentTargetId?: string;
+  customerUserAccountId?: string;
+  grantedByUserAccountId?: string;
+  reason: string;
+  expiresAt: string;
+  revokedAt?: string;
+  revokedByUserAccountId?: string;
+}
+
+export interface CreateAccessGrantRequest {
+  userAccountId: string;
+  deploymentTargetId?: string;
+  customerUserAccountId?: string;
+  reason: string;
+  expiresAt: string;
+}
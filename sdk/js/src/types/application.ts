@@ -5,6 +5,56 @@ export interface Application extends BaseModel, Named {
   type: DeploymentType;
   imageUrl?: string;
   versions?: ApplicationVersion[];
+  resourceRequirements?: ResourceRequirements;
+  versionPolicy?: ApplicationVersionPolicy;
+  dependencies?: ApplicationDependency[];
+  /** Set while the application is deleted. It can be restored until deletionScheduledAt. */
+  deletedAt?: string;
+  deletionScheduledAt?: string;
+}
+
+/** What happens to the active deployments of an application that is deleted. */
+export type ApplicationDeletionCascade = 'archive' | 'uninstall';
+
+export interface ApplicationDeletionImpact {
+  deployments: ApplicationDeletionDeployment[];
+}
+
+export interface ApplicationDeletionDeployment {
+  deploymentId: string;
+  deploymentTargetId: string;
+  deploymentTargetName: string;
+  releaseName?: string;
+  uninstallRequestedAt?: string;
+}
+
+/**
+ * How strictly the names of new versions are validated.
+ * * 'lenient' accepts any name.
+ * * 'warn' accepts any name but reports names that are not semantic versions or not higher than the highest version.
+ * * 'strict' rejects names that are not semantic versions and versions that are not higher than the highest version,
+ *   unless they are forced.
+ */
+export type ApplicationVersionPolicy = 'lenient' | 'warn' | 'strict';
+
+export type ApplicationVersionBump = 'patch' | 'minor' | 'major';
+
+export interface NextApplicationVersion {
+  version: string;
+  basedOn?: string;
+}
+
+export interface ApplicationDependency {
+  applicationId: string;
+  dependsOnApplicationId: string;
+  dependsOnApplicationName: string;
+  minVersion?: string;
+  createdAt: string;
+}
+
+export interface ApplicationDependencyRequest {
+  applicationId: string;
+  minVersion?: string;
 }
 
 export interface ApplicationVersion {
@@ -17,6 +67,38 @@ export interface ApplicationVersion {
   chartName?: string;
   chartUrl?: string;
   chartVersion?: string;
+  resourceRequirements?: ResourceRequirements;
+  metricsEndpoint?: ApplicationMetricsEndpoint;
+  acknowledgmentMessage?: string;
+}
+
+export type ResourceRequirementsEnforcement = 'warn' | 'block';
+
+export interface ResourceRequirements {
+  minCpuCoresMillis?: number;
+  minMemoryBytes?: number;
+  minDiskBytes?: number;
+  architectures?: string[];
+  enforcement?: ResourceRequirementsEnforcement;
+}
+
+export interface ApplicationMetricsEndpoint {
+  service?: string;
+  port: number;
+  path: string;
+  series: string[];
+}
+
+export type AppMetricAlertOperator = 'gt' | 'gte' | 'lt' | 'lte' | 'eq' | 'ne';
+
+export interface ApplicationMetricAlertRule {
+  id?: string;
+  createdAt?: string;
+  applicationId?: string;
+  metric: string;
+  operator: AppMetricAlertOperator;
+  value: number;
+  forSeconds: number;
 }
 
 export interface PatchApplicationRequest {
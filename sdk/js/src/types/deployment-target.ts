@@ -1,9 +1,9 @@
 import {AgentVersion} from './agent-version';
 import {BaseModel, Named} from './base';
-import {DeploymentTargetScope, DeploymentType, DeploymentWithLatestRevision} from './deployment';
+import {DeploymentTargetScope, DeploymentType, DeploymentWithLatestRevision, Pause} from './deployment';
 import {UserAccountWithRole} from './user-account';
 
-export interface DeploymentTarget extends BaseModel, Named {
+export interface DeploymentTarget extends BaseModel, Named, Pause {
   name: string;
   type: DeploymentType;
   namespace?: string;
@@ -18,6 +18,125 @@ export interface DeploymentTarget extends BaseModel, Named {
   agentVersion?: AgentVersion;
   reportedAgentVersionId?: string;
   metricsEnabled: boolean;
+  inventoryEnabled?: boolean;
+  production?: boolean;
+  clockSkewMs?: number;
+  clockSkewMeasuredAt?: string;
+  vendorDataCollection?: DataCollection;
+  customerDataCollection?: DataCollection;
+  /**
+   * The effective data collection policy, i.e. the stricter combination of vendorDataCollection and
+   * customerDataCollection.
+   */
+  dataCollection?: DataCollection;
+  /**
+   * Overrides the agent resource limits of the organization for this deployment target.
+   */
+  agentResourceLimits?: AgentResourceLimits;
+  /**
+   * The combination of the limits of the organization and agentResourceLimits, which is sent to the agent.
+   */
+  effectiveAgentResourceLimits?: AgentResourceLimits;
+  appliedAgentResourceLimits?: AppliedAgentResourceLimits;
+  /**
+   * True if the deployment target has been pre-registered by the vendor and its agent has not connected yet.
+   */
+  awaitingConnection?: boolean;
+}
+
+export interface AgentResourceLimits {
+  maxConcurrentPulls?: number;
+  maxDownloadBytesPerSecond?: number;
+  maxProcs?: number;
+  niceLevel?: number;
+  logLevel?: 'debug' | 'info' | 'warn' | 'error';
+}
+
+export interface AppliedAgentResourceLimits {
+  limits: AgentResourceLimits;
+  /**
+   * The names of the requested limits that the agent or its host does not support.
+   */
+  unsupported?: (keyof AgentResourceLimits)[];
+  reportedAt: string;
+}
+
+export interface DataCollection {
+  logsDisabled: boolean;
+  metricsDisabled: boolean;
+  diagnosticsDisabled: boolean;
+  inventoryDisabled: boolean;
+}
+
+export interface DeploymentTargetDataPurge {
+  id: string;
+  createdAt: string;
+  deploymentTargetId: string;
+  logs: boolean;
+  metrics: boolean;
+  diagnostics: boolean;
+  inventory: boolean;
+  completedAt?: string;
+}
+
+/**
+ * Glob patterns of the paths on the host of a deployment target that may be retrieved. It is maintained by the
+ * customer for deployment targets of customers and by the vendor otherwise.
+ */
+export interface DeploymentTargetFileRetrievalAllowList {
+  updatedAt?: string;
+  allowedPaths: string[];
+}
+
+export type FileRetrievalStatus = 'pending' | 'completed' | 'denied' | 'failed' | 'expired';
+
+export interface DeploymentTargetFileRetrieval {
+  id: string;
+  createdAt: string;
+  deploymentTargetId: string;
+  requestedBy?: string;
+  path: string;
+  status: FileRetrievalStatus;
+  reportedAt?: string;
+  size?: number;
+  redactedSecrets?: number;
+  error?: string;
+}
+
+export interface DeploymentTargetFileRetrievalWithContent extends DeploymentTargetFileRetrieval {
+  /**
+   * The base64 encoded content of the file, in which secrets have been redacted.
+   */
+  content?: string;
+}
+
+export interface InventoryItem {
+  /**
+   * "container" for docker or the kind of the kubernetes resource, e.g. "Deployment"
+   */
+  kind: string;
+  name: string;
+  images: string[];
+  state: string;
+  /**
+   * true if the workload belongs to a deployment managed by Distr, false if it is foreign
+   */
+  managed: boolean;
+}
+
+export interface InventoryDiff {
+  added: InventoryItem[];
+  removed: InventoryItem[];
+  changed: InventoryItem[];
+}
+
+export interface DeploymentTargetInventory {
+  deploymentTargetId: string;
+  reportedAt: string;
+  previousReportedAt?: string;
+  items: InventoryItem[];
+  truncated: boolean;
+  diff?: InventoryDiff;
 }
 
 export interface DeploymentTargetStatus extends BaseModel {
@@ -1,10 +1,33 @@
 import {BaseModel} from './base';
 
-export interface Deployment extends BaseModel {
+/**
+ * While a deployment or its deployment target is paused, the agent does not apply the deployment, but keeps reporting
+ * its status, and no new revisions can be created.
+ */
+export interface Pause {
+  pausedAt?: string;
+  pausedByUserAccountId?: string;
+  pauseReason?: string;
+  /**
+   * The time at which the pause is ended automatically, if any.
+   */
+  resumeAt?: string;
+}
+
+export interface PauseRequest {
+  reason: string;
+  resumeAt?: string;
+}
+
+export interface Deployment extends BaseModel, Pause {
   deploymentTargetId: string;
   releaseName?: string;
   dockerType?: DockerType;
   logsEnabled: boolean;
+  uninstallRequestedAt?: string;
+  uninstallRequestedByUserAccountId?: string;
+  uninstallDeleteData: boolean;
+  uninstalledAt?: string;
 }
 
 export interface DeploymentRequest {
@@ -16,6 +39,7 @@ export interface DeploymentRequest {
   dockerType?: DockerType;
   valuesYaml?: string;
   envFileData?: string;
+  reason?: string;
 }
 
 export interface PatchDeploymentRequest {
@@ -33,11 +57,79 @@ export interface DeploymentWithLatestRevision extends Deployment {
   deploymentRevisionId?: string;
   deploymentRevisionCreatedAt?: string;
   latestStatus?: DeploymentRevisionStatus;
+  /**
+   * True if the deployment or its deployment target is paused.
+   */
+  paused: boolean;
+}
+
+export interface DeploymentAppMetric {
+  name: string;
+  labels?: Record<string, string>;
+  value: number;
+  updatedAt: string;
+}
+
+export interface DeploymentRevision extends BaseModel {
+  deploymentId: string;
+  applicationVersionId: string;
+  applicationVersionName: string;
+  reason?: string;
+  operationId: string;
+  acknowledgmentRequired: boolean;
+  acknowledgedAt?: string;
+  acknowledgedByUserAccountId?: string;
+  acknowledgmentOverridden: boolean;
+}
+
+export interface PendingDeploymentAcknowledgment {
+  deploymentRevisionId: string;
+  createdAt: string;
+  deploymentId: string;
+  deploymentTargetId: string;
+  deploymentTargetName: string;
+  customerUserAccountId?: string;
+  customerEmail?: string;
+  applicationName: string;
+  applicationVersionName: string;
+  acknowledgmentMessage: string;
+  acknowledgmentRemindedAt?: string;
+}
+
+export interface AcknowledgeDeploymentRevisionRequest {
+  override?: boolean;
+  reason?: string;
+}
+
+export interface DeploymentUninstallRequest {
+  deleteData: boolean;
+  confirmApplicationName?: string;
+}
+
+export interface DeploymentDependencies {
+  dependsOn: string[];
+  dependedOnBy: string[];
+}
+
+export interface DeploymentTimelineEvent {
+  time: string;
+  source: 'server' | 'agent';
+  type: 'revision_created' | 'status' | 'log';
+  severity?: string;
+  resource?: string;
+  message: string;
+  approximate: boolean;
+}
+
+export interface DeploymentRevisionTimeline extends DeploymentRevision {
+  events: DeploymentTimelineEvent[];
+  approximate: boolean;
 }
 
 export interface DeploymentRevisionStatus extends BaseModel {
   type: DeploymentStatusType;
   message: string;
+  errorReason?: DeploymentStatusErrorReason;
 }
 
 export type DeploymentType = 'docker' | 'kubernetes';
@@ -46,6 +138,8 @@ export type HelmChartType = 'repository' | 'oci';
 
 export type DockerType = 'compose' | 'swarm';
 
-export type DeploymentStatusType = 'ok' | 'progressing' | 'error';
+export type DeploymentStatusType = 'ok' | 'progressing' | 'error' | 'uninstalled';
+
+export type DeploymentStatusErrorReason = 'secret_resolution_failed';
 
 export type DeploymentTargetScope = 'cluster' | 'namespace';
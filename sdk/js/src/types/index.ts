@@ -1,3 +1,4 @@
+export * from './access-grant';
 export * from './access-token';
 export * from './agent-version';
 export * from './application';
@@ -5,4 +6,5 @@ export * from './base';
 export * from './deployment';
 export * from './deployment-target';
 export * from './organization-branding';
+export * from './sent-mail';
 export * from './user-account';
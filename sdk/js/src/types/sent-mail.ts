@@ -0,0 +1,33 @@
+import {BaseModel} from './base';
+
+export type MailType =
+  | 'other'
+  | 'invite_user'
+  | 'invite_customer'
+  | 'verify_email'
+  | 'password_reset'
+  | 'security_event'
+  | 'upstream_watch_changed'
+  | 'certificate_expiring'
+  | 'app_metric_alert_firing'
+  | 'organization_mail_config_disabled'
+  | 'artifact_deletion_requested'
+  | 'access_grant_created'
+  | 'deployment_acknowledgment_required';
+
+export type SentMailStatus = 'sent' | 'failed' | 'delivered' | 'bounced' | 'complained';
+
+export interface SentMail extends BaseModel {
+  recipient: string;
+  type: MailType;
+  subject: string;
+  providerMessageId?: string;
+  status: SentMailStatus;
+  statusDetails?: string;
+  statusUpdatedAt: string;
+}
+
+export interface ResendMailRequest {
+  type: 'invite_user' | 'invite_customer' | 'verify_email' | 'password_reset';
+  userAccountId: string;
+}